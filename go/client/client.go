@@ -0,0 +1,261 @@
+// Package client 时区 SAAS 服务的 Go 客户端，封装全部 HTTP 接口，
+// 请求和响应使用 models 包中的类型，内部服务调用 API 时应使用本包而不是自行拼装 HTTP 请求。
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"timezone-saas-demo/models"
+)
+
+// 重试的默认配置
+const (
+	// DefaultMaxRetries 幂等请求失败后的最大重试次数
+	DefaultMaxRetries = 2
+	// DefaultRetryWait 第一次重试前的等待时间，之后每次翻倍；响应带 Retry-After 时以其为准
+	DefaultRetryWait = 200 * time.Millisecond
+	// maxRetryWait 单次等待的上限
+	maxRetryWait = 10 * time.Second
+)
+
+// Client 时区 SAAS 服务客户端，可并发使用
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	adminToken string
+	apiKey     string
+	tenant     string
+	language   string
+	maxRetries int
+	retryWait  time.Duration
+}
+
+// Option 客户端选项
+type Option func(*Client)
+
+// WithHTTPClient 使用指定的 http.Client，默认 30 秒超时
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithAdminToken 设置 /api/admin 接口的 Bearer 令牌（服务端的 ADMIN_TOKEN）
+func WithAdminToken(token string) Option {
+	return func(c *Client) { c.adminToken = token }
+}
+
+// WithAPIKey 设置 X-API-Key 请求头（开通租户时返回的 api_key.secret），服务端按密钥所属的商户确定租户
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithTenant 设置 X-Tenant-ID 请求头，分析查询按租户限流
+func WithTenant(tenant string) Option {
+	return func(c *Client) { c.tenant = tenant }
+}
+
+// WithLanguage 设置 Accept-Language 请求头，影响 message 和本地化展示字段
+func WithLanguage(lang string) Option {
+	return func(c *Client) { c.language = lang }
+}
+
+// WithRetries 设置幂等请求的重试次数和首次等待时间，maxRetries 为 0 时不重试
+func WithRetries(maxRetries int, wait time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.retryWait = wait
+	}
+}
+
+// New 创建客户端，baseURL 为服务地址，如 http://localhost:8080
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("无效的服务地址 %q", baseURL)
+	}
+	c := &Client{
+		baseURL:    u,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		maxRetries: DefaultMaxRetries,
+		retryWait:  DefaultRetryWait,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// APIError 服务端返回的失败响应
+type APIError struct {
+	StatusCode int
+	// Code 稳定的消息代码（如 orders.list_failed），Message 为按语言渲染的文案，Detail 为原始错误信息
+	Code    string
+	Message string
+	Detail  string
+	// RetryAfter 服务端建议的重试间隔（Retry-After 响应头），没有时为 0
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.Detail != "" {
+		return fmt.Sprintf("%d %s: %s", e.StatusCode, e.Code, e.Detail)
+	}
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// IsStatus 判断 err 是否为指定 HTTP 状态码的 APIError
+func IsStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// envelope 服务端统一的响应格式
+type envelope struct {
+	Success bool             `json:"success"`
+	Code    string           `json:"code"`
+	Message string           `json:"message"`
+	Data    json.RawMessage  `json:"data"`
+	Meta    *models.PageMeta `json:"meta"`
+	Error   string           `json:"error"`
+}
+
+// request 一次 API 调用
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   interface{}
+	// raw 原样发送的请求体（如需要签名的 Webhook），设置时忽略 body
+	raw    []byte
+	header http.Header
+	admin  bool
+	// idempotent POST 请求也可以安全重试，如批量转换
+	idempotent bool
+}
+
+// do 发送请求并把 data 解码到 out（为 nil 时忽略），返回列表接口的分页信息
+// GET/PUT/DELETE 和标记为幂等的 POST 在网络错误、429、502、503、504 时按退避重试
+func (c *Client) do(ctx context.Context, req request, out interface{}) (*models.PageMeta, error) {
+	body := req.raw
+	if body == nil && req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil {
+			return nil, fmt.Errorf("序列化请求体失败: %w", err)
+		}
+	}
+	retryable := req.idempotent || req.method != http.MethodPost
+
+	for attempt := 0; ; attempt++ {
+		meta, wait, err := c.send(ctx, req, body, out)
+		if err == nil {
+			return meta, nil
+		}
+		if !retryable || attempt >= c.maxRetries || wait < 0 {
+			return nil, err
+		}
+		if wait == 0 {
+			wait = c.retryWait << attempt
+		}
+		wait = min(wait, maxRetryWait)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+// send 发送一次请求；失败时 wait 为建议的重试等待时间，0 表示按退避等待，小于 0 表示不应重试
+func (c *Client) send(ctx context.Context, req request, body []byte, out interface{}) (*models.PageMeta, time.Duration, error) {
+	u := *c.baseURL
+	u.Path += req.path
+	u.RawQuery = req.query.Encode()
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u.String(), reader)
+	if err != nil {
+		return nil, -1, fmt.Errorf("创建请求失败: %w", err)
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	for key, values := range req.header {
+		httpReq.Header[key] = values
+	}
+	if c.apiKey != "" {
+		httpReq.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.tenant != "" {
+		httpReq.Header.Set("X-Tenant-ID", c.tenant)
+	}
+	if c.language != "" {
+		httpReq.Header.Set("Accept-Language", c.language)
+	}
+	if req.admin && c.adminToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.adminToken)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, -1, ctx.Err()
+		}
+		return nil, 0, fmt.Errorf("%s %s 请求失败: %w", req.method, req.path, err)
+	}
+	defer resp.Body.Close()
+
+	var env envelope
+	decodeErr := json.NewDecoder(resp.Body).Decode(&env)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &APIError{
+			StatusCode: resp.StatusCode,
+			Code:       env.Code,
+			Message:    env.Message,
+			Detail:     env.Error,
+			RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+		}
+		if decodeErr != nil && apiErr.Message == "" {
+			apiErr.Message = resp.Status
+		}
+		switch resp.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return nil, apiErr.RetryAfter, apiErr
+		}
+		return nil, -1, apiErr
+	}
+	if decodeErr != nil {
+		return nil, -1, fmt.Errorf("%s %s 解析响应失败: %w", req.method, req.path, decodeErr)
+	}
+
+	if out != nil && len(env.Data) > 0 && string(env.Data) != "null" {
+		if err := json.Unmarshal(env.Data, out); err != nil {
+			return nil, -1, fmt.Errorf("%s %s 解析 data 失败: %w", req.method, req.path, err)
+		}
+	}
+	return env.Meta, 0, nil
+}
+
+// retryAfter 解析以秒为单位的 Retry-After 响应头
+func retryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
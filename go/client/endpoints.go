@@ -0,0 +1,1575 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/mailtemplate"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/tzdb"
+)
+
+// Page 列表接口的分页参数，Limit 为 0 时使用接口的默认值
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// set 写入 limit/offset 查询参数
+func (p Page) set(query url.Values) {
+	if p.Limit > 0 {
+		query.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Offset > 0 {
+		query.Set("offset", strconv.Itoa(p.Offset))
+	}
+}
+
+// OrdersParams 订单列表的查询条件
+type OrdersParams struct {
+	Page
+	Timezone string
+	Statuses []string
+}
+
+// AnalysisParams 分析接口的查询条件，Date 为空时使用服务端的今天
+type AnalysisParams struct {
+	Date     string
+	Currency string
+	Statuses []string
+	// AggregateTimezone 非空时按该时区（如 Europe/London）划分日期和小时，需要通过 WithAdminToken 设置管理令牌
+	AggregateTimezone string
+}
+
+// AnalysisBatchParams 批量分析的查询条件，Dates、Month（YYYY-MM）和 Range 只能指定一个
+// Range 为相对范围，如 last_30_days、this_month，按 UTC（或 AggregateTimezone）的今天计算
+type AnalysisBatchParams struct {
+	Dates    []string
+	Month    string
+	Range    string
+	Currency string
+	Statuses []string
+	// AggregateTimezone 与 AnalysisParams 相同
+	AggregateTimezone string
+}
+
+// CohortParams 注册周群组分析的查询条件，零值字段使用服务端默认值
+// From、To 为注册日期范围（商户本地日期），也可以用 Range 指定相对范围（如 last_month）；WeekStart 为 ISO 星期 1~7
+type CohortParams struct {
+	From      string
+	To        string
+	Range     string
+	Weeks     int
+	WeekStart int
+	Statuses  []string
+}
+
+// PeakHoursParams 高峰小时分析的查询条件，To 为统计的最后一个商户本地日期
+type PeakHoursParams struct {
+	Weeks      int
+	To         string
+	Confidence float64
+	Statuses   []string
+}
+
+// FulfillmentParams 履约时长分析的查询条件，To 为统计的最后一个商户本地下单日期
+type FulfillmentParams struct {
+	Weeks    int
+	To       string
+	Statuses []string
+}
+
+// DashboardParams 首页概览的查询条件，Currency 和 Statuses 为空时不过滤
+type DashboardParams struct {
+	MerchantID int
+	Currency   string
+	Statuses   []string
+}
+
+// ChangesParams 变更流水的查询条件，Since 为上一次返回的 NextSeq，Wait 大于 0 时没有新变更则等待
+type ChangesParams struct {
+	Since      int64
+	MerchantID int
+	Entities   []string
+	Limit      int
+	Wait       time.Duration
+}
+
+// OverlapParams 营业时间重叠分析的参数，字段含义见 /api/timezone/overlap
+type OverlapParams struct {
+	MerchantIDs []int
+	Date        string
+	Timezone    string
+	Window      time.Duration
+	Step        time.Duration
+	Limit       int
+}
+
+// ScheduleParams 重复规则展开的参数，MerchantID 大于 0 时使用商户时区，否则使用 Timezone
+// Range 为相对范围（如 this_month），按规则所用时区的今天计算，与 From、To 只能指定一种
+type ScheduleParams struct {
+	Rule       string
+	MerchantID int
+	Timezone   string
+	From       string
+	To         string
+	Range      string
+	Gap        string
+	Overlap    string
+	Limit      int
+}
+
+// OverviewParams 运营看板的参数，零值使用服务端默认值
+type OverviewParams struct {
+	Sort       string
+	Order      string
+	Window     time.Duration
+	StaleAfter time.Duration
+	ErrorRate  float64
+}
+
+// RefundRequest 创建退款的请求体，Amount 为零时退还剩余全部金额，RefundedAt 为零值时取当前时间
+type RefundRequest struct {
+	Amount     decimal.Decimal `json:"amount"`
+	Reason     string          `json:"reason,omitempty"`
+	RefundedAt time.Time       `json:"-"`
+	Operator   string          `json:"operator,omitempty"`
+}
+
+// AdjustRequest 迟到订单调整的请求体，字段均可省略
+type AdjustRequest struct {
+	Date       string `json:"date,omitempty"`
+	MerchantID int    `json:"merchant_id,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	Operator   string `json:"operator,omitempty"`
+}
+
+// FaultRule 故障注入规则，Kind 为 latency、error 或 db_drop，Percent 为 0~100 的注入比例
+// Route（路径前缀）、Method、Tenant 为空时匹配全部 API 请求；TTL 为 0 时规则一直生效直到被删除
+type FaultRule struct {
+	Kind    string
+	Percent float64
+	Route   string
+	Method  string
+	Tenant  string
+	Latency time.Duration
+	Jitter  time.Duration
+	// Status error 规则返回的 5xx 状态码，为 0 时随机使用 500、502、503
+	Status int
+	TTL    time.Duration
+}
+
+// body 转换为接口的请求体，时长按 Go duration 格式传递
+func (f FaultRule) body() map[string]interface{} {
+	body := map[string]interface{}{"kind": f.Kind, "percent": f.Percent}
+	for name, value := range map[string]string{"route": f.Route, "method": f.Method, "tenant": f.Tenant} {
+		if value != "" {
+			body[name] = value
+		}
+	}
+	for name, d := range map[string]time.Duration{"latency": f.Latency, "jitter": f.Jitter, "ttl": f.TTL} {
+		if d > 0 {
+			body[name] = d.String()
+		}
+	}
+	if f.Status != 0 {
+		body["status"] = f.Status
+	}
+	return body
+}
+
+// OnboardRequest 商户入驻的请求体，字段含义与 /api/merchants/onboard 一致
+type OnboardRequest struct {
+	Name               string   `json:"name"`
+	Code               string   `json:"code,omitempty"`
+	Country            string   `json:"country,omitempty"`
+	City               string   `json:"city,omitempty"`
+	Address            string   `json:"address,omitempty"`
+	Timezone           string   `json:"timezone,omitempty"`
+	BusinessHoursStart string   `json:"business_hours_start,omitempty"`
+	BusinessHoursEnd   string   `json:"business_hours_end,omitempty"`
+	WeekendDays        []int    `json:"weekend_days,omitempty"`
+	Lat                *float64 `json:"lat,omitempty"`
+	Lon                *float64 `json:"lon,omitempty"`
+	ContactEmail       string   `json:"contact_email,omitempty"`
+	DryRun             bool     `json:"dry_run,omitempty"`
+}
+
+// ReportDefinitionRequest 报表定义的请求体，字段含义与 /api/reports/definitions 一致
+type ReportDefinitionRequest struct {
+	Name         string   `json:"name"`
+	MerchantID   int      `json:"merchant_id,omitempty"`
+	TimezoneMode string   `json:"timezone_mode,omitempty"`
+	RangeType    string   `json:"range_type,omitempty"`
+	From         string   `json:"from,omitempty"`
+	To           string   `json:"to,omitempty"`
+	Statuses     []string `json:"statuses,omitempty"`
+	Currency     string   `json:"currency,omitempty"`
+	Format       string   `json:"format,omitempty"`
+	Schedule     string   `json:"schedule,omitempty"`
+	Emails       []string `json:"emails,omitempty"`
+	Operator     string   `json:"operator,omitempty"`
+}
+
+// AlertRuleRequest 告警规则的请求体，字段含义与 /api/alerts/rules 一致；Enabled 为 nil 时启用
+type AlertRuleRequest struct {
+	Name             string   `json:"name"`
+	MerchantID       int      `json:"merchant_id"`
+	Type             string   `json:"type"`
+	WindowHours      int      `json:"window_hours,omitempty"`
+	ThresholdPercent int      `json:"threshold_percent,omitempty"`
+	Currency         string   `json:"currency,omitempty"`
+	Statuses         []string `json:"statuses,omitempty"`
+	WebhookURL       string   `json:"webhook_url,omitempty"`
+	Emails           []string `json:"emails,omitempty"`
+	Enabled          *bool    `json:"enabled,omitempty"`
+	Operator         string   `json:"operator,omitempty"`
+}
+
+// EmailTemplateRequest 邮件模板的请求体，字段含义与 /api/email/templates/{name} 一致
+type EmailTemplateRequest struct {
+	Subject  string `json:"subject"`
+	HTML     string `json:"html"`
+	Text     string `json:"text,omitempty"`
+	Operator string `json:"operator,omitempty"`
+}
+
+// Health 健康检查结果
+type Health struct {
+	Timestamp string    `json:"timestamp"`
+	Version   string    `json:"version"`
+	Service   string    `json:"service"`
+	TZData    tzdb.Info `json:"tzdata"`
+}
+
+// Health 健康检查
+func (c *Client) Health(ctx context.Context) (*Health, error) {
+	var health Health
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/health"}, &health); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+// Live 存活探针
+func (c *Client) Live(ctx context.Context) error {
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/api/health/live"}, nil)
+	return err
+}
+
+// Ready 就绪探针，数据库不可用时返回 503 的 APIError
+func (c *Client) Ready(ctx context.Context) error {
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/api/health/ready"}, nil)
+	return err
+}
+
+// Docs 接口文档
+func (c *Client) Docs(ctx context.Context) (map[string]interface{}, error) {
+	var docs map[string]interface{}
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/docs"}, &docs); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// TenantQueryStats 各租户分析查询的并发和排队统计
+func (c *Client) TenantQueryStats(ctx context.Context) ([]models.TenantQueryStats, error) {
+	var stats []models.TenantQueryStats
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/metrics/tenants"}, &stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// SlowQueries 最近的慢查询，limit 为 0 时使用服务端默认值；需要管理令牌
+func (c *Client) SlowQueries(ctx context.Context, limit int) ([]database.SlowQuery, error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var queries []database.SlowQuery
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/admin/slow-queries", query: query, admin: true}, &queries); err != nil {
+		return nil, err
+	}
+	return queries, nil
+}
+
+// CircuitBreaker 数据库熔断器状态；需要管理令牌
+func (c *Client) CircuitBreaker(ctx context.Context) (*database.BreakerStatus, error) {
+	var status database.BreakerStatus
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/admin/circuit-breaker", admin: true}, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// RuntimeDiagnostics 服务进程的 goroutine、内存、GC 和数据库连接池统计；需要管理令牌
+func (c *Client) RuntimeDiagnostics(ctx context.Context) (*models.RuntimeDiagnostics, error) {
+	var diag models.RuntimeDiagnostics
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/admin/runtime", admin: true}, &diag); err != nil {
+		return nil, err
+	}
+	return &diag, nil
+}
+
+// ResponseCache 分析和时区对比接口的响应缓存统计；需要管理令牌
+func (c *Client) ResponseCache(ctx context.Context) (*models.ResponseCacheStats, error) {
+	var stats models.ResponseCacheStats
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/admin/cache", admin: true}, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// PurgeResponseCache 清空响应缓存，返回清除的条数；tenant 不为空时只清空该租户，需要管理令牌
+func (c *Client) PurgeResponseCache(ctx context.Context, tenant string) (int, error) {
+	query := url.Values{}
+	setString(query, "tenant", tenant)
+	var result struct {
+		Purged int `json:"purged"`
+	}
+	if _, err := c.do(ctx, request{method: http.MethodDelete, path: "/api/admin/cache", query: query, admin: true}, &result); err != nil {
+		return 0, err
+	}
+	return result.Purged, nil
+}
+
+// IndexAdvice 订单和商户表的索引建议；需要管理令牌
+func (c *Client) IndexAdvice(ctx context.Context) (*models.IndexAdviceReport, error) {
+	var report models.IndexAdviceReport
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/admin/index-advice", admin: true}, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ReloadTZData 重新加载服务端的 zoneinfo；需要管理令牌
+func (c *Client) ReloadTZData(ctx context.Context) (*tzdb.Info, error) {
+	var info tzdb.Info
+	req := request{method: http.MethodPost, path: "/api/admin/tzdata/reload", admin: true, idempotent: true}
+	if _, err := c.do(ctx, req, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// AdminOverview 跨租户运营看板；需要管理令牌
+func (c *Client) AdminOverview(ctx context.Context, params OverviewParams) (*models.AdminOverview, error) {
+	query := url.Values{}
+	setString(query, "sort", params.Sort)
+	setString(query, "order", params.Order)
+	setDuration(query, "window", params.Window)
+	setDuration(query, "stale_after", params.StaleAfter)
+	if params.ErrorRate > 0 {
+		query.Set("error_rate", strconv.FormatFloat(params.ErrorRate, 'f', -1, 64))
+	}
+	var overview models.AdminOverview
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/admin/overview", query: query, admin: true}, &overview); err != nil {
+		return nil, err
+	}
+	return &overview, nil
+}
+
+// FaultRules 当前生效的故障注入规则及命中统计；需要管理令牌，服务端须设置 FAULT_INJECTION=true
+func (c *Client) FaultRules(ctx context.Context) ([]models.FaultRule, error) {
+	var rules []models.FaultRule
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/admin/faults", admin: true}, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// AddFaultRule 添加故障注入规则；需要管理令牌
+func (c *Client) AddFaultRule(ctx context.Context, rule FaultRule) (*models.FaultRule, error) {
+	var created models.FaultRule
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/api/admin/faults", body: rule.body(), admin: true}, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// DeleteFaultRule 删除一条故障注入规则；需要管理令牌
+func (c *Client) DeleteFaultRule(ctx context.Context, id int64) error {
+	path := fmt.Sprintf("/api/admin/faults/%d", id)
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: path, admin: true}, nil)
+	return err
+}
+
+// ClearFaultRules 删除全部故障注入规则；需要管理令牌
+func (c *Client) ClearFaultRules(ctx context.Context) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: "/api/admin/faults", admin: true}, nil)
+	return err
+}
+
+// Captures 最近的录制记录，按时间倒序；tenant 为空时返回全部租户，limit 为 0 时使用默认值 50；需要管理令牌
+func (c *Client) Captures(ctx context.Context, tenant string, limit int) ([]models.RequestCapture, error) {
+	query := url.Values{}
+	setString(query, "tenant", tenant)
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var captures []models.RequestCapture
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/admin/captures", query: query, admin: true}, &captures); err != nil {
+		return nil, err
+	}
+	return captures, nil
+}
+
+// Capture 单条录制记录；需要管理令牌
+func (c *Client) Capture(ctx context.Context, id int64) (*models.RequestCapture, error) {
+	var capture models.RequestCapture
+	path := fmt.Sprintf("/api/admin/captures/%d", id)
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: path, admin: true}, &capture); err != nil {
+		return nil, err
+	}
+	return &capture, nil
+}
+
+// ClearCaptures 删除录制记录，tenant 为空时删除全部；需要管理令牌
+func (c *Client) ClearCaptures(ctx context.Context, tenant string) error {
+	query := url.Values{}
+	setString(query, "tenant", tenant)
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: "/api/admin/captures", query: query, admin: true}, nil)
+	return err
+}
+
+// CaptureTenants 正在录制的租户；需要管理令牌
+func (c *Client) CaptureTenants(ctx context.Context) ([]models.CaptureTenant, error) {
+	var tenants []models.CaptureTenant
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/admin/captures/tenants", admin: true}, &tenants); err != nil {
+		return nil, err
+	}
+	return tenants, nil
+}
+
+// EnableCapture 为租户开启录制，ttl 为 0 时服务端默认 1 小时；需要管理令牌
+func (c *Client) EnableCapture(ctx context.Context, tenant string, ttl time.Duration) (*models.CaptureTenant, error) {
+	body := map[string]string{}
+	if ttl > 0 {
+		body["ttl"] = ttl.String()
+	}
+	var enabled models.CaptureTenant
+	req := request{method: http.MethodPut, path: "/api/admin/captures/tenants/" + url.PathEscape(tenant), body: body, admin: true}
+	if _, err := c.do(ctx, req, &enabled); err != nil {
+		return nil, err
+	}
+	return &enabled, nil
+}
+
+// DisableCapture 关闭租户的录制；需要管理令牌
+func (c *Client) DisableCapture(ctx context.Context, tenant string) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: "/api/admin/captures/tenants/" + url.PathEscape(tenant), admin: true}, nil)
+	return err
+}
+
+// ReplayCapture 在服务端重放录制的 GET 请求并与录制时的响应比较；需要管理令牌
+func (c *Client) ReplayCapture(ctx context.Context, id int64) (*models.CaptureReplay, error) {
+	var replay models.CaptureReplay
+	path := fmt.Sprintf("/api/admin/captures/%d/replay", id)
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: path, admin: true, idempotent: true}, &replay); err != nil {
+		return nil, err
+	}
+	return &replay, nil
+}
+
+// TimezoneDemo asOf 时刻的时区演示数据，asOf 为零值时为服务端的当前时间
+func (c *Client) TimezoneDemo(ctx context.Context, asOf time.Time) (*models.TimezoneDemo, error) {
+	query := url.Values{}
+	if !asOf.IsZero() {
+		query.Set("as_of", asOf.UTC().Format(time.RFC3339))
+	}
+	var demo models.TimezoneDemo
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/timezone/demo", query: query}, &demo); err != nil {
+		return nil, err
+	}
+	return &demo, nil
+}
+
+// Merchants 商户列表，page 为零值时返回全部商户
+func (c *Client) Merchants(ctx context.Context, page Page) ([]models.Merchant, *models.PageMeta, error) {
+	query := url.Values{}
+	page.set(query)
+	var merchants []models.Merchant
+	meta, err := c.do(ctx, request{method: http.MethodGet, path: "/api/timezone/merchants", query: query}, &merchants)
+	if err != nil {
+		return nil, nil, err
+	}
+	return merchants, meta, nil
+}
+
+// MerchantBoundaries 商户在 at 之后的本地时间边界，at 为零值时使用当前时间
+func (c *Client) MerchantBoundaries(ctx context.Context, merchantID int, at time.Time) (*models.MerchantBoundaries, error) {
+	query := url.Values{}
+	setTime(query, "at", at)
+	var boundaries models.MerchantBoundaries
+	path := fmt.Sprintf("/api/timezone/merchants/%d/boundaries", merchantID)
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: path, query: query}, &boundaries); err != nil {
+		return nil, err
+	}
+	return &boundaries, nil
+}
+
+// MerchantPeakHours 商户最近若干周的高峰本地小时和建议排班时段，params 的零值字段使用服务端默认值
+func (c *Client) MerchantPeakHours(ctx context.Context, merchantID int, params PeakHoursParams) (*models.PeakHoursReport, error) {
+	query := url.Values{}
+	if params.Weeks > 0 {
+		query.Set("weeks", strconv.Itoa(params.Weeks))
+	}
+	setString(query, "to", params.To)
+	if params.Confidence > 0 {
+		query.Set("confidence", strconv.FormatFloat(params.Confidence, 'f', -1, 64))
+	}
+	setString(query, "status", strings.Join(params.Statuses, ","))
+	var report models.PeakHoursReport
+	path := fmt.Sprintf("/api/timezone/merchants/%d/peak-hours", merchantID)
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: path, query: query}, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// MerchantFulfillment 商户最近若干周下单到发货的时长，按下单的本地小时和星期分组，params 的零值字段使用服务端默认值
+func (c *Client) MerchantFulfillment(ctx context.Context, merchantID int, params FulfillmentParams) (*models.FulfillmentReport, error) {
+	query := url.Values{}
+	if params.Weeks > 0 {
+		query.Set("weeks", strconv.Itoa(params.Weeks))
+	}
+	setString(query, "to", params.To)
+	setString(query, "status", strings.Join(params.Statuses, ","))
+	var report models.FulfillmentReport
+	path := fmt.Sprintf("/api/timezone/merchants/%d/fulfillment", merchantID)
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: path, query: query}, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Orders 获取一页订单
+func (c *Client) Orders(ctx context.Context, params OrdersParams) ([]models.OrderAnalysis, *models.PageMeta, error) {
+	query := url.Values{}
+	params.Page.set(query)
+	setString(query, "timezone", params.Timezone)
+	setString(query, "status", strings.Join(params.Statuses, ","))
+	var orders []models.OrderAnalysis
+	meta, err := c.do(ctx, request{method: http.MethodGet, path: "/api/timezone/orders", query: query}, &orders)
+	if err != nil {
+		return nil, nil, err
+	}
+	return orders, meta, nil
+}
+
+// EachOrder 从 params.Offset 开始逐页获取订单并回调 fn，直到最后一页；fn 返回错误时停止并返回该错误
+func (c *Client) EachOrder(ctx context.Context, params OrdersParams, fn func(models.OrderAnalysis) error) error {
+	for {
+		orders, meta, err := c.Orders(ctx, params)
+		if err != nil {
+			return err
+		}
+		for _, order := range orders {
+			if err := fn(order); err != nil {
+				return err
+			}
+		}
+		if meta == nil || !meta.HasMore || len(orders) == 0 {
+			return nil
+		}
+		params.Offset = meta.Offset + meta.Count
+	}
+}
+
+// OrderRefunds 订单及其退款记录
+func (c *Client) OrderRefunds(ctx context.Context, orderID int) (*models.OrderRefunds, error) {
+	var order models.OrderRefunds
+	path := fmt.Sprintf("/api/timezone/orders/%d/refunds", orderID)
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: path}, &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// CreateRefund 为订单创建一笔（部分）退款，不会自动重试
+func (c *Client) CreateRefund(ctx context.Context, orderID int, req RefundRequest) (*models.Refund, error) {
+	body := struct {
+		RefundRequest
+		RefundedAt string `json:"refunded_at,omitempty"`
+	}{RefundRequest: req}
+	if !req.RefundedAt.IsZero() {
+		body.RefundedAt = req.RefundedAt.UTC().Format(time.RFC3339)
+	}
+	var refund models.Refund
+	path := fmt.Sprintf("/api/timezone/orders/%d/refunds", orderID)
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: path, body: body}, &refund); err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// Analysis 指定本地日期的分析数据
+func (c *Client) Analysis(ctx context.Context, params AnalysisParams) (*models.AnalysisData, error) {
+	query := url.Values{}
+	setString(query, "date", params.Date)
+	setString(query, "currency", params.Currency)
+	setString(query, "status", strings.Join(params.Statuses, ","))
+	setString(query, "aggregate_tz", params.AggregateTimezone)
+	var analysis models.AnalysisData
+	req := request{method: http.MethodGet, path: "/api/timezone/analysis", query: query, admin: params.AggregateTimezone != ""}
+	if _, err := c.do(ctx, req, &analysis); err != nil {
+		return nil, err
+	}
+	return &analysis, nil
+}
+
+// AnalysisBatch 一次获取多个本地日期的分析数据，按请求的日期顺序返回
+func (c *Client) AnalysisBatch(ctx context.Context, params AnalysisBatchParams) ([]models.AnalysisData, error) {
+	query := url.Values{}
+	setString(query, "dates", strings.Join(params.Dates, ","))
+	setString(query, "month", params.Month)
+	setString(query, "range", params.Range)
+	setString(query, "currency", params.Currency)
+	setString(query, "status", strings.Join(params.Statuses, ","))
+	setString(query, "aggregate_tz", params.AggregateTimezone)
+	var batch []models.AnalysisData
+	req := request{method: http.MethodGet, path: "/api/timezone/analysis/batch", query: query, admin: params.AggregateTimezone != ""}
+	if _, err := c.do(ctx, req, &batch); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// AnalysisCohorts 按商户注册周（商户本地日期）分组的订单留存
+func (c *Client) AnalysisCohorts(ctx context.Context, params CohortParams) (*models.CohortReport, error) {
+	query := url.Values{}
+	setString(query, "from", params.From)
+	setString(query, "to", params.To)
+	setString(query, "range", params.Range)
+	if params.Weeks > 0 {
+		query.Set("weeks", strconv.Itoa(params.Weeks))
+	}
+	if params.WeekStart > 0 {
+		query.Set("week_start", strconv.Itoa(params.WeekStart))
+	}
+	setString(query, "status", strings.Join(params.Statuses, ","))
+	var report models.CohortReport
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/timezone/analysis/cohorts", query: query}, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// GlobalBusinessDay 全球营业日报告：UTC 日期 date（为空时为服务端的今天）每个 UTC 小时处于营业时间的商户时区数和各地区订单在营业时间内外的占比
+func (c *Client) GlobalBusinessDay(ctx context.Context, date string, statuses []string) (*models.GlobalBusinessDayReport, error) {
+	query := url.Values{}
+	setString(query, "date", date)
+	setString(query, "status", strings.Join(statuses, ","))
+	var report models.GlobalBusinessDayReport
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/timezone/analysis/business-day", query: query}, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// DashboardSummary 商户的首页概览：今天截至当前的订单、与昨天同期的对比、本地时间、营业状态和最近 24 个本地小时
+func (c *Client) DashboardSummary(ctx context.Context, params DashboardParams) (*models.DashboardSummary, error) {
+	query := url.Values{}
+	query.Set("merchant_id", strconv.Itoa(params.MerchantID))
+	setString(query, "currency", params.Currency)
+	setString(query, "status", strings.Join(params.Statuses, ","))
+	var summary models.DashboardSummary
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/dashboard/summary", query: query}, &summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// Changes seq 大于 params.Since 的商户和订单变更；Wait 大于 0 时请求可能阻塞到该时长，客户端的超时应大于 Wait
+func (c *Client) Changes(ctx context.Context, params ChangesParams) (*models.ChangeFeed, error) {
+	query := url.Values{}
+	query.Set("since", strconv.FormatInt(params.Since, 10))
+	if params.MerchantID > 0 {
+		query.Set("merchant_id", strconv.Itoa(params.MerchantID))
+	}
+	setString(query, "entity", strings.Join(params.Entities, ","))
+	if params.Limit > 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Wait > 0 {
+		query.Set("wait", params.Wait.String())
+	}
+	var feed models.ChangeFeed
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/changes", query: query}, &feed); err != nil {
+		return nil, err
+	}
+	return &feed, nil
+}
+
+// Sync 推送离线期间的订单修改并拉取 req.Since 之后的变更
+// 修改按 mutation_id 去重，重试不会重复写入，因此网络错误时会自动重试
+func (c *Client) Sync(ctx context.Context, req models.SyncRequest) (*models.SyncResponse, error) {
+	var resp models.SyncResponse
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/api/sync", body: req, idempotent: true}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// IngestWebhook 以外部平台的身份投递一次订单 Webhook，header 带平台的签名和投递ID，payload 原样发送；
+// merchantID 为 0 时由载荷确定商户。同一投递ID按平台去重，因此网络错误时会自动重试
+func (c *Client) IngestWebhook(ctx context.Context, provider string, merchantID int, header http.Header, payload []byte) (*models.IngestDelivery, error) {
+	query := url.Values{}
+	if merchantID > 0 {
+		query.Set("merchant_id", strconv.Itoa(merchantID))
+	}
+	var delivery models.IngestDelivery
+	path := "/api/ingest/webhooks/" + url.PathEscape(provider)
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: path, query: query, raw: payload, header: header, idempotent: true}, &delivery); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// IngestDeliveries Webhook 投递记录，按接收时间倒序；filter 的零值字段不过滤，status 为 failed 时即死信；需要管理令牌
+func (c *Client) IngestDeliveries(ctx context.Context, filter models.IngestDeliveryFilter) ([]models.IngestDelivery, error) {
+	query := url.Values{}
+	setString(query, "provider", filter.Provider)
+	setString(query, "status", filter.Status)
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	var deliveries []models.IngestDelivery
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/admin/ingest/deliveries", query: query, admin: true}, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// ReplayIngestDelivery 重新处理一次 Webhook 投递；需要管理令牌
+func (c *Client) ReplayIngestDelivery(ctx context.Context, id int64) (*models.IngestDelivery, error) {
+	var delivery models.IngestDelivery
+	path := fmt.Sprintf("/api/admin/ingest/deliveries/%d/replay", id)
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: path, admin: true}, &delivery); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// ReplayFailedIngestDeliveries 重放最多 limit 条死信，provider 为空时不限平台；需要管理令牌
+func (c *Client) ReplayFailedIngestDeliveries(ctx context.Context, provider string, limit int) ([]models.IngestDelivery, error) {
+	query := url.Values{}
+	setString(query, "provider", provider)
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var deliveries []models.IngestDelivery
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/api/admin/ingest/deliveries/replay", query: query, admin: true}, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// ClosedAnalysis 指定本地日期的日结数据，date 为空时为服务端的昨天
+func (c *Client) ClosedAnalysis(ctx context.Context, date string) (*models.ClosedAnalysis, error) {
+	query := url.Values{}
+	setString(query, "date", date)
+	var closed models.ClosedAnalysis
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/timezone/analysis/closed", query: query}, &closed); err != nil {
+		return nil, err
+	}
+	return &closed, nil
+}
+
+// Reconciliation 迟到订单对账，date 和 merchantID 为零值时不过滤
+func (c *Client) Reconciliation(ctx context.Context, date string, merchantID int) (*models.ReconciliationReport, error) {
+	query := url.Values{}
+	setString(query, "date", date)
+	if merchantID > 0 {
+		query.Set("merchant_id", strconv.Itoa(merchantID))
+	}
+	var report models.ReconciliationReport
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/timezone/reconciliation", query: query}, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// EventReconciliation 核对已接收的 Webhook 事件与订单本地日期，provider 为空时为 stripe，from、to 为事件的 UTC 日期，零值不过滤
+func (c *Client) EventReconciliation(ctx context.Context, provider, from, to string, merchantID int) (*models.EventReconciliationReport, error) {
+	query := url.Values{}
+	setString(query, "provider", provider)
+	setString(query, "from", from)
+	setString(query, "to", to)
+	if merchantID > 0 {
+		query.Set("merchant_id", strconv.Itoa(merchantID))
+	}
+	var report models.EventReconciliationReport
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/timezone/reconciliation/events", query: query}, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ReconcileEvents 核对上传的支付平台事件与订单本地日期，只读，可以安全重试
+func (c *Client) ReconcileEvents(ctx context.Context, events []models.ProviderEvent, merchantID int) (*models.EventReconciliationReport, error) {
+	query := url.Values{}
+	if merchantID > 0 {
+		query.Set("merchant_id", strconv.Itoa(merchantID))
+	}
+	var report models.EventReconciliationReport
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/api/timezone/reconciliation/events", query: query, body: events, idempotent: true}, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// AdjustLateOrders 为未调整的迟到订单追加调整记录，每笔订单只调整一次，可以安全重试
+func (c *Client) AdjustLateOrders(ctx context.Context, req AdjustRequest) (*models.AdjustmentResult, error) {
+	var result models.AdjustmentResult
+	r := request{method: http.MethodPost, path: "/api/timezone/reconciliation/adjust", body: req, idempotent: true}
+	if _, err := c.do(ctx, r, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CompareTimezones 时区对比（世界时钟），at 为零值时使用当前时间，不指定时区时对比所有商户时区
+func (c *Client) CompareTimezones(ctx context.Context, at time.Time, timezones ...string) (*models.TimezoneComparison, error) {
+	query := url.Values{}
+	setTime(query, "utc_time", at)
+	setString(query, "timezones", strings.Join(timezones, ","))
+	var comparison models.TimezoneComparison
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/timezone/compare", query: query}, &comparison); err != nil {
+		return nil, err
+	}
+	return &comparison, nil
+}
+
+// Overlap 多商户营业时间重叠区间与候选会议时段
+func (c *Client) Overlap(ctx context.Context, params OverlapParams) (*models.MeetingOverlap, error) {
+	ids := make([]string, len(params.MerchantIDs))
+	for i, id := range params.MerchantIDs {
+		ids[i] = strconv.Itoa(id)
+	}
+	query := url.Values{}
+	setString(query, "merchants", strings.Join(ids, ","))
+	setString(query, "date", params.Date)
+	setString(query, "tz", params.Timezone)
+	setDuration(query, "window", params.Window)
+	setDuration(query, "step", params.Step)
+	if params.Limit > 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	var overlap models.MeetingOverlap
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/timezone/overlap", query: query}, &overlap); err != nil {
+		return nil, err
+	}
+	return &overlap, nil
+}
+
+// ExpandSchedule 按本地时间展开类 RRULE 重复规则
+func (c *Client) ExpandSchedule(ctx context.Context, params ScheduleParams) (*models.ScheduleExpansion, error) {
+	query := url.Values{}
+	setString(query, "rule", params.Rule)
+	if params.MerchantID > 0 {
+		query.Set("merchant_id", strconv.Itoa(params.MerchantID))
+	}
+	setString(query, "timezone", params.Timezone)
+	setString(query, "from", params.From)
+	setString(query, "to", params.To)
+	setString(query, "range", params.Range)
+	setString(query, "gap", params.Gap)
+	setString(query, "overlap", params.Overlap)
+	if params.Limit > 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	var expansion models.ScheduleExpansion
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/timezone/schedule/expand", query: query}, &expansion); err != nil {
+		return nil, err
+	}
+	return &expansion, nil
+}
+
+// LookupTimezone 根据坐标查询时区，at 为零值时按当前时间计算本地时间
+func (c *Client) LookupTimezone(ctx context.Context, lat, lon float64, at time.Time) (*models.TimezoneLookup, error) {
+	query := url.Values{}
+	query.Set("lat", strconv.FormatFloat(lat, 'f', -1, 64))
+	query.Set("lon", strconv.FormatFloat(lon, 'f', -1, 64))
+	if !at.IsZero() {
+		query.Set("at", at.UTC().Format(time.RFC3339))
+	}
+	var lookup models.TimezoneLookup
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/timezone/lookup", query: query}, &lookup); err != nil {
+		return nil, err
+	}
+	return &lookup, nil
+}
+
+// ConvertTimestamps 批量时区转换，gap/overlap 为空时使用服务端默认策略
+func (c *Client) ConvertTimestamps(ctx context.Context, items []models.ConversionItem, gap, overlap string) (*models.BatchConversion, error) {
+	query := url.Values{}
+	setString(query, "gap", gap)
+	setString(query, "overlap", overlap)
+	var result models.BatchConversion
+	req := request{method: http.MethodPost, path: "/api/timezone/convert", query: query, body: items, idempotent: true}
+	if _, err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// TZData 服务端使用的 tzdata 版本
+func (c *Client) TZData(ctx context.Context) (*tzdb.Info, error) {
+	var info tzdb.Info
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/timezone/tzdata"}, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// TimezoneRules 时区在 from～to（UTC 日期，为空时使用服务端默认范围）内的历史偏移切换
+func (c *Client) TimezoneRules(ctx context.Context, timezone, from, to string) (*models.TimezoneRules, error) {
+	query := url.Values{}
+	setString(query, "timezone", timezone)
+	setString(query, "from", from)
+	setString(query, "to", to)
+	var rules models.TimezoneRules
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/timezone/rules", query: query}, &rules); err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}
+
+// ReferenceCountries 国家参考数据，q 为空时返回全部国家
+func (c *Client) ReferenceCountries(ctx context.Context, q string) ([]models.ReferenceCountry, error) {
+	query := url.Values{}
+	setString(query, "q", q)
+	var countries []models.ReferenceCountry
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/reference/countries", query: query}, &countries); err != nil {
+		return nil, err
+	}
+	return countries, nil
+}
+
+// ReferenceCountry 按代码、英文名或中文名查询一个国家
+func (c *Client) ReferenceCountry(ctx context.Context, country string) (*models.ReferenceCountry, error) {
+	var result models.ReferenceCountry
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/reference/countries/" + url.PathEscape(country)}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ReferenceCities 城市参考数据，country、q 为空时不过滤
+func (c *Client) ReferenceCities(ctx context.Context, country, q string) ([]models.ReferenceCity, error) {
+	query := url.Values{}
+	setString(query, "country", country)
+	setString(query, "q", q)
+	var cities []models.ReferenceCity
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/reference/cities", query: query}, &cities); err != nil {
+		return nil, err
+	}
+	return cities, nil
+}
+
+// Onboard 商户入驻，req.DryRun 为 true 时只返回推断和校验结果；不会自动重试
+func (c *Client) Onboard(ctx context.Context, req OnboardRequest) (*models.OnboardingResult, error) {
+	var result models.OnboardingResult
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/api/merchants/onboard", body: req, idempotent: req.DryRun}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// MerchantSettings 商户的全部配置项
+func (c *Client) MerchantSettings(ctx context.Context, merchantID int) ([]models.TenantSetting, error) {
+	var settings []models.TenantSetting
+	path := fmt.Sprintf("/api/merchants/%d/settings", merchantID)
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: path}, &settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// MerchantSetting 商户的单个配置项
+func (c *Client) MerchantSetting(ctx context.Context, merchantID int, key string) (*models.TenantSetting, error) {
+	var setting models.TenantSetting
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: settingPath(merchantID, key)}, &setting); err != nil {
+		return nil, err
+	}
+	return &setting, nil
+}
+
+// UpdateMerchantSetting 修改商户配置项，value 按 JSON 序列化，类型须与配置项一致
+func (c *Client) UpdateMerchantSetting(ctx context.Context, merchantID int, key string, value interface{}, operator string) (*models.TenantSetting, error) {
+	body := struct {
+		Value    interface{} `json:"value"`
+		Operator string      `json:"operator,omitempty"`
+	}{value, operator}
+	var setting models.TenantSetting
+	if _, err := c.do(ctx, request{method: http.MethodPut, path: settingPath(merchantID, key), body: body}, &setting); err != nil {
+		return nil, err
+	}
+	return &setting, nil
+}
+
+// ResetMerchantSetting 删除商户配置项，恢复默认值
+func (c *Client) ResetMerchantSetting(ctx context.Context, merchantID int, key, operator string) (*models.TenantSetting, error) {
+	query := url.Values{}
+	setString(query, "operator", operator)
+	var setting models.TenantSetting
+	if _, err := c.do(ctx, request{method: http.MethodDelete, path: settingPath(merchantID, key), query: query}, &setting); err != nil {
+		return nil, err
+	}
+	return &setting, nil
+}
+
+// RevokeTenantAPIKey 吊销租户的 API 密钥，立即失效；需要管理令牌
+func (c *Client) RevokeTenantAPIKey(ctx context.Context, merchantID, keyID int) error {
+	path := fmt.Sprintf("/api/admin/tenants/%d/api-keys/%d", merchantID, keyID)
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: path, admin: true}, nil)
+	return err
+}
+
+// BillingPeriods 商户计费周期，through 为空时计算到当前周期
+func (c *Client) BillingPeriods(ctx context.Context, merchantID int, through string) (*models.BillingPeriods, error) {
+	query := url.Values{}
+	query.Set("merchant_id", strconv.Itoa(merchantID))
+	setString(query, "through", through)
+	var periods models.BillingPeriods
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/billing/periods", query: query}, &periods); err != nil {
+		return nil, err
+	}
+	return &periods, nil
+}
+
+// settingPath 商户配置项的路径
+func settingPath(merchantID int, key string) string {
+	return fmt.Sprintf("/api/merchants/%d/settings/%s", merchantID, url.PathEscape(key))
+}
+
+// setString 值不为空时写入查询参数
+func setString(query url.Values, name, value string) {
+	if value != "" {
+		query.Set(name, value)
+	}
+}
+
+// setDuration 值大于 0 时写入查询参数
+func setDuration(query url.Values, name string, d time.Duration) {
+	if d > 0 {
+		query.Set(name, d.String())
+	}
+}
+
+// setTime 写入 RFC3339 时刻，零值时为 now
+func setTime(query url.Values, name string, t time.Time) {
+	if t.IsZero() {
+		query.Set(name, "now")
+		return
+	}
+	query.Set(name, t.UTC().Format(time.RFC3339))
+}
+
+// ConsistencyRuns 最近的一致性检查记录（不含差异明细），按开始时间倒序，limit 为 0 时使用默认值 20；需要管理令牌
+func (c *Client) ConsistencyRuns(ctx context.Context, limit int) ([]models.ConsistencyRun, error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var runs []models.ConsistencyRun
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/admin/consistency", query: query, admin: true}, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// ConsistencyRun 一次一致性检查及其差异明细；需要管理令牌
+func (c *Client) ConsistencyRun(ctx context.Context, id int64) (*models.ConsistencyRun, error) {
+	var run models.ConsistencyRun
+	path := fmt.Sprintf("/api/admin/consistency/%d", id)
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: path, admin: true}, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// RunConsistencyCheck 立即执行一次一致性检查；需要管理令牌
+func (c *Client) RunConsistencyCheck(ctx context.Context) (*models.ConsistencyRun, error) {
+	var run models.ConsistencyRun
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/api/admin/consistency/run", admin: true}, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// AuditRollup 比对本地日期 from~to 内的订单小时汇总表与分析视图，参数为空时使用服务端默认值（最近 7 天、100 条）；需要管理令牌
+func (c *Client) AuditRollup(ctx context.Context, from, to string, limit int) (*models.RollupAudit, error) {
+	query := url.Values{}
+	if from != "" {
+		query.Set("from", from)
+	}
+	if to != "" {
+		query.Set("to", to)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var audit models.RollupAudit
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/admin/rollup/audit", query: query, admin: true}, &audit); err != nil {
+		return nil, err
+	}
+	return &audit, nil
+}
+
+// RebuildRollup 从订单表重建商户的小时汇总，merchantID 为 0 时重建全部商户；需要管理令牌
+func (c *Client) RebuildRollup(ctx context.Context, merchantID int) (*models.RollupRebuild, error) {
+	query := url.Values{}
+	if merchantID > 0 {
+		query.Set("merchant_id", strconv.Itoa(merchantID))
+	}
+	var result models.RollupRebuild
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/api/admin/rollup/rebuild", query: query, admin: true}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Retention 全部订单保留策略和最近的归档记录，limit 为 0 时使用默认值 20；需要管理令牌
+func (c *Client) Retention(ctx context.Context, limit int) (*models.RetentionOverview, error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var overview models.RetentionOverview
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/admin/retention", query: query, admin: true}, &overview); err != nil {
+		return nil, err
+	}
+	return &overview, nil
+}
+
+// SetRetentionPolicy 设置商户的保留策略：保留最近 months 个本地自然月，更早的订单归档到 target（table | object）；需要管理令牌
+func (c *Client) SetRetentionPolicy(ctx context.Context, merchantID, months int, target, operator string) (*models.RetentionPolicy, error) {
+	body := struct {
+		ArchiveAfterMonths int    `json:"archive_after_months"`
+		Target             string `json:"target"`
+		Operator           string `json:"operator,omitempty"`
+	}{months, target, operator}
+	var policy models.RetentionPolicy
+	path := fmt.Sprintf("/api/admin/retention/%d", merchantID)
+	if _, err := c.do(ctx, request{method: http.MethodPut, path: path, body: body, admin: true}, &policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// DeleteRetentionPolicy 删除商户的保留策略；需要管理令牌
+func (c *Client) DeleteRetentionPolicy(ctx context.Context, merchantID int) error {
+	path := fmt.Sprintf("/api/admin/retention/%d", merchantID)
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: path, admin: true}, nil)
+	return err
+}
+
+// RunRetention 立即按保留策略归档，merchantID 为 0 时处理所有配置了策略的商户；需要管理令牌
+func (c *Client) RunRetention(ctx context.Context, merchantID int) (*models.RetentionRun, error) {
+	query := url.Values{}
+	if merchantID > 0 {
+		query.Set("merchant_id", strconv.Itoa(merchantID))
+	}
+	var run models.RetentionRun
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/api/admin/retention/run", query: query, admin: true}, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// RetentionRun 一次归档及其明细；需要管理令牌
+func (c *Client) RetentionRun(ctx context.Context, id int64) (*models.RetentionRun, error) {
+	var run models.RetentionRun
+	path := fmt.Sprintf("/api/admin/retention/runs/%d", id)
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: path, admin: true}, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// ExportMerchants 导出全部商户及其编码、ISO 国家代码和状态；需要管理令牌
+func (c *Client) ExportMerchants(ctx context.Context) ([]models.MerchantRecord, error) {
+	var records []models.MerchantRecord
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/admin/merchants/export", admin: true}, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// ImportMerchants 批量导入商户，dryRun 时只返回逐行校验报告；有校验失败的行时整批拒绝，重复的商户跳过；需要管理令牌
+func (c *Client) ImportMerchants(ctx context.Context, rows []models.MerchantImportRow, dryRun bool) (*models.MerchantImportReport, error) {
+	query := url.Values{}
+	if dryRun {
+		query.Set("dry_run", "true")
+	}
+	var report models.MerchantImportReport
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/api/admin/merchants/import", query: query, body: rows, admin: true, idempotent: dryRun}, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Query 在只读 SQL 控制台执行一条 SELECT，maxRows 为 0 时使用服务端上限；operator 必填，每次执行都会记录审计；需要管理令牌
+func (c *Client) Query(ctx context.Context, sql, operator string, maxRows int) (*models.ConsoleResult, error) {
+	body := models.ConsoleQuery{SQL: sql, Operator: operator, MaxRows: maxRows}
+	var result models.ConsoleResult
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/api/admin/query", body: body, admin: true}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// QueryAudit SQL 控制台最近的审计记录，limit 为 0 时使用默认值 20；需要管理令牌
+func (c *Client) QueryAudit(ctx context.Context, limit int) ([]models.ConsoleAudit, error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var audits []models.ConsoleAudit
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/admin/query/audit", query: query, admin: true}, &audits); err != nil {
+		return nil, err
+	}
+	return audits, nil
+}
+
+// BackfillJobs 最近的回填任务及进度，按创建时间倒序，limit 为 0 时使用默认值 20；需要管理令牌
+func (c *Client) BackfillJobs(ctx context.Context, limit int) ([]models.BackfillJob, error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var jobs []models.BackfillJob
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/admin/backfill", query: query, admin: true}, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// BackfillJob 单个回填任务的进度；需要管理令牌
+func (c *Client) BackfillJob(ctx context.Context, id int64) (*models.BackfillJob, error) {
+	var job models.BackfillJob
+	path := fmt.Sprintf("/api/admin/backfill/%d", id)
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: path, admin: true}, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// StartBackfill 创建回填任务并在服务端后台执行，merchantIDs 为空表示全部商户；需要管理令牌
+func (c *Client) StartBackfill(ctx context.Context, merchantIDs []int, reason string) (*models.BackfillJob, error) {
+	body := map[string]interface{}{"merchant_ids": merchantIDs, "reason": reason}
+	var job models.BackfillJob
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/api/admin/backfill", body: body, admin: true}, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ResumeBackfill 从游标处继续执行中断或失败的回填任务；需要管理令牌
+func (c *Client) ResumeBackfill(ctx context.Context, id int64) (*models.BackfillJob, error) {
+	var job models.BackfillJob
+	path := fmt.Sprintf("/api/admin/backfill/%d/resume", id)
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: path, admin: true}, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Organizations 全部组织及其门店ID；需要管理令牌
+func (c *Client) Organizations(ctx context.Context) ([]models.Organization, error) {
+	var orgs []models.Organization
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/admin/orgs", admin: true}, &orgs); err != nil {
+		return nil, err
+	}
+	return orgs, nil
+}
+
+// CreateOrganization 创建组织并划入门店；需要管理令牌，不会自动重试
+func (c *Client) CreateOrganization(ctx context.Context, name, hqTimezone string, merchantIDs []int) (*models.Organization, error) {
+	body := map[string]interface{}{"name": name, "hq_timezone": hqTimezone, "merchant_ids": merchantIDs}
+	var org models.Organization
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/api/admin/orgs", body: body, admin: true}, &org); err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// SetOrganizationMerchants 替换组织的门店；需要管理令牌
+func (c *Client) SetOrganizationMerchants(ctx context.Context, id int, merchantIDs []int) (*models.Organization, error) {
+	body := map[string]interface{}{"merchant_ids": merchantIDs}
+	var org models.Organization
+	path := fmt.Sprintf("/api/admin/orgs/%d/merchants", id)
+	if _, err := c.do(ctx, request{method: http.MethodPut, path: path, body: body, admin: true}, &org); err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// OrganizationTokens 组织令牌列表，不含明文；需要管理令牌
+func (c *Client) OrganizationTokens(ctx context.Context, id int) ([]models.OrganizationToken, error) {
+	var tokens []models.OrganizationToken
+	path := fmt.Sprintf("/api/admin/orgs/%d/tokens", id)
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: path, admin: true}, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// IssueOrganizationToken 签发组织令牌，明文只在返回值的 Token 中出现一次；需要管理令牌，不会自动重试
+func (c *Client) IssueOrganizationToken(ctx context.Context, id int, name string) (*models.OrganizationToken, error) {
+	body := map[string]interface{}{"name": name}
+	var token models.OrganizationToken
+	path := fmt.Sprintf("/api/admin/orgs/%d/tokens", id)
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: path, body: body, admin: true}, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RevokeOrganizationToken 吊销组织令牌；需要管理令牌
+func (c *Client) RevokeOrganizationToken(ctx context.Context, id, tokenID int) error {
+	path := fmt.Sprintf("/api/admin/orgs/%d/tokens/%d", id, tokenID)
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: path, admin: true}, nil)
+	return err
+}
+
+// Organization 组织及其门店详情；需要该组织的令牌或管理令牌，均通过 WithAdminToken 设置
+func (c *Client) Organization(ctx context.Context, id int) (*models.Organization, error) {
+	var org models.Organization
+	path := fmt.Sprintf("/api/orgs/%d", id)
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: path, admin: true}, &org); err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// OrganizationAnalysis 组织全部门店在 date 的订单汇总，mode 为 local（默认）或 hq；
+// 需要该组织的令牌或管理令牌，均通过 WithAdminToken 设置
+func (c *Client) OrganizationAnalysis(ctx context.Context, id int, date, mode string, statuses []string) (*models.OrganizationAnalysis, error) {
+	query := url.Values{}
+	setString(query, "date", date)
+	setString(query, "mode", mode)
+	setString(query, "status", strings.Join(statuses, ","))
+	var analysis models.OrganizationAnalysis
+	path := fmt.Sprintf("/api/orgs/%d/analysis", id)
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: path, query: query, admin: true}, &analysis); err != nil {
+		return nil, err
+	}
+	return &analysis, nil
+}
+
+// ReportDefinitions 全部保存的报表定义
+func (c *Client) ReportDefinitions(ctx context.Context) ([]models.ReportDefinition, error) {
+	var defs []models.ReportDefinition
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/reports/definitions"}, &defs); err != nil {
+		return nil, err
+	}
+	return defs, nil
+}
+
+// ReportDefinition 单个报表定义
+func (c *Client) ReportDefinition(ctx context.Context, id int) (*models.ReportDefinition, error) {
+	var def models.ReportDefinition
+	path := fmt.Sprintf("/api/reports/definitions/%d", id)
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: path}, &def); err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// CreateReportDefinition 保存报表定义；不会自动重试
+func (c *Client) CreateReportDefinition(ctx context.Context, req ReportDefinitionRequest) (*models.ReportDefinition, error) {
+	var def models.ReportDefinition
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/api/reports/definitions", body: req}, &def); err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// UpdateReportDefinition 覆盖报表定义
+func (c *Client) UpdateReportDefinition(ctx context.Context, id int, req ReportDefinitionRequest) (*models.ReportDefinition, error) {
+	var def models.ReportDefinition
+	path := fmt.Sprintf("/api/reports/definitions/%d", id)
+	if _, err := c.do(ctx, request{method: http.MethodPut, path: path, body: req}, &def); err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// DeleteReportDefinition 删除报表定义及其执行记录
+func (c *Client) DeleteReportDefinition(ctx context.Context, id int) error {
+	path := fmt.Sprintf("/api/reports/definitions/%d", id)
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: path}, nil)
+	return err
+}
+
+// RunReport 立即执行报表，结果文件从返回值的 ArtifactURL 下载；不会自动重试
+func (c *Client) RunReport(ctx context.Context, id int) (*models.ReportRun, error) {
+	var run models.ReportRun
+	path := fmt.Sprintf("/api/reports/definitions/%d/run", id)
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: path}, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// ReportRuns 报表最近 limit 次执行，limit 为 0 时使用服务端默认值
+func (c *Client) ReportRuns(ctx context.Context, id, limit int) ([]models.ReportRun, error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var runs []models.ReportRun
+	path := fmt.Sprintf("/api/reports/definitions/%d/runs", id)
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: path, query: query}, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// AlertRules 当前租户（WithTenant）的告警规则
+func (c *Client) AlertRules(ctx context.Context) ([]models.AlertRule, error) {
+	var rules []models.AlertRule
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/alerts/rules"}, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// AlertRule 单个告警规则
+func (c *Client) AlertRule(ctx context.Context, id int) (*models.AlertRule, error) {
+	var rule models.AlertRule
+	path := fmt.Sprintf("/api/alerts/rules/%d", id)
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: path}, &rule); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// CreateAlertRule 为当前租户创建告警规则；不会自动重试
+func (c *Client) CreateAlertRule(ctx context.Context, req AlertRuleRequest) (*models.AlertRule, error) {
+	var rule models.AlertRule
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/api/alerts/rules", body: req}, &rule); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// UpdateAlertRule 覆盖告警规则的配置
+func (c *Client) UpdateAlertRule(ctx context.Context, id int, req AlertRuleRequest) (*models.AlertRule, error) {
+	var rule models.AlertRule
+	path := fmt.Sprintf("/api/alerts/rules/%d", id)
+	if _, err := c.do(ctx, request{method: http.MethodPut, path: path, body: req}, &rule); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// DeleteAlertRule 删除告警规则及其告警历史
+func (c *Client) DeleteAlertRule(ctx context.Context, id int) error {
+	path := fmt.Sprintf("/api/alerts/rules/%d", id)
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: path}, nil)
+	return err
+}
+
+// EvaluateAlertRule 立即检查告警规则，状态变化时服务端会发送通知；不会自动重试
+func (c *Client) EvaluateAlertRule(ctx context.Context, id int) (*models.AlertEvaluation, error) {
+	var eval models.AlertEvaluation
+	path := fmt.Sprintf("/api/alerts/rules/%d/evaluate", id)
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: path}, &eval); err != nil {
+		return nil, err
+	}
+	return &eval, nil
+}
+
+// AlertEvents 当前租户的告警历史，ruleID、limit 为 0 时不过滤规则、使用服务端默认条数
+func (c *Client) AlertEvents(ctx context.Context, ruleID, limit int) ([]models.AlertEvent, error) {
+	query := url.Values{}
+	if ruleID > 0 {
+		query.Set("rule_id", strconv.Itoa(ruleID))
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var events []models.AlertEvent
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/alerts/events", query: query}, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// EmailTemplates 当前租户（WithTenant）可用的邮件模板，租户覆盖的版本 Source 为 tenant
+func (c *Client) EmailTemplates(ctx context.Context) ([]models.EmailTemplate, error) {
+	var templates []models.EmailTemplate
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/email/templates"}, &templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// EmailTemplate 当前租户发送 lang 语言（为空时 zh）的邮件时实际使用的模板
+func (c *Client) EmailTemplate(ctx context.Context, name, lang string) (*models.EmailTemplate, error) {
+	var t models.EmailTemplate
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: emailTemplatePath(name), query: localeQuery(lang)}, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// SaveEmailTemplate 覆盖当前租户 lang 语言的模板，模板无法用示例数据渲染时返回 400
+func (c *Client) SaveEmailTemplate(ctx context.Context, name, lang string, req EmailTemplateRequest) (*models.EmailTemplate, error) {
+	var t models.EmailTemplate
+	r := request{method: http.MethodPut, path: emailTemplatePath(name), query: localeQuery(lang), body: req}
+	if _, err := c.do(ctx, r, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// DeleteEmailTemplate 删除当前租户覆盖的模板，恢复使用内置模板
+func (c *Client) DeleteEmailTemplate(ctx context.Context, name, lang string) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: emailTemplatePath(name), query: localeQuery(lang)}, nil)
+	return err
+}
+
+// PreviewEmailTemplate 使用示例数据按 lang 和 timezone 渲染模板，draft 为 nil 时渲染当前使用的模板
+func (c *Client) PreviewEmailTemplate(ctx context.Context, name, lang, timezone string, draft *EmailTemplateRequest) (*mailtemplate.Message, error) {
+	query := localeQuery(lang)
+	if timezone != "" {
+		query.Set("timezone", timezone)
+	}
+	r := request{method: http.MethodPost, path: emailTemplatePath(name) + "/preview", query: query, idempotent: true}
+	if draft != nil {
+		r.body = draft
+	}
+	var msg mailtemplate.Message
+	if _, err := c.do(ctx, r, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// emailTemplatePath 邮件模板的接口路径
+func emailTemplatePath(name string) string {
+	return "/api/email/templates/" + url.PathEscape(name)
+}
+
+// localeQuery lang 不为空时设置 locale 查询参数
+func localeQuery(lang string) url.Values {
+	query := url.Values{}
+	if lang != "" {
+		query.Set("locale", lang)
+	}
+	return query
+}
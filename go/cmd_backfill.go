@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/services"
+)
+
+// runBackfill 按当前的营业时间、周末和时区规则重新镜像订单到 ClickHouse
+func runBackfill(config *AppConfig, args []string) error {
+	fs := newFlagSet("backfill")
+	merchants := fs.String("merchants", "", "需要回填的商户ID，逗号分隔，为空表示全部商户")
+	batch := fs.Int("batch", services.DefaultBackfillBatchSize, "每批订单数")
+	reason := fs.String("reason", "", "回填原因，记录在任务中")
+	resume := fs.Int64("resume", 0, "继续执行中断或失败的任务ID，忽略 -merchants/-batch/-reason")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	merchantIDs, err := parseMerchantIDs(*merchants)
+	if err != nil {
+		return err
+	}
+
+	conn, err := openDatabase(config, database.RetryPolicyFromEnv())
+	if err != nil {
+		return fmt.Errorf("数据库连接失败: %w", err)
+	}
+	defer conn.Close()
+	ch, err := openClickHouse(config)
+	if err != nil {
+		return err
+	}
+	backfill := services.NewClickHouseBackfill(conn, ch)
+
+	// Ctrl-C 时在当前批次后停止，之后可用 -resume 继续
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	id := *resume
+	if id == 0 {
+		job, err := backfill.Create(ctx, services.BackfillRequest{MerchantIDs: merchantIDs, BatchSize: *batch, Reason: *reason})
+		if err != nil {
+			return err
+		}
+		id = job.ID
+		log.Printf("已创建回填任务 %d，共 %d 笔订单", job.ID, job.TotalOrders)
+	}
+
+	job, err := backfill.Run(ctx, id, func(job models.BackfillJob) {
+		log.Printf("回填任务 %d: %d/%d 笔订单（%.1f%%）", job.ID, job.ProcessedOrders, job.TotalOrders, job.Progress)
+	})
+	if err != nil {
+		if job != nil {
+			return fmt.Errorf("%w（使用 -resume %d 继续）", err, id)
+		}
+		return err
+	}
+
+	log.Printf("回填任务 %d 完成，共 %d 笔订单", job.ID, job.ProcessedOrders)
+	return nil
+}
+
+// parseMerchantIDs 解析逗号分隔的商户ID
+func parseMerchantIDs(value string) ([]int, error) {
+	var ids []int
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("-merchants 格式错误: %q", part)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
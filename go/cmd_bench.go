@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"timezone-saas-demo/locale"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/msgpack"
+	"timezone-saas-demo/services"
+	"timezone-saas-demo/testsupport"
+)
+
+// runBenchAnalysis 对比分析接口两种查询方式的耗时，用于选择 ANALYSIS_QUERY_MODE
+//
+//	./main bench-analysis -date 2024-08-19 -n 50
+func runBenchAnalysis(config *AppConfig, args []string) error {
+	fs := newFlagSet("bench-analysis")
+	date := fs.String("date", time.Now().Format("2006-01-02"), "分析的本地日期")
+	n := fs.Int("n", 20, "每种查询方式的执行次数")
+	modes := fs.String("modes", services.AnalysisQueryModeFanout+","+services.AnalysisQueryModeSingle, "参与对比的查询方式，逗号分隔")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *n <= 0 {
+		return fmt.Errorf("执行次数必须大于 0")
+	}
+
+	conn, svc, err := openServices(config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := svc.SetRevenueDefinition(config.Revenue); err != nil {
+		return fmt.Errorf("营收口径配置错误: %w", err)
+	}
+	svc.SetQueryTimeout(config.AnalysisQueryTimeout)
+
+	fmt.Printf("分析日期 %s，每种方式执行 %d 次（另有 1 次预热）\n", *date, *n)
+	fmt.Printf("%-8s %8s %10s %10s %10s %10s\n", "mode", "orders", "min", "p50", "p95", "avg")
+
+	var baseline string
+	for _, mode := range strings.Split(*modes, ",") {
+		mode = strings.TrimSpace(mode)
+		if err := svc.SetQueryMode(mode); err != nil {
+			return err
+		}
+
+		// 预热连接池和查询计划，不计入结果
+		warmup, err := svc.GetAnalysisData(context.Background(), *date, "", nil)
+		if err != nil {
+			return fmt.Errorf("%s 预热失败: %w", mode, err)
+		}
+		if warmup.QueryMode != mode {
+			fmt.Printf("%-8s 分析存储 %s 不支持，实际使用 %s\n", mode, warmup.Source, warmup.QueryMode)
+		}
+
+		// 各方式的结果应一致，只比较耗时才有意义
+		fingerprint := analysisFingerprint(warmup)
+		if baseline == "" {
+			baseline = fingerprint
+		} else if fingerprint != baseline {
+			return fmt.Errorf("%s 的结果与其他查询方式不一致:\n%s\n%s", mode, fingerprint, baseline)
+		}
+
+		durations := make([]time.Duration, 0, *n)
+		for i := 0; i < *n; i++ {
+			start := time.Now()
+			if _, err := svc.GetAnalysisData(context.Background(), *date, "", nil); err != nil {
+				return fmt.Errorf("%s 第 %d 次执行失败: %w", mode, i+1, err)
+			}
+			durations = append(durations, time.Since(start))
+		}
+
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		var total time.Duration
+		for _, d := range durations {
+			total += d
+		}
+		fmt.Printf("%-8s %8d %10s %10s %10s %10s\n", mode, warmup.TotalOrders,
+			roundDuration(durations[0]),
+			roundDuration(durations[len(durations)/2]),
+			roundDuration(durations[(len(durations)*95-1)/100]),
+			roundDuration(total/time.Duration(len(durations))))
+	}
+	return nil
+}
+
+// analysisFingerprint 分析结果中与查询方式无关的部分，用于校验不同方式的结果一致
+func analysisFingerprint(a *models.AnalysisData) string {
+	var b strings.Builder
+	for _, t := range a.TotalsByCurrency {
+		fmt.Fprintf(&b, "%s:%d:%s ", t.Currency, t.OrderCount, t.GrossAmount)
+	}
+	for _, h := range a.HourlyBreakdown {
+		fmt.Fprintf(&b, "h%d:%d:%s ", h.Hour, h.OrderCount, h.TotalAmount)
+	}
+	fmt.Fprintf(&b, "tz%d m%d", len(a.TimezoneStats), len(a.TopMerchants))
+	return b.String()
+}
+
+// roundDuration 保留到 10 微秒，方便阅读
+func roundDuration(d time.Duration) time.Duration {
+	return d.Round(10 * time.Microsecond)
+}
+
+// runBenchJSON 对比 encoding/json 与 fastjson 编码订单列表和分析结果的耗时和内存分配，并校验两者输出一致，用于选择 JSON_ENCODER；
+// 同时列出 MessagePack（Accept: application/x-msgpack）的大小和耗时作为参考
+// 使用与 serve -mock 相同的模拟数据，不需要数据库
+//
+//	./main bench-json -orders 5000 -n 50
+func runBenchJSON(config *AppConfig, args []string) error {
+	fs := newFlagSet("bench-json")
+	size := fs.Int("orders", 5000, "订单列表的条数")
+	n := fs.Int("n", 50, "每种编码方式的执行次数")
+	seed := fs.Int64("seed", 1, "模拟数据的随机种子")
+	lang := fs.String("lang", "zh", "展示字段的语言")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *size <= 0 || *n <= 0 {
+		return fmt.Errorf("订单条数和执行次数必须大于 0")
+	}
+
+	// 按订单条数估算模拟数据的天数，保证订单足够
+	fakes := testsupport.NewMockFakes(testsupport.MockOptions{Seed: *seed, Days: max(testsupport.DefaultMockDays, *size/100+1)})
+	svc := fakes.Service()
+	l := locale.Negotiate(*lang)
+
+	orders, err := svc.GetOrders(models.OrderFilter{Limit: *size})
+	if err != nil {
+		return fmt.Errorf("读取模拟订单失败: %w", err)
+	}
+	services.LocalizeOrders(orders, l)
+	// humanize=true 的订单列表，相对时间以模拟数据最后一天的次日零点为当前时刻，输出固定
+	humanized := append([]models.OrderAnalysis(nil), orders...)
+	services.HumanizeOrders(humanized, l, testsupport.DefaultMockEndDate.AddDate(0, 0, 1))
+	analysis, err := svc.GetAnalysisData(context.Background(), "2024-08-19", "", nil)
+	if err != nil {
+		return fmt.Errorf("读取模拟分析数据失败: %w", err)
+	}
+	services.LocalizeAnalysis(analysis, l)
+	dates := make([]string, 7)
+	for i := range dates {
+		dates[i] = time.Date(2024, 8, 13+i, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
+	}
+	batch, err := svc.GetAnalysisBatch(context.Background(), dates, "", "", nil)
+	if err != nil {
+		return fmt.Errorf("读取模拟分析数据失败: %w", err)
+	}
+
+	cases := []struct {
+		name     string
+		response APIResponse
+	}{
+		{fmt.Sprintf("orders(%d)", len(orders)), APIResponse{Success: true, Code: "orders.listed", Message: l.Message("orders.listed", len(orders)), Data: orders,
+			Meta: &models.PageMeta{TotalCount: int64(len(orders)), TotalCountExact: true, Limit: *size, Count: len(orders), Page: 1, PageCount: 1}}},
+		{fmt.Sprintf("humanized(%d)", len(humanized)), APIResponse{Success: true, Code: "orders.listed", Message: l.Message("orders.listed", len(humanized)), Data: humanized,
+			Meta: &models.PageMeta{TotalCount: int64(len(humanized)), TotalCountExact: true, Limit: *size, Count: len(humanized), Page: 1, PageCount: 1}}},
+		{"analysis", APIResponse{Success: true, Code: "analysis.ok", Message: l.Message("analysis.ok"), Data: analysis}},
+		{fmt.Sprintf("batch(%d)", len(batch)), APIResponse{Success: true, Code: "analysis.batch_ok", Message: l.Message("analysis.batch_ok", len(batch)), Data: batch}},
+	}
+
+	fmt.Printf("每种编码方式执行 %d 次（另有 1 次预热）\n", *n)
+	fmt.Printf("%-14s %-7s %10s %10s %10s %12s %8s\n", "case", "enc", "bytes", "avg", "MB/s", "allocs/op", "speedup")
+	for _, c := range cases {
+		var std bytes.Buffer
+		if err := json.NewEncoder(&std).Encode(c.response); err != nil {
+			return fmt.Errorf("%s 编码失败: %w", c.name, err)
+		}
+		fast, ok := appendFastResponse(nil, c.response)
+		if !ok {
+			return fmt.Errorf("%s 不支持 fastjson 编码", c.name)
+		}
+		if !bytes.Equal(std.Bytes(), fast) {
+			return fmt.Errorf("%s 两种编码的输出不一致:\n%s", c.name, firstDifference(std.Bytes(), fast))
+		}
+
+		var buf bytes.Buffer
+		stdAvg, stdAllocs := measureEncoding(*n, func() {
+			buf.Reset()
+			json.NewEncoder(&buf).Encode(c.response)
+		})
+		out := fast[:0]
+		fastAvg, fastAllocs := measureEncoding(*n, func() {
+			out, _ = appendFastResponse(out[:0], c.response)
+		})
+		packed, err := msgpack.Marshal(c.response)
+		if err != nil {
+			return fmt.Errorf("%s MessagePack 编码失败: %w", c.name, err)
+		}
+		packedAvg, packedAllocs := measureEncoding(*n, func() {
+			packed, _ = msgpack.Append(packed[:0], c.response)
+		})
+		mbps := func(size int, d time.Duration) float64 { return float64(size) / d.Seconds() / (1 << 20) }
+		fmt.Printf("%-14s %-7s %10d %10s %10.1f %12.1f %8s\n", c.name, jsonEncoderStd, len(fast), roundDuration(stdAvg), mbps(len(fast), stdAvg), stdAllocs, "")
+		fmt.Printf("%-14s %-7s %10d %10s %10.1f %12.1f %7.2fx\n", c.name, jsonEncoderFast, len(fast), roundDuration(fastAvg), mbps(len(fast), fastAvg), fastAllocs, float64(stdAvg)/float64(fastAvg))
+		fmt.Printf("%-14s %-7s %10d %10s %10.1f %12.1f %7.2fx\n", c.name, formatMsgpack, len(packed), roundDuration(packedAvg), mbps(len(packed), packedAvg), packedAllocs, float64(stdAvg)/float64(packedAvg))
+	}
+	return nil
+}
+
+// measureEncoding 预热一次后执行 n 次，返回平均耗时和每次的内存分配次数
+func measureEncoding(n int, encode func()) (time.Duration, float64) {
+	encode()
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		encode()
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	return elapsed / time.Duration(n), float64(after.Mallocs-before.Mallocs) / float64(n)
+}
+
+// firstDifference 两段 JSON 第一个不同字节附近的内容
+func firstDifference(a, b []byte) string {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	from := max(0, i-60)
+	return fmt.Sprintf("位置 %d\nencoding/json: %s\nfastjson:      %s", i, a[from:min(len(a), i+60)], b[from:min(len(b), i+60)])
+}
+
+// runBenchOrders 对比订单 local_date、local_weekday 三种格式化方式（go、sql、raw）流式读取订单的耗时和内存分配，用于选择 ORDER_FORMATTING 和导出的 -formatting
+// raw 方式的日期和星期由 order_time_local、local_day_of_week 推导后与其他方式校验一致
+//
+//	./main bench-orders -orders 200000 -n 5
+func runBenchOrders(config *AppConfig, args []string) error {
+	fs := newFlagSet("bench-orders")
+	size := fs.Int("orders", 100000, "每次读取的订单条数，0 表示全部")
+	n := fs.Int("n", 5, "每种格式化方式的执行次数")
+	timezone := fs.String("timezone", "", "只读取指定时区的商户订单")
+	modes := fs.String("modes", strings.Join(models.OrderFormattings, ","), "参与对比的格式化方式，逗号分隔")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *size < 0 || *n <= 0 {
+		return fmt.Errorf("订单条数不能为负数，执行次数必须大于 0")
+	}
+
+	conn, svc, err := openServices(config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	fmt.Printf("每种方式读取 %d 条订单（0 为全部），执行 %d 次（另有 1 次预热）\n", *size, *n)
+	fmt.Printf("%-5s %8s %10s %10s %10s %12s %10s\n", "mode", "orders", "min", "p50", "avg", "allocs/row", "rows/s")
+
+	var baseline uint64
+	for i, mode := range strings.Split(*modes, ",") {
+		formatting, err := services.ParseOrderFormatting(mode, "")
+		if err != nil || formatting == "" {
+			return fmt.Errorf("不支持的订单格式化方式: %q", mode)
+		}
+		filter := models.OrderFilter{Timezone: *timezone, Limit: *size, Formatting: formatting}
+
+		// 预热并校验各方式得到的日期和星期一致
+		h := fnv.New64a()
+		rows := 0
+		err = svc.StreamOrders(context.Background(), filter, func(o models.OrderAnalysis) error {
+			date, weekday := o.LocalDate, o.LocalWeekday
+			if formatting == models.OrderFormattingRaw {
+				date, weekday = o.OrderTimeLocal.Format("2006-01-02"), time.Weekday(o.LocalDayOfWeek).String()
+			}
+			fmt.Fprintf(h, "%d|%s|%s\n", o.OrderID, date, weekday)
+			rows++
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s 预热失败: %w", formatting, err)
+		}
+		if i == 0 {
+			baseline = h.Sum64()
+		} else if h.Sum64() != baseline {
+			return fmt.Errorf("%s 的日期或星期与 %s 不一致", formatting, strings.Split(*modes, ",")[0])
+		}
+
+		durations := make([]time.Duration, 0, *n)
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		for j := 0; j < *n; j++ {
+			start := time.Now()
+			if err := svc.StreamOrders(context.Background(), filter, func(models.OrderAnalysis) error { return nil }); err != nil {
+				return fmt.Errorf("%s 第 %d 次执行失败: %w", formatting, j+1, err)
+			}
+			durations = append(durations, time.Since(start))
+		}
+		runtime.ReadMemStats(&after)
+
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		var total time.Duration
+		for _, d := range durations {
+			total += d
+		}
+		avg := total / time.Duration(len(durations))
+		allocs := float64(after.Mallocs-before.Mallocs) / float64(*n) / float64(max(rows, 1))
+		fmt.Printf("%-5s %8d %10s %10s %10s %12.1f %10.0f\n", formatting, rows,
+			roundDuration(durations[0]),
+			roundDuration(durations[len(durations)/2]),
+			roundDuration(avg),
+			allocs,
+			float64(rows)/avg.Seconds())
+	}
+	return nil
+}
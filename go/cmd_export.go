@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+
+	"timezone-saas-demo/export"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/services"
+)
+
+// runExport 导出订单数据
+func runExport(config *AppConfig, args []string) error {
+	fs := newFlagSet("export")
+	formatStr := fs.String("format", "csv", "导出格式: csv | ndjson | parquet")
+	timezone := fs.String("timezone", "", "只导出指定时区的商户订单")
+	status := fs.String("status", "", "只导出指定状态的订单，如 paid,shipped")
+	outPath := fs.String("out", "-", "输出文件路径，- 表示标准输出；parquet 格式为输出目录")
+	formattingStr := fs.String("formatting", config.OrderFormatting, "local_date、local_weekday 的格式化方式: go | sql | raw（raw 时两列为空）")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	format, err := export.ParseFormat(*formatStr)
+	if err != nil {
+		return err
+	}
+	statuses, err := services.ParseStatuses(*status)
+	if err != nil {
+		return err
+	}
+
+	formatting, err := services.ParseOrderFormatting(*formattingStr, models.OrderFormattingGo)
+	if err != nil {
+		return err
+	}
+
+	if format == export.FormatParquet && *outPath == "-" {
+		return fmt.Errorf("parquet 格式按商户和本地日期分区写出，需要用 -out 指定输出目录")
+	}
+	if format == export.FormatParquet && formatting == models.OrderFormattingRaw {
+		return fmt.Errorf("parquet 格式按 local_date 分区，-formatting 只能是 %s 或 %s", models.OrderFormattingGo, models.OrderFormattingSQL)
+	}
+
+	conn, svc, err := openServices(config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// 逐行扫描写出，内存占用与订单总数无关；Ctrl-C 时中止查询
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	filter := models.OrderFilter{Timezone: *timezone, Statuses: statuses, Formatting: formatting}
+
+	if format == export.FormatParquet {
+		return exportPartitioned(ctx, svc, filter, *outPath, format)
+	}
+
+	var out io.Writer = os.Stdout
+	if *outPath != "-" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			return fmt.Errorf("创建输出文件失败: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	buffered := bufio.NewWriter(out)
+
+	writer, err := export.NewOrderWriter(buffered, format)
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	err = svc.StreamOrders(ctx, filter, func(order models.OrderAnalysis) error {
+		if err := writer.Write(order); err != nil {
+			return fmt.Errorf("写出订单失败: %w", err)
+		}
+		total++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("写出订单失败: %w", err)
+	}
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("写出订单失败: %w", err)
+	}
+
+	log.Printf("导出完成: %d 条订单（%s）", total, format)
+	return nil
+}
+
+// exportPartitioned 按商户和本地日期把订单写到 dir 下的分区目录（tenant=<商户>/dt=<本地日期>）
+// 每个分区在内存中缓存一个行组，同时打开的分区数有上限，内存占用与订单总数无关
+func exportPartitioned(ctx context.Context, svc *services.TimezoneService, filter models.OrderFilter, dir string, format export.Format) error {
+	writer, err := export.NewPartitionedWriter(dir, format, export.DefaultMaxOpenPartitions)
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	err = svc.StreamOrders(ctx, filter, func(order models.OrderAnalysis) error {
+		if err := writer.Write(order); err != nil {
+			return fmt.Errorf("写出订单失败: %w", err)
+		}
+		total++
+		return nil
+	})
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("导出完成: %d 条订单（%s），%d 个文件写入 %s", total, format, writer.Files(), dir)
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"timezone-saas-demo/database"
+)
+
+// runHealthcheck 容器健康检查：失败时返回非零退出码，无需在镜像中安装 curl
+//
+//	./main healthcheck --timeout 2s              请求本机 /api/health/ready
+//	./main healthcheck --mode db --timeout 2s    直接检查数据库
+func runHealthcheck(config *AppConfig, args []string) error {
+	fs := newFlagSet("healthcheck")
+	mode := fs.String("mode", "http", "检查方式: http（请求就绪接口）| db（直接检查数据库）")
+	url := fs.String("url", "", "就绪接口地址，默认 http(s)://127.0.0.1:$PORT/api/health/ready，PORT=off 时经 UNIX_SOCKET 请求")
+	timeout := fs.Duration("timeout", 5*time.Second, "整体超时时间")
+	quiet := fs.Bool("quiet", false, "成功时不输出日志")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	var err error
+	switch *mode {
+	case "http":
+		client, target := http.DefaultClient, *url
+		if target == "" {
+			client, target = localProbe(config)
+		}
+		err = probeHTTP(ctx, client, target)
+	case "db":
+		err = probeDatabase(ctx, config)
+	default:
+		return fmt.Errorf("不支持的检查方式: %s", *mode)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !*quiet {
+		log.Println("✅ 健康检查通过")
+	}
+	return nil
+}
+
+// localProbe 按 serve 的监听方式请求本机的就绪接口
+// HTTPS 时不校验证书（证书签发给对外域名而不是 127.0.0.1），自动证书时 SNI 使用第一个域名；只监听 Unix 套接字时经套接字请求
+func localProbe(config *AppConfig) (*http.Client, string) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	switch {
+	case config.Port == portOff:
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", config.UnixSocket)
+		}
+		return &http.Client{Transport: transport}, "http://unix/api/health/ready"
+	case config.TLSEnabled():
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		if len(config.AutocertDomains) > 0 {
+			transport.TLSClientConfig.ServerName = config.AutocertDomains[0]
+		}
+		return &http.Client{Transport: transport}, fmt.Sprintf("https://127.0.0.1:%s/api/health/ready", config.Port)
+	default:
+		return http.DefaultClient, fmt.Sprintf("http://127.0.0.1:%s/api/health/ready", config.Port)
+	}
+}
+
+// probeHTTP 请求就绪接口，非 2xx 视为失败
+func probeHTTP(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求就绪接口失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("就绪接口返回 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// probeDatabase 直接连接数据库执行 DB.HealthCheck，在 ctx 超时后放弃等待
+func probeDatabase(ctx context.Context, config *AppConfig) error {
+	done := make(chan error, 1)
+	go func() {
+		conn, err := openDatabase(config, database.RetryPolicy{})
+		if err != nil {
+			done <- err
+			return
+		}
+		defer conn.Close()
+		done <- conn.HealthCheck()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("数据库健康检查超时: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"timezone-saas-demo/database"
+)
+
+// runMigrate 执行数据库迁移
+func runMigrate(config *AppConfig, args []string) error {
+	fs := newFlagSet("migrate")
+	dir := fs.String("dir", config.SQLDir, "SQL 脚本目录")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	conn, err := openDatabase(config, database.RetryPolicyFromEnv())
+	if err != nil {
+		return fmt.Errorf("数据库连接失败: %w", err)
+	}
+	defer conn.Close()
+
+	applied, err := conn.Migrate(*dir)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("迁移完成，本次应用 %d 个脚本", len(applied))
+	return nil
+}
+
+// runSeed 导入示例数据
+func runSeed(config *AppConfig, args []string) error {
+	fs := newFlagSet("seed")
+	dir := fs.String("dir", config.SQLDir, "SQL 脚本目录")
+	yes := fs.Bool("yes", false, "确认清空现有商户和订单数据")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !*yes {
+		return fmt.Errorf("示例数据脚本会清空现有商户和订单，请使用 -yes 确认")
+	}
+
+	conn, err := openDatabase(config, database.RetryPolicyFromEnv())
+	if err != nil {
+		return fmt.Errorf("数据库连接失败: %w", err)
+	}
+	defer conn.Close()
+
+	seeded, err := conn.Seed(*dir)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("示例数据导入完成: %v", seeded)
+	return nil
+}
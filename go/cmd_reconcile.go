@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/services"
+)
+
+// runReconcileEvents 核对支付平台事件的日期与订单本地日期，报告以 JSON 写出，适合定时任务
+// 指定 -file 时核对导出的 Stripe 事件，否则核对已接收的 Webhook 事件
+func runReconcileEvents(config *AppConfig, args []string) error {
+	fs := newFlagSet("reconcile-events")
+	file := fs.String("file", "", "Stripe 事件列表文件（GET /v1/events 的响应或事件数组），- 表示标准输入；为空时核对已接收的 Webhook 事件")
+	provider := fs.String("provider", "stripe", "核对 Webhook 事件时的平台")
+	from := fs.String("from", "", "核对 Webhook 事件时的开始日期（UTC，YYYY-MM-DD），默认 7 天前")
+	to := fs.String("to", "", "核对 Webhook 事件时的结束日期（UTC，YYYY-MM-DD），默认今天")
+	merchant := fs.Int("merchant", 0, "只核对该商户的订单，0 表示全部商户")
+	outPath := fs.String("out", "-", "报告输出文件路径，- 表示标准输出")
+	strict := fs.Bool("strict", false, "存在本地日期不一致（local_date）的事件时返回非零退出码")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var events []models.ProviderEvent
+	if *file != "" {
+		var body []byte
+		var err error
+		if *file == "-" {
+			body, err = io.ReadAll(os.Stdin)
+		} else {
+			body, err = os.ReadFile(*file)
+		}
+		if err != nil {
+			return fmt.Errorf("读取事件文件失败: %w", err)
+		}
+		if events, err = services.ParseProviderEvents(body); err != nil {
+			return err
+		}
+	}
+
+	conn, err := openDatabase(config, database.RetryPolicyFromEnv())
+	if err != nil {
+		return fmt.Errorf("数据库连接失败: %w", err)
+	}
+	defer conn.Close()
+	ingest := services.NewIngestService(conn)
+
+	var report *models.EventReconciliationReport
+	if *file != "" {
+		report, err = ingest.ReconcileEvents(context.Background(), events, *merchant)
+	} else {
+		report, err = ingest.ReconcileDeliveries(context.Background(), *provider, *from, *to, *merchant)
+	}
+	if err != nil {
+		return err
+	}
+
+	out := os.Stdout
+	if *outPath != "-" {
+		if out, err = os.Create(*outPath); err != nil {
+			return fmt.Errorf("创建输出文件失败: %w", err)
+		}
+		defer out.Close()
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("写出报告失败: %w", err)
+	}
+
+	localMismatches := 0
+	for _, zone := range report.Timezones {
+		localMismatches += zone.LocalDateMismatches
+		log.Printf("%s: %d 个事件，UTC 日期不一致 %d 个，本地日期不一致 %d 个", zone.Timezone, zone.Events, zone.UTCDateMismatches, zone.LocalDateMismatches)
+	}
+	log.Printf("核对完成: %d 个事件，匹配订单 %d 个，日期不一致 %d 个，未找到订单 %d 个",
+		report.TotalEvents, report.MatchedEvents, report.Mismatches, report.UnmatchedEvents)
+	if *strict && localMismatches > 0 {
+		return fmt.Errorf("%d 个事件的本地日期与订单本地日期不一致", localMismatches)
+	}
+	return nil
+}
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/locale"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/redact"
+	"timezone-saas-demo/repository"
+	"timezone-saas-demo/secrets"
+	"timezone-saas-demo/services"
+	"timezone-saas-demo/tzdb"
+)
+
+// runServe 启动 API 服务
+func runServe(config *AppConfig, args []string) error {
+	fs := newFlagSet("serve")
+	port := fs.String("port", config.Port, "HTTP 监听端口，off 表示不监听 TCP 端口")
+	socket := fs.String("socket", config.UnixSocket, "额外监听的 Unix 套接字路径")
+	mock := fs.Bool("mock", false, "不连接数据库，使用内存中的确定性示例数据提供全部接口（前端离线开发用）")
+	mockSeed := fs.Int64("mock-seed", 1, "mock 模式生成数据和注入错误的随机种子")
+	mockDate := fs.String("mock-date", "", "mock 模式订单覆盖到的日期 YYYY-MM-DD 或 today，默认 2024-08-19")
+	mockLatency := fs.Duration("mock-latency", 0, "mock 模式每个 API 请求的固定延迟")
+	mockJitter := fs.Duration("mock-jitter", 0, "mock 模式在固定延迟上随机增加的最大延迟")
+	mockErrorRate := fs.Float64("mock-error-rate", 0, "mock 模式随机返回 5xx 的请求比例，0~1")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// 加载额外的 API 消息翻译
+	if config.MessagesDir != "" {
+		if err := locale.LoadMessages(config.MessagesDir); err != nil {
+			return fmt.Errorf("加载消息翻译失败: %w", err)
+		}
+	}
+
+	// tzdata 过旧时历史和未来的本地时间可能按过期的夏令时规则换算
+	warnOutdatedTZData(context.Background(), config.TZDataManifest)
+
+	alerter, mailer, rotator, err := setupMonitors(config)
+	if err != nil {
+		return err
+	}
+
+	if *mock {
+		endDate, err := parseMockDate(*mockDate)
+		if err != nil {
+			return err
+		}
+		// mock 模式始终启用故障注入，延迟和错误率参数转换为初始规则
+		faultInjector = services.NewFaultInjector(*mockSeed)
+		if err := setupMockFaults(*mockLatency, *mockJitter, *mockErrorRate); err != nil {
+			return err
+		}
+		setupMockServices(*mockSeed, endDate, alerter, mailer)
+	}
+	if err := configureServer(config); err != nil {
+		return err
+	}
+
+	switch {
+	case *mock:
+		if err := startServices(config); err != nil {
+			return err
+		}
+		// mock 模式始终使用内存数据
+		if config.AnalyticsBackend != "postgres" {
+			log.Printf("⚠️ mock 模式忽略分析存储 %s，分析接口使用内存数据", config.AnalyticsBackend)
+		}
+	case config.ServeBeforeDBReady:
+		// 先开始监听，存活探针立即可用；数据库连接成功、各服务初始化后才标记为就绪
+		startup.begin()
+		go func() {
+			if err := connectServices(config, alerter, mailer, rotator); err != nil {
+				log.Fatalf("❌ serve 失败: %v", err)
+			}
+			startup.finish()
+			log.Println("✅ 服务已就绪")
+		}()
+	default:
+		if err := connectServices(config, alerter, mailer, rotator); err != nil {
+			return err
+		}
+		defer db.Close()
+	}
+
+	// 定期重新读取密钥，轮换后更新数据库连接池、管理令牌和告警地址
+	watchSecrets(rotator, config, alerter)
+	if config.SecretsRotationInterval > 0 {
+		go rotator.Run(context.Background(), config.SecretsRotationInterval)
+		log.Printf("🔑 每 %s 从 %s 重新读取密钥", config.SecretsRotationInterval, config.Secrets.Name())
+	}
+
+	// 设置路由
+	router := setupRoutes()
+
+	// 启动服务器，收到 SIGTERM 后等待进行中的请求完成再返回
+	return serveHTTP(config, *port, *socket, router)
+}
+
+// setupMonitors 创建告警、邮件、时钟和 SLA 监控、过载保护和密钥轮换，mock 模式和连接数据库时共用
+func setupMonitors(config *AppConfig) (services.Alerter, services.Mailer, *secrets.Rotator, error) {
+	alerter := newAlerter(config)
+	mailer, err := newMailer(config)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	clockMonitor = services.NewClockMonitor(config.ClockSkewThreshold, alerter)
+	if config.NTPServer != "" {
+		clockMonitor.AddSource("ntp "+config.NTPServer, services.NTPSource(config.NTPServer))
+	}
+	slaMonitor = services.NewSLAMonitor(config.SLAWindow, config.SLABudget, alerter)
+	loadShedder = newLoadShedder(config)
+	return alerter, mailer, secrets.NewRotator(config.Secrets), nil
+}
+
+// configureServer 按配置设置路由和中间件使用的管理令牌、语句超时、响应缓存等，在 mock 服务创建之后调用
+func configureServer(config *AppConfig) error {
+	setAdminToken(config.AdminToken)
+	apiStatementTimeout, exportStatementTimeout = config.APIStatementTimeout, config.ExportStatementTimeout
+	captureRecorder = services.NewCaptureRecorder(config.CaptureCapacity, config.CaptureMaxBody)
+	responseCache = services.NewResponseCache(config.ResponseCacheTTL, config.ResponseCacheStale, config.ResponseCacheEntries)
+	if config.FaultInjection && faultInjector == nil {
+		faultInjector = services.NewFaultInjector(time.Now().UnixNano())
+		log.Printf("⚠️ 已启用故障注入，可通过 /api/admin/faults 配置规则，不要在生产环境开启")
+	}
+	debugEndpoints = config.DebugEndpoints
+	fastJSON = config.JSONEncoder == jsonEncoderFast
+	if config.DemoPseudonymize {
+		var err error
+		if pseudonymizer, err = newPseudonymizer(config); err != nil {
+			return err
+		}
+	}
+	orderFormatting = config.OrderFormatting
+	trustedProxies = config.TrustedProxies
+	csrf = config.CSRF
+	if currentAdminToken() == "" {
+		log.Printf("⚠️ 未设置 ADMIN_TOKEN，/api/admin 接口不可用")
+	}
+	if debugEndpoints {
+		log.Printf("🔍 已在 /debug/pprof 和 /debug/vars 开启性能分析，需要管理令牌")
+	}
+	return nil
+}
+
+// connectServices 连接数据库（按 DB_CONNECT_* 重试）并初始化各业务服务和后台任务
+func connectServices(config *AppConfig, alerter services.Alerter, mailer services.Mailer, rotator *secrets.Rotator) error {
+	conn, tzService, err := openServices(config)
+	if err != nil {
+		return err
+	}
+	if config.SlowQueryThreshold > 0 {
+		conn.SetSlowQueryLog(database.NewSlowQueryLog(config.SlowQueryThreshold, config.SlowQueryExplainRate, database.DefaultSlowQueryCapacity))
+	}
+	if config.CircuitBreakerThreshold > 0 {
+		conn.SetCircuitBreaker(database.NewCircuitBreaker(config.CircuitBreakerThreshold, config.CircuitBreakerOpenTimeout))
+	}
+	var plans *database.PlanRecorder
+	if config.PlanRegressionInterval > 0 {
+		plans = database.NewPlanRecorder(database.DefaultPlanFingerprints)
+		conn.SetPlanRecorder(plans)
+	}
+	// 分析接口默认读取汇总表，ANALYSIS_ROLLUP=false 时扫描分析视图
+	analytics := repository.NewPostgresAnalysisRepository(conn)
+	analytics.SetRollup(config.AnalysisRollup)
+	tzService.SetAnalysisRepository(analytics)
+	db, timezoneService = conn, tzService
+	billingService = services.NewBillingService(db)
+	revenueCloseService = services.NewRevenueCloseService(db)
+	onboardingService = services.NewOnboardingService(db)
+	refundService = services.NewRefundService(db)
+	indexAdvisorService = services.NewIndexAdvisorService(db)
+	settingsService = services.NewSettingsService(db)
+	overviewService = services.NewOverviewService(db, requestCounter, timezoneService.TenantQueryStats)
+	consistencyService = services.NewConsistencyService(db, alerter)
+	organizationService = services.NewOrganizationService(db)
+	reportService = services.NewReportService(db, timezoneService)
+	emailService = services.NewEmailService(db, settingsService, mailer)
+	alertRuleService = services.NewAlertRuleService(db, emailService)
+	dashboardService = services.NewDashboardService(db)
+	changeService = services.NewChangeService(db)
+	syncService = services.NewSyncService(db)
+	ingestService = services.NewIngestService(db)
+	rollupService = services.NewRollupService(db)
+	clockMonitor.AddSource("postgres", db.Now)
+	// 未配置归档目录时保留策略只能归档到冷表
+	var archiveStore services.ArchiveStore
+	if config.RetentionArchiveDir != "" {
+		archiveStore = services.NewFileArchiveStore(config.RetentionArchiveDir)
+	}
+	retentionService = services.NewRetentionService(db, archiveStore, config.RetentionArchiveFormat)
+	tenantDataService = services.NewTenantDataService(db, archiveStore)
+	provisioningService = services.NewTenantProvisioningService(db, onboardingService)
+	lifecycleService = services.NewLifecycleService(db)
+	attributeService = services.NewAttributeService(db)
+	savedViewService = services.NewSavedViewService(db)
+	jobService = services.NewJobService(db)
+	if config.LeaderElection {
+		leaderElector = services.NewLeaderElector(db, services.DefaultLeaderLockName, config.LeaderLeaseInterval)
+		jobService.SetLeader(leaderElector)
+	}
+	queryConsoleService = services.NewQueryConsoleService(db, config.AdminQueryRole, config.AdminQueryTimeout, config.AdminQueryMaxRows)
+	if config.CanaryInterval > 0 {
+		canaryService = services.NewCanaryService(db, timezoneService, alerter)
+	}
+	if plans != nil {
+		planRegressionService = services.NewPlanRegressionService(db, plans, config.DeployVersion, config.PlanRegressionThreshold)
+	}
+
+	// DB_PASSWORD 变化时先用新密码建立测试连接，成功后重建连接池；DB_USER 只在启动时读取
+	rotator.Watch("DB_PASSWORD", db.Config().Password, func(ctx context.Context, value string) error {
+		dbConfig := db.Config()
+		dbConfig.Password = value
+		return db.Rotate(ctx, dbConfig)
+	})
+
+	if err := startServices(config); err != nil {
+		return err
+	}
+
+	// 可选：使用 ClickHouse 作为分析查询后端
+	if err := setupAnalyticsBackend(context.Background(), config, rotator); err != nil {
+		return fmt.Errorf("分析存储初始化失败: %w", err)
+	}
+	if config.AnalysisQueryMode == services.AnalysisQueryModeSingle && config.AnalyticsBackend != "postgres" {
+		log.Printf("⚠️ 分析存储 %s 不支持 single 查询方式，分析接口按 fanout 查询", config.AnalyticsBackend)
+	}
+	return nil
+}
+
+// startServices 按配置设置各服务并启动后台任务，mock 模式和连接数据库后共用
+func startServices(config *AppConfig) error {
+	if err := timezoneService.SetRevenueDefinition(config.Revenue); err != nil {
+		return fmt.Errorf("营收口径配置错误: %w", err)
+	}
+	timezoneService.SetQueryTimeout(config.AnalysisQueryTimeout)
+	if err := timezoneService.SetQueryMode(config.AnalysisQueryMode); err != nil {
+		return fmt.Errorf("分析查询方式配置错误: %w", err)
+	}
+	// 按租户限制同时执行的分析请求，避免单个租户占满连接池
+	if config.TenantQueryLimit > 0 {
+		timezoneService.SetTenantLimiter(services.NewTenantLimiter(config.TenantQueryLimit, config.TenantQueueTimeout))
+	}
+	consistencyService.SetSampleSize(config.ConsistencySampleSize)
+	organizationService.SetRevenueDefinition(config.Revenue)
+	alertRuleService.SetRevenueDefinition(config.Revenue)
+	dashboardService.SetRevenueDefinition(config.Revenue)
+	changeService.SetPollInterval(config.ChangesPollInterval)
+	for provider, secret := range config.WebhookSecrets {
+		ingestService.SetSecret(provider, secret)
+	}
+	ingestService.SetSettingsService(settingsService)
+	reportService.SetEmailService(emailService, config.PublicBaseURL)
+	onboardingService.SetEmailService(emailService)
+	// 时区、营业时间等配置影响分析结果和营业状态，修改后清空响应缓存和商户快照
+	settingsService.Subscribe(func(models.SettingChange) { responseCache.Purge("") })
+	settingsService.Subscribe(func(models.SettingChange) { timezoneService.InvalidateMerchantSnapshot() })
+	settingsService.Subscribe(func(change models.SettingChange) {
+		r := redact.Current()
+		log.Printf("⚙️ 商户 %d 配置 %s 已由 %s 修改: %s → %s", change.MerchantID, change.Key, redact.Inline(change.ChangedBy),
+			r.Field(change.Key, string(change.Old)), r.Field(change.Key, string(change.New)))
+	})
+
+	// 本机时钟偏差会让订单算错本地日期，启动时先检查一次
+	go clockMonitor.Run(context.Background(), config.ClockCheckInterval)
+	// 每个实例统计和检查自己处理的请求，不是单实例任务
+	if config.SLACheckInterval > 0 {
+		go slaMonitor.Run(context.Background(), config.SLACheckInterval)
+	}
+	// 每个实例按自己的连接池和排队情况决定是否拒绝请求
+	if loadShedder != nil {
+		go loadShedder.Run(context.Background(), config.LoadShedInterval)
+	}
+	// 每个实例检查自己的读取路径，mock 模式没有合成租户
+	if canaryService != nil {
+		go canaryService.Run(context.Background(), config.CanaryInterval)
+	}
+	// 每个实例统计自己执行的语句，mock 模式没有数据库
+	if planRegressionService != nil {
+		go planRegressionService.Run(context.Background(), config.PlanRegressionInterval)
+	}
+	// 以下为单实例任务，多实例部署时只在主实例上执行
+	// 每个商户本地零点过后结账
+	if config.DailyCloseInterval > 0 {
+		runSingleton(func(ctx context.Context) { revenueCloseService.Run(ctx, config.DailyCloseInterval) })
+	}
+	// 定期核对订单表与分析视图，发现差异时发送告警
+	if config.ConsistencyCheckInterval > 0 {
+		runSingleton(func(ctx context.Context) { consistencyService.Run(ctx, config.ConsistencyCheckInterval) })
+	}
+	// 按报表时区的重复规则执行定时报表
+	if config.ReportScheduleInterval > 0 {
+		runSingleton(func(ctx context.Context) { reportService.Run(ctx, config.ReportScheduleInterval) })
+	}
+	// 按商户时区和营业时间检查租户的指标告警规则
+	if config.AlertEvaluationInterval > 0 {
+		runSingleton(func(ctx context.Context) { alertRuleService.Run(ctx, config.AlertEvaluationInterval) })
+	}
+	// 按商户的保留策略归档旧订单，mock 模式没有归档服务
+	if config.RetentionInterval > 0 && retentionService != nil {
+		runSingleton(func(ctx context.Context) { retentionService.Run(ctx, config.RetentionInterval) })
+	}
+	// 按商户本地日期将试用到期的租户改为暂停，mock 模式没有生命周期服务
+	if config.TrialExpiryInterval > 0 && lifecycleService != nil {
+		runSingleton(func(ctx context.Context) { lifecycleService.Run(ctx, config.TrialExpiryInterval) })
+	}
+	// 后台任务在各队列的工作协程中执行，mock 模式没有任务服务
+	if config.JobPollInterval > 0 && jobService != nil {
+		registerJobKinds(jobService)
+		for queue, n := range config.JobConcurrency {
+			jobService.SetConcurrency(queue, n)
+		}
+		go jobService.Run(context.Background(), config.JobPollInterval)
+	}
+	if leaderElector != nil {
+		go leaderElector.Run(context.Background())
+	}
+	return nil
+}
+
+// runSingleton 启动单实例任务：开启选主时由主实例执行，失去主实例身份时停止；mock 模式或关闭选主时直接执行
+func runSingleton(task func(ctx context.Context)) {
+	if leaderElector == nil {
+		go task(context.Background())
+		return
+	}
+	leaderElector.Go(task)
+}
+
+// setupAnalyticsBackend 根据 ANALYTICS_BACKEND 配置分析存储
+// clickhouse 模式下订单会被周期性镜像到 ClickHouse，/api/timezone/analysis* 查询走 ClickHouse
+func setupAnalyticsBackend(ctx context.Context, config *AppConfig, rotator *secrets.Rotator) error {
+	if config.AnalyticsBackend != "clickhouse" {
+		return nil
+	}
+
+	ch, err := openClickHouse(config)
+	if err != nil {
+		return err
+	}
+	_, password := ch.Credentials()
+	rotator.Watch("CLICKHOUSE_PASSWORD", password, func(ctx context.Context, value string) error {
+		user, old := ch.Credentials()
+		ch.SetCredentials(user, value)
+		if err := ch.Ping(); err != nil {
+			ch.SetCredentials(user, old)
+			return err
+		}
+		return nil
+	})
+
+	mirror := services.NewClickHouseMirror(db, ch, config.ClickHouseMirrorInterval)
+	if err := mirror.Init(); err != nil {
+		return err
+	}
+	runSingleton(mirror.Run)
+
+	// 营业时间和周末修改后，ClickHouse 中该商户已镜像订单的本地时间字段需要回填
+	backfillService = services.NewClickHouseBackfill(db, ch)
+	tenantDataService.SetClickHouse(ch, backfillService)
+	settingsService.Subscribe(func(change models.SettingChange) {
+		if change.Key != services.SettingBusinessHours.Name && change.Key != services.SettingWeekendDays.Name {
+			return
+		}
+		job, err := backfillService.Create(ctx, services.BackfillRequest{
+			MerchantIDs: []int{change.MerchantID},
+			Reason:      "配置 " + change.Key + " 已修改",
+		})
+		if err == nil {
+			err = backfillService.Start(job.ID)
+		}
+		if err != nil {
+			log.Printf("商户 %d 回填任务创建失败: %v", change.MerchantID, err)
+		}
+	})
+
+	timezoneService.SetAnalysisRepository(repository.NewClickHouseAnalysisRepository(ch))
+	log.Printf("📈 分析查询使用 ClickHouse，镜像间隔 %s", config.ClickHouseMirrorInterval)
+	return nil
+}
+
+// warnOutdatedTZData 当前 tzdata 版本早于清单中的最新版本时打印警告，获取清单失败不影响启动
+func warnOutdatedTZData(ctx context.Context, manifestSource string) {
+	manifest, err := tzdb.LoadManifest(ctx, manifestSource)
+	if err != nil {
+		log.Printf("⚠️ 读取 tzdata 版本清单失败: %v", err)
+		return
+	}
+	info := tzdb.Detect()
+	current := info.EffectiveVersion()
+	if current == "" {
+		log.Printf("⚠️ 无法确定 %s 的 tzdata 版本，最新版本为 %s", info.Source, manifest.Version)
+		return
+	}
+	if cmp, ok := tzdb.CompareVersions(current, manifest.Version); ok && cmp < 0 {
+		log.Printf("⚠️ tzdata 版本 %s 早于最新版本 %s，可设置 TZDATA_DIR 指向新的 zoneinfo 目录后调用 POST /api/admin/tzdata/reload", current, manifest.Version)
+	}
+}
+
+// watchSecrets 跟踪与数据库无关、可以在运行中轮换的密钥
+func watchSecrets(rotator *secrets.Rotator, config *AppConfig, alerter services.Alerter) {
+	rotator.Watch("ADMIN_TOKEN", config.AdminToken, func(ctx context.Context, value string) error {
+		setAdminToken(value)
+		return nil
+	})
+	if webhook, ok := alerter.(*services.WebhookAlerter); ok {
+		rotator.Watch("ALERT_WEBHOOK_URL", config.AlertWebhookURL, func(ctx context.Context, value string) error {
+			webhook.SetURL(value)
+			return nil
+		})
+	}
+	for _, provider := range services.IngestProviders() {
+		provider := provider
+		rotator.Watch(webhookSecretKey(provider), config.WebhookSecrets[provider], func(ctx context.Context, value string) error {
+			// SERVE_BEFORE_DB_READY 时数据库就绪前还没有 ingestService，就绪后使用启动时读取的密钥
+			if ingestService != nil {
+				ingestService.SetSecret(provider, value)
+			}
+			return nil
+		})
+	}
+}
+
+// newAlerter 未配置 ALERT_WEBHOOK_URL 时返回 nil，告警只记录在日志中
+func newAlerter(config *AppConfig) services.Alerter {
+	if config.AlertWebhookURL == "" {
+		return nil
+	}
+	return services.NewWebhookAlerter(config.AlertWebhookURL)
+}
+
+// newMailer 未配置 SMTP_ADDR 时返回 nil，告警、报表和入驻邮件都不发送
+func newMailer(config *AppConfig) (services.Mailer, error) {
+	if config.SMTPAddr == "" {
+		return nil, nil
+	}
+	mailer, err := services.NewSMTPMailer(config.SMTPAddr, config.SMTPFrom, config.SMTPUsername, config.SMTPPassword)
+	if err != nil {
+		return nil, fmt.Errorf("SMTP 配置错误: %w", err)
+	}
+	return mailer, nil
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"timezone-saas-demo/client"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/repository"
+	"timezone-saas-demo/services"
+)
+
+const (
+	// simulateModeAPI 以 Shopify 的身份调用 Webhook 接收接口，经过签名校验、冲突处理和响应缓存清空
+	simulateModeAPI = "api"
+	// simulateModeDB 直接批量写入 dws_orders（order_source 为 simulator），不经过服务
+	simulateModeDB = "db"
+	// simulateProvider API 模式投递使用的平台
+	simulateProvider = "shopify"
+	// simulateDBBatch 直接写库时每个语句的最大订单数
+	simulateDBBatch = 1000
+	// simulateReportInterval 输出进度的间隔
+	simulateReportInterval = 10 * time.Second
+)
+
+// runSimulate 持续生成合成订单流，用于对缓存、汇总表、告警等功能做压力测试
+// 每个商户按本地时间的昼夜曲线下单，-rate 为全部商户的全天平均速率；Ctrl-C 或到达 -duration 后停止
+//
+//	./main simulate -rate 20 -url http://localhost:8080        # 经 Webhook 接收接口写入，需要 WEBHOOK_SECRET_SHOPIFY
+//	./main simulate -mode db -rate 200 -duration 10m          # 直接写库
+func runSimulate(config *AppConfig, args []string) error {
+	fs := newFlagSet("simulate")
+	mode := fs.String("mode", simulateModeAPI, "写入方式：api（Webhook 接收接口）或 db（直接写入 dws_orders）")
+	baseURL := fs.String("url", "http://localhost:8080", "api 模式的服务地址")
+	rate := fs.Float64("rate", 5, "全部商户全天平均每秒的订单数，实际速率随各商户的本地时间变化")
+	duration := fs.Duration("duration", 0, "运行时长，0 表示直到 Ctrl-C")
+	merchants := fs.String("merchants", "", "参与模拟的商户ID，逗号分隔，为空表示全部商户")
+	amount := fs.Float64("amount", services.DefaultSimulatorAmount, "订单金额的中位数（商户币种）")
+	tick := fs.Duration("tick", time.Second, "生成订单的间隔，每个间隔内的订单一起写入")
+	concurrency := fs.Int("concurrency", 8, "api 模式的并发请求数")
+	seed := fs.Int64("seed", 0, "随机种子，0 表示按当前时间")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *mode != simulateModeAPI && *mode != simulateModeDB {
+		return fmt.Errorf("-mode 只能是 %s 或 %s", simulateModeAPI, simulateModeDB)
+	}
+	if *tick < 10*time.Millisecond || *concurrency <= 0 {
+		return fmt.Errorf("-tick 不能小于 10ms，-concurrency 必须大于 0")
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	merchantIDs, err := parseMerchantIDs(*merchants)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	var sink simulateSink
+	var all []models.Merchant
+	switch *mode {
+	case simulateModeAPI:
+		secret := config.WebhookSecrets[simulateProvider]
+		if secret == "" {
+			return fmt.Errorf("api 模式需要与服务相同的 WEBHOOK_SECRET_SHOPIFY")
+		}
+		c, err := client.New(*baseURL)
+		if err != nil {
+			return err
+		}
+		if all, _, err = c.Merchants(ctx, client.Page{}); err != nil {
+			return fmt.Errorf("读取商户列表失败: %w", err)
+		}
+		sink = &simulateAPISink{client: c, secret: secret, concurrency: *concurrency, locations: map[int]*time.Location{}}
+	case simulateModeDB:
+		conn, svc, err := openServices(config)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if all, err = svc.GetMerchants(); err != nil {
+			return fmt.Errorf("读取商户列表失败: %w", err)
+		}
+		sink = &simulateDBSink{orders: repository.NewPostgresSimulationRepository(conn)}
+	}
+
+	selected, err := selectMerchants(all, merchantIDs)
+	if err != nil {
+		return err
+	}
+	sim, err := services.NewOrderSimulator(selected, *rate, *amount, *seed)
+	if err != nil {
+		return err
+	}
+	if s, ok := sink.(*simulateAPISink); ok {
+		for _, m := range selected {
+			if s.locations[m.ID], err = services.LoadLocation(m.Timezone); err != nil {
+				return err
+			}
+		}
+	}
+
+	started := time.Now()
+	log.Printf("开始模拟订单流：%d 个商户，写入方式 %s，全天平均 %.1f 笔/秒，当前 %.1f 笔/秒", len(selected), *mode, *rate, sim.Rate(started))
+
+	var written, failed int64
+	ticker := time.NewTicker(*tick)
+	defer ticker.Stop()
+	report := time.NewTicker(simulateReportInterval)
+	defer report.Stop()
+	last, reported, reportedAt := started, int64(0), started
+	for {
+		select {
+		case <-ctx.Done():
+			elapsed := time.Since(started)
+			log.Printf("模拟结束：%s 内写入 %d 笔订单，失败 %d 笔，平均 %.1f 笔/秒",
+				elapsed.Round(time.Second), written, failed, float64(written)/elapsed.Seconds())
+			return nil
+		case now := <-report.C:
+			log.Printf("已写入 %d 笔订单，失败 %d 笔；最近 %.1f 笔/秒，预期 %.1f 笔/秒",
+				written, failed, float64(written-reported)/now.Sub(reportedAt).Seconds(), sim.Rate(now))
+			reported, reportedAt = written, now
+		case now := <-ticker.C:
+			// 上一批写入较慢时错过的间隔由这一批补上，订单时刻仍分布在整个区间内
+			orders := sim.Next(last, now)
+			last = now
+			ok, err := sink.write(ctx, orders)
+			written += int64(ok)
+			if err != nil && ctx.Err() == nil {
+				// 停止时未发出的订单不计为失败
+				failed += int64(len(orders) - ok)
+				log.Printf("写入模拟订单失败: %v", err)
+			}
+		}
+	}
+}
+
+// selectMerchants 按ID选出参与模拟的商户，ids 为空时为全部商户
+func selectMerchants(all []models.Merchant, ids []int) ([]models.Merchant, error) {
+	if len(ids) == 0 {
+		return all, nil
+	}
+	byID := make(map[int]models.Merchant, len(all))
+	for _, m := range all {
+		byID[m.ID] = m
+	}
+	selected := make([]models.Merchant, 0, len(ids))
+	for _, id := range ids {
+		m, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("商户 %d 不存在", id)
+		}
+		selected = append(selected, m)
+	}
+	return selected, nil
+}
+
+// simulateSink 模拟订单的写入方式
+type simulateSink interface {
+	// write 写入一批订单，返回成功的笔数；部分失败时返回第一个错误
+	write(ctx context.Context, orders []models.Order) (int, error)
+}
+
+// simulateDBSink 直接写入 dws_orders
+type simulateDBSink struct {
+	orders repository.SimulationRepository
+}
+
+func (s *simulateDBSink) write(ctx context.Context, orders []models.Order) (int, error) {
+	written := 0
+	for start := 0; start < len(orders); start += simulateDBBatch {
+		batch := orders[start:min(start+simulateDBBatch, len(orders))]
+		if err := s.orders.InsertOrders(ctx, batch); err != nil {
+			return written, err
+		}
+		written += len(batch)
+	}
+	return written, nil
+}
+
+// simulateAPISink 以 Shopify 的身份并发投递订单 Webhook，X-Tenant-ID 为商户ID，请求计入各商户的 SLA 统计
+type simulateAPISink struct {
+	client      *client.Client
+	secret      string
+	concurrency int
+	locations   map[int]*time.Location
+}
+
+func (s *simulateAPISink) write(ctx context.Context, orders []models.Order) (int, error) {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		written  int
+		firstErr error
+	)
+	sem := make(chan struct{}, s.concurrency)
+	for _, order := range orders {
+		header, payload, err := services.ShopifyWebhook(order, s.locations[order.MerchantID], order.OrderNumber, s.secret)
+		if err != nil {
+			wg.Wait()
+			return written, err
+		}
+		header.Set("X-Tenant-ID", fmt.Sprint(order.MerchantID))
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return written, ctx.Err()
+		}
+		wg.Add(1)
+		go func(merchantID int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			_, err := s.client.IngestWebhook(ctx, simulateProvider, merchantID, header, payload)
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				written++
+			} else if firstErr == nil {
+				firstErr = err
+			}
+		}(order.MerchantID)
+	}
+	wg.Wait()
+	return written, firstErr
+}
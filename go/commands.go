@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/secrets"
+	"timezone-saas-demo/services"
+)
+
+// command 子命令定义
+type command struct {
+	Name  string
+	Usage string
+	Run   func(config *AppConfig, args []string) error
+}
+
+// commands 所有可用子命令
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{Name: "serve", Usage: "启动 API 服务（默认）", Run: runServe},
+		{Name: "migrate", Usage: "执行未应用的数据库迁移脚本", Run: runMigrate},
+		{Name: "seed", Usage: "导入示例数据（会清空现有商户和订单）", Run: runSeed},
+		{Name: "healthcheck", Usage: "检查服务和数据库是否就绪，失败时返回非零退出码", Run: runHealthcheck},
+		{Name: "export", Usage: "导出订单数据为 CSV 或 NDJSON", Run: runExport},
+		{Name: "backfill", Usage: "规则变更后按商户重新镜像订单，修正 ClickHouse 中的本地时间字段", Run: runBackfill},
+		{Name: "reconcile-events", Usage: "核对支付平台事件的日期与订单本地日期，按时区报告不一致", Run: runReconcileEvents},
+		{Name: "simulate", Usage: "按各商户时区的昼夜曲线持续生成模拟订单，经 Webhook 接收接口或直接写库，用于压力测试", Run: runSimulate},
+		{Name: "bench-analysis", Usage: "对比分析接口 fanout/single 两种查询方式的耗时", Run: runBenchAnalysis},
+		{Name: "bench-orders", Usage: "对比订单日期和星期在 Go、SQL 中格式化或不返回时流式读取订单的耗时", Run: runBenchOrders},
+		{Name: "bench-json", Usage: "对比 encoding/json 与 fastjson 编码订单列表和分析结果的耗时", Run: runBenchJSON},
+		{Name: "help", Usage: "显示帮助信息", Run: runHelp},
+	}
+}
+
+// findCommand 按名称查找子命令
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.Name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// printUsage 打印命令列表
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "用法: main <命令> [参数]")
+	fmt.Fprintln(os.Stderr, "\n可用命令:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-15s %s\n", cmd.Name, cmd.Usage)
+	}
+	fmt.Fprintln(os.Stderr, "\n使用 main <命令> -h 查看命令参数")
+}
+
+// runHelp 显示帮助信息
+func runHelp(config *AppConfig, args []string) error {
+	printUsage()
+	return nil
+}
+
+// newFlagSet 创建子命令参数解析器
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: main %s [参数]\n", name)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// openServices 打开数据库连接并初始化服务层，供各子命令复用
+func openServices(config *AppConfig) (*database.DB, *services.TimezoneService, error) {
+	conn, err := openDatabase(config, database.RetryPolicyFromEnv())
+	if err != nil {
+		return nil, nil, fmt.Errorf("数据库连接失败: %w", err)
+	}
+	return conn, services.NewTimezoneService(conn), nil
+}
+
+// openDatabase 打开数据库连接，DB_USER、DB_PASSWORD 优先从密钥来源读取
+// 数据库暂不可用时按 policy 重试，每次重试记录到启动状态供就绪探针展示
+func openDatabase(config *AppConfig, policy database.RetryPolicy) (*database.DB, error) {
+	dbConfig := database.ConfigFromEnv()
+	var err error
+	if dbConfig.User, err = secrets.Resolve(context.Background(), config.Secrets, "DB_USER", dbConfig.User); err != nil {
+		return nil, err
+	}
+	if dbConfig.Password, err = secrets.Resolve(context.Background(), config.Secrets, "DB_PASSWORD", dbConfig.Password); err != nil {
+		return nil, err
+	}
+	return database.ConnectWithRetry(context.Background(), dbConfig, policy, startup.retrying)
+}
+
+// openClickHouse 打开 ClickHouse 连接，CLICKHOUSE_USER、CLICKHOUSE_PASSWORD 优先从密钥来源读取
+func openClickHouse(config *AppConfig) (*database.ClickHouse, error) {
+	chConfig := database.ClickHouseConfigFromEnv()
+	var err error
+	if chConfig.User, err = secrets.Resolve(context.Background(), config.Secrets, "CLICKHOUSE_USER", chConfig.User); err != nil {
+		return nil, err
+	}
+	if chConfig.Password, err = secrets.Resolve(context.Background(), config.Secrets, "CLICKHOUSE_PASSWORD", chConfig.Password); err != nil {
+		return nil, err
+	}
+	return database.NewClickHouseConnectionWithConfig(chConfig)
+}
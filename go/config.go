@@ -0,0 +1,629 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/export"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/redact"
+	"timezone-saas-demo/secrets"
+	"timezone-saas-demo/services"
+)
+
+// AppConfig 应用配置，所有子命令共用同一份环境变量加载逻辑
+// 数据库连接配置由 database 包自行读取（DB_* 环境变量）
+type AppConfig struct {
+	// Port HTTP 监听端口，为 off 时不监听 TCP 端口（只监听 UnixSocket）
+	Port string
+	// UnixSocket 额外监听的 Unix 域套接字路径，供同一 Pod 内的 sidecar 代理转发，为空时不监听
+	UnixSocket string
+	// UnixSocketMode 套接字文件的权限
+	UnixSocketMode os.FileMode
+	// TLSCertFile、TLSKeyFile TCP 端口使用的证书和私钥（PEM），设置后以 HTTPS 提供服务；文件更新后自动重新加载，不需要重启
+	TLSCertFile string
+	TLSKeyFile  string
+	// AutocertDomains 通过 ACME（Let's Encrypt）自动申请和续期证书的域名，与 TLSCertFile 不能同时设置
+	AutocertDomains []string
+	// AutocertCacheDir 自动申请的证书和 ACME 账户密钥的缓存目录
+	AutocertCacheDir string
+	// AutocertEmail ACME 账户的联系邮箱，可为空
+	AutocertEmail string
+	// HTTPRedirectPort 启用 HTTPS 时额外监听的明文端口：响应 ACME http-01 验证，其余请求重定向到 HTTPS，为空时不监听
+	HTTPRedirectPort string
+	// HTTP2 HTTPS 连接是否通过 ALPN 协商 HTTP/2
+	HTTP2 bool
+	// H2C 明文 TCP 端口和 Unix 套接字是否接受不加密的 HTTP/2（h2c），供 Envoy 等 sidecar 代理使用
+	H2C bool
+	// ShutdownTimeout 收到 SIGTERM 后停止接受新连接，等待进行中的请求完成的最长时间
+	ShutdownTimeout time.Duration
+	// TrustedProxies 可信的反向代理和负载均衡地址（CIDR 或 IP），只有来自这些地址的 X-Forwarded-For、X-Forwarded-Proto 才会被采用
+	TrustedProxies []netip.Prefix
+	// CSRF 浏览器端写请求的双提交 Cookie 防护（CSRF_*），默认关闭
+	CSRF csrfOptions
+	// RedactionPolicy 日志、请求录制和审计的字段脱敏策略，REDACTION_POLICY 覆盖默认策略中的同名字段
+	RedactionPolicy redact.Policy
+	// RedactionHashKey 脱敏方式为 hash 时的 HMAC 密钥，为空时摘要可以被穷举低熵的值
+	RedactionHashKey string
+	// RedactLogs 是否对全部日志输出按行脱敏
+	RedactLogs bool
+	// DemoPseudonymize 演示部署：API 响应中的商户名称、商户编码和订单号替换为确定性的假名，金额按固定系数缩放
+	DemoPseudonymize bool
+	// DemoPseudonymKey 生成假名和金额系数的密钥，为空时每次启动随机生成，假名在重启后会变化
+	DemoPseudonymKey string
+	// DemoAmountScale 金额的缩放系数，为 0 时由密钥确定一个 0.5~2 之间的系数
+	DemoAmountScale float64
+	// SQLDir 迁移和示例数据脚本目录
+	SQLDir string
+	// AnalyticsBackend 分析查询后端：postgres | clickhouse
+	AnalyticsBackend string
+	// ClickHouseMirrorInterval 订单镜像到 ClickHouse 的周期
+	ClickHouseMirrorInterval time.Duration
+	// DailyCloseInterval 日结检查周期，为 0 时不在 serve 中运行日结
+	DailyCloseInterval time.Duration
+	// AnalysisQueryTimeout 分析接口单项查询的超时时间，为 0 时不限制
+	AnalysisQueryTimeout time.Duration
+	// APIStatementTimeout API 请求中每条 SQL 语句的 statement_timeout，为 0 时使用数据库的默认值
+	APIStatementTimeout time.Duration
+	// ExportStatementTimeout /api/admin 下的导出、重建、回填等管理接口的 statement_timeout，为 0 时使用数据库的默认值
+	ExportStatementTimeout time.Duration
+	// JSONEncoder 响应的 JSON 编码方式：std 使用 encoding/json | fast 订单列表和分析结果使用 fastjson
+	JSONEncoder string
+	// OrderFormatting 订单列表 local_date、local_weekday 的默认格式化方式：go | sql | raw，请求可用 formatting 参数覆盖
+	OrderFormatting string
+	// AnalysisQueryMode 分析接口的查询方式：fanout | single
+	AnalysisQueryMode string
+	// AnalysisRollup 分析接口是否读取订单小时汇总（含已归档订单），false 时扫描分析视图
+	AnalysisRollup bool
+	// TenantQueryLimit 每个租户同时执行的分析请求数，为 0 时不限制
+	TenantQueryLimit int
+	// TenantQueueTimeout 租户名额已满时的最长排队时间，超时返回 503
+	TenantQueueTimeout time.Duration
+	// LoadShedInterval 过载保护采样连接池和排队指标的周期，为 0 时不启用过载保护
+	LoadShedInterval time.Duration
+	// LoadShed 过载保护的阈值和接口优先级
+	LoadShed services.LoadShedPolicy
+	// SlowQueryThreshold 超过该耗时的语句记为慢查询，为 0 时不记录
+	SlowQueryThreshold time.Duration
+	// SlowQueryExplainRate 对只读慢查询采集 EXPLAIN (ANALYZE, BUFFERS) 的比例，0~1
+	SlowQueryExplainRate float64
+	// PlanRegressionInterval 保存语句指纹耗时统计、检查部署版本和 ANALYZE 的周期，为 0 时不统计
+	PlanRegressionInterval time.Duration
+	// PlanRegressionThreshold 语句耗时中位数比上一个统计周期变慢超过该比例时记为回归
+	PlanRegressionThreshold float64
+	// DeployVersion 部署版本，变化时开始新的统计周期；为空时每次启动视为一次部署
+	DeployVersion string
+	// CircuitBreakerThreshold 数据库连续连接失败多少次后熔断，为 0 时不熔断
+	CircuitBreakerThreshold int
+	// CircuitBreakerOpenTimeout 熔断后多久放行一个探测请求
+	CircuitBreakerOpenTimeout time.Duration
+	// AdminToken /api/admin 接口的 Bearer 令牌，为空时管理接口不可用
+	AdminToken string
+	// DebugEndpoints 是否在 /debug 下挂载 pprof 和 expvar（需要管理令牌），用于在线采集 CPU 和内存剖析
+	DebugEndpoints bool
+	// FaultInjection 是否允许通过 /api/admin/faults 配置故障注入，只应在预发环境开启
+	FaultInjection bool
+	// CaptureCapacity 请求录制缓冲区的条数，CaptureMaxBody 每条记录的请求体和响应体各自保留的字节数
+	CaptureCapacity int
+	CaptureMaxBody  int
+	// ResponseCacheTTL 分析和时区对比接口的响应缓存时间，为 0 时不缓存
+	ResponseCacheTTL time.Duration
+	// ResponseCacheStale 缓存过期后仍返回旧响应并在后台刷新的时间
+	ResponseCacheStale time.Duration
+	// ResponseCacheEntries 缓存的响应条数上限
+	ResponseCacheEntries int
+	// ConsistencyCheckInterval 订单表与分析视图一致性检查的周期，为 0 时不在 serve 中定期检查
+	ConsistencyCheckInterval time.Duration
+	// ConsistencySampleSize 每次一致性检查抽样重新计算派生字段的订单数
+	ConsistencySampleSize int
+	// RetentionInterval 按保留策略归档旧订单的周期，为 0 时不在 serve 中定期归档
+	RetentionInterval time.Duration
+	// ReportScheduleInterval 检查到期定时报表的周期，为 0 时不在 serve 中执行定时报表
+	ReportScheduleInterval time.Duration
+	// AlertEvaluationInterval 检查指标告警规则的周期，为 0 时不在 serve 中检查
+	AlertEvaluationInterval time.Duration
+	// TrialExpiryInterval 检查租户试用到期的周期，为 0 时不在 serve 中检查
+	TrialExpiryInterval time.Duration
+	// JobPollInterval 后台任务工作协程检查新任务和到期定时计划的周期，为 0 时不在 serve 中执行后台任务
+	JobPollInterval time.Duration
+	// LeaderElection 多实例部署时是否选出一个主实例执行单实例的后台任务，false 时每个实例都执行
+	LeaderElection bool
+	// LeaderLeaseInterval 主实例续约、其他实例尝试接替的周期
+	LeaderLeaseInterval time.Duration
+	// JobConcurrency 各队列的工作协程数（JOB_CONCURRENCY，如 reports=2,maintenance=1），未配置的队列为 1
+	JobConcurrency map[string]int
+	// ChangesPollInterval /api/changes 等待新变更时重新查询的间隔
+	ChangesPollInterval time.Duration
+	// RetentionArchiveDir 归档文件（target = object）的目录，为空时只能归档到冷表
+	RetentionArchiveDir string
+	// RetentionArchiveFormat 归档文件的格式
+	RetentionArchiveFormat export.Format
+	// AdminQueryTimeout SQL 控制台单条查询的语句超时
+	AdminQueryTimeout time.Duration
+	// AdminQueryMaxRows SQL 控制台单条查询最多返回的行数
+	AdminQueryMaxRows int
+	// AdminQueryRole SQL 控制台执行查询时切换到的数据库角色
+	AdminQueryRole string
+	// ClockCheckInterval 检查时钟偏差的周期，为 0 时只在启动时检查
+	ClockCheckInterval time.Duration
+	// ClockSkewThreshold 时钟偏差超过该值时记录警告并告警
+	ClockSkewThreshold time.Duration
+	// SLAWindow 按租户统计响应时间分位数和错误率的滚动窗口
+	SLAWindow time.Duration
+	// SLABudget 响应时间和 5xx 错误率的预算，超出时告警
+	SLABudget models.SLABudget
+	// SLACheckInterval 检查 SLA 预算的周期，为 0 时不检查（仍然统计）
+	SLACheckInterval time.Duration
+	// CanaryInterval 以合成租户检查商户、订单、分析读取路径的周期，为 0 时不运行合成监控
+	CanaryInterval time.Duration
+	// NTPServer 作为参照时钟的 NTP 服务器，为空时只与数据库比较
+	NTPServer string
+	// AlertWebhookURL 一致性检查等任务的告警 Webhook 地址，为空时只记录日志
+	AlertWebhookURL string
+	// SMTPAddr 发送告警、报表和入驻邮件的 SMTP 服务器（host:port），为空时不发送邮件
+	SMTPAddr string
+	// SMTPFrom 邮件发件人地址
+	SMTPFrom string
+	// SMTPUsername、SMTPPassword SMTP 认证凭证，用户名为空时不认证
+	SMTPUsername string
+	SMTPPassword string
+	// WebhookSecrets 外部平台 Webhook 的签名密钥（WEBHOOK_SECRET_<平台>），键为平台名称，为空的平台不接收 Webhook
+	WebhookSecrets map[string]string
+	// PublicBaseURL 服务对外的地址，用于邮件中的报表下载链接，为空时链接为相对路径
+	PublicBaseURL string
+	// TZDataDir 外部 zoneinfo 目录，为空时使用系统或 Go 自带的 tzdata
+	TZDataDir string
+	// TZDataManifest 最新 tzdata 版本清单（文件路径或 URL），为空时使用内置清单
+	TZDataManifest string
+	// MessagesDir 额外的 API 消息翻译目录（<语言>.json），为空时只用内置消息
+	MessagesDir string
+	// Revenue 分析接口的营收口径
+	Revenue models.RevenueDefinition
+	// Secrets 密钥来源（SECRETS_PROVIDER），数据库和 ClickHouse 凭证、ADMIN_TOKEN、ALERT_WEBHOOK_URL、SMTP_PASSWORD、WEBHOOK_SECRET_* 优先从这里读取
+	Secrets secrets.Provider
+	// SecretsRotationInterval 重新读取密钥的周期，为 0 时不轮换
+	SecretsRotationInterval time.Duration
+	// ServeBeforeDBReady 为 true 时 serve 先开始监听再在后台连接数据库，连接成功前只有存活探针返回 200
+	ServeBeforeDBReady bool
+}
+
+// loadConfig 从环境变量加载应用配置
+func loadConfig() (*AppConfig, error) {
+	config := &AppConfig{
+		Port:              getEnv("PORT", "8080"),
+		SQLDir:            getEnv("SQL_DIR", defaultSQLDir()),
+		AnalyticsBackend:  getEnv("ANALYTICS_BACKEND", "postgres"),
+		MessagesDir:       getEnv("MESSAGES_DIR", ""),
+		AnalysisQueryMode: getEnv("ANALYSIS_QUERY_MODE", "fanout"),
+		JSONEncoder:       getEnv("JSON_ENCODER", jsonEncoderStd),
+		OrderFormatting:   getEnv("ORDER_FORMATTING", models.OrderFormattingGo),
+		AdminToken:        getEnv("ADMIN_TOKEN", ""),
+		AlertWebhookURL:   getEnv("ALERT_WEBHOOK_URL", ""),
+		SMTPAddr:          getEnv("SMTP_ADDR", ""),
+		SMTPFrom:          getEnv("SMTP_FROM", ""),
+		SMTPUsername:      getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:      getEnv("SMTP_PASSWORD", ""),
+		PublicBaseURL:     getEnv("PUBLIC_BASE_URL", ""),
+		TZDataDir:         getEnv("TZDATA_DIR", ""),
+		TZDataManifest:    getEnv("TZDATA_MANIFEST", ""),
+	}
+
+	var err error
+	config.ClickHouseMirrorInterval, err = time.ParseDuration(getEnv("CLICKHOUSE_MIRROR_INTERVAL", "30s"))
+	if err != nil {
+		return nil, fmt.Errorf("CLICKHOUSE_MIRROR_INTERVAL 格式错误: %w", err)
+	}
+	config.DailyCloseInterval, err = time.ParseDuration(getEnv("DAILY_CLOSE_INTERVAL", "1m"))
+	if err != nil {
+		return nil, fmt.Errorf("DAILY_CLOSE_INTERVAL 格式错误: %w", err)
+	}
+	config.AnalysisQueryTimeout, err = time.ParseDuration(getEnv("ANALYSIS_QUERY_TIMEOUT", "10s"))
+	if err != nil {
+		return nil, fmt.Errorf("ANALYSIS_QUERY_TIMEOUT 格式错误: %w", err)
+	}
+	config.APIStatementTimeout, err = time.ParseDuration(getEnv("API_STATEMENT_TIMEOUT", "30s"))
+	if err != nil {
+		return nil, fmt.Errorf("API_STATEMENT_TIMEOUT 格式错误: %w", err)
+	}
+	config.ExportStatementTimeout, err = time.ParseDuration(getEnv("EXPORT_STATEMENT_TIMEOUT", "10m"))
+	if err != nil {
+		return nil, fmt.Errorf("EXPORT_STATEMENT_TIMEOUT 格式错误: %w", err)
+	}
+	config.AnalysisRollup, err = strconv.ParseBool(getEnv("ANALYSIS_ROLLUP", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("ANALYSIS_ROLLUP 格式错误: %w", err)
+	}
+	config.TenantQueryLimit, err = strconv.Atoi(getEnv("TENANT_QUERY_LIMIT", strconv.Itoa(services.DefaultTenantQueryLimit)))
+	if err != nil || config.TenantQueryLimit < 0 {
+		return nil, fmt.Errorf("TENANT_QUERY_LIMIT 必须是非负整数: %q", os.Getenv("TENANT_QUERY_LIMIT"))
+	}
+	config.TenantQueueTimeout, err = time.ParseDuration(getEnv("TENANT_QUEUE_TIMEOUT", services.DefaultTenantQueueTimeout.String()))
+	if err != nil {
+		return nil, fmt.Errorf("TENANT_QUEUE_TIMEOUT 格式错误: %w", err)
+	}
+	config.LoadShedInterval, err = time.ParseDuration(getEnv("LOAD_SHED_INTERVAL", services.DefaultLoadShedInterval.String()))
+	if err != nil || config.LoadShedInterval < 0 {
+		return nil, fmt.Errorf("LOAD_SHED_INTERVAL 必须是非负的时长: %q", os.Getenv("LOAD_SHED_INTERVAL"))
+	}
+	config.LoadShed.PoolWaitsPerSecond, err = strconv.ParseFloat(getEnv("LOAD_SHED_POOL_WAITS", strconv.FormatFloat(services.DefaultLoadShedPoolWaits, 'f', -1, 64)), 64)
+	if err != nil || config.LoadShed.PoolWaitsPerSecond < 0 {
+		return nil, fmt.Errorf("LOAD_SHED_POOL_WAITS 必须是非负数: %q", os.Getenv("LOAD_SHED_POOL_WAITS"))
+	}
+	config.LoadShed.QueueDepth, err = strconv.Atoi(getEnv("LOAD_SHED_QUEUE_DEPTH", strconv.Itoa(services.DefaultLoadShedQueueDepth)))
+	if err != nil || config.LoadShed.QueueDepth < 0 {
+		return nil, fmt.Errorf("LOAD_SHED_QUEUE_DEPTH 必须是非负整数: %q", os.Getenv("LOAD_SHED_QUEUE_DEPTH"))
+	}
+	config.LoadShed.CriticalFactor, err = strconv.ParseFloat(getEnv("LOAD_SHED_CRITICAL_FACTOR", strconv.FormatFloat(services.DefaultLoadShedCriticalFactor, 'f', -1, 64)), 64)
+	if err != nil || (config.LoadShed.CriticalFactor != 0 && config.LoadShed.CriticalFactor <= 1) {
+		return nil, fmt.Errorf("LOAD_SHED_CRITICAL_FACTOR 必须大于 1（0 表示只拒绝低优先级请求）: %q", os.Getenv("LOAD_SHED_CRITICAL_FACTOR"))
+	}
+	config.LoadShed.Cooldown, err = time.ParseDuration(getEnv("LOAD_SHED_COOLDOWN", services.DefaultLoadShedCooldown.String()))
+	if err != nil || config.LoadShed.Cooldown <= 0 {
+		return nil, fmt.Errorf("LOAD_SHED_COOLDOWN 必须是正的时长: %q", os.Getenv("LOAD_SHED_COOLDOWN"))
+	}
+	// 显式设置为空表示没有该优先级的接口，因此不使用 getEnv
+	lowPriority, ok := os.LookupEnv("LOAD_SHED_LOW_PRIORITY")
+	if !ok {
+		lowPriority = services.DefaultLoadShedLowPriority
+	}
+	if config.LoadShed.LowPriority, err = services.ParseLoadShedPaths(lowPriority); err != nil {
+		return nil, fmt.Errorf("LOAD_SHED_LOW_PRIORITY 配置错误: %w", err)
+	}
+	protected, ok := os.LookupEnv("LOAD_SHED_PROTECTED")
+	if !ok {
+		protected = services.DefaultLoadShedProtected
+	}
+	if config.LoadShed.Protected, err = services.ParseLoadShedPaths(protected); err != nil {
+		return nil, fmt.Errorf("LOAD_SHED_PROTECTED 配置错误: %w", err)
+	}
+	config.SlowQueryThreshold, err = time.ParseDuration(getEnv("SLOW_QUERY_THRESHOLD", database.DefaultSlowQueryThreshold.String()))
+	if err != nil {
+		return nil, fmt.Errorf("SLOW_QUERY_THRESHOLD 格式错误: %w", err)
+	}
+	config.SlowQueryExplainRate, err = strconv.ParseFloat(getEnv("SLOW_QUERY_EXPLAIN_RATE", "0"), 64)
+	if err != nil || config.SlowQueryExplainRate < 0 || config.SlowQueryExplainRate > 1 {
+		return nil, fmt.Errorf("SLOW_QUERY_EXPLAIN_RATE 必须是 0~1 之间的小数: %q", os.Getenv("SLOW_QUERY_EXPLAIN_RATE"))
+	}
+	config.PlanRegressionInterval, err = time.ParseDuration(getEnv("PLAN_REGRESSION_INTERVAL", services.DefaultPlanRegressionInterval.String()))
+	if err != nil {
+		return nil, fmt.Errorf("PLAN_REGRESSION_INTERVAL 格式错误: %w", err)
+	}
+	config.PlanRegressionThreshold, err = strconv.ParseFloat(getEnv("PLAN_REGRESSION_THRESHOLD", strconv.FormatFloat(services.DefaultPlanRegressionThreshold, 'f', -1, 64)), 64)
+	if err != nil || config.PlanRegressionThreshold <= 0 {
+		return nil, fmt.Errorf("PLAN_REGRESSION_THRESHOLD 必须是大于 0 的小数: %q", os.Getenv("PLAN_REGRESSION_THRESHOLD"))
+	}
+	config.DeployVersion = getEnv("DEPLOY_VERSION", "")
+	config.CircuitBreakerThreshold, err = strconv.Atoi(getEnv("CIRCUIT_BREAKER_THRESHOLD", strconv.Itoa(database.DefaultBreakerThreshold)))
+	if err != nil || config.CircuitBreakerThreshold < 0 {
+		return nil, fmt.Errorf("CIRCUIT_BREAKER_THRESHOLD 必须是非负整数: %q", os.Getenv("CIRCUIT_BREAKER_THRESHOLD"))
+	}
+	config.CircuitBreakerOpenTimeout, err = time.ParseDuration(getEnv("CIRCUIT_BREAKER_OPEN_TIMEOUT", database.DefaultBreakerOpenTimeout.String()))
+	if err != nil || config.CircuitBreakerOpenTimeout <= 0 {
+		return nil, fmt.Errorf("CIRCUIT_BREAKER_OPEN_TIMEOUT 必须是正的时长: %q", os.Getenv("CIRCUIT_BREAKER_OPEN_TIMEOUT"))
+	}
+
+	config.FaultInjection, err = strconv.ParseBool(getEnv("FAULT_INJECTION", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("FAULT_INJECTION 格式错误: %w", err)
+	}
+	config.DebugEndpoints, err = strconv.ParseBool(getEnv("DEBUG_ENDPOINTS", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("DEBUG_ENDPOINTS 格式错误: %w", err)
+	}
+
+	config.CaptureCapacity, err = strconv.Atoi(getEnv("CAPTURE_CAPACITY", strconv.Itoa(services.DefaultCaptureCapacity)))
+	if err != nil || config.CaptureCapacity <= 0 {
+		return nil, fmt.Errorf("CAPTURE_CAPACITY 必须是正整数: %q", os.Getenv("CAPTURE_CAPACITY"))
+	}
+	config.CaptureMaxBody, err = strconv.Atoi(getEnv("CAPTURE_MAX_BODY", strconv.Itoa(services.DefaultCaptureMaxBody)))
+	if err != nil || config.CaptureMaxBody <= 0 {
+		return nil, fmt.Errorf("CAPTURE_MAX_BODY 必须是正整数: %q", os.Getenv("CAPTURE_MAX_BODY"))
+	}
+
+	config.ResponseCacheTTL, err = time.ParseDuration(getEnv("RESPONSE_CACHE_TTL", services.DefaultResponseCacheTTL.String()))
+	if err != nil {
+		return nil, fmt.Errorf("RESPONSE_CACHE_TTL 格式错误: %w", err)
+	}
+	config.ResponseCacheStale, err = time.ParseDuration(getEnv("RESPONSE_CACHE_STALE", services.DefaultResponseCacheStale.String()))
+	if err != nil {
+		return nil, fmt.Errorf("RESPONSE_CACHE_STALE 格式错误: %w", err)
+	}
+	config.ResponseCacheEntries, err = strconv.Atoi(getEnv("RESPONSE_CACHE_ENTRIES", strconv.Itoa(services.DefaultResponseCacheEntries)))
+	if err != nil || config.ResponseCacheEntries <= 0 {
+		return nil, fmt.Errorf("RESPONSE_CACHE_ENTRIES 必须是正整数: %q", os.Getenv("RESPONSE_CACHE_ENTRIES"))
+	}
+
+	if err := loadListenConfig(config); err != nil {
+		return nil, err
+	}
+	if err := loadCSRFConfig(config); err != nil {
+		return nil, err
+	}
+
+	config.ServeBeforeDBReady, err = strconv.ParseBool(getEnv("SERVE_BEFORE_DB_READY", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("SERVE_BEFORE_DB_READY 格式错误: %w", err)
+	}
+	config.SecretsRotationInterval, err = time.ParseDuration(getEnv("SECRETS_ROTATION_INTERVAL", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("SECRETS_ROTATION_INTERVAL 格式错误: %w", err)
+	}
+	config.Secrets, err = secrets.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if config.AdminToken, err = secrets.Resolve(context.Background(), config.Secrets, "ADMIN_TOKEN", config.AdminToken); err != nil {
+		return nil, err
+	}
+	if config.AlertWebhookURL, err = secrets.Resolve(context.Background(), config.Secrets, "ALERT_WEBHOOK_URL", config.AlertWebhookURL); err != nil {
+		return nil, err
+	}
+	if config.SMTPPassword, err = secrets.Resolve(context.Background(), config.Secrets, "SMTP_PASSWORD", config.SMTPPassword); err != nil {
+		return nil, err
+	}
+	if config.RedactionPolicy, err = redact.ParsePolicy(getEnv("REDACTION_POLICY", ""), redact.DefaultPolicy()); err != nil {
+		return nil, fmt.Errorf("REDACTION_POLICY 格式错误: %w", err)
+	}
+	if config.RedactionHashKey, err = secrets.Resolve(context.Background(), config.Secrets, "REDACTION_HASH_KEY", getEnv("REDACTION_HASH_KEY", "")); err != nil {
+		return nil, err
+	}
+	if config.RedactLogs, err = strconv.ParseBool(getEnv("REDACT_LOGS", "true")); err != nil {
+		return nil, fmt.Errorf("REDACT_LOGS 格式错误: %w", err)
+	}
+	if config.DemoPseudonymize, err = strconv.ParseBool(getEnv("DEMO_PSEUDONYMIZE", "false")); err != nil {
+		return nil, fmt.Errorf("DEMO_PSEUDONYMIZE 格式错误: %w", err)
+	}
+	if config.DemoPseudonymKey, err = secrets.Resolve(context.Background(), config.Secrets, "DEMO_PSEUDONYM_KEY", getEnv("DEMO_PSEUDONYM_KEY", "")); err != nil {
+		return nil, err
+	}
+	config.DemoAmountScale, err = strconv.ParseFloat(getEnv("DEMO_AMOUNT_SCALE", "0"), 64)
+	if err != nil || config.DemoAmountScale < 0 {
+		return nil, fmt.Errorf("DEMO_AMOUNT_SCALE 必须是非负数: %q", os.Getenv("DEMO_AMOUNT_SCALE"))
+	}
+	config.WebhookSecrets = map[string]string{}
+	for _, provider := range services.IngestProviders() {
+		key := webhookSecretKey(provider)
+		if config.WebhookSecrets[provider], err = secrets.Resolve(context.Background(), config.Secrets, key, getEnv(key, "")); err != nil {
+			return nil, err
+		}
+	}
+
+	config.ConsistencyCheckInterval, err = time.ParseDuration(getEnv("CONSISTENCY_CHECK_INTERVAL", "1h"))
+	if err != nil {
+		return nil, fmt.Errorf("CONSISTENCY_CHECK_INTERVAL 格式错误: %w", err)
+	}
+	config.ConsistencySampleSize, err = strconv.Atoi(getEnv("CONSISTENCY_SAMPLE_SIZE", strconv.Itoa(services.DefaultConsistencySampleSize)))
+	if err != nil || config.ConsistencySampleSize < 0 {
+		return nil, fmt.Errorf("CONSISTENCY_SAMPLE_SIZE 必须是非负整数: %q", os.Getenv("CONSISTENCY_SAMPLE_SIZE"))
+	}
+
+	config.RetentionInterval, err = time.ParseDuration(getEnv("RETENTION_INTERVAL", "24h"))
+	if err != nil {
+		return nil, fmt.Errorf("RETENTION_INTERVAL 格式错误: %w", err)
+	}
+	config.RetentionArchiveDir = getEnv("RETENTION_ARCHIVE_DIR", "")
+	config.ReportScheduleInterval, err = time.ParseDuration(getEnv("REPORT_SCHEDULE_INTERVAL", "1m"))
+	if err != nil {
+		return nil, fmt.Errorf("REPORT_SCHEDULE_INTERVAL 格式错误: %w", err)
+	}
+	config.AlertEvaluationInterval, err = time.ParseDuration(getEnv("ALERT_EVALUATION_INTERVAL", "5m"))
+	if err != nil {
+		return nil, fmt.Errorf("ALERT_EVALUATION_INTERVAL 格式错误: %w", err)
+	}
+	config.TrialExpiryInterval, err = time.ParseDuration(getEnv("TRIAL_EXPIRY_INTERVAL", services.DefaultTrialExpiryInterval.String()))
+	if err != nil {
+		return nil, fmt.Errorf("TRIAL_EXPIRY_INTERVAL 格式错误: %w", err)
+	}
+	config.JobPollInterval, err = time.ParseDuration(getEnv("JOB_POLL_INTERVAL", services.DefaultJobPollInterval.String()))
+	if err != nil {
+		return nil, fmt.Errorf("JOB_POLL_INTERVAL 格式错误: %w", err)
+	}
+	config.LeaderElection, err = strconv.ParseBool(getEnv("LEADER_ELECTION", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("LEADER_ELECTION 格式错误: %w", err)
+	}
+	config.LeaderLeaseInterval, err = time.ParseDuration(getEnv("LEADER_LEASE_INTERVAL", services.DefaultLeaderLeaseInterval.String()))
+	if err != nil || config.LeaderLeaseInterval <= 0 {
+		return nil, fmt.Errorf("LEADER_LEASE_INTERVAL 必须是正的时长: %q", os.Getenv("LEADER_LEASE_INTERVAL"))
+	}
+	config.JobConcurrency = map[string]int{}
+	for _, part := range strings.Split(getEnv("JOB_CONCURRENCY", ""), ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		queue, value, _ := strings.Cut(part, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if strings.TrimSpace(queue) == "" || err != nil || n <= 0 {
+			return nil, fmt.Errorf("JOB_CONCURRENCY 格式错误，应为 队列=协程数: %q", part)
+		}
+		config.JobConcurrency[strings.TrimSpace(queue)] = n
+	}
+	config.ChangesPollInterval, err = time.ParseDuration(getEnv("CHANGES_POLL_INTERVAL", services.DefaultChangePollInterval.String()))
+	if err != nil || config.ChangesPollInterval <= 0 {
+		return nil, fmt.Errorf("CHANGES_POLL_INTERVAL 必须是正的时长: %q", os.Getenv("CHANGES_POLL_INTERVAL"))
+	}
+	config.RetentionArchiveFormat, err = export.ParseFormat(getEnv("RETENTION_ARCHIVE_FORMAT", string(export.FormatNDJSON)))
+	if err != nil {
+		return nil, fmt.Errorf("RETENTION_ARCHIVE_FORMAT 格式错误: %w", err)
+	}
+
+	config.AdminQueryTimeout, err = time.ParseDuration(getEnv("ADMIN_QUERY_TIMEOUT", services.DefaultConsoleTimeout.String()))
+	if err != nil || config.AdminQueryTimeout <= 0 {
+		return nil, fmt.Errorf("ADMIN_QUERY_TIMEOUT 必须是正的时长: %q", os.Getenv("ADMIN_QUERY_TIMEOUT"))
+	}
+	config.AdminQueryMaxRows, err = strconv.Atoi(getEnv("ADMIN_QUERY_MAX_ROWS", strconv.Itoa(services.DefaultConsoleMaxRows)))
+	if err != nil || config.AdminQueryMaxRows <= 0 {
+		return nil, fmt.Errorf("ADMIN_QUERY_MAX_ROWS 必须是正整数: %q", os.Getenv("ADMIN_QUERY_MAX_ROWS"))
+	}
+	config.AdminQueryRole = getEnv("ADMIN_QUERY_ROLE", services.DefaultConsoleRole)
+
+	config.ClockCheckInterval, err = time.ParseDuration(getEnv("CLOCK_CHECK_INTERVAL", "5m"))
+	if err != nil {
+		return nil, fmt.Errorf("CLOCK_CHECK_INTERVAL 格式错误: %w", err)
+	}
+	config.ClockSkewThreshold, err = time.ParseDuration(getEnv("CLOCK_SKEW_THRESHOLD", services.DefaultClockSkewThreshold.String()))
+	if err != nil || config.ClockSkewThreshold <= 0 {
+		return nil, fmt.Errorf("CLOCK_SKEW_THRESHOLD 必须是正的时长: %q", os.Getenv("CLOCK_SKEW_THRESHOLD"))
+	}
+	config.NTPServer = getEnv("NTP_SERVER", "")
+
+	config.SLAWindow, err = time.ParseDuration(getEnv("SLA_WINDOW", services.DefaultSLAWindow.String()))
+	if err != nil || config.SLAWindow <= 0 {
+		return nil, fmt.Errorf("SLA_WINDOW 必须是正的时长: %q", os.Getenv("SLA_WINDOW"))
+	}
+	p95, err := time.ParseDuration(getEnv("SLA_P95_BUDGET", services.DefaultSLAP95Budget.String()))
+	if err != nil || p95 < 0 {
+		return nil, fmt.Errorf("SLA_P95_BUDGET 必须是非负的时长: %q", os.Getenv("SLA_P95_BUDGET"))
+	}
+	p99, err := time.ParseDuration(getEnv("SLA_P99_BUDGET", services.DefaultSLAP99Budget.String()))
+	if err != nil || p99 < 0 {
+		return nil, fmt.Errorf("SLA_P99_BUDGET 必须是非负的时长: %q", os.Getenv("SLA_P99_BUDGET"))
+	}
+	config.SLABudget = models.SLABudget{P95Ms: p95.Milliseconds(), P99Ms: p99.Milliseconds()}
+	config.SLABudget.ErrorRate, err = strconv.ParseFloat(getEnv("SLA_ERROR_RATE_BUDGET", strconv.FormatFloat(services.DefaultSLAErrorRateBudget, 'f', -1, 64)), 64)
+	if err != nil || config.SLABudget.ErrorRate < 0 || config.SLABudget.ErrorRate > 1 {
+		return nil, fmt.Errorf("SLA_ERROR_RATE_BUDGET 必须是 0~1 之间的小数: %q", os.Getenv("SLA_ERROR_RATE_BUDGET"))
+	}
+	config.SLACheckInterval, err = time.ParseDuration(getEnv("SLA_CHECK_INTERVAL", "1m"))
+	if err != nil {
+		return nil, fmt.Errorf("SLA_CHECK_INTERVAL 格式错误: %w", err)
+	}
+	config.CanaryInterval, err = time.ParseDuration(getEnv("CANARY_INTERVAL", services.DefaultCanaryInterval.String()))
+	if err != nil {
+		return nil, fmt.Errorf("CANARY_INTERVAL 格式错误: %w", err)
+	}
+
+	// 显式设置为空表示统计全部状态，因此不使用 getEnv
+	excluded, ok := os.LookupEnv("REVENUE_EXCLUDED_STATUSES")
+	if !ok {
+		excluded = models.OrderStatusCancelled
+	}
+	config.Revenue.ExcludedStatuses, err = services.ParseStatuses(excluded)
+	if err != nil {
+		return nil, fmt.Errorf("REVENUE_EXCLUDED_STATUSES 格式错误: %w", err)
+	}
+	config.Revenue.SubtractRefunds, err = strconv.ParseBool(getEnv("REVENUE_SUBTRACT_REFUNDS", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("REVENUE_SUBTRACT_REFUNDS 格式错误: %w", err)
+	}
+	// 退款归属 order_date / refund_date，由 SetRevenueDefinition 校验
+	config.Revenue.RefundAttribution = getEnv("REFUND_ATTRIBUTION", models.RefundAttributionOrderDate)
+
+	switch config.AnalyticsBackend {
+	case "postgres", "clickhouse":
+	default:
+		return nil, fmt.Errorf("不支持的分析存储后端: %s", config.AnalyticsBackend)
+	}
+	switch config.JSONEncoder {
+	case jsonEncoderStd, jsonEncoderFast:
+	default:
+		return nil, fmt.Errorf("不支持的 JSON 编码方式: %s，可选 %s、%s", config.JSONEncoder, jsonEncoderStd, jsonEncoderFast)
+	}
+	if config.OrderFormatting, err = services.ParseOrderFormatting(config.OrderFormatting, models.OrderFormattingGo); err != nil {
+		return nil, fmt.Errorf("ORDER_FORMATTING 格式错误: %w", err)
+	}
+
+	return config, nil
+}
+
+// loadListenConfig 读取监听方式相关的配置：TLS 证书或自动证书、HTTP/2、Unix 套接字、优雅退出和可信代理
+func loadListenConfig(config *AppConfig) error {
+	config.UnixSocket = getEnv("UNIX_SOCKET", "")
+	mode, err := strconv.ParseUint(getEnv("UNIX_SOCKET_MODE", "0660"), 8, 32)
+	if err != nil {
+		return fmt.Errorf("UNIX_SOCKET_MODE 必须是八进制权限，如 0660: %q", os.Getenv("UNIX_SOCKET_MODE"))
+	}
+	config.UnixSocketMode = os.FileMode(mode)
+	if config.Port == portOff && config.UnixSocket == "" {
+		return fmt.Errorf("PORT=off 时必须设置 UNIX_SOCKET")
+	}
+
+	config.TLSCertFile = getEnv("TLS_CERT_FILE", "")
+	config.TLSKeyFile = getEnv("TLS_KEY_FILE", "")
+	if (config.TLSCertFile == "") != (config.TLSKeyFile == "") {
+		return fmt.Errorf("TLS_CERT_FILE 和 TLS_KEY_FILE 需要同时设置")
+	}
+	for _, domain := range strings.Split(getEnv("AUTOCERT_DOMAINS", ""), ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			config.AutocertDomains = append(config.AutocertDomains, domain)
+		}
+	}
+	if len(config.AutocertDomains) > 0 && config.TLSCertFile != "" {
+		return fmt.Errorf("AUTOCERT_DOMAINS 与 TLS_CERT_FILE 不能同时设置")
+	}
+	config.AutocertCacheDir = getEnv("AUTOCERT_CACHE_DIR", "autocert-cache")
+	config.AutocertEmail = getEnv("AUTOCERT_EMAIL", "")
+	config.HTTPRedirectPort = getEnv("HTTP_REDIRECT_PORT", "")
+	if config.HTTPRedirectPort != "" && !config.TLSEnabled() {
+		return fmt.Errorf("HTTP_REDIRECT_PORT 只在启用 HTTPS（TLS_CERT_FILE 或 AUTOCERT_DOMAINS）时使用")
+	}
+
+	if config.HTTP2, err = strconv.ParseBool(getEnv("HTTP2", "true")); err != nil {
+		return fmt.Errorf("HTTP2 格式错误: %w", err)
+	}
+	if config.H2C, err = strconv.ParseBool(getEnv("H2C", "false")); err != nil {
+		return fmt.Errorf("H2C 格式错误: %w", err)
+	}
+	if config.ShutdownTimeout, err = time.ParseDuration(getEnv("SHUTDOWN_TIMEOUT", "30s")); err != nil {
+		return fmt.Errorf("SHUTDOWN_TIMEOUT 格式错误: %w", err)
+	}
+	if config.TrustedProxies, err = parseTrustedProxies(getEnv("TRUSTED_PROXIES", "")); err != nil {
+		return fmt.Errorf("TRUSTED_PROXIES 格式错误: %w", err)
+	}
+	return nil
+}
+
+// loadCSRFConfig 读取 CSRF 防护和令牌 Cookie 的属性
+func loadCSRFConfig(config *AppConfig) error {
+	var err error
+	if config.CSRF.Enabled, err = strconv.ParseBool(getEnv("CSRF_PROTECTION", "false")); err != nil {
+		return fmt.Errorf("CSRF_PROTECTION 格式错误: %w", err)
+	}
+	config.CSRF.CookieName = getEnv("CSRF_COOKIE_NAME", "csrf_token")
+	config.CSRF.CookieDomain = getEnv("CSRF_COOKIE_DOMAIN", "")
+	config.CSRF.CookiePath = getEnv("CSRF_COOKIE_PATH", "/")
+	config.CSRF.CookieSecure = strings.ToLower(getEnv("CSRF_COOKIE_SECURE", "auto"))
+	switch config.CSRF.CookieSecure {
+	case "auto", "true", "false":
+	default:
+		return fmt.Errorf("CSRF_COOKIE_SECURE 可选 auto、true、false: %q", config.CSRF.CookieSecure)
+	}
+	if config.CSRF.CookieSameSite, err = parseSameSite(getEnv("CSRF_COOKIE_SAMESITE", "lax")); err != nil {
+		return fmt.Errorf("CSRF_COOKIE_SAMESITE 格式错误: %w", err)
+	}
+	if config.CSRF.CookieSameSite == http.SameSiteNoneMode && config.CSRF.CookieSecure == "false" {
+		return fmt.Errorf("CSRF_COOKIE_SAMESITE=none 时浏览器要求 Cookie 带 Secure，CSRF_COOKIE_SECURE 不能为 false")
+	}
+	if config.CSRF.CookieMaxAge, err = time.ParseDuration(getEnv("CSRF_COOKIE_MAX_AGE", "12h")); err != nil || config.CSRF.CookieMaxAge <= 0 {
+		return fmt.Errorf("CSRF_COOKIE_MAX_AGE 必须是正的时长: %q", os.Getenv("CSRF_COOKIE_MAX_AGE"))
+	}
+	return nil
+}
+
+// TLSEnabled TCP 端口是否以 HTTPS 提供服务
+func (c *AppConfig) TLSEnabled() bool {
+	return c.TLSCertFile != "" || len(c.AutocertDomains) > 0
+}
+
+// defaultSQLDir 默认脚本目录：容器内为 ./sql，本地在 go/ 目录下运行时为 ../sql
+func defaultSQLDir() string {
+	for _, dir := range []string{"sql", filepath.Join("..", "sql")} {
+		if _, err := os.Stat(filepath.Join(dir, "01_schema.sql")); err == nil {
+			return dir
+		}
+	}
+	return "sql"
+}
+
+// webhookSecretKey 平台签名密钥的环境变量和密钥名称，如 WEBHOOK_SECRET_SHOPIFY
+func webhookSecretKey(provider string) string {
+	return "WEBHOOK_SECRET_" + strings.ToUpper(provider)
+}
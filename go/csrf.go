@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// csrfHeader 浏览器端把 Cookie 中的令牌复制到该请求头，表单提交时也可以放在 csrfFormField 字段
+const (
+	csrfHeader    = "X-CSRF-Token"
+	csrfFormField = "csrf_token"
+)
+
+// csrfExemptPaths 不做 CSRF 校验的路径前缀：外部平台的 Webhook 由平台签名认证，不会携带 Cookie
+var csrfExemptPaths = []string{"/api/ingest/webhooks/"}
+
+// csrfOptions 双提交 Cookie 的 CSRF 防护配置
+type csrfOptions struct {
+	// Enabled 是否校验 POST、PUT、PATCH、DELETE 请求
+	Enabled bool
+	// CookieName、CookieDomain、CookiePath 令牌 Cookie 的名称和作用范围，Domain 为空时只对当前主机有效
+	CookieName   string
+	CookieDomain string
+	CookiePath   string
+	// CookieSecure auto 时按客户端协议（经可信代理还原）决定，true、false 强制设置
+	CookieSecure string
+	// CookieSameSite 令牌 Cookie 的 SameSite 属性
+	CookieSameSite http.SameSite
+	// CookieMaxAge 令牌 Cookie 的有效期，过期后下一个 GET 请求重新下发
+	CookieMaxAge time.Duration
+}
+
+// parseSameSite 解析 CSRF_COOKIE_SAMESITE：lax | strict | none
+func parseSameSite(value string) (http.SameSite, error) {
+	switch strings.ToLower(value) {
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	}
+	return 0, fmt.Errorf("可选 lax、strict、none: %q", value)
+}
+
+// csrfMiddleware 无会话的 CSRF 防护（双提交 Cookie）：
+// 安全方法的请求没有令牌 Cookie 时下发一个随机令牌（前端可读），写请求必须在 X-CSRF-Token 请求头或 csrf_token 表单字段中带上相同的值。
+// 其他站点的页面无法读取本站的 Cookie，也就无法构造出匹配的请求头。
+// Webhook 不校验；带 Authorization 或 X-API-Key 请求头的机器客户端只有在不带 Cookie 时才不校验，见 csrfExempt
+func csrfMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !csrf.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			if _, err := r.Cookie(csrf.CookieName); err != nil {
+				if err := setCSRFCookie(w, r); err != nil {
+					respondError(w, r, http.StatusInternalServerError, "csrf.invalid", err)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if csrfExempt(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err := verifyCSRF(r); err != nil {
+			respondError(w, r, http.StatusForbidden, "csrf.invalid", err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// csrfExempt 机器客户端和 Webhook 的写请求不需要 CSRF 令牌
+// 这里还没有校验 Authorization 或 X-API-Key 是否有效，只凭请求头存在就豁免时，带着 Cookie 的请求附加一个随意的请求头即可绕过；
+// 因此只豁免不带任何 Cookie 的请求，浏览器发出的请求会自动附带 Cookie，仍然需要令牌
+func csrfExempt(r *http.Request) bool {
+	for _, prefix := range csrfExemptPaths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	if r.Header.Get("Cookie") != "" {
+		return false
+	}
+	return r.Header.Get("Authorization") != "" || r.Header.Get("X-API-Key") != ""
+}
+
+// verifyCSRF 比较 Cookie 与请求头（或表单字段）中的令牌
+func verifyCSRF(r *http.Request) error {
+	cookie, err := r.Cookie(csrf.CookieName)
+	if err != nil || cookie.Value == "" {
+		return errors.New("缺少 CSRF 令牌 Cookie，请先通过 GET 请求获取")
+	}
+	token := r.Header.Get(csrfHeader)
+	if token == "" && strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		token = r.PostFormValue(csrfFormField)
+	}
+	if token == "" {
+		return fmt.Errorf("缺少 %s 请求头", csrfHeader)
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(cookie.Value)) != 1 {
+		return errors.New("CSRF 令牌与 Cookie 不一致")
+	}
+	return nil
+}
+
+// setCSRFCookie 下发新的随机令牌；Cookie 不设置 HttpOnly，前端脚本需要读取它
+func setCSRFCookie(w http.ResponseWriter, r *http.Request) error {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Errorf("生成 CSRF 令牌失败: %w", err)
+	}
+
+	secure := requestScheme(r) == "https"
+	switch csrf.CookieSecure {
+	case "true":
+		secure = true
+	case "false":
+		secure = false
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrf.CookieName,
+		Value:    base64.RawURLEncoding.EncodeToString(buf),
+		Domain:   csrf.CookieDomain,
+		Path:     csrf.CookiePath,
+		MaxAge:   int(csrf.CookieMaxAge.Seconds()),
+		Secure:   secure,
+		SameSite: csrf.CookieSameSite,
+	})
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCSRFMiddleware 写请求的 CSRF 校验：Authorization、X-API-Key 只在不带 Cookie 时豁免，
+// 带 Cookie 的请求附加一个无效的凭证请求头不能绕过校验
+func TestCSRFMiddleware(t *testing.T) {
+	prev := csrf
+	csrf = csrfOptions{
+		Enabled:        true,
+		CookieName:     "csrf_token",
+		CookiePath:     "/",
+		CookieSecure:   "auto",
+		CookieSameSite: http.SameSiteLaxMode,
+		CookieMaxAge:   12 * time.Hour,
+	}
+	t.Cleanup(func() { csrf = prev })
+	handler := csrfMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	const token = "csrf-token-value"
+	cases := []struct {
+		name    string
+		method  string
+		path    string
+		headers map[string]string
+		body    string
+		want    int
+	}{
+		{"没有令牌", http.MethodPost, "/api/views", nil, "", http.StatusForbidden},
+		{"令牌一致", http.MethodPost, "/api/views", map[string]string{"Cookie": "csrf_token=" + token, csrfHeader: token}, "", http.StatusOK},
+		{"令牌不一致", http.MethodPost, "/api/views", map[string]string{"Cookie": "csrf_token=" + token, csrfHeader: "other"}, "", http.StatusForbidden},
+		{"只有 Cookie", http.MethodDelete, "/api/views/1", map[string]string{"Cookie": "csrf_token=" + token}, "", http.StatusForbidden},
+		{"表单字段中的令牌", http.MethodPost, "/api/views", map[string]string{"Cookie": "csrf_token=" + token, "Content-Type": "application/x-www-form-urlencoded"}, "csrf_token=" + token, http.StatusOK},
+		{"不带 Cookie 的 API 密钥", http.MethodPost, "/api/views", map[string]string{"X-API-Key": "sv_anything"}, "", http.StatusOK},
+		{"不带 Cookie 的 Authorization", http.MethodPut, "/api/views/1", map[string]string{"Authorization": "Bearer anything"}, "", http.StatusOK},
+		{"带 Cookie 的无效 API 密钥", http.MethodPost, "/api/views", map[string]string{"Cookie": "csrf_token=" + token, "X-API-Key": "bogus"}, "", http.StatusForbidden},
+		{"带 Cookie 的无效 Authorization", http.MethodPatch, "/api/views/1", map[string]string{"Cookie": "csrf_token=" + token, "Authorization": "Bearer bogus"}, "", http.StatusForbidden},
+		{"带其他 Cookie 的 API 密钥", http.MethodPost, "/api/views", map[string]string{"Cookie": "session=abc", "X-API-Key": "bogus"}, "", http.StatusForbidden},
+		{"带 Cookie 的 API 密钥和令牌", http.MethodPost, "/api/views", map[string]string{"Cookie": "csrf_token=" + token, "X-API-Key": "sv_anything", csrfHeader: token}, "", http.StatusOK},
+		{"Webhook 路径", http.MethodPost, "/api/ingest/webhooks/shopify", map[string]string{"Cookie": "csrf_token=" + token}, "", http.StatusOK},
+		{"GET 不校验", http.MethodGet, "/api/views", map[string]string{"X-API-Key": "bogus"}, "", http.StatusOK},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+			for k, v := range c.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != c.want {
+				t.Errorf("%s %s 状态码 = %d, 期望 %d: %s", c.method, c.path, rec.Code, c.want, rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("GET 下发令牌", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/views", nil))
+		cookies := rec.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != "csrf_token" || cookies[0].Value == "" || cookies[0].HttpOnly {
+			t.Errorf("GET 应下发前端可读的令牌 Cookie, 得到 %v", cookies)
+		}
+	})
+}
@@ -0,0 +1,231 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// 熔断器的默认配置
+const (
+	// DefaultBreakerThreshold 连续多少次连接失败后熔断
+	DefaultBreakerThreshold = 5
+	// DefaultBreakerOpenTimeout 熔断后多久放行一个探测请求
+	DefaultBreakerOpenTimeout = 10 * time.Second
+)
+
+// ErrCircuitOpen 数据库熔断中，请求未发送到数据库
+var ErrCircuitOpen = errors.New("数据库暂时不可用")
+
+// CircuitOpenError 熔断中被拒绝的请求，RetryAfter 为距离下一次探测的时间
+type CircuitOpenError struct {
+	RetryAfter time.Duration
+	LastError  string
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%v（连续连接失败已熔断，%s 后重试）: %s", ErrCircuitOpen, e.RetryAfter.Round(time.Second), e.LastError)
+}
+
+// Unwrap 使 errors.Is(err, ErrCircuitOpen) 成立
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+// 熔断器状态
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// BreakerStatus 熔断器当前状态和统计
+type BreakerStatus struct {
+	Enabled             bool       `json:"enabled"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Threshold           int        `json:"threshold"`
+	OpenTimeoutMs       int64      `json:"open_timeout_ms"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	// Trips 熔断次数，Rejected 熔断期间直接拒绝的请求数，均从进程启动开始累计
+	Trips    int64 `json:"trips"`
+	Rejected int64 `json:"rejected"`
+}
+
+// CircuitBreaker 数据库熔断器
+// 连续 threshold 次连接类错误（连接被拒绝、连接中断、数据库正在关闭等）后熔断，
+// 熔断期间查询直接返回 *CircuitOpenError，不再占用连接池等待超时；
+// 经过 openTimeout 后进入半开状态放行一个探测请求，成功则恢复，失败则继续熔断
+// SQL 语法错误、约束冲突、调用方超时等不计为失败
+type CircuitBreaker struct {
+	threshold   int
+	openTimeout time.Duration
+	// onTrip 熔断时调用，用于丢弃连接池中已失效的空闲连接
+	onTrip func()
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+	lastErr  string
+	trips    int64
+	rejected int64
+	now      func() time.Time
+}
+
+// NewCircuitBreaker 创建熔断器
+func NewCircuitBreaker(threshold int, openTimeout time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		threshold = DefaultBreakerThreshold
+	}
+	if openTimeout <= 0 {
+		openTimeout = DefaultBreakerOpenTimeout
+	}
+	return &CircuitBreaker{threshold: threshold, openTimeout: openTimeout, state: BreakerClosed, now: time.Now}
+}
+
+// SetCircuitBreaker 启用熔断器，nil 表示关闭
+// 熔断时丢弃全部空闲连接，恢复后的请求使用新建的连接
+func (db *DB) SetCircuitBreaker(breaker *CircuitBreaker) {
+	if breaker != nil {
+		breaker.onTrip = db.dropIdleConns
+	}
+	db.breaker = breaker
+}
+
+// CircuitBreakerStatus 熔断器状态，未启用时 Enabled 为 false
+func (db *DB) CircuitBreakerStatus() BreakerStatus {
+	if db == nil || db.breaker == nil {
+		return BreakerStatus{State: BreakerClosed}
+	}
+	return db.breaker.Status()
+}
+
+// allow 请求是否可以发送到数据库
+// 半开状态只放行一个探测请求，其余请求在探测结果出来之前继续被拒绝
+func (b *CircuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if wait := b.openTimeout - b.now().Sub(b.openedAt); wait > 0 {
+			b.rejected++
+			return &CircuitOpenError{RetryAfter: wait, LastError: b.lastErr}
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		log.Printf("🔌 数据库熔断器半开，放行探测请求")
+		return nil
+	case BreakerHalfOpen:
+		if b.probing {
+			b.rejected++
+			return &CircuitOpenError{RetryAfter: time.Second, LastError: b.lastErr}
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// record 记录一次数据库访问的结果
+// 任何成功的访问（包括不经过 allow 的 QueryRow）都说明数据库已恢复，熔断器随之关闭
+func (b *CircuitBreaker) record(err error) {
+	if b == nil || errors.Is(err, ErrCircuitOpen) {
+		return
+	}
+	b.mu.Lock()
+	if !IsConnectionError(err) {
+		if b.state != BreakerClosed {
+			log.Printf("✅ 数据库已恢复，熔断器关闭")
+		}
+		b.state, b.failures, b.probing = BreakerClosed, 0, false
+		b.mu.Unlock()
+		return
+	}
+
+	b.lastErr = err.Error()
+	tripped := false
+	switch b.state {
+	case BreakerClosed:
+		b.failures++
+		tripped = b.failures >= b.threshold
+	case BreakerHalfOpen:
+		tripped = true
+	}
+	if tripped {
+		b.state, b.openedAt, b.probing = BreakerOpen, b.now(), false
+		b.trips++
+		log.Printf("⚡ 数据库连续 %d 次连接失败，熔断 %s: %v", b.failures, b.openTimeout, err)
+	}
+	onTrip := b.onTrip
+	b.mu.Unlock()
+
+	if tripped && onTrip != nil {
+		onTrip()
+	}
+}
+
+// Status 熔断器当前状态
+func (b *CircuitBreaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		Enabled:             true,
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		Threshold:           b.threshold,
+		OpenTimeoutMs:       b.openTimeout.Milliseconds(),
+		LastError:           b.lastErr,
+		Trips:               b.trips,
+		Rejected:            b.rejected,
+	}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
+
+// IsConnectionError 错误是否表示数据库连接不可用（而不是语句本身出错）
+func IsConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+	// 调用方取消或超时不代表数据库不可用
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, ErrTLS) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// 08 连接异常；57P01~57P03 数据库正在关闭或启动；53300 连接数已满
+		switch {
+		case pqErr.Code.Class() == "08", pqErr.Code == "57P01", pqErr.Code == "57P02", pqErr.Code == "57P03", pqErr.Code == "53300":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClickHouse ClickHouse HTTP 接口客户端
+// 使用 HTTP 协议（默认 8123 端口）访问，无需额外驱动
+type ClickHouse struct {
+	config     ClickHouseConfig
+	httpClient *http.Client
+
+	// mu 保护 config 中的用户名和密码，密钥轮换时会替换
+	mu sync.RWMutex
+}
+
+// ClickHouseConfig ClickHouse 配置
+type ClickHouseConfig struct {
+	URL      string
+	Database string
+	User     string
+	Password string
+	Timeout  time.Duration
+}
+
+// NewClickHouseConnection 创建 ClickHouse 连接并测试可用性
+func NewClickHouseConnection() (*ClickHouse, error) {
+	return NewClickHouseConnectionWithConfig(ClickHouseConfigFromEnv())
+}
+
+// NewClickHouseConnectionWithConfig 使用指定配置创建 ClickHouse 连接
+func NewClickHouseConnectionWithConfig(config ClickHouseConfig) (*ClickHouse, error) {
+	log.Printf("正在连接 ClickHouse: %s/%s", config.URL, config.Database)
+
+	ch := &ClickHouse{
+		config:     config,
+		httpClient: &http.Client{Timeout: config.Timeout},
+	}
+
+	if err := ch.Ping(); err != nil {
+		return nil, fmt.Errorf("ClickHouse 连接测试失败: %w", err)
+	}
+
+	log.Println("✅ ClickHouse 连接成功")
+	return ch, nil
+}
+
+// ClickHouseConfigFromEnv 从环境变量获取 ClickHouse 配置
+func ClickHouseConfigFromEnv() ClickHouseConfig {
+	timeout, err := time.ParseDuration(getEnv("CLICKHOUSE_TIMEOUT", "30s"))
+	if err != nil {
+		timeout = 30 * time.Second
+	}
+
+	return ClickHouseConfig{
+		URL:      strings.TrimRight(getEnv("CLICKHOUSE_URL", "http://localhost:8123"), "/"),
+		Database: getEnv("CLICKHOUSE_DATABASE", "timezone_demo"),
+		User:     getEnv("CLICKHOUSE_USER", "default"),
+		Password: getEnv("CLICKHOUSE_PASSWORD", ""),
+		Timeout:  timeout,
+	}
+}
+
+// SetCredentials 替换用户名和密码，之后的请求使用新凭证
+func (ch *ClickHouse) SetCredentials(user, password string) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.config.User = user
+	ch.config.Password = password
+}
+
+// Credentials 当前使用的用户名和密码
+func (ch *ClickHouse) Credentials() (user, password string) {
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+	return ch.config.User, ch.config.Password
+}
+
+// Ping 测试 ClickHouse 连接
+func (ch *ClickHouse) Ping() error {
+	return ch.Exec("SELECT 1", nil, nil)
+}
+
+// Exec 执行不返回结果的语句，body 不为空时作为 INSERT 数据体发送
+func (ch *ClickHouse) Exec(query string, params map[string]string, body io.Reader) error {
+	return ch.ExecContext(context.Background(), query, params, body)
+}
+
+// ExecContext 与 Exec 相同，ctx 取消或超时时中止 HTTP 请求
+func (ch *ClickHouse) ExecContext(ctx context.Context, query string, params map[string]string, body io.Reader) error {
+	resp, err := ch.do(ctx, query, params, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// Query 执行查询并将 FORMAT JSON 的 data 部分解码到 dest（切片指针）
+// 参数使用 ClickHouse 的 {name:Type} 占位符语法，通过 params 传入
+func (ch *ClickHouse) Query(query string, params map[string]string, dest interface{}) error {
+	return ch.QueryContext(context.Background(), query, params, dest)
+}
+
+// QueryContext 与 Query 相同，ctx 取消或超时时中止 HTTP 请求
+func (ch *ClickHouse) QueryContext(ctx context.Context, query string, params map[string]string, dest interface{}) error {
+	resp, err := ch.do(ctx, query+" FORMAT JSON", params, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	result := struct {
+		Data interface{} `json:"data"`
+	}{Data: dest}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("解析 ClickHouse 查询结果失败: %w", err)
+	}
+	return nil
+}
+
+// do 发送 HTTP 请求，非 200 响应转换为错误
+func (ch *ClickHouse) do(ctx context.Context, query string, params map[string]string, body io.Reader) (*http.Response, error) {
+	values := url.Values{}
+	values.Set("database", ch.config.Database)
+	// 64 位整数按数字而不是字符串输出，方便直接解码到 int
+	values.Set("output_format_json_quote_64bit_integers", "0")
+	for name, value := range params {
+		values.Set("param_"+name, value)
+	}
+
+	var req *http.Request
+	var err error
+	if body != nil {
+		// INSERT 语句放在 query 参数中，数据放在请求体
+		values.Set("query", query)
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, ch.config.URL+"/?"+values.Encode(), body)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, ch.config.URL+"/?"+values.Encode(), strings.NewReader(query))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("创建 ClickHouse 请求失败: %w", err)
+	}
+	req.SetBasicAuth(ch.Credentials())
+
+	resp, err := ch.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求 ClickHouse 失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("ClickHouse 返回错误 (%d): %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// 启动重试的默认配置
+const (
+	// DefaultConnectMaxWait 启动时等待数据库就绪的最长时间
+	DefaultConnectMaxWait = 30 * time.Second
+	// DefaultConnectBackoff 第一次重试前的等待时间
+	DefaultConnectBackoff = 500 * time.Millisecond
+	// DefaultConnectMaxBackoff 两次重试之间的最长等待时间
+	DefaultConnectMaxBackoff = 10 * time.Second
+)
+
+// RetryPolicy 启动时连接数据库的重试策略，零值表示只尝试一次
+type RetryPolicy struct {
+	// MaxWait 从第一次尝试开始最多等待的时间
+	MaxWait time.Duration
+	// InitialBackoff 第一次重试前的等待时间，之后每次翻倍
+	InitialBackoff time.Duration
+	// MaxBackoff 两次重试之间的最长等待时间
+	MaxBackoff time.Duration
+}
+
+// RetryPolicyFromEnv 从环境变量获取重试策略，DB_CONNECT_MAX_WAIT=0 表示不重试
+func RetryPolicyFromEnv() RetryPolicy {
+	return RetryPolicy{
+		MaxWait:        getEnvAsDuration("DB_CONNECT_MAX_WAIT", DefaultConnectMaxWait),
+		InitialBackoff: getEnvAsDuration("DB_CONNECT_BACKOFF", DefaultConnectBackoff),
+		MaxBackoff:     getEnvAsDuration("DB_CONNECT_MAX_BACKOFF", DefaultConnectMaxBackoff),
+	}
+}
+
+// ConnectWithRetry 连接数据库，失败时按指数退避重试，直到成功、超过 MaxWait 或 ctx 取消
+// TLS 配置错误和认证失败不会因为等待而恢复，立即返回；onRetry 在每次重试前调用，可为 nil
+// 用于 docker-compose 等编排中应用先于数据库启动的情况
+func ConnectWithRetry(ctx context.Context, config Config, policy RetryPolicy, onRetry func(attempt int, err error, wait time.Duration)) (*DB, error) {
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = DefaultConnectBackoff
+	}
+	if policy.MaxBackoff < policy.InitialBackoff {
+		policy.MaxBackoff = policy.InitialBackoff
+	}
+
+	deadline := time.Now().Add(policy.MaxWait)
+	backoff := policy.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		db, err := NewConnectionWithConfig(config)
+		if err == nil {
+			return db, nil
+		}
+		if errors.Is(err, ErrTLSConfig) || errors.Is(err, ErrAuth) {
+			return nil, err
+		}
+
+		wait := backoff
+		if remaining := time.Until(deadline); remaining < wait {
+			wait = remaining
+		}
+		if wait <= 0 {
+			if attempt == 1 {
+				return nil, err
+			}
+			return nil, fmt.Errorf("等待数据库就绪超时（%s，共尝试 %d 次）: %w", policy.MaxWait, attempt, err)
+		}
+
+		log.Printf("⏳ 数据库暂不可用（第 %d 次尝试），%s 后重试: %v", attempt, wait.Round(time.Millisecond), err)
+		if onRetry != nil {
+			onRetry(attempt, err, wait)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("等待数据库就绪已取消: %w", err)
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
+
+// getEnvAsDuration 获取时长类型的环境变量，格式错误时使用默认值
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(getEnv(key, "")); err == nil {
+		return value
+	}
+	return defaultValue
+}
@@ -1,19 +1,30 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 // DB 数据库连接包装器
 type DB struct {
 	*sql.DB
+	// slow 慢查询日志，为 nil 时不记录
+	slow *SlowQueryLog
+	// plans 语句指纹的耗时统计，为 nil 时不统计
+	plans *PlanRecorder
+	// connector 新建连接使用的配置，Rotate 时替换
+	connector *rotatingConnector
+	// breaker 熔断器，为 nil 时不熔断
+	breaker *CircuitBreaker
 }
 
 // Config 数据库配置
@@ -25,50 +36,84 @@ type Config struct {
 	DBName   string
 	SSLMode  string
 	Timezone string
+
+	// TLS 证书：CA 证书、客户端证书和私钥的文件路径，或直接给出 PEM 内容（优先于路径）
+	SSLRootCert    string
+	SSLCert        string
+	SSLKey         string
+	SSLRootCertPEM string
+	SSLCertPEM     string
+	SSLKeyPEM      string
+	// Production 生产环境要求 sslmode=verify-full
+	Production bool
 }
 
 // NewConnection 创建新的数据库连接
 func NewConnection() (*DB, error) {
-	config := getConfigFromEnv()
-	
-	// 构建连接字符串
-	dsn := fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s timezone=%s",
-		config.Host,
-		config.Port,
-		config.User,
-		config.Password,
-		config.DBName,
-		config.SSLMode,
-		config.Timezone,
-	)
-
-	log.Printf("正在连接数据库: %s:%d/%s", config.Host, config.Port, config.DBName)
+	return NewConnectionWithConfig(ConfigFromEnv())
+}
 
-	// 打开数据库连接
-	db, err := sql.Open("postgres", dsn)
+// NewConnectionWithConfig 使用指定配置创建数据库连接
+func NewConnectionWithConfig(config Config) (*DB, error) {
+	connector, err := newConnector(config)
 	if err != nil {
-		return nil, fmt.Errorf("打开数据库连接失败: %w", err)
+		return nil, err
 	}
 
+	log.Printf("正在连接数据库: %s:%d/%s (sslmode=%s)", config.Host, config.Port, config.DBName, config.SSLMode)
+
+	// 打开数据库连接
+	rotating := &rotatingConnector{current: connector, config: config}
+	db := sql.OpenDB(rotating)
+
 	// 配置连接池
 	db.SetMaxOpenConns(25)                 // 最大打开连接数
-	db.SetMaxIdleConns(5)                  // 最大空闲连接数
+	db.SetMaxIdleConns(maxIdleConns)       // 最大空闲连接数
 	db.SetConnMaxLifetime(5 * time.Minute) // 连接最大生存时间
 	db.SetConnMaxIdleTime(1 * time.Minute) // 连接最大空闲时间
 
 	// 测试连接
 	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("数据库连接测试失败: %w", err)
+		db.Close()
+		return nil, fmt.Errorf("数据库连接测试失败: %w", classifyConnectError(err))
 	}
 
 	log.Println("✅ 数据库连接成功")
 
-	return &DB{DB: db}, nil
+	return &DB{DB: db, connector: rotating}, nil
 }
 
-// getConfigFromEnv 从环境变量获取配置
-func getConfigFromEnv() Config {
+// newConnector 按配置创建 pq 连接器
+func newConnector(config Config) (driver.Connector, error) {
+	tlsConfig, err := config.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// 构建连接字符串，TLS 由 tlsDialer 完成，驱动本身不再协商 SSL
+	params := []string{
+		"host=" + dsnQuote(config.Host),
+		"port=" + strconv.Itoa(config.Port),
+		"user=" + dsnQuote(config.User),
+		"password=" + dsnQuote(config.Password),
+		"dbname=" + dsnQuote(config.DBName),
+		"sslmode=disable",
+		"timezone=" + dsnQuote(config.Timezone),
+	}
+	dsn := strings.Join(params, " ")
+
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("打开数据库连接失败: %w", err)
+	}
+	if tlsConfig != nil {
+		connector.Dialer(tlsDialer{config: tlsConfig})
+	}
+	return connector, nil
+}
+
+// ConfigFromEnv 从环境变量获取配置
+func ConfigFromEnv() Config {
 	config := Config{
 		Host:     getEnv("DB_HOST", "localhost"),
 		Port:     getEnvAsInt("DB_PORT", 5432),
@@ -77,6 +122,14 @@ func getConfigFromEnv() Config {
 		DBName:   getEnv("DB_NAME", "timezone_demo"),
 		SSLMode:  getEnv("DB_SSLMODE", "disable"),
 		Timezone: getEnv("DB_TIMEZONE", "UTC"),
+
+		SSLRootCert:    getEnv("DB_SSLROOTCERT", ""),
+		SSLCert:        getEnv("DB_SSLCERT", ""),
+		SSLKey:         getEnv("DB_SSLKEY", ""),
+		SSLRootCertPEM: getEnv("DB_SSLROOTCERT_PEM", ""),
+		SSLCertPEM:     getEnv("DB_SSLCERT_PEM", ""),
+		SSLKeyPEM:      getEnv("DB_SSLKEY_PEM", ""),
+		Production:     getEnv("APP_ENV", "") == "production",
 	}
 
 	// 如果密码为空，尝试从文件读取（Docker secrets）
@@ -125,9 +178,24 @@ func (db *DB) GetStats() sql.DBStats {
 	return db.DB.Stats()
 }
 
-// BeginTx 开始事务
+// BeginTx 开始事务，熔断中直接返回 *CircuitOpenError
 func (db *DB) BeginTx() (*sql.Tx, error) {
-	return db.DB.Begin()
+	if err := db.breaker.allow(); err != nil {
+		return nil, err
+	}
+	tx, err := db.DB.Begin()
+	db.breaker.record(err)
+	return tx, err
+}
+
+// BeginReadOnlyTx 开始只读事务，事务中的写操作会被数据库拒绝；熔断中直接返回 *CircuitOpenError
+func (db *DB) BeginReadOnlyTx(ctx context.Context) (*sql.Tx, error) {
+	if err := db.breaker.allow(); err != nil {
+		return nil, err
+	}
+	tx, err := db.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	db.breaker.record(err)
+	return tx, err
 }
 
 // ExecWithRetry 带重试的执行
@@ -201,6 +269,25 @@ func (db *DB) HealthCheck() error {
 	return nil
 }
 
+// Ready 就绪检查：在 ctx 超时前确认连接可用且能执行查询
+// 与 HealthCheck 不同，不输出日志，适合被探针高频调用
+func (db *DB) Ready(ctx context.Context) error {
+	var result int
+	if err := db.QueryRowContext(ctx, "SELECT 1").Scan(&result); err != nil {
+		return fmt.Errorf("数据库查询失败: %w", err)
+	}
+	return nil
+}
+
+// Now 数据库服务器的当前时间（SELECT now()）
+func (db *DB) Now(ctx context.Context) (time.Time, error) {
+	var now time.Time
+	if err := db.QueryRowContext(ctx, "SELECT now()").Scan(&now); err != nil {
+		return time.Time{}, fmt.Errorf("查询数据库时间失败: %w", err)
+	}
+	return now, nil
+}
+
 // GetVersion 获取数据库版本
 func (db *DB) GetVersion() (string, error) {
 	var version string
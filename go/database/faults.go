@@ -0,0 +1,30 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+)
+
+// droppedConnKey 标记 context 的数据库连接被故障注入断开
+type droppedConnKey struct{}
+
+// WithDroppedConnection 模拟请求的数据库连接断开：带该 context 的 QueryContext/ExecContext
+// 会从连接池丢弃一个连接并返回 driver.ErrBadConn，QueryRowContext 只丢弃连接
+// 不带 context 的查询不受影响
+func WithDroppedConnection(ctx context.Context) context.Context {
+	return context.WithValue(ctx, droppedConnKey{}, true)
+}
+
+// droppedConnection context 被标记为断开时丢弃一个连接并返回错误
+func (db *DB) droppedConnection(ctx context.Context) error {
+	if dropped, _ := ctx.Value(droppedConnKey{}).(bool); !dropped {
+		return nil
+	}
+	// 返回 ErrBadConn 后 database/sql 会关闭该连接而不是放回连接池
+	if conn, err := db.DB.Conn(ctx); err == nil {
+		conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		conn.Close()
+	}
+	return fmt.Errorf("故障注入：数据库连接已断开: %w", driver.ErrBadConn)
+}
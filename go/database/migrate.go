@@ -0,0 +1,123 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// Migrate 执行 dir 中尚未应用的迁移脚本，返回本次应用的脚本名
+// 已应用的脚本记录在 schema_migrations 表中。
+// 对于通过 docker-entrypoint-initdb.d 初始化的数据库（表已存在但没有迁移记录），
+// 首次运行时会将当前所有迁移脚本标记为已应用，避免 01_schema.sql 重新建表清空数据。
+func (db *DB) Migrate(dir string) ([]string, error) {
+	scripts, err := ListScripts(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	tracked, err := db.CheckTableExists("schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	if !tracked {
+		if _, err := db.Exec(`
+			CREATE TABLE schema_migrations (
+				name VARCHAR(255) PRIMARY KEY,
+				applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+			)
+		`); err != nil {
+			return nil, fmt.Errorf("创建迁移记录表失败: %w", err)
+		}
+
+		initialized, err := db.CheckTableExists("dim_merchant")
+		if err != nil {
+			return nil, err
+		}
+		if initialized {
+			log.Println("⚠️ 检测到已初始化的数据库，将现有迁移脚本标记为已应用")
+			for _, script := range scripts {
+				if script.Kind != ScriptMigration {
+					continue
+				}
+				if _, err := db.Exec(`INSERT INTO schema_migrations (name) VALUES ($1)`, script.Name); err != nil {
+					return nil, fmt.Errorf("记录迁移 %s 失败: %w", script.Name, err)
+				}
+			}
+			return nil, nil
+		}
+	}
+
+	applied := map[string]bool{}
+	rows, err := db.Query(`SELECT name FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("查询迁移记录失败: %w", err)
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("扫描迁移记录失败: %w", err)
+		}
+		applied[name] = true
+	}
+	rows.Close()
+
+	var result []string
+	for _, script := range scripts {
+		if script.Kind != ScriptMigration || applied[script.Name] {
+			continue
+		}
+		if err := db.applyMigration(script); err != nil {
+			return result, err
+		}
+		log.Printf("✅ 已应用迁移: %s", script.Name)
+		result = append(result, script.Name)
+	}
+
+	return result, nil
+}
+
+// applyMigration 在事务中执行单个迁移脚本并记录
+func (db *DB) applyMigration(script Script) error {
+	content, err := os.ReadFile(script.Path)
+	if err != nil {
+		return fmt.Errorf("读取脚本文件失败: %w", err)
+	}
+
+	tx, err := db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(string(content)); err != nil {
+		return fmt.Errorf("执行迁移 %s 失败: %w", script.Name, err)
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (name) VALUES ($1)`, script.Name); err != nil {
+		return fmt.Errorf("记录迁移 %s 失败: %w", script.Name, err)
+	}
+
+	return tx.Commit()
+}
+
+// Seed 执行 dir 中的示例数据脚本
+func (db *DB) Seed(dir string) ([]string, error) {
+	scripts, err := ListScripts(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	for _, script := range scripts {
+		if script.Kind != ScriptSeed {
+			continue
+		}
+		if err := db.ExecuteScript(script.Path); err != nil {
+			return result, err
+		}
+		result = append(result, script.Name)
+	}
+
+	return result, nil
+}
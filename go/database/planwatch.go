@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 执行计划回归检测的默认配置
+const (
+	// DefaultPlanFingerprints 最多统计的语句指纹数，超过后新的指纹不再统计
+	DefaultPlanFingerprints = 500
+	// planSampleCapacity 每个指纹保留的最近耗时样本数，中位数按这些样本计算
+	planSampleCapacity = 200
+	// planCaptureTimeout 采集执行计划的超时时间，只做 EXPLAIN 不执行语句
+	planCaptureTimeout = 10 * time.Second
+)
+
+var (
+	// planStringLiteral、planNumberLiteral、planPlaceholder 归一化时替换为 ? 的字面量和占位符
+	planStringLiteral = regexp.MustCompile(`'(?:[^']|'')*'`)
+	planNumberLiteral = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	planPlaceholder   = regexp.MustCompile(`\$\d+`)
+)
+
+// QueryFingerprint 一个语句指纹在当前统计周期内的耗时统计和执行计划
+type QueryFingerprint struct {
+	Fingerprint string  `json:"fingerprint"`
+	Query       string  `json:"query"`
+	Samples     int64   `json:"samples"`
+	MedianMs    float64 `json:"median_ms"`
+	// Plan 本周期第一次执行时采集的 EXPLAIN 输出（不含 ANALYZE，不执行语句）
+	Plan      string    `json:"plan,omitempty"`
+	FirstSeen time.Time `json:"first_seen"`
+}
+
+// fingerprintStats 一个指纹的环形耗时样本
+type fingerprintStats struct {
+	query     string
+	durations []float64
+	next      int
+	samples   int64
+	plan      string
+	capturing bool
+	firstSeen time.Time
+}
+
+// PlanRecorder 按归一化的语句指纹统计只读语句的耗时，并为每个指纹采集一次执行计划
+// 统计只在内存中，Reset 开始新的统计周期（部署或 ANALYZE 之后）
+type PlanRecorder struct {
+	maxFingerprints int
+
+	mu           sync.Mutex
+	fingerprints map[string]*fingerprintStats
+}
+
+// NewPlanRecorder 创建语句指纹统计，maxFingerprints 不大于 0 时使用 DefaultPlanFingerprints
+func NewPlanRecorder(maxFingerprints int) *PlanRecorder {
+	if maxFingerprints <= 0 {
+		maxFingerprints = DefaultPlanFingerprints
+	}
+	return &PlanRecorder{maxFingerprints: maxFingerprints, fingerprints: make(map[string]*fingerprintStats)}
+}
+
+// SetPlanRecorder 启用语句指纹统计，nil 表示关闭
+// 与慢查询日志相同，只统计通过 DB 直接执行的语句
+func (db *DB) SetPlanRecorder(plans *PlanRecorder) {
+	db.plans = plans
+}
+
+// Fingerprint 归一化语句（合并空白，字面量和占位符替换为 ?）及其 SHA-256 摘要的前 16 位
+// 同一语句带不同参数、或筛选条件相同但占位符编号不同时得到相同的指纹
+func Fingerprint(query string) (string, string) {
+	normalized := compactQuery(query)
+	normalized = planStringLiteral.ReplaceAllString(normalized, "?")
+	normalized = planPlaceholder.ReplaceAllString(normalized, "?")
+	normalized = planNumberLiteral.ReplaceAllString(normalized, "?")
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])[:16], normalized
+}
+
+// record 记录一次耗时，返回需要采集执行计划的指纹（本周期还没有执行计划时），否则返回空字符串
+func (r *PlanRecorder) record(query string, elapsed time.Duration, at time.Time) string {
+	fingerprint, normalized := Fingerprint(query)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats, ok := r.fingerprints[fingerprint]
+	if !ok {
+		if len(r.fingerprints) >= r.maxFingerprints {
+			return ""
+		}
+		stats = &fingerprintStats{query: normalized, durations: make([]float64, 0, planSampleCapacity), firstSeen: at}
+		r.fingerprints[fingerprint] = stats
+	}
+	ms := float64(elapsed.Microseconds()) / 1000
+	if len(stats.durations) < planSampleCapacity {
+		stats.durations = append(stats.durations, ms)
+	} else {
+		stats.durations[stats.next] = ms
+	}
+	stats.next = (stats.next + 1) % planSampleCapacity
+	stats.samples++
+	if stats.plan != "" || stats.capturing {
+		return ""
+	}
+	stats.capturing = true
+	return fingerprint
+}
+
+// setPlan 写入采集到的执行计划；采集期间已 Reset 时丢弃
+func (r *PlanRecorder) setPlan(fingerprint, plan string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if stats, ok := r.fingerprints[fingerprint]; ok {
+		stats.plan = plan
+		stats.capturing = false
+	}
+}
+
+// Snapshot 当前周期各指纹的统计，按指纹排序
+func (r *PlanRecorder) Snapshot() []QueryFingerprint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := make([]QueryFingerprint, 0, len(r.fingerprints))
+	for fingerprint, stats := range r.fingerprints {
+		result = append(result, QueryFingerprint{
+			Fingerprint: fingerprint,
+			Query:       stats.query,
+			Samples:     stats.samples,
+			MedianMs:    median(stats.durations),
+			Plan:        stats.plan,
+			FirstSeen:   stats.firstSeen,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Fingerprint < result[j].Fingerprint })
+	return result
+}
+
+// Reset 清空统计，开始新的统计周期，之后每个指纹会重新采集执行计划
+func (r *PlanRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fingerprints = make(map[string]*fingerprintStats)
+}
+
+// capturePlan 用 EXPLAIN（不带 ANALYZE）采集语句的执行计划，失败时把错误作为计划内容
+func (db *DB) capturePlan(plans *PlanRecorder, fingerprint, query string, args []interface{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), planCaptureTimeout)
+	defer cancel()
+
+	var plan strings.Builder
+	rows, err := db.DB.QueryContext(ctx, "EXPLAIN "+query, args...)
+	if err == nil {
+		for rows.Next() {
+			var line string
+			if err = rows.Scan(&line); err != nil {
+				break
+			}
+			plan.WriteString(line)
+			plan.WriteByte('\n')
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+		rows.Close()
+	}
+	if err != nil {
+		plans.setPlan(fingerprint, fmt.Sprintf("采集执行计划失败: %v", err))
+		return
+	}
+	plans.setPlan(fingerprint, plan.String())
+}
+
+// median 样本的中位数，没有样本时为 0
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[mid]
+	}
+	return (sorted[mid-1] + sorted[mid]) / 2
+}
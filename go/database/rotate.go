@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/lib/pq"
+)
+
+// maxIdleConns 连接池保留的最大空闲连接数
+const maxIdleConns = 5
+
+// rotatingConnector 可替换配置的连接器，Rotate 之后新建的连接使用新配置
+type rotatingConnector struct {
+	mu      sync.RWMutex
+	current driver.Connector
+	config  Config
+}
+
+// Connect 实现 driver.Connector
+func (c *rotatingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.mu.RLock()
+	current := c.current
+	c.mu.RUnlock()
+	conn, err := current.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &timeoutConn{Conn: conn}, nil
+}
+
+// Driver 实现 driver.Connector
+func (c *rotatingConnector) Driver() driver.Driver {
+	return pq.Driver{}
+}
+
+// Config 当前生效的连接配置
+func (db *DB) Config() Config {
+	db.connector.mu.RLock()
+	defer db.connector.mu.RUnlock()
+	return db.connector.config
+}
+
+// Rotate 使用新配置（通常是轮换后的密码）重建连接池
+// 先用新配置建立一个测试连接，失败时保留旧配置；成功后立即关闭全部空闲连接，之后新建的连接都使用新配置
+// 正在使用的连接归还后继续复用，最迟在 ConnMaxLifetime 到期后被替换，数据库修改密码不会断开已认证的会话
+func (db *DB) Rotate(ctx context.Context, config Config) error {
+	connector, err := newConnector(config)
+	if err != nil {
+		return err
+	}
+	conn, err := connector.Connect(ctx)
+	if err != nil {
+		return fmt.Errorf("使用新配置连接数据库失败: %w", classifyConnectError(err))
+	}
+	conn.Close()
+
+	db.connector.mu.Lock()
+	db.connector.current = connector
+	db.connector.config = config
+	db.connector.mu.Unlock()
+
+	db.dropIdleConns()
+	log.Printf("🔄 数据库连接池已按新配置重建: %s@%s:%d/%s", config.User, config.Host, config.Port, config.DBName)
+	return nil
+}
+
+// dropIdleConns 关闭全部空闲连接，之后的请求使用新建的连接
+// 空闲连接数上限设为 0 会立即关闭所有空闲连接
+func (db *DB) dropIdleConns() {
+	db.SetMaxIdleConns(0)
+	db.SetMaxIdleConns(maxIdleConns)
+}
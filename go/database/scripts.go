@@ -0,0 +1,70 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ScriptKind SQL 脚本类型
+type ScriptKind string
+
+const (
+	// ScriptMigration 表结构、视图等架构脚本
+	ScriptMigration ScriptKind = "migration"
+	// ScriptSeed 示例数据脚本
+	ScriptSeed ScriptKind = "seed"
+	// ScriptExample 仅供参考的查询示例，不会被执行
+	ScriptExample ScriptKind = "example"
+)
+
+// Script sql 目录下的一个脚本文件
+type Script struct {
+	Name string
+	Path string
+	Kind ScriptKind
+}
+
+// scriptNamePattern 脚本文件名格式：两位序号_描述.sql
+var scriptNamePattern = regexp.MustCompile(`^\d{2}_[a-z0-9_]+\.sql$`)
+
+// ListScripts 按文件名顺序列出目录中的 SQL 脚本
+// 与 docker-entrypoint-initdb.d 的执行顺序保持一致
+func ListScripts(dir string) ([]Script, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取脚本目录失败: %w", err)
+	}
+
+	var scripts []Script
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !scriptNamePattern.MatchString(name) {
+			continue
+		}
+		scripts = append(scripts, Script{
+			Name: name,
+			Path: filepath.Join(dir, name),
+			Kind: scriptKind(name),
+		})
+	}
+
+	sort.Slice(scripts, func(i, j int) bool { return scripts[i].Name < scripts[j].Name })
+	return scripts, nil
+}
+
+// scriptKind 根据文件名判断脚本类型
+func scriptKind(name string) ScriptKind {
+	base := strings.TrimSuffix(name, ".sql")
+	switch {
+	case strings.HasSuffix(base, "_sample_data"), strings.Contains(base, "_seed"):
+		return ScriptSeed
+	case strings.HasSuffix(base, "_examples"):
+		return ScriptExample
+	default:
+		return ScriptMigration
+	}
+}
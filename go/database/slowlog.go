@@ -0,0 +1,280 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 慢查询日志的默认配置
+const (
+	// DefaultSlowQueryThreshold 超过该耗时的语句记为慢查询
+	DefaultSlowQueryThreshold = 500 * time.Millisecond
+	// DefaultSlowQueryCapacity 保留的最近慢查询条数
+	DefaultSlowQueryCapacity = 100
+	// explainTimeout 采集执行计划的超时时间，EXPLAIN ANALYZE 会再执行一次原语句
+	explainTimeout = 30 * time.Second
+	// maxLoggedArgLen 记录参数时每个参数的最大长度
+	maxLoggedArgLen = 64
+)
+
+// SlowQuery 一条慢查询记录
+type SlowQuery struct {
+	Query      string    `json:"query"`
+	Args       []string  `json:"args,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs float64   `json:"duration_ms"`
+	Failed     bool      `json:"failed,omitempty"`
+	// Plan 为 EXPLAIN (ANALYZE, BUFFERS) 的输出，只有被采样的 SELECT 语句才有
+	Plan         string `json:"plan,omitempty"`
+	ExplainError string `json:"explain_error,omitempty"`
+}
+
+// SlowQueryLog 记录耗时超过阈值的语句，按采样率对其中的只读语句采集执行计划
+// 只保留最近 capacity 条，用于性能排查
+type SlowQueryLog struct {
+	threshold   time.Duration
+	explainRate float64
+	capacity    int
+
+	mu      sync.Mutex
+	entries []*SlowQuery
+	next    int
+	rand    *rand.Rand
+}
+
+// NewSlowQueryLog 创建慢查询日志
+// explainRate 为采集执行计划的比例（0 表示不采集，1 表示每条只读慢查询都采集）
+func NewSlowQueryLog(threshold time.Duration, explainRate float64, capacity int) *SlowQueryLog {
+	if capacity <= 0 {
+		capacity = DefaultSlowQueryCapacity
+	}
+	return &SlowQueryLog{
+		threshold:   threshold,
+		explainRate: explainRate,
+		capacity:    capacity,
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// SetSlowQueryLog 启用慢查询日志，nil 表示关闭
+// 只统计通过 DB 直接执行的语句，事务内的语句不在统计范围内
+func (db *DB) SetSlowQueryLog(slow *SlowQueryLog) {
+	db.slow = slow
+}
+
+// SlowQueries 最近的慢查询，按耗时倒序，limit <= 0 时返回全部
+func (db *DB) SlowQueries(limit int) []SlowQuery {
+	if db == nil || db.slow == nil {
+		return []SlowQuery{}
+	}
+	return db.slow.Slowest(limit)
+}
+
+// QueryContext 执行查询，耗时统计到返回第一批结果为止
+// 熔断中直接返回 *CircuitOpenError
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if err := db.breaker.allow(); err != nil {
+		return nil, err
+	}
+	if err := db.droppedConnection(ctx); err != nil {
+		db.breaker.record(err)
+		return nil, err
+	}
+	start := time.Now()
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	db.breaker.record(err)
+	db.observe(start, err, query, args)
+	return rows, err
+}
+
+// Query 执行查询
+func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.QueryContext(context.Background(), query, args...)
+}
+
+// QueryRowContext 执行单行查询
+// *sql.Row 无法携带自定义错误，因此不受熔断限制，但结果仍会计入熔断器
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	db.droppedConnection(ctx)
+	start := time.Now()
+	row := db.DB.QueryRowContext(ctx, query, args...)
+	db.breaker.record(row.Err())
+	db.observe(start, row.Err(), query, args)
+	return row
+}
+
+// QueryRow 执行单行查询
+func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.QueryRowContext(context.Background(), query, args...)
+}
+
+// ExecContext 执行语句，熔断中直接返回 *CircuitOpenError
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if err := db.breaker.allow(); err != nil {
+		return nil, err
+	}
+	if err := db.droppedConnection(ctx); err != nil {
+		db.breaker.record(err)
+		return nil, err
+	}
+	start := time.Now()
+	result, err := db.DB.ExecContext(ctx, query, args...)
+	db.breaker.record(err)
+	db.observe(start, err, query, args)
+	return result, err
+}
+
+// Exec 执行语句
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.ExecContext(context.Background(), query, args...)
+}
+
+// observe 语句超过阈值时记录慢查询，被采样的只读语句在后台采集执行计划；
+// 同时把成功的只读语句计入语句指纹统计
+func (db *DB) observe(start time.Time, err error, query string, args []interface{}) {
+	elapsed := time.Since(start)
+	if plans := db.plans; plans != nil && err == nil && isReadOnly(query) {
+		if fingerprint := plans.record(query, elapsed, start); fingerprint != "" {
+			go db.capturePlan(plans, fingerprint, query, args)
+		}
+	}
+
+	slow := db.slow
+	if slow == nil {
+		return
+	}
+	if elapsed < slow.threshold {
+		return
+	}
+
+	entry := &SlowQuery{
+		Query:      compactQuery(query),
+		Args:       formatArgs(args),
+		StartedAt:  start,
+		DurationMs: float64(elapsed.Microseconds()) / 1000,
+		Failed:     err != nil,
+	}
+	log.Printf("🐢 慢查询 %s: %s", elapsed.Round(time.Millisecond), truncate(entry.Query, 200))
+
+	explain := slow.add(entry) && isReadOnly(query)
+	if explain {
+		go db.explain(entry, query, args)
+	}
+}
+
+// explain 用 EXPLAIN (ANALYZE, BUFFERS) 重新执行语句，结果写回慢查询记录
+func (db *DB) explain(entry *SlowQuery, query string, args []interface{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), explainTimeout)
+	defer cancel()
+
+	var plan strings.Builder
+	rows, err := db.DB.QueryContext(ctx, "EXPLAIN (ANALYZE, BUFFERS) "+query, args...)
+	if err == nil {
+		for rows.Next() {
+			var line string
+			if err = rows.Scan(&line); err != nil {
+				break
+			}
+			plan.WriteString(line)
+			plan.WriteByte('\n')
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+		rows.Close()
+	}
+
+	db.slow.mu.Lock()
+	defer db.slow.mu.Unlock()
+	if err != nil {
+		entry.ExplainError = fmt.Sprintf("采集执行计划失败: %v", err)
+		return
+	}
+	entry.Plan = plan.String()
+}
+
+// add 记录一条慢查询，返回是否需要采集执行计划
+func (l *SlowQueryLog) add(entry *SlowQuery) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.entries) < l.capacity {
+		l.entries = append(l.entries, entry)
+	} else {
+		l.entries[l.next] = entry
+	}
+	l.next = (l.next + 1) % l.capacity
+	return l.explainRate > 0 && l.rand.Float64() < l.explainRate
+}
+
+// Slowest 最近的慢查询，按耗时倒序，limit <= 0 时返回全部
+func (l *SlowQueryLog) Slowest(limit int) []SlowQuery {
+	l.mu.Lock()
+	result := make([]SlowQuery, 0, len(l.entries))
+	for _, entry := range l.entries {
+		result = append(result, *entry)
+	}
+	l.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool { return result[i].DurationMs > result[j].DurationMs })
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// isReadOnly 只对 SELECT / WITH 开头的语句采集执行计划，EXPLAIN ANALYZE 会真正执行语句
+// WITH 中可以包含写操作，SELECT 也可能调用有副作用的函数（咨询锁、序列），这里按关键字保守排除
+func isReadOnly(query string) bool {
+	q := strings.ToUpper(strings.TrimSpace(query))
+	for _, fn := range []string{"PG_ADVISORY", "NEXTVAL", "SETVAL"} {
+		if strings.Contains(q, fn) {
+			return false
+		}
+	}
+	switch {
+	case strings.HasPrefix(q, "SELECT"):
+		return !strings.Contains(q, "FOR UPDATE")
+	case strings.HasPrefix(q, "WITH"):
+		for _, keyword := range []string{"INSERT", "UPDATE", "DELETE", "MERGE"} {
+			if strings.Contains(q, keyword) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// compactQuery 合并语句中的空白，便于日志单行展示
+func compactQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// formatArgs 将参数格式化为字符串，过长的参数截断
+func formatArgs(args []interface{}) []string {
+	if len(args) == 0 {
+		return nil
+	}
+	result := make([]string, len(args))
+	for i, arg := range args {
+		result[i] = truncate(fmt.Sprint(arg), maxLoggedArgLen)
+	}
+	return result
+}
+
+// truncate 截断到 n 个字符
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "…"
+}
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// statementTimeoutKey 请求的语句超时
+type statementTimeoutKey struct{}
+
+// WithStatementTimeout 为 ctx 中执行的语句设置 PostgreSQL statement_timeout，d <= 0 表示恢复数据库的默认值
+// 超时由数据库中止语句并释放连接，即使调用方没有为 ctx 设置截止时间；不带该值的查询使用数据库的默认值。
+// 设置作用于连接会话：连接上次使用的超时不同时先执行一次 SET，事务中的语句使用开始事务时的超时；
+// 预编译语句使用 Prepare 时的超时
+func WithStatementTimeout(ctx context.Context, d time.Duration) context.Context {
+	if d < 0 {
+		d = 0
+	}
+	return context.WithValue(ctx, statementTimeoutKey{}, d)
+}
+
+// StatementTimeoutFromContext ctx 中设置的语句超时，未设置时 ok 为 false
+func StatementTimeoutFromContext(ctx context.Context) (d time.Duration, ok bool) {
+	d, ok = ctx.Value(statementTimeoutKey{}).(time.Duration)
+	return d, ok
+}
+
+// IsStatementTimeout err 是否为数据库因 statement_timeout 中止语句（SQLSTATE 57014）
+// 调用方取消查询时数据库返回同样的错误码，以消息区分
+func IsStatementTimeout(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "57014" && strings.Contains(pqErr.Message, "statement timeout")
+}
+
+// timeoutConn 按 ctx 中的语句超时设置会话 statement_timeout 的连接，其余操作交给 pq 连接
+type timeoutConn struct {
+	driver.Conn
+	// current 会话当前的 statement_timeout，0 为数据库默认值
+	current time.Duration
+	// inTx 事务中不修改会话设置：事务内的 SET 会随回滚撤销，与 current 不一致
+	inTx bool
+}
+
+// apply 会话的 statement_timeout 与 ctx 要求的不同时执行 SET 或 RESET
+func (c *timeoutConn) apply(ctx context.Context) error {
+	want, _ := StatementTimeoutFromContext(ctx)
+	if c.inTx || want == c.current {
+		return nil
+	}
+	stmt := "RESET statement_timeout"
+	if want > 0 {
+		// 不足 1 毫秒按 1 毫秒，0 在数据库中表示不限制
+		ms := want.Milliseconds()
+		if ms == 0 {
+			ms = 1
+		}
+		stmt = fmt.Sprintf("SET statement_timeout = %d", ms)
+	}
+	if _, err := c.Conn.(driver.ExecerContext).ExecContext(ctx, stmt, nil); err != nil {
+		return fmt.Errorf("设置语句超时失败: %w", err)
+	}
+	c.current = want
+	return nil
+}
+
+// QueryContext 实现 driver.QueryerContext
+func (c *timeoutConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.apply(ctx); err != nil {
+		return nil, err
+	}
+	return c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+}
+
+// ExecContext 实现 driver.ExecerContext
+func (c *timeoutConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.apply(ctx); err != nil {
+		return nil, err
+	}
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+// PrepareContext 实现 driver.ConnPrepareContext
+func (c *timeoutConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if err := c.apply(ctx); err != nil {
+		return nil, err
+	}
+	return c.Conn.(driver.ConnPrepareContext).PrepareContext(ctx, query)
+}
+
+// BeginTx 实现 driver.ConnBeginTx，开始事务前按 ctx 设置超时
+func (c *timeoutConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if err := c.apply(ctx); err != nil {
+		return nil, err
+	}
+	tx, err := c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.inTx = true
+	return &timeoutTx{Tx: tx, conn: c}, nil
+}
+
+// Ping 实现 driver.Pinger
+func (c *timeoutConn) Ping(ctx context.Context) error {
+	return c.Conn.(driver.Pinger).Ping(ctx)
+}
+
+// ResetSession 实现 driver.SessionResetter
+func (c *timeoutConn) ResetSession(ctx context.Context) error {
+	return c.Conn.(driver.SessionResetter).ResetSession(ctx)
+}
+
+// IsValid 实现 driver.Validator
+func (c *timeoutConn) IsValid() bool {
+	return c.Conn.(driver.Validator).IsValid()
+}
+
+// timeoutTx 事务结束后允许连接再次修改会话设置
+type timeoutTx struct {
+	driver.Tx
+	conn *timeoutConn
+}
+
+// Commit 实现 driver.Tx
+func (t *timeoutTx) Commit() error {
+	t.conn.inTx = false
+	return t.Tx.Commit()
+}
+
+// Rollback 实现 driver.Tx
+func (t *timeoutTx) Rollback() error {
+	t.conn.inTx = false
+	return t.Tx.Rollback()
+}
@@ -0,0 +1,205 @@
+package database
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// 连接失败的类别，NewConnection 返回的错误可用 errors.Is 区分
+var (
+	// ErrTLSConfig 证书文件缺失、PEM 无法解析或生产环境未使用 verify-full，连接前即可发现
+	ErrTLSConfig = errors.New("数据库 TLS 配置错误")
+	// ErrTLS TLS 握手失败：服务器未启用 SSL、证书不受信任或主机名不匹配
+	ErrTLS = errors.New("数据库 TLS 连接失败")
+	// ErrAuth 服务器拒绝了用户名、密码或客户端证书
+	ErrAuth = errors.New("数据库认证失败")
+)
+
+// sslRequest PostgreSQL 的 SSLRequest 消息：长度 8，请求码 80877103
+var sslRequest = []byte{0, 0, 0, 8, 0x04, 0xd2, 0x16, 0x2f}
+
+// tlsConfig 按 sslmode 和证书配置生成 tls.Config，sslmode=disable 时返回 nil
+// 语义与 libpq 一致：require 只加密不校验（设置了 CA 时按 verify-ca 校验），
+// verify-ca 校验证书链，verify-full 同时校验主机名；未设置 CA 时使用系统 CA
+// 证书和私钥在连接前读取并解析，配置错误时返回 ErrTLSConfig 而不是等到握手时才失败
+func (c Config) tlsConfig() (*tls.Config, error) {
+	mode := c.SSLMode
+	switch mode {
+	case "disable", "require", "verify-ca", "verify-full":
+	default:
+		return nil, fmt.Errorf("%w: DB_SSLMODE 应为 disable、require、verify-ca 或 verify-full: %q", ErrTLSConfig, mode)
+	}
+	if c.Production && mode != "verify-full" {
+		return nil, fmt.Errorf("%w: 生产环境（APP_ENV=production）要求 DB_SSLMODE=verify-full，当前为 %s", ErrTLSConfig, mode)
+	}
+	if mode == "disable" {
+		return nil, nil
+	}
+
+	conf := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: c.Host}
+
+	rootCert, err := loadPEM("DB_SSLROOTCERT", c.SSLRootCert, c.SSLRootCertPEM)
+	if err != nil {
+		return nil, err
+	}
+	if rootCert != nil {
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(rootCert) {
+			return nil, fmt.Errorf("%w: DB_SSLROOTCERT 中没有可解析的 PEM 证书", ErrTLSConfig)
+		}
+	}
+
+	cert, err := loadPEM("DB_SSLCERT", c.SSLCert, c.SSLCertPEM)
+	if err != nil {
+		return nil, err
+	}
+	key, err := loadPEM("DB_SSLKEY", c.SSLKey, c.SSLKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	if (cert == nil) != (key == nil) {
+		return nil, fmt.Errorf("%w: 客户端证书 DB_SSLCERT 和私钥 DB_SSLKEY 需要同时设置", ErrTLSConfig)
+	}
+	if cert != nil {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: 客户端证书与私钥无法解析或不匹配: %v", ErrTLSConfig, err)
+		}
+		conf.Certificates = []tls.Certificate{pair}
+	}
+
+	switch {
+	case mode == "verify-full":
+	case mode == "verify-ca" || rootCert != nil:
+		// 只校验证书链，不校验主机名
+		conf.InsecureSkipVerify = true
+		conf.VerifyConnection = func(cs tls.ConnectionState) error {
+			opts := x509.VerifyOptions{Roots: conf.RootCAs, Intermediates: x509.NewCertPool()}
+			for _, cert := range cs.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(cert)
+			}
+			_, err := cs.PeerCertificates[0].Verify(opts)
+			return err
+		}
+	default:
+		conf.InsecureSkipVerify = true
+	}
+	return conf, nil
+}
+
+// loadPEM 读取证书内容，内联 PEM 优先于文件路径，均未设置时返回 nil
+func loadPEM(name, path, inline string) ([]byte, error) {
+	if inline != "" {
+		// 环境变量中的换行常被写成 \n
+		return []byte(strings.ReplaceAll(inline, `\n`, "\n")), nil
+	}
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 读取 %s 失败: %v", ErrTLSConfig, name, err)
+	}
+	return data, nil
+}
+
+// tlsDialer 自行完成 SSLRequest 协商和 TLS 握手，驱动以 sslmode=disable 在加密后的连接上通信
+// 这样 CA、客户端证书可以来自文件或内联 PEM 的任意组合，握手失败也能归类为 ErrTLS
+type tlsDialer struct {
+	dialer net.Dialer
+	config *tls.Config
+}
+
+// Dial 实现 pq.Dialer
+func (d tlsDialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialTimeout 实现 pq.Dialer
+func (d tlsDialer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return d.DialContext(ctx, network, address)
+}
+
+// DialContext 实现 pq.DialerContext
+func (d tlsDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := d.dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(sslRequest); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp := make([]byte, 1)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if resp[0] != 'S' {
+		conn.Close()
+		return nil, fmt.Errorf("%w（服务器未启用 SSL）: %v", ErrTLS, pq.ErrSSLNotSupported)
+	}
+
+	tlsConn := tls.Client(conn, d.config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, classifyHandshakeError(err)
+	}
+	conn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
+
+// classifyHandshakeError 为握手错误补充常见原因
+func classifyHandshakeError(err error) error {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostname         x509.HostnameError
+	)
+	switch {
+	case errors.As(err, &unknownAuthority):
+		return fmt.Errorf("%w（服务器证书不受信任，请检查 DB_SSLROOTCERT）: %v", ErrTLS, err)
+	case errors.As(err, &hostname):
+		return fmt.Errorf("%w（服务器证书与 DB_HOST 不匹配）: %v", ErrTLS, err)
+	}
+	return fmt.Errorf("%w: %v", ErrTLS, err)
+}
+
+// classifyConnectError 把服务器拒绝认证的错误归类为 ErrAuth，其他错误（如网络不通、ErrTLS）原样返回
+func classifyConnectError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	switch pqErr.Code {
+	case "28P01":
+		return fmt.Errorf("%w（用户名或密码错误）: %v", ErrAuth, err)
+	case "28000":
+		// pg_hba.conf 没有匹配的规则，常见原因是服务器只允许 hostssl 而客户端未启用 TLS
+		if strings.Contains(pqErr.Message, "no encryption") || strings.Contains(pqErr.Message, "SSL off") {
+			return fmt.Errorf("%w（服务器要求 TLS，请设置 DB_SSLMODE）: %v", ErrAuth, err)
+		}
+		return fmt.Errorf("%w（pg_hba.conf 拒绝了该用户或客户端证书）: %v", ErrAuth, err)
+	}
+	return err
+}
+
+// dsnQuote 按 libpq 连接字符串规则给值加引号，密码和证书中可以包含空格和引号
+func dsnQuote(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"timezone-saas-demo/services"
+	"timezone-saas-demo/timerange"
+)
+
+// queryDate 按 loc 解析 date 查询参数：YYYY-MM-DD，或按 loc 中的今天换算的 today、yesterday
+// 参数为空时使用 fallback（格式相同），fallback 也为空时返回空字符串，由服务使用默认日期
+func queryDate(r *http.Request, loc *time.Location, fallback string) (string, error) {
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		date = fallback
+	}
+	if date == "" {
+		return "", nil
+	}
+	rng, err := timerange.Parse(timerange.Params{Date: date}, loc, time.Now())
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", services.ErrInvalidArgument, err)
+	}
+	return rng.From, nil
+}
+
+// queryRange 按 loc 解析日期范围参数：date、from/to（包含两端）或 range（如 last_7_days、this_month），
+// 未指定时返回零值 Range
+func queryRange(r *http.Request, loc *time.Location) (timerange.Range, error) {
+	rng, err := timerange.Parse(timerange.FromQuery(r.URL.Query()), loc, time.Now())
+	if err != nil {
+		return timerange.Range{}, fmt.Errorf("%w: %v", services.ErrInvalidArgument, err)
+	}
+	return rng, nil
+}
+
+// queryDateRange 与 queryRange 相同，返回本地日期 from、to；
+// 只指定了 from 或 to 其中之一时校验格式后原样返回，由服务按各自的默认范围补全
+func queryDateRange(r *http.Request, loc *time.Location) (from, to string, err error) {
+	p := timerange.FromQuery(r.URL.Query())
+	if p.Date == "" && p.Range == "" && (p.From == "") != (p.To == "") {
+		for _, value := range []string{p.From, p.To} {
+			if value == "" {
+				continue
+			}
+			if _, err := timerange.ParseDate(value); err != nil {
+				return "", "", fmt.Errorf("%w: %v", services.ErrInvalidArgument, err)
+			}
+		}
+		return p.From, p.To, nil
+	}
+	rng, err := queryRange(r, loc)
+	if err != nil {
+		return "", "", err
+	}
+	return rng.From, rng.To, nil
+}
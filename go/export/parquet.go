@@ -0,0 +1,450 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"timezone-saas-demo/models"
+)
+
+// Parquet 文件的编码参数
+const (
+	// parquetMagic 文件头尾的魔数
+	parquetMagic = "PAR1"
+	// parquetRowGroupRows 每个行组的行数，写出器在内存中缓存一个行组
+	parquetRowGroupRows = 64 * 1024
+	// parquetAmountScale、parquetAmountPrecision 金额列的小数位数和精度，与 dws_orders.order_amount 的 DECIMAL(15,2) 相容
+	parquetAmountScale     = 2
+	parquetAmountPrecision = 18
+	parquetCreatedBy       = "timezone-saas-demo export"
+)
+
+// parquet.thrift 中的枚举值
+const (
+	parquetBoolean   = 0
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetRequired = 0
+
+	parquetConvertedUTF8            = 0
+	parquetConvertedDecimal         = 5
+	parquetConvertedDate            = 6
+	parquetConvertedTimestampMicros = 10
+
+	// LogicalType 联合体的字段编号
+	parquetLogicalString    = 1
+	parquetLogicalDecimal   = 5
+	parquetLogicalDate      = 6
+	parquetLogicalTimestamp = 8
+	// TimeUnit 联合体中 MICROS 的字段编号
+	parquetUnitMicros = 2
+
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+	parquetCodecGzip     = 2
+	parquetDataPage      = 0
+)
+
+// parquetColumn 一列的定义和当前行组的数据
+// 所有列都是 REQUIRED，数据页不需要定义级别和重复级别
+type parquetColumn struct {
+	name     string
+	physical int32
+	// annotate 写出 SchemaElement 中 name 之后的类型注解（converted_type、scale、precision、logicalType），可以为 nil
+	annotate func(t *thriftWriter)
+	// encode 把一行的值按 PLAIN 编码追加到 page，布尔列追加到 bools
+	encode func(c *parquetColumn, order models.OrderAnalysis) error
+
+	page  bytes.Buffer
+	bools []bool
+	// mark 写入当前行之前 page 的长度，编码失败时回退到这里
+	mark int
+}
+
+// plain 当前行组的 PLAIN 编码数据，布尔值按位打包（低位在前）
+func (c *parquetColumn) plain() []byte {
+	if c.physical != parquetBoolean {
+		return c.page.Bytes()
+	}
+	packed := make([]byte, (len(c.bools)+7)/8)
+	for i, v := range c.bools {
+		if v {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+	return packed
+}
+
+func (c *parquetColumn) reset() {
+	c.page.Reset()
+	c.bools = c.bools[:0]
+}
+
+func (c *parquetColumn) appendInt32(v int32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(v))
+	c.page.Write(b[:])
+}
+
+func (c *parquetColumn) appendInt64(v int64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(v))
+	c.page.Write(b[:])
+}
+
+func (c *parquetColumn) appendString(s string) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(len(s)))
+	c.page.Write(b[:])
+	c.page.WriteString(s)
+}
+
+// 列类型的构造函数
+
+func stringColumn(name string, value func(models.OrderAnalysis) string) *parquetColumn {
+	return &parquetColumn{
+		name:     name,
+		physical: parquetByteArray,
+		annotate: func(t *thriftWriter) {
+			t.i32Field(6, parquetConvertedUTF8)
+			t.structField(10)
+			t.emptyStructField(parquetLogicalString)
+			t.end()
+		},
+		encode: func(c *parquetColumn, order models.OrderAnalysis) error {
+			c.appendString(value(order))
+			return nil
+		},
+	}
+}
+
+func int32Column(name string, value func(models.OrderAnalysis) int) *parquetColumn {
+	return &parquetColumn{
+		name:     name,
+		physical: parquetInt32,
+		encode: func(c *parquetColumn, order models.OrderAnalysis) error {
+			c.appendInt32(int32(value(order)))
+			return nil
+		},
+	}
+}
+
+func boolColumn(name string, value func(models.OrderAnalysis) bool) *parquetColumn {
+	return &parquetColumn{
+		name:     name,
+		physical: parquetBoolean,
+		encode: func(c *parquetColumn, order models.OrderAnalysis) error {
+			c.bools = append(c.bools, value(order))
+			return nil
+		},
+	}
+}
+
+// timestampColumn 微秒精度的时间戳
+// utc 为 true 时是绝对时刻（isAdjustedToUTC）；否则是不带时区的本地挂钟时间，按挂钟读数编码
+func timestampColumn(name string, utc bool, value func(models.OrderAnalysis) time.Time) *parquetColumn {
+	return &parquetColumn{
+		name:     name,
+		physical: parquetInt64,
+		annotate: func(t *thriftWriter) {
+			// TIMESTAMP_MICROS 的含义是 UTC 时刻，本地时间只写逻辑类型
+			if utc {
+				t.i32Field(6, parquetConvertedTimestampMicros)
+			}
+			t.structField(10)
+			t.structField(parquetLogicalTimestamp)
+			t.boolField(1, utc)
+			t.structField(2)
+			t.emptyStructField(parquetUnitMicros)
+			t.end()
+			t.end()
+			t.end()
+		},
+		encode: func(c *parquetColumn, order models.OrderAnalysis) error {
+			ts := value(order)
+			if !utc {
+				ts = time.Date(ts.Year(), ts.Month(), ts.Day(), ts.Hour(), ts.Minute(), ts.Second(), ts.Nanosecond(), time.UTC)
+			}
+			c.appendInt64(ts.UnixMicro())
+			return nil
+		},
+	}
+}
+
+// dateColumn 本地日期，编码为距 1970-01-01 的天数
+func dateColumn(name string, value func(models.OrderAnalysis) string) *parquetColumn {
+	return &parquetColumn{
+		name:     name,
+		physical: parquetInt32,
+		annotate: func(t *thriftWriter) {
+			t.i32Field(6, parquetConvertedDate)
+			t.structField(10)
+			t.emptyStructField(parquetLogicalDate)
+			t.end()
+		},
+		encode: func(c *parquetColumn, order models.OrderAnalysis) error {
+			date, err := time.Parse("2006-01-02", value(order))
+			if err != nil {
+				return fmt.Errorf("订单 %d 的本地日期格式错误: %w", order.OrderID, err)
+			}
+			c.appendInt32(int32(date.Unix() / 86400))
+			return nil
+		},
+	}
+}
+
+// amountColumn 金额，编码为 DECIMAL(18,2) 的 INT64 非标度值；小数位超过 2 位时报错而不是舍入
+func amountColumn(name string) *parquetColumn {
+	return &parquetColumn{
+		name:     name,
+		physical: parquetInt64,
+		annotate: func(t *thriftWriter) {
+			t.i32Field(6, parquetConvertedDecimal)
+			t.i32Field(7, parquetAmountScale)
+			t.i32Field(8, parquetAmountPrecision)
+			t.structField(10)
+			t.structField(parquetLogicalDecimal)
+			t.i32Field(1, parquetAmountScale)
+			t.i32Field(2, parquetAmountPrecision)
+			t.end()
+			t.end()
+		},
+		encode: func(c *parquetColumn, order models.OrderAnalysis) error {
+			unscaled := order.Amount.Shift(parquetAmountScale)
+			if !unscaled.IsInteger() || unscaled.NumDigits() > parquetAmountPrecision {
+				return fmt.Errorf("订单 %d 的金额 %s 超出 DECIMAL(%d,%d)", order.OrderID, order.Amount, parquetAmountPrecision, parquetAmountScale)
+			}
+			c.appendInt64(unscaled.IntPart())
+			return nil
+		},
+	}
+}
+
+// parquetColumns 列与 CSV 表头一致
+func parquetColumns() []*parquetColumn {
+	return []*parquetColumn{
+		int32Column("order_id", func(o models.OrderAnalysis) int { return o.OrderID }),
+		stringColumn("order_number", func(o models.OrderAnalysis) string { return o.OrderNumber }),
+		amountColumn("amount"),
+		stringColumn("currency", func(o models.OrderAnalysis) string { return o.Currency }),
+		stringColumn("status", func(o models.OrderAnalysis) string { return o.Status }),
+		int32Column("merchant_id", func(o models.OrderAnalysis) int { return o.MerchantID }),
+		stringColumn("merchant_name", func(o models.OrderAnalysis) string { return o.MerchantName }),
+		stringColumn("timezone", func(o models.OrderAnalysis) string { return o.Timezone }),
+		stringColumn("country", func(o models.OrderAnalysis) string { return o.Country }),
+		stringColumn("city", func(o models.OrderAnalysis) string { return o.City }),
+		timestampColumn("order_time_utc", true, func(o models.OrderAnalysis) time.Time { return o.OrderTimeUTC }),
+		timestampColumn("order_time_local", false, func(o models.OrderAnalysis) time.Time { return o.OrderTimeLocal }),
+		dateColumn("local_date", func(o models.OrderAnalysis) string { return o.LocalDate }),
+		int32Column("local_hour", func(o models.OrderAnalysis) int { return o.LocalHour }),
+		int32Column("local_day_of_week", func(o models.OrderAnalysis) int { return o.LocalDayOfWeek }),
+		stringColumn("local_weekday", func(o models.OrderAnalysis) string { return o.LocalWeekday }),
+		boolColumn("is_weekend", func(o models.OrderAnalysis) bool { return o.IsWeekend }),
+		boolColumn("is_business_hour", func(o models.OrderAnalysis) bool { return o.IsBusinessHour }),
+		int32Column("timezone_offset", func(o models.OrderAnalysis) int { return o.TimezoneOffset }),
+	}
+}
+
+// countingWriter 记录已写出的字节数，即下一次写入在文件中的偏移
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// parquetChunk 已写出的一个列块
+type parquetChunk struct {
+	offset       int64
+	uncompressed int64
+	compressed   int64
+}
+
+// parquetRowGroup 已写出的一个行组
+type parquetRowGroup struct {
+	rows   int64
+	chunks []parquetChunk
+}
+
+// parquetWriter Parquet 写出器
+// 每个行组的每一列写成一个 GZIP 压缩的 PLAIN 数据页，元数据在 Close 时写在文件末尾；
+// 只顺序写出，不需要 Seek，因此可以写到标准输出或 HTTP 响应
+type parquetWriter struct {
+	out       *countingWriter
+	columns   []*parquetColumn
+	rows      int64
+	rowGroups []parquetRowGroup
+}
+
+func newParquetWriter(w io.Writer) (*parquetWriter, error) {
+	out := &countingWriter{w: w}
+	if _, err := io.WriteString(out, parquetMagic); err != nil {
+		return nil, fmt.Errorf("写入 Parquet 文件头失败: %w", err)
+	}
+	return &parquetWriter{out: out, columns: parquetColumns()}, nil
+}
+
+// Write 把一行订单追加到当前行组，行组满后写出
+func (p *parquetWriter) Write(order models.OrderAnalysis) error {
+	for _, c := range p.columns {
+		c.mark = c.page.Len()
+	}
+	for _, c := range p.columns {
+		if err := c.encode(c, order); err != nil {
+			// 已追加的列回退到行首，避免各列行数不一致
+			p.discardPartialRow()
+			return err
+		}
+	}
+	p.rows++
+	if p.rows >= parquetRowGroupRows {
+		return p.flushRowGroup()
+	}
+	return nil
+}
+
+// discardPartialRow 丢弃编码失败的行中已经追加的值
+func (p *parquetWriter) discardPartialRow() {
+	for _, c := range p.columns {
+		if c.physical == parquetBoolean {
+			c.bools = c.bools[:p.rows]
+			continue
+		}
+		c.page.Truncate(c.mark)
+	}
+}
+
+// Close 写出剩余的行和文件元数据
+func (p *parquetWriter) Close() error {
+	if err := p.flushRowGroup(); err != nil {
+		return err
+	}
+	footer := p.fileMetaData()
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(footer)))
+	for _, b := range [][]byte{footer, length[:], []byte(parquetMagic)} {
+		if _, err := p.out.Write(b); err != nil {
+			return fmt.Errorf("写入 Parquet 元数据失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// flushRowGroup 写出当前行组，没有缓存的行时不写
+func (p *parquetWriter) flushRowGroup() error {
+	if p.rows == 0 {
+		return nil
+	}
+	group := parquetRowGroup{rows: p.rows}
+	for _, c := range p.columns {
+		raw := c.plain()
+		var compressed bytes.Buffer
+		zw := gzip.NewWriter(&compressed)
+		if _, err := zw.Write(raw); err != nil {
+			return fmt.Errorf("压缩 Parquet 数据页失败: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("压缩 Parquet 数据页失败: %w", err)
+		}
+
+		header := newThriftWriter()
+		header.i32Field(1, parquetDataPage)
+		header.i32Field(2, int32(len(raw)))
+		header.i32Field(3, int32(compressed.Len()))
+		header.structField(5)
+		header.i32Field(1, int32(p.rows))
+		header.i32Field(2, parquetEncodingPlain)
+		header.i32Field(3, parquetEncodingRLE)
+		header.i32Field(4, parquetEncodingRLE)
+		header.end()
+		headerBytes := header.Bytes()
+
+		chunk := parquetChunk{
+			offset:       p.out.n,
+			uncompressed: int64(len(headerBytes) + len(raw)),
+			compressed:   int64(len(headerBytes) + compressed.Len()),
+		}
+		if _, err := p.out.Write(headerBytes); err != nil {
+			return fmt.Errorf("写入 Parquet 数据页失败: %w", err)
+		}
+		if _, err := p.out.Write(compressed.Bytes()); err != nil {
+			return fmt.Errorf("写入 Parquet 数据页失败: %w", err)
+		}
+		group.chunks = append(group.chunks, chunk)
+		c.reset()
+	}
+	p.rowGroups = append(p.rowGroups, group)
+	p.rows = 0
+	return nil
+}
+
+// fileMetaData 按 parquet.thrift 的 FileMetaData 编码文件元数据
+func (p *parquetWriter) fileMetaData() []byte {
+	var total int64
+	for _, g := range p.rowGroups {
+		total += g.rows
+	}
+
+	t := newThriftWriter()
+	t.i32Field(1, 1)
+
+	t.listField(2, thriftStruct, len(p.columns)+1)
+	t.listStruct()
+	t.stringField(4, "schema")
+	t.i32Field(5, int32(len(p.columns)))
+	t.end()
+	for _, c := range p.columns {
+		t.listStruct()
+		t.i32Field(1, c.physical)
+		t.i32Field(3, parquetRequired)
+		t.stringField(4, c.name)
+		if c.annotate != nil {
+			c.annotate(t)
+		}
+		t.end()
+	}
+
+	t.i64Field(3, total)
+
+	t.listField(4, thriftStruct, len(p.rowGroups))
+	for _, g := range p.rowGroups {
+		var size int64
+		t.listStruct()
+		t.listField(1, thriftStruct, len(g.chunks))
+		for i, chunk := range g.chunks {
+			c := p.columns[i]
+			size += chunk.uncompressed
+			t.listStruct()
+			t.i64Field(2, chunk.offset)
+			t.structField(3)
+			t.i32Field(1, c.physical)
+			t.listField(2, thriftI32, 1)
+			t.listI32(parquetEncodingPlain)
+			t.listField(3, thriftBinary, 1)
+			t.listString(c.name)
+			t.i32Field(4, parquetCodecGzip)
+			t.i64Field(5, g.rows)
+			t.i64Field(6, chunk.uncompressed)
+			t.i64Field(7, chunk.compressed)
+			t.i64Field(9, chunk.offset)
+			t.end()
+			t.end()
+		}
+		t.i64Field(2, size)
+		t.i64Field(3, g.rows)
+		t.end()
+	}
+
+	t.stringField(6, parquetCreatedBy)
+	return t.Bytes()
+}
@@ -0,0 +1,148 @@
+package export
+
+import (
+	"bufio"
+	"container/list"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"timezone-saas-demo/models"
+)
+
+// DefaultMaxOpenPartitions 分区写出器同时打开的文件数
+const DefaultMaxOpenPartitions = 128
+
+// partitionKey 分区：商户和本地日期
+type partitionKey struct {
+	merchantID int
+	localDate  string
+}
+
+// partitionFile 一个打开的分区文件
+type partitionFile struct {
+	key      partitionKey
+	file     *os.File
+	buffered *bufio.Writer
+	writer   OrderWriter
+	// elem 在最近使用列表中的位置
+	elem *list.Element
+}
+
+// PartitionedWriter 按（商户，本地日期）把订单写到 dir 下 Hive 风格的分区目录：
+// tenant=<merchant_id>/dt=<local_date>/part-<序号>.<格式>
+// 分区目录名与文件中的 merchant_id、local_date 列不同名，Spark / Athena 读取时不会冲突
+// 同时打开的文件超过 maxOpen 时关闭最久未写入的分区，该分区之后的订单写到新的 part 文件
+type PartitionedWriter struct {
+	dir     string
+	format  Format
+	maxOpen int
+
+	open     map[partitionKey]*partitionFile
+	lru      *list.List
+	nextPart map[partitionKey]int
+	files    int
+}
+
+// NewPartitionedWriter 创建分区写出器，maxOpen <= 0 时使用 DefaultMaxOpenPartitions
+// dir 中已有的同名文件会被覆盖
+func NewPartitionedWriter(dir string, format Format, maxOpen int) (*PartitionedWriter, error) {
+	if _, err := ParseFormat(string(format)); err != nil {
+		return nil, err
+	}
+	if maxOpen <= 0 {
+		maxOpen = DefaultMaxOpenPartitions
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建导出目录失败: %w", err)
+	}
+	return &PartitionedWriter{
+		dir:      dir,
+		format:   format,
+		maxOpen:  maxOpen,
+		open:     map[partitionKey]*partitionFile{},
+		lru:      list.New(),
+		nextPart: map[partitionKey]int{},
+	}, nil
+}
+
+// Files 已经创建的文件数
+func (p *PartitionedWriter) Files() int {
+	return p.files
+}
+
+// Write 把订单写到所属分区的文件
+func (p *PartitionedWriter) Write(order models.OrderAnalysis) error {
+	key := partitionKey{merchantID: order.MerchantID, localDate: order.LocalDate}
+	f, ok := p.open[key]
+	if ok {
+		p.lru.MoveToFront(f.elem)
+	} else {
+		var err error
+		if f, err = p.openPartition(key); err != nil {
+			return err
+		}
+	}
+	return f.writer.Write(order)
+}
+
+// Close 关闭全部打开的分区文件，返回第一个错误
+func (p *PartitionedWriter) Close() error {
+	var first error
+	for p.lru.Len() > 0 {
+		if err := p.closePartition(p.lru.Back().Value.(*partitionFile)); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// openPartition 为分区创建下一个 part 文件，必要时先关闭最久未写入的分区
+func (p *PartitionedWriter) openPartition(key partitionKey) (*partitionFile, error) {
+	if p.lru.Len() >= p.maxOpen {
+		if err := p.closePartition(p.lru.Back().Value.(*partitionFile)); err != nil {
+			return nil, err
+		}
+	}
+
+	dir := filepath.Join(p.dir, fmt.Sprintf("tenant=%d", key.merchantID), "dt="+key.localDate)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建分区目录失败: %w", err)
+	}
+	part := p.nextPart[key]
+	p.nextPart[key] = part + 1
+	file, err := os.Create(filepath.Join(dir, fmt.Sprintf("part-%05d.%s", part, p.format)))
+	if err != nil {
+		return nil, fmt.Errorf("创建分区文件失败: %w", err)
+	}
+	buffered := bufio.NewWriter(file)
+	writer, err := NewOrderWriter(buffered, p.format)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	f := &partitionFile{key: key, file: file, buffered: buffered, writer: writer}
+	f.elem = p.lru.PushFront(f)
+	p.open[key] = f
+	p.files++
+	return f, nil
+}
+
+// closePartition 写出分区文件的剩余内容并关闭
+func (p *PartitionedWriter) closePartition(f *partitionFile) error {
+	p.lru.Remove(f.elem)
+	delete(p.open, f.key)
+
+	err := f.writer.Close()
+	if err == nil {
+		err = f.buffered.Flush()
+	}
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("写入分区文件 %s 失败: %w", f.file.Name(), err)
+	}
+	return nil
+}
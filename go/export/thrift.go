@@ -0,0 +1,125 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Thrift compact 协议的类型编号，只包含 Parquet 元数据用到的类型
+const (
+	thriftBoolTrue  = 1
+	thriftBoolFalse = 2
+	thriftI32       = 5
+	thriftI64       = 6
+	thriftBinary    = 8
+	thriftList      = 9
+	thriftStruct    = 12
+)
+
+// thriftWriter 按 Thrift compact 协议编码 Parquet 的页头和文件元数据
+// 只支持写入；字段编号必须在每个结构体内递增，调用方负责按 parquet.thrift 的定义写出必填字段
+type thriftWriter struct {
+	buf bytes.Buffer
+	// lastID 每层结构体上一个字段的编号，用于计算字段头中的编号差
+	lastID []int16
+}
+
+// newThriftWriter 创建编码器，调用方从最外层结构体的第一个字段开始写
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{lastID: []int16{0}}
+}
+
+// Bytes 结束最外层结构体并返回编码结果
+func (t *thriftWriter) Bytes() []byte {
+	t.buf.WriteByte(0)
+	return t.buf.Bytes()
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	t.buf.Write(b[:n])
+}
+
+func (t *thriftWriter) zigzag(v int64) {
+	t.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+// fieldHeader 字段头：编号差在 1~15 时与类型合并为一个字节，否则单独写出编号
+func (t *thriftWriter) fieldHeader(id int16, typ byte) {
+	last := &t.lastID[len(t.lastID)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.zigzag(int64(id))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) i32Field(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) i64Field(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.zigzag(v)
+}
+
+func (t *thriftWriter) boolField(id int16, v bool) {
+	if v {
+		t.fieldHeader(id, thriftBoolTrue)
+	} else {
+		t.fieldHeader(id, thriftBoolFalse)
+	}
+}
+
+func (t *thriftWriter) stringField(id int16, s string) {
+	t.fieldHeader(id, thriftBinary)
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+// structField 开始一个结构体字段，写完其中的字段后调用 end
+func (t *thriftWriter) structField(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.lastID = append(t.lastID, 0)
+}
+
+// emptyStructField 没有字段的结构体，Parquet 的逻辑类型多为这种形式
+func (t *thriftWriter) emptyStructField(id int16) {
+	t.structField(id)
+	t.end()
+}
+
+// end 结束当前结构体
+func (t *thriftWriter) end() {
+	t.buf.WriteByte(0)
+	t.lastID = t.lastID[:len(t.lastID)-1]
+}
+
+// listField 开始一个列表字段，随后写出 n 个 elemType 类型的元素
+func (t *thriftWriter) listField(id int16, elemType byte, n int) {
+	t.fieldHeader(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | elemType)
+	} else {
+		t.buf.WriteByte(0xf0 | elemType)
+		t.varint(uint64(n))
+	}
+}
+
+// listStruct 开始列表中的一个结构体元素，写完其中的字段后调用 end
+func (t *thriftWriter) listStruct() {
+	t.lastID = append(t.lastID, 0)
+}
+
+func (t *thriftWriter) listI32(v int32) {
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) listString(s string) {
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
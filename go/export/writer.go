@@ -0,0 +1,134 @@
+// Package export 将订单分析数据编码为 CSV / NDJSON / Parquet 等导出格式
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/money"
+)
+
+// Format 导出格式
+type Format string
+
+const (
+	// FormatCSV 带表头的 CSV
+	FormatCSV Format = "csv"
+	// FormatNDJSON 每行一个 JSON 对象
+	FormatNDJSON Format = "ndjson"
+	// FormatParquet GZIP 压缩的 Parquet 列式文件，可直接由 Spark / Athena 读取
+	FormatParquet Format = "parquet"
+)
+
+// ParseFormat 解析导出格式
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case FormatCSV, FormatNDJSON, FormatParquet:
+		return Format(s), nil
+	}
+	return "", fmt.Errorf("不支持的导出格式: %s", s)
+}
+
+// ContentType 返回导出格式对应的 MIME 类型
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatParquet:
+		return "application/vnd.apache.parquet"
+	default:
+		return "application/x-ndjson"
+	}
+}
+
+// OrderWriter 逐行写出订单，调用方负责在结束时调用 Close
+type OrderWriter interface {
+	Write(order models.OrderAnalysis) error
+	Close() error
+}
+
+// NewOrderWriter 创建指定格式的订单写出器
+func NewOrderWriter(w io.Writer, format Format) (OrderWriter, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatNDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w)}, nil
+	case FormatParquet:
+		return newParquetWriter(w)
+	}
+	return nil, fmt.Errorf("不支持的导出格式: %s", format)
+}
+
+// csvHeader CSV 表头，与 OrderAnalysis 的 JSON 字段名一致
+var csvHeader = []string{
+	"order_id", "order_number", "amount", "currency", "status",
+	"merchant_id", "merchant_name", "timezone", "country", "city",
+	"order_time_utc", "order_time_local", "local_date", "local_hour",
+	"local_day_of_week", "local_weekday", "is_weekend", "is_business_hour", "timezone_offset",
+}
+
+// csvWriter CSV 写出器
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w)}
+	if err := cw.w.Write(csvHeader); err != nil {
+		return nil, fmt.Errorf("写入 CSV 表头失败: %w", err)
+	}
+	return cw, nil
+}
+
+// Write 写出一行订单
+func (c *csvWriter) Write(order models.OrderAnalysis) error {
+	record := []string{
+		strconv.Itoa(order.OrderID),
+		order.OrderNumber,
+		order.Amount.StringFixed(money.Exponent(order.Currency)),
+		order.Currency,
+		order.Status,
+		strconv.Itoa(order.MerchantID),
+		order.MerchantName,
+		order.Timezone,
+		order.Country,
+		order.City,
+		order.OrderTimeUTC.UTC().Format(time.RFC3339),
+		order.OrderTimeLocal.Format("2006-01-02 15:04:05"),
+		order.LocalDate,
+		strconv.Itoa(order.LocalHour),
+		strconv.Itoa(order.LocalDayOfWeek),
+		order.LocalWeekday,
+		strconv.FormatBool(order.IsWeekend),
+		strconv.FormatBool(order.IsBusinessHour),
+		strconv.Itoa(order.TimezoneOffset),
+	}
+	return c.w.Write(record)
+}
+
+// Close 刷新缓冲区
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ndjsonWriter NDJSON 写出器
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+// Write 写出一行订单
+func (n *ndjsonWriter) Write(order models.OrderAnalysis) error {
+	return n.encoder.Encode(order)
+}
+
+// Close NDJSON 无需收尾
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,465 @@
+// Package fastjson 为订单列表和分析结果提供不经反射的 JSON 编码，输出与 encoding/json 逐字节一致。
+// 大的订单列表在 encoding/json 中花费大量 CPU 做反射和逐字段查找；这里按字段顺序直接追加到缓冲区，
+// 写法与 easyjson 生成的 MarshalEasyJSON 相同，但不引入代码生成工具和依赖。
+// 修改 models 中对应结构体的字段或 json 标签时必须同步修改本包，bench-json 子命令会校验两种编码的输出是否一致。
+package fastjson
+
+import (
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shopspring/decimal"
+
+	"timezone-saas-demo/models"
+)
+
+// Marshal 编码支持的类型（订单列表、分析结果及其批量结果），ok 为 false 时调用方应使用 encoding/json
+func Marshal(v interface{}) (data []byte, ok bool) {
+	return Append(nil, v)
+}
+
+// Append 把 v 的编码追加到 buf，ok 为 false 时 buf 原样返回
+func Append(buf []byte, v interface{}) ([]byte, bool) {
+	switch v := v.(type) {
+	case []models.OrderAnalysis:
+		return AppendOrders(buf, v), true
+	case *models.OrderAnalysis:
+		if v == nil {
+			return append(buf, "null"...), true
+		}
+		return AppendOrder(buf, v), true
+	case models.OrderAnalysis:
+		return AppendOrder(buf, &v), true
+	case *models.AnalysisData:
+		if v == nil {
+			return append(buf, "null"...), true
+		}
+		return AppendAnalysis(buf, v), true
+	case models.AnalysisData:
+		return AppendAnalysis(buf, &v), true
+	case []models.AnalysisData:
+		return AppendAnalysisBatch(buf, v), true
+	}
+	return buf, false
+}
+
+// AppendOrders 编码订单列表，nil 编码为 null
+func AppendOrders(buf []byte, orders []models.OrderAnalysis) []byte {
+	if orders == nil {
+		return append(buf, "null"...)
+	}
+	buf = append(buf, '[')
+	for i := range orders {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = AppendOrder(buf, &orders[i])
+	}
+	return append(buf, ']')
+}
+
+// AppendOrder 编码单个订单，字段顺序与 models.OrderAnalysis 一致
+func AppendOrder(buf []byte, o *models.OrderAnalysis) []byte {
+	buf = append(buf, `{"order_id":`...)
+	buf = strconv.AppendInt(buf, int64(o.OrderID), 10)
+	buf = append(buf, `,"order_number":`...)
+	buf = AppendString(buf, o.OrderNumber)
+	buf = append(buf, `,"amount":`...)
+	buf = appendDecimal(buf, o.Amount)
+	buf = append(buf, `,"currency":`...)
+	buf = AppendString(buf, o.Currency)
+	buf = append(buf, `,"status":`...)
+	buf = AppendString(buf, o.Status)
+	buf = append(buf, `,"merchant_id":`...)
+	buf = strconv.AppendInt(buf, int64(o.MerchantID), 10)
+	buf = append(buf, `,"merchant_name":`...)
+	buf = AppendString(buf, o.MerchantName)
+	buf = append(buf, `,"timezone":`...)
+	buf = AppendString(buf, o.Timezone)
+	buf = append(buf, `,"country":`...)
+	buf = AppendString(buf, o.Country)
+	buf = append(buf, `,"city":`...)
+	buf = AppendString(buf, o.City)
+	buf = append(buf, `,"order_time_utc":`...)
+	buf = appendTime(buf, o.OrderTimeUTC)
+	buf = append(buf, `,"order_time_local":`...)
+	buf = appendTime(buf, o.OrderTimeLocal)
+	buf = append(buf, `,"local_date":`...)
+	buf = AppendString(buf, o.LocalDate)
+	buf = append(buf, `,"local_hour":`...)
+	buf = strconv.AppendInt(buf, int64(o.LocalHour), 10)
+	buf = append(buf, `,"local_day_of_week":`...)
+	buf = strconv.AppendInt(buf, int64(o.LocalDayOfWeek), 10)
+	buf = append(buf, `,"local_weekday":`...)
+	buf = AppendString(buf, o.LocalWeekday)
+	buf = append(buf, `,"is_weekend":`...)
+	buf = strconv.AppendBool(buf, o.IsWeekend)
+	buf = append(buf, `,"is_business_hour":`...)
+	buf = strconv.AppendBool(buf, o.IsBusinessHour)
+	buf = append(buf, `,"weekend_days":`...)
+	buf = appendInts(buf, o.WeekendDays)
+	if o.LocalWeekdayName != "" {
+		buf = append(buf, `,"local_weekday_name":`...)
+		buf = AppendString(buf, o.LocalWeekdayName)
+	}
+	if o.LocalDateDisplay != "" {
+		buf = append(buf, `,"local_date_display":`...)
+		buf = AppendString(buf, o.LocalDateDisplay)
+	}
+	if o.AmountDisplay != "" {
+		buf = append(buf, `,"amount_display":`...)
+		buf = AppendString(buf, o.AmountDisplay)
+	}
+	if o.OrderTimeHumanized != "" {
+		buf = append(buf, `,"order_time_humanized":`...)
+		buf = AppendString(buf, o.OrderTimeHumanized)
+	}
+	buf = append(buf, `,"timezone_offset":`...)
+	buf = strconv.AppendInt(buf, int64(o.TimezoneOffset), 10)
+	buf = append(buf, `,"ingested_at":`...)
+	buf = appendTime(buf, o.IngestedAt)
+	if o.Links != nil {
+		buf = append(buf, `,"links":{"self":`...)
+		buf = AppendString(buf, o.Links.Self)
+		buf = append(buf, `,"merchant":`...)
+		buf = AppendString(buf, o.Links.Merchant)
+		buf = append(buf, `,"merchant_orders":`...)
+		buf = AppendString(buf, o.Links.MerchantOrders)
+		buf = append(buf, `,"analysis":`...)
+		buf = AppendString(buf, o.Links.Analysis)
+		buf = append(buf, `,"refunds":`...)
+		buf = AppendString(buf, o.Links.Refunds)
+		buf = append(buf, `,"attributes":`...)
+		buf = AppendString(buf, o.Links.Attributes)
+		buf = append(buf, `,"timezone_compare":`...)
+		buf = AppendString(buf, o.Links.TimezoneCompare)
+		buf = append(buf, '}')
+	}
+	return append(buf, '}')
+}
+
+// AppendAnalysisBatch 编码多个日期的分析结果，nil 编码为 null
+func AppendAnalysisBatch(buf []byte, batch []models.AnalysisData) []byte {
+	if batch == nil {
+		return append(buf, "null"...)
+	}
+	buf = append(buf, '[')
+	for i := range batch {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = AppendAnalysis(buf, &batch[i])
+	}
+	return append(buf, ']')
+}
+
+// AppendAnalysis 编码分析结果，字段顺序与 models.AnalysisData 一致
+func AppendAnalysis(buf []byte, a *models.AnalysisData) []byte {
+	buf = append(buf, `{"date":`...)
+	buf = AppendString(buf, a.Date)
+	if a.Source != "" {
+		buf = append(buf, `,"source":`...)
+		buf = AppendString(buf, a.Source)
+	}
+	buf = append(buf, `,"partial":`...)
+	buf = strconv.AppendBool(buf, a.Partial)
+	if len(a.OmittedSections) > 0 {
+		buf = append(buf, `,"omitted_sections":`...)
+		buf = appendStrings(buf, a.OmittedSections)
+	}
+	if a.QueryMode != "" {
+		buf = append(buf, `,"query_mode":`...)
+		buf = AppendString(buf, a.QueryMode)
+	}
+	buf = append(buf, `,"time_basis":`...)
+	buf = AppendString(buf, a.TimeBasis)
+	if a.AggregateTimezone != "" {
+		buf = append(buf, `,"aggregate_timezone":`...)
+		buf = AppendString(buf, a.AggregateTimezone)
+	}
+	if a.WindowStartUTC != nil {
+		buf = append(buf, `,"window_start_utc":`...)
+		buf = appendTime(buf, *a.WindowStartUTC)
+	}
+	if a.WindowEndUTC != nil {
+		buf = append(buf, `,"window_end_utc":`...)
+		buf = appendTime(buf, *a.WindowEndUTC)
+	}
+	buf = append(buf, `,"total_orders":`...)
+	buf = strconv.AppendInt(buf, int64(a.TotalOrders), 10)
+	buf = append(buf, `,"statuses":`...)
+	buf = appendStrings(buf, a.Statuses)
+	buf = append(buf, `,"revenue_definition":{"excluded_statuses":`...)
+	buf = appendStrings(buf, a.Revenue.ExcludedStatuses)
+	buf = append(buf, `,"subtract_refunds":`...)
+	buf = strconv.AppendBool(buf, a.Revenue.SubtractRefunds)
+	buf = append(buf, `,"refund_attribution":`...)
+	buf = AppendString(buf, a.Revenue.RefundAttribution)
+	buf = append(buf, '}')
+	if a.Currency != "" {
+		buf = append(buf, `,"currency":`...)
+		buf = AppendString(buf, a.Currency)
+	}
+	if a.TotalAmount != nil {
+		buf = append(buf, `,"total_amount":`...)
+		buf = appendDecimal(buf, *a.TotalAmount)
+	}
+	if a.GrossAmount != nil {
+		buf = append(buf, `,"gross_amount":`...)
+		buf = appendDecimal(buf, *a.GrossAmount)
+	}
+	if a.NetAmount != nil {
+		buf = append(buf, `,"net_amount":`...)
+		buf = appendDecimal(buf, *a.NetAmount)
+	}
+	buf = append(buf, `,"totals_by_currency":`...)
+	if a.TotalsByCurrency == nil {
+		buf = append(buf, "null"...)
+	} else {
+		buf = append(buf, '[')
+		for i := range a.TotalsByCurrency {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = appendCurrencyTotal(buf, &a.TotalsByCurrency[i])
+		}
+		buf = append(buf, ']')
+	}
+	if a.Locale != "" {
+		buf = append(buf, `,"locale":`...)
+		buf = AppendString(buf, a.Locale)
+	}
+	if a.DateDisplay != "" {
+		buf = append(buf, `,"date_display":`...)
+		buf = AppendString(buf, a.DateDisplay)
+	}
+	if a.TotalAmountDisplay != "" {
+		buf = append(buf, `,"total_amount_display":`...)
+		buf = AppendString(buf, a.TotalAmountDisplay)
+	}
+	buf = append(buf, `,"hourly_breakdown":`...)
+	if a.HourlyBreakdown == nil {
+		buf = append(buf, "null"...)
+	} else {
+		buf = append(buf, '[')
+		for i, h := range a.HourlyBreakdown {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, `{"hour":`...)
+			buf = strconv.AppendInt(buf, int64(h.Hour), 10)
+			buf = append(buf, `,"order_count":`...)
+			buf = strconv.AppendInt(buf, int64(h.OrderCount), 10)
+			buf = appendAmounts(buf, h.Currency, h.TotalAmount, h.AvgAmount)
+		}
+		buf = append(buf, ']')
+	}
+	buf = append(buf, `,"timezone_stats":`...)
+	if a.TimezoneStats == nil {
+		buf = append(buf, "null"...)
+	} else {
+		buf = append(buf, '[')
+		for i, t := range a.TimezoneStats {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, `{"timezone":`...)
+			buf = AppendString(buf, t.Timezone)
+			buf = append(buf, `,"country":`...)
+			buf = AppendString(buf, t.Country)
+			buf = append(buf, `,"order_count":`...)
+			buf = strconv.AppendInt(buf, int64(t.OrderCount), 10)
+			buf = appendAmounts(buf, t.Currency, t.TotalAmount, t.AvgAmount)
+		}
+		buf = append(buf, ']')
+	}
+	buf = append(buf, `,"top_merchants":`...)
+	if a.TopMerchants == nil {
+		buf = append(buf, "null"...)
+	} else {
+		buf = append(buf, '[')
+		for i, m := range a.TopMerchants {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, `{"merchant_id":`...)
+			buf = strconv.AppendInt(buf, int64(m.MerchantID), 10)
+			buf = append(buf, `,"merchant_name":`...)
+			buf = AppendString(buf, m.MerchantName)
+			buf = append(buf, `,"timezone":`...)
+			buf = AppendString(buf, m.Timezone)
+			buf = append(buf, `,"order_count":`...)
+			buf = strconv.AppendInt(buf, int64(m.OrderCount), 10)
+			buf = appendAmounts(buf, m.Currency, m.TotalAmount, m.AvgAmount)
+		}
+		buf = append(buf, ']')
+	}
+	if a.GroupBy != "" {
+		buf = append(buf, `,"group_by":`...)
+		buf = AppendString(buf, a.GroupBy)
+	}
+	if len(a.Groups) > 0 {
+		buf = append(buf, `,"groups":[`...)
+		for i, g := range a.Groups {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, `{"value":`...)
+			if g.Value == nil {
+				buf = append(buf, "null"...)
+			} else {
+				buf = AppendString(buf, *g.Value)
+			}
+			buf = append(buf, `,"currency":`...)
+			buf = AppendString(buf, g.Currency)
+			buf = append(buf, `,"order_count":`...)
+			buf = strconv.AppendInt(buf, int64(g.OrderCount), 10)
+			buf = append(buf, `,"gross_amount":`...)
+			buf = appendDecimal(buf, g.GrossAmount)
+			buf = append(buf, '}')
+		}
+		buf = append(buf, ']')
+	}
+	return append(buf, '}')
+}
+
+// appendCurrencyTotal 编码单一币种的合计
+func appendCurrencyTotal(buf []byte, t *models.CurrencyTotal) []byte {
+	buf = append(buf, `{"currency":`...)
+	buf = AppendString(buf, t.Currency)
+	buf = append(buf, `,"order_count":`...)
+	buf = strconv.AppendInt(buf, int64(t.OrderCount), 10)
+	buf = append(buf, `,"total_amount":`...)
+	buf = appendDecimal(buf, t.TotalAmount)
+	buf = append(buf, `,"gross_amount":`...)
+	buf = appendDecimal(buf, t.GrossAmount)
+	buf = append(buf, `,"refund_amount":`...)
+	buf = appendDecimal(buf, t.RefundAmount)
+	buf = append(buf, `,"refund_by_order_date":`...)
+	buf = appendDecimal(buf, t.RefundByOrderDate)
+	buf = append(buf, `,"refund_by_refund_date":`...)
+	buf = appendDecimal(buf, t.RefundByRefundDate)
+	buf = append(buf, `,"net_amount":`...)
+	buf = appendDecimal(buf, t.NetAmount)
+	if t.TotalAmountDisplay != "" {
+		buf = append(buf, `,"total_amount_display":`...)
+		buf = AppendString(buf, t.TotalAmountDisplay)
+	}
+	return append(buf, '}')
+}
+
+// appendAmounts 小时分解、时区统计和商户排行共有的末尾字段：currency（omitempty）、total_amount、avg_amount，并结束对象
+func appendAmounts(buf []byte, currency string, total, avg decimal.Decimal) []byte {
+	if currency != "" {
+		buf = append(buf, `,"currency":`...)
+		buf = AppendString(buf, currency)
+	}
+	buf = append(buf, `,"total_amount":`...)
+	buf = appendDecimal(buf, total)
+	buf = append(buf, `,"avg_amount":`...)
+	buf = appendDecimal(buf, avg)
+	return append(buf, '}')
+}
+
+// appendDecimal 与 decimal.Decimal.MarshalJSON 一致：默认编码为字符串，设置了 MarshalJSONWithoutQuotes 时编码为数字
+func appendDecimal(buf []byte, d decimal.Decimal) []byte {
+	if decimal.MarshalJSONWithoutQuotes {
+		return append(buf, d.String()...)
+	}
+	buf = append(buf, '"')
+	buf = append(buf, d.String()...)
+	return append(buf, '"')
+}
+
+// appendTime 与 time.Time.MarshalJSON 一致，编码为 RFC 3339 字符串
+func appendTime(buf []byte, t time.Time) []byte {
+	buf = append(buf, '"')
+	buf = t.AppendFormat(buf, time.RFC3339Nano)
+	return append(buf, '"')
+}
+
+// appendStrings 编码字符串切片，nil 编码为 null
+func appendStrings(buf []byte, values []string) []byte {
+	if values == nil {
+		return append(buf, "null"...)
+	}
+	buf = append(buf, '[')
+	for i, v := range values {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = AppendString(buf, v)
+	}
+	return append(buf, ']')
+}
+
+// appendInts 编码整数切片，nil 编码为 null
+func appendInts(buf []byte, values []int) []byte {
+	if values == nil {
+		return append(buf, "null"...)
+	}
+	buf = append(buf, '[')
+	for i, v := range values {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = strconv.AppendInt(buf, int64(v), 10)
+	}
+	return append(buf, ']')
+}
+
+const hex = "0123456789abcdef"
+
+// AppendString 按 encoding/json 的规则编码字符串：转义 HTML 字符 <、>、&，无效的 UTF-8 替换为 U+FFFD，
+// U+2028、U+2029 转义为 \u2028、\u2029
+func AppendString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			buf = append(buf, s[start:i]...)
+			switch b {
+			case '\\', '"':
+				buf = append(buf, '\\', b)
+			case '\b':
+				buf = append(buf, '\\', 'b')
+			case '\f':
+				buf = append(buf, '\\', 'f')
+			case '\n':
+				buf = append(buf, '\\', 'n')
+			case '\r':
+				buf = append(buf, '\\', 'r')
+			case '\t':
+				buf = append(buf, '\\', 't')
+			default:
+				buf = append(buf, '\\', 'u', '0', '0', hex[b>>4], hex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, '\\', 'u', '2', '0', '2', hex[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf = append(buf, s[start:]...)
+	return append(buf, '"')
+}
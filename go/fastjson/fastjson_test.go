@@ -0,0 +1,180 @@
+package fastjson_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"timezone-saas-demo/fastjson"
+	"timezone-saas-demo/locale"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/services"
+	"timezone-saas-demo/testsupport"
+)
+
+// TestMarshalMatchesEncodingJSON 各种边界输入的编码与 encoding/json 逐字节一致：
+// HTML 字符、控制字符、U+2028/U+2029、无效的 UTF-8、nil 与空切片、omitempty 字段和各种金额
+func TestMarshalMatchesEncodingJSON(t *testing.T) {
+	strs := map[string]string{
+		"HTML 字符":        `<script>alert("x&y")</script>`,
+		"控制字符":           "a\x00\x01\b\f\n\r\t\x1f\x7f\"\\z",
+		"行分隔符和段分隔符":      "before\u2028middle\u2029after",
+		"无效的 UTF-8":      "ok\xffbad\xc3(\xe2\x82end\xed\xa0\x80",
+		"多字节字符":          "东京 🍣 café",
+		"空字符串":           "",
+		"只有需要转义的字符":      "<>&\"\\",
+		"结尾的无效 UTF-8":    "tail\xe4\xb8",
+		"U+FFFD 原样":      "\ufffd",
+		"非 HTML 的 ASCII": "plain text 123 !#$%'()*+,-./:;=?@[]^_`{|}~",
+	}
+	for name, s := range strs {
+		t.Run("字符串/"+name, func(t *testing.T) {
+			want, err := json.Marshal(s)
+			if err != nil {
+				t.Fatalf("encoding/json 编码失败: %v", err)
+			}
+			if got := fastjson.AppendString(nil, s); !bytes.Equal(got, want) {
+				t.Errorf("AppendString(%q) = %s, 期望 %s", s, got, want)
+			}
+		})
+	}
+
+	at := time.Date(2024, 3, 10, 1, 30, 0, 123456000, time.FixedZone("", -5*3600))
+	windowStart, windowEnd := at.UTC(), at.UTC().Add(24*time.Hour)
+	amount := func(s string) decimal.Decimal { return decimal.RequireFromString(s) }
+	total := amount("-0.10")
+	tag := "<vip>"
+	full := models.OrderAnalysis{
+		OrderID: 1, OrderNumber: "A&B<1>", Amount: amount("1234567.8900"), Currency: "JPY", Status: "paid",
+		MerchantID: 7, MerchantName: "东京\u2028寿司", Timezone: "Asia/Tokyo", Country: "日本", City: "东京",
+		OrderTimeUTC: at.UTC(), OrderTimeLocal: at, LocalDate: "2024-03-10", LocalHour: 1, LocalDayOfWeek: 0,
+		LocalWeekday: "Sunday", IsWeekend: true, IsBusinessHour: false, WeekendDays: []int{0, 6},
+		LocalWeekdayName: "星期日", LocalDateDisplay: "2024年3月10日", AmountDisplay: "¥1,234,568", OrderTimeHumanized: "3 小时前",
+		TimezoneOffset: -300, IngestedAt: at.Add(time.Minute),
+		Links: &models.OrderLinks{Self: "/api/orders/1?a=1&b=<2>", Merchant: "/m/7", MerchantOrders: "/o", Analysis: "/a", Refunds: "/r", Attributes: "/t", TimezoneCompare: "/c"},
+	}
+	analysis := models.AnalysisData{
+		Date: "2024-03-10", Source: "snapshot", Partial: true, OmittedSections: []string{"top_merchants"}, QueryMode: "single",
+		TimeBasis: "aggregate_timezone", AggregateTimezone: "America/Havana", WindowStartUTC: &windowStart, WindowEndUTC: &windowEnd,
+		TotalOrders: 3, Statuses: []string{"paid", "refunded"},
+		Revenue:  models.RevenueDefinition{ExcludedStatuses: []string{"cancelled"}, SubtractRefunds: true, RefundAttribution: "order_date"},
+		Currency: "USD", TotalAmount: &total, GrossAmount: &total, NetAmount: &total,
+		TotalsByCurrency: []models.CurrencyTotal{{Currency: "USD", OrderCount: 3, TotalAmount: amount("0"), GrossAmount: amount("1e3"), NetAmount: amount("999.999")}},
+		Locale:           "zh", DateDisplay: "3月10日", TotalAmountDisplay: "-$0.10",
+		HourlyBreakdown: []models.HourlyOrderBreakdown{{Hour: 0, OrderCount: 1, Currency: "USD", TotalAmount: amount("0.005"), AvgAmount: amount("0.0050")}, {Hour: 23}},
+		TimezoneStats:   []models.TimezoneOrderStats{{Timezone: "America/Havana", Country: "古巴", OrderCount: 3, TotalAmount: amount("12.30")}},
+		TopMerchants:    []models.MerchantOrderStats{{MerchantID: 1, MerchantName: "<Café>", Timezone: "UTC", OrderCount: 1, Currency: "EUR", TotalAmount: amount("-1"), AvgAmount: amount("-1")}},
+		GroupBy:         "tag:tier", Groups: []models.AttributeGroupTotal{{Value: &tag, Currency: "USD", OrderCount: 1, GrossAmount: amount("5")}, {Currency: "USD", GrossAmount: amount("0.1")}},
+	}
+
+	values := map[string]interface{}{
+		"订单全部字段":              full,
+		"订单零值":                models.OrderAnalysis{},
+		"订单空切片":               models.OrderAnalysis{WeekendDays: []int{}},
+		"订单指针":                &full,
+		"nil 订单指针":            (*models.OrderAnalysis)(nil),
+		"订单列表":                []models.OrderAnalysis{full, {}},
+		"nil 订单列表":            []models.OrderAnalysis(nil),
+		"空订单列表":               []models.OrderAnalysis{},
+		"分析全部字段":              analysis,
+		"分析零值":                models.AnalysisData{},
+		"分析空切片（omitempty 省略）": models.AnalysisData{OmittedSections: []string{}, Statuses: []string{}, TotalsByCurrency: []models.CurrencyTotal{}, HourlyBreakdown: []models.HourlyOrderBreakdown{}, TimezoneStats: []models.TimezoneOrderStats{}, TopMerchants: []models.MerchantOrderStats{}, Groups: []models.AttributeGroupTotal{}},
+		"nil 分析指针":            (*models.AnalysisData)(nil),
+		"分析批量结果":              []models.AnalysisData{analysis, {}},
+		"nil 分析批量结果":          []models.AnalysisData(nil),
+	}
+	for _, withoutQuotes := range []bool{false, true} {
+		prev := decimal.MarshalJSONWithoutQuotes
+		decimal.MarshalJSONWithoutQuotes = withoutQuotes
+		for name, v := range values {
+			t.Run(fmt.Sprintf("%s/金额不加引号=%v", name, withoutQuotes), func(t *testing.T) {
+				want, err := json.Marshal(v)
+				if err != nil {
+					t.Fatalf("encoding/json 编码失败: %v", err)
+				}
+				got, ok := fastjson.Marshal(v)
+				if !ok {
+					t.Fatalf("fastjson 不支持 %T", v)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("两种编码的输出不一致:\nencoding/json: %s\nfastjson:      %s", want, got)
+				}
+			})
+		}
+		decimal.MarshalJSONWithoutQuotes = prev
+	}
+
+	// 不支持的类型（如 map）返回 ok=false，调用方回退到 encoding/json
+	for _, v := range []interface{}{map[string]interface{}{"a": 1}, map[string]string(nil), []string{"a"}, nil} {
+		if buf, ok := fastjson.Append([]byte("x"), v); ok || string(buf) != "x" {
+			t.Errorf("Append(%T) = %q, %v, 期望原样返回且 ok=false", v, buf, ok)
+		}
+	}
+}
+
+// benchOrders 与 bench-json 子命令默认规模相同的订单列表，带展示字段和相对时间
+const benchOrders = 5000
+
+// BenchmarkEncodeOrders 对比 encoding/json 与 fastjson 编码订单列表
+func BenchmarkEncodeOrders(b *testing.B) {
+	fakes := testsupport.NewMockFakes(testsupport.MockOptions{Seed: 1, Days: benchOrders/100 + 1})
+	orders, err := fakes.Service().GetOrders(models.OrderFilter{Limit: benchOrders})
+	if err != nil {
+		b.Fatalf("读取模拟订单失败: %v", err)
+	}
+	l := locale.For("zh")
+	services.LocalizeOrders(orders, l)
+	services.HumanizeOrders(orders, l, testsupport.DefaultMockEndDate.AddDate(0, 0, 1))
+	benchmarkEncode(b, orders)
+}
+
+// BenchmarkEncodeAnalysis 对比 encoding/json 与 fastjson 编码一天的分析结果
+func BenchmarkEncodeAnalysis(b *testing.B) {
+	fakes := testsupport.NewMockFakes(testsupport.MockOptions{Seed: 1})
+	analysis, err := fakes.Service().GetAnalysisData(context.Background(), "2024-08-19", "", nil)
+	if err != nil {
+		b.Fatalf("读取模拟分析数据失败: %v", err)
+	}
+	services.LocalizeAnalysis(analysis, locale.For("zh"))
+	benchmarkEncode(b, *analysis)
+}
+
+// benchmarkEncode 先校验两种编码的输出逐字节一致，再分别计时
+func benchmarkEncode(b *testing.B, v interface{}) {
+	want, err := json.Marshal(v)
+	if err != nil {
+		b.Fatalf("encoding/json 编码失败: %v", err)
+	}
+	got, ok := fastjson.Marshal(v)
+	if !ok {
+		b.Fatalf("fastjson 不支持 %T", v)
+	}
+	if !bytes.Equal(want, got) {
+		b.Fatalf("两种编码的输出不一致:\nencoding/json: %.200s\nfastjson:      %.200s", want, got)
+	}
+
+	b.Run("encoding_json", func(b *testing.B) {
+		b.SetBytes(int64(len(want)))
+		b.ReportAllocs()
+		var buf bytes.Buffer
+		for i := 0; i < b.N; i++ {
+			buf.Reset()
+			if err := json.NewEncoder(&buf).Encode(v); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("fastjson", func(b *testing.B) {
+		b.SetBytes(int64(len(want)))
+		b.ReportAllocs()
+		buf := make([]byte, 0, len(want))
+		for i := 0; i < b.N; i++ {
+			buf, _ = fastjson.Append(buf[:0], v)
+		}
+	})
+}
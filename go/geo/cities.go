@@ -0,0 +1,212 @@
+// Package geo 提供内置的国家和城市参考数据，用于商户入驻时根据国家、城市或地址推断 IANA 时区。
+// 数据集 countries.csv、cities.csv 随程序一起编译，不依赖外部服务；
+// sql/19_reference_data.sql 中的 dim_country / dim_city 由同样的数据生成。
+package geo
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed cities.csv
+var citiesCSV string
+
+// City 数据集中的一个城市
+type City struct {
+	// ID 城市在 cities.csv 中的数据行号（从 1 开始），与 dim_city.city_id 一致；数据集只追加，已有城市的 ID 不变
+	ID          int
+	CountryCode string
+	Country     string
+	CountryZH   string
+	Name        string
+	NameZH      string
+	Timezone    string
+	Lat         float64
+	Lon         float64
+}
+
+// Match 时区推断结果
+type Match struct {
+	City City
+	// MatchedBy 命中方式：city 为城市匹配，country 为国家匹配（该国只有一个时区）
+	MatchedBy string
+	// Confidence 可信度：城市且国家一致为 1，仅城市为 0.9，仅国家（单时区国家）为 0.8
+	Confidence float64
+}
+
+var cities = mustParseCities(citiesCSV)
+
+// Cities 返回数据集中的所有城市
+func Cities() []City {
+	return append([]City(nil), cities...)
+}
+
+// mustParseCities 解析内置数据集，格式错误属于构建问题，直接 panic
+func mustParseCities(data string) []City {
+	records, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		panic(fmt.Sprintf("解析城市数据集失败: %v", err))
+	}
+
+	var result []City
+	for i, record := range records {
+		if i == 0 {
+			continue
+		}
+		lat, err1 := strconv.ParseFloat(record[6], 64)
+		lon, err2 := strconv.ParseFloat(record[7], 64)
+		if err1 != nil || err2 != nil {
+			panic(fmt.Sprintf("城市数据集第 %d 行坐标无效", i+1))
+		}
+		result = append(result, City{
+			ID:          i,
+			CountryCode: record[0],
+			Country:     record[1],
+			CountryZH:   record[2],
+			Name:        record[3],
+			NameZH:      record[4],
+			Timezone:    record[5],
+			Lat:         lat,
+			Lon:         lon,
+		})
+	}
+	return result
+}
+
+// Suggest 根据国家和城市推断时区，按可信度从高到低返回，同一时区只保留一条
+// 国家和城市均支持中文名、英文名，国家还支持 ISO 两位代码，大小写和首尾空格不敏感
+func Suggest(country, city string) []Match {
+	country, city = normalize(country), normalize(city)
+
+	var matches []Match
+	if city != "" {
+		for _, c := range cities {
+			if !c.matchesCity(city) {
+				continue
+			}
+			switch {
+			case country == "":
+				matches = append(matches, Match{City: c, MatchedBy: "city", Confidence: 0.9})
+			case c.matchesCountry(country):
+				matches = append(matches, Match{City: c, MatchedBy: "city", Confidence: 1})
+			}
+		}
+	}
+
+	// 城市未命中时，只有单一时区的国家才能按国家推断
+	if len(matches) == 0 && country != "" {
+		zones := map[string]City{}
+		for _, c := range cities {
+			if c.matchesCountry(country) {
+				if _, ok := zones[c.Timezone]; !ok {
+					zones[c.Timezone] = c
+				}
+			}
+		}
+		if len(zones) == 1 {
+			for _, c := range zones {
+				matches = append(matches, Match{City: c, MatchedBy: "country", Confidence: 0.8})
+			}
+		}
+	}
+
+	return dedupe(matches)
+}
+
+// SuggestFromAddress 从自由格式地址中识别国家和城市并推断时区
+// 地址中出现的最长城市名优先，如"美国 旧金山 市场街 1 号"、"1 Market St, San Francisco, US"
+func SuggestFromAddress(address string) []Match {
+	text := normalize(address)
+	if text == "" {
+		return nil
+	}
+
+	var matches []Match
+	lengths := map[string]int{}
+	for _, c := range cities {
+		name := longestContained(text, c.Name, c.NameZH)
+		if name == "" {
+			continue
+		}
+		confidence := 0.9
+		if containsCountry(text, c) {
+			confidence = 1
+		}
+		lengths[c.Name] = len(name)
+		matches = append(matches, Match{City: c, MatchedBy: "city", Confidence: confidence})
+	}
+	if len(matches) > 0 {
+		// 可信度相同时，匹配到的名称越长越不容易误判
+		sort.SliceStable(matches, func(i, j int) bool { return lengths[matches[i].City.Name] > lengths[matches[j].City.Name] })
+		return dedupe(matches)
+	}
+
+	for _, c := range cities {
+		if containsCountry(text, c) {
+			return Suggest(c.Country, "")
+		}
+	}
+	return nil
+}
+
+// matchesCity 城市名是否一致
+func (c City) matchesCity(name string) bool {
+	return name == normalize(c.Name) || name == normalize(c.NameZH)
+}
+
+// matchesCountry 国家名或代码是否一致
+func (c City) matchesCountry(name string) bool {
+	return name == normalize(c.CountryCode) || name == normalize(c.Country) || name == normalize(c.CountryZH)
+}
+
+// containsCountry 地址中是否包含城市所在国家的名称
+// 两位代码只在作为独立词出现时计入，避免误匹配单词片段
+func containsCountry(text string, c City) bool {
+	if longestContained(text, c.Country, c.CountryZH) != "" {
+		return true
+	}
+	code := normalize(c.CountryCode)
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ' ' || r == '，' }) {
+		if word == code {
+			return true
+		}
+	}
+	return false
+}
+
+// longestContained 返回 names 中出现在 text 里的最长名称
+func longestContained(text string, names ...string) string {
+	best := ""
+	for _, name := range names {
+		name = normalize(name)
+		if name != "" && strings.Contains(text, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	return best
+}
+
+// dedupe 按可信度排序，同一时区只保留可信度最高的一条
+func dedupe(matches []Match) []Match {
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Confidence > matches[j].Confidence })
+
+	seen := map[string]bool{}
+	var result []Match
+	for _, m := range matches {
+		if seen[m.City.Timezone] {
+			continue
+		}
+		seen[m.City.Timezone] = true
+		result = append(result, m)
+	}
+	return result
+}
+
+// normalize 去除首尾空格并转为小写
+func normalize(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
+}
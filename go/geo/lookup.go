@@ -0,0 +1,104 @@
+package geo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrInvalidCoordinates 经纬度超出范围
+var ErrInvalidCoordinates = errors.New("无效的经纬度")
+
+// 坐标查询来源
+const (
+	// SourceNearestCity 取数据集中距离最近的城市的时区
+	SourceNearestCity = "nearest_city"
+	// SourceNautical 附近没有已知城市（如海上），按经度取航海时区 Etc/GMT±N
+	SourceNautical = "nautical"
+)
+
+// earthRadiusKm 地球平均半径
+const earthRadiusKm = 6371.0
+
+// Result 坐标查询结果
+type Result struct {
+	Timezone string
+	Source   string
+	// City 最近的城市，航海时区时为 nil
+	City       *City
+	DistanceKm float64
+}
+
+// Provider 坐标→时区查询，可替换为基于时区边界多边形或外部地理编码服务的实现
+type Provider interface {
+	Lookup(lat, lon float64) (Result, error)
+}
+
+// NearestCityProvider 基于内置城市数据集的查询：取最近城市的时区
+// 数据集只包含主要城市，国境线附近可能不准确，适合作为入驻时的默认建议
+type NearestCityProvider struct {
+	cities []City
+	// MaxDistanceKm 最近城市超过该距离时改用航海时区，0 表示不限制
+	MaxDistanceKm float64
+}
+
+// NewNearestCityProvider 创建最近城市查询
+func NewNearestCityProvider(cities []City, maxDistanceKm float64) *NearestCityProvider {
+	return &NearestCityProvider{cities: cities, MaxDistanceKm: maxDistanceKm}
+}
+
+// DefaultProvider 默认查询，使用内置数据集，500 公里内没有已知城市时返回航海时区
+var DefaultProvider Provider = NewNearestCityProvider(cities, 500)
+
+// Lookup 查询坐标所在时区
+func (p *NearestCityProvider) Lookup(lat, lon float64) (Result, error) {
+	if err := ValidateCoordinates(lat, lon); err != nil {
+		return Result{}, err
+	}
+
+	var nearest *City
+	best := math.Inf(1)
+	for i := range p.cities {
+		if d := DistanceKm(lat, lon, p.cities[i].Lat, p.cities[i].Lon); d < best {
+			best, nearest = d, &p.cities[i]
+		}
+	}
+
+	if nearest == nil || (p.MaxDistanceKm > 0 && best > p.MaxDistanceKm) {
+		return Result{Timezone: NauticalZone(lon), Source: SourceNautical}, nil
+	}
+	city := *nearest
+	return Result{Timezone: city.Timezone, Source: SourceNearestCity, City: &city, DistanceKm: best}, nil
+}
+
+// ValidateCoordinates 校验纬度 [-90, 90]、经度 [-180, 180]
+func ValidateCoordinates(lat, lon float64) error {
+	if math.IsNaN(lat) || math.IsNaN(lon) || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return fmt.Errorf("%w: lat=%v lon=%v", ErrInvalidCoordinates, lat, lon)
+	}
+	return nil
+}
+
+// DistanceKm 两点间的大圆距离（haversine）
+func DistanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// NauticalZone 按经度返回航海时区，每 15 度一个小时
+// 注意 IANA 的 Etc/GMT 符号与常规相反：Etc/GMT-8 表示 UTC+8
+func NauticalZone(lon float64) string {
+	offset := int(math.Round(lon / 15))
+	switch {
+	case offset == 0:
+		return "Etc/GMT"
+	case offset > 0:
+		return fmt.Sprintf("Etc/GMT-%d", offset)
+	default:
+		return fmt.Sprintf("Etc/GMT+%d", -offset)
+	}
+}
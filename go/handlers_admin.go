@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"timezone-saas-demo/services"
+)
+
+// adminTokenMu 保护 adminToken，密钥轮换时会替换令牌
+var adminTokenMu sync.RWMutex
+
+// currentAdminToken 当前生效的管理令牌
+func currentAdminToken() string {
+	adminTokenMu.RLock()
+	defer adminTokenMu.RUnlock()
+	return adminToken
+}
+
+// setAdminToken 替换管理令牌
+func setAdminToken(token string) {
+	adminTokenMu.Lock()
+	defer adminTokenMu.Unlock()
+	adminToken = token
+}
+
+// adminMiddleware 校验 Authorization: Bearer <ADMIN_TOKEN>，未配置令牌时管理接口一律拒绝
+func adminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !requireAdmin(w, r) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAdmin 校验管理令牌，失败时写入错误响应并返回 false；用于只有部分参数需要管理员权限的公开接口
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	adminToken := currentAdminToken()
+	if adminToken == "" {
+		respondError(w, r, http.StatusForbidden, "admin.disabled", errors.New("未设置 ADMIN_TOKEN"))
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+		respondError(w, r, http.StatusUnauthorized, "admin.unauthorized", errors.New("管理令牌无效"))
+		return false
+	}
+	return true
+}
+
+// getSlowQueries 最近的慢查询，按耗时倒序；limit 默认 20
+func getSlowQueries(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+
+	queries := db.SlowQueries(limit)
+	respondSuccess(w, r, http.StatusOK, "admin.slow_queries", queries, len(queries))
+}
+
+// getPlanRegressions 最近一个统计周期比上一个周期变慢超过阈值的语句，含两个周期的执行计划以便比较
+func getPlanRegressions(w http.ResponseWriter, r *http.Request) {
+	if planRegressionService == nil {
+		respondError(w, r, http.StatusForbidden, "admin.plan_regressions_disabled", errors.New("执行计划回归检测未启用（mock 模式或 PLAN_REGRESSION_INTERVAL=0）"))
+		return
+	}
+
+	report, err := planRegressionService.Report(r.Context())
+	if err != nil {
+		respondError(w, r, errorStatus(err), "admin.plan_regressions_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "admin.plan_regressions", report, len(report.Regressions))
+}
+
+// getCircuitBreaker 数据库熔断器状态，未启用（CIRCUIT_BREAKER_THRESHOLD=0 或 mock 模式）时 enabled 为 false
+func getCircuitBreaker(w http.ResponseWriter, r *http.Request) {
+	status := db.CircuitBreakerStatus()
+	respondSuccess(w, r, http.StatusOK, "admin.circuit_breaker", status, status.State)
+}
+
+// getIndexAdvice 订单和商户表的索引建议，只给出建议语句，不会执行
+func getIndexAdvice(w http.ResponseWriter, r *http.Request) {
+	report, err := indexAdvisorService.Advise(r.Context())
+	if err != nil {
+		respondError(w, r, errorStatus(err), "admin.index_advice_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "admin.index_advice", report, len(report.Advice))
+}
+
+// getBusinessHoursCoverage 全部商户的营业时间覆盖分析：无人营业的 UTC 小时、营业商户最多的小时，
+// 以及新增哪个时区的商户最能填补空档；date 为 UTC 日期（默认今天），limit 候选时区数量
+func getBusinessHoursCoverage(w http.ResponseWriter, r *http.Request) {
+	opts := services.CoverageOptions{Date: r.URL.Query().Get("date")}
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			opts.Limit = l
+		}
+	}
+
+	coverage, err := timezoneService.BusinessHoursCoverage(opts)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "admin.coverage_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "admin.coverage", coverage, coverage.Date, len(coverage.GapHours), len(coverage.Candidates))
+}
+
+// reloadTZData 重新加载 TZDATA_DIR 中的 zoneinfo 并清空时区缓存，无需重启即可应用新的夏令时规则
+func reloadTZData(w http.ResponseWriter, r *http.Request) {
+	info, err := services.ReloadTimezoneData()
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, "admin.tzdata_reload_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "admin.tzdata_reloaded", info, info.Source, info.EffectiveVersion())
+}
+
+// getAdminOverview 跨租户运营看板
+// sort 排序字段（merchant_id、name、orders、recent_orders、requests、error_rate、last_ingested），order 为 asc/desc；
+// window 近期订单的统计窗口，stale_after 判定 stale 的时长，error_rate 判定 high_error_rate 的 5xx 占比
+func getAdminOverview(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts := services.OverviewOptions{Sort: query.Get("sort"), Order: query.Get("order")}
+	var err error
+	if opts.Window, err = parseDurationParam("window", query.Get("window"), services.DefaultOverviewWindow); err != nil {
+		respondError(w, r, http.StatusBadRequest, "admin.overview_failed", err)
+		return
+	}
+	if opts.StaleAfter, err = parseDurationParam("stale_after", query.Get("stale_after"), services.DefaultStaleAfter); err != nil {
+		respondError(w, r, http.StatusBadRequest, "admin.overview_failed", err)
+		return
+	}
+	if rate := query.Get("error_rate"); rate != "" {
+		if opts.ErrorRate, err = strconv.ParseFloat(rate, 64); err != nil {
+			respondError(w, r, http.StatusBadRequest, "admin.overview_failed",
+				fmt.Errorf("%w: error_rate 应为 0~1 之间的小数", services.ErrInvalidArgument))
+			return
+		}
+	}
+
+	overview, err := overviewService.Overview(r.Context(), opts)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "admin.overview_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "admin.overview", overview, overview.Totals.Tenants, overview.Totals.Unhealthy)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/services"
+)
+
+// parseAlertRuleID 解析路径中的告警规则ID
+func parseAlertRuleID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%w: 无效的告警规则ID %q", services.ErrInvalidArgument, mux.Vars(r)["id"])
+	}
+	return id, nil
+}
+
+// decodeAlertRule 解析告警规则的请求体
+func decodeAlertRule(r *http.Request) (services.AlertRuleRequest, error) {
+	var req services.AlertRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return req, fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+	}
+	return req, nil
+}
+
+// listAlertRules 当前租户（X-Tenant-ID）的告警规则
+func listAlertRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := alertRuleService.Rules(r.Context())
+	if err != nil {
+		respondError(w, r, errorStatus(err), "alerts.list_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "alerts.listed", rules, len(rules))
+}
+
+// createAlertRule 为当前租户创建告警规则
+func createAlertRule(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeAlertRule(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "alerts.save_failed", err)
+		return
+	}
+	rule, err := alertRuleService.CreateRule(r.Context(), req)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "alerts.save_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusCreated, "alerts.created", rule, rule.Name)
+}
+
+// getAlertRule 单个告警规则，属于其他租户时返回 404
+func getAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, err := parseAlertRuleID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "alerts.get_failed", err)
+		return
+	}
+	rule, err := alertRuleService.Rule(r.Context(), id)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "alerts.get_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "alerts.get", rule, rule.Name)
+}
+
+// updateAlertRule 覆盖告警规则的配置，规则状态不变
+func updateAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, err := parseAlertRuleID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "alerts.save_failed", err)
+		return
+	}
+	req, err := decodeAlertRule(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "alerts.save_failed", err)
+		return
+	}
+	rule, err := alertRuleService.UpdateRule(r.Context(), id, req)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "alerts.save_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "alerts.updated", rule, rule.Name)
+}
+
+// deleteAlertRule 删除告警规则及其告警历史
+func deleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, err := parseAlertRuleID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "alerts.delete_failed", err)
+		return
+	}
+	if err := alertRuleService.DeleteRule(r.Context(), id); err != nil {
+		respondError(w, r, errorStatus(err), "alerts.delete_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "alerts.deleted", nil, id)
+}
+
+// evaluateAlertRule 立即检查告警规则，状态变化时发送通知
+func evaluateAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, err := parseAlertRuleID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "alerts.evaluate_failed", err)
+		return
+	}
+	eval, err := alertRuleService.Evaluate(r.Context(), id)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "alerts.evaluate_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "alerts.evaluated", eval, id, eval.State)
+}
+
+// listAlertEvents 当前租户的告警历史，可按 rule_id 过滤，limit 默认 50
+func listAlertEvents(w http.ResponseWriter, r *http.Request) {
+	ruleID := 0
+	if value := r.URL.Query().Get("rule_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			err = fmt.Errorf("%w: 无效的告警规则ID %q", services.ErrInvalidArgument, value)
+			respondError(w, r, errorStatus(err), "alerts.events_failed", err)
+			return
+		}
+		ruleID = id
+	}
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+
+	events, err := alertRuleService.Events(r.Context(), ruleID, limit)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "alerts.events_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "alerts.events", events, len(events))
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"timezone-saas-demo/services"
+	"timezone-saas-demo/timerange"
+)
+
+// getAnalysisBatch 一次获取多个本地日期的分析数据（日历视图）：GET /api/timezone/analysis/batch?month=2024-08
+// dates 为逗号分隔的日期或 month 为 YYYY-MM，也可以用 from/to 或 range（如 last_30_days，按 UTC 或 aggregate_tz 的今天计算）指定，
+// 最多 62 个日期；currency、status、aggregate_tz 与单日期接口相同，结果按请求的日期顺序返回
+func getAnalysisBatch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	aggregateTZ := query.Get("aggregate_tz")
+	if aggregateTZ != "" && !requireAdmin(w, r) {
+		return
+	}
+	dates, err := analysisBatchDates(r, aggregateTZ)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "analysis.failed", err)
+		return
+	}
+	statuses, err := services.ParseStatuses(query.Get("status"))
+	if err != nil {
+		respondError(w, r, errorStatus(err), "analysis.failed", err)
+		return
+	}
+
+	batch, err := timezoneService.GetAnalysisBatch(r.Context(), dates, aggregateTZ, query.Get("currency"), statuses)
+	if errors.Is(err, services.ErrOverloaded) {
+		respondError(w, r, errorStatus(err), "analysis.overloaded", err)
+		return
+	}
+	if err != nil {
+		respondError(w, r, errorStatus(err), "analysis.failed", err)
+		return
+	}
+
+	lang := negotiateLocale(w, r)
+	for i := range batch {
+		services.LocalizeAnalysis(&batch[i], lang)
+	}
+
+	respondSuccess(w, r, http.StatusOK, "analysis.batch_ok", batch, len(batch))
+}
+
+// analysisBatchDates 批量分析的日期：dates、month 或 date/from/to/range 只能指定一种
+func analysisBatchDates(r *http.Request, aggregateTZ string) ([]string, error) {
+	query := r.URL.Query()
+	if timerange.FromQuery(query).IsZero() {
+		return services.ParseAnalysisDates(query.Get("dates"), query.Get("month"))
+	}
+	if query.Get("dates") != "" || query.Get("month") != "" {
+		return nil, fmt.Errorf("%w: dates、month 和 from/to、range 只能指定一种", services.ErrInvalidArgument)
+	}
+	loc, err := aggregateLocation(aggregateTZ)
+	if err != nil {
+		return nil, err
+	}
+	rng, err := queryRange(r, loc)
+	if err != nil {
+		return nil, err
+	}
+	return rng.Dates(), nil
+}
+
+// aggregateLocation 分析接口解析相对日期使用的时区：aggregate_tz 为空时为 UTC
+func aggregateLocation(aggregateTZ string) (*time.Location, error) {
+	if aggregateTZ == "" {
+		return time.UTC, nil
+	}
+	return services.LoadLocation(aggregateTZ)
+}
+
+// getAnalysisCohorts 商户注册周群组的订单留存：GET /api/timezone/analysis/cohorts?from=2024-01-01&to=2024-03-31&weeks=12
+// from、to 为注册日期范围（商户本地日期，默认最近 12 周），weeks 为注册周之后统计的周数（最多 52），
+// week_start 为周起始日（1~7 或 monday 等，默认周一），status 与分析接口相同
+func getAnalysisCohorts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	statuses, err := services.ParseStatuses(query.Get("status"))
+	if err != nil {
+		respondError(w, r, errorStatus(err), "analysis.cohorts_failed", err)
+		return
+	}
+	weekStart, err := services.ParseWeekStart(query.Get("week_start"))
+	if err != nil {
+		respondError(w, r, errorStatus(err), "analysis.cohorts_failed", err)
+		return
+	}
+	weeks := 0
+	if value := query.Get("weeks"); value != "" {
+		if weeks, err = strconv.Atoi(value); err != nil || weeks <= 0 {
+			err = fmt.Errorf("%w: 无效的周数 %q", services.ErrInvalidArgument, value)
+			respondError(w, r, errorStatus(err), "analysis.cohorts_failed", err)
+			return
+		}
+	}
+
+	from, to, err := queryDateRange(r, time.UTC)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "analysis.cohorts_failed", err)
+		return
+	}
+
+	report, err := timezoneService.GetCohorts(r.Context(), services.CohortQuery{
+		From:      from,
+		To:        to,
+		Weeks:     weeks,
+		WeekStart: weekStart,
+		Statuses:  statuses,
+	})
+	if errors.Is(err, services.ErrOverloaded) {
+		respondError(w, r, errorStatus(err), "analysis.overloaded", err)
+		return
+	}
+	if err != nil {
+		respondError(w, r, errorStatus(err), "analysis.cohorts_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "analysis.cohorts_ok", report, len(report.Cohorts))
+}
+
+// getGlobalBusinessDay 全球营业日报告：GET /api/timezone/analysis/business-day?date=2024-08-19
+// date 为 UTC 日期（默认今天），返回每个 UTC 小时处于营业时间的商户时区数，以及各地区订单在营业时间内外的占比；
+// status 与分析接口相同
+func getGlobalBusinessDay(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	statuses, err := services.ParseStatuses(query.Get("status"))
+	if err != nil {
+		respondError(w, r, errorStatus(err), "analysis.business_day_failed", err)
+		return
+	}
+
+	date, err := queryDate(r, time.UTC, "")
+	if err != nil {
+		respondError(w, r, errorStatus(err), "analysis.business_day_failed", err)
+		return
+	}
+
+	report, err := timezoneService.GlobalBusinessDay(r.Context(), date, statuses)
+	if errors.Is(err, services.ErrOverloaded) {
+		respondError(w, r, errorStatus(err), "analysis.overloaded", err)
+		return
+	}
+	if err != nil {
+		respondError(w, r, errorStatus(err), "analysis.business_day_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "analysis.business_day_ok", report, report.Date, report.TotalTimezones)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/services"
+)
+
+// attributesEnabled mock 模式没有自定义属性服务，属性接口以及订单列表的 tag 过滤、分析的 group_by 一律拒绝
+func attributesEnabled(w http.ResponseWriter, r *http.Request) bool {
+	if attributeService == nil {
+		respondError(w, r, http.StatusForbidden, "attributes.disabled", errors.New("mock 模式不支持自定义属性"))
+		return false
+	}
+	return true
+}
+
+// listAttributeDefinitions 当前租户（X-Tenant-ID）的属性定义
+func listAttributeDefinitions(w http.ResponseWriter, r *http.Request) {
+	if !attributesEnabled(w, r) {
+		return
+	}
+	definitions, err := attributeService.Definitions(r.Context())
+	if err != nil {
+		respondError(w, r, errorStatus(err), "attributes.list_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "attributes.definitions_listed", definitions, len(definitions))
+}
+
+// putAttributeDefinition 新增或覆盖商户或订单的属性定义
+func putAttributeDefinition(w http.ResponseWriter, r *http.Request) {
+	if !attributesEnabled(w, r) {
+		return
+	}
+	var req services.AttributeDefinitionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		err = fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+		respondError(w, r, errorStatus(err), "attributes.save_failed", err)
+		return
+	}
+	vars := mux.Vars(r)
+	definition, err := attributeService.SetDefinition(r.Context(), vars["entity"], vars["key"], req)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "attributes.save_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "attributes.definition_saved", definition, definition.Entity, definition.Key)
+}
+
+// deleteAttributeDefinition 删除属性定义及已设置的取值
+func deleteAttributeDefinition(w http.ResponseWriter, r *http.Request) {
+	if !attributesEnabled(w, r) {
+		return
+	}
+	vars := mux.Vars(r)
+	if err := attributeService.DeleteDefinition(r.Context(), vars["entity"], vars["key"]); err != nil {
+		respondError(w, r, errorStatus(err), "attributes.delete_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "attributes.definition_deleted", nil, vars["entity"], vars["key"])
+}
+
+// getMerchantAttributes 商户的属性取值
+func getMerchantAttributes(w http.ResponseWriter, r *http.Request) {
+	getEntityAttributes(w, r, models.AttributeEntityMerchant)
+}
+
+// patchMerchantAttributes 修改商户的属性取值
+func patchMerchantAttributes(w http.ResponseWriter, r *http.Request) {
+	patchEntityAttributes(w, r, models.AttributeEntityMerchant)
+}
+
+// getOrderAttributes 订单的属性取值和叠加商户取值后的有效取值
+func getOrderAttributes(w http.ResponseWriter, r *http.Request) {
+	getEntityAttributes(w, r, models.AttributeEntityOrder)
+}
+
+// patchOrderAttributes 修改订单的属性取值
+func patchOrderAttributes(w http.ResponseWriter, r *http.Request) {
+	patchEntityAttributes(w, r, models.AttributeEntityOrder)
+}
+
+// parseAttributeEntityID 解析路径中的商户或订单ID
+func parseAttributeEntityID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%w: 无效的ID %q", services.ErrInvalidArgument, mux.Vars(r)["id"])
+	}
+	return id, nil
+}
+
+// getEntityAttributes 商户或订单的属性取值
+func getEntityAttributes(w http.ResponseWriter, r *http.Request, entity string) {
+	if !attributesEnabled(w, r) {
+		return
+	}
+	id, err := parseAttributeEntityID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "attributes.get_failed", err)
+		return
+	}
+	attributes, err := attributeService.Attributes(r.Context(), entity, id)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "attributes.get_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "attributes.values_found", attributes, entity, id, len(attributes.Attributes))
+}
+
+// patchEntityAttributes 只修改请求中出现的键，值为 null 时删除该键
+func patchEntityAttributes(w http.ResponseWriter, r *http.Request, entity string) {
+	if !attributesEnabled(w, r) {
+		return
+	}
+	id, err := parseAttributeEntityID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "attributes.save_failed", err)
+		return
+	}
+	var req services.AttributeValuesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		err = fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+		respondError(w, r, errorStatus(err), "attributes.save_failed", err)
+		return
+	}
+	attributes, err := attributeService.SetAttributes(r.Context(), entity, id, req)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "attributes.save_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "attributes.values_saved", attributes, entity, id, len(attributes.Attributes))
+}
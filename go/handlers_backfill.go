@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/services"
+)
+
+// backfillEnabled 分析存储不是 ClickHouse 时没有需要回填的数据，回填接口一律拒绝
+func backfillEnabled(w http.ResponseWriter, r *http.Request) bool {
+	if backfillService == nil {
+		respondError(w, r, http.StatusForbidden, "backfill.disabled", errors.New("分析存储不是 ClickHouse，本地时间字段由视图实时计算"))
+		return false
+	}
+	return true
+}
+
+// listBackfillJobs 最近的回填任务，按创建时间倒序；limit 默认 20
+func listBackfillJobs(w http.ResponseWriter, r *http.Request) {
+	if !backfillEnabled(w, r) {
+		return
+	}
+	limit := 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+
+	jobs, err := backfillService.Jobs(r.Context(), limit)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "backfill.list_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "backfill.listed", jobs, len(jobs))
+}
+
+// createBackfillJob 创建回填任务并在后台执行
+// 请求体 merchant_ids 为需要回填的商户（为空表示全部），batch_size 每批订单数，reason 回填原因
+func createBackfillJob(w http.ResponseWriter, r *http.Request) {
+	if !backfillEnabled(w, r) {
+		return
+	}
+
+	var req services.BackfillRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		err = fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+		respondError(w, r, errorStatus(err), "backfill.create_failed", err)
+		return
+	}
+
+	job, err := backfillService.Create(r.Context(), req)
+	if err == nil {
+		err = backfillService.Start(job.ID)
+	}
+	if err != nil {
+		respondError(w, r, errorStatus(err), "backfill.create_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusAccepted, "backfill.started", job, job.ID, job.TotalOrders)
+}
+
+// getBackfillJob 单个回填任务的进度
+func getBackfillJob(w http.ResponseWriter, r *http.Request) {
+	if !backfillEnabled(w, r) {
+		return
+	}
+
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	job, err := backfillService.Job(r.Context(), id)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "backfill.get_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "backfill.found", job, job.ID, job.Status, job.Progress)
+}
+
+// resumeBackfillJob 在后台从游标处继续执行中断或失败的回填任务
+func resumeBackfillJob(w http.ResponseWriter, r *http.Request) {
+	if !backfillEnabled(w, r) {
+		return
+	}
+
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	job, err := backfillService.Job(r.Context(), id)
+	if err == nil && job.Status == models.BackfillCompleted {
+		err = fmt.Errorf("%w: 回填任务 %d 已完成", services.ErrInvalidArgument, id)
+	}
+	if err == nil {
+		err = backfillService.Start(id)
+	}
+	if err != nil {
+		respondError(w, r, errorStatus(err), "backfill.resume_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusAccepted, "backfill.resumed", job, job.ID, job.ProcessedOrders, job.TotalOrders)
+}
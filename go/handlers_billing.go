@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"timezone-saas-demo/services"
+)
+
+// getBillingPeriods 获取商户计费周期
+// merchant_id 必填；through=2024-12-31 计算到该本地日期开始的周期，默认当前周期
+func getBillingPeriods(w http.ResponseWriter, r *http.Request) {
+	idStr := r.URL.Query().Get("merchant_id")
+	merchantID, err := strconv.Atoi(idStr)
+	if err != nil || merchantID <= 0 {
+		err = fmt.Errorf("%w: 无效的商户ID %q", services.ErrInvalidArgument, idStr)
+		respondError(w, r, errorStatus(err), "billing.failed", err)
+		return
+	}
+
+	periods, err := billingService.GetPeriods(merchantID, r.URL.Query().Get("through"))
+	if err != nil {
+		respondError(w, r, errorStatus(err), "billing.failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "billing.ok", periods, merchantID, len(periods.Periods), periods.Timezone)
+}
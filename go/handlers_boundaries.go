@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/services"
+)
+
+// getMerchantBoundaries 商户下一个本地时间边界（倒计时）
+// at=2024-08-19T00:00:00Z 指定参考时刻，默认当前时间
+func getMerchantBoundaries(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		err = fmt.Errorf("%w: 无效的商户ID %q", services.ErrInvalidArgument, mux.Vars(r)["id"])
+		respondError(w, r, errorStatus(err), "boundaries.failed", err)
+		return
+	}
+
+	at := r.URL.Query().Get("at")
+	if at == "" || at == "now" {
+		at = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	boundaries, err := timezoneService.GetBoundaries(id, at)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "boundaries.failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "boundaries.ok", boundaries, boundaries.MerchantName, boundaries.AtLocal)
+}
+
+// getMerchantNow 商户当前的本地时间、偏移、夏令时和营业状态，不访问数据库（商户取自内存快照）
+// 响应可缓存到营业状态、本地日期或偏移下一次变化（最长一小时）：Cache-Control 的 max-age 和 Expires 按此设置
+func getMerchantNow(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		err = fmt.Errorf("%w: 无效的商户ID %q", services.ErrInvalidArgument, mux.Vars(r)["id"])
+		respondError(w, r, errorStatus(err), "merchant_now.failed", err)
+		return
+	}
+
+	now, err := timezoneService.MerchantNow(id, time.Now())
+	if err != nil {
+		respondError(w, r, errorStatus(err), "merchant_now.failed", err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", now.MaxAgeSeconds))
+	w.Header().Set("Expires", now.ValidUntil.Format(http.TimeFormat))
+	respondSuccess(w, r, http.StatusOK, "merchant_now.ok", now, now.MerchantName, now.Local, now.Offset)
+}
+
+// getMerchantCalendar 商户的日历订阅（iCalendar）：营业时间、节假日和定时报表的预计执行时刻，
+// 供客户经理在 Outlook、Google 日历中按 URL 订阅；事件使用商户时区，VTIMEZONE 与服务端的时区数据一致
+func getMerchantCalendar(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		err = fmt.Errorf("%w: 无效的商户ID %q", services.ErrInvalidArgument, mux.Vars(r)["id"])
+		respondError(w, r, errorStatus(err), "calendar.failed", err)
+		return
+	}
+
+	merchant, err := timezoneService.GetMerchant(id)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "calendar.failed", err)
+		return
+	}
+	holidays, err := services.GetSetting(settingsService, id, services.SettingHolidays)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "calendar.failed", err)
+		return
+	}
+	now := time.Now()
+	// 多取一天，覆盖商户本地日期与 UTC 日期的差异，范围外的由 MerchantCalendar 过滤
+	reports, err := reportService.MerchantSchedule(r.Context(), id, now.AddDate(0, 0, services.CalendarFutureDays+2))
+	if err != nil {
+		respondError(w, r, errorStatus(err), "calendar.failed", err)
+		return
+	}
+	calendar, err := services.MerchantCalendar(*merchant, holidays, reports, now)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "calendar.failed", err)
+		return
+	}
+	var buf bytes.Buffer
+	if err := calendar.Encode(&buf); err != nil {
+		respondError(w, r, http.StatusInternalServerError, "calendar.failed", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="merchant-%d.ics"`, id))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+
+	"timezone-saas-demo/services"
+)
+
+// cacheWriter 把响应同时写给客户端和缓冲区，用于缓存未命中时保存响应
+type cacheWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (c *cacheWriter) WriteHeader(status int) {
+	c.status = status
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *cacheWriter) Write(p []byte) (int, error) {
+	c.body.Write(p)
+	return c.ResponseWriter.Write(p)
+}
+
+// responseCacheKey 租户、路径、排序后的查询参数、Accept-Language（消息语言）和协商的响应格式确定一条缓存
+func responseCacheKey(r *http.Request, tenant string) string {
+	return strings.Join([]string{tenant, r.URL.Path, r.URL.Query().Encode(), r.Header.Get("Accept-Language"), negotiateFormat(r)}, "\x00")
+}
+
+// cacheResponse 按租户和请求参数缓存 h 的 200 响应，过期后先返回旧响应再在后台刷新
+// 响应带 X-Cache 头（HIT、STALE、MISS），命中时带 Age 头；
+// 带 Authorization 的请求（管理员参数）不经过缓存，Cache-Control: no-cache 的请求跳过查找并用结果更新缓存
+func cacheResponse(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !responseCache.Enabled() || r.Header.Get("Authorization") != "" || isCaptureReplay(r.Context()) {
+			h(w, r)
+			return
+		}
+
+		tenant := services.TenantFromContext(r.Context())
+		key := responseCacheKey(r, tenant)
+		cached, state, refresh, generation := responseCache.Get(key)
+		if strings.Contains(r.Header.Get("Cache-Control"), "no-cache") {
+			if refresh {
+				responseCache.Abandon(key)
+			}
+			state, refresh = services.CacheMiss, false
+		}
+		if refresh {
+			go refreshCachedResponse(h, r, key, tenant, generation)
+		}
+		if state != services.CacheMiss {
+			for name, values := range cached.Header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("X-Cache", state)
+			w.Header().Set("Age", strconv.Itoa(int(time.Since(cached.StoredAt).Seconds())))
+			w.WriteHeader(cached.Status)
+			w.Write(cached.Body)
+			return
+		}
+
+		w.Header().Set("X-Cache", services.CacheMiss)
+		rec := &cacheWriter{ResponseWriter: w, status: http.StatusOK}
+		h(rec, r)
+		if rec.status == http.StatusOK {
+			responseCache.Put(key, tenant, cachedResponse(rec.Header(), rec.status, rec.body.Bytes()), generation)
+		}
+	}
+}
+
+// refreshCachedResponse 在后台重新执行请求并更新缓存，客户端断开不影响刷新；失败时保留旧响应
+func refreshCachedResponse(h http.HandlerFunc, r *http.Request, key, tenant string, generation uint64) {
+	rec := httptest.NewRecorder()
+	h(rec, r.Clone(context.WithoutCancel(r.Context())))
+	if rec.Code != http.StatusOK {
+		responseCache.Abandon(key)
+		return
+	}
+	responseCache.Put(key, tenant, cachedResponse(rec.Header(), rec.Code, rec.Body.Bytes()), generation)
+}
+
+// cachedResponse 复制响应头和响应体；X-Cache 由读取缓存时重新设置，X-Fault-Injected 只属于当次请求
+func cachedResponse(header http.Header, status int, body []byte) services.CachedResponse {
+	header = header.Clone()
+	header.Del("X-Cache")
+	header.Del("X-Fault-Injected")
+	return services.CachedResponse{Status: status, Header: header, Body: bytes.Clone(body)}
+}
+
+// purgeResponseCache 写入数据的请求成功后清空响应缓存，之后的读取按最新数据计算
+func purgeResponseCache(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h(rec, r)
+		if rec.status < http.StatusBadRequest {
+			responseCache.Purge("")
+		}
+	}
+}
+
+// getResponseCache 响应缓存的配置、命中统计和各租户的条数
+func getResponseCache(w http.ResponseWriter, r *http.Request) {
+	stats := responseCache.Stats()
+	respondSuccess(w, r, http.StatusOK, "admin.response_cache", stats, stats.Entries)
+}
+
+// clearResponseCache 清空响应缓存，tenant 只清空该租户
+func clearResponseCache(w http.ResponseWriter, r *http.Request) {
+	n := responseCache.Purge(r.URL.Query().Get("tenant"))
+	respondSuccess(w, r, http.StatusOK, "admin.response_cache_purged", map[string]int{"purged": n}, n)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/services"
+)
+
+// captureReplayKey 标记由 /api/admin/captures/{id}/replay 发起的请求
+type captureReplayKey struct{}
+
+// isCaptureReplay 请求是否为录制记录的重放，重放请求不再录制也不注入故障
+func isCaptureReplay(ctx context.Context) bool {
+	replay, _ := ctx.Value(captureReplayKey{}).(bool)
+	return replay
+}
+
+// captureWriter 记录响应状态码，并保留响应体的前 MaxCaptureRawBody 字节
+type captureWriter struct {
+	http.ResponseWriter
+	status    int
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (c *captureWriter) WriteHeader(status int) {
+	c.status = status
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *captureWriter) Write(p []byte) (int, error) {
+	if room := services.MaxCaptureRawBody - c.body.Len(); room > 0 {
+		if len(p) > room {
+			c.body.Write(p[:room])
+			c.truncated = true
+		} else {
+			c.body.Write(p)
+		}
+	} else if len(p) > 0 {
+		c.truncated = true
+	}
+	return c.ResponseWriter.Write(p)
+}
+
+// captureMiddleware 为开启了录制的租户保存脱敏后的请求和响应，管理接口不录制
+func captureMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := services.TenantFromContext(r.Context())
+		if isCaptureReplay(r.Context()) || strings.HasPrefix(r.URL.Path, "/api/admin") || !captureRecorder.Enabled(tenant) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		// 只读取前 MaxCaptureRawBody 字节用于录制，处理函数仍能读到完整的请求体
+		var reqBody []byte
+		reqTruncated := false
+		if r.Body != nil {
+			reqBody, _ = io.ReadAll(io.LimitReader(r.Body, services.MaxCaptureRawBody+1))
+			if len(reqBody) > services.MaxCaptureRawBody {
+				reqBody, reqTruncated = reqBody[:services.MaxCaptureRawBody], true
+			}
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(reqBody), r.Body), r.Body}
+		}
+
+		rec := &captureWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		capture := models.RequestCapture{
+			Tenant:          tenant,
+			StartedAt:       start.UTC(),
+			DurationMs:      float64(time.Since(start).Microseconds()) / 1000,
+			Method:          r.Method,
+			Path:            r.URL.Path,
+			ClientIP:        clientIP(r),
+			Scheme:          requestScheme(r),
+			Query:           services.SanitizeQuery(r.URL.Query()),
+			RequestHeaders:  services.SanitizeHeaders(r.Header),
+			Status:          rec.status,
+			ResponseHeaders: services.SanitizeHeaders(rec.Header()),
+		}
+		var truncated bool
+		capture.RequestBody, truncated = captureRecorder.SanitizeBody(reqBody)
+		capture.RequestBodyTruncated = truncated || reqTruncated
+		capture.ResponseBody, truncated = captureRecorder.SanitizeBody(rec.body.Bytes())
+		capture.ResponseBodyTruncated = truncated || rec.truncated
+		captureRecorder.Record(capture)
+	})
+}
+
+// listCaptures 最近的录制记录，按时间倒序；tenant 过滤租户，limit 默认 50
+func listCaptures(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+
+	captures := captureRecorder.List(r.URL.Query().Get("tenant"), limit)
+	respondSuccess(w, r, http.StatusOK, "captures.listed", captures, len(captures))
+}
+
+// clearCaptures 删除录制记录，tenant 为空时删除全部
+func clearCaptures(w http.ResponseWriter, r *http.Request) {
+	n := captureRecorder.Clear(r.URL.Query().Get("tenant"))
+	respondSuccess(w, r, http.StatusOK, "captures.cleared", nil, n)
+}
+
+// getCapture 单条录制记录
+func getCapture(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	capture, err := captureRecorder.Get(id)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "captures.get_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "captures.found", capture, capture.ID)
+}
+
+// listCaptureTenants 正在录制的租户
+func listCaptureTenants(w http.ResponseWriter, r *http.Request) {
+	tenants := captureRecorder.Tenants()
+	respondSuccess(w, r, http.StatusOK, "captures.tenants", tenants, len(tenants))
+}
+
+// enableCapture 为租户开启录制，请求体 ttl 为有效期（默认 1h，最长 24h），可省略
+func enableCapture(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TTL string `json:"ttl"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		err = fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+		respondError(w, r, errorStatus(err), "captures.enable_failed", err)
+		return
+	}
+	ttl, err := parseDurationParam("ttl", req.TTL, services.DefaultCaptureTTL)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, "captures.enable_failed", err)
+		return
+	}
+
+	tenant, err := captureRecorder.Enable(mux.Vars(r)["tenant"], ttl)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "captures.enable_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "captures.enabled", tenant, tenant.Tenant, tenant.ExpiresAt.Format(time.RFC3339))
+}
+
+// disableCapture 关闭租户的录制，已录制的记录保留
+func disableCapture(w http.ResponseWriter, r *http.Request) {
+	tenant := mux.Vars(r)["tenant"]
+	if err := captureRecorder.Disable(tenant); err != nil {
+		respondError(w, r, errorStatus(err), "captures.disable_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "captures.disabled", nil, tenant)
+}
+
+// replayCapture 按录制的请求在本实例重新执行一次，返回新的响应以及与录制时是否一致
+// 只重放 GET 请求，避免重复创建退款等副作用；查询参数被脱敏的请求无法重放
+func replayCapture(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	capture, err := captureRecorder.Get(id)
+	if err == nil && capture.Method != http.MethodGet {
+		err = fmt.Errorf("%w: 只能重放 GET 请求，录制记录 %d 为 %s", services.ErrInvalidArgument, id, capture.Method)
+	}
+	if err == nil {
+		query, _ := url.ParseQuery(capture.Query)
+		for name := range query {
+			if !services.Replayable(name) {
+				err = fmt.Errorf("%w: 录制记录 %d 的查询参数 %s 已脱敏，无法重放", services.ErrInvalidArgument, id, name)
+				break
+			}
+		}
+	}
+	if err != nil {
+		respondError(w, r, errorStatus(err), "captures.replay_failed", err)
+		return
+	}
+
+	target := capture.Path
+	if capture.Query != "" {
+		target += "?" + capture.Query
+	}
+	ctx := context.WithValue(services.WithTenant(r.Context(), capture.Tenant), captureReplayKey{}, true)
+	req, err := http.NewRequestWithContext(ctx, capture.Method, target, nil)
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, "captures.replay_failed", err)
+		return
+	}
+	for name, value := range capture.RequestHeaders {
+		if services.Replayable(name) {
+			req.Header.Set(name, value)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	setupRoutes().ServeHTTP(rec, req)
+
+	replay := models.CaptureReplay{Capture: *capture, Status: rec.Code}
+	replay.ResponseBody, replay.ResponseBodyTruncated = captureRecorder.SanitizeBody(rec.Body.Bytes())
+	replay.SameStatus = replay.Status == capture.Status
+	replay.SameBody = replay.ResponseBody == capture.ResponseBody
+	respondSuccess(w, r, http.StatusOK, "captures.replayed", replay, capture.ID, rec.Code)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/services"
+)
+
+// getChanges 商户和订单的变更流水，按 seq 升序
+// since 为上一次响应的 next_seq（首次同步为 0）；wait=30s 时没有新变更则最多等待该时长（long polling，最长 60s）；
+// merchant_id 只返回该商户及其订单的变更，entity=merchant,order 过滤实体，limit 默认 500，最大 1000
+func getChanges(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var filter models.ChangeFilter
+	var err error
+	if since := query.Get("since"); since != "" {
+		if filter.Since, err = strconv.ParseInt(since, 10, 64); err != nil {
+			err = fmt.Errorf("%w: since 应为整数", services.ErrInvalidArgument)
+			respondError(w, r, errorStatus(err), "changes.failed", err)
+			return
+		}
+	}
+	if merchantID := query.Get("merchant_id"); merchantID != "" {
+		if filter.MerchantID, err = strconv.Atoi(merchantID); err != nil || filter.MerchantID <= 0 {
+			err = fmt.Errorf("%w: 无效的商户ID %q", services.ErrInvalidArgument, merchantID)
+			respondError(w, r, errorStatus(err), "changes.failed", err)
+			return
+		}
+	}
+	for _, entity := range strings.Split(query.Get("entity"), ",") {
+		if entity = strings.TrimSpace(entity); entity != "" {
+			filter.Entities = append(filter.Entities, entity)
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			err = fmt.Errorf("%w: limit 应为整数", services.ErrInvalidArgument)
+			respondError(w, r, errorStatus(err), "changes.failed", err)
+			return
+		}
+	}
+	wait, err := parseDurationParam("wait", query.Get("wait"), 0)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "changes.failed", err)
+		return
+	}
+
+	feed, err := changeService.Feed(r.Context(), filter, wait)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "changes.failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "changes.ok", feed, len(feed.Changes), feed.NextSeq)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// getClosedAnalysis 获取日结数据，读取日结快照而不是实时计算；date 默认为 UTC 的昨天
+func getClosedAnalysis(w http.ResponseWriter, r *http.Request) {
+	date, err := queryDate(r, time.UTC, "yesterday")
+	if err != nil {
+		respondError(w, r, errorStatus(err), "close.failed", err)
+		return
+	}
+
+	closed, err := revenueCloseService.GetClosedAnalysis(date)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "close.failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "close.ok", closed, date, closed.ClosedMerchants, len(closed.Pending))
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// listConsistencyRuns 最近的一致性检查记录，按开始时间倒序；limit 默认 20
+func listConsistencyRuns(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+
+	runs, err := consistencyService.Runs(r.Context(), limit)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "consistency.list_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "consistency.listed", runs, len(runs))
+}
+
+// getConsistencyRun 一次一致性检查及其差异明细
+func getConsistencyRun(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	run, err := consistencyService.GetRun(r.Context(), id)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "consistency.get_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "consistency.found", run, run.RunID, run.DiscrepancyCount)
+}
+
+// runConsistencyCheck 立即执行一次一致性检查，检查期间定时任务等待
+func runConsistencyCheck(w http.ResponseWriter, r *http.Request) {
+	run, err := consistencyService.Check(r.Context())
+	if err != nil {
+		respondError(w, r, errorStatus(err), "consistency.run_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "consistency.completed", run, run.RunID, run.Status, run.DiscrepancyCount)
+}
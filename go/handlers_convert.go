@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/services"
+)
+
+// maxConvertBodyBytes 批量转换请求体的上限，足够容纳 MaxConversionItems 项
+const maxConvertBodyBytes = 4 << 20
+
+// convertTimestamps 批量时区转换，不访问数据库
+// 请求体为 [{"timestamp":"2024-03-31T02:30:00","from_tz":"Europe/Berlin","to_tz":"Asia/Tokyo"}, ...]；
+// gap=shift|skip、overlap=earlier|later 控制来源本地时间遇到夏令时切换时的处理
+func convertTimestamps(w http.ResponseWriter, r *http.Request) {
+	var items []models.ConversionItem
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxConvertBodyBytes)).Decode(&items); err != nil {
+		err = fmt.Errorf("%w: 请求体应为转换项数组: %v", services.ErrInvalidArgument, err)
+		respondError(w, r, errorStatus(err), "convert.failed", err)
+		return
+	}
+
+	result, err := timezoneService.ConvertTimestamps(items, r.URL.Query().Get("gap"), r.URL.Query().Get("overlap"))
+	if err != nil {
+		respondError(w, r, errorStatus(err), "convert.failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "convert.ok", result, result.Count, result.Failed)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"timezone-saas-demo/services"
+)
+
+// getDashboardSummary 首页概览，前端首屏只需这一个请求
+// merchant_id 必填；currency=USD 只统计该币种，status=paid,refunded 指定参与统计的订单状态，默认按营收口径
+func getDashboardSummary(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	merchantID, err := strconv.Atoi(query.Get("merchant_id"))
+	if err != nil || merchantID <= 0 {
+		err = fmt.Errorf("%w: 无效的商户ID %q", services.ErrInvalidArgument, query.Get("merchant_id"))
+		respondError(w, r, errorStatus(err), "dashboard.failed", err)
+		return
+	}
+	statuses, err := services.ParseStatuses(query.Get("status"))
+	if err != nil {
+		respondError(w, r, errorStatus(err), "dashboard.failed", err)
+		return
+	}
+
+	summary, err := dashboardService.Summary(r.Context(), merchantID, query.Get("currency"), statuses)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "dashboard.failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "dashboard.ok", summary, summary.MerchantName, summary.LocalTime)
+}
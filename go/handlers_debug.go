@@ -0,0 +1,84 @@
+package main
+
+import (
+	"database/sql"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/services"
+)
+
+// processStartedAt 进程启动时间，用于计算运行时长
+var processStartedAt = time.Now()
+
+// setupDebugRoutes 挂载 net/http/pprof 和 expvar，需要 DEBUG_ENDPOINTS=true 和 ADMIN_TOKEN
+// 例如导出大量数据时 CPU 升高，可直接采集：go tool pprof -http=: "http://host/debug/pprof/profile?seconds=30"（需带管理令牌）
+func setupDebugRoutes(router *mux.Router) {
+	if !debugEndpoints {
+		return
+	}
+	publishDebugVars()
+
+	debug := router.PathPrefix("/debug").Subrouter()
+	debug.Use(adminMiddleware)
+	debug.HandleFunc("/pprof/cmdline", pprof.Cmdline)
+	debug.HandleFunc("/pprof/profile", pprof.Profile)
+	debug.HandleFunc("/pprof/symbol", pprof.Symbol)
+	debug.HandleFunc("/pprof/trace", pprof.Trace)
+	// 其余路径（goroutine、heap、allocs、block、mutex 等）由 pprof.Index 按名称查找
+	debug.PathPrefix("/pprof/").HandlerFunc(pprof.Index)
+	debug.Handle("/vars", expvar.Handler())
+}
+
+// publishDebugVarsOnce expvar 的变量名不能重复注册，录制重放会再次创建路由
+var publishDebugVarsOnce sync.Once
+
+// publishDebugVars 在 /debug/vars 中增加连接池、熔断器、响应缓存、过载保护、选主和合成监控的状态（memstats、cmdline 由 expvar 自带）
+func publishDebugVars() {
+	publishDebugVarsOnce.Do(func() {
+		expvar.Publish("db_pool", expvar.Func(func() interface{} {
+			if db == nil {
+				return nil
+			}
+			return db.GetStats()
+		}))
+		expvar.Publish("circuit_breaker", expvar.Func(func() interface{} { return db.CircuitBreakerStatus() }))
+		expvar.Publish("response_cache", expvar.Func(func() interface{} { return responseCache.Stats() }))
+		expvar.Publish("load_shed", expvar.Func(func() interface{} { return loadShedder.Status() }))
+		expvar.Publish("goroutines", expvar.Func(func() interface{} { return runtime.NumGoroutine() }))
+		expvar.Publish("leader", expvar.Func(func() interface{} {
+			if leaderElector == nil {
+				return nil
+			}
+			return leaderElector.Status()
+		}))
+		expvar.Publish("canary", expvar.Func(func() interface{} {
+			if canaryService == nil {
+				return nil
+			}
+			return canaryService.Status()
+		}))
+	})
+}
+
+// getRuntimeDiagnostics goroutine 数量和状态分布、内存、GC 以及数据库连接池统计
+func getRuntimeDiagnostics(w http.ResponseWriter, r *http.Request) {
+	var pool *sql.DBStats
+	if db != nil {
+		stats := db.GetStats()
+		pool = &stats
+	}
+	diag := services.CollectRuntime(processStartedAt, pool)
+	diag.DebugEndpoints = debugEndpoints
+	if leaderElector != nil {
+		status := leaderElector.Status()
+		diag.Leader = &status
+	}
+	respondSuccess(w, r, http.StatusOK, "admin.runtime", diag, diag.Goroutines)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/services"
+)
+
+// decodeEmailTemplate 解析邮件模板的请求体
+func decodeEmailTemplate(r *http.Request) (services.EmailTemplateRequest, error) {
+	var req services.EmailTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return req, fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+	}
+	return req, nil
+}
+
+// listEmailTemplates 当前租户（X-Tenant-ID）可用的邮件模板，覆盖的版本 source 为 tenant
+func listEmailTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := emailService.Templates(r.Context())
+	if err != nil {
+		respondError(w, r, errorStatus(err), "email.list_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "email.listed", templates, len(templates))
+}
+
+// getEmailTemplate 当前租户发送 locale 语言的邮件时实际使用的模板
+func getEmailTemplate(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	t, err := emailService.Template(r.Context(), name, r.URL.Query().Get("locale"))
+	if err != nil {
+		respondError(w, r, errorStatus(err), "email.get_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "email.get", t, t.Name, t.Locale, t.Source)
+}
+
+// saveEmailTemplate 覆盖当前租户 locale 语言的模板，保存前使用示例数据校验
+func saveEmailTemplate(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeEmailTemplate(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "email.save_failed", err)
+		return
+	}
+	t, err := emailService.SaveTemplate(r.Context(), mux.Vars(r)["name"], r.URL.Query().Get("locale"), req)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "email.save_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "email.saved", t, t.Name, t.Locale)
+}
+
+// deleteEmailTemplate 删除当前租户覆盖的模板，恢复使用内置模板
+func deleteEmailTemplate(w http.ResponseWriter, r *http.Request) {
+	name, lang := mux.Vars(r)["name"], r.URL.Query().Get("locale")
+	if err := emailService.DeleteTemplate(r.Context(), name, lang); err != nil {
+		respondError(w, r, errorStatus(err), "email.delete_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "email.deleted", nil, name)
+}
+
+// previewEmailTemplate 使用示例数据渲染模板，请求体为草稿时渲染草稿，为空时渲染当前使用的模板
+func previewEmailTemplate(w http.ResponseWriter, r *http.Request) {
+	var draft *services.EmailTemplateRequest
+	var req services.EmailTemplateRequest
+	switch err := json.NewDecoder(r.Body).Decode(&req); {
+	case err == nil:
+		draft = &req
+	case !errors.Is(err, io.EOF):
+		err = fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+		respondError(w, r, errorStatus(err), "email.preview_failed", err)
+		return
+	}
+
+	query := r.URL.Query()
+	msg, err := emailService.Preview(r.Context(), mux.Vars(r)["name"], query.Get("locale"), query.Get("timezone"), draft)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "email.preview_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "email.preview", msg, msg.Subject)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/services"
+)
+
+// faultExemptPrefixes 不注入故障的路径：健康检查、文档和管理接口（保证始终能关闭故障注入）
+var faultExemptPrefixes = []string{"/api/health", "/api/docs", "/api/admin"}
+
+// faultMiddleware 按故障注入规则为 API 请求增加延迟、返回 5xx 或断开数据库连接，录制记录的重放不注入
+// 注入了故障的响应带 X-Fault-Injected 头，值为生效的规则ID
+func faultMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if faultInjector == nil || faultExempt(r.URL.Path) || isCaptureReplay(r.Context()) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		d := faultInjector.Decide(r.Method, r.URL.Path, services.TenantFromContext(r.Context()))
+		if len(d.Rules) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ids := make([]string, len(d.Rules))
+		for i, id := range d.Rules {
+			ids[i] = strconv.FormatInt(id, 10)
+		}
+		w.Header().Set("X-Fault-Injected", strings.Join(ids, ","))
+
+		if d.Delay > 0 {
+			timer := time.NewTimer(d.Delay)
+			select {
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+		if d.Status != 0 {
+			if d.Status == http.StatusServiceUnavailable {
+				w.Header().Set("Retry-After", "1")
+			}
+			respondError(w, r, d.Status, "faults.injected", fmt.Errorf("故障注入返回 %d", d.Status))
+			return
+		}
+		if d.DropDB {
+			r = r.WithContext(database.WithDroppedConnection(r.Context()))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// faultExempt 路径是否不参与故障注入
+func faultExempt(path string) bool {
+	for _, prefix := range faultExemptPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// faultsEnabled 未设置 FAULT_INJECTION=true 时故障注入接口一律拒绝
+func faultsEnabled(w http.ResponseWriter, r *http.Request) bool {
+	if faultInjector == nil {
+		respondError(w, r, http.StatusForbidden, "faults.disabled", errors.New("未设置 FAULT_INJECTION=true"))
+		return false
+	}
+	return true
+}
+
+// listFaultRules 当前生效的故障注入规则及命中统计
+func listFaultRules(w http.ResponseWriter, r *http.Request) {
+	if !faultsEnabled(w, r) {
+		return
+	}
+	rules := faultInjector.Rules()
+	respondSuccess(w, r, http.StatusOK, "faults.listed", rules, len(rules))
+}
+
+// createFaultRule 添加故障注入规则
+// 请求体 kind 为 latency/error/db_drop，percent 为 0~100 的注入比例，route 路径前缀、method、tenant 限定范围，
+// latency/jitter 为延迟，status 为 error 规则的 5xx 状态码，ttl 为规则有效期
+func createFaultRule(w http.ResponseWriter, r *http.Request) {
+	if !faultsEnabled(w, r) {
+		return
+	}
+
+	var req services.FaultRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		err = fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+		respondError(w, r, errorStatus(err), "faults.create_failed", err)
+		return
+	}
+
+	rule, err := faultInjector.Add(req)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "faults.create_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusCreated, "faults.created", rule, rule.ID, rule.Kind, rule.Route)
+}
+
+// deleteFaultRule 删除一条故障注入规则
+func deleteFaultRule(w http.ResponseWriter, r *http.Request) {
+	if !faultsEnabled(w, r) {
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err == nil {
+		err = faultInjector.Remove(id)
+	}
+	if err != nil {
+		respondError(w, r, errorStatus(err), "faults.delete_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "faults.deleted", nil, id)
+}
+
+// clearFaultRules 删除全部故障注入规则
+func clearFaultRules(w http.ResponseWriter, r *http.Request) {
+	if !faultsEnabled(w, r) {
+		return
+	}
+	n := faultInjector.Clear()
+	respondSuccess(w, r, http.StatusOK, "faults.cleared", nil, n)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/services"
+)
+
+// getMerchantFulfillment 商户下单到发货的时长，按下单的本地小时和星期分组：GET /api/timezone/merchants/{id}/fulfillment?weeks=4
+// weeks 统计周数（默认 4，最多 26），to 为统计的最后一个本地下单日期（默认商户本地的昨天），
+// status 只统计这些状态的订单（默认全部有发货时间的订单）
+func getMerchantFulfillment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		err = fmt.Errorf("%w: 无效的商户ID %q", services.ErrInvalidArgument, mux.Vars(r)["id"])
+		respondError(w, r, errorStatus(err), "fulfillment.failed", err)
+		return
+	}
+	query := r.URL.Query()
+	opts := services.FulfillmentOptions{To: query.Get("to")}
+	if value := query.Get("weeks"); value != "" {
+		if opts.Weeks, err = strconv.Atoi(value); err != nil || opts.Weeks <= 0 {
+			err = fmt.Errorf("%w: 无效的周数 %q", services.ErrInvalidArgument, value)
+			respondError(w, r, errorStatus(err), "fulfillment.failed", err)
+			return
+		}
+	}
+	if opts.Statuses, err = services.ParseStatuses(query.Get("status")); err != nil {
+		respondError(w, r, errorStatus(err), "fulfillment.failed", err)
+		return
+	}
+
+	report, err := timezoneService.GetFulfillment(r.Context(), id, opts)
+	if errors.Is(err, services.ErrOverloaded) {
+		respondError(w, r, errorStatus(err), "analysis.overloaded", err)
+		return
+	}
+	if err != nil {
+		respondError(w, r, errorStatus(err), "fulfillment.failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "fulfillment.ok", report, report.MerchantName, report.Overall.Orders, report.Overall.P50Hours)
+}
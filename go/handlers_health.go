@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// readinessTimeout 就绪检查访问数据库的超时时间
+const readinessTimeout = 2 * time.Second
+
+// livenessHandler 存活探针：进程能处理请求即视为存活，不访问数据库
+func livenessHandler(w http.ResponseWriter, r *http.Request) {
+	respondSuccess(w, r, http.StatusOK, "health.live", nil)
+}
+
+// readinessHandler 就绪探针：数据库可用时返回 200，否则返回 503；mock 模式没有数据库，始终就绪
+// 后台连接数据库期间（SERVE_BEFORE_DB_READY=true）返回 503 和最近一次连接失败的原因
+func readinessHandler(w http.ResponseWriter, r *http.Request) {
+	if err := startup.err(); err != nil {
+		respondError(w, r, http.StatusServiceUnavailable, "health.not_ready", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	if db != nil {
+		if err := db.Ready(ctx); err != nil {
+			respondError(w, r, http.StatusServiceUnavailable, "health.not_ready", err)
+			return
+		}
+	}
+
+	respondSuccess(w, r, http.StatusOK, "health.ready", nil)
+}
+
+// clockHandler 最近一次时钟偏差检查的结果，还没有检查过时立即检查一次
+// 偏差超过阈值时仍返回 200：时钟问题需要运维处理，不应让探针重启实例
+func clockHandler(w http.ResponseWriter, r *http.Request) {
+	status := clockMonitor.Latest()
+	if status == nil {
+		status = clockMonitor.Check(r.Context())
+	}
+	respondSuccess(w, r, http.StatusOK, "health.clock", status, status.Status, status.MaxSkewMs)
+}
+
+// canaryHandler 本实例合成监控的最近一次运行和累计结果
+// 与时钟检查相同，失败时仍返回 200，由 status 和告警反映
+func canaryHandler(w http.ResponseWriter, r *http.Request) {
+	if canaryService == nil {
+		respondError(w, r, http.StatusForbidden, "health.canary_disabled", errors.New("合成监控未启用（mock 模式或 CANARY_INTERVAL=0）"))
+		return
+	}
+	status := canaryService.Status()
+	respondSuccess(w, r, http.StatusOK, "health.canary", status, status.Status, status.ConsecutiveFailures)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/services"
+)
+
+// maxIngestBodyBytes Webhook 请求体的上限，Shopify 含大量明细行的订单也在此范围内
+const maxIngestBodyBytes = 2 << 20
+
+// receiveWebhook 接收外部平台的订单 Webhook：POST /api/ingest/webhooks/{provider}?merchant_id=2
+// 平台不支持返回 404，未配置密钥返回 403，签名无效返回 401；投递保存后即返回 200（包括映射失败进入死信的投递），
+// 只有保存或写入数据库失败时返回 5xx，由平台按其策略重试
+func receiveWebhook(w http.ResponseWriter, r *http.Request) {
+	provider := mux.Vars(r)["provider"]
+
+	var merchantID int
+	if value := r.URL.Query().Get("merchant_id"); value != "" {
+		var err error
+		if merchantID, err = strconv.Atoi(value); err != nil || merchantID <= 0 {
+			err = fmt.Errorf("%w: 无效的商户ID %q", services.ErrInvalidArgument, value)
+			respondError(w, r, errorStatus(err), "ingest.failed", err)
+			return
+		}
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIngestBodyBytes))
+	if err != nil {
+		err = fmt.Errorf("%w: 读取请求体失败: %v", services.ErrInvalidArgument, err)
+		respondError(w, r, errorStatus(err), "ingest.failed", err)
+		return
+	}
+
+	delivery, err := ingestService.Receive(r.Context(), provider, r.Header, body, merchantID)
+	if errors.Is(err, services.ErrSignatureInvalid) {
+		respondError(w, r, http.StatusUnauthorized, "ingest.unauthorized", err)
+		return
+	}
+	if errors.Is(err, services.ErrIngestDisabled) {
+		respondError(w, r, http.StatusForbidden, "ingest.disabled", err)
+		return
+	}
+	if err != nil {
+		respondError(w, r, errorStatus(err), "ingest.failed", err)
+		return
+	}
+
+	delivery.Payload = nil
+	respondSuccess(w, r, http.StatusOK, "ingest.ok", delivery, provider, delivery.ExternalID, delivery.Status)
+}
+
+// listIngestDeliveries Webhook 投递记录，按接收时间倒序；provider、status（如 failed 查看死信）过滤，limit 默认 100
+func listIngestDeliveries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.IngestDeliveryFilter{Provider: query.Get("provider"), Status: query.Get("status")}
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			err = fmt.Errorf("%w: limit 应为整数", services.ErrInvalidArgument)
+			respondError(w, r, errorStatus(err), "ingest.list_failed", err)
+			return
+		}
+	}
+
+	deliveries, err := ingestService.Deliveries(r.Context(), filter)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "ingest.list_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "ingest.listed", deliveries, len(deliveries))
+}
+
+// replayIngestDelivery 重新处理一次投递（通常是修正商户或数据后的死信），不再校验签名
+func replayIngestDelivery(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	delivery, err := ingestService.Replay(r.Context(), id)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "ingest.replay_failed", err)
+		return
+	}
+
+	delivery.Payload = nil
+	respondSuccess(w, r, http.StatusOK, "ingest.replayed", delivery, delivery.ID, delivery.Status)
+}
+
+// replayFailedIngestDeliveries 重放全部死信（provider 为空时不限平台，limit 默认 100），返回每条投递重放后的状态
+func replayFailedIngestDeliveries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := 0
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil {
+			err = fmt.Errorf("%w: limit 应为整数", services.ErrInvalidArgument)
+			respondError(w, r, errorStatus(err), "ingest.replay_failed", err)
+			return
+		}
+	}
+
+	deliveries, err := ingestService.ReplayFailed(r.Context(), query.Get("provider"), limit)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "ingest.replay_failed", err)
+		return
+	}
+
+	stillFailed := 0
+	for _, d := range deliveries {
+		if d.Status == models.IngestStatusFailed {
+			stillFailed++
+		}
+	}
+	respondSuccess(w, r, http.StatusOK, "ingest.replayed_all", deliveries, len(deliveries), stillFailed)
+}
+
+// maxEventUploadBytes 上传的事件列表的上限，足够容纳 MaxReconcileEvents 个 Stripe 事件
+const maxEventUploadBytes = 32 << 20
+
+// getEventReconciliation 核对已接收的 Webhook 事件与订单本地日期：provider 默认 stripe，from、to 为事件的 UTC 日期（默认最近 7 天），
+// 也可以用 range（如 last_30_days）指定；merchant_id 可选
+func getEventReconciliation(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	merchantID, err := parseOptionalMerchantID(query.Get("merchant_id"))
+	if err != nil {
+		respondError(w, r, errorStatus(err), "reconciliation.events_failed", err)
+		return
+	}
+
+	from, to, err := queryDateRange(r, time.UTC)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "reconciliation.events_failed", err)
+		return
+	}
+
+	report, err := ingestService.ReconcileDeliveries(r.Context(), query.Get("provider"), from, to, merchantID)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "reconciliation.events_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "reconciliation.events_ok", report, report.MatchedEvents, report.Mismatches, report.UnmatchedEvents)
+}
+
+// reconcileUploadedEvents 核对上传的支付平台事件与订单本地日期
+// 请求体为 Stripe 的事件列表（{"object":"list","data":[...]}）或事件数组，merchant_id 可选
+func reconcileUploadedEvents(w http.ResponseWriter, r *http.Request) {
+	merchantID, err := parseOptionalMerchantID(r.URL.Query().Get("merchant_id"))
+	if err != nil {
+		respondError(w, r, errorStatus(err), "reconciliation.events_failed", err)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxEventUploadBytes))
+	if err != nil {
+		err = fmt.Errorf("%w: 读取请求体失败: %v", services.ErrInvalidArgument, err)
+		respondError(w, r, errorStatus(err), "reconciliation.events_failed", err)
+		return
+	}
+
+	events, err := services.ParseProviderEvents(body)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "reconciliation.events_failed", err)
+		return
+	}
+	report, err := ingestService.ReconcileEvents(r.Context(), events, merchantID)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "reconciliation.events_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "reconciliation.events_ok", report, report.MatchedEvents, report.Mismatches, report.UnmatchedEvents)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/services"
+)
+
+// 内置的后台任务类型
+const (
+	jobKindReportRun        = "report.run"
+	jobKindRetentionArchive = "retention.archive"
+	jobKindRollupRebuild    = "rollup.rebuild"
+)
+
+// registerJobKinds 注册内置的任务类型，报表在 reports 队列，归档和汇总重建在 maintenance 队列，互不阻塞
+func registerJobKinds(jobs *services.JobService) {
+	jobs.Register(jobKindReportRun, "reports", 10*time.Minute, func(ctx context.Context, job *models.Job) (interface{}, error) {
+		var payload struct {
+			DefinitionID int `json:"definition_id"`
+		}
+		if err := services.DecodeJobPayload(job, &payload); err != nil {
+			return nil, err
+		}
+		run, err := reportService.Execute(ctx, payload.DefinitionID)
+		if err != nil {
+			return nil, err
+		}
+		if run.Status == services.ReportStatusFailed {
+			return nil, fmt.Errorf("报表 %d 执行失败: %s", payload.DefinitionID, run.Error)
+		}
+		return run, nil
+	})
+	if retentionService != nil {
+		jobs.Register(jobKindRetentionArchive, "maintenance", time.Hour, func(ctx context.Context, job *models.Job) (interface{}, error) {
+			var payload struct {
+				MerchantID int `json:"merchant_id"`
+			}
+			if err := services.DecodeJobPayload(job, &payload); err != nil {
+				return nil, err
+			}
+			triggeredBy := services.RetentionTriggerManual
+			if job.Schedule != "" {
+				triggeredBy = services.RetentionTriggerSchedule
+			}
+			run, err := retentionService.Archive(ctx, triggeredBy, payload.MerchantID)
+			if err != nil {
+				return nil, err
+			}
+			if run.Status == services.RetentionStatusFailed {
+				return nil, fmt.Errorf("订单归档 %d 失败: %s", run.RunID, run.Error)
+			}
+			return run, nil
+		})
+	}
+	if rollupService != nil {
+		jobs.Register(jobKindRollupRebuild, "maintenance", time.Hour, func(ctx context.Context, job *models.Job) (interface{}, error) {
+			var payload struct {
+				MerchantID int `json:"merchant_id"`
+			}
+			if err := services.DecodeJobPayload(job, &payload); err != nil {
+				return nil, err
+			}
+			return rollupService.Rebuild(ctx, payload.MerchantID)
+		})
+	}
+}
+
+// jobsEnabled mock 模式没有后台任务服务，任务接口一律拒绝
+func jobsEnabled(w http.ResponseWriter, r *http.Request) bool {
+	if jobService == nil {
+		respondError(w, r, http.StatusForbidden, "jobs.disabled", errors.New("mock 模式不支持后台任务"))
+		return false
+	}
+	return true
+}
+
+// parseJobID 解析路径中的任务ID
+func parseJobID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%w: 无效的任务ID %q", services.ErrInvalidArgument, mux.Vars(r)["id"])
+	}
+	return id, nil
+}
+
+// listJobs 按 queue、kind、status 筛选的任务，按ID倒序，limit 默认 50
+func listJobs(w http.ResponseWriter, r *http.Request) {
+	if !jobsEnabled(w, r) {
+		return
+	}
+	query := r.URL.Query()
+	filter := models.JobFilter{Queue: query.Get("queue"), Kind: query.Get("kind"), Status: query.Get("status")}
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			filter.Limit = l
+		}
+	}
+
+	jobs, err := jobService.Jobs(r.Context(), filter)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "jobs.list_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "jobs.listed", jobs, len(jobs))
+}
+
+// createJob 提交任务
+func createJob(w http.ResponseWriter, r *http.Request) {
+	if !jobsEnabled(w, r) {
+		return
+	}
+	var req services.JobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		err = fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+		respondError(w, r, errorStatus(err), "jobs.create_failed", err)
+		return
+	}
+
+	job, err := jobService.Enqueue(r.Context(), req)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "jobs.create_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusAccepted, "jobs.created", job, job.ID, job.Kind, job.Queue)
+}
+
+// getJob 单个任务，包括最近一次的错误和执行结果
+func getJob(w http.ResponseWriter, r *http.Request) {
+	if !jobsEnabled(w, r) {
+		return
+	}
+	id, err := parseJobID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "jobs.get_failed", err)
+		return
+	}
+
+	job, err := jobService.Job(r.Context(), id)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "jobs.get_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "jobs.found", job, job.ID, job.Status)
+}
+
+// retryJob 失败或已取消的任务重新排队
+func retryJob(w http.ResponseWriter, r *http.Request) {
+	if !jobsEnabled(w, r) {
+		return
+	}
+	id, err := parseJobID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "jobs.retry_failed", err)
+		return
+	}
+
+	job, err := jobService.Retry(r.Context(), id)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "jobs.retry_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "jobs.retried", job, job.ID)
+}
+
+// cancelJob 取消排队或执行中的任务
+func cancelJob(w http.ResponseWriter, r *http.Request) {
+	if !jobsEnabled(w, r) {
+		return
+	}
+	id, err := parseJobID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "jobs.cancel_failed", err)
+		return
+	}
+
+	job, err := jobService.Cancel(r.Context(), id)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "jobs.cancel_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "jobs.cancelled", job, job.ID)
+}
+
+// listJobSchedules 全部定时计划
+func listJobSchedules(w http.ResponseWriter, r *http.Request) {
+	if !jobsEnabled(w, r) {
+		return
+	}
+	schedules, err := jobService.Schedules(r.Context())
+	if err != nil {
+		respondError(w, r, errorStatus(err), "jobs.schedules_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "jobs.schedules_listed", schedules, len(schedules))
+}
+
+// setJobSchedule 新增或覆盖定时计划
+func setJobSchedule(w http.ResponseWriter, r *http.Request) {
+	if !jobsEnabled(w, r) {
+		return
+	}
+	var req services.JobScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		err = fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+		respondError(w, r, errorStatus(err), "jobs.schedule_failed", err)
+		return
+	}
+
+	sc, err := jobService.SetSchedule(r.Context(), mux.Vars(r)["name"], req)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "jobs.schedule_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "jobs.schedule_saved", sc, sc.Name, sc.Kind)
+}
+
+// deleteJobSchedule 删除定时计划，已生成的任务不受影响
+func deleteJobSchedule(w http.ResponseWriter, r *http.Request) {
+	if !jobsEnabled(w, r) {
+		return
+	}
+	name := mux.Vars(r)["name"]
+	if err := jobService.DeleteSchedule(r.Context(), name); err != nil {
+		respondError(w, r, errorStatus(err), "jobs.schedule_delete_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "jobs.schedule_deleted", nil, name)
+}
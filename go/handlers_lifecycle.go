@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/services"
+)
+
+// lifecycleMiddleware 按请求涉及的商户的生命周期拦截数据接口：任一商户暂停时返回 402，归档时返回 403
+// 涉及的商户见 requestMerchantIDs，都没有时不检查；管理接口和账单接口不受限制，暂停的租户仍可查看账单。
+// 商户不存在时放行，由接口自身返回 404；查询状态失败时拒绝请求，不能因为数据库故障绕过暂停
+func lifecycleMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if lifecycleService == nil || strings.HasPrefix(r.URL.Path, "/api/admin/") || strings.HasPrefix(r.URL.Path, "/api/billing/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		merchantIDs, err := requestMerchantIDs(r)
+		if err != nil {
+			respondError(w, r, errorStatus(err), "tenant.lifecycle_failed", err)
+			return
+		}
+		for _, merchantID := range merchantIDs {
+			state, err := lifecycleService.State(r.Context(), merchantID)
+			switch {
+			case errors.Is(err, services.ErrNotFound):
+			case err != nil:
+				respondError(w, r, errorStatus(err), "tenant.lifecycle_failed", fmt.Errorf("查询商户 %d 的生命周期失败: %w", merchantID, err))
+				return
+			case state == models.TenantLifecycleSuspended:
+				respondError(w, r, http.StatusPaymentRequired, "tenant.suspended", fmt.Errorf("商户 %d 已暂停", merchantID))
+				return
+			case state == models.TenantLifecycleArchived:
+				respondError(w, r, http.StatusForbidden, "tenant.archived", fmt.Errorf("商户 %d 已归档", merchantID))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestMerchantIDs 请求涉及的全部商户，去重后按出现顺序：请求所属的租户（API 密钥所属的商户，或 X-Tenant-ID 为商户ID时）、
+// 路由 /merchants/{id} 中的ID、查询参数 merchant_id 和 merchants 列表；merchant_id 或 merchants 格式错误时返回 ErrInvalidArgument
+func requestMerchantIDs(r *http.Request) ([]int, error) {
+	var ids []int
+	seen := map[int]bool{}
+	add := func(id int) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	if id, err := strconv.Atoi(services.TenantFromContext(r.Context())); err == nil && id > 0 {
+		add(id)
+	}
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil && strings.Contains(tpl, "/merchants/{id") {
+			if id, err := strconv.Atoi(mux.Vars(r)["id"]); err == nil && id > 0 {
+				add(id)
+			}
+		}
+	}
+	query := r.URL.Query()
+	for _, value := range query["merchant_id"] {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("%w: 无效的商户ID %q", services.ErrInvalidArgument, value)
+		}
+		add(id)
+	}
+	for _, value := range query["merchants"] {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		list, err := parseIDList(value)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range list {
+			add(id)
+		}
+	}
+	return ids, nil
+}
+
+// lifecycleEnabled mock 模式没有生命周期服务，生命周期接口一律拒绝
+func lifecycleEnabled(w http.ResponseWriter, r *http.Request) bool {
+	if lifecycleService == nil {
+		respondError(w, r, http.StatusForbidden, "tenant.lifecycle_disabled", errors.New("mock 模式不支持租户生命周期"))
+		return false
+	}
+	return true
+}
+
+// getTenantLifecycle 租户当前的生命周期状态和最近的变更记录
+func getTenantLifecycle(w http.ResponseWriter, r *http.Request) {
+	if !lifecycleEnabled(w, r) {
+		return
+	}
+	id, err := parseMerchantID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "tenant.lifecycle_failed", err)
+		return
+	}
+
+	l, err := lifecycleService.Get(r.Context(), id)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "tenant.lifecycle_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "tenant.lifecycle_found", l, id, l.State)
+}
+
+// transitionTenantLifecycle 变更租户的生命周期，变更记录写入 tenant_lifecycle_event
+func transitionTenantLifecycle(w http.ResponseWriter, r *http.Request) {
+	if !lifecycleEnabled(w, r) {
+		return
+	}
+	id, err := parseMerchantID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "tenant.transition_failed", err)
+		return
+	}
+	var req services.TenantLifecycleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		err = fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+		respondError(w, r, errorStatus(err), "tenant.transition_failed", err)
+		return
+	}
+
+	l, err := lifecycleService.Transition(r.Context(), id, req)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "tenant.transition_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "tenant.transitioned", l, id, l.State)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/services"
+	"timezone-saas-demo/testsupport"
+)
+
+// lifecycleTestRouter 使用内存仓储的开通和生命周期服务，按 setupRoutes 的顺序挂载租户、API 密钥和生命周期中间件，
+// 接口本身只返回 200
+func lifecycleTestRouter(t *testing.T) (*mux.Router, *testsupport.Fakes) {
+	t.Helper()
+	fakes := testsupport.NewFakes()
+	prevLifecycle, prevProvisioning := lifecycleService, provisioningService
+	lifecycleService, provisioningService = fakes.LifecycleService(), fakes.ProvisioningService()
+	t.Cleanup(func() { lifecycleService, provisioningService = prevLifecycle, prevProvisioning })
+
+	router := mux.NewRouter()
+	router.Use(tenantMiddleware)
+	api := router.PathPrefix("/api").Subrouter()
+	api.Use(apiKeyMiddleware)
+	api.Use(lifecycleMiddleware)
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	api.HandleFunc("/timezone/orders", ok).Methods("GET")
+	api.HandleFunc("/merchants/{id}/now", ok).Methods("GET")
+	api.HandleFunc("/billing/merchants/{id}/periods", ok).Methods("GET")
+	return router, fakes
+}
+
+// provisionTestTenant 开通一个租户，返回商户ID和 API 密钥
+func provisionTestTenant(t *testing.T, name, code string) (int, string) {
+	t.Helper()
+	p, err := provisioningService.Provision(context.Background(), services.TenantProvisionRequest{
+		OnboardRequest: services.OnboardRequest{Name: name, Code: code, Country: "DE", City: "Berlin"},
+	})
+	if err != nil {
+		t.Fatalf("开通租户 %s 失败: %v", name, err)
+	}
+	return p.Merchant.ID, p.APIKey.Secret
+}
+
+// TestLifecycleMiddleware 请求涉及的每个商户都按生命周期拦截：API 密钥所属的租户、路径、merchant_id 和 merchants 列表
+func TestLifecycleMiddleware(t *testing.T) {
+	router, _ := lifecycleTestRouter(t)
+	suspended, suspendedKey := provisionTestTenant(t, "暂停的书店", "LC_SUSPENDED")
+	active, activeKey := provisionTestTenant(t, "正常的书店", "LC_ACTIVE")
+	archived, _ := provisionTestTenant(t, "归档的书店", "LC_ARCHIVED")
+	for id, state := range map[int]string{suspended: models.TenantLifecycleSuspended, archived: models.TenantLifecycleArchived} {
+		if _, err := lifecycleService.Transition(context.Background(), id, services.TenantLifecycleRequest{State: state}); err != nil {
+			t.Fatalf("变更商户 %d 为 %s 失败: %v", id, state, err)
+		}
+	}
+
+	cases := []struct {
+		name    string
+		path    string
+		headers map[string]string
+		want    int
+	}{
+		{"暂停租户的 API 密钥", "/api/timezone/orders", map[string]string{"X-API-Key": suspendedKey}, http.StatusPaymentRequired},
+		{"暂停租户的 API 密钥查询其他商户", fmt.Sprintf("/api/timezone/orders?merchant_id=%d", active), map[string]string{"X-API-Key": suspendedKey}, http.StatusPaymentRequired},
+		{"正常租户的 API 密钥", "/api/timezone/orders", map[string]string{"X-API-Key": activeKey}, http.StatusOK},
+		{"X-Tenant-ID 为暂停的商户", "/api/timezone/orders", map[string]string{"X-Tenant-ID": fmt.Sprint(suspended)}, http.StatusPaymentRequired},
+		{"路径中的暂停商户", fmt.Sprintf("/api/merchants/%d/now", suspended), nil, http.StatusPaymentRequired},
+		{"路径中的归档商户", fmt.Sprintf("/api/merchants/%d/now", archived), nil, http.StatusForbidden},
+		{"merchant_id 为暂停的商户", fmt.Sprintf("/api/timezone/orders?merchant_id=%d", suspended), nil, http.StatusPaymentRequired},
+		{"merchants 列表后面的暂停商户", fmt.Sprintf("/api/timezone/orders?merchants=%d,%d", active, suspended), map[string]string{"X-API-Key": activeKey}, http.StatusPaymentRequired},
+		{"merchants 列表中的归档商户", fmt.Sprintf("/api/timezone/orders?merchants=%d&merchants=%d", active, archived), nil, http.StatusForbidden},
+		{"merchants 列表都正常", fmt.Sprintf("/api/timezone/orders?merchants=%d", active), nil, http.StatusOK},
+		{"merchants 格式错误", "/api/timezone/orders?merchants=1,abc", nil, http.StatusBadRequest},
+		{"不存在的商户由接口处理", "/api/merchants/999999/now", nil, http.StatusOK},
+		{"不涉及商户", "/api/timezone/orders", nil, http.StatusOK},
+		{"暂停租户仍可查看账单", fmt.Sprintf("/api/billing/merchants/%d/periods", suspended), map[string]string{"X-API-Key": suspendedKey}, http.StatusOK},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, c.path, nil)
+			for k, v := range c.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != c.want {
+				t.Errorf("GET %s 状态码 = %d, 期望 %d: %s", c.path, rec.Code, c.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+// TestLifecycleMiddlewareFailsClosed 查询生命周期失败时拒绝请求，不能放行暂停的租户
+func TestLifecycleMiddlewareFailsClosed(t *testing.T) {
+	router, fakes := lifecycleTestRouter(t)
+	id, key := provisionTestTenant(t, "暂停的书店", "LC_SUSPENDED")
+	if _, err := lifecycleService.Transition(context.Background(), id, services.TenantLifecycleRequest{State: models.TenantLifecycleSuspended}); err != nil {
+		t.Fatalf("暂停商户失败: %v", err)
+	}
+	// 换一个服务实例，丢弃 Transition 写入的缓存
+	lifecycleService = fakes.LifecycleService()
+	fakes.Lifecycle.Err = errors.New("数据库连接中断")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/timezone/orders", nil)
+	req.Header.Set("X-API-Key", key)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("查询生命周期失败时状态码 = %d, 期望 %d: %s", rec.Code, http.StatusInternalServerError, rec.Body.String())
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"timezone-saas-demo/services"
+)
+
+// getLoadTestProfile 导出按本实例 API 用量生成的压测场景，供在预发环境重放与生产同分布的流量
+// format：json（默认，用量统计）、k6（k6 脚本）或 vegeta（vegeta 目标文件）；
+// base_url 压测目标地址（默认本服务地址），rate 每秒请求数，duration k6 场景时长，requests vegeta 请求数，seed vegeta 随机种子
+func getLoadTestProfile(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" || format == "json" {
+		profile := usageMeter.Profile()
+		respondSuccess(w, r, http.StatusOK, "admin.loadtest_profile", profile, len(profile.Routes), profile.TotalRequests)
+		return
+	}
+	if format != "k6" && format != "vegeta" {
+		respondError(w, r, http.StatusBadRequest, "admin.loadtest_profile_failed", fmt.Errorf("%w: format 应为 json、k6 或 vegeta", services.ErrInvalidArgument))
+		return
+	}
+
+	opts := services.LoadTestOptions{BaseURL: query.Get("base_url")}
+	if opts.BaseURL == "" {
+		opts.BaseURL = requestScheme(r) + "://" + r.Host
+	}
+	var err error
+	if v := query.Get("rate"); v != "" {
+		if opts.Rate, err = strconv.Atoi(v); err != nil || opts.Rate <= 0 {
+			respondError(w, r, http.StatusBadRequest, "admin.loadtest_profile_failed", fmt.Errorf("%w: rate 应为正整数", services.ErrInvalidArgument))
+			return
+		}
+	}
+	if v := query.Get("duration"); v != "" {
+		if opts.Duration, err = time.ParseDuration(v); err != nil || opts.Duration <= 0 {
+			respondError(w, r, http.StatusBadRequest, "admin.loadtest_profile_failed", fmt.Errorf("%w: duration 格式错误，如 5m", services.ErrInvalidArgument))
+			return
+		}
+	}
+	if v := query.Get("requests"); v != "" {
+		if opts.Requests, err = strconv.Atoi(v); err != nil || opts.Requests <= 0 {
+			respondError(w, r, http.StatusBadRequest, "admin.loadtest_profile_failed", fmt.Errorf("%w: requests 应为正整数", services.ErrInvalidArgument))
+			return
+		}
+	}
+	if v := query.Get("seed"); v != "" {
+		if opts.Seed, err = strconv.ParseInt(v, 10, 64); err != nil {
+			respondError(w, r, http.StatusBadRequest, "admin.loadtest_profile_failed", fmt.Errorf("%w: seed 应为整数", services.ErrInvalidArgument))
+			return
+		}
+	}
+
+	// 导出的场景只包含 GET 请求，占比按 GET 请求计算
+	profile := usageMeter.Profile(http.MethodGet)
+	var body []byte
+	var contentType, filename string
+	if format == "k6" {
+		body, err = services.K6Script(profile, opts)
+		contentType, filename = "application/javascript; charset=utf-8", "loadtest.js"
+	} else {
+		body, err = services.VegetaTargets(profile, opts)
+		contentType, filename = "text/plain; charset=utf-8", "targets.txt"
+	}
+	if err != nil {
+		respondError(w, r, errorStatus(err), "admin.loadtest_profile_failed", err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// resetLoadTestProfile 清空 API 用量统计，例如在发布或流量模式变化后重新开始统计
+func resetLoadTestProfile(w http.ResponseWriter, r *http.Request) {
+	usageMeter.Reset()
+	respondSuccess(w, r, http.StatusOK, "admin.loadtest_profile_reset", nil)
+}
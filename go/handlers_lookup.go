@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"timezone-saas-demo/services"
+)
+
+// lookupTimezone 根据经纬度查询时区，供移动端和入驻流程按设备定位设置商户时区
+// lat、lon 必填，at=2024-08-19T00:00:00Z 指定计算本地时间的时刻，默认当前时间
+func lookupTimezone(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	lat, err := strconv.ParseFloat(query.Get("lat"), 64)
+	if err != nil {
+		respondLookupError(w, r, fmt.Errorf("%w: 无效的纬度 %q", services.ErrInvalidArgument, query.Get("lat")))
+		return
+	}
+	lon, err := strconv.ParseFloat(query.Get("lon"), 64)
+	if err != nil {
+		respondLookupError(w, r, fmt.Errorf("%w: 无效的经度 %q", services.ErrInvalidArgument, query.Get("lon")))
+		return
+	}
+
+	at := time.Now()
+	if value := query.Get("at"); value != "" && value != "now" {
+		if at, err = time.Parse(time.RFC3339, value); err != nil {
+			respondLookupError(w, r, fmt.Errorf("%w: 时间格式错误，应为 RFC3339", services.ErrInvalidArgument))
+			return
+		}
+	}
+
+	lookup, err := timezoneService.LookupTimezone(lat, lon, at)
+	if err != nil {
+		respondLookupError(w, r, err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "lookup.ok", lookup, lookup.Timezone, lookup.Source)
+}
+
+// respondLookupError 输出坐标查询的错误响应
+func respondLookupError(w http.ResponseWriter, r *http.Request, err error) {
+	respondError(w, r, errorStatus(err), "lookup.failed", err)
+}
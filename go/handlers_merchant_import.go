@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/services"
+)
+
+// maxMerchantImportBytes 批量导入请求体的上限，足够容纳 MaxMerchantImportRows 行
+const maxMerchantImportBytes = 8 << 20
+
+// exportMerchants 导出全部商户，format=csv 时返回可直接再次导入的 CSV 文件，默认为 JSON
+func exportMerchants(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		respondError(w, r, http.StatusBadRequest, "merchants.export_failed", fmt.Errorf("%w: format 应为 json 或 csv", services.ErrInvalidArgument))
+		return
+	}
+
+	records, err := onboardingService.ExportMerchants(r.Context())
+	if err != nil {
+		respondError(w, r, errorStatus(err), "merchants.export_failed", err)
+		return
+	}
+
+	if format != "csv" {
+		respondSuccess(w, r, http.StatusOK, "merchants.exported", records, len(records))
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="merchants-%s.csv"`, time.Now().UTC().Format("20060102")))
+	if err := services.WriteMerchantCSV(w, records); err != nil {
+		// 响应头已经写出，只能记录日志
+		log.Printf("导出商户 CSV 失败: %v", err)
+	}
+}
+
+// importMerchants 批量导入商户：请求体为 CSV（Content-Type: text/csv）或 JSON 数组
+// dry_run=true 时只返回逐行校验报告；否则有校验失败的行时整批拒绝，重复的商户跳过
+func importMerchants(w http.ResponseWriter, r *http.Request) {
+	dryRun := false
+	if s := r.URL.Query().Get("dry_run"); s != "" {
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "merchants.import_failed", fmt.Errorf("%w: dry_run 应为 true 或 false", services.ErrInvalidArgument))
+			return
+		}
+		dryRun = v
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxMerchantImportBytes)
+	var rows []models.MerchantImportRow
+	var err error
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/csv" {
+		rows, err = services.ReadMerchantCSV(body)
+	} else if err = json.NewDecoder(body).Decode(&rows); err != nil {
+		err = fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+	}
+	if err != nil {
+		respondError(w, r, errorStatus(err), "merchants.import_failed", err)
+		return
+	}
+
+	report, err := onboardingService.ImportMerchants(r.Context(), rows, dryRun)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "merchants.import_failed", err)
+		return
+	}
+
+	if report.DryRun {
+		respondSuccess(w, r, http.StatusOK, "merchants.import_validated", report, report.Total, report.Valid, report.Duplicates, report.Invalid)
+		return
+	}
+	respondSuccess(w, r, http.StatusCreated, "merchants.imported", report, report.Created, report.Duplicates)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/services"
+)
+
+// getTenantQueryStats 各租户分析查询的并发和排队统计
+func getTenantQueryStats(w http.ResponseWriter, r *http.Request) {
+	stats := timezoneService.TenantQueryStats()
+	respondSuccess(w, r, http.StatusOK, "metrics.tenants", stats, len(stats))
+}
+
+// statusRecorder 记录响应状态码，未显式调用 WriteHeader 时为 200
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// requestStatsMiddleware 按租户统计 API 请求数和错误数，供 /api/admin/overview 使用；
+// 同时统计响应时间供 SLA 报告使用，按路由模板统计用量供导出压测场景使用
+func requestStatsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		now := time.Now()
+		tenant := services.TenantFromContext(r.Context())
+		requestCounter.Record(tenant, rec.status, now)
+		if slaTracked(r) {
+			slaMonitor.Record(tenant, rec.status, now.Sub(start), now)
+			if route := mux.CurrentRoute(r); route != nil {
+				if path, err := route.GetPathTemplate(); err == nil {
+					usageMeter.Record(r.Method, path, mux.Vars(r), r.URL.Query(), tenant)
+				}
+			}
+		}
+	})
+}
+
+// slaTracked 请求是否计入 SLA：管理接口和健康检查不面向租户，带 wait 的 /api/changes 会挂起等待新变更，都不计入
+func slaTracked(r *http.Request) bool {
+	path := r.URL.Path
+	switch {
+	case strings.HasPrefix(path, "/api/admin/"), strings.HasPrefix(path, "/api/health"):
+		return false
+	case path == "/api/changes" && r.URL.Query().Get("wait") != "":
+		return false
+	}
+	return true
+}
+
+// getLoadShedStatus 过载保护的当前级别、最近一次采样的指标、策略和各优先级被拒绝的请求数
+func getLoadShedStatus(w http.ResponseWriter, r *http.Request) {
+	status := loadShedder.Status()
+	if status == nil {
+		respondError(w, r, http.StatusForbidden, "load_shed.disabled", errors.New("过载保护未启用（LOAD_SHED_INTERVAL=0）"))
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "load_shed.status", status, status.Level, status.Shed[services.LoadShedPriorityLow], status.Shed[services.LoadShedPriorityNormal])
+}
+
+// getSLAReport 各租户在滚动窗口内的响应时间分位数、错误率和预算状态；breached=true 只返回超出预算的租户
+func getSLAReport(w http.ResponseWriter, r *http.Request) {
+	report := slaMonitor.Report()
+	if r.URL.Query().Get("breached") == "true" {
+		breached := []models.TenantSLA{}
+		for _, t := range report.Tenants {
+			if t.Status == services.SLAStatusBreached {
+				breached = append(breached, t)
+			}
+		}
+		report.Tenants = breached
+	}
+	respondSuccess(w, r, http.StatusOK, "sla.report", report, len(report.Tenants), report.Window)
+}
+
+// getMerchantSLA 商户的 SLA 统计，按 X-Tenant-ID 等于商户ID的请求统计
+func getMerchantSLA(w http.ResponseWriter, r *http.Request) {
+	id, err := parseMerchantID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "sla.failed", err)
+		return
+	}
+
+	sla := slaMonitor.Tenant(strconv.Itoa(id))
+	respondSuccess(w, r, http.StatusOK, "sla.tenant", sla, id, sla.Status)
+}
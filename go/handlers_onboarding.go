@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"timezone-saas-demo/services"
+)
+
+// onboardMerchant 商户入驻：推断时区、校验营业时间、创建商户并写入默认报表和 Webhook 配置
+// 请求体中 dry_run=true 时只返回推断和校验结果，不创建商户；指定了 contact_email 时发送欢迎邮件
+func onboardMerchant(w http.ResponseWriter, r *http.Request) {
+	var req services.OnboardRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		err = fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+		respondError(w, r, errorStatus(err), "onboarding.failed", err)
+		return
+	}
+
+	result, err := onboardingService.Onboard(req)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "onboarding.failed", err)
+		return
+	}
+
+	if result.DryRun {
+		respondSuccess(w, r, http.StatusOK, "onboarding.validated", result, result.Merchant.Timezone, result.TimezoneSource)
+		return
+	}
+
+	// 欢迎邮件使用请求协商的语言，发送结果见 welcome_email
+	onboardingService.SendWelcome(r.Context(), result, negotiateLocale(w, r))
+	respondSuccess(w, r, http.StatusCreated, "onboarding.created", result, result.Merchant.Name, result.Merchant.ID, result.Merchant.Timezone)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/services"
+)
+
+// organizationMerchantsRequest PUT /api/admin/orgs/{id}/merchants 的请求体
+type organizationMerchantsRequest struct {
+	MerchantIDs []int `json:"merchant_ids"`
+}
+
+// organizationTokenRequest POST /api/admin/orgs/{id}/tokens 的请求体
+type organizationTokenRequest struct {
+	Name string `json:"name"`
+}
+
+// organizationMiddleware 校验 /api/orgs/{id} 的访问令牌：管理令牌可以访问任意组织，组织令牌只能访问所属组织
+func organizationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseOrganizationID(r)
+		if err != nil {
+			respondError(w, r, errorStatus(err), "orgs.unauthorized", err)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			respondError(w, r, http.StatusUnauthorized, "orgs.unauthorized", errors.New("缺少组织令牌"))
+			return
+		}
+		if adminToken := currentAdminToken(); adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		owner, err := organizationService.Authenticate(r.Context(), token)
+		if errors.Is(err, services.ErrUnauthorized) {
+			respondError(w, r, http.StatusUnauthorized, "orgs.unauthorized", err)
+			return
+		}
+		if err != nil {
+			respondError(w, r, errorStatus(err), "orgs.unauthorized", err)
+			return
+		}
+		if owner != id {
+			respondError(w, r, http.StatusForbidden, "orgs.forbidden", fmt.Errorf("组织令牌不属于组织 %d", id))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// parseOrganizationID 解析路径中的组织ID
+func parseOrganizationID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%w: 无效的组织ID %q", services.ErrInvalidArgument, mux.Vars(r)["id"])
+	}
+	return id, nil
+}
+
+// createOrganization 创建组织并划入门店
+func createOrganization(w http.ResponseWriter, r *http.Request) {
+	var req services.OrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		err = fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+		respondError(w, r, errorStatus(err), "orgs.create_failed", err)
+		return
+	}
+
+	org, err := organizationService.Create(r.Context(), req)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "orgs.create_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusCreated, "orgs.created", org, org.Name, len(org.MerchantIDs))
+}
+
+// listOrganizations 全部组织及其门店ID
+func listOrganizations(w http.ResponseWriter, r *http.Request) {
+	orgs, err := organizationService.List(r.Context())
+	if err != nil {
+		respondError(w, r, errorStatus(err), "orgs.list_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "orgs.listed", orgs, len(orgs))
+}
+
+// setOrganizationMerchants 替换组织的门店，已属于其他组织的商户返回 409
+func setOrganizationMerchants(w http.ResponseWriter, r *http.Request) {
+	id, err := parseOrganizationID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "orgs.update_failed", err)
+		return
+	}
+	var req organizationMerchantsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		err = fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+		respondError(w, r, errorStatus(err), "orgs.update_failed", err)
+		return
+	}
+
+	org, err := organizationService.SetMerchants(r.Context(), id, req.MerchantIDs)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "orgs.update_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "orgs.updated", org, org.Name, len(org.MerchantIDs))
+}
+
+// listOrganizationTokens 组织的全部令牌，不含明文
+func listOrganizationTokens(w http.ResponseWriter, r *http.Request) {
+	id, err := parseOrganizationID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "orgs.token_failed", err)
+		return
+	}
+	tokens, err := organizationService.Tokens(r.Context(), id)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "orgs.token_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "orgs.tokens", tokens, len(tokens))
+}
+
+// issueOrganizationToken 签发组织令牌，明文只在本次响应中返回
+func issueOrganizationToken(w http.ResponseWriter, r *http.Request) {
+	id, err := parseOrganizationID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "orgs.token_failed", err)
+		return
+	}
+	var req organizationTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		err = fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+		respondError(w, r, errorStatus(err), "orgs.token_failed", err)
+		return
+	}
+
+	token, err := organizationService.IssueToken(r.Context(), id, req.Name)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "orgs.token_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusCreated, "orgs.token_issued", token, token.Name)
+}
+
+// revokeOrganizationToken 吊销组织令牌
+func revokeOrganizationToken(w http.ResponseWriter, r *http.Request) {
+	id, err := parseOrganizationID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "orgs.token_failed", err)
+		return
+	}
+	tokenID, err := strconv.Atoi(mux.Vars(r)["token_id"])
+	if err != nil || tokenID <= 0 {
+		err = fmt.Errorf("%w: 无效的令牌ID %q", services.ErrInvalidArgument, mux.Vars(r)["token_id"])
+		respondError(w, r, errorStatus(err), "orgs.token_failed", err)
+		return
+	}
+
+	if err := organizationService.RevokeToken(r.Context(), id, tokenID); err != nil {
+		respondError(w, r, errorStatus(err), "orgs.token_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "orgs.token_revoked", nil, tokenID)
+}
+
+// getOrganization 组织及其门店详情
+func getOrganization(w http.ResponseWriter, r *http.Request) {
+	id, err := parseOrganizationID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "orgs.get_failed", err)
+		return
+	}
+	org, err := organizationService.Get(r.Context(), id)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "orgs.get_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "orgs.get", org, org.Name)
+}
+
+// getOrganizationAnalysis 组织全部门店在 date 的订单汇总
+// mode=local（默认）按每个门店自身的本地日期统计，mode=hq 按总部时区的日期统计；status=paid,shipped 只统计指定状态
+func getOrganizationAnalysis(w http.ResponseWriter, r *http.Request) {
+	id, err := parseOrganizationID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "orgs.analysis_failed", err)
+		return
+	}
+	query := r.URL.Query()
+	statuses, err := services.ParseStatuses(query.Get("status"))
+	if err != nil {
+		respondError(w, r, errorStatus(err), "orgs.analysis_failed", err)
+		return
+	}
+
+	analysis, err := organizationService.Analysis(r.Context(), id, query.Get("date"), query.Get("mode"), statuses)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "orgs.analysis_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "orgs.analysis", analysis, analysis.Name, analysis.Date, analysis.TotalOrders)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"timezone-saas-demo/services"
+)
+
+// defaultOverlapWindow 未指定 window 时的默认会议时长
+const defaultOverlapWindow = 60 * time.Minute
+
+// findOverlap 多商户营业时间重叠分析（会议时段查找）
+// merchants=1,2,3 商户ID；window=60m 会议时长；date 为参考时区的本地日期；
+// tz 为参考时区（默认第一个商户的时区）；step 候选间隔；limit 候选数量
+func findOverlap(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	merchantIDs, err := parseIDList(query.Get("merchants"))
+	if err != nil {
+		respondOverlapError(w, r, err)
+		return
+	}
+
+	opts := services.OverlapOptions{
+		Date:     query.Get("date"),
+		Timezone: query.Get("tz"),
+	}
+	if opts.Window, err = parseDurationParam("window", query.Get("window"), defaultOverlapWindow); err != nil {
+		respondOverlapError(w, r, err)
+		return
+	}
+	if opts.Step, err = parseDurationParam("step", query.Get("step"), 0); err != nil {
+		respondOverlapError(w, r, err)
+		return
+	}
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			opts.Limit = l
+		}
+	}
+
+	overlap, err := timezoneService.FindOverlap(merchantIDs, opts)
+	if err != nil {
+		respondOverlapError(w, r, err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "overlap.ok", overlap, overlap.Date, len(overlap.Overlaps), len(overlap.Candidates))
+}
+
+// respondOverlapError 返回重叠分析失败响应
+func respondOverlapError(w http.ResponseWriter, r *http.Request, err error) {
+	respondError(w, r, errorStatus(err), "overlap.failed", err)
+}
+
+// parseIDList 解析逗号分隔的ID列表，如 1,2,3
+func parseIDList(value string) ([]int, error) {
+	var ids []int
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("%w: 无效的ID %q", services.ErrInvalidArgument, part)
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%w: 缺少ID列表", services.ErrInvalidArgument)
+	}
+	return ids, nil
+}
+
+// parseDurationParam 解析 60m / 1h30m 形式的时长参数，为空时返回默认值
+func parseDurationParam(name, value string, defaultValue time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s 格式错误，应为 60m、1h30m 等形式", services.ErrInvalidArgument, name)
+	}
+	return d, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/services"
+)
+
+// getMerchantPeakHours 商户的高峰本地小时和建议排班时段：GET /api/timezone/merchants/{id}/peak-hours?weeks=4
+// weeks 统计周数（默认 4，最多 26），to 为统计的最后一个本地日期（默认商户本地的昨天），
+// confidence 为判定高峰的置信度（默认 0.95），status 与分析接口相同
+func getMerchantPeakHours(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		err = fmt.Errorf("%w: 无效的商户ID %q", services.ErrInvalidArgument, mux.Vars(r)["id"])
+		respondError(w, r, errorStatus(err), "peak_hours.failed", err)
+		return
+	}
+	query := r.URL.Query()
+	opts := services.PeakHourOptions{To: query.Get("to")}
+	if value := query.Get("weeks"); value != "" {
+		if opts.Weeks, err = strconv.Atoi(value); err != nil || opts.Weeks <= 0 {
+			err = fmt.Errorf("%w: 无效的周数 %q", services.ErrInvalidArgument, value)
+			respondError(w, r, errorStatus(err), "peak_hours.failed", err)
+			return
+		}
+	}
+	if value := query.Get("confidence"); value != "" {
+		if opts.Confidence, err = strconv.ParseFloat(value, 64); err != nil || opts.Confidence <= 0 {
+			err = fmt.Errorf("%w: 无效的置信度 %q", services.ErrInvalidArgument, value)
+			respondError(w, r, errorStatus(err), "peak_hours.failed", err)
+			return
+		}
+	}
+	if opts.Statuses, err = services.ParseStatuses(query.Get("status")); err != nil {
+		respondError(w, r, errorStatus(err), "peak_hours.failed", err)
+		return
+	}
+
+	report, err := timezoneService.GetPeakHours(r.Context(), id, opts)
+	if errors.Is(err, services.ErrOverloaded) {
+		respondError(w, r, errorStatus(err), "analysis.overloaded", err)
+		return
+	}
+	if err != nil {
+		respondError(w, r, errorStatus(err), "peak_hours.failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "peak_hours.ok", report, report.MerchantName, len(report.PeakHours))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/services"
+)
+
+// queryConsoleEnabled mock 模式没有数据库，SQL 控制台接口一律拒绝
+func queryConsoleEnabled(w http.ResponseWriter, r *http.Request) bool {
+	if queryConsoleService == nil {
+		respondError(w, r, http.StatusForbidden, "console.disabled", errors.New("mock 模式没有数据库，不支持 SQL 控制台"))
+		return false
+	}
+	return true
+}
+
+// runConsoleQuery 以只读角色执行一条 SELECT，结果受语句超时和行数上限约束
+func runConsoleQuery(w http.ResponseWriter, r *http.Request) {
+	if !queryConsoleEnabled(w, r) {
+		return
+	}
+	var req models.ConsoleQuery
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		err = fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+		respondError(w, r, errorStatus(err), "console.failed", err)
+		return
+	}
+	req.ClientAddr = clientIP(r)
+
+	result, err := queryConsoleService.Run(r.Context(), req)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "console.failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "console.executed", result, result.RowCount, result.DurationMs)
+}
+
+// getConsoleAudit SQL 控制台最近的审计记录，limit 默认 20
+func getConsoleAudit(w http.ResponseWriter, r *http.Request) {
+	if !queryConsoleEnabled(w, r) {
+		return
+	}
+	limit := 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+
+	audits, err := queryConsoleService.Audits(r.Context(), limit)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "console.audit_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "console.audit_listed", audits, len(audits))
+}
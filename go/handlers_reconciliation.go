@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"timezone-saas-demo/services"
+)
+
+// adjustRequest 迟到订单调整请求体，字段均可省略
+type adjustRequest struct {
+	Date       string `json:"date"`
+	MerchantID int    `json:"merchant_id"`
+	Reason     string `json:"reason"`
+	Operator   string `json:"operator"`
+}
+
+// getReconciliation 列出本地日期结账后才入库的迟到订单
+// date=2024-08-19 只看该本地日期，merchant_id 只看该商户，均可省略
+func getReconciliation(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	merchantID, err := parseOptionalMerchantID(query.Get("merchant_id"))
+	if err != nil {
+		respondError(w, r, errorStatus(err), "reconciliation.failed", err)
+		return
+	}
+
+	report, err := revenueCloseService.GetLateOrders(query.Get("date"), merchantID)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "reconciliation.failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "reconciliation.ok", report, report.TotalLate, report.Unadjusted)
+}
+
+// adjustLateOrders 为未调整的迟到订单追加日结调整记录，快照保持不变
+func adjustLateOrders(w http.ResponseWriter, r *http.Request) {
+	var req adjustRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			err = fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+			respondError(w, r, errorStatus(err), "reconciliation.adjust_failed", err)
+			return
+		}
+	}
+	if req.MerchantID < 0 {
+		err := fmt.Errorf("%w: 无效的商户ID %d", services.ErrInvalidArgument, req.MerchantID)
+		respondError(w, r, errorStatus(err), "reconciliation.adjust_failed", err)
+		return
+	}
+
+	result, err := revenueCloseService.AdjustLateOrders(req.Date, req.MerchantID, req.Reason, req.Operator)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "reconciliation.adjust_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "reconciliation.adjusted", result, result.Created)
+}
+
+// parseOptionalMerchantID 解析可选的商户ID，空字符串返回 0
+func parseOptionalMerchantID(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%w: 无效的商户ID %q", services.ErrInvalidArgument, value)
+	}
+	return id, nil
+}
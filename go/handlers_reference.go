@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/services"
+)
+
+// listReferenceCountries 国家参考数据（ISO 代码、默认时区、默认币种、周末），q 按名称或代码过滤
+func listReferenceCountries(w http.ResponseWriter, r *http.Request) {
+	countries := services.ReferenceCountries(r.URL.Query().Get("q"))
+	respondSuccess(w, r, http.StatusOK, "reference.countries", countries, len(countries))
+}
+
+// getReferenceCountry 按代码、英文名或中文名查询一个国家
+func getReferenceCountry(w http.ResponseWriter, r *http.Request) {
+	country, err := services.ReferenceCountry(mux.Vars(r)["code"])
+	if err != nil {
+		respondError(w, r, errorStatus(err), "reference.failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "reference.country", country, country.NameZH, country.Code)
+}
+
+// listReferenceCities 城市参考数据，country 按国家过滤，q 按名称过滤
+func listReferenceCities(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	cities, err := services.ReferenceCities(query.Get("country"), query.Get("q"))
+	if err != nil {
+		respondError(w, r, errorStatus(err), "reference.failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "reference.cities", cities, len(cities))
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/services"
+)
+
+// getOrderRefunds 订单及其退款记录
+func getOrderRefunds(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		err = fmt.Errorf("%w: 无效的订单ID %q", services.ErrInvalidArgument, mux.Vars(r)["id"])
+		respondError(w, r, errorStatus(err), "refunds.list_failed", err)
+		return
+	}
+
+	order, err := refundService.ListRefunds(id)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "refunds.list_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "refunds.listed", order, order.OrderID, len(order.Refunds), order.RefundedAmount.String())
+}
+
+// createOrderRefund 为订单创建一笔（部分）退款
+// 请求体 amount 省略或为 0 时退还剩余全部金额，refunded_at 为 RFC3339 退款时间，默认当前时间
+func createOrderRefund(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		err = fmt.Errorf("%w: 无效的订单ID %q", services.ErrInvalidArgument, mux.Vars(r)["id"])
+		respondError(w, r, errorStatus(err), "refunds.create_failed", err)
+		return
+	}
+
+	var req services.RefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		err = fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+		respondError(w, r, errorStatus(err), "refunds.create_failed", err)
+		return
+	}
+
+	refund, err := refundService.CreateRefund(id, req)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "refunds.create_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusCreated, "refunds.created", refund, refund.OrderID, refund.Amount.String(), refund.Currency, refund.RefundLocalDate)
+}
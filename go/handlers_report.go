@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/services"
+)
+
+// parseReportID 解析路径中的报表定义ID
+func parseReportID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%w: 无效的报表ID %q", services.ErrInvalidArgument, mux.Vars(r)["id"])
+	}
+	return id, nil
+}
+
+// decodeReportDefinition 解析报表定义的请求体
+func decodeReportDefinition(r *http.Request) (services.ReportDefinitionRequest, error) {
+	var req services.ReportDefinitionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return req, fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+	}
+	return req, nil
+}
+
+// listReportDefinitions 全部报表定义
+func listReportDefinitions(w http.ResponseWriter, r *http.Request) {
+	defs, err := reportService.Definitions(r.Context())
+	if err != nil {
+		respondError(w, r, errorStatus(err), "reports.list_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "reports.listed", defs, len(defs))
+}
+
+// createReportDefinition 保存报表定义，schedule 不为空时按报表时区定时执行
+func createReportDefinition(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeReportDefinition(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "reports.save_failed", err)
+		return
+	}
+	def, err := reportService.CreateDefinition(r.Context(), req)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "reports.save_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusCreated, "reports.created", def, def.Name)
+}
+
+// getReportDefinition 单个报表定义
+func getReportDefinition(w http.ResponseWriter, r *http.Request) {
+	id, err := parseReportID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "reports.get_failed", err)
+		return
+	}
+	def, err := reportService.Definition(r.Context(), id)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "reports.get_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "reports.get", def, def.Name)
+}
+
+// updateReportDefinition 覆盖报表定义，重新计算下一次执行时刻
+func updateReportDefinition(w http.ResponseWriter, r *http.Request) {
+	id, err := parseReportID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "reports.save_failed", err)
+		return
+	}
+	req, err := decodeReportDefinition(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "reports.save_failed", err)
+		return
+	}
+	def, err := reportService.UpdateDefinition(r.Context(), id, req)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "reports.save_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "reports.updated", def, def.Name)
+}
+
+// deleteReportDefinition 删除报表定义及其执行记录
+func deleteReportDefinition(w http.ResponseWriter, r *http.Request) {
+	id, err := parseReportID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "reports.delete_failed", err)
+		return
+	}
+	if err := reportService.DeleteDefinition(r.Context(), id); err != nil {
+		respondError(w, r, errorStatus(err), "reports.delete_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "reports.deleted", nil, id)
+}
+
+// runReport 立即执行报表，生成失败时执行记录的 status 为 failed
+func runReport(w http.ResponseWriter, r *http.Request) {
+	id, err := parseReportID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "reports.run_failed", err)
+		return
+	}
+	run, err := reportService.Execute(r.Context(), id)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "reports.run_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusCreated, "reports.run", run, run.RunID, run.Status, run.From, run.To)
+}
+
+// listReportRuns 报表最近的执行记录，limit 默认 20
+func listReportRuns(w http.ResponseWriter, r *http.Request) {
+	id, err := parseReportID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "reports.runs_failed", err)
+		return
+	}
+	limit := 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+
+	runs, err := reportService.Runs(r.Context(), id, limit)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "reports.runs_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "reports.runs", runs, len(runs))
+}
+
+// getReportArtifact 下载一次执行生成的结果文件
+// 结果文件生成后不再变化，ETag 为内容的摘要（强校验），支持 Range 断点续传：客户端带 If-Range 续传时
+// 文件未变化返回 206 和请求的区间，否则返回完整文件；If-None-Match 命中时返回 304
+func getReportArtifact(w http.ResponseWriter, r *http.Request) {
+	runID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil || runID <= 0 {
+		err = fmt.Errorf("%w: 无效的执行记录ID %q", services.ErrInvalidArgument, mux.Vars(r)["id"])
+		respondError(w, r, errorStatus(err), "reports.artifact_failed", err)
+		return
+	}
+	run, artifact, contentType, err := reportService.Artifact(r.Context(), runID)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "reports.artifact_failed", err)
+		return
+	}
+
+	var modified time.Time
+	if run.FinishedAt != nil {
+		modified = *run.FinishedAt
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="report-%d-%s_%s.%s"`, run.DefinitionID, run.From, run.To, run.Format))
+	w.Header().Set("ETag", artifactETag(run.RunID, artifact))
+	http.ServeContent(w, r, "", modified, bytes.NewReader(artifact))
+}
+
+// artifactETag 结果文件的强 ETag：执行记录ID加内容 SHA-256 的前 16 字节
+func artifactETag(runID int64, artifact []byte) string {
+	sum := sha256.Sum256(artifact)
+	return fmt.Sprintf(`"report-%d-%x"`, runID, sum[:16])
+}
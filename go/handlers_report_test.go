@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/services"
+	"timezone-saas-demo/testsupport"
+)
+
+// artifactTestRouter 使用内存报表仓储，写入一次已完成的 CSV 执行，返回下载路由、执行记录ID和结果文件
+func artifactTestRouter(t *testing.T, artifact string) (*mux.Router, int64) {
+	t.Helper()
+	fakes := testsupport.NewFakes()
+	prev := reportService
+	reportService = fakes.ReportService(nil)
+	t.Cleanup(func() { reportService = prev })
+
+	ctx := context.Background()
+	started := time.Date(2024, 9, 8, 4, 0, 0, 0, time.UTC)
+	run := &models.ReportRun{DefinitionID: 3, TriggeredBy: "manual", Status: services.ReportStatusRunning, From: "2024-09-01", To: "2024-09-07", Format: services.ReportFormatCSV, StartedAt: started}
+	if err := fakes.Reports.CreateRun(ctx, run); err != nil {
+		t.Fatalf("写入执行记录失败: %v", err)
+	}
+	finished := started.Add(time.Minute)
+	run.Status, run.FinishedAt, run.ArtifactSize = services.ReportStatusCompleted, &finished, int64(len(artifact))
+	if err := fakes.Reports.FinishRun(ctx, run, []byte(artifact)); err != nil {
+		t.Fatalf("保存结果文件失败: %v", err)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/reports/runs/{id:[0-9]+}/artifact", getReportArtifact).Methods("GET", "HEAD")
+	return router, run.RunID
+}
+
+// TestGetReportArtifact 结果文件下载的 Range、If-Range、If-None-Match 和 HEAD
+func TestGetReportArtifact(t *testing.T) {
+	const artifact = "local_date,orders,amount\n2024-09-01,12,340.50\n2024-09-02,8,120.00\n"
+	router, runID := artifactTestRouter(t, artifact)
+	path := "/api/reports/runs/" + strconv.FormatInt(runID, 10) + "/artifact"
+	etag := artifactETag(runID, []byte(artifact))
+	size := strconv.Itoa(len(artifact))
+
+	cases := []struct {
+		name         string
+		method       string
+		headers      map[string]string
+		status       int
+		body         string
+		contentRange string
+	}{
+		{"完整文件", http.MethodGet, nil, http.StatusOK, artifact, ""},
+		{"单个区间", http.MethodGet, map[string]string{"Range": "bytes=0-9"}, http.StatusPartialContent, artifact[:10], "bytes 0-9/" + size},
+		{"从中间续传", http.MethodGet, map[string]string{"Range": "bytes=25-"}, http.StatusPartialContent, artifact[25:], "bytes 25-" + strconv.Itoa(len(artifact)-1) + "/" + size},
+		{"末尾若干字节", http.MethodGet, map[string]string{"Range": "bytes=-7"}, http.StatusPartialContent, artifact[len(artifact)-7:], "bytes " + strconv.Itoa(len(artifact)-7) + "-" + strconv.Itoa(len(artifact)-1) + "/" + size},
+		{"区间超出文件", http.MethodGet, map[string]string{"Range": "bytes=1000-"}, http.StatusRequestedRangeNotSatisfiable, "", "bytes */" + size},
+		{"If-Range 与 ETag 一致", http.MethodGet, map[string]string{"Range": "bytes=0-9", "If-Range": etag}, http.StatusPartialContent, artifact[:10], "bytes 0-9/" + size},
+		{"If-Range 为过期的 ETag", http.MethodGet, map[string]string{"Range": "bytes=0-9", "If-Range": `"report-1-00000000000000000000000000000000"`}, http.StatusOK, artifact, ""},
+		{"If-Range 为弱 ETag", http.MethodGet, map[string]string{"Range": "bytes=0-9", "If-Range": "W/" + etag}, http.StatusOK, artifact, ""},
+		{"If-Range 为生成前的时间", http.MethodGet, map[string]string{"Range": "bytes=0-9", "If-Range": "Sun, 01 Sep 2024 00:00:00 GMT"}, http.StatusOK, artifact, ""},
+		{"If-None-Match 命中", http.MethodGet, map[string]string{"If-None-Match": etag}, http.StatusNotModified, "", ""},
+		{"If-None-Match 命中列表中的一项", http.MethodGet, map[string]string{"If-None-Match": `"other", ` + etag}, http.StatusNotModified, "", ""},
+		{"If-None-Match 弱比较", http.MethodGet, map[string]string{"If-None-Match": "W/" + etag}, http.StatusNotModified, "", ""},
+		{"If-None-Match 为 *", http.MethodGet, map[string]string{"If-None-Match": "*"}, http.StatusNotModified, "", ""},
+		{"If-None-Match 不一致", http.MethodGet, map[string]string{"If-None-Match": `"other"`}, http.StatusOK, artifact, ""},
+		{"If-None-Match 命中时忽略 Range", http.MethodGet, map[string]string{"If-None-Match": etag, "Range": "bytes=0-9"}, http.StatusNotModified, "", ""},
+		{"HEAD", http.MethodHead, nil, http.StatusOK, "", ""},
+		{"HEAD 单个区间", http.MethodHead, map[string]string{"Range": "bytes=0-9"}, http.StatusPartialContent, "", "bytes 0-9/" + size},
+		{"HEAD If-None-Match 命中", http.MethodHead, map[string]string{"If-None-Match": etag}, http.StatusNotModified, "", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(c.method, path, nil)
+			for k, v := range c.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != c.status {
+				t.Fatalf("%s 状态码 = %d, 期望 %d: %s", c.method, rec.Code, c.status, rec.Body.String())
+			}
+			if c.status != http.StatusRequestedRangeNotSatisfiable && rec.Header().Get("ETag") != etag {
+				t.Errorf("ETag = %q, 期望 %q", rec.Header().Get("ETag"), etag)
+			}
+			if got := rec.Header().Get("Content-Range"); got != c.contentRange {
+				t.Errorf("Content-Range = %q, 期望 %q", got, c.contentRange)
+			}
+			if c.status == http.StatusRequestedRangeNotSatisfiable {
+				return
+			}
+			if got := rec.Body.String(); got != c.body {
+				t.Errorf("响应体 = %q, 期望 %q", got, c.body)
+			}
+			if c.status == http.StatusNotModified {
+				return
+			}
+			if got := rec.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+				t.Errorf("Content-Type = %q", got)
+			}
+			if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="report-3-2024-09-01_2024-09-07.csv"` {
+				t.Errorf("Content-Disposition = %q", got)
+			}
+			if got := rec.Header().Get("Accept-Ranges"); got != "bytes" {
+				t.Errorf("Accept-Ranges = %q, 期望 bytes", got)
+			}
+			if got := rec.Header().Get("Last-Modified"); got != "Sun, 08 Sep 2024 04:01:00 GMT" {
+				t.Errorf("Last-Modified = %q", got)
+			}
+			wantLength := len(c.body)
+			if c.method == http.MethodHead {
+				wantLength = len(artifact)
+				if c.contentRange != "" {
+					wantLength = 10
+				}
+			}
+			if got := rec.Header().Get("Content-Length"); got != strconv.Itoa(wantLength) {
+				t.Errorf("Content-Length = %q, 期望 %d", got, wantLength)
+			}
+		})
+	}
+
+	t.Run("多个区间", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Range", "bytes=0-9,25-34")
+		req.Header.Set("If-Range", etag)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusPartialContent {
+			t.Fatalf("状态码 = %d, 期望 206", rec.Code)
+		}
+		mediaType, params, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+		if err != nil || mediaType != "multipart/byteranges" {
+			t.Fatalf("Content-Type = %q, 期望 multipart/byteranges", rec.Header().Get("Content-Type"))
+		}
+		reader := multipart.NewReader(rec.Body, params["boundary"])
+		want := []struct{ contentRange, body string }{
+			{"bytes 0-9/" + size, artifact[:10]},
+			{"bytes 25-34/" + size, artifact[25:35]},
+		}
+		for i := 0; ; i++ {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				if i != len(want) {
+					t.Errorf("区间数 = %d, 期望 %d", i, len(want))
+				}
+				break
+			}
+			if err != nil {
+				t.Fatalf("读取第 %d 个区间失败: %v", i+1, err)
+			}
+			if i >= len(want) {
+				t.Fatalf("多出的区间 %q", part.Header.Get("Content-Range"))
+			}
+			body, _ := io.ReadAll(part)
+			if part.Header.Get("Content-Range") != want[i].contentRange || string(body) != want[i].body {
+				t.Errorf("第 %d 个区间 = %q %q, 期望 %q %q", i+1, part.Header.Get("Content-Range"), body, want[i].contentRange, want[i].body)
+			}
+			if got := part.Header.Get("Content-Type"); got != "text/csv; charset=utf-8" {
+				t.Errorf("第 %d 个区间 Content-Type = %q", i+1, got)
+			}
+		}
+	})
+
+	t.Run("不存在的执行记录", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/reports/runs/999/artifact", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("状态码 = %d, 期望 404: %s", rec.Code, rec.Body.String())
+		}
+	})
+}
+
+// TestArtifactETag 强 ETag 随执行记录和内容变化
+func TestArtifactETag(t *testing.T) {
+	a := artifactETag(1, []byte("a,b\n1,2\n"))
+	if !strings.HasPrefix(a, `"report-1-`) || !strings.HasSuffix(a, `"`) || len(a) != len(`"report-1-`)+32+1 {
+		t.Errorf("ETag 格式 = %s", a)
+	}
+	if a != artifactETag(1, []byte("a,b\n1,2\n")) {
+		t.Error("相同的执行记录和内容应得到相同的 ETag")
+	}
+	if a == artifactETag(2, []byte("a,b\n1,2\n")) {
+		t.Error("不同执行记录的 ETag 应不同")
+	}
+	if a == artifactETag(1, []byte("a,b\n1,3\n")) {
+		t.Error("内容变化后 ETag 应不同")
+	}
+}
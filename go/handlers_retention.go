@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/services"
+)
+
+// retentionPolicyRequest PUT /api/admin/retention/{id} 的请求体
+type retentionPolicyRequest struct {
+	ArchiveAfterMonths int    `json:"archive_after_months"`
+	Target             string `json:"target"`
+	Operator           string `json:"operator"`
+}
+
+// retentionEnabled mock 模式的订单在内存中，没有归档服务，归档接口一律拒绝
+func retentionEnabled(w http.ResponseWriter, r *http.Request) bool {
+	if retentionService == nil {
+		respondError(w, r, http.StatusForbidden, "retention.disabled", errors.New("mock 模式的订单保存在内存中，不支持归档"))
+		return false
+	}
+	return true
+}
+
+// getRetention 全部保留策略和最近的归档记录，limit 默认 20
+func getRetention(w http.ResponseWriter, r *http.Request) {
+	if !retentionEnabled(w, r) {
+		return
+	}
+	limit := 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+
+	policies, err := retentionService.Policies(r.Context())
+	if err != nil {
+		respondError(w, r, errorStatus(err), "retention.list_failed", err)
+		return
+	}
+	runs, err := retentionService.Runs(r.Context(), limit)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "retention.list_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "retention.listed", models.RetentionOverview{Policies: policies, Runs: runs}, len(policies), len(runs))
+}
+
+// setRetentionPolicy 新增或覆盖商户的保留策略
+func setRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	if !retentionEnabled(w, r) {
+		return
+	}
+	id, err := parseMerchantID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "retention.update_failed", err)
+		return
+	}
+	var req retentionPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		err = fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+		respondError(w, r, errorStatus(err), "retention.update_failed", err)
+		return
+	}
+
+	policy, err := retentionService.SetPolicy(r.Context(), id, req.ArchiveAfterMonths, req.Target, req.Operator)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "retention.update_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "retention.updated", policy, policy.MerchantID, policy.ArchiveAfterMonths, policy.Target)
+}
+
+// deleteRetentionPolicy 删除商户的保留策略，已归档的订单不会恢复
+func deleteRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	if !retentionEnabled(w, r) {
+		return
+	}
+	id, err := parseMerchantID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "retention.delete_failed", err)
+		return
+	}
+
+	if err := retentionService.DeletePolicy(r.Context(), id); err != nil {
+		respondError(w, r, errorStatus(err), "retention.delete_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "retention.deleted", nil, id)
+}
+
+// runRetention 立即按保留策略归档一次，merchant_id 为空时处理所有配置了策略的商户；定时归档执行中时等待其完成
+func runRetention(w http.ResponseWriter, r *http.Request) {
+	if !retentionEnabled(w, r) {
+		return
+	}
+	merchantID := 0
+	if idStr := r.URL.Query().Get("merchant_id"); idStr != "" {
+		id, err := strconv.Atoi(idStr)
+		if err != nil || id <= 0 {
+			respondError(w, r, http.StatusBadRequest, "retention.run_failed", fmt.Errorf("%w: merchant_id 必须是正整数", services.ErrInvalidArgument))
+			return
+		}
+		merchantID = id
+	}
+
+	run, err := retentionService.Archive(r.Context(), services.RetentionTriggerManual, merchantID)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "retention.run_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "retention.completed", run, run.RunID, run.Status, run.ArchivedOrders)
+}
+
+// getRetentionRun 一次归档及其每个（商户，月份）的明细
+func getRetentionRun(w http.ResponseWriter, r *http.Request) {
+	if !retentionEnabled(w, r) {
+		return
+	}
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	run, err := retentionService.GetRun(r.Context(), id)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "retention.get_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "retention.found", run, run.RunID, len(run.Buckets))
+}
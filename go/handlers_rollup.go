@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"timezone-saas-demo/services"
+)
+
+// rollupEnabled mock 模式没有汇总表，汇总表接口一律拒绝
+func rollupEnabled(w http.ResponseWriter, r *http.Request) bool {
+	if rollupService == nil {
+		respondError(w, r, http.StatusForbidden, "rollup.disabled", errors.New("mock 模式的分析数据直接从内存计算，没有汇总表"))
+		return false
+	}
+	return true
+}
+
+// auditRollup 比对汇总表与分析视图，from/to 为本地日期，默认最近 7 天，也可以用 range（如 last_month）指定；limit 默认 100
+func auditRollup(w http.ResponseWriter, r *http.Request) {
+	if !rollupEnabled(w, r) {
+		return
+	}
+	limit := 0
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+
+	from, to, err := queryDateRange(r, time.UTC)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "rollup.audit_failed", err)
+		return
+	}
+
+	audit, err := rollupService.Audit(r.Context(), from, to, limit)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "rollup.audit_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "rollup.audited", audit, audit.From, audit.To, audit.DiscrepancyCount)
+}
+
+// rebuildRollup 从订单表重新计算汇总，merchant_id 为空时重建全部商户
+func rebuildRollup(w http.ResponseWriter, r *http.Request) {
+	if !rollupEnabled(w, r) {
+		return
+	}
+	merchantID := 0
+	if idStr := r.URL.Query().Get("merchant_id"); idStr != "" {
+		id, err := strconv.Atoi(idStr)
+		if err != nil || id <= 0 {
+			respondError(w, r, http.StatusBadRequest, "rollup.rebuild_failed", fmt.Errorf("%w: merchant_id 必须是正整数", services.ErrInvalidArgument))
+			return
+		}
+		merchantID = id
+	}
+
+	result, err := rollupService.Rebuild(r.Context(), merchantID)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "rollup.rebuild_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "rollup.rebuilt", result, result.Rows)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/services"
+)
+
+// savedViewsEnabled mock 模式没有筛选视图服务，视图接口和 view_id 参数一律拒绝
+func savedViewsEnabled(w http.ResponseWriter, r *http.Request) bool {
+	if savedViewService == nil {
+		respondError(w, r, http.StatusForbidden, "views.disabled", errors.New("mock 模式不支持保存的筛选视图"))
+		return false
+	}
+	return true
+}
+
+// withSavedView 请求带 view_id 时先按视图补充查询参数，请求中显式指定的参数优先
+func withSavedView(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		value := r.URL.Query().Get("view_id")
+		if value == "" {
+			h(w, r)
+			return
+		}
+		if !savedViewsEnabled(w, r) {
+			return
+		}
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			err = fmt.Errorf("%w: 无效的视图ID %q", services.ErrInvalidArgument, value)
+			respondError(w, r, errorStatus(err), "views.apply_failed", err)
+			return
+		}
+		query := r.URL.Query()
+		if _, err := savedViewService.Expand(r.Context(), id, query); err != nil {
+			respondError(w, r, errorStatus(err), "views.apply_failed", err)
+			return
+		}
+		r = r.Clone(r.Context())
+		r.URL.RawQuery = query.Encode()
+		h(w, r)
+	}
+}
+
+// parseSavedViewID 解析路径中的视图ID
+func parseSavedViewID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%w: 无效的视图ID %q", services.ErrInvalidArgument, mux.Vars(r)["id"])
+	}
+	return id, nil
+}
+
+// decodeSavedView 解析视图的请求体
+func decodeSavedView(r *http.Request) (services.SavedViewRequest, error) {
+	var req services.SavedViewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return req, fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+	}
+	return req, nil
+}
+
+// listSavedViews 当前租户（X-Tenant-ID）的视图，owner 指定时只返回该用户的视图
+func listSavedViews(w http.ResponseWriter, r *http.Request) {
+	if !savedViewsEnabled(w, r) {
+		return
+	}
+	views, err := savedViewService.Views(r.Context(), r.URL.Query().Get("owner"))
+	if err != nil {
+		respondError(w, r, errorStatus(err), "views.list_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "views.listed", views, len(views))
+}
+
+// createSavedView 为当前租户保存视图
+func createSavedView(w http.ResponseWriter, r *http.Request) {
+	if !savedViewsEnabled(w, r) {
+		return
+	}
+	req, err := decodeSavedView(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "views.save_failed", err)
+		return
+	}
+	view, err := savedViewService.CreateView(r.Context(), req)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "views.save_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusCreated, "views.created", view, view.Name, view.ID)
+}
+
+// getSavedView 单个视图，属于其他租户时返回 404
+func getSavedView(w http.ResponseWriter, r *http.Request) {
+	if !savedViewsEnabled(w, r) {
+		return
+	}
+	id, err := parseSavedViewID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "views.get_failed", err)
+		return
+	}
+	view, err := savedViewService.View(r.Context(), id)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "views.get_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "views.get", view, view.Name)
+}
+
+// updateSavedView 覆盖视图的名称和筛选条件，引用该视图的链接随之使用新的条件
+func updateSavedView(w http.ResponseWriter, r *http.Request) {
+	if !savedViewsEnabled(w, r) {
+		return
+	}
+	id, err := parseSavedViewID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "views.save_failed", err)
+		return
+	}
+	req, err := decodeSavedView(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "views.save_failed", err)
+		return
+	}
+	view, err := savedViewService.UpdateView(r.Context(), id, req)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "views.save_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "views.updated", view, view.Name)
+}
+
+// deleteSavedView 删除视图
+func deleteSavedView(w http.ResponseWriter, r *http.Request) {
+	if !savedViewsEnabled(w, r) {
+		return
+	}
+	id, err := parseSavedViewID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "views.delete_failed", err)
+		return
+	}
+	if err := savedViewService.DeleteView(r.Context(), id); err != nil {
+		respondError(w, r, errorStatus(err), "views.delete_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "views.deleted", nil, id)
+}
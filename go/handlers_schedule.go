@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"timezone-saas-demo/services"
+)
+
+// expandSchedule 重复规则展开
+// rule=FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR;BYHOUR=9（需 URL 编码）；merchant_id 或 timezone 指定时区；
+// from/to 为本地日期（包含两端），也可以用 range（如 this_month，按该时区的今天计算）指定；
+// gap=shift|skip；overlap=earlier|later|both；limit 最大返回数量；humanize=true 附加各次发生的相对描述
+func expandSchedule(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	humanize, err := queryHumanize(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "schedule.failed", err)
+		return
+	}
+
+	req := services.ScheduleRequest{
+		Rule:     query.Get("rule"),
+		Timezone: query.Get("timezone"),
+		From:     query.Get("from"),
+		To:       query.Get("to"),
+		Range:    query.Get("range"),
+		Gap:      query.Get("gap"),
+		Overlap:  query.Get("overlap"),
+	}
+	if idStr := query.Get("merchant_id"); idStr != "" {
+		id, err := strconv.Atoi(idStr)
+		if err != nil || id <= 0 {
+			err = fmt.Errorf("%w: 无效的商户ID %q", services.ErrInvalidArgument, idStr)
+			respondError(w, r, errorStatus(err), "schedule.failed", err)
+			return
+		}
+		req.MerchantID = id
+	}
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			req.Limit = l
+		}
+	}
+
+	expansion, err := timezoneService.ExpandSchedule(req)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "schedule.failed", err)
+		return
+	}
+	if humanize {
+		if err := services.HumanizeSchedule(expansion.Occurrences, expansion.Timezone, negotiateLocale(w, r), time.Now()); err != nil {
+			respondError(w, r, errorStatus(err), "schedule.failed", err)
+			return
+		}
+	}
+
+	respondSuccess(w, r, http.StatusOK, "schedule.ok", expansion, expansion.From, expansion.To, expansion.Count, expansion.Timezone)
+}
+
+// validateSchedule 校验定时规则并预览接下来的发生时刻（UTC 和本地时间）
+// expr 为 cron 表达式（如 CRON_TZ=Asia/Shanghai 0 9 * * 1-5、CRON_TZ=@merchant_local 30 8 * * MON）或类 RRULE 规则，需 URL 编码；
+// merchant_id 指定 @merchant_local 的商户，timezone 为表达式没有 CRON_TZ 时使用的时区；after 起始时刻（RFC3339，默认当前时间）；count 返回次数（默认 10）；
+// humanize=true 附加各次发生相对当前时间的描述
+func validateSchedule(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	humanize, err := queryHumanize(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "schedule.validate_failed", err)
+		return
+	}
+
+	req := services.SchedulePreviewRequest{
+		Expression: query.Get("expr"),
+		Timezone:   query.Get("timezone"),
+	}
+	if idStr := query.Get("merchant_id"); idStr != "" {
+		id, err := strconv.Atoi(idStr)
+		if err != nil || id <= 0 {
+			err = fmt.Errorf("%w: 无效的商户ID %q", services.ErrInvalidArgument, idStr)
+			respondError(w, r, errorStatus(err), "schedule.validate_failed", err)
+			return
+		}
+		req.MerchantID = id
+	}
+	if after := query.Get("after"); after != "" && after != "now" {
+		t, err := time.Parse(time.RFC3339, after)
+		if err != nil {
+			err = fmt.Errorf("%w: after 应为 RFC3339 格式，如 2024-03-01T00:00:00Z", services.ErrInvalidArgument)
+			respondError(w, r, errorStatus(err), "schedule.validate_failed", err)
+			return
+		}
+		req.After = t
+	}
+	if countStr := query.Get("count"); countStr != "" {
+		if c, err := strconv.Atoi(countStr); err == nil && c > 0 {
+			req.Count = c
+		}
+	}
+
+	preview, err := timezoneService.PreviewSchedule(req)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "schedule.validate_failed", err)
+		return
+	}
+	if humanize {
+		if err := services.HumanizeSchedule(preview.Occurrences, preview.Timezone, negotiateLocale(w, r), time.Now()); err != nil {
+			respondError(w, r, errorStatus(err), "schedule.validate_failed", err)
+			return
+		}
+	}
+
+	respondSuccess(w, r, http.StatusOK, "schedule.validate_ok", preview, preview.Expression, preview.Timezone, preview.Count)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/services"
+)
+
+// settingsUpdateRequest 修改商户配置的请求体，value 的类型由配置键决定
+type settingsUpdateRequest struct {
+	Value    json.RawMessage `json:"value"`
+	Operator string          `json:"operator"`
+}
+
+// merchantLocaleKey 请求上下文中商户配置的语言
+type merchantLocaleKey struct{}
+
+// merchantLocaleMiddleware 读取商户配置的语言，请求未指定 lang 和 Accept-Language 时按该语言渲染消息
+// 商户不存在或读取失败时不设置，由具体接口返回错误
+func merchantLocaleMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, err := strconv.Atoi(mux.Vars(r)["id"]); err == nil {
+			if code, err := services.GetSetting(settingsService, id, services.SettingLocale); err == nil {
+				r = r.WithContext(context.WithValue(r.Context(), merchantLocaleKey{}, code))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// merchantLocale 请求上下文中商户配置的语言，没有时为空
+func merchantLocale(r *http.Request) string {
+	code, _ := r.Context().Value(merchantLocaleKey{}).(string)
+	return code
+}
+
+// parseMerchantID 解析路径中的商户ID
+func parseMerchantID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%w: 无效的商户ID %q", services.ErrInvalidArgument, mux.Vars(r)["id"])
+	}
+	return id, nil
+}
+
+// listMerchantSettings 商户的全部配置，未单独设置的配置项返回默认值
+func listMerchantSettings(w http.ResponseWriter, r *http.Request) {
+	id, err := parseMerchantID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "settings.failed", err)
+		return
+	}
+
+	settings, err := settingsService.List(id)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "settings.failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "settings.listed", settings, id, len(settings))
+}
+
+// getMerchantSetting 商户的一项配置
+func getMerchantSetting(w http.ResponseWriter, r *http.Request) {
+	id, err := parseMerchantID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "settings.failed", err)
+		return
+	}
+
+	setting, err := settingsService.Get(id, mux.Vars(r)["key"])
+	if err != nil {
+		respondError(w, r, errorStatus(err), "settings.failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "settings.ok", setting, setting.Key)
+}
+
+// updateMerchantSetting 修改商户的一项配置
+func updateMerchantSetting(w http.ResponseWriter, r *http.Request) {
+	id, err := parseMerchantID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "settings.update_failed", err)
+		return
+	}
+
+	var req settingsUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Value) == 0 {
+		if err == nil {
+			err = fmt.Errorf("缺少 value")
+		}
+		err = fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+		respondError(w, r, errorStatus(err), "settings.update_failed", err)
+		return
+	}
+
+	setting, err := settingsService.Set(id, mux.Vars(r)["key"], req.Value, req.Operator)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "settings.update_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "settings.updated", setting, setting.Key)
+}
+
+// resetMerchantSetting 删除商户单独设置的配置，恢复默认值
+func resetMerchantSetting(w http.ResponseWriter, r *http.Request) {
+	id, err := parseMerchantID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "settings.update_failed", err)
+		return
+	}
+
+	setting, err := settingsService.Reset(id, mux.Vars(r)["key"], r.URL.Query().Get("operator"))
+	if err != nil {
+		respondError(w, r, errorStatus(err), "settings.update_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "settings.reset", setting, setting.Key)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/services"
+)
+
+// maxSyncBodyBytes 同步请求体的上限，足够容纳 MaxSyncMutations 个修改
+const maxSyncBodyBytes = 1 << 20
+
+// syncOrders 外勤平板的离线同步：推送离线期间的订单修改，返回每个修改的处理结果和 since 之后的变更
+// 请求体为 {"device_id":"tab-01","merchant_id":2,"client_time":"2024-08-19T10:00:00+09:00","since":0,"mutations":[...]}
+func syncOrders(w http.ResponseWriter, r *http.Request) {
+	var req models.SyncRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSyncBodyBytes)).Decode(&req); err != nil {
+		err = fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+		respondError(w, r, errorStatus(err), "sync.failed", err)
+		return
+	}
+
+	resp, err := syncService.Sync(r.Context(), req)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "sync.failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "sync.ok", resp, resp.Applied, resp.Merged, resp.Rejected, len(resp.Changes))
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/services"
+)
+
+// tenantDataEnabled mock 模式的数据在内存中，没有租户数据服务，导出和删除接口一律拒绝
+func tenantDataEnabled(w http.ResponseWriter, r *http.Request) bool {
+	if tenantDataService == nil {
+		respondError(w, r, http.StatusForbidden, "tenant.disabled", errors.New("mock 模式的数据保存在内存中，不支持导出和删除租户数据"))
+		return false
+	}
+	return true
+}
+
+// exportTenantData 以 JSON 文件下载商户的全部数据，各表的数据来自同一个数据库快照
+func exportTenantData(w http.ResponseWriter, r *http.Request) {
+	if !tenantDataEnabled(w, r) {
+		return
+	}
+	id, err := parseMerchantID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "tenant.export_failed", err)
+		return
+	}
+	if _, err := tenantDataService.Merchant(id); err != nil {
+		respondError(w, r, errorStatus(err), "tenant.export_failed", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="tenant-%d-%s.json"`, id, time.Now().UTC().Format("20060102")))
+	if err := tenantDataService.Export(r.Context(), id, w); err != nil {
+		// 响应头已经写出，只能记录日志
+		log.Printf("导出商户 %d 的数据失败: %v", id, err)
+	}
+}
+
+// eraseTenantData 删除或匿名化商户的全部数据，返回每张表的处理行数和残留行数
+// dry_run=true 时只统计会处理的行数；失败时数据库回滚，处理记录可以通过 erasures 接口查看
+func eraseTenantData(w http.ResponseWriter, r *http.Request) {
+	if !tenantDataEnabled(w, r) {
+		return
+	}
+	id, err := parseMerchantID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "tenant.erase_failed", err)
+		return
+	}
+	var req services.TenantErasureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		err = fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+		respondError(w, r, errorStatus(err), "tenant.erase_failed", err)
+		return
+	}
+
+	erasure, err := tenantDataService.Erase(r.Context(), id, req)
+	if err != nil {
+		if erasure != nil {
+			err = fmt.Errorf("处理记录 %d: %w", erasure.ID, err)
+		}
+		respondError(w, r, errorStatus(err), "tenant.erase_failed", err)
+		return
+	}
+
+	if erasure.DryRun {
+		respondSuccess(w, r, http.StatusOK, "tenant.erase_previewed", erasure, id, len(erasure.Steps))
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "tenant.erased", erasure, id, erasure.Mode, erasure.ID)
+}
+
+// listTenantErasures 最近的删除和匿名化记录，merchant_id 为空时返回全部商户，limit 默认 20
+func listTenantErasures(w http.ResponseWriter, r *http.Request) {
+	if !tenantDataEnabled(w, r) {
+		return
+	}
+	merchantID := 0
+	if idStr := r.URL.Query().Get("merchant_id"); idStr != "" {
+		id, err := strconv.Atoi(idStr)
+		if err != nil || id <= 0 {
+			respondError(w, r, http.StatusBadRequest, "tenant.list_failed", fmt.Errorf("%w: merchant_id 必须是正整数", services.ErrInvalidArgument))
+			return
+		}
+		merchantID = id
+	}
+	limit := 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+
+	erasures, err := tenantDataService.Erasures(r.Context(), merchantID, limit)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "tenant.list_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "tenant.erasures_listed", erasures, len(erasures))
+}
+
+// getTenantErasure 一次删除或匿名化的核验报告
+func getTenantErasure(w http.ResponseWriter, r *http.Request) {
+	if !tenantDataEnabled(w, r) {
+		return
+	}
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	erasure, err := tenantDataService.GetErasure(r.Context(), id)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "tenant.get_failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "tenant.erasure_found", erasure, erasure.ID, erasure.Status)
+}
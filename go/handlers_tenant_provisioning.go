@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/services"
+)
+
+// provisioningEnabled mock 模式没有租户开通服务，开通接口一律拒绝
+func provisioningEnabled(w http.ResponseWriter, r *http.Request) bool {
+	if provisioningService == nil {
+		respondError(w, r, http.StatusForbidden, "tenant.provision_disabled", errors.New("mock 模式不支持开通租户"))
+		return false
+	}
+	return true
+}
+
+// provisionTenant 在一个事务中开通租户，响应中的 api_key.secret 只返回这一次
+// 请求体中 dry_run=true 时只返回校验结果和将写入的配置，不创建商户
+func provisionTenant(w http.ResponseWriter, r *http.Request) {
+	if !provisioningEnabled(w, r) {
+		return
+	}
+	var req services.TenantProvisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		err = fmt.Errorf("%w: 请求体格式错误: %v", services.ErrInvalidArgument, err)
+		respondError(w, r, errorStatus(err), "tenant.provision_failed", err)
+		return
+	}
+
+	p, err := provisioningService.Provision(r.Context(), req)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "tenant.provision_failed", err)
+		return
+	}
+
+	if req.DryRun {
+		respondSuccess(w, r, http.StatusOK, "tenant.provision_validated", p, p.Merchant.Name, p.Isolation)
+		return
+	}
+	respondSuccess(w, r, http.StatusCreated, "tenant.provisioned", p, p.Merchant.Name, p.Merchant.ID, p.Isolation)
+}
+
+// apiKeyMiddleware 请求携带 X-API-Key 时按开通时签发的 API 密钥确定租户：
+// 密钥无效或已吊销时返回 401，同时携带的 X-Tenant-ID 与密钥所属商户不一致时返回 403，
+// 通过后租户为密钥所属的商户ID，替换 tenantMiddleware 按 X-Tenant-ID 标记的租户
+func apiKeyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimSpace(r.Header.Get("X-API-Key"))
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// mock 模式不签发 API 密钥，不能忽略请求头按默认租户处理
+		if provisioningService == nil {
+			respondError(w, r, http.StatusForbidden, "tenant.api_key_disabled", errors.New("mock 模式不支持 API 密钥"))
+			return
+		}
+
+		merchantID, err := provisioningService.Authenticate(r.Context(), key)
+		if errors.Is(err, services.ErrUnauthorized) {
+			respondError(w, r, http.StatusUnauthorized, "tenant.api_key_invalid", err)
+			return
+		}
+		if err != nil {
+			respondError(w, r, errorStatus(err), "tenant.api_key_invalid", err)
+			return
+		}
+		tenant := strconv.Itoa(merchantID)
+		if header := strings.TrimSpace(r.Header.Get("X-Tenant-ID")); header != "" && header != tenant {
+			respondError(w, r, http.StatusForbidden, "tenant.api_key_forbidden", fmt.Errorf("API 密钥属于租户 %s，与 X-Tenant-ID %s 不一致", tenant, header))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(services.WithTenant(r.Context(), tenant)))
+	})
+}
+
+// revokeTenantAPIKey 吊销租户的 API 密钥，之后携带该密钥的请求返回 401
+func revokeTenantAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !provisioningEnabled(w, r) {
+		return
+	}
+	merchantID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || merchantID <= 0 {
+		err = fmt.Errorf("%w: 无效的商户ID %q", services.ErrInvalidArgument, mux.Vars(r)["id"])
+		respondError(w, r, errorStatus(err), "tenant.api_key_revoke_failed", err)
+		return
+	}
+	keyID, err := strconv.Atoi(mux.Vars(r)["key_id"])
+	if err != nil || keyID <= 0 {
+		err = fmt.Errorf("%w: 无效的密钥ID %q", services.ErrInvalidArgument, mux.Vars(r)["key_id"])
+		respondError(w, r, errorStatus(err), "tenant.api_key_revoke_failed", err)
+		return
+	}
+
+	if err := provisioningService.RevokeAPIKey(r.Context(), merchantID, keyID); err != nil {
+		respondError(w, r, errorStatus(err), "tenant.api_key_revoke_failed", err)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "tenant.api_key_revoked", nil, keyID, merchantID)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http"
+
+	"timezone-saas-demo/tzdb"
+)
+
+// getTZDataInfo 构建时和运行时使用的 tzdata 版本
+func getTZDataInfo(w http.ResponseWriter, r *http.Request) {
+	info := tzdb.Detect()
+	version := info.RuntimeVersion
+	if version == "" {
+		version = "unknown"
+	}
+	respondSuccess(w, r, http.StatusOK, "tzdata.ok", info, version, info.Source)
+}
+
+// getTimezoneRules 时区的历史偏移切换
+// timezone 必填；from/to 为 UTC 日期，默认 1970-01-01 至明年 1 月 1 日
+func getTimezoneRules(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	rules, err := timezoneService.GetTimezoneRules(query.Get("timezone"), query.Get("from"), query.Get("to"))
+	if err != nil {
+		respondError(w, r, errorStatus(err), "rules.failed", err)
+		return
+	}
+
+	respondSuccess(w, r, http.StatusOK, "rules.ok", rules, rules.Timezone, rules.From, rules.To, rules.Count)
+}
@@ -0,0 +1,220 @@
+// Package ical 生成 iCalendar（RFC 5545）订阅源：VTIMEZONE 由 Go 加载的 tzdata 逐条生成偏移切换，
+// 与服务端换算本地时间使用的规则完全一致，日历客户端不依赖自带的时区库。
+package ical
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"timezone-saas-demo/tzdb"
+)
+
+const (
+	// maxLineOctets 内容行折行前的最大字节数（不含 CRLF）
+	maxLineOctets = 75
+	// maxTransitions VTIMEZONE 最多包含的偏移切换数
+	maxTransitions = 200
+
+	localLayout = "20060102T150405"
+	utcLayout   = "20060102T150405Z"
+	dateLayout  = "20060102"
+)
+
+// Calendar 一个日历，事件的本地时间都位于 Location
+type Calendar struct {
+	// ProdID 生成该日历的产品标识
+	ProdID string
+	// Name 日历名称（X-WR-CALNAME），订阅后显示在客户端
+	Name string
+	// Location 事件使用的时区，TZID 为其名称
+	Location *time.Location
+	// From、To VTIMEZONE 覆盖的范围，应包含全部事件（含重复规则展开后的发生）
+	From, To time.Time
+	// Refresh 建议客户端刷新订阅的间隔，为 0 时不指定
+	Refresh time.Duration
+	// Stamp 生成时间，作为各事件的 DTSTAMP
+	Stamp  time.Time
+	Events []Event
+}
+
+// Event 一个事件
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Categories  []string
+	// Start、End 事件的起止时间，AllDay 时只取本地日期，End 为结束日期的次日
+	Start, End time.Time
+	AllDay     bool
+	// RRule 重复规则（不含 RRULE: 前缀），UNTIL 应为 UTC 时刻
+	RRule string
+	// ExDates 排除的发生时刻，本地时间应与 Start 相同
+	ExDates []time.Time
+	// Transparent 不占用忙闲时间，如营业时间和节假日
+	Transparent bool
+}
+
+// Encode 按 RFC 5545 写出日历：CRLF 换行、文本转义、超过 75 字节的行按 UTF-8 字符边界折行
+func (c *Calendar) Encode(w io.Writer) error {
+	e := &encoder{w: w}
+	tzid := c.Location.String()
+	e.line("BEGIN:VCALENDAR")
+	e.line("VERSION:2.0")
+	e.line("PRODID:" + c.ProdID)
+	e.line("CALSCALE:GREGORIAN")
+	e.line("METHOD:PUBLISH")
+	if c.Name != "" {
+		e.line("X-WR-CALNAME:" + escapeText(c.Name))
+	}
+	e.line("X-WR-TIMEZONE:" + tzid)
+	if c.Refresh > 0 {
+		e.line("REFRESH-INTERVAL;VALUE=DURATION:" + formatDuration(c.Refresh))
+		e.line("X-PUBLISHED-TTL:" + formatDuration(c.Refresh))
+	}
+	c.encodeTimezone(e)
+	for _, event := range c.Events {
+		c.encodeEvent(e, event)
+	}
+	e.line("END:VCALENDAR")
+	return e.err
+}
+
+// encodeTimezone 写出 VTIMEZONE：From 时生效的规则作为第一个分量，此后每次偏移切换一个分量
+// 分量的 DTSTART 为切换前的本地时间（RFC 5545 3.6.5），不使用重复规则，客户端不需要推算
+func (c *Calendar) encodeTimezone(e *encoder) {
+	tzid := c.Location.String()
+	start := c.From.In(c.Location)
+	initial := tzdb.ZoneAt(start)
+	e.line("BEGIN:VTIMEZONE")
+	e.line("TZID:" + tzid)
+	e.line("X-LIC-LOCATION:" + tzid)
+	observance(e, start.Format(localLayout), initial, initial)
+	transitions, _ := tzdb.Transitions(c.Location, c.From, c.To, maxTransitions)
+	for _, t := range transitions {
+		before := t.At.Add(time.Duration(t.Before.OffsetSeconds) * time.Second).UTC()
+		observance(e, before.Format(localLayout), t.Before, t.After)
+	}
+	e.line("END:VTIMEZONE")
+}
+
+// observance 写出一个 STANDARD 或 DAYLIGHT 分量
+func observance(e *encoder, dtstart string, from, to tzdb.Zone) {
+	kind := "STANDARD"
+	if to.IsDST {
+		kind = "DAYLIGHT"
+	}
+	e.line("BEGIN:" + kind)
+	e.line("DTSTART:" + dtstart)
+	e.line("TZOFFSETFROM:" + formatOffset(from.OffsetSeconds))
+	e.line("TZOFFSETTO:" + formatOffset(to.OffsetSeconds))
+	if to.Abbreviation != "" {
+		e.line("TZNAME:" + escapeText(to.Abbreviation))
+	}
+	e.line("END:" + kind)
+}
+
+func (c *Calendar) encodeEvent(e *encoder, event Event) {
+	tzid := c.Location.String()
+	e.line("BEGIN:VEVENT")
+	e.line("UID:" + event.UID)
+	e.line("DTSTAMP:" + c.Stamp.UTC().Format(utcLayout))
+	if event.AllDay {
+		e.line("DTSTART;VALUE=DATE:" + event.Start.Format(dateLayout))
+		e.line("DTEND;VALUE=DATE:" + event.End.Format(dateLayout))
+	} else {
+		e.line("DTSTART;TZID=" + tzid + ":" + event.Start.In(c.Location).Format(localLayout))
+		e.line("DTEND;TZID=" + tzid + ":" + event.End.In(c.Location).Format(localLayout))
+	}
+	if event.RRule != "" {
+		e.line("RRULE:" + event.RRule)
+	}
+	if len(event.ExDates) > 0 {
+		dates := make([]string, len(event.ExDates))
+		for i, d := range event.ExDates {
+			dates[i] = d.In(c.Location).Format(localLayout)
+		}
+		e.line("EXDATE;TZID=" + tzid + ":" + strings.Join(dates, ","))
+	}
+	e.line("SUMMARY:" + escapeText(event.Summary))
+	if event.Description != "" {
+		e.line("DESCRIPTION:" + escapeText(event.Description))
+	}
+	if len(event.Categories) > 0 {
+		categories := make([]string, len(event.Categories))
+		for i, category := range event.Categories {
+			categories[i] = escapeText(category)
+		}
+		e.line("CATEGORIES:" + strings.Join(categories, ","))
+	}
+	if event.Transparent {
+		e.line("TRANSP:TRANSPARENT")
+	}
+	e.line("END:VEVENT")
+}
+
+// UTC 重复规则 UNTIL 使用的 UTC 时刻格式
+func UTC(t time.Time) string {
+	return t.UTC().Format(utcLayout)
+}
+
+// encoder 按行写出，记录第一个写入错误
+type encoder struct {
+	w   io.Writer
+	err error
+}
+
+// line 写出一个内容行，超过 75 字节时折行，续行以一个空格开头
+func (e *encoder) line(content string) {
+	if e.err != nil {
+		return
+	}
+	var b strings.Builder
+	limit := maxLineOctets
+	for len(content) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		b.WriteString(content[:cut])
+		b.WriteString("\r\n ")
+		content = content[cut:]
+		// 续行开头的空格占一个字节
+		limit = maxLineOctets - 1
+	}
+	b.WriteString(content)
+	b.WriteString("\r\n")
+	_, e.err = io.WriteString(e.w, b.String())
+}
+
+// escapeText 转义 TEXT 值中的反斜杠、分号、逗号和换行
+func escapeText(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(value)
+}
+
+// formatOffset 格式化为 +HHMM，秒不为 0 时为 +HHMMSS
+func formatOffset(offsetSeconds int) string {
+	sign := '+'
+	if offsetSeconds < 0 {
+		sign = '-'
+		offsetSeconds = -offsetSeconds
+	}
+	if s := offsetSeconds % 60; s != 0 {
+		return fmt.Sprintf("%c%02d%02d%02d", sign, offsetSeconds/3600, offsetSeconds%3600/60, s)
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, offsetSeconds/3600, offsetSeconds%3600/60)
+}
+
+// formatDuration 格式化为 PT12H、PT30M 形式，不足一分钟按一分钟
+func formatDuration(d time.Duration) string {
+	minutes := int(d / time.Minute)
+	switch {
+	case minutes <= 0:
+		return "PT1M"
+	case minutes%60 == 0:
+		return fmt.Sprintf("PT%dH", minutes/60)
+	}
+	return fmt.Sprintf("PT%dM", minutes)
+}
@@ -0,0 +1,251 @@
+package ical_test
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"timezone-saas-demo/ical"
+)
+
+var update = flag.Bool("update", false, "按当前输出重写 testdata 中的 golden 文件")
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("加载时区 %s 失败: %v", name, err)
+	}
+	return loc
+}
+
+// checkGolden 与 testdata 中的 golden 文件逐字节比较，-update 时改为写入
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("写入 %s 失败: %v", path, err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取 %s 失败: %v", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("输出与 %s 不一致（go test -run %s -update 重新生成）\n得到:\n%s", path, t.Name(), got)
+	}
+}
+
+// checkLines 每行以 CRLF 结尾、不超过 75 字节、不在 UTF-8 字符中间折行
+func checkLines(t *testing.T, data []byte) {
+	t.Helper()
+	if !bytes.HasSuffix(data, []byte("\r\n")) {
+		t.Errorf("输出应以 CRLF 结尾")
+	}
+	for i, line := range strings.Split(strings.TrimSuffix(string(data), "\r\n"), "\r\n") {
+		if strings.ContainsAny(line, "\r\n") {
+			t.Errorf("第 %d 行包含单独的 CR 或 LF: %q", i+1, line)
+		}
+		if len(line) > 75 {
+			t.Errorf("第 %d 行 %d 字节, 超过 75: %q", i+1, len(line), line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("第 %d 行在 UTF-8 字符中间折行: %q", i+1, line)
+		}
+	}
+}
+
+// unfold 展开折行（RFC 5545 3.1）
+func unfold(data []byte) []string {
+	return strings.Split(strings.TrimSuffix(strings.ReplaceAll(string(data), "\r\n ", ""), "\r\n"), "\r\n")
+}
+
+// TestEncodeGolden 完整的日历输出：夏令时切换的 VTIMEZONE、TZID 本地时间、UTC 的 DTSTAMP 和 UNTIL、
+// 全天事件、文本转义和折行
+func TestEncodeGolden(t *testing.T) {
+	berlin := mustLoad(t, "Europe/Berlin")
+	stamp := time.Date(2024, 9, 8, 4, 5, 6, 0, time.UTC)
+	cases := []struct {
+		name   string
+		golden string
+		cal    ical.Calendar
+	}{
+		{
+			name:   "柏林夏令时",
+			golden: "berlin.ics",
+			cal: ical.Calendar{
+				ProdID:   "-//timezone-saas-demo//test//ZH",
+				Name:     "柏林书店, Mitte; 分店",
+				Location: berlin,
+				From:     time.Date(2024, 3, 1, 0, 0, 0, 0, berlin),
+				To:       time.Date(2024, 11, 1, 0, 0, 0, 0, berlin),
+				Refresh:  12 * time.Hour,
+				Stamp:    stamp,
+				Events: []ical.Event{
+					{
+						UID:         "merchant-1-business-hours-1@timezone-saas-demo",
+						Summary:     "营业时间 09:00-19:00",
+						Description: "柏林书店的营业时间（Europe/Berlin），周末和节假日不营业。夏令时切换当天由日历客户端按 VTIMEZONE 换算本地时间。",
+						Categories:  []string{"营业时间"},
+						Start:       time.Date(2024, 3, 1, 9, 0, 0, 0, berlin),
+						End:         time.Date(2024, 3, 1, 19, 0, 0, 0, berlin),
+						RRule:       "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR;UNTIL=" + ical.UTC(time.Date(2024, 11, 1, 0, 0, 0, 0, berlin)),
+						ExDates:     []time.Time{time.Date(2024, 3, 29, 9, 0, 0, 0, berlin), time.Date(2024, 10, 3, 9, 0, 0, 0, berlin)},
+						Transparent: true,
+					},
+					{
+						UID:         "merchant-1-holiday-20241003@timezone-saas-demo",
+						Summary:     "节假日：Tag der Deutschen Einheit",
+						Categories:  []string{"节假日"},
+						Start:       time.Date(2024, 10, 3, 0, 0, 0, 0, time.UTC),
+						End:         time.Date(2024, 10, 4, 0, 0, 0, 0, time.UTC),
+						AllDay:      true,
+						Transparent: true,
+					},
+					{
+						// Start 为 UTC 时刻，按 Location 输出本地时间
+						UID:         "report-7-20241027T003000Z@timezone-saas-demo",
+						Summary:     `报表：a;b,c\d`,
+						Description: "第一行\n第二行\r\n第三行",
+						Categories:  []string{"报表", "a,b"},
+						Start:       time.Date(2024, 10, 27, 0, 30, 0, 0, time.UTC),
+						End:         time.Date(2024, 10, 27, 0, 45, 0, 0, time.UTC),
+					},
+				},
+			},
+		},
+		{
+			name:   "UTC",
+			golden: "utc.ics",
+			cal: ical.Calendar{
+				ProdID:   "-//timezone-saas-demo//test//ZH",
+				Location: time.UTC,
+				From:     time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC),
+				To:       time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC),
+				Stamp:    stamp,
+				Events: []ical.Event{{
+					UID:     "daily@timezone-saas-demo",
+					Summary: "daily " + strings.Repeat("x", 80),
+					Start:   time.Date(2024, 9, 1, 8, 0, 0, 0, time.UTC),
+					End:     time.Date(2024, 9, 1, 8, 15, 0, 0, time.UTC),
+					RRule:   "FREQ=DAILY;UNTIL=" + ical.UTC(time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)),
+				}},
+			},
+		},
+		{
+			// 1986-01-01 从 +0530 改为 +0545
+			name:   "加德满都非整点偏移",
+			golden: "kathmandu.ics",
+			cal: ical.Calendar{
+				ProdID:   "-//timezone-saas-demo//test//ZH",
+				Location: mustLoad(t, "Asia/Kathmandu"),
+				From:     time.Date(1985, 12, 1, 0, 0, 0, 0, time.UTC),
+				To:       time.Date(1986, 2, 1, 0, 0, 0, 0, time.UTC),
+				Refresh:  90 * time.Minute,
+				Stamp:    stamp,
+			},
+		},
+		{
+			// 1972-01-07 从 -00:44:30 改为 UTC，偏移带秒
+			name:   "蒙罗维亚带秒的偏移",
+			golden: "monrovia.ics",
+			cal: ical.Calendar{
+				ProdID:   "-//timezone-saas-demo//test//ZH",
+				Location: mustLoad(t, "Africa/Monrovia"),
+				From:     time.Date(1971, 12, 1, 0, 0, 0, 0, time.UTC),
+				To:       time.Date(1972, 6, 1, 0, 0, 0, 0, time.UTC),
+				Refresh:  30 * time.Second,
+				Stamp:    stamp,
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := c.cal.Encode(&buf); err != nil {
+				t.Fatalf("Encode 失败: %v", err)
+			}
+			checkLines(t, buf.Bytes())
+			checkGolden(t, c.golden, buf.Bytes())
+		})
+	}
+}
+
+// TestEncodeEscapingAndFolding 文本值的转义，以及折行展开后还原为原始内容行
+func TestEncodeEscapingAndFolding(t *testing.T) {
+	cases := []struct {
+		summary string
+		want    string
+		// physical 折行后的物理行数
+		physical int
+	}{
+		{"plain", "SUMMARY:plain", 1},
+		{`a\b`, `SUMMARY:a\\b`, 1},
+		{"a;b", `SUMMARY:a\;b`, 1},
+		{"a,b", `SUMMARY:a\,b`, 1},
+		{"a\nb", `SUMMARY:a\nb`, 1},
+		{"a\r\nb", `SUMMARY:a\nb`, 1},
+		{`\;,` + "\n", `SUMMARY:\\\;\,\n`, 1},
+		{"冒号: 不转义", "SUMMARY:冒号: 不转义", 1},
+		// 恰好 75 字节不折行，76 字节折行
+		{strings.Repeat("a", 67), "SUMMARY:" + strings.Repeat("a", 67), 1},
+		{strings.Repeat("a", 68), "SUMMARY:" + strings.Repeat("a", 68), 2},
+		// 按字符边界折行，不切开多字节字符
+		{strings.Repeat("时区", 40), "SUMMARY:" + strings.Repeat("时区", 40), 4},
+		{"a" + strings.Repeat("时", 30), "SUMMARY:a" + strings.Repeat("时", 30), 2},
+		// 转义后的长度决定折行：75、75（含开头空格）、40 字节
+		{strings.Repeat("a,", 60), "SUMMARY:" + strings.Repeat(`a\,`, 60), 3},
+	}
+	for _, c := range cases {
+		cal := ical.Calendar{
+			ProdID:   "-//test//ZH",
+			Location: time.UTC,
+			From:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			To:       time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+			Stamp:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			Events: []ical.Event{{
+				UID:     "e@test",
+				Summary: c.summary,
+				Start:   time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC),
+				End:     time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+			}},
+		}
+		var buf bytes.Buffer
+		if err := cal.Encode(&buf); err != nil {
+			t.Fatalf("Encode 失败: %v", err)
+		}
+		checkLines(t, buf.Bytes())
+		start := strings.Index(buf.String(), "SUMMARY:")
+		end := strings.Index(buf.String()[start:], "\r\nEND:VEVENT")
+		if physical := strings.Count(buf.String()[start:start+end], "\r\n") + 1; physical != c.physical {
+			t.Errorf("SUMMARY(%q) 折为 %d 行, 期望 %d", c.summary, physical, c.physical)
+		}
+		found := false
+		for _, line := range unfold(buf.Bytes()) {
+			if strings.HasPrefix(line, "SUMMARY:") {
+				found = true
+				if line != c.want {
+					t.Errorf("SUMMARY(%q) = %q, 期望 %q", c.summary, line, c.want)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("SUMMARY(%q) 缺失", c.summary)
+		}
+	}
+}
+
+// TestUTC UNTIL 等使用的 UTC 时刻与所在时区无关
+func TestUTC(t *testing.T) {
+	tokyo := mustLoad(t, "Asia/Tokyo")
+	if got := ical.UTC(time.Date(2024, 9, 8, 9, 0, 0, 0, tokyo)); got != "20240908T000000Z" {
+		t.Errorf("UTC = %s, 期望 20240908T000000Z", got)
+	}
+}
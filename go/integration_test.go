@@ -0,0 +1,73 @@
+//go:build integration
+
+package main
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"timezone-saas-demo/client"
+	"timezone-saas-demo/services"
+	"timezone-saas-demo/testsupport"
+)
+
+// TestIntegration 在临时 PostgreSQL（完整架构、示例数据和夏令时用例）上运行时区服务、HTTP 和客户端用例
+// 运行方式: go test -tags integration ./...，需要 docker
+func TestIntegration(t *testing.T) {
+	env := testsupport.NewIntegrationEnv(t)
+
+	t.Run("Service", func(t *testing.T) {
+		testsupport.RunServiceSuite(t, services.NewTimezoneService(env.DB))
+	})
+
+	// 按 serve 的方式连接同一个数据库创建全部服务和路由
+	dbConfig := env.Container.Config
+	t.Setenv("DB_HOST", dbConfig.Host)
+	t.Setenv("DB_PORT", strconv.Itoa(dbConfig.Port))
+	t.Setenv("DB_USER", dbConfig.User)
+	t.Setenv("DB_PASSWORD", dbConfig.Password)
+	t.Setenv("DB_NAME", dbConfig.DBName)
+	t.Setenv("DB_SSLMODE", dbConfig.SSLMode)
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	// 日结、归档、合成监控等后台任务会写入或修改数据，用例运行期间不启动
+	config.DailyCloseInterval = 0
+	config.ConsistencyCheckInterval = 0
+	config.ReportScheduleInterval = 0
+	config.AlertEvaluationInterval = 0
+	config.RetentionInterval = 0
+	config.TrialExpiryInterval = 0
+	config.JobPollInterval = 0
+	config.CanaryInterval = 0
+	config.PlanRegressionInterval = 0
+	config.LeaderElection = false
+
+	alerter, mailer, rotator, err := setupMonitors(config)
+	if err != nil {
+		t.Fatalf("初始化监控失败: %v", err)
+	}
+	if err := configureServer(config); err != nil {
+		t.Fatalf("配置服务失败: %v", err)
+	}
+	if err := connectServices(config, alerter, mailer, rotator); err != nil {
+		t.Fatalf("连接服务失败: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	router := setupRoutes()
+
+	t.Run("HTTP", func(t *testing.T) {
+		testsupport.RunHTTPSuite(t, router)
+	})
+	t.Run("Client", func(t *testing.T) {
+		server := httptest.NewServer(router)
+		defer server.Close()
+		c, err := client.New(server.URL)
+		if err != nil {
+			t.Fatalf("创建客户端失败: %v", err)
+		}
+		testsupport.RunClientSuite(t, c)
+	})
+}
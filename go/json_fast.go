@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"timezone-saas-demo/fastjson"
+)
+
+// JSON 编码方式，见 JSON_ENCODER
+const (
+	jsonEncoderStd  = "std"
+	jsonEncoderFast = "fast"
+)
+
+// appendFastResponse 按 APIResponse 的字段顺序编码响应，末尾带换行（与 json.Encoder 一致）
+// Data 不是 fastjson 支持的类型时返回 false，由调用方使用 encoding/json；分页信息较小，仍使用 encoding/json
+func appendFastResponse(buf []byte, response APIResponse) ([]byte, bool) {
+	if response.Data == nil {
+		return buf, false
+	}
+	start := len(buf)
+	buf = append(buf, `{"success":`...)
+	buf = strconv.AppendBool(buf, response.Success)
+	if response.Code != "" {
+		buf = append(buf, `,"code":`...)
+		buf = fastjson.AppendString(buf, response.Code)
+	}
+	buf = append(buf, `,"message":`...)
+	buf = fastjson.AppendString(buf, response.Message)
+	buf = append(buf, `,"data":`...)
+	buf, ok := fastjson.Append(buf, response.Data)
+	if !ok {
+		return buf[:start], false
+	}
+	if response.Meta != nil {
+		meta, err := json.Marshal(response.Meta)
+		if err != nil {
+			return buf[:start], false
+		}
+		buf = append(buf, `,"meta":`...)
+		buf = append(buf, meta...)
+	}
+	if response.Error != "" {
+		buf = append(buf, `,"error":`...)
+		buf = fastjson.AppendString(buf, response.Error)
+	}
+	return append(buf, '}', '\n'), true
+}
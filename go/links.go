@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"timezone-saas-demo/models"
+)
+
+// merchantLinks 商户相关资源的相对 URL
+func merchantLinks(m *models.Merchant) *models.MerchantLinks {
+	self := fmt.Sprintf("/api/timezone/merchants/%d", m.ID)
+	return &models.MerchantLinks{
+		Self:       self,
+		Orders:     "/api/timezone/orders?" + url.Values{"merchants": {strconv.Itoa(m.ID)}}.Encode(),
+		Boundaries: self + "/boundaries",
+		Attributes: self + "/attributes",
+		TimezoneCompare: "/api/timezone/compare?" + url.Values{
+			"timezones": {m.Timezone},
+			"utc_time":  {"now"},
+		}.Encode(),
+	}
+}
+
+// orderLinks 订单相关资源的相对 URL
+// 本地日期取自 order_time_local，formatting=raw 不返回 local_date 时同样可用
+func orderLinks(o *models.OrderAnalysis) *models.OrderLinks {
+	self := fmt.Sprintf("/api/timezone/orders/%d", o.OrderID)
+	date := o.OrderTimeLocal.Format("2006-01-02")
+	return &models.OrderLinks{
+		Self:     self,
+		Merchant: fmt.Sprintf("/api/timezone/merchants/%d", o.MerchantID),
+		MerchantOrders: "/api/timezone/orders?" + url.Values{
+			"merchants": {strconv.Itoa(o.MerchantID)},
+			"date":      {date},
+		}.Encode(),
+		Analysis:   "/api/timezone/analysis?" + url.Values{"date": {date}}.Encode(),
+		Refunds:    self + "/refunds",
+		Attributes: self + "/attributes",
+		TimezoneCompare: "/api/timezone/compare?" + url.Values{
+			"timezones": {o.Timezone},
+			"utc_time":  {o.OrderTimeUTC.UTC().Format(time.RFC3339)},
+		}.Encode(),
+	}
+}
+
+// linkMerchants 返回带链接的商户副本，不修改传入的切片（可能是共享的缓存列表）
+func linkMerchants(merchants []models.Merchant) []models.Merchant {
+	linked := make([]models.Merchant, len(merchants))
+	for i := range merchants {
+		linked[i] = merchants[i]
+		linked[i].Links = merchantLinks(&linked[i])
+	}
+	return linked
+}
+
+// linkOrders 为订单设置链接
+func linkOrders(orders []models.OrderAnalysis) {
+	for i := range orders {
+		orders[i].Links = orderLinks(&orders[i])
+	}
+}
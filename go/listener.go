@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// portOff PORT 取该值时不监听 TCP 端口
+const portOff = "off"
+
+// certCheckInterval 检查证书文件是否更新的最短间隔
+const certCheckInterval = 10 * time.Second
+
+// listener 一个监听地址及在其上提供服务的 http.Server
+type listener struct {
+	name   string
+	server *http.Server
+	ln     net.Listener
+	tls    bool
+}
+
+// serveHTTP 按配置监听 TCP 端口（HTTP 或 HTTPS）、HTTPS 的明文重定向端口和 Unix 套接字
+// 收到 SIGINT/SIGTERM 后停止接受新连接，等待进行中的请求完成（最多 SHUTDOWN_TIMEOUT）后返回 nil；任一监听出错时关闭全部并返回错误
+func serveHTTP(config *AppConfig, port, socket string, handler http.Handler) error {
+	if port == portOff && socket == "" {
+		return fmt.Errorf("不监听 TCP 端口时需要用 UNIX_SOCKET 或 -socket 指定 Unix 套接字")
+	}
+
+	listeners, err := openListeners(config, port, socket, handler)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func(l listener) {
+			var err error
+			if l.tls {
+				// 证书由 TLSConfig.GetCertificate 提供
+				err = l.server.ServeTLS(l.ln, "", "")
+			} else {
+				err = l.server.Serve(l.ln)
+			}
+			if !errors.Is(err, http.ErrServerClosed) {
+				errc <- fmt.Errorf("%s 停止服务: %w", l.name, err)
+			}
+		}(l)
+	}
+
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		log.Printf("🛑 收到退出信号，停止接受新连接，等待进行中的请求完成（最多 %s）", config.ShutdownTimeout)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, l := range listeners {
+		wg.Add(1)
+		go func(l listener) {
+			defer wg.Done()
+			if shutdownErr := l.server.Shutdown(shutdownCtx); shutdownErr != nil {
+				log.Printf("⚠️ %s 未能在 %s 内处理完请求，强制关闭: %v", l.name, config.ShutdownTimeout, shutdownErr)
+				l.server.Close()
+			}
+		}(l)
+	}
+	wg.Wait()
+	return err
+}
+
+// openListeners 创建全部监听，失败时关闭已经打开的监听
+func openListeners(config *AppConfig, port, socket string, handler http.Handler) (listeners []listener, err error) {
+	defer func() {
+		if err != nil {
+			for _, l := range listeners {
+				l.ln.Close()
+			}
+		}
+	}()
+
+	// 明文连接（TCP 和 Unix 套接字）按配置接受 h2c
+	plain := handler
+	if config.H2C {
+		plain = h2c.NewHandler(handler, &http2.Server{})
+	}
+
+	if port != portOff {
+		ln, err := net.Listen("tcp", ":"+port)
+		if err != nil {
+			return listeners, fmt.Errorf("监听端口 %s 失败: %w", port, err)
+		}
+		if !config.TLSEnabled() {
+			listeners = append(listeners, listener{name: "http :" + port, server: &http.Server{Handler: plain}, ln: ln})
+			fmt.Printf("🚀 服务器启动在端口 %s（HTTP%s）\n", port, h2cSuffix(config))
+			fmt.Printf("📊 API文档: http://localhost:%s/api/docs\n", port)
+			fmt.Printf("🌍 时区演示: http://localhost:%s/api/timezone/demo\n", port)
+		} else {
+			tlsConfig, challenge, err := newTLSConfig(config)
+			if err != nil {
+				ln.Close()
+				return listeners, err
+			}
+			server := &http.Server{Handler: handler, TLSConfig: tlsConfig}
+			if !config.HTTP2 {
+				// TLSNextProto 非 nil 时 net/http 不会自动启用 HTTP/2
+				server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+			}
+			listeners = append(listeners, listener{name: "https :" + port, server: server, ln: ln, tls: true})
+			fmt.Printf("🚀 服务器启动在端口 %s（HTTPS，HTTP/2 %t）\n", port, config.HTTP2)
+			fmt.Printf("📊 API文档: https://localhost:%s/api/docs\n", port)
+
+			if config.HTTPRedirectPort != "" {
+				redirectLn, err := net.Listen("tcp", ":"+config.HTTPRedirectPort)
+				if err != nil {
+					return listeners, fmt.Errorf("监听端口 %s 失败: %w", config.HTTPRedirectPort, err)
+				}
+				redirect := redirectToHTTPS(port)
+				if challenge != nil {
+					redirect = challenge.HTTPHandler(redirect)
+				}
+				listeners = append(listeners, listener{name: "http :" + config.HTTPRedirectPort, server: &http.Server{Handler: redirect}, ln: redirectLn})
+				fmt.Printf("↪️  端口 %s 的 HTTP 请求重定向到 HTTPS\n", config.HTTPRedirectPort)
+			}
+		}
+	}
+
+	if socket != "" {
+		ln, err := listenUnix(socket, config.UnixSocketMode)
+		if err != nil {
+			return listeners, err
+		}
+		listeners = append(listeners, listener{name: "unix " + socket, server: &http.Server{Handler: plain}, ln: ln})
+		fmt.Printf("🔌 监听 Unix 套接字 %s（HTTP%s）\n", socket, h2cSuffix(config))
+	}
+	return listeners, nil
+}
+
+// h2cSuffix 启动日志中明文监听是否接受 h2c
+func h2cSuffix(config *AppConfig) string {
+	if config.H2C {
+		return "，接受 h2c"
+	}
+	return ""
+}
+
+// newTLSConfig 使用证书文件或 ACME 自动证书的 TLS 配置；自动证书时同时返回 Manager，用于响应 http-01 验证
+func newTLSConfig(config *AppConfig) (*tls.Config, *autocert.Manager, error) {
+	if config.TLSCertFile != "" {
+		certs, err := newCertReloader(config.TLSCertFile, config.TLSKeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: certs.GetCertificate}
+		if config.HTTP2 {
+			tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+		}
+		return tlsConfig, nil, nil
+	}
+
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(config.AutocertDomains...),
+		Cache:      autocert.DirCache(config.AutocertCacheDir),
+		Email:      config.AutocertEmail,
+	}
+	tlsConfig := manager.TLSConfig()
+	tlsConfig.MinVersion = tls.VersionTLS12
+	if !config.HTTP2 {
+		// 保留 tls-alpn-01 验证使用的协议
+		tlsConfig.NextProtos = []string{"http/1.1", acme.ALPNProto}
+	}
+	log.Printf("🔐 通过 ACME 为 %v 自动申请证书，缓存目录 %s", config.AutocertDomains, config.AutocertCacheDir)
+	return tlsConfig, manager, nil
+}
+
+// redirectToHTTPS 把明文请求重定向到同一主机的 HTTPS 端口，使用 308 保留请求方法
+func redirectToHTTPS(port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// listenUnix 监听 Unix 套接字，上次异常退出留下的套接字文件先删除
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Stat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s 已存在且不是套接字文件", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("删除旧的套接字文件失败: %w", err)
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("监听 Unix 套接字 %s 失败: %w", path, err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("设置套接字文件权限失败: %w", err)
+	}
+	return ln, nil
+}
+
+// certReloader 从文件加载证书，文件更新后在之后的握手中换用新证书，证书轮换不需要重启服务
+type certReloader struct {
+	certFile, keyFile string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// newCertReloader 加载证书，启动时证书无效直接返回错误
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.checkedAt = time.Now()
+	return r, nil
+}
+
+// GetCertificate 供 tls.Config 使用，最多每 certCheckInterval 检查一次文件的修改时间
+// 重新加载失败（如证书和私钥只更新了一个）时继续使用原证书，下次检查时重试
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checkedAt) >= certCheckInterval {
+		r.checkedAt = time.Now()
+		if modTime, err := r.latestModTime(); err == nil && !modTime.Equal(r.modTime) {
+			if err := r.load(); err != nil {
+				log.Printf("⚠️ 重新加载 TLS 证书失败，继续使用原证书: %v", err)
+			} else {
+				log.Printf("🔐 已重新加载 TLS 证书 %s", r.certFile)
+			}
+		}
+	}
+	return r.cert, nil
+}
+
+// load 读取证书和私钥，成功时记录两者中较晚的修改时间
+func (r *certReloader) load() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("加载 TLS 证书失败: %w", err)
+	}
+	r.cert, r.modTime = &cert, modTime
+	return nil
+}
+
+// latestModTime 证书和私钥文件中较晚的修改时间
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("读取证书文件失败: %w", err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"timezone-saas-demo/services"
+)
+
+// loadShedMiddleware 过载时按接口优先级拒绝请求，返回 503 和 Retry-After；未启用过载保护时全部放行
+func loadShedMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, retryAfter := loadShedder.Admit(r.URL.Path); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			respondError(w, r, http.StatusServiceUnavailable, "load_shed.rejected",
+				fmt.Errorf("%w: 服务负载过高，暂停处理 %s 优先级的请求", services.ErrOverloaded, loadShedder.Classify(r.URL.Path)))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newLoadShedder 按配置创建过载保护，采样本实例的连接池等待次数和各租户排队的分析请求数；mock 模式没有连接池
+func newLoadShedder(config *AppConfig) *services.LoadShedder {
+	if config.LoadShedInterval <= 0 {
+		return nil
+	}
+	return services.NewLoadShedder(config.LoadShed,
+		func() int64 {
+			if db == nil {
+				return 0
+			}
+			return db.GetStats().WaitCount
+		},
+		func() int {
+			depth := 0
+			for _, t := range timezoneService.TenantQueryStats() {
+				depth += t.Waiting
+			}
+			return depth
+		})
+}
@@ -0,0 +1,111 @@
+// Package locale 按语言渲染星期、月份、日期、相对时间以及数字和货币金额。
+// 语言协商和数字/货币格式使用 golang.org/x/text；星期和月份名称使用内置表，
+// 原始数值（星期序号、金额）由调用方原样保留，本包只生成展示用字符串。
+package locale
+
+import (
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"golang.org/x/text/currency"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+
+	"timezone-saas-demo/money"
+)
+
+// Default 未指定或无法匹配语言时使用中文，与 API 其余文案一致
+var Default = For("zh")
+
+// supported 支持的语言，顺序与 names 中的表一致，第一个为协商失败时的默认值
+var supported = []language.Tag{
+	language.Chinese,
+	language.English,
+	language.Japanese,
+	language.German,
+	language.French,
+	language.Spanish,
+}
+
+var matcher = language.NewMatcher(supported)
+
+// Locale 一种展示语言
+type Locale struct {
+	tag     language.Tag
+	names   nameTable
+	printer *message.Printer
+}
+
+// For 按语言代码（如 en、zh-CN、de-AT）返回最接近的受支持语言
+func For(code string) Locale {
+	tags, _, err := language.ParseAcceptLanguage(code)
+	if err != nil || len(tags) == 0 {
+		return newLocale(0)
+	}
+	_, index, _ := matcher.Match(tags...)
+	return newLocale(index)
+}
+
+// Negotiate 依次尝试 values（如 lang 查询参数、Accept-Language 请求头），
+// 使用第一个非空值协商语言，均为空时返回 Default
+func Negotiate(values ...string) Locale {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return For(value)
+		}
+	}
+	return Default
+}
+
+// Supported 返回支持的语言代码
+func Supported() []string {
+	codes := make([]string, len(supported))
+	for i, tag := range supported {
+		codes[i] = tag.String()
+	}
+	return codes
+}
+
+func newLocale(index int) Locale {
+	tag := supported[index]
+	return Locale{tag: tag, names: names[tag.String()], printer: message.NewPrinter(tag)}
+}
+
+// Code 语言代码，如 zh、en
+func (l Locale) Code() string {
+	return l.tag.String()
+}
+
+// Weekday 星期全称
+func (l Locale) Weekday(day time.Weekday) string {
+	return l.names.weekdays[day]
+}
+
+// Month 月份全称
+func (l Locale) Month(month time.Month) string {
+	return l.names.months[month-1]
+}
+
+// Date 本地化的长日期，如"2024年8月19日 星期一"、"Monday, August 19, 2024"
+func (l Locale) Date(t time.Time) string {
+	return l.names.date(l, t)
+}
+
+// Number 按语言的分组和小数符号格式化数字，保留 decimals 位小数
+func (l Locale) Number(value float64, decimals int) string {
+	return l.printer.Sprint(number.Decimal(value, number.MinFractionDigits(decimals), number.MaxFractionDigits(decimals)))
+}
+
+// Currency 按货币的标准小数位和语言格式化金额，如 zh 下 US$ 1,234.50、de 下 € 1.234,50
+// 无法识别的货币代码按两位小数格式化并附加代码。x/text 只接受浮点数，
+// 金额先按币种舍入再转换，15 位有效数字以内（数据库 DECIMAL(15,2)）不会失真
+func (l Locale) Currency(amount decimal.Decimal, code string) string {
+	value := money.Round(amount, code).InexactFloat64()
+	unit, err := currency.ParseISO(code)
+	if err != nil {
+		return l.Number(value, money.DefaultExponent) + " " + code
+	}
+	return l.printer.Sprint(currency.Symbol(unit.Amount(value)))
+}
@@ -0,0 +1,92 @@
+package locale
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// 内置消息目录，每种语言一个 JSON 文件，键为稳定的消息代码，值为 fmt 格式串
+//
+//go:embed messages/*.json
+var builtinMessages embed.FS
+
+var (
+	catalogMu sync.RWMutex
+	catalog   = map[string]map[string]string{}
+)
+
+func init() {
+	entries, err := builtinMessages.ReadDir("messages")
+	if err != nil {
+		panic(fmt.Sprintf("读取内置消息目录失败: %v", err))
+	}
+	for _, entry := range entries {
+		data, err := builtinMessages.ReadFile("messages/" + entry.Name())
+		if err != nil {
+			panic(fmt.Sprintf("读取内置消息 %s 失败: %v", entry.Name(), err))
+		}
+		if err := addMessages(strings.TrimSuffix(entry.Name(), ".json"), data); err != nil {
+			panic(err.Error())
+		}
+	}
+}
+
+// LoadMessages 从目录加载 <语言>.json 翻译文件，覆盖或补充内置消息
+// 可用于为 ja、de 等已支持但没有内置消息的语言提供翻译，或调整文案；消息代码保持不变
+func LoadMessages(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("查找翻译文件失败: %w", err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("读取翻译文件失败: %w", err)
+		}
+		if err := addMessages(strings.TrimSuffix(filepath.Base(file), ".json"), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addMessages 合并一种语言的消息
+func addMessages(lang string, data []byte) error {
+	var messages map[string]string
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return fmt.Errorf("解析 %s 翻译失败: %w", lang, err)
+	}
+
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+	if catalog[lang] == nil {
+		catalog[lang] = map[string]string{}
+	}
+	for code, text := range messages {
+		catalog[lang][code] = text
+	}
+	return nil
+}
+
+// Message 按消息代码渲染当前语言的消息
+// 当前语言缺少该消息时，非中文语言先回退到英文，最后回退到中文；都没有时返回代码本身
+func (l Locale) Message(code string, args ...interface{}) string {
+	chain := []string{l.Code(), "en", "zh"}
+	if l.Code() == "zh" {
+		chain = chain[:1]
+	}
+
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+	for _, lang := range chain {
+		if format, ok := catalog[lang][code]; ok {
+			return fmt.Sprintf(format, args...)
+		}
+	}
+	return code
+}
@@ -0,0 +1,59 @@
+package locale
+
+import (
+	"fmt"
+	"time"
+)
+
+// nameTable 一种语言的星期和月份名称及长日期格式
+type nameTable struct {
+	weekdays [7]string
+	months   [12]string
+	date     func(l Locale, t time.Time) string
+}
+
+// names 按语言代码索引，星期从周日开始，与 time.Weekday 一致
+var names = map[string]nameTable{
+	"zh": {
+		weekdays: [7]string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"},
+		months:   [12]string{"一月", "二月", "三月", "四月", "五月", "六月", "七月", "八月", "九月", "十月", "十一月", "十二月"},
+		date: func(l Locale, t time.Time) string {
+			return fmt.Sprintf("%d年%d月%d日 %s", t.Year(), t.Month(), t.Day(), l.Weekday(t.Weekday()))
+		},
+	},
+	"en": {
+		weekdays: [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		months:   [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		date: func(l Locale, t time.Time) string {
+			return fmt.Sprintf("%s, %s %d, %d", l.Weekday(t.Weekday()), l.Month(t.Month()), t.Day(), t.Year())
+		},
+	},
+	"ja": {
+		weekdays: [7]string{"日曜日", "月曜日", "火曜日", "水曜日", "木曜日", "金曜日", "土曜日"},
+		months:   [12]string{"1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"},
+		date: func(l Locale, t time.Time) string {
+			return fmt.Sprintf("%d年%d月%d日 %s", t.Year(), t.Month(), t.Day(), l.Weekday(t.Weekday()))
+		},
+	},
+	"de": {
+		weekdays: [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		months:   [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		date: func(l Locale, t time.Time) string {
+			return fmt.Sprintf("%s, %d. %s %d", l.Weekday(t.Weekday()), t.Day(), l.Month(t.Month()), t.Year())
+		},
+	},
+	"fr": {
+		weekdays: [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		months:   [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		date: func(l Locale, t time.Time) string {
+			return fmt.Sprintf("%s %d %s %d", l.Weekday(t.Weekday()), t.Day(), l.Month(t.Month()), t.Year())
+		},
+	},
+	"es": {
+		weekdays: [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		months:   [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		date: func(l Locale, t time.Time) string {
+			return fmt.Sprintf("%s, %d de %s de %d", l.Weekday(t.Weekday()), t.Day(), l.Month(t.Month()), t.Year())
+		},
+	},
+}
@@ -0,0 +1,119 @@
+package locale
+
+import (
+	"fmt"
+	"time"
+)
+
+// relativeTable 一种语言的相对时间文案；数量文案为单数、复数两种形式，%d 为数量，%s 为本地时间 15:04
+type relativeTable struct {
+	justNow, soon              string
+	minutesAgo, inMinutes      [2]string
+	hoursAgo, inHours          [2]string
+	daysAgo                    [2]string
+	today, yesterday, tomorrow string
+	// weekdayAt 一周内的将来时刻，第一个 %s 为星期，第二个为时间
+	weekdayAt string
+}
+
+// relativeNames 按语言代码索引
+var relativeNames = map[string]relativeTable{
+	"zh": {
+		justNow: "刚刚", soon: "即将",
+		minutesAgo: [2]string{"%d 分钟前", "%d 分钟前"}, inMinutes: [2]string{"%d 分钟后", "%d 分钟后"},
+		hoursAgo: [2]string{"%d 小时前", "%d 小时前"}, inHours: [2]string{"%d 小时后", "%d 小时后"},
+		daysAgo: [2]string{"%d 天前", "%d 天前"},
+		today:   "今天 %s", yesterday: "昨天 %s", tomorrow: "明天 %s", weekdayAt: "%s %s",
+	},
+	"en": {
+		justNow: "just now", soon: "in a moment",
+		minutesAgo: [2]string{"%d minute ago", "%d minutes ago"}, inMinutes: [2]string{"in %d minute", "in %d minutes"},
+		hoursAgo: [2]string{"%d hour ago", "%d hours ago"}, inHours: [2]string{"in %d hour", "in %d hours"},
+		daysAgo: [2]string{"%d day ago", "%d days ago"},
+		today:   "today %s", yesterday: "yesterday %s", tomorrow: "tomorrow %s", weekdayAt: "%s %s",
+	},
+	"ja": {
+		justNow: "たった今", soon: "まもなく",
+		minutesAgo: [2]string{"%d分前", "%d分前"}, inMinutes: [2]string{"%d分後", "%d分後"},
+		hoursAgo: [2]string{"%d時間前", "%d時間前"}, inHours: [2]string{"%d時間後", "%d時間後"},
+		daysAgo: [2]string{"%d日前", "%d日前"},
+		today:   "今日 %s", yesterday: "昨日 %s", tomorrow: "明日 %s", weekdayAt: "%s %s",
+	},
+	"de": {
+		justNow: "gerade eben", soon: "gleich",
+		minutesAgo: [2]string{"vor %d Minute", "vor %d Minuten"}, inMinutes: [2]string{"in %d Minute", "in %d Minuten"},
+		hoursAgo: [2]string{"vor %d Stunde", "vor %d Stunden"}, inHours: [2]string{"in %d Stunde", "in %d Stunden"},
+		daysAgo: [2]string{"vor %d Tag", "vor %d Tagen"},
+		today:   "heute %s", yesterday: "gestern %s", tomorrow: "morgen %s", weekdayAt: "%s %s",
+	},
+	"fr": {
+		justNow: "à l'instant", soon: "dans un instant",
+		minutesAgo: [2]string{"il y a %d minute", "il y a %d minutes"}, inMinutes: [2]string{"dans %d minute", "dans %d minutes"},
+		hoursAgo: [2]string{"il y a %d heure", "il y a %d heures"}, inHours: [2]string{"dans %d heure", "dans %d heures"},
+		daysAgo: [2]string{"il y a %d jour", "il y a %d jours"},
+		today:   "aujourd'hui %s", yesterday: "hier %s", tomorrow: "demain %s", weekdayAt: "%s %s",
+	},
+	"es": {
+		justNow: "ahora mismo", soon: "en un momento",
+		minutesAgo: [2]string{"hace %d minuto", "hace %d minutos"}, inMinutes: [2]string{"dentro de %d minuto", "dentro de %d minutos"},
+		hoursAgo: [2]string{"hace %d hora", "hace %d horas"}, inHours: [2]string{"dentro de %d hora", "dentro de %d horas"},
+		daysAgo: [2]string{"hace %d día", "hace %d días"},
+		today:   "hoy %s", yesterday: "ayer %s", tomorrow: "mañana %s", weekdayAt: "%s %s",
+	},
+}
+
+// Relative t 相对 now 的描述，如"3 小时前"、"明天 09:00"、"in 20 minutes"
+// 今天、昨天、明天和星期按 t 所在时区的本地日期判断，调用方先把 t 转换到商户或规则的时区：
+// 一分钟内为"刚刚"或"即将"，一小时内按分钟，当天 12 小时内按小时，
+// 其余过去的时刻为昨天、一周内按天，将来的时刻为今天、明天、一周内的星期，更早或更晚的为长日期加时间
+func (l Locale) Relative(t, now time.Time) string {
+	names := relativeNames[l.Code()]
+	clock := t.Format("15:04")
+	d := t.Sub(now)
+	past := d < 0
+	if past {
+		d = -d
+	}
+	days := calendarDays(now.In(t.Location()), t)
+
+	switch {
+	case d < time.Minute && past:
+		return names.justNow
+	case d < time.Minute:
+		return names.soon
+	case d < time.Hour && past:
+		return plural(names.minutesAgo, int(d/time.Minute))
+	case d < time.Hour:
+		return plural(names.inMinutes, int(d/time.Minute))
+	case d < 12*time.Hour && days == 0 && past:
+		return plural(names.hoursAgo, int(d/time.Hour))
+	case d < 12*time.Hour && days == 0:
+		return plural(names.inHours, int(d/time.Hour))
+	case days == 0:
+		return fmt.Sprintf(names.today, clock)
+	case days == -1:
+		return fmt.Sprintf(names.yesterday, clock)
+	case days == 1:
+		return fmt.Sprintf(names.tomorrow, clock)
+	case days < 0 && days > -7:
+		return plural(names.daysAgo, -days)
+	case days > 0 && days < 7:
+		return fmt.Sprintf(names.weekdayAt, l.Weekday(t.Weekday()), clock)
+	}
+	return l.Date(t) + " " + clock
+}
+
+// calendarDays to 与 from 之间相差的本地日期数（按各自的年月日计算，不受夏令时影响）
+func calendarDays(from, to time.Time) int {
+	a := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	b := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(b.Sub(a) / (24 * time.Hour))
+}
+
+// plural 按数量选择单数或复数形式
+func plural(forms [2]string, n int) string {
+	if n == 1 {
+		return fmt.Sprintf(forms[0], n)
+	}
+	return fmt.Sprintf(forms[1], n)
+}
@@ -0,0 +1,229 @@
+package mailtemplate
+
+import (
+	"sort"
+	"time"
+
+	"timezone-saas-demo/models"
+)
+
+// 内置模板名称，租户覆盖时使用相同的名称
+const (
+	AlertFiring     = "alert_firing"
+	AlertResolved   = "alert_resolved"
+	ReportReady     = "report_ready"
+	MerchantWelcome = "merchant_welcome"
+)
+
+// AlertData alert_firing / alert_resolved 的模板数据
+type AlertData struct {
+	Rule     models.AlertRule
+	Event    models.AlertEvent
+	Merchant models.Merchant
+}
+
+// ReportData report_ready 的模板数据，ArtifactURL 为结果文件的完整下载地址
+type ReportData struct {
+	Definition  models.ReportDefinition
+	Run         models.ReportRun
+	ArtifactURL string
+}
+
+// WelcomeData merchant_welcome 的模板数据
+// FirstReportAt 第一份日报的发送时刻，未开启日报时为 nil
+type WelcomeData struct {
+	Merchant       models.Merchant
+	MerchantCode   string
+	ReportSettings models.ReportSettings
+	FirstReportAt  *time.Time
+}
+
+// builtin 内置模板，按名称和语言代码索引
+var builtin = map[string]map[string]Template{
+	AlertFiring: {
+		"zh": {
+			Subject: `[告警] {{.Rule.Name}} - {{.Merchant.Name}}`,
+			HTML: `<p>告警规则 <strong>{{.Rule.Name}}</strong> 开始告警。</p>
+<p>{{.Event.Text}}</p>
+<table>
+<tr><td>商户</td><td>{{.Merchant.Name}}（{{.Merchant.City}}）</td></tr>
+<tr><td>本地时间</td><td>{{datetime .Event.OccurredAt}}（UTC{{offset .Event.OccurredAt}}）</td></tr>
+<tr><td>UTC 时间</td><td>{{datetimeIn "UTC" .Event.OccurredAt}}</td></tr>
+</table>`,
+			Text: `告警规则 {{.Rule.Name}} 开始告警。
+{{.Event.Text}}
+商户：{{.Merchant.Name}}（{{.Merchant.City}}）
+本地时间：{{datetime .Event.OccurredAt}}（UTC{{offset .Event.OccurredAt}}）
+UTC 时间：{{datetimeIn "UTC" .Event.OccurredAt}}
+`,
+		},
+		"en": {
+			Subject: `[Alert] {{.Rule.Name}} - {{.Merchant.Name}}`,
+			HTML: `<p>Alert rule <strong>{{.Rule.Name}}</strong> is firing.</p>
+<p>{{.Event.Text}}</p>
+<table>
+<tr><td>Merchant</td><td>{{.Merchant.Name}} ({{.Merchant.City}})</td></tr>
+<tr><td>Local time</td><td>{{datetime .Event.OccurredAt}} (UTC{{offset .Event.OccurredAt}})</td></tr>
+<tr><td>UTC</td><td>{{datetimeIn "UTC" .Event.OccurredAt}}</td></tr>
+</table>`,
+			Text: `Alert rule {{.Rule.Name}} is firing.
+{{.Event.Text}}
+Merchant: {{.Merchant.Name}} ({{.Merchant.City}})
+Local time: {{datetime .Event.OccurredAt}} (UTC{{offset .Event.OccurredAt}})
+UTC: {{datetimeIn "UTC" .Event.OccurredAt}}
+`,
+		},
+	},
+	AlertResolved: {
+		"zh": {
+			Subject: `[已恢复] {{.Rule.Name}} - {{.Merchant.Name}}`,
+			HTML: `<p>告警规则 <strong>{{.Rule.Name}}</strong> 已恢复。</p>
+<p>{{.Event.Text}}</p>
+<p>商户 {{.Merchant.Name}}，本地时间 {{datetime .Event.OccurredAt}}</p>`,
+			Text: `告警规则 {{.Rule.Name}} 已恢复。
+{{.Event.Text}}
+商户 {{.Merchant.Name}}，本地时间 {{datetime .Event.OccurredAt}}
+`,
+		},
+		"en": {
+			Subject: `[Resolved] {{.Rule.Name}} - {{.Merchant.Name}}`,
+			HTML: `<p>Alert rule <strong>{{.Rule.Name}}</strong> has resolved.</p>
+<p>{{.Event.Text}}</p>
+<p>Merchant {{.Merchant.Name}}, local time {{datetime .Event.OccurredAt}}</p>`,
+			Text: `Alert rule {{.Rule.Name}} has resolved.
+{{.Event.Text}}
+Merchant {{.Merchant.Name}}, local time {{datetime .Event.OccurredAt}}
+`,
+		},
+	},
+	ReportReady: {
+		"zh": {
+			Subject: `报表 {{.Definition.Name}}（{{.Run.From}} 至 {{.Run.To}}）已生成`,
+			HTML: `<p>报表 <strong>{{.Definition.Name}}</strong> 已生成，统计 {{.Run.From}} 至 {{.Run.To}}（{{.Run.Timezone}}）。</p>
+<p>生成时间：{{datetime .Run.FinishedAt}}</p>
+<p><a href="{{.ArtifactURL}}">下载结果文件</a>（{{.Run.Format}}，{{number .Run.ArtifactSize 0}} 字节）</p>`,
+			Text: `报表 {{.Definition.Name}} 已生成，统计 {{.Run.From}} 至 {{.Run.To}}（{{.Run.Timezone}}）。
+生成时间：{{datetime .Run.FinishedAt}}
+下载地址：{{.ArtifactURL}}
+`,
+		},
+		"en": {
+			Subject: `Report {{.Definition.Name}} ({{.Run.From}} to {{.Run.To}}) is ready`,
+			HTML: `<p>Report <strong>{{.Definition.Name}}</strong> is ready, covering {{.Run.From}} to {{.Run.To}} ({{.Run.Timezone}}).</p>
+<p>Generated at {{datetime .Run.FinishedAt}}</p>
+<p><a href="{{.ArtifactURL}}">Download</a> ({{.Run.Format}}, {{number .Run.ArtifactSize 0}} bytes)</p>`,
+			Text: `Report {{.Definition.Name}} is ready, covering {{.Run.From}} to {{.Run.To}} ({{.Run.Timezone}}).
+Generated at {{datetime .Run.FinishedAt}}
+Download: {{.ArtifactURL}}
+`,
+		},
+	},
+	MerchantWelcome: {
+		"zh": {
+			Subject: `欢迎加入，{{.Merchant.Name}}`,
+			HTML: `<p>{{.Merchant.Name}}，欢迎加入！您的商户编码为 <strong>{{.MerchantCode}}</strong>。</p>
+<p>时区：{{.Merchant.Timezone}}（当前 UTC{{offset .Merchant.CreatedAt}}），营业时间 {{.Merchant.BusinessHoursStart}}-{{.Merchant.BusinessHoursEnd}}。</p>
+{{if .FirstReportAt}}<p>第一份日报将于 {{datetime .FirstReportAt}} 发送。</p>{{end}}`,
+			Text: `{{.Merchant.Name}}，欢迎加入！您的商户编码为 {{.MerchantCode}}。
+时区：{{.Merchant.Timezone}}（当前 UTC{{offset .Merchant.CreatedAt}}），营业时间 {{.Merchant.BusinessHoursStart}}-{{.Merchant.BusinessHoursEnd}}。
+{{if .FirstReportAt}}第一份日报将于 {{datetime .FirstReportAt}} 发送。{{end}}
+`,
+		},
+		"en": {
+			Subject: `Welcome aboard, {{.Merchant.Name}}`,
+			HTML: `<p>Welcome aboard, {{.Merchant.Name}}! Your merchant code is <strong>{{.MerchantCode}}</strong>.</p>
+<p>Timezone: {{.Merchant.Timezone}} (currently UTC{{offset .Merchant.CreatedAt}}), business hours {{.Merchant.BusinessHoursStart}}-{{.Merchant.BusinessHoursEnd}}.</p>
+{{if .FirstReportAt}}<p>Your first daily report will be sent on {{datetime .FirstReportAt}}.</p>{{end}}`,
+			Text: `Welcome aboard, {{.Merchant.Name}}! Your merchant code is {{.MerchantCode}}.
+Timezone: {{.Merchant.Timezone}} (currently UTC{{offset .Merchant.CreatedAt}}), business hours {{.Merchant.BusinessHoursStart}}-{{.Merchant.BusinessHoursEnd}}.
+{{if .FirstReportAt}}Your first daily report will be sent on {{datetime .FirstReportAt}}.{{end}}
+`,
+		},
+	},
+}
+
+// Names 内置模板名称，按字母排序
+func Names() []string {
+	names := make([]string, 0, len(builtin))
+	for name := range builtin {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Builtin 名称和语言对应的内置模板，没有该语言的版本时 ok 为 false
+// 所有内置模板都有中文（zh）版本
+func Builtin(name, lang string) (Template, bool) {
+	t, ok := builtin[name][lang]
+	return t, ok
+}
+
+// Langs 内置模板提供的语言代码，按字母排序
+func Langs(name string) []string {
+	langs := make([]string, 0, len(builtin[name]))
+	for lang := range builtin[name] {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Sample 模板的示例数据，用于保存租户模板前的校验和预览
+func Sample(name string) (interface{}, bool) {
+	at := time.Date(2024, 3, 10, 1, 30, 0, 0, time.UTC)
+	merchant := models.Merchant{
+		ID:                 1,
+		Name:               "示例商户",
+		Timezone:           "Asia/Tokyo",
+		Country:            "日本",
+		City:               "东京",
+		BusinessHoursStart: "09:00",
+		BusinessHoursEnd:   "19:00",
+		WeekendDays:        []int{0, 6},
+		CreatedAt:          at,
+		UpdatedAt:          at,
+	}
+	switch name {
+	case AlertFiring, AlertResolved:
+		state, text := "firing", "连续 2 个营业小时没有订单"
+		if name == AlertResolved {
+			state, text = "resolved", "已恢复：最近 2 个营业小时有订单"
+		}
+		return AlertData{
+			Rule: models.AlertRule{
+				ID: 1, Name: "东京店无订单", MerchantID: merchant.ID, Type: "no_orders",
+				WindowHours: 2, Statuses: []string{}, Emails: []string{}, Enabled: true, State: state,
+			},
+			Event: models.AlertEvent{
+				EventID: 1, RuleID: 1, MerchantID: merchant.ID, State: state, Title: "东京店无订单", Text: text,
+				Timezone: merchant.Timezone, LocalTime: "2024-03-10 10:30:00 JST", OccurredAt: at,
+			},
+			Merchant: merchant,
+		}, true
+	case ReportReady:
+		return ReportData{
+			Definition: models.ReportDefinition{
+				ID: 1, Name: "东京日报", MerchantID: merchant.ID, TimezoneMode: "merchant", Timezone: merchant.Timezone,
+				RangeType: "yesterday", Statuses: []string{}, Format: "csv",
+			},
+			Run: models.ReportRun{
+				RunID: 1, DefinitionID: 1, TriggeredBy: "schedule", Status: "completed",
+				From: "2024-03-09", To: "2024-03-09", Timezone: merchant.Timezone, Format: "csv",
+				StartedAt: at, FinishedAt: &at, ArtifactSize: 2048, ArtifactURL: "/api/reports/runs/1/artifact",
+			},
+			ArtifactURL: "https://example.com/api/reports/runs/1/artifact",
+		}, true
+	case MerchantWelcome:
+		first := at.Add(22*time.Hour + 30*time.Minute)
+		return WelcomeData{
+			Merchant:     merchant,
+			MerchantCode: "JP_TOKYO_DEMO",
+			ReportSettings: models.ReportSettings{
+				MerchantID: merchant.ID, DailyReportEnabled: true, DailyReportTime: "09:00", WeekStartDay: 1,
+			},
+			FirstReportAt: &first,
+		}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,235 @@
+// Package mailtemplate 渲染邮件模板。
+// 主题和纯文本正文使用 text/template，HTML 正文使用 html/template（自动转义）；
+// 模板中可以使用按语言和时区格式化时刻、金额的辅助函数，同一个时刻在不同商户的邮件中显示为各自的本地时间。
+// 内置模板见 builtin.go，租户可以在数据库中覆盖（见 services.EmailService）。
+package mailtemplate
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"timezone-saas-demo/locale"
+)
+
+// Template 一个邮件模板：单行主题、HTML 正文和可选的纯文本正文
+type Template struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text,omitempty"`
+}
+
+// Message 渲染后的邮件内容，Text 为空时只发送 HTML
+type Message struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text,omitempty"`
+}
+
+// Options 渲染时使用的语言和时区，Location 为 nil 时按 UTC
+type Options struct {
+	Locale   locale.Locale
+	Location *time.Location
+	// Now relative 的参照时刻，零值为渲染时的当前时间
+	Now time.Time
+}
+
+// Render 按 opts 渲染模板，data 为模板数据（如 AlertData）
+// 模板引用了 data 中不存在的字段时返回错误
+func Render(t Template, opts Options, data interface{}) (*Message, error) {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	funcs := opts.funcs()
+
+	subject, err := renderText("subject", t.Subject, funcs, data)
+	if err != nil {
+		return nil, err
+	}
+	msg := &Message{Subject: strings.Join(strings.Fields(subject), " ")}
+	if msg.Subject == "" {
+		return nil, fmt.Errorf("邮件主题渲染结果为空")
+	}
+
+	tmpl, err := htmltemplate.New("html").Funcs(htmltemplate.FuncMap(funcs)).Option("missingkey=error").Parse(t.HTML)
+	if err != nil {
+		return nil, fmt.Errorf("解析 HTML 模板失败: %w", err)
+	}
+	var html bytes.Buffer
+	if err := tmpl.Execute(&html, data); err != nil {
+		return nil, fmt.Errorf("渲染 HTML 模板失败: %w", err)
+	}
+	msg.HTML = html.String()
+
+	if strings.TrimSpace(t.Text) != "" {
+		if msg.Text, err = renderText("text", t.Text, funcs, data); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
+// renderText 使用 text/template 渲染主题或纯文本正文
+func renderText(name, text string, funcs map[string]interface{}, data interface{}) (string, error) {
+	tmpl, err := texttemplate.New(name).Funcs(texttemplate.FuncMap(funcs)).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("解析 %s 模板失败: %w", name, err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("渲染 %s 模板失败: %w", name, err)
+	}
+	return out.String(), nil
+}
+
+// funcs 模板辅助函数，时刻参数可以是 time.Time、*time.Time（nil 输出空字符串）或 RFC 3339 字符串
+//
+//	date t              本地化长日期，如 2024年8月19日 星期一
+//	time t              本地时间 15:04
+//	datetime t          本地化日期、时间和时区缩写，如 2024年8月19日 星期一 09:30 JST
+//	datetimeIn "UTC" t  按指定时区格式化，用于同时给出 UTC 或总部时间
+//	weekday t           本地化星期
+//	offset t            该时刻的 UTC 偏移，如 +09:00（夏令时前后不同）
+//	relative t          相对渲染时刻的描述，如 3 小时前、明天 09:00（今天、明天按本地日期判断）
+//	tz                  渲染使用的时区名称
+//	lang                渲染使用的语言代码
+//	money amount "JPY"  按币种小数位和语言格式化金额
+//	number v 2          按语言格式化数字
+//	msg "key" args...   API 消息目录中的文案
+func (o Options) funcs() map[string]interface{} {
+	l, loc := o.Locale, o.Location
+	datetime := func(v interface{}, loc *time.Location) (string, error) {
+		t, ok, err := toTime(v)
+		if err != nil || !ok {
+			return "", err
+		}
+		local := t.In(loc)
+		return l.Date(local) + " " + local.Format("15:04 MST"), nil
+	}
+	return map[string]interface{}{
+		"date": func(v interface{}) (string, error) {
+			t, ok, err := toTime(v)
+			if err != nil || !ok {
+				return "", err
+			}
+			return l.Date(t.In(loc)), nil
+		},
+		"time": func(v interface{}) (string, error) {
+			t, ok, err := toTime(v)
+			if err != nil || !ok {
+				return "", err
+			}
+			return t.In(loc).Format("15:04"), nil
+		},
+		"datetime": func(v interface{}) (string, error) {
+			return datetime(v, loc)
+		},
+		"datetimeIn": func(timezone string, v interface{}) (string, error) {
+			zone, err := time.LoadLocation(timezone)
+			if err != nil {
+				return "", fmt.Errorf("无效的时区 %q", timezone)
+			}
+			return datetime(v, zone)
+		},
+		"weekday": func(v interface{}) (string, error) {
+			t, ok, err := toTime(v)
+			if err != nil || !ok {
+				return "", err
+			}
+			return l.Weekday(t.In(loc).Weekday()), nil
+		},
+		"offset": func(v interface{}) (string, error) {
+			t, ok, err := toTime(v)
+			if err != nil || !ok {
+				return "", err
+			}
+			return t.In(loc).Format("-07:00"), nil
+		},
+		"relative": func(v interface{}) (string, error) {
+			t, ok, err := toTime(v)
+			if err != nil || !ok {
+				return "", err
+			}
+			return l.Relative(t.In(loc), o.Now), nil
+		},
+		"tz": func() string {
+			return loc.String()
+		},
+		"lang": func() string {
+			return l.Code()
+		},
+		"money": func(v interface{}, code string) (string, error) {
+			amount, err := toDecimal(v)
+			if err != nil {
+				return "", err
+			}
+			return l.Currency(amount, code), nil
+		},
+		"number": func(v interface{}, decimals int) (string, error) {
+			amount, err := toDecimal(v)
+			if err != nil {
+				return "", err
+			}
+			return l.Number(amount.InexactFloat64(), decimals), nil
+		},
+		"msg": func(key string, args ...interface{}) string {
+			return l.Message(key, args...)
+		},
+	}
+}
+
+// toTime 转换模板中的时刻参数，nil 指针返回 ok = false
+func toTime(v interface{}) (time.Time, bool, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true, nil
+	case *time.Time:
+		if t == nil {
+			return time.Time{}, false, nil
+		}
+		return *t, true, nil
+	case string:
+		parsed, err := time.Parse(time.RFC3339, t)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("无效的时刻 %q，应为 RFC 3339 格式", t)
+		}
+		return parsed, true, nil
+	default:
+		return time.Time{}, false, fmt.Errorf("不支持的时刻类型 %T", v)
+	}
+}
+
+// toDecimal 转换模板中的金额参数
+func toDecimal(v interface{}) (decimal.Decimal, error) {
+	switch n := v.(type) {
+	case decimal.Decimal:
+		return n, nil
+	case *decimal.Decimal:
+		if n == nil {
+			return decimal.Zero, nil
+		}
+		return *n, nil
+	case int:
+		return decimal.NewFromInt(int64(n)), nil
+	case int64:
+		return decimal.NewFromInt(n), nil
+	case float64:
+		return decimal.NewFromFloat(n), nil
+	case string:
+		d, err := decimal.NewFromString(n)
+		if err != nil {
+			return decimal.Zero, fmt.Errorf("无效的金额 %q", n)
+		}
+		return d, nil
+	default:
+		return decimal.Zero, fmt.Errorf("不支持的金额类型 %T", v)
+	}
+}
@@ -1,16 +1,27 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/sha256"
+	"encoding/xml"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"net/netip"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"timezone-saas-demo/database"
+	"timezone-saas-demo/locale"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/pseudonym"
+	"timezone-saas-demo/redact"
 	"timezone-saas-demo/services"
+	"timezone-saas-demo/tzdb"
 
 	"github.com/gorilla/mux"
 	_ "github.com/lib/pq"
@@ -18,40 +29,132 @@ import (
 
 // APIResponse 统一的API响应格式
 type APIResponse struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	XMLName xml.Name    `json:"-" xml:"response"`
+	Success bool        `json:"success" xml:"success"`
+	// Code 稳定的消息代码，Message 为按请求语言渲染的文案
+	Code    string      `json:"code,omitempty" xml:"code,omitempty"`
+	Message string      `json:"message" xml:"message"`
+	Data    interface{} `json:"data,omitempty" xml:"data,omitempty"`
+	// Meta 列表接口的分页信息
+	Meta    *models.PageMeta `json:"meta,omitempty" xml:"meta,omitempty"`
+	Error   string      `json:"error,omitempty" xml:"error,omitempty"`
 }
 
 // 全局变量
 var (
 	db             *database.DB
 	timezoneService *services.TimezoneService
+	billingService  *services.BillingService
+	revenueCloseService *services.RevenueCloseService
+	onboardingService   *services.OnboardingService
+	refundService       *services.RefundService
+	indexAdvisorService *services.IndexAdvisorService
+	settingsService     *services.SettingsService
+	overviewService     *services.OverviewService
+	consistencyService  *services.ConsistencyService
+	organizationService *services.OrganizationService
+	reportService       *services.ReportService
+	alertRuleService    *services.AlertRuleService
+	emailService        *services.EmailService
+	dashboardService    *services.DashboardService
+	changeService       *services.ChangeService
+	syncService         *services.SyncService
+	ingestService       *services.IngestService
+	// rollupService 订单小时汇总表的比对和重建，mock 模式下为 nil
+	rollupService *services.RollupService
+	// retentionService 按商户保留策略归档旧订单，mock 模式下为 nil
+	retentionService *services.RetentionService
+	// queryConsoleService 管理员的只读 SQL 控制台，mock 模式下为 nil
+	queryConsoleService *services.QueryConsoleService
+	// tenantDataService 导出、删除和匿名化商户的全部数据，mock 模式下为 nil
+	tenantDataService *services.TenantDataService
+	// provisioningService 在一个事务中开通租户，mock 模式下为 nil
+	provisioningService *services.TenantProvisioningService
+	// lifecycleService 租户生命周期（试用、正式、暂停、归档），mock 模式下为 nil，不限制请求
+	lifecycleService *services.LifecycleService
+	// attributeService 租户为商户和订单定义的自定义属性，mock 模式下为 nil
+	attributeService *services.AttributeService
+	// savedViewService 看板用户保存的筛选视图，mock 模式下为 nil
+	savedViewService *services.SavedViewService
+	// jobService 后台任务的队列、重试和定时计划，mock 模式下为 nil
+	jobService *services.JobService
+	// leaderElector 多实例部署时单实例后台任务的选主，mock 模式或 LEADER_ELECTION=false 时为 nil
+	leaderElector *services.LeaderElector
+	// clockMonitor 本机时钟与数据库、NTP 服务器的偏差检查，serve 启动时创建
+	clockMonitor *services.ClockMonitor
+	// backfillService ClickHouse 镜像的回填任务，分析存储不是 ClickHouse 时为 nil
+	backfillService *services.ClickHouseBackfill
+	// requestCounter 按租户（X-Tenant-ID）统计的 API 请求数
+	requestCounter = services.NewTenantRequestCounter()
+	// slaMonitor 按租户（X-Tenant-ID）统计的响应时间分位数和错误率，serve 启动时按配置创建
+	slaMonitor *services.SLAMonitor
+	// canaryService 以合成租户检查读取路径的合成监控，mock 模式或 CANARY_INTERVAL=0 时为 nil
+	canaryService *services.CanaryService
+	// planRegressionService 执行计划回归检测，mock 模式或 PLAN_REGRESSION_INTERVAL=0 时为 nil
+	planRegressionService *services.PlanRegressionService
+	// adminToken /api/admin 接口的 Bearer 令牌，为空时管理接口不可用
+	adminToken string
+	// apiStatementTimeout、exportStatementTimeout API 请求和管理接口的 SQL 语句超时，serve 启动时按配置设置
+	apiStatementTimeout, exportStatementTimeout time.Duration
+	// debugEndpoints 是否挂载 /debug/pprof 和 /debug/vars，serve 启动时按 DEBUG_ENDPOINTS 设置
+	debugEndpoints bool
+	// fastJSON 订单列表和分析结果是否使用 fastjson 编码，serve 启动时按 JSON_ENCODER 设置
+	fastJSON bool
+	// pseudonymizer 演示部署的响应假名化，serve 启动时按 DEMO_PSEUDONYMIZE 设置，关闭时为 nil
+	pseudonymizer *pseudonym.Pseudonymizer
+	// csrf 浏览器端写请求的 CSRF 防护配置，serve 启动时按 CSRF_* 设置，默认关闭
+	csrf csrfOptions
+	// trustedProxies 可信的反向代理地址，serve 启动时按 TRUSTED_PROXIES 设置，为空时不信任任何 X-Forwarded-* 请求头
+	trustedProxies []netip.Prefix
+	// orderFormatting 订单列表未指定 formatting 参数时的格式化方式，serve 启动时按 ORDER_FORMATTING 设置
+	orderFormatting = models.OrderFormattingGo
+	// faultInjector 故障注入器，未设置 FAULT_INJECTION=true 且不是 mock 模式时为 nil
+	faultInjector *services.FaultInjector
+	// responseCache 分析和时区对比接口的响应缓存，serve 启动时按配置创建，默认不缓存
+	responseCache = services.NewResponseCache(0, 0, 0)
+	// captureRecorder 按租户录制请求和响应，只录制通过 /api/admin/captures/tenants 开启的租户
+	captureRecorder = services.NewCaptureRecorder(services.DefaultCaptureCapacity, services.DefaultCaptureMaxBody)
+	// usageMeter 按路由模板统计的 API 用量和参数分布，用于 /api/admin/loadtest/profile 导出压测场景
+	usageMeter = services.NewUsageMeter()
+	// loadShedder 过载保护，serve 启动时按 LOAD_SHED_* 创建，LOAD_SHED_INTERVAL=0 时为 nil（不拒绝请求）
+	loadShedder *services.LoadShedder
 )
 
 func main() {
-	// 初始化数据库连接
-	var err error
-	db, err = database.NewConnection()
-	if err != nil {
-		log.Fatalf("数据库连接失败: %v", err)
+	// 未指定子命令时默认启动 API 服务，兼容原有的 ./main 启动方式
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
 	}
-	defer db.Close()
-
-	// 初始化时区服务
-	timezoneService = services.NewTimezoneService(db)
 
-	// 设置路由
-	router := setupRoutes()
+	cmd := findCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "未知命令: %s\n\n", name)
+		printUsage()
+		os.Exit(2)
+	}
 
-	// 启动服务器
-	port := getEnv("PORT", "8080")
-	fmt.Printf("🚀 服务器启动在端口 %s\n", port)
-	fmt.Printf("📊 API文档: http://localhost:%s/api/docs\n", port)
-	fmt.Printf("🌍 时区演示: http://localhost:%s/api/timezone/demo\n", port)
+	config, err := loadConfig()
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+	// 日志、请求录制和审计共用同一套脱敏策略，日志可以直接交给外部日志平台
+	redact.SetCurrent(redact.New(config.RedactionPolicy, []byte(config.RedactionHashKey)))
+	if config.RedactLogs {
+		log.SetOutput(redact.Current().Writer(os.Stderr))
+	}
+	// 外部 zoneinfo 目录对所有子命令生效，必须在加载任何时区之前设置
+	if err := tzdb.SetDir(config.TZDataDir); err != nil {
+		log.Fatalf("加载配置失败: TZDATA_DIR %v", err)
+	}
 
-	log.Fatal(http.ListenAndServe(":"+port, router))
+	if err := cmd.Run(config, args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		log.Printf("❌ %s 失败: %v", cmd.Name, err)
+		os.Exit(1)
+	}
 }
 
 // setupRoutes 设置所有路由
@@ -59,13 +162,107 @@ func setupRoutes() *mux.Router {
 	router := mux.NewRouter()
 
 	// 添加CORS中间件
+	router.Use(realClientMiddleware)
 	router.Use(corsMiddleware)
+	router.Use(csrfMiddleware)
+	router.Use(tenantMiddleware)
 
 	// API路由
 	api := router.PathPrefix("/api").Subrouter()
+	api.Use(startupMiddleware)
+	api.Use(apiKeyMiddleware)
+	api.Use(requestStatsMiddleware)
+	api.Use(loadShedMiddleware)
+	api.Use(captureMiddleware)
+	api.Use(faultMiddleware)
+	api.Use(lifecycleMiddleware)
+	api.Use(statementTimeoutMiddleware(&apiStatementTimeout))
+
+	// 性能分析，未开启 DEBUG_ENDPOINTS 时不挂载
+	setupDebugRoutes(router)
 
 	// 健康检查
 	api.HandleFunc("/health", healthCheckHandler).Methods("GET")
+	api.HandleFunc("/health/live", livenessHandler).Methods("GET")
+	api.HandleFunc("/health/ready", readinessHandler).Methods("GET")
+	api.HandleFunc("/health/clock", clockHandler).Methods("GET")
+	api.HandleFunc("/health/canary", canaryHandler).Methods("GET")
+
+	// 运行指标
+	api.HandleFunc("/metrics/tenants", getTenantQueryStats).Methods("GET")
+
+	// 管理接口，需要 ADMIN_TOKEN
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.Use(adminMiddleware)
+	admin.Use(statementTimeoutMiddleware(&exportStatementTimeout))
+	admin.HandleFunc("/slow-queries", getSlowQueries).Methods("GET")
+	admin.HandleFunc("/plan-regressions", getPlanRegressions).Methods("GET")
+	admin.HandleFunc("/circuit-breaker", getCircuitBreaker).Methods("GET")
+	admin.HandleFunc("/runtime", getRuntimeDiagnostics).Methods("GET")
+	admin.HandleFunc("/cache", getResponseCache).Methods("GET")
+	admin.HandleFunc("/cache", clearResponseCache).Methods("DELETE")
+	admin.HandleFunc("/index-advice", getIndexAdvice).Methods("GET")
+	admin.HandleFunc("/coverage", getBusinessHoursCoverage).Methods("GET")
+	admin.HandleFunc("/loadtest/profile", getLoadTestProfile).Methods("GET")
+	admin.HandleFunc("/loadtest/profile", resetLoadTestProfile).Methods("DELETE")
+	admin.HandleFunc("/load-shed", getLoadShedStatus).Methods("GET")
+	admin.HandleFunc("/tzdata/reload", purgeResponseCache(reloadTZData)).Methods("POST")
+	admin.HandleFunc("/overview", getAdminOverview).Methods("GET")
+	admin.HandleFunc("/sla", getSLAReport).Methods("GET")
+	admin.HandleFunc("/faults", listFaultRules).Methods("GET")
+	admin.HandleFunc("/faults", createFaultRule).Methods("POST")
+	admin.HandleFunc("/faults", clearFaultRules).Methods("DELETE")
+	admin.HandleFunc("/faults/{id:[0-9]+}", deleteFaultRule).Methods("DELETE")
+	admin.HandleFunc("/captures", listCaptures).Methods("GET")
+	admin.HandleFunc("/captures", clearCaptures).Methods("DELETE")
+	admin.HandleFunc("/captures/tenants", listCaptureTenants).Methods("GET")
+	admin.HandleFunc("/captures/tenants/{tenant}", enableCapture).Methods("PUT")
+	admin.HandleFunc("/captures/tenants/{tenant}", disableCapture).Methods("DELETE")
+	admin.HandleFunc("/captures/{id:[0-9]+}", getCapture).Methods("GET")
+	admin.HandleFunc("/captures/{id:[0-9]+}/replay", replayCapture).Methods("POST")
+	admin.HandleFunc("/consistency", listConsistencyRuns).Methods("GET")
+	admin.HandleFunc("/consistency/run", runConsistencyCheck).Methods("POST")
+	admin.HandleFunc("/consistency/{id:[0-9]+}", getConsistencyRun).Methods("GET")
+	admin.HandleFunc("/rollup/audit", auditRollup).Methods("GET")
+	admin.HandleFunc("/rollup/rebuild", purgeResponseCache(rebuildRollup)).Methods("POST")
+	admin.HandleFunc("/retention", getRetention).Methods("GET")
+	admin.HandleFunc("/retention/run", purgeResponseCache(runRetention)).Methods("POST")
+	admin.HandleFunc("/retention/runs/{id:[0-9]+}", getRetentionRun).Methods("GET")
+	admin.HandleFunc("/retention/{id:[0-9]+}", setRetentionPolicy).Methods("PUT")
+	admin.HandleFunc("/retention/{id:[0-9]+}", deleteRetentionPolicy).Methods("DELETE")
+	admin.HandleFunc("/jobs", listJobs).Methods("GET")
+	admin.HandleFunc("/jobs", createJob).Methods("POST")
+	admin.HandleFunc("/jobs/schedules", listJobSchedules).Methods("GET")
+	admin.HandleFunc("/jobs/schedules/{name}", setJobSchedule).Methods("PUT")
+	admin.HandleFunc("/jobs/schedules/{name}", deleteJobSchedule).Methods("DELETE")
+	admin.HandleFunc("/jobs/{id:[0-9]+}", getJob).Methods("GET")
+	admin.HandleFunc("/jobs/{id:[0-9]+}/retry", retryJob).Methods("POST")
+	admin.HandleFunc("/jobs/{id:[0-9]+}/cancel", cancelJob).Methods("POST")
+	admin.HandleFunc("/tenants", purgeResponseCache(provisionTenant)).Methods("POST")
+	admin.HandleFunc("/tenants/erasures", listTenantErasures).Methods("GET")
+	admin.HandleFunc("/tenants/erasures/{id:[0-9]+}", getTenantErasure).Methods("GET")
+	admin.HandleFunc("/tenants/{id:[0-9]+}/api-keys/{key_id:[0-9]+}", revokeTenantAPIKey).Methods("DELETE")
+	admin.HandleFunc("/tenants/{id:[0-9]+}/lifecycle", getTenantLifecycle).Methods("GET")
+	admin.HandleFunc("/tenants/{id:[0-9]+}/lifecycle", purgeResponseCache(transitionTenantLifecycle)).Methods("POST")
+	admin.HandleFunc("/tenants/{id:[0-9]+}/export", exportTenantData).Methods("GET")
+	admin.HandleFunc("/tenants/{id:[0-9]+}/erase", purgeResponseCache(eraseTenantData)).Methods("POST")
+	admin.HandleFunc("/query", runConsoleQuery).Methods("POST")
+	admin.HandleFunc("/merchants/export", exportMerchants).Methods("GET")
+	admin.HandleFunc("/merchants/import", purgeResponseCache(importMerchants)).Methods("POST")
+	admin.HandleFunc("/query/audit", getConsoleAudit).Methods("GET")
+	admin.HandleFunc("/backfill", listBackfillJobs).Methods("GET")
+	admin.HandleFunc("/backfill", createBackfillJob).Methods("POST")
+	admin.HandleFunc("/backfill/{id:[0-9]+}", getBackfillJob).Methods("GET")
+	admin.HandleFunc("/backfill/{id:[0-9]+}/resume", resumeBackfillJob).Methods("POST")
+	admin.HandleFunc("/ingest/deliveries", listIngestDeliveries).Methods("GET")
+	admin.HandleFunc("/ingest/deliveries/replay", purgeResponseCache(replayFailedIngestDeliveries)).Methods("POST")
+	admin.HandleFunc("/ingest/deliveries/{id:[0-9]+}/replay", purgeResponseCache(replayIngestDelivery)).Methods("POST")
+	admin.HandleFunc("/orgs", listOrganizations).Methods("GET")
+	admin.HandleFunc("/orgs", createOrganization).Methods("POST")
+	admin.HandleFunc("/orgs/{id:[0-9]+}/merchants", setOrganizationMerchants).Methods("PUT")
+	admin.HandleFunc("/orgs/{id:[0-9]+}/tokens", listOrganizationTokens).Methods("GET")
+	admin.HandleFunc("/orgs/{id:[0-9]+}/tokens", issueOrganizationToken).Methods("POST")
+	admin.HandleFunc("/orgs/{id:[0-9]+}/tokens/{token_id:[0-9]+}", revokeOrganizationToken).Methods("DELETE")
 
 	// API文档
 	api.HandleFunc("/docs", apiDocsHandler).Methods("GET")
@@ -73,9 +270,111 @@ func setupRoutes() *mux.Router {
 	// 时区相关API
 	api.HandleFunc("/timezone/demo", timezoneDemo).Methods("GET")
 	api.HandleFunc("/timezone/merchants", getMerchants).Methods("GET")
-	api.HandleFunc("/timezone/orders", getOrders).Methods("GET")
-	api.HandleFunc("/timezone/analysis", getAnalysisData).Methods("GET")
-	api.HandleFunc("/timezone/compare", compareTimezones).Methods("GET")
+	api.HandleFunc("/timezone/merchants/{id:[0-9]+}", getMerchant).Methods("GET")
+	api.HandleFunc("/timezone/merchants/{id:[0-9]+}/boundaries", getMerchantBoundaries).Methods("GET")
+	api.HandleFunc("/timezone/merchants/{id:[0-9]+}/now", getMerchantNow).Methods("GET")
+	api.HandleFunc("/timezone/merchants/{id:[0-9]+}/calendar.ics", getMerchantCalendar).Methods("GET")
+	api.HandleFunc("/timezone/merchants/{id:[0-9]+}/peak-hours", getMerchantPeakHours).Methods("GET")
+	api.HandleFunc("/timezone/merchants/{id:[0-9]+}/fulfillment", getMerchantFulfillment).Methods("GET")
+	api.HandleFunc("/timezone/merchants/{id:[0-9]+}/attributes", getMerchantAttributes).Methods("GET")
+	api.HandleFunc("/timezone/merchants/{id:[0-9]+}/attributes", purgeResponseCache(patchMerchantAttributes)).Methods("PATCH")
+	api.HandleFunc("/timezone/orders", withSavedView(getOrders)).Methods("GET")
+	api.HandleFunc("/timezone/orders/{id:[0-9]+}", getOrder).Methods("GET")
+	api.HandleFunc("/timezone/orders/{id:[0-9]+}/refunds", getOrderRefunds).Methods("GET")
+	api.HandleFunc("/timezone/orders/{id:[0-9]+}/refunds", purgeResponseCache(createOrderRefund)).Methods("POST")
+	api.HandleFunc("/timezone/orders/{id:[0-9]+}/attributes", getOrderAttributes).Methods("GET")
+	api.HandleFunc("/timezone/orders/{id:[0-9]+}/attributes", purgeResponseCache(patchOrderAttributes)).Methods("PATCH")
+	api.HandleFunc("/timezone/analysis", cacheResponse(withSavedView(getAnalysisData))).Methods("GET")
+	api.HandleFunc("/timezone/analysis/batch", withSavedView(getAnalysisBatch)).Methods("GET")
+	api.HandleFunc("/timezone/analysis/cohorts", getAnalysisCohorts).Methods("GET")
+	api.HandleFunc("/timezone/analysis/business-day", getGlobalBusinessDay).Methods("GET")
+	api.HandleFunc("/timezone/analysis/closed", getClosedAnalysis).Methods("GET")
+	api.HandleFunc("/timezone/reconciliation", getReconciliation).Methods("GET")
+	api.HandleFunc("/timezone/reconciliation/adjust", purgeResponseCache(adjustLateOrders)).Methods("POST")
+	api.HandleFunc("/timezone/reconciliation/events", getEventReconciliation).Methods("GET")
+	api.HandleFunc("/timezone/reconciliation/events", reconcileUploadedEvents).Methods("POST")
+	api.HandleFunc("/timezone/compare", cacheResponse(compareTimezones)).Methods("GET")
+	api.HandleFunc("/timezone/overlap", findOverlap).Methods("GET")
+	api.HandleFunc("/timezone/schedule/expand", expandSchedule).Methods("GET")
+	api.HandleFunc("/timezone/schedule/validate", validateSchedule).Methods("GET")
+	api.HandleFunc("/timezone/lookup", lookupTimezone).Methods("GET")
+	api.HandleFunc("/timezone/convert", convertTimestamps).Methods("POST")
+	api.HandleFunc("/timezone/tzdata", getTZDataInfo).Methods("GET")
+	api.HandleFunc("/timezone/rules", getTimezoneRules).Methods("GET")
+
+	// 首页概览，合并首屏需要的今天订单、昨天对比、本地时间、营业状态和最近 24 小时
+	api.HandleFunc("/dashboard/summary", getDashboardSummary).Methods("GET")
+
+	// 商户和订单的变更流水，移动端按 seq 增量同步
+	api.HandleFunc("/changes", getChanges).Methods("GET")
+
+	// 外勤平板的离线同步：推送订单修改并拉取变更
+	api.HandleFunc("/sync", purgeResponseCache(syncOrders)).Methods("POST")
+
+	// 外部平台（Shopify、Stripe）的订单 Webhook，按平台签名校验
+	api.HandleFunc("/ingest/webhooks/{provider}", purgeResponseCache(receiveWebhook)).Methods("POST")
+
+	// 国家和城市参考数据
+	api.HandleFunc("/reference/countries", listReferenceCountries).Methods("GET")
+	api.HandleFunc("/reference/countries/{code}", getReferenceCountry).Methods("GET")
+	api.HandleFunc("/reference/cities", listReferenceCities).Methods("GET")
+
+	// 商户入驻
+	api.HandleFunc("/merchants/onboard", purgeResponseCache(onboardMerchant)).Methods("POST")
+
+	// 商户配置，未指定语言时按商户配置的语言返回消息
+	merchants := api.PathPrefix("/merchants/{id:[0-9]+}").Subrouter()
+	merchants.Use(merchantLocaleMiddleware)
+	merchants.HandleFunc("/sla", getMerchantSLA).Methods("GET")
+	merchants.HandleFunc("/settings", listMerchantSettings).Methods("GET")
+	merchants.HandleFunc("/settings/{key}", getMerchantSetting).Methods("GET")
+	merchants.HandleFunc("/settings/{key}", updateMerchantSetting).Methods("PUT")
+	merchants.HandleFunc("/settings/{key}", resetMerchantSetting).Methods("DELETE")
+
+	// 组织接口，需要管理令牌或该组织的令牌
+	orgs := api.PathPrefix("/orgs/{id:[0-9]+}").Subrouter()
+	orgs.Use(organizationMiddleware)
+	orgs.HandleFunc("", getOrganization).Methods("GET")
+	orgs.HandleFunc("/analysis", getOrganizationAnalysis).Methods("GET")
+
+	// 保存的报表定义、执行记录和结果文件
+	api.HandleFunc("/reports/definitions", listReportDefinitions).Methods("GET")
+	api.HandleFunc("/reports/definitions", createReportDefinition).Methods("POST")
+	api.HandleFunc("/reports/definitions/{id:[0-9]+}", getReportDefinition).Methods("GET")
+	api.HandleFunc("/reports/definitions/{id:[0-9]+}", updateReportDefinition).Methods("PUT")
+	api.HandleFunc("/reports/definitions/{id:[0-9]+}", deleteReportDefinition).Methods("DELETE")
+	api.HandleFunc("/reports/definitions/{id:[0-9]+}/run", runReport).Methods("POST")
+	api.HandleFunc("/reports/definitions/{id:[0-9]+}/runs", listReportRuns).Methods("GET")
+	api.HandleFunc("/reports/runs/{id:[0-9]+}/artifact", getReportArtifact).Methods("GET", "HEAD")
+
+	// 指标告警规则，按 X-Tenant-ID 隔离
+	api.HandleFunc("/alerts/rules", listAlertRules).Methods("GET")
+	api.HandleFunc("/alerts/rules", createAlertRule).Methods("POST")
+	api.HandleFunc("/alerts/rules/{id:[0-9]+}", getAlertRule).Methods("GET")
+	api.HandleFunc("/alerts/rules/{id:[0-9]+}", updateAlertRule).Methods("PUT")
+	api.HandleFunc("/alerts/rules/{id:[0-9]+}", deleteAlertRule).Methods("DELETE")
+	api.HandleFunc("/alerts/rules/{id:[0-9]+}/evaluate", evaluateAlertRule).Methods("POST")
+	api.HandleFunc("/alerts/events", listAlertEvents).Methods("GET")
+
+	// 自定义属性的定义，按 X-Tenant-ID 隔离
+	api.HandleFunc("/attributes/definitions", listAttributeDefinitions).Methods("GET")
+	api.HandleFunc("/attributes/definitions/{entity:merchant|order}/{key}", purgeResponseCache(putAttributeDefinition)).Methods("PUT")
+	api.HandleFunc("/attributes/definitions/{entity:merchant|order}/{key}", purgeResponseCache(deleteAttributeDefinition)).Methods("DELETE")
+
+	// 保存的筛选视图，按 X-Tenant-ID 隔离，订单和分析接口通过 view_id 引用
+	api.HandleFunc("/views", listSavedViews).Methods("GET")
+	api.HandleFunc("/views", createSavedView).Methods("POST")
+	api.HandleFunc("/views/{id:[0-9]+}", getSavedView).Methods("GET")
+	api.HandleFunc("/views/{id:[0-9]+}", purgeResponseCache(updateSavedView)).Methods("PUT")
+	api.HandleFunc("/views/{id:[0-9]+}", purgeResponseCache(deleteSavedView)).Methods("DELETE")
+	api.HandleFunc("/email/templates", listEmailTemplates).Methods("GET")
+	api.HandleFunc("/email/templates/{name}", getEmailTemplate).Methods("GET")
+	api.HandleFunc("/email/templates/{name}", saveEmailTemplate).Methods("PUT")
+	api.HandleFunc("/email/templates/{name}", deleteEmailTemplate).Methods("DELETE")
+	api.HandleFunc("/email/templates/{name}/preview", previewEmailTemplate).Methods("POST")
+
+	// 计费相关路由
+	api.HandleFunc("/billing/periods", getBillingPeriods).Methods("GET")
 
 	// 静态文件服务（如果需要）
 	router.PathPrefix("/").Handler(http.FileServer(http.Dir("./static/"))).Methods("GET")
@@ -87,8 +386,9 @@ func setupRoutes() *mux.Router {
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Accept-Language, X-Tenant-ID, X-API-Key, X-CSRF-Token, Range, If-Range")
+		w.Header().Set("Access-Control-Expose-Headers", "Link, Retry-After, X-Fault-Injected, ETag, Content-Range")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -99,18 +399,25 @@ func corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// tenantMiddleware 按 X-Tenant-ID 请求头标记请求所属租户，用于分析查询按租户限流
+// 未携带该请求头的请求共用 default 租户的名额；/api 下携带 X-API-Key 的请求由 apiKeyMiddleware 按密钥所属的商户替换
+func tenantMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tenant := strings.TrimSpace(r.Header.Get("X-Tenant-ID")); tenant != "" {
+			r = r.WithContext(services.WithTenant(r.Context(), tenant))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // healthCheckHandler 健康检查
 func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
-	response := APIResponse{
-		Success: true,
-		Message: "服务运行正常",
-		Data: map[string]interface{}{
-			"timestamp": time.Now().Format(time.RFC3339),
-			"version":   "1.0.0",
-			"service":   "timezone-saas-demo",
-		},
-	}
-	respondJSON(w, http.StatusOK, response)
+	respondSuccess(w, r, http.StatusOK, "health.ok", map[string]interface{}{
+		"timestamp": time.Now().Format(time.RFC3339),
+		"version":   "1.0.0",
+		"service":   "timezone-saas-demo",
+		"tzdata":    tzdb.Detect(),
+	})
 }
 
 // apiDocsHandler API文档
@@ -121,172 +428,589 @@ func apiDocsHandler(w http.ResponseWriter, r *http.Request) {
 		"description": "演示如何优雅地处理多租户时区问题",
 		"endpoints": map[string]interface{}{
 			"/api/health":            "健康检查",
-			"/api/timezone/demo":     "时区处理演示",
-			"/api/timezone/merchants": "获取商户列表",
-			"/api/timezone/orders":    "获取订单列表（支持时区转换）",
-			"/api/timezone/analysis":  "获取分析数据（基于视图）",
+			"/api/health/live":       "存活探针（进程可响应即返回200）",
+			"/api/health/ready":      "就绪探针（数据库不可用时返回503）",
+			"/api/health/clock":      "本机时钟与数据库、NTP 服务器的偏差（最近一次检查结果，超过 CLOCK_SKEW_THRESHOLD 时 status 为 warning）",
+			"/api/health/canary":     "合成监控：以合成租户查询商户、订单、分析的耗时和结果比对（本实例最近一次运行和累计次数，mock 模式下不可用）",
+			"/api/metrics/tenants":   "各租户（X-Tenant-ID）分析查询的并发和排队统计",
+			"/api/admin/slow-queries": "最近的慢查询（按耗时倒序，含采样的执行计划，需要 ADMIN_TOKEN）",
+			"/api/admin/plan-regressions": "执行计划回归：耗时中位数在部署或 ANALYZE 之后比上一个统计周期变慢超过 PLAN_REGRESSION_THRESHOLD 的语句，含两个周期的执行计划（需要 ADMIN_TOKEN）",
+			"/api/admin/circuit-breaker": "数据库熔断器状态：closed/open/half_open、连续失败次数、熔断次数和被拒绝的请求数（需要 ADMIN_TOKEN）",
+			"/api/admin/runtime": "运行时诊断：goroutine 数量和状态分布、内存、GC、数据库连接池统计和单实例任务的选主状态（需要 ADMIN_TOKEN）",
+			"/debug/pprof/": "net/http/pprof 性能分析（profile、heap、goroutine、trace 等，需要 DEBUG_ENDPOINTS=true 和 ADMIN_TOKEN）",
+			"/debug/vars": "expvar 运行时变量，含 memstats、连接池、熔断器、响应缓存、过载保护、选主和合成监控状态（需要 DEBUG_ENDPOINTS=true 和 ADMIN_TOKEN）",
+			"/api/admin/cache": "分析和时区对比接口的响应缓存：命中、过期命中、未命中和后台刷新次数，各租户的条数（需要 ADMIN_TOKEN）",
+			"DELETE /api/admin/cache": "清空响应缓存（tenant 只清空该租户）",
+			"/api/admin/index-advice": "订单和商户表的索引建议（缺少的索引、冗余和未使用的索引、顺序扫描，需要 ADMIN_TOKEN）",
+			"/api/admin/coverage":     "商户组合的营业时间覆盖：无人营业的 UTC 小时、营业商户最多的小时和最能填补空档的新增时区（date 为 UTC 日期，需要 ADMIN_TOKEN）",
+			"/api/admin/loadtest/profile": "按本实例的 API 用量导出压测场景：format=json（用量统计）、k6 或 vegeta，参数取值按真实分布抽样（需要 ADMIN_TOKEN）",
+			"DELETE /api/admin/loadtest/profile": "清空 API 用量统计，重新开始统计",
+			"/api/admin/load-shed": "过载保护状态：当前级别、连接池等待速率、排队的分析请求数、策略和各优先级被拒绝的请求数（需要 ADMIN_TOKEN）",
+			"POST /api/admin/tzdata/reload": "重新加载 TZDATA_DIR 中的 zoneinfo 并清空时区缓存（需要 ADMIN_TOKEN）",
+			"/api/admin/sla": "各租户（X-Tenant-ID）在滚动窗口内的 p50/p95/p99 响应时间、5xx 错误率和预算状态（breached=true 只返回超出预算的租户，需要 ADMIN_TOKEN）",
+			"/api/admin/overview":     "跨租户运营看板：请求数、订单量、最近入库时间、时区分布和健康标记（sort/order 排序，window/stale_after/error_rate 阈值，需要 ADMIN_TOKEN）",
+			"/api/admin/faults":       "故障注入规则列表及命中统计（需要 ADMIN_TOKEN 和 FAULT_INJECTION=true）",
+			"POST /api/admin/faults":  "添加故障注入规则：按路径前缀/方法/租户对一定比例的请求增加延迟、返回 5xx 或断开数据库连接",
+			"DELETE /api/admin/faults": "删除全部故障注入规则",
+			"DELETE /api/admin/faults/{id}": "删除一条故障注入规则",
+			"/api/admin/captures":     "录制的请求和响应（脱敏、截断，按时间倒序，tenant 过滤租户，需要 ADMIN_TOKEN）",
+			"DELETE /api/admin/captures": "删除录制记录（tenant 为空时删除全部）",
+			"/api/admin/captures/{id}": "单条录制记录",
+			"POST /api/admin/captures/{id}/replay": "在本实例重放录制的 GET 请求，并与录制时的响应比较",
+			"/api/admin/captures/tenants": "正在录制的租户",
+			"PUT /api/admin/captures/tenants/{tenant}": "为租户开启录制（请求体 ttl 为有效期，默认 1h，最长 24h）",
+			"DELETE /api/admin/captures/tenants/{tenant}": "关闭租户的录制",
+			"/api/admin/consistency": "订单表与分析视图的一致性检查记录（按开始时间倒序，limit 默认 20，需要 ADMIN_TOKEN）",
+			"/api/admin/consistency/{id}": "一次一致性检查及其差异明细",
+			"POST /api/admin/consistency/run": "立即执行一次一致性检查",
+			"/api/admin/rollup/audit": "比对订单小时汇总表与分析视图（from/to 为本地日期，默认最近 7 天，需要 ADMIN_TOKEN）",
+			"POST /api/admin/rollup/rebuild": "从订单表重建小时汇总表（merchant_id 为空时重建全部商户）",
+			"/api/admin/retention": "商户的订单保留策略和最近的归档记录（limit 默认 20，需要 ADMIN_TOKEN）",
+			"PUT /api/admin/retention/{id}": "设置商户的保留策略（archive_after_months 为保留的本地自然月数，target 为 table 或 object）",
+			"DELETE /api/admin/retention/{id}": "删除商户的保留策略，已归档的订单不会恢复",
+			"POST /api/admin/retention/run": "立即按保留策略归档早于保留期的订单（merchant_id 为空时处理全部商户）",
+			"/api/admin/retention/runs/{id}": "一次归档及其每个商户、每个月份的明细",
+			"/api/admin/jobs": "后台任务列表，按 queue、kind、status 筛选（limit 默认 50）",
+			"POST /api/admin/jobs": "提交后台任务：kind（report.run、retention.archive、rollup.rebuild）、payload、run_at、max_attempts、dedupe_key",
+			"/api/admin/jobs/{id}": "单个后台任务，包括执行次数、最近一次的错误和执行结果",
+			"POST /api/admin/jobs/{id}/retry": "失败或已取消的任务重新排队，执行次数清零",
+			"POST /api/admin/jobs/{id}/cancel": "取消排队或执行中的任务",
+			"/api/admin/jobs/schedules": "后台任务的定时计划",
+			"PUT /api/admin/jobs/schedules/{name}": "新增或覆盖定时计划：kind、payload、schedule（类 RRULE 或 cron 表达式，可带 CRON_TZ=）、timezone、enabled",
+			"DELETE /api/admin/jobs/schedules/{name}": "删除定时计划，已生成的任务不受影响",
+			"POST /api/admin/tenants": "开通租户（商户信息同入驻接口，settings 为配置项，isolation 为 shared 或 rls，trial_days 为试用天数），在一个事务中创建商户、默认配置、Webhook 占位、API 密钥、隔离策略和示例订单，API 密钥只返回一次",
+			"DELETE /api/admin/tenants/{id}/api-keys/{key_id}": "吊销租户的 API 密钥，立即失效；请求通过 X-API-Key 携带未吊销的密钥时租户为密钥所属的商户",
+			"/api/admin/tenants/{id}/lifecycle": "租户的生命周期状态（trial、active、suspended、archived）和最近的变更记录",
+			"POST /api/admin/tenants/{id}/lifecycle": "变更租户的生命周期（state、reason、operator，state 为 trial 时 trial_ends_on 为试用的最后一天），暂停的租户数据接口返回 402，归档的返回 403，账单接口不受限制",
+			"/api/admin/tenants/{id}/export": "以 JSON 文件下载商户的全部数据（配置、订单、退款、流水、审计记录等，需要 ADMIN_TOKEN）",
+			"POST /api/admin/tenants/{id}/erase": "删除或匿名化商户的全部数据（mode 为 delete 或 anonymize，confirm 为商户名称，dry_run 只统计行数），返回逐表核验报告",
+			"/api/admin/tenants/erasures": "最近的删除和匿名化记录（merchant_id 可选，limit 默认 20）",
+			"/api/admin/tenants/erasures/{id}": "一次删除或匿名化的核验报告",
+			"/api/admin/merchants/export": "导出全部商户（format=json 或 csv，CSV 可直接再次导入，需要 ADMIN_TOKEN）",
+			"POST /api/admin/merchants/import": "批量导入商户（CSV 或 JSON 数组，校验时区、ISO 3166 国家代码和名称+城市重复；dry_run=true 只返回逐行报告）",
+			"POST /api/admin/query": "只读 SQL 控制台：以 saasview_console 角色执行单条 SELECT，只能读取分析视图，受语句超时和行数上限约束（需要 ADMIN_TOKEN 和 operator）",
+			"/api/admin/query/audit": "SQL 控制台的审计记录（包括被拒绝的语句，limit 默认 20）",
+			"/api/admin/backfill":    "本地时间字段回填任务及进度（只在 ANALYTICS_BACKEND=clickhouse 时可用，需要 ADMIN_TOKEN）",
+			"POST /api/admin/backfill": "创建并在后台执行回填任务：按当前规则重新镜像 merchant_ids 的订单到 ClickHouse",
+			"/api/admin/backfill/{id}": "单个回填任务的进度",
+			"POST /api/admin/backfill/{id}/resume": "从游标处继续执行中断或失败的回填任务",
+			"/api/admin/ingest/deliveries": "Webhook 投递记录（provider、status 过滤，status=failed 为死信，status=conflict 为按冲突策略未写入的投递，limit 默认 100，需要 ADMIN_TOKEN）",
+			"POST /api/admin/ingest/deliveries/{id}/replay": "重新处理一次投递（不再校验签名），用于修正商户或数据后的死信",
+			"POST /api/admin/ingest/deliveries/replay": "重放全部死信（provider 为空时不限平台，limit 默认 100）",
+			"/api/admin/orgs":        "全部组织及其门店ID（需要 ADMIN_TOKEN）",
+			"POST /api/admin/orgs":   "创建组织：name、hq_timezone（总部时区）、merchant_ids（门店，每个商户最多属于一个组织）",
+			"PUT /api/admin/orgs/{id}/merchants": "替换组织的门店，移出的商户成为独立商户，已属于其他组织的商户返回 409",
+			"/api/admin/orgs/{id}/tokens": "组织令牌列表（不含明文）",
+			"POST /api/admin/orgs/{id}/tokens": "签发组织令牌，明文只在本次响应中返回",
+			"DELETE /api/admin/orgs/{id}/tokens/{token_id}": "吊销组织令牌，立即失效",
+			"/api/timezone/demo":     "时区处理演示（as_of 指定 UTC 时刻，默认当前时间；带强 ETag 和 Cache-Control）",
+			"/api/timezone/merchants": "获取商户列表，每个商户的 links 为自身、订单、时间边界、自定义属性和时区对比的相对 URL",
+			"/api/timezone/merchants/{id}": "获取单个商户（含 links）",
+			"/api/timezone/orders":    "获取订单列表（支持时区转换）；merchants=1,2 按商户过滤，date、from/to、range 按订单本地日期过滤，view_id 引用保存的筛选视图；tag=region:emea 按当前租户（X-Tenant-ID）的自定义属性过滤（可重复，全部满足，订单的取值覆盖商户的取值）",
+			"/api/timezone/orders/{id}": "获取单个订单（含 links：商户、同一商户同一本地日期的订单、本地日期的分析、退款、自定义属性和下单时刻的时区对比）",
+			"/api/timezone/orders/{id}/attributes": "订单的自定义属性取值及叠加商户取值后的有效取值（effective）",
+			"PATCH /api/timezone/orders/{id}/attributes": "修改订单的自定义属性取值（attributes 中为 null 的键被删除），按定义的类型校验",
+			"/api/timezone/orders/{id}/refunds": "订单退款记录（含原订单和退款的本地日期）",
+			"POST /api/timezone/orders/{id}/refunds": "创建订单（部分）退款，累计退款不能超过订单金额",
+			"/api/timezone/analysis":  "获取分析数据（基于视图）；aggregate_tz=Europe/London 按该时区而不是商户本地时间划分日期和小时（需要管理令牌，响应 time_basis 为 aggregate_timezone）；group_by=tag:region 按自定义属性的取值和币种分组返回 groups",
+			"/api/timezone/analysis/batch": "批量获取多个日期的分析数据（dates 逗号分隔或 month=YYYY-MM，最多 62 个日期，一条语句完成查询）",
+			"/api/timezone/analysis/cohorts": "商户注册周群组的订单留存（注册日期和订单都按商户本地日期分周，from/to 注册日期范围，weeks 统计周数，week_start 周起始日）",
+			"/api/timezone/analysis/business-day": "全球营业日报告（date 为 UTC 日期）：每个 UTC 小时处于营业时间的商户时区数，以及各地区（Asia、Europe、America 等）订单在营业时间内外的占比，用于按时区接力安排客服",
+			"/api/timezone/analysis/closed": "获取日结数据（读取不可修改的日结快照及调整）",
+			"POST /api/ingest/webhooks/{provider}": "外部平台的订单 Webhook（provider 为 shopify 或 stripe，merchant_id 指定商户）：校验平台签名后保存投递，映射为订单写入 dws_orders，各种时间格式按商户时区换算为 UTC；订单号在该商户下已存在时按商户的 order_conflict_policy（reject / upsert / version）处理，未写入的投递返回 conflict 详情；处理失败的投递保留为死信",
+			"POST /api/sync": "离线同步：推送订单修改（insert/update，带 mutation_id 和 base_version），按冲突规则处理后返回结果和 since 之后的变更；设备时刻按 client_time 校正时钟偏差后换算为 UTC",
+			"/api/changes": "商户和订单的变更流水（insert/update/delete 及实体快照），按 seq 升序；since 为上次的 next_seq，wait=30s 时没有新变更则等待（long polling，最长 60s），merchant_id、entity、limit 过滤",
+			"/api/dashboard/summary": "首页概览（merchant_id 必填）：商户本地今天截至当前的订单、与昨天同期的对比、当前本地时间和营业状态、最近 24 个本地小时，一次请求一次查询",
+			"/api/timezone/reconciliation":  "迟到订单对账（本地日期结账后才入库的订单）",
+			"POST /api/timezone/reconciliation/adjust": "为未调整的迟到订单追加日结调整记录（不修改快照）",
+			"/api/timezone/reconciliation/events": "核对已接收的支付平台 Webhook 事件（provider 默认 stripe，from/to 为事件的 UTC 日期，默认最近 7 天）：事件的 UTC 日期、商户本地日期与订单本地日期是否一致，按时区汇总",
+			"POST /api/timezone/reconciliation/events": "核对上传的支付平台事件（Stripe 事件列表或数组，created 为 Unix 秒），结果与 GET 相同",
 			"/api/timezone/compare":   "时区对比分析",
+			"/api/timezone/overlap":   "多商户营业时间重叠分析（会议时段查找）",
+			"/api/timezone/merchants/{id}/boundaries": "商户下一个本地午夜、营业开始/结束、周/月切换的UTC时刻",
+			"/api/timezone/merchants/{id}/now": "商户当前的本地时间、偏移、夏令时和营业状态及距下次营业开始/结束的秒数，不访问数据库，max-age 到下一次状态变化",
+			"/api/timezone/merchants/{id}/calendar.ics": "商户的日历订阅（iCalendar）：营业时间、节假日（配置项 holidays）和定时报表的预计执行时刻，VTIMEZONE 与服务端的时区数据一致",
+			"/api/timezone/merchants/{id}/attributes": "商户的自定义属性取值",
+			"PATCH /api/timezone/merchants/{id}/attributes": "修改商户的自定义属性取值（attributes 中为 null 的键被删除），按定义的类型校验",
+			"/api/timezone/merchants/{id}/peak-hours": "商户最近 N 周（weeks，默认 4）显著高于当天平均的本地高峰小时（配对 t 检验，confidence 默认 0.95）和合并后的建议排班时段",
+			"/api/timezone/merchants/{id}/fulfillment": "商户最近 N 周（weeks，默认 4）下单到发货的时长，按下单的本地小时和星期分组的平均值和 p50/p90/p95，列出履约偏慢的下单小时",
+			"/api/timezone/schedule/expand":           "按本地时间展开类RRULE重复规则，返回UTC时刻（处理夏令时跳过/重复）",
+			"/api/timezone/schedule/validate":         "校验定时规则（cron 表达式，支持 CRON_TZ=<时区> 和 CRON_TZ=@merchant_local，或类RRULE规则），预览接下来 10 次的UTC和本地时间",
+			"/api/timezone/lookup":                    "根据经纬度查询时区（内置城市数据集，远离已知城市时返回航海时区）",
+			"POST /api/timezone/convert":              "批量时区转换（最多10000项，返回偏移、夏令时标记和跨日天数，不访问数据库）",
+			"/api/timezone/tzdata":                    "构建时和运行时使用的 tzdata 版本",
+			"/api/timezone/rules":                     "时区在日期范围内的历史偏移切换（核对历史订单按哪一版规则换算）",
+			"/api/reference/countries":                "国家参考数据（ISO 代码、默认时区、默认币种、周末），q 按名称或代码过滤",
+			"/api/reference/countries/{code}":         "按代码、英文名或中文名查询一个国家",
+			"/api/reference/cities":                   "城市参考数据（country 按国家过滤，q 按名称过滤）",
+			"/api/billing/periods":                    "商户计费周期（按商户本地零点锚定，处理月末和夏令时）",
+			"POST /api/merchants/onboard":             "商户入驻（根据国家/城市/地址推断时区，校验营业时间，创建商户及默认报表/Webhook配置，contact_email 接收欢迎邮件）",
+			"/api/merchants/{id}/sla": "商户的 SLA 统计（X-Tenant-ID 等于商户ID的请求在滚动窗口内的响应时间分位数、错误率和预算状态）",
+			"/api/merchants/{id}/settings":           "商户配置（营业时间、周末、语言、报表计划、币种偏好、Webhook 订单号冲突策略，未设置的返回默认值）",
+			"PUT /api/merchants/{id}/settings/{key}":  "修改商户的一项配置，营业时间和周末同步到分析视图",
+			"DELETE /api/merchants/{id}/settings/{key}": "删除商户单独设置的配置，恢复默认值",
+			"/api/orgs/{id}":          "组织及其门店详情（Authorization: Bearer 组织令牌或 ADMIN_TOKEN，组织令牌只能访问所属组织）",
+			"/api/reports/definitions": "保存的报表定义（分析参数、日期范围类型、时区口径、格式和定时规则）",
+			"POST /api/reports/definitions": "保存报表定义：name、merchant_id、timezone_mode（merchant|utc）、range_type（today、yesterday、last_7_days 等或 custom + from/to）、statuses、currency、format（json|csv）、schedule（类 RRULE 或 cron 表达式，按报表时区或 CRON_TZ 执行，CRON_TZ=@merchant_local 为商户时区）、emails（执行完成后按 report_ready 模板通知）",
+			"PUT /api/reports/definitions/{id}": "覆盖报表定义，重新计算下一次执行时刻",
+			"DELETE /api/reports/definitions/{id}": "删除报表定义及其执行记录",
+			"POST /api/reports/definitions/{id}/run": "立即执行报表，返回执行记录和结果文件地址",
+			"/api/reports/definitions/{id}/runs": "报表最近的执行记录（limit 默认 20），完成的执行带 artifact_url",
+			"/api/reports/runs/{id}/artifact": "下载一次执行生成的结果文件（JSON 或 CSV），支持 Range 断点续传和 ETag/If-Range 校验",
+			"/api/alerts/rules":                "当前租户（X-Tenant-ID）的指标告警规则及其状态（ok|firing）",
+			"POST /api/alerts/rules":           "创建告警规则：name、merchant_id、type（no_orders + window_hours 营业小时数 | revenue_drop + threshold_percent 相对上周同期的百分比）、currency、statuses、webhook_url、emails、enabled",
+			"PUT /api/alerts/rules/{id}":        "覆盖告警规则的配置，规则状态不变",
+			"DELETE /api/alerts/rules/{id}":     "删除告警规则及其告警历史",
+			"POST /api/alerts/rules/{id}/evaluate": "立即检查告警规则，状态变化时发送通知并写入告警历史",
+			"/api/alerts/events":               "当前租户的告警历史（firing/resolved），可按 rule_id 过滤，limit 默认 50",
+			"/api/attributes/definitions":      "当前租户（X-Tenant-ID）为商户和订单定义的自定义属性",
+			"PUT /api/attributes/definitions/{entity}/{key}": "新增或覆盖属性定义（entity 为 merchant 或 order）：type（string|number|boolean|date|enum）、values（enum 的可选值）、description；同名属性在商户和订单上的类型必须一致",
+			"DELETE /api/attributes/definitions/{entity}/{key}": "删除属性定义及已设置的取值",
+			"/api/views":                       "当前租户（X-Tenant-ID）保存的筛选视图，owner 按用户过滤",
+			"POST /api/views":                  "保存筛选视图：owner、name、timezone、merchant_ids、statuses、range（today、yesterday、last_7_days 等相对范围）；订单列表和分析接口用 view_id=<ID> 引用，请求中显式指定的参数优先",
+			"PUT /api/views/{id}":              "覆盖视图的名称和筛选条件，引用该视图的链接随之使用新的条件",
+			"DELETE /api/views/{id}":           "删除筛选视图",
+			"/api/email/templates":             "当前租户（X-Tenant-ID）可用的邮件模板（alert_firing、alert_resolved、report_ready、merchant_welcome），source 为 builtin 或 tenant",
+			"/api/email/templates/{name}":      "按 locale（缺省 zh）查询实际使用的模板，租户没有覆盖时为内置模板",
+			"PUT /api/email/templates/{name}":  "覆盖当前租户 locale 语言的模板：subject、html、text（Go 模板语法，可用 date、datetime、datetimeIn、offset、money 等函数），保存前用示例数据校验",
+			"DELETE /api/email/templates/{name}": "删除当前租户覆盖的模板，恢复使用内置模板",
+			"POST /api/email/templates/{name}/preview": "使用示例数据按 locale 和 timezone（缺省 Asia/Tokyo）渲染模板，请求体为草稿时渲染草稿",
+			"/api/orgs/{id}/analysis": "组织全部门店的订单汇总（date 必填；mode=local 按各门店本地日期，mode=hq 按总部时区日期；status 过滤订单状态），含按币种合计、按小时分布和各门店明细",
 		},
 		"examples": map[string]string{
 			"获取商户列表":     "/api/timezone/merchants",
 			"获取订单（带时区）":   "/api/timezone/orders?timezone=Asia/Shanghai",
 			"分析特定日期":     "/api/timezone/analysis?date=2024-08-19",
+			"单一币种合计":     "/api/timezone/analysis?date=2024-08-19&currency=USD",
+			"按总部时区汇总":    "/api/timezone/analysis?date=2024-08-19&aggregate_tz=Europe/London（需要管理令牌）",
+			"整月日历":       "/api/timezone/analysis/batch?month=2024-08",
+			"注册周群组留存":    "/api/timezone/analysis/cohorts?from=2023-12-25&to=2024-01-07&weeks=34",
+			"全球营业日":      "/api/timezone/analysis/business-day?date=2024-08-19",
+			"订单退款记录":     "/api/timezone/orders/1/refunds",
+			"按自定义属性过滤订单": "/api/timezone/orders?tag=region:emea&tag=vip:true",
+			"按自定义属性分组":   "/api/timezone/analysis?date=2024-08-19&group_by=tag:region",
+			"使用保存的视图":    "/api/timezone/orders?view_id=3",
+			"日结数据":       "/api/timezone/analysis/closed?date=2024-08-19",
+			"首页概览":       "/api/dashboard/summary?merchant_id=2",
+			"增量同步":       "/api/changes?since=0&merchant_id=2&wait=30s",
+			"迟到订单对账":     "/api/timezone/reconciliation?date=2024-08-19&merchant_id=1",
+			"支付事件日期核对":   "/api/timezone/reconciliation/events?provider=stripe&from=2024-08-01&to=2024-08-07",
 			"时区对比":       "/api/timezone/compare?utc_time=2024-08-19T00:00:00Z",
+			"世界时钟":       "/api/timezone/compare?utc_time=now&timezones=Asia/Tokyo,Europe/Paris,America/New_York",
+			"会议时段查找":     "/api/timezone/overlap?merchants=1,2,3&window=60m&date=2024-08-19",
+			"商户时间边界":     "/api/timezone/merchants/1/boundaries?at=now",
+			"商户高峰小时":     "/api/timezone/merchants/2/peak-hours?weeks=2&to=2024-08-18",
+			"商户履约时长":     "/api/timezone/merchants/2/fulfillment?weeks=2&to=2024-08-18",
+			"工作日9点展开":    "/api/timezone/schedule/expand?merchant_id=1&from=2024-03-01&to=2024-03-31&rule=FREQ%3DWEEKLY%3BBYDAY%3DMO%2CTU%2CWE%2CTH%2CFR%3BBYHOUR%3D9",
+			"坐标查时区":      "/api/timezone/lookup?lat=31.23&lon=121.47",
+			"国家的城市":      "/api/reference/cities?country=JP",
+			"历史偏移切换":     "/api/timezone/rules?timezone=Europe/Moscow&from=2010-01-01&to=2016-01-01",
+			"计费周期":       "/api/billing/periods?merchant_id=1&through=2024-12-31",
+			"商户配置":       "/api/merchants/1/settings",
+			"组织总部口径分析":   "/api/orgs/1/analysis?date=2024-08-19&mode=hq",
 		},
 	}
 
-	response := APIResponse{
-		Success: true,
-		Message: "API文档",
-		Data:    docs,
-	}
-	respondJSON(w, http.StatusOK, response)
+	respondSuccess(w, r, http.StatusOK, "docs.ok", docs)
 }
 
 // timezoneDemo 时区处理演示
+// as_of=2024-08-19T00:00:00Z 指定演示的 UTC 时刻，默认当前时间（取整到分钟）
+// 结果只取决于 as_of、商户时区和 tzdata 版本：响应带强 ETag，If-None-Match 命中时返回 304；
+// 指定 as_of 时可缓存一天，未指定时缓存到下一分钟
 func timezoneDemo(w http.ResponseWriter, r *http.Request) {
-	demo, err := timezoneService.GetTimezoneDemo()
-	if err != nil {
-		response := APIResponse{
-			Success: false,
-			Message: "获取时区演示数据失败",
-			Error:   err.Error(),
+	now := time.Now().UTC()
+	asOf, maxAge := now.Truncate(time.Minute), now.Truncate(time.Minute).Add(time.Minute).Sub(now)
+	if value := r.URL.Query().Get("as_of"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			err = fmt.Errorf("%w: as_of 格式应为 RFC3339（如 2024-08-19T00:00:00Z）: %q", services.ErrInvalidArgument, value)
+			respondError(w, r, errorStatus(err), "demo.failed", err)
+			return
 		}
-		respondJSON(w, http.StatusInternalServerError, response)
+		asOf, maxAge = t, demoMaxAge
+	}
+
+	demo, err := timezoneService.GetTimezoneDemo(asOf)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "demo.failed", err)
+		return
+	}
+	demo.TZDataVersion = demoTZDataVersion()
+
+	etag := demoETag(r, demo, negotiateLocale(w, r).Code())
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	if ifNoneMatch(r, etag) {
+		w.Header().Add("Vary", "Accept")
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
+	respondSuccess(w, r, http.StatusOK, "demo.ok", demo)
+}
 
-	response := APIResponse{
-		Success: true,
-		Message: "时区处理演示数据",
-		Data:    demo,
+// demoMaxAge 指定 as_of 时演示结果的缓存时间；商户时区变化时 ETag 随之变化，过期后按 ETag 重新校验
+const demoMaxAge = 24 * time.Hour
+
+// demoTZDataVersion 当前加载时区使用的 tzdata 版本（重新加载后随之变化），都读不到时为 Go 自带 zoneinfo.zip 所属的 Go 版本
+func demoTZDataVersion() string {
+	info := tzdb.Detect()
+	if version := info.EffectiveVersion(); version != "" {
+		return version
+	}
+	return info.GoVersion
+}
+
+// demoETag 演示结果的强 ETag：as_of、tzdata 版本、各商户的时区，以及响应格式和语言
+func demoETag(r *http.Request, demo *models.TimezoneDemo, lang string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%s|%s|%s|", demo.UTCTime, demo.TZDataVersion, negotiateFormat(r), lang)
+	for _, tz := range demo.Timezones {
+		fmt.Fprintf(h, "%s,%s,%s;", tz.Timezone, tz.Country, tz.City)
+	}
+	return fmt.Sprintf(`"demo-%x"`, h.Sum(nil)[:16])
+}
+
+// ifNoneMatch 请求的 If-None-Match 是否包含 etag（或为 *）
+func ifNoneMatch(r *http.Request, etag string) bool {
+	for _, value := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		if value = strings.TrimSpace(value); value == etag || value == "*" {
+			return true
+		}
 	}
-	respondJSON(w, http.StatusOK, response)
+	return false
 }
 
 // getMerchants 获取商户列表
+// 未指定 limit 时返回全部商户；meta 中的 total_count 总是精确的
+// 数据库不可用时返回最近一次成功读取的列表，并带 X-Served-From-Cache 头（值为缓存时间）
 func getMerchants(w http.ResponseWriter, r *http.Request) {
-	merchants, err := timezoneService.GetMerchants()
+	merchants, cachedAt, err := timezoneService.GetMerchantsWithFallback()
 	if err != nil {
-		response := APIResponse{
-			Success: false,
-			Message: "获取商户列表失败",
-			Error:   err.Error(),
-		}
-		respondJSON(w, http.StatusInternalServerError, response)
+		respondError(w, r, errorStatus(err), "merchants.list_failed", err)
 		return
 	}
 
-	response := APIResponse{
-		Success: true,
-		Message: fmt.Sprintf("获取到 %d 个商户", len(merchants)),
-		Data:    merchants,
+	page := parsePage(r, 0)
+	total := len(merchants)
+	merchants = merchants[min(page.Offset, total):]
+	hasMore := false
+	if page.Limit > 0 && len(merchants) > page.Limit {
+		merchants, hasMore = merchants[:page.Limit], true
+	}
+
+	meta := newPageMeta(r, page, len(merchants), hasMore, int64(total))
+	merchants = linkMerchants(merchants)
+	if !cachedAt.IsZero() {
+		w.Header().Set("X-Served-From-Cache", cachedAt.UTC().Format(time.RFC3339))
+		respondPage(w, r, "merchants.listed_cached", merchants, meta, len(merchants), cachedAt.UTC().Format(time.RFC3339))
+		return
+	}
+	respondPage(w, r, "merchants.listed", merchants, meta, len(merchants))
+}
+
+// getMerchant 获取单个商户及其相关资源的链接
+func getMerchant(w http.ResponseWriter, r *http.Request) {
+	id, err := parseMerchantID(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "merchants.get_failed", err)
+		return
+	}
+	merchant, err := timezoneService.GetMerchant(id)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "merchants.get_failed", err)
+		return
 	}
-	respondJSON(w, http.StatusOK, response)
+	merchant.Links = merchantLinks(merchant)
+	respondSuccess(w, r, http.StatusOK, "merchants.get", merchant, merchant.Name)
 }
 
 // getOrders 获取订单列表
+// status=paid,shipped 只返回指定状态的订单
+// tag=region:emea 只返回当前租户自定义属性的有效取值匹配的订单，可重复指定
+// merchants=1,2 只返回这些商户的订单；date、from/to 或 range 按订单的本地日期过滤，相对日期按 UTC 的今天计算
+// view_id 引用保存的筛选视图，见 withSavedView
+// humanize=true 时按请求语言附加下单时刻的相对描述 order_time_humanized
 func getOrders(w http.ResponseWriter, r *http.Request) {
 	// 解析查询参数
 	timezone := r.URL.Query().Get("timezone")
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
+	page := parsePage(r, 20)
 
-	limit := 20 // 默认限制
-	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
-		}
+	statuses, err := services.ParseStatuses(r.URL.Query().Get("status"))
+	if err != nil {
+		respondError(w, r, errorStatus(err), "orders.list_failed", err)
+		return
+	}
+	humanize, err := queryHumanize(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "orders.list_failed", err)
+		return
 	}
 
-	offset := 0 // 默认偏移
-	if offsetStr != "" {
-		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-			offset = o
-		}
+	formatting, err := services.ParseOrderFormatting(r.URL.Query().Get("formatting"), orderFormatting)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "orders.list_failed", err)
+		return
 	}
 
-	orders, err := timezoneService.GetOrders(timezone, limit, offset)
+	var merchantIDs []int
+	if value := r.URL.Query().Get("merchants"); value != "" {
+		if merchantIDs, err = parseIDList(value); err != nil {
+			respondError(w, r, errorStatus(err), "orders.list_failed", err)
+			return
+		}
+	}
+	dates, err := queryRange(r, time.UTC)
 	if err != nil {
-		response := APIResponse{
-			Success: false,
-			Message: "获取订单列表失败",
-			Error:   err.Error(),
+		respondError(w, r, errorStatus(err), "orders.list_failed", err)
+		return
+	}
+
+	// 多取一条判断是否还有下一页
+	filter := models.OrderFilter{
+		Timezone: timezone, Statuses: statuses, Limit: page.Limit + 1, Offset: page.Offset, Formatting: formatting,
+		MerchantIDs: merchantIDs, From: dates.From, To: dates.To,
+	}
+	if tags := r.URL.Query()["tag"]; len(tags) > 0 {
+		if !attributesEnabled(w, r) {
+			return
 		}
-		respondJSON(w, http.StatusInternalServerError, response)
+		filter.Tenant = services.TenantFromContext(r.Context())
+		if filter.Attributes, err = attributeService.ParseTagFilters(r.Context(), tags); err != nil {
+			respondError(w, r, errorStatus(err), "orders.list_failed", err)
+			return
+		}
+	}
+	orders, err := timezoneService.GetOrders(filter)
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, "orders.list_failed", err)
 		return
 	}
+	hasMore := len(orders) > page.Limit
+	if hasMore {
+		orders = orders[:page.Limit]
+	}
+
+	// 最后一页可以直接得出总数，否则使用估算值
+	estimate := int64(-1)
+	if hasMore || (len(orders) == 0 && page.Offset > 0) {
+		filter.Limit, filter.Offset = page.Limit, 0
+		if estimate, err = timezoneService.EstimateOrderCount(r.Context(), filter); err != nil {
+			log.Printf("⚠️ 估算订单总数失败: %v", err)
+			estimate = -1
+		}
+	}
+	meta := newPageMeta(r, page, len(orders), hasMore, estimate)
+
+	// raw 方式连同按语言渲染的展示字段一起跳过
+	if formatting != models.OrderFormattingRaw {
+		services.LocalizeOrders(orders, negotiateLocale(w, r))
+	}
+	if humanize {
+		services.HumanizeOrders(orders, negotiateLocale(w, r), time.Now())
+	}
+	linkOrders(orders)
 
-	message := fmt.Sprintf("获取到 %d 条订单", len(orders))
 	if timezone != "" {
-		message += fmt.Sprintf("（时区: %s）", timezone)
+		respondPage(w, r, "orders.listed_in_timezone", orders, meta, len(orders), timezone)
+		return
 	}
+	respondPage(w, r, "orders.listed", orders, meta, len(orders))
+}
 
-	response := APIResponse{
-		Success: true,
-		Message: message,
-		Data:    orders,
+// getOrder 获取单个订单及其相关资源的链接，formatting、humanize 与订单列表相同
+func getOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		err = fmt.Errorf("%w: 无效的订单ID %q", services.ErrInvalidArgument, mux.Vars(r)["id"])
+		respondError(w, r, errorStatus(err), "orders.get_failed", err)
+		return
 	}
-	respondJSON(w, http.StatusOK, response)
+	formatting, err := services.ParseOrderFormatting(r.URL.Query().Get("formatting"), orderFormatting)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "orders.get_failed", err)
+		return
+	}
+	humanize, err := queryHumanize(r)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "orders.get_failed", err)
+		return
+	}
+	order, err := timezoneService.GetOrder(id, formatting)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "orders.get_failed", err)
+		return
+	}
+	orders := []models.OrderAnalysis{*order}
+	if formatting != models.OrderFormattingRaw {
+		services.LocalizeOrders(orders, negotiateLocale(w, r))
+	}
+	if humanize {
+		services.HumanizeOrders(orders, negotiateLocale(w, r), time.Now())
+	}
+	linkOrders(orders)
+	respondSuccess(w, r, http.StatusOK, "orders.get", &orders[0], orders[0].OrderNumber)
 }
 
 // getAnalysisData 获取分析数据
+// 金额按币种分组返回 totals_by_currency；currency=USD 指定目标币种时额外返回该币种的 total_amount
+// status=paid,refunded 指定参与统计的订单状态，默认按营收口径排除已取消订单
+// 小时分解、时区统计或商户排行查询超时时仍返回 200，partial=true 并列出省略的部分
+// aggregate_tz=Europe/London 按该时区而不是商户本地时间划分日期和小时（全租户汇总），需要管理令牌，响应的 time_basis 为 aggregate_timezone
+// date 默认 today：指定 aggregate_tz 时为该时区的今天，否则为 UTC 的今天
+// group_by=tag:region 按当前租户自定义属性的取值分组，分组扫描分析视图，不包括已归档的订单
+// view_id 引用保存的筛选视图，只使用其中的订单状态和单日范围（today、yesterday）
 func getAnalysisData(w http.ResponseWriter, r *http.Request) {
-	date := r.URL.Query().Get("date")
-	if date == "" {
-		date = time.Now().Format("2006-01-02")
+	aggregateTZ := r.URL.Query().Get("aggregate_tz")
+	if aggregateTZ != "" && !requireAdmin(w, r) {
+		return
+	}
+	loc, err := aggregateLocation(aggregateTZ)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "analysis.failed", err)
+		return
+	}
+	date, err := queryDate(r, loc, "today")
+	if err != nil {
+		respondError(w, r, errorStatus(err), "analysis.failed", err)
+		return
 	}
 
-	analysis, err := timezoneService.GetAnalysisData(date)
+	statuses, err := services.ParseStatuses(r.URL.Query().Get("status"))
 	if err != nil {
-		response := APIResponse{
-			Success: false,
-			Message: "获取分析数据失败",
-			Error:   err.Error(),
-		}
-		respondJSON(w, http.StatusInternalServerError, response)
+		respondError(w, r, errorStatus(err), "analysis.failed", err)
 		return
 	}
 
-	response := APIResponse{
-		Success: true,
-		Message: fmt.Sprintf("获取 %s 的分析数据", date),
-		Data:    analysis,
+	analysis, err := timezoneService.GetAnalysisDataIn(r.Context(), date, aggregateTZ, r.URL.Query().Get("currency"), statuses)
+	if errors.Is(err, services.ErrOverloaded) {
+		respondError(w, r, errorStatus(err), "analysis.overloaded", err)
+		return
 	}
-	respondJSON(w, http.StatusOK, response)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "analysis.failed", err)
+		return
+	}
+
+	if groupBy := r.URL.Query().Get("group_by"); groupBy != "" {
+		if !attributesEnabled(w, r) {
+			return
+		}
+		if err := attributeService.GroupAnalysis(r.Context(), groupBy, analysis); err != nil {
+			respondError(w, r, errorStatus(err), "analysis.failed", err)
+			return
+		}
+	}
+
+	services.LocalizeAnalysis(analysis, negotiateLocale(w, r))
+
+	if analysis.Partial {
+		respondSuccess(w, r, http.StatusOK, "analysis.partial", analysis, date, strings.Join(analysis.OmittedSections, ", "))
+		return
+	}
+	if aggregateTZ != "" {
+		respondSuccess(w, r, http.StatusOK, "analysis.aggregate_ok", analysis, date, aggregateTZ)
+		return
+	}
+	respondSuccess(w, r, http.StatusOK, "analysis.ok", analysis, date)
 }
 
-// compareTimezones 时区对比分析
+// compareTimezones 时区对比分析（世界时钟）
+// utc_time=now 表示当前时间；timezones=Asia/Tokyo,Europe/Paris 指定任意时区集合
 func compareTimezones(w http.ResponseWriter, r *http.Request) {
 	utcTime := r.URL.Query().Get("utc_time")
 	if utcTime == "" {
 		utcTime = "2024-08-19T00:00:00Z"
+	} else if utcTime == "now" {
+		utcTime = time.Now().UTC().Format(time.RFC3339)
 	}
 
-	comparison, err := timezoneService.CompareTimezones(utcTime)
-	if err != nil {
-		response := APIResponse{
-			Success: false,
-			Message: "时区对比分析失败",
-			Error:   err.Error(),
+	var timezones []string
+	for _, tz := range strings.Split(r.URL.Query().Get("timezones"), ",") {
+		if tz = strings.TrimSpace(tz); tz != "" {
+			timezones = append(timezones, tz)
 		}
-		respondJSON(w, http.StatusInternalServerError, response)
+	}
+
+	comparison, err := timezoneService.CompareTimezones(utcTime, timezones)
+	if err != nil {
+		respondError(w, r, errorStatus(err), "compare.failed", err)
 		return
 	}
 
+	services.LocalizeComparison(comparison, negotiateLocale(w, r))
+
+	respondSuccess(w, r, http.StatusOK, "compare.ok", comparison, comparison.UTCTime)
+}
+
+// errorStatus 根据服务层错误类型选择 HTTP 状态码
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidArgument):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, context.DeadlineExceeded), database.IsStatementTimeout(err):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, services.ErrOverloaded), errors.Is(err, services.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// negotiateLocale 按 lang 查询参数、Accept-Language 请求头或商户配置的语言协商展示语言，并写入响应头
+func negotiateLocale(w http.ResponseWriter, r *http.Request) locale.Locale {
+	l := locale.Negotiate(r.URL.Query().Get("lang"), r.Header.Get("Accept-Language"), merchantLocale(r))
+	w.Header().Set("Content-Language", l.Code())
+	w.Header().Set("Vary", "Accept-Language")
+	return l
+}
+
+// queryHumanize humanize=true 时在响应中附加按请求语言生成的相对时间（如"3 小时前"、"明天 09:00"）
+func queryHumanize(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get("humanize")
+	if value == "" {
+		return false, nil
+	}
+	humanize, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%w: humanize 应为 true 或 false", services.ErrInvalidArgument)
+	}
+	return humanize, nil
+}
+
+// respondSuccess 输出成功响应，消息按请求语言从消息目录渲染
+func respondSuccess(w http.ResponseWriter, r *http.Request, statusCode int, code string, data interface{}, args ...interface{}) {
 	response := APIResponse{
 		Success: true,
-		Message: fmt.Sprintf("UTC时间 %s 的全球时区对比", utcTime),
-		Data:    comparison,
+		Code:    code,
+		Message: negotiateLocale(w, r).Message(code, args...),
+		Data:    data,
 	}
-	respondJSON(w, http.StatusOK, response)
+	respondNegotiated(w, r, statusCode, pseudonymizeResponse(w, r, response))
 }
 
-// respondJSON 统一的JSON响应函数
-func respondJSON(w http.ResponseWriter, statusCode int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(data)
+// respondError 输出失败响应，消息按请求语言从消息目录渲染，Error 为原始错误信息
+// 限流错误附带 Retry-After 响应头
+func respondError(w http.ResponseWriter, r *http.Request, statusCode int, code string, err error) {
+	var overloaded *services.OverloadedError
+	if errors.As(err, &overloaded) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(overloaded.RetryAfter.Seconds())))
+	}
+	var circuitOpen *database.CircuitOpenError
+	if errors.As(err, &circuitOpen) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(circuitOpen.RetryAfter.Seconds())+1))
+	}
+	response := APIResponse{
+		Success: false,
+		Code:    code,
+		Message: negotiateLocale(w, r).Message(code),
+		Error:   err.Error(),
+	}
+	respondNegotiated(w, r, statusCode, pseudonymizeResponse(w, r, response))
 }
 
 // getEnv 获取环境变量，如果不存在则返回默认值
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"timezone-saas-demo/services"
+	"timezone-saas-demo/testsupport"
+)
+
+// setupMockFaults 把 --mock-latency/--mock-jitter/--mock-error-rate 转换为故障注入规则
+func setupMockFaults(latency, jitter time.Duration, errorRate float64) error {
+	if latency > 0 || jitter > 0 {
+		req := services.FaultRuleRequest{Kind: services.FaultLatency, Percent: 100}
+		if latency > 0 {
+			req.Latency = latency.String()
+		}
+		if jitter > 0 {
+			req.Jitter = jitter.String()
+		}
+		if _, err := faultInjector.Add(req); err != nil {
+			return fmt.Errorf("--mock-latency/--mock-jitter 配置错误: %w", err)
+		}
+	}
+	if errorRate > 0 {
+		if _, err := faultInjector.Add(services.FaultRuleRequest{Kind: services.FaultError, Percent: errorRate * 100}); err != nil {
+			return fmt.Errorf("--mock-error-rate 配置错误: %w", err)
+		}
+	}
+	return nil
+}
+
+// setupMockServices 使用内存中的确定性示例数据创建全部服务，不连接数据库
+func setupMockServices(seed int64, endDate time.Time, alerter services.Alerter, mailer services.Mailer) {
+	fakes := testsupport.NewMockFakes(testsupport.MockOptions{Seed: seed, EndDate: endDate})
+	timezoneService = fakes.Service()
+	billingService = fakes.BillingService()
+	revenueCloseService = fakes.RevenueCloseService()
+	onboardingService = fakes.OnboardingService()
+	refundService = fakes.RefundService()
+	indexAdvisorService = fakes.IndexAdvisorService()
+	settingsService = fakes.SettingsService()
+	overviewService = fakes.OverviewService(requestCounter, timezoneService.TenantQueryStats)
+	consistencyService = fakes.ConsistencyService(alerter)
+	organizationService = fakes.OrganizationService()
+	reportService = fakes.ReportService(timezoneService)
+	emailService = fakes.EmailService(settingsService, mailer)
+	alertRuleService = fakes.AlertRuleService(emailService)
+	dashboardService = fakes.DashboardService()
+	changeService = fakes.ChangeService()
+	syncService = fakes.SyncService()
+	ingestService = fakes.IngestService()
+
+	merchants, _ := fakes.Merchants.Count()
+	orders, _ := fakes.Orders.Count()
+	log.Printf("🧪 mock 模式：%d 个商户、%d 笔订单（种子 %d），数据只保存在内存中", merchants, orders, seed)
+}
+
+// parseMockDate 解析 --mock-date，为空时使用示例数据的日期
+func parseMockDate(value string) (time.Time, error) {
+	if value == "" {
+		return testsupport.DefaultMockEndDate, nil
+	}
+	if value == "today" {
+		return time.Now().UTC(), nil
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("--mock-date 格式应为 YYYY-MM-DD 或 today: %q", value)
+	}
+	return date, nil
+}
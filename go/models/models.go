@@ -2,20 +2,46 @@ package models
 
 import (
 	"database/sql/driver"
+	"encoding/json"
 	"fmt"
 	"time"
+
+	"github.com/shopspring/decimal"
+
+	"timezone-saas-demo/tzdb"
 )
 
 // Merchant 商户模型
 type Merchant struct {
-	ID          int       `json:"id" db:"id"`
-	Name        string    `json:"name" db:"name"`
-	Timezone    string    `json:"timezone" db:"timezone"`
-	Country     string    `json:"country" db:"country"`
-	City        string    `json:"city" db:"city"`
-	Description string    `json:"description" db:"description"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	ID          int       `json:"id" xml:"id" db:"id"`
+	Name        string    `json:"name" xml:"name" db:"name"`
+	Timezone    string    `json:"timezone" xml:"timezone" db:"timezone"`
+	Country     string    `json:"country" xml:"country" db:"country"`
+	City        string    `json:"city" xml:"city" db:"city"`
+	// 国家代码和城市，关联 dim_country / dim_city，国家或城市不在参考数据中时为空
+	CountryCode string `json:"country_code,omitempty" xml:"country_code,omitempty" db:"country_code"`
+	CityID      *int   `json:"city_id,omitempty" xml:"city_id,omitempty" db:"city_id"`
+	Description string    `json:"description" xml:"description" db:"description"`
+	// 营业时间（本地时间 HH:MM），结束时间不晚于开始时间表示跨午夜
+	BusinessHoursStart string    `json:"business_hours_start" xml:"business_hours_start" db:"business_hours_start"`
+	BusinessHoursEnd   string    `json:"business_hours_end" xml:"business_hours_end" db:"business_hours_end"`
+	// 周末的星期序号（0=周日 … 6=周六），按国家默认，中东部分国家为周五周六
+	WeekendDays []int `json:"weekend_days" xml:"weekend_days>item" db:"weekend_days"`
+	CreatedAt   time.Time `json:"created_at" xml:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" xml:"updated_at" db:"updated_at"`
+	// Links 相关资源的相对 URL，只在商户接口的响应中设置
+	Links *MerchantLinks `json:"links,omitempty" xml:"links,omitempty"`
+}
+
+// MerchantLinks 商户相关资源的相对 URL，客户端按名称导航，不需要拼接 URL 模板
+type MerchantLinks struct {
+	Self string `json:"self" xml:"self"`
+	// Orders 该商户的订单列表
+	Orders     string `json:"orders" xml:"orders"`
+	Boundaries string `json:"boundaries" xml:"boundaries"`
+	Attributes string `json:"attributes" xml:"attributes"`
+	// TimezoneCompare 当前时刻在该商户时区的对比
+	TimezoneCompare string `json:"timezone_compare" xml:"timezone_compare"`
 }
 
 // Order 订单模型
@@ -23,10 +49,12 @@ type Order struct {
 	ID           int       `json:"id" db:"id"`
 	MerchantID   int       `json:"merchant_id" db:"merchant_id"`
 	OrderNumber  string    `json:"order_number" db:"order_number"`
-	Amount       float64   `json:"amount" db:"amount"`
+	Amount       decimal.Decimal `json:"amount" db:"amount"`
 	Currency     string    `json:"currency" db:"currency"`
 	Status       string    `json:"status" db:"status"`
 	OrderTimeUTC time.Time `json:"order_time_utc" db:"order_time_utc"`
+	// Version 订单版本号，快照字段变化时加一，离线同步用于发现冲突
+	Version      int       `json:"version" db:"version"`
 	CreatedAt    time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -34,126 +62,223 @@ type Order struct {
 // OrderAnalysis 订单分析模型（对应视图）
 type OrderAnalysis struct {
 	// 基础订单信息
-	OrderID      int     `json:"order_id" db:"order_id"`
-	OrderNumber  string  `json:"order_number" db:"order_number"`
-	Amount       float64 `json:"amount" db:"amount"`
-	Currency     string  `json:"currency" db:"currency"`
-	Status       string  `json:"status" db:"status"`
+	OrderID      int     `json:"order_id" xml:"order_id" db:"order_id"`
+	OrderNumber  string  `json:"order_number" xml:"order_number" db:"order_number"`
+	Amount       decimal.Decimal `json:"amount" xml:"amount" db:"amount"`
+	Currency     string  `json:"currency" xml:"currency" db:"currency"`
+	Status       string  `json:"status" xml:"status" db:"status"`
 
 	// 商户信息
-	MerchantID   int    `json:"merchant_id" db:"merchant_id"`
-	MerchantName string `json:"merchant_name" db:"merchant_name"`
-	Timezone     string `json:"timezone" db:"timezone"`
-	Country      string `json:"country" db:"country"`
-	City         string `json:"city" db:"city"`
+	MerchantID   int    `json:"merchant_id" xml:"merchant_id" db:"merchant_id"`
+	MerchantName string `json:"merchant_name" xml:"merchant_name" db:"merchant_name"`
+	Timezone     string `json:"timezone" xml:"timezone" db:"timezone"`
+	Country      string `json:"country" xml:"country" db:"country"`
+	City         string `json:"city" xml:"city" db:"city"`
 
 	// 时间信息（核心）
-	OrderTimeUTC   time.Time `json:"order_time_utc" db:"order_time_utc"`
-	OrderTimeLocal time.Time `json:"order_time_local" db:"order_time_local"`
-	LocalDate      string    `json:"local_date" db:"local_date"`
-	LocalHour      int       `json:"local_hour" db:"local_hour"`
-	LocalDayOfWeek int       `json:"local_day_of_week" db:"local_day_of_week"`
-	LocalWeekday   string    `json:"local_weekday" db:"local_weekday"`
-	IsWeekend      bool      `json:"is_weekend" db:"is_weekend"`
-	IsBusinessHour bool      `json:"is_business_hour" db:"is_business_hour"`
+	OrderTimeUTC   time.Time `json:"order_time_utc" xml:"order_time_utc" db:"order_time_utc"`
+	OrderTimeLocal time.Time `json:"order_time_local" xml:"order_time_local" db:"order_time_local"`
+	LocalDate      string    `json:"local_date" xml:"local_date" db:"local_date"`
+	LocalHour      int       `json:"local_hour" xml:"local_hour" db:"local_hour"`
+	LocalDayOfWeek int       `json:"local_day_of_week" xml:"local_day_of_week" db:"local_day_of_week"`
+	LocalWeekday   string    `json:"local_weekday" xml:"local_weekday" db:"local_weekday"`
+	IsWeekend      bool      `json:"is_weekend" xml:"is_weekend" db:"is_weekend"`
+	IsBusinessHour bool      `json:"is_business_hour" xml:"is_business_hour" db:"is_business_hour"`
+	// WeekendDays 判断 IsWeekend 所用的商户周末（0=周日 … 6=周六）
+	WeekendDays    []int     `json:"weekend_days" xml:"weekend_days>item" db:"weekend_days"`
+
+	// 按请求语言渲染的展示字段，原始值见 LocalDayOfWeek、LocalDate、Amount
+	LocalWeekdayName string `json:"local_weekday_name,omitempty" xml:"local_weekday_name,omitempty"`
+	LocalDateDisplay string `json:"local_date_display,omitempty" xml:"local_date_display,omitempty"`
+	AmountDisplay    string `json:"amount_display,omitempty" xml:"amount_display,omitempty"`
+	// OrderTimeHumanized 请求 humanize=true 时下单时刻相对当前时间的描述，如"3 小时前"，今天、昨天按商户本地日期判断
+	OrderTimeHumanized string `json:"order_time_humanized,omitempty" xml:"order_time_humanized,omitempty"`
 
 	// 时区偏移信息
-	TimezoneOffset int `json:"timezone_offset" db:"timezone_offset"`
+	TimezoneOffset int `json:"timezone_offset" xml:"timezone_offset" db:"timezone_offset"`
+
+	// 入库时间（UTC），晚于所属本地日期结账时间的为迟到订单
+	IngestedAt time.Time `json:"ingested_at" xml:"ingested_at" db:"ingested_at"`
+
+	// Links 相关资源的相对 URL，只在订单接口的响应中设置
+	Links *OrderLinks `json:"links,omitempty" xml:"links,omitempty"`
+}
+
+// OrderLinks 订单相关资源的相对 URL，日期均为订单的本地日期
+type OrderLinks struct {
+	Self     string `json:"self" xml:"self"`
+	Merchant string `json:"merchant" xml:"merchant"`
+	// MerchantOrders 同一商户同一本地日期的订单
+	MerchantOrders string `json:"merchant_orders" xml:"merchant_orders"`
+	// Analysis 订单本地日期的分析数据
+	Analysis   string `json:"analysis" xml:"analysis"`
+	Refunds    string `json:"refunds" xml:"refunds"`
+	Attributes string `json:"attributes" xml:"attributes"`
+	// TimezoneCompare 下单时刻在商户时区的对比
+	TimezoneCompare string `json:"timezone_compare" xml:"timezone_compare"`
 }
 
 // TimezoneDemo 时区演示数据
 type TimezoneDemo struct {
-	UTCTime     string                   `json:"utc_time"`
-	Description string                   `json:"description"`
-	Timezones   []TimezoneConversion     `json:"timezones"`
-	Summary     TimezoneDemoSummary      `json:"summary"`
+	UTCTime     string                   `json:"utc_time" xml:"utc_time"`
+	// TZDataVersion 换算使用的 tzdata 版本，与 utc_time 一起决定结果和 ETag
+	TZDataVersion string `json:"tzdata_version" xml:"tzdata_version"`
+	Description string                   `json:"description" xml:"description"`
+	Timezones   []TimezoneConversion     `json:"timezones" xml:"timezones>item"`
+	Summary     TimezoneDemoSummary      `json:"summary" xml:"summary"`
 }
 
 // TimezoneConversion 时区转换信息
 type TimezoneConversion struct {
-	Timezone    string `json:"timezone"`
-	LocalTime   string `json:"local_time"`
-	LocalDate   string `json:"local_date"`
-	Offset      string `json:"offset"`
-	Country     string `json:"country"`
-	City        string `json:"city"`
-	IsNextDay   bool   `json:"is_next_day"`
-	IsPrevDay   bool   `json:"is_prev_day"`
+	Timezone    string `json:"timezone" xml:"timezone"`
+	LocalTime   string `json:"local_time" xml:"local_time"`
+	LocalDate   string `json:"local_date" xml:"local_date"`
+	Offset      string `json:"offset" xml:"offset"`
+	Country     string `json:"country" xml:"country"`
+	City        string `json:"city" xml:"city"`
+	IsNextDay   bool   `json:"is_next_day" xml:"is_next_day"`
+	IsPrevDay   bool   `json:"is_prev_day" xml:"is_prev_day"`
 }
 
 // TimezoneDemoSummary 时区演示汇总
 type TimezoneDemoSummary struct {
-	TotalTimezones int `json:"total_timezones"`
-	NextDayCount   int `json:"next_day_count"`
-	SameDayCount   int `json:"same_day_count"`
-	PrevDayCount   int `json:"prev_day_count"`
-	MinOffset      int `json:"min_offset_hours"`
-	MaxOffset      int `json:"max_offset_hours"`
+	TotalTimezones int `json:"total_timezones" xml:"total_timezones"`
+	NextDayCount   int `json:"next_day_count" xml:"next_day_count"`
+	SameDayCount   int `json:"same_day_count" xml:"same_day_count"`
+	PrevDayCount   int `json:"prev_day_count" xml:"prev_day_count"`
+	MinOffset      int `json:"min_offset_hours" xml:"min_offset_hours"`
+	MaxOffset      int `json:"max_offset_hours" xml:"max_offset_hours"`
 }
 
 // TimezoneComparison 时区对比分析
 type TimezoneComparison struct {
-	UTCTime       string                    `json:"utc_time"`
-	Comparisons   []TimezoneComparisonItem  `json:"comparisons"`
-	Statistics    TimezoneStatistics        `json:"statistics"`
+	UTCTime       string                    `json:"utc_time" xml:"utc_time"`
+	Comparisons   []TimezoneComparisonItem  `json:"comparisons" xml:"comparisons>item"`
+	Statistics    TimezoneStatistics        `json:"statistics" xml:"statistics"`
 }
 
 // TimezoneComparisonItem 时区对比项
 type TimezoneComparisonItem struct {
-	MerchantName   string `json:"merchant_name"`
-	Timezone       string `json:"timezone"`
-	LocalTime      string `json:"local_time"`
-	LocalDate      string `json:"local_date"`
-	Hour           int    `json:"hour"`
-	DayOfWeek      string `json:"day_of_week"`
-	IsWeekend      bool   `json:"is_weekend"`
-	IsBusinessHour bool   `json:"is_business_hour"`
-	TimeDifference string `json:"time_difference"`
+	MerchantName   string `json:"merchant_name,omitempty" xml:"merchant_name,omitempty"`
+	Timezone       string `json:"timezone" xml:"timezone"`
+	LocalTime      string `json:"local_time" xml:"local_time"`
+	LocalDate      string `json:"local_date" xml:"local_date"`
+	Hour           int    `json:"hour" xml:"hour"`
+	DayOfWeek      string `json:"day_of_week" xml:"day_of_week"`
+	// Weekday 星期序号，0=周日；DayOfWeekName 为按请求语言渲染的名称
+	Weekday        int    `json:"weekday" xml:"weekday"`
+	DayOfWeekName  string `json:"day_of_week_name,omitempty" xml:"day_of_week_name,omitempty"`
+	IsWeekend      bool   `json:"is_weekend" xml:"is_weekend"`
+	// WeekendDays 该时区适用的周末：商户的配置，或时区所在国家的默认值
+	WeekendDays    []int  `json:"weekend_days" xml:"weekend_days>item"`
+	IsBusinessHour bool   `json:"is_business_hour" xml:"is_business_hour"`
+	TimeDifference string `json:"time_difference" xml:"time_difference"`
+	Offset         string `json:"offset" xml:"offset"`
+	OffsetSeconds  int    `json:"offset_seconds" xml:"offset_seconds"`
+	Abbreviation   string `json:"abbreviation" xml:"abbreviation"`
+	IsDST          bool   `json:"is_dst" xml:"is_dst"`
 }
 
 // TimezoneStatistics 时区统计信息
 type TimezoneStatistics struct {
-	BusinessHourCount int     `json:"business_hour_count"`
-	WeekendCount      int     `json:"weekend_count"`
-	AverageHour       float64 `json:"average_hour"`
-	TimezoneSpread    int     `json:"timezone_spread_hours"`
+	BusinessHourCount int     `json:"business_hour_count" xml:"business_hour_count"`
+	WeekendCount      int     `json:"weekend_count" xml:"weekend_count"`
+	AverageHour       float64 `json:"average_hour" xml:"average_hour"`
+	TimezoneSpread    int     `json:"timezone_spread_hours" xml:"timezone_spread_hours"`
 }
 
 // AnalysisData 分析数据
 type AnalysisData struct {
-	Date            string                 `json:"date"`
-	TotalOrders     int                    `json:"total_orders"`
-	TotalAmount     float64                `json:"total_amount"`
-	HourlyBreakdown []HourlyOrderBreakdown `json:"hourly_breakdown"`
-	TimezoneStats   []TimezoneOrderStats   `json:"timezone_stats"`
-	TopMerchants    []MerchantOrderStats   `json:"top_merchants"`
+	Date            string                 `json:"date" xml:"date"`
+	Source          string                 `json:"source,omitempty" xml:"source,omitempty"`
+	// Partial 为 true 时 OmittedSections 中的部分（如 top_merchants）查询超时，未包含在结果中
+	Partial         bool                   `json:"partial" xml:"partial"`
+	OmittedSections []string               `json:"omitted_sections,omitempty" xml:"omitted_sections>item,omitempty"`
+	// QueryMode 实际使用的查询方式：fanout 各部分并发查询，single 一条语句返回全部聚合，batch 多个日期由一条语句返回
+	QueryMode       string                 `json:"query_mode,omitempty" xml:"query_mode,omitempty"`
+	// TimeBasis 日期和小时的划分依据：merchant_local 按各商户本地时间，aggregate_timezone 统一按 AggregateTimezone，
+	// 此时 Date 为该时区的日期，WindowStartUTC、WindowEndUTC 为对应的 UTC 区间
+	TimeBasis         string     `json:"time_basis" xml:"time_basis"`
+	AggregateTimezone string     `json:"aggregate_timezone,omitempty" xml:"aggregate_timezone,omitempty"`
+	WindowStartUTC    *time.Time `json:"window_start_utc,omitempty" xml:"window_start_utc,omitempty"`
+	WindowEndUTC      *time.Time `json:"window_end_utc,omitempty" xml:"window_end_utc,omitempty"`
+	TotalOrders     int                    `json:"total_orders" xml:"total_orders"`
+	// Statuses 参与统计的订单状态，Revenue 为营收口径
+	Statuses []string          `json:"statuses" xml:"statuses>item"`
+	Revenue  RevenueDefinition `json:"revenue_definition" xml:"revenue_definition"`
+	// 不同币种的金额不能直接相加：TotalsByCurrency 按币种分组合计，
+	// TotalAmount/GrossAmount/NetAmount 只在请求指定目标币种 Currency 时返回，为该币种订单的合计（不做汇率换算）
+	Currency         string           `json:"currency,omitempty" xml:"currency,omitempty"`
+	TotalAmount      *decimal.Decimal `json:"total_amount,omitempty" xml:"total_amount,omitempty"`
+	GrossAmount      *decimal.Decimal `json:"gross_amount,omitempty" xml:"gross_amount,omitempty"`
+	NetAmount        *decimal.Decimal `json:"net_amount,omitempty" xml:"net_amount,omitempty"`
+	TotalsByCurrency []CurrencyTotal  `json:"totals_by_currency" xml:"totals_by_currency>item"`
+	// 按请求语言渲染的展示字段
+	Locale             string `json:"locale,omitempty" xml:"locale,omitempty"`
+	DateDisplay        string `json:"date_display,omitempty" xml:"date_display,omitempty"`
+	TotalAmountDisplay string `json:"total_amount_display,omitempty" xml:"total_amount_display,omitempty"`
+	HourlyBreakdown []HourlyOrderBreakdown `json:"hourly_breakdown" xml:"hourly_breakdown>item"`
+	TimezoneStats   []TimezoneOrderStats   `json:"timezone_stats" xml:"timezone_stats>item"`
+	TopMerchants    []MerchantOrderStats   `json:"top_merchants" xml:"top_merchants>item"`
+	// GroupBy、Groups 请求 group_by=tag:<键> 时按租户自定义属性的取值分组的合计，按取值和币种排序
+	GroupBy string                `json:"group_by,omitempty" xml:"group_by,omitempty"`
+	Groups  []AttributeGroupTotal `json:"groups,omitempty" xml:"groups>item,omitempty"`
+}
+
+// CurrencyTotal 单一币种的订单数和金额合计
+// GrossAmount 为参与统计订单的金额合计；退款同时按两种口径统计：RefundByOrderDate 为原订单本地日期在当天的退款，
+// RefundByRefundDate 为退款本地日期在当天的退款。RefundAmount 为营收口径选定的退款归属，NetAmount = GrossAmount - RefundAmount；
+// TotalAmount 为按营收口径计算的营收：扣除退款时等于 NetAmount，否则等于 GrossAmount
+type CurrencyTotal struct {
+	Currency           string          `json:"currency" xml:"currency"`
+	OrderCount         int             `json:"order_count" xml:"order_count"`
+	TotalAmount        decimal.Decimal `json:"total_amount" xml:"total_amount"`
+	GrossAmount        decimal.Decimal `json:"gross_amount" xml:"gross_amount"`
+	RefundAmount       decimal.Decimal `json:"refund_amount" xml:"refund_amount"`
+	RefundByOrderDate  decimal.Decimal `json:"refund_by_order_date" xml:"refund_by_order_date"`
+	RefundByRefundDate decimal.Decimal `json:"refund_by_refund_date" xml:"refund_by_refund_date"`
+	NetAmount          decimal.Decimal `json:"net_amount" xml:"net_amount"`
+	// TotalAmountDisplay 按请求语言渲染的金额
+	TotalAmountDisplay string `json:"total_amount_display,omitempty" xml:"total_amount_display,omitempty"`
 }
 
 // HourlyOrderBreakdown 按小时订单分解
 type HourlyOrderBreakdown struct {
-	Hour        int     `json:"hour"`
-	OrderCount  int     `json:"order_count"`
-	TotalAmount float64 `json:"total_amount"`
-	AvgAmount   float64 `json:"avg_amount"`
+	Hour        int     `json:"hour" xml:"hour"`
+	OrderCount  int     `json:"order_count" xml:"order_count"`
+	// Currency 分组内订单币种一致时为该币种，混合币种时为空，平均金额按其小数位舍入
+	Currency    string  `json:"currency,omitempty" xml:"currency,omitempty"`
+	TotalAmount decimal.Decimal `json:"total_amount" xml:"total_amount"`
+	AvgAmount   decimal.Decimal `json:"avg_amount" xml:"avg_amount"`
 }
 
 // TimezoneOrderStats 时区订单统计
 type TimezoneOrderStats struct {
-	Timezone    string  `json:"timezone"`
-	Country     string  `json:"country"`
-	OrderCount  int     `json:"order_count"`
-	TotalAmount float64 `json:"total_amount"`
-	AvgAmount   float64 `json:"avg_amount"`
+	Timezone    string  `json:"timezone" xml:"timezone"`
+	Country     string  `json:"country" xml:"country"`
+	OrderCount  int     `json:"order_count" xml:"order_count"`
+	Currency    string  `json:"currency,omitempty" xml:"currency,omitempty"`
+	TotalAmount decimal.Decimal `json:"total_amount" xml:"total_amount"`
+	AvgAmount   decimal.Decimal `json:"avg_amount" xml:"avg_amount"`
 }
 
 // MerchantOrderStats 商户订单统计
 type MerchantOrderStats struct {
-	MerchantID   int     `json:"merchant_id"`
-	MerchantName string  `json:"merchant_name"`
-	Timezone     string  `json:"timezone"`
-	OrderCount   int     `json:"order_count"`
-	TotalAmount  float64 `json:"total_amount"`
-	AvgAmount    float64 `json:"avg_amount"`
+	MerchantID   int     `json:"merchant_id" xml:"merchant_id"`
+	MerchantName string  `json:"merchant_name" xml:"merchant_name"`
+	Timezone     string  `json:"timezone" xml:"timezone"`
+	OrderCount   int     `json:"order_count" xml:"order_count"`
+	Currency     string  `json:"currency,omitempty" xml:"currency,omitempty"`
+	TotalAmount  decimal.Decimal `json:"total_amount" xml:"total_amount"`
+	AvgAmount    decimal.Decimal `json:"avg_amount" xml:"avg_amount"`
+}
+
+// AnalysisAggregates 一次查询得到的全部分析聚合，各部分与单项查询的结果一致
+type AnalysisAggregates struct {
+	Summary      []CurrencyTotal
+	Hourly       []HourlyOrderBreakdown
+	Timezones    []TimezoneOrderStats
+	TopMerchants []MerchantOrderStats
 }
 
 // NullTime 可空时间类型
@@ -263,4 +388,2481 @@ func NewNullTimeFromPtr(t *time.Time) NullTime {
 		Time:  *t,
 		Valid: true,
 	}
-}
\ No newline at end of file
+}
+// MeetingOverlap 多商户营业时间重叠分析
+type MeetingOverlap struct {
+	Date          string               `json:"date"`
+	Timezone      string               `json:"timezone"`
+	WindowMinutes int                  `json:"window_minutes"`
+	RangeStartUTC time.Time            `json:"range_start_utc"`
+	RangeEndUTC   time.Time            `json:"range_end_utc"`
+	Participants  []OverlapParticipant `json:"participants"`
+	Overlaps      []TimeInterval       `json:"overlaps"`
+	Candidates    []MeetingSlot        `json:"candidates"`
+}
+
+// OverlapParticipant 参与重叠分析的商户
+type OverlapParticipant struct {
+	MerchantID    int    `json:"merchant_id"`
+	MerchantName  string `json:"merchant_name"`
+	Timezone      string `json:"timezone"`
+	BusinessHours string `json:"business_hours"`
+}
+
+// TimeInterval UTC 时间区间
+type TimeInterval struct {
+	StartUTC        time.Time `json:"start_utc"`
+	EndUTC          time.Time `json:"end_utc"`
+	DurationMinutes int       `json:"duration_minutes"`
+}
+
+// MeetingSlot 候选会议时段
+type MeetingSlot struct {
+	Rank           int             `json:"rank"`
+	StartUTC       time.Time       `json:"start_utc"`
+	EndUTC         time.Time       `json:"end_utc"`
+	AvailableCount int             `json:"available_count"`
+	AllAvailable   bool            `json:"all_available"`
+	Score          float64         `json:"score"`
+	LocalTimes     []SlotLocalTime `json:"local_times"`
+}
+
+// SlotLocalTime 候选时段在某个商户时区的本地时间
+type SlotLocalTime struct {
+	MerchantID int    `json:"merchant_id"`
+	Timezone   string `json:"timezone"`
+	LocalStart string `json:"local_start"`
+	LocalEnd   string `json:"local_end"`
+	Available  bool   `json:"available"`
+}
+
+// BusinessHoursCoverage 全部商户在一个 UTC 日内的营业时间覆盖分析
+type BusinessHoursCoverage struct {
+	Date          string         `json:"date"`
+	RangeStartUTC time.Time      `json:"range_start_utc"`
+	RangeEndUTC   time.Time      `json:"range_end_utc"`
+	MerchantCount int            `json:"merchant_count"`
+	Hours         []CoverageHour `json:"hours"`
+	// GapHours 整个小时都没有商户营业的 UTC 小时，Gaps 为精确到分钟的空档区间
+	GapHours   []int          `json:"gap_hours"`
+	Gaps       []TimeInterval `json:"gaps"`
+	GapMinutes int            `json:"gap_minutes"`
+	// PeakHours 营业商户数最多的 UTC 小时
+	PeakHours         []int `json:"peak_hours"`
+	PeakOpenMerchants int   `json:"peak_open_merchants"`
+	// Candidates 新增一个商户（按默认营业时间）最能填补空档的时区，按填补的分钟数排序
+	Candidates []CoverageCandidate `json:"candidates"`
+}
+
+// CoverageHour 一个 UTC 小时的营业覆盖
+type CoverageHour struct {
+	HourUTC int `json:"hour_utc"`
+	// OpenMerchants 该小时内营业过的商户数，CoveredMinutes 至少有一个商户营业的分钟数
+	OpenMerchants  int `json:"open_merchants"`
+	CoveredMinutes int `json:"covered_minutes"`
+}
+
+// CoverageCandidate 填补营业空档的候选时区
+type CoverageCandidate struct {
+	Rank     int    `json:"rank"`
+	Timezone string `json:"timezone"`
+	// EquivalentTimezones 当天营业区间与 Timezone 相同的其他时区
+	EquivalentTimezones []string       `json:"equivalent_timezones"`
+	Cities              []string       `json:"cities"`
+	BusinessHours       string         `json:"business_hours"`
+	Open                []TimeInterval `json:"open"`
+	FilledMinutes       int            `json:"filled_minutes"`
+	FilledHours         []int          `json:"filled_hours"`
+	RemainingGapMinutes int            `json:"remaining_gap_minutes"`
+}
+
+// LoadShedStatus 过载保护的状态：当前级别、最近一次采样的指标和各优先级被拒绝的请求数
+type LoadShedStatus struct {
+	// Level ok、shedding_low（拒绝低优先级请求）或 shedding_normal（除受保护接口外全部拒绝）
+	Level string `json:"level"`
+	// Since 进入当前级别的时间
+	Since              time.Time  `json:"since"`
+	SampledAt          *time.Time `json:"sampled_at,omitempty"`
+	PoolWaitsPerSecond float64    `json:"pool_waits_per_second"`
+	QueueDepth         int        `json:"queue_depth"`
+	// Transitions 级别变化的次数，Shed 为启动以来各优先级（low、normal）被拒绝的请求数
+	Transitions int64              `json:"transitions"`
+	Shed        map[string]int64   `json:"shed"`
+	Policy      LoadShedPolicyInfo `json:"policy"`
+}
+
+// LoadShedPolicyInfo 过载保护策略，阈值为 0 的指标不检查
+type LoadShedPolicyInfo struct {
+	PoolWaitsPerSecond float64  `json:"pool_waits_per_second"`
+	QueueDepth         int      `json:"queue_depth"`
+	CriticalFactor     float64  `json:"critical_factor"`
+	Cooldown           string   `json:"cooldown"`
+	LowPriority        []string `json:"low_priority"`
+	Protected          []string `json:"protected"`
+}
+
+// UsageProfile 本实例按路由模板统计的 API 用量，用于导出压测场景
+type UsageProfile struct {
+	// Since 开始统计的时间（服务启动或上次重置）
+	Since         time.Time    `json:"since"`
+	GeneratedAt   time.Time    `json:"generated_at"`
+	TotalRequests int64        `json:"total_requests"`
+	Routes        []RouteUsage `json:"routes"`
+}
+
+// RouteUsage 一个路由的用量，Weight 为其请求数占全部请求的比例
+type RouteUsage struct {
+	Method   string  `json:"method"`
+	Path     string  `json:"path"`
+	Requests int64   `json:"requests"`
+	Weight   float64 `json:"weight"`
+	// Tenants 各租户（X-Tenant-ID）的请求数
+	Tenants  []UsageValue `json:"tenants"`
+	PathVars []ParamUsage `json:"path_vars"`
+	Query    []ParamUsage `json:"query"`
+}
+
+// ParamUsage 一个参数的出现比例和取值分布
+type ParamUsage struct {
+	Name     string  `json:"name"`
+	Requests int64   `json:"requests"`
+	Presence float64 `json:"presence"`
+	// Values 单独统计的取值，按次数倒序；OtherCount 超出统计上限或过长的取值次数
+	Values     []UsageValue `json:"values"`
+	OtherCount int64        `json:"other_count"`
+	// Redacted 参数按脱敏策略不保留取值，压测场景中不会带上该参数
+	Redacted bool `json:"redacted"`
+}
+
+// UsageValue 一个取值及其次数
+type UsageValue struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// MerchantNow 商户当前的本地时间、偏移和营业状态
+type MerchantNow struct {
+	MerchantID   int       `json:"merchant_id"`
+	MerchantName string    `json:"merchant_name"`
+	Timezone     string    `json:"timezone"`
+	NowUTC       time.Time `json:"now_utc"`
+	Local        string    `json:"local"`
+	LocalDate    string    `json:"local_date"`
+	// Weekday 本地星期序号，0=周日
+	Weekday       int    `json:"weekday"`
+	Offset        string `json:"offset"`
+	OffsetSeconds int    `json:"offset_seconds"`
+	Abbreviation  string `json:"abbreviation"`
+	IsDST         bool   `json:"is_dst"`
+	BusinessHours string `json:"business_hours"`
+	IsOpen        bool   `json:"is_open"`
+	// NextOpen、NextClose 下一次营业开始和结束，SecondsUntil 为距现在的秒数
+	NextOpen     *Boundary `json:"next_open"`
+	NextClose    *Boundary `json:"next_close"`
+	NextMidnight Boundary  `json:"next_midnight"`
+	// NextOffsetChange 8 天内下一次偏移切换（夏令时开始或结束），没有时为 nil
+	NextOffsetChange *Boundary `json:"next_offset_change"`
+	// ValidUntil 营业状态、本地日期或偏移下一次变化的时刻（最长一小时），MaxAgeSeconds 为距现在的秒数，与 Cache-Control 的 max-age 相同
+	ValidUntil    time.Time `json:"valid_until"`
+	MaxAgeSeconds int       `json:"max_age_seconds"`
+}
+
+// MerchantBoundaries 商户下一个本地时间边界（午夜、营业开始/结束、周/月切换）
+type MerchantBoundaries struct {
+	MerchantID     int       `json:"merchant_id"`
+	MerchantName   string    `json:"merchant_name"`
+	Timezone       string    `json:"timezone"`
+	BusinessHours  string    `json:"business_hours"`
+	AtUTC          time.Time `json:"at_utc"`
+	AtLocal        string    `json:"at_local"`
+	IsOpen         bool      `json:"is_open"`
+	NextMidnight   Boundary  `json:"next_midnight"`
+	NextOpen       *Boundary `json:"next_open"`
+	NextClose      *Boundary `json:"next_close"`
+	NextWeekStart  Boundary  `json:"next_week_start"`
+	NextMonthStart Boundary  `json:"next_month_start"`
+}
+
+// Boundary 一个本地时间边界对应的 UTC 时刻
+type Boundary struct {
+	UTC          time.Time `json:"utc"`
+	Local        string    `json:"local"`
+	Offset       string    `json:"offset"`
+	SecondsUntil int64     `json:"seconds_until"`
+}
+
+// ScheduleExpansion 重复规则展开结果
+type ScheduleExpansion struct {
+	Rule        string               `json:"rule"`
+	MerchantID  int                  `json:"merchant_id,omitempty"`
+	Timezone    string               `json:"timezone"`
+	From        string               `json:"from"`
+	To          string               `json:"to"`
+	GapPolicy   string               `json:"gap_policy"`
+	Overlap     string               `json:"overlap_policy"`
+	Count       int                  `json:"count"`
+	Truncated   bool                 `json:"truncated"`
+	Occurrences []ScheduleOccurrence `json:"occurrences"`
+}
+
+// SchedulePreview 定时规则的校验结果和接下来的发生时刻
+type SchedulePreview struct {
+	// Expression 规范化的规则，可直接用于报表定义和定时计划的 schedule
+	Expression string `json:"expression"`
+	// Kind cron 或 rrule
+	Kind       string `json:"kind"`
+	MerchantID int    `json:"merchant_id,omitempty"`
+	Timezone   string `json:"timezone"`
+	// TimezoneSource 时区的来源：cron_tz（表达式的 CRON_TZ）、merchant（商户时区）、request（timezone 参数）或 default（UTC）
+	TimezoneSource string               `json:"timezone_source"`
+	After          time.Time            `json:"after"`
+	Count          int                  `json:"count"`
+	Occurrences    []ScheduleOccurrence `json:"occurrences"`
+}
+// ScheduleOccurrence 重复规则的一次发生
+type ScheduleOccurrence struct {
+	UTC       time.Time `json:"utc"`
+	Local     string    `json:"local"`
+	Wall      string    `json:"wall"`
+	Offset    string    `json:"offset"`
+	Shifted   bool      `json:"shifted"`
+	Ambiguous bool      `json:"ambiguous"`
+	// Humanized 请求 humanize=true 时相对当前时间的描述，如"明天 09:00"，按规则时区的本地日期判断
+	Humanized string `json:"humanized,omitempty"`
+}
+
+// Subscription 商户订阅配置
+type Subscription struct {
+	MerchantID    int       `json:"merchant_id" db:"merchant_id"`
+	PlanCode      string    `json:"plan_code" db:"plan_code"`
+	IntervalUnit  string    `json:"interval_unit" db:"interval_unit"`
+	IntervalCount int       `json:"interval_count" db:"interval_count"`
+	AnchorDate    string    `json:"anchor_date" db:"anchor_date"`
+	Status        string    `json:"status" db:"status"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// BillingPeriod 计费周期（左闭右开）
+type BillingPeriod struct {
+	PeriodID   int64     `json:"period_id" db:"period_id"`
+	MerchantID int       `json:"merchant_id" db:"merchant_id"`
+	Sequence   int       `json:"sequence" db:"sequence"`
+	Timezone   string    `json:"timezone" db:"timezone"`
+	StartLocal string    `json:"start_local" db:"period_start_local"`
+	EndLocal   string    `json:"end_local" db:"period_end_local"`
+	StartUTC   time.Time `json:"start_utc" db:"period_start_utc"`
+	EndUTC     time.Time `json:"end_utc" db:"period_end_utc"`
+	// DurationHours 周期实际时长，跨夏令时切换时不是 24 的整数倍
+	DurationHours float64 `json:"duration_hours"`
+}
+
+// BillingPeriods 商户计费周期列表
+type BillingPeriods struct {
+	MerchantID   int             `json:"merchant_id"`
+	Timezone     string          `json:"timezone"`
+	Subscription Subscription    `json:"subscription"`
+	Periods      []BillingPeriod `json:"periods"`
+}
+
+// DailyRevenueSnapshot 日结快照，Adjusted* 为快照加上调整记录后的数值
+type DailyRevenueSnapshot struct {
+	SnapshotID         int64     `json:"snapshot_id" db:"snapshot_id"`
+	MerchantID         int       `json:"merchant_id" db:"merchant_id"`
+	MerchantName       string    `json:"merchant_name,omitempty" db:"merchant_name"`
+	LocalDate          string    `json:"local_date" db:"local_date"`
+	Timezone           string    `json:"timezone" db:"timezone"`
+	WindowStartUTC     time.Time `json:"window_start_utc" db:"window_start_utc"`
+	WindowEndUTC       time.Time `json:"window_end_utc" db:"window_end_utc"`
+	OrderCount         int       `json:"order_count" db:"order_count"`
+	TotalAmount        decimal.Decimal `json:"total_amount" db:"total_amount"`
+	ClosedAt           time.Time `json:"closed_at" db:"closed_at"`
+	AdjustmentCount    int       `json:"adjustment_count"`
+	AdjustedOrderCount int       `json:"adjusted_order_count"`
+	AdjustedAmount     decimal.Decimal `json:"adjusted_amount"`
+}
+
+// ClosedAnalysis 基于日结快照的分析数据
+type ClosedAnalysis struct {
+	Date            string                 `json:"date"`
+	ClosedMerchants int                    `json:"closed_merchants"`
+	TotalOrders     int                    `json:"total_orders"`
+	TotalAmount     decimal.Decimal        `json:"total_amount"`
+	Snapshots       []DailyRevenueSnapshot `json:"snapshots"`
+	Pending         []PendingClose         `json:"pending"`
+}
+
+// PendingClose 指定日期尚未结账的商户
+type PendingClose struct {
+	MerchantID   int       `json:"merchant_id"`
+	MerchantName string    `json:"merchant_name"`
+	Timezone     string    `json:"timezone"`
+	ClosesAtUTC  time.Time `json:"closes_at_utc"`
+}
+
+// DailyRevenueAdjustment 日结调整记录（只追加，不修改快照）
+type DailyRevenueAdjustment struct {
+	AdjustmentID    int64     `json:"adjustment_id" db:"adjustment_id"`
+	SnapshotID      int64     `json:"snapshot_id" db:"snapshot_id"`
+	MerchantID      int       `json:"merchant_id" db:"merchant_id"`
+	LocalDate       string    `json:"local_date" db:"local_date"`
+	OrderCountDelta int       `json:"order_count_delta" db:"order_count_delta"`
+	AmountDelta     decimal.Decimal `json:"amount_delta" db:"amount_delta"`
+	Reason          string    `json:"reason" db:"reason"`
+	// OrderID 迟到订单调整关联的订单，人工调整时为空
+	OrderID   *int      `json:"order_id,omitempty" db:"order_id"`
+	CreatedBy string    `json:"created_by" db:"created_by"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// LateOrder 所属本地日期已结账后才入库的订单
+type LateOrder struct {
+	OrderID      int       `json:"order_id"`
+	OrderNumber  string    `json:"order_number"`
+	MerchantID   int       `json:"merchant_id"`
+	MerchantName string    `json:"merchant_name"`
+	Timezone     string    `json:"timezone"`
+	Amount       decimal.Decimal `json:"amount"`
+	OrderTimeUTC time.Time `json:"order_time_utc"`
+	IngestedAt   time.Time `json:"ingested_at"`
+	LocalDate    string    `json:"local_date"`
+	SnapshotID   int64     `json:"snapshot_id"`
+	ClosedAt     time.Time `json:"closed_at"`
+	// DelaySeconds 入库时间与下单时间的间隔
+	DelaySeconds int64  `json:"delay_seconds"`
+	AdjustmentID *int64 `json:"adjustment_id,omitempty"`
+}
+
+// 订单状态，与 dws_orders 的 chk_order_status 约束一致
+const (
+	OrderStatusPending   = "pending"
+	OrderStatusPaid      = "paid"
+	OrderStatusShipped   = "shipped"
+	OrderStatusDelivered = "delivered"
+	OrderStatusCancelled = "cancelled"
+	OrderStatusRefunded  = "refunded"
+)
+
+// OrderStatuses 所有订单状态
+var OrderStatuses = []string{
+	OrderStatusPending, OrderStatusPaid, OrderStatusShipped,
+	OrderStatusDelivered, OrderStatusCancelled, OrderStatusRefunded,
+}
+
+// OrderFilter 订单查询条件，零值表示不过滤
+type OrderFilter struct {
+	Timezone string
+	// Statuses 只返回这些状态的订单
+	Statuses []string
+	Limit    int
+	Offset   int
+	// Formatting local_date、local_weekday 的格式化方式，见 OrderFormattingGo 等，为空时按 go
+	Formatting string
+	// Attributes 按租户 Tenant 的自定义属性过滤：JSON 对象，订单的有效取值（商户取值叠加订单取值）须包含其中全部键值，为空时不过滤
+	Tenant     string
+	Attributes json.RawMessage
+	// OrderID 只返回该订单，为 0 时不过滤
+	OrderID int
+	// MerchantIDs 只返回这些商户的订单，为空时不过滤
+	MerchantIDs []int
+	// From、To 订单本地日期（各商户时区）的范围 YYYY-MM-DD，包含两端，为空时不过滤
+	From string
+	To   string
+}
+
+// 订单本地日期和星期名称的格式化方式
+const (
+	// OrderFormattingGo 查询 DATE 类型后在 Go 中逐行格式化（默认）
+	OrderFormattingGo = "go"
+	// OrderFormattingSQL 在查询中用 TO_CHAR 格式化为文本，直接扫描为字符串
+	OrderFormattingSQL = "sql"
+	// OrderFormattingRaw 不查询 local_date、local_weekday，两者为空，由调用方从 order_time_local、local_day_of_week 推导
+	OrderFormattingRaw = "raw"
+)
+
+// OrderFormattings 所有订单格式化方式
+var OrderFormattings = []string{OrderFormattingGo, OrderFormattingSQL, OrderFormattingRaw}
+
+// AnalysisFilter 分析查询条件
+type AnalysisFilter struct {
+	LocalDate string
+	// Statuses 参与统计的订单状态，为空时统计全部状态
+	Statuses []string
+	// AggregateTimezone 不为空时 LocalDate 和小时按该时区根据 order_time_utc 划分，而不是各商户的本地时区；
+	// Start、End 为该时区 LocalDate 当天对应的 UTC 区间 [Start, End)
+	AggregateTimezone string
+	Start             time.Time
+	End               time.Time
+}
+
+// 分析数据日期和小时的划分依据
+const (
+	// TimeBasisMerchantLocal 各商户的本地日期和小时
+	TimeBasisMerchantLocal = "merchant_local"
+	// TimeBasisAggregateTimezone 统一按请求指定的汇总时区
+	TimeBasisAggregateTimezone = "aggregate_timezone"
+)
+
+// RevenueDefinition 营收口径
+type RevenueDefinition struct {
+	// ExcludedStatuses 未指定状态过滤时不参与统计的订单状态，如 cancelled
+	ExcludedStatuses []string `json:"excluded_statuses" xml:"excluded_statuses>item"`
+	// SubtractRefunds 营收（total_amount）是否扣除退款金额
+	SubtractRefunds bool `json:"subtract_refunds" xml:"subtract_refunds"`
+	// RefundAttribution 退款归属的本地日期：order_date 归属原订单日期，refund_date 归属退款发生日期
+	RefundAttribution string `json:"refund_attribution" xml:"refund_attribution"`
+}
+
+// 退款归属口径
+const (
+	RefundAttributionOrderDate  = "order_date"
+	RefundAttributionRefundDate = "refund_date"
+)
+
+// Refund 订单退款记录，一笔订单可以多次部分退款
+type Refund struct {
+	RefundID      int64           `json:"refund_id" db:"refund_id"`
+	OrderID       int             `json:"order_id" db:"order_id"`
+	MerchantID    int             `json:"merchant_id" db:"merchant_id"`
+	Amount        decimal.Decimal `json:"amount" db:"refund_amount"`
+	Currency      string          `json:"currency" db:"currency"`
+	Reason        string          `json:"reason" db:"reason"`
+	RefundTimeUTC time.Time       `json:"refund_time_utc" db:"refund_time_utc"`
+	// OrderLocalDate / RefundLocalDate 在商户时区下原订单和退款的本地日期
+	OrderLocalDate  string    `json:"order_local_date"`
+	RefundLocalDate string    `json:"refund_local_date"`
+	CreatedBy       string    `json:"created_by" db:"created_by"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+}
+
+// RefundTotal 单一币种在指定本地日期的退款合计，按两种归属口径分别统计
+type RefundTotal struct {
+	Currency     string          `json:"currency"`
+	ByOrderDate  decimal.Decimal `json:"by_order_date"`
+	ByRefundDate decimal.Decimal `json:"by_refund_date"`
+}
+
+// OrderRefunds 订单及其退款记录
+type OrderRefunds struct {
+	OrderID         int             `json:"order_id"`
+	MerchantID      int             `json:"merchant_id"`
+	Timezone        string          `json:"timezone"`
+	OrderTimeUTC    time.Time       `json:"order_time_utc"`
+	Currency        string          `json:"currency"`
+	Status          string          `json:"status"`
+	OrderAmount     decimal.Decimal `json:"order_amount"`
+	RefundedAmount  decimal.Decimal `json:"refunded_amount"`
+	RemainingAmount decimal.Decimal `json:"remaining_amount"`
+	Refunds         []Refund        `json:"refunds"`
+}
+
+// LateOrderFilter 迟到订单查询条件，零值表示不过滤
+type LateOrderFilter struct {
+	LocalDate  string
+	MerchantID int
+	// UnadjustedOnly 只返回尚未生成调整记录的订单
+	UnadjustedOnly bool
+}
+
+// ReconciliationReport 迟到订单对账报告
+type ReconciliationReport struct {
+	Date             string      `json:"date,omitempty"`
+	MerchantID       int         `json:"merchant_id,omitempty"`
+	TotalLate        int         `json:"total_late"`
+	Unadjusted       int         `json:"unadjusted"`
+	UnadjustedAmount decimal.Decimal `json:"unadjusted_amount"`
+	LateOrders       []LateOrder `json:"late_orders"`
+}
+
+// AdjustmentResult 迟到订单调整结果
+type AdjustmentResult struct {
+	Created     int                      `json:"created"`
+	Adjustments []DailyRevenueAdjustment `json:"adjustments"`
+}
+
+// TimezoneSuggestion 根据国家、城市或地址推断出的时区
+type TimezoneSuggestion struct {
+	Timezone   string  `json:"timezone"`
+	Country    string  `json:"country"`
+	City       string  `json:"city"`
+	MatchedBy  string  `json:"matched_by"`
+	Confidence float64 `json:"confidence"`
+}
+
+// ReportSettings 商户报表配置，发送时间为商户本地时间
+type ReportSettings struct {
+	MerchantID          int    `json:"merchant_id" db:"merchant_id"`
+	DailyReportEnabled  bool   `json:"daily_report_enabled" db:"daily_report_enabled"`
+	DailyReportTime     string `json:"daily_report_time" db:"daily_report_time"`
+	WeeklyReportEnabled bool   `json:"weekly_report_enabled" db:"weekly_report_enabled"`
+	// WeekStartDay ISO 星期，1=周一 ... 7=周日
+	WeekStartDay int `json:"week_start_day" db:"week_start_day"`
+}
+
+// WebhookSetting 商户 Webhook 订阅
+type WebhookSetting struct {
+	WebhookID  int    `json:"webhook_id" db:"webhook_id"`
+	MerchantID int    `json:"merchant_id" db:"merchant_id"`
+	EventType  string `json:"event_type" db:"event_type"`
+	URL        string `json:"url" db:"url"`
+	Enabled    bool   `json:"enabled" db:"enabled"`
+}
+
+// MerchantOnboarding 入驻时在同一事务中写入的商户及默认配置
+type MerchantOnboarding struct {
+	Merchant       Merchant         `json:"merchant"`
+	MerchantCode   string           `json:"merchant_code"`
+	ReportSettings ReportSettings   `json:"report_settings"`
+	Webhooks       []WebhookSetting `json:"webhooks"`
+}
+
+// OnboardingResult 入驻结果，DryRun 时只返回推断和校验结果，不创建商户
+type OnboardingResult struct {
+	DryRun bool `json:"dry_run"`
+	// TimezoneSource 时区来源：input 为请求指定，inferred 为根据国家/城市/地址推断
+	TimezoneSource string               `json:"timezone_source"`
+	Suggestions    []TimezoneSuggestion `json:"suggestions"`
+	MerchantOnboarding
+	// ContactEmail 接收欢迎邮件的邮箱，WelcomeEmail 为发送结果 sent、failed 或 skipped
+	ContactEmail string `json:"contact_email,omitempty"`
+	WelcomeEmail string `json:"welcome_email,omitempty"`
+}
+
+// 租户开通的隔离方式，与 tenant_provisioning 表的约束一致
+const (
+	TenantIsolationShared = "shared"
+	TenantIsolationRLS    = "rls"
+)
+
+// TenantAPIKey 租户的 API 密钥，Secret 为完整密钥，只在开通的响应中返回一次，库中只保存摘要
+type TenantAPIKey struct {
+	KeyID     int       `json:"key_id"`
+	Prefix    string    `json:"prefix"`
+	Label     string    `json:"label"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TenantProvisioning 开通租户时在同一事务中写入的商户、默认配置、API 密钥、隔离策略和示例订单
+type TenantProvisioning struct {
+	MerchantOnboarding
+	// Settings 开通时写入的配置项（日历类配置保存在商户的营业时间和周末列，不在其中）
+	Settings []TenantSetting `json:"settings"`
+	APIKey   TenantAPIKey    `json:"api_key"`
+	// Isolation 隔离方式 shared 或 rls，DBRole 为 rls 时租户专属的数据库角色
+	Isolation     string    `json:"isolation"`
+	DBRole        string    `json:"db_role,omitempty"`
+	// Lifecycle 开通后的生命周期 trial 或 active，TrialEndsOn 为试用的最后一天（商户本地日期）
+	Lifecycle   string `json:"lifecycle"`
+	TrialEndsOn string `json:"trial_ends_on,omitempty"`
+	SampleOrder   Order     `json:"sample_order"`
+	ProvisionedBy string    `json:"provisioned_by"`
+	ProvisionedAt time.Time `json:"provisioned_at"`
+}
+
+// 租户生命周期，与 dim_merchant.lifecycle 的约束一致
+const (
+	TenantLifecycleTrial     = "trial"
+	TenantLifecycleActive    = "active"
+	TenantLifecycleSuspended = "suspended"
+	TenantLifecycleArchived  = "archived"
+)
+
+// TenantLifecycles 所有生命周期状态
+var TenantLifecycles = []string{
+	TenantLifecycleTrial, TenantLifecycleActive, TenantLifecycleSuspended, TenantLifecycleArchived,
+}
+
+// TenantLifecycle 租户当前的生命周期状态
+type TenantLifecycle struct {
+	MerchantID int    `json:"merchant_id"`
+	Timezone   string `json:"timezone"`
+	State      string `json:"state"`
+	// TrialEndsOn 试用的最后一天（商户本地日期 YYYY-MM-DD），只对 trial 有意义
+	TrialEndsOn string     `json:"trial_ends_on,omitempty"`
+	ChangedAt   *time.Time `json:"changed_at,omitempty"`
+	// Events 最近的变更记录，按时间倒序
+	Events []TenantLifecycleEvent `json:"events,omitempty"`
+}
+
+// TenantLifecycleEvent 一次生命周期变更的审计记录
+type TenantLifecycleEvent struct {
+	EventID     int64     `json:"event_id"`
+	MerchantID  int       `json:"merchant_id"`
+	From        string    `json:"from"`
+	To          string    `json:"to"`
+	TrialEndsOn string    `json:"trial_ends_on,omitempty"`
+	Reason      string    `json:"reason"`
+	ChangedBy   string    `json:"changed_by"`
+	ChangedAt   time.Time `json:"changed_at"`
+}
+
+// ReferenceCountry 国家参考数据（dim_country）
+type ReferenceCountry struct {
+	Code            string `json:"code"`
+	Name            string `json:"name"`
+	NameZH          string `json:"name_zh"`
+	DefaultTimezone string `json:"default_timezone"`
+	DefaultCurrency string `json:"default_currency"`
+	// WeekendDays 默认周末的星期序号（0=周日 … 6=周六）
+	WeekendDays []int `json:"weekend_days"`
+}
+
+// ReferenceCity 城市参考数据（dim_city）
+type ReferenceCity struct {
+	ID          int     `json:"id"`
+	CountryCode string  `json:"country_code"`
+	Name        string  `json:"name"`
+	NameZH      string  `json:"name_zh"`
+	Timezone    string  `json:"timezone"`
+	Lat         float64 `json:"lat"`
+	Lon         float64 `json:"lon"`
+}
+
+// MerchantRecord 商户目录中的一个商户，用于批量导出
+type MerchantRecord struct {
+	Merchant
+	Code   string `json:"code"`
+	Status string `json:"status"`
+}
+
+// MerchantImportRow 批量导入的一行商户数据
+// 时区为空时按国家和城市推断，营业时间缺省为 09:00-19:00；WeekendDays 为 nil 时按国家取默认值
+type MerchantImportRow struct {
+	Code               string `json:"code"`
+	Name               string `json:"name"`
+	CountryCode        string `json:"country_code"`
+	Country            string `json:"country"`
+	City               string `json:"city"`
+	Timezone           string `json:"timezone"`
+	BusinessHoursStart string `json:"business_hours_start"`
+	BusinessHoursEnd   string `json:"business_hours_end"`
+	WeekendDays        []int  `json:"weekend_days"`
+}
+
+// 批量导入中每一行的结果
+const (
+	MerchantImportValid     = "valid"
+	MerchantImportInvalid   = "invalid"
+	MerchantImportDuplicate = "duplicate"
+	MerchantImportCreated   = "created"
+)
+
+// MerchantImportResult 批量导入中一行的校验或创建结果
+type MerchantImportResult struct {
+	// Row 数据行号，从 1 开始，不含 CSV 表头
+	Row    int      `json:"row"`
+	Status string   `json:"status"`
+	Errors []string `json:"errors,omitempty"`
+	// DuplicateOf 与之重复的已有商户 ID，与同一文件中更早的行重复时为 0
+	DuplicateOf int `json:"duplicate_of,omitempty"`
+	// Merchant 校验后将要创建（或已创建）的商户，invalid 时为空
+	Merchant *MerchantRecord `json:"merchant,omitempty"`
+}
+
+// MerchantImportReport 批量导入报告，DryRun 时只校验不创建
+type MerchantImportReport struct {
+	DryRun     bool                   `json:"dry_run"`
+	Total      int                    `json:"total"`
+	Valid      int                    `json:"valid"`
+	Invalid    int                    `json:"invalid"`
+	Duplicates int                    `json:"duplicates"`
+	Created    int                    `json:"created"`
+	Rows       []MerchantImportResult `json:"rows"`
+}
+
+// TimezoneLookup 坐标所在时区
+type TimezoneLookup struct {
+	Lat      float64 `json:"lat"`
+	Lon      float64 `json:"lon"`
+	Timezone string  `json:"timezone"`
+	// Source 查询来源：nearest_city 为最近城市，nautical 为按经度推算的航海时区
+	Source      string  `json:"source"`
+	NearestCity string  `json:"nearest_city,omitempty"`
+	Country     string  `json:"country,omitempty"`
+	DistanceKm  float64 `json:"distance_km,omitempty"`
+	// 当前时刻在该时区的本地时间和偏移
+	LocalTime     string `json:"local_time"`
+	Offset        string `json:"offset"`
+	OffsetSeconds int    `json:"offset_seconds"`
+}
+
+// TenantQueryStats 单个租户的查询并发和排队统计（进程启动以来）
+type TenantQueryStats struct {
+	Tenant string `json:"tenant"`
+	// Limit 同时执行的查询上限，InFlight/Waiting 为当前执行中和排队中的数量
+	Limit    int `json:"limit"`
+	InFlight int `json:"in_flight"`
+	Waiting  int `json:"waiting"`
+	// Acquired 获得名额的次数，其中 Queued 次需要排队；Rejected 为排队超时被拒绝的次数
+	Acquired int64 `json:"acquired"`
+	Queued   int64 `json:"queued"`
+	Rejected int64 `json:"rejected"`
+	// 获得名额前的排队时间（毫秒）
+	AvgWaitMs float64 `json:"avg_wait_ms"`
+	MaxWaitMs float64 `json:"max_wait_ms"`
+}
+
+// IndexInfo 表上已有的索引
+type IndexInfo struct {
+	Table string `json:"table"`
+	Name  string `json:"name"`
+	// Columns 索引列，表达式列为 "(expression)"
+	Columns    []string `json:"columns"`
+	Definition string   `json:"definition"`
+	Unique     bool     `json:"unique"`
+	Primary    bool     `json:"primary"`
+	// Partial 带 WHERE 条件的部分索引，只能服务满足条件的查询
+	Partial   bool  `json:"partial"`
+	Scans     int64 `json:"scans"`
+	SizeBytes int64 `json:"size_bytes"`
+}
+
+// TableScanStats 表的扫描统计（pg_stat_user_tables，自统计重置以来）
+type TableScanStats struct {
+	Table       string `json:"table"`
+	LiveRows    int64  `json:"live_rows"`
+	SeqScans    int64  `json:"seq_scans"`
+	SeqRowsRead int64  `json:"seq_rows_read"`
+	IndexScans  int64  `json:"index_scans"`
+	SizeBytes   int64  `json:"size_bytes"`
+}
+
+// StatementStats pg_stat_statements 中的一条语句统计
+type StatementStats struct {
+	Query       string  `json:"query"`
+	Calls       int64   `json:"calls"`
+	TotalTimeMs float64 `json:"total_time_ms"`
+	MeanTimeMs  float64 `json:"mean_time_ms"`
+	Rows        int64   `json:"rows"`
+}
+
+// IndexAdvice 一条索引建议
+type IndexAdvice struct {
+	// Kind 建议类型：create 建议新建；redundant 被其他索引的前缀覆盖；unused 从未被使用；seq_scan 大表以顺序扫描为主
+	Kind    string   `json:"kind"`
+	Table   string   `json:"table"`
+	Columns []string `json:"columns,omitempty"`
+	Index   string   `json:"index,omitempty"`
+	Reason  string   `json:"reason"`
+	// SQL 建议执行的语句，新建索引使用 CONCURRENTLY 避免锁表
+	SQL string `json:"sql,omitempty"`
+}
+
+// IndexAdviceReport 订单和商户表的索引建议报告
+type IndexAdviceReport struct {
+	GeneratedAt time.Time        `json:"generated_at"`
+	Tables      []TableScanStats `json:"tables"`
+	Indexes     []IndexInfo      `json:"indexes"`
+	Advice      []IndexAdvice    `json:"advice"`
+	// StatementsAvailable 为 false 时 StatementsNote 说明 pg_stat_statements 不可用的原因
+	StatementsAvailable bool             `json:"statements_available"`
+	StatementsNote      string           `json:"statements_note,omitempty"`
+	Statements          []StatementStats `json:"statements"`
+}
+
+// ConversionItem 批量时区转换的一项
+// Timestamp 带偏移（RFC3339）时表示确定的时刻，不带偏移时为 FromTZ 的本地时间
+type ConversionItem struct {
+	Timestamp string `json:"timestamp"`
+	FromTZ    string `json:"from_tz"`
+	ToTZ      string `json:"to_tz"`
+}
+
+// ConversionSide 转换一侧的本地时间
+type ConversionSide struct {
+	Timezone      string `json:"timezone"`
+	LocalTime     string `json:"local_time"`
+	LocalDate     string `json:"local_date"`
+	Offset        string `json:"offset"`
+	OffsetSeconds int    `json:"offset_seconds"`
+	Abbreviation  string `json:"abbreviation"`
+	IsDST         bool   `json:"is_dst"`
+}
+
+// ConversionResult 一项转换结果，Error 不为空时其余结果字段无效
+type ConversionResult struct {
+	Index int `json:"index"`
+	ConversionItem
+	UTC  string          `json:"utc,omitempty"`
+	From *ConversionSide `json:"from,omitempty"`
+	To   *ConversionSide `json:"to,omitempty"`
+	// DayShift 目标本地日期减来源本地日期的天数，如 +1 表示目标时区已是第二天
+	DayShift int `json:"day_shift"`
+	// Shifted 来源本地时间因夏令时跳过而不存在，已按跳过的时长顺延
+	Shifted bool `json:"shifted,omitempty"`
+	// Ambiguous 来源本地时间因夏令时回拨出现两次，按 overlap 策略取其一
+	Ambiguous bool   `json:"ambiguous,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// BatchConversion 批量时区转换结果，Results 与请求顺序一致
+type BatchConversion struct {
+	Count   int                `json:"count"`
+	Failed  int                `json:"failed"`
+	Results []ConversionResult `json:"results"`
+}
+
+// TimezoneRules 时区在一段时间内的历史偏移切换
+type TimezoneRules struct {
+	Timezone string    `json:"timezone"`
+	From     string    `json:"from"`
+	To       string    `json:"to"`
+	TZData   tzdb.Info `json:"tzdata"`
+	// Current 当前生效的规则
+	Current     tzdb.Zone            `json:"current"`
+	Count       int                  `json:"count"`
+	Truncated   bool                 `json:"truncated"`
+	Transitions []TimezoneTransition `json:"transitions"`
+}
+
+// TimezoneTransition 一次偏移切换及其对本地时钟的影响
+type TimezoneTransition struct {
+	tzdb.Transition
+	// Kind 切换类型：dst_start 进入夏令时，dst_end 退出夏令时，offset_change 标准偏移调整，abbreviation_change 只有缩写变化
+	Kind string `json:"kind"`
+	// DeltaSeconds 本地时钟的变化，正数为拨快（该段本地时间不存在），负数为回拨（该段本地时间出现两次）
+	DeltaSeconds int `json:"delta_seconds"`
+	// LocalBefore 切换前一刻的本地时间，LocalAfter 切换时刻的本地时间
+	LocalBefore string `json:"local_before"`
+	LocalAfter  string `json:"local_after"`
+}
+
+// TenantSetting 商户（租户）的一项配置，Value 为该配置键对应类型的 JSON
+type TenantSetting struct {
+	MerchantID int             `json:"merchant_id"`
+	Key        string          `json:"key"`
+	Value      json.RawMessage `json:"value"`
+	// IsDefault 未单独设置，Value 为默认值
+	IsDefault bool       `json:"is_default"`
+	UpdatedBy string     `json:"updated_by,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// MerchantCalendar 商户的营业时间和周末，分析视图直接读取 dim_merchant 中的这些列
+type MerchantCalendar struct {
+	BusinessHoursStart string
+	BusinessHoursEnd   string
+	WeekendDays        []int
+}
+
+// SettingChange 配置变更事件，New 为变更后的生效值（重置时为默认值）
+type SettingChange struct {
+	MerchantID int             `json:"merchant_id"`
+	Key        string          `json:"key"`
+	Old        json.RawMessage `json:"old"`
+	New        json.RawMessage `json:"new"`
+	Reset      bool            `json:"reset"`
+	ChangedBy  string          `json:"changed_by,omitempty"`
+	ChangedAt  time.Time       `json:"changed_at"`
+}
+
+// TenantActivity 商户（租户）的订单量和最近入库时间
+type TenantActivity struct {
+	MerchantID   int    `json:"merchant_id"`
+	MerchantName string `json:"merchant_name"`
+	Timezone     string `json:"timezone"`
+	// OrderCount 全部订单数，RecentOrderCount 为统计窗口内入库的订单数
+	OrderCount       int64 `json:"order_count"`
+	RecentOrderCount int64 `json:"recent_order_count"`
+	// LastIngestedAt 最近一笔订单的入库时间，没有订单时为 nil
+	LastIngestedAt *time.Time `json:"last_ingested_at"`
+}
+
+// TenantRequestStats 单个租户（X-Tenant-ID）的 API 请求统计（进程启动以来）
+type TenantRequestStats struct {
+	Tenant   string `json:"tenant"`
+	Requests int64  `json:"requests"`
+	// ClientErrors/ServerErrors 响应状态为 4xx/5xx 的请求数
+	ClientErrors  int64      `json:"client_errors"`
+	ServerErrors  int64      `json:"server_errors"`
+	LastRequestAt *time.Time `json:"last_request_at"`
+}
+
+// SLABudget 延迟和错误率预算，ErrorRate 为 5xx 响应占比
+type SLABudget struct {
+	P95Ms     int64   `json:"p95_ms"`
+	P99Ms     int64   `json:"p99_ms"`
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// TenantSLA 租户在滚动窗口内的响应时间分位数和错误率
+// 分位数由固定分桶的直方图估计，精度为所在分桶的宽度
+type TenantSLA struct {
+	Tenant       string  `json:"tenant"`
+	Requests     int64   `json:"requests"`
+	ServerErrors int64   `json:"server_errors"`
+	ErrorRate    float64 `json:"error_rate"`
+	P50Ms        float64 `json:"p50_ms"`
+	P95Ms        float64 `json:"p95_ms"`
+	P99Ms        float64 `json:"p99_ms"`
+	// Status ok、breached 或 insufficient_data（请求数不足以评估），Breaches 为超出预算的指标
+	Status   string   `json:"status"`
+	Breaches []string `json:"breaches"`
+	// BreachedSince 本次超出预算的开始时间，只对 breached 有意义
+	BreachedSince *time.Time `json:"breached_since,omitempty"`
+}
+
+// SLAReport 各租户的 SLA 统计
+type SLAReport struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	Window      string    `json:"window"`
+	Budget      SLABudget `json:"budget"`
+	// MinRequests 窗口内请求数少于该值的租户不评估预算
+	MinRequests int64       `json:"min_requests"`
+	Tenants     []TenantSLA `json:"tenants"`
+}
+
+// PlanEpoch 语句耗时的统计周期：部署版本或表统计信息（ANALYZE）变化时开始新的周期
+type PlanEpoch struct {
+	Epoch         string `json:"epoch"`
+	DeployVersion string `json:"deploy_version"`
+	// StatsAnalyzedAt 各表最近一次 ANALYZE（含 autovacuum）的时间，从未 ANALYZE 时为 nil
+	StatsAnalyzedAt *time.Time `json:"stats_analyzed_at,omitempty"`
+	StartedAt       time.Time  `json:"started_at"`
+}
+
+// QueryPlanStats 一个语句指纹在一个统计周期内的耗时中位数和执行计划
+type QueryPlanStats struct {
+	PlanEpoch
+	Fingerprint string    `json:"fingerprint"`
+	Query       string    `json:"query"`
+	Samples     int64     `json:"samples"`
+	MedianMs    float64   `json:"median_ms"`
+	Plan        string    `json:"plan,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// PlanRegression 语句指纹在最近一个周期的耗时中位数比上一个周期变慢超过阈值
+type PlanRegression struct {
+	Fingerprint string  `json:"fingerprint"`
+	Query       string  `json:"query"`
+	Ratio       float64 `json:"ratio"`
+	// PlanChanged 两个周期的执行计划结构（忽略代价估计）是否不同
+	PlanChanged bool           `json:"plan_changed"`
+	Baseline    QueryPlanStats `json:"baseline"`
+	Current     QueryPlanStats `json:"current"`
+}
+
+// PlanRegressionReport 执行计划回归检测的结果
+type PlanRegressionReport struct {
+	Epoch *PlanEpoch `json:"epoch,omitempty"`
+	// Threshold 中位数变慢超过该比例（0.5 即 50%）记为回归；MinSamples 两个周期的样本数都不少于该值才比较
+	Threshold   float64          `json:"threshold"`
+	MinSamples  int64            `json:"min_samples"`
+	Regressions []PlanRegression `json:"regressions"`
+}
+
+// CanaryTenant 合成监控租户及其固定订单的预期合计，由 sql/35_canary.sql 写入
+type CanaryTenant struct {
+	MerchantID int    `json:"merchant_id"`
+	Timezone   string `json:"timezone"`
+	// Date 固定订单所在的商户本地日期
+	Date           string          `json:"date"`
+	Currency       string          `json:"currency"`
+	ExpectedOrders int             `json:"expected_orders"`
+	ExpectedAmount decimal.Decimal `json:"expected_amount"`
+}
+
+// CanaryStep 合成监控一次运行中的一个步骤
+type CanaryStep struct {
+	// Step merchants、orders 或 analysis
+	Step       string  `json:"step"`
+	OK         bool    `json:"ok"`
+	DurationMs float64 `json:"duration_ms"`
+	Expected   string  `json:"expected,omitempty"`
+	Actual     string  `json:"actual,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// CanaryRun 合成监控的一次运行：依次查询商户、订单和分析，任一步骤失败时后续步骤不再执行
+type CanaryRun struct {
+	// Status ok 或 failed
+	Status     string       `json:"status"`
+	MerchantID int          `json:"merchant_id,omitempty"`
+	Date       string       `json:"date,omitempty"`
+	StartedAt  time.Time    `json:"started_at"`
+	DurationMs float64      `json:"duration_ms"`
+	Steps      []CanaryStep `json:"steps"`
+	Error      string       `json:"error,omitempty"`
+}
+
+// CanaryStatus 本实例合成监控的累计结果和最近一次运行，Latest 为 nil 表示还没有运行过
+type CanaryStatus struct {
+	// Status ok、failed 或 unknown（还没有运行过）
+	Status              string     `json:"status"`
+	Interval            string     `json:"interval"`
+	Runs                int64      `json:"runs"`
+	Failures            int64      `json:"failures"`
+	ConsecutiveFailures int64      `json:"consecutive_failures"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+	Latest              *CanaryRun `json:"latest,omitempty"`
+}
+
+// TenantOverview 管理看板中单个租户的汇总，请求统计按 X-Tenant-ID 等于商户ID归属
+type TenantOverview struct {
+	TenantActivity
+	Requests      int64      `json:"requests"`
+	ClientErrors  int64      `json:"client_errors"`
+	ServerErrors  int64      `json:"server_errors"`
+	// ErrorRate 5xx 请求占比
+	ErrorRate     float64    `json:"error_rate"`
+	LastRequestAt *time.Time `json:"last_request_at"`
+	// Rejected 分析查询排队超时被拒绝的次数
+	Rejected int64 `json:"rejected"`
+	// IngestionLagSeconds 距最近一笔订单入库的秒数，没有订单时为 nil
+	IngestionLagSeconds *int64 `json:"ingestion_lag_seconds"`
+	// Flags 健康标记：no_orders、stale、throttled、high_error_rate，为空时 Healthy 为 true
+	Flags   []string `json:"flags"`
+	Healthy bool     `json:"healthy"`
+}
+
+// TimezoneShare 时区分布中的一项
+type TimezoneShare struct {
+	Timezone     string `json:"timezone"`
+	Tenants      int    `json:"tenants"`
+	Orders       int64  `json:"orders"`
+	RecentOrders int64  `json:"recent_orders"`
+}
+
+// OverviewTotals 管理看板的全局合计
+type OverviewTotals struct {
+	Tenants      int   `json:"tenants"`
+	Orders       int64 `json:"orders"`
+	RecentOrders int64 `json:"recent_orders"`
+	Requests     int64 `json:"requests"`
+	Stale        int   `json:"stale"`
+	Unhealthy    int   `json:"unhealthy"`
+}
+
+// AdminOverview 跨租户运营看板
+type AdminOverview struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	// Window 统计近期订单的窗口，StaleAfter 超过该时长没有新订单入库的租户标记为 stale
+	Window     string  `json:"window"`
+	StaleAfter string  `json:"stale_after"`
+	ErrorRate  float64 `json:"error_rate_threshold"`
+	Sort       string  `json:"sort"`
+	Order      string  `json:"order"`
+
+	Totals               OverviewTotals   `json:"totals"`
+	TimezoneDistribution []TimezoneShare  `json:"timezone_distribution"`
+	Tenants              []TenantOverview `json:"tenants"`
+	// OtherTenants X-Tenant-ID 不对应任何商户的请求统计（如 default）
+	OtherTenants []TenantRequestStats `json:"other_tenants"`
+}
+
+// PageMeta 列表接口的分页信息，Links 中的 next/prev 同时以 Link 响应头（RFC 5988）返回
+type PageMeta struct {
+	// TotalCount 符合条件的总数，TotalCountExact 为 false 时是按数据库统计信息估算的值
+	TotalCount      int64 `json:"total_count" xml:"total_count"`
+	TotalCountExact bool  `json:"total_count_exact" xml:"total_count_exact"`
+	// Limit 每页条数（0 表示不分页），Offset 本页起始位置，Count 本页实际条数
+	Limit  int `json:"limit" xml:"limit"`
+	Offset int `json:"offset" xml:"offset"`
+	Count  int `json:"count" xml:"count"`
+	// Page 当前页码（从 1 开始），PageCount 按 TotalCount 计算的页数
+	Page      int       `json:"page" xml:"page"`
+	PageCount int       `json:"page_count" xml:"page_count"`
+	HasMore   bool      `json:"has_more" xml:"has_more"`
+	Links     PageLinks `json:"links" xml:"links"`
+}
+
+// PageLinks 分页链接（相对 URL，保留原请求的其他查询参数）
+type PageLinks struct {
+	Self  string `json:"self" xml:"self"`
+	First string `json:"first" xml:"first"`
+	Prev  string `json:"prev,omitempty" xml:"prev,omitempty"`
+	Next  string `json:"next,omitempty" xml:"next,omitempty"`
+}
+
+// FaultRule 故障注入规则，命中 Route/Method/Tenant 的请求按 Percent 的比例注入故障
+type FaultRule struct {
+	ID int64 `json:"id"`
+	// Kind 故障类型：latency 增加延迟，error 返回 5xx，db_drop 断开请求使用的数据库连接
+	Kind string `json:"kind"`
+	// Percent 命中的请求中注入故障的比例，0~100
+	Percent float64 `json:"percent"`
+	// Route 路径前缀（如 /api/timezone/analysis），Method 和 Tenant（X-Tenant-ID）为空时匹配全部
+	Route  string `json:"route,omitempty"`
+	Method string `json:"method,omitempty"`
+	Tenant string `json:"tenant,omitempty"`
+	// Latency latency 规则的固定延迟，Jitter 在此基础上随机增加 0~Jitter
+	Latency string `json:"latency,omitempty"`
+	Jitter  string `json:"jitter,omitempty"`
+	// Status error 规则返回的状态码，为 0 时随机使用 500、502、503
+	Status    int        `json:"status,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Matched 命中路由条件的请求数，Injected 实际注入故障的次数
+	Matched  int64 `json:"matched"`
+	Injected int64 `json:"injected"`
+}
+
+// CaptureTenant 开启了请求录制的租户
+type CaptureTenant struct {
+	Tenant    string    `json:"tenant"`
+	EnabledAt time.Time `json:"enabled_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Captured 开启以来录制的请求数（含已被新记录挤出缓冲区的）
+	Captured int64 `json:"captured"`
+}
+
+// RequestCapture 录制的一次请求和响应
+// 认证头、令牌类参数和邮箱等敏感信息已替换为 [REDACTED]，请求体和响应体超过上限时截断
+type RequestCapture struct {
+	ID         int64     `json:"id"`
+	Tenant     string    `json:"tenant"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs float64   `json:"duration_ms"`
+
+	Method               string            `json:"method"`
+	Path                 string            `json:"path"`
+	// ClientIP、Scheme 经可信代理还原后的客户端地址和协议
+	ClientIP             string            `json:"client_ip,omitempty"`
+	Scheme               string            `json:"scheme,omitempty"`
+	Query                string            `json:"query,omitempty"`
+	RequestHeaders       map[string]string `json:"request_headers"`
+	RequestBody          string            `json:"request_body,omitempty"`
+	RequestBodyTruncated bool              `json:"request_body_truncated,omitempty"`
+
+	Status                int               `json:"status"`
+	ResponseHeaders       map[string]string `json:"response_headers"`
+	ResponseBody          string            `json:"response_body,omitempty"`
+	ResponseBodyTruncated bool              `json:"response_body_truncated,omitempty"`
+}
+
+// CaptureReplay 按录制的请求重新执行一次，并与录制时的响应比较
+type CaptureReplay struct {
+	Capture               RequestCapture `json:"capture"`
+	Status                int            `json:"status"`
+	ResponseBody          string         `json:"response_body,omitempty"`
+	ResponseBodyTruncated bool           `json:"response_body_truncated,omitempty"`
+	SameStatus            bool           `json:"same_status"`
+	SameBody              bool           `json:"same_body"`
+}
+
+// 一致性检查结果状态
+const (
+	ConsistencyStatusOK            = "ok"
+	ConsistencyStatusDiscrepancies = "discrepancies"
+	ConsistencyStatusFailed        = "failed"
+)
+
+// ConsistencyTotals 一个商户在 dws_orders 和分析视图中的订单数及金额合计
+type ConsistencyTotals struct {
+	MerchantID  int             `json:"merchant_id"`
+	OrderCount  int64           `json:"order_count"`
+	OrderAmount decimal.Decimal `json:"order_amount"`
+	ViewCount   int64           `json:"view_count"`
+	ViewAmount  decimal.Decimal `json:"view_amount"`
+}
+
+// ConsistencyDiscrepancy 一致性检查发现的一处差异
+// Kind 为 row_count、amount_sum、missing_merchant 或派生字段名（local_date、local_hour 等）；
+// Expected 为 dws_orders 的统计或 Go 重新计算的值，Actual 为分析视图中的值
+type ConsistencyDiscrepancy struct {
+	ID         int64  `json:"id"`
+	RunID      int64  `json:"run_id"`
+	Kind       string `json:"kind"`
+	MerchantID int    `json:"merchant_id"`
+	OrderID    *int   `json:"order_id,omitempty"`
+	Expected   string `json:"expected"`
+	Actual     string `json:"actual"`
+}
+
+// ConsistencyRun 一次一致性检查
+type ConsistencyRun struct {
+	RunID      int64     `json:"run_id"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	// Status ok / discrepancies / failed，failed 时 Error 为失败原因
+	Status           string `json:"status"`
+	Merchants        int    `json:"merchants"`
+	SampledOrders    int    `json:"sampled_orders"`
+	DiscrepancyCount int    `json:"discrepancy_count"`
+	Error            string `json:"error,omitempty"`
+	// Discrepancies 差异明细，列表接口不返回
+	Discrepancies []ConsistencyDiscrepancy `json:"discrepancies,omitempty"`
+}
+
+// RollupDiscrepancy 汇总表与分析视图在一个小时桶上的差异
+// Expected 为分析视图的统计，Actual 为 agg_orders_hourly 中的值
+type RollupDiscrepancy struct {
+	MerchantID     int             `json:"merchant_id"`
+	LocalDate      string          `json:"local_date"`
+	LocalHour      int             `json:"local_hour"`
+	Currency       string          `json:"currency"`
+	Status         string          `json:"status"`
+	ExpectedCount  int64           `json:"expected_count"`
+	ActualCount    int64           `json:"actual_count"`
+	ExpectedAmount decimal.Decimal `json:"expected_amount"`
+	ActualAmount   decimal.Decimal `json:"actual_amount"`
+}
+
+// RollupAudit 一次汇总表比对，From/To 为本地日期范围（含两端）
+type RollupAudit struct {
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	CheckedAt time.Time `json:"checked_at"`
+	// DiscrepancyCount 差异总数，Discrepancies 最多返回前 limit 条
+	DiscrepancyCount int                 `json:"discrepancy_count"`
+	Discrepancies    []RollupDiscrepancy `json:"discrepancies"`
+	Truncated        bool                `json:"truncated,omitempty"`
+}
+
+// RollupRebuild 一次汇总表重建，MerchantID 为 0 表示全部商户
+type RollupRebuild struct {
+	MerchantID int   `json:"merchant_id,omitempty"`
+	Rows       int64 `json:"rows"`
+	DurationMs int64 `json:"duration_ms"`
+}
+
+// RetentionPolicy 商户的订单保留策略
+// 保留最近 ArchiveAfterMonths 个本地自然月（含当月）的订单，更早的按月归档到 Target
+type RetentionPolicy struct {
+	MerchantID         int       `json:"merchant_id"`
+	ArchiveAfterMonths int       `json:"archive_after_months"`
+	Target             string    `json:"target"`
+	UpdatedBy          string    `json:"updated_by"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// RetentionBucket 一次归档中一个商户一个本地月份的归档结果
+type RetentionBucket struct {
+	MerchantID int             `json:"merchant_id"`
+	Month      string          `json:"month"`
+	Target     string          `json:"target"`
+	Orders     int64           `json:"orders"`
+	Amount     decimal.Decimal `json:"amount"`
+	// Location target 为 object 时为归档文件的位置
+	Location string `json:"location,omitempty"`
+}
+
+// RetentionRun 一次归档执行
+type RetentionRun struct {
+	RunID       int64      `json:"run_id"`
+	TriggeredBy string     `json:"triggered_by"`
+	Status      string     `json:"status"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	// Merchants 执行了归档的商户数，ArchivedOrders 归档的订单总数
+	Merchants      int    `json:"merchants"`
+	ArchivedOrders int64  `json:"archived_orders"`
+	Error          string `json:"error,omitempty"`
+	// Buckets 归档明细，列表接口不返回
+	Buckets []RetentionBucket `json:"buckets,omitempty"`
+}
+
+// RetentionOverview 全部保留策略和最近的归档记录
+type RetentionOverview struct {
+	Policies []RetentionPolicy `json:"policies"`
+	Runs     []RetentionRun    `json:"runs"`
+}
+
+// 租户数据的处理方式
+const (
+	// TenantErasureDelete 删除商户及其全部数据
+	TenantErasureDelete = "delete"
+	// TenantErasureAnonymize 保留订单金额和时间，清除可识别字段，删除配置和流水
+	TenantErasureAnonymize = "anonymize"
+)
+
+// TenantErasure 一次租户（商户）数据删除或匿名化及其核验报告
+type TenantErasure struct {
+	ID         int64  `json:"id,omitempty"`
+	MerchantID int    `json:"merchant_id"`
+	Mode       string `json:"mode"`
+	Status     string `json:"status"`
+	// DryRun 为 true 时只统计会处理的行数，没有修改数据，也不保存记录
+	DryRun      bool       `json:"dry_run,omitempty"`
+	RequestedBy string     `json:"requested_by"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	// Verified 处理后每张表都没有残留的可识别数据
+	Verified bool                `json:"verified"`
+	Steps    []TenantErasureStep `json:"steps"`
+	Error    string              `json:"error,omitempty"`
+}
+
+// TenantErasureStep 核验报告中的一张表（或一个存储）
+type TenantErasureStep struct {
+	Table string `json:"table"`
+	// Action delete 删除行，anonymize 清除可识别字段，keep 保留不处理
+	Action string `json:"action"`
+	// Rows 删除或匿名化的行数，预演时为将要处理的行数
+	Rows int64 `json:"rows"`
+	// Remaining 处理后仍属于该商户（anonymize 时为仍可识别）的行数，核验通过时为 0
+	Remaining int64 `json:"remaining"`
+}
+
+// 自定义属性的适用对象
+const (
+	AttributeEntityMerchant = "merchant"
+	AttributeEntityOrder    = "order"
+)
+
+// 自定义属性的取值类型
+const (
+	AttributeTypeString  = "string"
+	AttributeTypeNumber  = "number"
+	AttributeTypeBoolean = "boolean"
+	AttributeTypeDate    = "date"
+	AttributeTypeEnum    = "enum"
+)
+
+// AttributeDefinition 租户为商户或订单定义的自定义属性
+type AttributeDefinition struct {
+	Tenant string `json:"tenant"`
+	Entity string `json:"entity"`
+	Key    string `json:"key"`
+	Type   string `json:"type"`
+	// Values enum 类型的可选值
+	Values      []string  `json:"values,omitempty"`
+	Description string    `json:"description,omitempty"`
+	CreatedBy   string    `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// EntityAttributes 租户为一个商户或订单设置的属性取值
+type EntityAttributes struct {
+	Entity     string                     `json:"entity"`
+	ID         int                        `json:"id"`
+	MerchantID int                        `json:"merchant_id"`
+	Attributes map[string]json.RawMessage `json:"attributes"`
+	// Effective 订单的有效取值：商户的取值叠加订单的取值，用于过滤和分组
+	Effective map[string]json.RawMessage `json:"effective,omitempty"`
+	UpdatedBy string                     `json:"updated_by,omitempty"`
+	UpdatedAt *time.Time                 `json:"updated_at,omitempty"`
+}
+
+// AttributeGroupTotal 按自定义属性取值分组的单一币种订单数和金额
+type AttributeGroupTotal struct {
+	// Value 取值的文本形式，没有该属性的订单为 null
+	Value       *string         `json:"value" xml:"value"`
+	Currency    string          `json:"currency" xml:"currency"`
+	OrderCount  int             `json:"order_count" xml:"order_count"`
+	GrossAmount decimal.Decimal `json:"gross_amount" xml:"gross_amount"`
+}
+
+// SavedView 看板用户保存的命名筛选条件，订单列表和分析接口通过 view_id 引用
+type SavedView struct {
+	ID     int    `json:"id"`
+	Tenant string `json:"tenant"`
+	Owner  string `json:"owner"`
+	Name   string `json:"name"`
+	// Timezone 只看该时区商户的订单，为空时不过滤
+	Timezone    string   `json:"timezone,omitempty"`
+	MerchantIDs []int    `json:"merchant_ids"`
+	Statuses    []string `json:"statuses"`
+	// Range 相对日期范围（today、yesterday、last_7_days 等），按请求时的今天计算，为空时使用接口的默认日期
+	Range     string    `json:"range,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Job 后台任务，按队列由工作协程领取执行
+type Job struct {
+	ID    int64  `json:"id"`
+	Queue string `json:"queue"`
+	Kind  string `json:"kind"`
+	// Payload 任务参数，含义由任务类型决定
+	Payload json.RawMessage `json:"payload"`
+	Status  string          `json:"status"`
+	// Attempts 已开始执行的次数，达到 MaxAttempts 后不再重试
+	Attempts    int `json:"attempts"`
+	MaxAttempts int `json:"max_attempts"`
+	// RunAt 最早执行时刻：新任务为指定的延迟时刻，失败重试时为退避后的时刻
+	RunAt time.Time `json:"run_at"`
+	// DedupeKey 不为空时同一键只能有一个排队或执行中的任务
+	DedupeKey string `json:"dedupe_key,omitempty"`
+	// Schedule 由定时计划生成时为计划名称
+	Schedule string `json:"schedule,omitempty"`
+	// LockedBy、LockedAt 执行任务的实例及其最近一次心跳
+	LockedBy  string     `json:"locked_by,omitempty"`
+	LockedAt  *time.Time `json:"locked_at,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+	// Result 执行成功时任务返回的结果
+	Result     json.RawMessage `json:"result,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+// JobFilter 任务列表的筛选条件，为空的条件不过滤
+type JobFilter struct {
+	Queue  string
+	Kind   string
+	Status string
+	Limit  int
+}
+
+// JobSchedule 后台任务的定时计划，到期时生成一个任务
+type JobSchedule struct {
+	Name    string          `json:"name"`
+	Kind    string          `json:"kind"`
+	Payload json.RawMessage `json:"payload"`
+	// Schedule RRULE 重复规则，按 Timezone 的本地时间展开
+	Schedule string `json:"schedule"`
+	Timezone string `json:"timezone"`
+	Enabled  bool   `json:"enabled"`
+	// NextRunAt 下一次生成任务的时刻，停用或规则不再有下一次时为空
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// ConsoleQuery SQL 控制台的一次查询请求
+type ConsoleQuery struct {
+	SQL      string `json:"sql"`
+	Operator string `json:"operator"`
+	// MaxRows 最多返回的行数，为 0 或超过服务端上限时使用上限
+	MaxRows    int    `json:"max_rows,omitempty"`
+	ClientAddr string `json:"-"`
+}
+
+// ConsoleResult SQL 控制台的查询结果，Rows 中每行的值与 Columns 一一对应
+type ConsoleResult struct {
+	AuditID    int64           `json:"audit_id"`
+	Columns    []string        `json:"columns"`
+	Rows       [][]interface{} `json:"rows"`
+	RowCount   int             `json:"row_count"`
+	Truncated  bool            `json:"truncated"`
+	DurationMs int64           `json:"duration_ms"`
+}
+
+// ConsoleAudit SQL 控制台的一条审计记录
+type ConsoleAudit struct {
+	ID         int64     `json:"id"`
+	Operator   string    `json:"operator"`
+	SQL        string    `json:"sql"`
+	Status     string    `json:"status"`
+	RowCount   int       `json:"row_count"`
+	Truncated  bool      `json:"truncated"`
+	DurationMs int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+	ClientAddr string    `json:"client_addr,omitempty"`
+	ExecutedAt time.Time `json:"executed_at"`
+}
+
+// ClockReference 一个参照时钟的测量结果
+// SkewMs 为参照时钟减去本机时钟的偏差，正数表示本机时钟偏慢
+type ClockReference struct {
+	Name   string `json:"name"`
+	SkewMs int64  `json:"skew_ms"`
+	// RTTMs 请求往返耗时，偏差的测量误差不超过它的一半
+	RTTMs int64  `json:"rtt_ms"`
+	Error string `json:"error,omitempty"`
+}
+
+// ClockStatus 一次时钟偏差检查的结果
+type ClockStatus struct {
+	// Status ok、warning（有参照时钟的偏差超过阈值）或 unknown（没有可用的参照时钟）
+	Status      string           `json:"status"`
+	CheckedAt   time.Time        `json:"checked_at"`
+	ThresholdMs int64            `json:"threshold_ms"`
+	MaxSkewMs   int64            `json:"max_skew_ms"`
+	References  []ClockReference `json:"references"`
+}
+
+// Alert 发送到告警 Webhook 的消息
+type Alert struct {
+	Source   string      `json:"source"`
+	Severity string      `json:"severity"`
+	Title    string      `json:"title"`
+	Text     string      `json:"text"`
+	Details  interface{} `json:"details,omitempty"`
+	At       time.Time   `json:"at"`
+}
+
+// 回填任务状态
+const (
+	BackfillRunning   = "running"
+	BackfillCompleted = "completed"
+	BackfillFailed    = "failed"
+)
+
+// BackfillJob 本地时间字段回填任务，按 (merchant_id, order_id) 分批重新镜像到 ClickHouse
+type BackfillJob struct {
+	ID int64 `json:"id"`
+	// MerchantIDs 需要回填的商户，为空表示全部商户
+	MerchantIDs []int  `json:"merchant_ids"`
+	Reason      string `json:"reason,omitempty"`
+	// Status running / completed / failed；running 的任务在进程退出后同样可以继续执行
+	Status    string `json:"status"`
+	BatchSize int    `json:"batch_size"`
+	// TotalOrders 创建任务时统计的订单数，ProcessedOrders 已回填的订单数
+	TotalOrders     int64   `json:"total_orders"`
+	ProcessedOrders int64   `json:"processed_orders"`
+	Progress        float64 `json:"progress"`
+	// CursorMerchantID、CursorOrderID 最后一批完成的位置，继续执行时从其后开始
+	CursorMerchantID int        `json:"cursor_merchant_id"`
+	CursorOrderID    int        `json:"cursor_order_id"`
+	Error            string     `json:"error,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	FinishedAt       *time.Time `json:"finished_at,omitempty"`
+}
+
+// Organization 组织（连锁品牌、集团），拥有多个不同时区的商户门店
+type Organization struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// HQTimezone 总部时区，按总部口径统计时用于划分日期和小时
+	HQTimezone  string    `json:"hq_timezone"`
+	MerchantIDs []int     `json:"merchant_ids"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	// Locations 门店详情，只在查询单个组织时返回
+	Locations []Merchant `json:"locations,omitempty"`
+}
+
+// OrganizationToken 组织令牌，只能访问所属组织的接口
+type OrganizationToken struct {
+	ID             int    `json:"id"`
+	OrganizationID int    `json:"organization_id"`
+	Name           string `json:"name"`
+	// Token 令牌明文，只在创建时返回一次，库中只保存摘要
+	Token      string     `json:"token,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// 组织分析的统计口径
+const (
+	// OrganizationModeLocal 门店口径：每个门店按自身时区的本地日期和小时统计
+	OrganizationModeLocal = "local"
+	// OrganizationModeHQ 总部口径：所有门店按总部时区的日期和小时统计
+	OrganizationModeHQ = "hq"
+)
+
+// OrganizationAnalysisFilter 组织分析的查询条件
+// HQTimezone 为空时按门店口径统计本地日期为 LocalDate 的订单；
+// 不为空时按总部口径统计下单时间在 [Start, End) 内的订单，小时按 HQTimezone 计算
+type OrganizationAnalysisFilter struct {
+	OrganizationID int
+	LocalDate      string
+	HQTimezone     string
+	Start          time.Time
+	End            time.Time
+	Statuses       []string
+}
+
+// OrganizationOrderTotal 组织分析的聚合行：一个门店在一个小时内一种币种的订单数和金额
+type OrganizationOrderTotal struct {
+	MerchantID int
+	Hour       int
+	Currency   string
+	OrderCount int
+	Amount     decimal.Decimal
+}
+
+// OrganizationCurrencyTotal 单一币种的订单数和金额合计，组织分析不扣除退款
+type OrganizationCurrencyTotal struct {
+	Currency    string          `json:"currency"`
+	OrderCount  int             `json:"order_count"`
+	TotalAmount decimal.Decimal `json:"total_amount"`
+}
+
+// OrganizationLocationStats 组织分析中一个门店的统计
+type OrganizationLocationStats struct {
+	MerchantID   int    `json:"merchant_id"`
+	MerchantName string `json:"merchant_name"`
+	Timezone     string `json:"timezone"`
+	Country      string `json:"country"`
+	City         string `json:"city"`
+	// WindowStartUTC、WindowEndUTC 该门店统计的 UTC 区间：门店口径为门店本地的当天，总部口径为总部的当天
+	WindowStartUTC   time.Time                   `json:"window_start_utc"`
+	WindowEndUTC     time.Time                   `json:"window_end_utc"`
+	OrderCount       int                         `json:"order_count"`
+	TotalsByCurrency []OrganizationCurrencyTotal `json:"totals_by_currency"`
+}
+
+// OrganizationAnalysis 组织分析：汇总全部门店，按门店口径或总部口径划分日期和小时
+type OrganizationAnalysis struct {
+	OrganizationID int    `json:"organization_id"`
+	Name           string `json:"name"`
+	Date           string `json:"date"`
+	// Mode local 为门店口径，hq 为总部口径
+	Mode string `json:"mode"`
+	// ReportTimezone 划分日期和小时所用的时区，门店口径下为空（各门店使用自身时区）
+	ReportTimezone string   `json:"report_timezone,omitempty"`
+	Statuses       []string `json:"statuses"`
+	TotalOrders    int      `json:"total_orders"`
+	// 不同币种的金额不能直接相加，按币种分组合计
+	TotalsByCurrency []OrganizationCurrencyTotal `json:"totals_by_currency"`
+	HourlyBreakdown  []HourlyOrderBreakdown      `json:"hourly_breakdown"`
+	Locations        []OrganizationLocationStats `json:"locations"`
+}
+
+// ReportDefinition 保存的报表定义：分析参数、日期范围类型、时区口径、输出格式和可选的定时规则
+type ReportDefinition struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// MerchantID 时区口径为 merchant 时按该商户的时区计算日期范围和执行时间
+	MerchantID   int    `json:"merchant_id,omitempty"`
+	TimezoneMode string `json:"timezone_mode"`
+	// Timezone 计算日期范围和执行时间使用的时区，由 MerchantID 和 TimezoneMode 决定
+	Timezone  string `json:"timezone"`
+	RangeType string `json:"range_type"`
+	// From、To range_type 为 custom 时的本地日期 YYYY-MM-DD，包含两端
+	From     string   `json:"from,omitempty"`
+	To       string   `json:"to,omitempty"`
+	Statuses []string `json:"statuses"`
+	Currency string   `json:"currency,omitempty"`
+	Format   string   `json:"format"`
+	// Schedule 类 RRULE 的重复规则，按 Timezone 的本地时间执行；为空时只能手动执行
+	Schedule  string     `json:"schedule,omitempty"`
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+	// Emails 执行完成后通过 report_ready 邮件模板通知的邮箱
+	Emails []string `json:"emails"`
+	CreatedBy string     `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// ReportRun 报表的一次执行
+type ReportRun struct {
+	RunID        int64  `json:"run_id"`
+	DefinitionID int    `json:"definition_id"`
+	TriggeredBy  string `json:"triggered_by"`
+	Status       string `json:"status"`
+	// From、To 本次统计的本地日期范围，Timezone 为计算范围使用的时区
+	From       string     `json:"from"`
+	To         string     `json:"to"`
+	Timezone   string     `json:"timezone"`
+	Format     string     `json:"format"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// ArtifactSize 结果文件的字节数，ArtifactURL 为下载地址，执行失败时为空
+	ArtifactSize int64  `json:"artifact_size"`
+	ArtifactURL  string `json:"artifact_url,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// ReportArtifact 报表 JSON 结果文件的内容
+type ReportArtifact struct {
+	Definition  ReportDefinition `json:"definition"`
+	From        string           `json:"from"`
+	To          string           `json:"to"`
+	Timezone    string           `json:"timezone"`
+	GeneratedAt time.Time        `json:"generated_at"`
+	// Days 每个本地日期的分析结果，与 /api/timezone/analysis 的响应一致
+	Days []AnalysisData `json:"days"`
+}
+
+// AlertRule 租户为商户定义的指标告警规则，检查时间按商户时区和营业时间
+type AlertRule struct {
+	ID         int    `json:"id"`
+	Tenant     string `json:"tenant"`
+	Name       string `json:"name"`
+	MerchantID int    `json:"merchant_id"`
+	// Type no_orders 或 revenue_drop
+	Type string `json:"type"`
+	// WindowHours no_orders：连续多少个本地营业小时没有订单时告警
+	WindowHours int `json:"window_hours,omitempty"`
+	// ThresholdPercent revenue_drop：当天截至当前的营收低于上周同一天同一时刻的百分之多少时告警
+	ThresholdPercent int `json:"threshold_percent,omitempty"`
+	// Currency revenue_drop 只比较该币种，为空时逐个币种比较
+	Currency   string   `json:"currency,omitempty"`
+	Statuses   []string `json:"statuses"`
+	WebhookURL string   `json:"webhook_url,omitempty"`
+	Emails     []string `json:"emails"`
+	Enabled    bool     `json:"enabled"`
+	// State 最近一次检查的结果 ok 或 firing，只在变化时发送通知
+	State           string     `json:"state"`
+	LastEvaluatedAt *time.Time `json:"last_evaluated_at,omitempty"`
+	CreatedBy       string     `json:"created_by"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// AlertEvent 告警历史中的一条记录：规则开始告警（firing）或恢复（resolved）
+type AlertEvent struct {
+	EventID    int64  `json:"event_id"`
+	RuleID     int    `json:"rule_id"`
+	Tenant     string `json:"tenant"`
+	MerchantID int    `json:"merchant_id"`
+	State      string `json:"state"`
+	Title      string `json:"title"`
+	Text       string `json:"text"`
+	// Timezone、LocalTime 检查时刻在商户时区的本地时间
+	Timezone   string          `json:"timezone"`
+	LocalTime  string          `json:"local_time"`
+	Details    json.RawMessage `json:"details,omitempty"`
+	Deliveries []AlertDelivery `json:"deliveries"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
+
+// AlertDelivery 告警通过一个渠道发送的结果
+type AlertDelivery struct {
+	// Channel webhook 或 email
+	Channel string `json:"channel"`
+	Target  string `json:"target"`
+	// Status sent、failed 或 skipped（未配置发送方式）
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// AlertEvaluation 一次检查告警规则的结果
+type AlertEvaluation struct {
+	RuleID int `json:"rule_id"`
+	// Evaluated 为 false 时条件不适用（如商户不在营业时间、上周同期没有营收），规则状态不变
+	Evaluated bool   `json:"evaluated"`
+	Reason    string `json:"reason,omitempty"`
+	State     string `json:"state"`
+	Text      string `json:"text,omitempty"`
+	// Event 状态发生变化时写入的告警历史
+	Event       *AlertEvent `json:"event,omitempty"`
+	EvaluatedAt time.Time   `json:"evaluated_at"`
+}
+
+// EmailTemplate 邮件模板，Source 为 tenant 时是租户覆盖的版本，builtin 为内置模板
+type EmailTemplate struct {
+	Tenant  string `json:"tenant"`
+	Name    string `json:"name"`
+	Locale  string `json:"locale"`
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text,omitempty"`
+	Source  string `json:"source"`
+	// UpdatedBy、UpdatedAt 只有租户覆盖的版本有值
+	UpdatedBy string     `json:"updated_by,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// DashboardFilter 首页概览的统计窗口，时间均为 UTC 时刻
+type DashboardFilter struct {
+	MerchantID int
+	// TodayStart、YesterdayStart 商户本地今天和昨天的零点
+	TodayStart     time.Time
+	YesterdayStart time.Time
+	// YesterdayAt 昨天与 Now 相同的本地时刻，昨天同期统计 [YesterdayStart, YesterdayAt)
+	YesterdayAt time.Time
+	// HourlyStart 小时点中第一个本地整点，小时点统计 [HourlyStart, Now)
+	HourlyStart time.Time
+	Now         time.Time
+	// Currency 为空时统计全部币种；Statuses 为空时统计全部状态
+	Currency string
+	Statuses []string
+}
+
+// DashboardHourlyTotal 一个小时点一个币种的订单数和金额
+type DashboardHourlyTotal struct {
+	HourStart  time.Time
+	Currency   string
+	OrderCount int
+	Amount     decimal.Decimal
+}
+
+// DashboardAggregates 一次查询得到的首页概览聚合，汇总按币种排序，小时点按时间和币种排序
+type DashboardAggregates struct {
+	Today             []CurrencyTotal
+	YesterdaySameTime []CurrencyTotal
+	Yesterday         []CurrencyTotal
+	Hourly            []DashboardHourlyTotal
+}
+
+// DashboardSummary 首页概览：商户本地今天的订单、与昨天的对比、当前本地时间和营业状态，以及最近 24 个本地小时
+type DashboardSummary struct {
+	MerchantID   int    `json:"merchant_id"`
+	MerchantName string `json:"merchant_name"`
+	Timezone     string `json:"timezone"`
+	// GeneratedAt 统计截止的 UTC 时刻，LocalTime 为对应的商户本地时间
+	GeneratedAt   time.Time `json:"generated_at"`
+	LocalDate     string    `json:"local_date"`
+	LocalTime     string    `json:"local_time"`
+	Offset        string    `json:"offset"`
+	Abbreviation  string    `json:"abbreviation"`
+	IsDST         bool      `json:"is_dst"`
+	BusinessHours string    `json:"business_hours"`
+	IsOpen        bool      `json:"is_open"`
+	NextOpen      *Boundary `json:"next_open"`
+	NextClose     *Boundary `json:"next_close"`
+	Currency      string    `json:"currency,omitempty"`
+	Statuses      []string  `json:"statuses"`
+	// TotalOrders、YesterdaySameTimeOrders 今天截至当前和昨天同一时刻之前的订单数（全部币种）
+	TotalOrders             int                      `json:"total_orders"`
+	YesterdaySameTimeOrders int                      `json:"yesterday_same_time_orders"`
+	TotalsByCurrency        []DashboardCurrencyTotal `json:"totals_by_currency"`
+	Hourly                  []DashboardHourlyPoint   `json:"hourly"`
+}
+
+// DashboardTotals 一个统计窗口的订单数和金额
+type DashboardTotals struct {
+	OrderCount  int             `json:"order_count"`
+	TotalAmount decimal.Decimal `json:"total_amount"`
+}
+
+// DashboardCurrencyTotal 一个币种今天截至当前与昨天的对比
+type DashboardCurrencyTotal struct {
+	Currency          string          `json:"currency"`
+	Today             DashboardTotals `json:"today"`
+	YesterdaySameTime DashboardTotals `json:"yesterday_same_time"`
+	Yesterday         DashboardTotals `json:"yesterday"`
+	// 相对昨天同期的变化百分比，昨天同期为 0 时为 null
+	OrderChangePercent  *decimal.Decimal `json:"order_change_percent"`
+	AmountChangePercent *decimal.Decimal `json:"amount_change_percent"`
+}
+
+// DashboardHourlyPoint 一个本地小时的订单，最后一个小时点是当前未结束的小时
+type DashboardHourlyPoint struct {
+	StartUTC time.Time `json:"start_utc"`
+	// Local 本地整点（2006-01-02 15:04），Hour 为本地小时
+	Local      string `json:"local"`
+	Hour       int    `json:"hour"`
+	OrderCount int    `json:"order_count"`
+	// Currency 小时内订单币种一致时为该币种，混合币种时为空
+	Currency    string          `json:"currency,omitempty"`
+	TotalAmount decimal.Decimal `json:"total_amount"`
+}
+
+// 变更流水的实体和操作
+const (
+	ChangeEntityMerchant = "merchant"
+	ChangeEntityOrder    = "order"
+
+	ChangeOpInsert = "insert"
+	ChangeOpUpdate = "update"
+	ChangeOpDelete = "delete"
+)
+
+// Change 一条商户或订单的变更
+type Change struct {
+	// Seq 同步序号，单调递增但不保证连续
+	Seq        int64  `json:"seq"`
+	Entity     string `json:"entity"`
+	EntityID   int    `json:"entity_id"`
+	MerchantID int    `json:"merchant_id"`
+	Op         string `json:"op"`
+	// Snapshot 变更后的实体（字段与 Merchant / Order 一致），删除时为删除前的实体
+	Snapshot  json.RawMessage `json:"snapshot"`
+	ChangedAt time.Time       `json:"changed_at"`
+}
+
+// ChangeFilter 读取变更流水的条件
+type ChangeFilter struct {
+	// Since 只返回 seq 大于该值的变更
+	Since int64
+	// MerchantID 不为 0 时只返回该商户及其订单的变更
+	MerchantID int
+	// Entities 为空时返回全部实体
+	Entities []string
+	Limit    int
+}
+
+// ChangeFeed 一次增量拉取的结果
+type ChangeFeed struct {
+	Changes []Change `json:"changes"`
+	// NextSeq 下一次请求的 since：最后一条变更的 seq，没有变更时等于请求的 since
+	NextSeq int64 `json:"next_seq"`
+	// HasMore 为 true 时还有更多变更，应立即用 NextSeq 继续拉取
+	HasMore bool `json:"has_more"`
+	// WaitedMs 本次请求为等待新变更阻塞的时长（毫秒）
+	WaitedMs int64 `json:"waited_ms"`
+}
+
+// 离线同步中一次修改的处理结果
+const (
+	// SyncStatusApplied 修改全部生效
+	SyncStatusApplied = "applied"
+	// SyncStatusMerged 与服务端的修改冲突，部分字段按规则保留了服务端的值
+	SyncStatusMerged = "merged"
+	// SyncStatusRejected 修改没有生效，设备应以返回的订单覆盖本地数据
+	SyncStatusRejected = "rejected"
+)
+
+// SyncRequest 设备的一次同步：推送离线期间的订单修改，再拉取 Since 之后的变更
+type SyncRequest struct {
+	DeviceID   string `json:"device_id"`
+	MerchantID int    `json:"merchant_id"`
+	// Timezone 设备时区，不带偏移的本地时间按该时区换算，为空时使用商户时区
+	Timezone string `json:"timezone"`
+	// ClientTime 设备发出请求时的时钟（RFC3339），与服务器收到请求的时刻之差作为时钟偏差
+	ClientTime string `json:"client_time"`
+	// Since 上一次同步返回的 next_seq，首次同步为 0
+	Since     int64          `json:"since"`
+	Mutations []SyncMutation `json:"mutations"`
+}
+
+// SyncMutation 设备离线期间的一次订单修改，字段为空表示不修改
+// 时刻可以是 RFC3339，也可以是设备时区的本地时间（2006-01-02T15:04:05），都按时钟偏差校正后换算为 UTC
+type SyncMutation struct {
+	// MutationID 设备生成的唯一ID，重复推送同一ID时返回第一次的处理结果
+	MutationID string `json:"mutation_id"`
+	// Op 为 insert（新建订单）或 update（修改订单）
+	Op          string `json:"op"`
+	OrderID     int    `json:"order_id,omitempty"`
+	OrderNumber string `json:"order_number,omitempty"`
+	// BaseVersion 修改所基于的订单版本，update 必填，小于服务端版本时按冲突规则处理
+	BaseVersion int              `json:"base_version,omitempty"`
+	Amount      *decimal.Decimal `json:"amount,omitempty"`
+	Currency    string           `json:"currency,omitempty"`
+	Status      string           `json:"status,omitempty"`
+	OrderTime   string           `json:"order_time,omitempty"`
+	// ChangedAt 设备上做出修改的时刻，冲突时与服务端的 updated_at 比较先后，为空时取服务器收到请求的时刻
+	ChangedAt string `json:"changed_at,omitempty"`
+}
+
+// SyncConflict 一个字段没有按设备提交的值生效，或与服务端的修改冲突
+type SyncConflict struct {
+	Field       string `json:"field"`
+	ClientValue string `json:"client_value"`
+	ServerValue string `json:"server_value"`
+	// Winner 为 client 或 server
+	Winner string `json:"winner"`
+	Rule   string `json:"rule"`
+}
+
+// SyncMutationResult 一次修改的处理结果
+type SyncMutationResult struct {
+	MutationID string `json:"mutation_id"`
+	Status     string `json:"status"`
+	// Order 处理后服务端的订单，订单不存在或属于其他商户时为空
+	Order     *Order         `json:"order,omitempty"`
+	Conflicts []SyncConflict `json:"conflicts,omitempty"`
+	// ChangedAtUTC 校正时钟偏差后的修改时刻
+	ChangedAtUTC time.Time `json:"changed_at_utc"`
+	Reason       string    `json:"reason,omitempty"`
+	// Replayed 为 true 表示该修改之前已经处理过，返回的是当时的结果
+	Replayed bool `json:"replayed"`
+}
+
+// SyncDevice 设备最近一次同步
+type SyncDevice struct {
+	DeviceID    string    `json:"device_id"`
+	MerchantID  int       `json:"merchant_id"`
+	Timezone    string    `json:"timezone"`
+	ClockSkewMs int64     `json:"clock_skew_ms"`
+	LastPullSeq int64     `json:"last_pull_seq"`
+	LastSyncAt  time.Time `json:"last_sync_at"`
+}
+
+// SyncResponse 一次同步的结果：推送的处理结果和 since 之后的变更（包括本次推送产生的变更）
+type SyncResponse struct {
+	ServerTime time.Time `json:"server_time"`
+	// ClockSkewMs 服务器时刻减去设备时钟，正数表示设备时钟偏慢；小于容差时为 0，不做校正
+	ClockSkewMs int64                `json:"clock_skew_ms"`
+	Timezone    string               `json:"timezone"`
+	Results     []SyncMutationResult `json:"results"`
+	Applied     int                  `json:"applied"`
+	Merged      int                  `json:"merged"`
+	Rejected    int                  `json:"rejected"`
+	Changes     []Change             `json:"changes"`
+	NextSeq     int64                `json:"next_seq"`
+	HasMore     bool                 `json:"has_more"`
+}
+
+// Webhook 投递的处理状态，与 ingest_delivery 的约束一致
+const (
+	IngestStatusReceived  = "received"
+	IngestStatusProcessed = "processed"
+	IngestStatusSkipped   = "skipped"
+	IngestStatusIgnored   = "ignored"
+	IngestStatusConflict  = "conflict"
+	IngestStatusFailed    = "failed"
+)
+
+// 订单号已存在时的冲突策略，商户配置 order_conflict_policy 的取值
+const (
+	// OrderConflictReject 不修改已有订单，投递记为 conflict
+	OrderConflictReject = "reject"
+	// OrderConflictUpsert 用投递覆盖已有订单的金额、币种和状态
+	OrderConflictUpsert = "upsert"
+	// OrderConflictVersion 投递的事件时刻晚于最近一次写入订单的平台事件时才覆盖，否则记为 conflict
+	OrderConflictVersion = "version"
+)
+
+// OrderConflictPolicies 支持的冲突策略
+var OrderConflictPolicies = []string{OrderConflictReject, OrderConflictUpsert, OrderConflictVersion}
+
+// OrderConflict 写入的订单号在该商户下已存在且没有写入时的冲突详情
+type OrderConflict struct {
+	Policy      string `json:"policy"`
+	MerchantID  int    `json:"merchant_id"`
+	OrderNumber string `json:"order_number"`
+	// ExistingOrderID、ExistingVersion 已有订单的ID和版本号
+	ExistingOrderID int    `json:"existing_order_id"`
+	ExistingVersion int `json:"existing_version"`
+	// ExistingEventTime 已处理的投递中该订单最晚的事件时刻（不限平台），没有由 Webhook 写入过时为空
+	ExistingEventTime *time.Time `json:"existing_event_time,omitempty"`
+	IncomingEventTime *time.Time `json:"incoming_event_time,omitempty"`
+	// Fields 投递与已有订单不同的字段，相同的字段不列出
+	Fields []OrderFieldDiff `json:"fields"`
+	Reason string           `json:"reason"`
+}
+
+// OrderFieldDiff 冲突中一个字段的已有值和投递中的值
+type OrderFieldDiff struct {
+	Field    string `json:"field"`
+	Existing string `json:"existing"`
+	Incoming string `json:"incoming"`
+}
+
+// IngestDelivery 外部平台的一次 Webhook 投递
+type IngestDelivery struct {
+	ID       int64  `json:"id"`
+	Provider string `json:"provider"`
+	// ExternalID 平台的投递ID或事件ID，同一平台内唯一
+	ExternalID string `json:"external_id"`
+	Topic      string `json:"topic"`
+	// MerchantID Webhook 地址中指定的商户，为 0 时由载荷决定（Stripe 的 metadata.merchant_id）
+	MerchantID  int    `json:"merchant_id,omitempty"`
+	OrderNumber string `json:"order_number,omitempty"`
+	OrderID     int    `json:"order_id,omitempty"`
+	// EventTime 平台事件发生的时刻（UTC）
+	EventTime   *time.Time      `json:"event_time,omitempty"`
+	Status      string          `json:"status"`
+	Error       string          `json:"error,omitempty"`
+	Attempts    int             `json:"attempts"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	ProcessedAt *time.Time      `json:"processed_at,omitempty"`
+	// Conflict status 为 conflict 时的冲突详情
+	Conflict *OrderConflict `json:"conflict,omitempty"`
+	// Duplicate 为 true 表示该投递之前已经收到过，本次没有重新处理
+	Duplicate bool `json:"duplicate,omitempty"`
+}
+
+// IngestDeliveryFilter Webhook 投递的查询条件，零值表示不过滤
+type IngestDeliveryFilter struct {
+	Provider string
+	Status   string
+	Limit    int
+}
+
+// ProviderEvent 支付平台的一个事件，用于核对平台的事件日期与订单的本地日期
+type ProviderEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type,omitempty"`
+	// Created 事件发生的 Unix 时间戳（秒，UTC），与 Stripe 事件的 created 相同
+	Created     int64  `json:"created"`
+	OrderNumber string `json:"order_number"`
+	// MerchantID 订单所属商户，上传的事件可以在 metadata.merchant_id 中指定，为 0 时按订单号在各商户中匹配
+	MerchantID int `json:"merchant_id,omitempty"`
+}
+
+// ProviderEventFilter 从已接收的 Webhook 投递中读取事件的条件，事件时刻在 [From, To) 内
+type ProviderEventFilter struct {
+	Provider   string
+	MerchantID int
+	From       time.Time
+	To         time.Time
+	Limit      int
+}
+
+// 事件日期与订单本地日期不一致的类型
+const (
+	// EventMismatchUTCDate 事件按 UTC 归属的日期（平台报表的日期）与订单本地日期不同，按商户时区换算后一致
+	EventMismatchUTCDate = "utc_date"
+	// EventMismatchLocalDate 事件按商户时区换算后的本地日期仍与订单本地日期不同，如跨本地零点支付或下单时间有误
+	EventMismatchLocalDate = "local_date"
+)
+
+// EventDateMismatch 一个日期归属不一致的事件
+type EventDateMismatch struct {
+	EventID     string `json:"event_id"`
+	EventType   string `json:"event_type,omitempty"`
+	OrderID     int    `json:"order_id"`
+	OrderNumber string `json:"order_number"`
+	MerchantID  int    `json:"merchant_id"`
+	Timezone    string `json:"timezone"`
+	Kind        string `json:"kind"`
+	// EventTimeUTC 事件时刻，EventUTCDate 为其 UTC 日期，EventLocalDate 为按商户时区换算后的日期
+	EventTimeUTC   time.Time `json:"event_time_utc"`
+	EventUTCDate   string    `json:"event_utc_date"`
+	EventLocalDate string    `json:"event_local_date"`
+	OrderTimeUTC   time.Time `json:"order_time_utc"`
+	OrderLocalDate string    `json:"order_local_date"`
+	// DayShift 事件 UTC 日期减订单本地日期的天数，负数表示平台把事件算在前一天
+	DayShift int `json:"day_shift"`
+}
+
+// TimezoneEventReconciliation 一个时区的事件日期核对结果
+type TimezoneEventReconciliation struct {
+	Timezone string `json:"timezone"`
+	Events   int    `json:"events"`
+	// SameDate 事件 UTC 日期、事件本地日期和订单本地日期三者一致的事件数
+	SameDate            int `json:"same_date"`
+	UTCDateMismatches   int `json:"utc_date_mismatches"`
+	LocalDateMismatches int `json:"local_date_mismatches"`
+	// ProviderEarlier、ProviderLater 事件 UTC 日期早于、晚于订单本地日期的事件数
+	ProviderEarlier int `json:"provider_earlier"`
+	ProviderLater   int `json:"provider_later"`
+}
+
+// EventReconciliationReport 支付平台事件与订单本地日期的核对报告
+type EventReconciliationReport struct {
+	// Source 事件来源：upload 为请求中上传的事件，webhooks 为已接收的 Webhook 投递
+	Source   string `json:"source"`
+	Provider string `json:"provider,omitempty"`
+	// From、To 读取 Webhook 投递时的事件 UTC 日期范围（含两端）
+	From            string                        `json:"from,omitempty"`
+	To              string                        `json:"to,omitempty"`
+	TotalEvents     int                           `json:"total_events"`
+	MatchedEvents   int                           `json:"matched_events"`
+	UnmatchedEvents int                           `json:"unmatched_events"`
+	Mismatches      int                           `json:"mismatches"`
+	Timezones       []TimezoneEventReconciliation `json:"timezones"`
+	// Details 不一致的事件，按订单本地日期和事件时刻排序，最多返回 500 条
+	Details   []EventDateMismatch `json:"details"`
+	Truncated bool                `json:"truncated"`
+	// UnmatchedEventIDs 找不到对应订单的事件，最多返回 500 个
+	UnmatchedEventIDs []string  `json:"unmatched_event_ids"`
+	CheckedAt         time.Time `json:"checked_at"`
+}
+
+// CohortFilter 商户按本地周统计订单数的条件
+type CohortFilter struct {
+	// From、To 商户本地日期范围 [From, To)，YYYY-MM-DD
+	From string
+	To   string
+	// WeekStart 周起始日，ISO 星期：1=周一 ... 7=周日
+	WeekStart int
+	// Statuses 参与统计的订单状态，为空时统计全部状态
+	Statuses []string
+}
+
+// MerchantWeeklyOrders 一个商户一个本地周的订单数，WeekStart 为该周第一天的商户本地日期
+type MerchantWeeklyOrders struct {
+	MerchantID int    `json:"merchant_id"`
+	WeekStart  string `json:"week_start"`
+	OrderCount int    `json:"order_count"`
+}
+
+// CohortWeek 群组注册后第 Offset 周（第 0 周为注册周）的订单
+type CohortWeek struct {
+	Offset int `json:"offset"`
+	// WeekStart、WeekEnd 该周第一天和最后一天，按各商户自己的本地日期计算
+	WeekStart string `json:"week_start"`
+	WeekEnd   string `json:"week_end"`
+	// EligibleMerchants 按本地日期该周已经开始的商户数，留存率以此为分母
+	EligibleMerchants int `json:"eligible_merchants"`
+	ActiveMerchants   int `json:"active_merchants"`
+	OrderCount        int `json:"order_count"`
+	// MerchantRetentionPercent 有订单的商户占已开始商户的百分比
+	MerchantRetentionPercent *decimal.Decimal `json:"merchant_retention_percent"`
+	// OrderRetentionPercent 订单数相对第 0 周的百分比，第 0 周没有订单时为 null
+	OrderRetentionPercent *decimal.Decimal `json:"order_retention_percent"`
+	// Complete 群组内所有商户的本地日期都已过完该周
+	Complete bool `json:"complete"`
+}
+
+// Cohort 同一本地周注册的商户群组
+type Cohort struct {
+	// WeekStart 注册周第一天，按商户本地日期
+	WeekStart   string       `json:"week_start"`
+	Merchants   int          `json:"merchants"`
+	MerchantIDs []int        `json:"merchant_ids"`
+	Weeks       []CohortWeek `json:"weeks"`
+}
+
+// CohortReport 商户注册周群组的订单留存
+// 注册日期和订单日期都按各商户时区的本地日期计算，同一 UTC 时刻注册的商户可能属于不同的注册周
+type CohortReport struct {
+	// From、To 注册日期范围（含两端），按商户本地日期
+	From string `json:"from"`
+	To   string `json:"to"`
+	// WeekStartDay 周起始日，ISO 星期：1=周一 ... 7=周日
+	WeekStartDay int       `json:"week_start_day"`
+	Weeks        int       `json:"weeks"`
+	Source       string    `json:"source"`
+	Statuses     []string  `json:"statuses"`
+	Cohorts      []Cohort  `json:"cohorts"`
+	GeneratedAt  time.Time `json:"generated_at"`
+}
+
+// UTCHourFilter 按 UTC 小时统计订单的条件
+type UTCHourFilter struct {
+	// Start、End 订单 UTC 时刻范围 [Start, End)
+	Start time.Time
+	End   time.Time
+	// Statuses 参与统计的订单状态，为空时统计全部状态
+	Statuses []string
+}
+
+// UTCHourTimezoneOrders 一个 UTC 小时、一个时区、营业时间内或外的订单数
+type UTCHourTimezoneOrders struct {
+	HourStart      time.Time `json:"hour_start"`
+	Timezone       string    `json:"timezone"`
+	IsBusinessHour bool      `json:"is_business_hour"`
+	OrderCount     int       `json:"order_count"`
+}
+
+// BusinessDayOrders 营业时间内外的订单数，InsidePercent 为营业时间内订单的占比，没有订单时为 null
+type BusinessDayOrders struct {
+	OrderCount    int              `json:"order_count"`
+	Inside        int              `json:"inside_business_hours"`
+	Outside       int              `json:"outside_business_hours"`
+	InsidePercent *decimal.Decimal `json:"inside_percent"`
+}
+
+// BusinessDayRegion 一个地区（IANA 时区名的第一段，如 Asia、Europe、America）在一个小时或全天的营业覆盖和订单
+type BusinessDayRegion struct {
+	Region string `json:"region"`
+	// OpenTimezones、OpenMerchants 该小时内有营业时间的时区数和商户数，全天合计时为出现营业的时区数和商户数
+	OpenTimezones  int `json:"open_timezones"`
+	OpenMerchants  int `json:"open_merchants"`
+	TotalTimezones int `json:"total_timezones"`
+	TotalMerchants int `json:"total_merchants"`
+	BusinessDayOrders
+}
+
+// BusinessDayHour 一个 UTC 小时的营业覆盖和订单
+type BusinessDayHour struct {
+	HourUTC  int       `json:"hour_utc"`
+	StartUTC time.Time `json:"start_utc"`
+	// OpenTimezones 该小时内至少有一个商户处于营业时间（不必整小时）的时区，按名称排序
+	OpenTimezones []string `json:"open_timezones"`
+	OpenMerchants int      `json:"open_merchants"`
+	BusinessDayOrders
+	Regions []BusinessDayRegion `json:"regions"`
+}
+
+// GlobalBusinessDayReport 一个 UTC 日期内各 UTC 小时有多少商户时区处于营业时间，以及各地区订单落在营业时间内外的比例
+// 营业时间按商户配置和周末判断，与订单的 is_business_hour 一致
+type GlobalBusinessDayReport struct {
+	Date           string              `json:"date"`
+	Source         string              `json:"source"`
+	Statuses       []string            `json:"statuses"`
+	TotalTimezones int                 `json:"total_timezones"`
+	TotalMerchants int                 `json:"total_merchants"`
+	Hours          []BusinessDayHour   `json:"hours"`
+	Regions        []BusinessDayRegion `json:"regions"`
+	BusinessDayOrders
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// MerchantHourFilter 一个商户按本地日期和小时统计订单数的条件
+type MerchantHourFilter struct {
+	MerchantID int
+	// From、To 商户本地日期范围 [From, To)，YYYY-MM-DD
+	From string
+	To   string
+	// Statuses 参与统计的订单状态，为空时统计全部状态
+	Statuses []string
+}
+
+// LocalDateHourOrders 一个本地日期一个本地小时的订单数
+type LocalDateHourOrders struct {
+	LocalDate  string `json:"local_date"`
+	LocalHour  int    `json:"local_hour"`
+	OrderCount int    `json:"order_count"`
+}
+
+// PeakHourStat 一个本地小时在统计范围内的订单分布和显著性
+type PeakHourStat struct {
+	Hour        int `json:"hour"`
+	TotalOrders int `json:"total_orders"`
+	// MeanOrders、StdDev 每天该小时订单数的均值和标准差
+	MeanOrders float64 `json:"mean_orders"`
+	StdDev     float64 `json:"std_dev"`
+	// Lift 均值相对全天每小时平均订单数的倍数
+	Lift float64 `json:"lift"`
+	// TStatistic 每天该小时订单数减去当天每小时平均数的单侧配对 t 检验统计量
+	TStatistic float64 `json:"t_statistic"`
+	// Confidence 该小时订单高于当天平均水平的置信度（0~1）
+	Confidence float64 `json:"confidence"`
+	Peak       bool    `json:"peak"`
+}
+
+// StaffingWindow 由相邻高峰小时合并而成的建议排班时段，按本地时间
+type StaffingWindow struct {
+	// Start、End 本地时间 HH:MM，End 不晚于 Start 表示跨午夜
+	Start string `json:"start"`
+	End   string `json:"end"`
+	Hours int    `json:"hours"`
+	// PeakHours 时段内的高峰小时
+	PeakHours []int `json:"peak_hours"`
+	// AvgOrdersPerDay 时段内每天的平均订单数，OrderSharePercent 为占全天订单的百分比
+	AvgOrdersPerDay   float64 `json:"avg_orders_per_day"`
+	OrderSharePercent float64 `json:"order_share_percent"`
+	// Lift 时段内每小时平均订单数相对全天每小时平均数的倍数
+	Lift float64 `json:"lift"`
+	// WithinBusinessHours 时段是否完全处于商户营业时间内，为 false 时需要营业时间外排班
+	WithinBusinessHours bool `json:"within_business_hours"`
+	// Priority 按每小时订单数排序的优先级，1 最高
+	Priority int `json:"priority"`
+}
+
+// PeakHoursReport 商户最近若干周的高峰本地小时和建议排班时段
+type PeakHoursReport struct {
+	MerchantID    int    `json:"merchant_id"`
+	MerchantName  string `json:"merchant_name"`
+	Timezone      string `json:"timezone"`
+	BusinessHours string `json:"business_hours"`
+	// From、To 统计的商户本地日期范围（含两端），截至昨天
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Weeks int    `json:"weeks"`
+	Days  int    `json:"days"`
+	// ConfidenceLevel 判定高峰小时所需的置信度
+	ConfidenceLevel  float64          `json:"confidence_level"`
+	TotalOrders      int              `json:"total_orders"`
+	AvgOrdersPerHour float64          `json:"avg_orders_per_hour"`
+	Hours            []PeakHourStat   `json:"hours"`
+	PeakHours        []int            `json:"peak_hours"`
+	StaffingWindows  []StaffingWindow `json:"staffing_windows"`
+	Source           string           `json:"source"`
+	Statuses         []string         `json:"statuses"`
+	GeneratedAt      time.Time        `json:"generated_at"`
+}
+
+// OrderFulfillment 一笔已发货订单的下单本地时间和履约时长
+type OrderFulfillment struct {
+	LocalHour      int `json:"local_hour"`
+	LocalDayOfWeek int `json:"local_day_of_week"`
+	// Seconds 下单到首次变为已发货的秒数
+	Seconds int64 `json:"seconds"`
+}
+
+// FulfillmentStats 一组订单的履约时长分布，单位为小时
+type FulfillmentStats struct {
+	Orders   int     `json:"orders"`
+	AvgHours float64 `json:"avg_hours"`
+	P50Hours float64 `json:"p50_hours"`
+	P90Hours float64 `json:"p90_hours"`
+	P95Hours float64 `json:"p95_hours"`
+	MaxHours float64 `json:"max_hours"`
+}
+
+// FulfillmentHourStat 按下单的本地小时分组的履约时长
+type FulfillmentHourStat struct {
+	Hour int `json:"hour"`
+	FulfillmentStats
+	// MedianRatio 中位数相对全部订单中位数的倍数，没有订单时为 0
+	MedianRatio float64 `json:"median_ratio"`
+}
+
+// FulfillmentWeekdayStat 按下单的本地星期分组的履约时长
+type FulfillmentWeekdayStat struct {
+	// Weekday 0=周日 … 6=周六
+	Weekday int `json:"weekday"`
+	FulfillmentStats
+	MedianRatio float64 `json:"median_ratio"`
+	// IsWeekend 是否为商户的周末
+	IsWeekend bool `json:"is_weekend"`
+}
+
+// FulfillmentReport 商户最近若干周下单到发货的时长，按下单的本地小时和星期分组
+type FulfillmentReport struct {
+	MerchantID    int    `json:"merchant_id"`
+	MerchantName  string `json:"merchant_name"`
+	Timezone      string `json:"timezone"`
+	BusinessHours string `json:"business_hours"`
+	// From、To 下单的商户本地日期范围（含两端）
+	From    string           `json:"from"`
+	To      string           `json:"to"`
+	Weeks   int              `json:"weeks"`
+	Overall FulfillmentStats `json:"overall"`
+	// Hours 0~23 点，Weekdays 周日到周六，没有订单的分组各项为 0
+	Hours    []FulfillmentHourStat    `json:"hours"`
+	Weekdays []FulfillmentWeekdayStat `json:"weekdays"`
+	// SlowHours 订单数足够且中位数明显高于全部订单中位数的下单小时
+	SlowHours   []int     `json:"slow_hours"`
+	Source      string    `json:"source"`
+	Statuses    []string  `json:"statuses"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// ResponseCacheStats 响应缓存的配置、命中统计和条数
+type ResponseCacheStats struct {
+	Enabled      bool    `json:"enabled"`
+	TTLSeconds   float64 `json:"ttl_seconds"`
+	StaleSeconds float64 `json:"stale_seconds"`
+	MaxEntries   int     `json:"max_entries"`
+	Entries      int     `json:"entries"`
+	// Hits 未过期命中，StaleHits 过期后返回旧响应，Misses 未命中，Refreshes 触发的后台刷新
+	Hits      int64 `json:"hits"`
+	StaleHits int64 `json:"stale_hits"`
+	Misses    int64 `json:"misses"`
+	Refreshes int64 `json:"refreshes"`
+	// Evictions 超出条数上限被淘汰的条数，Purges 清空次数
+	Evictions   int64                 `json:"evictions"`
+	Purges      int64                 `json:"purges"`
+	LastPurgeAt *time.Time            `json:"last_purge_at,omitempty"`
+	Tenants     []ResponseCacheTenant `json:"tenants"`
+}
+
+// ResponseCacheTenant 一个租户缓存的响应条数
+type ResponseCacheTenant struct {
+	Tenant  string `json:"tenant"`
+	Entries int    `json:"entries"`
+}
+
+// RuntimeDiagnostics 进程的运行时状态：goroutine、内存、GC 和数据库连接池
+type RuntimeDiagnostics struct {
+	CollectedAt   time.Time `json:"collected_at"`
+	StartedAt     time.Time `json:"started_at"`
+	UptimeSeconds float64   `json:"uptime_seconds"`
+	GoVersion     string    `json:"go_version"`
+	NumCPU        int       `json:"num_cpu"`
+	GOMAXPROCS    int       `json:"gomaxprocs"`
+	Goroutines    int       `json:"goroutines"`
+	// GoroutinesByState 按状态（running、runnable、IO wait、select、chan receive 等）统计的 goroutine 数
+	GoroutinesByState map[string]int `json:"goroutines_by_state"`
+	Memory            MemoryStats    `json:"memory"`
+	GC                GCStats        `json:"gc"`
+	// DBPool 数据库连接池统计，mock 模式下为空
+	DBPool *DBPoolStats `json:"db_pool,omitempty"`
+	// Leader 单实例后台任务的选主状态，mock 模式或关闭选主时为空
+	Leader *LeaderStatus `json:"leader,omitempty"`
+	// DebugEndpoints /debug/pprof 和 /debug/vars 是否可用
+	DebugEndpoints bool `json:"debug_endpoints"`
+}
+
+// LeaderStatus 本实例在单实例后台任务选主中的状态
+type LeaderStatus struct {
+	Name string `json:"name"`
+	// Holder 本实例的标识（主机名-进程号）
+	Holder string `json:"holder"`
+	Leader bool   `json:"leader"`
+	// Since 本次成为主实例或失去主实例身份的时刻
+	Since         *time.Time `json:"since,omitempty"`
+	LastRenewedAt *time.Time `json:"last_renewed_at,omitempty"`
+	// Acquisitions、Losses 进程启动以来成为主实例和失去主实例身份的次数
+	Acquisitions int64  `json:"acquisitions"`
+	Losses       int64  `json:"losses"`
+	LastError    string `json:"last_error,omitempty"`
+}
+
+// MemoryStats 堆内存统计，单位字节
+type MemoryStats struct {
+	HeapAlloc   uint64 `json:"heap_alloc"`
+	HeapInuse   uint64 `json:"heap_inuse"`
+	HeapIdle    uint64 `json:"heap_idle"`
+	HeapObjects uint64 `json:"heap_objects"`
+	StackInuse  uint64 `json:"stack_inuse"`
+	Sys         uint64 `json:"sys"`
+	TotalAlloc  uint64 `json:"total_alloc"`
+	Mallocs     uint64 `json:"mallocs"`
+	Frees       uint64 `json:"frees"`
+}
+
+// GCStats 垃圾回收统计
+type GCStats struct {
+	NumGC        uint32  `json:"num_gc"`
+	NumForcedGC  uint32  `json:"num_forced_gc"`
+	PauseTotalMs float64 `json:"pause_total_ms"`
+	// LastPauseMs 最近一次 GC 的停顿时间，LastGCAt 为其结束时刻，从未 GC 时为空
+	LastPauseMs float64    `json:"last_pause_ms"`
+	LastGCAt    *time.Time `json:"last_gc_at,omitempty"`
+	// NextGCBytes 堆达到该大小时触发下一次 GC
+	NextGCBytes   uint64  `json:"next_gc_bytes"`
+	GCCPUFraction float64 `json:"gc_cpu_fraction"`
+}
+
+// DBPoolStats 数据库连接池统计
+type DBPoolStats struct {
+	MaxOpenConnections int `json:"max_open_connections"`
+	OpenConnections    int `json:"open_connections"`
+	InUse              int `json:"in_use"`
+	Idle               int `json:"idle"`
+	// WaitCount、WaitDurationMs 等待空闲连接的次数和累计时间
+	WaitCount         int64   `json:"wait_count"`
+	WaitDurationMs    float64 `json:"wait_duration_ms"`
+	MaxIdleClosed     int64   `json:"max_idle_closed"`
+	MaxIdleTimeClosed int64   `json:"max_idle_time_closed"`
+	MaxLifetimeClosed int64   `json:"max_lifetime_closed"`
+}
@@ -0,0 +1,51 @@
+// Package money 处理金额的精度和按币种舍入。
+// 金额统一使用 decimal.Decimal，避免 float64 在累加营收时产生舍入误差；
+// 汇总结果按 ISO 4217 的币种小数位舍入，如 CNY/USD 2 位、JPY 0 位、KWD 3 位。
+package money
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"golang.org/x/text/currency"
+)
+
+// DefaultExponent 无法识别币种或多币种混合时的小数位
+const DefaultExponent = 2
+
+// ErrUnknownCurrency 不是有效的 ISO 4217 币种代码
+var ErrUnknownCurrency = errors.New("无效的币种代码")
+
+// ParseCode 校验并规范化币种代码，如 usd → USD
+func ParseCode(code string) (string, error) {
+	unit, err := currency.ParseISO(strings.TrimSpace(code))
+	if err != nil {
+		return "", fmt.Errorf("%w: %q", ErrUnknownCurrency, code)
+	}
+	return unit.String(), nil
+}
+
+// Exponent 返回币种的标准小数位，无法识别的币种返回 DefaultExponent
+func Exponent(code string) int32 {
+	unit, err := currency.ParseISO(code)
+	if err != nil {
+		return DefaultExponent
+	}
+	scale, _ := currency.Standard.Rounding(unit)
+	return int32(scale)
+}
+
+// Round 按币种小数位四舍五入
+func Round(amount decimal.Decimal, code string) decimal.Decimal {
+	return amount.Round(Exponent(code))
+}
+
+// Average 计算平均金额并按币种舍入，count 为 0 时返回 0
+func Average(total decimal.Decimal, count int, code string) decimal.Decimal {
+	if count == 0 {
+		return decimal.Zero
+	}
+	return Round(total.Div(decimal.NewFromInt(int64(count))), code)
+}
@@ -0,0 +1,494 @@
+// Package msgpack 把 API 响应编码为 MessagePack，供大量拉取订单和分析数据的内部服务使用。
+// 编码规则与 encoding/json 对应，客户端解码后得到与 JSON 相同的结构：结构体按 json 标签编码为 map（支持 omitempty 和 "-"，
+// 匿名嵌入的结构体字段展开到外层），实现 encoding.TextMarshaler 的类型（time.Time、decimal.Decimal）编码为其文本形式，
+// 只实现 json.Marshaler 的类型按其 JSON 输出编码，nil 切片和指针编码为 nil，map 的键按字符串排序。
+// 整数按值选择最短的格式，不使用扩展类型。
+package msgpack
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	numberType        = reflect.TypeOf(json.Number(""))
+	timeType          = reflect.TypeOf(time.Time{})
+	decimalType       = reflect.TypeOf(decimal.Decimal{})
+)
+
+// marshaler 类型的特殊编码方式，按类型缓存，避免对每个值做接口检查
+type marshaler int
+
+const (
+	marshalNone marshaler = iota
+	marshalNumber
+	// marshalTime、marshalDecimal 是 TextMarshaler 的快速路径，输出与 MarshalText 相同
+	marshalTime
+	marshalDecimal
+	marshalText
+	// marshalTextAddr 指针接收者实现 TextMarshaler，值可寻址时使用
+	marshalTextAddr
+	marshalJSON
+)
+
+var marshalerCache sync.Map // reflect.Type -> marshaler
+
+func typeMarshaler(t reflect.Type) marshaler {
+	if m, ok := marshalerCache.Load(t); ok {
+		return m.(marshaler)
+	}
+	m := marshalNone
+	switch {
+	case t.Kind() == reflect.Pointer || t.Kind() == reflect.Interface:
+	case t == numberType:
+		m = marshalNumber
+	case t == timeType:
+		m = marshalTime
+	case t == decimalType:
+		m = marshalDecimal
+	case t.Implements(textMarshalerType):
+		m = marshalText
+	case reflect.PointerTo(t).Implements(textMarshalerType):
+		m = marshalTextAddr
+	case t.Implements(jsonMarshalerType):
+		m = marshalJSON
+	}
+	marshalerCache.Store(t, m)
+	return m
+}
+
+// appendTime 与 time.Time.MarshalText 相同，格式为 RFC 3339（纳秒精度），按 str8 编码后回填长度
+func appendTime(buf []byte, v reflect.Value) ([]byte, error) {
+	var t time.Time
+	if v.CanAddr() {
+		t = *v.Addr().Interface().(*time.Time)
+	} else {
+		t = v.Interface().(time.Time)
+	}
+	if y := t.Year(); y < 0 || y >= 10000 {
+		return buf, fmt.Errorf("msgpack: 时间 %v 超出 RFC 3339 的年份范围", t)
+	}
+	start := len(buf)
+	buf = append(buf, 0xd9, 0)
+	buf = t.AppendFormat(buf, time.RFC3339Nano)
+	buf[start+1] = byte(len(buf) - start - 2)
+	return buf, nil
+}
+
+// appendDecimal 与 decimal.Decimal.MarshalText 相同
+func appendDecimal(buf []byte, v reflect.Value) ([]byte, error) {
+	if v.CanAddr() {
+		return AppendString(buf, v.Addr().Interface().(*decimal.Decimal).String()), nil
+	}
+	return AppendString(buf, v.Interface().(decimal.Decimal).String()), nil
+}
+
+// Marshal 编码 v
+func Marshal(v interface{}) ([]byte, error) {
+	return Append(nil, v)
+}
+
+// Append 把 v 的编码追加到 buf，失败时返回的 buf 内容不完整
+func Append(buf []byte, v interface{}) ([]byte, error) {
+	return appendValue(buf, reflect.ValueOf(v))
+}
+
+func appendValue(buf []byte, v reflect.Value) ([]byte, error) {
+	if !v.IsValid() {
+		return append(buf, 0xc0), nil
+	}
+	switch typeMarshaler(v.Type()) {
+	case marshalNumber:
+		return appendNumber(buf, json.Number(v.String()))
+	case marshalTime:
+		return appendTime(buf, v)
+	case marshalDecimal:
+		return appendDecimal(buf, v)
+	case marshalText:
+		return appendTextMarshaler(buf, v.Interface().(encoding.TextMarshaler))
+	case marshalTextAddr:
+		if v.CanAddr() {
+			return appendTextMarshaler(buf, v.Addr().Interface().(encoding.TextMarshaler))
+		}
+	case marshalJSON:
+		return appendJSONMarshaler(buf, v.Interface().(json.Marshaler))
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return append(buf, 0xc0), nil
+		}
+		if v.Kind() == reflect.Pointer {
+			switch typeMarshaler(v.Type().Elem()) {
+			case marshalText, marshalTextAddr:
+				return appendTextMarshaler(buf, v.Interface().(encoding.TextMarshaler))
+			case marshalJSON:
+				return appendJSONMarshaler(buf, v.Interface().(json.Marshaler))
+			}
+		}
+		return appendValue(buf, v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			return append(buf, 0xc3), nil
+		}
+		return append(buf, 0xc2), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return AppendInt(buf, v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return AppendUint(buf, v.Uint()), nil
+	case reflect.Float32:
+		buf = append(buf, 0xca)
+		return appendUint32(buf, math.Float32bits(float32(v.Float()))), nil
+	case reflect.Float64:
+		return AppendFloat(buf, v.Float()), nil
+	case reflect.String:
+		return AppendString(buf, v.String()), nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return append(buf, 0xc0), nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return appendBinary(buf, v.Bytes()), nil
+		}
+		return appendArray(buf, v)
+	case reflect.Array:
+		return appendArray(buf, v)
+	case reflect.Map:
+		if v.IsNil() {
+			return append(buf, 0xc0), nil
+		}
+		return appendMap(buf, v)
+	case reflect.Struct:
+		return appendStruct(buf, v)
+	}
+	return buf, fmt.Errorf("msgpack: 不支持的类型 %s", v.Type())
+}
+
+// AppendInt 编码有符号整数
+func AppendInt(buf []byte, n int64) []byte {
+	switch {
+	case n >= 0:
+		return AppendUint(buf, uint64(n))
+	case n >= -32:
+		return append(buf, byte(n))
+	case n >= math.MinInt8:
+		return append(buf, 0xd0, byte(n))
+	case n >= math.MinInt16:
+		return appendUint16(append(buf, 0xd1), uint16(n))
+	case n >= math.MinInt32:
+		return appendUint32(append(buf, 0xd2), uint32(n))
+	}
+	return appendUint64(append(buf, 0xd3), uint64(n))
+}
+
+// AppendUint 编码无符号整数
+func AppendUint(buf []byte, n uint64) []byte {
+	switch {
+	case n <= 0x7f:
+		return append(buf, byte(n))
+	case n <= math.MaxUint8:
+		return append(buf, 0xcc, byte(n))
+	case n <= math.MaxUint16:
+		return appendUint16(append(buf, 0xcd), uint16(n))
+	case n <= math.MaxUint32:
+		return appendUint32(append(buf, 0xce), uint32(n))
+	}
+	return appendUint64(append(buf, 0xcf), n)
+}
+
+// AppendFloat 编码 float64
+func AppendFloat(buf []byte, f float64) []byte {
+	return appendUint64(append(buf, 0xcb), math.Float64bits(f))
+}
+
+// AppendString 编码字符串
+func AppendString(buf []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n <= 31:
+		buf = append(buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		buf = append(buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		buf = appendUint16(append(buf, 0xda), uint16(n))
+	default:
+		buf = appendUint32(append(buf, 0xdb), uint32(n))
+	}
+	return append(buf, s...)
+}
+
+func appendBinary(buf []byte, b []byte) []byte {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		buf = append(buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		buf = appendUint16(append(buf, 0xc5), uint16(n))
+	default:
+		buf = appendUint32(append(buf, 0xc6), uint32(n))
+	}
+	return append(buf, b...)
+}
+
+func appendArrayHeader(buf []byte, n int) []byte {
+	switch {
+	case n <= 15:
+		return append(buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return appendUint16(append(buf, 0xdc), uint16(n))
+	}
+	return appendUint32(append(buf, 0xdd), uint32(n))
+}
+
+func appendMapHeader(buf []byte, n int) []byte {
+	switch {
+	case n <= 15:
+		return append(buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return appendUint16(append(buf, 0xde), uint16(n))
+	}
+	return appendUint32(append(buf, 0xdf), uint32(n))
+}
+
+func appendUint16(buf []byte, n uint16) []byte {
+	return append(buf, byte(n>>8), byte(n))
+}
+
+func appendUint32(buf []byte, n uint32) []byte {
+	return append(buf, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+func appendUint64(buf []byte, n uint64) []byte {
+	return append(buf, byte(n>>56), byte(n>>48), byte(n>>40), byte(n>>32), byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+// appendNumber json.Number（如假名化后的数据）按整数或浮点数编码
+func appendNumber(buf []byte, n json.Number) ([]byte, error) {
+	if i, err := n.Int64(); err == nil {
+		return AppendInt(buf, i), nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return buf, fmt.Errorf("msgpack: 无效的数字 %q", n)
+	}
+	return AppendFloat(buf, f), nil
+}
+
+func appendTextMarshaler(buf []byte, m encoding.TextMarshaler) ([]byte, error) {
+	text, err := m.MarshalText()
+	if err != nil {
+		return buf, fmt.Errorf("msgpack: %w", err)
+	}
+	return AppendString(buf, string(text)), nil
+}
+
+// appendJSONMarshaler 只实现 json.Marshaler 的类型按其 JSON 输出的结构编码
+func appendJSONMarshaler(buf []byte, m json.Marshaler) ([]byte, error) {
+	data, err := m.MarshalJSON()
+	if err != nil {
+		return buf, fmt.Errorf("msgpack: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return buf, fmt.Errorf("msgpack: %w", err)
+	}
+	return appendValue(buf, reflect.ValueOf(v))
+}
+
+func appendArray(buf []byte, v reflect.Value) ([]byte, error) {
+	buf = appendArrayHeader(buf, v.Len())
+	var err error
+	for i := 0; i < v.Len(); i++ {
+		if buf, err = appendValue(buf, v.Index(i)); err != nil {
+			return buf, err
+		}
+	}
+	return buf, nil
+}
+
+// appendMap 键与 encoding/json 一样转为字符串并排序
+func appendMap(buf []byte, v reflect.Value) ([]byte, error) {
+	type entry struct {
+		key   string
+		value reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := mapKey(iter.Key())
+		if err != nil {
+			return buf, err
+		}
+		entries = append(entries, entry{key, iter.Value()})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+
+	buf = appendMapHeader(buf, len(entries))
+	var err error
+	for _, e := range entries {
+		buf = AppendString(buf, e.key)
+		if buf, err = appendValue(buf, e.value); err != nil {
+			return buf, err
+		}
+	}
+	return buf, nil
+}
+
+func mapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if m, ok := k.Interface().(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		return string(text), err
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("msgpack: 不支持的 map 键类型 %s", k.Type())
+}
+
+// appendStruct 先数出要编码的字段数写入 map 头，再逐个编码，不为每个结构体分配临时切片
+func appendStruct(buf []byte, v reflect.Value) ([]byte, error) {
+	fields := cachedFields(v.Type())
+	n := 0
+	for i := range fields {
+		if _, ok := fields[i].value(v); ok {
+			n++
+		}
+	}
+
+	buf = appendMapHeader(buf, n)
+	var err error
+	for i := range fields {
+		fv, ok := fields[i].value(v)
+		if !ok {
+			continue
+		}
+		buf = AppendString(buf, fields[i].name)
+		if buf, err = appendValue(buf, fv); err != nil {
+			return buf, err
+		}
+	}
+	return buf, nil
+}
+
+// value 字段的值，omitempty 且为空值、或经过 nil 的嵌入指针时返回 false
+func (f *field) value(v reflect.Value) (reflect.Value, bool) {
+	fv, ok := fieldByIndex(v, f.index)
+	if !ok || (f.omitEmpty && isEmptyValue(fv)) {
+		return fv, false
+	}
+	return fv, true
+}
+
+// fieldByIndex 与 reflect.Value.FieldByIndex 相同，经过 nil 的嵌入指针时返回 false（encoding/json 同样跳过这些字段）
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
+
+// field 结构体中参与编码的字段
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+var fieldCache sync.Map // reflect.Type -> []field
+
+func cachedFields(t reflect.Type) []field {
+	if fields, ok := fieldCache.Load(t); ok {
+		return fields.([]field)
+	}
+	fields, _ := fieldCache.LoadOrStore(t, typeFields(t, nil))
+	return fields.([]field)
+}
+
+// typeFields 按 json 标签列出字段，没有 json 名称的匿名嵌入结构体展开到外层；
+// 外层字段与展开的字段同名时外层优先（encoding/json 的完整规则还会比较标签和深度，这里的模型不涉及）
+func typeFields(t reflect.Type, index []int) []field {
+	var fields []field
+	seen := make(map[string]bool)
+	var embedded []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fieldIndex := append(append([]int(nil), index...), i)
+
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, typeFields(ft, fieldIndex)...)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		seen[name] = true
+		fields = append(fields, field{name: name, index: fieldIndex, omitEmpty: strings.Contains(opts, "omitempty")})
+	}
+	for _, f := range embedded {
+		if !seen[f.name] {
+			seen[f.name] = true
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
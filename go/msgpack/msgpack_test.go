@@ -0,0 +1,399 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"timezone-saas-demo/msgpack"
+)
+
+// decoder 测试用的最小解码器，解码为与 encoding/json 通用结构相同的 map[string]interface{}、[]interface{}
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func decode(data []byte) (interface{}, error) {
+	d := &decoder{data: data}
+	v, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("解码后剩余 %d 字节", len(d.data)-d.pos)
+	}
+	return v, nil
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if d.pos+n > len(d.data) {
+		return nil, fmt.Errorf("位置 %d 需要 %d 字节, 数据已结束", d.pos, n)
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) uint(n int) (uint64, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+func (d *decoder) value() (interface{}, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		return d.str(int(c & 0x1f))
+	case c&0xf0 == 0x90:
+		return d.array(int(c & 0x0f))
+	case c&0xf0 == 0x80:
+		return d.object(int(c & 0x0f))
+	}
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.uint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.next(int(n))
+		return append([]byte(nil), b...), err
+	case 0xca:
+		u, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := d.uint(8)
+		return math.Float64frombits(u), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.uint(1 << (c - 0xcc))
+		if u > math.MaxInt64 {
+			return u, err
+		}
+		return int64(u), err
+	case 0xd0:
+		u, err := d.uint(1)
+		return int64(int8(u)), err
+	case 0xd1:
+		u, err := d.uint(2)
+		return int64(int16(u)), err
+	case 0xd2:
+		u, err := d.uint(4)
+		return int64(int32(u)), err
+	case 0xd3:
+		u, err := d.uint(8)
+		return int64(u), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(int(n))
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(int(n))
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.object(int(n))
+	}
+	return nil, fmt.Errorf("位置 %d 不支持的格式 0x%02x", d.pos-1, c)
+}
+
+func (d *decoder) str(n int) (string, error) {
+	b, err := d.next(n)
+	return string(b), err
+}
+
+func (d *decoder) array(n int) (interface{}, error) {
+	items := make([]interface{}, n)
+	for i := range items {
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		items[i] = v
+	}
+	return items, nil
+}
+
+func (d *decoder) object(n int) (interface{}, error) {
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("map 键 %v 不是字符串", k)
+		}
+		if m[key], err = d.value(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// TestMarshalFormats 各类型按值选择最短的格式，边界两侧的长度和取值使用不同的格式
+func TestMarshalFormats(t *testing.T) {
+	cases := []struct {
+		name   string
+		value  interface{}
+		header string
+		length int
+	}{
+		{"nil", nil, "c0", 1},
+		{"nil 指针", (*int)(nil), "c0", 1},
+		{"nil 切片", []int(nil), "c0", 1},
+		{"nil map", map[string]int(nil), "c0", 1},
+		{"false", false, "c2", 1},
+		{"true", true, "c3", 1},
+
+		{"0", 0, "00", 1},
+		{"positive fixint 上界", 127, "7f", 1},
+		{"uint8 下界", 128, "cc80", 2},
+		{"uint8 上界", 255, "ccff", 2},
+		{"uint16 下界", 256, "cd0100", 3},
+		{"uint16 上界", 65535, "cdffff", 3},
+		{"uint32 下界", 65536, "ce00010000", 5},
+		{"uint32 上界", int64(math.MaxUint32), "ceffffffff", 5},
+		{"uint64 下界", int64(math.MaxUint32) + 1, "cf0000000100000000", 9},
+		{"uint64 上界", uint64(math.MaxUint64), "cfffffffffffffffff", 9},
+		{"-1", -1, "ff", 1},
+		{"negative fixint 下界", -32, "e0", 1},
+		{"int8 上界", -33, "d0df", 2},
+		{"int8 下界", math.MinInt8, "d080", 2},
+		{"int16 上界", math.MinInt8 - 1, "d1ff7f", 3},
+		{"int16 下界", math.MinInt16, "d18000", 3},
+		{"int32 上界", math.MinInt16 - 1, "d2ffff7fff", 5},
+		{"int32 下界", math.MinInt32, "d280000000", 5},
+		{"int64 上界", int64(math.MinInt32) - 1, "d3ffffffff7fffffff", 9},
+		{"int64 下界", int64(math.MinInt64), "d38000000000000000", 9},
+		{"int8 类型的负数", int8(-5), "fb", 1},
+		{"json.Number 整数", json.Number("-200"), "d1ff38", 3},
+		{"json.Number 小数", json.Number("1.5"), "cb3ff8000000000000", 9},
+		{"float32", float32(1.5), "ca3fc00000", 5},
+		{"float64", 1.5, "cb3ff8000000000000", 9},
+
+		{"空字符串", "", "a0", 1},
+		{"fixstr 上界", strings.Repeat("a", 31), "bf", 32},
+		{"str8 下界", strings.Repeat("a", 32), "d920", 34},
+		{"str8 上界", strings.Repeat("a", 255), "d9ff", 257},
+		{"str16 下界", strings.Repeat("a", 256), "da0100", 259},
+		{"str16 上界", strings.Repeat("a", 65535), "daffff", 65538},
+		{"str32 下界", strings.Repeat("a", 65536), "db00010000", 65541},
+		{"多字节字符按字节计长度", strings.Repeat("时", 11), "d921", 35},
+
+		{"bin8", []byte{1, 2}, "c402", 4},
+		{"bin16", make([]byte, 256), "c50100", 259},
+		{"空数组", []int{}, "90", 1},
+		{"fixarray 上界", make([]int, 15), "9f", 16},
+		{"array16 下界", make([]int, 16), "dc0010", 19},
+		{"空 map", map[string]int{}, "80", 1},
+		{"fixmap 上界", intMap(15), "8f", 0},
+		{"map16 下界", intMap(16), "de0010", 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := msgpack.Marshal(c.value)
+			if err != nil {
+				t.Fatalf("Marshal(%v) 失败: %v", c.value, err)
+			}
+			header, _ := hex.DecodeString(c.header)
+			if !bytes.HasPrefix(got, header) {
+				t.Errorf("Marshal 的头部 = %x, 期望 %s", got[:min(len(got), len(header)+1)], c.header)
+			}
+			if c.length > 0 && len(got) != c.length {
+				t.Errorf("Marshal 的长度 = %d, 期望 %d", len(got), c.length)
+			}
+		})
+	}
+}
+
+func intMap(n int) map[string]int {
+	m := make(map[string]int, n)
+	for i := 0; i < n; i++ {
+		m[fmt.Sprintf("k%02d", i)] = i
+	}
+	return m
+}
+
+// TestMarshalNestedMap 嵌套 map 的键按字符串排序，整数键转为字符串，输出逐字节确定
+func TestMarshalNestedMap(t *testing.T) {
+	value := map[string]interface{}{
+		"b": map[int]interface{}{10: nil, 2: -1},
+		"a": []interface{}{"x", map[string]bool{"z": true, "y": false}},
+	}
+	want := "82" + // {2}
+		"a161" + "92" + "a178" + "82" + "a179" + "c2" + "a17a" + "c3" + // "a": ["x", {"y": false, "z": true}]
+		"a162" + "82" + "a23130" + "c0" + "a132" + "ff" // "b": {"10": nil, "2": -1}
+	for i := 0; i < 5; i++ {
+		got, err := msgpack.Marshal(value)
+		if err != nil {
+			t.Fatalf("Marshal 失败: %v", err)
+		}
+		if hex.EncodeToString(got) != want {
+			t.Fatalf("Marshal = %x, 期望 %s", got, want)
+		}
+	}
+}
+
+type roundTripInner struct {
+	Code   string          `json:"code"`
+	Amount decimal.Decimal `json:"amount"`
+	Note   *string         `json:"note,omitempty"`
+}
+
+type roundTripBase struct {
+	ID      int    `json:"id"`
+	Created string `json:"created"`
+}
+
+type roundTripOuter struct {
+	roundTripBase
+	ID       int64                     `json:"order_id"`
+	Name     string                    `json:"name"`
+	At       time.Time                 `json:"at"`
+	Ratio    float64                   `json:"ratio"`
+	Negative int32                     `json:"negative"`
+	Tags     []string                  `json:"tags"`
+	Empty    []string                  `json:"empty,omitempty"`
+	Items    []roundTripInner          `json:"items"`
+	Nested   map[string]map[string]int `json:"nested"`
+	Inner    *roundTripInner           `json:"inner"`
+	Raw      json.RawMessage           `json:"raw"`
+	Secret   string                    `json:"-"`
+	private  string
+}
+
+// TestMarshalRoundTrip 解码后的结构与同一个值经 encoding/json 编码的结构相同
+func TestMarshalRoundTrip(t *testing.T) {
+	note := "加急"
+	at := time.Date(2024, 9, 8, 1, 30, 0, 123456789, time.FixedZone("-03", -3*3600))
+	values := []interface{}{
+		nil,
+		"",
+		strings.Repeat("订单", 100),
+		[]int64{0, -1, -32, -33, -129, -32769, math.MinInt64, 127, 128, 65536, math.MaxInt64},
+		map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{"c": []interface{}{nil, true, 1.25}}}},
+		roundTripOuter{},
+		roundTripOuter{
+			roundTripBase: roundTripBase{ID: 7, Created: "2024-09-08"},
+			ID:            -42,
+			Name:          strings.Repeat("n", 40),
+			At:            at,
+			Ratio:         -0.125,
+			Negative:      math.MinInt32,
+			Tags:          []string{"a", strings.Repeat("b", 300)},
+			Empty:         nil,
+			Items:         []roundTripInner{{Code: "X", Amount: decimal.RequireFromString("-12.50"), Note: &note}, {Code: "Y"}},
+			Nested:        map[string]map[string]int{"z": {"b": 2, "a": -1}, "y": nil},
+			Inner:         &roundTripInner{Code: "I", Amount: decimal.NewFromInt(3)},
+			Raw:           json.RawMessage(`{"k":[1,-2,"s",null]}`),
+			Secret:        "不编码",
+			private:       "不编码",
+		},
+	}
+	for i, v := range values {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			packed, err := msgpack.Marshal(v)
+			if err != nil {
+				t.Fatalf("Marshal 失败: %v", err)
+			}
+			decoded, err := decode(packed)
+			if err != nil {
+				t.Fatalf("解码 %x 失败: %v", packed, err)
+			}
+			got, err := json.Marshal(decoded)
+			if err != nil {
+				t.Fatalf("重新编码为 JSON 失败: %v", err)
+			}
+			want, err := canonicalJSON(v)
+			if err != nil {
+				t.Fatalf("编码为 JSON 失败: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("往返结果 = %s\n期望 %s", got, want)
+			}
+		})
+	}
+}
+
+// canonicalJSON v 经 encoding/json 编码后再按通用结构重新编码，对象的键按字符串排序
+func canonicalJSON(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(generic)
+}
+
+// TestMarshalErrors 不支持的类型和超出范围的时间返回错误
+func TestMarshalErrors(t *testing.T) {
+	for name, v := range map[string]interface{}{
+		"函数":       func() {},
+		"通道":       make(chan int),
+		"复数":       complex(1, 2),
+		"结构体键":     map[struct{ A int }]int{{1}: 1},
+		"嵌套的不支持类型": map[string]interface{}{"f": func() {}},
+		"年份超出范围":   time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		if _, err := msgpack.Marshal(v); err == nil {
+			t.Errorf("%s: Marshal 应返回错误", name)
+		}
+	}
+}
+
+// TestAppendReusesBuffer Append 追加到已有内容之后
+func TestAppendReusesBuffer(t *testing.T) {
+	buf := []byte{0xff}
+	buf, err := msgpack.Append(buf, map[string]int{"n": 300})
+	if err != nil {
+		t.Fatalf("Append 失败: %v", err)
+	}
+	want := []byte{0xff, 0x81, 0xa1, 'n', 0xcd}
+	want = binary.BigEndian.AppendUint16(want, 300)
+	if !bytes.Equal(buf, want) {
+		t.Errorf("Append = %x, 期望 %x", buf, want)
+	}
+}
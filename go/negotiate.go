@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"log"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"timezone-saas-demo/msgpack"
+)
+
+// 响应格式，按 Accept 请求头协商
+const (
+	formatJSON    = "json"
+	formatXML     = "xml"
+	formatMsgpack = "msgpack"
+)
+
+// formatMediaTypes 各响应格式对应的媒体类型，顺序即权重相同时的优先顺序
+var formatMediaTypes = []struct {
+	format string
+	types  []string
+}{
+	{formatJSON, []string{"application/json"}},
+	{formatMsgpack, []string{"application/x-msgpack", "application/msgpack", "application/vnd.msgpack"}},
+	{formatXML, []string{"application/xml", "text/xml"}},
+}
+
+// negotiateFormat 按 Accept 请求头选择响应格式：每种格式的权重取匹配的最具体的媒体范围（完整类型优先于 type/*，
+// type/* 优先于 */*），取权重最高的格式，权重相同时依次为 JSON、MessagePack、XML；
+// 未指定 Accept 时为 JSON，没有可接受的格式（都是不支持的类型或 q=0）时返回空字符串
+func negotiateFormat(r *http.Request) string {
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return formatJSON
+	}
+	type weight struct {
+		specificity int
+		q           float64
+	}
+	weights := make(map[string]weight, len(formatMediaTypes))
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		for _, f := range formatMediaTypes {
+			for _, t := range f.types {
+				specificity := mediaRangeSpecificity(mediaType, t)
+				if specificity == 0 {
+					continue
+				}
+				w, ok := weights[f.format]
+				if !ok || specificity > w.specificity || (specificity == w.specificity && q > w.q) {
+					weights[f.format] = weight{specificity, q}
+				}
+			}
+		}
+	}
+	format, best := "", 0.0
+	for _, f := range formatMediaTypes {
+		if q := weights[f.format].q; q > best {
+			format, best = f.format, q
+		}
+	}
+	return format
+}
+
+// mediaRangeSpecificity 媒体范围 mediaRange 匹配 mediaType 时的具体程度：完整类型为 3，type/* 为 2，*/* 为 1，不匹配为 0
+func mediaRangeSpecificity(mediaRange, mediaType string) int {
+	switch mediaRange {
+	case mediaType:
+		return 3
+	case mediaType[:strings.Index(mediaType, "/")] + "/*":
+		return 2
+	case "*/*":
+		return 1
+	}
+	return 0
+}
+
+// respondNegotiated 按 Accept 请求头输出 JSON、MessagePack 或 XML 响应，所有接口的响应都经过这里
+// MessagePack 的结构与 JSON 相同（见 msgpack 包），适合大量拉取订单列表和分析结果的内部服务；
+// XML 根元素为 response，字段名与 JSON 一致，列表的每一项为 item 元素；
+// Accept 中没有支持的格式时返回 406，数据无法编码为所选格式时（如 XML 不支持的类型）返回 500，都不改用其他格式
+// JSON_ENCODER=fast 时数据为订单列表或分析结果的 JSON 响应使用 fastjson 编码，输出与 encoding/json 相同
+func respondNegotiated(w http.ResponseWriter, r *http.Request, statusCode int, response APIResponse) {
+	w.Header().Add("Vary", "Accept")
+	var body []byte
+	var contentType string
+	var err error
+	switch format := negotiateFormat(r); format {
+	case formatMsgpack:
+		contentType = "application/x-msgpack"
+		body, err = msgpack.Marshal(response)
+	case formatXML:
+		contentType = "application/xml; charset=utf-8"
+		body, err = marshalXMLResponse(response)
+	case formatJSON:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+		if fastJSON {
+			if body, ok := appendFastResponse(nil, response); ok {
+				w.Write(body)
+				return
+			}
+		}
+		json.NewEncoder(w).Encode(response)
+		return
+	default:
+		respondUnencodable(w, r, http.StatusNotAcceptable, "response.not_acceptable")
+		return
+	}
+	if err != nil {
+		log.Printf("⚠️ %s %s 响应编码为 %s 失败: %v", r.Method, r.URL.Path, contentType, err)
+		respondUnencodable(w, r, http.StatusInternalServerError, "response.encode_failed")
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
+
+// respondUnencodable 无法按协商的格式输出时返回纯文本的错误消息
+func respondUnencodable(w http.ResponseWriter, r *http.Request, statusCode int, code string) {
+	message := negotiateLocale(w, r).Message(code)
+	// negotiateLocale 重设了 Vary，重新加上 Accept
+	w.Header().Add("Vary", "Accept")
+	http.Error(w, message, statusCode)
+}
+
+// marshalXMLResponse 编码 XML 响应，包含 XML 声明，以换行结尾
+func marshalXMLResponse(response APIResponse) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(&buf).Encode(xmlResponse(response)); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// xmlResponse 把 Data 换成 xmlValue，列表和 map 按统一的规则编码
+func xmlResponse(response APIResponse) APIResponse {
+	if response.Data != nil {
+		response.Data = xmlValue{response.Data}
+	}
+	return response
+}
+
+// xmlValue 编码任意数据：切片的每一项为 item 元素，map[string]interface{}（如假名化后的数据）的键为子元素，
+// 不是合法元素名的键写成 <entry key="...">；其他类型按 xml 标签编码
+type xmlValue struct {
+	v interface{}
+}
+
+// MarshalXML 实现 xml.Marshaler 接口
+func (x xmlValue) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	switch value := x.v.(type) {
+	case nil:
+		return nil
+	case xmlValue:
+		return value.MarshalXML(e, start)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, key := range keys {
+			child := xml.StartElement{Name: xml.Name{Local: key}}
+			if !isXMLName(key) {
+				child = xml.StartElement{
+					Name: xml.Name{Local: "entry"},
+					Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: key}},
+				}
+			}
+			if err := (xmlValue{value[key]}).MarshalXML(e, child); err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+	}
+
+	rv := reflect.ValueOf(x.v)
+	if (rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8) || rv.Kind() == reflect.Array {
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		item := xml.StartElement{Name: xml.Name{Local: "item"}}
+		for i := 0; i < rv.Len(); i++ {
+			if err := (xmlValue{rv.Index(i).Interface()}).MarshalXML(e, item); err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+	}
+	return e.EncodeElement(x.v, start)
+}
+
+// isXMLName 键能否直接作为元素名：字母或下划线开头，其余为字母、数字、下划线、连字符或点
+func isXMLName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case i > 0 && (c == '-' || c == '.' || c >= '0' && c <= '9'):
+		default:
+			return false
+		}
+	}
+	return true
+}
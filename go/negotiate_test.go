@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"timezone-saas-demo/msgpack"
+)
+
+// TestNegotiateFormat q 值、通配符、最具体的媒体范围优先，以及没有支持的格式
+func TestNegotiateFormat(t *testing.T) {
+	cases := []struct {
+		accept string
+		want   string
+	}{
+		{"", formatJSON},
+		{"*/*", formatJSON},
+		{"application/*", formatJSON},
+		{"application/json", formatJSON},
+		{"application/xml", formatXML},
+		{"text/xml", formatXML},
+		{"text/*", formatXML},
+		{"application/x-msgpack", formatMsgpack},
+		{"application/vnd.msgpack", formatMsgpack},
+		{"APPLICATION/XML", formatXML},
+		{"application/xml; charset=utf-8", formatXML},
+		{"application/json, application/xml", formatJSON},
+		{"application/xml, application/x-msgpack", formatMsgpack},
+		{"application/json;q=0.5, application/xml", formatXML},
+		{"application/json;q=0.5, application/xml;q=0.5", formatJSON},
+		{"application/json;q=0.9, application/x-msgpack;q=0.9, application/xml;q=1", formatXML},
+		{"application/xml;q=0.8, */*;q=0.9", formatJSON},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", formatXML},
+		{"*/*, application/json;q=0", formatMsgpack},
+		{"*/*;q=0.1, application/json;q=0, application/x-msgpack;q=0", formatXML},
+		{"application/*;q=0.2, application/x-msgpack", formatMsgpack},
+		{"application/x-msgpack;q=0, application/msgpack", formatMsgpack},
+		{"application/json;q=abc, application/xml", formatXML},
+		{"application/json;q=2, application/xml;q=0.1", formatXML},
+		{"not a media type, application/xml", formatXML},
+
+		{"text/html", ""},
+		{"image/png, text/csv;q=0.5", ""},
+		{"application/json;q=0", ""},
+		{"*/*;q=0", ""},
+		{"application/json;q=0, application/xml;q=0, application/*;q=0.5", formatMsgpack},
+		{",,,", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.accept, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/timezone/orders", nil)
+			if c.accept != "" {
+				req.Header.Set("Accept", c.accept)
+			}
+			if got := negotiateFormat(req); got != c.want {
+				t.Errorf("negotiateFormat(%q) = %q, 期望 %q", c.accept, got, c.want)
+			}
+		})
+	}
+}
+
+// TestRespondNegotiated 按协商的格式输出；没有支持的格式时返回 406，编码失败时返回 500，都不改用 JSON
+func TestRespondNegotiated(t *testing.T) {
+	list := APIResponse{Success: true, Code: "test.ok", Data: []map[string]interface{}{{"id": 1}}}
+	// XML 不支持 map[string]int
+	unencodable := APIResponse{Success: true, Code: "test.ok", Data: map[string]int{"a": 1}}
+
+	cases := []struct {
+		name        string
+		accept      string
+		response    APIResponse
+		status      int
+		contentType string
+	}{
+		{"JSON", "application/json", list, http.StatusCreated, "application/json"},
+		{"MessagePack", "application/x-msgpack", list, http.StatusCreated, "application/x-msgpack"},
+		{"XML", "application/xml", list, http.StatusCreated, "application/xml; charset=utf-8"},
+		{"不支持的类型", "text/html", list, http.StatusNotAcceptable, "text/plain; charset=utf-8"},
+		{"都是 q=0", "application/json;q=0, */*;q=0", list, http.StatusNotAcceptable, "text/plain; charset=utf-8"},
+		{"XML 编码失败", "application/xml", unencodable, http.StatusInternalServerError, "text/plain; charset=utf-8"},
+		{"XML 编码失败不改用可接受的 JSON", "application/xml, application/json;q=0.5", unencodable, http.StatusInternalServerError, "text/plain; charset=utf-8"},
+		{"JSON 可编码 map", "application/json", unencodable, http.StatusCreated, "application/json"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/timezone/orders", nil)
+			req.Header.Set("Accept", c.accept)
+			rec := httptest.NewRecorder()
+			respondNegotiated(rec, req, http.StatusCreated, c.response)
+			if rec.Code != c.status {
+				t.Errorf("状态码 = %d, 期望 %d: %s", rec.Code, c.status, rec.Body.String())
+			}
+			if got := rec.Header().Get("Content-Type"); got != c.contentType {
+				t.Errorf("Content-Type = %q, 期望 %q", got, c.contentType)
+			}
+			vary := false
+			for _, v := range rec.Header().Values("Vary") {
+				vary = vary || v == "Accept"
+			}
+			if !vary {
+				t.Errorf("Vary = %v, 期望包含 Accept", rec.Header().Values("Vary"))
+			}
+
+			body := rec.Body.Bytes()
+			switch c.contentType {
+			case "application/json":
+				var decoded APIResponse
+				if err := json.Unmarshal(body, &decoded); err != nil || decoded.Code != "test.ok" {
+					t.Errorf("JSON 响应无效: %v %s", err, body)
+				}
+			case "application/x-msgpack":
+				if want, _ := msgpack.Marshal(c.response); string(body) != string(want) {
+					t.Errorf("MessagePack 响应 = %x, 期望 %x", body, want)
+				}
+			case "application/xml; charset=utf-8":
+				if !strings.HasPrefix(string(body), xml.Header+"<response>") || !strings.Contains(string(body), "<data><item><id>1</id></item></data>") {
+					t.Errorf("XML 响应无效: %s", body)
+				}
+			default:
+				if strings.HasPrefix(string(body), "{") || strings.HasPrefix(string(body), "<") {
+					t.Errorf("错误响应应为纯文本: %s", body)
+				}
+			}
+		})
+	}
+}
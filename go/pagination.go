@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"timezone-saas-demo/models"
+)
+
+// pageRequest 列表接口的分页参数，Limit 为 0 表示不分页
+type pageRequest struct {
+	Limit  int
+	Offset int
+}
+
+// parsePage 解析 limit/offset 查询参数，缺省或非法时使用默认值
+func parsePage(r *http.Request, defaultLimit int) pageRequest {
+	page := pageRequest{Limit: defaultLimit}
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		page.Limit = l
+	}
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o >= 0 {
+		page.Offset = o
+	}
+	return page
+}
+
+// newPageMeta 生成分页信息
+// hasMore 表示本页之后还有数据（通常多查一条判断）；estimate 为估算的总数，小于 0 表示未知。
+// 最后一页的总数可以精确得出，其余情况使用估算值，并按已知的上下界修正
+func newPageMeta(r *http.Request, page pageRequest, count int, hasMore bool, estimate int64) models.PageMeta {
+	meta := models.PageMeta{
+		Limit:   page.Limit,
+		Offset:  page.Offset,
+		Count:   count,
+		HasMore: hasMore,
+		Page:    1,
+	}
+
+	seen := int64(page.Offset + count)
+	switch {
+	case !hasMore && (count > 0 || page.Offset == 0):
+		meta.TotalCount, meta.TotalCountExact = seen, true
+	case hasMore:
+		meta.TotalCount = max(estimate, seen+1)
+	default:
+		// offset 已超出末尾，总数不超过 offset
+		meta.TotalCount = min(max(estimate, 0), int64(page.Offset))
+	}
+
+	if page.Limit > 0 {
+		meta.Page = page.Offset/page.Limit + 1
+		meta.PageCount = int((meta.TotalCount + int64(page.Limit) - 1) / int64(page.Limit))
+	} else if meta.TotalCount > 0 {
+		meta.PageCount = 1
+	}
+
+	meta.Links.Self = pageURL(r, page.Limit, page.Offset)
+	meta.Links.First = pageURL(r, page.Limit, 0)
+	if page.Limit > 0 && page.Offset > 0 {
+		meta.Links.Prev = pageURL(r, page.Limit, max(page.Offset-page.Limit, 0))
+	}
+	if hasMore {
+		meta.Links.Next = pageURL(r, page.Limit, page.Offset+count)
+	}
+	return meta
+}
+
+// pageURL 替换原请求的 limit/offset 参数，其他参数保持不变
+func pageURL(r *http.Request, limit, offset int) string {
+	query := r.URL.Query()
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	} else {
+		query.Del("limit")
+	}
+	if offset > 0 {
+		query.Set("offset", strconv.Itoa(offset))
+	} else {
+		query.Del("offset")
+	}
+	if len(query) == 0 {
+		return r.URL.Path
+	}
+	return r.URL.Path + "?" + query.Encode()
+}
+
+// respondPage 输出列表接口的成功响应：附带 meta 分页信息和 Link 响应头
+func respondPage(w http.ResponseWriter, r *http.Request, code string, data interface{}, meta models.PageMeta, args ...interface{}) {
+	var links []string
+	for _, link := range []struct{ rel, url string }{
+		{"first", meta.Links.First},
+		{"prev", meta.Links.Prev},
+		{"next", meta.Links.Next},
+	} {
+		if link.url != "" {
+			links = append(links, fmt.Sprintf("<%s>; rel=%q", link.url, link.rel))
+		}
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	response := APIResponse{
+		Success: true,
+		Code:    code,
+		Message: negotiateLocale(w, r).Message(code, args...),
+		Data:    data,
+		Meta:    &meta,
+	}
+	respondNegotiated(w, r, http.StatusOK, pseudonymizeResponse(w, r, response))
+}
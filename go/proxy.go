@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// clientInfo 经可信代理还原后的客户端地址和请求协议
+type clientInfo struct {
+	// IP 客户端 IP，无法解析时为连接的对端地址
+	IP string
+	// Scheme 客户端访问使用的协议：http 或 https
+	Scheme string
+}
+
+// clientInfoKey clientInfo 在请求上下文中的键
+type clientInfoKey struct{}
+
+// parseTrustedProxies 解析逗号分隔的可信代理地址，支持 CIDR（10.0.0.0/8）和单个 IP
+func parseTrustedProxies(value string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if strings.Contains(part, "/") {
+			prefix, err := netip.ParsePrefix(part)
+			if err != nil {
+				return nil, fmt.Errorf("无效的可信代理网段 %q: %w", part, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(part)
+		if err != nil {
+			return nil, fmt.Errorf("无效的可信代理地址 %q: %w", part, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// isTrustedProxy addr 是否在 trustedProxies 中
+func isTrustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// realClientMiddleware 还原客户端 IP 和协议，供日志、录制和审计使用
+// 只有连接来自 TRUSTED_PROXIES（或 Unix 套接字上的 sidecar）时才读取 X-Forwarded-For、X-Forwarded-Proto，
+// 否则任何客户端都能伪造这两个请求头
+func realClientMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := resolveClient(r)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientInfoKey{}, info)))
+	})
+}
+
+// resolveClient 从右向左跳过 X-Forwarded-For 中的可信代理，第一个不可信的地址即客户端；全部可信时取最左边的地址
+func resolveClient(r *http.Request) clientInfo {
+	info := clientInfo{IP: r.RemoteAddr, Scheme: "http"}
+	if r.TLS != nil {
+		info.Scheme = "https"
+	}
+
+	trusted := false
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		info.IP = host
+		if addr, err := netip.ParseAddr(host); err == nil {
+			trusted = isTrustedProxy(addr)
+		}
+	} else {
+		// Unix 套接字连接没有对端 IP，只有同一主机上的 sidecar 能访问
+		trusted = true
+	}
+	if !trusted {
+		return info
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// 格式错误的地址之前的部分不可信，使用最后一个有效的地址
+			break
+		}
+		info.IP = addr.Unmap().String()
+		if !isTrustedProxy(addr) {
+			break
+		}
+	}
+
+	// 多级代理时最左边的值由最外层（面向客户端）的代理设置
+	proto, _, _ := strings.Cut(r.Header.Get("X-Forwarded-Proto"), ",")
+	switch proto = strings.ToLower(strings.TrimSpace(proto)); proto {
+	case "http", "https":
+		info.Scheme = proto
+	}
+	return info
+}
+
+// clientIP 请求的客户端 IP，未经过 realClientMiddleware 时为连接的对端地址
+func clientIP(r *http.Request) string {
+	if info, ok := r.Context().Value(clientInfoKey{}).(clientInfo); ok {
+		return info.IP
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// requestScheme 客户端访问使用的协议，在 TLS 终止于负载均衡时仍能得到 https
+func requestScheme(r *http.Request) string {
+	if info, ok := r.Context().Value(clientInfoKey{}).(clientInfo); ok {
+		return info.Scheme
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// useTrustedProxies 在测试期间替换可信代理配置
+func useTrustedProxies(t *testing.T, value string) {
+	t.Helper()
+	prefixes, err := parseTrustedProxies(value)
+	if err != nil {
+		t.Fatalf("解析可信代理失败: %v", err)
+	}
+	prev := trustedProxies
+	trustedProxies = prefixes
+	t.Cleanup(func() { trustedProxies = prev })
+}
+
+// TestResolveClient X-Forwarded-For 只在对端可信时采用，从右向左跳过可信代理，伪造的左侧地址和格式错误的值不会被当作客户端
+func TestResolveClient(t *testing.T) {
+	useTrustedProxies(t, "10.0.0.0/8, 192.168.1.1, 2001:db8:1::/48")
+
+	cases := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		proto      string
+		tls        bool
+		wantIP     string
+		wantScheme string
+	}{
+		{name: "直连无请求头", remoteAddr: "203.0.113.7:51000", wantIP: "203.0.113.7", wantScheme: "http"},
+		{name: "直连 TLS", remoteAddr: "203.0.113.7:51000", tls: true, wantIP: "203.0.113.7", wantScheme: "https"},
+		{name: "可信代理无请求头", remoteAddr: "10.0.0.5:443", wantIP: "10.0.0.5", wantScheme: "http"},
+
+		// 不可信的对端
+		{name: "不可信对端伪造 X-Forwarded-For", remoteAddr: "203.0.113.7:51000", forwarded: []string{"1.1.1.1"}, proto: "https", wantIP: "203.0.113.7", wantScheme: "http"},
+		{name: "不可信对端伪造可信地址", remoteAddr: "203.0.113.7:51000", forwarded: []string{"10.0.0.1"}, wantIP: "203.0.113.7", wantScheme: "http"},
+		{name: "与可信单个地址相邻的地址不可信", remoteAddr: "192.168.1.2:80", forwarded: []string{"1.1.1.1"}, wantIP: "192.168.1.2", wantScheme: "http"},
+		{name: "不可信对端 TLS 不被降级", remoteAddr: "203.0.113.7:51000", proto: "http", tls: true, wantIP: "203.0.113.7", wantScheme: "https"},
+
+		// 可信代理
+		{name: "单级代理", remoteAddr: "10.0.0.5:443", forwarded: []string{"198.51.100.9"}, proto: "https", wantIP: "198.51.100.9", wantScheme: "https"},
+		{name: "可信的单个地址", remoteAddr: "192.168.1.1:443", forwarded: []string{"198.51.100.9"}, wantIP: "198.51.100.9", wantScheme: "http"},
+		{name: "伪造的最左侧地址", remoteAddr: "10.0.0.5:443", forwarded: []string{"6.6.6.6, 198.51.100.9"}, wantIP: "198.51.100.9", wantScheme: "http"},
+		{name: "伪造的最左侧可信地址", remoteAddr: "10.0.0.5:443", forwarded: []string{"10.9.9.9, 198.51.100.9"}, wantIP: "198.51.100.9", wantScheme: "http"},
+		{name: "多级可信代理", remoteAddr: "10.0.0.5:443", forwarded: []string{"6.6.6.6, 198.51.100.9, 10.1.0.1, 192.168.1.1"}, wantIP: "198.51.100.9", wantScheme: "http"},
+		{name: "多个请求头按顺序拼接", remoteAddr: "10.0.0.5:443", forwarded: []string{"6.6.6.6", "198.51.100.9, 10.1.0.1"}, wantIP: "198.51.100.9", wantScheme: "http"},
+		{name: "全部可信时取最左侧", remoteAddr: "10.0.0.5:443", forwarded: []string{"10.2.0.1, 10.1.0.1"}, wantIP: "10.2.0.1", wantScheme: "http"},
+		{name: "地址两侧空白", remoteAddr: "10.0.0.5:443", forwarded: []string{"  198.51.100.9 ,10.1.0.1  "}, wantIP: "198.51.100.9", wantScheme: "http"},
+
+		// IPv6
+		{name: "IPv6 不可信对端", remoteAddr: "[2001:db8:2::1]:51000", forwarded: []string{"198.51.100.9"}, wantIP: "2001:db8:2::1", wantScheme: "http"},
+		{name: "IPv6 可信对端", remoteAddr: "[2001:db8:1::10]:443", forwarded: []string{"2001:DB8:FFFF::0001"}, wantIP: "2001:db8:ffff::1", wantScheme: "http"},
+		{name: "IPv6 多级代理", remoteAddr: "[2001:db8:1::10]:443", forwarded: []string{"2001:db8:ffff::1, 2001:db8:1::20, 10.0.0.9"}, wantIP: "2001:db8:ffff::1", wantScheme: "http"},
+		{name: "IPv4 映射地址的可信对端", remoteAddr: "[::ffff:10.0.0.5]:443", forwarded: []string{"198.51.100.9"}, wantIP: "198.51.100.9", wantScheme: "http"},
+		{name: "IPv4 映射地址还原为 IPv4", remoteAddr: "10.0.0.5:443", forwarded: []string{"::ffff:198.51.100.9"}, wantIP: "198.51.100.9", wantScheme: "http"},
+		{name: "IPv4 映射的可信地址被跳过", remoteAddr: "10.0.0.5:443", forwarded: []string{"198.51.100.9, ::ffff:10.1.0.1"}, wantIP: "198.51.100.9", wantScheme: "http"},
+
+		// 格式错误的值：之前的部分不可信，使用最后一个有效的地址
+		{name: "最右侧格式错误", remoteAddr: "10.0.0.5:443", forwarded: []string{"198.51.100.9, unknown"}, wantIP: "10.0.0.5", wantScheme: "http"},
+		{name: "中间格式错误", remoteAddr: "10.0.0.5:443", forwarded: []string{"6.6.6.6, garbage, 10.1.0.1"}, wantIP: "10.1.0.1", wantScheme: "http"},
+		{name: "带端口的地址", remoteAddr: "10.0.0.5:443", forwarded: []string{"198.51.100.9:1234"}, wantIP: "10.0.0.5", wantScheme: "http"},
+		{name: "带方括号的 IPv6", remoteAddr: "10.0.0.5:443", forwarded: []string{"[2001:db8:ffff::1]"}, wantIP: "10.0.0.5", wantScheme: "http"},
+		{name: "空的一项", remoteAddr: "10.0.0.5:443", forwarded: []string{"6.6.6.6,,198.51.100.9"}, wantIP: "198.51.100.9", wantScheme: "http"},
+		{name: "末尾多余的逗号", remoteAddr: "10.0.0.5:443", forwarded: []string{"198.51.100.9,"}, wantIP: "10.0.0.5", wantScheme: "http"},
+		{name: "空请求头", remoteAddr: "10.0.0.5:443", forwarded: []string{""}, wantIP: "10.0.0.5", wantScheme: "http"},
+		{name: "网段不是地址", remoteAddr: "10.0.0.5:443", forwarded: []string{"198.51.100.0/24"}, wantIP: "10.0.0.5", wantScheme: "http"},
+		{name: "超长的值", remoteAddr: "10.0.0.5:443", forwarded: []string{"198.51.100.9999"}, wantIP: "10.0.0.5", wantScheme: "http"},
+
+		// X-Forwarded-Proto
+		{name: "多级代理取最左侧的协议", remoteAddr: "10.0.0.5:443", proto: "HTTPS, http", wantIP: "10.0.0.5", wantScheme: "https"},
+		{name: "不支持的协议被忽略", remoteAddr: "10.0.0.5:443", proto: "ftp", tls: true, wantIP: "10.0.0.5", wantScheme: "https"},
+		{name: "可信代理可声明 http", remoteAddr: "10.0.0.5:443", proto: "http", tls: true, wantIP: "10.0.0.5", wantScheme: "http"},
+
+		// Unix 套接字上的 sidecar
+		{name: "Unix 套接字", remoteAddr: "@", forwarded: []string{"6.6.6.6, 198.51.100.9"}, proto: "https", wantIP: "198.51.100.9", wantScheme: "https"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = c.remoteAddr
+			req.TLS = nil
+			if c.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			for _, v := range c.forwarded {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			if c.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", c.proto)
+			}
+
+			var gotIP, gotScheme string
+			realClientMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotIP, gotScheme = clientIP(r), requestScheme(r)
+			})).ServeHTTP(httptest.NewRecorder(), req)
+			if gotIP != c.wantIP || gotScheme != c.wantScheme {
+				t.Errorf("客户端 = %s %s, 期望 %s %s", gotIP, gotScheme, c.wantIP, c.wantScheme)
+			}
+		})
+	}
+}
+
+// TestResolveClientNoTrustedProxies 未配置 TRUSTED_PROXIES 时任何对端的 X-Forwarded-* 都不被采用
+func TestResolveClientNoTrustedProxies(t *testing.T) {
+	useTrustedProxies(t, "")
+	for _, remoteAddr := range []string{"127.0.0.1:8080", "10.0.0.5:443", "[::1]:8080"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", "198.51.100.9")
+		req.Header.Set("X-Forwarded-Proto", "https")
+		info := resolveClient(req)
+		if info.IP == "198.51.100.9" || info.Scheme != "http" {
+			t.Errorf("对端 %s: 客户端 = %s %s, 不应采用 X-Forwarded-*", remoteAddr, info.IP, info.Scheme)
+		}
+	}
+}
+
+// TestParseTrustedProxies 网段按掩码规范化，单个地址视为 /32 或 /128，格式错误返回错误
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := parseTrustedProxies(" 10.1.2.3/8, ,192.168.1.1,2001:db8::/32,::ffff:172.16.0.1,fe80::1 ")
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	want := []string{"10.0.0.0/8", "192.168.1.1/32", "2001:db8::/32", "172.16.0.1/32", "fe80::1/128"}
+	if len(prefixes) != len(want) {
+		t.Fatalf("网段 = %v, 期望 %v", prefixes, want)
+	}
+	for i, prefix := range prefixes {
+		if prefix.String() != want[i] {
+			t.Errorf("第 %d 项 = %s, 期望 %s", i+1, prefix, want[i])
+		}
+	}
+
+	if prefixes, err := parseTrustedProxies(""); err != nil || len(prefixes) != 0 {
+		t.Errorf("空值 = %v, %v, 期望不信任任何地址", prefixes, err)
+	}
+	for _, value := range []string{"10.0.0.0/33", "10.0.0.1/", "proxy.internal", "10.0.0.256", "10.0.0.1,nope", "[::1]"} {
+		if _, err := parseTrustedProxies(value); err == nil {
+			t.Errorf("parseTrustedProxies(%q) 应返回错误", value)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+// Package pseudonym 演示部署的数据假名化
+//
+// 开启后 API 响应中的商户名称、商户编码和订单号替换为确定性的假名，金额按固定系数缩放，
+// 演示站点可以使用生产规模和分布的数据公开展示。同一个原值在所有响应中得到同一个假名，
+// 订单号保持原有的格式（字母替换为字母、数字替换为数字，分隔符不变）；
+// 所有金额使用同一个系数，汇总与明细、各商户之间的比例保持一致，订单数等计数不变。
+// 每个租户使用由密钥派生的独立密钥（见 Tenant），一个租户从自己数据的原值和假名无法推出其他租户的假名和金额系数。
+package pseudonym
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/shopspring/decimal"
+
+	"timezone-saas-demo/money"
+)
+
+// 假名商户名称的组成部分，组合数远大于演示数据的商户数
+var (
+	namePrefixes = []string{
+		"星河", "青松", "远山", "明月", "云帆", "晨光", "海岳", "银杏", "金桥", "蓝湾",
+		"朗峰", "瑞丰", "嘉禾", "长风", "松柏", "翠湖", "北辰", "南溪", "东篱", "西岭",
+		"锦程", "和光", "拓远", "凌云", "鸿图", "清泉", "晴川", "华岩", "悦森", "启明",
+	}
+	nameIndustries = []string{
+		"科技", "贸易", "电商", "食品", "服饰", "家居", "文化", "物流",
+		"数码", "餐饮", "美妆", "图书", "运动", "旅行", "宠物", "花艺",
+	}
+	nameSuffixes = []string{"有限公司", "股份有限公司", "商行", "工作室", "集团"}
+)
+
+// amountFields 名称不含 amount 的金额字段
+var amountFields = map[string]bool{
+	"by_order_date":         true,
+	"by_refund_date":        true,
+	"refund_by_order_date":  true,
+	"refund_by_refund_date": true,
+}
+
+// DisplayFunc 按响应的语言格式化金额，用于重新生成 *_display 字段
+type DisplayFunc func(amount decimal.Decimal, currency string) string
+
+// Pseudonymizer 按密钥生成假名，创建后只读，可并发使用
+type Pseudonymizer struct {
+	key   []byte
+	scale decimal.Decimal
+	// fixedScale scale 由配置指定，派生的租户假名化器沿用同一个系数
+	fixedScale bool
+}
+
+// New 创建 Pseudonymizer，scale 为金额的缩放系数，不大于 0 时由密钥确定一个 0.5~2 之间的系数，
+// 不公开系数时无法从演示数据还原真实金额
+func New(key []byte, scale float64) *Pseudonymizer {
+	p := &Pseudonymizer{key: key}
+	if scale > 0 {
+		p.scale, p.fixedScale = decimal.NewFromFloat(scale), true
+	} else {
+		p.scale = p.derivedScale()
+	}
+	return p
+}
+
+// derivedScale 由密钥确定的 0.5~2 之间的系数
+func (p *Pseudonymizer) derivedScale() decimal.Decimal {
+	n := binary.BigEndian.Uint16(p.sum("scale"))
+	return decimal.NewFromFloat(0.5 + 1.5*float64(n)/65535).Round(4)
+}
+
+// Tenant 租户使用的假名化器，密钥为 HMAC(key, tenant)：同一个租户和密钥得到相同的假名，
+// 不同租户的同一个原值得到无关的假名；系数未由配置指定时同样按租户派生
+func (p *Pseudonymizer) Tenant(tenant string) *Pseudonymizer {
+	t := &Pseudonymizer{key: p.sum("tenant", tenant), scale: p.scale, fixedScale: p.fixedScale}
+	if !t.fixedScale {
+		t.scale = t.derivedScale()
+	}
+	return t
+}
+
+// Scale 金额的缩放系数
+func (p *Pseudonymizer) Scale() decimal.Decimal {
+	return p.scale
+}
+
+// sum 带用途前缀的 HMAC-SHA256 摘要，不同字段的同一个值得到不同的假名
+func (p *Pseudonymizer) sum(purpose string, values ...string) []byte {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(purpose))
+	for _, value := range values {
+		mac.Write([]byte{0})
+		mac.Write([]byte(value))
+	}
+	return mac.Sum(nil)
+}
+
+// MerchantName 商户名称的假名，如 星河数码有限公司
+func (p *Pseudonymizer) MerchantName(name string) string {
+	if name == "" {
+		return ""
+	}
+	h := p.sum("merchant_name", name)
+	return namePrefixes[int(h[0])%len(namePrefixes)] +
+		nameIndustries[int(h[1])%len(nameIndustries)] +
+		nameSuffixes[int(h[2])%len(nameSuffixes)]
+}
+
+// MerchantCode 商户编码的假名，如 DEMO_3F2A9C0D
+func (p *Pseudonymizer) MerchantCode(code string) string {
+	if code == "" {
+		return ""
+	}
+	return "DEMO_" + strings.ToUpper(hex.EncodeToString(p.sum("merchant_code", code)[:4]))
+}
+
+// OrderNumber 订单号的假名，保持原有的长度和格式：大写字母、小写字母和数字各自替换为同类字符，其余字符不变
+func (p *Pseudonymizer) OrderNumber(number string) string {
+	if number == "" {
+		return ""
+	}
+	var b strings.Builder
+	stream := p.sum("order_number", number)
+	for i, c := range number {
+		// 订单号超过摘要长度时对后续位置再取摘要
+		if i > 0 && i%len(stream) == 0 {
+			stream = p.sum("order_number", number, string(stream))
+		}
+		n := int(stream[i%len(stream)])
+		switch {
+		case c >= '0' && c <= '9':
+			b.WriteByte(byte('0' + n%10))
+		case c >= 'A' && c <= 'Z':
+			b.WriteByte(byte('A' + n%26))
+		case c >= 'a' && c <= 'z':
+			b.WriteByte(byte('a' + n%26))
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// Amount 按系数缩放金额并保留 places 位小数
+func (p *Pseudonymizer) Amount(amount decimal.Decimal, places int32) decimal.Decimal {
+	return amount.Mul(p.scale).Round(places)
+}
+
+// Response 假名化一个响应的字段
+// 使用前先把响应编码为 JSON 再以 UseNumber 解码，处理后的值重新编码即可输出
+type Response struct {
+	p       *Pseudonymizer
+	display DisplayFunc
+	// replaced 原值到假名，用于替换消息和错误文本中的同一个值
+	replaced map[string]string
+}
+
+// Response 创建处理一个响应的 Response，display 为 nil 时 *_display 字段保持原值
+func (p *Pseudonymizer) Response(display DisplayFunc) *Response {
+	return &Response{p: p, display: display, replaced: map[string]string{}}
+}
+
+// JSON 递归处理 encoding/json 解码得到的值，会修改传入的 map 和 slice：
+//   - merchant_name，以及商户对象（含 business_hours_start）中的 name 替换为假名商户名称
+//   - merchant_code，以及商户对象中的 code 替换为假名编码
+//   - order_number、order_no 替换为同格式的假名订单号
+//   - 名称含 amount（不含 percent）的字段及退款合计字段按系数缩放，同一对象有 currency 时按币种小数位舍入，
+//     否则保留原值的小数位；对应的 *_display 字段按缩放后的金额重新格式化
+func (r *Response) JSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		_, merchant := v["business_hours_start"]
+		currency, _ := v["currency"].(string)
+		for key, field := range v {
+			switch {
+			case key == "merchant_name" || merchant && key == "name":
+				v[key] = r.text(field, r.p.MerchantName)
+			case key == "merchant_code" || merchant && key == "code":
+				v[key] = r.text(field, r.p.MerchantCode)
+			case key == "order_number" || key == "order_no":
+				v[key] = r.text(field, r.p.OrderNumber)
+			case isAmount(key):
+				v[key] = r.amount(field, currency)
+			default:
+				v[key] = r.JSON(field)
+			}
+		}
+		if r.display != nil && currency != "" {
+			for key := range v {
+				base, ok := strings.CutSuffix(key, "_display")
+				if !ok || !isAmount(base) {
+					continue
+				}
+				if amount, ok := decimalValue(v[base]); ok {
+					v[key] = r.display(amount, currency)
+				}
+			}
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = r.JSON(item)
+		}
+		return v
+	}
+	return value
+}
+
+// isAmount 字段名称是否为金额
+func isAmount(key string) bool {
+	key = strings.ToLower(key)
+	if strings.HasSuffix(key, "_display") || strings.Contains(key, "percent") {
+		return false
+	}
+	return strings.Contains(key, "amount") || amountFields[key]
+}
+
+// text 替换字符串值并记录原值，其他类型保持不变
+func (r *Response) text(value interface{}, fake func(string) string) interface{} {
+	original, ok := value.(string)
+	if !ok || original == "" {
+		return value
+	}
+	replaced := fake(original)
+	r.replaced[original] = replaced
+	return replaced
+}
+
+// amount 缩放字符串（decimal.Decimal 的 JSON 编码）或数字形式的金额，数组逐项处理，其他类型保持不变
+func (r *Response) amount(value interface{}, currency string) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		for i, item := range v {
+			v[i] = r.amount(item, currency)
+		}
+		return v
+	case map[string]interface{}:
+		return r.JSON(v)
+	}
+	amount, ok := decimalValue(value)
+	if !ok {
+		return value
+	}
+	places := -amount.Exponent()
+	if currency != "" {
+		places = money.Exponent(currency)
+	}
+	if places < 0 {
+		places = 0
+	}
+	scaled := r.p.Amount(amount, places).StringFixed(places)
+	if _, ok := value.(json.Number); ok {
+		return json.Number(scaled)
+	}
+	return scaled
+}
+
+// decimalValue 把字符串或数字形式的金额解析为 decimal
+func decimalValue(value interface{}) (decimal.Decimal, bool) {
+	var text string
+	switch v := value.(type) {
+	case string:
+		text = v
+	case json.Number:
+		text = v.String()
+	default:
+		return decimal.Decimal{}, false
+	}
+	amount, err := decimal.NewFromString(text)
+	return amount, err == nil
+}
+
+// Text 把消息或错误文本中出现过的原值替换为假名，在 JSON 处理之后调用
+func (r *Response) Text(text string) string {
+	if len(r.replaced) == 0 || text == "" {
+		return text
+	}
+	originals := make([]string, 0, len(r.replaced))
+	for original := range r.replaced {
+		originals = append(originals, original)
+	}
+	// 较长的原值优先，避免只替换了其中一部分
+	sort.Slice(originals, func(i, j int) bool {
+		if len(originals[i]) != len(originals[j]) {
+			return len(originals[i]) > len(originals[j])
+		}
+		return originals[i] < originals[j]
+	})
+	pairs := make([]string, 0, 2*len(originals))
+	for _, original := range originals {
+		pairs = append(pairs, original, r.replaced[original])
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
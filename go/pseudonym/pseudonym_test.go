@@ -0,0 +1,211 @@
+package pseudonym_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/shopspring/decimal"
+
+	"timezone-saas-demo/pseudonym"
+)
+
+var testKey = []byte("pseudonym-test-key")
+
+// fingerprint 一个假名化器对一组原值的全部输出
+func fingerprint(p *pseudonym.Pseudonymizer, values ...string) string {
+	var b strings.Builder
+	for _, v := range values {
+		fmt.Fprintf(&b, "%s|%s|%s;", p.MerchantName(v), p.MerchantCode(v), p.OrderNumber(v))
+	}
+	b.WriteString(p.Scale().String())
+	return b.String()
+}
+
+// TestDeterministicPerTenantAndKey 同一个密钥和租户在不同实例中得到相同的假名和系数，换密钥或租户后都不同
+func TestDeterministicPerTenantAndKey(t *testing.T) {
+	values := []string{"东京书店", "TOKYO_BOOKS", "ORD-20240908-0001"}
+	a := fingerprint(pseudonym.New(testKey, 0).Tenant("acme"), values...)
+	if b := fingerprint(pseudonym.New(append([]byte(nil), testKey...), 0).Tenant("acme"), values...); a != b {
+		t.Errorf("同一个密钥和租户的输出不一致:\n%s\n%s", a, b)
+	}
+	p := pseudonym.New(testKey, 0).Tenant("acme")
+	if again := fingerprint(p, values...); a != again {
+		t.Errorf("同一个实例重复调用的输出不一致")
+	}
+
+	others := map[string]*pseudonym.Pseudonymizer{
+		"其他租户":    pseudonym.New(testKey, 0).Tenant("globex"),
+		"租户名大小写":  pseudonym.New(testKey, 0).Tenant("ACME"),
+		"租户名前缀":   pseudonym.New(testKey, 0).Tenant("acm"),
+		"其他密钥":    pseudonym.New([]byte("other-key"), 0).Tenant("acme"),
+		"未区分租户":   pseudonym.New(testKey, 0),
+		"默认租户":    pseudonym.New(testKey, 0).Tenant("default"),
+		"派生两次":    pseudonym.New(testKey, 0).Tenant("acme").Tenant("acme"),
+		"空租户":     pseudonym.New(testKey, 0).Tenant(""),
+		"租户名含分隔符": pseudonym.New(testKey, 0).Tenant("ac\x00me"),
+	}
+	for name, other := range others {
+		code, order := other.MerchantCode("TOKYO_BOOKS"), other.OrderNumber("ORD-20240908-0001")
+		if code == p.MerchantCode("TOKYO_BOOKS") || order == p.OrderNumber("ORD-20240908-0001") {
+			t.Errorf("%s: 假名应与租户 acme 的不同, 得到 %s %s", name, code, order)
+		}
+	}
+}
+
+// TestNotReversibleAcrossTenants 一个租户知道自己数据的原值和假名，也无法得到其他租户的假名或系数：
+// 不同租户的同一个原值得到无关的假名，从租户的假名化器再派生也得不到其他租户的假名化器
+func TestNotReversibleAcrossTenants(t *testing.T) {
+	root := pseudonym.New(testKey, 0)
+	acme, globex := root.Tenant("acme"), root.Tenant("globex")
+
+	// 订单号的每一位在两个租户之间应大体不同（同一位置相同的概率为 1/10 或 1/26）
+	same, total := 0, 0
+	for i := 0; i < 200; i++ {
+		number := fmt.Sprintf("ORD-%08d-%04d", 20240000+i, i)
+		a, g := acme.OrderNumber(number), globex.OrderNumber(number)
+		if a == g {
+			t.Fatalf("订单号 %s 在两个租户的假名相同: %s", number, a)
+		}
+		for j := range a {
+			if unicode.IsLetter(rune(number[j])) || unicode.IsDigit(rune(number[j])) {
+				total++
+				if a[j] == g[j] {
+					same++
+				}
+			}
+		}
+	}
+	if ratio := float64(same) / float64(total); ratio > 0.2 {
+		t.Errorf("两个租户的假名订单号有 %.0f%% 的位置相同, 假名之间不应相关", ratio*100)
+	}
+
+	// 商户名称的组合有限，允许个别相同，但不能整体一致
+	sameNames := 0
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("商户%d", i)
+		if acme.MerchantName(name) == globex.MerchantName(name) {
+			sameNames++
+		}
+	}
+	if sameNames > 5 {
+		t.Errorf("50 个商户名称中有 %d 个在两个租户的假名相同", sameNames)
+	}
+
+	// 从 acme 的假名化器派生 globex 得到的与服务端的 globex 不同
+	if acme.Tenant("globex").MerchantCode("X") == globex.MerchantCode("X") {
+		t.Error("从租户的假名化器不应派生出其他租户的假名化器")
+	}
+
+	// 系数按租户派生：知道自己的系数不能还原其他租户的金额
+	if acme.Scale().Equal(globex.Scale()) && root.Tenant("initech").Scale().Equal(acme.Scale()) {
+		t.Errorf("租户的金额系数应各不相同, 都为 %s", acme.Scale())
+	}
+	for _, tenant := range []string{"acme", "globex", "initech", "default", ""} {
+		scale := root.Tenant(tenant).Scale()
+		if scale.LessThan(decimal.NewFromFloat(0.5)) || scale.GreaterThan(decimal.NewFromInt(2)) {
+			t.Errorf("租户 %q 的系数 %s 不在 0.5~2 之间", tenant, scale)
+		}
+	}
+
+	// 配置了系数时所有租户使用同一个系数
+	fixed := pseudonym.New(testKey, 1.25)
+	for _, tenant := range []string{"acme", "globex"} {
+		if got := fixed.Tenant(tenant).Scale(); !got.Equal(decimal.NewFromFloat(1.25)) {
+			t.Errorf("配置系数 1.25 时租户 %s 的系数 = %s", tenant, got)
+		}
+	}
+}
+
+// TestOrderNumberFormat 假名订单号保持长度和每一位的字符类别，分隔符不变
+func TestOrderNumberFormat(t *testing.T) {
+	p := pseudonym.New(testKey, 0).Tenant("acme")
+	for _, number := range []string{
+		"ORD-20240908-0001",
+		"shop_abc_123",
+		"12345",
+		"A",
+		"#1001/订单",
+		strings.Repeat("AB12-", 20),
+	} {
+		got := p.OrderNumber(number)
+		if got == number {
+			t.Errorf("OrderNumber(%q) 未替换", number)
+		}
+		if len(got) != len(number) {
+			t.Errorf("OrderNumber(%q) = %q, 长度不同", number, got)
+			continue
+		}
+		for i, c := range number {
+			g := rune(got[i])
+			switch {
+			case c >= '0' && c <= '9':
+				if g < '0' || g > '9' {
+					t.Errorf("OrderNumber(%q)[%d] = %q, 应为数字", number, i, g)
+				}
+			case c >= 'A' && c <= 'Z':
+				if g < 'A' || g > 'Z' {
+					t.Errorf("OrderNumber(%q)[%d] = %q, 应为大写字母", number, i, g)
+				}
+			case c >= 'a' && c <= 'z':
+				if g < 'a' || g > 'z' {
+					t.Errorf("OrderNumber(%q)[%d] = %q, 应为小写字母", number, i, g)
+				}
+			default:
+				if !strings.HasPrefix(got[i:], string(c)) {
+					t.Errorf("OrderNumber(%q) 位置 %d 的 %q 应保持不变", number, i, c)
+				}
+			}
+		}
+	}
+	if p.OrderNumber("") != "" || p.MerchantName("") != "" || p.MerchantCode("") != "" {
+		t.Error("空值应保持为空")
+	}
+}
+
+// TestResponseJSON 响应中被替换和保持原值的字段
+func TestResponseJSON(t *testing.T) {
+	p := pseudonym.New(testKey, 2).Tenant("acme")
+	display := func(amount decimal.Decimal, currency string) string { return currency + " " + amount.String() }
+	input := `{
+		"merchant": {"id": 1, "name": "东京书店", "code": "TOKYO_BOOKS", "business_hours_start": "09:00", "timezone": "Asia/Tokyo"},
+		"report": {"name": "日报", "code": "daily"},
+		"orders": [
+			{"order_number": "ORD-1", "merchant_name": "东京书店", "amount": "1000", "amount_display": "¥1,000", "currency": "JPY", "order_count": 3},
+			{"order_no": "A-9", "total_amount": 12.35, "currency": "USD", "amount_percent": 40.5, "by_order_date": ["1.10", "2.20"]}
+		],
+		"refund_amount": "0.125"
+	}`
+	decoder := json.NewDecoder(strings.NewReader(input))
+	decoder.UseNumber()
+	var data interface{}
+	if err := decoder.Decode(&data); err != nil {
+		t.Fatalf("解码失败: %v", err)
+	}
+	r := p.Response(display)
+	got, _ := json.Marshal(r.JSON(data))
+
+	name, code := p.MerchantName("东京书店"), p.MerchantCode("TOKYO_BOOKS")
+	want, _ := json.Marshal(map[string]interface{}{
+		"merchant": map[string]interface{}{"id": 1, "name": name, "code": code, "business_hours_start": "09:00", "timezone": "Asia/Tokyo"},
+		// 不是商户对象的 name、code 保持原值
+		"report": map[string]interface{}{"name": "日报", "code": "daily"},
+		"orders": []interface{}{
+			map[string]interface{}{"order_number": p.OrderNumber("ORD-1"), "merchant_name": name, "amount": "2000", "amount_display": "JPY 2000", "currency": "JPY", "order_count": 3},
+			map[string]interface{}{"order_no": p.OrderNumber("A-9"), "total_amount": json.Number("24.70"), "currency": "USD", "amount_percent": 40.5, "by_order_date": []interface{}{"2.20", "4.40"}},
+		},
+		"refund_amount": "0.250",
+	})
+	if string(got) != string(want) {
+		t.Errorf("JSON = %s\n期望 %s", got, want)
+	}
+
+	if text := r.Text("商户 东京书店（TOKYO_BOOKS）的订单 ORD-1 查询失败"); text != fmt.Sprintf("商户 %s（%s）的订单 %s 查询失败", name, code, p.OrderNumber("ORD-1")) {
+		t.Errorf("Text = %q", text)
+	}
+	if text := p.Response(nil).Text("东京书店"); text != "东京书店" {
+		t.Errorf("未处理 JSON 时 Text 应保持原文, 得到 %q", text)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"timezone-saas-demo/pseudonym"
+	"timezone-saas-demo/services"
+)
+
+// pseudonymizeResponse DEMO_PSEUDONYMIZE 开启时把响应中的商户名称、订单号替换为假名并缩放金额，
+// 消息和错误文本中出现的同一个名称也一并替换；假名按请求的租户（X-Tenant-ID）派生，未开启时原样返回
+// 数据重新编码后对象的字段按名称排序，不再使用 fastjson
+func pseudonymizeResponse(w http.ResponseWriter, r *http.Request, response APIResponse) APIResponse {
+	if pseudonymizer == nil {
+		return response
+	}
+	l := negotiateLocale(w, r)
+	rewrite := pseudonymizer.Tenant(services.TenantFromContext(r.Context())).Response(l.Currency)
+	if response.Data != nil {
+		body, err := json.Marshal(response.Data)
+		var data interface{}
+		if err == nil {
+			decoder := json.NewDecoder(bytes.NewReader(body))
+			decoder.UseNumber()
+			err = decoder.Decode(&data)
+		}
+		if err != nil {
+			// 无法处理时不输出数据，避免泄露原值
+			log.Printf("假名化响应 %s 失败: %v", r.URL.Path, err)
+			response.Data = nil
+		} else {
+			response.Data = rewrite.JSON(data)
+		}
+	}
+	response.Message = rewrite.Text(response.Message)
+	response.Error = rewrite.Text(response.Error)
+	return response
+}
+
+// newPseudonymizer 按 DEMO_* 配置创建假名化器，未配置 DEMO_PSEUDONYM_KEY 时每次启动随机生成密钥
+func newPseudonymizer(config *AppConfig) (*pseudonym.Pseudonymizer, error) {
+	key := []byte(config.DemoPseudonymKey)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("生成假名化密钥失败: %w", err)
+		}
+		log.Printf("⚠️ 未设置 DEMO_PSEUDONYM_KEY，假名和金额系数在重启后会变化")
+	}
+	log.Printf("🎭 演示模式：响应中的商户名称和订单号已假名化，金额按固定系数缩放")
+	return pseudonym.New(key, config.DemoAmountScale), nil
+}
@@ -0,0 +1,338 @@
+// Package redact 日志、请求录制和审计记录中的敏感信息脱敏
+//
+// 按字段名称查找策略：订单号部分遮盖，API 密钥和令牌取 HMAC 摘要（同一个值得到同一个摘要，便于关联），
+// 商户联系方式删除，密码等替换为占位符。同一套策略用于 JSON、请求头、查询参数和日志文本，
+// 日志因此可以交给第三方日志平台保存。
+package redact
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Action 字段的脱敏方式
+type Action string
+
+const (
+	// Keep 保持原值
+	Keep Action = "keep"
+	// Mask 只保留末尾 maskKeep 个字符，其余替换为 *
+	Mask Action = "mask"
+	// Hash 替换为 HMAC-SHA256 摘要的前 12 位十六进制，如 hash:3f2a9c0d71be
+	Hash Action = "hash"
+	// Strip 删除字段；文本中无法删除，替换为占位符
+	Strip Action = "strip"
+	// Redact 替换为占位符
+	Redact Action = "redact"
+)
+
+// Placeholder 替换敏感信息的占位符
+const Placeholder = "[REDACTED]"
+
+// maskKeep Mask 保留的末尾字符数
+const maskKeep = 4
+
+// Policy 字段名称到脱敏方式的映射，名称不区分大小写，- 与 _ 视为相同
+// 名称没有完全匹配时按包含关系匹配（如 X-Shopify-Hmac-Signature 匹配 signature），较长的名称优先
+type Policy map[string]Action
+
+// DefaultPolicy 默认策略
+func DefaultPolicy() Policy {
+	return Policy{
+		"order_number":  Mask,
+		"api_key":       Hash,
+		"apikey":        Hash,
+		"authorization": Hash,
+		"contact_email": Strip,
+		"contact_name":  Strip,
+		"contact_phone": Strip,
+		"email":         Strip,
+		"emails":        Strip,
+		"phone":         Strip,
+		"password":      Redact,
+		"passwd":        Redact,
+		"secret":        Redact,
+		"token":         Redact,
+		"cookie":        Redact,
+		"signature":     Redact,
+		"credential":    Redact,
+	}
+}
+
+// ParsePolicy 解析 name=action 的逗号分隔列表（如 order_number=hash,notes=strip）并覆盖 base 中的同名字段
+func ParsePolicy(value string, base Policy) (Policy, error) {
+	policy := make(Policy, len(base))
+	for name, action := range base {
+		policy[normalize(name)] = action
+	}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, action, ok := strings.Cut(part, "=")
+		name = normalize(strings.TrimSpace(name))
+		if !ok || name == "" {
+			return nil, fmt.Errorf("脱敏策略应为 字段=方式: %q", part)
+		}
+		switch a := Action(strings.ToLower(strings.TrimSpace(action))); a {
+		case Keep, Mask, Hash, Strip, Redact:
+			policy[name] = a
+		default:
+			return nil, fmt.Errorf("字段 %s 的脱敏方式 %q 无效，可选 keep、mask、hash、strip、redact", name, action)
+		}
+	}
+	return policy, nil
+}
+
+// Redactor 按策略脱敏，创建后只读，可并发使用
+type Redactor struct {
+	policy Policy
+	key    []byte
+	// names 按长度倒序的字段名称，用于包含关系匹配
+	names []string
+	// fieldPattern 文本中 name=value、name: value、"name":"value"、name = 'value' 形式的敏感字段
+	fieldPattern *regexp.Regexp
+}
+
+// emailPattern 文本中的邮箱地址
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+// authPattern 文本中的 Authorization 凭证
+var authPattern = regexp.MustCompile(`(?i)\b(Bearer|Basic)\s+[A-Za-z0-9._~+/=-]+`)
+
+// New 创建 Redactor，key 为 Hash 使用的 HMAC 密钥，为空时摘要仍不可逆，但可以被穷举低熵的值
+func New(policy Policy, key []byte) *Redactor {
+	r := &Redactor{policy: make(Policy, len(policy)), key: key}
+	var sensitive []string
+	for name, action := range policy {
+		name = normalize(name)
+		r.policy[name] = action
+		r.names = append(r.names, name)
+		if action != Keep {
+			sensitive = append(sensitive, regexp.QuoteMeta(name))
+		}
+	}
+	sort.Slice(r.names, func(i, j int) bool {
+		if len(r.names[i]) != len(r.names[j]) {
+			return len(r.names[i]) > len(r.names[j])
+		}
+		return r.names[i] < r.names[j]
+	})
+	if len(sensitive) > 0 {
+		sort.Slice(sensitive, func(i, j int) bool { return len(sensitive[i]) > len(sensitive[j]) })
+		// 名称中的 _ 同时匹配 -
+		names := strings.ReplaceAll(strings.Join(sensitive, "|"), "_", "[_-]")
+		// 名称可以带前后缀（如 csrf_token、X-Api-Key），与 Action 的包含关系匹配一致
+		r.fieldPattern = regexp.MustCompile(`(?i)("?\b[\w-]*(?:` + names + `)[\w-]*"?\s*[:=]\s*['"]?)([^\s"',&;)]+)`)
+	}
+	return r
+}
+
+// normalize 名称统一为小写，- 替换为 _
+func normalize(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "-", "_")
+}
+
+// Action 字段名称对应的脱敏方式，不在策略中时为 Keep
+func (r *Redactor) Action(name string) Action {
+	name = normalize(name)
+	if action, ok := r.policy[name]; ok {
+		return action
+	}
+	for _, candidate := range r.names {
+		if strings.Contains(name, candidate) {
+			return r.policy[candidate]
+		}
+	}
+	return Keep
+}
+
+// Value 按字段名称脱敏一个值，返回 false 表示应删除该字段
+func (r *Redactor) Value(name, value string) (string, bool) {
+	action := r.Action(name)
+	if action == Strip {
+		return "", false
+	}
+	return r.apply(action, value), true
+}
+
+// Field 按字段名称脱敏一个值，用于无法删除字段的位置（如日志文本），Strip 替换为占位符
+func (r *Redactor) Field(name, value string) string {
+	return r.apply(r.Action(name), value)
+}
+
+// apply 对值执行脱敏；Strip 在无法删除的位置等同于 Redact
+func (r *Redactor) apply(action Action, value string) string {
+	switch action {
+	case Keep:
+		return value
+	case Mask:
+		return mask(value)
+	case Hash:
+		return r.hash(value)
+	}
+	return Placeholder
+}
+
+// mask 只保留末尾 maskKeep 个字符，不足时全部遮盖
+func mask(value string) string {
+	n := utf8.RuneCountInString(value)
+	if n <= maskKeep {
+		return strings.Repeat("*", n)
+	}
+	runes := []rune(value)
+	return strings.Repeat("*", n-maskKeep) + string(runes[n-maskKeep:])
+}
+
+// hash 值的 HMAC-SHA256 摘要前 12 位
+func (r *Redactor) hash(value string) string {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(value))
+	return "hash:" + hex.EncodeToString(mac.Sum(nil))[:12]
+}
+
+// JSON 递归脱敏 encoding/json 解码得到的值：按字段名称处理，Strip 的字段被删除，其他字符串中的邮箱按 email 的策略处理
+// 会修改传入的 map 和 slice
+func (r *Redactor) JSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			switch action := r.Action(key); action {
+			case Keep:
+				v[key] = r.JSON(field)
+			case Strip:
+				delete(v, key)
+			default:
+				v[key] = r.jsonValue(action, field)
+			}
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = r.JSON(item)
+		}
+		return v
+	case string:
+		return r.emails(v)
+	}
+	return value
+}
+
+// jsonValue 对敏感字段的值脱敏：字符串和数字按方式处理，数组逐项处理，对象整体替换为占位符
+func (r *Redactor) jsonValue(action Action, value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return r.apply(action, v)
+	case []interface{}:
+		for i, item := range v {
+			v[i] = r.jsonValue(action, item)
+		}
+		return v
+	case nil:
+		return nil
+	case fmt.Stringer:
+		// json.Number
+		return r.apply(action, v.String())
+	}
+	return Placeholder
+}
+
+// emails 替换文本中的邮箱地址
+func (r *Redactor) emails(text string) string {
+	action := r.Action("email")
+	if action == Keep {
+		return text
+	}
+	return emailPattern.ReplaceAllStringFunc(text, func(email string) string { return r.apply(action, email) })
+}
+
+// Text 脱敏自由文本（日志行、错误消息、SQL）：邮箱、Authorization 凭证，以及 name=value 等形式的敏感字段
+func (r *Redactor) Text(text string) string {
+	text = r.emails(text)
+	if action := r.Action("authorization"); action != Keep {
+		text = authPattern.ReplaceAllStringFunc(text, func(credential string) string { return r.apply(action, credential) })
+	}
+	if r.fieldPattern == nil {
+		return text
+	}
+	return r.fieldPattern.ReplaceAllStringFunc(text, func(match string) string {
+		m := r.fieldPattern.FindStringSubmatch(match)
+		prefix, value := m[1], m[2]
+		if value == Placeholder || strings.HasPrefix(value, "hash:") {
+			return match
+		}
+		name := strings.Trim(strings.TrimRight(prefix, " :='\""), "\"")
+		return prefix + r.apply(r.Action(name), value)
+	})
+}
+
+// Writer 按行脱敏后写入 w，用于 log.SetOutput；log 包每条日志一次 Write，不完整的行会先缓存到换行为止
+func (r *Redactor) Writer(w io.Writer) io.Writer {
+	return &lineWriter{r: r, w: w}
+}
+
+// lineWriter 按行脱敏的 io.Writer
+type lineWriter struct {
+	r *Redactor
+	w io.Writer
+
+	mu      sync.Mutex
+	pending []byte
+}
+
+// Write 脱敏完整的行，返回值按输入的字节数计算
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	lw.pending = append(lw.pending, p...)
+	end := bytes.LastIndexByte(lw.pending, '\n')
+	if end < 0 {
+		return len(p), nil
+	}
+	lines := string(lw.pending[:end+1])
+	lw.pending = append(lw.pending[:0], lw.pending[end+1:]...)
+	if _, err := io.WriteString(lw.w, lw.r.Text(lines)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// current 进程使用的 Redactor，启动时按配置替换
+var current atomic.Pointer[Redactor]
+
+func init() {
+	current.Store(New(DefaultPolicy(), nil))
+}
+
+// Current 当前的 Redactor
+func Current() *Redactor {
+	return current.Load()
+}
+
+// SetCurrent 替换当前的 Redactor，应在开始处理请求前调用
+func SetCurrent(r *Redactor) {
+	current.Store(r)
+}
+
+// Inline 把用户输入中的换行等控制字符替换为空格，写入日志和审计时避免伪造出额外的日志行
+func Inline(value string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, value)
+}
@@ -0,0 +1,253 @@
+package redact_test
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"timezone-saas-demo/redact"
+)
+
+var testKey = []byte("redact-test-key")
+
+// hashOf 与 Hash 方式相同的摘要
+func hashOf(value string) string {
+	mac := hmac.New(sha256.New, testKey)
+	mac.Write([]byte(value))
+	return "hash:" + hex.EncodeToString(mac.Sum(nil))[:12]
+}
+
+// TestAction 字段名称不区分大小写，- 与 _ 相同，没有完全匹配时按包含关系匹配，不在策略中的字段保持原值
+func TestAction(t *testing.T) {
+	r := redact.New(redact.DefaultPolicy(), testKey)
+	cases := []struct {
+		name string
+		want redact.Action
+	}{
+		{"order_number", redact.Mask},
+		{"Order-Number", redact.Mask},
+		{"parent_order_number", redact.Mask},
+		{"api_key", redact.Hash},
+		{"X-Api-Key", redact.Hash},
+		{"X-APIKEY", redact.Hash},
+		{"Authorization", redact.Hash},
+		{"Proxy-Authorization", redact.Hash},
+		{"contact_email", redact.Strip},
+		{"emails", redact.Strip},
+		{"billing_email", redact.Strip},
+		{"contact_phone", redact.Strip},
+		{"password", redact.Redact},
+		{"csrf_token", redact.Redact},
+		{"X-CSRF-Token", redact.Redact},
+		{"refresh_token", redact.Redact},
+		{"webhook_secret", redact.Redact},
+		{"X-Shopify-Hmac-Signature", redact.Redact},
+		{"Cookie", redact.Redact},
+		{"Set-Cookie", redact.Redact},
+
+		{"merchant_id", redact.Keep},
+		{"merchant_name", redact.Keep},
+		{"timezone", redact.Keep},
+		{"amount", redact.Keep},
+		{"local_date", redact.Keep},
+		{"order_id", redact.Keep},
+		{"Accept-Language", redact.Keep},
+		{"", redact.Keep},
+	}
+	for _, c := range cases {
+		if got := r.Action(c.name); got != c.want {
+			t.Errorf("Action(%q) = %s, 期望 %s", c.name, got, c.want)
+		}
+	}
+}
+
+// TestValue 各脱敏方式的输出
+func TestValue(t *testing.T) {
+	r := redact.New(redact.DefaultPolicy(), testKey)
+	cases := []struct {
+		name, value string
+		want        string
+		kept        bool
+	}{
+		{"order_number", "ORD-20240908-1234", "*************1234", true},
+		{"order_number", "1234", "****", true},
+		{"order_number", "12", "**", true},
+		{"order_number", "", "", true},
+		{"order_number", "订单编号一二三四五", "*****二三四五", true},
+		{"X-Api-Key", "sv_live_abc", hashOf("sv_live_abc"), true},
+		{"authorization", "Bearer abc", hashOf("Bearer abc"), true},
+		{"password", "hunter2", redact.Placeholder, true},
+		{"token", "", redact.Placeholder, true},
+		{"contact_email", "ops@example.com", "", false},
+		{"merchant_name", "东京书店", "东京书店", true},
+		{"notes", "ops@example.com", "ops@example.com", true},
+	}
+	for _, c := range cases {
+		got, kept := r.Value(c.name, c.value)
+		if got != c.want || kept != c.kept {
+			t.Errorf("Value(%q, %q) = %q, %v, 期望 %q, %v", c.name, c.value, got, kept, c.want, c.kept)
+		}
+	}
+
+	// 无法删除字段的位置 Strip 替换为占位符
+	if got := r.Field("contact_email", "ops@example.com"); got != redact.Placeholder {
+		t.Errorf("Field(contact_email) = %q, 期望 %q", got, redact.Placeholder)
+	}
+
+	// 同一个值得到同一个摘要，不同的值或密钥得到不同的摘要
+	if r.Field("api_key", "a") != r.Field("X-API-Key", "a") || r.Field("api_key", "a") == r.Field("api_key", "b") {
+		t.Error("摘要应只由值和密钥决定")
+	}
+	if r.Field("api_key", "a") == redact.New(redact.DefaultPolicy(), []byte("other")).Field("api_key", "a") {
+		t.Error("不同密钥的摘要应不同")
+	}
+}
+
+// TestJSON 敏感字段按方式处理或删除，其他字段原样保留，普通字符串中的邮箱被替换
+func TestJSON(t *testing.T) {
+	r := redact.New(redact.DefaultPolicy(), testKey)
+	input := `{
+		"merchant_id": 42,
+		"merchant_name": "东京书店",
+		"active": true,
+		"timezone": "Asia/Tokyo",
+		"order_number": "ORD-20240908-1234",
+		"contact_email": "ops@example.com",
+		"contact_phone": "+81-3-0000-0000",
+		"api_key": "sv_live_abc",
+		"credentials": {"user": "u", "password": "p"},
+		"notes": "请联系 ops@example.com 或 sales@example.co.jp",
+		"orders": [
+			{"order_number": 20240908, "amount": "12.50", "local_date": "2024-09-08"},
+			{"order_number": null, "tags": ["vip", "x@example.com"]}
+		],
+		"token": ["a", "b"],
+		"nested": {"csrf_token": "t", "X-Api-Key": "k", "empty": {}}
+	}`
+	decoder := json.NewDecoder(strings.NewReader(input))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		t.Fatalf("解码失败: %v", err)
+	}
+	got, err := json.Marshal(r.JSON(value))
+	if err != nil {
+		t.Fatalf("编码失败: %v", err)
+	}
+
+	want, _ := json.Marshal(map[string]interface{}{
+		"merchant_id":   42,
+		"merchant_name": "东京书店",
+		"active":        true,
+		"timezone":      "Asia/Tokyo",
+		"order_number":  "*************1234",
+		"api_key":       hashOf("sv_live_abc"),
+		"credentials":   redact.Placeholder,
+		"notes":         "请联系 [REDACTED] 或 [REDACTED]",
+		"orders": []interface{}{
+			map[string]interface{}{"order_number": "****0908", "amount": "12.50", "local_date": "2024-09-08"},
+			map[string]interface{}{"order_number": nil, "tags": []interface{}{"vip", redact.Placeholder}},
+		},
+		"token":  []interface{}{redact.Placeholder, redact.Placeholder},
+		"nested": map[string]interface{}{"csrf_token": redact.Placeholder, "X-Api-Key": hashOf("k"), "empty": map[string]interface{}{}},
+	})
+	if !bytes.Equal(got, want) {
+		t.Errorf("JSON 脱敏 = %s\n期望 %s", got, want)
+	}
+}
+
+// TestText 日志文本中的邮箱、Authorization 凭证和 name=value 等形式的敏感字段；其他内容原样保留，重复脱敏结果不变
+func TestText(t *testing.T) {
+	r := redact.New(redact.DefaultPolicy(), testKey)
+	cases := []struct {
+		text, want string
+	}{
+		{"password=hunter2 user=bob", "password=[REDACTED] user=bob"},
+		{"login password: 'hunter2'", "login password: '[REDACTED]'"},
+		{`{"token":"abc","merchant_id":42}`, `{"token":"[REDACTED]","merchant_id":42}`},
+		{"csrf_token = xyz; path=/", "csrf_token = [REDACTED]; path=/"},
+		{"GET /api/orders?order_number=ORD-20240908-1234&limit=5", "GET /api/orders?order_number=*************1234&limit=5"},
+		{"X-Api-Key: sv_live_abc", "X-Api-Key: " + hashOf("sv_live_abc")},
+		{"Authorization: Bearer abc.def", "Authorization: " + hashOf("Bearer abc.def")},
+		{"调用失败 bearer abc123", "调用失败 " + hashOf("bearer abc123")},
+		{"发送到 ops@example.com 失败", "发送到 [REDACTED] 失败"},
+		{"contact_email=ops@example.com", "contact_email=[REDACTED]"},
+		{"X-Shopify-Hmac-Signature: c2ln", "X-Shopify-Hmac-Signature: [REDACTED]"},
+
+		{"merchant_id=42 timezone=Asia/Tokyo date=2024-09-08", "merchant_id=42 timezone=Asia/Tokyo date=2024-09-08"},
+		{"查询 12 个商户耗时 35ms", "查询 12 个商户耗时 35ms"},
+		{"", ""},
+	}
+	for _, c := range cases {
+		got := r.Text(c.text)
+		if got != c.want {
+			t.Errorf("Text(%q) = %q, 期望 %q", c.text, got, c.want)
+		}
+		if again := r.Text(got); again != got {
+			t.Errorf("Text(%q) 重复脱敏 = %q, 期望不变", got, again)
+		}
+	}
+}
+
+// TestParsePolicy 覆盖默认策略中的同名字段，名称按 normalize 处理，格式错误返回错误
+func TestParsePolicy(t *testing.T) {
+	policy, err := redact.ParsePolicy(" Order-Number=HASH, notes=strip ,email=keep,", redact.DefaultPolicy())
+	if err != nil {
+		t.Fatalf("ParsePolicy 失败: %v", err)
+	}
+	r := redact.New(policy, testKey)
+	cases := []struct {
+		name string
+		want redact.Action
+	}{
+		{"order_number", redact.Hash},
+		{"notes", redact.Strip},
+		{"email", redact.Keep},
+		{"contact_email", redact.Strip},
+		{"password", redact.Redact},
+	}
+	for _, c := range cases {
+		if got := r.Action(c.name); got != c.want {
+			t.Errorf("Action(%q) = %s, 期望 %s", c.name, got, c.want)
+		}
+	}
+	// email=keep 时文本中的邮箱不再替换
+	if got := r.Text("发送到 ops@example.com"); got != "发送到 ops@example.com" {
+		t.Errorf("Text = %q, 期望保留邮箱", got)
+	}
+	// 不修改 base
+	if redact.DefaultPolicy()["order_number"] != redact.Mask {
+		t.Error("ParsePolicy 不应修改默认策略")
+	}
+
+	for _, value := range []string{"order_number", "=mask", "order_number=encrypt", "a=mask,b"} {
+		if _, err := redact.ParsePolicy(value, redact.DefaultPolicy()); err == nil {
+			t.Errorf("ParsePolicy(%q) 应返回错误", value)
+		}
+	}
+}
+
+// TestWriter 按行脱敏，不完整的行缓存到换行为止
+func TestWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := redact.New(redact.DefaultPolicy(), testKey).Writer(&out)
+	for _, p := range []string{"token=ab", "c ok\nmerchant_id=1", "\n"} {
+		if n, err := w.Write([]byte(p)); n != len(p) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v", p, n, err)
+		}
+	}
+	if got, want := out.String(), "token=[REDACTED] ok\nmerchant_id=1\n"; got != want {
+		t.Errorf("输出 = %q, 期望 %q", got, want)
+	}
+}
+
+// TestInline 控制字符替换为空格，不能伪造出额外的日志行
+func TestInline(t *testing.T) {
+	if got, want := redact.Inline("a\nb\r\tc\x00d 东京"), "a b  c d 东京"; got != want {
+		t.Errorf("Inline = %q, 期望 %q", got, want)
+	}
+}
@@ -0,0 +1,245 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// ClickHouse 镜像表由 services.ClickHouseMirror 维护，表名为 orders_analysis
+// 金额以字符串返回并解码为 decimal.Decimal，避免经 JSON 数值转换损失精度
+
+// ClickHouseAnalysisRepository 基于 ClickHouse 镜像表的分析仓储
+type ClickHouseAnalysisRepository struct {
+	ch *database.ClickHouse
+}
+
+// NewClickHouseAnalysisRepository 创建 ClickHouse 分析仓储
+func NewClickHouseAnalysisRepository(ch *database.ClickHouse) *ClickHouseAnalysisRepository {
+	return &ClickHouseAnalysisRepository{ch: ch}
+}
+
+// Name 返回存储后端名称
+func (r *ClickHouseAnalysisRepository) Name() string {
+	return "clickhouse"
+}
+
+// OrderSummary 按币种分组获取订单汇总
+func (r *ClickHouseAnalysisRepository) OrderSummary(ctx context.Context, filter models.AnalysisFilter) ([]models.CurrencyTotal, error) {
+	query := fmt.Sprintf(`
+		SELECT
+			currency,
+			count() AS order_count,
+			toString(sum(amount)) AS gross_amount
+		FROM %s
+		WHERE local_date = {date:Date}
+			AND (empty({statuses:Array(String)}) OR has({statuses:Array(String)}, status))
+		GROUP BY currency
+		ORDER BY currency
+	`, analysisSource(filter))
+
+	var result []models.CurrencyTotal
+	if err := r.ch.QueryContext(ctx, query, analysisParams(filter), &result); err != nil {
+		return nil, fmt.Errorf("查询订单汇总失败: %w", err)
+	}
+
+	return result, nil
+}
+
+// HourlyBreakdown 获取按小时分解的数据
+func (r *ClickHouseAnalysisRepository) HourlyBreakdown(ctx context.Context, filter models.AnalysisFilter) ([]models.HourlyOrderBreakdown, error) {
+	query := fmt.Sprintf(`
+		SELECT
+			toInt32(local_hour) AS hour,
+			count() AS order_count,
+			if(uniqExact(currency) = 1, any(currency), '') AS currency,
+			toString(sum(amount)) AS total_amount,
+			toString(avg(amount)) AS avg_amount
+		FROM %s
+		WHERE local_date = {date:Date}
+			AND (empty({statuses:Array(String)}) OR has({statuses:Array(String)}, status))
+		GROUP BY local_hour
+		ORDER BY local_hour
+	`, analysisSource(filter))
+
+	var result []models.HourlyOrderBreakdown
+	if err := r.ch.QueryContext(ctx, query, analysisParams(filter), &result); err != nil {
+		return nil, fmt.Errorf("查询小时分解数据失败: %w", err)
+	}
+
+	return result, nil
+}
+
+// TimezoneStats 获取时区统计
+func (r *ClickHouseAnalysisRepository) TimezoneStats(ctx context.Context, filter models.AnalysisFilter) ([]models.TimezoneOrderStats, error) {
+	query := fmt.Sprintf(`
+		SELECT
+			timezone,
+			country,
+			count() AS order_count,
+			if(uniqExact(currency) = 1, any(currency), '') AS currency,
+			toString(sum(amount)) AS total_amount,
+			toString(avg(amount)) AS avg_amount
+		FROM %s
+		WHERE local_date = {date:Date}
+			AND (empty({statuses:Array(String)}) OR has({statuses:Array(String)}, status))
+		GROUP BY timezone, country
+		ORDER BY sum(amount) DESC, timezone, country
+	`, analysisSource(filter))
+
+	var result []models.TimezoneOrderStats
+	if err := r.ch.QueryContext(ctx, query, analysisParams(filter), &result); err != nil {
+		return nil, fmt.Errorf("查询时区统计失败: %w", err)
+	}
+
+	return result, nil
+}
+
+// TopMerchants 获取顶级商户
+func (r *ClickHouseAnalysisRepository) TopMerchants(ctx context.Context, filter models.AnalysisFilter, limit int) ([]models.MerchantOrderStats, error) {
+	query := fmt.Sprintf(`
+		SELECT
+			toInt64(merchant_id) AS merchant_id,
+			any(merchant_name) AS merchant_name,
+			any(timezone) AS timezone,
+			count() AS order_count,
+			if(uniqExact(currency) = 1, any(currency), '') AS currency,
+			toString(sum(amount)) AS total_amount,
+			toString(avg(amount)) AS avg_amount
+		FROM %s
+		WHERE local_date = {date:Date}
+			AND (empty({statuses:Array(String)}) OR has({statuses:Array(String)}, status))
+		GROUP BY merchant_id
+		ORDER BY sum(amount) DESC, merchant_id
+		LIMIT {limit:UInt32}
+	`, analysisSource(filter))
+
+	params := analysisParams(filter)
+	params["limit"] = strconv.Itoa(limit)
+	var result []models.MerchantOrderStats
+	if err := r.ch.QueryContext(ctx, query, params, &result); err != nil {
+		return nil, fmt.Errorf("查询顶级商户失败: %w", err)
+	}
+
+	return result, nil
+}
+
+// WeeklyMerchantOrders 获取各商户按本地周的订单数，local_date 为商户本地日期
+func (r *ClickHouseAnalysisRepository) WeeklyMerchantOrders(ctx context.Context, filter models.CohortFilter) ([]models.MerchantWeeklyOrders, error) {
+	query := `
+		SELECT
+			toInt64(merchant_id) AS merchant_id,
+			toString(subtractDays(local_date, (toDayOfWeek(local_date) - {week_start:Int32} + 7) % 7)) AS week_start,
+			count() AS order_count
+		FROM orders_analysis FINAL
+		WHERE local_date >= {from:Date} AND local_date < {to:Date}
+			AND (empty({statuses:Array(String)}) OR has({statuses:Array(String)}, status))
+		GROUP BY merchant_id, week_start
+		ORDER BY merchant_id, week_start
+	`
+
+	params := analysisParams(models.AnalysisFilter{Statuses: filter.Statuses})
+	params["from"], params["to"] = filter.From, filter.To
+	params["week_start"] = strconv.Itoa(filter.WeekStart)
+	var result []models.MerchantWeeklyOrders
+	if err := r.ch.QueryContext(ctx, query, params, &result); err != nil {
+		return nil, fmt.Errorf("查询商户周订单数失败: %w", err)
+	}
+
+	return result, nil
+}
+
+// UTCHourlyOrders 按 UTC 整点、时区和是否营业时间统计订单数
+func (r *ClickHouseAnalysisRepository) UTCHourlyOrders(ctx context.Context, filter models.UTCHourFilter) ([]models.UTCHourTimezoneOrders, error) {
+	query := `
+		SELECT
+			formatDateTime(toStartOfHour(order_time_utc), '%Y-%m-%dT%H:00:00Z') AS hour_start,
+			timezone,
+			is_business_hour,
+			count() AS order_count
+		FROM orders_analysis FINAL
+		WHERE order_time_utc >= {start:DateTime64(6, 'UTC')} AND order_time_utc < {end:DateTime64(6, 'UTC')}
+			AND (empty({statuses:Array(String)}) OR has({statuses:Array(String)}, status))
+		GROUP BY hour_start, timezone, is_business_hour
+		ORDER BY hour_start, timezone, is_business_hour
+	`
+
+	params := analysisParams(models.AnalysisFilter{Statuses: filter.Statuses})
+	params["start"] = filter.Start.UTC().Format("2006-01-02 15:04:05.000000")
+	params["end"] = filter.End.UTC().Format("2006-01-02 15:04:05.000000")
+	var result []models.UTCHourTimezoneOrders
+	if err := r.ch.QueryContext(ctx, query, params, &result); err != nil {
+		return nil, fmt.Errorf("查询 UTC 小时订单数失败: %w", err)
+	}
+
+	return result, nil
+}
+
+// MerchantHourlyOrders 按本地日期和小时统计一个商户的订单数
+func (r *ClickHouseAnalysisRepository) MerchantHourlyOrders(ctx context.Context, filter models.MerchantHourFilter) ([]models.LocalDateHourOrders, error) {
+	query := `
+		SELECT
+			toString(local_date) AS local_date,
+			toInt32(local_hour) AS local_hour,
+			count() AS order_count
+		FROM orders_analysis FINAL
+		WHERE merchant_id = {merchant_id:UInt32}
+			AND local_date >= {from:Date} AND local_date < {to:Date}
+			AND (empty({statuses:Array(String)}) OR has({statuses:Array(String)}, status))
+		GROUP BY local_date, local_hour
+		ORDER BY local_date, local_hour
+	`
+
+	params := analysisParams(models.AnalysisFilter{Statuses: filter.Statuses})
+	params["merchant_id"] = strconv.Itoa(filter.MerchantID)
+	params["from"], params["to"] = filter.From, filter.To
+	var result []models.LocalDateHourOrders
+	if err := r.ch.QueryContext(ctx, query, params, &result); err != nil {
+		return nil, fmt.Errorf("查询商户 %d 小时订单数失败: %w", filter.MerchantID, err)
+	}
+
+	return result, nil
+}
+
+// analysisSource 单项分析查询的数据来源：默认为 orders_analysis；
+// filter.AggregateTimezone 非空时改为按该时区由 order_time_utc 重新计算 local_date 和 local_hour 的子查询，
+// 时区和时间范围由 analysisParams 的 aggregate_tz、start、end 参数提供
+func analysisSource(filter models.AnalysisFilter) string {
+	if filter.AggregateTimezone == "" {
+		return "orders_analysis FINAL"
+	}
+	window := ""
+	if !filter.Start.IsZero() && !filter.End.IsZero() {
+		window = "WHERE order_time_utc >= {start:DateTime64(6, 'UTC')} AND order_time_utc < {end:DateTime64(6, 'UTC')}"
+	}
+	return `(
+			SELECT
+				merchant_id, merchant_name, timezone, country, currency, status, amount,
+				toDate(order_time_utc, {aggregate_tz:String}) AS local_date,
+				toHour(toTimeZone(order_time_utc, {aggregate_tz:String})) AS local_hour
+			FROM orders_analysis FINAL
+			` + window + `
+		)`
+}
+
+// analysisParams 分析查询的公共参数，状态列表编码为 ClickHouse 数组字面量
+func analysisParams(filter models.AnalysisFilter) map[string]string {
+	quoted := make([]string, len(filter.Statuses))
+	for i, status := range filter.Statuses {
+		quoted[i] = "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(status) + "'"
+	}
+	params := map[string]string{
+		"date":     filter.LocalDate,
+		"statuses": "[" + strings.Join(quoted, ",") + "]",
+	}
+	if filter.AggregateTimezone != "" {
+		params["aggregate_tz"] = filter.AggregateTimezone
+		params["start"] = filter.Start.UTC().Format("2006-01-02 15:04:05.000000")
+		params["end"] = filter.End.UTC().Format("2006-01-02 15:04:05.000000")
+	}
+	return params
+}
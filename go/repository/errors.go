@@ -0,0 +1,12 @@
+package repository
+
+import "errors"
+
+// ErrNotFound 查询的记录不存在
+var ErrNotFound = errors.New("资源不存在")
+
+// ErrConflict 写入的记录与已有记录冲突（如唯一键重复）
+var ErrConflict = errors.New("资源已存在")
+
+// ErrInvalidQuery 控制台语句本身有误（语法错误、权限不足、超时、试图写入等），而不是数据库不可用
+var ErrInvalidQuery = errors.New("查询语句执行失败")
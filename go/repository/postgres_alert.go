@@ -0,0 +1,286 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// alertRuleColumns alert_rule 的查询列，与 scanAlertRule 的顺序一致
+const alertRuleColumns = `
+	rule_id, tenant, name, merchant_id, rule_type, window_hours, threshold_percent, currency,
+	statuses, webhook_url, emails, enabled, state, last_evaluated_at, created_by, created_at, updated_at`
+
+// alertEventColumns alert_event 的查询列，与 scanAlertEvent 的顺序一致
+const alertEventColumns = `
+	event_id, rule_id, tenant, merchant_id, state, title, text, timezone, local_time,
+	details, deliveries, occurred_at`
+
+// PostgresAlertRepository 基于 alert_rule / alert_event 表的告警仓储，订单汇总来自 dws_orders_analysis_view
+type PostgresAlertRepository struct {
+	db *database.DB
+}
+
+// NewPostgresAlertRepository 创建 PostgreSQL 告警仓储
+func NewPostgresAlertRepository(db *database.DB) *PostgresAlertRepository {
+	return &PostgresAlertRepository{db: db}
+}
+
+// CreateRule 写入告警规则，初始状态为 ok
+func (r *PostgresAlertRepository) CreateRule(ctx context.Context, rule *models.AlertRule) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO alert_rule (
+			tenant, name, merchant_id, rule_type, window_hours, threshold_percent, currency,
+			statuses, webhook_url, emails, enabled, created_by
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING rule_id, state, created_at, updated_at
+	`, rule.Tenant, rule.Name, rule.MerchantID, rule.Type, rule.WindowHours, rule.ThresholdPercent, rule.Currency,
+		pq.Array(rule.Statuses), rule.WebhookURL, pq.Array(rule.Emails), rule.Enabled, rule.CreatedBy,
+	).Scan(&rule.ID, &rule.State, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return alertRuleError(err, rule)
+	}
+	rule.CreatedAt, rule.UpdatedAt = rule.CreatedAt.UTC(), rule.UpdatedAt.UTC()
+	return nil
+}
+
+// UpdateRule 覆盖告警规则的配置，写回状态、检查时间、创建人和时间
+func (r *PostgresAlertRepository) UpdateRule(ctx context.Context, rule *models.AlertRule) error {
+	var lastEvaluatedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE alert_rule SET
+			name = $3, merchant_id = $4, rule_type = $5, window_hours = $6, threshold_percent = $7,
+			currency = $8, statuses = $9, webhook_url = $10, emails = $11, enabled = $12
+		WHERE rule_id = $1 AND tenant = $2
+		RETURNING state, last_evaluated_at, created_by, created_at, updated_at
+	`, rule.ID, rule.Tenant, rule.Name, rule.MerchantID, rule.Type, rule.WindowHours, rule.ThresholdPercent,
+		rule.Currency, pq.Array(rule.Statuses), rule.WebhookURL, pq.Array(rule.Emails), rule.Enabled,
+	).Scan(&rule.State, &lastEvaluatedAt, &rule.CreatedBy, &rule.CreatedAt, &rule.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: 告警规则 %d", ErrNotFound, rule.ID)
+	}
+	if err != nil {
+		return alertRuleError(err, rule)
+	}
+	if lastEvaluatedAt.Valid {
+		t := lastEvaluatedAt.Time.UTC()
+		rule.LastEvaluatedAt = &t
+	}
+	rule.CreatedAt, rule.UpdatedAt = rule.CreatedAt.UTC(), rule.UpdatedAt.UTC()
+	return nil
+}
+
+// alertRuleError 名称重复映射为 ErrConflict，商户不存在映射为 ErrNotFound
+func alertRuleError(err error, rule *models.AlertRule) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case uniqueViolation:
+			return fmt.Errorf("%w: 告警规则名称 %s", ErrConflict, rule.Name)
+		case foreignKeyViolation:
+			return fmt.Errorf("%w: 商户 %d", ErrNotFound, rule.MerchantID)
+		}
+	}
+	return fmt.Errorf("保存告警规则失败: %w", err)
+}
+
+// DeleteRule 删除告警规则，告警历史随外键级联删除
+func (r *PostgresAlertRepository) DeleteRule(ctx context.Context, tenant string, id int) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM alert_rule WHERE rule_id = $1 AND tenant = $2`, id, tenant)
+	if err != nil {
+		return fmt.Errorf("删除告警规则 %d 失败: %w", id, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: 告警规则 %d", ErrNotFound, id)
+	}
+	return nil
+}
+
+// Rules 租户的全部告警规则
+func (r *PostgresAlertRepository) Rules(ctx context.Context, tenant string) ([]models.AlertRule, error) {
+	return r.queryRules(ctx, `SELECT `+alertRuleColumns+` FROM alert_rule WHERE tenant = $1 ORDER BY rule_id`, tenant)
+}
+
+// Rule 租户的单个告警规则
+func (r *PostgresAlertRepository) Rule(ctx context.Context, tenant string, id int) (*models.AlertRule, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+alertRuleColumns+` FROM alert_rule WHERE rule_id = $1 AND tenant = $2`, id, tenant)
+	rule, err := scanAlertRule(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: 告警规则 %d", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// EnabledRules 所有租户已启用的告警规则
+func (r *PostgresAlertRepository) EnabledRules(ctx context.Context) ([]models.AlertRule, error) {
+	return r.queryRules(ctx, `SELECT `+alertRuleColumns+` FROM alert_rule WHERE enabled ORDER BY rule_id`)
+}
+
+// queryRules 执行查询并扫描告警规则
+func (r *PostgresAlertRepository) queryRules(ctx context.Context, query string, args ...interface{}) ([]models.AlertRule, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询告警规则失败: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []models.AlertRule
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历告警规则失败: %w", err)
+	}
+	return rules, nil
+}
+
+// scanAlertRule 扫描一条告警规则，单行查询没有结果时原样返回 sql.ErrNoRows
+func scanAlertRule(row interface{ Scan(...interface{}) error }) (models.AlertRule, error) {
+	var rule models.AlertRule
+	var statuses, emails pq.StringArray
+	var lastEvaluatedAt sql.NullTime
+	err := row.Scan(&rule.ID, &rule.Tenant, &rule.Name, &rule.MerchantID, &rule.Type, &rule.WindowHours,
+		&rule.ThresholdPercent, &rule.Currency, &statuses, &rule.WebhookURL, &emails, &rule.Enabled, &rule.State,
+		&lastEvaluatedAt, &rule.CreatedBy, &rule.CreatedAt, &rule.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return rule, err
+	}
+	if err != nil {
+		return rule, fmt.Errorf("扫描告警规则失败: %w", err)
+	}
+	rule.Statuses, rule.Emails = []string(statuses), []string(emails)
+	if rule.Statuses == nil {
+		rule.Statuses = []string{}
+	}
+	if rule.Emails == nil {
+		rule.Emails = []string{}
+	}
+	if lastEvaluatedAt.Valid {
+		t := lastEvaluatedAt.Time.UTC()
+		rule.LastEvaluatedAt = &t
+	}
+	rule.CreatedAt, rule.UpdatedAt = rule.CreatedAt.UTC(), rule.UpdatedAt.UTC()
+	return rule, nil
+}
+
+// TransitionState 以 state 作为乐观锁，多个实例同时检查时只有一个实例发送状态变化的通知
+func (r *PostgresAlertRepository) TransitionState(ctx context.Context, id int, from, to string, at time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE alert_rule SET state = $3, last_evaluated_at = $4
+		WHERE rule_id = $1 AND state = $2
+	`, id, from, to, at)
+	if err != nil {
+		return false, fmt.Errorf("更新告警规则 %d 的状态失败: %w", id, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("更新告警规则 %d 的状态失败: %w", id, err)
+	}
+	return n == 1, nil
+}
+
+// CreateEvent 写入告警历史
+func (r *PostgresAlertRepository) CreateEvent(ctx context.Context, event *models.AlertEvent) error {
+	deliveries, err := json.Marshal(event.Deliveries)
+	if err != nil {
+		return fmt.Errorf("序列化告警发送结果失败: %w", err)
+	}
+	var details interface{}
+	if len(event.Details) > 0 {
+		details = []byte(event.Details)
+	}
+	err = r.db.QueryRowContext(ctx, `
+		INSERT INTO alert_event (
+			rule_id, tenant, merchant_id, state, title, text, timezone, local_time, details, deliveries, occurred_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING event_id
+	`, event.RuleID, event.Tenant, event.MerchantID, event.State, event.Title, event.Text,
+		event.Timezone, event.LocalTime, details, deliveries, event.OccurredAt,
+	).Scan(&event.EventID)
+	if err != nil {
+		return fmt.Errorf("写入告警历史失败: %w", err)
+	}
+	return nil
+}
+
+// Events 租户最近的告警历史
+func (r *PostgresAlertRepository) Events(ctx context.Context, tenant string, ruleID, limit int) ([]models.AlertEvent, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+alertEventColumns+`
+		FROM alert_event
+		WHERE tenant = $1 AND ($2 = 0 OR rule_id = $2)
+		ORDER BY occurred_at DESC, event_id DESC
+		LIMIT $3
+	`, tenant, ruleID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询告警历史失败: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.AlertEvent
+	for rows.Next() {
+		var event models.AlertEvent
+		var details, deliveries []byte
+		if err := rows.Scan(&event.EventID, &event.RuleID, &event.Tenant, &event.MerchantID, &event.State,
+			&event.Title, &event.Text, &event.Timezone, &event.LocalTime, &details, &deliveries, &event.OccurredAt); err != nil {
+			return nil, fmt.Errorf("扫描告警历史失败: %w", err)
+		}
+		if len(details) > 0 {
+			event.Details = json.RawMessage(details)
+		}
+		if err := json.Unmarshal(deliveries, &event.Deliveries); err != nil {
+			return nil, fmt.Errorf("解析告警 %d 的发送结果失败: %w", event.EventID, err)
+		}
+		event.OccurredAt = event.OccurredAt.UTC()
+		events = append(events, event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历告警历史失败: %w", err)
+	}
+	return events, nil
+}
+
+// MerchantTotals 按 UTC 下单时间区间汇总商户的订单，用于检查告警规则
+func (r *PostgresAlertRepository) MerchantTotals(ctx context.Context, merchantID int, start, end time.Time, statuses []string) ([]models.CurrencyTotal, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT currency, COUNT(*), COALESCE(SUM(amount), 0)
+		FROM dws_orders_analysis_view
+		WHERE merchant_id = $1
+			AND order_time_utc >= $2 AND order_time_utc < $3
+			AND (COALESCE(cardinality($4::text[]), 0) = 0 OR status = ANY($4::text[]))
+		GROUP BY currency
+		ORDER BY currency
+	`, merchantID, start, end, pq.Array(statuses))
+	if err != nil {
+		return nil, fmt.Errorf("查询商户 %d 订单汇总失败: %w", merchantID, err)
+	}
+	defer rows.Close()
+
+	var totals []models.CurrencyTotal
+	for rows.Next() {
+		var t models.CurrencyTotal
+		if err := rows.Scan(&t.Currency, &t.OrderCount, &t.GrossAmount); err != nil {
+			return nil, fmt.Errorf("扫描商户订单汇总失败: %w", err)
+		}
+		totals = append(totals, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历商户订单汇总失败: %w", err)
+	}
+	return totals, nil
+}
@@ -0,0 +1,685 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// PostgresAnalysisRepository 基于 dws_orders_analysis_view 视图的分析仓储
+// 分析查询默认读取 agg_orders_hourly_all 汇总（在线订单和已归档订单），汇总不存在（未执行迁移）时回退为扫描视图
+// 扫描视图时查不到已归档的订单
+type PostgresAnalysisRepository struct {
+	db *database.DB
+	// rollup 是否读取汇总表
+	rollup bool
+	// rollupMissing 汇总表不存在，之后的查询直接扫描视图
+	rollupMissing atomic.Bool
+}
+
+// NewPostgresAnalysisRepository 创建 PostgreSQL 分析仓储
+func NewPostgresAnalysisRepository(db *database.DB) *PostgresAnalysisRepository {
+	return &PostgresAnalysisRepository{db: db, rollup: true}
+}
+
+// SetRollup 设置分析查询是否读取汇总表，false 时总是扫描视图
+func (r *PostgresAnalysisRepository) SetRollup(enabled bool) {
+	r.rollup = enabled
+}
+
+// useRollup 本次查询是否读取汇总表
+func (r *PostgresAnalysisRepository) useRollup() bool {
+	return r.rollup && !r.rollupMissing.Load()
+}
+
+// rollupUnavailable 汇总表查询失败是否因为表不存在，是则记录下来，调用方改为扫描视图
+func (r *PostgresAnalysisRepository) rollupUnavailable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "42P01" {
+		return false
+	}
+	if r.rollupMissing.CompareAndSwap(false, true) {
+		log.Printf("⚠️ 汇总表 agg_orders_hourly_all 不存在，分析查询改为扫描分析视图，请执行 migrate: %v", err)
+	}
+	return true
+}
+
+// Name 返回存储后端名称
+func (r *PostgresAnalysisRepository) Name() string {
+	return "postgres"
+}
+
+// OrderSummary 按币种分组获取订单汇总，优先读取汇总表
+func (r *PostgresAnalysisRepository) OrderSummary(ctx context.Context, filter models.AnalysisFilter) ([]models.CurrencyTotal, error) {
+	if r.useRollup() && filter.AggregateTimezone == "" {
+		query := `
+			SELECT
+				currency,
+				SUM(order_count) as order_count,
+				COALESCE(SUM(amount_sum), 0) as gross_amount
+			FROM agg_orders_hourly_all
+			WHERE local_date = $1
+				AND order_count > 0
+				AND (COALESCE(cardinality($2::text[]), 0) = 0 OR status = ANY($2::text[]))
+			GROUP BY currency
+			ORDER BY currency
+		`
+		result, err := r.orderSummary(ctx, query, filter)
+		if !r.rollupUnavailable(err) {
+			return result, err
+		}
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			currency,
+			COUNT(*) as order_count,
+			COALESCE(SUM(amount), 0) as gross_amount
+		FROM %s
+		WHERE local_date = $1
+			AND (COALESCE(cardinality($2::text[]), 0) = 0 OR status = ANY($2::text[]))
+		GROUP BY currency
+		ORDER BY currency
+	`, analysisView(filter))
+	return r.orderSummary(ctx, query, filter)
+}
+
+// orderSummary 执行订单汇总查询，视图和汇总表的查询返回相同的列
+func (r *PostgresAnalysisRepository) orderSummary(ctx context.Context, query string, filter models.AnalysisFilter) ([]models.CurrencyTotal, error) {
+	rows, err := r.db.QueryContext(ctx, query, filter.LocalDate, pq.Array(filter.Statuses))
+	if err != nil {
+		return nil, fmt.Errorf("查询订单汇总失败: %w", err)
+	}
+	defer rows.Close()
+
+	var result []models.CurrencyTotal
+	for rows.Next() {
+		var total models.CurrencyTotal
+		if err := rows.Scan(&total.Currency, &total.OrderCount, &total.GrossAmount); err != nil {
+			return nil, fmt.Errorf("扫描订单汇总失败: %w", err)
+		}
+		result = append(result, total)
+	}
+
+	return result, rows.Err()
+}
+
+// HourlyBreakdown 获取按小时分解的数据，优先读取汇总表
+func (r *PostgresAnalysisRepository) HourlyBreakdown(ctx context.Context, filter models.AnalysisFilter) ([]models.HourlyOrderBreakdown, error) {
+	if r.useRollup() && filter.AggregateTimezone == "" {
+		// 平均金额按合计除以订单数计算，与 AVG 的结果（包括小数位数）相同
+		query := `
+			SELECT
+				local_hour,
+				SUM(order_count) as order_count,
+				CASE WHEN COUNT(DISTINCT currency) = 1 THEN MIN(currency) ELSE '' END as currency,
+				COALESCE(SUM(amount_sum), 0) as total_amount,
+				COALESCE(SUM(amount_sum) / NULLIF(SUM(order_count), 0), 0) as avg_amount
+			FROM agg_orders_hourly_all
+			WHERE local_date = $1
+				AND order_count > 0
+				AND (COALESCE(cardinality($2::text[]), 0) = 0 OR status = ANY($2::text[]))
+			GROUP BY local_hour
+			ORDER BY local_hour
+		`
+		result, err := r.hourlyBreakdown(ctx, query, filter)
+		if !r.rollupUnavailable(err) {
+			return result, err
+		}
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			local_hour,
+			COUNT(*) as order_count,
+			CASE WHEN COUNT(DISTINCT currency) = 1 THEN MIN(currency) ELSE '' END as currency,
+			COALESCE(SUM(amount), 0) as total_amount,
+			COALESCE(AVG(amount), 0) as avg_amount
+		FROM %s
+		WHERE local_date = $1
+			AND (COALESCE(cardinality($2::text[]), 0) = 0 OR status = ANY($2::text[]))
+		GROUP BY local_hour
+		ORDER BY local_hour
+	`, analysisView(filter))
+	return r.hourlyBreakdown(ctx, query, filter)
+}
+
+// hourlyBreakdown 执行小时分解查询，视图和汇总表的查询返回相同的列
+func (r *PostgresAnalysisRepository) hourlyBreakdown(ctx context.Context, query string, filter models.AnalysisFilter) ([]models.HourlyOrderBreakdown, error) {
+	rows, err := r.db.QueryContext(ctx, query, filter.LocalDate, pq.Array(filter.Statuses))
+	if err != nil {
+		return nil, fmt.Errorf("查询小时分解数据失败: %w", err)
+	}
+	defer rows.Close()
+
+	var result []models.HourlyOrderBreakdown
+	for rows.Next() {
+		var breakdown models.HourlyOrderBreakdown
+		err := rows.Scan(
+			&breakdown.Hour,
+			&breakdown.OrderCount,
+			&breakdown.Currency,
+			&breakdown.TotalAmount,
+			&breakdown.AvgAmount,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("扫描小时分解数据失败: %w", err)
+		}
+		result = append(result, breakdown)
+	}
+
+	return result, rows.Err()
+}
+
+// TimezoneStats 获取时区统计，优先读取汇总表
+// 汇总表不保存时区和国家，按商户当前的时区和国家分组，与视图一致
+func (r *PostgresAnalysisRepository) TimezoneStats(ctx context.Context, filter models.AnalysisFilter) ([]models.TimezoneOrderStats, error) {
+	if r.useRollup() && filter.AggregateTimezone == "" {
+		query := `
+			SELECT
+				m.timezone,
+				m.country,
+				SUM(a.order_count) as order_count,
+				CASE WHEN COUNT(DISTINCT a.currency) = 1 THEN MIN(a.currency) ELSE '' END as currency,
+				COALESCE(SUM(a.amount_sum), 0) as total_amount,
+				COALESCE(SUM(a.amount_sum) / NULLIF(SUM(a.order_count), 0), 0) as avg_amount
+			FROM agg_orders_hourly_all a
+			JOIN dim_merchant m ON m.merchant_id = a.merchant_id
+			WHERE a.local_date = $1
+				AND a.order_count > 0
+				AND (COALESCE(cardinality($2::text[]), 0) = 0 OR a.status = ANY($2::text[]))
+			GROUP BY m.timezone, m.country
+			ORDER BY total_amount DESC, m.timezone, m.country
+		`
+		result, err := r.timezoneStats(ctx, query, filter)
+		if !r.rollupUnavailable(err) {
+			return result, err
+		}
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			timezone,
+			country,
+			COUNT(*) as order_count,
+			CASE WHEN COUNT(DISTINCT currency) = 1 THEN MIN(currency) ELSE '' END as currency,
+			COALESCE(SUM(amount), 0) as total_amount,
+			COALESCE(AVG(amount), 0) as avg_amount
+		FROM %s
+		WHERE local_date = $1
+			AND (COALESCE(cardinality($2::text[]), 0) = 0 OR status = ANY($2::text[]))
+		GROUP BY timezone, country
+		ORDER BY total_amount DESC, timezone, country
+	`, analysisView(filter))
+	return r.timezoneStats(ctx, query, filter)
+}
+
+// timezoneStats 执行时区统计查询，视图和汇总表的查询返回相同的列
+func (r *PostgresAnalysisRepository) timezoneStats(ctx context.Context, query string, filter models.AnalysisFilter) ([]models.TimezoneOrderStats, error) {
+	rows, err := r.db.QueryContext(ctx, query, filter.LocalDate, pq.Array(filter.Statuses))
+	if err != nil {
+		return nil, fmt.Errorf("查询时区统计失败: %w", err)
+	}
+	defer rows.Close()
+
+	var result []models.TimezoneOrderStats
+	for rows.Next() {
+		var stats models.TimezoneOrderStats
+		err := rows.Scan(
+			&stats.Timezone,
+			&stats.Country,
+			&stats.OrderCount,
+			&stats.Currency,
+			&stats.TotalAmount,
+			&stats.AvgAmount,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("扫描时区统计数据失败: %w", err)
+		}
+		result = append(result, stats)
+	}
+
+	return result, rows.Err()
+}
+
+// TopMerchants 获取顶级商户，优先读取汇总表
+// 汇总表不保存商户名称和时区，按商户当前的名称和时区返回，与视图一致
+func (r *PostgresAnalysisRepository) TopMerchants(ctx context.Context, filter models.AnalysisFilter, limit int) ([]models.MerchantOrderStats, error) {
+	if r.useRollup() && filter.AggregateTimezone == "" {
+		query := `
+			SELECT
+				a.merchant_id,
+				m.merchant_name,
+				m.timezone,
+				SUM(a.order_count) as order_count,
+				CASE WHEN COUNT(DISTINCT a.currency) = 1 THEN MIN(a.currency) ELSE '' END as currency,
+				COALESCE(SUM(a.amount_sum), 0) as total_amount,
+				COALESCE(SUM(a.amount_sum) / NULLIF(SUM(a.order_count), 0), 0) as avg_amount
+			FROM agg_orders_hourly_all a
+			JOIN dim_merchant m ON m.merchant_id = a.merchant_id
+			WHERE a.local_date = $1
+				AND a.order_count > 0
+				AND (COALESCE(cardinality($2::text[]), 0) = 0 OR a.status = ANY($2::text[]))
+			GROUP BY a.merchant_id, m.merchant_name, m.timezone
+			ORDER BY total_amount DESC, a.merchant_id
+			LIMIT $3
+		`
+		result, err := r.topMerchants(ctx, query, filter, limit)
+		if !r.rollupUnavailable(err) {
+			return result, err
+		}
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			merchant_id,
+			merchant_name,
+			timezone,
+			COUNT(*) as order_count,
+			CASE WHEN COUNT(DISTINCT currency) = 1 THEN MIN(currency) ELSE '' END as currency,
+			COALESCE(SUM(amount), 0) as total_amount,
+			COALESCE(AVG(amount), 0) as avg_amount
+		FROM %s
+		WHERE local_date = $1
+			AND (COALESCE(cardinality($2::text[]), 0) = 0 OR status = ANY($2::text[]))
+		GROUP BY merchant_id, merchant_name, timezone
+		ORDER BY total_amount DESC, merchant_id
+		LIMIT $3
+	`, analysisView(filter))
+	return r.topMerchants(ctx, query, filter, limit)
+}
+
+// topMerchants 执行商户排行查询，视图和汇总表的查询返回相同的列
+func (r *PostgresAnalysisRepository) topMerchants(ctx context.Context, query string, filter models.AnalysisFilter, limit int) ([]models.MerchantOrderStats, error) {
+	rows, err := r.db.QueryContext(ctx, query, filter.LocalDate, pq.Array(filter.Statuses), limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询顶级商户失败: %w", err)
+	}
+	defer rows.Close()
+
+	var result []models.MerchantOrderStats
+	for rows.Next() {
+		var merchant models.MerchantOrderStats
+		err := rows.Scan(
+			&merchant.MerchantID,
+			&merchant.MerchantName,
+			&merchant.Timezone,
+			&merchant.OrderCount,
+			&merchant.Currency,
+			&merchant.TotalAmount,
+			&merchant.AvgAmount,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("扫描顶级商户数据失败: %w", err)
+		}
+		result = append(result, merchant)
+	}
+
+	return result, rows.Err()
+}
+
+// WeeklyMerchantOrders 获取各商户按本地周的订单数，优先读取汇总表
+// local_date 已是商户本地日期，周起始日为 local_date 减去距 filter.WeekStart 的天数
+func (r *PostgresAnalysisRepository) WeeklyMerchantOrders(ctx context.Context, filter models.CohortFilter) ([]models.MerchantWeeklyOrders, error) {
+	if r.useRollup() {
+		query := `
+			SELECT
+				merchant_id,
+				to_char(local_date - (EXTRACT(ISODOW FROM local_date)::int - $3 + 7) % 7, 'YYYY-MM-DD') as week_start,
+				SUM(order_count) as order_count
+			FROM agg_orders_hourly_all
+			WHERE local_date >= $1::date AND local_date < $2::date
+				AND order_count > 0
+				AND (COALESCE(cardinality($4::text[]), 0) = 0 OR status = ANY($4::text[]))
+			GROUP BY 1, 2
+			ORDER BY 1, 2
+		`
+		result, err := r.weeklyMerchantOrders(ctx, query, filter)
+		if !r.rollupUnavailable(err) {
+			return result, err
+		}
+	}
+
+	query := `
+		SELECT
+			merchant_id,
+			to_char(local_date - (EXTRACT(ISODOW FROM local_date)::int - $3 + 7) % 7, 'YYYY-MM-DD') as week_start,
+			COUNT(*) as order_count
+		FROM dws_orders_analysis_view
+		WHERE local_date >= $1::date AND local_date < $2::date
+			AND (COALESCE(cardinality($4::text[]), 0) = 0 OR status = ANY($4::text[]))
+		GROUP BY 1, 2
+		ORDER BY 1, 2
+	`
+	return r.weeklyMerchantOrders(ctx, query, filter)
+}
+
+// weeklyMerchantOrders 执行商户周订单数查询，视图和汇总表的查询返回相同的列
+func (r *PostgresAnalysisRepository) weeklyMerchantOrders(ctx context.Context, query string, filter models.CohortFilter) ([]models.MerchantWeeklyOrders, error) {
+	rows, err := r.db.QueryContext(ctx, query, filter.From, filter.To, filter.WeekStart, pq.Array(filter.Statuses))
+	if err != nil {
+		return nil, fmt.Errorf("查询商户周订单数失败: %w", err)
+	}
+	defer rows.Close()
+
+	var result []models.MerchantWeeklyOrders
+	for rows.Next() {
+		var w models.MerchantWeeklyOrders
+		if err := rows.Scan(&w.MerchantID, &w.WeekStart, &w.OrderCount); err != nil {
+			return nil, fmt.Errorf("扫描商户周订单数失败: %w", err)
+		}
+		result = append(result, w)
+	}
+
+	return result, rows.Err()
+}
+
+// UTCHourlyOrders 按 UTC 整点、时区和是否营业时间统计订单数
+// 汇总表按本地日期和小时汇总、不区分营业时间，因此总是扫描视图（走 order_time_utc 索引），查不到已归档的订单
+func (r *PostgresAnalysisRepository) UTCHourlyOrders(ctx context.Context, filter models.UTCHourFilter) ([]models.UTCHourTimezoneOrders, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT
+			date_trunc('hour', order_time_utc) as hour_start,
+			timezone,
+			is_business_hour,
+			COUNT(*) as order_count
+		FROM dws_orders_analysis_view
+		WHERE order_time_utc >= $1 AND order_time_utc < $2
+			AND (COALESCE(cardinality($3::text[]), 0) = 0 OR status = ANY($3::text[]))
+		GROUP BY 1, 2, 3
+		ORDER BY 1, 2, 3
+	`, filter.Start, filter.End, pq.Array(filter.Statuses))
+	if err != nil {
+		return nil, fmt.Errorf("查询 UTC 小时订单数失败: %w", err)
+	}
+	defer rows.Close()
+
+	var result []models.UTCHourTimezoneOrders
+	for rows.Next() {
+		var h models.UTCHourTimezoneOrders
+		if err := rows.Scan(&h.HourStart, &h.Timezone, &h.IsBusinessHour, &h.OrderCount); err != nil {
+			return nil, fmt.Errorf("扫描 UTC 小时订单数失败: %w", err)
+		}
+		h.HourStart = h.HourStart.UTC()
+		result = append(result, h)
+	}
+
+	return result, rows.Err()
+}
+
+// MerchantHourlyOrders 按本地日期和小时统计一个商户的订单数，优先读取汇总表
+func (r *PostgresAnalysisRepository) MerchantHourlyOrders(ctx context.Context, filter models.MerchantHourFilter) ([]models.LocalDateHourOrders, error) {
+	if r.useRollup() {
+		query := `
+			SELECT to_char(local_date, 'YYYY-MM-DD'), local_hour, SUM(order_count) as order_count
+			FROM agg_orders_hourly_all
+			WHERE merchant_id = $1 AND local_date >= $2::date AND local_date < $3::date
+				AND order_count > 0
+				AND (COALESCE(cardinality($4::text[]), 0) = 0 OR status = ANY($4::text[]))
+			GROUP BY local_date, local_hour
+			ORDER BY local_date, local_hour
+		`
+		result, err := r.merchantHourlyOrders(ctx, query, filter)
+		if !r.rollupUnavailable(err) {
+			return result, err
+		}
+	}
+
+	query := `
+		SELECT to_char(local_date, 'YYYY-MM-DD'), local_hour, COUNT(*) as order_count
+		FROM dws_orders_analysis_view
+		WHERE merchant_id = $1 AND local_date >= $2::date AND local_date < $3::date
+			AND (COALESCE(cardinality($4::text[]), 0) = 0 OR status = ANY($4::text[]))
+		GROUP BY local_date, local_hour
+		ORDER BY local_date, local_hour
+	`
+	return r.merchantHourlyOrders(ctx, query, filter)
+}
+
+// merchantHourlyOrders 执行商户小时订单数查询，视图和汇总表的查询返回相同的列
+func (r *PostgresAnalysisRepository) merchantHourlyOrders(ctx context.Context, query string, filter models.MerchantHourFilter) ([]models.LocalDateHourOrders, error) {
+	rows, err := r.db.QueryContext(ctx, query, filter.MerchantID, filter.From, filter.To, pq.Array(filter.Statuses))
+	if err != nil {
+		return nil, fmt.Errorf("查询商户 %d 小时订单数失败: %w", filter.MerchantID, err)
+	}
+	defer rows.Close()
+
+	var result []models.LocalDateHourOrders
+	for rows.Next() {
+		var h models.LocalDateHourOrders
+		if err := rows.Scan(&h.LocalDate, &h.LocalHour, &h.OrderCount); err != nil {
+			return nil, fmt.Errorf("扫描商户小时订单数失败: %w", err)
+		}
+		result = append(result, h)
+	}
+
+	return result, rows.Err()
+}
+
+// MerchantFulfillmentDurations 统计一个商户已发货订单的履约秒数，发货时间只保存在 dws_orders 中
+func (r *PostgresAnalysisRepository) MerchantFulfillmentDurations(ctx context.Context, filter models.MerchantHourFilter) ([]models.OrderFulfillment, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT v.local_hour, v.local_day_of_week, EXTRACT(EPOCH FROM (o.shipped_time_utc - o.order_time_utc))::bigint
+		FROM dws_orders_analysis_view v
+		JOIN dws_orders o ON o.order_id = v.order_id
+		WHERE v.merchant_id = $1 AND v.local_date >= $2::date AND v.local_date < $3::date
+			AND o.shipped_time_utc >= o.order_time_utc
+			AND (COALESCE(cardinality($4::text[]), 0) = 0 OR v.status = ANY($4::text[]))
+		ORDER BY v.order_time_utc
+	`, filter.MerchantID, filter.From, filter.To, pq.Array(filter.Statuses))
+	if err != nil {
+		return nil, fmt.Errorf("查询商户 %d 履约时长失败: %w", filter.MerchantID, err)
+	}
+	defer rows.Close()
+
+	var result []models.OrderFulfillment
+	for rows.Next() {
+		var f models.OrderFulfillment
+		if err := rows.Scan(&f.LocalHour, &f.LocalDayOfWeek, &f.Seconds); err != nil {
+			return nil, fmt.Errorf("扫描商户履约时长失败: %w", err)
+		}
+		result = append(result, f)
+	}
+
+	return result, rows.Err()
+}
+
+// analysisView 单项分析查询的数据来源：默认为 dws_orders_analysis_view；
+// filter.AggregateTimezone 非空时改为按该时区由 order_time_utc 重新计算 local_date 和 local_hour 的子查询，
+// 只读取 filter.Start~End 内的订单（走 order_time_utc 索引），汇总表按商户本地时间汇总，因此不使用汇总表
+func analysisView(filter models.AnalysisFilter) string {
+	if filter.AggregateTimezone == "" {
+		return "dws_orders_analysis_view"
+	}
+	tz := pq.QuoteLiteral(filter.AggregateTimezone)
+	window := ""
+	if !filter.Start.IsZero() && !filter.End.IsZero() {
+		window = fmt.Sprintf("WHERE order_time_utc >= %s::timestamptz AND order_time_utc < %s::timestamptz",
+			pq.QuoteLiteral(filter.Start.UTC().Format(time.RFC3339Nano)), pq.QuoteLiteral(filter.End.UTC().Format(time.RFC3339Nano)))
+	}
+	return fmt.Sprintf(`(
+			SELECT
+				order_id, merchant_id, merchant_name, timezone, country, currency, status, amount,
+				(order_time_utc AT TIME ZONE %[1]s)::date as local_date,
+				EXTRACT(HOUR FROM order_time_utc AT TIME ZONE %[1]s)::int as local_hour
+			FROM dws_orders_analysis_view
+			%[2]s
+		) v`, tz, window)
+}
+
+// aggregatesQuery AggregatesByDate 的语句模板，第一个 %s 为聚合列，第二个为数据来源
+// 数据来源需要提供 local_date、currency、local_hour、timezone、country、merchant_id、merchant_name 列
+const aggregatesQuery = `
+		WITH grouped AS (
+			SELECT
+				local_date,
+				CASE
+					WHEN GROUPING(currency) = 0 THEN 'summary'
+					WHEN GROUPING(local_hour) = 0 THEN 'hourly'
+					WHEN GROUPING(merchant_id) = 0 THEN 'merchant'
+					ELSE 'timezone'
+				END as section,
+				currency as group_key,
+				local_hour,
+				timezone,
+				country,
+				merchant_id,
+				merchant_name,
+				%s
+			FROM %s
+			GROUP BY GROUPING SETS (
+				(local_date, currency),
+				(local_date, local_hour),
+				(local_date, timezone, country),
+				(local_date, merchant_id, merchant_name, timezone)
+			)
+		), ranked AS (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY local_date, section ORDER BY total_amount DESC, merchant_id) as rank
+			FROM grouped
+		)
+		SELECT
+			to_char(local_date, 'YYYY-MM-DD'), section, group_key, local_hour, timezone, country, merchant_id, merchant_name,
+			order_count, currency, total_amount, avg_amount
+		FROM ranked
+		WHERE section <> 'merchant' OR rank <= $3
+		ORDER BY local_date, section, group_key, local_hour, total_amount DESC, timezone, country, merchant_id
+	`
+
+// Aggregates 用 GROUPING SETS 在一条语句中同时计算四种分组，优先读取汇总表
+// GROUPING() 区分每行所属的分组，商户排行在库内按金额截取前 limit 个；金额相同时的排序与单项查询一致
+func (r *PostgresAnalysisRepository) Aggregates(ctx context.Context, filter models.AnalysisFilter, limit int) (*models.AnalysisAggregates, error) {
+	byDate, err := r.AggregatesByDate(ctx, []string{filter.LocalDate}, filter, limit)
+	if err != nil {
+		return nil, err
+	}
+	if aggregates, ok := byDate[filter.LocalDate]; ok {
+		return aggregates, nil
+	}
+	return &models.AnalysisAggregates{}, nil
+}
+
+// AggregatesByDate 与 Aggregates 相同的语句，分组中加入本地日期，一次计算多个日期；商户排行按日期分别截取
+func (r *PostgresAnalysisRepository) AggregatesByDate(ctx context.Context, dates []string, filter models.AnalysisFilter, limit int) (map[string]*models.AnalysisAggregates, error) {
+	if r.useRollup() && filter.AggregateTimezone == "" {
+		query := fmt.Sprintf(aggregatesQuery, `
+				SUM(order_count) as order_count,
+				CASE WHEN COUNT(DISTINCT currency) = 1 THEN MIN(currency) ELSE '' END as currency,
+				COALESCE(SUM(amount_sum), 0) as total_amount,
+				COALESCE(SUM(amount_sum) / NULLIF(SUM(order_count), 0), 0) as avg_amount`, `(
+				SELECT
+					a.local_date, a.currency, a.local_hour::int as local_hour, m.timezone, m.country,
+					a.merchant_id, m.merchant_name, a.order_count, a.amount_sum
+				FROM agg_orders_hourly_all a
+				JOIN dim_merchant m ON m.merchant_id = a.merchant_id
+				WHERE a.local_date = ANY($1::date[])
+					AND a.order_count > 0
+					AND (COALESCE(cardinality($2::text[]), 0) = 0 OR a.status = ANY($2::text[]))
+			) t`)
+		aggregates, err := r.aggregates(ctx, query, dates, filter, limit)
+		if !r.rollupUnavailable(err) {
+			return aggregates, err
+		}
+	}
+
+	query := fmt.Sprintf(aggregatesQuery, `
+				COUNT(*) as order_count,
+				CASE WHEN COUNT(DISTINCT currency) = 1 THEN MIN(currency) ELSE '' END as currency,
+				COALESCE(SUM(amount), 0) as total_amount,
+				COALESCE(AVG(amount), 0) as avg_amount`, analysisView(filter)+`
+			WHERE local_date = ANY($1::date[])
+				AND (COALESCE(cardinality($2::text[]), 0) = 0 OR status = ANY($2::text[]))`)
+	return r.aggregates(ctx, query, dates, filter, limit)
+}
+
+// aggregates 执行 AggregatesByDate 的语句并按日期和分组拆分结果
+func (r *PostgresAnalysisRepository) aggregates(ctx context.Context, query string, dates []string, filter models.AnalysisFilter, limit int) (map[string]*models.AnalysisAggregates, error) {
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(dates), pq.Array(filter.Statuses), limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询分析聚合失败: %w", err)
+	}
+	defer rows.Close()
+
+	byDate := map[string]*models.AnalysisAggregates{}
+	for rows.Next() {
+		var (
+			date, section, currency                   string
+			groupKey, timezone, country, merchantName sql.NullString
+			hour, merchantID                          sql.NullInt64
+			orderCount                                int
+			total, avg                                decimal.Decimal
+		)
+		err := rows.Scan(
+			&date,
+			&section,
+			&groupKey,
+			&hour,
+			&timezone,
+			&country,
+			&merchantID,
+			&merchantName,
+			&orderCount,
+			&currency,
+			&total,
+			&avg,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("扫描分析聚合失败: %w", err)
+		}
+		aggregates, ok := byDate[date]
+		if !ok {
+			aggregates = &models.AnalysisAggregates{}
+			byDate[date] = aggregates
+		}
+
+		switch section {
+		case "summary":
+			aggregates.Summary = append(aggregates.Summary, models.CurrencyTotal{
+				Currency:    groupKey.String,
+				OrderCount:  orderCount,
+				GrossAmount: total,
+			})
+		case "hourly":
+			aggregates.Hourly = append(aggregates.Hourly, models.HourlyOrderBreakdown{
+				Hour:        int(hour.Int64),
+				OrderCount:  orderCount,
+				Currency:    currency,
+				TotalAmount: total,
+				AvgAmount:   avg,
+			})
+		case "timezone":
+			aggregates.Timezones = append(aggregates.Timezones, models.TimezoneOrderStats{
+				Timezone:    timezone.String,
+				Country:     country.String,
+				OrderCount:  orderCount,
+				Currency:    currency,
+				TotalAmount: total,
+				AvgAmount:   avg,
+			})
+		case "merchant":
+			aggregates.TopMerchants = append(aggregates.TopMerchants, models.MerchantOrderStats{
+				MerchantID:   int(merchantID.Int64),
+				MerchantName: merchantName.String,
+				Timezone:     timezone.String,
+				OrderCount:   orderCount,
+				Currency:     currency,
+				TotalAmount:  total,
+				AvgAmount:    avg,
+			})
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历分析聚合失败: %w", err)
+	}
+	return byDate, nil
+}
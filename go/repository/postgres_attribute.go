@@ -0,0 +1,292 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// attributeTables 各对象的取值表及其对象表，取值表的对象ID列与对象表的主键同名
+var attributeTables = map[string]struct{ values, entities, id string }{
+	models.AttributeEntityMerchant: {values: "merchant_attributes", entities: "dim_merchant", id: "merchant_id"},
+	models.AttributeEntityOrder:    {values: "order_attributes", entities: "dws_orders", id: "order_id"},
+}
+
+// PostgresAttributeRepository 基于 attribute_definition、merchant_attributes、order_attributes 表的自定义属性仓储
+type PostgresAttributeRepository struct {
+	db *database.DB
+}
+
+// NewPostgresAttributeRepository 创建 PostgreSQL 自定义属性仓储
+func NewPostgresAttributeRepository(db *database.DB) *PostgresAttributeRepository {
+	return &PostgresAttributeRepository{db: db}
+}
+
+// attributeTable 对象对应的表，未知对象返回错误
+func attributeTable(entity string) (struct{ values, entities, id string }, error) {
+	t, ok := attributeTables[entity]
+	if !ok {
+		return t, fmt.Errorf("不支持的属性对象: %s", entity)
+	}
+	return t, nil
+}
+
+// Definitions 租户的全部属性定义
+func (r *PostgresAttributeRepository) Definitions(ctx context.Context, tenant string) ([]models.AttributeDefinition, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT tenant, entity, attr_key, value_type, allowed_values, description, created_by, created_at, updated_at
+		FROM attribute_definition
+		WHERE tenant = $1
+		ORDER BY entity, attr_key
+	`, tenant)
+	if err != nil {
+		return nil, fmt.Errorf("查询属性定义失败: %w", err)
+	}
+	defer rows.Close()
+
+	var definitions []models.AttributeDefinition
+	for rows.Next() {
+		var d models.AttributeDefinition
+		var values pq.StringArray
+		if err := rows.Scan(&d.Tenant, &d.Entity, &d.Key, &d.Type, &values, &d.Description, &d.CreatedBy, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("扫描属性定义失败: %w", err)
+		}
+		d.Values = values
+		d.CreatedAt, d.UpdatedAt = d.CreatedAt.UTC(), d.UpdatedAt.UTC()
+		definitions = append(definitions, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历属性定义失败: %w", err)
+	}
+	return definitions, nil
+}
+
+// SaveDefinition 新增或覆盖属性定义
+// 已有取值时修改类型，或 enum 的可选值不再包含已有的取值，返回 ErrConflict
+func (r *PostgresAttributeRepository) SaveDefinition(ctx context.Context, d *models.AttributeDefinition) error {
+	t, err := attributeTable(d.Entity)
+	if err != nil {
+		return err
+	}
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("开始保存属性定义事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	var current string
+	err = tx.QueryRowContext(ctx, `
+		SELECT value_type FROM attribute_definition
+		WHERE tenant = $1 AND entity = $2 AND attr_key = $3
+		FOR UPDATE
+	`, d.Tenant, d.Entity, d.Key).Scan(&current)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("查询属性定义失败: %w", err)
+	}
+	if current != "" {
+		// 类型改变时任何已有取值都不兼容；enum 只检查不在新可选值中的取值
+		var incompatible int
+		err = tx.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM `+t.values+`
+			WHERE tenant = $1 AND attributes ? $2
+				AND ($3 <> $4 OR ($4 = 'enum' AND NOT (attributes->>$2 = ANY($5::text[]))))
+		`, d.Tenant, d.Key, current, d.Type, pq.Array(d.Values)).Scan(&incompatible)
+		if err != nil {
+			return fmt.Errorf("检查已有取值失败: %w", err)
+		}
+		if incompatible > 0 {
+			return fmt.Errorf("%w: 属性 %s 已有 %d 个%s的取值与新定义不兼容，请先修改或删除这些取值", ErrConflict, d.Key, incompatible, attributeEntityName(d.Entity))
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO attribute_definition (tenant, entity, attr_key, value_type, allowed_values, description, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (tenant, entity, attr_key) DO UPDATE SET
+			value_type = EXCLUDED.value_type,
+			allowed_values = EXCLUDED.allowed_values,
+			description = EXCLUDED.description
+		RETURNING created_by, created_at, updated_at
+	`, d.Tenant, d.Entity, d.Key, d.Type, pq.Array(d.Values), d.Description, d.CreatedBy,
+	).Scan(&d.CreatedBy, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("保存属性定义失败: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交属性定义失败: %w", err)
+	}
+	d.CreatedAt, d.UpdatedAt = d.CreatedAt.UTC(), d.UpdatedAt.UTC()
+	return nil
+}
+
+// DeleteDefinition 在一个事务中删除属性定义及该租户已有的取值
+func (r *PostgresAttributeRepository) DeleteDefinition(ctx context.Context, tenant, entity, key string) error {
+	t, err := attributeTable(entity)
+	if err != nil {
+		return err
+	}
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("开始删除属性定义事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		DELETE FROM attribute_definition WHERE tenant = $1 AND entity = $2 AND attr_key = $3
+	`, tenant, entity, key)
+	if err != nil {
+		return fmt.Errorf("删除属性定义失败: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s属性 %s", ErrNotFound, attributeEntityName(entity), key)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE `+t.values+` SET attributes = attributes - $2, updated_at = CURRENT_TIMESTAMP
+		WHERE tenant = $1 AND attributes ? $2
+	`, tenant, key); err != nil {
+		return fmt.Errorf("删除属性取值失败: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交删除属性定义失败: %w", err)
+	}
+	return nil
+}
+
+// Attributes 商户或订单的取值，订单的有效取值为商户取值叠加订单取值
+func (r *PostgresAttributeRepository) Attributes(ctx context.Context, tenant, entity string, id int) (*models.EntityAttributes, error) {
+	t, err := attributeTable(entity)
+	if err != nil {
+		return nil, err
+	}
+	result := &models.EntityAttributes{Entity: entity, ID: id}
+	var attributes, effective []byte
+	var updatedAt sql.NullTime
+	err = r.db.QueryRowContext(ctx, `
+		SELECT e.merchant_id, COALESCE(a.attributes, '{}'),
+			COALESCE(ma.attributes, '{}') || COALESCE(a.attributes, '{}'),
+			COALESCE(a.updated_by, ''), a.updated_at
+		FROM `+t.entities+` e
+		LEFT JOIN `+t.values+` a ON a.tenant = $1 AND a.`+t.id+` = e.`+t.id+`
+		LEFT JOIN merchant_attributes ma ON ma.tenant = $1 AND ma.merchant_id = e.merchant_id
+		WHERE e.`+t.id+` = $2
+	`, tenant, id).Scan(&result.MerchantID, &attributes, &effective, &result.UpdatedBy, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s %d", ErrNotFound, attributeEntityName(entity), id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询%s属性失败: %w", attributeEntityName(entity), err)
+	}
+	if err := json.Unmarshal(attributes, &result.Attributes); err != nil {
+		return nil, fmt.Errorf("解析%s属性失败: %w", attributeEntityName(entity), err)
+	}
+	if entity == models.AttributeEntityOrder {
+		if err := json.Unmarshal(effective, &result.Effective); err != nil {
+			return nil, fmt.Errorf("解析%s属性失败: %w", attributeEntityName(entity), err)
+		}
+	}
+	if updatedAt.Valid {
+		at := updatedAt.Time.UTC()
+		result.UpdatedAt = &at
+	}
+	return result, nil
+}
+
+// SaveAttributes 合并取值并删除指定的键；对象不存在时不写入任何行
+func (r *PostgresAttributeRepository) SaveAttributes(ctx context.Context, tenant, entity string, id int, set map[string]json.RawMessage, remove []string, operator string) (*models.EntityAttributes, error) {
+	t, err := attributeTable(entity)
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		set = map[string]json.RawMessage{}
+	}
+	values, err := json.Marshal(set)
+	if err != nil {
+		return nil, fmt.Errorf("编码%s属性失败: %w", attributeEntityName(entity), err)
+	}
+	if remove == nil {
+		remove = []string{}
+	}
+
+	// 两张取值表都保存 merchant_id，订单的 merchant_id 从 dws_orders 取
+	idColumns := "merchant_id"
+	if t.id != "merchant_id" {
+		idColumns = t.id + ", merchant_id"
+	}
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO `+t.values+` (tenant, `+idColumns+`, attributes, updated_by)
+		SELECT $1, `+idColumns+`, $3::jsonb - $4::text[], $5
+		FROM `+t.entities+`
+		WHERE `+t.id+` = $2
+		ON CONFLICT (tenant, `+t.id+`) DO UPDATE SET
+			attributes = (`+t.values+`.attributes || $3::jsonb) - $4::text[],
+			updated_by = EXCLUDED.updated_by,
+			updated_at = CURRENT_TIMESTAMP
+	`, tenant, id, string(values), pq.Array(remove), operator)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return nil, fmt.Errorf("%w: %s %d", ErrNotFound, attributeEntityName(entity), id)
+		}
+		return nil, fmt.Errorf("保存%s属性失败: %w", attributeEntityName(entity), err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("%w: %s %d", ErrNotFound, attributeEntityName(entity), id)
+	}
+	return r.Attributes(ctx, tenant, entity, id)
+}
+
+// GroupTotals 按订单有效取值中 key 的取值分组统计，扫描分析视图，不包括已归档的订单
+func (r *PostgresAttributeRepository) GroupTotals(ctx context.Context, tenant, key string, filter models.AnalysisFilter) ([]models.AttributeGroupTotal, error) {
+	query := fmt.Sprintf(`
+		SELECT
+			(COALESCE(ma.attributes, '{}') || COALESCE(oa.attributes, '{}')) ->> $3 as value,
+			o.currency,
+			COUNT(*) as order_count,
+			COALESCE(SUM(o.amount), 0) as gross_amount
+		FROM (SELECT order_id, merchant_id, currency, status, amount, local_date FROM %s) o
+		LEFT JOIN merchant_attributes ma ON ma.tenant = $4 AND ma.merchant_id = o.merchant_id
+		LEFT JOIN order_attributes oa ON oa.tenant = $4 AND oa.order_id = o.order_id
+		WHERE o.local_date = $1
+			AND (COALESCE(cardinality($2::text[]), 0) = 0 OR o.status = ANY($2::text[]))
+		GROUP BY 1, 2
+		ORDER BY 1 NULLS LAST, 2
+	`, analysisView(filter))
+	rows, err := r.db.QueryContext(ctx, query, filter.LocalDate, pq.Array(filter.Statuses), key, tenant)
+	if err != nil {
+		return nil, fmt.Errorf("按属性 %s 分组统计失败: %w", key, err)
+	}
+	defer rows.Close()
+
+	var groups []models.AttributeGroupTotal
+	for rows.Next() {
+		var g models.AttributeGroupTotal
+		var value sql.NullString
+		if err := rows.Scan(&value, &g.Currency, &g.OrderCount, &g.GrossAmount); err != nil {
+			return nil, fmt.Errorf("扫描属性分组失败: %w", err)
+		}
+		if value.Valid {
+			g.Value = &value.String
+		}
+		groups = append(groups, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历属性分组失败: %w", err)
+	}
+	return groups, nil
+}
+
+// attributeEntityName 错误信息中的对象名称
+func attributeEntityName(entity string) string {
+	if entity == models.AttributeEntityOrder {
+		return "订单"
+	}
+	return "商户"
+}
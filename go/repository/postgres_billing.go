@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// PostgresBillingRepository 基于 merchant_subscription / billing_period 表的计费仓储
+type PostgresBillingRepository struct {
+	db *database.DB
+}
+
+// NewPostgresBillingRepository 创建 PostgreSQL 计费仓储
+func NewPostgresBillingRepository(db *database.DB) *PostgresBillingRepository {
+	return &PostgresBillingRepository{db: db}
+}
+
+// Subscription 获取商户订阅配置
+func (r *PostgresBillingRepository) Subscription(merchantID int) (*models.Subscription, error) {
+	query := `
+		SELECT merchant_id, plan_code, interval_unit, interval_count, anchor_date, status, created_at
+		FROM merchant_subscription
+		WHERE merchant_id = $1
+	`
+
+	var sub models.Subscription
+	var anchorDate time.Time
+	err := r.db.QueryRow(query, merchantID).Scan(
+		&sub.MerchantID,
+		&sub.PlanCode,
+		&sub.IntervalUnit,
+		&sub.IntervalCount,
+		&anchorDate,
+		&sub.Status,
+		&sub.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: 商户 %d 的订阅配置", ErrNotFound, merchantID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询订阅配置失败: %w", err)
+	}
+
+	sub.AnchorDate = anchorDate.Format("2006-01-02")
+	return &sub, nil
+}
+
+// SavePeriods 在一个事务中保存计费周期
+func (r *PostgresBillingRepository) SavePeriods(periods []models.BillingPeriod) error {
+	if len(periods) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO billing_period (
+			merchant_id, sequence, timezone,
+			period_start_local, period_end_local, period_start_utc, period_end_utc
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (merchant_id, sequence) DO NOTHING
+	`)
+	if err != nil {
+		return fmt.Errorf("准备写入计费周期失败: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, p := range periods {
+		_, err := stmt.Exec(p.MerchantID, p.Sequence, p.Timezone, p.StartLocal, p.EndLocal, p.StartUTC, p.EndUTC)
+		if err != nil {
+			return fmt.Errorf("写入计费周期 %d/%d 失败: %w", p.MerchantID, p.Sequence, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交计费周期失败: %w", err)
+	}
+	return nil
+}
+
+// ListPeriods 获取商户已保存的计费周期
+func (r *PostgresBillingRepository) ListPeriods(merchantID int) ([]models.BillingPeriod, error) {
+	query := `
+		SELECT period_id, merchant_id, sequence, timezone,
+			period_start_local, period_end_local, period_start_utc, period_end_utc
+		FROM billing_period
+		WHERE merchant_id = $1
+		ORDER BY sequence
+	`
+
+	rows, err := r.db.Query(query, merchantID)
+	if err != nil {
+		return nil, fmt.Errorf("查询计费周期失败: %w", err)
+	}
+	defer rows.Close()
+
+	var periods []models.BillingPeriod
+	for rows.Next() {
+		var p models.BillingPeriod
+		var startLocal, endLocal time.Time
+		err := rows.Scan(
+			&p.PeriodID,
+			&p.MerchantID,
+			&p.Sequence,
+			&p.Timezone,
+			&startLocal,
+			&endLocal,
+			&p.StartUTC,
+			&p.EndUTC,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("扫描计费周期失败: %w", err)
+		}
+		p.StartLocal = startLocal.Format("2006-01-02 15:04:05")
+		p.EndLocal = endLocal.Format("2006-01-02 15:04:05")
+		p.StartUTC, p.EndUTC = p.StartUTC.UTC(), p.EndUTC.UTC()
+		p.DurationHours = p.EndUTC.Sub(p.StartUTC).Hours()
+		periods = append(periods, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历计费周期失败: %w", err)
+	}
+
+	return periods, nil
+}
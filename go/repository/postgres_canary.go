@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// PostgresCanaryRepository 基于 canary_tenant 表的合成监控租户仓储
+type PostgresCanaryRepository struct {
+	db *database.DB
+}
+
+// NewPostgresCanaryRepository 创建 PostgreSQL 合成监控租户仓储
+func NewPostgresCanaryRepository(db *database.DB) *PostgresCanaryRepository {
+	return &PostgresCanaryRepository{db: db}
+}
+
+// Tenant 合成监控租户和预期合计
+func (r *PostgresCanaryRepository) Tenant(ctx context.Context) (*models.CanaryTenant, error) {
+	var t models.CanaryTenant
+	err := r.db.QueryRowContext(ctx, `
+		SELECT c.merchant_id, m.timezone, to_char(c.canary_date, 'YYYY-MM-DD'), c.currency, c.expected_orders, c.expected_amount
+		FROM canary_tenant c
+		JOIN dim_merchant m ON m.merchant_id = c.merchant_id
+	`).Scan(&t.MerchantID, &t.Timezone, &t.Date, &t.Currency, &t.ExpectedOrders, &t.ExpectedAmount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: 没有合成监控租户，请执行 sql/35_canary.sql", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询合成监控租户失败: %w", err)
+	}
+	return &t, nil
+}
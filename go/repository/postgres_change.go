@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// PostgresChangeRepository 基于 change_log 表的变更流水仓储
+type PostgresChangeRepository struct {
+	db *database.DB
+}
+
+// NewPostgresChangeRepository 创建 PostgreSQL 变更流水仓储
+func NewPostgresChangeRepository(db *database.DB) *PostgresChangeRepository {
+	return &PostgresChangeRepository{db: db}
+}
+
+// Changes 先调用 sequence_change_log() 编号，再按 seq 读取
+// 编号在独立的语句（事务）中完成，读取只能看到已提交的编号结果
+func (r *PostgresChangeRepository) Changes(ctx context.Context, filter models.ChangeFilter) ([]models.Change, error) {
+	if _, err := r.db.ExecContext(ctx, `SELECT sequence_change_log()`); err != nil {
+		return nil, fmt.Errorf("变更流水编号失败: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT seq, entity, entity_id, merchant_id, op, snapshot, changed_at
+		FROM change_log
+		WHERE seq > $1
+			AND ($2 = 0 OR merchant_id = $2)
+			AND (COALESCE(cardinality($3::text[]), 0) = 0 OR entity = ANY($3::text[]))
+		ORDER BY seq
+		LIMIT $4
+	`, filter.Since, filter.MerchantID, pq.Array(filter.Entities), filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询变更流水失败: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []models.Change
+	for rows.Next() {
+		var c models.Change
+		var snapshot []byte
+		if err := rows.Scan(&c.Seq, &c.Entity, &c.EntityID, &c.MerchantID, &c.Op, &snapshot, &c.ChangedAt); err != nil {
+			return nil, fmt.Errorf("扫描变更流水失败: %w", err)
+		}
+		c.Snapshot = snapshot
+		c.ChangedAt = c.ChangedAt.UTC()
+		changes = append(changes, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历变更流水失败: %w", err)
+	}
+	return changes, nil
+}
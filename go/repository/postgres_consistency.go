@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// PostgresConsistencyRepository 基于 consistency_check_run / consistency_discrepancy 表的一致性检查仓储
+type PostgresConsistencyRepository struct {
+	db *database.DB
+}
+
+// NewPostgresConsistencyRepository 创建 PostgreSQL 一致性检查仓储
+func NewPostgresConsistencyRepository(db *database.DB) *PostgresConsistencyRepository {
+	return &PostgresConsistencyRepository{db: db}
+}
+
+// MerchantTotals 分别聚合 dws_orders 和分析视图，视图丢失商户（如 dim_merchant 缺行）时 view 一侧为 0
+func (r *PostgresConsistencyRepository) MerchantTotals(ctx context.Context) ([]models.ConsistencyTotals, error) {
+	query := `
+		WITH base AS (
+			SELECT merchant_id, COUNT(*) AS order_count, COALESCE(SUM(amount), 0) AS order_amount
+			FROM dws_orders
+			GROUP BY merchant_id
+		), view AS (
+			SELECT merchant_id, COUNT(*) AS order_count, COALESCE(SUM(amount), 0) AS order_amount
+			FROM dws_orders_analysis_view
+			GROUP BY merchant_id
+		)
+		SELECT
+			COALESCE(base.merchant_id, view.merchant_id),
+			COALESCE(base.order_count, 0),
+			COALESCE(base.order_amount, 0),
+			COALESCE(view.order_count, 0),
+			COALESCE(view.order_amount, 0)
+		FROM base
+		FULL JOIN view ON view.merchant_id = base.merchant_id
+		ORDER BY 1
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("统计商户订单合计失败: %w", err)
+	}
+	defer rows.Close()
+
+	var totals []models.ConsistencyTotals
+	for rows.Next() {
+		var t models.ConsistencyTotals
+		if err := rows.Scan(&t.MerchantID, &t.OrderCount, &t.OrderAmount, &t.ViewCount, &t.ViewAmount); err != nil {
+			return nil, fmt.Errorf("扫描商户订单合计失败: %w", err)
+		}
+		totals = append(totals, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历商户订单合计失败: %w", err)
+	}
+	return totals, nil
+}
+
+// SampleOrders 先在 dws_orders 上随机抽取订单ID，再从视图读取这些订单，避免对整个视图排序
+func (r *PostgresConsistencyRepository) SampleOrders(ctx context.Context, n int) ([]models.OrderAnalysis, error) {
+	query := `
+		SELECT ` + orderAnalysisColumns + `
+		FROM dws_orders_analysis_view
+		WHERE order_id IN (SELECT order_id FROM dws_orders ORDER BY random() LIMIT $1)
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, n)
+	if err != nil {
+		return nil, fmt.Errorf("抽样订单失败: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []models.OrderAnalysis
+	for rows.Next() {
+		order, err := scanOrderAnalysis(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历抽样订单失败: %w", err)
+	}
+	return orders, nil
+}
+
+// SaveRun 写入检查记录及差异明细
+func (r *PostgresConsistencyRepository) SaveRun(ctx context.Context, run *models.ConsistencyRun) error {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO consistency_check_run (started_at, finished_at, status, merchants, sampled_orders, discrepancy_count, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING run_id
+	`, run.StartedAt, run.FinishedAt, run.Status, run.Merchants, run.SampledOrders, run.DiscrepancyCount, run.Error).Scan(&run.RunID)
+	if err != nil {
+		return fmt.Errorf("写入一致性检查记录失败: %w", err)
+	}
+
+	for i := range run.Discrepancies {
+		d := &run.Discrepancies[i]
+		d.RunID = run.RunID
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO consistency_discrepancy (run_id, kind, merchant_id, order_id, expected, actual)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING discrepancy_id
+		`, d.RunID, d.Kind, d.MerchantID, d.OrderID, d.Expected, d.Actual).Scan(&d.ID)
+		if err != nil {
+			return fmt.Errorf("写入一致性差异失败: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// Runs 获取最近的检查记录
+func (r *PostgresConsistencyRepository) Runs(ctx context.Context, limit int) ([]models.ConsistencyRun, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT run_id, started_at, finished_at, status, merchants, sampled_orders, discrepancy_count, error
+		FROM consistency_check_run
+		ORDER BY started_at DESC, run_id DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询一致性检查记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []models.ConsistencyRun
+	for rows.Next() {
+		var run models.ConsistencyRun
+		if err := scanConsistencyRun(rows, &run); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历一致性检查记录失败: %w", err)
+	}
+	return runs, nil
+}
+
+// Run 获取一次检查及其差异明细
+func (r *PostgresConsistencyRepository) Run(ctx context.Context, runID int64) (*models.ConsistencyRun, error) {
+	var run models.ConsistencyRun
+	row := r.db.QueryRowContext(ctx, `
+		SELECT run_id, started_at, finished_at, status, merchants, sampled_orders, discrepancy_count, error
+		FROM consistency_check_run
+		WHERE run_id = $1
+	`, runID)
+	if err := scanConsistencyRun(row, &run); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: 一致性检查 %d", ErrNotFound, runID)
+		}
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT discrepancy_id, run_id, kind, merchant_id, order_id, expected, actual
+		FROM consistency_discrepancy
+		WHERE run_id = $1
+		ORDER BY discrepancy_id
+	`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("查询一致性差异失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d models.ConsistencyDiscrepancy
+		var orderID sql.NullInt64
+		if err := rows.Scan(&d.ID, &d.RunID, &d.Kind, &d.MerchantID, &orderID, &d.Expected, &d.Actual); err != nil {
+			return nil, fmt.Errorf("扫描一致性差异失败: %w", err)
+		}
+		if orderID.Valid {
+			id := int(orderID.Int64)
+			d.OrderID = &id
+		}
+		run.Discrepancies = append(run.Discrepancies, d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历一致性差异失败: %w", err)
+	}
+	return &run, nil
+}
+
+// scanConsistencyRun 扫描一行检查记录，sql.ErrNoRows 原样返回
+func scanConsistencyRun(row interface{ Scan(...interface{}) error }, run *models.ConsistencyRun) error {
+	err := row.Scan(&run.RunID, &run.StartedAt, &run.FinishedAt, &run.Status, &run.Merchants, &run.SampledOrders, &run.DiscrepancyCount, &run.Error)
+	if errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("扫描一致性检查记录失败: %w", err)
+	}
+	run.StartedAt, run.FinishedAt = run.StartedAt.UTC(), run.FinishedAt.UTC()
+	return nil
+}
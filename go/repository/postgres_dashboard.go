@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// PostgresDashboardRepository 基于 dws_orders_analysis_view 的首页概览仓储
+type PostgresDashboardRepository struct {
+	db *database.DB
+}
+
+// NewPostgresDashboardRepository 创建 PostgreSQL 首页概览仓储
+func NewPostgresDashboardRepository(db *database.DB) *PostgresDashboardRepository {
+	return &PostgresDashboardRepository{db: db}
+}
+
+// Summary 只扫描一次商户最近两天的订单（走 merchant_id + order_time_utc 索引），用 UNION ALL 在一条语句中返回各部分
+// 小时点按距 HourlyStart 的整小时数分桶，HourlyStart 是本地整点，因此每个桶对应一个本地小时
+func (r *PostgresDashboardRepository) Summary(ctx context.Context, filter models.DashboardFilter) (*models.DashboardAggregates, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		WITH o AS (
+			SELECT currency, amount, order_time_utc
+			FROM dws_orders_analysis_view
+			WHERE merchant_id = $1
+				AND order_time_utc >= LEAST($3::timestamptz, $5::timestamptz) AND order_time_utc < $6
+				AND ($7 = '' OR currency = $7)
+				AND (COALESCE(cardinality($8::text[]), 0) = 0 OR status = ANY($8::text[]))
+		)
+		SELECT 'today' as section, currency, NULL::timestamptz as hour_start, COUNT(*), COALESCE(SUM(amount), 0)
+		FROM o WHERE order_time_utc >= $2
+		GROUP BY currency
+		UNION ALL
+		SELECT 'yesterday_same_time', currency, NULL, COUNT(*), COALESCE(SUM(amount), 0)
+		FROM o WHERE order_time_utc >= $3 AND order_time_utc < $4
+		GROUP BY currency
+		UNION ALL
+		SELECT 'yesterday', currency, NULL, COUNT(*), COALESCE(SUM(amount), 0)
+		FROM o WHERE order_time_utc >= $3 AND order_time_utc < $2
+		GROUP BY currency
+		UNION ALL
+		SELECT 'hourly', currency, hour_start, COUNT(*), COALESCE(SUM(amount), 0)
+		FROM (
+			SELECT currency, amount,
+				$5::timestamptz + FLOOR(EXTRACT(EPOCH FROM order_time_utc - $5::timestamptz) / 3600) * INTERVAL '1 hour' as hour_start
+			FROM o WHERE order_time_utc >= $5
+		) h
+		GROUP BY hour_start, currency
+		ORDER BY 1, 3, 2
+	`,
+		filter.MerchantID,
+		filter.TodayStart,
+		filter.YesterdayStart,
+		filter.YesterdayAt,
+		filter.HourlyStart,
+		filter.Now,
+		filter.Currency,
+		pq.Array(filter.Statuses),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("查询商户 %d 首页概览失败: %w", filter.MerchantID, err)
+	}
+	defer rows.Close()
+
+	aggregates := &models.DashboardAggregates{}
+	for rows.Next() {
+		var (
+			section, currency string
+			hourStart         sql.NullTime
+			orderCount        int
+			amount            decimal.Decimal
+		)
+		if err := rows.Scan(&section, &currency, &hourStart, &orderCount, &amount); err != nil {
+			return nil, fmt.Errorf("扫描首页概览失败: %w", err)
+		}
+
+		total := models.CurrencyTotal{Currency: currency, OrderCount: orderCount, GrossAmount: amount}
+		switch section {
+		case "today":
+			aggregates.Today = append(aggregates.Today, total)
+		case "yesterday_same_time":
+			aggregates.YesterdaySameTime = append(aggregates.YesterdaySameTime, total)
+		case "yesterday":
+			aggregates.Yesterday = append(aggregates.Yesterday, total)
+		case "hourly":
+			aggregates.Hourly = append(aggregates.Hourly, models.DashboardHourlyTotal{
+				HourStart:  hourStart.Time.UTC(),
+				Currency:   currency,
+				OrderCount: orderCount,
+				Amount:     amount,
+			})
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历首页概览失败: %w", err)
+	}
+	return aggregates, nil
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// emailTemplateColumns email_template 的查询列，与 scanEmailTemplate 的顺序一致
+const emailTemplateColumns = `tenant, name, locale, subject, html, text, updated_by, updated_at`
+
+// PostgresEmailTemplateRepository 基于 email_template 表的邮件模板仓储
+type PostgresEmailTemplateRepository struct {
+	db *database.DB
+}
+
+// NewPostgresEmailTemplateRepository 创建 PostgreSQL 邮件模板仓储
+func NewPostgresEmailTemplateRepository(db *database.DB) *PostgresEmailTemplateRepository {
+	return &PostgresEmailTemplateRepository{db: db}
+}
+
+// Templates 租户覆盖的全部模板
+func (r *PostgresEmailTemplateRepository) Templates(ctx context.Context, tenant string) ([]models.EmailTemplate, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+emailTemplateColumns+` FROM email_template WHERE tenant = $1 ORDER BY name, locale
+	`, tenant)
+	if err != nil {
+		return nil, fmt.Errorf("查询邮件模板失败: %w", err)
+	}
+	defer rows.Close()
+
+	var templates []models.EmailTemplate
+	for rows.Next() {
+		t, err := scanEmailTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历邮件模板失败: %w", err)
+	}
+	return templates, nil
+}
+
+// Template 租户覆盖的单个模板
+func (r *PostgresEmailTemplateRepository) Template(ctx context.Context, tenant, name, locale string) (*models.EmailTemplate, error) {
+	row := r.db.QueryRowContext(ctx, `
+		SELECT `+emailTemplateColumns+` FROM email_template WHERE tenant = $1 AND name = $2 AND locale = $3
+	`, tenant, name, locale)
+	t, err := scanEmailTemplate(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: 邮件模板 %s（%s）", ErrNotFound, name, locale)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// SaveTemplate 写入或覆盖租户的模板
+func (r *PostgresEmailTemplateRepository) SaveTemplate(ctx context.Context, t *models.EmailTemplate) error {
+	var updatedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO email_template (tenant, name, locale, subject, html, text, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (tenant, name, locale) DO UPDATE SET
+			subject = EXCLUDED.subject, html = EXCLUDED.html, text = EXCLUDED.text, updated_by = EXCLUDED.updated_by
+		RETURNING updated_at
+	`, t.Tenant, t.Name, t.Locale, t.Subject, t.HTML, t.Text, t.UpdatedBy).Scan(&updatedAt)
+	if err != nil {
+		return fmt.Errorf("保存邮件模板失败: %w", err)
+	}
+	if updatedAt.Valid {
+		at := updatedAt.Time.UTC()
+		t.UpdatedAt = &at
+	}
+	return nil
+}
+
+// DeleteTemplate 删除租户覆盖的模板
+func (r *PostgresEmailTemplateRepository) DeleteTemplate(ctx context.Context, tenant, name, locale string) error {
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM email_template WHERE tenant = $1 AND name = $2 AND locale = $3
+	`, tenant, name, locale)
+	if err != nil {
+		return fmt.Errorf("删除邮件模板失败: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: 邮件模板 %s（%s）", ErrNotFound, name, locale)
+	}
+	return nil
+}
+
+// scanEmailTemplate 扫描一条邮件模板，单行查询没有结果时原样返回 sql.ErrNoRows
+func scanEmailTemplate(row interface{ Scan(...interface{}) error }) (models.EmailTemplate, error) {
+	t := models.EmailTemplate{Source: "tenant"}
+	var updatedAt sql.NullTime
+	err := row.Scan(&t.Tenant, &t.Name, &t.Locale, &t.Subject, &t.HTML, &t.Text, &t.UpdatedBy, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return t, err
+	}
+	if err != nil {
+		return t, fmt.Errorf("扫描邮件模板失败: %w", err)
+	}
+	if updatedAt.Valid {
+		at := updatedAt.Time.UTC()
+		t.UpdatedAt = &at
+	}
+	return t, nil
+}
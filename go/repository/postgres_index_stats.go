@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// PostgresIndexStatsRepository 基于系统目录和统计视图的索引统计仓储
+type PostgresIndexStatsRepository struct {
+	db *database.DB
+}
+
+// NewPostgresIndexStatsRepository 创建 PostgreSQL 索引统计仓储
+func NewPostgresIndexStatsRepository(db *database.DB) *PostgresIndexStatsRepository {
+	return &PostgresIndexStatsRepository{db: db}
+}
+
+// Indexes 获取指定表上的索引，列按索引中的顺序返回
+func (r *PostgresIndexStatsRepository) Indexes(ctx context.Context, tables []string) ([]models.IndexInfo, error) {
+	query := `
+		SELECT
+			t.relname,
+			i.relname,
+			ARRAY(
+				SELECT COALESCE(a.attname::text, '(expression)')
+				FROM unnest(ix.indkey::int2[]) WITH ORDINALITY AS k(attnum, ord)
+				LEFT JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+				ORDER BY k.ord
+			),
+			pg_get_indexdef(ix.indexrelid),
+			ix.indisunique,
+			ix.indisprimary,
+			ix.indpred IS NOT NULL,
+			COALESCE(s.idx_scan, 0),
+			pg_relation_size(ix.indexrelid)
+		FROM pg_index ix
+		JOIN pg_class i ON i.oid = ix.indexrelid
+		JOIN pg_class t ON t.oid = ix.indrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		LEFT JOIN pg_stat_user_indexes s ON s.indexrelid = ix.indexrelid
+		WHERE n.nspname = current_schema() AND t.relname = ANY($1)
+		ORDER BY t.relname, i.relname
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(tables))
+	if err != nil {
+		return nil, fmt.Errorf("查询索引失败: %w", err)
+	}
+	defer rows.Close()
+
+	var indexes []models.IndexInfo
+	for rows.Next() {
+		var index models.IndexInfo
+		var columns pq.StringArray
+		err := rows.Scan(
+			&index.Table,
+			&index.Name,
+			&columns,
+			&index.Definition,
+			&index.Unique,
+			&index.Primary,
+			&index.Partial,
+			&index.Scans,
+			&index.SizeBytes,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("扫描索引失败: %w", err)
+		}
+		index.Columns = columns
+		indexes = append(indexes, index)
+	}
+
+	return indexes, rows.Err()
+}
+
+// TableStats 获取指定表的扫描统计
+func (r *PostgresIndexStatsRepository) TableStats(ctx context.Context, tables []string) ([]models.TableScanStats, error) {
+	query := `
+		SELECT
+			relname,
+			n_live_tup,
+			seq_scan,
+			seq_tup_read,
+			COALESCE(idx_scan, 0),
+			pg_table_size(relid)
+		FROM pg_stat_user_tables
+		WHERE schemaname = current_schema() AND relname = ANY($1)
+		ORDER BY relname
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(tables))
+	if err != nil {
+		return nil, fmt.Errorf("查询表扫描统计失败: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []models.TableScanStats
+	for rows.Next() {
+		var s models.TableScanStats
+		if err := rows.Scan(&s.Table, &s.LiveRows, &s.SeqScans, &s.SeqRowsRead, &s.IndexScans, &s.SizeBytes); err != nil {
+			return nil, fmt.Errorf("扫描表统计失败: %w", err)
+		}
+		stats = append(stats, s)
+	}
+
+	return stats, rows.Err()
+}
+
+// Statements 获取 pg_stat_statements 中涉及指定表的语句，按总耗时倒序
+// 分析视图基于这些表，查询视图的语句也一并返回
+func (r *PostgresIndexStatsRepository) Statements(ctx context.Context, tables []string, limit int) ([]models.StatementStats, error) {
+	var installed bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_stat_statements')`).Scan(&installed)
+	if err != nil {
+		return nil, fmt.Errorf("检查 pg_stat_statements 扩展失败: %w", err)
+	}
+	if !installed {
+		return nil, fmt.Errorf("未安装 pg_stat_statements 扩展")
+	}
+
+	patterns := make([]string, 0, len(tables)+1)
+	for _, table := range tables {
+		patterns = append(patterns, "%"+table+"%")
+	}
+	patterns = append(patterns, "%dws_orders_analysis_view%")
+
+	query := `
+		SELECT query, calls, total_exec_time, mean_exec_time, rows
+		FROM pg_stat_statements
+		WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database())
+			AND query ILIKE ANY($1)
+			AND query NOT ILIKE '%pg_stat_statements%'
+		ORDER BY total_exec_time DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(patterns), limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询 pg_stat_statements 失败: %w", err)
+	}
+	defer rows.Close()
+
+	var statements []models.StatementStats
+	for rows.Next() {
+		var s models.StatementStats
+		if err := rows.Scan(&s.Query, &s.Calls, &s.TotalTimeMs, &s.MeanTimeMs, &s.Rows); err != nil {
+			return nil, fmt.Errorf("扫描语句统计失败: %w", err)
+		}
+		statements = append(statements, s)
+	}
+
+	return statements, rows.Err()
+}
@@ -0,0 +1,317 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// ingestDeliveryColumns 读取投递时的列（不含载荷），与 scanIngestDelivery 的顺序一致
+const ingestDeliveryColumns = `delivery_id, provider, external_id, topic, COALESCE(merchant_id, 0), COALESCE(order_no, ''),
+	COALESCE(order_id, 0), event_time, status, COALESCE(error, ''), attempts, received_at, processed_at, conflict`
+
+// PostgresIngestRepository 基于 ingest_delivery 和 dws_orders 表的 Webhook 投递仓储
+type PostgresIngestRepository struct {
+	db *database.DB
+}
+
+// NewPostgresIngestRepository 创建 PostgreSQL Webhook 投递仓储
+func NewPostgresIngestRepository(db *database.DB) *PostgresIngestRepository {
+	return &PostgresIngestRepository{db: db}
+}
+
+// SaveDelivery 写入投递，(provider, external_id) 冲突时读取已有记录
+func (r *PostgresIngestRepository) SaveDelivery(ctx context.Context, d *models.IngestDelivery) (bool, error) {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO ingest_delivery (provider, external_id, topic, merchant_id, payload)
+		VALUES ($1, $2, $3, NULLIF($4, 0), $5)
+		ON CONFLICT (provider, external_id) DO NOTHING
+		RETURNING delivery_id, status, received_at
+	`, d.Provider, d.ExternalID, d.Topic, d.MerchantID, []byte(d.Payload)).Scan(&d.ID, &d.Status, &d.ReceivedAt)
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("保存 Webhook 投递失败: %w", err)
+	}
+
+	existing, err := scanIngestDelivery(r.db.QueryRowContext(ctx, `
+		SELECT `+ingestDeliveryColumns+` FROM ingest_delivery WHERE provider = $1 AND external_id = $2
+	`, d.Provider, d.ExternalID))
+	if err != nil {
+		return false, fmt.Errorf("查询已有的 Webhook 投递失败: %w", err)
+	}
+	existing.Payload = d.Payload
+	*d = *existing
+	return false, nil
+}
+
+// ApplyOrder 按（商户，订单号）取咨询锁后比较事件时刻并调用 resolve，新订单和已有订单的写入都在锁内完成；已有订单不修改下单时间
+func (r *PostgresIngestRepository) ApplyOrder(ctx context.Context, d *models.IngestDelivery, order models.Order, resolve IngestResolver) error {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1, hashtext($2))`, order.MerchantID, order.OrderNumber); err != nil {
+		return fmt.Errorf("锁定订单号失败: %w", err)
+	}
+
+	d.Status = models.IngestStatusProcessed
+	d.Conflict = nil
+	if d.EventTime != nil {
+		var newer bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM ingest_delivery
+				WHERE merchant_id = $1 AND order_no = $2 AND status = 'processed' AND provider = $3
+					AND event_time > $4 AND delivery_id <> $5
+			)
+		`, order.MerchantID, order.OrderNumber, d.Provider, *d.EventTime, d.ID).Scan(&newer)
+		if err != nil {
+			return fmt.Errorf("查询订单的已处理事件失败: %w", err)
+		}
+		if newer {
+			d.Status = models.IngestStatusSkipped
+		}
+	}
+
+	if d.Status == models.IngestStatusProcessed {
+		current, err := scanSyncOrder(tx.QueryRowContext(ctx, `
+			SELECT `+syncOrderColumns+` FROM dws_orders WHERE merchant_id = $1 AND order_no = $2 FOR UPDATE
+		`, order.MerchantID, order.OrderNumber))
+		if errors.Is(err, sql.ErrNoRows) {
+			current = nil
+		} else if err != nil {
+			return fmt.Errorf("锁定订单失败: %w", err)
+		}
+		var lastEventTime *time.Time
+		if current != nil {
+			var last sql.NullTime
+			err := tx.QueryRowContext(ctx, `
+				SELECT MAX(event_time) FROM ingest_delivery
+				WHERE merchant_id = $1 AND order_no = $2 AND status = 'processed' AND delivery_id <> $3
+			`, order.MerchantID, order.OrderNumber, d.ID).Scan(&last)
+			if err != nil {
+				return fmt.Errorf("查询订单的已处理事件失败: %w", err)
+			}
+			if last.Valid {
+				t := last.Time.UTC()
+				lastEventTime = &t
+			}
+		}
+
+		next, conflict := resolve(current, lastEventTime)
+		switch {
+		case conflict != nil:
+			d.Status = models.IngestStatusConflict
+			d.Conflict = conflict
+			d.OrderID = current.ID
+		case next.ID == 0:
+			err = tx.QueryRowContext(ctx, `
+				INSERT INTO dws_orders (
+					order_no, merchant_id, order_amount, currency, order_status, order_time_utc, order_source, ingested_at
+				) VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP)
+				RETURNING order_id
+			`, next.OrderNumber, next.MerchantID, next.Amount, next.Currency, next.Status, next.OrderTimeUTC, d.Provider,
+			).Scan(&d.OrderID)
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+				return fmt.Errorf("%w: 商户 %d 的订单号 %s 已由其他请求写入", ErrConflict, next.MerchantID, next.OrderNumber)
+			}
+			if err != nil {
+				return fmt.Errorf("写入订单失败: %w", err)
+			}
+		default:
+			d.OrderID = next.ID
+			// 首次变为已发货时以平台事件时刻作为发货时间，没有事件时刻时由触发器取当前时刻
+			_, err = tx.ExecContext(ctx, `
+				UPDATE dws_orders
+				SET order_amount = $2, currency = $3, order_status = $4::text,
+					shipped_time_utc = CASE
+						WHEN $4::text IN ('shipped', 'delivered') AND COALESCE(order_status, '') NOT IN ('shipped', 'delivered')
+							THEN COALESCE(shipped_time_utc, $5::timestamptz)
+						ELSE shipped_time_utc
+					END
+				WHERE order_id = $1
+			`, next.ID, next.Amount, next.Currency, next.Status, d.EventTime)
+			if err != nil {
+				return fmt.Errorf("更新订单失败: %w", err)
+			}
+		}
+	}
+
+	var conflict []byte
+	if d.Conflict != nil {
+		if conflict, err = json.Marshal(d.Conflict); err != nil {
+			return fmt.Errorf("序列化冲突详情失败: %w", err)
+		}
+	}
+	d.MerchantID = order.MerchantID
+	d.OrderNumber = order.OrderNumber
+	d.Error = ""
+	err = tx.QueryRowContext(ctx, `
+		UPDATE ingest_delivery
+		SET status = $2, merchant_id = $3, order_no = $4, order_id = NULLIF($5, 0), event_time = $6,
+			error = NULL, conflict = $7, attempts = attempts + 1, processed_at = CURRENT_TIMESTAMP
+		WHERE delivery_id = $1
+		RETURNING attempts, processed_at
+	`, d.ID, d.Status, d.MerchantID, d.OrderNumber, d.OrderID, d.EventTime, conflict).Scan(&d.Attempts, &d.ProcessedAt)
+	if err != nil {
+		return fmt.Errorf("更新 Webhook 投递状态失败: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交 Webhook 订单失败: %w", err)
+	}
+	return nil
+}
+
+// FinishDelivery 更新投递的状态和错误信息
+func (r *PostgresIngestRepository) FinishDelivery(ctx context.Context, d *models.IngestDelivery) error {
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE ingest_delivery
+		SET status = $2, error = NULLIF($3, ''), merchant_id = COALESCE(NULLIF($4, 0), merchant_id),
+			order_no = COALESCE(NULLIF($5, ''), order_no), event_time = COALESCE($6, event_time),
+			conflict = NULL, attempts = attempts + 1, processed_at = CURRENT_TIMESTAMP
+		WHERE delivery_id = $1
+		RETURNING attempts, processed_at
+	`, d.ID, d.Status, d.Error, d.MerchantID, d.OrderNumber, d.EventTime).Scan(&d.Attempts, &d.ProcessedAt)
+	d.Conflict = nil
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: Webhook 投递 %d", ErrNotFound, d.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("更新 Webhook 投递状态失败: %w", err)
+	}
+	return nil
+}
+
+// Delivery 按ID获取投递及其载荷
+func (r *PostgresIngestRepository) Delivery(ctx context.Context, id int64) (*models.IngestDelivery, error) {
+	var payload []byte
+	row := r.db.QueryRowContext(ctx, `SELECT `+ingestDeliveryColumns+`, payload FROM ingest_delivery WHERE delivery_id = $1`, id)
+	d, err := scanIngestDelivery(row, &payload)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: Webhook 投递 %d", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询 Webhook 投递失败: %w", err)
+	}
+	d.Payload = payload
+	return d, nil
+}
+
+// Deliveries 按接收时间倒序查询投递
+func (r *PostgresIngestRepository) Deliveries(ctx context.Context, filter models.IngestDeliveryFilter) ([]models.IngestDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+ingestDeliveryColumns+`
+		FROM ingest_delivery
+		WHERE ($1 = '' OR provider = $1) AND ($2 = '' OR status = $2)
+		ORDER BY received_at DESC, delivery_id DESC
+		LIMIT $3
+	`, filter.Provider, filter.Status, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询 Webhook 投递失败: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.IngestDelivery{}
+	for rows.Next() {
+		d, err := scanIngestDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描 Webhook 投递失败: %w", err)
+		}
+		deliveries = append(deliveries, *d)
+	}
+	return deliveries, rows.Err()
+}
+
+// ProviderEvents 按事件时刻读取已写入订单的投递，事件时刻截断到秒
+func (r *PostgresIngestRepository) ProviderEvents(ctx context.Context, filter models.ProviderEventFilter) ([]models.ProviderEvent, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT external_id, topic, EXTRACT(EPOCH FROM event_time)::BIGINT, order_no, merchant_id
+		FROM ingest_delivery
+		WHERE provider = $1 AND status IN ('processed', 'skipped') AND order_no IS NOT NULL AND merchant_id IS NOT NULL
+			AND event_time >= $2 AND event_time < $3 AND ($4 = 0 OR merchant_id = $4)
+		ORDER BY event_time, delivery_id
+		LIMIT $5
+	`, filter.Provider, filter.From, filter.To, filter.MerchantID, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询 Webhook 事件失败: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.ProviderEvent{}
+	for rows.Next() {
+		var e models.ProviderEvent
+		if err := rows.Scan(&e.ID, &e.Type, &e.Created, &e.OrderNumber, &e.MerchantID); err != nil {
+			return nil, fmt.Errorf("扫描 Webhook 事件失败: %w", err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// OrdersByNumber 从分析视图按订单号读取订单
+func (r *PostgresIngestRepository) OrdersByNumber(ctx context.Context, merchantID int, orderNumbers []string) ([]models.OrderAnalysis, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+orderAnalysisColumns+`
+		FROM dws_orders_analysis_view
+		WHERE order_number = ANY($1) AND ($2 = 0 OR merchant_id = $2)
+	`, pq.Array(orderNumbers), merchantID)
+	if err != nil {
+		return nil, fmt.Errorf("按订单号查询订单失败: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []models.OrderAnalysis
+	for rows.Next() {
+		order, err := scanOrderAnalysis(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历订单失败: %w", err)
+	}
+	return orders, nil
+}
+
+// scanIngestDelivery 扫描 ingestDeliveryColumns，extra 为其后追加的列
+func scanIngestDelivery(row interface{ Scan(...interface{}) error }, extra ...interface{}) (*models.IngestDelivery, error) {
+	var d models.IngestDelivery
+	var eventTime, processedAt sql.NullTime
+	var conflict []byte
+	dest := append([]interface{}{
+		&d.ID, &d.Provider, &d.ExternalID, &d.Topic, &d.MerchantID, &d.OrderNumber,
+		&d.OrderID, &eventTime, &d.Status, &d.Error, &d.Attempts, &d.ReceivedAt, &processedAt, &conflict,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if conflict != nil {
+		if err := json.Unmarshal(conflict, &d.Conflict); err != nil {
+			return nil, fmt.Errorf("解析冲突详情失败: %w", err)
+		}
+	}
+	if eventTime.Valid {
+		t := eventTime.Time.UTC()
+		d.EventTime = &t
+	}
+	if processedAt.Valid {
+		t := processedAt.Time
+		d.ProcessedAt = &t
+	}
+	return &d, nil
+}
@@ -0,0 +1,355 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// jobColumns job 的查询列，与 scanJob 的顺序一致
+const jobColumns = `
+	job_id, queue, kind, payload, status, attempts, max_attempts, run_at,
+	COALESCE(dedupe_key, ''), COALESCE(schedule_name, ''), COALESCE(locked_by, ''), locked_at,
+	last_error, result, created_at, updated_at, finished_at`
+
+// jobScheduleColumns job_schedule 的查询列，与 querySchedules 的读取顺序一致
+const jobScheduleColumns = `name, kind, payload, schedule, timezone, enabled, next_run_at, created_at, updated_at`
+
+// PostgresJobRepository 基于 job 和 job_schedule 表的后台任务仓储
+type PostgresJobRepository struct {
+	db *database.DB
+}
+
+// NewPostgresJobRepository 创建 PostgreSQL 后台任务仓储
+func NewPostgresJobRepository(db *database.DB) *PostgresJobRepository {
+	return &PostgresJobRepository{db: db}
+}
+
+// EnqueueJob 写入排队中的任务
+func (r *PostgresJobRepository) EnqueueJob(ctx context.Context, job *models.Job) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO job (queue, kind, payload, status, max_attempts, run_at, dedupe_key, schedule_name)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''))
+		RETURNING job_id, created_at, updated_at
+	`, job.Queue, job.Kind, jsonParam(job.Payload), job.Status, job.MaxAttempts, job.RunAt, job.DedupeKey, job.Schedule,
+	).Scan(&job.ID, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return jobError(err, job)
+	}
+	job.CreatedAt, job.UpdatedAt = job.CreatedAt.UTC(), job.UpdatedAt.UTC()
+	return nil
+}
+
+// ClaimJob 使用 FOR UPDATE SKIP LOCKED 领取任务，其他实例正在领取的行直接跳过而不是等待
+func (r *PostgresJobRepository) ClaimJob(ctx context.Context, queue, worker string, now time.Time) (*models.Job, error) {
+	row := r.db.QueryRowContext(ctx, `
+		UPDATE job SET status = 'running', attempts = attempts + 1, locked_by = $2, locked_at = $3
+		WHERE job_id = (
+			SELECT job_id FROM job
+			WHERE queue = $1 AND status = 'queued' AND run_at <= $3
+			ORDER BY run_at, job_id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+jobColumns, queue, worker, now)
+	job, err := scanJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("领取队列 %s 的任务失败: %w", queue, err)
+	}
+	return job, nil
+}
+
+// FinishJob 按 job 更新执行结果，只修改仍由 worker 执行的任务
+func (r *PostgresJobRepository) FinishJob(ctx context.Context, job *models.Job, worker string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE job SET
+			status = $3, attempts = $4, run_at = $5, last_error = $6, result = $7, finished_at = $8,
+			locked_by = NULL, locked_at = NULL
+		WHERE job_id = $1 AND status = 'running' AND locked_by = $2
+	`, job.ID, worker, job.Status, job.Attempts, job.RunAt, job.LastError, jsonParam(job.Result), job.FinishedAt)
+	if err != nil {
+		return false, fmt.Errorf("更新后台任务 %d 失败: %w", job.ID, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("更新后台任务 %d 失败: %w", job.ID, err)
+	}
+	return n == 1, nil
+}
+
+// HeartbeatJobs 刷新执行中任务的 locked_at
+func (r *PostgresJobRepository) HeartbeatJobs(ctx context.Context, worker string, ids []int64, now time.Time) ([]int64, error) {
+	alive := []int64{}
+	if len(ids) == 0 {
+		return alive, nil
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		UPDATE job SET locked_at = $3
+		WHERE job_id = ANY($1) AND status = 'running' AND locked_by = $2
+		RETURNING job_id
+	`, pq.Array(ids), worker, now)
+	if err != nil {
+		return nil, fmt.Errorf("刷新后台任务心跳失败: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("刷新后台任务心跳失败: %w", err)
+		}
+		alive = append(alive, id)
+	}
+	return alive, rows.Err()
+}
+
+// RequeueStaleJobs 回收心跳超时的任务
+func (r *PostgresJobRepository) RequeueStaleJobs(ctx context.Context, before, now time.Time, reason string) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE job SET
+			status = CASE WHEN attempts >= max_attempts THEN 'failed' ELSE 'queued' END,
+			finished_at = CASE WHEN attempts >= max_attempts THEN $2::timestamptz END,
+			run_at = $2, last_error = $3, locked_by = NULL, locked_at = NULL
+		WHERE status = 'running' AND locked_at < $1
+	`, before, now, reason)
+	if err != nil {
+		return 0, fmt.Errorf("回收超时的后台任务失败: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("回收超时的后台任务失败: %w", err)
+	}
+	return n, nil
+}
+
+// Jobs 按条件筛选的任务
+func (r *PostgresJobRepository) Jobs(ctx context.Context, filter models.JobFilter) ([]models.Job, error) {
+	var conditions []string
+	var args []interface{}
+	for _, c := range []struct{ column, value string }{
+		{"queue", filter.Queue}, {"kind", filter.Kind}, {"status", filter.Status},
+	} {
+		if c.value != "" {
+			args = append(args, c.value)
+			conditions = append(conditions, fmt.Sprintf("%s = $%d", c.column, len(args)))
+		}
+	}
+	query := `SELECT ` + jobColumns + ` FROM job`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(` ORDER BY job_id DESC LIMIT $%d`, len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询后台任务失败: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []models.Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("读取后台任务失败: %w", err)
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, rows.Err()
+}
+
+// Job 单个任务
+func (r *PostgresJobRepository) Job(ctx context.Context, id int64) (*models.Job, error) {
+	job, err := scanJob(r.db.QueryRowContext(ctx, `SELECT `+jobColumns+` FROM job WHERE job_id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: 后台任务 %d", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询后台任务 %d 失败: %w", id, err)
+	}
+	return job, nil
+}
+
+// RetryJob 失败或已取消的任务重新排队
+func (r *PostgresJobRepository) RetryJob(ctx context.Context, id int64, now time.Time) (*models.Job, error) {
+	job, err := scanJob(r.db.QueryRowContext(ctx, `
+		UPDATE job SET status = 'queued', attempts = 0, run_at = $2, last_error = '', result = NULL, finished_at = NULL
+		WHERE job_id = $1 AND status IN ('failed', 'cancelled')
+		RETURNING `+jobColumns, id, now))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, r.transitionError(ctx, id, "重试")
+	}
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return nil, fmt.Errorf("%w: 后台任务 %d 的去重键已有排队或执行中的任务", ErrConflict, id)
+		}
+		return nil, fmt.Errorf("重试后台任务 %d 失败: %w", id, err)
+	}
+	return job, nil
+}
+
+// CancelJob 取消排队或执行中的任务，执行中的任务由执行它的实例在下一次检查时停止
+func (r *PostgresJobRepository) CancelJob(ctx context.Context, id int64, now time.Time) (*models.Job, error) {
+	job, err := scanJob(r.db.QueryRowContext(ctx, `
+		UPDATE job SET status = 'cancelled', finished_at = $2, locked_by = NULL, locked_at = NULL
+		WHERE job_id = $1 AND status IN ('queued', 'running')
+		RETURNING `+jobColumns, id, now))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, r.transitionError(ctx, id, "取消")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("取消后台任务 %d 失败: %w", id, err)
+	}
+	return job, nil
+}
+
+// transitionError 状态不允许 action 时区分任务不存在和状态冲突
+func (r *PostgresJobRepository) transitionError(ctx context.Context, id int64, action string) error {
+	job, err := r.Job(ctx, id)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: 后台任务 %d 状态为 %s，不能%s", ErrConflict, id, job.Status, action)
+}
+
+// jobError 去重键重复映射为 ErrConflict
+func jobError(err error, job *models.Job) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return fmt.Errorf("%w: 去重键 %s 已有排队或执行中的任务", ErrConflict, job.DedupeKey)
+	}
+	return fmt.Errorf("写入后台任务失败: %w", err)
+}
+
+// SaveSchedule 新增或覆盖定时计划
+func (r *PostgresJobRepository) SaveSchedule(ctx context.Context, schedule *models.JobSchedule) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO job_schedule (name, kind, payload, schedule, timezone, enabled, next_run_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (name) DO UPDATE SET
+			kind = EXCLUDED.kind, payload = EXCLUDED.payload, schedule = EXCLUDED.schedule,
+			timezone = EXCLUDED.timezone, enabled = EXCLUDED.enabled, next_run_at = EXCLUDED.next_run_at
+		RETURNING created_at, updated_at
+	`, schedule.Name, schedule.Kind, jsonParam(schedule.Payload), schedule.Schedule, schedule.Timezone,
+		schedule.Enabled, schedule.NextRunAt,
+	).Scan(&schedule.CreatedAt, &schedule.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("保存定时计划 %s 失败: %w", schedule.Name, err)
+	}
+	schedule.CreatedAt, schedule.UpdatedAt = schedule.CreatedAt.UTC(), schedule.UpdatedAt.UTC()
+	return nil
+}
+
+// DeleteSchedule 删除定时计划
+func (r *PostgresJobRepository) DeleteSchedule(ctx context.Context, name string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM job_schedule WHERE name = $1`, name)
+	if err != nil {
+		return fmt.Errorf("删除定时计划 %s 失败: %w", name, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: 定时计划 %s", ErrNotFound, name)
+	}
+	return nil
+}
+
+// Schedules 全部定时计划
+func (r *PostgresJobRepository) Schedules(ctx context.Context) ([]models.JobSchedule, error) {
+	return r.querySchedules(ctx, `SELECT `+jobScheduleColumns+` FROM job_schedule ORDER BY name`)
+}
+
+// DueSchedules 到期的定时计划
+func (r *PostgresJobRepository) DueSchedules(ctx context.Context, at time.Time) ([]models.JobSchedule, error) {
+	return r.querySchedules(ctx, `
+		SELECT `+jobScheduleColumns+` FROM job_schedule
+		WHERE enabled AND next_run_at <= $1
+		ORDER BY next_run_at, name
+	`, at)
+}
+
+func (r *PostgresJobRepository) querySchedules(ctx context.Context, query string, args ...interface{}) ([]models.JobSchedule, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询定时计划失败: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := []models.JobSchedule{}
+	for rows.Next() {
+		var s models.JobSchedule
+		var payload []byte
+		var next sql.NullTime
+		if err := rows.Scan(&s.Name, &s.Kind, &payload, &s.Schedule, &s.Timezone, &s.Enabled, &next, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("读取定时计划失败: %w", err)
+		}
+		s.Payload = json.RawMessage(payload)
+		s.NextRunAt = utcTimePtr(next)
+		s.CreatedAt, s.UpdatedAt = s.CreatedAt.UTC(), s.UpdatedAt.UTC()
+		schedules = append(schedules, s)
+	}
+	return schedules, rows.Err()
+}
+
+// ClaimSchedule 以 next_run_at 作为乐观锁，多个实例同时调度时只有一个领取成功
+func (r *PostgresJobRepository) ClaimSchedule(ctx context.Context, name string, due time.Time, next *time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE job_schedule SET next_run_at = $3
+		WHERE name = $1 AND next_run_at = $2
+	`, name, due, next)
+	if err != nil {
+		return false, fmt.Errorf("领取定时计划 %s 失败: %w", name, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("领取定时计划 %s 失败: %w", name, err)
+	}
+	return n == 1, nil
+}
+
+func scanJob(row interface{ Scan(...interface{}) error }) (*models.Job, error) {
+	var job models.Job
+	var payload, result []byte
+	var lockedAt, finishedAt sql.NullTime
+	err := row.Scan(
+		&job.ID, &job.Queue, &job.Kind, &payload, &job.Status, &job.Attempts, &job.MaxAttempts, &job.RunAt,
+		&job.DedupeKey, &job.Schedule, &job.LockedBy, &lockedAt,
+		&job.LastError, &result, &job.CreatedAt, &job.UpdatedAt, &finishedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	job.Payload = json.RawMessage(payload)
+	if len(result) > 0 {
+		job.Result = json.RawMessage(result)
+	}
+	job.RunAt, job.CreatedAt, job.UpdatedAt = job.RunAt.UTC(), job.CreatedAt.UTC(), job.UpdatedAt.UTC()
+	job.LockedAt, job.FinishedAt = utcTimePtr(lockedAt), utcTimePtr(finishedAt)
+	return &job, nil
+}
+
+// jsonParam jsonb 参数：为空时写 NULL，否则以字符串传入，避免 []byte 被当作 bytea
+func jsonParam(raw json.RawMessage) interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
+}
+
+func utcTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	utc := t.Time.UTC()
+	return &utc
+}
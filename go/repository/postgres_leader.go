@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"timezone-saas-demo/database"
+)
+
+// PostgresAdvisoryLock 基于会话级咨询锁（pg_try_advisory_lock）的排他锁
+// 锁属于一个数据库会话，持有期间占用连接池中的一个连接；进程退出或连接断开时数据库自动释放锁，
+// 不需要过期时间，其他实例在下一次尝试时即可获取
+type PostgresAdvisoryLock struct {
+	db   *database.DB
+	name string
+	key  int64
+
+	mu sync.Mutex
+	// conn 持有锁的连接，未持有锁时为 nil
+	conn *sql.Conn
+}
+
+// NewPostgresAdvisoryLock 创建名为 name 的咨询锁，锁的键为名称的 FNV-64a 哈希
+func NewPostgresAdvisoryLock(db *database.DB, name string) *PostgresAdvisoryLock {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return &PostgresAdvisoryLock{db: db, name: name, key: int64(h.Sum64())}
+}
+
+// TryAcquire 在单独的连接上尝试获取锁，获取失败时把连接还给连接池
+func (l *PostgresAdvisoryLock) TryAcquire(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn != nil {
+		return true, nil
+	}
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("获取锁 %s 的连接失败: %w", l.name, err)
+	}
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, l.key).Scan(&acquired); err != nil {
+		discardConn(conn)
+		return false, fmt.Errorf("获取锁 %s 失败: %w", l.name, err)
+	}
+	if !acquired {
+		conn.Close()
+		return false, nil
+	}
+	l.conn = conn
+	return true, nil
+}
+
+// Renew 在持有锁的连接上查询 pg_locks，确认会话仍然存在且锁仍然有效
+// bigint 键的咨询锁在 pg_locks 中拆为 classid（高 32 位）和 objid（低 32 位），objsubid 为 1
+func (l *PostgresAdvisoryLock) Renew(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return false, nil
+	}
+	var held bool
+	err := l.conn.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM pg_locks
+			WHERE locktype = 'advisory' AND pid = pg_backend_pid() AND granted
+				AND classid = $1::bigint::oid AND objid = $2::bigint::oid AND objsubid = 1
+		)
+	`, int64(uint64(l.key)>>32), int64(uint32(l.key))).Scan(&held)
+	if err != nil || !held {
+		// 连接已不可用或锁已丢失，丢弃连接，数据库随会话释放残留的锁
+		discardConn(l.conn)
+		l.conn = nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("续约锁 %s 失败: %w", l.name, err)
+	}
+	return held, nil
+}
+
+// Release 释放锁并把连接还给连接池
+func (l *PostgresAdvisoryLock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return nil
+	}
+	conn := l.conn
+	l.conn = nil
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, l.key); err != nil {
+		// 解锁失败时不能把仍持有锁的会话还给连接池
+		discardConn(conn)
+		return fmt.Errorf("释放锁 %s 失败: %w", l.name, err)
+	}
+	return conn.Close()
+}
+
+// discardConn 关闭连接而不是还给连接池：Raw 的回调返回 driver.ErrBadConn 时 database/sql 丢弃该连接
+func discardConn(conn *sql.Conn) {
+	conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	conn.Close()
+}
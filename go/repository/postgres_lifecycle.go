@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// PostgresLifecycleRepository 基于 dim_merchant.lifecycle / tenant_lifecycle_event 表的租户生命周期仓储
+type PostgresLifecycleRepository struct {
+	db *database.DB
+}
+
+// NewPostgresLifecycleRepository 创建 PostgreSQL 租户生命周期仓储
+func NewPostgresLifecycleRepository(db *database.DB) *PostgresLifecycleRepository {
+	return &PostgresLifecycleRepository{db: db}
+}
+
+// lifecycleColumns 与 scanLifecycle 的字段顺序一致
+const lifecycleColumns = `merchant_id, timezone, lifecycle, COALESCE(to_char(trial_ends_on, 'YYYY-MM-DD'), ''), lifecycle_changed_at`
+
+// Lifecycle 商户当前的生命周期状态
+func (r *PostgresLifecycleRepository) Lifecycle(ctx context.Context, merchantID int) (*models.TenantLifecycle, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+lifecycleColumns+` FROM dim_merchant WHERE merchant_id = $1`, merchantID)
+	l, err := scanLifecycle(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: 商户 %d", ErrNotFound, merchantID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询商户 %d 的生命周期失败: %w", merchantID, err)
+	}
+	return l, nil
+}
+
+// Transition 在一个事务中按状态条件更新商户并写入变更记录，条件不满足时区分商户不存在和状态已变化
+func (r *PostgresLifecycleRepository) Transition(ctx context.Context, e *models.TenantLifecycleEvent) error {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE dim_merchant
+		SET lifecycle = $2, trial_ends_on = NULLIF($3, '')::date, lifecycle_changed_at = CURRENT_TIMESTAMP
+		WHERE merchant_id = $1 AND lifecycle = $4
+	`, e.MerchantID, e.To, e.TrialEndsOn, e.From)
+	if err != nil {
+		return fmt.Errorf("更新商户 %d 的生命周期失败: %w", e.MerchantID, err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("更新商户 %d 的生命周期失败: %w", e.MerchantID, err)
+	} else if n == 0 {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM dim_merchant WHERE merchant_id = $1)`, e.MerchantID).Scan(&exists); err != nil {
+			return fmt.Errorf("查询商户 %d 失败: %w", e.MerchantID, err)
+		}
+		if !exists {
+			return fmt.Errorf("%w: 商户 %d", ErrNotFound, e.MerchantID)
+		}
+		return fmt.Errorf("%w: 商户 %d 的状态已不是 %s", ErrConflict, e.MerchantID, e.From)
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO tenant_lifecycle_event (merchant_id, from_state, to_state, trial_ends_on, reason, changed_by)
+		VALUES ($1, $2, $3, NULLIF($4, '')::date, $5, $6)
+		RETURNING event_id, changed_at
+	`, e.MerchantID, e.From, e.To, e.TrialEndsOn, e.Reason, e.ChangedBy).Scan(&e.EventID, &e.ChangedAt)
+	if err != nil {
+		return fmt.Errorf("写入生命周期变更记录失败: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交生命周期变更失败: %w", err)
+	}
+	return nil
+}
+
+// Events 商户最近的变更记录
+func (r *PostgresLifecycleRepository) Events(ctx context.Context, merchantID, limit int) ([]models.TenantLifecycleEvent, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT event_id, merchant_id, from_state, to_state, COALESCE(to_char(trial_ends_on, 'YYYY-MM-DD'), ''),
+			reason, changed_by, changed_at
+		FROM tenant_lifecycle_event
+		WHERE merchant_id = $1
+		ORDER BY event_id DESC
+		LIMIT $2
+	`, merchantID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询生命周期变更记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.TenantLifecycleEvent
+	for rows.Next() {
+		var e models.TenantLifecycleEvent
+		if err := rows.Scan(&e.EventID, &e.MerchantID, &e.From, &e.To, &e.TrialEndsOn, &e.Reason, &e.ChangedBy, &e.ChangedAt); err != nil {
+			return nil, fmt.Errorf("扫描生命周期变更记录失败: %w", err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历生命周期变更记录失败: %w", err)
+	}
+	return events, nil
+}
+
+// Trials 全部试用中的商户
+func (r *PostgresLifecycleRepository) Trials(ctx context.Context) ([]models.TenantLifecycle, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+lifecycleColumns+` FROM dim_merchant
+		WHERE lifecycle = 'trial'
+		ORDER BY trial_ends_on, merchant_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("查询试用中的商户失败: %w", err)
+	}
+	defer rows.Close()
+
+	var trials []models.TenantLifecycle
+	for rows.Next() {
+		l, err := scanLifecycle(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描试用中的商户失败: %w", err)
+		}
+		trials = append(trials, *l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历试用中的商户失败: %w", err)
+	}
+	return trials, nil
+}
+
+// scanLifecycle 扫描一行 lifecycleColumns
+func scanLifecycle(row rowScanner) (*models.TenantLifecycle, error) {
+	var l models.TenantLifecycle
+	var changedAt sql.NullTime
+	if err := row.Scan(&l.MerchantID, &l.Timezone, &l.State, &l.TrialEndsOn, &changedAt); err != nil {
+		return nil, err
+	}
+	if changedAt.Valid {
+		t := changedAt.Time.UTC()
+		l.ChangedAt = &t
+	}
+	return &l, nil
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// PostgresMerchantRepository 基于 dim_merchant 表的商户仓储
+type PostgresMerchantRepository struct {
+	db *database.DB
+}
+
+// NewPostgresMerchantRepository 创建 PostgreSQL 商户仓储
+func NewPostgresMerchantRepository(db *database.DB) *PostgresMerchantRepository {
+	return &PostgresMerchantRepository{db: db}
+}
+
+// merchantColumns 商户查询列，与 scanMerchant 的扫描顺序一致
+const merchantColumns = `
+	merchant_id, merchant_name, timezone, country, city, COALESCE(country_code, ''), city_id,
+	TO_CHAR(business_hours_start, 'HH24:MI'), TO_CHAR(business_hours_end, 'HH24:MI'),
+	weekend_days, created_at, updated_at
+`
+
+// rowScanner sql.Row 和 sql.Rows 的公共扫描接口
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanMerchant 扫描一行商户数据，extra 为 merchantColumns 之后追加的列
+func scanMerchant(row rowScanner, extra ...interface{}) (models.Merchant, error) {
+	var merchant models.Merchant
+	var weekendDays pq.Int64Array
+	var cityID sql.NullInt64
+	dest := []interface{}{
+		&merchant.ID,
+		&merchant.Name,
+		&merchant.Timezone,
+		&merchant.Country,
+		&merchant.City,
+		&merchant.CountryCode,
+		&cityID,
+		&merchant.BusinessHoursStart,
+		&merchant.BusinessHoursEnd,
+		&weekendDays,
+		&merchant.CreatedAt,
+		&merchant.UpdatedAt,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	merchant.WeekendDays = intsFromArray(weekendDays)
+	if cityID.Valid {
+		id := int(cityID.Int64)
+		merchant.CityID = &id
+	}
+	return merchant, err
+}
+
+// intsFromArray 将 SMALLINT[] 转换为 []int，空数组返回非 nil 的空切片
+func intsFromArray(values pq.Int64Array) []int {
+	result := make([]int, len(values))
+	for i, v := range values {
+		result[i] = int(v)
+	}
+	return result
+}
+
+// List 获取所有商户
+func (r *PostgresMerchantRepository) List() ([]models.Merchant, error) {
+	query := `SELECT ` + merchantColumns + ` FROM dim_merchant ORDER BY merchant_name`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("查询商户失败: %w", err)
+	}
+	defer rows.Close()
+
+	var merchants []models.Merchant
+	for rows.Next() {
+		merchant, err := scanMerchant(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描商户数据失败: %w", err)
+		}
+		merchants = append(merchants, merchant)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历商户数据失败: %w", err)
+	}
+
+	return merchants, nil
+}
+
+// Get 按ID获取商户
+func (r *PostgresMerchantRepository) Get(id int) (*models.Merchant, error) {
+	query := `SELECT ` + merchantColumns + ` FROM dim_merchant WHERE merchant_id = $1`
+
+	merchant, err := scanMerchant(r.db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: 商户 %d", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询商户失败: %w", err)
+	}
+	return &merchant, nil
+}
+
+// Count 获取商户数量
+func (r *PostgresMerchantRepository) Count() (int, error) {
+	return r.db.GetTableRowCount("dim_merchant")
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// uniqueViolation PostgreSQL 唯一约束冲突的错误码
+const uniqueViolation = "23505"
+
+// PostgresOnboardingRepository 基于 dim_merchant / merchant_report_settings / merchant_webhook 表的入驻仓储
+type PostgresOnboardingRepository struct {
+	db *database.DB
+}
+
+// NewPostgresOnboardingRepository 创建 PostgreSQL 入驻仓储
+func NewPostgresOnboardingRepository(db *database.DB) *PostgresOnboardingRepository {
+	return &PostgresOnboardingRepository{db: db}
+}
+
+// Onboard 在一个事务中创建商户并写入默认配置
+func (r *PostgresOnboardingRepository) Onboard(o *models.MerchantOnboarding) error {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertOnboarding(context.Background(), tx, o); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交入驻数据失败: %w", err)
+	}
+	return nil
+}
+
+// Import 在一个事务中依次创建商户
+func (r *PostgresOnboardingRepository) Import(ctx context.Context, onboardings []models.MerchantOnboarding) error {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i := range onboardings {
+		if err := insertOnboarding(ctx, tx, &onboardings[i]); err != nil {
+			return fmt.Errorf("导入商户 %s 失败: %w", onboardings[i].Merchant.Name, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交导入数据失败: %w", err)
+	}
+	return nil
+}
+
+// Catalog 全部商户及其编码和状态
+func (r *PostgresOnboardingRepository) Catalog(ctx context.Context) ([]models.MerchantRecord, error) {
+	query := `SELECT ` + merchantColumns + `, merchant_code, COALESCE(status, 'active') FROM dim_merchant ORDER BY merchant_id`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("查询商户目录失败: %w", err)
+	}
+	defer rows.Close()
+
+	var records []models.MerchantRecord
+	for rows.Next() {
+		var rec models.MerchantRecord
+		merchant, err := scanMerchant(rows, &rec.Code, &rec.Status)
+		if err != nil {
+			return nil, fmt.Errorf("扫描商户目录失败: %w", err)
+		}
+		rec.Merchant = merchant
+		records = append(records, rec)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历商户目录失败: %w", err)
+	}
+	return records, nil
+}
+
+// insertOnboarding 在事务中写入商户、报表配置和 Webhook 配置，回填生成的ID
+func insertOnboarding(ctx context.Context, tx *sql.Tx, o *models.MerchantOnboarding) error {
+	m := &o.Merchant
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO dim_merchant (
+			merchant_name, merchant_code, country, city, country_code, city_id, timezone, status,
+			business_hours_start, business_hours_end, weekend_days
+		) VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, 'active', $8, $9, $10)
+		RETURNING merchant_id, created_at, updated_at
+	`, m.Name, o.MerchantCode, m.Country, m.City, m.CountryCode, m.CityID, m.Timezone, m.BusinessHoursStart, m.BusinessHoursEnd,
+		pq.Array(m.WeekendDays),
+	).Scan(&m.ID, &m.CreatedAt, &m.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return fmt.Errorf("%w: 商户编码 %s", ErrConflict, o.MerchantCode)
+		}
+		return fmt.Errorf("创建商户失败: %w", err)
+	}
+
+	s := &o.ReportSettings
+	s.MerchantID = m.ID
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO merchant_report_settings (
+			merchant_id, daily_report_enabled, daily_report_time, weekly_report_enabled, week_start_day
+		) VALUES ($1, $2, $3, $4, $5)
+	`, s.MerchantID, s.DailyReportEnabled, s.DailyReportTime, s.WeeklyReportEnabled, s.WeekStartDay)
+	if err != nil {
+		return fmt.Errorf("写入报表配置失败: %w", err)
+	}
+
+	for i := range o.Webhooks {
+		w := &o.Webhooks[i]
+		w.MerchantID = m.ID
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO merchant_webhook (merchant_id, event_type, url, enabled)
+			VALUES ($1, $2, $3, $4)
+			RETURNING webhook_id
+		`, w.MerchantID, w.EventType, w.URL, w.Enabled).Scan(&w.WebhookID)
+		if err != nil {
+			return fmt.Errorf("写入 Webhook 配置 %s 失败: %w", w.EventType, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// orderAnalysisColumns 分析视图中订单的查询列，与 scanOrderAnalysis 的扫描顺序一致
+const orderAnalysisColumns = `
+			order_id, order_number, amount, currency, status,
+			merchant_id, merchant_name, timezone, country, city,
+			order_time_utc, order_time_local, local_date,
+			local_hour, local_day_of_week, local_weekday,
+			is_weekend, is_business_hour, timezone_offset, ingested_at, weekend_days`
+
+// orderColumnsByFormatting 各格式化方式的查询列，sql 方式由数据库格式化日期，raw 方式不查询 local_date、local_weekday
+var orderColumnsByFormatting = map[string]string{
+	models.OrderFormattingGo: orderAnalysisColumns,
+	models.OrderFormattingSQL: `
+			order_id, order_number, amount, currency, status,
+			merchant_id, merchant_name, timezone, country, city,
+			order_time_utc, order_time_local, TO_CHAR(local_date, 'YYYY-MM-DD'),
+			local_hour, local_day_of_week, btrim(local_weekday),
+			is_weekend, is_business_hour, timezone_offset, ingested_at, weekend_days`,
+	models.OrderFormattingRaw: `
+			order_id, order_number, amount, currency, status,
+			merchant_id, merchant_name, timezone, country, city,
+			order_time_utc, order_time_local,
+			local_hour, local_day_of_week,
+			is_weekend, is_business_hour, timezone_offset, ingested_at, weekend_days`,
+}
+
+// PostgresOrderRepository 基于 dws_orders_analysis_view 视图的订单仓储
+type PostgresOrderRepository struct {
+	db *database.DB
+}
+
+// NewPostgresOrderRepository 创建 PostgreSQL 订单仓储
+func NewPostgresOrderRepository(db *database.DB) *PostgresOrderRepository {
+	return &PostgresOrderRepository{db: db}
+}
+
+// List 分页获取订单分析数据
+// 时区和状态为空时不过滤
+func (r *PostgresOrderRepository) List(filter models.OrderFilter) ([]models.OrderAnalysis, error) {
+	var orders []models.OrderAnalysis
+	err := r.Stream(context.Background(), filter, func(order models.OrderAnalysis) error {
+		orders = append(orders, order)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// Stream 逐行扫描订单并回调，不在内存中累积结果；filter.Limit 为 0 时不限制条数
+// filter.Formatting 决定 local_date、local_weekday 在数据库还是 Go 中格式化，或者不返回
+func (r *PostgresOrderRepository) Stream(ctx context.Context, filter models.OrderFilter, fn func(models.OrderAnalysis) error) error {
+	formatting := filter.Formatting
+	if formatting == "" {
+		formatting = models.OrderFormattingGo
+	}
+	columns, ok := orderColumnsByFormatting[formatting]
+	if !ok {
+		return fmt.Errorf("不支持的订单格式化方式: %s", formatting)
+	}
+	args := []interface{}{filter.Timezone, pq.Array(filter.Statuses), filter.Limit, filter.Offset}
+	query := `
+		SELECT ` + columns + `
+		FROM dws_orders_analysis_view v
+		WHERE ($1 = '' OR timezone = $1)
+			AND (COALESCE(cardinality($2::text[]), 0) = 0 OR status = ANY($2::text[]))` + orderScopeCondition(filter, &args) + orderAttributesCondition(filter, &args) + `
+		ORDER BY order_time_utc DESC
+		LIMIT NULLIF($3, 0) OFFSET $4
+	`
+
+	ctx, cancel := context.WithCancel(ctx)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("查询订单失败: %w", err)
+	}
+	defer rows.Close()
+	// 先于 rows.Close 执行：回调提前返回时取消查询，避免 Close 读完剩余的行
+	defer cancel()
+
+	for rows.Next() {
+		order, err := scanOrderAnalysisAs(rows, formatting)
+		if err != nil {
+			return err
+		}
+		if err := fn(order); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("遍历订单数据失败: %w", err)
+	}
+
+	return nil
+}
+
+// EstimateCount 估算符合条件的订单数
+// 不过滤时读取 dws_orders 的统计行数（reltuples），否则取查询计划的估计行数，避免对大表 COUNT(*)
+func (r *PostgresOrderRepository) EstimateCount(ctx context.Context, filter models.OrderFilter) (int64, error) {
+	if filter.Timezone == "" && len(filter.Statuses) == 0 && len(filter.Attributes) == 0 && filter.OrderID == 0 && len(filter.MerchantIDs) == 0 && filter.From == "" && filter.To == "" {
+		var estimate float64
+		err := r.db.QueryRowContext(ctx, `SELECT reltuples FROM pg_class WHERE oid = 'dws_orders'::regclass`).Scan(&estimate)
+		if err != nil {
+			return 0, fmt.Errorf("估算订单数量失败: %w", err)
+		}
+		// 表从未 ANALYZE 时 reltuples 为 -1，改用查询计划估算
+		if estimate >= 0 {
+			return int64(estimate), nil
+		}
+	}
+
+	args := []interface{}{filter.Timezone, pq.Array(filter.Statuses)}
+	query := `
+		EXPLAIN (FORMAT JSON)
+		SELECT 1 FROM dws_orders_analysis_view v
+		WHERE ($1 = '' OR timezone = $1)
+			AND (COALESCE(cardinality($2::text[]), 0) = 0 OR status = ANY($2::text[]))` + orderScopeCondition(filter, &args) + orderAttributesCondition(filter, &args) + `
+	`
+	var raw []byte
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&raw); err != nil {
+		return 0, fmt.Errorf("估算订单数量失败: %w", err)
+	}
+	var plans []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal(raw, &plans); err != nil || len(plans) == 0 {
+		return 0, fmt.Errorf("解析查询计划失败: %v", err)
+	}
+	return int64(plans[0].Plan.Rows), nil
+}
+
+// orderScopeCondition 按订单ID、商户和本地日期范围过滤的条件，只为指定的条件追加参数
+func orderScopeCondition(filter models.OrderFilter, args *[]interface{}) string {
+	var condition string
+	if filter.OrderID > 0 {
+		*args = append(*args, filter.OrderID)
+		condition += fmt.Sprintf(`
+			AND order_id = $%d`, len(*args))
+	}
+	if len(filter.MerchantIDs) > 0 {
+		*args = append(*args, pq.Array(filter.MerchantIDs))
+		condition += fmt.Sprintf(`
+			AND merchant_id = ANY($%d::int[])`, len(*args))
+	}
+	if filter.From != "" {
+		*args = append(*args, filter.From)
+		condition += fmt.Sprintf(`
+			AND local_date >= $%d::date`, len(*args))
+	}
+	if filter.To != "" {
+		*args = append(*args, filter.To)
+		condition += fmt.Sprintf(`
+			AND local_date <= $%d::date`, len(*args))
+	}
+	return condition
+}
+
+// orderAttributesCondition 按自定义属性过滤的条件，追加租户和属性两个参数，filter.Attributes 为空时返回空字符串
+// 订单的有效取值为商户取值叠加订单取值（同名的键以订单为准）；只在需要时引用属性表，未执行迁移时不影响其他查询
+func orderAttributesCondition(filter models.OrderFilter, args *[]interface{}) string {
+	if len(filter.Attributes) == 0 {
+		return ""
+	}
+	*args = append(*args, filter.Tenant, string(filter.Attributes))
+	tenant, attributes := len(*args)-1, len(*args)
+	return fmt.Sprintf(`
+			AND (
+				COALESCE((SELECT ma.attributes FROM merchant_attributes ma WHERE ma.tenant = $%[1]d AND ma.merchant_id = v.merchant_id), '{}')
+				|| COALESCE((SELECT oa.attributes FROM order_attributes oa WHERE oa.tenant = $%[1]d AND oa.order_id = v.order_id), '{}')
+			) @> $%[2]d::jsonb`, tenant, attributes)
+}
+
+// Count 获取订单数量
+func (r *PostgresOrderRepository) Count() (int, error) {
+	return r.db.GetTableRowCount("dws_orders")
+}
+
+// scanOrderAnalysis 按 orderAnalysisColumns 的列顺序扫描一行订单
+func scanOrderAnalysis(rows *sql.Rows) (models.OrderAnalysis, error) {
+	return scanOrderAnalysisAs(rows, models.OrderFormattingGo)
+}
+
+// scanOrderAnalysisAs 按 orderColumnsByFormatting[formatting] 的列顺序扫描一行订单
+func scanOrderAnalysisAs(rows *sql.Rows, formatting string) (models.OrderAnalysis, error) {
+	var order models.OrderAnalysis
+	var localDate time.Time
+	var localWeekday string
+	var weekendDays pq.Int64Array
+
+	dest := make([]interface{}, 0, 21)
+	dest = append(dest,
+		&order.OrderID,
+		&order.OrderNumber,
+		&order.Amount,
+		&order.Currency,
+		&order.Status,
+		&order.MerchantID,
+		&order.MerchantName,
+		&order.Timezone,
+		&order.Country,
+		&order.City,
+		&order.OrderTimeUTC,
+		&order.OrderTimeLocal,
+	)
+	switch formatting {
+	case models.OrderFormattingSQL:
+		dest = append(dest, &order.LocalDate, &order.LocalHour, &order.LocalDayOfWeek, &order.LocalWeekday)
+	case models.OrderFormattingRaw:
+		dest = append(dest, &order.LocalHour, &order.LocalDayOfWeek)
+	default:
+		dest = append(dest, &localDate, &order.LocalHour, &order.LocalDayOfWeek, &localWeekday)
+	}
+	dest = append(dest,
+		&order.IsWeekend,
+		&order.IsBusinessHour,
+		&order.TimezoneOffset,
+		&order.IngestedAt,
+		&weekendDays,
+	)
+
+	if err := rows.Scan(dest...); err != nil {
+		return order, fmt.Errorf("扫描订单数据失败: %w", err)
+	}
+
+	if formatting == models.OrderFormattingGo || formatting == "" {
+		order.LocalDate = localDate.Format("2006-01-02")
+		order.LocalWeekday = strings.TrimSpace(localWeekday)
+	}
+	order.WeekendDays = intsFromArray(weekendDays)
+	return order, nil
+}
@@ -0,0 +1,325 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// PostgresOrganizationRepository 基于 organization / organization_token 表和 dim_merchant.organization_id 的组织仓储
+type PostgresOrganizationRepository struct {
+	db *database.DB
+}
+
+// NewPostgresOrganizationRepository 创建 PostgreSQL 组织仓储
+func NewPostgresOrganizationRepository(db *database.DB) *PostgresOrganizationRepository {
+	return &PostgresOrganizationRepository{db: db}
+}
+
+// organizationQuery 组织及其门店ID，%s 为 WHERE 条件
+const organizationQuery = `
+	SELECT
+		o.organization_id, o.name, o.hq_timezone, o.created_at, o.updated_at,
+		COALESCE(array_agg(m.merchant_id ORDER BY m.merchant_id) FILTER (WHERE m.merchant_id IS NOT NULL), '{}')
+	FROM organization o
+	LEFT JOIN dim_merchant m ON m.organization_id = o.organization_id
+	%s
+	GROUP BY o.organization_id
+	ORDER BY o.organization_id
+`
+
+// Create 在一个事务中创建组织并划入门店
+func (r *PostgresOrganizationRepository) Create(ctx context.Context, org *models.Organization) error {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO organization (name, hq_timezone) VALUES ($1, $2)
+		RETURNING organization_id, created_at, updated_at
+	`, org.Name, org.HQTimezone).Scan(&org.ID, &org.CreatedAt, &org.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return fmt.Errorf("%w: 组织名称 %s", ErrConflict, org.Name)
+		}
+		return fmt.Errorf("创建组织失败: %w", err)
+	}
+	if err := assignMerchants(ctx, tx, org.ID, org.MerchantIDs); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交组织失败: %w", err)
+	}
+	org.CreatedAt, org.UpdatedAt = org.CreatedAt.UTC(), org.UpdatedAt.UTC()
+	return nil
+}
+
+// List 全部组织
+func (r *PostgresOrganizationRepository) List(ctx context.Context) ([]models.Organization, error) {
+	return r.query(ctx, fmt.Sprintf(organizationQuery, ""))
+}
+
+// Get 按ID获取组织
+func (r *PostgresOrganizationRepository) Get(ctx context.Context, id int) (*models.Organization, error) {
+	orgs, err := r.query(ctx, fmt.Sprintf(organizationQuery, "WHERE o.organization_id = $1"), id)
+	if err != nil {
+		return nil, err
+	}
+	if len(orgs) == 0 {
+		return nil, fmt.Errorf("%w: 组织 %d", ErrNotFound, id)
+	}
+	return &orgs[0], nil
+}
+
+// query 执行组织查询
+func (r *PostgresOrganizationRepository) query(ctx context.Context, query string, args ...interface{}) ([]models.Organization, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询组织失败: %w", err)
+	}
+	defer rows.Close()
+
+	var orgs []models.Organization
+	for rows.Next() {
+		var org models.Organization
+		var merchantIDs pq.Int64Array
+		if err := rows.Scan(&org.ID, &org.Name, &org.HQTimezone, &org.CreatedAt, &org.UpdatedAt, &merchantIDs); err != nil {
+			return nil, fmt.Errorf("扫描组织失败: %w", err)
+		}
+		org.CreatedAt, org.UpdatedAt = org.CreatedAt.UTC(), org.UpdatedAt.UTC()
+		org.MerchantIDs = intsFromArray(merchantIDs)
+		orgs = append(orgs, org)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历组织失败: %w", err)
+	}
+	return orgs, nil
+}
+
+// SetMerchants 替换组织的门店
+func (r *PostgresOrganizationRepository) SetMerchants(ctx context.Context, id int, merchantIDs []int) error {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE organization SET updated_at = CURRENT_TIMESTAMP WHERE organization_id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("更新组织失败: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("更新组织失败: %w", err)
+	} else if n == 0 {
+		return fmt.Errorf("%w: 组织 %d", ErrNotFound, id)
+	}
+	if err := assignMerchants(ctx, tx, id, merchantIDs); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交组织门店失败: %w", err)
+	}
+	return nil
+}
+
+// assignMerchants 在事务中把组织的门店设置为 merchantIDs：移出不在列表中的门店，锁定并检查列表中的商户后划入组织
+func assignMerchants(ctx context.Context, tx *sql.Tx, id int, merchantIDs []int) error {
+	ids := pq.Array(merchantIDs)
+	_, err := tx.ExecContext(ctx, `
+		UPDATE dim_merchant SET organization_id = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE organization_id = $1 AND NOT (merchant_id = ANY($2::int[]))
+	`, id, ids)
+	if err != nil {
+		return fmt.Errorf("移出组织门店失败: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT merchant_id, organization_id FROM dim_merchant
+		WHERE merchant_id = ANY($1::int[])
+		FOR UPDATE
+	`, ids)
+	if err != nil {
+		return fmt.Errorf("查询门店失败: %w", err)
+	}
+	owners := map[int]sql.NullInt64{}
+	for rows.Next() {
+		var merchantID int
+		var owner sql.NullInt64
+		if err := rows.Scan(&merchantID, &owner); err != nil {
+			rows.Close()
+			return fmt.Errorf("扫描门店失败: %w", err)
+		}
+		owners[merchantID] = owner
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("遍历门店失败: %w", err)
+	}
+	for _, merchantID := range merchantIDs {
+		owner, ok := owners[merchantID]
+		if !ok {
+			return fmt.Errorf("%w: 商户 %d", ErrNotFound, merchantID)
+		}
+		if owner.Valid && int(owner.Int64) != id {
+			return fmt.Errorf("%w: 商户 %d 已属于组织 %d", ErrConflict, merchantID, owner.Int64)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE dim_merchant SET organization_id = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE merchant_id = ANY($2::int[]) AND organization_id IS DISTINCT FROM $1
+	`, id, ids)
+	if err != nil {
+		return fmt.Errorf("划入组织门店失败: %w", err)
+	}
+	return nil
+}
+
+// CreateToken 保存令牌摘要
+func (r *PostgresOrganizationRepository) CreateToken(ctx context.Context, token *models.OrganizationToken, hash string) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO organization_token (organization_id, name, token_hash) VALUES ($1, $2, $3)
+		RETURNING token_id, created_at
+	`, token.OrganizationID, token.Name, hash).Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			return fmt.Errorf("%w: 组织 %d", ErrNotFound, token.OrganizationID)
+		}
+		return fmt.Errorf("创建组织令牌失败: %w", err)
+	}
+	token.CreatedAt = token.CreatedAt.UTC()
+	return nil
+}
+
+// Tokens 组织的全部令牌
+func (r *PostgresOrganizationRepository) Tokens(ctx context.Context, organizationID int) ([]models.OrganizationToken, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT token_id, organization_id, name, created_at, last_used_at, revoked_at
+		FROM organization_token
+		WHERE organization_id = $1
+		ORDER BY token_id
+	`, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("查询组织令牌失败: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []models.OrganizationToken
+	for rows.Next() {
+		var t models.OrganizationToken
+		var lastUsed, revoked sql.NullTime
+		if err := rows.Scan(&t.ID, &t.OrganizationID, &t.Name, &t.CreatedAt, &lastUsed, &revoked); err != nil {
+			return nil, fmt.Errorf("扫描组织令牌失败: %w", err)
+		}
+		t.CreatedAt = t.CreatedAt.UTC()
+		if lastUsed.Valid {
+			at := lastUsed.Time.UTC()
+			t.LastUsedAt = &at
+		}
+		if revoked.Valid {
+			at := revoked.Time.UTC()
+			t.RevokedAt = &at
+		}
+		tokens = append(tokens, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历组织令牌失败: %w", err)
+	}
+	return tokens, nil
+}
+
+// RevokeToken 吊销令牌
+func (r *PostgresOrganizationRepository) RevokeToken(ctx context.Context, organizationID, tokenID int) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE organization_token SET revoked_at = CURRENT_TIMESTAMP
+		WHERE token_id = $1 AND organization_id = $2 AND revoked_at IS NULL
+	`, tokenID, organizationID)
+	if err != nil {
+		return fmt.Errorf("吊销组织令牌失败: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("吊销组织令牌失败: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: 组织 %d 的令牌 %d", ErrNotFound, organizationID, tokenID)
+	}
+	return nil
+}
+
+// TokenOrganization 查找令牌所属的组织并记录使用时间
+func (r *PostgresOrganizationRepository) TokenOrganization(ctx context.Context, hash string) (int, error) {
+	var organizationID int
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE organization_token SET last_used_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND revoked_at IS NULL
+		RETURNING organization_id
+	`, hash).Scan(&organizationID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%w: 组织令牌", ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("查询组织令牌失败: %w", err)
+	}
+	return organizationID, nil
+}
+
+// OrderTotals 按门店、小时和币种聚合组织的订单
+// 门店口径按视图中的本地日期和本地小时；总部口径按 UTC 区间过滤，小时按总部时区换算
+func (r *PostgresOrganizationRepository) OrderTotals(ctx context.Context, filter models.OrganizationAnalysisFilter) ([]models.OrganizationOrderTotal, error) {
+	var rows *sql.Rows
+	var err error
+	if filter.HQTimezone == "" {
+		rows, err = r.db.QueryContext(ctx, `
+			SELECT a.merchant_id, a.local_hour, a.currency, COUNT(*), COALESCE(SUM(a.amount), 0)
+			FROM dws_orders_analysis_view a
+			JOIN dim_merchant m ON m.merchant_id = a.merchant_id
+			WHERE m.organization_id = $1
+				AND a.local_date = $2
+				AND (COALESCE(cardinality($3::text[]), 0) = 0 OR a.status = ANY($3::text[]))
+			GROUP BY a.merchant_id, a.local_hour, a.currency
+			ORDER BY a.merchant_id, a.local_hour, a.currency
+		`, filter.OrganizationID, filter.LocalDate, pq.Array(filter.Statuses))
+	} else {
+		rows, err = r.db.QueryContext(ctx, `
+			SELECT
+				a.merchant_id,
+				EXTRACT(HOUR FROM a.order_time_utc AT TIME ZONE $4)::int AS hq_hour,
+				a.currency, COUNT(*), COALESCE(SUM(a.amount), 0)
+			FROM dws_orders_analysis_view a
+			JOIN dim_merchant m ON m.merchant_id = a.merchant_id
+			WHERE m.organization_id = $1
+				AND a.order_time_utc >= $2 AND a.order_time_utc < $3
+				AND (COALESCE(cardinality($5::text[]), 0) = 0 OR a.status = ANY($5::text[]))
+			GROUP BY a.merchant_id, hq_hour, a.currency
+			ORDER BY a.merchant_id, hq_hour, a.currency
+		`, filter.OrganizationID, filter.Start, filter.End, filter.HQTimezone, pq.Array(filter.Statuses))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询组织订单汇总失败: %w", err)
+	}
+	defer rows.Close()
+
+	var totals []models.OrganizationOrderTotal
+	for rows.Next() {
+		var t models.OrganizationOrderTotal
+		if err := rows.Scan(&t.MerchantID, &t.Hour, &t.Currency, &t.OrderCount, &t.Amount); err != nil {
+			return nil, fmt.Errorf("扫描组织订单汇总失败: %w", err)
+		}
+		totals = append(totals, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历组织订单汇总失败: %w", err)
+	}
+	return totals, nil
+}
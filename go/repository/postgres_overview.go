@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// PostgresOverviewRepository 基于 PostgreSQL 的运营看板仓储
+type PostgresOverviewRepository struct {
+	db *database.DB
+}
+
+// NewPostgresOverviewRepository 创建 PostgreSQL 运营看板仓储
+func NewPostgresOverviewRepository(db *database.DB) *PostgresOverviewRepository {
+	return &PostgresOverviewRepository{db: db}
+}
+
+// TenantActivity 获取每个商户的订单数和最近入库时间，没有订单的商户也会返回
+func (r *PostgresOverviewRepository) TenantActivity(ctx context.Context, since time.Time) ([]models.TenantActivity, error) {
+	query := `
+		SELECT
+			m.merchant_id,
+			m.merchant_name,
+			m.timezone,
+			COUNT(o.order_id),
+			COUNT(o.order_id) FILTER (WHERE o.ingested_at >= $1),
+			MAX(o.ingested_at)
+		FROM dim_merchant m
+		LEFT JOIN dws_orders o ON o.merchant_id = m.merchant_id
+		GROUP BY m.merchant_id, m.merchant_name, m.timezone
+		ORDER BY m.merchant_id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, since)
+	if err != nil {
+		return nil, fmt.Errorf("查询租户订单统计失败: %w", err)
+	}
+	defer rows.Close()
+
+	var activity []models.TenantActivity
+	for rows.Next() {
+		var a models.TenantActivity
+		var lastIngested sql.NullTime
+		err := rows.Scan(
+			&a.MerchantID,
+			&a.MerchantName,
+			&a.Timezone,
+			&a.OrderCount,
+			&a.RecentOrderCount,
+			&lastIngested,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("扫描租户订单统计失败: %w", err)
+		}
+		if lastIngested.Valid {
+			t := lastIngested.Time.UTC()
+			a.LastIngestedAt = &t
+		}
+		activity = append(activity, a)
+	}
+
+	return activity, rows.Err()
+}
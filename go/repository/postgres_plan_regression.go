@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// planStatsRetention 统计周期的保留时间，超过后由 Save 清除
+const planStatsRetention = "30 days"
+
+// PostgresPlanRegressionRepository 基于 query_plan_stats 表的语句指纹统计仓储
+type PostgresPlanRegressionRepository struct {
+	db *database.DB
+}
+
+// NewPostgresPlanRegressionRepository 创建 PostgreSQL 语句指纹统计仓储
+func NewPostgresPlanRegressionRepository(db *database.DB) *PostgresPlanRegressionRepository {
+	return &PostgresPlanRegressionRepository{db: db}
+}
+
+// StatsAnalyzedAt 各表最近一次 ANALYZE 的时间
+func (r *PostgresPlanRegressionRepository) StatsAnalyzedAt(ctx context.Context) (*time.Time, error) {
+	var analyzedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, `
+		SELECT MAX(GREATEST(last_analyze, last_autoanalyze)) FROM pg_stat_user_tables
+	`).Scan(&analyzedAt)
+	if err != nil {
+		return nil, fmt.Errorf("查询表统计信息的更新时间失败: %w", err)
+	}
+	if !analyzedAt.Valid {
+		return nil, nil
+	}
+	t := analyzedAt.Time.UTC()
+	return &t, nil
+}
+
+// Save 在一个事务中写入各指纹的统计；同一周期已有的统计被覆盖，周期开始时间保持不变
+func (r *PostgresPlanRegressionRepository) Save(ctx context.Context, stats []models.QueryPlanStats) error {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, s := range stats {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO query_plan_stats (
+				fingerprint, epoch, deploy_version, stats_analyzed_at, epoch_started_at, query, samples, median_ms, plan
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''))
+			ON CONFLICT (fingerprint, epoch) DO UPDATE SET
+				samples = EXCLUDED.samples,
+				median_ms = EXCLUDED.median_ms,
+				plan = COALESCE(EXCLUDED.plan, query_plan_stats.plan),
+				updated_at = CURRENT_TIMESTAMP
+		`, s.Fingerprint, s.Epoch, s.DeployVersion, s.StatsAnalyzedAt, s.StartedAt, s.Query, s.Samples, s.MedianMs, s.Plan)
+		if err != nil {
+			return fmt.Errorf("写入语句 %s 的统计失败: %w", s.Fingerprint, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM query_plan_stats WHERE updated_at < CURRENT_TIMESTAMP - INTERVAL '`+planStatsRetention+`'`); err != nil {
+		return fmt.Errorf("清除过期的语句统计失败: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交语句统计失败: %w", err)
+	}
+	return nil
+}
+
+// Latest 每个指纹最近两个周期的统计
+func (r *PostgresPlanRegressionRepository) Latest(ctx context.Context) ([]models.QueryPlanStats, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT fingerprint, epoch, deploy_version, stats_analyzed_at, epoch_started_at, query, samples, median_ms,
+			COALESCE(plan, ''), updated_at
+		FROM (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY fingerprint ORDER BY epoch_started_at DESC) AS rn
+			FROM query_plan_stats
+		) s
+		WHERE rn <= 2
+		ORDER BY fingerprint, epoch_started_at DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("查询语句统计失败: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []models.QueryPlanStats
+	for rows.Next() {
+		var s models.QueryPlanStats
+		var analyzedAt sql.NullTime
+		if err := rows.Scan(&s.Fingerprint, &s.Epoch, &s.DeployVersion, &analyzedAt, &s.StartedAt, &s.Query,
+			&s.Samples, &s.MedianMs, &s.Plan, &s.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("扫描语句统计失败: %w", err)
+		}
+		if analyzedAt.Valid {
+			t := analyzedAt.Time.UTC()
+			s.StatsAnalyzedAt = &t
+		}
+		stats = append(stats, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历语句统计失败: %w", err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// PostgresQueryConsoleRepository 基于只读事务和 saasview_console 角色的 SQL 控制台仓储
+type PostgresQueryConsoleRepository struct {
+	db *database.DB
+}
+
+// NewPostgresQueryConsoleRepository 创建 PostgreSQL SQL 控制台仓储
+func NewPostgresQueryConsoleRepository(db *database.DB) *PostgresQueryConsoleRepository {
+	return &PostgresQueryConsoleRepository{db: db}
+}
+
+// Execute 依次设置只读事务、角色和语句超时后执行查询，事务总是回滚
+// 查询通过 Prepare 执行，走扩展查询协议，数据库拒绝一次提交多条语句
+func (r *PostgresQueryConsoleRepository) Execute(ctx context.Context, role, query string, timeout time.Duration, maxRows int) (*models.ConsoleResult, error) {
+	// statement_timeout 由数据库中止查询；ctx 的超时额外留出余量，兜底网络卡住等情况
+	ctx, cancel := context.WithTimeout(ctx, timeout+time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginReadOnlyTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("开始只读事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SET LOCAL ROLE "+pq.QuoteIdentifier(role)); err != nil {
+		return nil, fmt.Errorf("切换到控制台角色 %s 失败（是否已执行 migrate）: %w", role, err)
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", timeout.Milliseconds())); err != nil {
+		return nil, fmt.Errorf("设置语句超时失败: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, consoleError(err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx)
+	if err != nil {
+		return nil, consoleError(err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("读取结果列失败: %w", err)
+	}
+	result := &models.ConsoleResult{Columns: columns, Rows: [][]interface{}{}}
+	for rows.Next() {
+		if len(result.Rows) >= maxRows {
+			result.Truncated = true
+			break
+		}
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, fmt.Errorf("扫描查询结果失败: %w", err)
+		}
+		// 驱动以 []byte 返回 numeric、text 等类型，转为字符串后 JSON 中可读
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, consoleError(err)
+	}
+	result.RowCount = len(result.Rows)
+	return result, nil
+}
+
+// consoleError 区分语句本身的错误和数据库不可用
+// 22 数据异常、42 语法或权限错误、25006 只读事务中写入、57014 超时取消、0A000 不支持的功能
+func consoleError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code.Class() == "22", pqErr.Code.Class() == "42", pqErr.Code == "25006", pqErr.Code == "57014", pqErr.Code == "0A000":
+			return fmt.Errorf("%w: %s", ErrInvalidQuery, pqErr.Message)
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: 查询超时", ErrInvalidQuery)
+	}
+	return fmt.Errorf("执行查询失败: %w", err)
+}
+
+// CreateAudit 写入审计记录
+func (r *PostgresQueryConsoleRepository) CreateAudit(ctx context.Context, audit *models.ConsoleAudit) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO admin_query_audit (operator, query_text, status, error, client_addr)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING audit_id, executed_at
+	`, audit.Operator, audit.SQL, audit.Status, audit.Error, audit.ClientAddr).Scan(&audit.ID, &audit.ExecutedAt)
+	if err != nil {
+		return fmt.Errorf("写入控制台审计记录失败: %w", err)
+	}
+	audit.ExecutedAt = audit.ExecutedAt.UTC()
+	return nil
+}
+
+// FinishAudit 更新审计记录
+func (r *PostgresQueryConsoleRepository) FinishAudit(ctx context.Context, audit *models.ConsoleAudit) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE admin_query_audit
+		SET status = $2, row_count = $3, truncated = $4, duration_ms = $5, error = $6
+		WHERE audit_id = $1
+	`, audit.ID, audit.Status, audit.RowCount, audit.Truncated, audit.DurationMs, audit.Error)
+	if err != nil {
+		return fmt.Errorf("更新控制台审计记录 %d 失败: %w", audit.ID, err)
+	}
+	return nil
+}
+
+// Audits 获取最近的审计记录
+func (r *PostgresQueryConsoleRepository) Audits(ctx context.Context, limit int) ([]models.ConsoleAudit, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT audit_id, operator, query_text, status, row_count, truncated, duration_ms, error, client_addr, executed_at
+		FROM admin_query_audit
+		ORDER BY executed_at DESC, audit_id DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询控制台审计记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	var audits []models.ConsoleAudit
+	for rows.Next() {
+		var a models.ConsoleAudit
+		err := rows.Scan(&a.ID, &a.Operator, &a.SQL, &a.Status, &a.RowCount, &a.Truncated, &a.DurationMs, &a.Error, &a.ClientAddr, &a.ExecutedAt)
+		if err != nil {
+			return nil, fmt.Errorf("扫描控制台审计记录失败: %w", err)
+		}
+		a.ExecutedAt = a.ExecutedAt.UTC()
+		audits = append(audits, a)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历控制台审计记录失败: %w", err)
+	}
+	return audits, nil
+}
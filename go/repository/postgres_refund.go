@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// PostgresRefundRepository 基于 dws_order_refund 表的退款仓储
+type PostgresRefundRepository struct {
+	db *database.DB
+}
+
+// NewPostgresRefundRepository 创建 PostgreSQL 退款仓储
+func NewPostgresRefundRepository(db *database.DB) *PostgresRefundRepository {
+	return &PostgresRefundRepository{db: db}
+}
+
+// Order 获取订单及其退款记录
+func (r *PostgresRefundRepository) Order(orderID int) (*models.OrderRefunds, error) {
+	var o models.OrderRefunds
+	err := r.db.QueryRow(`
+		SELECT o.order_id, o.merchant_id, m.timezone, o.order_time_utc,
+			COALESCE(o.currency, 'USD'), COALESCE(o.order_status, 'pending'), o.order_amount
+		FROM dws_orders o
+		JOIN dim_merchant m ON m.merchant_id = o.merchant_id
+		WHERE o.order_id = $1
+	`, orderID).Scan(&o.OrderID, &o.MerchantID, &o.Timezone, &o.OrderTimeUTC, &o.Currency, &o.Status, &o.OrderAmount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: 订单 %d", ErrNotFound, orderID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询订单失败: %w", err)
+	}
+
+	rows, err := r.db.Query(`
+		SELECT refund_id, order_id, merchant_id, refund_amount, currency, reason, refund_time_utc, created_by, created_at
+		FROM dws_order_refund
+		WHERE order_id = $1
+		ORDER BY refund_time_utc, refund_id
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("查询退款记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	o.Refunds = []models.Refund{}
+	for rows.Next() {
+		var refund models.Refund
+		err := rows.Scan(
+			&refund.RefundID,
+			&refund.OrderID,
+			&refund.MerchantID,
+			&refund.Amount,
+			&refund.Currency,
+			&refund.Reason,
+			&refund.RefundTimeUTC,
+			&refund.CreatedBy,
+			&refund.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("扫描退款记录失败: %w", err)
+		}
+		o.RefundedAmount = o.RefundedAmount.Add(refund.Amount)
+		o.Refunds = append(o.Refunds, refund)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历退款记录失败: %w", err)
+	}
+
+	o.RemainingAmount = o.OrderAmount.Sub(o.RefundedAmount)
+	return &o, nil
+}
+
+// Create 写入退款记录
+// 锁定订单行后再核对累计退款，避免并发退款超过订单金额
+func (r *PostgresRefundRepository) Create(refund *models.Refund) error {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	var orderAmount decimal.Decimal
+	err = tx.QueryRow(`
+		SELECT merchant_id, COALESCE(currency, 'USD'), order_amount
+		FROM dws_orders
+		WHERE order_id = $1
+		FOR UPDATE
+	`, refund.OrderID).Scan(&refund.MerchantID, &refund.Currency, &orderAmount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: 订单 %d", ErrNotFound, refund.OrderID)
+	}
+	if err != nil {
+		return fmt.Errorf("锁定订单失败: %w", err)
+	}
+
+	var refunded decimal.Decimal
+	err = tx.QueryRow(`SELECT COALESCE(SUM(refund_amount), 0) FROM dws_order_refund WHERE order_id = $1`, refund.OrderID).Scan(&refunded)
+	if err != nil {
+		return fmt.Errorf("统计已退款金额失败: %w", err)
+	}
+	refunded = refunded.Add(refund.Amount)
+	if refunded.GreaterThan(orderAmount) {
+		return fmt.Errorf("%w: 订单 %d 累计退款 %s 超过订单金额 %s", ErrConflict, refund.OrderID, refunded, orderAmount)
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO dws_order_refund (
+			order_id, merchant_id, refund_amount, currency, reason, refund_time_utc, created_by
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING refund_id, created_at
+	`, refund.OrderID, refund.MerchantID, refund.Amount, refund.Currency, refund.Reason, refund.RefundTimeUTC, refund.CreatedBy,
+	).Scan(&refund.RefundID, &refund.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("写入退款记录失败: %w", err)
+	}
+
+	if refunded.Equal(orderAmount) {
+		_, err = tx.Exec(`UPDATE dws_orders SET order_status = 'refunded' WHERE order_id = $1`, refund.OrderID)
+		if err != nil {
+			return fmt.Errorf("更新订单状态失败: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交退款记录失败: %w", err)
+	}
+	return nil
+}
+
+// Totals 按币种分组统计指定本地日期的退款
+// 原订单日期和退款日期都按商户时区换算，与分析视图的 local_date 一致；filter.AggregateTimezone 非空时都按该时区换算
+func (r *PostgresRefundRepository) Totals(ctx context.Context, filter models.AnalysisFilter) ([]models.RefundTotal, error) {
+	totals, err := r.TotalsByDate(ctx, []string{filter.LocalDate}, filter)
+	if err != nil {
+		return nil, err
+	}
+	return totals[filter.LocalDate], nil
+}
+
+// TotalsByDate 按（本地日期，币种）分组统计多个本地日期的退款，一笔退款的原订单日期和退款日期可以分属两个日期
+func (r *PostgresRefundRepository) TotalsByDate(ctx context.Context, dates []string, filter models.AnalysisFilter) (map[string][]models.RefundTotal, error) {
+	query := `
+		WITH d AS (
+			SELECT DISTINCT unnest($1::date[]) AS local_date
+		), t AS (
+			SELECT
+				rf.currency,
+				rf.refund_amount,
+				(o.order_time_utc AT TIME ZONE COALESCE(NULLIF($3, ''), m.timezone))::date AS order_local_date,
+				(rf.refund_time_utc AT TIME ZONE COALESCE(NULLIF($3, ''), m.timezone))::date AS refund_local_date
+			FROM dws_order_refund rf
+			JOIN dws_orders o ON o.order_id = rf.order_id
+			JOIN dim_merchant m ON m.merchant_id = o.merchant_id
+			WHERE (COALESCE(cardinality($2::text[]), 0) = 0 OR o.order_status = ANY($2::text[]))
+		)
+		SELECT
+			to_char(d.local_date, 'YYYY-MM-DD'),
+			t.currency,
+			COALESCE(SUM(t.refund_amount) FILTER (WHERE t.order_local_date = d.local_date), 0) AS by_order_date,
+			COALESCE(SUM(t.refund_amount) FILTER (WHERE t.refund_local_date = d.local_date), 0) AS by_refund_date
+		FROM t
+		JOIN d ON d.local_date IN (t.order_local_date, t.refund_local_date)
+		GROUP BY d.local_date, t.currency
+		ORDER BY d.local_date, t.currency
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(dates), pq.Array(filter.Statuses), filter.AggregateTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("查询退款汇总失败: %w", err)
+	}
+	defer rows.Close()
+
+	result := map[string][]models.RefundTotal{}
+	for rows.Next() {
+		var date string
+		var total models.RefundTotal
+		if err := rows.Scan(&date, &total.Currency, &total.ByOrderDate, &total.ByRefundDate); err != nil {
+			return nil, fmt.Errorf("扫描退款汇总失败: %w", err)
+		}
+		result[date] = append(result[date], total)
+	}
+
+	return result, rows.Err()
+}
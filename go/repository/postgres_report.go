@@ -0,0 +1,288 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// reportDefinitionColumns report_definition 的查询列，与 scanReportDefinition 的顺序一致
+const reportDefinitionColumns = `
+	definition_id, name, COALESCE(merchant_id, 0), timezone_mode, range_type,
+	COALESCE(to_char(from_date, 'YYYY-MM-DD'), ''), COALESCE(to_char(to_date, 'YYYY-MM-DD'), ''),
+	statuses, currency, format, schedule, next_run_at, emails, created_by, created_at, updated_at`
+
+// reportRunColumns report_run 的查询列（不含结果文件），与 scanReportRun 的顺序一致
+const reportRunColumns = `
+	run_id, definition_id, triggered_by, status,
+	to_char(from_date, 'YYYY-MM-DD'), to_char(to_date, 'YYYY-MM-DD'), timezone, format,
+	started_at, finished_at, artifact_size, error`
+
+// PostgresReportRepository 基于 report_definition / report_run 表的报表仓储
+type PostgresReportRepository struct {
+	db *database.DB
+}
+
+// NewPostgresReportRepository 创建 PostgreSQL 报表仓储
+func NewPostgresReportRepository(db *database.DB) *PostgresReportRepository {
+	return &PostgresReportRepository{db: db}
+}
+
+// CreateDefinition 写入报表定义
+func (r *PostgresReportRepository) CreateDefinition(ctx context.Context, def *models.ReportDefinition) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO report_definition (
+			name, merchant_id, timezone_mode, range_type, from_date, to_date,
+			statuses, currency, format, schedule, next_run_at, emails, created_by
+		) VALUES ($1, NULLIF($2, 0), $3, $4, NULLIF($5, '')::date, NULLIF($6, '')::date, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING definition_id, created_at, updated_at
+	`, def.Name, def.MerchantID, def.TimezoneMode, def.RangeType, def.From, def.To,
+		pq.Array(def.Statuses), def.Currency, def.Format, def.Schedule, def.NextRunAt, pq.Array(def.Emails), def.CreatedBy,
+	).Scan(&def.ID, &def.CreatedAt, &def.UpdatedAt)
+	if err != nil {
+		return reportDefinitionError(err, def)
+	}
+	def.CreatedAt, def.UpdatedAt = def.CreatedAt.UTC(), def.UpdatedAt.UTC()
+	return nil
+}
+
+// UpdateDefinition 覆盖报表定义，创建人和创建时间不变
+func (r *PostgresReportRepository) UpdateDefinition(ctx context.Context, def *models.ReportDefinition) error {
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE report_definition SET
+			name = $2, merchant_id = NULLIF($3, 0), timezone_mode = $4, range_type = $5,
+			from_date = NULLIF($6, '')::date, to_date = NULLIF($7, '')::date,
+			statuses = $8, currency = $9, format = $10, schedule = $11, next_run_at = $12, emails = $13
+		WHERE definition_id = $1
+		RETURNING created_by, created_at, updated_at
+	`, def.ID, def.Name, def.MerchantID, def.TimezoneMode, def.RangeType, def.From, def.To,
+		pq.Array(def.Statuses), def.Currency, def.Format, def.Schedule, def.NextRunAt, pq.Array(def.Emails),
+	).Scan(&def.CreatedBy, &def.CreatedAt, &def.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: 报表定义 %d", ErrNotFound, def.ID)
+	}
+	if err != nil {
+		return reportDefinitionError(err, def)
+	}
+	def.CreatedAt, def.UpdatedAt = def.CreatedAt.UTC(), def.UpdatedAt.UTC()
+	return nil
+}
+
+// reportDefinitionError 名称重复映射为 ErrConflict，商户不存在映射为 ErrNotFound
+func reportDefinitionError(err error, def *models.ReportDefinition) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case uniqueViolation:
+			return fmt.Errorf("%w: 报表名称 %s", ErrConflict, def.Name)
+		case foreignKeyViolation:
+			return fmt.Errorf("%w: 商户 %d", ErrNotFound, def.MerchantID)
+		}
+	}
+	return fmt.Errorf("保存报表定义失败: %w", err)
+}
+
+// DeleteDefinition 删除报表定义，执行记录随外键级联删除
+func (r *PostgresReportRepository) DeleteDefinition(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM report_definition WHERE definition_id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("删除报表定义 %d 失败: %w", id, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: 报表定义 %d", ErrNotFound, id)
+	}
+	return nil
+}
+
+// Definitions 全部报表定义
+func (r *PostgresReportRepository) Definitions(ctx context.Context) ([]models.ReportDefinition, error) {
+	return r.queryDefinitions(ctx, `SELECT `+reportDefinitionColumns+` FROM report_definition ORDER BY definition_id`)
+}
+
+// Definition 单个报表定义
+func (r *PostgresReportRepository) Definition(ctx context.Context, id int) (*models.ReportDefinition, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+reportDefinitionColumns+` FROM report_definition WHERE definition_id = $1`, id)
+	def, err := scanReportDefinition(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: 报表定义 %d", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// DueDefinitions 到期的报表定义
+func (r *PostgresReportRepository) DueDefinitions(ctx context.Context, at time.Time) ([]models.ReportDefinition, error) {
+	return r.queryDefinitions(ctx, `
+		SELECT `+reportDefinitionColumns+`
+		FROM report_definition
+		WHERE next_run_at IS NOT NULL AND next_run_at <= $1
+		ORDER BY next_run_at, definition_id
+	`, at)
+}
+
+// queryDefinitions 执行查询并扫描报表定义
+func (r *PostgresReportRepository) queryDefinitions(ctx context.Context, query string, args ...interface{}) ([]models.ReportDefinition, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询报表定义失败: %w", err)
+	}
+	defer rows.Close()
+
+	var defs []models.ReportDefinition
+	for rows.Next() {
+		def, err := scanReportDefinition(rows)
+		if err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历报表定义失败: %w", err)
+	}
+	return defs, nil
+}
+
+// scanReportDefinition 扫描一条报表定义，单行查询没有结果时原样返回 sql.ErrNoRows
+func scanReportDefinition(row interface{ Scan(...interface{}) error }) (models.ReportDefinition, error) {
+	var def models.ReportDefinition
+	var statuses, emails pq.StringArray
+	var nextRunAt sql.NullTime
+	err := row.Scan(&def.ID, &def.Name, &def.MerchantID, &def.TimezoneMode, &def.RangeType, &def.From, &def.To,
+		&statuses, &def.Currency, &def.Format, &def.Schedule, &nextRunAt, &emails, &def.CreatedBy, &def.CreatedAt, &def.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return def, err
+	}
+	if err != nil {
+		return def, fmt.Errorf("扫描报表定义失败: %w", err)
+	}
+	def.Statuses = []string(statuses)
+	if def.Statuses == nil {
+		def.Statuses = []string{}
+	}
+	def.Emails = []string(emails)
+	if def.Emails == nil {
+		def.Emails = []string{}
+	}
+	if nextRunAt.Valid {
+		t := nextRunAt.Time.UTC()
+		def.NextRunAt = &t
+	}
+	def.CreatedAt, def.UpdatedAt = def.CreatedAt.UTC(), def.UpdatedAt.UTC()
+	return def, nil
+}
+
+// ClaimDue 以 next_run_at 作为乐观锁，多个实例同时调度时只有一个领取成功
+func (r *PostgresReportRepository) ClaimDue(ctx context.Context, id int, due time.Time, next *time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE report_definition SET next_run_at = $3
+		WHERE definition_id = $1 AND next_run_at = $2
+	`, id, due, next)
+	if err != nil {
+		return false, fmt.Errorf("领取报表 %d 的定时执行失败: %w", id, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("领取报表 %d 的定时执行失败: %w", id, err)
+	}
+	return n == 1, nil
+}
+
+// CreateRun 写入执行记录
+func (r *PostgresReportRepository) CreateRun(ctx context.Context, run *models.ReportRun) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO report_run (definition_id, triggered_by, status, from_date, to_date, timezone, format, started_at)
+		VALUES ($1, $2, $3, $4::date, $5::date, $6, $7, $8)
+		RETURNING run_id
+	`, run.DefinitionID, run.TriggeredBy, run.Status, run.From, run.To, run.Timezone, run.Format, run.StartedAt).Scan(&run.RunID)
+	if err != nil {
+		return fmt.Errorf("写入报表执行记录失败: %w", err)
+	}
+	return nil
+}
+
+// FinishRun 更新执行记录，artifact 为 nil 时不保存结果文件
+func (r *PostgresReportRepository) FinishRun(ctx context.Context, run *models.ReportRun, artifact []byte) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE report_run
+		SET status = $2, finished_at = $3, artifact = $4, artifact_size = $5, error = $6
+		WHERE run_id = $1
+	`, run.RunID, run.Status, run.FinishedAt, artifact, run.ArtifactSize, run.Error)
+	if err != nil {
+		return fmt.Errorf("更新报表执行记录 %d 失败: %w", run.RunID, err)
+	}
+	return nil
+}
+
+// Runs 报表最近的执行记录
+func (r *PostgresReportRepository) Runs(ctx context.Context, definitionID, limit int) ([]models.ReportRun, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+reportRunColumns+`
+		FROM report_run
+		WHERE definition_id = $1
+		ORDER BY started_at DESC, run_id DESC
+		LIMIT $2
+	`, definitionID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询报表执行记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []models.ReportRun
+	for rows.Next() {
+		var run models.ReportRun
+		if err := scanReportRun(rows, &run); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历报表执行记录失败: %w", err)
+	}
+	return runs, nil
+}
+
+// Artifact 一次执行及其结果文件
+func (r *PostgresReportRepository) Artifact(ctx context.Context, runID int64) (*models.ReportRun, []byte, error) {
+	var run models.ReportRun
+	var artifact []byte
+	row := r.db.QueryRowContext(ctx, `SELECT `+reportRunColumns+`, artifact FROM report_run WHERE run_id = $1`, runID)
+	if err := scanReportRun(row, &run, &artifact); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, fmt.Errorf("%w: 报表执行记录 %d", ErrNotFound, runID)
+		}
+		return nil, nil, err
+	}
+	if artifact == nil {
+		return nil, nil, fmt.Errorf("%w: 报表执行记录 %d 没有结果文件", ErrNotFound, runID)
+	}
+	return &run, artifact, nil
+}
+
+// scanReportRun 扫描一条执行记录，extra 为 reportRunColumns 之后的列；单行查询没有结果时原样返回 sql.ErrNoRows
+func scanReportRun(row interface{ Scan(...interface{}) error }, run *models.ReportRun, extra ...interface{}) error {
+	var finishedAt sql.NullTime
+	dest := []interface{}{&run.RunID, &run.DefinitionID, &run.TriggeredBy, &run.Status, &run.From, &run.To,
+		&run.Timezone, &run.Format, &run.StartedAt, &finishedAt, &run.ArtifactSize, &run.Error}
+	err := row.Scan(append(dest, extra...)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("扫描报表执行记录失败: %w", err)
+	}
+	run.StartedAt = run.StartedAt.UTC()
+	if finishedAt.Valid {
+		t := finishedAt.Time.UTC()
+		run.FinishedAt = &t
+	}
+	return nil
+}
@@ -0,0 +1,334 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// archivableOrder 订单可以归档的条件：退款和营收调整通过外键引用订单，被引用的订单留在 dws_orders
+const archivableOrder = `
+			NOT EXISTS (SELECT 1 FROM dws_order_refund rf WHERE rf.order_id = o.order_id)
+			AND NOT EXISTS (SELECT 1 FROM daily_revenue_adjustment adj WHERE adj.order_id = o.order_id)`
+
+// PostgresRetentionRepository 基于 retention_* 表、dws_orders_archive 冷表和 agg_orders_hourly_archived 的归档仓储
+type PostgresRetentionRepository struct {
+	db *database.DB
+}
+
+// NewPostgresRetentionRepository 创建 PostgreSQL 归档仓储
+func NewPostgresRetentionRepository(db *database.DB) *PostgresRetentionRepository {
+	return &PostgresRetentionRepository{db: db}
+}
+
+// Policies 获取全部保留策略
+func (r *PostgresRetentionRepository) Policies(ctx context.Context) ([]models.RetentionPolicy, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT merchant_id, archive_after_months, target, updated_by, updated_at
+		FROM retention_policy
+		ORDER BY merchant_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("查询保留策略失败: %w", err)
+	}
+	defer rows.Close()
+
+	var policies []models.RetentionPolicy
+	for rows.Next() {
+		var p models.RetentionPolicy
+		if err := rows.Scan(&p.MerchantID, &p.ArchiveAfterMonths, &p.Target, &p.UpdatedBy, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("扫描保留策略失败: %w", err)
+		}
+		p.UpdatedAt = p.UpdatedAt.UTC()
+		policies = append(policies, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历保留策略失败: %w", err)
+	}
+	return policies, nil
+}
+
+// SavePolicy 新增或覆盖保留策略
+func (r *PostgresRetentionRepository) SavePolicy(ctx context.Context, policy *models.RetentionPolicy) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO retention_policy (merchant_id, archive_after_months, target, updated_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (merchant_id) DO UPDATE SET
+			archive_after_months = EXCLUDED.archive_after_months,
+			target = EXCLUDED.target,
+			updated_by = EXCLUDED.updated_by
+		RETURNING updated_at
+	`, policy.MerchantID, policy.ArchiveAfterMonths, policy.Target, policy.UpdatedBy).Scan(&policy.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("保存商户 %d 的保留策略失败: %w", policy.MerchantID, err)
+	}
+	policy.UpdatedAt = policy.UpdatedAt.UTC()
+	return nil
+}
+
+// DeletePolicy 删除保留策略
+func (r *PostgresRetentionRepository) DeletePolicy(ctx context.Context, merchantID int) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM retention_policy WHERE merchant_id = $1`, merchantID)
+	if err != nil {
+		return fmt.Errorf("删除商户 %d 的保留策略失败: %w", merchantID, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: 商户 %d 的保留策略", ErrNotFound, merchantID)
+	}
+	return nil
+}
+
+// PendingMonths 按商户当前时区计算订单的本地月份
+func (r *PostgresRetentionRepository) PendingMonths(ctx context.Context, merchantID int, before string) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT DISTINCT to_char(o.order_time_utc AT TIME ZONE m.timezone, 'YYYY-MM')
+		FROM dws_orders o
+		JOIN dim_merchant m ON m.merchant_id = o.merchant_id
+		WHERE o.merchant_id = $1
+			AND (o.order_time_utc AT TIME ZONE m.timezone)::date < $2::date
+			AND `+archivableOrder+`
+		ORDER BY 1
+	`, merchantID, before)
+	if err != nil {
+		return nil, fmt.Errorf("查询商户 %d 待归档的月份失败: %w", merchantID, err)
+	}
+	defer rows.Close()
+
+	var months []string
+	for rows.Next() {
+		var month string
+		if err := rows.Scan(&month); err != nil {
+			return nil, fmt.Errorf("扫描待归档月份失败: %w", err)
+		}
+		months = append(months, month)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历待归档月份失败: %w", err)
+	}
+	return months, nil
+}
+
+// StreamMonth 从分析视图读取订单，字段与导出接口一致
+func (r *PostgresRetentionRepository) StreamMonth(ctx context.Context, merchantID int, month string, fn func(models.OrderAnalysis) error) error {
+	query := `
+		SELECT ` + orderAnalysisColumns + `
+		FROM dws_orders_analysis_view o
+		WHERE o.merchant_id = $1
+			AND o.local_date >= $2::date
+			AND o.local_date < ($2::date + INTERVAL '1 month')
+			AND ` + archivableOrder + `
+		ORDER BY o.order_time_utc, o.order_id
+	`
+
+	ctx, cancel := context.WithCancel(ctx)
+	rows, err := r.db.QueryContext(ctx, query, merchantID, month+"-01")
+	if err != nil {
+		cancel()
+		return fmt.Errorf("查询商户 %d %s 的待归档订单失败: %w", merchantID, month, err)
+	}
+	defer rows.Close()
+	// 先于 rows.Close 执行：回调提前返回时取消查询，避免 Close 读完剩余的行
+	defer cancel()
+
+	for rows.Next() {
+		order, err := scanOrderAnalysis(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(order); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("遍历待归档订单失败: %w", err)
+	}
+	return nil
+}
+
+// ArchiveMonth 删除订单、写入冷表、合并已归档汇总和写入明细在同一个语句中完成，要么全部生效要么全部回滚
+// 删除订单时 agg_orders_hourly 的触发器扣减在线汇总，与写入 agg_orders_hourly_archived 的增量相抵，
+// agg_orders_hourly_all 的合计不变；没有可归档的订单时不写明细，Orders 为 0
+func (r *PostgresRetentionRepository) ArchiveMonth(ctx context.Context, runID int64, bucket *models.RetentionBucket, orderIDs []int) error {
+	var ids pq.Int64Array
+	if orderIDs != nil {
+		ids = make(pq.Int64Array, len(orderIDs))
+		for i, id := range orderIDs {
+			ids[i] = int64(id)
+		}
+	}
+
+	query := `
+		WITH moved AS (
+			DELETE FROM dws_orders o
+			USING dim_merchant m
+			WHERE m.merchant_id = o.merchant_id
+				AND o.merchant_id = $2
+				AND (o.order_time_utc AT TIME ZONE m.timezone)::date >= $3::date
+				AND (o.order_time_utc AT TIME ZONE m.timezone)::date < ($3::date + INTERVAL '1 month')
+				AND ($6::bigint[] IS NULL OR o.order_id = ANY($6::bigint[]))
+				AND ` + archivableOrder + `
+			RETURNING o.*, o.order_time_utc AT TIME ZONE m.timezone AS order_time_local
+		), archived AS (
+			INSERT INTO dws_orders_archive (
+				order_id, order_no, merchant_id, order_amount, currency, order_status,
+				order_time_utc, payment_time_utc, customer_id, customer_email, order_source,
+				created_at, updated_at, ingested_at, archive_run_id
+			)
+			SELECT
+				order_id, order_no, merchant_id, order_amount, currency, order_status,
+				order_time_utc, payment_time_utc, customer_id, customer_email, order_source,
+				created_at, updated_at, ingested_at, $1
+			FROM moved
+			WHERE $4::text = 'table'
+		), rolled AS (
+			INSERT INTO agg_orders_hourly_archived AS a (merchant_id, local_date, local_hour, currency, status, order_count, amount_sum)
+			SELECT
+				merchant_id,
+				order_time_local::date,
+				EXTRACT(HOUR FROM order_time_local)::smallint,
+				COALESCE(currency, ''),
+				COALESCE(order_status, ''),
+				COUNT(*),
+				SUM(order_amount)
+			FROM moved
+			GROUP BY 1, 2, 3, 4, 5
+			ON CONFLICT (merchant_id, local_date, local_hour, currency, status) DO UPDATE SET
+				order_count = a.order_count + EXCLUDED.order_count,
+				amount_sum = a.amount_sum + EXCLUDED.amount_sum,
+				updated_at = CURRENT_TIMESTAMP
+		)
+		INSERT INTO retention_bucket (run_id, merchant_id, month, target, orders, amount, location)
+		SELECT $1, $2, $3::date, $4::text, COUNT(*), COALESCE(SUM(order_amount), 0), $5
+		FROM moved
+		HAVING COUNT(*) > 0
+		RETURNING orders, amount
+	`
+
+	err := r.db.QueryRowContext(ctx, query, runID, bucket.MerchantID, bucket.Month+"-01", bucket.Target, bucket.Location, ids).
+		Scan(&bucket.Orders, &bucket.Amount)
+	if errors.Is(err, sql.ErrNoRows) {
+		bucket.Orders = 0
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("归档商户 %d %s 的订单失败: %w", bucket.MerchantID, bucket.Month, err)
+	}
+	return nil
+}
+
+// CreateRun 写入归档记录
+func (r *PostgresRetentionRepository) CreateRun(ctx context.Context, run *models.RetentionRun) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO retention_run (triggered_by, status, started_at)
+		VALUES ($1, $2, $3)
+		RETURNING run_id
+	`, run.TriggeredBy, run.Status, run.StartedAt).Scan(&run.RunID)
+	if err != nil {
+		return fmt.Errorf("写入归档记录失败: %w", err)
+	}
+	return nil
+}
+
+// FinishRun 更新归档记录
+func (r *PostgresRetentionRepository) FinishRun(ctx context.Context, run *models.RetentionRun) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE retention_run
+		SET status = $2, finished_at = $3, merchants = $4, archived_orders = $5, error = $6
+		WHERE run_id = $1
+	`, run.RunID, run.Status, run.FinishedAt, run.Merchants, run.ArchivedOrders, run.Error)
+	if err != nil {
+		return fmt.Errorf("更新归档记录 %d 失败: %w", run.RunID, err)
+	}
+	return nil
+}
+
+// Runs 获取最近的归档记录
+func (r *PostgresRetentionRepository) Runs(ctx context.Context, limit int) ([]models.RetentionRun, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT run_id, triggered_by, status, started_at, finished_at, merchants, archived_orders, error
+		FROM retention_run
+		ORDER BY started_at DESC, run_id DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询归档记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []models.RetentionRun
+	for rows.Next() {
+		var run models.RetentionRun
+		if err := scanRetentionRun(rows, &run); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历归档记录失败: %w", err)
+	}
+	return runs, nil
+}
+
+// Run 获取一次归档及其明细
+func (r *PostgresRetentionRepository) Run(ctx context.Context, runID int64) (*models.RetentionRun, error) {
+	var run models.RetentionRun
+	row := r.db.QueryRowContext(ctx, `
+		SELECT run_id, triggered_by, status, started_at, finished_at, merchants, archived_orders, error
+		FROM retention_run
+		WHERE run_id = $1
+	`, runID)
+	if err := scanRetentionRun(row, &run); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: 归档记录 %d", ErrNotFound, runID)
+		}
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT merchant_id, to_char(month, 'YYYY-MM'), target, orders, amount, location
+		FROM retention_bucket
+		WHERE run_id = $1
+		ORDER BY merchant_id, month
+	`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("查询归档明细失败: %w", err)
+	}
+	defer rows.Close()
+
+	run.Buckets = []models.RetentionBucket{}
+	for rows.Next() {
+		var b models.RetentionBucket
+		if err := rows.Scan(&b.MerchantID, &b.Month, &b.Target, &b.Orders, &b.Amount, &b.Location); err != nil {
+			return nil, fmt.Errorf("扫描归档明细失败: %w", err)
+		}
+		run.Buckets = append(run.Buckets, b)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历归档明细失败: %w", err)
+	}
+	return &run, nil
+}
+
+// scanRetentionRun 扫描一条归档记录，单行查询没有结果时原样返回 sql.ErrNoRows
+func scanRetentionRun(row interface{ Scan(...interface{}) error }, run *models.RetentionRun) error {
+	var finishedAt sql.NullTime
+	err := row.Scan(&run.RunID, &run.TriggeredBy, &run.Status, &run.StartedAt, &finishedAt, &run.Merchants, &run.ArchivedOrders, &run.Error)
+	if errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("扫描归档记录失败: %w", err)
+	}
+	run.StartedAt = run.StartedAt.UTC()
+	if finishedAt.Valid {
+		t := finishedAt.Time.UTC()
+		run.FinishedAt = &t
+	}
+	return nil
+}
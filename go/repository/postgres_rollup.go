@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// PostgresRollupRepository 基于 agg_orders_hourly 表和 rebuild_agg_orders_hourly 函数的汇总表仓储
+type PostgresRollupRepository struct {
+	db *database.DB
+}
+
+// NewPostgresRollupRepository 创建 PostgreSQL 汇总表仓储
+func NewPostgresRollupRepository(db *database.DB) *PostgresRollupRepository {
+	return &PostgresRollupRepository{db: db}
+}
+
+// Audit 分别按小时桶聚合分析视图和汇总表，订单数或金额不同的桶即为差异
+// 汇总表中订单数为 0 的桶视为不存在
+func (r *PostgresRollupRepository) Audit(ctx context.Context, from, to string, limit int) ([]models.RollupDiscrepancy, int, error) {
+	query := `
+		WITH expected AS (
+			SELECT
+				merchant_id,
+				local_date,
+				local_hour,
+				COALESCE(currency, '') AS currency,
+				COALESCE(status, '') AS status,
+				COUNT(*) AS order_count,
+				SUM(amount) AS amount_sum
+			FROM dws_orders_analysis_view
+			WHERE local_date BETWEEN $1 AND $2
+			GROUP BY 1, 2, 3, 4, 5
+		), actual AS (
+			SELECT merchant_id, local_date, local_hour::int AS local_hour, currency, status, order_count, amount_sum
+			FROM agg_orders_hourly
+			WHERE local_date BETWEEN $1 AND $2
+				AND (order_count <> 0 OR amount_sum <> 0)
+		)
+		SELECT
+			COALESCE(e.merchant_id, a.merchant_id),
+			COALESCE(e.local_date, a.local_date)::text,
+			COALESCE(e.local_hour, a.local_hour),
+			COALESCE(e.currency, a.currency),
+			COALESCE(e.status, a.status),
+			COALESCE(e.order_count, 0),
+			COALESCE(a.order_count, 0),
+			COALESCE(e.amount_sum, 0),
+			COALESCE(a.amount_sum, 0),
+			COUNT(*) OVER () AS total
+		FROM expected e
+		FULL JOIN actual a
+			ON a.merchant_id = e.merchant_id
+			AND a.local_date = e.local_date
+			AND a.local_hour = e.local_hour
+			AND a.currency = e.currency
+			AND a.status = e.status
+		WHERE e.order_count IS DISTINCT FROM a.order_count
+			OR e.amount_sum IS DISTINCT FROM a.amount_sum
+		ORDER BY 1, 2, 3, 4, 5
+		LIMIT $3
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, from, to, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("比对汇总表失败: %w", err)
+	}
+	defer rows.Close()
+
+	discrepancies := []models.RollupDiscrepancy{}
+	total := 0
+	for rows.Next() {
+		var d models.RollupDiscrepancy
+		err := rows.Scan(
+			&d.MerchantID,
+			&d.LocalDate,
+			&d.LocalHour,
+			&d.Currency,
+			&d.Status,
+			&d.ExpectedCount,
+			&d.ActualCount,
+			&d.ExpectedAmount,
+			&d.ActualAmount,
+			&total,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf("扫描汇总表差异失败: %w", err)
+		}
+		discrepancies = append(discrepancies, d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("遍历汇总表差异失败: %w", err)
+	}
+	return discrepancies, total, nil
+}
+
+// Rebuild 调用 rebuild_agg_orders_hourly 重新计算汇总，重建期间写入订单的事务会等待
+func (r *PostgresRollupRepository) Rebuild(ctx context.Context, merchantID int) (int64, error) {
+	var merchant sql.NullInt64
+	if merchantID > 0 {
+		merchant = sql.NullInt64{Int64: int64(merchantID), Valid: true}
+	}
+
+	var inserted int64
+	if err := r.db.QueryRowContext(ctx, `SELECT rebuild_agg_orders_hourly($1)`, merchant).Scan(&inserted); err != nil {
+		return 0, fmt.Errorf("重建汇总表失败: %w", err)
+	}
+	return inserted, nil
+}
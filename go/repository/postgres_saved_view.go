@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// savedViewColumns saved_view 的查询列，与 scanSavedView 的顺序一致
+const savedViewColumns = `
+	view_id, tenant, owner, name, timezone, merchant_ids, statuses, date_range, created_at, updated_at`
+
+// PostgresSavedViewRepository 基于 saved_view 表的筛选视图仓储
+type PostgresSavedViewRepository struct {
+	db *database.DB
+}
+
+// NewPostgresSavedViewRepository 创建 PostgreSQL 筛选视图仓储
+func NewPostgresSavedViewRepository(db *database.DB) *PostgresSavedViewRepository {
+	return &PostgresSavedViewRepository{db: db}
+}
+
+// CreateView 写入视图
+func (r *PostgresSavedViewRepository) CreateView(ctx context.Context, view *models.SavedView) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO saved_view (tenant, owner, name, timezone, merchant_ids, statuses, date_range)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING view_id, created_at, updated_at
+	`, view.Tenant, view.Owner, view.Name, view.Timezone, pq.Array(view.MerchantIDs), pq.Array(view.Statuses), view.Range,
+	).Scan(&view.ID, &view.CreatedAt, &view.UpdatedAt)
+	if err != nil {
+		return savedViewError(err, view)
+	}
+	view.CreatedAt, view.UpdatedAt = view.CreatedAt.UTC(), view.UpdatedAt.UTC()
+	return nil
+}
+
+// UpdateView 覆盖视图的名称和筛选条件，写回 owner 和时间
+func (r *PostgresSavedViewRepository) UpdateView(ctx context.Context, view *models.SavedView) error {
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE saved_view SET
+			name = $3, timezone = $4, merchant_ids = $5, statuses = $6, date_range = $7
+		WHERE view_id = $1 AND tenant = $2
+		RETURNING owner, created_at, updated_at
+	`, view.ID, view.Tenant, view.Name, view.Timezone, pq.Array(view.MerchantIDs), pq.Array(view.Statuses), view.Range,
+	).Scan(&view.Owner, &view.CreatedAt, &view.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: 视图 %d", ErrNotFound, view.ID)
+	}
+	if err != nil {
+		return savedViewError(err, view)
+	}
+	view.CreatedAt, view.UpdatedAt = view.CreatedAt.UTC(), view.UpdatedAt.UTC()
+	return nil
+}
+
+// savedViewError 名称重复映射为 ErrConflict
+func savedViewError(err error, view *models.SavedView) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return fmt.Errorf("%w: %s 的视图名称 %s", ErrConflict, view.Owner, view.Name)
+	}
+	return fmt.Errorf("保存视图失败: %w", err)
+}
+
+// DeleteView 删除租户的视图
+func (r *PostgresSavedViewRepository) DeleteView(ctx context.Context, tenant string, id int) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM saved_view WHERE view_id = $1 AND tenant = $2`, id, tenant)
+	if err != nil {
+		return fmt.Errorf("删除视图 %d 失败: %w", id, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: 视图 %d", ErrNotFound, id)
+	}
+	return nil
+}
+
+// View 租户的单个视图
+func (r *PostgresSavedViewRepository) View(ctx context.Context, tenant string, id int) (*models.SavedView, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+savedViewColumns+` FROM saved_view WHERE view_id = $1 AND tenant = $2`, id, tenant)
+	view, err := scanSavedView(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: 视图 %d", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &view, nil
+}
+
+// Views 租户的视图，owner 为空时返回全部用户的视图
+func (r *PostgresSavedViewRepository) Views(ctx context.Context, tenant, owner string) ([]models.SavedView, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+savedViewColumns+` FROM saved_view
+		WHERE tenant = $1 AND ($2 = '' OR owner = $2)
+		ORDER BY view_id
+	`, tenant, owner)
+	if err != nil {
+		return nil, fmt.Errorf("查询视图失败: %w", err)
+	}
+	defer rows.Close()
+
+	var views []models.SavedView
+	for rows.Next() {
+		view, err := scanSavedView(rows)
+		if err != nil {
+			return nil, err
+		}
+		views = append(views, view)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历视图失败: %w", err)
+	}
+	return views, nil
+}
+
+// scanSavedView 扫描一个视图，单行查询没有结果时原样返回 sql.ErrNoRows
+func scanSavedView(row interface{ Scan(...interface{}) error }) (models.SavedView, error) {
+	var view models.SavedView
+	var merchantIDs pq.Int64Array
+	var statuses pq.StringArray
+	err := row.Scan(&view.ID, &view.Tenant, &view.Owner, &view.Name, &view.Timezone, &merchantIDs, &statuses,
+		&view.Range, &view.CreatedAt, &view.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return view, err
+	}
+	if err != nil {
+		return view, fmt.Errorf("扫描视图失败: %w", err)
+	}
+	view.MerchantIDs = make([]int, len(merchantIDs))
+	for i, id := range merchantIDs {
+		view.MerchantIDs[i] = int(id)
+	}
+	view.Statuses = []string(statuses)
+	if view.Statuses == nil {
+		view.Statuses = []string{}
+	}
+	view.CreatedAt, view.UpdatedAt = view.CreatedAt.UTC(), view.UpdatedAt.UTC()
+	return view, nil
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// foreignKeyViolation PostgreSQL 外键约束冲突的错误码
+const foreignKeyViolation = "23503"
+
+// PostgresSettingsRepository 基于 tenant_settings 表的商户配置仓储
+type PostgresSettingsRepository struct {
+	db *database.DB
+}
+
+// NewPostgresSettingsRepository 创建 PostgreSQL 商户配置仓储
+func NewPostgresSettingsRepository(db *database.DB) *PostgresSettingsRepository {
+	return &PostgresSettingsRepository{db: db}
+}
+
+// List 获取商户单独设置过的配置，按键排序
+func (r *PostgresSettingsRepository) List(merchantID int) ([]models.TenantSetting, error) {
+	rows, err := r.db.Query(`
+		SELECT setting_key, setting_value, updated_by, updated_at
+		FROM tenant_settings
+		WHERE merchant_id = $1
+		ORDER BY setting_key
+	`, merchantID)
+	if err != nil {
+		return nil, fmt.Errorf("查询商户配置失败: %w", err)
+	}
+	defer rows.Close()
+
+	var settings []models.TenantSetting
+	for rows.Next() {
+		setting := models.TenantSetting{MerchantID: merchantID}
+		var value []byte
+		var updatedAt sql.NullTime
+		if err := rows.Scan(&setting.Key, &value, &setting.UpdatedBy, &updatedAt); err != nil {
+			return nil, fmt.Errorf("扫描商户配置失败: %w", err)
+		}
+		setting.Value = value
+		if updatedAt.Valid {
+			setting.UpdatedAt = &updatedAt.Time
+		}
+		settings = append(settings, setting)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历商户配置失败: %w", err)
+	}
+	return settings, nil
+}
+
+// Save 写入配置，calendar 不为 nil 时在同一事务中更新商户的营业时间和周末列
+func (r *PostgresSettingsRepository) Save(setting *models.TenantSetting, calendar *models.MerchantCalendar) error {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := updateCalendar(tx, setting.MerchantID, calendar); err != nil {
+		return err
+	}
+
+	var updatedAt sql.NullTime
+	err = tx.QueryRow(`
+		INSERT INTO tenant_settings (merchant_id, setting_key, setting_value, updated_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (merchant_id, setting_key) DO UPDATE
+		SET setting_value = EXCLUDED.setting_value, updated_by = EXCLUDED.updated_by
+		RETURNING updated_at
+	`, setting.MerchantID, setting.Key, []byte(setting.Value), setting.UpdatedBy).Scan(&updatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			return fmt.Errorf("%w: 商户 %d", ErrNotFound, setting.MerchantID)
+		}
+		return fmt.Errorf("写入商户配置失败: %w", err)
+	}
+	if updatedAt.Valid {
+		setting.UpdatedAt = &updatedAt.Time
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// Delete 删除配置，calendar 不为 nil 时在同一事务中更新商户的营业时间和周末列
+func (r *PostgresSettingsRepository) Delete(merchantID int, key string, calendar *models.MerchantCalendar) error {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if calendar == nil {
+		var exists bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM dim_merchant WHERE merchant_id = $1)`, merchantID).Scan(&exists); err != nil {
+			return fmt.Errorf("查询商户失败: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("%w: 商户 %d", ErrNotFound, merchantID)
+		}
+	} else if err := updateCalendar(tx, merchantID, calendar); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM tenant_settings WHERE merchant_id = $1 AND setting_key = $2`, merchantID, key); err != nil {
+		return fmt.Errorf("删除商户配置失败: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// updateCalendar 更新商户的营业时间和周末列，calendar 为 nil 时不更新
+func updateCalendar(tx *sql.Tx, merchantID int, calendar *models.MerchantCalendar) error {
+	if calendar == nil {
+		return nil
+	}
+	result, err := tx.Exec(`
+		UPDATE dim_merchant
+		SET business_hours_start = $2, business_hours_end = $3, weekend_days = $4
+		WHERE merchant_id = $1
+	`, merchantID, calendar.BusinessHoursStart, calendar.BusinessHoursEnd, pq.Array(calendar.WeekendDays))
+	if err != nil {
+		return fmt.Errorf("更新商户营业时间失败: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: 商户 %d", ErrNotFound, merchantID)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// simulationSource 模拟订单的 order_source，便于与真实订单区分和清理
+const simulationSource = "simulator"
+
+// PostgresSimulationRepository 把模拟订单直接写入 dws_orders
+type PostgresSimulationRepository struct {
+	db *database.DB
+}
+
+// NewPostgresSimulationRepository 创建 PostgreSQL 模拟订单仓储
+func NewPostgresSimulationRepository(db *database.DB) *PostgresSimulationRepository {
+	return &PostgresSimulationRepository{db: db}
+}
+
+// InsertOrders 在一个语句中写入一批模拟订单
+func (r *PostgresSimulationRepository) InsertOrders(ctx context.Context, orders []models.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	const columns = 6
+	values := make([]string, 0, len(orders))
+	args := make([]interface{}, 0, len(orders)*columns)
+	for i, o := range orders {
+		n := i * columns
+		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, '%s', CURRENT_TIMESTAMP)",
+			n+1, n+2, n+3, n+4, n+5, n+6, simulationSource))
+		args = append(args, o.OrderNumber, o.MerchantID, o.Amount, o.Currency, o.Status, o.OrderTimeUTC)
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO dws_orders (
+			order_no, merchant_id, order_amount, currency, order_status, order_time_utc, order_source, ingested_at
+		) VALUES `+strings.Join(values, ", "), args...)
+	if err != nil {
+		return fmt.Errorf("写入模拟订单失败: %w", err)
+	}
+	return nil
+}
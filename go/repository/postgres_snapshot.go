@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// PostgresSnapshotRepository 基于 daily_revenue_snapshot / daily_revenue_adjustment 表的日结仓储
+type PostgresSnapshotRepository struct {
+	db *database.DB
+}
+
+// NewPostgresSnapshotRepository 创建 PostgreSQL 日结仓储
+func NewPostgresSnapshotRepository(db *database.DB) *PostgresSnapshotRepository {
+	return &PostgresSnapshotRepository{db: db}
+}
+
+// LastClosedDate 获取商户最近一次结账的本地日期
+func (r *PostgresSnapshotRepository) LastClosedDate(merchantID int) (string, error) {
+	var date sql.NullTime
+	err := r.db.QueryRow(`SELECT MAX(local_date) FROM daily_revenue_snapshot WHERE merchant_id = $1`, merchantID).Scan(&date)
+	if err != nil {
+		return "", fmt.Errorf("查询最近结账日期失败: %w", err)
+	}
+	if !date.Valid {
+		return "", nil
+	}
+	return date.Time.Format("2006-01-02"), nil
+}
+
+// EarliestOrderTime 获取商户最早一笔订单的 UTC 时间
+func (r *PostgresSnapshotRepository) EarliestOrderTime(merchantID int) (*time.Time, error) {
+	var t sql.NullTime
+	err := r.db.QueryRow(`SELECT MIN(order_time_utc) FROM dws_orders WHERE merchant_id = $1`, merchantID).Scan(&t)
+	if err != nil {
+		return nil, fmt.Errorf("查询最早订单时间失败: %w", err)
+	}
+	if !t.Valid {
+		return nil, nil
+	}
+	utc := t.Time.UTC()
+	return &utc, nil
+}
+
+// WindowTotals 统计商户在 UTC 区间内的订单数和金额
+func (r *PostgresSnapshotRepository) WindowTotals(merchantID int, start, end time.Time) (int, decimal.Decimal, error) {
+	query := `
+		SELECT COUNT(*), COALESCE(SUM(order_amount), 0)
+		FROM dws_orders
+		WHERE merchant_id = $1 AND order_time_utc >= $2 AND order_time_utc < $3
+	`
+
+	var count int
+	var amount decimal.Decimal
+	if err := r.db.QueryRow(query, merchantID, start, end).Scan(&count, &amount); err != nil {
+		return 0, decimal.Zero, fmt.Errorf("统计日结数据失败: %w", err)
+	}
+	return count, amount, nil
+}
+
+// Create 写入快照
+func (r *PostgresSnapshotRepository) Create(s models.DailyRevenueSnapshot) (bool, error) {
+	result, err := r.db.Exec(`
+		INSERT INTO daily_revenue_snapshot (
+			merchant_id, local_date, timezone, window_start_utc, window_end_utc, order_count, total_amount
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (merchant_id, local_date) DO NOTHING
+	`, s.MerchantID, s.LocalDate, s.Timezone, s.WindowStartUTC, s.WindowEndUTC, s.OrderCount, s.TotalAmount)
+	if err != nil {
+		return false, fmt.Errorf("写入日结快照失败: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("获取写入结果失败: %w", err)
+	}
+	return n > 0, nil
+}
+
+// ListByDate 获取指定本地日期的所有快照
+func (r *PostgresSnapshotRepository) ListByDate(date string) ([]models.DailyRevenueSnapshot, error) {
+	query := `
+		SELECT
+			s.snapshot_id, s.merchant_id, m.merchant_name, s.local_date, s.timezone,
+			s.window_start_utc, s.window_end_utc, s.order_count, s.total_amount, s.closed_at,
+			COUNT(a.adjustment_id),
+			COALESCE(SUM(a.order_count_delta), 0),
+			COALESCE(SUM(a.amount_delta), 0)
+		FROM daily_revenue_snapshot s
+		JOIN dim_merchant m ON m.merchant_id = s.merchant_id
+		LEFT JOIN daily_revenue_adjustment a ON a.snapshot_id = s.snapshot_id
+		WHERE s.local_date = $1
+		GROUP BY s.snapshot_id, m.merchant_name
+		ORDER BY s.merchant_id
+	`
+
+	rows, err := r.db.Query(query, date)
+	if err != nil {
+		return nil, fmt.Errorf("查询日结快照失败: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []models.DailyRevenueSnapshot
+	for rows.Next() {
+		var s models.DailyRevenueSnapshot
+		var localDate time.Time
+		var countDelta int
+		var amountDelta decimal.Decimal
+		err := rows.Scan(
+			&s.SnapshotID,
+			&s.MerchantID,
+			&s.MerchantName,
+			&localDate,
+			&s.Timezone,
+			&s.WindowStartUTC,
+			&s.WindowEndUTC,
+			&s.OrderCount,
+			&s.TotalAmount,
+			&s.ClosedAt,
+			&s.AdjustmentCount,
+			&countDelta,
+			&amountDelta,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("扫描日结快照失败: %w", err)
+		}
+		s.LocalDate = localDate.Format("2006-01-02")
+		s.AdjustedOrderCount = s.OrderCount + countDelta
+		s.AdjustedAmount = s.TotalAmount.Add(amountDelta)
+		snapshots = append(snapshots, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历日结快照失败: %w", err)
+	}
+
+	return snapshots, nil
+}
+
+// LateOrders 获取入库时间晚于所属快照结账时间的订单
+func (r *PostgresSnapshotRepository) LateOrders(filter models.LateOrderFilter) ([]models.LateOrder, error) {
+	query := `
+		SELECT
+			o.order_id, o.order_no, o.merchant_id, m.merchant_name, s.timezone,
+			o.order_amount, o.order_time_utc, o.ingested_at,
+			s.local_date, s.snapshot_id, s.closed_at, a.adjustment_id
+		FROM dws_orders o
+		JOIN daily_revenue_snapshot s
+			ON s.merchant_id = o.merchant_id
+			AND o.order_time_utc >= s.window_start_utc
+			AND o.order_time_utc < s.window_end_utc
+		JOIN dim_merchant m ON m.merchant_id = o.merchant_id
+		LEFT JOIN daily_revenue_adjustment a
+			ON a.snapshot_id = s.snapshot_id AND a.order_id = o.order_id
+		WHERE o.ingested_at > s.closed_at
+			AND ($1 = '' OR s.local_date = NULLIF($1, '')::date)
+			AND ($2 = 0 OR o.merchant_id = $2)
+			AND (NOT $3 OR a.adjustment_id IS NULL)
+		ORDER BY s.local_date, o.order_time_utc, o.order_id
+	`
+
+	rows, err := r.db.Query(query, filter.LocalDate, filter.MerchantID, filter.UnadjustedOnly)
+	if err != nil {
+		return nil, fmt.Errorf("查询迟到订单失败: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []models.LateOrder
+	for rows.Next() {
+		var o models.LateOrder
+		var localDate time.Time
+		var adjustmentID sql.NullInt64
+		err := rows.Scan(
+			&o.OrderID,
+			&o.OrderNumber,
+			&o.MerchantID,
+			&o.MerchantName,
+			&o.Timezone,
+			&o.Amount,
+			&o.OrderTimeUTC,
+			&o.IngestedAt,
+			&localDate,
+			&o.SnapshotID,
+			&o.ClosedAt,
+			&adjustmentID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("扫描迟到订单失败: %w", err)
+		}
+		o.LocalDate = localDate.Format("2006-01-02")
+		o.DelaySeconds = int64(o.IngestedAt.Sub(o.OrderTimeUTC).Seconds())
+		if adjustmentID.Valid {
+			o.AdjustmentID = &adjustmentID.Int64
+		}
+		orders = append(orders, o)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历迟到订单失败: %w", err)
+	}
+
+	return orders, nil
+}
+
+// AddAdjustments 在一个事务中追加调整记录，同一快照+订单已有调整时跳过
+func (r *PostgresSnapshotRepository) AddAdjustments(adjustments []models.DailyRevenueAdjustment) ([]models.DailyRevenueAdjustment, error) {
+	if len(adjustments) == 0 {
+		return nil, nil
+	}
+
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return nil, fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO daily_revenue_adjustment (
+			snapshot_id, merchant_id, local_date, order_count_delta, amount_delta, reason, order_id, created_by
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (snapshot_id, order_id) WHERE order_id IS NOT NULL DO NOTHING
+		RETURNING adjustment_id, created_at
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("准备写入调整记录失败: %w", err)
+	}
+	defer stmt.Close()
+
+	var created []models.DailyRevenueAdjustment
+	for _, a := range adjustments {
+		err := stmt.QueryRow(
+			a.SnapshotID, a.MerchantID, a.LocalDate, a.OrderCountDelta, a.AmountDelta, a.Reason, a.OrderID, a.CreatedBy,
+		).Scan(&a.AdjustmentID, &a.CreatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("写入快照 %d 的调整记录失败: %w", a.SnapshotID, err)
+		}
+		created = append(created, a)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("提交调整记录失败: %w", err)
+	}
+	return created, nil
+}
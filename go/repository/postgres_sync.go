@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// syncOrderColumns 离线同步读写订单时返回的列，与 scanSyncOrder 的顺序一致
+const syncOrderColumns = `order_id, merchant_id, order_no, order_amount, COALESCE(currency, 'USD'), COALESCE(order_status, 'pending'),
+	order_time_utc, version, created_at, updated_at`
+
+// PostgresSyncRepository 基于 dws_orders、sync_mutation 和 sync_device 表的离线同步仓储
+type PostgresSyncRepository struct {
+	db *database.DB
+}
+
+// NewPostgresSyncRepository 创建 PostgreSQL 离线同步仓储
+func NewPostgresSyncRepository(db *database.DB) *PostgresSyncRepository {
+	return &PostgresSyncRepository{db: db}
+}
+
+// ApplyOrder 按（设备，修改ID）取咨询锁，同一修改的并发重试依次处理，后到的读到先到的结果
+// 新建订单时再按（商户，订单号）取与 Webhook 写入相同的咨询锁；仍违反唯一约束时返回 ErrConflict，设备重试时按订单号已存在处理
+func (r *PostgresSyncRepository) ApplyOrder(ctx context.Context, deviceID string, merchantID int, mutation models.SyncMutation, resolve SyncResolver) (*models.SyncMutationResult, error) {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return nil, fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1), hashtext($2))`, deviceID, mutation.MutationID); err != nil {
+		return nil, fmt.Errorf("锁定同步修改失败: %w", err)
+	}
+
+	var stored []byte
+	err = tx.QueryRowContext(ctx, `SELECT result FROM sync_mutation WHERE device_id = $1 AND mutation_id = $2`,
+		deviceID, mutation.MutationID).Scan(&stored)
+	if err == nil {
+		var result models.SyncMutationResult
+		if err := json.Unmarshal(stored, &result); err != nil {
+			return nil, fmt.Errorf("解析同步结果失败: %w", err)
+		}
+		result.Replayed = true
+		return &result, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("查询同步结果失败: %w", err)
+	}
+
+	var row *sql.Row
+	if mutation.Op == models.ChangeOpInsert {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1, hashtext($2))`, merchantID, mutation.OrderNumber); err != nil {
+			return nil, fmt.Errorf("锁定订单号失败: %w", err)
+		}
+		row = tx.QueryRowContext(ctx, `SELECT `+syncOrderColumns+` FROM dws_orders WHERE merchant_id = $1 AND order_no = $2 FOR UPDATE`,
+			merchantID, mutation.OrderNumber)
+	} else {
+		row = tx.QueryRowContext(ctx, `SELECT `+syncOrderColumns+` FROM dws_orders WHERE order_id = $1 FOR UPDATE`, mutation.OrderID)
+	}
+	current, err := scanSyncOrder(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		current = nil
+	} else if err != nil {
+		return nil, fmt.Errorf("锁定订单失败: %w", err)
+	}
+
+	next, result := resolve(current)
+	if next != nil {
+		if next.ID == 0 {
+			row = tx.QueryRowContext(ctx, `
+				INSERT INTO dws_orders (
+					order_no, merchant_id, order_amount, currency, order_status, order_time_utc, order_source, ingested_at
+				) VALUES ($1, $2, $3, $4, $5, $6, 'sync', CURRENT_TIMESTAMP)
+				RETURNING `+syncOrderColumns,
+				next.OrderNumber, next.MerchantID, next.Amount, next.Currency, next.Status, next.OrderTimeUTC)
+		} else {
+			row = tx.QueryRowContext(ctx, `
+				UPDATE dws_orders
+				SET order_amount = $2, currency = $3, order_status = $4, order_time_utc = $5
+				WHERE order_id = $1
+				RETURNING `+syncOrderColumns,
+				next.ID, next.Amount, next.Currency, next.Status, next.OrderTimeUTC)
+		}
+		written, err := scanSyncOrder(row)
+		if err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+				return nil, fmt.Errorf("%w: 商户 %d 的订单号 %s 已存在", ErrConflict, next.MerchantID, next.OrderNumber)
+			}
+			return nil, fmt.Errorf("写入订单失败: %w", err)
+		}
+		result.Order = written
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("序列化同步结果失败: %w", err)
+	}
+	var orderID sql.NullInt64
+	if result.Order != nil {
+		orderID = sql.NullInt64{Int64: int64(result.Order.ID), Valid: true}
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO sync_mutation (device_id, mutation_id, order_id, status, result)
+		VALUES ($1, $2, $3, $4, $5)
+	`, deviceID, mutation.MutationID, orderID, result.Status, data)
+	if err != nil {
+		return nil, fmt.Errorf("保存同步结果失败: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("提交同步修改失败: %w", err)
+	}
+	return &result, nil
+}
+
+// SaveDevice 写入或覆盖设备最近一次同步
+func (r *PostgresSyncRepository) SaveDevice(ctx context.Context, device models.SyncDevice) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO sync_device (device_id, merchant_id, timezone, clock_skew_ms, last_pull_seq, last_sync_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (device_id) DO UPDATE SET
+			merchant_id = EXCLUDED.merchant_id,
+			timezone = EXCLUDED.timezone,
+			clock_skew_ms = EXCLUDED.clock_skew_ms,
+			last_pull_seq = EXCLUDED.last_pull_seq,
+			last_sync_at = EXCLUDED.last_sync_at
+	`, device.DeviceID, device.MerchantID, device.Timezone, device.ClockSkewMs, device.LastPullSeq, device.LastSyncAt)
+	if err != nil {
+		return fmt.Errorf("保存设备同步状态失败: %w", err)
+	}
+	return nil
+}
+
+// scanSyncOrder 扫描 syncOrderColumns
+func scanSyncOrder(row *sql.Row) (*models.Order, error) {
+	var o models.Order
+	err := row.Scan(&o.ID, &o.MerchantID, &o.OrderNumber, &o.Amount, &o.Currency, &o.Status,
+		&o.OrderTimeUTC, &o.Version, &o.CreatedAt, &o.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
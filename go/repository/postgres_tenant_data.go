@@ -0,0 +1,369 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// tenantTable 一张保存商户数据的表
+type tenantTable struct {
+	// section 导出文件中的分组名称
+	section string
+	table   string
+	// where 属于商户的行（表别名 t，$1 为商户ID）
+	where   string
+	orderBy string
+	// internal 为 true 时是派生或运维数据，不导出，只删除
+	internal bool
+	// keep 为 true 时匿名化方式保留该表，不做处理
+	keep bool
+	// anonymize 匿名化方式下执行的 SET 子句，为空时删除行
+	anonymize string
+	// identified 匿名化后仍可识别的行，为空时该商户的全部行
+	identified string
+}
+
+// tenantTables 按删除顺序排列：引用其他表的行先删除，变更流水由其他表的修改触发写入，最后处理；
+// 导出按相反的顺序，商户和配置在前
+var tenantTables = []tenantTable{
+	{section: "ingest_deliveries", table: "ingest_delivery", where: "t.merchant_id = $1", orderBy: "t.delivery_id"},
+	{section: "sync_mutations", table: "sync_mutation", where: "t.device_id IN (SELECT device_id FROM sync_device WHERE merchant_id = $1)", orderBy: "t.device_id, t.mutation_id"},
+	{section: "sync_devices", table: "sync_device", where: "t.merchant_id = $1", orderBy: "t.device_id"},
+	{section: "consistency_discrepancies", table: "consistency_discrepancy", where: "t.merchant_id = $1", orderBy: "t.discrepancy_id", internal: true},
+	{section: "alert_events", table: "alert_event", where: "t.merchant_id = $1", orderBy: "t.event_id"},
+	{section: "alert_rules", table: "alert_rule", where: "t.merchant_id = $1", orderBy: "t.rule_id"},
+	{section: "report_definitions", table: "report_definition", where: "t.merchant_id = $1", orderBy: "t.definition_id"},
+	{section: "order_attributes", table: "order_attributes", where: "t.merchant_id = $1", orderBy: "t.tenant, t.order_id"},
+	{section: "merchant_attributes", table: "merchant_attributes", where: "t.merchant_id = $1", orderBy: "t.tenant"},
+	{section: "retention_buckets", table: "retention_bucket", where: "t.merchant_id = $1", orderBy: "t.run_id, t.month"},
+	{
+		section: "revenue_adjustments", table: "daily_revenue_adjustment", where: "t.merchant_id = $1", orderBy: "t.adjustment_id",
+		// 迟到订单的调整原因为“原因: 订单号”
+		anonymize: "reason = split_part(t.reason, ':', 1)", identified: "t.reason LIKE '%:%'",
+	},
+	{section: "revenue_snapshots", table: "daily_revenue_snapshot", where: "t.merchant_id = $1", orderBy: "t.local_date", keep: true},
+	{
+		section: "refunds", table: "dws_order_refund", where: "t.merchant_id = $1", orderBy: "t.refund_id",
+		anonymize: "reason = '', created_by = 'anonymized'", identified: "t.reason <> '' OR t.created_by <> 'anonymized'",
+	},
+	{
+		section: "archived_orders", table: "dws_orders_archive", where: "t.merchant_id = $1", orderBy: "t.order_id",
+		anonymize:  "order_no = 'anon-' || t.order_id, customer_id = NULL, customer_email = NULL",
+		identified: "t.order_no <> 'anon-' || t.order_id OR t.customer_id IS NOT NULL OR t.customer_email IS NOT NULL",
+	},
+	{
+		section: "orders", table: "dws_orders", where: "t.merchant_id = $1", orderBy: "t.order_id",
+		anonymize:  "order_no = 'anon-' || t.order_id, customer_id = NULL, customer_email = NULL",
+		identified: "t.order_no <> 'anon-' || t.order_id OR t.customer_id IS NOT NULL OR t.customer_email IS NOT NULL",
+	},
+	// 删除订单时触发器会写入小时汇总，汇总在订单之后删除
+	{section: "hourly_rollup_archived", table: "agg_orders_hourly_archived", where: "t.merchant_id = $1", internal: true, keep: true},
+	{section: "hourly_rollup", table: "agg_orders_hourly", where: "t.merchant_id = $1", internal: true, keep: true},
+	{section: "retention_policy", table: "retention_policy", where: "t.merchant_id = $1"},
+	{section: "billing_periods", table: "billing_period", where: "t.merchant_id = $1", orderBy: "t.sequence", keep: true},
+	{section: "subscription", table: "merchant_subscription", where: "t.merchant_id = $1", keep: true},
+	{section: "webhooks", table: "merchant_webhook", where: "t.merchant_id = $1", orderBy: "t.webhook_id"},
+	{section: "report_settings", table: "merchant_report_settings", where: "t.merchant_id = $1"},
+	{section: "settings", table: "tenant_settings", where: "t.merchant_id = $1", orderBy: "t.setting_key"},
+	{section: "api_keys", table: "tenant_api_key", where: "t.merchant_id = $1", orderBy: "t.key_id"},
+	{section: "provisioning", table: "tenant_provisioning", where: "t.merchant_id = $1"},
+	{section: "lifecycle_events", table: "tenant_lifecycle_event", where: "t.merchant_id = $1", orderBy: "t.event_id"},
+	{section: "canary", table: "canary_tenant", where: "t.merchant_id = $1", internal: true},
+	{
+		section: "merchant", table: "dim_merchant", where: "t.merchant_id = $1",
+		// 国家、城市、时区和营业时间不能识别商户，保留用于统计
+		anonymize:  "merchant_name = '匿名商户 ' || t.merchant_id, merchant_code = 'anon-' || t.merchant_id, status = 'inactive', organization_id = NULL",
+		identified: "t.merchant_code <> 'anon-' || t.merchant_id OR t.organization_id IS NOT NULL",
+	},
+	{
+		section: "audit", table: "change_log", where: "t.merchant_id = $1", orderBy: "t.change_id",
+		// 匿名化时保留本事务写入的流水（快照已是匿名化后的数据），客户端增量同步后本地数据随之匿名化
+		identified: "t.changed_at < CURRENT_TIMESTAMP",
+	},
+}
+
+// action 表在 mode 方式下的处理：delete、anonymize 或 keep
+func (t tenantTable) action(mode string) string {
+	if mode == models.TenantErasureAnonymize {
+		switch {
+		case t.keep:
+			return "keep"
+		case t.anonymize != "":
+			return "anonymize"
+		}
+	}
+	return "delete"
+}
+
+// target 表在 mode 方式下需要处理（以及处理后核验）的行
+func (t tenantTable) target(mode string) string {
+	if mode == models.TenantErasureAnonymize && t.identified != "" {
+		return "(" + t.where + ") AND (" + t.identified + ")"
+	}
+	return t.where
+}
+
+// tenantRoleStep 删除报告中租户专属数据库角色（及其行级安全策略）一项的名称
+func tenantRoleStep(merchantID int) string {
+	return "role:" + tenantRoleName(merchantID)
+}
+
+// PostgresTenantDataRepository 跨表导出、删除和匿名化一个商户的数据，处理记录保存在 tenant_erasure 表
+type PostgresTenantDataRepository struct {
+	db *database.DB
+}
+
+// NewPostgresTenantDataRepository 创建 PostgreSQL 租户数据仓储
+func NewPostgresTenantDataRepository(db *database.DB) *PostgresTenantDataRepository {
+	return &PostgresTenantDataRepository{db: db}
+}
+
+// Export 在可重复读的只读事务中逐表读取，每行由 row_to_json 转为 JSON，各表的数据属于同一个快照
+func (r *PostgresTenantDataRepository) Export(ctx context.Context, merchantID int, section func(name string) error, row func(json.RawMessage) error) error {
+	tx, err := r.db.BeginReadOnlyTx(ctx)
+	if err != nil {
+		return fmt.Errorf("开始导出事务失败: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `SET TRANSACTION ISOLATION LEVEL REPEATABLE READ`); err != nil {
+		return fmt.Errorf("设置导出事务隔离级别失败: %w", err)
+	}
+
+	for i := len(tenantTables) - 1; i >= 0; i-- {
+		t := tenantTables[i]
+		if t.internal {
+			continue
+		}
+		if err := section(t.section); err != nil {
+			return err
+		}
+		if err := r.exportTable(ctx, tx, t, merchantID, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportTable 导出一张表中属于商户的行
+func (r *PostgresTenantDataRepository) exportTable(ctx context.Context, tx *sql.Tx, t tenantTable, merchantID int, fn func(json.RawMessage) error) error {
+	query := `SELECT row_to_json(t)::text FROM ` + t.table + ` t WHERE ` + t.where
+	if t.orderBy != "" {
+		query += ` ORDER BY ` + t.orderBy
+	}
+	rows, err := tx.QueryContext(ctx, query, merchantID)
+	if err != nil {
+		return fmt.Errorf("导出 %s 失败: %w", t.table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return fmt.Errorf("扫描 %s 失败: %w", t.table, err)
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("遍历 %s 失败: %w", t.table, err)
+	}
+	return nil
+}
+
+// Preview 统计每张表在 mode 方式下会处理的行数
+func (r *PostgresTenantDataRepository) Preview(ctx context.Context, merchantID int, mode string) ([]models.TenantErasureStep, error) {
+	steps := make([]models.TenantErasureStep, 0, len(tenantTables))
+	for _, t := range tenantTables {
+		step := models.TenantErasureStep{Table: t.table, Action: t.action(mode)}
+		if step.Action != "keep" {
+			err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+t.table+` t WHERE `+t.target(mode), merchantID).Scan(&step.Rows)
+			if err != nil {
+				return nil, fmt.Errorf("统计 %s 失败: %w", t.table, err)
+			}
+		}
+		steps = append(steps, step)
+	}
+
+	step := models.TenantErasureStep{Table: tenantRoleStep(merchantID), Action: models.TenantErasureDelete}
+	if err := r.db.QueryRowContext(ctx, tenantRoleCountQuery, tenantRoleName(merchantID)).Scan(&step.Rows); err != nil {
+		return nil, fmt.Errorf("统计数据库角色失败: %w", err)
+	}
+	return append(steps, step), nil
+}
+
+// Erase 按 tenantTables 的顺序删除或更新，全部完成后在同一事务中重新统计每张表的残留行数；
+// 有残留时回滚并返回 ErrConflict，返回的结果中 Remaining 为核验时的残留行数
+// 事务设置 saasview.tenant_erasure 后，只允许插入的营收快照和调整记录可以删除或匿名化
+func (r *PostgresTenantDataRepository) Erase(ctx context.Context, merchantID int, mode string, beforeCommit func() error) ([]models.TenantErasureStep, error) {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return nil, fmt.Errorf("开始删除事务失败: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `SELECT set_config('saasview.tenant_erasure', 'on', true)`); err != nil {
+		return nil, fmt.Errorf("设置删除事务失败: %w", err)
+	}
+
+	steps := make([]models.TenantErasureStep, 0, len(tenantTables))
+	for _, t := range tenantTables {
+		step := models.TenantErasureStep{Table: t.table, Action: t.action(mode)}
+		var query string
+		switch step.Action {
+		case "keep":
+			steps = append(steps, step)
+			continue
+		case "anonymize":
+			query = `UPDATE ` + t.table + ` AS t SET ` + t.anonymize + ` WHERE ` + t.target(mode)
+		default:
+			query = `DELETE FROM ` + t.table + ` AS t WHERE ` + t.target(mode)
+		}
+		result, err := tx.ExecContext(ctx, query, merchantID)
+		if err != nil {
+			return nil, fmt.Errorf("处理 %s 失败: %w", t.table, err)
+		}
+		step.Rows, _ = result.RowsAffected()
+		steps = append(steps, step)
+	}
+	// 两种方式都删除开通记录，rls 隔离创建的数据库角色和行级安全策略随之删除
+	role := models.TenantErasureStep{Table: tenantRoleStep(merchantID), Action: models.TenantErasureDelete}
+	if role.Rows, err = dropTenantRole(ctx, tx, merchantID); err != nil {
+		return nil, err
+	}
+
+	var residual []string
+	for i, t := range tenantTables {
+		if steps[i].Action == "keep" {
+			continue
+		}
+		err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+t.table+` t WHERE `+t.target(mode), merchantID).Scan(&steps[i].Remaining)
+		if err != nil {
+			return nil, fmt.Errorf("核验 %s 失败: %w", t.table, err)
+		}
+		if steps[i].Remaining > 0 {
+			residual = append(residual, fmt.Sprintf("%s %d 行", t.table, steps[i].Remaining))
+		}
+	}
+	if err := tx.QueryRowContext(ctx, tenantRoleCountQuery, tenantRoleName(merchantID)).Scan(&role.Remaining); err != nil {
+		return nil, fmt.Errorf("核验数据库角色失败: %w", err)
+	}
+	if role.Remaining > 0 {
+		residual = append(residual, role.Table)
+	}
+	steps = append(steps, role)
+	if len(residual) > 0 {
+		return steps, fmt.Errorf("%w: 核验未通过，仍有 %v，已回滚", ErrConflict, residual)
+	}
+
+	if beforeCommit != nil {
+		if err := beforeCommit(); err != nil {
+			return steps, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return steps, fmt.Errorf("提交删除事务失败: %w", err)
+	}
+	return steps, nil
+}
+
+// CreateErasure 写入执行中的处理记录
+func (r *PostgresTenantDataRepository) CreateErasure(ctx context.Context, e *models.TenantErasure) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO tenant_erasure (merchant_id, mode, status, requested_by, started_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING erasure_id
+	`, e.MerchantID, e.Mode, e.Status, e.RequestedBy, e.StartedAt).Scan(&e.ID)
+	if err != nil {
+		return fmt.Errorf("写入删除记录失败: %w", err)
+	}
+	return nil
+}
+
+// FinishErasure 更新处理记录的状态、核验结果和结束时间
+func (r *PostgresTenantDataRepository) FinishErasure(ctx context.Context, e *models.TenantErasure) error {
+	steps, err := json.Marshal(e.Steps)
+	if err != nil {
+		return fmt.Errorf("序列化核验报告失败: %w", err)
+	}
+	_, err = r.db.ExecContext(ctx, `
+		UPDATE tenant_erasure
+		SET status = $2, finished_at = $3, verified = $4, steps = $5, error = $6
+		WHERE erasure_id = $1
+	`, e.ID, e.Status, e.FinishedAt, e.Verified, steps, e.Error)
+	if err != nil {
+		return fmt.Errorf("更新删除记录 %d 失败: %w", e.ID, err)
+	}
+	return nil
+}
+
+// Erasures 获取处理记录，按开始时间倒序
+func (r *PostgresTenantDataRepository) Erasures(ctx context.Context, merchantID, limit int) ([]models.TenantErasure, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+tenantErasureColumns+`
+		FROM tenant_erasure
+		WHERE $1 = 0 OR merchant_id = $1
+		ORDER BY started_at DESC, erasure_id DESC
+		LIMIT $2
+	`, merchantID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询删除记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	var erasures []models.TenantErasure
+	for rows.Next() {
+		var e models.TenantErasure
+		if err := scanTenantErasure(rows, &e); err != nil {
+			return nil, err
+		}
+		erasures = append(erasures, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历删除记录失败: %w", err)
+	}
+	return erasures, nil
+}
+
+// Erasure 获取一次处理记录
+func (r *PostgresTenantDataRepository) Erasure(ctx context.Context, id int64) (*models.TenantErasure, error) {
+	var e models.TenantErasure
+	row := r.db.QueryRowContext(ctx, `SELECT `+tenantErasureColumns+` FROM tenant_erasure WHERE erasure_id = $1`, id)
+	if err := scanTenantErasure(row, &e); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: 删除记录 %d", ErrNotFound, id)
+		}
+		return nil, err
+	}
+	return &e, nil
+}
+
+// tenantErasureColumns 与 scanTenantErasure 对应的列
+const tenantErasureColumns = `erasure_id, merchant_id, mode, status, requested_by, started_at, finished_at, verified, steps, error`
+
+// scanTenantErasure 扫描一条处理记录，单行查询没有结果时原样返回 sql.ErrNoRows
+func scanTenantErasure(row interface{ Scan(...interface{}) error }, e *models.TenantErasure) error {
+	var finishedAt sql.NullTime
+	var steps []byte
+	err := row.Scan(&e.ID, &e.MerchantID, &e.Mode, &e.Status, &e.RequestedBy, &e.StartedAt, &finishedAt, &e.Verified, &steps, &e.Error)
+	if errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("扫描删除记录失败: %w", err)
+	}
+	if err := json.Unmarshal(steps, &e.Steps); err != nil {
+		return fmt.Errorf("解析核验报告失败: %w", err)
+	}
+	e.StartedAt = e.StartedAt.UTC()
+	if finishedAt.Valid {
+		t := finishedAt.Time.UTC()
+		e.FinishedAt = &t
+	}
+	return nil
+}
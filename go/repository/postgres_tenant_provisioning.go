@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// tenantRoleParent 租户专属数据库角色的公共父角色，见 sql/33_tenant_provisioning.sql
+const tenantRoleParent = "saasview_tenant"
+
+// tenantRLSTables rls 隔离时为租户角色创建行级安全策略的表
+var tenantRLSTables = []string{"dws_orders", "dim_merchant"}
+
+// PostgresProvisioningRepository 基于 tenant_provisioning / tenant_api_key 表的租户开通仓储
+type PostgresProvisioningRepository struct {
+	db *database.DB
+}
+
+// NewPostgresProvisioningRepository 创建 PostgreSQL 租户开通仓储
+func NewPostgresProvisioningRepository(db *database.DB) *PostgresProvisioningRepository {
+	return &PostgresProvisioningRepository{db: db}
+}
+
+// Provision 在一个事务中开通租户；CREATE ROLE 和 CREATE POLICY 同样随事务回滚
+func (r *PostgresProvisioningRepository) Provision(ctx context.Context, p *models.TenantProvisioning, keyHash string) error {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertOnboarding(ctx, tx, &p.MerchantOnboarding); err != nil {
+		return err
+	}
+	merchantID := p.Merchant.ID
+
+	// 开通同样记入生命周期的变更记录，from_state 为空
+	_, err = tx.ExecContext(ctx, `
+		UPDATE dim_merchant
+		SET lifecycle = $2, trial_ends_on = NULLIF($3, '')::date, lifecycle_changed_at = CURRENT_TIMESTAMP
+		WHERE merchant_id = $1
+	`, merchantID, p.Lifecycle, p.TrialEndsOn)
+	if err != nil {
+		return fmt.Errorf("写入生命周期失败: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO tenant_lifecycle_event (merchant_id, from_state, to_state, trial_ends_on, reason, changed_by)
+		VALUES ($1, '', $2, NULLIF($3, '')::date, '开通', $4)
+	`, merchantID, p.Lifecycle, p.TrialEndsOn, p.ProvisionedBy)
+	if err != nil {
+		return fmt.Errorf("写入生命周期变更记录失败: %w", err)
+	}
+
+	for i := range p.Settings {
+		setting := &p.Settings[i]
+		setting.MerchantID = merchantID
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO tenant_settings (merchant_id, setting_key, setting_value, updated_by)
+			VALUES ($1, $2, $3, $4)
+			RETURNING updated_at
+		`, merchantID, setting.Key, []byte(setting.Value), setting.UpdatedBy).Scan(&setting.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("写入配置 %s 失败: %w", setting.Key, err)
+		}
+	}
+
+	key := &p.APIKey
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO tenant_api_key (merchant_id, key_prefix, key_hash, label)
+		VALUES ($1, $2, $3, $4)
+		RETURNING key_id, created_at
+	`, merchantID, key.Prefix, keyHash, key.Label).Scan(&key.KeyID, &key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("写入 API 密钥失败: %w", err)
+	}
+
+	if p.Isolation == models.TenantIsolationRLS {
+		if err := createTenantRole(ctx, tx, merchantID, &p.DBRole); err != nil {
+			return err
+		}
+	}
+
+	// 示例订单的订单号由商户ID生成，order_source 为 sample，便于与真实订单区分
+	order := &p.SampleOrder
+	order.MerchantID = merchantID
+	order.OrderNumber = fmt.Sprintf("SAMPLE-%d", merchantID)
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO dws_orders (
+			order_no, merchant_id, order_amount, currency, order_status, order_time_utc, order_source, ingested_at
+		) VALUES ($1, $2, $3, $4, $5, $6, 'sample', CURRENT_TIMESTAMP)
+		RETURNING order_id, version, created_at, updated_at
+	`, order.OrderNumber, merchantID, order.Amount, order.Currency, order.Status, order.OrderTimeUTC,
+	).Scan(&order.ID, &order.Version, &order.CreatedAt, &order.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("写入示例订单失败: %w", err)
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO tenant_provisioning (merchant_id, isolation, db_role, sample_order_id, provisioned_by)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5)
+		RETURNING provisioned_at
+	`, merchantID, p.Isolation, p.DBRole, order.ID, p.ProvisionedBy).Scan(&p.ProvisionedAt)
+	if err != nil {
+		return fmt.Errorf("写入开通记录失败: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交开通数据失败: %w", err)
+	}
+	return nil
+}
+
+// APIKeyMerchant 查找未吊销的 API 密钥所属的商户
+func (r *PostgresProvisioningRepository) APIKeyMerchant(ctx context.Context, keyHash string) (int, error) {
+	var merchantID int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT merchant_id FROM tenant_api_key
+		WHERE key_hash = $1 AND revoked_at IS NULL
+	`, keyHash).Scan(&merchantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%w: API 密钥", ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("查询 API 密钥失败: %w", err)
+	}
+	return merchantID, nil
+}
+
+// RevokeAPIKey 吊销 API 密钥
+func (r *PostgresProvisioningRepository) RevokeAPIKey(ctx context.Context, merchantID, keyID int) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE tenant_api_key SET revoked_at = CURRENT_TIMESTAMP
+		WHERE key_id = $1 AND merchant_id = $2 AND revoked_at IS NULL
+	`, keyID, merchantID)
+	if err != nil {
+		return fmt.Errorf("吊销 API 密钥失败: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("吊销 API 密钥失败: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: 商户 %d 的 API 密钥 %d", ErrNotFound, merchantID, keyID)
+	}
+	return nil
+}
+
+// createTenantRole 创建租户专属的数据库角色，并在 tenantRLSTables 上创建只匹配该商户的行级安全策略
+// DDL 不支持参数，角色名和商户ID都由商户ID生成，不含外部输入
+func createTenantRole(ctx context.Context, tx *sql.Tx, merchantID int, role *string) error {
+	name := tenantRoleName(merchantID)
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`CREATE ROLE %s NOLOGIN IN ROLE %s`,
+		pq.QuoteIdentifier(name), pq.QuoteIdentifier(tenantRoleParent))); err != nil {
+		return fmt.Errorf("创建数据库角色 %s 失败: %w", name, err)
+	}
+	for _, table := range tenantRLSTables {
+		_, err := tx.ExecContext(ctx, fmt.Sprintf(`CREATE POLICY %s ON %s FOR SELECT TO %s USING (merchant_id = %d)`,
+			pq.QuoteIdentifier(name), table, pq.QuoteIdentifier(name), merchantID))
+		if err != nil {
+			return fmt.Errorf("创建 %s 的行级安全策略失败: %w", table, err)
+		}
+	}
+	*role = name
+	return nil
+}
+
+// tenantRoleName 商户的租户专属数据库角色名
+func tenantRoleName(merchantID int) string {
+	return fmt.Sprintf("%s_%d", tenantRoleParent, merchantID)
+}
+
+// tenantRoleCountQuery 商户的租户专属数据库角色是否存在（0 或 1），$1 为角色名；shared 隔离的商户没有该角色
+const tenantRoleCountQuery = `SELECT COUNT(*) FROM pg_roles WHERE rolname = $1`
+
+// dropTenantRole 删除 createTenantRole 创建的行级安全策略和数据库角色，返回删除的角色数；
+// 策略引用角色，先于角色删除。与 createTenantRole 一样随事务回滚
+func dropTenantRole(ctx context.Context, tx *sql.Tx, merchantID int) (int64, error) {
+	name := tenantRoleName(merchantID)
+	var count int64
+	if err := tx.QueryRowContext(ctx, tenantRoleCountQuery, name).Scan(&count); err != nil {
+		return 0, fmt.Errorf("查询数据库角色 %s 失败: %w", name, err)
+	}
+	if count == 0 {
+		return 0, nil
+	}
+	for _, table := range tenantRLSTables {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DROP POLICY IF EXISTS %s ON %s`, pq.QuoteIdentifier(name), table)); err != nil {
+			return 0, fmt.Errorf("删除 %s 的行级安全策略失败: %w", table, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DROP ROLE %s`, pq.QuoteIdentifier(name))); err != nil {
+		return 0, fmt.Errorf("删除数据库角色 %s 失败: %w", name, err)
+	}
+	return count, nil
+}
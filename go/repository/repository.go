@@ -0,0 +1,508 @@
+// Package repository 封装所有数据访问，服务层只依赖这里定义的接口，
+// 生产环境使用 PostgreSQL / ClickHouse 实现，测试中可替换为 testsupport 包中的内存实现。
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"timezone-saas-demo/models"
+)
+
+// MerchantRepository 商户仓储
+type MerchantRepository interface {
+	// List 获取所有商户，按名称排序
+	List() ([]models.Merchant, error)
+	// Get 按ID获取商户，不存在时返回 ErrNotFound
+	Get(id int) (*models.Merchant, error)
+	// Count 获取商户数量
+	Count() (int, error)
+}
+
+// OrderRepository 订单仓储
+type OrderRepository interface {
+	// List 分页获取订单分析数据，按时区和状态过滤，按 UTC 时间倒序
+	List(filter models.OrderFilter) ([]models.OrderAnalysis, error)
+	// Stream 与 List 的条件和顺序相同，但逐行回调 fn 而不返回切片，filter.Limit 为 0 时不限制条数
+	// fn 返回错误时停止扫描并原样返回该错误
+	Stream(ctx context.Context, filter models.OrderFilter, fn func(models.OrderAnalysis) error) error
+	// EstimateCount 估算符合 filter 条件（忽略 Limit/Offset）的订单数，用于分页元数据，不保证精确
+	EstimateCount(ctx context.Context, filter models.OrderFilter) (int64, error)
+	// Count 获取订单数量
+	Count() (int, error)
+}
+
+// AnalysisRepository 分析数据仓储
+// 可在 PostgreSQL 视图和 ClickHouse 镜像之间切换；查询在 ctx 取消或超时时中止
+type AnalysisRepository interface {
+	// Name 返回存储后端名称
+	Name() string
+	// OrderSummary 按币种分组获取指定本地日期的订单数和金额（GrossAmount），按币种代码排序
+	OrderSummary(ctx context.Context, filter models.AnalysisFilter) ([]models.CurrencyTotal, error)
+	// HourlyBreakdown 获取指定本地日期按本地小时分解的数据
+	HourlyBreakdown(ctx context.Context, filter models.AnalysisFilter) ([]models.HourlyOrderBreakdown, error)
+	// TimezoneStats 获取指定本地日期按时区的统计
+	TimezoneStats(ctx context.Context, filter models.AnalysisFilter) ([]models.TimezoneOrderStats, error)
+	// TopMerchants 获取指定本地日期销售额最高的商户
+	TopMerchants(ctx context.Context, filter models.AnalysisFilter, limit int) ([]models.MerchantOrderStats, error)
+	// WeeklyMerchantOrders 获取本地日期在 [filter.From, filter.To) 内各商户按本地周的订单数，没有订单的周不返回，按商户和周排序
+	WeeklyMerchantOrders(ctx context.Context, filter models.CohortFilter) ([]models.MerchantWeeklyOrders, error)
+	// UTCHourlyOrders 获取 UTC 时刻在 [filter.Start, filter.End) 内的订单按 UTC 整点、时区和是否营业时间分组的订单数，按小时和时区排序
+	UTCHourlyOrders(ctx context.Context, filter models.UTCHourFilter) ([]models.UTCHourTimezoneOrders, error)
+	// MerchantHourlyOrders 获取一个商户本地日期在 [filter.From, filter.To) 内按本地日期和小时的订单数，没有订单的小时不返回，按日期和小时排序
+	MerchantHourlyOrders(ctx context.Context, filter models.MerchantHourFilter) ([]models.LocalDateHourOrders, error)
+}
+
+// CombinedAnalysisRepository 支持一次查询返回全部分析聚合的分析仓储
+// 用于对延迟敏感的看板，把订单汇总、小时分解、时区统计和商户排行的多次往返合并为一次
+type CombinedAnalysisRepository interface {
+	AnalysisRepository
+	// Aggregates 获取指定本地日期的全部分析聚合，商户排行只返回前 limit 个，各部分排序与单项查询一致
+	Aggregates(ctx context.Context, filter models.AnalysisFilter, limit int) (*models.AnalysisAggregates, error)
+}
+
+// BatchAnalysisRepository 支持一次查询返回多个本地日期全部分析聚合的分析仓储，用于日历视图
+type BatchAnalysisRepository interface {
+	AnalysisRepository
+	// AggregatesByDate 获取 dates 中每个本地日期的全部分析聚合（filter.LocalDate 不使用），键为日期，没有订单的日期不返回；
+	// 每个日期的结果与 Aggregates 一致
+	AggregatesByDate(ctx context.Context, dates []string, filter models.AnalysisFilter, limit int) (map[string]*models.AnalysisAggregates, error)
+}
+
+// FulfillmentAnalysisRepository 能读取订单发货时间的分析仓储，ClickHouse 镜像不包含发货时间
+type FulfillmentAnalysisRepository interface {
+	AnalysisRepository
+	// MerchantFulfillmentDurations 获取一个商户本地日期在 [filter.From, filter.To) 内、有发货时间的订单的下单本地小时、星期和履约秒数，
+	// 发货时间早于下单时间的订单不返回，按下单时间排序
+	MerchantFulfillmentDurations(ctx context.Context, filter models.MerchantHourFilter) ([]models.OrderFulfillment, error)
+}
+
+// BillingRepository 计费仓储
+type BillingRepository interface {
+	// Subscription 获取商户订阅配置，未配置时返回 ErrNotFound
+	Subscription(merchantID int) (*models.Subscription, error)
+	// SavePeriods 保存计费周期，已存在的（商户+序号）记录保持不变
+	SavePeriods(periods []models.BillingPeriod) error
+	// ListPeriods 获取商户已保存的计费周期，按序号排序
+	ListPeriods(merchantID int) ([]models.BillingPeriod, error)
+}
+
+// SnapshotRepository 日结快照仓储
+type SnapshotRepository interface {
+	// LastClosedDate 获取商户最近一次结账的本地日期，从未结账时返回空字符串
+	LastClosedDate(merchantID int) (string, error)
+	// EarliestOrderTime 获取商户最早一笔订单的 UTC 时间，没有订单时返回 nil
+	EarliestOrderTime(merchantID int) (*time.Time, error)
+	// WindowTotals 统计商户在 [start, end) UTC 区间内的订单数和金额
+	WindowTotals(merchantID int, start, end time.Time) (int, decimal.Decimal, error)
+	// Create 写入快照，同一商户同一日期已存在时不做任何修改并返回 false
+	Create(snapshot models.DailyRevenueSnapshot) (bool, error)
+	// ListByDate 获取指定本地日期的所有快照（含调整汇总），按商户ID排序
+	ListByDate(date string) ([]models.DailyRevenueSnapshot, error)
+	// LateOrders 获取入库时间晚于所属快照结账时间的订单，按本地日期和下单时间排序
+	LateOrders(filter models.LateOrderFilter) ([]models.LateOrder, error)
+	// AddAdjustments 追加调整记录，已调整过的（快照+订单）会被跳过，返回实际写入的记录
+	AddAdjustments(adjustments []models.DailyRevenueAdjustment) ([]models.DailyRevenueAdjustment, error)
+}
+
+// OnboardingRepository 商户入驻仓储
+type OnboardingRepository interface {
+	// Onboard 在一个事务中创建商户并写入默认报表和 Webhook 配置，回填生成的ID
+	// 商户编码重复时返回 ErrConflict
+	Onboard(onboarding *models.MerchantOnboarding) error
+	// Import 在一个事务中创建多个商户及其默认配置，任一商户失败时全部回滚；商户编码重复时返回 ErrConflict
+	Import(ctx context.Context, onboardings []models.MerchantOnboarding) error
+	// Catalog 全部商户（包括停用的），按商户 ID 排序
+	Catalog(ctx context.Context) ([]models.MerchantRecord, error)
+}
+
+// ProvisioningRepository 租户开通仓储
+type ProvisioningRepository interface {
+	// Provision 在一个事务中写入商户、默认报表和 Webhook 配置、配置项、API 密钥（只保存摘要 keyHash）、
+	// 隔离策略、示例订单和开通记录，任一步失败时全部回滚；回填生成的ID、数据库角色和时间
+	// 商户编码重复时返回 ErrConflict
+	Provision(ctx context.Context, p *models.TenantProvisioning, keyHash string) error
+	// APIKeyMerchant 未吊销的 API 密钥所属的商户ID；密钥不存在或已吊销时返回 ErrNotFound
+	APIKeyMerchant(ctx context.Context, keyHash string) (int, error)
+	// RevokeAPIKey 吊销商户的 API 密钥，密钥不存在或已吊销时返回 ErrNotFound
+	RevokeAPIKey(ctx context.Context, merchantID, keyID int) error
+}
+
+// LifecycleRepository 租户生命周期（dim_merchant.lifecycle）及其变更记录
+type LifecycleRepository interface {
+	// Lifecycle 商户当前的生命周期状态，不含变更记录；商户不存在时返回 ErrNotFound
+	Lifecycle(ctx context.Context, merchantID int) (*models.TenantLifecycle, error)
+	// Transition 商户的状态仍为 event.From 时改为 event.To 并写入变更记录，回填记录ID和时间；
+	// 商户不存在时返回 ErrNotFound，状态已被其他请求修改时返回 ErrConflict
+	Transition(ctx context.Context, event *models.TenantLifecycleEvent) error
+	// Events 商户最近 limit 条变更记录，按时间倒序
+	Events(ctx context.Context, merchantID, limit int) ([]models.TenantLifecycleEvent, error)
+	// Trials 全部试用中的商户，按试用结束日期排序
+	Trials(ctx context.Context) ([]models.TenantLifecycle, error)
+}
+
+// PlanRegressionRepository 语句指纹各统计周期的耗时和执行计划
+type PlanRegressionRepository interface {
+	// StatsAnalyzedAt 各表最近一次 ANALYZE（含 autovacuum）的时间，从未 ANALYZE 时返回 nil
+	StatsAnalyzedAt(ctx context.Context) (*time.Time, error)
+	// Save 写入或更新本周期各指纹的统计，同时清除 planStatsRetention 之前的周期
+	Save(ctx context.Context, stats []models.QueryPlanStats) error
+	// Latest 每个指纹最近两个周期的统计，按指纹和周期开始时间倒序
+	Latest(ctx context.Context) ([]models.QueryPlanStats, error)
+}
+
+// CanaryRepository 合成监控租户
+type CanaryRepository interface {
+	// Tenant 合成监控租户和预期合计，没有写入合成监控数据时返回 ErrNotFound
+	Tenant(ctx context.Context) (*models.CanaryTenant, error)
+}
+
+// SimulationRepository 订单流模拟直接写库
+type SimulationRepository interface {
+	// InsertOrders 在一个语句中写入一批模拟订单，order_source 为 simulator
+	InsertOrders(ctx context.Context, orders []models.Order) error
+}
+
+// RefundRepository 订单退款仓储
+type RefundRepository interface {
+	// Order 获取订单及其退款记录（按退款时间排序），订单不存在时返回 ErrNotFound
+	Order(orderID int) (*models.OrderRefunds, error)
+	// Create 写入退款记录并回填ID和创建时间，订单累计退款达到订单金额时将订单状态置为 refunded
+	// 订单不存在时返回 ErrNotFound，累计退款超过订单金额时返回 ErrConflict
+	Create(refund *models.Refund) error
+	// Totals 按币种分组统计指定本地日期的退款，同时返回按原订单日期和按退款日期归属的金额，按币种代码排序
+	// 只统计 filter.Statuses 中状态的订单的退款
+	Totals(ctx context.Context, filter models.AnalysisFilter) ([]models.RefundTotal, error)
+	// TotalsByDate 一次统计 dates 中每个本地日期的退款（filter.LocalDate 不使用），键为日期，没有退款的日期不返回
+	TotalsByDate(ctx context.Context, dates []string, filter models.AnalysisFilter) (map[string][]models.RefundTotal, error)
+}
+
+// SettingsRepository 商户配置仓储（tenant_settings 表）
+type SettingsRepository interface {
+	// List 获取商户单独设置过的配置，按键排序
+	List(merchantID int) ([]models.TenantSetting, error)
+	// Save 写入配置并回填更新时间；calendar 不为 nil 时在同一事务中更新商户的营业时间和周末列
+	// 商户不存在时返回 ErrNotFound
+	Save(setting *models.TenantSetting, calendar *models.MerchantCalendar) error
+	// Delete 删除配置（恢复默认值），calendar 含义与 Save 相同；配置不存在时不报错
+	// 商户不存在时返回 ErrNotFound
+	Delete(merchantID int, key string, calendar *models.MerchantCalendar) error
+}
+
+// IndexStatsRepository 索引和查询统计仓储，读取 PostgreSQL 系统目录和统计视图
+type IndexStatsRepository interface {
+	// Indexes 获取指定表上的索引及其使用次数，按表名和索引名排序
+	Indexes(ctx context.Context, tables []string) ([]models.IndexInfo, error)
+	// TableStats 获取指定表的扫描统计，按表名排序
+	TableStats(ctx context.Context, tables []string) ([]models.TableScanStats, error)
+	// Statements 获取 pg_stat_statements 中涉及指定表、总耗时最高的 limit 条语句
+	// 扩展未安装或未加载时返回错误
+	Statements(ctx context.Context, tables []string, limit int) ([]models.StatementStats, error)
+}
+
+// OverviewRepository 跨租户运营看板的数据来源
+type OverviewRepository interface {
+	// TenantActivity 获取每个商户的订单数、since 之后入库的订单数和最近入库时间，按商户ID排序
+	TenantActivity(ctx context.Context, since time.Time) ([]models.TenantActivity, error)
+}
+
+// ConsistencyRepository 订单表与分析视图的一致性检查数据
+type ConsistencyRepository interface {
+	// MerchantTotals 按商户分别统计 dws_orders 和分析视图中的订单数和金额合计，任一侧有订单的商户都会返回，按商户ID排序
+	MerchantTotals(ctx context.Context) ([]models.ConsistencyTotals, error)
+	// SampleOrders 从分析视图中随机抽取最多 n 笔订单
+	SampleOrders(ctx context.Context, n int) ([]models.OrderAnalysis, error)
+	// SaveRun 在一个事务中写入检查记录及差异明细，回填 RunID 和差异ID
+	SaveRun(ctx context.Context, run *models.ConsistencyRun) error
+	// Runs 获取最近 limit 次检查，按开始时间倒序，不含差异明细
+	Runs(ctx context.Context, limit int) ([]models.ConsistencyRun, error)
+	// Run 获取一次检查及其差异明细，不存在时返回 ErrNotFound
+	Run(ctx context.Context, runID int64) (*models.ConsistencyRun, error)
+}
+
+// RollupRepository 订单小时汇总表（agg_orders_hourly）的维护
+type RollupRepository interface {
+	// Audit 比对本地日期 from~to（含两端）内汇总表与分析视图的每个小时桶，返回全部差异中的前 limit 条和差异总数
+	Audit(ctx context.Context, from, to string, limit int) (discrepancies []models.RollupDiscrepancy, total int, err error)
+	// Rebuild 从订单表重新计算商户的汇总，merchantID 为 0 时重建全部商户，返回写入的行数
+	Rebuild(ctx context.Context, merchantID int) (int64, error)
+}
+
+// RetentionRepository 订单保留策略和按月归档
+// 月份均为商户本地自然月，格式 YYYY-MM
+type RetentionRepository interface {
+	// Policies 全部保留策略，按商户排序
+	Policies(ctx context.Context) ([]models.RetentionPolicy, error)
+	// SavePolicy 新增或覆盖商户的保留策略，写回更新时间
+	SavePolicy(ctx context.Context, policy *models.RetentionPolicy) error
+	// DeletePolicy 删除商户的保留策略，不存在时返回 ErrNotFound
+	DeletePolicy(ctx context.Context, merchantID int) error
+	// PendingMonths 商户本地日期早于 before（YYYY-MM-DD）、可以归档的订单所在的月份，从早到晚
+	// 有退款或营收调整引用的订单不归档
+	PendingMonths(ctx context.Context, merchantID int, before string) ([]string, error)
+	// StreamMonth 逐行回调商户某个月份可以归档的订单，按 UTC 时间排序
+	StreamMonth(ctx context.Context, merchantID int, month string, fn func(models.OrderAnalysis) error) error
+	// ArchiveMonth 在一个语句中把商户某个月份可以归档的订单移出 dws_orders，汇总移入已归档汇总表，并记录归档明细
+	// orderIDs 不为 nil 时只归档其中的订单；bucket.Target 为 table 时订单写入冷表；写回 Orders 和 Amount
+	ArchiveMonth(ctx context.Context, runID int64, bucket *models.RetentionBucket, orderIDs []int) error
+	// CreateRun 写入一条执行中的归档记录，写回 RunID
+	CreateRun(ctx context.Context, run *models.RetentionRun) error
+	// FinishRun 更新归档记录的状态、统计和结束时间
+	FinishRun(ctx context.Context, run *models.RetentionRun) error
+	// Runs 最近 limit 次归档，不含明细
+	Runs(ctx context.Context, limit int) ([]models.RetentionRun, error)
+	// Run 一次归档及其明细，不存在时返回 ErrNotFound
+	Run(ctx context.Context, runID int64) (*models.RetentionRun, error)
+}
+
+// QueryConsoleRepository 只读 SQL 控制台的执行和审计
+type QueryConsoleRepository interface {
+	// Execute 在只读事务中以 role 角色执行单条查询，超过 timeout 时中止，最多读取 maxRows 行
+	// 语句本身的错误包装为 ErrInvalidQuery
+	Execute(ctx context.Context, role, query string, timeout time.Duration, maxRows int) (*models.ConsoleResult, error)
+	// CreateAudit 写入审计记录，写回 ID 和 ExecutedAt
+	CreateAudit(ctx context.Context, audit *models.ConsoleAudit) error
+	// FinishAudit 更新审计记录的状态、行数、耗时和错误
+	FinishAudit(ctx context.Context, audit *models.ConsoleAudit) error
+	// Audits 最近 limit 条审计记录，按执行时间倒序
+	Audits(ctx context.Context, limit int) ([]models.ConsoleAudit, error)
+}
+
+// OrganizationRepository 组织、组织的门店和组织令牌
+type OrganizationRepository interface {
+	// Create 在一个事务中创建组织并把 MerchantIDs 中的商户划入组织，写回 ID 和时间
+	// 名称重复或商户已属于其他组织时返回 ErrConflict，商户不存在时返回 ErrNotFound
+	Create(ctx context.Context, org *models.Organization) error
+	// List 全部组织及其门店ID，按组织ID排序
+	List(ctx context.Context) ([]models.Organization, error)
+	// Get 获取组织及其门店ID，不存在时返回 ErrNotFound
+	Get(ctx context.Context, id int) (*models.Organization, error)
+	// SetMerchants 替换组织的门店，移出的商户成为独立商户；错误与 Create 相同
+	SetMerchants(ctx context.Context, id int, merchantIDs []int) error
+	// CreateToken 保存令牌的摘要，写回 ID 和 CreatedAt；组织不存在时返回 ErrNotFound
+	CreateToken(ctx context.Context, token *models.OrganizationToken, hash string) error
+	// Tokens 组织的全部令牌（包括已吊销的），按ID排序，不含明文
+	Tokens(ctx context.Context, organizationID int) ([]models.OrganizationToken, error)
+	// RevokeToken 吊销令牌，令牌不存在或已吊销时返回 ErrNotFound
+	RevokeToken(ctx context.Context, organizationID, tokenID int) error
+	// TokenOrganization 未吊销的令牌所属的组织ID，同时记录使用时间；令牌不存在或已吊销时返回 ErrNotFound
+	TokenOrganization(ctx context.Context, hash string) (int, error)
+	// OrderTotals 按门店、小时和币种聚合组织的订单，按门店ID、小时和币种排序
+	OrderTotals(ctx context.Context, filter models.OrganizationAnalysisFilter) ([]models.OrganizationOrderTotal, error)
+}
+
+// ReportRepository 保存的报表定义和执行记录
+type ReportRepository interface {
+	// CreateDefinition 写入报表定义，写回ID和时间；名称重复时返回 ErrConflict
+	CreateDefinition(ctx context.Context, def *models.ReportDefinition) error
+	// UpdateDefinition 覆盖报表定义（含下一次执行时刻），写回更新时间；不存在时返回 ErrNotFound，名称重复时返回 ErrConflict
+	UpdateDefinition(ctx context.Context, def *models.ReportDefinition) error
+	// DeleteDefinition 删除报表定义及其执行记录，不存在时返回 ErrNotFound
+	DeleteDefinition(ctx context.Context, id int) error
+	// Definitions 全部报表定义，按ID排序
+	Definitions(ctx context.Context) ([]models.ReportDefinition, error)
+	// Definition 单个报表定义，不存在时返回 ErrNotFound
+	Definition(ctx context.Context, id int) (*models.ReportDefinition, error)
+	// DueDefinitions 下一次执行时刻不晚于 at 的报表定义，按执行时刻排序
+	DueDefinitions(ctx context.Context, at time.Time) ([]models.ReportDefinition, error)
+	// ClaimDue 下一次执行时刻仍为 due 时改为 next 并返回 true，已被其他实例领取时返回 false
+	ClaimDue(ctx context.Context, id int, due time.Time, next *time.Time) (bool, error)
+	// CreateRun 写入一条执行中的记录，写回 RunID
+	CreateRun(ctx context.Context, run *models.ReportRun) error
+	// FinishRun 更新执行记录的状态、结束时间和结果文件
+	FinishRun(ctx context.Context, run *models.ReportRun, artifact []byte) error
+	// Runs 报表最近 limit 次执行，不含结果文件
+	Runs(ctx context.Context, definitionID, limit int) ([]models.ReportRun, error)
+	// Artifact 一次执行及其结果文件，执行记录不存在或没有结果文件时返回 ErrNotFound
+	Artifact(ctx context.Context, runID int64) (*models.ReportRun, []byte, error)
+}
+
+// AlertRepository 指标告警规则、告警历史及规则检查使用的订单汇总
+type AlertRepository interface {
+	// CreateRule 写入告警规则，写回ID和时间；同一租户内名称重复时返回 ErrConflict，商户不存在时返回 ErrNotFound
+	CreateRule(ctx context.Context, rule *models.AlertRule) error
+	// UpdateRule 覆盖告警规则的配置，状态和检查时间不变；规则不存在或不属于 rule.Tenant 时返回 ErrNotFound
+	UpdateRule(ctx context.Context, rule *models.AlertRule) error
+	// DeleteRule 删除租户的告警规则及其告警历史，不存在时返回 ErrNotFound
+	DeleteRule(ctx context.Context, tenant string, id int) error
+	// Rules 租户的全部告警规则，按ID排序
+	Rules(ctx context.Context, tenant string) ([]models.AlertRule, error)
+	// Rule 租户的单个告警规则，不存在或属于其他租户时返回 ErrNotFound
+	Rule(ctx context.Context, tenant string, id int) (*models.AlertRule, error)
+	// EnabledRules 所有租户已启用的告警规则，按ID排序
+	EnabledRules(ctx context.Context) ([]models.AlertRule, error)
+	// TransitionState 规则状态仍为 from 时改为 to 并记录检查时间，返回 true；已被其他实例改变时返回 false
+	TransitionState(ctx context.Context, id int, from, to string, at time.Time) (bool, error)
+	// CreateEvent 写入告警历史，写回 EventID
+	CreateEvent(ctx context.Context, event *models.AlertEvent) error
+	// Events 租户最近 limit 条告警历史，ruleID 不为 0 时只返回该规则的记录，按时间倒序
+	Events(ctx context.Context, tenant string, ruleID, limit int) ([]models.AlertEvent, error)
+	// MerchantTotals 按币种汇总商户下单时间在 [start, end) 内的订单数和金额（GrossAmount），按币种排序
+	// statuses 为空时统计全部状态
+	MerchantTotals(ctx context.Context, merchantID int, start, end time.Time, statuses []string) ([]models.CurrencyTotal, error)
+}
+
+// DashboardRepository 首页概览的数据来源
+type DashboardRepository interface {
+	// Summary 在一次查询中统计商户今天、昨天同期、昨天全天的订单汇总和 [HourlyStart, Now) 内每小时的订单
+	Summary(ctx context.Context, filter models.DashboardFilter) (*models.DashboardAggregates, error)
+}
+
+// ChangeRepository 商户和订单的变更流水（change_log 表，由触发器写入）
+type ChangeRepository interface {
+	// Changes 按 seq 升序返回符合 filter 的变更，最多 filter.Limit 条
+	// 读取前先为已提交但尚未编号的变更分配 seq，保证客户端看到某个 seq 时更小的 seq 都已可见
+	Changes(ctx context.Context, filter models.ChangeFilter) ([]models.Change, error)
+}
+
+// SyncResolver 根据订单的当前状态决定离线修改的结果
+// current 为锁定后的订单，不存在时为 nil；返回的订单为 nil 时不写入，否则按 ID 是否为 0 新建或更新
+type SyncResolver func(current *models.Order) (*models.Order, models.SyncMutationResult)
+
+// SyncRepository 离线同步：幂等地应用设备推送的订单修改，记录设备的同步状态
+type SyncRepository interface {
+	// ApplyOrder 在一个事务中处理设备的一次修改：锁定订单（update 按 OrderID，insert 按 merchantID 下的 OrderNumber），
+	// 调用 resolve 决定写入内容，写入订单并保存处理结果；结果中的 Order 为写入后的订单
+	// 同一设备的同一 MutationID 已处理过时不调用 resolve，返回保存的结果（Replayed 为 true）
+	ApplyOrder(ctx context.Context, deviceID string, merchantID int, mutation models.SyncMutation, resolve SyncResolver) (*models.SyncMutationResult, error)
+	// SaveDevice 记录设备最近一次同步，已存在时覆盖
+	SaveDevice(ctx context.Context, device models.SyncDevice) error
+}
+
+// IngestResolver 根据商户下同一订单号的已有订单决定投递的写入内容
+// current 为锁定后的已有订单，不存在时为 nil；lastEventTime 为已处理的投递中该订单最晚的事件时刻（不限平台），没有时为 nil
+// 返回的订单按 ID 是否为 0 新建或更新（已有订单只更新金额、币种和状态）；返回冲突时不写入订单
+type IngestResolver func(current *models.Order, lastEventTime *time.Time) (*models.Order, *models.OrderConflict)
+
+// IngestRepository 外部平台的 Webhook 投递（ingest_delivery 表）及其订单写入
+type IngestRepository interface {
+	// SaveDelivery 保存收到的投递并回填 ID 和接收时间；同一平台的 ExternalID 已存在时不写入，
+	// 以已有记录覆盖 d 并返回 false
+	SaveDelivery(ctx context.Context, d *models.IngestDelivery) (bool, error)
+	// ApplyOrder 在一个事务中锁定 order.MerchantID 下的 order.OrderNumber，调用 resolve 后新建或更新订单，
+	// 把投递标记为 processed 并回填 OrderID；resolve 返回冲突时标记为 conflict 并保存冲突详情；
+	// 同一平台已处理过该订单事件时刻更晚的投递时不调用 resolve，标记为 skipped；
+	// 并发写入同一订单号违反唯一约束时返回 ErrConflict，重试时按订单已存在处理
+	ApplyOrder(ctx context.Context, d *models.IngestDelivery, order models.Order, resolve IngestResolver) error
+	// FinishDelivery 把投递标记为 d.Status（ignored 或 failed）并记录 d.Error，清除冲突详情，处理次数加一
+	FinishDelivery(ctx context.Context, d *models.IngestDelivery) error
+	// Delivery 按ID获取投递（包括载荷），不存在时返回 ErrNotFound
+	Delivery(ctx context.Context, id int64) (*models.IngestDelivery, error)
+	// Deliveries 按接收时间倒序返回符合条件的投递，不含载荷
+	Deliveries(ctx context.Context, filter models.IngestDeliveryFilter) ([]models.IngestDelivery, error)
+	// ProviderEvents 已写入订单（processed 或 skipped）的投递中事件时刻在 [From, To) 内的事件，按事件时刻排序，
+	// 最多 filter.Limit 个；MerchantID 为 0 时不限商户
+	ProviderEvents(ctx context.Context, filter models.ProviderEventFilter) ([]models.ProviderEvent, error)
+	// OrdersByNumber 按订单号从分析视图读取订单（含本地日期），merchantID 不为 0 时只返回该商户的订单，不存在的订单号不返回
+	OrdersByNumber(ctx context.Context, merchantID int, orderNumbers []string) ([]models.OrderAnalysis, error)
+}
+
+// EmailTemplateRepository 租户覆盖的邮件模板，按（租户，模板名称，语言）唯一
+type EmailTemplateRepository interface {
+	// Templates 租户覆盖的全部模板，按名称和语言排序
+	Templates(ctx context.Context, tenant string) ([]models.EmailTemplate, error)
+	// Template 租户覆盖的单个模板，没有覆盖时返回 ErrNotFound
+	Template(ctx context.Context, tenant, name, locale string) (*models.EmailTemplate, error)
+	// SaveTemplate 写入或覆盖租户的模板，写回更新时间
+	SaveTemplate(ctx context.Context, t *models.EmailTemplate) error
+	// DeleteTemplate 删除租户覆盖的模板，恢复使用内置模板；没有覆盖时返回 ErrNotFound
+	DeleteTemplate(ctx context.Context, tenant, name, locale string) error
+}
+
+// TenantDataRepository 跨表导出、删除和匿名化一个租户（商户）的全部数据，及处理记录（tenant_erasure 表）
+type TenantDataRepository interface {
+	// Export 在一个一致的快照中逐表导出商户的数据：每张表先调用 section，再为每行调用 row（行的 JSON）
+	// 回调返回错误时停止并原样返回该错误
+	Export(ctx context.Context, merchantID int, section func(name string) error, row func(json.RawMessage) error) error
+	// Preview 统计 mode 方式下每张表会删除或匿名化的行数，不修改数据
+	Preview(ctx context.Context, merchantID int, mode string) ([]models.TenantErasureStep, error)
+	// Erase 在一个事务中按 mode 删除或匿名化商户的数据，提交前核验每张表的残留行数，有残留时回滚并返回 ErrConflict；
+	// beforeCommit 不为 nil 时在核验通过后、提交前调用，返回错误时回滚。出错时同样返回已得到的处理结果
+	Erase(ctx context.Context, merchantID int, mode string, beforeCommit func() error) ([]models.TenantErasureStep, error)
+	// CreateErasure 写入一条执行中的处理记录，写回 ID
+	CreateErasure(ctx context.Context, e *models.TenantErasure) error
+	// FinishErasure 更新处理记录的状态、核验结果和结束时间
+	FinishErasure(ctx context.Context, e *models.TenantErasure) error
+	// Erasures 最近 limit 条处理记录，merchantID 不为 0 时只返回该商户的记录，按开始时间倒序
+	Erasures(ctx context.Context, merchantID, limit int) ([]models.TenantErasure, error)
+	// Erasure 一条处理记录，不存在时返回 ErrNotFound
+	Erasure(ctx context.Context, id int64) (*models.TenantErasure, error)
+}
+
+// AttributeRepository 租户自定义属性的定义、商户和订单的取值及按取值分组的统计
+type AttributeRepository interface {
+	// Definitions 租户的全部属性定义，按对象和键排序
+	Definitions(ctx context.Context, tenant string) ([]models.AttributeDefinition, error)
+	// SaveDefinition 新增或覆盖属性定义，写回创建人和时间
+	SaveDefinition(ctx context.Context, definition *models.AttributeDefinition) error
+	// DeleteDefinition 删除属性定义，同时从该租户所有商户或订单的取值中删除该键；不存在时返回 ErrNotFound
+	DeleteDefinition(ctx context.Context, tenant, entity, key string) error
+	// Attributes 租户为商户或订单设置的取值，未设置时 Attributes 为空；订单同时返回有效取值
+	// 商户或订单不存在时返回 ErrNotFound
+	Attributes(ctx context.Context, tenant, entity string, id int) (*models.EntityAttributes, error)
+	// SaveAttributes 合并 set 中的取值并删除 remove 中的键，返回修改后的取值；商户或订单不存在时返回 ErrNotFound
+	SaveAttributes(ctx context.Context, tenant, entity string, id int, set map[string]json.RawMessage, remove []string, operator string) (*models.EntityAttributes, error)
+	// GroupTotals 按订单有效取值中 key 的取值和币种分组统计 filter 对应日期的订单数和金额，按取值（未设置的在最后）和币种排序
+	GroupTotals(ctx context.Context, tenant, key string, filter models.AnalysisFilter) ([]models.AttributeGroupTotal, error)
+}
+
+// SavedViewRepository 看板用户保存的筛选视图
+type SavedViewRepository interface {
+	// CreateView 写入视图，写回ID和时间；同一用户的视图名称重复时返回 ErrConflict
+	CreateView(ctx context.Context, view *models.SavedView) error
+	// UpdateView 覆盖视图的名称和筛选条件，owner 不变；不存在或不属于 view.Tenant 时返回 ErrNotFound
+	UpdateView(ctx context.Context, view *models.SavedView) error
+	// DeleteView 删除租户的视图，不存在时返回 ErrNotFound
+	DeleteView(ctx context.Context, tenant string, id int) error
+	// View 租户的一个视图，不存在或属于其他租户时返回 ErrNotFound
+	View(ctx context.Context, tenant string, id int) (*models.SavedView, error)
+	// Views 租户的视图，owner 不为空时只返回该用户的视图，按ID排序
+	Views(ctx context.Context, tenant, owner string) ([]models.SavedView, error)
+}
+
+// JobRepository 后台任务和定时计划
+type JobRepository interface {
+	// EnqueueJob 写入排队中的任务，写回ID和时间；DedupeKey 已有排队或执行中的任务时返回 ErrConflict
+	EnqueueJob(ctx context.Context, job *models.Job) error
+	// ClaimJob 领取队列中 run_at 不晚于 now 的最早一个任务，状态改为 running 并加一执行次数；没有可领取的任务时返回 nil
+	// 多个实例同时领取时每个任务只会被一个实例领取
+	ClaimJob(ctx context.Context, queue, worker string, now time.Time) (*models.Job, error)
+	// FinishJob 按 job 更新状态、执行次数、下一次执行时刻、错误、结果和结束时间，并释放锁；
+	// 任务已不是 worker 执行中的任务（已取消或被回收）时不修改并返回 false
+	FinishJob(ctx context.Context, job *models.Job, worker string) (bool, error)
+	// HeartbeatJobs 刷新 worker 执行中任务的心跳时间，返回仍由 worker 执行的任务ID，其余任务已被取消或回收
+	HeartbeatJobs(ctx context.Context, worker string, ids []int64, now time.Time) ([]int64, error)
+	// RequeueStaleJobs 心跳早于 before 的执行中任务重新排队，已达最大执行次数的改为 failed，返回处理的任务数
+	RequeueStaleJobs(ctx context.Context, before, now time.Time, reason string) (int64, error)
+	// Jobs 按条件筛选的任务，按ID倒序
+	Jobs(ctx context.Context, filter models.JobFilter) ([]models.Job, error)
+	// Job 单个任务，不存在时返回 ErrNotFound
+	Job(ctx context.Context, id int64) (*models.Job, error)
+	// RetryJob 失败或已取消的任务重新排队并清零执行次数；不存在时返回 ErrNotFound，其他状态或去重键冲突时返回 ErrConflict
+	RetryJob(ctx context.Context, id int64, now time.Time) (*models.Job, error)
+	// CancelJob 取消排队或执行中的任务；不存在时返回 ErrNotFound，已结束时返回 ErrConflict
+	CancelJob(ctx context.Context, id int64, now time.Time) (*models.Job, error)
+	// SaveSchedule 新增或覆盖定时计划，写回时间
+	SaveSchedule(ctx context.Context, schedule *models.JobSchedule) error
+	// DeleteSchedule 删除定时计划，已生成的任务不受影响；不存在时返回 ErrNotFound
+	DeleteSchedule(ctx context.Context, name string) error
+	// Schedules 全部定时计划，按名称排序
+	Schedules(ctx context.Context) ([]models.JobSchedule, error)
+	// DueSchedules 启用且下一次执行时刻不晚于 at 的定时计划，按执行时刻排序
+	DueSchedules(ctx context.Context, at time.Time) ([]models.JobSchedule, error)
+	// ClaimSchedule 下一次执行时刻仍为 due 时改为 next 并返回 true，已被其他实例领取时返回 false
+	ClaimSchedule(ctx context.Context, name string, due time.Time, next *time.Time) (bool, error)
+}
+
+// LeaderLock 多个实例之间的排他锁，持有锁的实例为主实例
+type LeaderLock interface {
+	// TryAcquire 尝试获取锁，已被其他实例持有时立即返回 false
+	TryAcquire(ctx context.Context) (bool, error)
+	// Renew 确认本实例仍持有锁，连接断开或锁已释放时返回 false
+	Renew(ctx context.Context) (bool, error)
+	// Release 释放锁，未持有时不做任何事
+	Release(ctx context.Context) error
+}
@@ -0,0 +1,235 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MerchantLocal CRON_TZ 取该值时按商户的时区执行，由调用方解析为具体时区
+const MerchantLocal = "@merchant_local"
+
+// maxCronSearchDays 查找下一次发生时最多向后搜索的天数，覆盖 2 月 29 日等每四年一次的表达式
+const maxCronSearchDays = 366*8 + 2
+
+// cronMacros 预定义的表达式
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	cronMonthNames = map[string]int{
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+		"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	}
+	cronWeekdayNames = map[string]int{"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6}
+)
+
+// Cron 标准 cron 表达式（分 时 日 月 周），字段均为本地时间
+// 支持 *、列表、范围、步长、月份和星期的英文缩写、星期 7 表示周日以及 @daily 等预定义表达式；
+// 日和周都不是 * 时任一匹配即可（与 Vixie cron 一致）
+type Cron struct {
+	// Timezone CRON_TZ= 指定的时区，为空时由调用方决定，MerchantLocal 表示商户时区
+	Timezone string
+	fields   string
+
+	minute, hour, dom, month, dow uint64
+	// domStar、dowStar 日、周字段以 * 开头，此时两者需同时匹配
+	domStar, dowStar bool
+}
+
+// IsCron 规则是否为 cron 表达式：以 CRON_TZ=、TZ=、@ 开头或不含 =；类 RRULE 规则总是包含 FREQ=
+func IsCron(value string) bool {
+	value = strings.TrimSpace(value)
+	upper := strings.ToUpper(value)
+	return strings.HasPrefix(upper, "CRON_TZ=") || strings.HasPrefix(upper, "TZ=") ||
+		strings.HasPrefix(value, "@") || !strings.Contains(value, "=")
+}
+
+// ParseCron 解析 cron 表达式，如 CRON_TZ=Asia/Shanghai 0 9 * * 1-5
+// 时区前缀可写作 CRON_TZ= 或 TZ=，CRON_TZ=@merchant_local 也可简写为 @merchant_local
+func ParseCron(value string) (*Cron, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, fmt.Errorf("cron 表达式为空")
+	}
+	c := &Cron{}
+	fields := strings.Fields(value)
+	if strings.EqualFold(fields[0], MerchantLocal) {
+		c.Timezone, fields = MerchantLocal, fields[1:]
+	} else if key, zone, ok := strings.Cut(fields[0], "="); ok {
+		if !strings.EqualFold(key, "CRON_TZ") && !strings.EqualFold(key, "TZ") {
+			return nil, fmt.Errorf("cron 表达式只支持 CRON_TZ= 前缀，得到 %q", fields[0])
+		}
+		if zone == "" {
+			return nil, fmt.Errorf("CRON_TZ 缺少时区")
+		}
+		c.Timezone, fields = zone, fields[1:]
+		if strings.EqualFold(zone, MerchantLocal) {
+			c.Timezone = MerchantLocal
+		}
+	}
+
+	if len(fields) == 1 && strings.HasPrefix(fields[0], "@") {
+		macro := strings.ToLower(fields[0])
+		expanded, ok := cronMacros[macro]
+		if !ok {
+			return nil, fmt.Errorf("不支持的预定义表达式 %q，可选 @yearly、@monthly、@weekly、@daily、@hourly", fields[0])
+		}
+		c.fields, fields = macro, strings.Fields(expanded)
+	}
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron 表达式应为 5 个字段（分 时 日 月 周），得到 %d 个", len(fields))
+	}
+	if c.fields == "" {
+		c.fields = strings.ToUpper(strings.Join(fields, " "))
+	}
+
+	var err error
+	if c.minute, err = parseCronField("分", fields[0], 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if c.hour, err = parseCronField("时", fields[1], 0, 23, nil); err != nil {
+		return nil, err
+	}
+	if c.dom, err = parseCronField("日", fields[2], 1, 31, nil); err != nil {
+		return nil, err
+	}
+	if c.month, err = parseCronField("月", fields[3], 1, 12, cronMonthNames); err != nil {
+		return nil, err
+	}
+	if c.dow, err = parseCronField("周", fields[4], 0, 7, cronWeekdayNames); err != nil {
+		return nil, err
+	}
+	// 7 与 0 都表示周日
+	if c.dow&(1<<7) != 0 {
+		c.dow = c.dow&^(1<<7) | 1
+	}
+	c.domStar = strings.HasPrefix(fields[2], "*")
+	c.dowStar = strings.HasPrefix(fields[4], "*")
+	return c, nil
+}
+
+// parseCronField 解析一个字段，返回取值的位图
+func parseCronField(name, value string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(value, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n < 1 || n > max {
+				return 0, fmt.Errorf("%s字段的步长无效: %q", name, item)
+			}
+			step = n
+		}
+
+		lo, hi := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			a, b, _ := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = parseCronValue(a, min, max, names); err != nil {
+				return 0, fmt.Errorf("%s字段%v", name, err)
+			}
+			if hi, err = parseCronValue(b, min, max, names); err != nil {
+				return 0, fmt.Errorf("%s字段%v", name, err)
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("%s字段的范围无效: %q", name, item)
+			}
+		default:
+			v, err := parseCronValue(rangePart, min, max, names)
+			if err != nil {
+				return 0, fmt.Errorf("%s字段%v", name, err)
+			}
+			// 5/15 表示从 5 开始每 15 个取一次
+			lo = v
+			if !hasStep {
+				hi = v
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// parseCronValue 解析数字或名称
+func parseCronValue(value string, min, max int, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToUpper(value)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("的取值 %q 无效，应为 %d-%d", value, min, max)
+	}
+	return v, nil
+}
+
+// String 规范化的表达式，带时区前缀时为 CRON_TZ=<时区> <字段>
+func (c *Cron) String() string {
+	if c.Timezone != "" {
+		return "CRON_TZ=" + c.Timezone + " " + c.fields
+	}
+	return c.fields
+}
+
+// Next loc 时区下 after 之后的第一次发生，本地时间不存在或出现两次时按 opts 处理；
+// maxCronSearchDays 天内没有发生（如 2 月 30 日）时返回 false
+func (c *Cron) Next(loc *time.Location, after time.Time, opts Options) (Occurrence, bool) {
+	if opts.Gap == "" {
+		opts.Gap = GapShift
+	}
+	if opts.Overlap == "" {
+		opts.Overlap = OverlapEarlier
+	}
+	// 从前一天开始，夏令时顺延后的时刻可能落在 after 所在的本地日期
+	local := after.In(loc)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+	for i := 0; i < maxCronSearchDays; i++ {
+		date := day.AddDate(0, 0, i)
+		if !c.matchesDate(date) {
+			continue
+		}
+		for hour := 0; hour < 24; hour++ {
+			if c.hour&(1<<uint(hour)) == 0 {
+				continue
+			}
+			for minute := 0; minute < 60; minute++ {
+				if c.minute&(1<<uint(minute)) == 0 {
+					continue
+				}
+				wall := time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, time.UTC)
+				for _, occ := range resolve(wall, loc, opts) {
+					if occ.Time.After(after) {
+						return occ, true
+					}
+				}
+			}
+		}
+	}
+	return Occurrence{}, false
+}
+
+// matchesDate 日期（以 UTC 字段表示）是否匹配月、日、周字段
+func (c *Cron) matchesDate(date time.Time) bool {
+	if c.month&(1<<uint(date.Month())) == 0 {
+		return false
+	}
+	dom := c.dom&(1<<uint(date.Day())) != 0
+	dow := c.dow&(1<<uint(date.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
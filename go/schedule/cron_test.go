@@ -0,0 +1,170 @@
+package schedule_test
+
+import (
+	"testing"
+	"time"
+
+	"timezone-saas-demo/schedule"
+)
+
+// TestParseCron 时区前缀、预定义表达式、名称和各字段的取值范围
+func TestParseCron(t *testing.T) {
+	cases := []struct {
+		value    string
+		timezone string
+		str      string
+	}{
+		{"0 9 * * 1-5", "", "0 9 * * 1-5"},
+		{"  0 9 * * mon-fri  ", "", "0 9 * * MON-FRI"},
+		{"CRON_TZ=Asia/Shanghai 0 9 * * 1-5", "Asia/Shanghai", "CRON_TZ=Asia/Shanghai 0 9 * * 1-5"},
+		{"TZ=Asia/Tokyo 0 9 * * *", "Asia/Tokyo", "CRON_TZ=Asia/Tokyo 0 9 * * *"},
+		{"tz=Europe/Berlin 0 9 * * *", "Europe/Berlin", "CRON_TZ=Europe/Berlin 0 9 * * *"},
+		{"CRON_TZ=@merchant_local 0 9 * * *", schedule.MerchantLocal, "CRON_TZ=@merchant_local 0 9 * * *"},
+		{"TZ=@MERCHANT_LOCAL 0 9 * * *", schedule.MerchantLocal, "CRON_TZ=@merchant_local 0 9 * * *"},
+		{"@merchant_local 0 9 * * *", schedule.MerchantLocal, "CRON_TZ=@merchant_local 0 9 * * *"},
+		{"@daily", "", "@daily"},
+		{"TZ=UTC @Weekly", "UTC", "CRON_TZ=UTC @weekly"},
+		{"0,30 8-18/2 1,15 jan,jul 0,7", "", "0,30 8-18/2 1,15 JAN,JUL 0,7"},
+		{"59 23 31 12 7", "", "59 23 31 12 7"},
+	}
+	for _, c := range cases {
+		t.Run(c.value, func(t *testing.T) {
+			if !schedule.IsCron(c.value) {
+				t.Errorf("IsCron(%q) = false", c.value)
+			}
+			cron, err := schedule.ParseCron(c.value)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) 失败: %v", c.value, err)
+			}
+			if cron.Timezone != c.timezone || cron.String() != c.str {
+				t.Errorf("ParseCron(%q) = %q（时区 %q）, 期望 %q（时区 %q）", c.value, cron.String(), cron.Timezone, c.str, c.timezone)
+			}
+		})
+	}
+
+	invalid := []string{
+		"",
+		"0 9 * *",
+		"0 9 * * * *",
+		"60 * * * *",
+		"-1 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"* * * FOO *",
+		"* * * * MON-XYZ",
+		"5-1 * * * *",
+		"1-2-3 * * * *",
+		"*/0 * * * *",
+		"*/60 * * * *",
+		"*/x * * * *",
+		"0 9 * * SUN/0",
+		"FOO=bar 0 9 * * *",
+		"CRON_TZ= 0 9 * * *",
+		"@fortnightly",
+		"@daily 0",
+	}
+	for _, value := range invalid {
+		if cron, err := schedule.ParseCron(value); err == nil {
+			t.Errorf("ParseCron(%q) 应返回错误, 得到 %q", value, cron)
+		}
+	}
+
+	for value, want := range map[string]bool{
+		"FREQ=DAILY;BYHOUR=9": false,
+		"0 9 * * *":           true,
+		"@hourly":             true,
+		"TZ=UTC 0 9 * * *":    true,
+		"cron_tz=UTC @daily":  true,
+	} {
+		if got := schedule.IsCron(value); got != want {
+			t.Errorf("IsCron(%q) = %v, 期望 %v", value, got, want)
+		}
+	}
+}
+
+// TestCronNext 日和周字段的“或”语义、星期 7、名称、步长和范围，以及夏令时跳过和重复的本地时间
+func TestCronNext(t *testing.T) {
+	load := func(name string) *time.Location {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			t.Fatalf("加载时区 %s 失败: %v", name, err)
+		}
+		return loc
+	}
+	berlin, santiago := load("Europe/Berlin"), load("America/Santiago")
+	utc := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	cases := []struct {
+		name      string
+		expr      string
+		loc       *time.Location
+		opts      schedule.Options
+		after     time.Time
+		want      time.Time
+		shifted   bool
+		ambiguous bool
+	}{
+		// 2024-09-01 为周日，2024-09-06 为周五，2024-09-13 既是 13 号又是周五
+		{"日和周都指定时任一匹配", "0 0 13 * 5", time.UTC, schedule.Options{}, utc(2024, 9, 1, 0, 0), utc(2024, 9, 6, 0, 0), false, false},
+		{"日和周都指定时 13 号", "0 0 13 * 5", time.UTC, schedule.Options{}, utc(2024, 9, 6, 0, 0), utc(2024, 9, 13, 0, 0), false, false},
+		{"周为 * 时只看日", "0 0 13 * *", time.UTC, schedule.Options{}, utc(2024, 9, 1, 0, 0), utc(2024, 9, 13, 0, 0), false, false},
+		{"日为 * 时只看周", "0 0 * * 5", time.UTC, schedule.Options{}, utc(2024, 9, 1, 0, 0), utc(2024, 9, 6, 0, 0), false, false},
+		{"日以 * 开头的步长需同时匹配", "0 0 */2 * 5", time.UTC, schedule.Options{}, utc(2024, 9, 1, 0, 0), utc(2024, 9, 13, 0, 0), false, false},
+		{"1 号或周日", "0 0 1 * 7", time.UTC, schedule.Options{}, utc(2024, 9, 2, 0, 0), utc(2024, 9, 8, 0, 0), false, false},
+		{"星期 7 为周日", "30 9 * * 7", time.UTC, schedule.Options{}, utc(2024, 9, 2, 0, 0), utc(2024, 9, 8, 9, 30), false, false},
+		{"星期 0 为周日", "30 9 * * 0", time.UTC, schedule.Options{}, utc(2024, 9, 2, 0, 0), utc(2024, 9, 8, 9, 30), false, false},
+		{"星期名称", "30 9 * * sun", time.UTC, schedule.Options{}, utc(2024, 9, 2, 0, 0), utc(2024, 9, 8, 9, 30), false, false},
+		{"星期范围跨到 7", "0 8 * * 5-7", time.UTC, schedule.Options{}, utc(2024, 9, 2, 0, 0), utc(2024, 9, 6, 8, 0), false, false},
+		{"月份和星期名称范围", "0 12 * JAN-MAR MON-FRI", time.UTC, schedule.Options{}, utc(2024, 3, 29, 12, 0), utc(2025, 1, 1, 12, 0), false, false},
+		{"步长", "*/15 * * * *", time.UTC, schedule.Options{}, utc(2024, 9, 2, 10, 7), utc(2024, 9, 2, 10, 15), false, false},
+		{"起点加步长", "5/20 * * * *", time.UTC, schedule.Options{}, utc(2024, 9, 2, 10, 26), utc(2024, 9, 2, 10, 45), false, false},
+		{"范围加步长", "10-30/10 * * * *", time.UTC, schedule.Options{}, utc(2024, 9, 2, 10, 31), utc(2024, 9, 2, 11, 10), false, false},
+		{"范围上界", "0 22-23 * * *", time.UTC, schedule.Options{}, utc(2024, 9, 2, 23, 0), utc(2024, 9, 3, 22, 0), false, false},
+		{"31 号跳过短月", "0 0 31 * *", time.UTC, schedule.Options{}, utc(2024, 8, 31, 0, 0), utc(2024, 10, 31, 0, 0), false, false},
+		{"2 月 29 日", "0 0 29 2 *", time.UTC, schedule.Options{}, utc(2024, 3, 1, 0, 0), utc(2028, 2, 29, 0, 0), false, false},
+		{"预定义表达式", "@monthly", time.UTC, schedule.Options{}, utc(2024, 9, 2, 0, 0), utc(2024, 10, 1, 0, 0), false, false},
+		{"按时区的本地时间", "TZ=Europe/Berlin 0 9 * * *", berlin, schedule.Options{}, utc(2024, 9, 2, 7, 0), utc(2024, 9, 3, 7, 0), false, false},
+
+		// 柏林 2024-03-31 02:00 跳到 03:00，02:30 不存在
+		{"跳过的时刻顺延", "30 2 * * *", berlin, schedule.Options{}, utc(2024, 3, 30, 12, 0), utc(2024, 3, 31, 1, 30), true, false},
+		{"跳过的时刻按 skip 跳过", "30 2 * * *", berlin, schedule.Options{Gap: schedule.GapSkip}, utc(2024, 3, 30, 12, 0), utc(2024, 4, 1, 0, 30), false, false},
+		// 圣地亚哥 2024-09-08 零点不存在，顺延到 01:00 -03
+		{"零点不存在", "0 0 * * *", santiago, schedule.Options{}, utc(2024, 9, 7, 12, 0), utc(2024, 9, 8, 4, 0), true, false},
+		// 柏林 2024-10-27 03:00 回拨到 02:00，02:30 出现两次（00:30Z、01:30Z）
+		{"重复的时刻取前一次", "30 2 * * *", berlin, schedule.Options{}, utc(2024, 10, 26, 12, 0), utc(2024, 10, 27, 0, 30), false, true},
+		{"重复的时刻只执行一次", "30 2 * * *", berlin, schedule.Options{}, utc(2024, 10, 27, 0, 30), utc(2024, 10, 28, 1, 30), false, false},
+		{"重复的时刻取后一次", "30 2 * * *", berlin, schedule.Options{Overlap: schedule.OverlapLater}, utc(2024, 10, 26, 12, 0), utc(2024, 10, 27, 1, 30), false, true},
+		{"重复的时刻两次都执行", "30 2 * * *", berlin, schedule.Options{Overlap: schedule.OverlapBoth}, utc(2024, 10, 27, 0, 30), utc(2024, 10, 27, 1, 30), false, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cron, err := schedule.ParseCron(c.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) 失败: %v", c.expr, err)
+			}
+			occ, ok := cron.Next(c.loc, c.after, c.opts)
+			if !ok {
+				t.Fatalf("%q 在 %s 之后没有发生", c.expr, c.after)
+			}
+			if !occ.Time.Equal(c.want) || occ.Shifted != c.shifted || occ.Ambiguous != c.ambiguous {
+				t.Errorf("%q 在 %s 之后 = %s（顺延 %v，重复 %v）, 期望 %s（顺延 %v，重复 %v）",
+					c.expr, c.after, occ.Time.UTC(), occ.Shifted, occ.Ambiguous, c.want, c.shifted, c.ambiguous)
+			}
+		})
+	}
+
+	// 2 月 30 日永远不会发生
+	cron, err := schedule.ParseCron("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("ParseCron 失败: %v", err)
+	}
+	if occ, ok := cron.Next(time.UTC, utc(2024, 1, 1, 0, 0), schedule.Options{}); ok {
+		t.Errorf("2 月 30 日不应有发生, 得到 %s", occ.Time)
+	}
+}
@@ -0,0 +1,270 @@
+package schedule
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// GapPolicy 本地时间落在夏令时跳过区间（不存在的时刻）时的处理方式
+type GapPolicy string
+
+const (
+	// GapShift 按跳过的时长顺延（与 RFC 5545 一致），如柏林 02:30 变为 03:30
+	GapShift GapPolicy = "shift"
+	// GapSkip 跳过该次发生
+	GapSkip GapPolicy = "skip"
+)
+
+// OverlapPolicy 本地时间落在夏令时回拨区间（出现两次的时刻）时的处理方式
+type OverlapPolicy string
+
+const (
+	// OverlapEarlier 取第一次出现（夏令时）
+	OverlapEarlier OverlapPolicy = "earlier"
+	// OverlapLater 取第二次出现（标准时间）
+	OverlapLater OverlapPolicy = "later"
+	// OverlapBoth 两次都返回
+	OverlapBoth OverlapPolicy = "both"
+)
+
+// ParseGapPolicy 解析夏令时跳过策略，空字符串为 shift
+func ParseGapPolicy(value string) (GapPolicy, error) {
+	switch GapPolicy(value) {
+	case "", GapShift:
+		return GapShift, nil
+	case GapSkip:
+		return GapSkip, nil
+	}
+	return "", fmt.Errorf("不支持的 gap 策略 %q，可选 shift、skip", value)
+}
+
+// ParseOverlapPolicy 解析夏令时重复策略，空字符串为 earlier
+func ParseOverlapPolicy(value string) (OverlapPolicy, error) {
+	switch OverlapPolicy(value) {
+	case "", OverlapEarlier:
+		return OverlapEarlier, nil
+	case OverlapLater, OverlapBoth:
+		return OverlapPolicy(value), nil
+	}
+	return "", fmt.Errorf("不支持的 overlap 策略 %q，可选 earlier、later、both", value)
+}
+
+// Options 展开参数
+type Options struct {
+	Gap     GapPolicy
+	Overlap OverlapPolicy
+	// Limit 最多返回的发生次数，超出时结果被截断
+	Limit int
+}
+
+// Occurrence 一次发生
+type Occurrence struct {
+	// Time 实际时刻（位于规则的时区）
+	Time time.Time
+	// Wall 规则要求的本地时间，Shifted 时与 Time 的本地时间不同
+	Wall string
+	// Shifted 本地时间不存在，已按跳过的时长顺延
+	Shifted bool
+	// Ambiguous 本地时间出现两次
+	Ambiguous bool
+}
+
+// Expand 在 loc 时区展开规则，返回 [from, to) 内的所有发生时刻
+// 规则没有 DTSTART，以 from 所在的本地日期作为起点：INTERVAL 从该日（周、月）开始计数，
+// COUNT 从该日起计数，未指定 BYDAY/BYMONTHDAY 时取该日的星期几或日期
+func Expand(rule *Rule, loc *time.Location, from, to time.Time, opts Options) ([]Occurrence, bool, error) {
+	if !from.Before(to) {
+		return nil, false, fmt.Errorf("结束时间必须晚于开始时间")
+	}
+	if opts.Gap == "" {
+		opts.Gap = GapShift
+	}
+	if opts.Overlap == "" {
+		opts.Overlap = OverlapEarlier
+	}
+
+	// 日期运算统一在 UTC 下进行，避免本地夏令时切换影响加减天数
+	local := from.In(loc)
+	anchor := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+
+	var occurrences []Occurrence
+	truncated := false
+	emitted := 0
+periods:
+	for period := 0; ; period++ {
+		start, dates := rule.periodDates(anchor, period)
+		if !wallToInstant(start, loc).Before(to) {
+			break
+		}
+
+		for _, date := range dates {
+			if date.Before(anchor) {
+				continue
+			}
+			for _, hour := range rule.ByHour {
+				for _, minute := range rule.ByMinute {
+					wall := time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, time.UTC)
+					matched := false
+					for _, occ := range resolve(wall, loc, opts) {
+						if occ.Time.Before(from) || !occ.Time.Before(to) {
+							continue
+						}
+						if opts.Limit > 0 && len(occurrences) >= opts.Limit {
+							truncated = true
+							break periods
+						}
+						occurrences = append(occurrences, occ)
+						matched = true
+					}
+					// OverlapBoth 时同一本地时间的两次发生只计一次
+					if matched {
+						emitted++
+					}
+					if rule.Count > 0 && emitted >= rule.Count {
+						break periods
+					}
+				}
+			}
+		}
+	}
+
+	// 重复时段内的两次发生可能与相邻分钟交错，按实际时刻排序
+	sort.SliceStable(occurrences, func(i, j int) bool { return occurrences[i].Time.Before(occurrences[j].Time) })
+	return occurrences, truncated, nil
+}
+
+// periodDates 返回第 period 个周期的起始日期和周期内满足规则的日期（均为 UTC 零点）
+func (r *Rule) periodDates(anchor time.Time, period int) (time.Time, []time.Time) {
+	var start time.Time
+	var days int
+	switch r.Freq {
+	case Daily:
+		start, days = anchor.AddDate(0, 0, period*r.Interval), 1
+	case Weekly:
+		monday := anchor.AddDate(0, 0, -((int(anchor.Weekday()) + 6) % 7))
+		start, days = monday.AddDate(0, 0, 7*period*r.Interval), 7
+	case Monthly:
+		start = time.Date(anchor.Year(), anchor.Month()+time.Month(period*r.Interval), 1, 0, 0, 0, 0, time.UTC)
+		days = start.AddDate(0, 1, -1).Day()
+	}
+
+	var dates []time.Time
+	for i := 0; i < days; i++ {
+		date := start.AddDate(0, 0, i)
+		if r.matches(date, anchor) {
+			dates = append(dates, date)
+		}
+	}
+	return start, dates
+}
+
+// matches 判断日期是否满足 BYDAY / BYMONTHDAY 条件
+func (r *Rule) matches(date, anchor time.Time) bool {
+	if len(r.ByMonthDay) > 0 && !matchesMonthDay(r.ByMonthDay, date) {
+		return false
+	}
+	if len(r.ByDay) > 0 {
+		return matchesByDay(r.ByDay, date)
+	}
+	if len(r.ByMonthDay) > 0 {
+		return true
+	}
+	switch r.Freq {
+	case Weekly:
+		return date.Weekday() == anchor.Weekday()
+	case Monthly:
+		return date.Day() == anchor.Day()
+	}
+	return true
+}
+
+// matchesMonthDay 日期是否在 BYMONTHDAY 中，负数从月末倒数
+func matchesMonthDay(monthDays []int, date time.Time) bool {
+	lastDay := time.Date(date.Year(), date.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, d := range monthDays {
+		if d == date.Day() || (d < 0 && lastDay+d+1 == date.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesByDay 日期是否在 BYDAY 中，带序号时按当月第 N 个（或倒数第 N 个）星期几判断
+func matchesByDay(byDay []WeekdayNum, date time.Time) bool {
+	lastDay := time.Date(date.Year(), date.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, d := range byDay {
+		if d.Weekday != date.Weekday() {
+			continue
+		}
+		switch {
+		case d.N == 0:
+			return true
+		case d.N > 0 && (date.Day()-1)/7+1 == d.N:
+			return true
+		case d.N < 0 && (lastDay-date.Day())/7+1 == -d.N:
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve 将 loc 时区的本地时间（以 UTC 字段表示）转换为实际时刻
+// 本地时间不存在时按 opts.Gap 顺延或跳过（返回空），出现两次时按 opts.Overlap 取舍
+func Resolve(wall time.Time, loc *time.Location, opts Options) []Occurrence {
+	return resolve(wall, loc, opts)
+}
+
+// resolve 将本地时间（以 UTC 字段表示）转换为实际时刻，按策略处理夏令时跳过和重复
+func resolve(wall time.Time, loc *time.Location, opts Options) []Occurrence {
+	label := wall.Format("2006-01-02 15:04")
+
+	// 候选偏移取前后各一天和当天的偏移，足以覆盖一次夏令时切换
+	seen := map[int]bool{}
+	var matches []time.Time
+	for _, probe := range []time.Time{wall.Add(-24 * time.Hour), wall, wall.Add(24 * time.Hour)} {
+		_, offset := probe.In(loc).Zone()
+		if seen[offset] {
+			continue
+		}
+		seen[offset] = true
+		t := wall.Add(-time.Duration(offset) * time.Second).In(loc)
+		if sameWall(t, wall) {
+			matches = append(matches, t)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Before(matches[j]) })
+
+	switch len(matches) {
+	case 0:
+		if opts.Gap == GapSkip {
+			return nil
+		}
+		// 使用切换前的偏移，得到的本地时间恰好顺延了跳过的时长
+		_, before := wall.Add(-24 * time.Hour).In(loc).Zone()
+		t := wall.Add(-time.Duration(before) * time.Second).In(loc)
+		return []Occurrence{{Time: t, Wall: label, Shifted: true}}
+	case 1:
+		return []Occurrence{{Time: matches[0], Wall: label}}
+	}
+
+	first, last := matches[0], matches[len(matches)-1]
+	switch opts.Overlap {
+	case OverlapLater:
+		return []Occurrence{{Time: last, Wall: label, Ambiguous: true}}
+	case OverlapBoth:
+		return []Occurrence{{Time: first, Wall: label, Ambiguous: true}, {Time: last, Wall: label, Ambiguous: true}}
+	}
+	return []Occurrence{{Time: first, Wall: label, Ambiguous: true}}
+}
+
+// wallToInstant 将本地日期时间转换为时刻（不关心夏令时细节，仅用于范围判断）
+func wallToInstant(wall time.Time, loc *time.Location) time.Time {
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), 0, 0, loc)
+}
+
+// sameWall 判断时刻在本地的年月日时分是否与 wall 一致
+func sameWall(t, wall time.Time) bool {
+	return t.Year() == wall.Year() && t.Month() == wall.Month() && t.Day() == wall.Day() &&
+		t.Hour() == wall.Hour() && t.Minute() == wall.Minute()
+}
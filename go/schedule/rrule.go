@@ -0,0 +1,198 @@
+// Package schedule 实现类 RRULE（RFC 5545 子集）的重复规则解析和按本地时间展开，
+// 供调度器、计费等需要"每个工作日本地 09:00"这类规则的模块使用。
+package schedule
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency 重复频率
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+// WeekdayNum BYDAY 中的一项，N 非 0 时表示每月第 N 个（负数从月末倒数）星期几，仅 MONTHLY 支持
+type WeekdayNum struct {
+	Weekday time.Weekday
+	N       int
+}
+
+// Rule 重复规则
+// 支持 FREQ、INTERVAL、COUNT、BYDAY、BYMONTHDAY、BYHOUR、BYMINUTE，
+// 时间字段均为本地时间，BYHOUR/BYMINUTE 缺省为 0
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByHour     []int
+	ByMinute   []int
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// Parse 解析规则字符串，如 FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR;BYHOUR=9
+// 允许带 RRULE: 前缀，键名不区分大小写
+func Parse(value string) (*Rule, error) {
+	value = strings.TrimSpace(value)
+	if len(value) >= 6 && strings.EqualFold(value[:6], "RRULE:") {
+		value = value[6:]
+	}
+	if value == "" {
+		return nil, fmt.Errorf("重复规则为空")
+	}
+
+	rule := &Rule{Interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, fmt.Errorf("无效的规则片段 %q", part)
+		}
+		key, val := strings.ToUpper(strings.TrimSpace(kv[0])), strings.ToUpper(strings.TrimSpace(kv[1]))
+
+		var err error
+		switch key {
+		case "FREQ":
+			rule.Freq = Frequency(val)
+			if rule.Freq != Daily && rule.Freq != Weekly && rule.Freq != Monthly {
+				return nil, fmt.Errorf("不支持的 FREQ %q，仅支持 DAILY、WEEKLY、MONTHLY", val)
+			}
+		case "INTERVAL":
+			rule.Interval, err = parseBounded(key, val, 1, 1000)
+		case "COUNT":
+			rule.Count, err = parseBounded(key, val, 1, 100000)
+		case "BYDAY":
+			rule.ByDay, err = parseByDay(val)
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseIntList(key, val, -31, 31, true)
+		case "BYHOUR":
+			rule.ByHour, err = parseIntList(key, val, 0, 23, false)
+		case "BYMINUTE":
+			rule.ByMinute, err = parseIntList(key, val, 0, 59, false)
+		default:
+			return nil, fmt.Errorf("不支持的规则字段 %s", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("缺少 FREQ")
+	}
+	for _, d := range rule.ByDay {
+		if d.N != 0 && rule.Freq != Monthly {
+			return nil, fmt.Errorf("BYDAY 序号（如 1MO）仅在 FREQ=MONTHLY 时支持")
+		}
+	}
+	if len(rule.ByHour) == 0 {
+		rule.ByHour = []int{0}
+	}
+	if len(rule.ByMinute) == 0 {
+		rule.ByMinute = []int{0}
+	}
+	return rule, nil
+}
+
+// String 规则的规范化字符串形式
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, fmt.Sprintf("COUNT=%d", r.Count))
+	}
+	if len(r.ByDay) > 0 {
+		var days []string
+		for _, d := range r.ByDay {
+			code := strings.ToUpper(d.Weekday.String()[:2])
+			if d.N != 0 {
+				code = strconv.Itoa(d.N) + code
+			}
+			days = append(days, code)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	parts = append(parts, "BYHOUR="+joinInts(r.ByHour), "BYMINUTE="+joinInts(r.ByMinute))
+	return strings.Join(parts, ";")
+}
+
+// parseByDay 解析 MO,TU 或 1MO,-1FR
+func parseByDay(value string) ([]WeekdayNum, error) {
+	var days []WeekdayNum
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) < 2 {
+			return nil, fmt.Errorf("无效的 BYDAY %q", item)
+		}
+		weekday, ok := weekdayCodes[item[len(item)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("无效的 BYDAY %q", item)
+		}
+		day := WeekdayNum{Weekday: weekday}
+		if prefix := item[:len(item)-2]; prefix != "" {
+			n, err := strconv.Atoi(prefix)
+			if err != nil || n == 0 || n < -5 || n > 5 {
+				return nil, fmt.Errorf("无效的 BYDAY 序号 %q", item)
+			}
+			day.N = n
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// parseIntList 解析逗号分隔的整数列表并排序去重
+func parseIntList(key, value string, min, max int, nonZero bool) ([]int, error) {
+	seen := map[int]bool{}
+	var values []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || n < min || n > max || (nonZero && n == 0) {
+			return nil, fmt.Errorf("无效的 %s %q", key, item)
+		}
+		if !seen[n] {
+			seen[n] = true
+			values = append(values, n)
+		}
+	}
+	sort.Ints(values)
+	return values, nil
+}
+
+// parseBounded 解析范围内的整数
+func parseBounded(key, value string, min, max int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("无效的 %s %q，范围 %d~%d", key, value, min, max)
+	}
+	return n, nil
+}
+
+// joinInts 将整数列表格式化为逗号分隔
+func joinInts(values []int) string {
+	items := make([]string, len(values))
+	for i, v := range values {
+		items[i] = strconv.Itoa(v)
+	}
+	return strings.Join(items, ",")
+}
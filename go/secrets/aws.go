@@ -0,0 +1,178 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AWSConfig AWS Secrets Manager 配置
+type AWSConfig struct {
+	// Region 区域，如 ap-southeast-1
+	Region string
+	// SecretID 密钥名称或 ARN，SecretString 须为 JSON 对象，每个字段对应一个密钥
+	SecretID string
+	// AccessKeyID、SecretAccessKey、SessionToken 访问凭证，只支持通过环境变量提供
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Endpoint 自定义接口地址（如 VPC 终端节点或 LocalStack），为空时使用区域的默认地址
+	Endpoint string
+}
+
+// AWSProvider 从 AWS Secrets Manager 的一个密钥中按字段名读取密钥
+// 直接调用 GetSecretValue 接口并自行完成 SigV4 签名，不依赖 AWS SDK
+type AWSProvider struct {
+	config AWSConfig
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewAWSProvider 创建 AWS Secrets Manager 密钥来源
+func NewAWSProvider(config AWSConfig) (*AWSProvider, error) {
+	if config.Region == "" || config.SecretID == "" {
+		return nil, errors.New("使用 AWS Secrets Manager 需要设置 AWS_REGION 和 AWS_SECRET_ID")
+	}
+	if config.AccessKeyID == "" || config.SecretAccessKey == "" {
+		return nil, errors.New("使用 AWS Secrets Manager 需要设置 AWS_ACCESS_KEY_ID 和 AWS_SECRET_ACCESS_KEY")
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", config.Region)
+	}
+	config.Endpoint = strings.TrimRight(config.Endpoint, "/")
+	return &AWSProvider{config: config, client: &http.Client{Timeout: defaultTimeout}, now: time.Now}, nil
+}
+
+// Name 实现 Provider
+func (p *AWSProvider) Name() string { return "aws:" + p.config.SecretID }
+
+// Get 实现 Provider
+func (p *AWSProvider) Get(ctx context.Context, key string) (string, error) {
+	body, err := json.Marshal(map[string]string{"SecretId": p.config.SecretID})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.Endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("创建 Secrets Manager 请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	p.sign(req, body)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("请求 Secrets Manager 失败: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(respBody, &apiErr)
+		if strings.HasSuffix(apiErr.Type, "ResourceNotFoundException") {
+			return "", fmt.Errorf("%w: Secrets Manager 中没有 %s", ErrNotFound, p.config.SecretID)
+		}
+		return "", fmt.Errorf("Secrets Manager 返回 %d: %s %s", resp.StatusCode, apiErr.Type, apiErr.Message)
+	}
+
+	var result struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("解析 Secrets Manager 响应失败: %w", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(result.SecretString), &fields); err != nil {
+		return "", fmt.Errorf("%s 的 SecretString 不是 JSON 对象: %w", p.config.SecretID, err)
+	}
+	value, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("%w: %s 中没有字段 %s", ErrNotFound, p.config.SecretID, key)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+// sign 按 AWS Signature Version 4 为请求添加 Authorization 头
+func (p *AWSProvider) sign(req *http.Request, body []byte) {
+	const service = "secretsmanager"
+	now := p.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if p.config.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", p.config.SessionToken)
+	}
+
+	// 参与签名的请求头按小写名称排序
+	headers := map[string]string{
+		"content-type": req.Header.Get("Content-Type"),
+		"host":         req.URL.Host,
+		"x-amz-date":   amzDate,
+		"x-amz-target": req.Header.Get("X-Amz-Target"),
+	}
+	names := []string{"content-type", "host", "x-amz-date"}
+	if p.config.SessionToken != "" {
+		headers["x-amz-security-token"] = p.config.SessionToken
+		names = append(names, "x-amz-security-token")
+	}
+	names = append(names, "x-amz-target")
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+
+	scope := strings.Join([]string{date, p.config.Region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+p.config.SecretAccessKey), date)
+	key = hmacSHA256(key, p.config.Region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		p.config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// sha256Hex 内容的 SHA-256 十六进制摘要
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 HMAC-SHA256
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,36 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileProvider 从目录中按密钥名读取文件，如 Docker/Kubernetes 挂载的 /run/secrets/DB_PASSWORD
+type FileProvider struct {
+	dir string
+}
+
+// NewFileProvider 创建文件密钥来源，目录必须存在
+func NewFileProvider(dir string) (*FileProvider, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("密钥目录 SECRETS_DIR 不可用: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("密钥目录 SECRETS_DIR 不是目录: %s", dir)
+	}
+	return &FileProvider{dir: dir}, nil
+}
+
+// Name 实现 Provider
+func (p *FileProvider) Name() string { return "file:" + p.dir }
+
+// Get 实现 Provider
+func (p *FileProvider) Get(ctx context.Context, key string) (string, error) {
+	if key != filepath.Base(key) {
+		return "", fmt.Errorf("密钥名称不合法: %q", key)
+	}
+	return readSecretFile(filepath.Join(p.dir, key))
+}
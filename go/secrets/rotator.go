@@ -0,0 +1,82 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Rotator 定期重新读取密钥，值变化时调用对应的 apply 使新值生效
+type Rotator struct {
+	provider Provider
+
+	mu      sync.Mutex
+	watches []*watch
+}
+
+// watch 一个被跟踪的密钥
+type watch struct {
+	key     string
+	current string
+	apply   func(ctx context.Context, value string) error
+}
+
+// NewRotator 创建密钥轮换任务
+func NewRotator(provider Provider) *Rotator {
+	return &Rotator{provider: provider}
+}
+
+// Watch 跟踪一个密钥，current 为当前生效的值
+// apply 返回错误时保留旧值，下一轮会重试
+func (r *Rotator) Watch(key, current string, apply func(ctx context.Context, value string) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.watches = append(r.watches, &watch{key: key, current: current, apply: apply})
+}
+
+// Refresh 重新读取所有密钥并应用变化，返回轮换的密钥数
+// 单个密钥读取或应用失败不影响其他密钥，错误合并返回
+func (r *Rotator) Refresh(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rotated := 0
+	var errs []error
+	for _, w := range r.watches {
+		value, err := r.provider.Get(ctx, w.key)
+		if errors.Is(err, ErrNotFound) || (err == nil && value == w.current) {
+			continue
+		}
+		if err == nil {
+			err = w.apply(ctx, value)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("轮换 %s 失败: %w", w.key, err))
+			continue
+		}
+		w.current = value
+		rotated++
+		log.Printf("🔑 密钥 %s 已从 %s 轮换", w.key, r.provider.Name())
+	}
+	return rotated, errors.Join(errs...)
+}
+
+// Run 按 interval 定期刷新密钥，直到 ctx 取消
+func (r *Rotator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.Refresh(ctx); err != nil {
+				log.Printf("密钥轮换失败: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Package secrets 从环境变量、文件、HashiCorp Vault 或 AWS Secrets Manager 读取密钥，
+// 并支持定期重新读取，在密钥轮换后更新数据库密码、管理令牌等配置
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrNotFound 密钥不存在，调用方可回退到默认值
+var ErrNotFound = errors.New("密钥不存在")
+
+// Provider 按名称读取密钥，名称与对应的环境变量相同（如 DB_PASSWORD、ADMIN_TOKEN）
+type Provider interface {
+	// Name 密钥来源名称，用于日志
+	Name() string
+	// Get 读取密钥的当前值，不存在时返回 ErrNotFound
+	Get(ctx context.Context, key string) (string, error)
+}
+
+// defaultTimeout 访问 Vault、AWS 的超时时间
+const defaultTimeout = 10 * time.Second
+
+// FromEnv 按 SECRETS_PROVIDER 创建密钥来源：env（默认）| file | vault | aws
+// 各来源的参数见 .env.example
+func FromEnv() (Provider, error) {
+	switch kind := getEnv("SECRETS_PROVIDER", "env"); kind {
+	case "env":
+		return EnvProvider{}, nil
+	case "file":
+		return NewFileProvider(getEnv("SECRETS_DIR", "/run/secrets"))
+	case "vault":
+		return NewVaultProvider(VaultConfig{
+			Addr:      getEnv("VAULT_ADDR", ""),
+			Token:     getEnv("VAULT_TOKEN", ""),
+			Namespace: getEnv("VAULT_NAMESPACE", ""),
+			Mount:     getEnv("VAULT_KV_MOUNT", "secret"),
+			Path:      getEnv("VAULT_SECRET_PATH", ""),
+		})
+	case "aws":
+		return NewAWSProvider(AWSConfig{
+			Region:          getEnv("AWS_REGION", ""),
+			SecretID:        getEnv("AWS_SECRET_ID", ""),
+			AccessKeyID:     getEnv("AWS_ACCESS_KEY_ID", ""),
+			SecretAccessKey: getEnv("AWS_SECRET_ACCESS_KEY", ""),
+			SessionToken:    getEnv("AWS_SESSION_TOKEN", ""),
+			Endpoint:        getEnv("AWS_SECRETS_ENDPOINT", ""),
+		})
+	default:
+		return nil, fmt.Errorf("不支持的密钥来源 SECRETS_PROVIDER=%s，可选 env、file、vault、aws", kind)
+	}
+}
+
+// Resolve 读取密钥，不存在时返回 fallback（通常是同名环境变量的值）
+func Resolve(ctx context.Context, p Provider, key, fallback string) (string, error) {
+	value, err := p.Get(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		return fallback, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("从 %s 读取 %s 失败: %w", p.Name(), key, err)
+	}
+	return value, nil
+}
+
+// EnvProvider 从环境变量读取密钥，未设置时读取 <KEY>_FILE 指向的文件（与 DB_PASSWORD_FILE 相同）
+// 文件内容可以在运行中被替换（如 Kubernetes Secret 挂载），轮换时会读到新值
+type EnvProvider struct{}
+
+// Name 实现 Provider
+func (EnvProvider) Name() string { return "env" }
+
+// Get 实现 Provider
+func (EnvProvider) Get(ctx context.Context, key string) (string, error) {
+	if value := os.Getenv(key); value != "" {
+		return value, nil
+	}
+	if path := os.Getenv(key + "_FILE"); path != "" {
+		return readSecretFile(path)
+	}
+	return "", fmt.Errorf("%w: %s", ErrNotFound, key)
+}
+
+// readSecretFile 读取密钥文件，去掉末尾换行
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("读取密钥文件失败: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// getEnv 获取环境变量，如果不存在则返回默认值
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
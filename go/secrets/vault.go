@@ -0,0 +1,94 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// VaultConfig HashiCorp Vault KV v2 配置
+type VaultConfig struct {
+	// Addr Vault 地址，如 https://vault.example.com:8200
+	Addr string
+	// Token 访问令牌
+	Token string
+	// Namespace Vault Enterprise 命名空间，可为空
+	Namespace string
+	// Mount KV v2 引擎的挂载路径，默认 secret
+	Mount string
+	// Path 密钥路径，该路径下每个字段对应一个密钥（如 DB_PASSWORD、ADMIN_TOKEN）
+	Path string
+}
+
+// VaultProvider 从 Vault KV v2 的一个密钥路径中按字段名读取密钥
+// 每次 Get 都读取最新版本，Vault 中更新后下一次轮换即可生效
+type VaultProvider struct {
+	config VaultConfig
+	client *http.Client
+}
+
+// NewVaultProvider 创建 Vault 密钥来源
+func NewVaultProvider(config VaultConfig) (*VaultProvider, error) {
+	if config.Addr == "" || config.Token == "" || config.Path == "" {
+		return nil, errors.New("使用 Vault 需要设置 VAULT_ADDR、VAULT_TOKEN 和 VAULT_SECRET_PATH")
+	}
+	if config.Mount == "" {
+		config.Mount = "secret"
+	}
+	config.Addr = strings.TrimRight(config.Addr, "/")
+	config.Mount = strings.Trim(config.Mount, "/")
+	config.Path = strings.Trim(config.Path, "/")
+	return &VaultProvider{config: config, client: &http.Client{Timeout: defaultTimeout}}, nil
+}
+
+// Name 实现 Provider
+func (p *VaultProvider) Name() string {
+	return "vault:" + p.config.Mount + "/" + p.config.Path
+}
+
+// Get 实现 Provider
+func (p *VaultProvider) Get(ctx context.Context, key string) (string, error) {
+	url := fmt.Sprintf("%s/v1/%s/data/%s", p.config.Addr, p.config.Mount, p.config.Path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("创建 Vault 请求失败: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", p.config.Token)
+	if p.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.config.Namespace)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("请求 Vault 失败: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("%w: Vault 中没有 %s", ErrNotFound, p.Name())
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Vault 返回 %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("解析 Vault 响应失败: %w", err)
+	}
+	value, ok := result.Data.Data[key]
+	if !ok {
+		return "", fmt.Errorf("%w: %s 中没有字段 %s", ErrNotFound, p.Name(), key)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"timezone-saas-demo/client"
+	"timezone-saas-demo/testsupport"
+)
+
+// TestMockServer 按 serve --mock 的方式创建服务和路由，运行 HTTP 和客户端用例；PostgreSQL 上的同一组用例见 integration_test.go
+func TestMockServer(t *testing.T) {
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	alerter, mailer, _, err := setupMonitors(config)
+	if err != nil {
+		t.Fatalf("初始化监控失败: %v", err)
+	}
+	setupMockServices(1, testsupport.DefaultMockEndDate, alerter, mailer)
+	if err := configureServer(config); err != nil {
+		t.Fatalf("配置服务失败: %v", err)
+	}
+	if err := startServices(config); err != nil {
+		t.Fatalf("启动服务失败: %v", err)
+	}
+	router := setupRoutes()
+
+	t.Run("HTTP", func(t *testing.T) {
+		testsupport.RunHTTPSuite(t, router)
+	})
+	t.Run("Client", func(t *testing.T) {
+		server := httptest.NewServer(router)
+		defer server.Close()
+		c, err := client.New(server.URL)
+		if err != nil {
+			t.Fatalf("创建客户端失败: %v", err)
+		}
+		testsupport.RunClientSuite(t, c)
+	})
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"timezone-saas-demo/models"
+)
+
+// 告警级别
+const (
+	AlertInfo     = "info"
+	AlertWarning  = "warning"
+	AlertCritical = "critical"
+)
+
+// defaultAlertTimeout 发送告警的超时时间
+const defaultAlertTimeout = 10 * time.Second
+
+// Alerter 发送运维告警
+type Alerter interface {
+	Alert(ctx context.Context, alert models.Alert) error
+}
+
+// WebhookAlerter 以 JSON POST 告警到 Webhook 地址
+// 请求体带 text 字段，可以直接对接 Slack、飞书等兼容 incoming webhook 的机器人
+type WebhookAlerter struct {
+	client *http.Client
+
+	mu  sync.RWMutex
+	url string
+}
+
+// NewWebhookAlerter 创建 Webhook 告警
+func NewWebhookAlerter(url string) *WebhookAlerter {
+	return &WebhookAlerter{url: url, client: &http.Client{Timeout: defaultAlertTimeout}}
+}
+
+// SetURL 替换 Webhook 地址，地址中通常带有令牌，随密钥轮换
+func (a *WebhookAlerter) SetURL(url string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.url = url
+}
+
+// Alert 发送告警，非 2xx 响应视为失败
+func (a *WebhookAlerter) Alert(ctx context.Context, alert models.Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("序列化告警失败: %w", err)
+	}
+	a.mu.RLock()
+	url := a.url
+	a.mu.RUnlock()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建告警请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送告警失败: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("发送告警失败: Webhook 返回 %d", resp.StatusCode)
+	}
+	return nil
+}
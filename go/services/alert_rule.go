@@ -0,0 +1,595 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/mailtemplate"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/money"
+	"timezone-saas-demo/repository"
+	"timezone-saas-demo/timerange"
+)
+
+// 告警规则类型
+const (
+	// AlertRuleNoOrders 连续 WindowHours 个本地营业小时没有订单
+	AlertRuleNoOrders = "no_orders"
+	// AlertRuleRevenueDrop 当天截至当前的营收低于上周同一天同一本地时刻的 ThresholdPercent%
+	AlertRuleRevenueDrop = "revenue_drop"
+)
+
+// 告警规则状态和告警历史的状态
+const (
+	AlertStateOK       = "ok"
+	AlertStateFiring   = "firing"
+	AlertStateResolved = "resolved"
+)
+
+// 告警通知渠道和发送结果
+const (
+	AlertChannelWebhook = "webhook"
+	AlertChannelEmail   = "email"
+
+	AlertDeliverySent    = "sent"
+	AlertDeliveryFailed  = "failed"
+	AlertDeliverySkipped = "skipped"
+)
+
+const (
+	// maxAlertWindowHours no_orders 规则最长的营业小时窗口
+	maxAlertWindowHours = 72
+	// alertLookbackDays no_orders 规则向前查找营业时间的天数
+	alertLookbackDays = 31
+	// maxAlertEmails 一条规则最多的收件人
+	maxAlertEmails = 20
+	// maxAlertEvents 一次最多返回的告警历史
+	maxAlertEvents = 500
+)
+
+// AlertRuleRequest 创建或修改告警规则的请求
+type AlertRuleRequest struct {
+	Name       string `json:"name"`
+	MerchantID int    `json:"merchant_id"`
+	Type       string `json:"type"`
+	// WindowHours no_orders 必填，1~72
+	WindowHours int `json:"window_hours"`
+	// ThresholdPercent revenue_drop 必填，1~100
+	ThresholdPercent int      `json:"threshold_percent"`
+	Currency         string   `json:"currency"`
+	Statuses         []string `json:"statuses"`
+	WebhookURL       string   `json:"webhook_url"`
+	Emails           []string `json:"emails"`
+	// Enabled 缺省为 true
+	Enabled  *bool  `json:"enabled"`
+	Operator string `json:"operator"`
+}
+
+// AlertRuleService 租户的指标告警规则
+// 后台任务定期按商户时区检查已启用的规则，状态在 ok 和 firing 之间变化时通过 Webhook 和邮件通知并记录告警历史；
+// 规则和告警历史按请求的租户（X-Tenant-ID）隔离
+type AlertRuleService struct {
+	alerts    repository.AlertRepository
+	merchants repository.MerchantRepository
+	emails    *EmailService
+	revenue   models.RevenueDefinition
+
+	// mu 保证同一进程内的检查不会重叠
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+// NewAlertRuleService 创建告警规则服务，使用 PostgreSQL 仓储；邮件通过 emails 按模板发送，未配置 SMTP 时不发送
+func NewAlertRuleService(db *database.DB, emails *EmailService) *AlertRuleService {
+	return NewAlertRuleServiceWithRepositories(
+		repository.NewPostgresAlertRepository(db),
+		repository.NewPostgresMerchantRepository(db),
+		emails,
+	)
+}
+
+// NewAlertRuleServiceWithRepositories 使用指定仓储创建告警规则服务
+func NewAlertRuleServiceWithRepositories(alerts repository.AlertRepository, merchants repository.MerchantRepository, emails *EmailService) *AlertRuleService {
+	return &AlertRuleService{
+		alerts:    alerts,
+		merchants: merchants,
+		emails:    emails,
+		revenue:   DefaultRevenueDefinition,
+		now:       time.Now,
+	}
+}
+
+// SetRevenueDefinition 设置规则未指定订单状态时排除的状态，与分析接口的营收口径一致
+// 告警只比较订单金额，不扣除退款
+func (s *AlertRuleService) SetRevenueDefinition(revenue models.RevenueDefinition) {
+	s.revenue = revenue
+}
+
+// CreateRule 校验并保存 ctx 中租户的告警规则
+func (s *AlertRuleService) CreateRule(ctx context.Context, req AlertRuleRequest) (*models.AlertRule, error) {
+	rule, err := s.prepareRule(req)
+	if err != nil {
+		return nil, err
+	}
+	rule.Tenant = TenantFromContext(ctx)
+	rule.CreatedBy = operatorOrSystem(req.Operator)
+	if err := s.alerts.CreateRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// UpdateRule 覆盖告警规则的配置，规则状态不变，下一次检查按新配置判断
+func (s *AlertRuleService) UpdateRule(ctx context.Context, id int, req AlertRuleRequest) (*models.AlertRule, error) {
+	rule, err := s.prepareRule(req)
+	if err != nil {
+		return nil, err
+	}
+	rule.ID = id
+	rule.Tenant = TenantFromContext(ctx)
+	if err := s.alerts.UpdateRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// DeleteRule 删除告警规则及其告警历史
+func (s *AlertRuleService) DeleteRule(ctx context.Context, id int) error {
+	return s.alerts.DeleteRule(ctx, TenantFromContext(ctx), id)
+}
+
+// Rules ctx 中租户的全部告警规则
+func (s *AlertRuleService) Rules(ctx context.Context) ([]models.AlertRule, error) {
+	rules, err := s.alerts.Rules(ctx, TenantFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if rules == nil {
+		rules = []models.AlertRule{}
+	}
+	return rules, nil
+}
+
+// Rule 单个告警规则，不存在或属于其他租户时返回 ErrNotFound
+func (s *AlertRuleService) Rule(ctx context.Context, id int) (*models.AlertRule, error) {
+	return s.alerts.Rule(ctx, TenantFromContext(ctx), id)
+}
+
+// prepareRule 校验请求并生成告警规则（不含ID、租户和状态）
+func (s *AlertRuleService) prepareRule(req AlertRuleRequest) (*models.AlertRule, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len([]rune(name)) > 100 {
+		return nil, fmt.Errorf("%w: 告警规则名称不能为空且不超过 100 个字符", ErrInvalidArgument)
+	}
+	if req.MerchantID <= 0 {
+		return nil, fmt.Errorf("%w: 必须指定商户", ErrInvalidArgument)
+	}
+	if _, err := s.merchants.Get(req.MerchantID); err != nil {
+		return nil, err
+	}
+
+	rule := &models.AlertRule{
+		Name:       name,
+		MerchantID: req.MerchantID,
+		Type:       strings.ToLower(strings.TrimSpace(req.Type)),
+		Enabled:    req.Enabled == nil || *req.Enabled,
+	}
+	switch rule.Type {
+	case AlertRuleNoOrders:
+		if req.WindowHours < 1 || req.WindowHours > maxAlertWindowHours {
+			return nil, fmt.Errorf("%w: no_orders 规则的 window_hours 必须在 1~%d 之间", ErrInvalidArgument, maxAlertWindowHours)
+		}
+		if req.ThresholdPercent != 0 || strings.TrimSpace(req.Currency) != "" {
+			return nil, fmt.Errorf("%w: no_orders 规则不支持 threshold_percent 和 currency", ErrInvalidArgument)
+		}
+		rule.WindowHours = req.WindowHours
+	case AlertRuleRevenueDrop:
+		if req.ThresholdPercent < 1 || req.ThresholdPercent > 100 {
+			return nil, fmt.Errorf("%w: revenue_drop 规则的 threshold_percent 必须在 1~100 之间", ErrInvalidArgument)
+		}
+		if req.WindowHours != 0 {
+			return nil, fmt.Errorf("%w: revenue_drop 规则不支持 window_hours", ErrInvalidArgument)
+		}
+		rule.ThresholdPercent = req.ThresholdPercent
+		if currency := strings.TrimSpace(req.Currency); currency != "" {
+			code, err := money.ParseCode(currency)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+			}
+			rule.Currency = code
+		}
+	default:
+		return nil, fmt.Errorf("%w: 无效的告警规则类型 %q，可选 %s,%s", ErrInvalidArgument, req.Type, AlertRuleNoOrders, AlertRuleRevenueDrop)
+	}
+
+	statuses, err := ParseStatuses(strings.Join(req.Statuses, ","))
+	if err != nil {
+		return nil, err
+	}
+	rule.Statuses = statuses
+	if rule.Statuses == nil {
+		rule.Statuses = []string{}
+	}
+
+	if value := strings.TrimSpace(req.WebhookURL); value != "" {
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("%w: 无效的 Webhook 地址 %q", ErrInvalidArgument, value)
+		}
+		rule.WebhookURL = value
+	}
+	if rule.Emails, err = normalizeEmails(req.Emails, maxAlertEmails); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// Evaluate 立即检查 ctx 中租户的一条告警规则（包括已停用的规则），状态变化时与定时检查一样发送通知
+func (s *AlertRuleService) Evaluate(ctx context.Context, id int) (*models.AlertEvaluation, error) {
+	rule, err := s.alerts.Rule(ctx, TenantFromContext(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.evaluate(ctx, rule, s.now())
+}
+
+// Run 每隔 interval 检查所有已启用的告警规则，直到 ctx 取消
+func (s *AlertRuleService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := s.EvaluateAll(ctx, s.now()); err != nil {
+			log.Printf("检查告警规则失败: %v", err)
+		} else if n > 0 {
+			log.Printf("告警规则检查完成: %d 个规则状态变化", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// EvaluateAll 在 at 时刻检查所有已启用的告警规则，返回状态变化的规则数
+// 单个规则检查失败只记录日志，不影响其他规则
+func (s *AlertRuleService) EvaluateAll(ctx context.Context, at time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rules, err := s.alerts.EnabledRules(ctx)
+	if err != nil {
+		return 0, err
+	}
+	changed := 0
+	for i := range rules {
+		eval, err := s.evaluate(ctx, &rules[i], at)
+		if err != nil {
+			log.Printf("⚠️ 告警规则 %d（%s）检查失败: %v", rules[i].ID, rules[i].Name, err)
+			continue
+		}
+		if eval.Event != nil {
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+// alertCheck 一次条件判断的结果
+type alertCheck struct {
+	// evaluated 为 false 时条件不适用，reason 为原因
+	evaluated bool
+	reason    string
+	firing    bool
+	text      string
+	details   interface{}
+}
+
+// evaluate 在 at 时刻检查规则，状态变化时发送通知并写入告警历史
+func (s *AlertRuleService) evaluate(ctx context.Context, rule *models.AlertRule, at time.Time) (*models.AlertEvaluation, error) {
+	eval := &models.AlertEvaluation{RuleID: rule.ID, State: rule.State, EvaluatedAt: at.UTC()}
+	merchant, err := s.merchants.Get(rule.MerchantID)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := LoadLocation(merchant.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	var check alertCheck
+	switch rule.Type {
+	case AlertRuleNoOrders:
+		check, err = s.checkNoOrders(ctx, rule, merchant, loc, at)
+	case AlertRuleRevenueDrop:
+		check, err = s.checkRevenueDrop(ctx, rule, loc, at)
+	default:
+		err = fmt.Errorf("未知的告警规则类型 %q", rule.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	eval.Evaluated, eval.Reason, eval.Text = check.evaluated, check.reason, check.text
+	if !check.evaluated {
+		return eval, nil
+	}
+
+	to := AlertStateOK
+	if check.firing {
+		to = AlertStateFiring
+	}
+	ok, err := s.alerts.TransitionState(ctx, rule.ID, rule.State, to, at)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || to == rule.State {
+		// 状态未变化，或已被其他实例更新并通知
+		return eval, nil
+	}
+
+	event := &models.AlertEvent{
+		RuleID:     rule.ID,
+		Tenant:     rule.Tenant,
+		MerchantID: rule.MerchantID,
+		State:      AlertStateResolved,
+		Title:      fmt.Sprintf("[恢复] 告警规则 %s: %s", rule.Name, merchant.Name),
+		Text:       check.text,
+		Timezone:   merchant.Timezone,
+		LocalTime:  at.In(loc).Format("2006-01-02 15:04:05 MST"),
+		OccurredAt: at.UTC(),
+	}
+	if to == AlertStateFiring {
+		event.State = AlertStateFiring
+		event.Title = fmt.Sprintf("告警规则 %s: %s", rule.Name, merchant.Name)
+	}
+	if check.details != nil {
+		if event.Details, err = json.Marshal(check.details); err != nil {
+			return nil, fmt.Errorf("序列化告警详情失败: %w", err)
+		}
+	}
+	event.Deliveries = s.notify(ctx, rule, merchant, event)
+	if err := s.alerts.CreateEvent(ctx, event); err != nil {
+		return nil, err
+	}
+	rule.State = to
+	eval.State, eval.Event = to, event
+	return eval, nil
+}
+
+// alertInterval 告警详情中的本地时间区间
+type alertInterval struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// noOrdersDetails no_orders 规则的告警详情
+type noOrdersDetails struct {
+	WindowHours int             `json:"window_hours"`
+	Intervals   []alertInterval `json:"business_intervals"`
+	OrderCount  int             `json:"order_count"`
+}
+
+// checkNoOrders 商户在营业时间内时，统计最近 WindowHours 个营业小时（跳过非营业时间和周末）的订单数
+func (s *AlertRuleService) checkNoOrders(ctx context.Context, rule *models.AlertRule, merchant *models.Merchant, loc *time.Location, at time.Time) (alertCheck, error) {
+	hours, err := MerchantBusinessHours(*merchant)
+	if err != nil {
+		return alertCheck{}, err
+	}
+	if !hours.IsOpen(at.In(loc)) {
+		return alertCheck{reason: fmt.Sprintf("商户不在营业时间（%s）", hours)}, nil
+	}
+
+	window := time.Duration(rule.WindowHours) * time.Hour
+	intervals := hours.Intervals(at.AddDate(0, 0, -alertLookbackDays), at, loc)
+	var covered []TimeRange
+	var total time.Duration
+	for i := len(intervals) - 1; i >= 0 && total < window; i-- {
+		r := intervals[i]
+		if remaining := window - total; r.Duration() > remaining {
+			r.Start = r.End.Add(-remaining)
+		}
+		covered = append([]TimeRange{r}, covered...)
+		total += r.Duration()
+	}
+	if total < window {
+		return alertCheck{reason: fmt.Sprintf("最近 %d 天的营业时间不足 %d 小时", alertLookbackDays, rule.WindowHours)}, nil
+	}
+
+	details := noOrdersDetails{WindowHours: rule.WindowHours}
+	statuses := countedStatuses(s.revenue, rule.Statuses)
+	for _, r := range covered {
+		totals, err := s.alerts.MerchantTotals(ctx, rule.MerchantID, r.Start, r.End, statuses)
+		if err != nil {
+			return alertCheck{}, err
+		}
+		for _, t := range totals {
+			details.OrderCount += t.OrderCount
+		}
+		details.Intervals = append(details.Intervals, alertInterval{
+			Start: r.Start.In(loc).Format("2006-01-02 15:04"),
+			End:   r.End.In(loc).Format("2006-01-02 15:04"),
+		})
+	}
+
+	check := alertCheck{evaluated: true, details: details}
+	since := covered[0].Start.In(loc).Format("2006-01-02 15:04")
+	if details.OrderCount == 0 {
+		check.firing = true
+		check.text = fmt.Sprintf("商户 %s 最近 %d 个营业小时（本地时间 %s 起）没有订单", merchant.Name, rule.WindowHours, since)
+	} else {
+		check.text = fmt.Sprintf("商户 %s 最近 %d 个营业小时（本地时间 %s 起）有 %d 笔订单", merchant.Name, rule.WindowHours, since, details.OrderCount)
+	}
+	return check, nil
+}
+
+// revenueComparison 一个币种当天与上周同期的营收
+type revenueComparison struct {
+	Currency string          `json:"currency"`
+	Current  decimal.Decimal `json:"current"`
+	Baseline decimal.Decimal `json:"baseline"`
+	Percent  decimal.Decimal `json:"percent"`
+	Dropped  bool            `json:"dropped"`
+}
+
+// revenueDropDetails revenue_drop 规则的告警详情
+type revenueDropDetails struct {
+	ThresholdPercent int                 `json:"threshold_percent"`
+	Current          alertInterval       `json:"current"`
+	Baseline         alertInterval       `json:"baseline"`
+	Currencies       []revenueComparison `json:"currencies"`
+}
+
+// checkRevenueDrop 比较商户当天本地零点至 at 与上周同一天零点至同一本地时刻的营收，上周同期没有营收的币种不比较
+// 当天和上周同一天从本地日期的第一个时刻开始（零点不存在时为切换后的时刻），
+// 截止时刻按 time.Date 构造，夏令时切换的日子与上周对应的是同一墙上时间
+func (s *AlertRuleService) checkRevenueDrop(ctx context.Context, rule *models.AlertRule, loc *time.Location, at time.Time) (alertCheck, error) {
+	local := at.In(loc)
+	today := timerange.Today(loc, at)
+	dayStart := timerange.StartOfDay(today, loc)
+	baseStart := timerange.StartOfDay(today.AddDate(0, 0, -7), loc)
+	baseEnd := time.Date(local.Year(), local.Month(), local.Day()-7, local.Hour(), local.Minute(), local.Second(), local.Nanosecond(), loc)
+
+	statuses := countedStatuses(s.revenue, rule.Statuses)
+	current, err := s.alerts.MerchantTotals(ctx, rule.MerchantID, dayStart, at, statuses)
+	if err != nil {
+		return alertCheck{}, err
+	}
+	baseline, err := s.alerts.MerchantTotals(ctx, rule.MerchantID, baseStart, baseEnd, statuses)
+	if err != nil {
+		return alertCheck{}, err
+	}
+	currentByCurrency := map[string]decimal.Decimal{}
+	for _, t := range current {
+		currentByCurrency[t.Currency] = t.GrossAmount
+	}
+
+	const layout = "2006-01-02 15:04"
+	details := revenueDropDetails{
+		ThresholdPercent: rule.ThresholdPercent,
+		Current:          alertInterval{Start: dayStart.Format(layout), End: local.Format(layout)},
+		Baseline:         alertInterval{Start: baseStart.Format(layout), End: baseEnd.Format(layout)},
+	}
+	threshold := decimal.NewFromInt(int64(rule.ThresholdPercent))
+	hundred := decimal.NewFromInt(100)
+	var dropped []string
+	for _, t := range baseline {
+		if (rule.Currency != "" && t.Currency != rule.Currency) || !t.GrossAmount.IsPositive() {
+			continue
+		}
+		c := revenueComparison{
+			Currency: t.Currency,
+			Current:  money.Round(currentByCurrency[t.Currency], t.Currency),
+			Baseline: money.Round(t.GrossAmount, t.Currency),
+		}
+		c.Percent = currentByCurrency[t.Currency].Mul(hundred).Div(t.GrossAmount).Round(1)
+		c.Dropped = currentByCurrency[t.Currency].Mul(hundred).LessThan(t.GrossAmount.Mul(threshold))
+		if c.Dropped {
+			dropped = append(dropped, fmt.Sprintf("%s %s%%", c.Currency, c.Percent))
+		}
+		details.Currencies = append(details.Currencies, c)
+	}
+	if len(details.Currencies) == 0 {
+		return alertCheck{reason: "上周同一天同一时段没有营收"}, nil
+	}
+
+	check := alertCheck{evaluated: true, details: details}
+	if len(dropped) > 0 {
+		check.firing = true
+		check.text = fmt.Sprintf("截至本地时间 %s 的营收低于上周同期的 %d%%：%s", local.Format(layout), rule.ThresholdPercent, strings.Join(dropped, "，"))
+	} else {
+		check.text = fmt.Sprintf("截至本地时间 %s 的营收不低于上周同期的 %d%%", local.Format(layout), rule.ThresholdPercent)
+	}
+	return check, nil
+}
+
+// notify 通过规则配置的 Webhook 和邮件发送告警，返回每个渠道的发送结果
+// 邮件使用租户的 alert_firing / alert_resolved 模板，按商户的语言和时区渲染
+func (s *AlertRuleService) notify(ctx context.Context, rule *models.AlertRule, merchant *models.Merchant, event *models.AlertEvent) []models.AlertDelivery {
+	deliveries := []models.AlertDelivery{}
+	body := fmt.Sprintf("%s\n\n商户本地时间: %s（%s）", event.Text, event.LocalTime, event.Timezone)
+
+	if rule.WebhookURL != "" {
+		severity := AlertWarning
+		if event.State == AlertStateResolved {
+			severity = AlertInfo
+		}
+		alert := models.Alert{
+			Source:   "alert_rule",
+			Severity: severity,
+			Title:    event.Title,
+			Text:     body,
+			Details:  event.Details,
+			At:       event.OccurredAt,
+		}
+		d := models.AlertDelivery{Channel: AlertChannelWebhook, Target: rule.WebhookURL, Status: AlertDeliverySent}
+		if err := NewWebhookAlerter(rule.WebhookURL).Alert(ctx, alert); err != nil {
+			d.Status, d.Error = AlertDeliveryFailed, err.Error()
+			log.Printf("⚠️ 告警规则 %d 的 Webhook 发送失败: %v", rule.ID, err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if len(rule.Emails) > 0 {
+		d := models.AlertDelivery{Channel: AlertChannelEmail, Target: strings.Join(rule.Emails, ","), Status: AlertDeliverySent}
+		if err := s.sendEmail(ctx, rule, merchant, event); errors.Is(err, ErrMailDisabled) {
+			d.Status, d.Error = AlertDeliverySkipped, err.Error()
+		} else if err != nil {
+			d.Status, d.Error = AlertDeliveryFailed, err.Error()
+			log.Printf("⚠️ 告警规则 %d 的邮件发送失败: %v", rule.ID, err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries
+}
+
+// sendEmail 按规则所属租户的模板发送告警邮件
+func (s *AlertRuleService) sendEmail(ctx context.Context, rule *models.AlertRule, merchant *models.Merchant, event *models.AlertEvent) error {
+	if !s.emails.Enabled() {
+		return ErrMailDisabled
+	}
+	opts, err := s.emails.MerchantOptions(merchant)
+	if err != nil {
+		return err
+	}
+	name := mailtemplate.AlertFiring
+	if event.State == AlertStateResolved {
+		name = mailtemplate.AlertResolved
+	}
+	data := mailtemplate.AlertData{Rule: *rule, Event: *event, Merchant: *merchant}
+	return s.emails.Send(ctx, rule.Tenant, name, rule.Emails, opts, data)
+}
+
+// Events ctx 中租户最近的告警历史，ruleID 不为 0 时只返回该规则的记录
+func (s *AlertRuleService) Events(ctx context.Context, ruleID, limit int) ([]models.AlertEvent, error) {
+	tenant := TenantFromContext(ctx)
+	if ruleID > 0 {
+		if _, err := s.alerts.Rule(ctx, tenant, ruleID); err != nil {
+			return nil, err
+		}
+	}
+	if limit <= 0 || limit > maxAlertEvents {
+		limit = maxAlertEvents
+	}
+	events, err := s.alerts.Events(ctx, tenant, ruleID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if events == nil {
+		events = []models.AlertEvent{}
+	}
+	return events, nil
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/money"
+	"timezone-saas-demo/repository"
+)
+
+// MaxAnalysisBatchDates 一次批量分析的日期数上限，足够日历视图显示两个月
+const MaxAnalysisBatchDates = 62
+
+// ParseAnalysisDates 解析批量分析的日期：dates 为逗号分隔的本地日期（YYYY-MM-DD），month 为 YYYY-MM 表示该月每一天，
+// 两者只能指定一个；重复的日期只保留第一次出现的位置
+func ParseAnalysisDates(dates, month string) ([]string, error) {
+	dates, month = strings.TrimSpace(dates), strings.TrimSpace(month)
+	switch {
+	case dates != "" && month != "":
+		return nil, fmt.Errorf("%w: dates 和 month 只能指定一个", ErrInvalidArgument)
+	case month != "":
+		start, err := time.Parse("2006-01", month)
+		if err != nil {
+			return nil, fmt.Errorf("%w: 月份格式错误，应为 YYYY-MM", ErrInvalidArgument)
+		}
+		var result []string
+		for d := start; d.Month() == start.Month(); d = d.AddDate(0, 0, 1) {
+			result = append(result, d.Format("2006-01-02"))
+		}
+		return result, nil
+	case dates == "":
+		return nil, fmt.Errorf("%w: 需要指定 dates 或 month", ErrInvalidArgument)
+	}
+
+	var result []string
+	seen := map[string]bool{}
+	for _, date := range strings.Split(dates, ",") {
+		date = strings.TrimSpace(date)
+		if date == "" {
+			continue
+		}
+		if _, err := parseLocalDate(date); err != nil {
+			return nil, err
+		}
+		if !seen[date] {
+			seen[date] = true
+			result = append(result, date)
+		}
+	}
+	if len(result) > MaxAnalysisBatchDates {
+		return nil, fmt.Errorf("%w: 一次最多分析 %d 个日期，请求了 %d 个", ErrInvalidArgument, MaxAnalysisBatchDates, len(result))
+	}
+	return result, nil
+}
+
+// GetAnalysisBatch 一次获取多个本地日期的分析数据，结果顺序与 dates 一致，每个日期的内容与 GetAnalysisData 相同
+// 分析后端支持批量查询时全部日期的聚合由一条语句返回，退款合计也只查询一次，超时时整体返回错误；
+// 不支持时（如 ClickHouse）或指定了 aggregateTZ 时在同一个租户名额内逐个日期查询，aggregateTZ 的含义与 GetAnalysisDataIn 相同
+func (s *TimezoneService) GetAnalysisBatch(ctx context.Context, dates []string, aggregateTZ, currency string, statuses []string) ([]models.AnalysisData, error) {
+	if len(dates) == 0 {
+		return nil, fmt.Errorf("%w: 没有需要分析的日期", ErrInvalidArgument)
+	}
+	if len(dates) > MaxAnalysisBatchDates {
+		return nil, fmt.Errorf("%w: 一次最多分析 %d 个日期，请求了 %d 个", ErrInvalidArgument, MaxAnalysisBatchDates, len(dates))
+	}
+	for _, date := range dates {
+		if _, err := parseLocalDate(date); err != nil {
+			return nil, err
+		}
+	}
+	if currency != "" {
+		var err error
+		if currency, err = money.ParseCode(currency); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+		}
+	}
+	if err := validateAggregateTimezone(aggregateTZ); err != nil {
+		return nil, err
+	}
+
+	// 整个批量请求只占用一个名额
+	release, err := s.limiter.Acquire(ctx, TenantFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	batch, ok := s.analytics.(repository.BatchAnalysisRepository)
+	if !ok || aggregateTZ != "" {
+		result := make([]models.AnalysisData, 0, len(dates))
+		for _, date := range dates {
+			analysis, err := s.analysisData(ctx, date, aggregateTZ, currency, statuses)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, *analysis)
+		}
+		return result, nil
+	}
+
+	filter := models.AnalysisFilter{Statuses: countedStatuses(s.revenue, statuses)}
+	var (
+		aggregates map[string]*models.AnalysisAggregates
+		refunds    map[string][]models.RefundTotal
+	)
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		return s.withQueryTimeout(gctx, func(ctx context.Context) (err error) {
+			if refunds, err = s.refunds.TotalsByDate(ctx, dates, filter); err != nil {
+				return fmt.Errorf("获取退款汇总失败: %w", err)
+			}
+			return nil
+		})
+	})
+	g.Go(func() error {
+		return s.withQueryTimeout(gctx, func(ctx context.Context) (err error) {
+			if aggregates, err = batch.AggregatesByDate(ctx, dates, filter, topMerchantLimit); err != nil {
+				return fmt.Errorf("获取分析聚合失败: %w", err)
+			}
+			return nil
+		})
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	result := make([]models.AnalysisData, 0, len(dates))
+	for _, date := range dates {
+		analysis := models.AnalysisData{
+			Date:      date,
+			Source:    s.analytics.Name(),
+			QueryMode: AnalysisQueryModeBatch,
+			Statuses:  filter.Statuses,
+			Revenue:   s.revenue,
+			Currency:  currency,
+			TimeBasis: models.TimeBasisMerchantLocal,
+		}
+		var totals []models.CurrencyTotal
+		if a, ok := aggregates[date]; ok {
+			totals = a.Summary
+			analysis.HourlyBreakdown = a.Hourly
+			analysis.TimezoneStats = a.Timezones
+			analysis.TopMerchants = a.TopMerchants
+		}
+		s.finishAnalysis(&analysis, totals, refunds[date])
+		result = append(result, analysis)
+	}
+	return result, nil
+}
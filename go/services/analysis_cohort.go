@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/timerange"
+)
+
+// 注册周群组分析的默认值和上限
+const (
+	// DefaultCohortWeeks 注册周之后默认统计的周数
+	DefaultCohortWeeks = 12
+	// MaxCohortWeeks 注册周之后最多统计的周数
+	MaxCohortWeeks = 52
+	// maxCohortSignupDays 注册日期范围最多的天数
+	maxCohortSignupDays = 366
+)
+
+// CohortQuery 注册周群组分析的参数
+type CohortQuery struct {
+	// From、To 商户注册日期范围（含两端，YYYY-MM-DD），按商户本地日期
+	// To 为空时为当前 UTC 日期，From 为空时为 To 所在周之前 DefaultCohortWeeks-1 周的第一天
+	From string
+	To   string
+	// Weeks 注册周之后统计的周数，0 时为 DefaultCohortWeeks
+	Weeks int
+	// WeekStart 周起始日，ISO 星期：1=周一 ... 7=周日，0 时为周一
+	WeekStart int
+	// Statuses 参与统计的订单状态，为空时按营收口径
+	Statuses []string
+}
+
+// cohortMember 群组中的商户及其当前本地日期
+type cohortMember struct {
+	id    int
+	today string
+}
+
+// GetCohorts 按商户注册周分组，统计每个群组在之后各周的订单留存
+// 注册日期取 created_at 在商户时区的本地日期，订单按 local_date 分周，两者都是商户自己的日历，
+// 因此同一周对不同时区的商户是不同的 UTC 区间；商户本地日期尚未进入的周不计入留存率的分母，
+// 所有商户都尚未进入的周不返回，群组内还有商户未过完的周 complete 为 false
+func (s *TimezoneService) GetCohorts(ctx context.Context, q CohortQuery) (*models.CohortReport, error) {
+	now := time.Now().UTC()
+	if q.WeekStart == 0 {
+		q.WeekStart = 1
+	}
+	if q.WeekStart < 1 || q.WeekStart > 7 {
+		return nil, fmt.Errorf("%w: 周起始日应为 1（周一）~7（周日），得到 %d", ErrInvalidArgument, q.WeekStart)
+	}
+	if q.Weeks == 0 {
+		q.Weeks = DefaultCohortWeeks
+	}
+	if q.Weeks < 1 || q.Weeks > MaxCohortWeeks {
+		return nil, fmt.Errorf("%w: 统计周数应为 1~%d，得到 %d", ErrInvalidArgument, MaxCohortWeeks, q.Weeks)
+	}
+
+	to := timerange.Today(time.UTC, now)
+	if q.To != "" {
+		var err error
+		if to, err = parseLocalDate(q.To); err != nil {
+			return nil, err
+		}
+	}
+	from := q.From
+	if from == "" {
+		from = cohortWeekStart(to, q.WeekStart).AddDate(0, 0, -7*(DefaultCohortWeeks-1)).Format(timerange.Layout)
+	}
+	signups, err := localDates(from, to.Format(timerange.Layout), time.UTC)
+	if err != nil {
+		return nil, err
+	}
+	if days := signups.Days(); days > maxCohortSignupDays {
+		return nil, fmt.Errorf("%w: 注册日期范围最多 %d 天，请求了 %d 天", ErrInvalidArgument, maxCohortSignupDays, days)
+	}
+
+	report := &models.CohortReport{
+		From:         signups.From,
+		To:           signups.To,
+		WeekStartDay: q.WeekStart,
+		Weeks:        q.Weeks,
+		Source:       s.analytics.Name(),
+		Statuses:     countedStatuses(s.revenue, q.Statuses),
+		Cohorts:      []models.Cohort{},
+		GeneratedAt:  now.Truncate(time.Second),
+	}
+
+	merchants, err := s.merchants.List()
+	if err != nil {
+		return nil, fmt.Errorf("获取商户列表失败: %w", err)
+	}
+	cohorts := map[string][]cohortMember{}
+	for _, m := range merchants {
+		loc, err := LoadLocation(m.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("商户 %d 的时区无效: %v", m.ID, err)
+		}
+		signupDate := timerange.Today(loc, m.CreatedAt)
+		if day := signupDate.Format(timerange.Layout); day < signups.From || day > signups.To {
+			continue
+		}
+		week := cohortWeekStart(signupDate, q.WeekStart).Format("2006-01-02")
+		cohorts[week] = append(cohorts[week], cohortMember{id: m.ID, today: now.In(loc).Format("2006-01-02")})
+	}
+	if len(cohorts) == 0 {
+		return report, nil
+	}
+
+	weeks := make([]string, 0, len(cohorts))
+	for week := range cohorts {
+		weeks = append(weeks, week)
+	}
+	sort.Strings(weeks)
+	first, _ := timerange.ParseDate(weeks[0])
+	last, _ := timerange.ParseDate(weeks[len(weeks)-1])
+	filter := models.CohortFilter{
+		From:      first.Format("2006-01-02"),
+		To:        last.AddDate(0, 0, 7*(q.Weeks+1)).Format("2006-01-02"),
+		WeekStart: q.WeekStart,
+		Statuses:  report.Statuses,
+	}
+
+	release, err := s.limiter.Acquire(ctx, TenantFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var rows []models.MerchantWeeklyOrders
+	err = s.withQueryTimeout(ctx, func(ctx context.Context) (err error) {
+		if rows, err = s.analytics.WeeklyMerchantOrders(ctx, filter); err != nil {
+			return fmt.Errorf("获取商户周订单数失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	orders := map[int]map[string]int{}
+	for _, row := range rows {
+		if orders[row.MerchantID] == nil {
+			orders[row.MerchantID] = map[string]int{}
+		}
+		orders[row.MerchantID][row.WeekStart] += row.OrderCount
+	}
+
+	for _, week := range weeks {
+		members := cohorts[week]
+		sort.Slice(members, func(i, j int) bool { return members[i].id < members[j].id })
+		cohort := models.Cohort{WeekStart: week, Merchants: len(members), MerchantIDs: make([]int, len(members))}
+		for i, m := range members {
+			cohort.MerchantIDs[i] = m.id
+		}
+		cohort.Weeks = cohortWeeks(week, q.Weeks, members, orders)
+		report.Cohorts = append(report.Cohorts, cohort)
+	}
+	return report, nil
+}
+
+// cohortWeeks 群组从注册周开始的各周订单，遇到所有商户本地日期都还没进入的周时停止
+func cohortWeeks(week string, weeks int, members []cohortMember, orders map[int]map[string]int) []models.CohortWeek {
+	start, _ := timerange.ParseDate(week)
+	result := []models.CohortWeek{}
+	for offset := 0; offset <= weeks; offset++ {
+		w := models.CohortWeek{
+			Offset:    offset,
+			WeekStart: start.AddDate(0, 0, 7*offset).Format("2006-01-02"),
+			WeekEnd:   start.AddDate(0, 0, 7*offset+6).Format("2006-01-02"),
+			Complete:  true,
+		}
+		for _, m := range members {
+			if m.today < w.WeekStart {
+				w.Complete = false
+				continue
+			}
+			w.EligibleMerchants++
+			w.Complete = w.Complete && m.today > w.WeekEnd
+			if n := orders[m.id][w.WeekStart]; n > 0 {
+				w.ActiveMerchants++
+				w.OrderCount += n
+			}
+		}
+		if w.EligibleMerchants == 0 {
+			break
+		}
+		w.MerchantRetentionPercent = percentOf(w.ActiveMerchants, w.EligibleMerchants)
+		if offset == 0 {
+			w.OrderRetentionPercent = percentOf(w.OrderCount, w.OrderCount)
+		} else {
+			w.OrderRetentionPercent = percentOf(w.OrderCount, result[0].OrderCount)
+		}
+		result = append(result, w)
+	}
+	return result
+}
+
+// cohortWeekStart date 所在周的第一天，weekStart 为 ISO 星期
+func cohortWeekStart(date time.Time, weekStart int) time.Time {
+	isoWeekday := (int(date.Weekday())+6)%7 + 1
+	return date.AddDate(0, 0, -((isoWeekday - weekStart + 7) % 7))
+}
+
+// percentOf part 占 total 的百分比，保留一位小数；total 为 0 时返回 nil
+func percentOf(part, total int) *decimal.Decimal {
+	if total == 0 {
+		return nil
+	}
+	p := decimal.NewFromInt(int64(part)).Mul(decimal.NewFromInt(100)).Div(decimal.NewFromInt(int64(total))).Round(1)
+	return &p
+}
+
+// ParseWeekStart 解析周起始日参数：ISO 星期序号 1~7 或英文星期名（mon、monday），为空时返回 0
+func ParseWeekStart(value string) (int, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return 0, nil
+	}
+	for i, name := range []string{"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"} {
+		if value == name || value == name[:3] || value == fmt.Sprint(i+1) {
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: 周起始日应为 1~7 或星期名称（如 monday），得到 %q", ErrInvalidArgument, value)
+}
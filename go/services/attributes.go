@@ -0,0 +1,386 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/repository"
+)
+
+const (
+	// maxAttributeStringLength string 类型取值的最大字符数
+	maxAttributeStringLength = 200
+	// maxAttributeEnumValues enum 类型最多的可选值
+	maxAttributeEnumValues = 100
+	// maxAttributeDescription 属性说明的最大字符数
+	maxAttributeDescription = 500
+	// maxTagFilters 订单列表一次最多的 tag 过滤条件
+	maxTagFilters = 10
+	// groupByTagPrefix 分析接口 group_by 按自定义属性分组的前缀
+	groupByTagPrefix = "tag:"
+)
+
+// attributeKeyPattern 属性键：小写字母开头，小写字母、数字和下划线，最长 50 个字符
+var attributeKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// AttributeDefinitionRequest 新增或修改属性定义的请求
+type AttributeDefinitionRequest struct {
+	Type string `json:"type"`
+	// Values enum 类型必填，其他类型不能填写
+	Values      []string `json:"values"`
+	Description string   `json:"description"`
+	Operator    string   `json:"operator"`
+}
+
+// AttributeValuesRequest 修改商户或订单取值的请求，只修改 Attributes 中出现的键，值为 null 时删除该键
+type AttributeValuesRequest struct {
+	Attributes map[string]json.RawMessage `json:"attributes"`
+	Operator   string                     `json:"operator"`
+}
+
+// AttributeService 租户为商户和订单定义的自定义属性（标签）
+// 租户（X-Tenant-ID）先定义属性的键和取值类型，再为商户或订单设置取值，取值按定义的类型校验后保存；
+// 订单的有效取值为商户的取值叠加订单的取值，订单列表按有效取值过滤（tag=region:emea），分析接口按有效取值分组（group_by=tag:region）。
+// 同一个键可以同时定义在商户和订单上，此时两者的类型必须一致
+type AttributeService struct {
+	attributes repository.AttributeRepository
+}
+
+// NewAttributeService 创建自定义属性服务，使用 PostgreSQL 仓储
+func NewAttributeService(db *database.DB) *AttributeService {
+	return NewAttributeServiceWithRepositories(repository.NewPostgresAttributeRepository(db))
+}
+
+// NewAttributeServiceWithRepositories 使用指定仓储创建自定义属性服务
+func NewAttributeServiceWithRepositories(attributes repository.AttributeRepository) *AttributeService {
+	return &AttributeService{attributes: attributes}
+}
+
+// Definitions ctx 中租户的全部属性定义
+func (s *AttributeService) Definitions(ctx context.Context) ([]models.AttributeDefinition, error) {
+	definitions, err := s.attributes.Definitions(ctx, TenantFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if definitions == nil {
+		definitions = []models.AttributeDefinition{}
+	}
+	return definitions, nil
+}
+
+// SetDefinition 新增或覆盖属性定义
+// 另一个对象上已有同名且类型不同的定义，或已有取值与新定义不兼容时返回 ErrConflict
+func (s *AttributeService) SetDefinition(ctx context.Context, entity, key string, req AttributeDefinitionRequest) (*models.AttributeDefinition, error) {
+	if err := validateAttributeTarget(entity, key); err != nil {
+		return nil, err
+	}
+	definition := &models.AttributeDefinition{
+		Tenant:      TenantFromContext(ctx),
+		Entity:      entity,
+		Key:         key,
+		Type:        strings.TrimSpace(req.Type),
+		Description: strings.TrimSpace(req.Description),
+		CreatedBy:   operatorOrSystem(req.Operator),
+	}
+	switch definition.Type {
+	case models.AttributeTypeString, models.AttributeTypeNumber, models.AttributeTypeBoolean, models.AttributeTypeDate:
+		if len(req.Values) > 0 {
+			return nil, fmt.Errorf("%w: 只有 enum 类型可以指定 values", ErrInvalidArgument)
+		}
+	case models.AttributeTypeEnum:
+		values, err := normalizeEnumValues(req.Values)
+		if err != nil {
+			return nil, err
+		}
+		definition.Values = values
+	default:
+		return nil, fmt.Errorf("%w: type 应为 string、number、boolean、date 或 enum", ErrInvalidArgument)
+	}
+	if len([]rune(definition.Description)) > maxAttributeDescription {
+		return nil, fmt.Errorf("%w: description 不能超过 %d 个字符", ErrInvalidArgument, maxAttributeDescription)
+	}
+
+	definitions, err := s.attributes.Definitions(ctx, definition.Tenant)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range definitions {
+		if d.Key == key && d.Entity != entity && d.Type != definition.Type {
+			return nil, fmt.Errorf("%w: 属性 %s 在%s上的类型为 %s，同名属性的类型必须一致", ErrConflict, key, attributeEntityName(d.Entity), d.Type)
+		}
+	}
+
+	if err := s.attributes.SaveDefinition(ctx, definition); err != nil {
+		return nil, err
+	}
+	return definition, nil
+}
+
+// DeleteDefinition 删除属性定义及所有商户或订单上的该属性取值
+func (s *AttributeService) DeleteDefinition(ctx context.Context, entity, key string) error {
+	if err := validateAttributeTarget(entity, key); err != nil {
+		return err
+	}
+	return s.attributes.DeleteDefinition(ctx, TenantFromContext(ctx), entity, key)
+}
+
+// Attributes 商户或订单的取值，订单同时返回叠加商户取值后的有效取值
+func (s *AttributeService) Attributes(ctx context.Context, entity string, id int) (*models.EntityAttributes, error) {
+	if err := validateAttributeTarget(entity, ""); err != nil {
+		return nil, err
+	}
+	return s.attributes.Attributes(ctx, TenantFromContext(ctx), entity, id)
+}
+
+// SetAttributes 按定义校验并修改商户或订单的取值，未定义的键返回 ErrInvalidArgument
+func (s *AttributeService) SetAttributes(ctx context.Context, entity string, id int, req AttributeValuesRequest) (*models.EntityAttributes, error) {
+	if err := validateAttributeTarget(entity, ""); err != nil {
+		return nil, err
+	}
+	if len(req.Attributes) == 0 {
+		return nil, fmt.Errorf("%w: attributes 不能为空", ErrInvalidArgument)
+	}
+	tenant := TenantFromContext(ctx)
+	definitions, err := s.definitionsOf(ctx, tenant, entity)
+	if err != nil {
+		return nil, err
+	}
+
+	set := make(map[string]json.RawMessage)
+	var remove []string
+	for key, raw := range req.Attributes {
+		definition, ok := definitions[key]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s属性 %s 未定义", ErrInvalidArgument, attributeEntityName(entity), key)
+		}
+		if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+			remove = append(remove, key)
+			continue
+		}
+		value, err := normalizeAttributeValue(definition, raw)
+		if err != nil {
+			return nil, err
+		}
+		set[key] = value
+	}
+	sort.Strings(remove)
+	return s.attributes.SaveAttributes(ctx, tenant, entity, id, set, remove, operatorOrSystem(req.Operator))
+}
+
+// ParseTagFilters 把 tag=key:value 形式的过滤条件转换为订单有效取值需要包含的 JSON 对象
+// 键可以是商户或订单的属性，值按定义的类型解析；没有条件时返回 nil
+func (s *AttributeService) ParseTagFilters(ctx context.Context, tags []string) (json.RawMessage, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	if len(tags) > maxTagFilters {
+		return nil, fmt.Errorf("%w: tag 最多 %d 个", ErrInvalidArgument, maxTagFilters)
+	}
+	definitions, err := s.definitionsOf(ctx, TenantFromContext(ctx), "")
+	if err != nil {
+		return nil, err
+	}
+
+	filter := make(map[string]json.RawMessage)
+	for _, tag := range tags {
+		key, text, ok := strings.Cut(tag, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%w: tag 应为 键:值 格式，如 region:emea", ErrInvalidArgument)
+		}
+		definition, ok := definitions[key]
+		if !ok {
+			return nil, fmt.Errorf("%w: 属性 %s 未定义", ErrInvalidArgument, key)
+		}
+		value, err := parseAttributeText(definition, strings.TrimSpace(text))
+		if err != nil {
+			return nil, err
+		}
+		if existing, ok := filter[key]; ok && !bytes.Equal(existing, value) {
+			return nil, fmt.Errorf("%w: 属性 %s 只能指定一个取值", ErrInvalidArgument, key)
+		}
+		filter[key] = value
+	}
+	body, err := json.Marshal(filter)
+	if err != nil {
+		return nil, fmt.Errorf("编码 tag 过滤条件失败: %w", err)
+	}
+	return body, nil
+}
+
+// GroupAnalysis 按 group_by=tag:<键> 为分析结果补充按属性取值分组的合计，日期、订单状态和汇总时区与 analysis 一致
+// 分组统计扫描分析视图，不包括已归档的订单
+func (s *AttributeService) GroupAnalysis(ctx context.Context, groupBy string, analysis *models.AnalysisData) error {
+	key, ok := strings.CutPrefix(groupBy, groupByTagPrefix)
+	if !ok || key == "" {
+		return fmt.Errorf("%w: group_by 应为 tag:<属性键>，如 tag:region", ErrInvalidArgument)
+	}
+	tenant := TenantFromContext(ctx)
+	definitions, err := s.definitionsOf(ctx, tenant, "")
+	if err != nil {
+		return err
+	}
+	if _, ok := definitions[key]; !ok {
+		return fmt.Errorf("%w: 属性 %s 未定义", ErrInvalidArgument, key)
+	}
+
+	filter := models.AnalysisFilter{
+		LocalDate:         analysis.Date,
+		Statuses:          analysis.Statuses,
+		AggregateTimezone: analysis.AggregateTimezone,
+	}
+	if analysis.WindowStartUTC != nil && analysis.WindowEndUTC != nil {
+		filter.Start, filter.End = *analysis.WindowStartUTC, *analysis.WindowEndUTC
+	}
+	groups, err := s.attributes.GroupTotals(ctx, tenant, key, filter)
+	if err != nil {
+		return err
+	}
+	if groups == nil {
+		groups = []models.AttributeGroupTotal{}
+	}
+	analysis.GroupBy = groupBy
+	analysis.Groups = groups
+	return nil
+}
+
+// definitionsOf 租户在 entity 上的属性定义，entity 为空时返回两个对象的定义（同名属性类型一致）
+func (s *AttributeService) definitionsOf(ctx context.Context, tenant, entity string) (map[string]models.AttributeDefinition, error) {
+	definitions, err := s.attributes.Definitions(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+	byKey := make(map[string]models.AttributeDefinition, len(definitions))
+	for _, d := range definitions {
+		if entity != "" && d.Entity != entity {
+			continue
+		}
+		// 两个对象上的同名 enum 可选值可能不同，取值按并集校验
+		if existing, ok := byKey[d.Key]; ok && d.Type == models.AttributeTypeEnum {
+			d.Values = append(append([]string{}, existing.Values...), d.Values...)
+		}
+		byKey[d.Key] = d
+	}
+	return byKey, nil
+}
+
+// validateAttributeTarget 校验对象和属性键，key 为空时只校验对象
+func validateAttributeTarget(entity, key string) error {
+	if entity != models.AttributeEntityMerchant && entity != models.AttributeEntityOrder {
+		return fmt.Errorf("%w: 对象应为 merchant 或 order", ErrInvalidArgument)
+	}
+	if key != "" && !attributeKeyPattern.MatchString(key) {
+		return fmt.Errorf("%w: 属性键应以小写字母开头，只包含小写字母、数字和下划线，最长 50 个字符", ErrInvalidArgument)
+	}
+	return nil
+}
+
+// normalizeEnumValues 去掉首尾空白，拒绝空值和重复值
+func normalizeEnumValues(values []string) ([]string, error) {
+	if len(values) == 0 || len(values) > maxAttributeEnumValues {
+		return nil, fmt.Errorf("%w: enum 类型的 values 应有 1~%d 个", ErrInvalidArgument, maxAttributeEnumValues)
+	}
+	seen := make(map[string]bool, len(values))
+	normalized := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" || len([]rune(value)) > maxAttributeStringLength {
+			return nil, fmt.Errorf("%w: enum 的取值不能为空且不能超过 %d 个字符", ErrInvalidArgument, maxAttributeStringLength)
+		}
+		if seen[value] {
+			return nil, fmt.Errorf("%w: enum 的取值 %s 重复", ErrInvalidArgument, value)
+		}
+		seen[value] = true
+		normalized = append(normalized, value)
+	}
+	return normalized, nil
+}
+
+// normalizeAttributeValue 按定义校验 JSON 取值并返回规范形式：数字不带多余的零，日期为 YYYY-MM-DD
+func normalizeAttributeValue(d models.AttributeDefinition, raw json.RawMessage) (json.RawMessage, error) {
+	switch d.Type {
+	case models.AttributeTypeNumber:
+		var n float64
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return nil, fmt.Errorf("%w: 属性 %s 的取值应为数字", ErrInvalidArgument, d.Key)
+		}
+		return numberAttribute(d.Key, n)
+	case models.AttributeTypeBoolean:
+		var b bool
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return nil, fmt.Errorf("%w: 属性 %s 的取值应为 true 或 false", ErrInvalidArgument, d.Key)
+		}
+		return json.RawMessage(strconv.FormatBool(b)), nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err != nil {
+		return nil, fmt.Errorf("%w: 属性 %s 的取值应为字符串", ErrInvalidArgument, d.Key)
+	}
+	return parseAttributeText(d, strings.TrimSpace(text))
+}
+
+// parseAttributeText 按定义把文本形式的取值转换为 JSON 取值，用于 tag 过滤条件和字符串类的取值
+func parseAttributeText(d models.AttributeDefinition, text string) (json.RawMessage, error) {
+	switch d.Type {
+	case models.AttributeTypeNumber:
+		n, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: 属性 %s 的取值应为数字", ErrInvalidArgument, d.Key)
+		}
+		return numberAttribute(d.Key, n)
+	case models.AttributeTypeBoolean:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, fmt.Errorf("%w: 属性 %s 的取值应为 true 或 false", ErrInvalidArgument, d.Key)
+		}
+		return json.RawMessage(strconv.FormatBool(b)), nil
+	case models.AttributeTypeDate:
+		date, err := time.Parse("2006-01-02", text)
+		if err != nil {
+			return nil, fmt.Errorf("%w: 属性 %s 的取值应为 YYYY-MM-DD 格式的日期", ErrInvalidArgument, d.Key)
+		}
+		text = date.Format("2006-01-02")
+	case models.AttributeTypeEnum:
+		allowed := false
+		for _, value := range d.Values {
+			if value == text {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, fmt.Errorf("%w: 属性 %s 的取值应为 %s 之一", ErrInvalidArgument, d.Key, strings.Join(d.Values, "、"))
+		}
+	default:
+		if text == "" || len([]rune(text)) > maxAttributeStringLength {
+			return nil, fmt.Errorf("%w: 属性 %s 的取值不能为空且不能超过 %d 个字符", ErrInvalidArgument, d.Key, maxAttributeStringLength)
+		}
+	}
+	return json.Marshal(text)
+}
+
+// numberAttribute 数字取值的规范 JSON 形式，拒绝 NaN 和无穷大
+func numberAttribute(key string, n float64) (json.RawMessage, error) {
+	if math.IsNaN(n) || math.IsInf(n, 0) {
+		return nil, fmt.Errorf("%w: 属性 %s 的取值应为有限的数字", ErrInvalidArgument, key)
+	}
+	return json.RawMessage(strconv.FormatFloat(n, 'f', -1, 64)), nil
+}
+
+// attributeEntityName 错误信息中的对象名称
+func attributeEntityName(entity string) string {
+	if entity == models.AttributeEntityOrder {
+		return "订单"
+	}
+	return "商户"
+}
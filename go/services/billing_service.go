@@ -0,0 +1,175 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/repository"
+	"timezone-saas-demo/timerange"
+)
+
+// maxBillingPeriods 单次计算的最大周期数，避免错误的截止日期导致生成过多记录
+const maxBillingPeriods = 1000
+
+// BillingService 订阅计费服务
+// 计费周期锚定在商户本地时区：每期从签约日对应日期的本地零点开始，到下一期开始为止
+type BillingService struct {
+	merchants repository.MerchantRepository
+	billing   repository.BillingRepository
+}
+
+// NewBillingService 创建计费服务，使用 PostgreSQL 仓储
+func NewBillingService(db *database.DB) *BillingService {
+	return &BillingService{
+		merchants: repository.NewPostgresMerchantRepository(db),
+		billing:   repository.NewPostgresBillingRepository(db),
+	}
+}
+
+// NewBillingServiceWithRepositories 使用指定仓储创建计费服务
+func NewBillingServiceWithRepositories(merchants repository.MerchantRepository, billing repository.BillingRepository) *BillingService {
+	return &BillingService{
+		merchants: merchants,
+		billing:   billing,
+	}
+}
+
+// GetPeriods 计算并保存商户截至 through（本地日期，包含当天开始的周期）的所有计费周期
+// through 为空时计算到当前时刻所在的周期；已保存的周期不会被重新计算覆盖
+func (s *BillingService) GetPeriods(merchantID int, through string) (*models.BillingPeriods, error) {
+	merchant, err := s.merchants.Get(merchantID)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := LoadLocation(merchant.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := s.billing.Subscription(merchantID)
+	if errors.Is(err, repository.ErrNotFound) {
+		sub = DefaultSubscription(*merchant, loc)
+	} else if err != nil {
+		return nil, err
+	}
+
+	end := time.Now()
+	if through != "" {
+		day, err := timerange.ParseDate(through)
+		if err != nil {
+			return nil, fmt.Errorf("%w: 截止日期格式错误，应为 YYYY-MM-DD", ErrInvalidArgument)
+		}
+		end = timerange.StartOfDay(day.AddDate(0, 0, 1), loc).Add(-time.Nanosecond)
+	}
+
+	periods, err := ComputeBillingPeriods(*sub, merchant.Timezone, end)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.billing.SavePeriods(periods); err != nil {
+		return nil, err
+	}
+
+	saved, err := s.billing.ListPeriods(merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if saved == nil {
+		saved = []models.BillingPeriod{}
+	}
+
+	return &models.BillingPeriods{
+		MerchantID:   merchantID,
+		Timezone:     merchant.Timezone,
+		Subscription: *sub,
+		Periods:      saved,
+	}, nil
+}
+
+// DefaultSubscription 未配置订阅时的默认规则：按月计费，以商户创建日（本地日期）为签约日
+func DefaultSubscription(merchant models.Merchant, loc *time.Location) *models.Subscription {
+	return &models.Subscription{
+		MerchantID:    merchant.ID,
+		PlanCode:      "standard",
+		IntervalUnit:  "month",
+		IntervalCount: 1,
+		AnchorDate:    merchant.CreatedAt.In(loc).Format("2006-01-02"),
+		Status:        "active",
+		CreatedAt:     merchant.CreatedAt,
+	}
+}
+
+// ComputeBillingPeriods 计算从签约日开始、直到包含 through 时刻的所有计费周期
+// 月末锚定：签约日为 31 号时，短月在当月最后一天开始，之后的月份仍回到 31 号；
+// 每期从本地日期的第一个时刻开始（timerange.StartOfDay），零点因夏令时不存在时从当天第一个存在的时刻（如 01:00）开始，
+// 前一期在该时刻结束，不会把前一天的最后一小时计入新的一期
+func ComputeBillingPeriods(sub models.Subscription, timezone string, through time.Time) ([]models.BillingPeriod, error) {
+	loc, err := LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+	anchor, err := time.Parse("2006-01-02", sub.AnchorDate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 签约日格式错误: %s", ErrInvalidArgument, sub.AnchorDate)
+	}
+	if sub.IntervalCount <= 0 {
+		return nil, fmt.Errorf("%w: 计费间隔必须大于 0", ErrInvalidArgument)
+	}
+
+	periodStart := func(n int) (time.Time, error) {
+		step := n * sub.IntervalCount
+		var y, m, d int
+		switch sub.IntervalUnit {
+		case "week":
+			date := anchor.AddDate(0, 0, 7*step)
+			y, m, d = date.Year(), int(date.Month()), date.Day()
+		case "month":
+			y, m, d = clampDay(anchor.Year(), int(anchor.Month())+step, anchor.Day())
+		case "year":
+			y, m, d = clampDay(anchor.Year()+step, int(anchor.Month()), anchor.Day())
+		default:
+			return time.Time{}, fmt.Errorf("%w: 不支持的计费间隔 %q", ErrInvalidArgument, sub.IntervalUnit)
+		}
+		return timerange.StartOfDay(time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC), loc), nil
+	}
+
+	var periods []models.BillingPeriod
+	start, err := periodStart(0)
+	if err != nil {
+		return nil, err
+	}
+	for n := 0; !start.After(through); n++ {
+		if n >= maxBillingPeriods {
+			return nil, fmt.Errorf("%w: 计费周期数超过 %d", ErrInvalidArgument, maxBillingPeriods)
+		}
+		end, err := periodStart(n + 1)
+		if err != nil {
+			return nil, err
+		}
+		periods = append(periods, models.BillingPeriod{
+			MerchantID:    sub.MerchantID,
+			Sequence:      n + 1,
+			Timezone:      timezone,
+			StartLocal:    start.Format("2006-01-02 15:04:05"),
+			EndLocal:      end.Format("2006-01-02 15:04:05"),
+			StartUTC:      start.UTC(),
+			EndUTC:        end.UTC(),
+			DurationHours: end.Sub(start).Hours(),
+		})
+		start = end
+	}
+	return periods, nil
+}
+
+// clampDay 规范化年月，日期超过当月天数时取当月最后一天
+func clampDay(year, month, day int) (int, int, int) {
+	first := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return first.Year(), int(first.Month()), day
+}
@@ -0,0 +1,96 @@
+package services_test
+
+import (
+	"testing"
+	"time"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/services"
+	"timezone-saas-demo/testsupport"
+)
+
+// TestComputeBillingPeriodsMidnightGap 周期开始日零点因夏令时不存在时，新周期从当天第一个存在的时刻开始，
+// 不能回退到前一天 23:00
+func TestComputeBillingPeriodsMidnightGap(t *testing.T) {
+	cases := []struct {
+		name     string
+		timezone string
+		unit     string
+		anchor   string
+		through  time.Time
+		// 第二期的开始时刻，即第一期的结束时刻
+		wantLocal string
+		wantUTC   time.Time
+		wantHours float64
+	}{
+		{
+			// 2024-09-08 00:00 -04 跳到 01:00 -03
+			name: "圣地亚哥按月", timezone: "America/Santiago", unit: "month", anchor: "2024-08-08",
+			through:   time.Date(2024, 9, 10, 0, 0, 0, 0, time.UTC),
+			wantLocal: "2024-09-08 01:00:00", wantUTC: time.Date(2024, 9, 8, 4, 0, 0, 0, time.UTC), wantHours: 31 * 24,
+		},
+		{
+			// 2024-03-10 00:00 CST 跳到 01:00 CDT
+			name: "哈瓦那按周", timezone: "America/Havana", unit: "week", anchor: "2024-03-03",
+			through:   time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC),
+			wantLocal: "2024-03-10 01:00:00", wantUTC: time.Date(2024, 3, 10, 5, 0, 0, 0, time.UTC), wantHours: 7 * 24,
+		},
+		{
+			// 2018-11-04 00:00 -03 跳到 01:00 -02
+			name: "圣保罗按周", timezone: "America/Sao_Paulo", unit: "week", anchor: "2018-10-28",
+			through:   time.Date(2018, 11, 6, 0, 0, 0, 0, time.UTC),
+			wantLocal: "2018-11-04 01:00:00", wantUTC: time.Date(2018, 11, 4, 3, 0, 0, 0, time.UTC), wantHours: 7 * 24,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sub := models.Subscription{MerchantID: 1, PlanCode: "standard", IntervalUnit: c.unit, IntervalCount: 1, AnchorDate: c.anchor, Status: "active"}
+			periods, err := services.ComputeBillingPeriods(sub, c.timezone, c.through)
+			if err != nil {
+				t.Fatalf("计算计费周期失败: %v", err)
+			}
+			if len(periods) != 2 {
+				t.Fatalf("计费周期数 = %d, 期望 2: %+v", len(periods), periods)
+			}
+			first, second := periods[0], periods[1]
+			if first.EndLocal != c.wantLocal || !first.EndUTC.Equal(c.wantUTC) {
+				t.Errorf("第一期结束 = %s (%s), 期望 %s (%s)", first.EndLocal, first.EndUTC, c.wantLocal, c.wantUTC)
+			}
+			if second.StartLocal != c.wantLocal || !second.StartUTC.Equal(c.wantUTC) {
+				t.Errorf("第二期开始 = %s (%s), 期望 %s (%s)", second.StartLocal, second.StartUTC, c.wantLocal, c.wantUTC)
+			}
+			if first.DurationHours != c.wantHours {
+				t.Errorf("第一期时长 = %v 小时, 期望 %v", first.DurationHours, c.wantHours)
+			}
+		})
+	}
+}
+
+// TestGetPeriodsThroughBeforeGap 截止日期的次日零点不存在时，截止时刻是次日 01:00 之前，
+// 不能把截止日最后一小时排除在外
+func TestGetPeriodsThroughBeforeGap(t *testing.T) {
+	// 截止 09-07 只包含第一期；截止 09-08 包含从 09-08 01:00 开始的第二期
+	cases := []struct {
+		through string
+		want    int
+	}{
+		{"2024-09-07", 1},
+		{"2024-09-08", 2},
+	}
+	for _, c := range cases {
+		fakes := testsupport.NewFakes()
+		fakes.Merchants.Add(testsupport.NewMerchant(1, "圣地亚哥零售", "America/Santiago", "智利", "圣地亚哥"))
+		fakes.Billing.SetSubscription(models.Subscription{MerchantID: 1, PlanCode: "standard", IntervalUnit: "week", IntervalCount: 1, AnchorDate: "2024-09-01", Status: "active"})
+
+		result, err := fakes.BillingService().GetPeriods(1, c.through)
+		if err != nil {
+			t.Fatalf("截止 %s 计算计费周期失败: %v", c.through, err)
+		}
+		if len(result.Periods) != c.want {
+			t.Fatalf("截止 %s 计费周期数 = %d, 期望 %d", c.through, len(result.Periods), c.want)
+		}
+		if got := result.Periods[0].EndLocal; got != "2024-09-08 01:00:00" {
+			t.Errorf("截止 %s 第一期结束 = %s, 期望 2024-09-08 01:00:00", c.through, got)
+		}
+	}
+}
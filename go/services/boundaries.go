@@ -0,0 +1,89 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/timerange"
+)
+
+// boundaryLookahead 查找下一个营业开始/结束时间的范围，覆盖一个完整的周末
+const boundaryLookahead = 8 * 24 * time.Hour
+
+// GetBoundaries 获取商户在指定时刻之后的本地时间边界
+// atStr 为 RFC3339 时间（可带任意偏移）
+func (s *TimezoneService) GetBoundaries(merchantID int, atStr string) (*models.MerchantBoundaries, error) {
+	t, err := time.Parse(time.RFC3339, atStr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 时间格式错误: %v", ErrInvalidArgument, err)
+	}
+
+	merchant, err := s.merchants.Get(merchantID)
+	if err != nil {
+		return nil, err
+	}
+	return ComputeBoundaries(*merchant, t.UTC())
+}
+
+// ComputeBoundaries 纯 Go 计算商户的下一个本地午夜、营业开始/结束、周一零点和下月一日零点
+// 本地零点因夏令时不存在时（如部分时区在午夜切换），按 time.Date 的规则顺延到切换后的时刻
+func ComputeBoundaries(merchant models.Merchant, at time.Time) (*models.MerchantBoundaries, error) {
+	loc, err := LoadLocation(merchant.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	hours, err := MerchantBusinessHours(merchant)
+	if err != nil {
+		return nil, err
+	}
+
+	local := at.In(loc)
+	boundary := func(t time.Time) models.Boundary {
+		t = t.In(loc)
+		_, offset := t.Zone()
+		return models.Boundary{
+			UTC:          t.UTC(),
+			Local:        t.Format("2006-01-02 15:04:05"),
+			Offset:       formatOffset(offset),
+			SecondsUntil: int64(t.Sub(at) / time.Second),
+		}
+	}
+
+	result := &models.MerchantBoundaries{
+		MerchantID:    merchant.ID,
+		MerchantName:  merchant.Name,
+		Timezone:      merchant.Timezone,
+		BusinessHours: hours.String(),
+		AtUTC:         at,
+		AtLocal:       local.Format("2006-01-02 15:04:05"),
+		IsOpen:        hours.IsOpen(local),
+	}
+
+	result.NextMidnight = boundary(nextLocalMidnight(local, 1))
+	daysToMonday := (int(time.Monday) - int(local.Weekday()) + 7) % 7
+	if daysToMonday == 0 {
+		daysToMonday = 7
+	}
+	result.NextWeekStart = boundary(nextLocalMidnight(local, daysToMonday))
+	result.NextMonthStart = boundary(timerange.StartOfDay(time.Date(local.Year(), local.Month()+1, 1, 0, 0, 0, 0, time.UTC), loc))
+
+	// 从前一天开始展开营业区间，才能拿到当前所在区间的结束时间
+	for _, r := range hours.Intervals(at.Add(-24*time.Hour), at.Add(boundaryLookahead), loc) {
+		if r.Start.After(at) && result.NextOpen == nil {
+			b := boundary(r.Start)
+			result.NextOpen = &b
+		}
+		if r.End.After(at) && result.NextClose == nil {
+			b := boundary(r.End)
+			result.NextClose = &b
+		}
+	}
+	return result, nil
+}
+
+// nextLocalMidnight 返回 days 天后的本地零点，零点因夏令时不存在时为当天第一个存在的时刻
+func nextLocalMidnight(local time.Time, days int) time.Time {
+	date := time.Date(local.Year(), local.Month(), local.Day()+days, 0, 0, 0, 0, time.UTC)
+	return timerange.StartOfDay(date, local.Location())
+}
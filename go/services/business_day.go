@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"timezone-saas-demo/models"
+)
+
+// businessDayMerchant 参与全球营业日统计的商户，open 为 UTC 日期内的营业区间
+type businessDayMerchant struct {
+	id       int
+	timezone string
+	region   string
+	open     []TimeRange
+}
+
+// openDuring 商户在 r 内是否有营业时间（不必覆盖整个区间）
+func (m businessDayMerchant) openDuring(r TimeRange) bool {
+	for _, o := range m.open {
+		if o.Start.Before(r.End) && o.End.After(r.Start) {
+			return true
+		}
+	}
+	return false
+}
+
+// TimezoneRegion 时区所属地区：IANA 时区名的第一段，如 Asia/Tokyo 为 Asia，America/Argentina/Salta 为 America；
+// 没有分段的名称（UTC 等）归为 Etc
+func TimezoneRegion(timezone string) string {
+	if i := strings.Index(timezone, "/"); i > 0 {
+		return timezone[:i]
+	}
+	return "Etc"
+}
+
+// GlobalBusinessDay 全球营业日报告：UTC 日期 date 的每个 UTC 小时有多少商户时区处于营业时间，
+// 以及各地区的订单落在商户营业时间内外的比例，用于按时区接力安排客服（follow-the-sun）
+// 营业时间按商户配置和周末计算，某小时内只要有一部分处于营业时间即算营业，非整点偏移的时区因此可能跨两个小时；
+// 订单是否在营业时间内取订单的 is_business_hour，date 为空时为当前 UTC 日期
+func (s *TimezoneService) GlobalBusinessDay(ctx context.Context, date string, statuses []string) (*models.GlobalBusinessDayReport, error) {
+	now := time.Now().UTC()
+	day, err := localDay(date, time.UTC, now)
+	if err != nil {
+		return nil, err
+	}
+	dayStart, dayEnd := day.Start, day.End
+
+	merchants, err := s.merchants.List()
+	if err != nil {
+		return nil, fmt.Errorf("获取商户列表失败: %w", err)
+	}
+	participants := make([]businessDayMerchant, 0, len(merchants))
+	for _, m := range merchants {
+		loc, err := LoadLocation(m.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("商户 %d 的时区无效: %v", m.ID, err)
+		}
+		hours, err := MerchantBusinessHours(m)
+		if err != nil {
+			return nil, fmt.Errorf("商户 %d 的营业时间无效: %v", m.ID, err)
+		}
+		participants = append(participants, businessDayMerchant{
+			id:       m.ID,
+			timezone: m.Timezone,
+			region:   TimezoneRegion(m.Timezone),
+			open:     hours.Intervals(dayStart, dayEnd, loc),
+		})
+	}
+
+	filter := models.UTCHourFilter{Start: dayStart, End: dayEnd, Statuses: countedStatuses(s.revenue, statuses)}
+	release, err := s.limiter.Acquire(ctx, TenantFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	var rows []models.UTCHourTimezoneOrders
+	err = s.withQueryTimeout(ctx, func(ctx context.Context) (err error) {
+		if rows, err = s.analytics.UTCHourlyOrders(ctx, filter); err != nil {
+			return fmt.Errorf("获取 UTC 小时订单数失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.GlobalBusinessDayReport{
+		Date:        day.From,
+		Source:      s.analytics.Name(),
+		Statuses:    filter.Statuses,
+		Hours:       make([]models.BusinessDayHour, 24),
+		GeneratedAt: now.Truncate(time.Second),
+	}
+
+	// 地区列表包括商户所在的地区和订单出现的地区，按名称排序，每个小时都返回全部地区
+	timezones := map[string]map[string]bool{}
+	merchantCount := map[string]int{}
+	addTimezone := func(timezone string) {
+		region := TimezoneRegion(timezone)
+		if timezones[region] == nil {
+			timezones[region] = map[string]bool{}
+		}
+		timezones[region][timezone] = true
+	}
+	for _, p := range participants {
+		addTimezone(p.timezone)
+		merchantCount[p.region]++
+	}
+	for _, row := range rows {
+		addTimezone(row.Timezone)
+	}
+	regions := make([]string, 0, len(timezones))
+	for region, zones := range timezones {
+		regions = append(regions, region)
+		report.TotalTimezones += len(zones)
+	}
+	sort.Strings(regions)
+	report.TotalMerchants = len(participants)
+	regionIndex := map[string]int{}
+	for i, region := range regions {
+		regionIndex[region] = i
+	}
+	newRegions := func() []models.BusinessDayRegion {
+		result := make([]models.BusinessDayRegion, len(regions))
+		for i, region := range regions {
+			result[i] = models.BusinessDayRegion{
+				Region:         region,
+				TotalTimezones: len(timezones[region]),
+				TotalMerchants: merchantCount[region],
+			}
+		}
+		return result
+	}
+	report.Regions = newRegions()
+
+	dayOpenZones, dayOpenMerchants := map[string]bool{}, map[int]bool{}
+	for h := range report.Hours {
+		start := dayStart.Add(time.Duration(h) * time.Hour)
+		hour := &report.Hours[h]
+		hour.HourUTC, hour.StartUTC = h, start
+		hour.OpenTimezones = []string{}
+		hour.Regions = newRegions()
+
+		openZones := map[string]bool{}
+		for _, p := range participants {
+			if !p.openDuring(TimeRange{Start: start, End: start.Add(time.Hour)}) {
+				continue
+			}
+			hour.OpenMerchants++
+			region := &hour.Regions[regionIndex[p.region]]
+			region.OpenMerchants++
+			if !openZones[p.timezone] {
+				openZones[p.timezone] = true
+				region.OpenTimezones++
+				hour.OpenTimezones = append(hour.OpenTimezones, p.timezone)
+			}
+			dayOpenZones[p.timezone], dayOpenMerchants[p.id] = true, true
+		}
+		sort.Strings(hour.OpenTimezones)
+	}
+	for _, p := range participants {
+		if dayOpenMerchants[p.id] {
+			report.Regions[regionIndex[p.region]].OpenMerchants++
+		}
+	}
+	for timezone := range dayOpenZones {
+		report.Regions[regionIndex[TimezoneRegion(timezone)]].OpenTimezones++
+	}
+
+	for _, row := range rows {
+		h := int(row.HourStart.Sub(dayStart) / time.Hour)
+		if h < 0 || h >= len(report.Hours) {
+			continue
+		}
+		hour := &report.Hours[h]
+		i := regionIndex[TimezoneRegion(row.Timezone)]
+		for _, orders := range []*models.BusinessDayOrders{&report.BusinessDayOrders, &report.Regions[i].BusinessDayOrders, &hour.BusinessDayOrders, &hour.Regions[i].BusinessDayOrders} {
+			orders.OrderCount += row.OrderCount
+			if row.IsBusinessHour {
+				orders.Inside += row.OrderCount
+			} else {
+				orders.Outside += row.OrderCount
+			}
+		}
+	}
+
+	finish := func(orders *models.BusinessDayOrders) {
+		orders.InsidePercent = percentOf(orders.Inside, orders.OrderCount)
+	}
+	finish(&report.BusinessDayOrders)
+	for i := range report.Regions {
+		finish(&report.Regions[i].BusinessDayOrders)
+	}
+	for h := range report.Hours {
+		finish(&report.Hours[h].BusinessDayOrders)
+		for i := range report.Hours[h].Regions {
+			finish(&report.Hours[h].Regions[i].BusinessDayOrders)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/timerange"
+)
+
+// BusinessHours 商户营业时间，以本地时间当天的分钟数表示
+// End 不晚于 Start 时表示跨午夜营业（如 22:00-06:00），两者相等表示全天营业
+// Weekend 中的日期不营业
+type BusinessHours struct {
+	Start   int
+	End     int
+	Weekend Weekend
+}
+
+// DefaultBusinessHours 未配置营业时间时使用的默认值，与 dim_merchant 的列默认值一致
+var DefaultBusinessHours = BusinessHours{Start: 9 * 60, End: 19 * 60, Weekend: DefaultWeekend}
+
+// ParseBusinessHours 解析 HH:MM 格式的营业时间，两者都为空时返回默认值；周末为周六周日
+func ParseBusinessHours(start, end string) (BusinessHours, error) {
+	if start == "" && end == "" {
+		return DefaultBusinessHours, nil
+	}
+	s, err := parseClock(start)
+	if err != nil {
+		return BusinessHours{}, err
+	}
+	e, err := parseClock(end)
+	if err != nil {
+		return BusinessHours{}, err
+	}
+	return BusinessHours{Start: s, End: e, Weekend: DefaultWeekend}, nil
+}
+
+// MerchantBusinessHours 获取商户的营业时间，周末按商户配置
+func MerchantBusinessHours(merchant models.Merchant) (BusinessHours, error) {
+	hours, err := ParseBusinessHours(merchant.BusinessHoursStart, merchant.BusinessHoursEnd)
+	if err != nil {
+		return BusinessHours{}, fmt.Errorf("商户 %d 营业时间配置错误: %w", merchant.ID, err)
+	}
+	if hours.Weekend, err = MerchantWeekend(merchant); err != nil {
+		return BusinessHours{}, err
+	}
+	return hours, nil
+}
+
+// parseClock 解析 HH:MM 或 HH:MM:SS，返回当天的分钟数
+func parseClock(value string) (int, error) {
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Hour()*60 + t.Minute(), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: 无效的时间 %q，应为 HH:MM", ErrInvalidArgument, value)
+}
+
+// Overnight 是否跨午夜营业
+func (b BusinessHours) Overnight() bool {
+	return b.End <= b.Start
+}
+
+// String 格式化为 09:00-19:00
+func (b BusinessHours) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", b.Start/60, b.Start%60, b.End/60, b.End%60)
+}
+
+// IsOpen 判断本地时间是否处于营业时间
+// 与 dws_orders_analysis_view 的 is_business_hour 一致：周末不营业，
+// 跨午夜营业时按时刻本身所在的星期判断
+func (b BusinessHours) IsOpen(local time.Time) bool {
+	if b.Weekend.Contains(local.Weekday()) {
+		return false
+	}
+	minute := local.Hour()*60 + local.Minute()
+	if b.Overnight() {
+		return minute >= b.Start || minute < b.End
+	}
+	return minute >= b.Start && minute < b.End
+}
+
+// Intervals 返回 [from, to) 范围内在 loc 时区的营业区间，相邻区间会被合并
+// 按本地日历日期逐天展开，每天从 timerange.StartOfDay 开始；营业时间按 timerange.Wall 换算，
+// 夏令时跳过的时刻顺延，重复的时刻取第一次出现
+func (b BusinessHours) Intervals(from, to time.Time, loc *time.Location) []TimeRange {
+	var ranges []TimeRange
+
+	first := from.In(loc)
+	date := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, time.UTC)
+	for day := timerange.StartOfDay(date, loc); day.Before(to); {
+		nextDate := date.AddDate(0, 0, 1)
+		next := timerange.StartOfDay(nextDate, loc)
+		if !b.Weekend.Contains(date.Weekday()) {
+			at := func(minute int) time.Time {
+				return timerange.Wall(date, minute, loc)
+			}
+			if b.Overnight() {
+				if b.End > 0 {
+					ranges = append(ranges, TimeRange{Start: day, End: at(b.End)})
+				}
+				ranges = append(ranges, TimeRange{Start: at(b.Start), End: next})
+			} else {
+				ranges = append(ranges, TimeRange{Start: at(b.Start), End: at(b.End)})
+			}
+		}
+		date, day = nextDate, next
+	}
+
+	return clipRanges(mergeRanges(ranges), from, to)
+}
+
+// TimeRange 左闭右开的时间区间
+type TimeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Duration 区间长度
+func (r TimeRange) Duration() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+// Covers 判断区间是否完整覆盖 other
+func (r TimeRange) Covers(other TimeRange) bool {
+	return !other.Start.Before(r.Start) && !other.End.After(r.End)
+}
+
+// mergeRanges 排序并合并重叠或相邻的区间
+func mergeRanges(ranges []TimeRange) []TimeRange {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start.Before(ranges[j].Start) })
+
+	var merged []TimeRange
+	for _, r := range ranges {
+		if !r.Start.Before(r.End) {
+			continue
+		}
+		if n := len(merged); n > 0 && !r.Start.After(merged[n-1].End) {
+			if r.End.After(merged[n-1].End) {
+				merged[n-1].End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// clipRanges 将区间裁剪到 [from, to)
+func clipRanges(ranges []TimeRange, from, to time.Time) []TimeRange {
+	var clipped []TimeRange
+	for _, r := range ranges {
+		if r.Start.Before(from) {
+			r.Start = from
+		}
+		if r.End.After(to) {
+			r.End = to
+		}
+		if r.Start.Before(r.End) {
+			clipped = append(clipped, r)
+		}
+	}
+	return clipped
+}
+
+// intersectRanges 求两组有序区间的交集
+func intersectRanges(a, b []TimeRange) []TimeRange {
+	var result []TimeRange
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		start, end := a[i].Start, a[i].End
+		if b[j].Start.After(start) {
+			start = b[j].Start
+		}
+		if b[j].End.Before(end) {
+			end = b[j].End
+		}
+		if start.Before(end) {
+			result = append(result, TimeRange{Start: start, End: end})
+		}
+		if a[i].End.Before(b[j].End) {
+			i++
+		} else {
+			j++
+		}
+	}
+	return result
+}
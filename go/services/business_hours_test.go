@@ -0,0 +1,92 @@
+package services_test
+
+import (
+	"testing"
+	"time"
+
+	"timezone-saas-demo/services"
+)
+
+// TestIntervalsMidnightGap 零点因夏令时不存在的时区：逐天展开必须前进到下一天，当天从第一个存在的时刻开始
+func TestIntervalsMidnightGap(t *testing.T) {
+	utc := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2024, month, day, hour, 0, 0, 0, time.UTC)
+	}
+	noWeekend := services.NewWeekend()
+	cases := []struct {
+		name     string
+		timezone string
+		hours    services.BusinessHours
+		from, to time.Time
+		want     []services.TimeRange
+	}{
+		{
+			// 2024-09-08 为周日不营业，周一、周二 09:00-19:00 -03
+			name: "圣地亚哥默认营业时间", timezone: "America/Santiago", hours: services.DefaultBusinessHours,
+			from: utc(time.September, 8, 0), to: utc(time.September, 11, 0),
+			want: []services.TimeRange{
+				{Start: utc(time.September, 9, 12), End: utc(time.September, 9, 22)},
+				{Start: utc(time.September, 10, 12), End: utc(time.September, 10, 22)},
+			},
+		},
+		{
+			// 00:00 -04 跳到 01:00 -03，当天从 01:00 -03（04:00Z）开始
+			name: "圣地亚哥零点开始营业", timezone: "America/Santiago",
+			hours: services.BusinessHours{Start: 0, End: 6 * 60, Weekend: noWeekend},
+			from:  utc(time.September, 8, 0), to: utc(time.September, 9, 0),
+			want: []services.TimeRange{
+				{Start: utc(time.September, 8, 4), End: utc(time.September, 8, 9)},
+			},
+		},
+		{
+			// 22:00-02:00 跨过不存在的零点，实际营业 3 小时
+			name: "圣地亚哥跨午夜营业", timezone: "America/Santiago",
+			hours: services.BusinessHours{Start: 22 * 60, End: 2 * 60, Weekend: noWeekend},
+			from:  utc(time.September, 7, 12), to: utc(time.September, 8, 12),
+			want: []services.TimeRange{
+				{Start: utc(time.September, 8, 2), End: utc(time.September, 8, 5)},
+			},
+		},
+		{
+			// 哈瓦那 2024-03-10 00:00 CST 跳到 01:00 CDT
+			name: "哈瓦那零点开始营业", timezone: "America/Havana",
+			hours: services.BusinessHours{Start: 0, End: 6 * 60, Weekend: noWeekend},
+			from:  utc(time.March, 10, 0), to: utc(time.March, 11, 0),
+			want: []services.TimeRange{
+				{Start: utc(time.March, 10, 5), End: utc(time.March, 10, 10)},
+			},
+		},
+		{
+			// 2024-03-10 为周日不营业，周一 09:00-19:00 CDT
+			name: "哈瓦那默认营业时间", timezone: "America/Havana", hours: services.DefaultBusinessHours,
+			from: utc(time.March, 9, 0), to: utc(time.March, 12, 0),
+			want: []services.TimeRange{
+				{Start: utc(time.March, 11, 13), End: utc(time.March, 11, 23)},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			loc, err := time.LoadLocation(c.timezone)
+			if err != nil {
+				t.Fatalf("加载时区失败: %v", err)
+			}
+			done := make(chan []services.TimeRange, 1)
+			go func() { done <- c.hours.Intervals(c.from, c.to, loc) }()
+			var got []services.TimeRange
+			select {
+			case got = <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("展开营业区间未结束")
+			}
+			if len(got) != len(c.want) {
+				t.Fatalf("营业区间 = %v, 期望 %v", got, c.want)
+			}
+			for i := range got {
+				if !got[i].Start.Equal(c.want[i].Start) || !got[i].End.Equal(c.want[i].End) {
+					t.Errorf("第 %d 个区间 = %v ~ %v, 期望 %v ~ %v", i, got[i].Start.UTC(), got[i].End.UTC(), c.want[i].Start, c.want[i].End)
+				}
+			}
+		})
+	}
+}
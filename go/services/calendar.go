@@ -0,0 +1,144 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"timezone-saas-demo/ical"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/timerange"
+)
+
+const (
+	// CalendarPastDays、CalendarFutureDays 日历订阅覆盖今天之前和之后的天数（商户本地日期）
+	CalendarPastDays   = 30
+	CalendarFutureDays = 365
+	// calendarRefresh 建议日历客户端刷新订阅的间隔
+	calendarRefresh = 12 * time.Hour
+	// calendarReportDuration 日历中定时报表事件的时长，报表执行没有持续时间，只为在客户端中可见
+	calendarReportDuration = 15 * time.Minute
+	// calendarDomain 事件 UID 的域名部分
+	calendarDomain = "timezone-saas-demo"
+)
+
+var calendarWeekdayCodes = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// MerchantCalendar 商户的日历订阅：营业时间（每周重复，节假日排除）、节假日（全天）和定时报表的预计执行时刻，
+// 事件使用商户时区，VTIMEZONE 按服务端的时区数据逐条列出覆盖范围内的偏移切换
+// 覆盖商户本地日期今天之前 CalendarPastDays 天到之后 CalendarFutureDays 天，范围外的报表执行不列出
+func MerchantCalendar(merchant models.Merchant, holidays []Holiday, reports []ReportOccurrence, now time.Time) (*ical.Calendar, error) {
+	loc, err := LoadLocation(merchant.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	hours, err := MerchantBusinessHours(merchant)
+	if err != nil {
+		return nil, err
+	}
+	// fromDate、toDate 为本地日期（UTC 零点表示），from、to 为对应的第一个时刻
+	today := timerange.Today(loc, now)
+	fromDate := today.AddDate(0, 0, -CalendarPastDays)
+	toDate := today.AddDate(0, 0, CalendarFutureDays+1)
+	from, to := timerange.StartOfDay(fromDate, loc), timerange.StartOfDay(toDate, loc)
+
+	cal := &ical.Calendar{
+		ProdID:   "-//timezone-saas-demo//merchant calendar//ZH",
+		Name:     fmt.Sprintf("%s（%s）", merchant.Name, merchant.Timezone),
+		Location: loc,
+		From:     from,
+		To:       to,
+		Refresh:  calendarRefresh,
+		Stamp:    now.UTC().Truncate(time.Second),
+		Events:   businessHoursEvents(merchant, hours, holidays, fromDate, toDate, loc),
+	}
+	for _, h := range holidays {
+		date, err := timerange.ParseDate(h.Date)
+		if err != nil || date.Before(fromDate) || !date.Before(toDate) {
+			continue
+		}
+		cal.Events = append(cal.Events, ical.Event{
+			UID:         fmt.Sprintf("merchant-%d-holiday-%s@%s", merchant.ID, date.Format("20060102"), calendarDomain),
+			Summary:     "节假日：" + h.Name,
+			Categories:  []string{"节假日"},
+			Start:       date,
+			End:         date.AddDate(0, 0, 1),
+			AllDay:      true,
+			Transparent: true,
+		})
+	}
+	for _, run := range reports {
+		if run.At.Before(from) || !run.At.Before(to) {
+			continue
+		}
+		cal.Events = append(cal.Events, ical.Event{
+			UID:         fmt.Sprintf("report-%d-%s@%s", run.DefinitionID, ical.UTC(run.At), calendarDomain),
+			Summary:     "报表：" + run.Name,
+			Description: fmt.Sprintf("定时报表 #%d，统计本地日期 %s 至 %s，格式 %s", run.DefinitionID, run.From, run.To, run.Format),
+			Categories:  []string{"报表"},
+			Start:       run.At,
+			End:         run.At.Add(calendarReportDuration),
+			Transparent: true,
+		})
+	}
+	return cal, nil
+}
+
+// businessHoursEvents 营业时间的每周重复事件，周末不重复，节假日用 EXDATE 排除
+// 与 IsOpen 一致，跨午夜营业拆为当天开始时间到午夜、当天零点到结束时间两个系列（都属于当天的星期），
+// 开始和结束时间相同时为全天营业；夏令时切换当天的本地时间由客户端按 VTIMEZONE 换算
+// fromDate、toDate 为本地日期（UTC 零点表示），覆盖 [fromDate, toDate)
+func businessHoursEvents(merchant models.Merchant, hours BusinessHours, holidays []Holiday, fromDate, toDate time.Time, loc *time.Location) []ical.Event {
+	var days []string
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if !hours.Weekend.Contains(d) {
+			days = append(days, calendarWeekdayCodes[d])
+		}
+	}
+	if len(days) == 0 {
+		return nil
+	}
+	// 第一个营业日作为 DTSTART，与重复规则的第一次发生一致
+	first := fromDate
+	for hours.Weekend.Contains(first.Weekday()) {
+		first = first.AddDate(0, 0, 1)
+	}
+
+	type series struct{ start, end int }
+	var spans []series
+	switch {
+	case hours.Start == hours.End:
+		spans = []series{{0, 24 * 60}}
+	case hours.Overnight():
+		spans = []series{{hours.Start, 24 * 60}}
+		if hours.End > 0 {
+			spans = append(spans, series{0, hours.End})
+		}
+	default:
+		spans = []series{{hours.Start, hours.End}}
+	}
+
+	rrule := fmt.Sprintf("FREQ=WEEKLY;BYDAY=%s;UNTIL=%s", strings.Join(days, ","), ical.UTC(timerange.StartOfDay(toDate, loc)))
+	events := make([]ical.Event, 0, len(spans))
+	for i, span := range spans {
+		event := ical.Event{
+			UID:         fmt.Sprintf("merchant-%d-business-hours-%d@%s", merchant.ID, i+1, calendarDomain),
+			Summary:     fmt.Sprintf("营业时间 %s", hours),
+			Description: fmt.Sprintf("%s 的营业时间（%s），周末和节假日不营业", merchant.Name, merchant.Timezone),
+			Categories:  []string{"营业时间"},
+			Start:       timerange.Wall(first, span.start, loc),
+			End:         timerange.Wall(first, span.end, loc),
+			RRule:       rrule,
+			Transparent: true,
+		}
+		for _, h := range holidays {
+			date, err := timerange.ParseDate(h.Date)
+			if err != nil || date.Before(first) || !date.Before(toDate) || hours.Weekend.Contains(date.Weekday()) {
+				continue
+			}
+			event.ExDates = append(event.ExDates, timerange.Wall(date, span.start, loc))
+		}
+		events = append(events, event)
+	}
+	return events
+}
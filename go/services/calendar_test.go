@@ -0,0 +1,90 @@
+package services_test
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/services"
+	"timezone-saas-demo/testsupport"
+)
+
+var update = flag.Bool("update", false, "按当前输出重写 testdata 中的 golden 文件")
+
+// TestMerchantCalendarGolden 商户日历订阅的完整输出：VTIMEZONE 覆盖范围内的夏令时切换，营业时间按 TZID 本地时间重复、
+// UNTIL 为 UTC，节假日为全天事件并从营业时间中排除，跨午夜营业拆为两个系列，名称中的特殊字符转义
+func TestMerchantCalendarGolden(t *testing.T) {
+	// 圣地亚哥 2024-09-08 09:00 -03，柏林 2024-09-08 14:00 CEST
+	now := time.Date(2024, 9, 8, 12, 0, 0, 0, time.UTC)
+
+	bar := testsupport.NewMerchant(2, "柏林酒吧; Kreuzberg, 夜场", "Europe/Berlin", "德国", "柏林")
+	bar.BusinessHoursStart, bar.BusinessHoursEnd = "22:00", "02:00"
+
+	cases := []struct {
+		name     string
+		golden   string
+		merchant models.Merchant
+		holidays []services.Holiday
+		reports  []services.ReportOccurrence
+	}{
+		{
+			name:     "圣地亚哥",
+			golden:   "merchant_calendar_santiago.ics",
+			merchant: testsupport.NewMerchant(1, "圣地亚哥零售", "America/Santiago", "智利", "圣地亚哥"),
+			holidays: []services.Holiday{
+				{Date: "2024-09-18", Name: "独立日"},
+				{Date: "2024-09-19", Name: "陆军节"},
+				// 周六：列为全天事件，营业时间本就不重复，不排除
+				{Date: "2024-09-21", Name: "周末的节日"},
+				// 覆盖范围之外
+				{Date: "2023-01-01", Name: "新年"},
+			},
+			reports: []services.ReportOccurrence{
+				{DefinitionID: 7, Name: "日报, 智利", Format: "csv", At: time.Date(2024, 9, 9, 11, 0, 0, 0, time.UTC), From: "2024-09-08", To: "2024-09-08"},
+				{DefinitionID: 7, Name: "范围外", Format: "csv", At: time.Date(2026, 1, 1, 11, 0, 0, 0, time.UTC), From: "2025-12-31", To: "2025-12-31"},
+			},
+		},
+		{
+			name:     "柏林跨午夜营业",
+			golden:   "merchant_calendar_berlin_overnight.ics",
+			merchant: bar,
+			holidays: []services.Holiday{{Date: "2024-10-03", Name: "Tag der Deutschen Einheit"}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cal, err := services.MerchantCalendar(c.merchant, c.holidays, c.reports, now)
+			if err != nil {
+				t.Fatalf("生成日历失败: %v", err)
+			}
+			var buf bytes.Buffer
+			if err := cal.Encode(&buf); err != nil {
+				t.Fatalf("编码日历失败: %v", err)
+			}
+			checkGolden(t, c.golden, buf.Bytes())
+		})
+	}
+}
+
+// checkGolden 与 testdata 中的 golden 文件逐字节比较，-update 时改为写入
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("写入 %s 失败: %v", path, err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取 %s 失败: %v", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("输出与 %s 不一致（go test -run %s -update 重新生成）\n得到:\n%s", path, t.Name(), got)
+	}
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/repository"
+)
+
+// 合成监控的默认值和状态
+const (
+	// DefaultCanaryInterval 合成监控的运行周期
+	DefaultCanaryInterval = time.Minute
+	// CanaryTenant 合成监控查询分析时使用的租户（X-Tenant-ID），占用该租户自己的分析名额
+	CanaryTenant = "canary"
+	// canaryTimeout 一次运行的超时时间
+	canaryTimeout = 30 * time.Second
+
+	CanaryStatusOK      = "ok"
+	CanaryStatusFailed  = "failed"
+	CanaryStatusUnknown = "unknown"
+)
+
+// CanaryService 合成监控：定期以合成租户走一遍商户 → 订单 → 分析的读取路径，记录各步骤和端到端耗时，
+// 并将订单数和金额与 sql/35_canary.sql 写入的预期合计比对；从成功变为失败时告警
+// 结果只有本进程的数据，多实例部署时各实例分别检查自己的读取路径
+type CanaryService struct {
+	canary   repository.CanaryRepository
+	timezone *TimezoneService
+	alerter  Alerter
+	interval time.Duration
+	now      func() time.Time
+
+	mu            sync.Mutex
+	runs          int64
+	failures      int64
+	consecutive   int64
+	lastSuccessAt *time.Time
+	latest        *models.CanaryRun
+}
+
+// NewCanaryService 创建合成监控，使用 PostgreSQL 仓储；alerter 为 nil 时只记录日志
+func NewCanaryService(db *database.DB, timezone *TimezoneService, alerter Alerter) *CanaryService {
+	return NewCanaryServiceWithRepositories(repository.NewPostgresCanaryRepository(db), timezone, alerter)
+}
+
+// NewCanaryServiceWithRepositories 使用指定仓储创建合成监控
+func NewCanaryServiceWithRepositories(canary repository.CanaryRepository, timezone *TimezoneService, alerter Alerter) *CanaryService {
+	return &CanaryService{
+		canary:   canary,
+		timezone: timezone,
+		alerter:  alerter,
+		interval: DefaultCanaryInterval,
+		now:      time.Now,
+	}
+}
+
+// Status 累计结果和最近一次运行
+func (s *CanaryService) Status() models.CanaryStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := models.CanaryStatus{
+		Status:              CanaryStatusUnknown,
+		Interval:            s.interval.String(),
+		Runs:                s.runs,
+		Failures:            s.failures,
+		ConsecutiveFailures: s.consecutive,
+		LastSuccessAt:       s.lastSuccessAt,
+		Latest:              s.latest,
+	}
+	if s.latest != nil {
+		status.Status = s.latest.Status
+	}
+	return status
+}
+
+// Run 立即运行一次，之后每隔 interval 运行，直到 ctx 取消
+func (s *CanaryService) Run(ctx context.Context, interval time.Duration) {
+	s.mu.Lock()
+	s.interval = interval
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check 运行一次合成监控并记录结果；失败时记录日志，从成功（或未运行）变为失败时告警，恢复时记录日志
+func (s *CanaryService) Check(ctx context.Context) *models.CanaryRun {
+	ctx, cancel := context.WithTimeout(WithTenant(ctx, CanaryTenant), canaryTimeout)
+	defer cancel()
+
+	started := s.now()
+	run := &models.CanaryRun{Status: CanaryStatusOK, StartedAt: started.UTC(), Steps: []models.CanaryStep{}}
+	if err := s.probe(ctx, run); err != nil {
+		run.Status = CanaryStatusFailed
+		run.Error = err.Error()
+	}
+	run.DurationMs = canaryMs(s.now().Sub(started))
+
+	s.mu.Lock()
+	previous := s.latest
+	s.latest = run
+	s.runs++
+	if run.Status == CanaryStatusOK {
+		at := run.StartedAt
+		s.lastSuccessAt = &at
+		s.consecutive = 0
+	} else {
+		s.failures++
+		s.consecutive++
+	}
+	s.mu.Unlock()
+
+	switch {
+	case run.Status == CanaryStatusFailed:
+		log.Printf("⚠️ 合成监控失败（%.0f ms）: %s", run.DurationMs, run.Error)
+		if previous == nil || previous.Status != CanaryStatusFailed {
+			s.alert(ctx, run)
+		}
+	case previous != nil && previous.Status == CanaryStatusFailed:
+		log.Printf("✅ 合成监控已恢复（%.0f ms）", run.DurationMs)
+	}
+	return run
+}
+
+// probe 依次执行各步骤，返回第一个失败步骤的错误
+func (s *CanaryService) probe(ctx context.Context, run *models.CanaryRun) error {
+	tenant, err := s.canary.Tenant(ctx)
+	if err != nil {
+		return err
+	}
+	run.MerchantID, run.Date = tenant.MerchantID, tenant.Date
+
+	steps := []struct {
+		name string
+		fn   func() (expected, actual string, err error)
+	}{
+		{"merchants", func() (string, string, error) { return s.checkMerchants(tenant) }},
+		{"orders", func() (string, string, error) { return s.checkOrders(tenant) }},
+		{"analysis", func() (string, string, error) { return s.checkAnalysis(ctx, tenant) }},
+	}
+	for _, step := range steps {
+		start := s.now()
+		expected, actual, err := step.fn()
+		result := models.CanaryStep{Step: step.name, DurationMs: canaryMs(s.now().Sub(start)), Expected: expected, Actual: actual}
+		if err == nil && expected != actual {
+			err = fmt.Errorf("结果与预期不一致：预期 %s，实际 %s", expected, actual)
+		}
+		if err != nil {
+			result.Error = err.Error()
+			run.Steps = append(run.Steps, result)
+			return fmt.Errorf("%s: %w", step.name, err)
+		}
+		result.OK = true
+		run.Steps = append(run.Steps, result)
+	}
+	return nil
+}
+
+// checkMerchants 商户列表中应有合成租户，且时区与写入时一致
+func (s *CanaryService) checkMerchants(tenant *models.CanaryTenant) (string, string, error) {
+	merchants, err := s.timezone.GetMerchants()
+	if err != nil {
+		return "", "", err
+	}
+	expected := fmt.Sprintf("商户 %d（%s）", tenant.MerchantID, tenant.Timezone)
+	for _, m := range merchants {
+		if m.ID == tenant.MerchantID {
+			return expected, fmt.Sprintf("商户 %d（%s）", m.ID, m.Timezone), nil
+		}
+	}
+	return expected, fmt.Sprintf("%d 个商户中没有合成租户", len(merchants)), nil
+}
+
+// checkOrders 合成租户在本地日期当天的订单数和金额合计
+func (s *CanaryService) checkOrders(tenant *models.CanaryTenant) (string, string, error) {
+	orders, err := s.timezone.GetOrders(models.OrderFilter{
+		MerchantIDs: []int{tenant.MerchantID},
+		From:        tenant.Date,
+		To:          tenant.Date,
+		Limit:       tenant.ExpectedOrders + 10,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	amount := decimal.Zero
+	for _, o := range orders {
+		amount = amount.Add(o.Amount)
+	}
+	return canaryTotals(tenant.ExpectedOrders, tenant.ExpectedAmount, tenant.Currency),
+		canaryTotals(len(orders), amount, tenant.Currency), nil
+}
+
+// checkAnalysis 当天分析结果中合成租户所用币种的订单数和金额；只统计 paid，不受营收口径配置影响
+func (s *CanaryService) checkAnalysis(ctx context.Context, tenant *models.CanaryTenant) (string, string, error) {
+	analysis, err := s.timezone.GetAnalysisDataIn(ctx, tenant.Date, "", "", []string{models.OrderStatusPaid})
+	if err != nil {
+		return "", "", err
+	}
+	count, amount := 0, decimal.Zero
+	for _, total := range analysis.TotalsByCurrency {
+		if strings.EqualFold(total.Currency, tenant.Currency) {
+			count, amount = total.OrderCount, total.GrossAmount
+		}
+	}
+	return canaryTotals(tenant.ExpectedOrders, tenant.ExpectedAmount, tenant.Currency),
+		canaryTotals(count, amount, tenant.Currency), nil
+}
+
+// alert 发送合成监控失败的告警，失败只记录日志
+func (s *CanaryService) alert(ctx context.Context, run *models.CanaryRun) {
+	if s.alerter == nil {
+		return
+	}
+	alert := models.Alert{
+		Source:   "canary",
+		Severity: AlertCritical,
+		Title:    "合成监控失败",
+		Text:     fmt.Sprintf("以合成租户 %d 查询 %s 的数据失败: %s", run.MerchantID, run.Date, run.Error),
+		Details:  run,
+		At:       s.now().UTC(),
+	}
+	if err := s.alerter.Alert(context.WithoutCancel(ctx), alert); err != nil {
+		log.Printf("合成监控告警发送失败: %v", err)
+	}
+}
+
+// canaryTotals 订单数和金额的比对文本，金额按两位小数格式化
+func canaryTotals(count int, amount decimal.Decimal, currency string) string {
+	return fmt.Sprintf("%d 笔 %s %s", count, amount.StringFixed(2), currency)
+}
+
+func canaryMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
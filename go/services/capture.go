@@ -0,0 +1,288 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/redact"
+)
+
+// 请求录制的默认配置
+const (
+	// DefaultCaptureCapacity 所有租户共用的录制缓冲区条数，写满后覆盖最早的记录
+	DefaultCaptureCapacity = 200
+	// DefaultCaptureMaxBody 请求体和响应体各自保留的最大字节数
+	DefaultCaptureMaxBody = 8 << 10
+	// MaxCaptureRawBody 脱敏前读取的最大字节数，更长的 JSON 无法解析，只按文本替换邮箱
+	MaxCaptureRawBody = 1 << 20
+	// DefaultCaptureTTL 开启录制时未指定有效期的默认值
+	DefaultCaptureTTL = time.Hour
+	// maxCaptureTTL 录制的最长有效期，避免忘记关闭
+	maxCaptureTTL = 24 * time.Hour
+)
+
+// Redacted 替换敏感信息的占位符
+const Redacted = redact.Placeholder
+
+// CaptureRecorder 按租户录制 API 请求和响应，用于复现租户反馈的问题（如“我所在时区的数字不对”）
+// 只录制显式开启的租户，记录在内存环形缓冲区中，进程重启后清空
+type CaptureRecorder struct {
+	maxBody int
+
+	mu      sync.Mutex
+	tenants map[string]*models.CaptureTenant
+	buf     []models.RequestCapture
+	next    int
+	full    bool
+	nextID  int64
+	now     func() time.Time
+}
+
+// NewCaptureRecorder 创建请求录制器，capacity 为缓冲区条数，maxBody 为请求体和响应体各自保留的字节数
+func NewCaptureRecorder(capacity, maxBody int) *CaptureRecorder {
+	if capacity <= 0 {
+		capacity = DefaultCaptureCapacity
+	}
+	if maxBody <= 0 {
+		maxBody = DefaultCaptureMaxBody
+	}
+	return &CaptureRecorder{
+		maxBody: maxBody,
+		tenants: make(map[string]*models.CaptureTenant),
+		buf:     make([]models.RequestCapture, capacity),
+		now:     time.Now,
+	}
+}
+
+// Enable 为租户开启录制，ttl 为 0 时使用 DefaultCaptureTTL；已开启时延长有效期
+func (c *CaptureRecorder) Enable(tenant string, ttl time.Duration) (models.CaptureTenant, error) {
+	if strings.TrimSpace(tenant) == "" {
+		return models.CaptureTenant{}, fmt.Errorf("%w: 租户不能为空", ErrInvalidArgument)
+	}
+	if ttl == 0 {
+		ttl = DefaultCaptureTTL
+	}
+	if ttl < 0 || ttl > maxCaptureTTL {
+		return models.CaptureTenant{}, fmt.Errorf("%w: 录制有效期应在 0~%s 之间", ErrInvalidArgument, maxCaptureTTL)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now().UTC()
+	t, ok := c.tenants[tenant]
+	if !ok || !now.Before(t.ExpiresAt) {
+		t = &models.CaptureTenant{Tenant: tenant, EnabledAt: now}
+		c.tenants[tenant] = t
+	}
+	t.ExpiresAt = now.Add(ttl)
+	return *t, nil
+}
+
+// Disable 关闭租户的录制，已录制的记录保留
+func (c *CaptureRecorder) Disable(tenant string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.tenants[tenant]; !ok {
+		return fmt.Errorf("%w: 租户 %s 未开启录制", ErrNotFound, tenant)
+	}
+	delete(c.tenants, tenant)
+	return nil
+}
+
+// Tenants 正在录制的租户，按租户排序
+func (c *CaptureRecorder) Tenants() []models.CaptureTenant {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	tenants := make([]models.CaptureTenant, 0, len(c.tenants))
+	for name, t := range c.tenants {
+		if !now.Before(t.ExpiresAt) {
+			delete(c.tenants, name)
+			continue
+		}
+		tenants = append(tenants, *t)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].Tenant < tenants[j].Tenant })
+	return tenants
+}
+
+// Enabled 租户当前是否在录制
+func (c *CaptureRecorder) Enabled(tenant string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, ok := c.tenants[tenant]
+	return ok && c.now().Before(t.ExpiresAt)
+}
+
+// Record 保存一条录制记录，租户未开启或已过期时忽略
+// 调用方负责用 SanitizeHeaders、SanitizeQuery 和 SanitizeBody 脱敏
+func (c *CaptureRecorder) Record(capture models.RequestCapture) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, ok := c.tenants[capture.Tenant]
+	if !ok || !c.now().Before(t.ExpiresAt) {
+		return
+	}
+	t.Captured++
+	c.nextID++
+	capture.ID = c.nextID
+	c.buf[c.next] = capture
+	c.next = (c.next + 1) % len(c.buf)
+	if c.next == 0 {
+		c.full = true
+	}
+}
+
+// List 最近的录制记录，按时间倒序；tenant 为空时返回全部租户，limit <= 0 时不限制条数
+func (c *CaptureRecorder) List(tenant string, limit int) []models.RequestCapture {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	captures := []models.RequestCapture{}
+	c.eachNewest(func(capture models.RequestCapture) bool {
+		if tenant == "" || capture.Tenant == tenant {
+			captures = append(captures, capture)
+		}
+		return limit <= 0 || len(captures) < limit
+	})
+	return captures
+}
+
+// Get 按ID获取录制记录
+func (c *CaptureRecorder) Get(id int64) (*models.RequestCapture, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var found *models.RequestCapture
+	c.eachNewest(func(capture models.RequestCapture) bool {
+		if capture.ID == id {
+			found = &capture
+			return false
+		}
+		return true
+	})
+	if found == nil {
+		return nil, fmt.Errorf("%w: 录制记录 %d 不存在或已被覆盖", ErrNotFound, id)
+	}
+	return found, nil
+}
+
+// Clear 删除录制记录，tenant 为空时删除全部，返回删除的条数
+func (c *CaptureRecorder) Clear(tenant string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var kept []models.RequestCapture
+	removed := 0
+	c.eachNewest(func(capture models.RequestCapture) bool {
+		if tenant == "" || capture.Tenant == tenant {
+			removed++
+		} else {
+			kept = append(kept, capture)
+		}
+		return true
+	})
+
+	for i := range c.buf {
+		c.buf[i] = models.RequestCapture{}
+	}
+	c.next, c.full = 0, false
+	for i := len(kept) - 1; i >= 0; i-- {
+		c.buf[c.next] = kept[i]
+		c.next++
+	}
+	return removed
+}
+
+// eachNewest 从最新到最早遍历缓冲区，fn 返回 false 时停止，调用方需持有锁
+func (c *CaptureRecorder) eachNewest(fn func(models.RequestCapture) bool) {
+	n := c.next
+	if c.full {
+		n = len(c.buf)
+	}
+	for i := 1; i <= n; i++ {
+		capture := c.buf[(c.next-i+len(c.buf))%len(c.buf)]
+		if !fn(capture) {
+			return
+		}
+	}
+}
+
+// SanitizeHeaders 复制请求头或响应头，按脱敏策略处理敏感头的值，策略为 strip 的头被删除
+func SanitizeHeaders(header http.Header) map[string]string {
+	r := redact.Current()
+	headers := make(map[string]string, len(header))
+	for name, values := range header {
+		if value, ok := r.Value(name, strings.Join(values, ", ")); ok {
+			headers[name] = value
+		}
+	}
+	return headers
+}
+
+// SanitizeQuery 查询字符串，按脱敏策略处理敏感参数的值
+func SanitizeQuery(query url.Values) string {
+	r := redact.Current()
+	sanitized := url.Values{}
+	for name, values := range query {
+		for _, value := range values {
+			if value, ok := r.Value(name, value); ok {
+				sanitized.Add(name, value)
+			}
+		}
+	}
+	return sanitized.Encode()
+}
+
+// SanitizeBody 脱敏并截断请求体或响应体
+// JSON 按字段名称脱敏（订单号遮盖、联系方式删除等），其他字符串中的邮箱替换；非 JSON 按文本脱敏；超过上限时截断并返回 true
+func (c *CaptureRecorder) SanitizeBody(body []byte) (string, bool) {
+	if len(body) == 0 {
+		return "", false
+	}
+
+	r := redact.Current()
+	text := string(body)
+	var value interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if json.Valid(body) && dec.Decode(&value) == nil {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if enc.Encode(r.JSON(value)) == nil {
+			text = strings.TrimSuffix(buf.String(), "\n")
+		}
+	} else {
+		text = r.Text(text)
+	}
+
+	if len(text) <= c.maxBody {
+		return text, false
+	}
+	// 按 UTF-8 字符边界截断
+	cut := c.maxBody
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut], true
+}
+
+// Replayable 录制的请求头或查询参数是否保持原值，被脱敏的值无法用于重放
+func Replayable(name string) bool {
+	return redact.Current().Action(name) == redact.Keep
+}
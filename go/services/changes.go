@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/repository"
+)
+
+const (
+	// DefaultChangeLimit 一次最多返回的变更
+	DefaultChangeLimit = 500
+	// maxChangeLimit limit 参数的上限
+	maxChangeLimit = 1000
+	// MaxChangeWait long polling 最长的等待时间，客户端和代理的读超时应大于该值
+	MaxChangeWait = 60 * time.Second
+	// DefaultChangePollInterval 等待新变更时重新查询的间隔
+	DefaultChangePollInterval = time.Second
+)
+
+// ChangeService 商户和订单的变更流水，供移动端按 seq 增量同步
+// 没有新变更时请求可以阻塞等待（long polling），等待期间按固定间隔重新查询，多实例部署时不依赖进程内通知
+type ChangeService struct {
+	changes      repository.ChangeRepository
+	pollInterval time.Duration
+}
+
+// NewChangeService 创建变更流水服务，使用 PostgreSQL 仓储
+func NewChangeService(db *database.DB) *ChangeService {
+	return NewChangeServiceWithRepositories(repository.NewPostgresChangeRepository(db))
+}
+
+// NewChangeServiceWithRepositories 使用指定仓储创建变更流水服务
+func NewChangeServiceWithRepositories(changes repository.ChangeRepository) *ChangeService {
+	return &ChangeService{changes: changes, pollInterval: DefaultChangePollInterval}
+}
+
+// SetPollInterval 设置等待新变更时重新查询的间隔，不大于 0 时使用默认值
+func (s *ChangeService) SetPollInterval(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultChangePollInterval
+	}
+	s.pollInterval = interval
+}
+
+// Feed 返回 seq 大于 filter.Since 的变更
+// 没有变更且 wait 大于 0 时每隔 pollInterval 重新查询，直到有变更、等待超时或 ctx 取消；超时返回空列表而不是错误
+func (s *ChangeService) Feed(ctx context.Context, filter models.ChangeFilter, wait time.Duration) (*models.ChangeFeed, error) {
+	if filter.Since < 0 {
+		return nil, fmt.Errorf("%w: since 不能为负数", ErrInvalidArgument)
+	}
+	if wait < 0 || wait > MaxChangeWait {
+		return nil, fmt.Errorf("%w: wait 应在 0~%s 之间", ErrInvalidArgument, MaxChangeWait)
+	}
+	for _, entity := range filter.Entities {
+		if entity != models.ChangeEntityMerchant && entity != models.ChangeEntityOrder {
+			return nil, fmt.Errorf("%w: 未知的实体 %q，可选 %s,%s", ErrInvalidArgument, entity, models.ChangeEntityMerchant, models.ChangeEntityOrder)
+		}
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultChangeLimit
+	}
+	if filter.Limit > maxChangeLimit {
+		filter.Limit = maxChangeLimit
+	}
+
+	started := time.Now()
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		changes, err := s.changes.Changes(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		if len(changes) > 0 || wait == 0 {
+			return newChangeFeed(changes, filter, started), nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			return newChangeFeed(nil, filter, started), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// newChangeFeed 组装一次拉取的结果，返回条数达到 limit 时认为还有更多变更
+func newChangeFeed(changes []models.Change, filter models.ChangeFilter, started time.Time) *models.ChangeFeed {
+	feed := &models.ChangeFeed{
+		Changes:  changes,
+		NextSeq:  filter.Since,
+		HasMore:  len(changes) >= filter.Limit,
+		WaitedMs: time.Since(started).Milliseconds(),
+	}
+	if feed.Changes == nil {
+		feed.Changes = []models.Change{}
+	}
+	if len(changes) > 0 {
+		feed.NextSeq = changes[len(changes)-1].Seq
+	}
+	return feed
+}
@@ -0,0 +1,344 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lib/pq"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// 回填任务的批大小
+const (
+	DefaultBackfillBatchSize = 5000
+	maxBackfillBatchSize     = 50000
+)
+
+// backfillJobColumns backfill_job 的查询列，与 scanBackfillJob 的扫描顺序一致
+const backfillJobColumns = `
+	job_id, merchant_ids, reason, status, batch_size, total_orders, processed_orders,
+	cursor_merchant_id, cursor_order_id, error, created_at, updated_at, finished_at`
+
+// BackfillRequest 创建回填任务的请求
+type BackfillRequest struct {
+	// MerchantIDs 需要回填的商户，为空表示全部商户
+	MerchantIDs []int `json:"merchant_ids"`
+	// BatchSize 每批订单数，为 0 时使用 DefaultBackfillBatchSize
+	BatchSize int    `json:"batch_size"`
+	Reason    string `json:"reason"`
+}
+
+// ClickHouseBackfill 按分析视图的当前规则重新镜像订单，修正 ClickHouse 中过期的本地时间字段
+// 营业时间、周末或商户时区修改后，PostgreSQL 视图立即生效，但镜像只同步 updated_at 变化的订单，
+// 已写入 ClickHouse 的 local_date、is_business_hour 等字段需要回填。
+// 任务按 (merchant_id, order_id) 分批执行，每批完成后把游标写入 backfill_job，进程退出后可以继续执行。
+type ClickHouseBackfill struct {
+	db *database.DB
+	ch *database.ClickHouse
+
+	// active 本进程中正在执行的任务
+	mu     sync.Mutex
+	active map[int64]bool
+}
+
+// NewClickHouseBackfill 创建回填服务
+func NewClickHouseBackfill(db *database.DB, ch *database.ClickHouse) *ClickHouseBackfill {
+	return &ClickHouseBackfill{db: db, ch: ch, active: make(map[int64]bool)}
+}
+
+// Create 校验请求、统计需要回填的订单数并创建任务，任务需要通过 Run 或 Start 执行
+func (b *ClickHouseBackfill) Create(ctx context.Context, req BackfillRequest) (*models.BackfillJob, error) {
+	if req.BatchSize == 0 {
+		req.BatchSize = DefaultBackfillBatchSize
+	}
+	if req.BatchSize < 0 || req.BatchSize > maxBackfillBatchSize {
+		return nil, fmt.Errorf("%w: batch_size 应在 1~%d 之间", ErrInvalidArgument, maxBackfillBatchSize)
+	}
+	merchantIDs, err := normalizeMerchantIDs(req.MerchantIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	err = b.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM dws_orders_analysis_view
+		WHERE COALESCE(cardinality($1::int[]), 0) = 0 OR merchant_id = ANY($1::int[])
+	`, pq.Array(merchantIDs)).Scan(&total)
+	if err != nil {
+		return nil, fmt.Errorf("统计回填订单数失败: %w", err)
+	}
+
+	row := b.db.QueryRowContext(ctx, `
+		INSERT INTO backfill_job (merchant_ids, reason, status, batch_size, total_orders)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+backfillJobColumns,
+		pq.Array(merchantIDs), req.Reason, models.BackfillRunning, req.BatchSize, total)
+	job, err := scanBackfillJob(row)
+	if err != nil {
+		return nil, fmt.Errorf("创建回填任务失败: %w", err)
+	}
+	return job, nil
+}
+
+// Run 执行任务直到完成、失败或 ctx 取消，progress 在每批完成后回调，可以为 nil
+// 失败和中断的任务从游标处继续；已完成的任务返回 ErrInvalidArgument，本进程中正在执行的任务返回 ErrConflict
+func (b *ClickHouseBackfill) Run(ctx context.Context, id int64, progress func(models.BackfillJob)) (*models.BackfillJob, error) {
+	if err := b.acquire(id); err != nil {
+		return nil, err
+	}
+	defer b.release(id)
+	return b.run(ctx, id, progress)
+}
+
+// Start 在后台执行任务，进度写日志，同步返回任务无法开始的原因
+func (b *ClickHouseBackfill) Start(id int64) error {
+	if err := b.acquire(id); err != nil {
+		return err
+	}
+	go func() {
+		defer b.release(id)
+		job, err := b.run(context.Background(), id, func(job models.BackfillJob) {
+			log.Printf("回填任务 %d: %d/%d 笔订单（%.1f%%）", job.ID, job.ProcessedOrders, job.TotalOrders, job.Progress)
+		})
+		if err != nil {
+			log.Printf("回填任务 %d 失败: %v", id, err)
+		} else {
+			log.Printf("回填任务 %d 完成: %d 笔订单", job.ID, job.ProcessedOrders)
+		}
+	}()
+	return nil
+}
+
+// Jobs 最近 limit 个任务，按创建时间倒序
+func (b *ClickHouseBackfill) Jobs(ctx context.Context, limit int) ([]models.BackfillJob, error) {
+	rows, err := b.db.QueryContext(ctx, `
+		SELECT `+backfillJobColumns+`
+		FROM backfill_job
+		ORDER BY job_id DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询回填任务失败: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []models.BackfillJob{}
+	for rows.Next() {
+		job, err := scanBackfillJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描回填任务失败: %w", err)
+		}
+		jobs = append(jobs, *job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历回填任务失败: %w", err)
+	}
+	return jobs, nil
+}
+
+// Job 按ID获取任务，不存在时返回 ErrNotFound
+func (b *ClickHouseBackfill) Job(ctx context.Context, id int64) (*models.BackfillJob, error) {
+	row := b.db.QueryRowContext(ctx, `SELECT `+backfillJobColumns+` FROM backfill_job WHERE job_id = $1`, id)
+	job, err := scanBackfillJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: 回填任务 %d", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询回填任务失败: %w", err)
+	}
+	return job, nil
+}
+
+// run 逐批回填，调用方需已通过 acquire 占用任务
+func (b *ClickHouseBackfill) run(ctx context.Context, id int64, progress func(models.BackfillJob)) (*models.BackfillJob, error) {
+	job, err := b.Job(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == models.BackfillCompleted {
+		return nil, fmt.Errorf("%w: 回填任务 %d 已完成", ErrInvalidArgument, id)
+	}
+	if job.Status == models.BackfillFailed {
+		if _, err := b.db.ExecContext(ctx, `UPDATE backfill_job SET status = $2, error = '' WHERE job_id = $1`, id, models.BackfillRunning); err != nil {
+			return nil, fmt.Errorf("更新回填任务失败: %w", err)
+		}
+		job.Status, job.Error = models.BackfillRunning, ""
+	}
+
+	for {
+		n, err := b.batch(ctx, job)
+		if err != nil {
+			// ctx 可能已取消，记录失败状态时不使用 ctx
+			if _, uerr := b.db.Exec(`UPDATE backfill_job SET status = $2, error = $3 WHERE job_id = $1`, id, models.BackfillFailed, err.Error()); uerr != nil {
+				log.Printf("记录回填任务 %d 失败状态失败: %v", id, uerr)
+			}
+			job.Status, job.Error = models.BackfillFailed, err.Error()
+			return job, err
+		}
+		if n > 0 && progress != nil {
+			progress(*job)
+		}
+		if n < job.BatchSize {
+			break
+		}
+	}
+
+	if _, err := b.db.ExecContext(ctx, `UPDATE backfill_job SET status = $2, finished_at = CURRENT_TIMESTAMP WHERE job_id = $1`, id, models.BackfillCompleted); err != nil {
+		return job, fmt.Errorf("更新回填任务失败: %w", err)
+	}
+	return b.Job(ctx, id)
+}
+
+// batch 回填游标之后的一批订单并推进游标，返回本批订单数
+func (b *ClickHouseBackfill) batch(ctx context.Context, job *models.BackfillJob) (int, error) {
+	query := `
+		SELECT ` + clickHouseSourceColumns + `
+		FROM dws_orders_analysis_view v
+		JOIN dws_orders o ON o.order_id = v.order_id
+		WHERE (COALESCE(cardinality($1::int[]), 0) = 0 OR v.merchant_id = ANY($1::int[]))
+			AND (v.merchant_id, v.order_id) > ($2, $3)
+		ORDER BY v.merchant_id, v.order_id
+		LIMIT $4
+	`
+
+	rows, err := b.db.QueryContext(ctx, query, pq.Array(job.MerchantIDs), job.CursorMerchantID, job.CursorOrderID, job.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("查询待回填订单失败: %w", err)
+	}
+	defer rows.Close()
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	ids := make([]string, 0, job.BatchSize)
+	var lastMerchantID, lastOrderID int
+
+	for rows.Next() {
+		row, _, err := scanClickHouseRow(rows)
+		if err != nil {
+			return 0, err
+		}
+		if err := encoder.Encode(row); err != nil {
+			return 0, fmt.Errorf("编码回填数据失败: %w", err)
+		}
+		ids = append(ids, strconv.Itoa(row.OrderID))
+		lastMerchantID, lastOrderID = row.MerchantID, row.OrderID
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("遍历待回填订单失败: %w", err)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	// local_date 是排序键的一部分，日期变化后重新写入不会替换旧行，需要先删除
+	// 删除后写入前中断时这批订单暂时缺失，继续执行时会从同一游标重新写入
+	params := map[string]string{"ids": "[" + strings.Join(ids, ",") + "]"}
+	if err := b.ch.ExecContext(ctx, "DELETE FROM orders_analysis WHERE order_id IN {ids:Array(UInt64)}", params, nil); err != nil {
+		return 0, fmt.Errorf("删除 ClickHouse 旧镜像失败: %w", err)
+	}
+	if err := b.ch.ExecContext(ctx, "INSERT INTO orders_analysis FORMAT JSONEachRow", nil, &buf); err != nil {
+		return 0, fmt.Errorf("写入 ClickHouse 失败: %w", err)
+	}
+
+	_, err = b.db.ExecContext(ctx, `
+		UPDATE backfill_job
+		SET processed_orders = processed_orders + $2, cursor_merchant_id = $3, cursor_order_id = $4
+		WHERE job_id = $1
+	`, job.ID, len(ids), lastMerchantID, lastOrderID)
+	if err != nil {
+		return 0, fmt.Errorf("保存回填进度失败: %w", err)
+	}
+	job.ProcessedOrders += int64(len(ids))
+	job.CursorMerchantID, job.CursorOrderID = lastMerchantID, lastOrderID
+	job.Progress = backfillProgress(job.ProcessedOrders, job.TotalOrders)
+	return len(ids), nil
+}
+
+// acquire 标记任务在本进程中执行
+func (b *ClickHouseBackfill) acquire(id int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.active[id] {
+		return fmt.Errorf("%w: 回填任务 %d 正在执行", ErrConflict, id)
+	}
+	b.active[id] = true
+	return nil
+}
+
+// release 取消任务的执行标记
+func (b *ClickHouseBackfill) release(id int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.active, id)
+}
+
+// scanBackfillJob 扫描一行任务，sql.ErrNoRows 原样返回
+func scanBackfillJob(row interface{ Scan(...interface{}) error }) (*models.BackfillJob, error) {
+	var job models.BackfillJob
+	var merchantIDs pq.Int64Array
+	var finishedAt sql.NullTime
+	err := row.Scan(
+		&job.ID,
+		&merchantIDs,
+		&job.Reason,
+		&job.Status,
+		&job.BatchSize,
+		&job.TotalOrders,
+		&job.ProcessedOrders,
+		&job.CursorMerchantID,
+		&job.CursorOrderID,
+		&job.Error,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+		&finishedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	job.MerchantIDs = make([]int, len(merchantIDs))
+	for i, id := range merchantIDs {
+		job.MerchantIDs[i] = int(id)
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	job.Progress = backfillProgress(job.ProcessedOrders, job.TotalOrders)
+	return &job, nil
+}
+
+// backfillProgress 完成百分比；任务创建后新增的订单会让已处理数超过总数，此时按 100 计
+func backfillProgress(processed, total int64) float64 {
+	if total <= 0 || processed >= total {
+		return 100
+	}
+	return float64(processed) * 100 / float64(total)
+}
+
+// normalizeMerchantIDs 校验商户ID，去重并排序
+func normalizeMerchantIDs(ids []int) ([]int, error) {
+	seen := make(map[int]bool, len(ids))
+	result := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if id <= 0 {
+			return nil, fmt.Errorf("%w: 商户ID应为正整数: %d", ErrInvalidArgument, id)
+		}
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	sort.Ints(result)
+	return result, nil
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"timezone-saas-demo/database"
+)
+
+// clickHouseSchema ClickHouse 中订单分析镜像表
+// ReplacingMergeTree 以 updated_at 为版本，重复镜像同一订单时保留最新版本
+const clickHouseSchema = `
+	CREATE TABLE IF NOT EXISTS orders_analysis (
+		order_id          UInt64,
+		order_number      String,
+		amount            Decimal(15, 2),
+		currency          LowCardinality(String),
+		status            LowCardinality(String),
+		merchant_id       UInt32,
+		merchant_name     String,
+		timezone          LowCardinality(String),
+		country           String,
+		city              String,
+		order_time_utc    DateTime64(6, 'UTC'),
+		local_date        Date,
+		local_hour        UInt8,
+		local_day_of_week UInt8,
+		is_weekend        Bool,
+		is_business_hour  Bool,
+		updated_at        DateTime64(6, 'UTC')
+	)
+	ENGINE = ReplacingMergeTree(updated_at)
+	PARTITION BY toYYYYMM(local_date)
+	ORDER BY (local_date, merchant_id, order_id)
+`
+
+// clickHouseTimeLayout ClickHouse DateTime64 可解析的时间格式
+const clickHouseTimeLayout = "2006-01-02 15:04:05.000000"
+
+// ClickHouseMirror 将 PostgreSQL 订单增量镜像到 ClickHouse
+// 以 (updated_at, order_id) 为游标按批拉取，订单状态变更同样会被重新镜像
+type ClickHouseMirror struct {
+	db        *database.DB
+	ch        *database.ClickHouse
+	interval  time.Duration
+	batchSize int
+
+	lastUpdatedAt time.Time
+	lastOrderID   int
+}
+
+// NewClickHouseMirror 创建镜像任务
+func NewClickHouseMirror(db *database.DB, ch *database.ClickHouse, interval time.Duration) *ClickHouseMirror {
+	return &ClickHouseMirror{
+		db:        db,
+		ch:        ch,
+		interval:  interval,
+		batchSize: 5000,
+	}
+}
+
+// clickHouseOrderRow 写入 ClickHouse 的一行（JSONEachRow 格式）
+type clickHouseOrderRow struct {
+	OrderID        int             `json:"order_id"`
+	OrderNumber    string          `json:"order_number"`
+	Amount         decimal.Decimal `json:"amount"`
+	Currency       string          `json:"currency"`
+	Status         string          `json:"status"`
+	MerchantID     int             `json:"merchant_id"`
+	MerchantName   string          `json:"merchant_name"`
+	Timezone       string          `json:"timezone"`
+	Country        string          `json:"country"`
+	City           string          `json:"city"`
+	OrderTimeUTC   string          `json:"order_time_utc"`
+	LocalDate      string          `json:"local_date"`
+	LocalHour      int             `json:"local_hour"`
+	LocalDayOfWeek int             `json:"local_day_of_week"`
+	IsWeekend      bool            `json:"is_weekend"`
+	IsBusinessHour bool            `json:"is_business_hour"`
+	UpdatedAt      string          `json:"updated_at"`
+}
+
+// clickHouseSourceColumns 从分析视图（v）和订单表（o）读取镜像行的列，与 scanClickHouseRow 的扫描顺序一致
+const clickHouseSourceColumns = `
+			v.order_id, v.order_number, v.amount, v.currency, v.status,
+			v.merchant_id, v.merchant_name, v.timezone, v.country, v.city,
+			v.order_time_utc, v.local_date, v.local_hour, v.local_day_of_week,
+			v.is_weekend, v.is_business_hour, o.updated_at`
+
+// scanClickHouseRow 扫描一行镜像数据，同时返回订单的 updated_at（镜像版本）
+func scanClickHouseRow(rows *sql.Rows) (clickHouseOrderRow, time.Time, error) {
+	var row clickHouseOrderRow
+	var orderTimeUTC, localDate, updatedAt time.Time
+	err := rows.Scan(
+		&row.OrderID,
+		&row.OrderNumber,
+		&row.Amount,
+		&row.Currency,
+		&row.Status,
+		&row.MerchantID,
+		&row.MerchantName,
+		&row.Timezone,
+		&row.Country,
+		&row.City,
+		&orderTimeUTC,
+		&localDate,
+		&row.LocalHour,
+		&row.LocalDayOfWeek,
+		&row.IsWeekend,
+		&row.IsBusinessHour,
+		&updatedAt,
+	)
+	if err != nil {
+		return row, updatedAt, fmt.Errorf("扫描待镜像订单失败: %w", err)
+	}
+
+	row.OrderTimeUTC = orderTimeUTC.UTC().Format(clickHouseTimeLayout)
+	row.LocalDate = localDate.Format("2006-01-02")
+	row.UpdatedAt = updatedAt.UTC().Format(clickHouseTimeLayout)
+	return row, updatedAt, nil
+}
+
+// Init 创建镜像表并从 ClickHouse 中恢复同步游标
+func (m *ClickHouseMirror) Init() error {
+	if err := m.ch.Exec(clickHouseSchema, nil, nil); err != nil {
+		return fmt.Errorf("创建 ClickHouse 镜像表失败: %w", err)
+	}
+
+	var rows []struct {
+		UpdatedAt string `json:"updated_at"`
+		OrderID   int    `json:"order_id"`
+	}
+	query := `
+		SELECT
+			toString(max(updated_at)) AS updated_at,
+			toInt64(argMax(order_id, updated_at)) AS order_id
+		FROM orders_analysis
+	`
+	if err := m.ch.Query(query, nil, &rows); err != nil {
+		return fmt.Errorf("读取 ClickHouse 同步游标失败: %w", err)
+	}
+	if len(rows) > 0 && rows[0].OrderID > 0 {
+		if t, err := time.Parse("2006-01-02 15:04:05.999999", rows[0].UpdatedAt); err == nil {
+			m.lastUpdatedAt = t
+			m.lastOrderID = rows[0].OrderID
+		}
+	}
+
+	log.Printf("ClickHouse 镜像游标: updated_at=%s order_id=%d", m.lastUpdatedAt.Format(time.RFC3339), m.lastOrderID)
+	return nil
+}
+
+// Run 周期性执行镜像，直到 ctx 取消
+func (m *ClickHouseMirror) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := m.SyncOnce(); err != nil {
+			log.Printf("ClickHouse 镜像失败: %v", err)
+		} else if n > 0 {
+			log.Printf("ClickHouse 镜像完成: %d 条订单", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SyncOnce 拉取所有待镜像的订单并写入 ClickHouse，返回写入行数
+func (m *ClickHouseMirror) SyncOnce() (int, error) {
+	total := 0
+	for {
+		n, err := m.syncBatch()
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < m.batchSize {
+			return total, nil
+		}
+	}
+}
+
+// syncBatch 镜像一批订单
+func (m *ClickHouseMirror) syncBatch() (int, error) {
+	query := `
+		SELECT ` + clickHouseSourceColumns + `
+		FROM dws_orders_analysis_view v
+		JOIN dws_orders o ON o.order_id = v.order_id
+		WHERE (o.updated_at, o.order_id) > ($1, $2)
+		ORDER BY o.updated_at, o.order_id
+		LIMIT $3
+	`
+
+	rows, err := m.db.Query(query, m.lastUpdatedAt, m.lastOrderID, m.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("查询待镜像订单失败: %w", err)
+	}
+	defer rows.Close()
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	count := 0
+	var lastUpdatedAt time.Time
+	var lastOrderID int
+
+	for rows.Next() {
+		row, updatedAt, err := scanClickHouseRow(rows)
+		if err != nil {
+			return 0, err
+		}
+		if err := encoder.Encode(row); err != nil {
+			return 0, fmt.Errorf("编码镜像数据失败: %w", err)
+		}
+
+		lastUpdatedAt, lastOrderID = updatedAt, row.OrderID
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("遍历待镜像订单失败: %w", err)
+	}
+	if count == 0 {
+		return 0, nil
+	}
+
+	if err := m.ch.Exec("INSERT INTO orders_analysis FORMAT JSONEachRow", nil, &buf); err != nil {
+		return 0, fmt.Errorf("写入 ClickHouse 失败: %w", err)
+	}
+
+	// 写入成功后才推进游标，失败时下次重试同一批
+	m.lastUpdatedAt, m.lastOrderID = lastUpdatedAt, lastOrderID
+	return count, nil
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"timezone-saas-demo/models"
+)
+
+// 时钟检查的默认值和状态
+const (
+	// DefaultClockSkewThreshold 偏差超过该值时告警
+	DefaultClockSkewThreshold = time.Second
+	// clockReferenceTimeout 读取单个参照时钟的超时时间
+	clockReferenceTimeout = 3 * time.Second
+
+	ClockStatusOK      = "ok"
+	ClockStatusWarning = "warning"
+	ClockStatusUnknown = "unknown"
+)
+
+// ClockSource 读取参照时钟的当前时间
+type ClockSource func(ctx context.Context) (time.Time, error)
+
+// namedClockSource 带名称的参照时钟
+type namedClockSource struct {
+	name   string
+	source ClockSource
+}
+
+// ClockMonitor 比较本机时钟与数据库、NTP 等参照时钟
+// 订单是否属于商户的"今天"依赖本机时钟换算，偏差会让跨零点的订单算错日期，而且不会报错
+type ClockMonitor struct {
+	threshold time.Duration
+	alerter   Alerter
+	now       func() time.Time
+
+	mu      sync.Mutex
+	sources []namedClockSource
+	latest  *models.ClockStatus
+}
+
+// NewClockMonitor 创建时钟检查，threshold 不大于 0 时使用 DefaultClockSkewThreshold；alerter 为 nil 时只记录日志
+func NewClockMonitor(threshold time.Duration, alerter Alerter) *ClockMonitor {
+	if threshold <= 0 {
+		threshold = DefaultClockSkewThreshold
+	}
+	return &ClockMonitor{threshold: threshold, alerter: alerter, now: time.Now}
+}
+
+// AddSource 添加参照时钟，下一次检查生效
+func (m *ClockMonitor) AddSource(name string, source ClockSource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sources = append(m.sources, namedClockSource{name: name, source: source})
+}
+
+// Latest 最近一次检查的结果，还没有检查过时返回 nil
+func (m *ClockMonitor) Latest() *models.ClockStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.latest
+}
+
+// Run 立即检查一次，之后每隔 interval 检查，直到 ctx 取消；interval 不大于 0 时只检查一次
+func (m *ClockMonitor) Run(ctx context.Context, interval time.Duration) {
+	m.Check(ctx)
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Check(ctx)
+		}
+	}
+}
+
+// Check 依次读取各参照时钟并计算偏差
+// 偏差按请求往返的中点估计：参照时间 - (发送时间 + 往返耗时/2)
+// 偏差超过阈值时每次都记录日志，从正常变为超过阈值时发送告警
+func (m *ClockMonitor) Check(ctx context.Context) *models.ClockStatus {
+	m.mu.Lock()
+	sources := append([]namedClockSource(nil), m.sources...)
+	m.mu.Unlock()
+
+	status := &models.ClockStatus{
+		Status:      ClockStatusUnknown,
+		ThresholdMs: m.threshold.Milliseconds(),
+		References:  []models.ClockReference{},
+	}
+	var maxSkew time.Duration
+	for _, s := range sources {
+		ref := models.ClockReference{Name: s.name}
+		skew, rtt, err := m.measure(ctx, s.source)
+		if err != nil {
+			ref.Error = err.Error()
+			log.Printf("⚠️ 读取参照时钟 %s 失败: %v", s.name, err)
+		} else {
+			ref.SkewMs = skew.Milliseconds()
+			ref.RTTMs = rtt.Milliseconds()
+			if status.Status == ClockStatusUnknown {
+				status.Status = ClockStatusOK
+			}
+			if skew.Abs() > maxSkew.Abs() {
+				maxSkew = skew
+			}
+			if skew.Abs() > m.threshold {
+				status.Status = ClockStatusWarning
+				log.Printf("⚠️ 本机时钟与 %s 相差 %s（阈值 %s），本地日期和营业时间的换算可能出错", s.name, skew, m.threshold)
+			}
+		}
+		status.References = append(status.References, ref)
+	}
+	status.MaxSkewMs = maxSkew.Milliseconds()
+	status.CheckedAt = m.now().UTC()
+
+	m.mu.Lock()
+	previous := m.latest
+	m.latest = status
+	m.mu.Unlock()
+
+	if status.Status == ClockStatusWarning && (previous == nil || previous.Status != ClockStatusWarning) {
+		m.alert(ctx, status)
+	}
+	return status
+}
+
+// measure 读取一次参照时钟，返回偏差和往返耗时
+func (m *ClockMonitor) measure(ctx context.Context, source ClockSource) (time.Duration, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, clockReferenceTimeout)
+	defer cancel()
+
+	sent := m.now()
+	ref, err := source(ctx)
+	received := m.now()
+	if err != nil {
+		return 0, 0, err
+	}
+	rtt := received.Sub(sent)
+	return ref.Sub(sent.Add(rtt / 2)), rtt, nil
+}
+
+// alert 发送告警，失败只记录日志
+func (m *ClockMonitor) alert(ctx context.Context, status *models.ClockStatus) {
+	if m.alerter == nil {
+		return
+	}
+	alert := models.Alert{
+		Source:   "clock_check",
+		Severity: AlertWarning,
+		Title:    "服务器时钟偏差超过阈值",
+		Text:     fmt.Sprintf("本机时钟与参照时钟最多相差 %d ms（阈值 %d ms）", status.MaxSkewMs, status.ThresholdMs),
+		Details:  status,
+		At:       status.CheckedAt,
+	}
+	if err := m.alerter.Alert(ctx, alert); err != nil {
+		log.Printf("时钟偏差告警发送失败: %v", err)
+	}
+}
+
+// ntpEpochOffset NTP 时间戳（从 1900 年起）与 Unix 时间戳之间的秒数
+const ntpEpochOffset = 2208988800
+
+// NTPSource 以 SNTP（RFC 4330）查询 server 的时间，server 不带端口时使用 123
+// 返回服务器收到请求和发出响应的中点，排除服务器自身的处理时间
+func NTPSource(server string) ClockSource {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	return func(ctx context.Context) (time.Time, error) {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "udp", server)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("连接 NTP 服务器 %s 失败: %w", server, err)
+		}
+		defer conn.Close()
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+
+		// LI = 0，版本 4，模式 3（客户端）
+		req := make([]byte, 48)
+		req[0] = 0x23
+		if _, err := conn.Write(req); err != nil {
+			return time.Time{}, fmt.Errorf("发送 NTP 请求失败: %w", err)
+		}
+		resp := make([]byte, 48)
+		n, err := conn.Read(resp)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("读取 NTP 响应失败: %w", err)
+		}
+		if n < 48 {
+			return time.Time{}, errors.New("NTP 响应长度不足")
+		}
+		if mode := resp[0] & 0x07; mode != 4 {
+			return time.Time{}, fmt.Errorf("NTP 响应模式 %d 不是服务器模式", mode)
+		}
+		// 层级 0 是 Kiss-o'-Death，服务器拒绝提供时间
+		if resp[1] == 0 {
+			return time.Time{}, fmt.Errorf("NTP 服务器拒绝请求: %s", resp[12:16])
+		}
+		received := ntpTime(resp[32:40])
+		transmitted := ntpTime(resp[40:48])
+		return received.Add(transmitted.Sub(received) / 2), nil
+	}
+}
+
+// ntpTime 解析 64 位 NTP 时间戳：32 位秒和 32 位秒的小数部分
+func ntpTime(b []byte) time.Time {
+	seconds := int64(binary.BigEndian.Uint32(b[:4])) - ntpEpochOffset
+	fraction := int64(binary.BigEndian.Uint32(b[4:8]))
+	return time.Unix(seconds, fraction*int64(time.Second)>>32).UTC()
+}
@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/repository"
+)
+
+// 一致性检查的默认配置
+const (
+	// DefaultConsistencySampleSize 每次抽样重新计算派生字段的订单数
+	DefaultConsistencySampleSize = 500
+	// maxRecordedDiscrepancies 每次检查最多保存的差异明细，总数仍记录在 DiscrepancyCount 中
+	maxRecordedDiscrepancies = 1000
+)
+
+// 差异类型，派生字段差异使用视图列名
+const (
+	DiscrepancyRowCount        = "row_count"
+	DiscrepancyAmountSum       = "amount_sum"
+	DiscrepancyMissingMerchant = "missing_merchant"
+)
+
+// ConsistencyService 数据一致性检查
+// 按商户比较 dws_orders 与 dws_orders_analysis_view 的订单数和金额合计，
+// 并抽样在 Go 中重新计算本地日期等派生字段，与视图的结果对比；发现差异或检查失败时发送告警
+type ConsistencyService struct {
+	merchants  repository.MerchantRepository
+	checks     repository.ConsistencyRepository
+	alerter    Alerter
+	sampleSize int
+
+	// mu 保证定时检查和手动触发的检查不会同时执行
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+// NewConsistencyService 创建一致性检查服务，使用 PostgreSQL 仓储；alerter 为 nil 时不发送告警
+func NewConsistencyService(db *database.DB, alerter Alerter) *ConsistencyService {
+	return NewConsistencyServiceWithRepositories(
+		repository.NewPostgresMerchantRepository(db),
+		repository.NewPostgresConsistencyRepository(db),
+		alerter,
+	)
+}
+
+// NewConsistencyServiceWithRepositories 使用指定仓储创建一致性检查服务
+func NewConsistencyServiceWithRepositories(merchants repository.MerchantRepository, checks repository.ConsistencyRepository, alerter Alerter) *ConsistencyService {
+	return &ConsistencyService{
+		merchants:  merchants,
+		checks:     checks,
+		alerter:    alerter,
+		sampleSize: DefaultConsistencySampleSize,
+		now:        time.Now,
+	}
+}
+
+// SetSampleSize 设置每次抽样的订单数，为 0 时只比较合计
+func (s *ConsistencyService) SetSampleSize(n int) {
+	s.sampleSize = n
+}
+
+// Run 每隔 interval 检查一次，直到 ctx 取消
+// 启动时不立即检查，避免频繁重启时反复扫描全表
+func (s *ConsistencyService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		run, err := s.Check(ctx)
+		if err != nil {
+			log.Printf("一致性检查失败: %v", err)
+		} else if run.Status != models.ConsistencyStatusOK {
+			log.Printf("一致性检查 %d 发现 %d 处差异", run.RunID, run.DiscrepancyCount)
+		}
+	}
+}
+
+// Check 执行一次检查并保存结果
+// 查询失败时同样保存一条 failed 记录并告警；只有保存结果失败时返回错误
+func (s *ConsistencyService) Check(ctx context.Context) (*models.ConsistencyRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run := &models.ConsistencyRun{StartedAt: s.now().UTC(), Discrepancies: []models.ConsistencyDiscrepancy{}}
+	if err := s.check(ctx, run); err != nil {
+		run.Status = models.ConsistencyStatusFailed
+		run.Error = err.Error()
+	} else if run.DiscrepancyCount > 0 {
+		run.Status = models.ConsistencyStatusDiscrepancies
+	} else {
+		run.Status = models.ConsistencyStatusOK
+	}
+	run.FinishedAt = s.now().UTC()
+
+	if err := s.checks.SaveRun(ctx, run); err != nil {
+		return nil, err
+	}
+	if run.Status != models.ConsistencyStatusOK {
+		s.alert(ctx, run)
+	}
+	return run, nil
+}
+
+// Runs 最近 limit 次检查，不含差异明细
+func (s *ConsistencyService) Runs(ctx context.Context, limit int) ([]models.ConsistencyRun, error) {
+	runs, err := s.checks.Runs(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	if runs == nil {
+		runs = []models.ConsistencyRun{}
+	}
+	return runs, nil
+}
+
+// GetRun 一次检查及其差异明细，不存在时返回 ErrNotFound
+func (s *ConsistencyService) GetRun(ctx context.Context, runID int64) (*models.ConsistencyRun, error) {
+	return s.checks.Run(ctx, runID)
+}
+
+// check 比较合计并抽样核对派生字段，差异追加到 run
+func (s *ConsistencyService) check(ctx context.Context, run *models.ConsistencyRun) error {
+	totals, err := s.checks.MerchantTotals(ctx)
+	if err != nil {
+		return err
+	}
+	run.Merchants = len(totals)
+	for _, t := range totals {
+		if t.OrderCount != t.ViewCount {
+			s.record(run, models.ConsistencyDiscrepancy{
+				Kind:       DiscrepancyRowCount,
+				MerchantID: t.MerchantID,
+				Expected:   strconv.FormatInt(t.OrderCount, 10),
+				Actual:     strconv.FormatInt(t.ViewCount, 10),
+			})
+		}
+		if !t.OrderAmount.Equal(t.ViewAmount) {
+			s.record(run, models.ConsistencyDiscrepancy{
+				Kind:       DiscrepancyAmountSum,
+				MerchantID: t.MerchantID,
+				Expected:   t.OrderAmount.String(),
+				Actual:     t.ViewAmount.String(),
+			})
+		}
+	}
+
+	if s.sampleSize <= 0 {
+		return nil
+	}
+	orders, err := s.checks.SampleOrders(ctx, s.sampleSize)
+	if err != nil {
+		return err
+	}
+	merchants, err := s.merchants.List()
+	if err != nil {
+		return err
+	}
+	byID := make(map[int]models.Merchant, len(merchants))
+	for _, m := range merchants {
+		byID[m.ID] = m
+	}
+
+	run.SampledOrders = len(orders)
+	for _, order := range orders {
+		orderID := order.OrderID
+		merchant, ok := byID[order.MerchantID]
+		if !ok {
+			s.record(run, models.ConsistencyDiscrepancy{
+				Kind:       DiscrepancyMissingMerchant,
+				MerchantID: order.MerchantID,
+				OrderID:    &orderID,
+				Expected:   "dim_merchant",
+				Actual:     "",
+			})
+			continue
+		}
+		fields, err := derivedLocalFields(merchant, order.OrderTimeUTC)
+		if err != nil {
+			return fmt.Errorf("订单 %d: %w", order.OrderID, err)
+		}
+		for _, f := range compareLocalFields(fields, order) {
+			f.MerchantID = order.MerchantID
+			f.OrderID = &orderID
+			s.record(run, f)
+		}
+	}
+	return nil
+}
+
+// record 记录一处差异，超过 maxRecordedDiscrepancies 后只计数
+func (s *ConsistencyService) record(run *models.ConsistencyRun, d models.ConsistencyDiscrepancy) {
+	run.DiscrepancyCount++
+	if len(run.Discrepancies) < maxRecordedDiscrepancies {
+		run.Discrepancies = append(run.Discrepancies, d)
+	}
+}
+
+// alert 发送告警，失败只记录日志
+func (s *ConsistencyService) alert(ctx context.Context, run *models.ConsistencyRun) {
+	if s.alerter == nil {
+		return
+	}
+	alert := models.Alert{
+		Source:   "consistency_check",
+		Severity: AlertWarning,
+		Title:    "订单数据一致性检查发现差异",
+		Text:     fmt.Sprintf("一致性检查 %d：%d 个商户、抽样 %d 笔订单，发现 %d 处差异", run.RunID, run.Merchants, run.SampledOrders, run.DiscrepancyCount),
+		Details:  run,
+		At:       run.FinishedAt,
+	}
+	if run.Status == models.ConsistencyStatusFailed {
+		alert.Severity = AlertCritical
+		alert.Title = "订单数据一致性检查失败"
+		alert.Text = fmt.Sprintf("一致性检查 %d 失败: %s", run.RunID, run.Error)
+	}
+	if err := s.alerter.Alert(ctx, alert); err != nil {
+		log.Printf("一致性检查 %d 告警发送失败: %v", run.RunID, err)
+	}
+}
+
+// localFields 按商户时区、营业时间和周末重新计算的派生字段，与分析视图的列一一对应
+type localFields struct {
+	LocalDate      string
+	LocalHour      int
+	LocalDayOfWeek int
+	IsWeekend      bool
+	IsBusinessHour bool
+	TimezoneOffset int
+}
+
+// derivedLocalFields 按分析视图的规则在 Go 中计算订单的本地时间字段
+func derivedLocalFields(merchant models.Merchant, orderTimeUTC time.Time) (localFields, error) {
+	loc, err := LoadLocation(merchant.Timezone)
+	if err != nil {
+		return localFields{}, err
+	}
+	hours, err := MerchantBusinessHours(merchant)
+	if err != nil {
+		return localFields{}, err
+	}
+
+	local := orderTimeUTC.In(loc)
+	_, offset := local.Zone()
+	return localFields{
+		LocalDate:      local.Format("2006-01-02"),
+		LocalHour:      local.Hour(),
+		LocalDayOfWeek: int(local.Weekday()),
+		IsWeekend:      hours.Weekend.Contains(local.Weekday()),
+		IsBusinessHour: hours.IsOpen(local),
+		TimezoneOffset: offset,
+	}, nil
+}
+
+// compareLocalFields 比较重新计算的字段和视图中的值，返回不一致的字段
+func compareLocalFields(expected localFields, order models.OrderAnalysis) []models.ConsistencyDiscrepancy {
+	var diffs []models.ConsistencyDiscrepancy
+	add := func(kind, want, got string) {
+		if want != got {
+			diffs = append(diffs, models.ConsistencyDiscrepancy{Kind: kind, Expected: want, Actual: got})
+		}
+	}
+	add("local_date", expected.LocalDate, order.LocalDate)
+	add("local_hour", strconv.Itoa(expected.LocalHour), strconv.Itoa(order.LocalHour))
+	add("local_day_of_week", strconv.Itoa(expected.LocalDayOfWeek), strconv.Itoa(order.LocalDayOfWeek))
+	add("is_weekend", strconv.FormatBool(expected.IsWeekend), strconv.FormatBool(order.IsWeekend))
+	add("is_business_hour", strconv.FormatBool(expected.IsBusinessHour), strconv.FormatBool(order.IsBusinessHour))
+	add("timezone_offset", strconv.Itoa(expected.TimezoneOffset), strconv.Itoa(order.TimezoneOffset))
+	return diffs
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/schedule"
+)
+
+// MaxConversionItems 一次批量转换的最大项数
+const MaxConversionItems = 10000
+
+// conversionTimeLayout 本地时间的输出格式，有小数秒时保留
+const conversionTimeLayout = "2006-01-02T15:04:05.999999999"
+
+// localTimestampLayouts 不带偏移的本地时间可接受的格式，秒后可带小数
+var localTimestampLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+}
+
+// ConvertTimestamps 批量转换时区，纯 Go 计算，不访问数据库
+// 单项出错只记录在该项的 Error 中，不影响其他项；gap/overlap 决定来源本地时间遇到夏令时切换时的取舍
+func (s *TimezoneService) ConvertTimestamps(items []models.ConversionItem, gap, overlap string) (*models.BatchConversion, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%w: 转换列表为空", ErrInvalidArgument)
+	}
+	if len(items) > MaxConversionItems {
+		return nil, fmt.Errorf("%w: 一次最多转换 %d 项，实际 %d 项", ErrInvalidArgument, MaxConversionItems, len(items))
+	}
+	gapPolicy, err := schedule.ParseGapPolicy(gap)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	overlapPolicy, err := schedule.ParseOverlapPolicy(overlap)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	if overlapPolicy == schedule.OverlapBoth {
+		return nil, fmt.Errorf("%w: 转换只能返回一个时刻，overlap 可选 earlier、later", ErrInvalidArgument)
+	}
+	opts := schedule.Options{Gap: gapPolicy, Overlap: overlapPolicy}
+
+	result := &models.BatchConversion{
+		Count:   len(items),
+		Results: make([]models.ConversionResult, len(items)),
+	}
+	for i, item := range items {
+		r := &result.Results[i]
+		r.Index = i
+		r.ConversionItem = item
+		if err := convertTimestamp(r, opts); err != nil {
+			r.Error = err.Error()
+			result.Failed++
+		}
+	}
+	return result, nil
+}
+
+// convertTimestamp 转换一项，结果写入 r
+func convertTimestamp(r *models.ConversionResult, opts schedule.Options) error {
+	from, err := LoadLocation(r.FromTZ)
+	if err != nil {
+		return fmt.Errorf("from_tz: %v", err)
+	}
+	to, err := LoadLocation(r.ToTZ)
+	if err != nil {
+		return fmt.Errorf("to_tz: %v", err)
+	}
+
+	instant, err := time.Parse(time.RFC3339Nano, r.Timestamp)
+	if err != nil {
+		wall, ok := parseLocalTimestamp(r.Timestamp)
+		if !ok {
+			return fmt.Errorf("时间格式错误 %q，应为 RFC3339 或 2006-01-02T15:04:05 形式的本地时间", r.Timestamp)
+		}
+		occurrences := schedule.Resolve(wall, from, opts)
+		if len(occurrences) == 0 {
+			return fmt.Errorf("本地时间 %s 在 %s 因夏令时跳过而不存在", r.Timestamp, r.FromTZ)
+		}
+		instant = occurrences[0].Time
+		r.Shifted = occurrences[0].Shifted
+		r.Ambiguous = occurrences[0].Ambiguous
+	}
+
+	fromSide := conversionSide(instant.In(from), r.FromTZ)
+	toSide := conversionSide(instant.In(to), r.ToTZ)
+	r.UTC = instant.UTC().Format(time.RFC3339Nano)
+	r.From = &fromSide
+	r.To = &toSide
+	r.DayShift = civilDays(instant.In(to)) - civilDays(instant.In(from))
+	return nil
+}
+
+// parseLocalTimestamp 解析不带偏移的本地时间，结果以 UTC 字段表示
+func parseLocalTimestamp(value string) (time.Time, bool) {
+	for _, layout := range localTimestampLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// conversionSide 时刻在某个时区的本地时间
+func conversionSide(local time.Time, timezone string) models.ConversionSide {
+	abbreviation, offset := local.Zone()
+	return models.ConversionSide{
+		Timezone:      timezone,
+		LocalTime:     local.Format(conversionTimeLayout),
+		LocalDate:     local.Format("2006-01-02"),
+		Offset:        formatOffset(offset),
+		OffsetSeconds: offset,
+		Abbreviation:  abbreviation,
+		IsDST:         local.IsDST(),
+	}
+}
+
+// civilDays 本地日期距 1970-01-01 的天数，用于计算跨日
+func civilDays(local time.Time) int {
+	date := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	return int(date.Unix() / 86400)
+}
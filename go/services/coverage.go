@@ -0,0 +1,258 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"timezone-saas-demo/geo"
+	"timezone-saas-demo/models"
+)
+
+const (
+	// defaultCoverageLimit 默认返回的候选时区数量
+	defaultCoverageLimit = 5
+	// maxCoverageLimit 最多返回的候选时区数量
+	maxCoverageLimit = 20
+	// coverageCandidateCities 每个候选时区列出的参考城市数量
+	coverageCandidateCities = 3
+)
+
+// CoverageOptions 商户组合营业时间覆盖分析参数
+type CoverageOptions struct {
+	// Date UTC 日期 YYYY-MM-DD，为空时使用 UTC 的今天；周末按各商户自己的周末配置不营业
+	Date string
+	// Limit 返回的候选时区数量，为 0 时使用 5
+	Limit int
+}
+
+// coverageCandidate 按营业区间合并后的候选时区：同一天营业区间相同的时区效果相同
+type coverageCandidate struct {
+	timezones []string
+	cities    []string
+	open      []TimeRange
+}
+
+// BusinessHoursCoverage 计算全部商户在一个 UTC 日内的营业时间覆盖：每个 UTC 小时营业的商户数、
+// 没有任何商户营业的空档和营业商户最多的小时，并评估在参考城市的哪个时区新增一个商户
+// （按默认营业时间和周末）能填补最多的空档
+func (s *TimezoneService) BusinessHoursCoverage(opts CoverageOptions) (*models.BusinessHoursCoverage, error) {
+	if opts.Limit <= 0 {
+		opts.Limit = defaultCoverageLimit
+	}
+	if opts.Limit > maxCoverageLimit {
+		opts.Limit = maxCoverageLimit
+	}
+	day, err := localDay(opts.Date, time.UTC, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	opts.Date = day.From
+	rangeStart, rangeEnd := day.Start, day.End
+
+	merchants, err := s.merchants.List()
+	if err != nil {
+		return nil, err
+	}
+
+	// open[i] 为第 i 分钟营业的商户数，hourly[h] 为该小时内营业过的商户
+	open := make([]int, 24*60)
+	hourly := make([]map[int]bool, 24)
+	for h := range hourly {
+		hourly[h] = map[int]bool{}
+	}
+	used := map[string]bool{}
+	for _, merchant := range merchants {
+		loc, err := LoadLocation(merchant.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		hours, err := MerchantBusinessHours(merchant)
+		if err != nil {
+			return nil, err
+		}
+		used[merchant.Timezone] = true
+		for _, r := range hours.Intervals(rangeStart, rangeEnd, loc) {
+			from, to := coverageMinutes(r, rangeStart)
+			for i := from; i < to; i++ {
+				open[i]++
+				hourly[i/60][merchant.ID] = true
+			}
+		}
+	}
+
+	result := &models.BusinessHoursCoverage{
+		Date:          opts.Date,
+		RangeStartUTC: rangeStart,
+		RangeEndUTC:   rangeEnd,
+		MerchantCount: len(merchants),
+		Hours:         make([]models.CoverageHour, 0, 24),
+		GapHours:      []int{},
+		Gaps:          []models.TimeInterval{},
+		PeakHours:     []int{},
+		Candidates:    []models.CoverageCandidate{},
+	}
+	for h := 0; h < 24; h++ {
+		hour := models.CoverageHour{HourUTC: h, OpenMerchants: len(hourly[h])}
+		for _, n := range open[h*60 : (h+1)*60] {
+			if n > 0 {
+				hour.CoveredMinutes++
+			}
+		}
+		result.Hours = append(result.Hours, hour)
+		if hour.CoveredMinutes == 0 {
+			result.GapHours = append(result.GapHours, h)
+		}
+		switch {
+		case hour.OpenMerchants == 0:
+		case hour.OpenMerchants > result.PeakOpenMerchants:
+			result.PeakOpenMerchants = hour.OpenMerchants
+			result.PeakHours = []int{h}
+		case hour.OpenMerchants == result.PeakOpenMerchants:
+			result.PeakHours = append(result.PeakHours, h)
+		}
+	}
+	for _, gap := range coverageGaps(open, rangeStart) {
+		result.Gaps = append(result.Gaps, coverageInterval(gap))
+		result.GapMinutes += int(gap.Duration() / time.Minute)
+	}
+	if result.GapMinutes == 0 {
+		return result, nil
+	}
+
+	candidates, err := coverageCandidates(used, rangeStart, rangeEnd)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range candidates {
+		candidate := models.CoverageCandidate{
+			Timezone:            c.timezones[0],
+			EquivalentTimezones: c.timezones[1:],
+			Cities:              c.cities,
+			BusinessHours:       DefaultBusinessHours.String(),
+			Open:                []models.TimeInterval{},
+			FilledHours:         []int{},
+		}
+		filledHours := map[int]bool{}
+		for _, r := range c.open {
+			candidate.Open = append(candidate.Open, coverageInterval(r))
+			from, to := coverageMinutes(r, rangeStart)
+			for i := from; i < to; i++ {
+				if open[i] == 0 {
+					candidate.FilledMinutes++
+					filledHours[i/60] = true
+				}
+			}
+		}
+		if candidate.FilledMinutes == 0 {
+			continue
+		}
+		for h := range filledHours {
+			candidate.FilledHours = append(candidate.FilledHours, h)
+		}
+		sort.Ints(candidate.FilledHours)
+		candidate.RemainingGapMinutes = result.GapMinutes - candidate.FilledMinutes
+		result.Candidates = append(result.Candidates, candidate)
+	}
+
+	sort.SliceStable(result.Candidates, func(i, j int) bool {
+		if result.Candidates[i].FilledMinutes != result.Candidates[j].FilledMinutes {
+			return result.Candidates[i].FilledMinutes > result.Candidates[j].FilledMinutes
+		}
+		return result.Candidates[i].Timezone < result.Candidates[j].Timezone
+	})
+	if len(result.Candidates) > opts.Limit {
+		result.Candidates = result.Candidates[:opts.Limit]
+	}
+	for i := range result.Candidates {
+		result.Candidates[i].Rank = i + 1
+	}
+	return result, nil
+}
+
+// coverageCandidates 参考城市中商户尚未使用的时区，按默认营业时间计算当天的营业区间；
+// 营业区间相同的时区合并为一个候选，参考城市最多的时区作为代表
+func coverageCandidates(used map[string]bool, rangeStart, rangeEnd time.Time) ([]coverageCandidate, error) {
+	cities := map[string][]string{}
+	var zones []string
+	for _, city := range geo.Cities() {
+		if used[city.Timezone] {
+			continue
+		}
+		if _, ok := cities[city.Timezone]; !ok {
+			zones = append(zones, city.Timezone)
+		}
+		cities[city.Timezone] = append(cities[city.Timezone], city.NameZH)
+	}
+	sort.SliceStable(zones, func(i, j int) bool {
+		if len(cities[zones[i]]) != len(cities[zones[j]]) {
+			return len(cities[zones[i]]) > len(cities[zones[j]])
+		}
+		return zones[i] < zones[j]
+	})
+
+	var candidates []coverageCandidate
+	index := map[string]int{}
+	for _, zone := range zones {
+		loc, err := LoadLocation(zone)
+		if err != nil {
+			return nil, err
+		}
+		open := DefaultBusinessHours.Intervals(rangeStart, rangeEnd, loc)
+		key := coverageKey(open)
+		if i, ok := index[key]; ok {
+			candidates[i].timezones = append(candidates[i].timezones, zone)
+			continue
+		}
+		names := cities[zone]
+		if len(names) > coverageCandidateCities {
+			names = names[:coverageCandidateCities]
+		}
+		index[key] = len(candidates)
+		candidates = append(candidates, coverageCandidate{timezones: []string{zone}, cities: names, open: open})
+	}
+	return candidates, nil
+}
+
+// coverageGaps 没有任何商户营业的连续区间
+func coverageGaps(open []int, rangeStart time.Time) []TimeRange {
+	var gaps []TimeRange
+	for i := 0; i < len(open); i++ {
+		if open[i] > 0 {
+			continue
+		}
+		j := i
+		for j < len(open) && open[j] == 0 {
+			j++
+		}
+		gaps = append(gaps, TimeRange{
+			Start: rangeStart.Add(time.Duration(i) * time.Minute),
+			End:   rangeStart.Add(time.Duration(j) * time.Minute),
+		})
+		i = j
+	}
+	return gaps
+}
+
+// coverageMinutes 区间在当天的起止分钟，区间已裁剪到当天
+func coverageMinutes(r TimeRange, rangeStart time.Time) (int, int) {
+	return int(r.Start.Sub(rangeStart) / time.Minute), int(r.End.Sub(rangeStart) / time.Minute)
+}
+
+// coverageKey 营业区间的比较键
+func coverageKey(ranges []TimeRange) string {
+	var b strings.Builder
+	for _, r := range ranges {
+		fmt.Fprintf(&b, "%d-%d;", r.Start.Unix(), r.End.Unix())
+	}
+	return b.String()
+}
+
+func coverageInterval(r TimeRange) models.TimeInterval {
+	return models.TimeInterval{
+		StartUTC:        r.Start.UTC(),
+		EndUTC:          r.End.UTC(),
+		DurationMinutes: int(r.Duration() / time.Minute),
+	}
+}
@@ -0,0 +1,47 @@
+package services_test
+
+import (
+	"testing"
+	"time"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/services"
+	"timezone-saas-demo/testsupport"
+)
+
+// TestBusinessHoursCoverageMidnightGap 2024-09-08 圣地亚哥零点不存在：商户和参考城市的营业区间都要能展开，
+// 商户 00:00-06:00 营业当天从 01:00 -03（04:00Z）开始
+func TestBusinessHoursCoverageMidnightGap(t *testing.T) {
+	fakes := testsupport.NewFakes()
+	merchant := testsupport.NewMerchant(1, "圣地亚哥零售", "America/Santiago", "智利", "圣地亚哥")
+	merchant.BusinessHoursStart, merchant.BusinessHoursEnd = "00:00", "06:00"
+	merchant.WeekendDays = []int{}
+	fakes.Merchants.Add(merchant)
+	svc := fakes.Service()
+
+	done := make(chan error, 1)
+	var coverage *models.BusinessHoursCoverage
+	go func() {
+		var err error
+		coverage, err = svc.BusinessHoursCoverage(services.CoverageOptions{Date: "2024-09-08"})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("营业时间覆盖分析失败: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("营业时间覆盖分析未结束")
+	}
+
+	for h, hour := range coverage.Hours {
+		open := h >= 4 && h < 9
+		if (hour.OpenMerchants == 1) != open || (hour.CoveredMinutes == 60) != open {
+			t.Errorf("%02d 点（UTC）营业商户 %d 覆盖 %d 分钟, 期望营业 %v", h, hour.OpenMerchants, hour.CoveredMinutes, open)
+		}
+	}
+	if coverage.GapMinutes != 19*60 || len(coverage.Candidates) == 0 {
+		t.Errorf("空档 %d 分钟, 候选时区 %d 个, 期望空档 %d 分钟且有候选", coverage.GapMinutes, len(coverage.Candidates), 19*60)
+	}
+}
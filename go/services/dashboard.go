@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/money"
+	"timezone-saas-demo/repository"
+	"timezone-saas-demo/timerange"
+)
+
+// dashboardHours 首页概览返回的小时点个数，包括当前未结束的小时
+const dashboardHours = 24
+
+// DashboardService 首页概览：把首屏需要的今天订单、昨天对比、商户本地时间和营业状态、最近 24 小时合并为一次请求
+// 订单统计只有一次数据库往返，营业状态在内存中按商户配置计算
+type DashboardService struct {
+	dashboard repository.DashboardRepository
+	merchants repository.MerchantRepository
+	revenue   models.RevenueDefinition
+	now       func() time.Time
+}
+
+// NewDashboardService 创建首页概览服务，使用 PostgreSQL 仓储
+func NewDashboardService(db *database.DB) *DashboardService {
+	return NewDashboardServiceWithRepositories(
+		repository.NewPostgresDashboardRepository(db),
+		repository.NewPostgresMerchantRepository(db),
+	)
+}
+
+// NewDashboardServiceWithRepositories 使用指定仓储创建首页概览服务
+func NewDashboardServiceWithRepositories(dashboard repository.DashboardRepository, merchants repository.MerchantRepository) *DashboardService {
+	return &DashboardService{
+		dashboard: dashboard,
+		merchants: merchants,
+		revenue:   DefaultRevenueDefinition,
+		now:       time.Now,
+	}
+}
+
+// SetRevenueDefinition 设置未指定订单状态时排除的状态，与分析接口的营收口径一致
+// 首页概览只统计订单金额，不扣除退款
+func (s *DashboardService) SetRevenueDefinition(revenue models.RevenueDefinition) {
+	s.revenue = revenue
+}
+
+// Summary 按商户时区生成首页概览
+// 今天为本地零点至当前；昨天同期为昨天零点至昨天同一本地时刻，用于计算变化百分比；
+// 本地时刻按 time.Date 构造，夏令时切换的日子与昨天对应的是同一墙上时间
+func (s *DashboardService) Summary(ctx context.Context, merchantID int, currency string, statuses []string) (*models.DashboardSummary, error) {
+	if currency != "" {
+		code, err := money.ParseCode(currency)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+		}
+		currency = code
+	}
+	merchant, err := s.merchants.Get(merchantID)
+	if err != nil {
+		return nil, err
+	}
+	boundaries, err := ComputeBoundaries(*merchant, s.now().UTC().Truncate(time.Second))
+	if err != nil {
+		return nil, err
+	}
+	loc, err := LoadLocation(merchant.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	now := boundaries.AtUTC
+	local := now.In(loc)
+	today := timerange.Today(loc, now)
+	filter := models.DashboardFilter{
+		MerchantID:     merchant.ID,
+		TodayStart:     timerange.StartOfDay(today, loc).UTC(),
+		YesterdayStart: timerange.StartOfDay(today.AddDate(0, 0, -1), loc).UTC(),
+		YesterdayAt:    time.Date(local.Year(), local.Month(), local.Day()-1, local.Hour(), local.Minute(), local.Second(), 0, loc).UTC(),
+		// 减去本地分钟和秒得到当前本地整点，偏移不是整小时的时区（如 Asia/Kolkata）同样对齐本地小时
+		HourlyStart: now.Add(-time.Duration(local.Minute()*60+local.Second())*time.Second - (dashboardHours-1)*time.Hour),
+		Now:         now,
+		Currency:    currency,
+		Statuses:    countedStatuses(s.revenue, statuses),
+	}
+
+	aggregates, err := s.dashboard.Summary(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	abbreviation, offset := local.Zone()
+	summary := &models.DashboardSummary{
+		MerchantID:       merchant.ID,
+		MerchantName:     merchant.Name,
+		Timezone:         merchant.Timezone,
+		GeneratedAt:      now,
+		LocalDate:        local.Format("2006-01-02"),
+		LocalTime:        boundaries.AtLocal,
+		Offset:           formatOffset(offset),
+		Abbreviation:     abbreviation,
+		IsDST:            local.IsDST(),
+		BusinessHours:    boundaries.BusinessHours,
+		IsOpen:           boundaries.IsOpen,
+		NextOpen:         boundaries.NextOpen,
+		NextClose:        boundaries.NextClose,
+		Currency:         currency,
+		Statuses:         filter.Statuses,
+		TotalsByCurrency: dashboardTotals(aggregates),
+		Hourly:           dashboardHourly(aggregates.Hourly, filter.HourlyStart, loc),
+	}
+	for _, t := range summary.TotalsByCurrency {
+		summary.TotalOrders += t.Today.OrderCount
+		summary.YesterdaySameTimeOrders += t.YesterdaySameTime.OrderCount
+	}
+	return summary, nil
+}
+
+// dashboardTotals 按币种合并今天、昨天同期和昨天全天的汇总，按币种代码排序
+func dashboardTotals(aggregates *models.DashboardAggregates) []models.DashboardCurrencyTotal {
+	totals := []models.DashboardCurrencyTotal{}
+	index := map[string]int{}
+	get := func(currency string) *models.DashboardCurrencyTotal {
+		i, ok := index[currency]
+		if !ok {
+			i = len(totals)
+			index[currency] = i
+			totals = append(totals, models.DashboardCurrencyTotal{Currency: currency})
+		}
+		return &totals[i]
+	}
+	dashboardTotal := func(t models.CurrencyTotal) models.DashboardTotals {
+		return models.DashboardTotals{OrderCount: t.OrderCount, TotalAmount: money.Round(t.GrossAmount, t.Currency)}
+	}
+	for _, t := range aggregates.Today {
+		get(t.Currency).Today = dashboardTotal(t)
+	}
+	for _, t := range aggregates.YesterdaySameTime {
+		get(t.Currency).YesterdaySameTime = dashboardTotal(t)
+	}
+	for _, t := range aggregates.Yesterday {
+		get(t.Currency).Yesterday = dashboardTotal(t)
+	}
+
+	for i := range totals {
+		t := &totals[i]
+		t.OrderChangePercent = changePercent(decimal.NewFromInt(int64(t.Today.OrderCount)), decimal.NewFromInt(int64(t.YesterdaySameTime.OrderCount)))
+		t.AmountChangePercent = changePercent(t.Today.TotalAmount, t.YesterdaySameTime.TotalAmount)
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].Currency < totals[j].Currency })
+	return totals
+}
+
+// changePercent current 相对 baseline 的变化百分比，保留一位小数；baseline 为 0 时返回 nil
+func changePercent(current, baseline decimal.Decimal) *decimal.Decimal {
+	if baseline.IsZero() {
+		return nil
+	}
+	p := current.Sub(baseline).Mul(decimal.NewFromInt(100)).Div(baseline).Round(1)
+	return &p
+}
+
+// dashboardHourly 从 start 开始的 dashboardHours 个小时点，没有订单的小时也返回
+func dashboardHourly(hourly []models.DashboardHourlyTotal, start time.Time, loc *time.Location) []models.DashboardHourlyPoint {
+	points := make([]models.DashboardHourlyPoint, dashboardHours)
+	for i := range points {
+		at := start.Add(time.Duration(i) * time.Hour)
+		points[i] = models.DashboardHourlyPoint{
+			StartUTC:    at,
+			Local:       at.In(loc).Format("2006-01-02 15:04"),
+			Hour:        at.In(loc).Hour(),
+			TotalAmount: decimal.Zero,
+		}
+	}
+	for _, h := range hourly {
+		i := int(h.HourStart.Sub(start) / time.Hour)
+		if i < 0 || i >= len(points) {
+			continue
+		}
+		p := &points[i]
+		if p.OrderCount == 0 || p.Currency == h.Currency {
+			p.Currency = h.Currency
+		} else {
+			p.Currency = ""
+		}
+		p.OrderCount += h.OrderCount
+		p.TotalAmount = p.TotalAmount.Add(h.Amount)
+	}
+	for i := range points {
+		points[i].TotalAmount = money.Round(points[i].TotalAmount, points[i].Currency)
+	}
+	return points
+}
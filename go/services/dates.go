@@ -0,0 +1,53 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"timezone-saas-demo/timerange"
+)
+
+// parseLocalDate 解析本地日期 YYYY-MM-DD，返回该日期的 UTC 零点，只用于按日历计算；格式错误时返回 ErrInvalidArgument
+func parseLocalDate(value string) (time.Time, error) {
+	date, err := timerange.ParseDate(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	return date, nil
+}
+
+// localDay loc 中本地日期 date 的范围，也接受 today、yesterday，为空时为 now 所在的本地日期
+func localDay(date string, loc *time.Location, now time.Time) (timerange.Range, error) {
+	if date == "" {
+		date = "today"
+	}
+	day, err := timerange.Parse(timerange.Params{Date: date}, loc, now)
+	if err != nil {
+		return timerange.Range{}, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	return day, nil
+}
+
+// localDates loc 中本地日期 from~to（含两端）的范围，to 早于 from 时返回 ErrInvalidArgument
+func localDates(from, to string, loc *time.Location) (timerange.Range, error) {
+	dates, err := timerange.Between(from, to, loc)
+	if err != nil {
+		return timerange.Range{}, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	return dates, nil
+}
+
+// recentDates loc 中本地日期 from~to（含两端）的范围：to 为空时为 defaultTo，from 为空时为截至 to 的最近 days 天
+func recentDates(from, to string, defaultTo time.Time, days int, loc *time.Location) (timerange.Range, error) {
+	if to == "" {
+		to = defaultTo.Format(timerange.Layout)
+	}
+	if from == "" {
+		end, err := parseLocalDate(to)
+		if err != nil {
+			return timerange.Range{}, err
+		}
+		from = end.AddDate(0, 0, 1-days).Format(timerange.Layout)
+	}
+	return localDates(from, to, loc)
+}
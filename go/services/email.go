@@ -0,0 +1,330 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/locale"
+	"timezone-saas-demo/mailtemplate"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/repository"
+)
+
+// 邮件模板的来源
+const (
+	EmailTemplateBuiltin = "builtin"
+	EmailTemplateTenant  = "tenant"
+)
+
+// maxEmailTemplateSize 模板各部分的最大字节数
+const maxEmailTemplateSize = 64 * 1024
+
+// ErrMailDisabled 未配置 SMTP，邮件不发送
+var ErrMailDisabled = errors.New("未配置 SMTP_ADDR")
+
+// EmailTemplateRequest 保存租户邮件模板或预览草稿的请求
+type EmailTemplateRequest struct {
+	Subject  string `json:"subject"`
+	HTML     string `json:"html"`
+	Text     string `json:"text"`
+	Operator string `json:"operator"`
+}
+
+// EmailService 按模板渲染并发送告警、报表完成和商户入驻邮件
+// 模板按（租户，名称，语言）选择：租户覆盖的版本优先，其次是内置模板，收件商户的语言没有对应版本时使用中文；
+// 模板中的时刻按收件商户的时区格式化
+type EmailService struct {
+	templates repository.EmailTemplateRepository
+	settings  *SettingsService
+	mailer    Mailer
+}
+
+// NewEmailService 创建邮件服务，使用 PostgreSQL 仓储；商户语言从 settings 读取，mailer 为 nil 时不发送邮件
+func NewEmailService(db *database.DB, settings *SettingsService, mailer Mailer) *EmailService {
+	return NewEmailServiceWithRepositories(repository.NewPostgresEmailTemplateRepository(db), settings, mailer)
+}
+
+// NewEmailServiceWithRepositories 使用指定仓储创建邮件服务
+func NewEmailServiceWithRepositories(templates repository.EmailTemplateRepository, settings *SettingsService, mailer Mailer) *EmailService {
+	return &EmailService{templates: templates, settings: settings, mailer: mailer}
+}
+
+// Enabled 是否配置了邮件发送
+func (s *EmailService) Enabled() bool {
+	return s != nil && s.mailer != nil
+}
+
+// MerchantOptions 按商户的 locale 配置和时区渲染；读取配置失败时使用默认语言
+func (s *EmailService) MerchantOptions(merchant *models.Merchant) (mailtemplate.Options, error) {
+	loc, err := LoadLocation(merchant.Timezone)
+	if err != nil {
+		return mailtemplate.Options{}, err
+	}
+	opts := mailtemplate.Options{Locale: locale.Default, Location: loc}
+	if s.settings != nil {
+		if code, err := GetSetting(s.settings, merchant.ID, SettingLocale); err == nil {
+			opts.Locale = locale.For(code)
+		}
+	}
+	return opts, nil
+}
+
+// Send 渲染租户的模板并发送给 to；未配置 SMTP 时返回 ErrMailDisabled
+func (s *EmailService) Send(ctx context.Context, tenant, name string, to []string, opts mailtemplate.Options, data interface{}) error {
+	if !s.Enabled() {
+		return ErrMailDisabled
+	}
+	if len(to) == 0 {
+		return fmt.Errorf("%w: 邮件没有收件人", ErrInvalidArgument)
+	}
+	msg, err := s.Render(ctx, tenant, name, opts, data)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, Mail{To: to, Subject: msg.Subject, Body: msg.Text, HTML: msg.HTML})
+}
+
+// Render 按租户和语言选择模板并渲染；租户的模板渲染失败时记录日志并改用内置模板
+func (s *EmailService) Render(ctx context.Context, tenant, name string, opts mailtemplate.Options, data interface{}) (*mailtemplate.Message, error) {
+	t, err := s.resolve(ctx, tenant, name, opts.Locale.Code())
+	if err != nil {
+		return nil, err
+	}
+	// 没有该语言的模板时改用中文模板，日期和金额也按中文格式化，避免一封邮件中混用两种语言
+	if t.Locale != opts.Locale.Code() {
+		opts.Locale = locale.For(t.Locale)
+	}
+	msg, err := mailtemplate.Render(templateOf(t), opts, data)
+	if err == nil || t.Source == EmailTemplateBuiltin {
+		return msg, err
+	}
+	log.Printf("⚠️ 租户 %s 的邮件模板 %s（%s）渲染失败，改用内置模板: %v", tenant, name, t.Locale, err)
+	builtin, ok := builtinTemplate(name, opts.Locale.Code())
+	if !ok {
+		builtin, _ = builtinTemplate(name, locale.Default.Code())
+		opts.Locale = locale.Default
+	}
+	return mailtemplate.Render(templateOf(builtin), opts, data)
+}
+
+// resolve 依次查找租户的 lang 版本、内置的 lang 版本、租户的中文版本和内置的中文版本
+func (s *EmailService) resolve(ctx context.Context, tenant, name, lang string) (*models.EmailTemplate, error) {
+	if !isEmailTemplateName(name) {
+		return nil, fmt.Errorf("%w: 邮件模板 %s", ErrNotFound, name)
+	}
+	langs := []string{lang}
+	if lang != locale.Default.Code() {
+		langs = append(langs, locale.Default.Code())
+	}
+	for _, l := range langs {
+		t, err := s.templates.Template(ctx, tenant, name, l)
+		if err == nil {
+			return t, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		if t, ok := builtinTemplate(name, l); ok {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: 邮件模板 %s（%s）", ErrNotFound, name, lang)
+}
+
+// Templates ctx 中租户可用的全部模板：每个（名称，语言）一项，租户覆盖的版本替换内置版本
+func (s *EmailService) Templates(ctx context.Context) ([]models.EmailTemplate, error) {
+	tenant := TenantFromContext(ctx)
+	overrides, err := s.templates.Templates(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+	byKey := make(map[string]models.EmailTemplate)
+	for _, name := range mailtemplate.Names() {
+		for _, lang := range mailtemplate.Langs(name) {
+			t, _ := builtinTemplate(name, lang)
+			byKey[name+"/"+lang] = *t
+		}
+	}
+	for _, t := range overrides {
+		byKey[t.Name+"/"+t.Locale] = t
+	}
+
+	templates := make([]models.EmailTemplate, 0, len(byKey))
+	for _, t := range byKey {
+		t.Tenant = tenant
+		templates = append(templates, t)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		if templates[i].Name != templates[j].Name {
+			return templates[i].Name < templates[j].Name
+		}
+		return templates[i].Locale < templates[j].Locale
+	})
+	return templates, nil
+}
+
+// Template ctx 中租户发送 lang 语言的邮件时实际使用的模板，Locale 为模板本身的语言
+func (s *EmailService) Template(ctx context.Context, name, lang string) (*models.EmailTemplate, error) {
+	lang, err := parseTemplateLocale(lang)
+	if err != nil {
+		return nil, err
+	}
+	tenant := TenantFromContext(ctx)
+	t, err := s.resolve(ctx, tenant, name, lang)
+	if err != nil {
+		return nil, err
+	}
+	t.Tenant = tenant
+	return t, nil
+}
+
+// SaveTemplate 校验并保存 ctx 中租户的模板：使用示例数据渲染一次，引用不存在的字段或函数时返回 ErrInvalidArgument
+func (s *EmailService) SaveTemplate(ctx context.Context, name, lang string, req EmailTemplateRequest) (*models.EmailTemplate, error) {
+	lang, err := parseTemplateLocale(lang)
+	if err != nil {
+		return nil, err
+	}
+	if !isEmailTemplateName(name) {
+		return nil, fmt.Errorf("%w: 邮件模板 %s", ErrNotFound, name)
+	}
+	t := &models.EmailTemplate{
+		Tenant:    TenantFromContext(ctx),
+		Name:      name,
+		Locale:    lang,
+		Subject:   strings.TrimSpace(req.Subject),
+		HTML:      req.HTML,
+		Text:      req.Text,
+		Source:    EmailTemplateTenant,
+		UpdatedBy: operatorOrSystem(req.Operator),
+	}
+	if t.Subject == "" || strings.TrimSpace(t.HTML) == "" {
+		return nil, fmt.Errorf("%w: 邮件模板的 subject 和 html 不能为空", ErrInvalidArgument)
+	}
+	if len(t.Subject) > maxEmailTemplateSize || len(t.HTML) > maxEmailTemplateSize || len(t.Text) > maxEmailTemplateSize {
+		return nil, fmt.Errorf("%w: 邮件模板的每一部分不能超过 %d 字节", ErrInvalidArgument, maxEmailTemplateSize)
+	}
+	if _, err := renderSample(templateOf(t), name, lang, time.UTC); err != nil {
+		return nil, err
+	}
+	if err := s.templates.SaveTemplate(ctx, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// DeleteTemplate 删除 ctx 中租户覆盖的模板，恢复使用内置模板
+func (s *EmailService) DeleteTemplate(ctx context.Context, name, lang string) error {
+	lang, err := parseTemplateLocale(lang)
+	if err != nil {
+		return err
+	}
+	return s.templates.DeleteTemplate(ctx, TenantFromContext(ctx), name, lang)
+}
+
+// Preview 使用示例数据按 lang 和 timezone（缺省为示例商户的 Asia/Tokyo）渲染模板；draft 不为 nil 时渲染草稿，否则渲染租户实际使用的模板
+func (s *EmailService) Preview(ctx context.Context, name, lang, timezone string, draft *EmailTemplateRequest) (*mailtemplate.Message, error) {
+	lang, err := parseTemplateLocale(lang)
+	if err != nil {
+		return nil, err
+	}
+	if timezone == "" {
+		// 示例数据中商户的时区
+		timezone = "Asia/Tokyo"
+	}
+	loc, err := LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	var t mailtemplate.Template
+	if draft != nil {
+		if !isEmailTemplateName(name) {
+			return nil, fmt.Errorf("%w: 邮件模板 %s", ErrNotFound, name)
+		}
+		t = mailtemplate.Template{Subject: draft.Subject, HTML: draft.HTML, Text: draft.Text}
+	} else {
+		resolved, err := s.resolve(ctx, TenantFromContext(ctx), name, lang)
+		if err != nil {
+			return nil, err
+		}
+		t = templateOf(resolved)
+	}
+	return renderSample(t, name, lang, loc)
+}
+
+// renderSample 使用示例数据渲染模板，渲染失败时返回 ErrInvalidArgument
+func renderSample(t mailtemplate.Template, name, lang string, loc *time.Location) (*mailtemplate.Message, error) {
+	data, _ := mailtemplate.Sample(name)
+	msg, err := mailtemplate.Render(t, mailtemplate.Options{Locale: locale.For(lang), Location: loc}, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	return msg, nil
+}
+
+// builtinTemplate 内置模板转换为 models.EmailTemplate
+func builtinTemplate(name, lang string) (*models.EmailTemplate, bool) {
+	t, ok := mailtemplate.Builtin(name, lang)
+	if !ok {
+		return nil, false
+	}
+	return &models.EmailTemplate{
+		Name: name, Locale: lang, Subject: t.Subject, HTML: t.HTML, Text: t.Text, Source: EmailTemplateBuiltin,
+	}, true
+}
+
+// templateOf models.EmailTemplate 中的模板内容
+func templateOf(t *models.EmailTemplate) mailtemplate.Template {
+	return mailtemplate.Template{Subject: t.Subject, HTML: t.HTML, Text: t.Text}
+}
+
+// isEmailTemplateName 是否为内置模板的名称，租户只能覆盖内置模板
+func isEmailTemplateName(name string) bool {
+	for _, n := range mailtemplate.Names() {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTemplateLocale 校验模板的语言代码，缺省为默认语言
+func parseTemplateLocale(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return locale.Default.Code(), nil
+	}
+	for _, code := range locale.Supported() {
+		if code == value {
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("%w: 不支持的语言 %q，可选 %s", ErrInvalidArgument, value, strings.Join(locale.Supported(), ","))
+}
+
+// normalizeEmails 校验邮件地址，去掉显示名称和重复的地址，最多 max 个
+func normalizeEmails(values []string, max int) ([]string, error) {
+	emails := []string{}
+	seen := map[string]bool{}
+	for _, value := range values {
+		addr, err := mail.ParseAddress(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%w: 无效的邮件地址 %q", ErrInvalidArgument, value)
+		}
+		if !seen[addr.Address] {
+			seen[addr.Address] = true
+			emails = append(emails, addr.Address)
+		}
+	}
+	if len(emails) > max {
+		return nil, fmt.Errorf("%w: 收件人不能超过 %d 个", ErrInvalidArgument, max)
+	}
+	return emails, nil
+}
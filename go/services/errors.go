@@ -0,0 +1,23 @@
+package services
+
+import (
+	"errors"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/repository"
+)
+
+var (
+	// ErrInvalidArgument 调用方传入的参数不合法，HTTP 层映射为 400
+	ErrInvalidArgument = errors.New("参数错误")
+	// ErrNotFound 请求的资源不存在，HTTP 层映射为 404
+	// 与 repository.ErrNotFound 为同一个值，仓储返回的错误可直接透传
+	ErrNotFound = repository.ErrNotFound
+	// ErrConflict 与已有资源冲突，HTTP 层映射为 409
+	ErrConflict = repository.ErrConflict
+	// ErrOverloaded 租户的并发查询已满且排队超时，HTTP 层映射为 503 并带 Retry-After
+	ErrOverloaded = errors.New("服务繁忙")
+	// ErrUnavailable 数据库熔断中，请求未发送到数据库，HTTP 层映射为 503 并带 Retry-After
+	// 与 database.ErrCircuitOpen 为同一个值
+	ErrUnavailable = database.ErrCircuitOpen
+)
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"timezone-saas-demo/models"
+)
+
+// 故障类型
+const (
+	FaultLatency = "latency"
+	FaultError   = "error"
+	FaultDBDrop  = "db_drop"
+)
+
+// maxFaultLatency 单条规则的延迟上限，避免误配置后请求长时间挂起
+const maxFaultLatency = time.Minute
+
+// faultErrorStatuses error 规则未指定状态码时随机使用的状态码
+var faultErrorStatuses = []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable}
+
+// FaultRuleRequest 添加故障注入规则的请求
+type FaultRuleRequest struct {
+	Kind    string  `json:"kind"`
+	Percent float64 `json:"percent"`
+	Route   string  `json:"route"`
+	Method  string  `json:"method"`
+	Tenant  string  `json:"tenant"`
+	// Latency、Jitter、TTL 为 Go duration 格式，如 200ms、15m；TTL 为空时规则一直生效直到被删除
+	Latency string `json:"latency"`
+	Jitter  string `json:"jitter"`
+	Status  int    `json:"status"`
+	TTL     string `json:"ttl"`
+}
+
+// FaultDecision 一个请求要注入的故障
+type FaultDecision struct {
+	// Delay 处理请求前的延迟
+	Delay time.Duration
+	// Status 不为 0 时直接返回该状态码，不再处理请求
+	Status int
+	// DropDB 断开该请求使用的数据库连接
+	DropDB bool
+	// Rules 生效的规则ID
+	Rules []int64
+}
+
+// faultRule 故障注入规则及解析后的延迟
+type faultRule struct {
+	models.FaultRule
+	latency time.Duration
+	jitter  time.Duration
+}
+
+// FaultInjector 按规则为请求注入延迟、5xx 响应或断开数据库连接，供预发环境验证调用方的重试行为
+// 规则只保存在进程内存中，多实例部署时需要分别配置
+type FaultInjector struct {
+	mu     sync.Mutex
+	rules  []*faultRule
+	nextID int64
+	rng    *rand.Rand
+	now    func() time.Time
+}
+
+// NewFaultInjector 创建故障注入器，相同的种子和请求序列产生相同的注入结果
+func NewFaultInjector(seed int64) *FaultInjector {
+	return &FaultInjector{rng: rand.New(rand.NewSource(seed)), now: time.Now}
+}
+
+// Add 校验并添加一条规则
+func (f *FaultInjector) Add(req FaultRuleRequest) (models.FaultRule, error) {
+	rule, ttl, err := parseFaultRule(req)
+	if err != nil {
+		return models.FaultRule{}, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.nextID++
+	rule.ID = f.nextID
+	rule.CreatedAt = f.now().UTC()
+	if ttl > 0 {
+		expires := rule.CreatedAt.Add(ttl)
+		rule.ExpiresAt = &expires
+	}
+	f.rules = append(f.rules, rule)
+	return rule.FaultRule, nil
+}
+
+// Rules 当前生效的规则，按添加顺序
+func (f *FaultInjector) Rules() []models.FaultRule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.pruneLocked(f.now())
+	rules := make([]models.FaultRule, 0, len(f.rules))
+	for _, rule := range f.rules {
+		rules = append(rules, rule.FaultRule)
+	}
+	return rules
+}
+
+// Remove 删除一条规则
+func (f *FaultInjector) Remove(id int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, rule := range f.rules {
+		if rule.ID == id {
+			f.rules = append(f.rules[:i], f.rules[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: 故障注入规则 %d 不存在", ErrNotFound, id)
+}
+
+// Clear 删除全部规则，返回删除的数量
+func (f *FaultInjector) Clear() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := len(f.rules)
+	f.rules = nil
+	return n
+}
+
+// Decide 为一个请求抽取要注入的故障
+// latency 规则的延迟累加，error 规则只有第一条生效，db_drop 规则标记断开数据库连接
+func (f *FaultInjector) Decide(method, path, tenant string) FaultDecision {
+	var d FaultDecision
+	if f == nil {
+		return d
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.pruneLocked(f.now())
+	for _, rule := range f.rules {
+		if !rule.matches(method, path, tenant) {
+			continue
+		}
+		rule.Matched++
+		if f.rng.Float64()*100 >= rule.Percent {
+			continue
+		}
+		switch rule.Kind {
+		case FaultLatency:
+			delay := rule.latency
+			if rule.jitter > 0 {
+				delay += time.Duration(f.rng.Int63n(int64(rule.jitter) + 1))
+			}
+			d.Delay += delay
+		case FaultError:
+			if d.Status != 0 {
+				continue
+			}
+			d.Status = rule.Status
+			if d.Status == 0 {
+				d.Status = faultErrorStatuses[f.rng.Intn(len(faultErrorStatuses))]
+			}
+		case FaultDBDrop:
+			d.DropDB = true
+		}
+		rule.Injected++
+		d.Rules = append(d.Rules, rule.ID)
+	}
+	return d
+}
+
+// pruneLocked 删除已到期的规则，调用方需持有锁
+func (f *FaultInjector) pruneLocked(now time.Time) {
+	kept := f.rules[:0]
+	for _, rule := range f.rules {
+		if rule.ExpiresAt == nil || now.Before(*rule.ExpiresAt) {
+			kept = append(kept, rule)
+		}
+	}
+	for i := len(kept); i < len(f.rules); i++ {
+		f.rules[i] = nil
+	}
+	f.rules = kept
+}
+
+// matches 请求是否满足规则的路由、方法和租户条件
+func (r *faultRule) matches(method, path, tenant string) bool {
+	return strings.HasPrefix(path, r.Route) &&
+		(r.Method == "" || r.Method == method) &&
+		(r.Tenant == "" || r.Tenant == tenant)
+}
+
+// parseFaultRule 校验请求并转换为规则，同时返回有效期
+func parseFaultRule(req FaultRuleRequest) (*faultRule, time.Duration, error) {
+	rule := &faultRule{FaultRule: models.FaultRule{
+		Kind:    req.Kind,
+		Percent: req.Percent,
+		Route:   req.Route,
+		Method:  strings.ToUpper(req.Method),
+		Tenant:  req.Tenant,
+	}}
+
+	if req.Percent <= 0 || req.Percent > 100 {
+		return nil, 0, fmt.Errorf("%w: percent 应在 0~100 之间且大于 0", ErrInvalidArgument)
+	}
+	if rule.Route == "" {
+		rule.Route = "/api/"
+	}
+	if !strings.HasPrefix(rule.Route, "/") {
+		return nil, 0, fmt.Errorf("%w: route 应为以 / 开头的路径前缀: %q", ErrInvalidArgument, req.Route)
+	}
+
+	var err error
+	switch req.Kind {
+	case FaultLatency:
+		if rule.latency, err = parseFaultDuration("latency", req.Latency); err != nil {
+			return nil, 0, err
+		}
+		if rule.jitter, err = parseFaultDuration("jitter", req.Jitter); err != nil {
+			return nil, 0, err
+		}
+		if rule.latency+rule.jitter == 0 {
+			return nil, 0, fmt.Errorf("%w: latency 规则需要设置 latency 或 jitter", ErrInvalidArgument)
+		}
+		if rule.latency+rule.jitter > maxFaultLatency {
+			return nil, 0, fmt.Errorf("%w: 延迟不能超过 %s", ErrInvalidArgument, maxFaultLatency)
+		}
+		if rule.latency > 0 {
+			rule.Latency = rule.latency.String()
+		}
+		if rule.jitter > 0 {
+			rule.Jitter = rule.jitter.String()
+		}
+	case FaultError:
+		if req.Status != 0 && (req.Status < 500 || req.Status > 599) {
+			return nil, 0, fmt.Errorf("%w: error 规则的状态码应为 5xx: %d", ErrInvalidArgument, req.Status)
+		}
+		rule.Status = req.Status
+	case FaultDBDrop:
+	default:
+		return nil, 0, fmt.Errorf("%w: 不支持的故障类型 %q，应为 latency、error 或 db_drop", ErrInvalidArgument, req.Kind)
+	}
+
+	ttl, err := parseFaultDuration("ttl", req.TTL)
+	if err != nil {
+		return nil, 0, err
+	}
+	return rule, ttl, nil
+}
+
+// parseFaultDuration 解析规则中的时长，为空时返回 0
+func parseFaultDuration(name, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%w: %s 格式错误，应为 200ms、15m 这样的时长: %q", ErrInvalidArgument, name, value)
+	}
+	return d, nil
+}
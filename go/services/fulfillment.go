@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/repository"
+)
+
+// 履约时长分析的默认值和阈值
+const (
+	// DefaultFulfillmentWeeks 默认统计的周数
+	DefaultFulfillmentWeeks = 4
+	// MaxFulfillmentWeeks 最多统计的周数
+	MaxFulfillmentWeeks = 26
+	// minSlowHourOrders 判定履约偏慢的下单小时至少需要的订单数，订单太少时分位数没有意义
+	minSlowHourOrders = 5
+	// slowHourMedianRatio 下单小时的履约时长中位数达到全部订单中位数的该倍数时判定为偏慢
+	slowHourMedianRatio = 1.25
+)
+
+// FulfillmentOptions 履约时长分析的参数
+type FulfillmentOptions struct {
+	// Weeks 统计的周数，0 时为 DefaultFulfillmentWeeks
+	Weeks int
+	// To 统计的最后一个商户本地下单日期（含），为空时为商户本地的昨天
+	To string
+	// Statuses 参与统计的订单状态，为空时统计全部有发货时间的订单
+	Statuses []string
+}
+
+// GetFulfillment 统计商户最近若干周下单到发货的时长，按下单的本地小时和星期分组，给出平均值和 p50/p90/p95，
+// 用于判断晚间、周末等营业时间外下单的订单是否履约更慢。
+// 只统计有发货时间的订单（sql/37_order_fulfillment.sql 之后变为已发货的订单），统计范围内尚未发货的订单不计入，
+// 截止日期越近，履约慢的订单越可能尚未发货，分位数会偏低；分位数按线性插值，与 PostgreSQL 的 percentile_cont 一致
+func (s *TimezoneService) GetFulfillment(ctx context.Context, merchantID int, opts FulfillmentOptions) (*models.FulfillmentReport, error) {
+	if opts.Weeks == 0 {
+		opts.Weeks = DefaultFulfillmentWeeks
+	}
+	if opts.Weeks < 1 || opts.Weeks > MaxFulfillmentWeeks {
+		return nil, fmt.Errorf("%w: 统计周数应为 1~%d，得到 %d", ErrInvalidArgument, MaxFulfillmentWeeks, opts.Weeks)
+	}
+	analytics, ok := s.analytics.(repository.FulfillmentAnalysisRepository)
+	if !ok {
+		return nil, fmt.Errorf("%w: 分析后端 %s 没有订单发货时间，履约时长分析需要 ANALYTICS_BACKEND=postgres", ErrInvalidArgument, s.analytics.Name())
+	}
+
+	merchant, err := s.merchants.Get(merchantID)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := LoadLocation(merchant.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	hours, err := MerchantBusinessHours(*merchant)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	dates, filter, err := merchantWeeks(merchant.ID, loc, opts.To, opts.Weeks, now)
+	if err != nil {
+		return nil, err
+	}
+	filter.Statuses = opts.Statuses
+	release, err := s.limiter.Acquire(ctx, TenantFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	var rows []models.OrderFulfillment
+	err = s.withQueryTimeout(ctx, func(ctx context.Context) (err error) {
+		if rows, err = analytics.MerchantFulfillmentDurations(ctx, filter); err != nil {
+			return fmt.Errorf("获取商户履约时长失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var all []float64
+	byHour := make([][]float64, 24)
+	byWeekday := make([][]float64, 7)
+	for _, row := range rows {
+		if row.LocalHour < 0 || row.LocalHour > 23 || row.LocalDayOfWeek < 0 || row.LocalDayOfWeek > 6 {
+			continue
+		}
+		h := float64(row.Seconds) / 3600
+		all = append(all, h)
+		byHour[row.LocalHour] = append(byHour[row.LocalHour], h)
+		byWeekday[row.LocalDayOfWeek] = append(byWeekday[row.LocalDayOfWeek], h)
+	}
+
+	report := &models.FulfillmentReport{
+		MerchantID:    merchant.ID,
+		MerchantName:  merchant.Name,
+		Timezone:      merchant.Timezone,
+		BusinessHours: hours.String(),
+		From:          dates.From,
+		To:            dates.To,
+		Weeks:         opts.Weeks,
+		Overall:       fulfillmentStats(all),
+		Hours:         make([]models.FulfillmentHourStat, 24),
+		Weekdays:      make([]models.FulfillmentWeekdayStat, 7),
+		SlowHours:     []int{},
+		Source:        s.analytics.Name(),
+		Statuses:      filter.Statuses,
+		GeneratedAt:   now.Truncate(time.Second),
+	}
+	overall := report.Overall.P50Hours
+	for h, values := range byHour {
+		stat := models.FulfillmentHourStat{Hour: h, FulfillmentStats: fulfillmentStats(values), MedianRatio: medianRatio(values, overall)}
+		if stat.Orders >= minSlowHourOrders && stat.MedianRatio >= slowHourMedianRatio {
+			report.SlowHours = append(report.SlowHours, h)
+		}
+		report.Hours[h] = stat
+	}
+	for d, values := range byWeekday {
+		report.Weekdays[d] = models.FulfillmentWeekdayStat{
+			Weekday:          d,
+			FulfillmentStats: fulfillmentStats(values),
+			MedianRatio:      medianRatio(values, overall),
+			IsWeekend:        hours.Weekend.Contains(time.Weekday(d)),
+		}
+	}
+	return report, nil
+}
+
+// fulfillmentStats 履约时长（小时）的订单数、平均值、分位数和最大值，保留两位小数
+func fulfillmentStats(values []float64) models.FulfillmentStats {
+	if len(values) == 0 {
+		return models.FulfillmentStats{}
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mean, _ := meanStdDev(sorted)
+	return models.FulfillmentStats{
+		Orders:   len(sorted),
+		AvgHours: roundTo(mean, 2),
+		P50Hours: roundTo(percentile(sorted, 0.50), 2),
+		P90Hours: roundTo(percentile(sorted, 0.90), 2),
+		P95Hours: roundTo(percentile(sorted, 0.95), 2),
+		MaxHours: roundTo(sorted[len(sorted)-1], 2),
+	}
+}
+
+// medianRatio values 的中位数相对 overall 的倍数，没有订单或 overall 为 0 时为 0
+func medianRatio(values []float64, overall float64) float64 {
+	if len(values) == 0 || overall == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return roundTo(percentile(sorted, 0.5)/overall, 2)
+}
+
+// percentile 已排序的 sorted 的 q 分位数，在相邻两个值之间线性插值
+func percentile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	if lower >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lower] + (pos-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
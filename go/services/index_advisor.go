@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/repository"
+)
+
+// 索引建议类型
+const (
+	IndexAdviceCreate    = "create"
+	IndexAdviceRedundant = "redundant"
+	IndexAdviceUnused    = "unused"
+	IndexAdviceSeqScan   = "seq_scan"
+)
+
+const (
+	// seqScanMinRows 行数达到该值的表才检查顺序扫描比例，小表顺序扫描更快
+	seqScanMinRows = 10000
+	// unusedMinTableScans 表的扫描次数达到该值后才把未使用的索引列为建议，避免统计刚重置时误报
+	unusedMinTableScans = 1000
+	// adviceStatementLimit 报告中的语句统计条数
+	adviceStatementLimit = 20
+)
+
+// advisedTables 索引建议检查的表：分析视图的源表
+var advisedTables = []string{"dws_orders", "dim_merchant"}
+
+// accessPattern 服务对表的一种访问方式，需要以 columns 为前缀的索引
+type accessPattern struct {
+	table   string
+	columns []string
+	usage   string
+}
+
+// accessPatterns 服务生成的查询依赖的索引前缀
+var accessPatterns = []accessPattern{
+	{"dws_orders", []string{"merchant_id", "order_time_utc"}, "分析视图按商户关联，日结和账单按商户的本地日期换算为 UTC 区间查询"},
+	{"dws_orders", []string{"order_time_utc"}, "订单列表和导出按 UTC 时间倒序扫描"},
+	{"dws_orders", []string{"merchant_id", "ingested_at"}, "迟到订单对账按商户和入库时间查询"},
+	{"dim_merchant", []string{"timezone"}, "订单和分析接口按商户时区过滤"},
+}
+
+// IndexAdvisorService 根据服务的访问方式和 PostgreSQL 统计信息给出订单、商户表的索引建议
+type IndexAdvisorService struct {
+	stats repository.IndexStatsRepository
+	now   func() time.Time
+}
+
+// NewIndexAdvisorService 创建索引建议服务，使用 PostgreSQL 统计信息
+func NewIndexAdvisorService(db *database.DB) *IndexAdvisorService {
+	return NewIndexAdvisorServiceWithRepositories(repository.NewPostgresIndexStatsRepository(db))
+}
+
+// NewIndexAdvisorServiceWithRepositories 使用指定仓储创建索引建议服务
+func NewIndexAdvisorServiceWithRepositories(stats repository.IndexStatsRepository) *IndexAdvisorService {
+	return &IndexAdvisorService{stats: stats, now: time.Now}
+}
+
+// Advise 生成索引建议报告
+// 建议只供参考，不会自动执行；pg_stat_statements 不可用时报告中不含语句统计
+func (s *IndexAdvisorService) Advise(ctx context.Context) (*models.IndexAdviceReport, error) {
+	indexes, err := s.stats.Indexes(ctx, advisedTables)
+	if err != nil {
+		return nil, err
+	}
+	tables, err := s.stats.TableStats(ctx, advisedTables)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.IndexAdviceReport{
+		GeneratedAt: s.now().UTC(),
+		Tables:      tables,
+		Indexes:     indexes,
+		Advice:      []models.IndexAdvice{},
+		Statements:  []models.StatementStats{},
+	}
+
+	statements, err := s.stats.Statements(ctx, advisedTables, adviceStatementLimit)
+	if err != nil {
+		report.StatementsNote = err.Error()
+	} else {
+		report.StatementsAvailable = true
+		if statements != nil {
+			report.Statements = statements
+		}
+	}
+
+	report.Advice = append(report.Advice, missingIndexAdvice(indexes)...)
+	report.Advice = append(report.Advice, redundantIndexAdvice(indexes)...)
+	report.Advice = append(report.Advice, unusedIndexAdvice(indexes, tables)...)
+	report.Advice = append(report.Advice, seqScanAdvice(tables)...)
+	return report, nil
+}
+
+// missingIndexAdvice 访问方式没有可用的索引前缀时建议新建
+func missingIndexAdvice(indexes []models.IndexInfo) []models.IndexAdvice {
+	var advice []models.IndexAdvice
+	for _, p := range accessPatterns {
+		if coveringIndex(indexes, p.table, p.columns) != nil {
+			continue
+		}
+		name := "idx_" + p.table + "_" + strings.Join(p.columns, "_")
+		advice = append(advice, models.IndexAdvice{
+			Kind:    IndexAdviceCreate,
+			Table:   p.table,
+			Columns: p.columns,
+			Reason:  fmt.Sprintf("没有以 (%s) 为前缀的完整索引：%s", strings.Join(p.columns, ", "), p.usage),
+			SQL:     fmt.Sprintf("CREATE INDEX CONCURRENTLY IF NOT EXISTS %s ON %s (%s);", name, p.table, strings.Join(p.columns, ", ")),
+		})
+	}
+	return advice
+}
+
+// redundantIndexAdvice 索引的列是同表另一个完整索引的前缀时，查询可以改用后者
+func redundantIndexAdvice(indexes []models.IndexInfo) []models.IndexAdvice {
+	var advice []models.IndexAdvice
+	for _, index := range indexes {
+		if index.Unique || index.Primary || index.Partial {
+			continue
+		}
+		for _, other := range indexes {
+			if other.Name == index.Name || other.Table != index.Table || other.Partial ||
+				len(other.Columns) <= len(index.Columns) || !hasPrefix(other.Columns, index.Columns) {
+				continue
+			}
+			advice = append(advice, models.IndexAdvice{
+				Kind:    IndexAdviceRedundant,
+				Table:   index.Table,
+				Columns: index.Columns,
+				Index:   index.Name,
+				Reason:  fmt.Sprintf("列 (%s) 是索引 %s 的前缀，可以由其代替", strings.Join(index.Columns, ", "), other.Name),
+				SQL:     fmt.Sprintf("DROP INDEX CONCURRENTLY IF EXISTS %s;", index.Name),
+			})
+			break
+		}
+	}
+	return advice
+}
+
+// unusedIndexAdvice 表有足够访问量而索引从未被使用时，建议评估是否删除
+func unusedIndexAdvice(indexes []models.IndexInfo, tables []models.TableScanStats) []models.IndexAdvice {
+	scans := make(map[string]int64, len(tables))
+	for _, t := range tables {
+		scans[t.Table] = t.SeqScans + t.IndexScans
+	}
+
+	var advice []models.IndexAdvice
+	for _, index := range indexes {
+		if index.Unique || index.Primary || index.Scans > 0 || scans[index.Table] < unusedMinTableScans {
+			continue
+		}
+		advice = append(advice, models.IndexAdvice{
+			Kind:    IndexAdviceUnused,
+			Table:   index.Table,
+			Columns: index.Columns,
+			Index:   index.Name,
+			Reason:  fmt.Sprintf("表已被扫描 %d 次，索引从未被使用，占用 %d 字节并增加写入开销", scans[index.Table], index.SizeBytes),
+			SQL:     fmt.Sprintf("DROP INDEX CONCURRENTLY IF EXISTS %s;", index.Name),
+		})
+	}
+	return advice
+}
+
+// seqScanAdvice 大表的顺序扫描多于索引扫描时提示结合语句统计排查
+func seqScanAdvice(tables []models.TableScanStats) []models.IndexAdvice {
+	var advice []models.IndexAdvice
+	for _, t := range tables {
+		if t.LiveRows < seqScanMinRows || t.SeqScans <= t.IndexScans {
+			continue
+		}
+		advice = append(advice, models.IndexAdvice{
+			Kind:  IndexAdviceSeqScan,
+			Table: t.Table,
+			Reason: fmt.Sprintf("%d 行的表顺序扫描 %d 次（共读取 %d 行），多于索引扫描 %d 次，请结合语句统计排查缺少的索引",
+				t.LiveRows, t.SeqScans, t.SeqRowsRead, t.IndexScans),
+		})
+	}
+	return advice
+}
+
+// coveringIndex 返回表上以 columns 为前缀的完整索引，部分索引只能服务满足条件的查询，不计入
+func coveringIndex(indexes []models.IndexInfo, table string, columns []string) *models.IndexInfo {
+	for i := range indexes {
+		if indexes[i].Table == table && !indexes[i].Partial && hasPrefix(indexes[i].Columns, columns) {
+			return &indexes[i]
+		}
+	}
+	return nil
+}
+
+// hasPrefix columns 的前 len(prefix) 列是否与 prefix 相同
+func hasPrefix(columns, prefix []string) bool {
+	if len(columns) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if columns[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
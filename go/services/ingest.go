@@ -0,0 +1,731 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/money"
+	"timezone-saas-demo/repository"
+	"timezone-saas-demo/schedule"
+)
+
+var (
+	// ErrSignatureInvalid Webhook 签名缺失、不匹配或已过期，HTTP 层映射为 401
+	ErrSignatureInvalid = errors.New("Webhook 签名无效")
+	// ErrIngestDisabled 平台未配置签名密钥，HTTP 层映射为 403
+	ErrIngestDisabled = errors.New("未启用该平台的 Webhook")
+)
+
+const (
+	// DefaultIngestListLimit 投递列表默认返回的条数
+	DefaultIngestListLimit = 100
+	// maxIngestListLimit 投递列表和批量重放的条数上限
+	maxIngestListLimit = 1000
+	// stripeSignatureTolerance Stripe 签名中的时间戳与当前时刻的最大差值，超过时视为重放的旧请求
+	stripeSignatureTolerance = 5 * time.Minute
+)
+
+// providerTimeLayouts 平台载荷中带偏移的时刻可接受的格式，不带偏移的本地时间见 localTimestampLayouts
+var providerTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05-07:00",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// webhookOrder 平台载荷映射出的订单，时刻保留原始值，确定商户时区后再解析
+type webhookOrder struct {
+	// MerchantID 载荷中指定的商户，0 表示未指定
+	MerchantID  int
+	OrderNumber string
+	Amount      decimal.Decimal
+	Currency    string
+	Status      string
+	OrderTime   json.RawMessage
+	// EventTime 平台事件发生的时刻，为空时不比较事件先后
+	EventTime json.RawMessage
+}
+
+// webhookProvider 外部平台的签名校验和载荷映射
+type webhookProvider interface {
+	// verify 用 secret 校验请求签名
+	verify(header http.Header, body []byte, secret string, now time.Time) error
+	// identify 平台的投递ID和事件类型，用于去重和决定如何映射
+	identify(header http.Header, body []byte) (externalID, topic string, err error)
+	// order 把载荷映射为订单，事件类型不处理时返回 nil
+	order(topic string, payload []byte) (*webhookOrder, error)
+}
+
+// webhookProviders 支持的平台，键为 /api/ingest/webhooks/{provider} 中的名称
+var webhookProviders = map[string]webhookProvider{
+	"shopify": shopifyProvider{},
+	"stripe":  stripeProvider{},
+}
+
+// IngestProviders 支持的平台名称，按字母排序
+func IngestProviders() []string {
+	names := make([]string, 0, len(webhookProviders))
+	for name := range webhookProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IngestService 接收外部平台（Shopify、Stripe）的订单 Webhook
+// 签名校验通过的投递先原样保存，再映射为订单写入 dws_orders，平台的各种时刻格式都按商户时区换算为 UTC；
+// 映射或写入失败的投递保留为 failed（死信），修正后可以重放，重放不再校验签名
+type IngestService struct {
+	ingest    repository.IngestRepository
+	merchants repository.MerchantRepository
+
+	// settings 读取商户的冲突策略，为 nil 时使用 DefaultOrderConflictPolicy
+	settings *SettingsService
+
+	mu      sync.RWMutex
+	secrets map[string]string
+	now     func() time.Time
+}
+
+// NewIngestService 创建 Webhook 接收服务，使用 PostgreSQL 仓储
+func NewIngestService(db *database.DB) *IngestService {
+	return NewIngestServiceWithRepositories(repository.NewPostgresIngestRepository(db), repository.NewPostgresMerchantRepository(db))
+}
+
+// NewIngestServiceWithRepositories 使用指定仓储创建 Webhook 接收服务
+func NewIngestServiceWithRepositories(ingest repository.IngestRepository, merchants repository.MerchantRepository) *IngestService {
+	return &IngestService{ingest: ingest, merchants: merchants, secrets: map[string]string{}, now: time.Now}
+}
+
+// SetSecret 设置平台的签名密钥，为空时不接收该平台的 Webhook；密钥轮换后可以再次调用
+func (s *IngestService) SetSecret(provider, secret string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secrets[provider] = secret
+}
+
+// SetSettingsService 设置读取商户冲突策略（order_conflict_policy）的配置服务
+func (s *IngestService) SetSettingsService(settings *SettingsService) {
+	s.settings = settings
+}
+
+func (s *IngestService) secret(provider string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.secrets[provider]
+}
+
+// Receive 校验签名并保存投递，然后立即处理；平台未配置密钥时返回 ErrIngestDisabled
+// 同一投递重复到达时不重新处理（Duplicate 为 true），上一次处理中断（仍为 received）的除外；
+// 映射失败不返回错误，投递标记为 failed 等待重放；保存或写入数据库失败时返回错误，平台会重试
+func (s *IngestService) Receive(ctx context.Context, provider string, header http.Header, body []byte, merchantID int) (*models.IngestDelivery, error) {
+	p, ok := webhookProviders[provider]
+	if !ok {
+		return nil, fmt.Errorf("%w: 不支持的平台 %q，可选 %s", ErrNotFound, provider, strings.Join(IngestProviders(), ","))
+	}
+	secret := s.secret(provider)
+	if secret == "" {
+		return nil, fmt.Errorf("%w: 未配置 %s 的签名密钥", ErrIngestDisabled, provider)
+	}
+	if err := p.verify(header, body, secret, s.now()); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+	}
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("%w: 请求体不是合法的 JSON", ErrInvalidArgument)
+	}
+	externalID, topic, err := p.identify(header, body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+
+	d := &models.IngestDelivery{
+		Provider:   provider,
+		ExternalID: externalID,
+		Topic:      topic,
+		MerchantID: merchantID,
+		Payload:    body,
+	}
+	created, err := s.ingest.SaveDelivery(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	if !created && d.Status != models.IngestStatusReceived {
+		d.Duplicate = true
+		return d, nil
+	}
+	if err := s.process(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Replay 重新处理一次投递，用于死信修正后的重试；按当前的商户数据和映射规则处理，不再校验签名
+func (s *IngestService) Replay(ctx context.Context, id int64) (*models.IngestDelivery, error) {
+	d, err := s.ingest.Delivery(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := webhookProviders[d.Provider]; !ok {
+		return nil, fmt.Errorf("%w: 投递 %d 的平台 %q 已不再支持", ErrInvalidArgument, id, d.Provider)
+	}
+	if err := s.process(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// ReplayFailed 按接收时间从新到旧重放最多 limit 条失败的投递，provider 为空时不限平台
+// 返回重放后的投递，重放仍然失败的保持 failed
+func (s *IngestService) ReplayFailed(ctx context.Context, provider string, limit int) ([]models.IngestDelivery, error) {
+	failed, err := s.Deliveries(ctx, models.IngestDeliveryFilter{Provider: provider, Status: models.IngestStatusFailed, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	replayed := make([]models.IngestDelivery, 0, len(failed))
+	for _, f := range failed {
+		d, err := s.Replay(ctx, f.ID)
+		if err != nil {
+			return nil, err
+		}
+		d.Payload = nil
+		replayed = append(replayed, *d)
+	}
+	return replayed, nil
+}
+
+// Deliveries 按接收时间倒序查询投递，limit 默认 DefaultIngestListLimit，最大 1000
+func (s *IngestService) Deliveries(ctx context.Context, filter models.IngestDeliveryFilter) ([]models.IngestDelivery, error) {
+	if filter.Provider != "" {
+		if _, ok := webhookProviders[filter.Provider]; !ok {
+			return nil, fmt.Errorf("%w: 不支持的平台 %q，可选 %s", ErrInvalidArgument, filter.Provider, strings.Join(IngestProviders(), ","))
+		}
+	}
+	switch filter.Status {
+	case "", models.IngestStatusReceived, models.IngestStatusProcessed, models.IngestStatusSkipped, models.IngestStatusIgnored,
+		models.IngestStatusConflict, models.IngestStatusFailed:
+	default:
+		return nil, fmt.Errorf("%w: 无效的投递状态 %q", ErrInvalidArgument, filter.Status)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultIngestListLimit
+	}
+	if filter.Limit > maxIngestListLimit {
+		filter.Limit = maxIngestListLimit
+	}
+	return s.ingest.Deliveries(ctx, filter)
+}
+
+// process 映射并写入投递中的订单，映射失败时把投递标记为 failed，只有数据库错误才返回
+func (s *IngestService) process(ctx context.Context, d *models.IngestDelivery) error {
+	order, err := s.mapOrder(d)
+	if err != nil {
+		d.Status = models.IngestStatusFailed
+		d.Error = err.Error()
+		return s.ingest.FinishDelivery(ctx, d)
+	}
+	if order == nil {
+		d.Status = models.IngestStatusIgnored
+		d.Error = ""
+		return s.ingest.FinishDelivery(ctx, d)
+	}
+
+	policy, err := s.conflictPolicy(order.MerchantID)
+	if err != nil {
+		return err
+	}
+	resolve := func(current *models.Order, lastEventTime *time.Time) (*models.Order, *models.OrderConflict) {
+		return resolveIngestOrder(policy, *order, d.EventTime, current, lastEventTime)
+	}
+	err = s.ingest.ApplyOrder(ctx, d, *order, resolve)
+	if errors.Is(err, ErrConflict) {
+		// 并发的请求（其他平台的投递或离线同步）刚写入同一订单号，重试一次即按订单已存在处理
+		err = s.ingest.ApplyOrder(ctx, d, *order, resolve)
+	}
+	if errors.Is(err, ErrConflict) {
+		d.Status = models.IngestStatusFailed
+		d.Error = err.Error()
+		return s.ingest.FinishDelivery(ctx, d)
+	}
+	return err
+}
+
+// conflictPolicy 商户的订单号冲突策略
+func (s *IngestService) conflictPolicy(merchantID int) (string, error) {
+	if s.settings == nil {
+		return DefaultOrderConflictPolicy, nil
+	}
+	policy, err := GetSetting(s.settings, merchantID, SettingOrderConflictPolicy)
+	if err != nil {
+		return "", fmt.Errorf("读取商户 %d 的冲突策略失败: %w", merchantID, err)
+	}
+	return policy, nil
+}
+
+// resolveIngestOrder 按冲突策略决定投递如何写入商户下已存在的订单号
+// 订单不存在时新建；金额、币种和状态都与已有订单相同时视为重复投递，不算冲突；
+// reject 不修改已有订单；upsert 覆盖；version 只应用事件时刻晚于订单版本的投递，
+// 订单的版本取已处理投递中最晚的事件时刻，没有由 Webhook 写入过时取订单的更新时间
+func resolveIngestOrder(policy string, order models.Order, eventTime *time.Time, current *models.Order, lastEventTime *time.Time) (*models.Order, *models.OrderConflict) {
+	if current == nil {
+		return &order, nil
+	}
+	order.ID = current.ID
+	order.OrderTimeUTC = current.OrderTimeUTC
+
+	var fields []models.OrderFieldDiff
+	if !order.Amount.Equal(current.Amount) {
+		fields = append(fields, models.OrderFieldDiff{Field: "amount", Existing: current.Amount.String(), Incoming: order.Amount.String()})
+	}
+	if order.Currency != current.Currency {
+		fields = append(fields, models.OrderFieldDiff{Field: "currency", Existing: current.Currency, Incoming: order.Currency})
+	}
+	if order.Status != current.Status {
+		fields = append(fields, models.OrderFieldDiff{Field: "status", Existing: current.Status, Incoming: order.Status})
+	}
+	if len(fields) == 0 || policy == models.OrderConflictUpsert {
+		return &order, nil
+	}
+
+	conflict := &models.OrderConflict{
+		Policy:            policy,
+		MerchantID:        current.MerchantID,
+		OrderNumber:       current.OrderNumber,
+		ExistingOrderID:   current.ID,
+		ExistingVersion:   current.Version,
+		ExistingEventTime: lastEventTime,
+		IncomingEventTime: eventTime,
+		Fields:            fields,
+	}
+	switch policy {
+	case models.OrderConflictVersion:
+		version, from := current.UpdatedAt.UTC(), "订单的更新时间"
+		if lastEventTime != nil {
+			version, from = *lastEventTime, "订单最近一次写入的事件时刻"
+		}
+		switch {
+		case eventTime == nil:
+			conflict.Reason = "投递没有事件时刻，无法与订单的版本比较"
+		case !eventTime.After(version):
+			conflict.Reason = fmt.Sprintf("事件时刻 %s 不晚于%s %s", eventTime.Format(time.RFC3339), from, version.Format(time.RFC3339))
+		default:
+			return &order, nil
+		}
+	default:
+		conflict.Reason = fmt.Sprintf("商户 %d 已有订单号 %s（订单 %d，版本 %d）", current.MerchantID, current.OrderNumber, current.ID, current.Version)
+	}
+	return nil, conflict
+}
+
+// mapOrder 把投递映射为订单；事件类型不处理或金额为 0 时返回 nil
+// 商户取 Webhook 地址中的 merchant_id 或载荷中指定的商户，两者都有时必须一致；没有偏移的时刻按商户时区解析
+func (s *IngestService) mapOrder(d *models.IngestDelivery) (*models.Order, error) {
+	w, err := webhookProviders[d.Provider].order(d.Topic, d.Payload)
+	if err != nil || w == nil {
+		return nil, err
+	}
+	d.OrderNumber = w.OrderNumber
+
+	merchantID := d.MerchantID
+	if w.MerchantID != 0 {
+		if merchantID != 0 && merchantID != w.MerchantID {
+			return nil, fmt.Errorf("载荷中的商户 %d 与 Webhook 地址中的商户 %d 不一致", w.MerchantID, merchantID)
+		}
+		merchantID = w.MerchantID
+	}
+	if merchantID == 0 {
+		return nil, errors.New("无法确定商户，Webhook 地址应带 merchant_id 参数")
+	}
+	merchant, err := s.merchants.Get(merchantID)
+	if err != nil {
+		return nil, err
+	}
+	d.MerchantID = merchant.ID
+	loc, err := LoadLocation(merchant.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(w.EventTime) > 0 {
+		t, err := parseProviderTime(w.EventTime, loc)
+		if err != nil {
+			return nil, fmt.Errorf("事件时间: %v", err)
+		}
+		d.EventTime = &t
+	}
+	orderTime, err := parseProviderTime(w.OrderTime, loc)
+	if err != nil {
+		return nil, fmt.Errorf("下单时间: %v", err)
+	}
+	currency, err := money.ParseCode(w.Currency)
+	if err != nil {
+		return nil, err
+	}
+	if !w.Amount.IsPositive() {
+		// dws_orders 要求金额大于 0，免费订单不写入
+		return nil, nil
+	}
+	if !money.Round(w.Amount, currency).Equal(w.Amount) {
+		return nil, fmt.Errorf("金额 %s 超出币种 %s 的小数位", w.Amount, currency)
+	}
+	if len(w.OrderNumber) > maxOrderNumberLength {
+		return nil, fmt.Errorf("订单号 %s 超过 %d 个字符", w.OrderNumber, maxOrderNumberLength)
+	}
+
+	return &models.Order{
+		MerchantID:   merchant.ID,
+		OrderNumber:  w.OrderNumber,
+		Amount:       w.Amount,
+		Currency:     currency,
+		Status:       w.Status,
+		OrderTimeUTC: orderTime,
+	}, nil
+}
+
+// parseProviderTime 解析平台载荷中的时刻并换算为 UTC（精确到秒）
+// 数字或数字字符串为 Unix 时间戳（超过 1e11 的按毫秒）；字符串可以是 RFC 3339、RFC 1123 等带偏移的格式，
+// 也可以是不带偏移的本地时间，按 loc 解析，夏令时跳过的时刻顺延，重复的时刻取第一次
+func parseProviderTime(raw json.RawMessage, loc *time.Location) (time.Time, error) {
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		var number json.Number
+		if err := json.Unmarshal(raw, &number); err != nil {
+			return time.Time{}, fmt.Errorf("无法识别的时间 %s", raw)
+		}
+		value = number.String()
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, errors.New("时间为空")
+	}
+
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if unix > 1e11 {
+			return time.UnixMilli(unix).UTC().Truncate(time.Second), nil
+		}
+		return time.Unix(unix, 0).UTC(), nil
+	}
+	for _, layout := range providerTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC().Truncate(time.Second), nil
+		}
+	}
+	wall, ok := parseLocalTimestamp(value)
+	if !ok {
+		return time.Time{}, fmt.Errorf("无法识别的时间格式 %q", value)
+	}
+	occurrences := schedule.Resolve(wall, loc, schedule.Options{Gap: schedule.GapShift, Overlap: schedule.OverlapEarlier})
+	if len(occurrences) == 0 {
+		return time.Time{}, fmt.Errorf("本地时间 %s 在 %s 不存在", value, loc)
+	}
+	return occurrences[0].Time.UTC().Truncate(time.Second), nil
+}
+
+// shopifyProvider Shopify 订单 Webhook：X-Shopify-Hmac-Sha256 为请求体 HMAC-SHA256 的 Base64
+type shopifyProvider struct{}
+
+// shopifyOrderTopics 处理的订单事件，其他事件（如 customers/create）标记为 ignored
+var shopifyOrderTopics = map[string]bool{
+	"orders/create":    true,
+	"orders/updated":   true,
+	"orders/paid":      true,
+	"orders/cancelled": true,
+	"orders/fulfilled": true,
+}
+
+func (shopifyProvider) verify(header http.Header, body []byte, secret string, now time.Time) error {
+	signature, err := base64.StdEncoding.DecodeString(header.Get("X-Shopify-Hmac-Sha256"))
+	if err != nil || len(signature) == 0 {
+		return errors.New("缺少或无法解析 X-Shopify-Hmac-Sha256")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return errors.New("X-Shopify-Hmac-Sha256 不匹配")
+	}
+	return nil
+}
+
+func (shopifyProvider) identify(header http.Header, body []byte) (string, string, error) {
+	id := header.Get("X-Shopify-Webhook-Id")
+	if id == "" {
+		return "", "", errors.New("缺少 X-Shopify-Webhook-Id")
+	}
+	return id, header.Get("X-Shopify-Topic"), nil
+}
+
+// order 订单号为 shopify-<订单ID>；状态按取消、退款、发货、支付的顺序判断，
+// 下单时间取 processed_at（缺省 created_at），事件时间取 updated_at
+func (shopifyProvider) order(topic string, payload []byte) (*webhookOrder, error) {
+	if !shopifyOrderTopics[topic] {
+		return nil, nil
+	}
+	var o struct {
+		ID                json.Number     `json:"id"`
+		TotalPrice        decimal.Decimal `json:"total_price"`
+		Currency          string          `json:"currency"`
+		FinancialStatus   string          `json:"financial_status"`
+		FulfillmentStatus *string         `json:"fulfillment_status"`
+		CancelledAt       json.RawMessage `json:"cancelled_at"`
+		CreatedAt         json.RawMessage `json:"created_at"`
+		ProcessedAt       json.RawMessage `json:"processed_at"`
+		UpdatedAt         json.RawMessage `json:"updated_at"`
+	}
+	if err := json.Unmarshal(payload, &o); err != nil {
+		return nil, fmt.Errorf("解析 Shopify 订单失败: %v", err)
+	}
+	if o.ID == "" {
+		return nil, errors.New("Shopify 订单缺少 id")
+	}
+
+	w := &webhookOrder{
+		OrderNumber: "shopify-" + o.ID.String(),
+		Amount:      o.TotalPrice,
+		Currency:    o.Currency,
+		OrderTime:   o.ProcessedAt,
+		EventTime:   o.UpdatedAt,
+	}
+	if isJSONNull(w.OrderTime) {
+		w.OrderTime = o.CreatedAt
+	}
+	if isJSONNull(w.EventTime) {
+		w.EventTime = nil
+	}
+	switch {
+	case !isJSONNull(o.CancelledAt):
+		w.Status = models.OrderStatusCancelled
+	case o.FinancialStatus == "refunded":
+		w.Status = models.OrderStatusRefunded
+	case o.FulfillmentStatus != nil && *o.FulfillmentStatus == "fulfilled":
+		w.Status = models.OrderStatusShipped
+	case o.FinancialStatus == "paid" || o.FinancialStatus == "partially_refunded":
+		w.Status = models.OrderStatusPaid
+	case o.FinancialStatus == "voided":
+		w.Status = models.OrderStatusCancelled
+	default:
+		w.Status = models.OrderStatusPending
+	}
+	return w, nil
+}
+
+// ShopifyWebhook 把订单编码为 Shopify 的 orders/create 投递并签名，用于订单流模拟和联调
+// 订单ID取订单号的 FNV 哈希（平台映射后的订单号为 shopify-<订单ID>），时刻按商户时区带偏移输出，
+// 与真实平台一样由 Webhook 接收方按商户时区换算
+func ShopifyWebhook(order models.Order, loc *time.Location, webhookID, secret string) (http.Header, []byte, error) {
+	h := fnv.New64a()
+	h.Write([]byte(order.OrderNumber))
+	orderTime := order.OrderTimeUTC.In(loc).Format(time.RFC3339)
+	payload := map[string]interface{}{
+		"id":                 h.Sum64() >> 1,
+		"name":               order.OrderNumber,
+		"total_price":        order.Amount.String(),
+		"currency":           order.Currency,
+		"financial_status":   "paid",
+		"fulfillment_status": nil,
+		"cancelled_at":       nil,
+		"created_at":         orderTime,
+		"processed_at":       orderTime,
+		"updated_at":         orderTime,
+	}
+	switch order.Status {
+	case models.OrderStatusPending:
+		payload["financial_status"] = "pending"
+	case models.OrderStatusShipped:
+		payload["fulfillment_status"] = "fulfilled"
+	case models.OrderStatusRefunded:
+		payload["financial_status"] = "refunded"
+	case models.OrderStatusCancelled:
+		payload["financial_status"] = "voided"
+		payload["cancelled_at"] = orderTime
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, nil, fmt.Errorf("序列化 Shopify 订单失败: %w", err)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("X-Shopify-Topic", "orders/create")
+	header.Set("X-Shopify-Webhook-Id", webhookID)
+	header.Set("X-Shopify-Hmac-Sha256", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	return header, body, nil
+}
+
+// stripeProvider Stripe 事件：Stripe-Signature 为 t=<时间戳>,v1=<签名>，签名为 "<时间戳>.<请求体>" 的 HMAC-SHA256
+type stripeProvider struct{}
+
+// stripeEventStatuses 处理的事件类型及对应的订单状态，空字符串表示按对象的字段判断
+var stripeEventStatuses = map[string]string{
+	"payment_intent.created":     models.OrderStatusPending,
+	"payment_intent.processing":  models.OrderStatusPending,
+	"payment_intent.succeeded":   models.OrderStatusPaid,
+	"payment_intent.canceled":    models.OrderStatusCancelled,
+	"charge.succeeded":           models.OrderStatusPaid,
+	"charge.refunded":            "",
+	"checkout.session.completed": "",
+}
+
+func (stripeProvider) verify(header http.Header, body []byte, secret string, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header.Get("Stripe-Signature"), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return errors.New("缺少或无法解析 Stripe-Signature")
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > stripeSignatureTolerance || age < -stripeSignatureTolerance {
+		return fmt.Errorf("Stripe-Signature 的时间戳与当前时刻相差 %s，超过 %s", age.Round(time.Second), stripeSignatureTolerance)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+	for _, signature := range signatures {
+		if decoded, err := hex.DecodeString(signature); err == nil && hmac.Equal(decoded, expected) {
+			return nil
+		}
+	}
+	return errors.New("Stripe-Signature 不匹配")
+}
+
+func (stripeProvider) identify(header http.Header, body []byte) (string, string, error) {
+	var event stripeEvent
+	if err := json.Unmarshal(body, &event); err != nil || event.ID == "" {
+		return "", "", errors.New("Stripe 事件缺少 id")
+	}
+	return event.ID, event.Type, nil
+}
+
+// stripeEvent Stripe 事件中用到的字段
+type stripeEvent struct {
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Created json.RawMessage `json:"created"`
+	Data    struct {
+		Object stripeObject `json:"object"`
+	} `json:"data"`
+}
+
+// stripeObject 事件的 data.object：PaymentIntent、Charge 或 Checkout Session
+type stripeObject struct {
+	ID            string            `json:"id"`
+	Object        string            `json:"object"`
+	Amount        *int64            `json:"amount"`
+	AmountTotal   *int64            `json:"amount_total"`
+	Currency      string            `json:"currency"`
+	Created       json.RawMessage   `json:"created"`
+	PaymentIntent *string           `json:"payment_intent"`
+	Refunded      bool              `json:"refunded"`
+	PaymentStatus string            `json:"payment_status"`
+	Metadata      map[string]string `json:"metadata"`
+}
+
+// orderNumber 优先取 metadata.order_number，否则为 stripe-<PaymentIntent ID>，同一笔支付的 charge 和 payment_intent 事件对应同一订单
+func (o stripeObject) orderNumber() string {
+	switch {
+	case o.Metadata["order_number"] != "":
+		return o.Metadata["order_number"]
+	case o.Object != "payment_intent" && o.PaymentIntent != nil && *o.PaymentIntent != "":
+		return "stripe-" + *o.PaymentIntent
+	default:
+		return "stripe-" + o.ID
+	}
+}
+
+// merchantID metadata.merchant_id 中指定的商户，未指定时为 0
+func (o stripeObject) merchantID() (int, error) {
+	id := o.Metadata["merchant_id"]
+	if id == "" {
+		return 0, nil
+	}
+	merchantID, err := strconv.Atoi(id)
+	if err != nil || merchantID <= 0 {
+		return 0, fmt.Errorf("metadata.merchant_id 无效: %q", id)
+	}
+	return merchantID, nil
+}
+
+// order 订单号见 stripeObject.orderNumber；金额为最小货币单位，按币种小数位换算；下单时间取对象的 created，事件时间取事件的 created
+func (stripeProvider) order(topic string, payload []byte) (*webhookOrder, error) {
+	status, ok := stripeEventStatuses[topic]
+	if !ok {
+		return nil, nil
+	}
+	var event stripeEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("解析 Stripe 事件失败: %v", err)
+	}
+	o := event.Data.Object
+	if o.ID == "" {
+		return nil, errors.New("Stripe 事件缺少 data.object.id")
+	}
+
+	w := &webhookOrder{
+		Currency:  strings.ToUpper(o.Currency),
+		Status:    status,
+		OrderTime: o.Created,
+		EventTime: event.Created,
+	}
+	amount := o.Amount
+	if o.Object == "checkout.session" {
+		amount = o.AmountTotal
+	}
+	if amount == nil {
+		return nil, fmt.Errorf("Stripe %s 缺少金额", o.Object)
+	}
+	w.Amount = decimal.New(*amount, -money.Exponent(w.Currency))
+
+	switch topic {
+	case "charge.refunded":
+		// 部分退款时订单仍为已支付，退款金额不在订单中体现
+		w.Status = models.OrderStatusPaid
+		if o.Refunded {
+			w.Status = models.OrderStatusRefunded
+		}
+	case "checkout.session.completed":
+		w.Status = models.OrderStatusPending
+		if o.PaymentStatus == "paid" || o.PaymentStatus == "no_payment_required" {
+			w.Status = models.OrderStatusPaid
+		}
+	}
+
+	w.OrderNumber = o.orderNumber()
+	merchantID, err := o.merchantID()
+	if err != nil {
+		return nil, err
+	}
+	w.MerchantID = merchantID
+	return w, nil
+}
+
+// isJSONNull 字段缺失或为 null
+func isJSONNull(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(raw) == "null"
+}
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/timerange"
+)
+
+const (
+	// MaxReconcileEvents 一次核对的事件数上限
+	MaxReconcileEvents = 10000
+	// DefaultEventReconcileDays 核对 Webhook 事件时默认的天数（含今天，UTC）
+	DefaultEventReconcileDays = 7
+	// maxEventReconcileDays 核对 Webhook 事件时一次最多的天数
+	maxEventReconcileDays = 92
+	// maxEventReconcileDetails 报告中不一致事件和未匹配事件的条数上限
+	maxEventReconcileDetails = 500
+)
+
+// 事件核对报告的来源
+const (
+	EventSourceUpload   = "upload"
+	EventSourceWebhooks = "webhooks"
+)
+
+// ParseProviderEvents 解析上传的支付平台事件，兼容 Stripe 的导出格式：
+// 列表对象 {"object":"list","data":[...]}（如 GET /v1/events 的响应）或事件数组；
+// 每个事件的 created 为 Unix 秒，订单号取 order_number，没有时按 Webhook 的规则从 Stripe 的 data.object 推导；
+// 商户取 merchant_id 或 data.object.metadata.merchant_id，都没有时按订单号在各商户中匹配
+func ParseProviderEvents(body []byte) ([]models.ProviderEvent, error) {
+	body = bytes.TrimSpace(body)
+	var raw []json.RawMessage
+	if len(body) > 0 && body[0] == '{' {
+		var list struct {
+			Data []json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(body, &list); err != nil {
+			return nil, fmt.Errorf("%w: 事件列表格式错误: %v", ErrInvalidArgument, err)
+		}
+		raw = list.Data
+	} else if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("%w: 事件列表应为 Stripe 列表对象或事件数组: %v", ErrInvalidArgument, err)
+	}
+
+	events := make([]models.ProviderEvent, 0, len(raw))
+	for i, item := range raw {
+		var e struct {
+			stripeEvent
+			OrderNumber string `json:"order_number"`
+			MerchantID  int    `json:"merchant_id"`
+		}
+		if err := json.Unmarshal(item, &e); err != nil {
+			return nil, fmt.Errorf("%w: 第 %d 个事件格式错误: %v", ErrInvalidArgument, i+1, err)
+		}
+		if e.ID == "" {
+			return nil, fmt.Errorf("%w: 第 %d 个事件缺少 id", ErrInvalidArgument, i+1)
+		}
+		if isJSONNull(e.Created) {
+			return nil, fmt.Errorf("%w: 事件 %s 缺少 created", ErrInvalidArgument, e.ID)
+		}
+		created, err := parseProviderTime(e.Created, time.UTC)
+		if err != nil {
+			return nil, fmt.Errorf("%w: 事件 %s 的 created: %v", ErrInvalidArgument, e.ID, err)
+		}
+		event := models.ProviderEvent{ID: e.ID, Type: e.Type, Created: created.Unix(), OrderNumber: e.OrderNumber, MerchantID: e.MerchantID}
+		if event.OrderNumber == "" && e.Data.Object.ID != "" {
+			event.OrderNumber = e.Data.Object.orderNumber()
+		}
+		if event.MerchantID == 0 {
+			if event.MerchantID, err = e.Data.Object.merchantID(); err != nil {
+				return nil, fmt.Errorf("%w: 事件 %s 的 %v", ErrInvalidArgument, e.ID, err)
+			}
+		}
+		if event.OrderNumber == "" {
+			return nil, fmt.Errorf("%w: 事件 %s 缺少 order_number 或 data.object", ErrInvalidArgument, e.ID)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// ReconcileEvents 核对上传的平台事件：事件时刻（UTC）的日期、按商户时区换算后的日期与订单在分析视图中的本地日期是否一致
+// merchantID 不为 0 时只匹配该商户的订单，其他订单的事件计为未匹配
+func (s *IngestService) ReconcileEvents(ctx context.Context, events []models.ProviderEvent, merchantID int) (*models.EventReconciliationReport, error) {
+	if len(events) == 0 {
+		return nil, fmt.Errorf("%w: 没有需要核对的事件", ErrInvalidArgument)
+	}
+	if len(events) > MaxReconcileEvents {
+		return nil, fmt.Errorf("%w: 一次最多核对 %d 个事件，上传了 %d 个", ErrInvalidArgument, MaxReconcileEvents, len(events))
+	}
+	report := &models.EventReconciliationReport{Source: EventSourceUpload}
+	return s.reconcileEvents(ctx, report, events, merchantID)
+}
+
+// ReconcileDeliveries 核对已接收的 Webhook 事件，from、to 为事件的 UTC 日期（含两端），默认最近 7 天，provider 默认 stripe
+func (s *IngestService) ReconcileDeliveries(ctx context.Context, provider, from, to string, merchantID int) (*models.EventReconciliationReport, error) {
+	if provider == "" {
+		provider = "stripe"
+	}
+	if _, ok := webhookProviders[provider]; !ok {
+		return nil, fmt.Errorf("%w: 不支持的平台 %q，可选 %s", ErrInvalidArgument, provider, strings.Join(IngestProviders(), ","))
+	}
+	dates, err := recentDates(from, to, timerange.Today(time.UTC, s.now()), DefaultEventReconcileDays, time.UTC)
+	if err != nil {
+		return nil, err
+	}
+	if days := dates.Days(); days > maxEventReconcileDays {
+		return nil, fmt.Errorf("%w: 一次最多核对 %d 天，请求了 %d 天", ErrInvalidArgument, maxEventReconcileDays, days)
+	}
+
+	events, err := s.ingest.ProviderEvents(ctx, models.ProviderEventFilter{
+		Provider:   provider,
+		MerchantID: merchantID,
+		From:       dates.Start,
+		To:         dates.End,
+		Limit:      MaxReconcileEvents + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(events) > MaxReconcileEvents {
+		return nil, fmt.Errorf("%w: %s~%s 的事件超过 %d 个，请缩小日期范围或指定商户", ErrInvalidArgument,
+			dates.From, dates.To, MaxReconcileEvents)
+	}
+	report := &models.EventReconciliationReport{
+		Source:   EventSourceWebhooks,
+		Provider: provider,
+		From:     dates.From,
+		To:       dates.To,
+	}
+	return s.reconcileEvents(ctx, report, events, merchantID)
+}
+
+// reconcileEvents 按（商户，订单号）匹配订单后逐个比较日期，按时区汇总；
+// 没有指定商户的事件按订单号匹配，订单号在多个商户下都存在时无法确定订单，计为未匹配
+// 事件的 UTC 日期与订单本地日期不同而本地日期相同的是平台报表按 UTC 切日造成的（utc_date），
+// 换算到商户时区后仍不同的是真正跨日的事件（local_date），如本地 23:50 下单、次日 00:10 支付，或订单的下单时间有误
+func (s *IngestService) reconcileEvents(ctx context.Context, report *models.EventReconciliationReport, events []models.ProviderEvent, merchantID int) (*models.EventReconciliationReport, error) {
+	numbers := make([]string, 0, len(events))
+	seen := make(map[string]bool, len(events))
+	for _, e := range events {
+		if !seen[e.OrderNumber] {
+			seen[e.OrderNumber] = true
+			numbers = append(numbers, e.OrderNumber)
+		}
+	}
+	orders, err := s.ingest.OrdersByNumber(ctx, merchantID, numbers)
+	if err != nil {
+		return nil, err
+	}
+	type orderKey struct {
+		merchantID int
+		number     string
+	}
+	byKey := make(map[orderKey]models.OrderAnalysis, len(orders))
+	merchantsByNumber := make(map[string]int, len(orders))
+	for _, o := range orders {
+		byKey[orderKey{o.MerchantID, o.OrderNumber}] = o
+		byKey[orderKey{0, o.OrderNumber}] = o
+		merchantsByNumber[o.OrderNumber]++
+	}
+
+	report.TotalEvents = len(events)
+	report.Details = []models.EventDateMismatch{}
+	report.UnmatchedEventIDs = []string{}
+	zones := map[string]*models.TimezoneEventReconciliation{}
+	locations := map[string]*time.Location{}
+	for _, e := range events {
+		order, ok := byKey[orderKey{e.MerchantID, e.OrderNumber}]
+		if e.MerchantID == 0 && merchantsByNumber[e.OrderNumber] > 1 {
+			ok = false
+		}
+		if !ok {
+			report.UnmatchedEvents++
+			if len(report.UnmatchedEventIDs) < maxEventReconcileDetails {
+				report.UnmatchedEventIDs = append(report.UnmatchedEventIDs, e.ID)
+			}
+			continue
+		}
+		loc, ok := locations[order.Timezone]
+		if !ok {
+			if loc, err = LoadLocation(order.Timezone); err != nil {
+				return nil, err
+			}
+			locations[order.Timezone] = loc
+		}
+		zone, ok := zones[order.Timezone]
+		if !ok {
+			zone = &models.TimezoneEventReconciliation{Timezone: order.Timezone}
+			zones[order.Timezone] = zone
+		}
+		report.MatchedEvents++
+		zone.Events++
+
+		eventTime := time.Unix(e.Created, 0).UTC()
+		mismatch := models.EventDateMismatch{
+			EventID:        e.ID,
+			EventType:      e.Type,
+			OrderID:        order.OrderID,
+			OrderNumber:    order.OrderNumber,
+			MerchantID:     order.MerchantID,
+			Timezone:       order.Timezone,
+			EventTimeUTC:   eventTime,
+			EventUTCDate:   eventTime.Format("2006-01-02"),
+			EventLocalDate: eventTime.In(loc).Format("2006-01-02"),
+			OrderTimeUTC:   order.OrderTimeUTC.UTC(),
+			OrderLocalDate: order.LocalDate,
+		}
+		switch {
+		case mismatch.EventLocalDate != order.LocalDate:
+			mismatch.Kind = models.EventMismatchLocalDate
+			zone.LocalDateMismatches++
+		case mismatch.EventUTCDate != order.LocalDate:
+			mismatch.Kind = models.EventMismatchUTCDate
+			zone.UTCDateMismatches++
+		default:
+			zone.SameDate++
+			continue
+		}
+		mismatch.DayShift = dayDiff(mismatch.EventUTCDate, order.LocalDate)
+		if mismatch.DayShift < 0 {
+			zone.ProviderEarlier++
+		} else if mismatch.DayShift > 0 {
+			zone.ProviderLater++
+		}
+		report.Mismatches++
+		report.Details = append(report.Details, mismatch)
+	}
+
+	report.Timezones = make([]models.TimezoneEventReconciliation, 0, len(zones))
+	for _, zone := range zones {
+		report.Timezones = append(report.Timezones, *zone)
+	}
+	sort.Slice(report.Timezones, func(i, j int) bool { return report.Timezones[i].Timezone < report.Timezones[j].Timezone })
+	sort.SliceStable(report.Details, func(i, j int) bool {
+		a, b := report.Details[i], report.Details[j]
+		if a.OrderLocalDate != b.OrderLocalDate {
+			return a.OrderLocalDate < b.OrderLocalDate
+		}
+		return a.EventTimeUTC.Before(b.EventTimeUTC)
+	})
+	if len(report.Details) > maxEventReconcileDetails {
+		report.Details = report.Details[:maxEventReconcileDetails]
+		report.Truncated = true
+	}
+	report.CheckedAt = s.now()
+	if report.Mismatches > 0 {
+		log.Printf("⚠️ %d 个支付平台事件中 %d 个的日期与订单本地日期不一致", report.MatchedEvents, report.Mismatches)
+	}
+	return report, nil
+}
+
+// dayDiff 日期 a 减日期 b 的天数，两者都是 YYYY-MM-DD
+func dayDiff(a, b string) int {
+	ta, errA := timerange.ParseDate(a)
+	tb, errB := timerange.ParseDate(b)
+	if errA != nil || errB != nil {
+		return 0
+	}
+	return int(ta.Sub(tb).Hours() / 24)
+}
@@ -0,0 +1,658 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/repository"
+)
+
+// 后台任务的状态
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+)
+
+// 后台任务的默认值和限制
+const (
+	// DefaultJobQueue 注册任务类型时未指定队列时使用的队列
+	DefaultJobQueue = "default"
+	// DefaultJobMaxAttempts 未指定 max_attempts 时的最大执行次数
+	DefaultJobMaxAttempts = 5
+	maxJobAttempts        = 20
+	// DefaultJobPollInterval 工作协程没有被唤醒时检查新任务和到期定时计划的周期
+	DefaultJobPollInterval = time.Second
+	// jobHeartbeatInterval 执行中任务的心跳周期，超过 jobStaleAfter 没有心跳的任务视为实例已退出
+	jobHeartbeatInterval = 15 * time.Second
+	jobStaleAfter        = 4 * jobHeartbeatInterval
+	// jobBackoffBase、jobBackoffMax 失败重试的退避：第 n 次失败后等待 base·2^(n-1)，不超过 max
+	jobBackoffBase = 10 * time.Second
+	jobBackoffMax  = time.Hour
+	// jobFinishTimeout 服务停止后保存任务结果的超时时间
+	jobFinishTimeout    = 10 * time.Second
+	defaultJobListLimit = 50
+	maxJobListLimit     = 500
+	maxJobDedupeKey     = 200
+)
+
+var jobStatuses = []string{JobStatusQueued, JobStatusRunning, JobStatusSucceeded, JobStatusFailed, JobStatusCancelled}
+
+// JobHandler 执行一个任务，返回值编码为 JSON 保存在任务的 result 中
+// 返回 ErrInvalidArgument 或 ErrNotFound 时任务直接失败，其他错误按退避重试；
+// ctx 在任务被取消、超时或服务停止时取消
+type JobHandler func(ctx context.Context, job *models.Job) (interface{}, error)
+
+// JobRequest 提交任务的请求
+type JobRequest struct {
+	Kind    string          `json:"kind"`
+	Payload json.RawMessage `json:"payload"`
+	// RunAt 最早执行时刻，为空时立即执行
+	RunAt *time.Time `json:"run_at"`
+	// MaxAttempts 最大执行次数，缺省为 DefaultJobMaxAttempts
+	MaxAttempts int `json:"max_attempts"`
+	// DedupeKey 不为空时同一键已有排队或执行中的任务则返回 ErrConflict
+	DedupeKey string `json:"dedupe_key"`
+}
+
+// JobScheduleRequest 新增或覆盖定时计划的请求
+type JobScheduleRequest struct {
+	Kind     string          `json:"kind"`
+	Payload  json.RawMessage `json:"payload"`
+	Schedule string          `json:"schedule"`
+	// Timezone 展开重复规则的时区，缺省为 UTC
+	Timezone string `json:"timezone"`
+	// Enabled 缺省为 true
+	Enabled *bool `json:"enabled"`
+}
+
+// jobKind 已注册的任务类型
+type jobKind struct {
+	queue   string
+	timeout time.Duration
+	handler JobHandler
+}
+
+// JobService 后台任务：报表、归档、汇总重建等耗时操作写入 job 表，由各队列的工作协程领取执行
+// 多个实例可以同时运行，领取由数据库保证每个任务只被一个实例执行；执行中的任务定期刷新心跳，
+// 实例退出后超时的任务由其他实例重新排队。失败的任务按指数退避重试，达到最大执行次数后为 failed
+type JobService struct {
+	jobs repository.JobRepository
+	// worker 本实例的标识（主机名-进程号），写入任务的 locked_by
+	worker string
+	now    func() time.Time
+
+	mu          sync.Mutex
+	kinds       map[string]jobKind
+	concurrency map[string]int
+	// running 本实例执行中任务的取消函数，取消任务或心跳发现任务已被回收时调用
+	running map[int64]context.CancelFunc
+	// wake 按队列唤醒等待中的工作协程，提交任务后不必等到下一次轮询
+	wake map[string]chan struct{}
+	// leader 多实例部署时只有主实例提交定时计划的任务和回收超时任务，为 nil 时本实例总是执行
+	leader *LeaderElector
+}
+
+// NewJobService 创建后台任务服务，使用 PostgreSQL 仓储
+func NewJobService(db *database.DB) *JobService {
+	return NewJobServiceWithRepositories(repository.NewPostgresJobRepository(db))
+}
+
+// NewJobServiceWithRepositories 使用指定仓储创建后台任务服务
+func NewJobServiceWithRepositories(jobs repository.JobRepository) *JobService {
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "localhost"
+	}
+	return &JobService{
+		jobs:        jobs,
+		worker:      fmt.Sprintf("%s-%d", host, os.Getpid()),
+		now:         time.Now,
+		kinds:       make(map[string]jobKind),
+		concurrency: make(map[string]int),
+		running:     make(map[int64]context.CancelFunc),
+		wake:        make(map[string]chan struct{}),
+	}
+}
+
+// Register 注册任务类型，queue 为空时使用 DefaultJobQueue，timeout 为 0 时不限制单次执行时间
+// 需要在 Run 之前注册，Run 只为已注册任务类型的队列启动工作协程
+func (s *JobService) Register(kind, queue string, timeout time.Duration, handler JobHandler) {
+	if queue == "" {
+		queue = DefaultJobQueue
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.kinds[kind] = jobKind{queue: queue, timeout: timeout, handler: handler}
+	if _, ok := s.wake[queue]; !ok {
+		s.wake[queue] = make(chan struct{}, 1)
+	}
+}
+
+// SetConcurrency 设置队列的工作协程数，未设置的队列为 1；需要在 Run 之前设置
+func (s *JobService) SetConcurrency(queue string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.concurrency[queue] = n
+}
+
+// SetLeader 设置选主，定时计划和超时任务的回收只在主实例上执行；需要在 Run 之前设置
+func (s *JobService) SetLeader(leader *LeaderElector) {
+	s.leader = leader
+}
+
+// Kinds 已注册的任务类型及其队列
+func (s *JobService) Kinds() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kinds := make(map[string]string, len(s.kinds))
+	for name, k := range s.kinds {
+		kinds[name] = k.queue
+	}
+	return kinds
+}
+
+func (s *JobService) kind(name string) (jobKind, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.kinds[name]
+	return k, ok
+}
+
+// Enqueue 校验并提交任务
+func (s *JobService) Enqueue(ctx context.Context, req JobRequest) (*models.Job, error) {
+	kind, ok := s.kind(strings.TrimSpace(req.Kind))
+	if !ok {
+		return nil, s.unknownKind(req.Kind)
+	}
+	payload, err := normalizeJobPayload(req.Payload)
+	if err != nil {
+		return nil, err
+	}
+	if req.MaxAttempts == 0 {
+		req.MaxAttempts = DefaultJobMaxAttempts
+	}
+	if req.MaxAttempts < 1 || req.MaxAttempts > maxJobAttempts {
+		return nil, fmt.Errorf("%w: max_attempts 必须在 1 到 %d 之间", ErrInvalidArgument, maxJobAttempts)
+	}
+	dedupeKey := strings.TrimSpace(req.DedupeKey)
+	if len(dedupeKey) > maxJobDedupeKey {
+		return nil, fmt.Errorf("%w: dedupe_key 不能超过 %d 个字符", ErrInvalidArgument, maxJobDedupeKey)
+	}
+	runAt := s.now().UTC()
+	if req.RunAt != nil && req.RunAt.After(runAt) {
+		runAt = req.RunAt.UTC()
+	}
+
+	job := &models.Job{
+		Queue:       kind.queue,
+		Kind:        strings.TrimSpace(req.Kind),
+		Payload:     payload,
+		Status:      JobStatusQueued,
+		MaxAttempts: req.MaxAttempts,
+		RunAt:       runAt,
+		DedupeKey:   dedupeKey,
+	}
+	if err := s.enqueue(ctx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// enqueue 写入任务并唤醒队列的工作协程
+func (s *JobService) enqueue(ctx context.Context, job *models.Job) error {
+	if err := s.jobs.EnqueueJob(ctx, job); err != nil {
+		return err
+	}
+	s.notify(job.Queue)
+	return nil
+}
+
+func (s *JobService) notify(queue string) {
+	s.mu.Lock()
+	wake := s.wake[queue]
+	s.mu.Unlock()
+	if wake == nil {
+		return
+	}
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *JobService) unknownKind(kind string) error {
+	names := make([]string, 0)
+	for name := range s.Kinds() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("%w: 未注册的任务类型 %q，可选 %s", ErrInvalidArgument, kind, strings.Join(names, ","))
+}
+
+// normalizeJobPayload 参数必须是 JSON 对象，为空时为 {}
+func normalizeJobPayload(raw json.RawMessage) (json.RawMessage, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return json.RawMessage("{}"), nil
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, fmt.Errorf("%w: payload 必须是 JSON 对象", ErrInvalidArgument)
+	}
+	return raw, nil
+}
+
+// DecodeJobPayload 把任务参数解码到 v，格式错误时返回 ErrInvalidArgument，任务不会重试
+func DecodeJobPayload(job *models.Job, v interface{}) error {
+	if err := json.Unmarshal(job.Payload, v); err != nil {
+		return fmt.Errorf("%w: 任务 %s 的参数格式错误: %v", ErrInvalidArgument, job.Kind, err)
+	}
+	return nil
+}
+
+// Jobs 按条件筛选的任务，limit 默认 50，最多 500
+func (s *JobService) Jobs(ctx context.Context, filter models.JobFilter) ([]models.Job, error) {
+	if filter.Status != "" && !isJobStatus(filter.Status) {
+		return nil, fmt.Errorf("%w: 无效的任务状态 %q，可选 %s", ErrInvalidArgument, filter.Status, strings.Join(jobStatuses, ","))
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultJobListLimit
+	}
+	if filter.Limit > maxJobListLimit {
+		filter.Limit = maxJobListLimit
+	}
+	return s.jobs.Jobs(ctx, filter)
+}
+
+// isJobStatus 是否为任务状态
+func isJobStatus(value string) bool {
+	for _, status := range jobStatuses {
+		if status == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Job 单个任务
+func (s *JobService) Job(ctx context.Context, id int64) (*models.Job, error) {
+	return s.jobs.Job(ctx, id)
+}
+
+// Retry 失败或已取消的任务重新排队，执行次数清零
+func (s *JobService) Retry(ctx context.Context, id int64) (*models.Job, error) {
+	job, err := s.jobs.RetryJob(ctx, id, s.now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	s.notify(job.Queue)
+	return job, nil
+}
+
+// Cancel 取消排队或执行中的任务；任务在本实例执行时立即取消其 ctx，在其他实例执行时由该实例在下一次心跳时停止
+func (s *JobService) Cancel(ctx context.Context, id int64) (*models.Job, error) {
+	job, err := s.jobs.CancelJob(ctx, id, s.now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	cancel := s.running[id]
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	return job, nil
+}
+
+// SetSchedule 新增或覆盖定时计划，下一次执行时刻从当前时刻重新计算
+func (s *JobService) SetSchedule(ctx context.Context, name string, req JobScheduleRequest) (*models.JobSchedule, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > 100 {
+		return nil, fmt.Errorf("%w: 定时计划名称不能为空且不超过 100 个字符", ErrInvalidArgument)
+	}
+	kind := strings.TrimSpace(req.Kind)
+	if _, ok := s.kind(kind); !ok {
+		return nil, s.unknownKind(kind)
+	}
+	payload, err := normalizeJobPayload(req.Payload)
+	if err != nil {
+		return nil, err
+	}
+	sc := &models.JobSchedule{
+		Name:     name,
+		Kind:     kind,
+		Payload:  payload,
+		Timezone: strings.TrimSpace(req.Timezone),
+		Enabled:  req.Enabled == nil || *req.Enabled,
+	}
+	// 定时计划不关联商户，不支持 CRON_TZ=@merchant_local
+	spec, err := parseScheduleSpec(req.Schedule, nil)
+	if err != nil {
+		return nil, err
+	}
+	// cron 表达式的 CRON_TZ 即定时计划的时区，与 timezone 同时指定时必须一致
+	if spec.loc != nil {
+		if sc.Timezone != "" && sc.Timezone != spec.loc.String() {
+			return nil, fmt.Errorf("%w: timezone %s 与 CRON_TZ=%s 不一致", ErrInvalidArgument, sc.Timezone, spec.loc)
+		}
+		sc.Timezone = spec.loc.String()
+	}
+	if sc.Timezone == "" {
+		sc.Timezone = "UTC"
+	}
+	loc, err := LoadLocation(sc.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	// 与定时报表相同，每次生成任务后从当前时刻重新展开规则，COUNT 无法累计
+	if spec.rule != nil && spec.rule.Count > 0 {
+		return nil, fmt.Errorf("%w: 定时计划的重复规则不支持 COUNT", ErrInvalidArgument)
+	}
+	sc.Schedule = spec.String()
+	if sc.Enabled {
+		if sc.NextRunAt, err = spec.next(loc, s.now()); err != nil {
+			return nil, err
+		}
+		if sc.NextRunAt == nil {
+			return nil, fmt.Errorf("%w: 重复规则 %s 在一年内没有发生", ErrInvalidArgument, sc.Schedule)
+		}
+	}
+	if err := s.jobs.SaveSchedule(ctx, sc); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+// Schedules 全部定时计划
+func (s *JobService) Schedules(ctx context.Context) ([]models.JobSchedule, error) {
+	return s.jobs.Schedules(ctx)
+}
+
+// DeleteSchedule 删除定时计划，已生成的任务不受影响
+func (s *JobService) DeleteSchedule(ctx context.Context, name string) error {
+	return s.jobs.DeleteSchedule(ctx, name)
+}
+
+// EnqueueDue 为下一次执行时刻不晚于 at 的定时计划提交任务，返回提交的任务数
+// 任务以 schedule:<名称> 去重，上一次的任务仍在排队或执行时跳过本次；
+// 停机期间错过的多次执行只补一次，下一次执行时刻从 at 之后重新计算
+func (s *JobService) EnqueueDue(ctx context.Context, at time.Time) (int, error) {
+	schedules, err := s.jobs.DueSchedules(ctx, at)
+	if err != nil {
+		return 0, err
+	}
+	enqueued := 0
+	for i := range schedules {
+		sc := &schedules[i]
+		loc, err := LoadLocation(sc.Timezone)
+		if err != nil {
+			return enqueued, fmt.Errorf("定时计划 %s 时区解析失败: %w", sc.Name, err)
+		}
+		spec, err := parseScheduleSpec(sc.Schedule, nil)
+		if err != nil {
+			return enqueued, fmt.Errorf("定时计划 %s 重复规则解析失败: %w", sc.Name, err)
+		}
+		next, err := spec.next(loc, at)
+		if err != nil {
+			return enqueued, fmt.Errorf("定时计划 %s 计算下一次执行时刻失败: %w", sc.Name, err)
+		}
+		claimed, err := s.jobs.ClaimSchedule(ctx, sc.Name, *sc.NextRunAt, next)
+		if err != nil {
+			return enqueued, err
+		}
+		if !claimed {
+			continue
+		}
+		kind, ok := s.kind(sc.Kind)
+		if !ok {
+			log.Printf("⚠️ 定时计划 %s 的任务类型 %s 未注册，跳过本次", sc.Name, sc.Kind)
+			continue
+		}
+		job := &models.Job{
+			Queue:       kind.queue,
+			Kind:        sc.Kind,
+			Payload:     sc.Payload,
+			Status:      JobStatusQueued,
+			MaxAttempts: DefaultJobMaxAttempts,
+			RunAt:       at.UTC(),
+			DedupeKey:   "schedule:" + sc.Name,
+			Schedule:    sc.Name,
+		}
+		if err := s.enqueue(ctx, job); err != nil {
+			if errors.Is(err, ErrConflict) {
+				log.Printf("⚠️ 定时计划 %s 上一次的任务仍在排队或执行，跳过本次", sc.Name)
+				continue
+			}
+			return enqueued, fmt.Errorf("定时计划 %s 提交任务失败: %w", sc.Name, err)
+		}
+		enqueued++
+	}
+	return enqueued, nil
+}
+
+// Run 为每个已注册的队列启动工作协程，每隔 pollInterval 检查新任务和到期的定时计划，
+// 每隔 15s 刷新执行中任务的心跳并回收超时的任务，直到 ctx 取消；
+// ctx 取消后等待执行中的任务退出，被中断的任务重新排队，不计入执行次数
+func (s *JobService) Run(ctx context.Context, pollInterval time.Duration) {
+	s.mu.Lock()
+	workers := make(map[string]int)
+	for _, k := range s.kinds {
+		workers[k.queue] = 1
+		if n, ok := s.concurrency[k.queue]; ok && n > 0 {
+			workers[k.queue] = n
+		}
+	}
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for queue, n := range workers {
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(queue string) {
+				defer wg.Done()
+				s.work(ctx, queue, pollInterval)
+			}(queue)
+		}
+	}
+	s.maintain(ctx, pollInterval)
+	wg.Wait()
+}
+
+// work 循环领取并执行队列中的任务，队列为空时等待唤醒或下一次轮询
+func (s *JobService) work(ctx context.Context, queue string, pollInterval time.Duration) {
+	s.mu.Lock()
+	wake := s.wake[queue]
+	s.mu.Unlock()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			job, err := s.jobs.ClaimJob(ctx, queue, s.worker, s.now().UTC())
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("队列 %s 领取任务失败: %v", queue, err)
+				}
+				break
+			}
+			if job == nil {
+				break
+			}
+			s.execute(ctx, job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-wake:
+		}
+	}
+}
+
+// maintain 提交到期定时计划的任务、刷新心跳和回收超时任务，提交和回收只在主实例上执行
+func (s *JobService) maintain(ctx context.Context, pollInterval time.Duration) {
+	schedules := time.NewTicker(pollInterval)
+	defer schedules.Stop()
+	heartbeat := time.NewTicker(jobHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		if s.leader == nil || s.leader.IsLeader() {
+			if n, err := s.EnqueueDue(ctx, s.now()); err != nil {
+				log.Printf("定时任务提交失败: %v", err)
+			} else if n > 0 {
+				log.Printf("定时任务提交完成: %d 个任务", n)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-schedules.C:
+		case <-heartbeat.C:
+			s.heartbeat(ctx)
+		}
+	}
+}
+
+// heartbeat 刷新本实例执行中任务的心跳，停止已被取消或回收的任务；主实例还回收其他实例超时的任务
+func (s *JobService) heartbeat(ctx context.Context) {
+	s.mu.Lock()
+	ids := make([]int64, 0, len(s.running))
+	for id := range s.running {
+		ids = append(ids, id)
+	}
+	s.mu.Unlock()
+
+	now := s.now().UTC()
+	if len(ids) > 0 {
+		alive, err := s.jobs.HeartbeatJobs(ctx, s.worker, ids, now)
+		if err != nil {
+			log.Printf("刷新后台任务心跳失败: %v", err)
+		} else {
+			s.stopLost(ids, alive)
+		}
+	}
+	if s.leader != nil && !s.leader.IsLeader() {
+		return
+	}
+	reason := fmt.Sprintf("执行实例超过 %s 没有心跳，重新排队", jobStaleAfter)
+	if n, err := s.jobs.RequeueStaleJobs(ctx, now.Add(-jobStaleAfter), now, reason); err != nil {
+		log.Printf("回收超时的后台任务失败: %v", err)
+	} else if n > 0 {
+		log.Printf("⚠️ 回收了 %d 个没有心跳的后台任务", n)
+	}
+}
+
+// stopLost 取消 ids 中不在 alive 里的任务，这些任务已被取消或被其他实例回收
+func (s *JobService) stopLost(ids, alive []int64) {
+	owned := make(map[int64]bool, len(alive))
+	for _, id := range alive {
+		owned[id] = true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		if cancel, ok := s.running[id]; ok && !owned[id] {
+			cancel()
+		}
+	}
+}
+
+// execute 执行任务并保存结果，只在本实例仍持有任务时写入
+func (s *JobService) execute(ctx context.Context, job *models.Job) {
+	kind, registered := s.kind(job.Kind)
+	runCtx, cancel := context.WithCancel(ctx)
+	if registered && kind.timeout > 0 {
+		runCtx, cancel = context.WithTimeout(ctx, kind.timeout)
+	}
+	s.mu.Lock()
+	s.running[job.ID] = cancel
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, job.ID)
+		s.mu.Unlock()
+		cancel()
+	}()
+
+	var result interface{}
+	var err error
+	if registered {
+		result, err = runJobHandler(runCtx, kind.handler, job)
+	} else {
+		err = fmt.Errorf("%w: 本实例没有注册任务类型 %s", ErrInvalidArgument, job.Kind)
+	}
+
+	now := s.now().UTC()
+	job.Result, job.FinishedAt = nil, nil
+	switch {
+	case err == nil:
+		job.Status, job.LastError, job.FinishedAt = JobStatusSucceeded, "", &now
+		if result != nil {
+			if job.Result, err = json.Marshal(result); err != nil {
+				log.Printf("⚠️ 任务 %d 的结果无法编码为 JSON，不保存结果: %v", job.ID, err)
+				job.Result = nil
+			}
+		}
+	case ctx.Err() != nil:
+		// 服务停止：重新排队，本次不计入执行次数
+		job.Status, job.LastError, job.RunAt = JobStatusQueued, err.Error(), now
+		job.Attempts--
+	case errors.Is(err, ErrInvalidArgument) || errors.Is(err, ErrNotFound) || job.Attempts >= job.MaxAttempts:
+		job.Status, job.LastError, job.FinishedAt = JobStatusFailed, err.Error(), &now
+	default:
+		job.Status, job.LastError, job.RunAt = JobStatusQueued, err.Error(), now.Add(jobBackoff(job.Attempts))
+	}
+
+	// ctx 可能已经取消，结果仍然要保存
+	finishCtx, finishCancel := context.WithTimeout(context.WithoutCancel(ctx), jobFinishTimeout)
+	defer finishCancel()
+	owned, ferr := s.jobs.FinishJob(finishCtx, job, s.worker)
+	switch {
+	case ferr != nil:
+		log.Printf("保存任务 %d 的执行结果失败: %v", job.ID, ferr)
+	case !owned:
+		log.Printf("任务 %d 已被取消或回收，丢弃本次执行结果", job.ID)
+	case job.Status == JobStatusFailed:
+		log.Printf("⚠️ 任务 %d（%s）执行失败: %s", job.ID, job.Kind, job.LastError)
+	case job.Status == JobStatusQueued && ctx.Err() == nil:
+		log.Printf("任务 %d（%s）第 %d 次执行失败，%s 重试: %s", job.ID, job.Kind, job.Attempts, job.RunAt.Format(time.RFC3339), job.LastError)
+	}
+}
+
+// runJobHandler 执行 handler，panic 视为本次执行失败
+func runJobHandler(ctx context.Context, handler JobHandler, job *models.Job) (result interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("任务异常退出: %v", p)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// jobBackoff 第 attempts 次执行失败后的等待时间，加上 ±20% 的随机抖动，避免大量任务同时重试
+func jobBackoff(attempts int) time.Duration {
+	delay := jobBackoffMax
+	if attempts < 20 {
+		if d := jobBackoffBase << (attempts - 1); d < jobBackoffMax {
+			delay = d
+		}
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay)/5*2+1)) - delay/5
+	return delay + jitter
+}
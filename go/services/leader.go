@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/repository"
+)
+
+const (
+	// DefaultLeaderLockName 单实例后台任务共用的锁名称
+	DefaultLeaderLockName = "saasview-singleton-workers"
+	// DefaultLeaderLeaseInterval 主实例续约、其他实例尝试接替的周期
+	DefaultLeaderLeaseInterval = 5 * time.Second
+	// leaderReleaseTimeout 停止时释放锁的超时时间
+	leaderReleaseTimeout = 5 * time.Second
+)
+
+// LeaderElector 多实例部署时选出一个主实例执行单实例的后台任务（定时报表、告警检查、日结、一致性检查、归档、
+// ClickHouse 镜像和后台任务的定时计划），其他实例只处理 API 请求和任务队列
+// 锁由 repository.LeaderLock 提供（PostgreSQL 咨询锁）：主实例每隔 interval 续约，续约失败时立即停止单实例任务，
+// 避免与接替的主实例重复执行；其他实例每隔 interval 尝试获取锁，原主实例退出或断开后最迟一个周期内接替
+type LeaderElector struct {
+	lock     repository.LeaderLock
+	interval time.Duration
+	now      func() time.Time
+
+	mu     sync.Mutex
+	status models.LeaderStatus
+	// tasks 通过 Go 注册的单实例任务，每次成为主实例时以新的 term 启动
+	tasks []func(ctx context.Context)
+	// term 本次任期的 ctx，失去主实例身份时取消
+	term   context.Context
+	cancel context.CancelFunc
+}
+
+// NewLeaderElector 创建基于 PostgreSQL 咨询锁的选主，name 相同的实例竞争同一个锁
+func NewLeaderElector(db *database.DB, name string, interval time.Duration) *LeaderElector {
+	return NewLeaderElectorWithLock(repository.NewPostgresAdvisoryLock(db, name), name, interval)
+}
+
+// NewLeaderElectorWithLock 使用指定的锁创建选主
+func NewLeaderElectorWithLock(lock repository.LeaderLock, name string, interval time.Duration) *LeaderElector {
+	if interval <= 0 {
+		interval = DefaultLeaderLeaseInterval
+	}
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "localhost"
+	}
+	return &LeaderElector{
+		lock:     lock,
+		interval: interval,
+		now:      time.Now,
+		status:   models.LeaderStatus{Name: name, Holder: fmt.Sprintf("%s-%d", host, os.Getpid())},
+	}
+}
+
+// Go 注册单实例任务：本实例为主实例时以任期的 ctx 启动，失去主实例身份时 ctx 取消，再次成为主实例时重新启动
+// task 需要在 ctx 取消后尽快返回
+func (e *LeaderElector) Go(task func(ctx context.Context)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.tasks = append(e.tasks, task)
+	if e.term != nil {
+		go task(e.term)
+	}
+}
+
+// IsLeader 本实例当前是否为主实例
+func (e *LeaderElector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.status.Leader
+}
+
+// Status 选主状态，包括成为主实例和失去主实例身份的次数
+func (e *LeaderElector) Status() models.LeaderStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.status
+}
+
+// Run 立即尝试成为主实例，之后每隔 interval 续约或重新尝试，直到 ctx 取消；ctx 取消时停止单实例任务并释放锁
+func (e *LeaderElector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		e.tick(ctx)
+
+		select {
+		case <-ctx.Done():
+			if e.IsLeader() {
+				e.resign("服务停止")
+				releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), leaderReleaseTimeout)
+				if err := e.lock.Release(releaseCtx); err != nil {
+					log.Printf("⚠️ %v", err)
+				}
+				cancel()
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tick 主实例续约，其他实例尝试获取锁
+func (e *LeaderElector) tick(ctx context.Context) {
+	if e.IsLeader() {
+		held, err := e.lock.Renew(ctx)
+		switch {
+		case err != nil:
+			e.setError(err)
+			e.resign(err.Error())
+		case !held:
+			e.resign("锁已丢失")
+		default:
+			now := e.now().UTC()
+			e.mu.Lock()
+			e.status.LastRenewedAt = &now
+			e.mu.Unlock()
+		}
+		return
+	}
+
+	acquired, err := e.lock.TryAcquire(ctx)
+	if err != nil {
+		e.setError(err)
+		return
+	}
+	if acquired {
+		e.elect()
+	}
+}
+
+// elect 成为主实例，启动全部单实例任务
+func (e *LeaderElector) elect() {
+	now := e.now().UTC()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.status.Leader = true
+	e.status.Since, e.status.LastRenewedAt = &now, &now
+	e.status.Acquisitions++
+	e.status.LastError = ""
+	e.term, e.cancel = context.WithCancel(context.Background())
+	for _, task := range e.tasks {
+		go task(e.term)
+	}
+	log.Printf("👑 %s 成为 %s 的主实例，启动 %d 个单实例任务", e.status.Holder, e.status.Name, len(e.tasks))
+}
+
+// resign 失去主实例身份，取消本次任期的单实例任务
+func (e *LeaderElector) resign(reason string) {
+	now := e.now().UTC()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.status.Leader {
+		return
+	}
+	e.status.Leader = false
+	e.status.Since = &now
+	e.status.Losses++
+	e.cancel()
+	e.term, e.cancel = nil, nil
+	log.Printf("⚠️ %s 不再是 %s 的主实例（%s），已停止单实例任务", e.status.Holder, e.status.Name, reason)
+}
+
+func (e *LeaderElector) setError(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.status.LastError = err.Error()
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/redact"
+	"timezone-saas-demo/repository"
+)
+
+const (
+	// lifecycleCacheTTL 生命周期状态的缓存时间，多实例部署时其他实例的变更最迟在该时间后生效
+	lifecycleCacheTTL = 30 * time.Second
+	// lifecycleEventLimit 查询生命周期时返回的最近变更记录数
+	lifecycleEventLimit = 20
+	// DefaultTrialExpiryInterval 检查试用到期的周期
+	DefaultTrialExpiryInterval = 5 * time.Minute
+)
+
+// lifecycleTransitions 每个状态允许变更到的状态；trial 到 trial 为延长试用，归档的租户只能重新启用
+var lifecycleTransitions = map[string][]string{
+	models.TenantLifecycleTrial:     {models.TenantLifecycleTrial, models.TenantLifecycleActive, models.TenantLifecycleSuspended, models.TenantLifecycleArchived},
+	models.TenantLifecycleActive:    {models.TenantLifecycleSuspended, models.TenantLifecycleArchived},
+	models.TenantLifecycleSuspended: {models.TenantLifecycleActive, models.TenantLifecycleArchived},
+	models.TenantLifecycleArchived:  {models.TenantLifecycleActive},
+}
+
+// TenantLifecycleRequest 变更租户生命周期的请求
+type TenantLifecycleRequest struct {
+	State string `json:"state"`
+	// TrialEndsOn 试用的最后一天（商户本地日期 YYYY-MM-DD），state 为 trial 时必填
+	TrialEndsOn string `json:"trial_ends_on"`
+	Reason      string `json:"reason"`
+	Operator    string `json:"operator"`
+}
+
+// LifecycleService 租户生命周期：状态变更、变更审计、请求拦截用的状态查询和试用自动到期
+type LifecycleService struct {
+	lifecycle repository.LifecycleRepository
+	now       func() time.Time
+
+	mu    sync.Mutex
+	cache map[int]lifecycleEntry
+}
+
+type lifecycleEntry struct {
+	state    string
+	loadedAt time.Time
+}
+
+// NewLifecycleService 创建租户生命周期服务，使用 PostgreSQL 仓储
+func NewLifecycleService(db *database.DB) *LifecycleService {
+	return NewLifecycleServiceWithRepositories(repository.NewPostgresLifecycleRepository(db))
+}
+
+// NewLifecycleServiceWithRepositories 使用指定仓储创建租户生命周期服务
+func NewLifecycleServiceWithRepositories(lifecycle repository.LifecycleRepository) *LifecycleService {
+	return &LifecycleService{
+		lifecycle: lifecycle,
+		now:       time.Now,
+		cache:     make(map[int]lifecycleEntry),
+	}
+}
+
+// Get 商户当前的生命周期状态和最近的变更记录
+func (s *LifecycleService) Get(ctx context.Context, merchantID int) (*models.TenantLifecycle, error) {
+	l, err := s.lifecycle.Lifecycle(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if l.Events, err = s.lifecycle.Events(ctx, merchantID, lifecycleEventLimit); err != nil {
+		return nil, err
+	}
+	if l.Events == nil {
+		l.Events = []models.TenantLifecycleEvent{}
+	}
+	return l, nil
+}
+
+// State 商户当前的生命周期状态，缓存 lifecycleCacheTTL，供每个请求检查
+func (s *LifecycleService) State(ctx context.Context, merchantID int) (string, error) {
+	s.mu.Lock()
+	entry, ok := s.cache[merchantID]
+	s.mu.Unlock()
+	if ok && s.now().Sub(entry.loadedAt) < lifecycleCacheTTL {
+		return entry.state, nil
+	}
+
+	l, err := s.lifecycle.Lifecycle(ctx, merchantID)
+	if err != nil {
+		return "", err
+	}
+	s.store(merchantID, l.State)
+	return l.State, nil
+}
+
+// Transition 校验并变更商户的生命周期，写入变更记录，返回变更后的状态
+func (s *LifecycleService) Transition(ctx context.Context, merchantID int, req TenantLifecycleRequest) (*models.TenantLifecycle, error) {
+	to := strings.ToLower(strings.TrimSpace(req.State))
+	if !isLifecycle(to) {
+		return nil, fmt.Errorf("%w: 无效的生命周期状态 %q，可选 %s", ErrInvalidArgument, req.State, strings.Join(models.TenantLifecycles, "、"))
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if len([]rune(reason)) > 500 {
+		return nil, fmt.Errorf("%w: 变更原因不超过 500 个字符", ErrInvalidArgument)
+	}
+
+	current, err := s.lifecycle.Lifecycle(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if !lifecycleAllowed(current.State, to) {
+		return nil, fmt.Errorf("%w: 商户 %d 的状态为 %s，不能变更为 %s", ErrConflict, merchantID, current.State, to)
+	}
+
+	event := &models.TenantLifecycleEvent{
+		MerchantID: merchantID,
+		From:       current.State,
+		To:         to,
+		Reason:     reason,
+		ChangedBy:  operatorOrSystem(redact.Inline(req.Operator)),
+	}
+	if to == models.TenantLifecycleTrial {
+		if event.TrialEndsOn, err = s.trialEndsOn(current.Timezone, req.TrialEndsOn); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.apply(ctx, event); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, merchantID)
+}
+
+// trialEndsOn 校验试用的最后一天：YYYY-MM-DD 且不早于商户本地的今天
+func (s *LifecycleService) trialEndsOn(timezone, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", fmt.Errorf("%w: 试用必须指定 trial_ends_on", ErrInvalidArgument)
+	}
+	if _, err := time.Parse("2006-01-02", value); err != nil {
+		return "", fmt.Errorf("%w: trial_ends_on 格式错误，应为 YYYY-MM-DD", ErrInvalidArgument)
+	}
+	loc, err := LoadLocation(timezone)
+	if err != nil {
+		return "", err
+	}
+	if today := s.now().In(loc).Format("2006-01-02"); value < today {
+		return "", fmt.Errorf("%w: trial_ends_on 早于商户本地的今天 %s", ErrInvalidArgument, today)
+	}
+	return value, nil
+}
+
+// ExpireTrials 商户本地日期已过 trial_ends_on 的试用租户改为 suspended，返回处理的租户数
+// 某个商户失败时记录日志并继续处理其余商户
+func (s *LifecycleService) ExpireTrials(ctx context.Context) (int, error) {
+	trials, err := s.lifecycle.Trials(ctx)
+	if err != nil {
+		return 0, err
+	}
+	expired := 0
+	for _, trial := range trials {
+		loc, err := LoadLocation(trial.Timezone)
+		if err != nil {
+			log.Printf("⚠️ 商户 %d 的时区 %s 无效，跳过试用到期检查: %v", trial.MerchantID, trial.Timezone, err)
+			continue
+		}
+		if s.now().In(loc).Format("2006-01-02") <= trial.TrialEndsOn {
+			continue
+		}
+		err = s.apply(ctx, &models.TenantLifecycleEvent{
+			MerchantID: trial.MerchantID,
+			From:       models.TenantLifecycleTrial,
+			To:         models.TenantLifecycleSuspended,
+			Reason:     fmt.Sprintf("试用已于 %s 结束", trial.TrialEndsOn),
+			ChangedBy:  operatorOrSystem(""),
+		})
+		switch {
+		case errors.Is(err, ErrConflict), errors.Is(err, ErrNotFound):
+			// 检查期间已被手动变更或删除
+		case err != nil:
+			log.Printf("⚠️ 商户 %d 试用到期处理失败: %v", trial.MerchantID, err)
+		default:
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// Run 启动时检查一次试用到期，之后每隔 interval 检查，直到 ctx 取消
+func (s *LifecycleService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := s.ExpireTrials(ctx); err != nil {
+			log.Printf("试用到期检查失败: %v", err)
+		} else if n > 0 {
+			log.Printf("⏳ %d 个租户试用到期，已暂停", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// apply 写入变更并更新本实例的缓存，变更记录同时写入日志
+func (s *LifecycleService) apply(ctx context.Context, event *models.TenantLifecycleEvent) error {
+	if err := s.lifecycle.Transition(ctx, event); err != nil {
+		return err
+	}
+	s.store(event.MerchantID, event.To)
+	log.Printf("🔄 商户 %d 生命周期已由 %s 变更: %s → %s（%s）", event.MerchantID, event.ChangedBy, event.From, event.To, event.Reason)
+	return nil
+}
+
+func (s *LifecycleService) store(merchantID int, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache[merchantID] = lifecycleEntry{state: state, loadedAt: s.now()}
+}
+
+// lifecycleAllowed 是否允许从 from 变更为 to
+func lifecycleAllowed(from, to string) bool {
+	for _, next := range lifecycleTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+func isLifecycle(state string) bool {
+	for _, s := range models.TenantLifecycles {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"timezone-saas-demo/models"
+)
+
+// 按租户限流的默认配置
+const (
+	// DefaultTenantQueryLimit 每个租户同时执行的分析查询数
+	// 连接池共 25 个连接，fanout 方式的一次分析最多同时占用 5 个
+	DefaultTenantQueryLimit = 4
+	// DefaultTenantQueueTimeout 名额已满时的最长排队时间
+	DefaultTenantQueueTimeout = 2 * time.Second
+)
+
+// OverloadedError 租户的查询名额已满且排队超时，RetryAfter 为建议的重试间隔
+type OverloadedError struct {
+	Tenant     string
+	Limit      int
+	RetryAfter time.Duration
+}
+
+func (e *OverloadedError) Error() string {
+	return fmt.Sprintf("%v: 租户 %s 同时执行的查询已达上限 %d", ErrOverloaded, e.Tenant, e.Limit)
+}
+
+// Unwrap 使 errors.Is(err, ErrOverloaded) 成立
+func (e *OverloadedError) Unwrap() error {
+	return ErrOverloaded
+}
+
+// TenantLimiter 按租户限制同时执行的查询数，避免单个租户的重查询占满连接池
+// 名额已满时最多排队 queueTimeout，超时返回 *OverloadedError
+type TenantLimiter struct {
+	limit        int
+	queueTimeout time.Duration
+
+	mu      sync.Mutex
+	tenants map[string]*tenantSlots
+}
+
+// tenantSlots 单个租户的信号量和统计
+type tenantSlots struct {
+	sem       chan struct{}
+	inFlight  int
+	waiting   int
+	acquired  int64
+	queued    int64
+	rejected  int64
+	totalWait time.Duration
+	maxWait   time.Duration
+}
+
+// NewTenantLimiter 创建按租户的并发限制，limit <= 0 表示不限制
+func NewTenantLimiter(limit int, queueTimeout time.Duration) *TenantLimiter {
+	return &TenantLimiter{
+		limit:        limit,
+		queueTimeout: queueTimeout,
+		tenants:      make(map[string]*tenantSlots),
+	}
+}
+
+// Acquire 为租户占用一个查询名额，成功时必须调用返回的 release 归还
+// ctx 取消时停止排队并返回 ctx 的错误
+func (l *TenantLimiter) Acquire(ctx context.Context, tenant string) (release func(), err error) {
+	if l == nil || l.limit <= 0 {
+		return func() {}, nil
+	}
+
+	slots := l.slots(tenant)
+	start := time.Now()
+	select {
+	case slots.sem <- struct{}{}:
+	default:
+		l.mu.Lock()
+		slots.waiting++
+		l.mu.Unlock()
+
+		timer := time.NewTimer(l.queueTimeout)
+		defer timer.Stop()
+		select {
+		case slots.sem <- struct{}{}:
+			l.mu.Lock()
+			slots.waiting--
+			slots.queued++
+			l.mu.Unlock()
+		case <-timer.C:
+			l.mu.Lock()
+			slots.waiting--
+			slots.rejected++
+			l.mu.Unlock()
+			log.Printf("⚠️ 租户 %s 的查询排队超过 %s，拒绝请求", tenant, l.queueTimeout)
+			return nil, &OverloadedError{Tenant: tenant, Limit: l.limit, RetryAfter: l.retryAfter()}
+		case <-ctx.Done():
+			l.mu.Lock()
+			slots.waiting--
+			l.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+
+	wait := time.Since(start)
+	l.mu.Lock()
+	slots.inFlight++
+	slots.acquired++
+	slots.totalWait += wait
+	if wait > slots.maxWait {
+		slots.maxWait = wait
+	}
+	l.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			slots.inFlight--
+			l.mu.Unlock()
+			<-slots.sem
+		})
+	}, nil
+}
+
+// Stats 各租户的并发和排队统计，按租户排序
+func (l *TenantLimiter) Stats() []models.TenantQueryStats {
+	if l == nil {
+		return []models.TenantQueryStats{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := make([]models.TenantQueryStats, 0, len(l.tenants))
+	for tenant, slots := range l.tenants {
+		s := models.TenantQueryStats{
+			Tenant:    tenant,
+			Limit:     l.limit,
+			InFlight:  slots.inFlight,
+			Waiting:   slots.waiting,
+			Acquired:  slots.acquired,
+			Queued:    slots.queued,
+			Rejected:  slots.rejected,
+			MaxWaitMs: durationMs(slots.maxWait),
+		}
+		if slots.acquired > 0 {
+			s.AvgWaitMs = durationMs(slots.totalWait / time.Duration(slots.acquired))
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Tenant < stats[j].Tenant })
+	return stats
+}
+
+// slots 获取租户的信号量，首次出现的租户按当前上限创建
+func (l *TenantLimiter) slots(tenant string) *tenantSlots {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	slots, ok := l.tenants[tenant]
+	if !ok {
+		slots = &tenantSlots{sem: make(chan struct{}, l.limit)}
+		l.tenants[tenant] = slots
+	}
+	return slots
+}
+
+// retryAfter 建议客户端的重试间隔：一个排队周期，至少 1 秒
+func (l *TenantLimiter) retryAfter() time.Duration {
+	if l.queueTimeout < time.Second {
+		return time.Second
+	}
+	return l.queueTimeout.Round(time.Second)
+}
+
+// durationMs 转换为毫秒，保留两位小数
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()/10) / 100
+}
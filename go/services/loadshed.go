@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"timezone-saas-demo/models"
+)
+
+// 过载保护的默认值、级别和请求优先级
+const (
+	// DefaultLoadShedInterval 采样连接池和排队指标的周期
+	DefaultLoadShedInterval = time.Second
+	// DefaultLoadShedPoolWaits 连接池每秒等待空闲连接的次数超过该值视为过载
+	DefaultLoadShedPoolWaits = 20.0
+	// DefaultLoadShedQueueDepth 排队等待分析查询名额的请求数超过该值视为过载
+	DefaultLoadShedQueueDepth = 8
+	// DefaultLoadShedCriticalFactor 任一指标达到阈值的该倍数时，除受保护的接口外全部拒绝
+	DefaultLoadShedCriticalFactor = 2.0
+	// DefaultLoadShedCooldown 指标回落后继续拒绝的时间，避免在阈值附近反复切换
+	DefaultLoadShedCooldown = 10 * time.Second
+	// DefaultLoadShedLowPriority 过载时最先拒绝的接口：演示、导出和下载
+	DefaultLoadShedLowPriority = "/api/timezone/demo,/api/admin/tenants/*/export,/api/admin/merchants/export,/api/reports/runs/*/artifact,/api/admin/loadtest/profile"
+	// DefaultLoadShedProtected 任何级别都不拒绝的接口：健康检查、订单接收、管理接口和文档
+	DefaultLoadShedProtected = "/api/health,/api/ingest,/api/admin,/api/docs"
+
+	LoadShedLevelOK     = "ok"
+	LoadShedLevelLow    = "shedding_low"
+	LoadShedLevelNormal = "shedding_normal"
+
+	LoadShedPriorityProtected = "protected"
+	LoadShedPriorityNormal    = "normal"
+	LoadShedPriorityLow       = "low"
+)
+
+// LoadShedPolicy 过载保护策略：任一指标超过阈值时拒绝低优先级请求，达到阈值的 CriticalFactor 倍时拒绝除受保护接口外的全部请求
+type LoadShedPolicy struct {
+	// PoolWaitsPerSecond 连接池每秒等待空闲连接的次数阈值，为 0 时不检查
+	PoolWaitsPerSecond float64
+	// QueueDepth 排队等待分析查询名额的请求数阈值，为 0 时不检查
+	QueueDepth int
+	// CriticalFactor 为 0 时只拒绝低优先级请求
+	CriticalFactor float64
+	// Cooldown 指标回落到阈值以下后继续拒绝的时间，也是 Retry-After 的上限
+	Cooldown time.Duration
+	// LowPriority、Protected 接口路径：不含通配符时按路径前缀（整段）匹配，含 * 时按 path.Match 匹配整个路径；
+	// 同时匹配两者时按低优先级处理，例如 /api/admin 下的导出
+	LowPriority []string
+	Protected   []string
+}
+
+// ParseLoadShedPaths 解析逗号分隔的接口路径
+func ParseLoadShedPaths(value string) ([]string, error) {
+	paths := []string{}
+	for _, p := range strings.Split(value, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !strings.HasPrefix(p, "/") {
+			return nil, fmt.Errorf("%w: 接口路径必须以 / 开头: %q", ErrInvalidArgument, p)
+		}
+		if _, err := path.Match(p, "/"); err != nil {
+			return nil, fmt.Errorf("%w: 接口路径格式错误: %q", ErrInvalidArgument, p)
+		}
+		paths = append(paths, strings.TrimSuffix(p, "/"))
+	}
+	return paths, nil
+}
+
+// LoadShedder 过载保护：定期采样连接池的等待次数和分析查询的排队数，过载时按接口优先级拒绝请求，
+// 由 HTTP 层返回 503 和 Retry-After；指标回落后经过 Cooldown 才恢复，统计只有本进程的数据
+type LoadShedder struct {
+	policy     LoadShedPolicy
+	poolWaits  func() int64
+	queueDepth func() int
+	now        func() time.Time
+
+	mu          sync.Mutex
+	sampledAt   time.Time
+	lastWaits   int64
+	waitRate    float64
+	depth       int
+	lowUntil    time.Time
+	normalUntil time.Time
+	level       string
+	since       time.Time
+	transitions int64
+	shed        map[string]int64
+}
+
+// NewLoadShedder 创建过载保护，poolWaits 返回连接池累计的等待次数（database/sql 的 WaitCount），queueDepth 返回当前排队的请求数
+func NewLoadShedder(policy LoadShedPolicy, poolWaits func() int64, queueDepth func() int) *LoadShedder {
+	if policy.Cooldown <= 0 {
+		policy.Cooldown = DefaultLoadShedCooldown
+	}
+	return &LoadShedder{
+		policy:     policy,
+		poolWaits:  poolWaits,
+		queueDepth: queueDepth,
+		now:        time.Now,
+		level:      LoadShedLevelOK,
+		since:      time.Now(),
+		shed:       map[string]int64{},
+	}
+}
+
+// Run 每隔 interval 采样一次，直到 ctx 取消
+func (s *LoadShedder) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.Sample()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sample 采样指标并更新过载级别；第一次采样只记录连接池的等待次数，不计算速率
+func (s *LoadShedder) Sample() {
+	waits, depth := s.poolWaits(), s.queueDepth()
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rate := 0.0
+	if elapsed := now.Sub(s.sampledAt).Seconds(); !s.sampledAt.IsZero() && elapsed > 0 && waits >= s.lastWaits {
+		rate = float64(waits-s.lastWaits) / elapsed
+	}
+	s.sampledAt, s.lastWaits, s.waitRate, s.depth = now, waits, rate, depth
+
+	pressure := s.pressure(rate, depth)
+	if pressure > 1 {
+		s.lowUntil = now.Add(s.policy.Cooldown)
+	}
+	if s.policy.CriticalFactor > 0 && pressure >= s.policy.CriticalFactor {
+		s.normalUntil = now.Add(s.policy.Cooldown)
+	}
+
+	level := s.currentLevel(now)
+	if level == s.level {
+		return
+	}
+	if level == LoadShedLevelOK {
+		log.Printf("✅ 负载已恢复（连接池等待 %.1f 次/秒，排队 %d 个），停止拒绝请求，过载持续 %s",
+			rate, depth, now.Sub(s.since).Round(time.Second))
+	} else {
+		log.Printf("⚠️ 负载过高（连接池等待 %.1f 次/秒，排队 %d 个），进入 %s，拒绝%s请求", rate, depth, level, loadShedScope(level))
+	}
+	s.level, s.since = level, now
+	s.transitions++
+}
+
+// Admit 请求是否放行；拒绝时返回建议的重试间隔（至少 1 秒）并计入统计。nil 时全部放行
+func (s *LoadShedder) Admit(urlPath string) (bool, time.Duration) {
+	if s == nil {
+		return true, 0
+	}
+	priority := s.Classify(urlPath)
+	if priority == LoadShedPriorityProtected {
+		return true, 0
+	}
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until := s.normalUntil
+	if priority == LoadShedPriorityLow {
+		until = s.lowUntil
+	}
+	if !now.Before(until) {
+		return true, 0
+	}
+	s.shed[priority]++
+	return false, max(time.Second, until.Sub(now))
+}
+
+// Classify 接口的优先级
+func (s *LoadShedder) Classify(urlPath string) string {
+	switch {
+	case loadShedMatch(s.policy.LowPriority, urlPath):
+		return LoadShedPriorityLow
+	case loadShedMatch(s.policy.Protected, urlPath):
+		return LoadShedPriorityProtected
+	}
+	return LoadShedPriorityNormal
+}
+
+// Status 当前的过载级别、最近一次采样的指标、策略和各优先级被拒绝的请求数
+func (s *LoadShedder) Status() *models.LoadShedStatus {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := &models.LoadShedStatus{
+		Level:              s.currentLevel(s.now()),
+		Since:              s.since.UTC(),
+		PoolWaitsPerSecond: math.Round(s.waitRate*100) / 100,
+		QueueDepth:         s.depth,
+		Transitions:        s.transitions,
+		Shed:               map[string]int64{LoadShedPriorityLow: s.shed[LoadShedPriorityLow], LoadShedPriorityNormal: s.shed[LoadShedPriorityNormal]},
+		Policy: models.LoadShedPolicyInfo{
+			PoolWaitsPerSecond: s.policy.PoolWaitsPerSecond,
+			QueueDepth:         s.policy.QueueDepth,
+			CriticalFactor:     s.policy.CriticalFactor,
+			Cooldown:           s.policy.Cooldown.String(),
+			LowPriority:        s.policy.LowPriority,
+			Protected:          s.policy.Protected,
+		},
+	}
+	if !s.sampledAt.IsZero() {
+		sampledAt := s.sampledAt.UTC()
+		status.SampledAt = &sampledAt
+	}
+	return status
+}
+
+// pressure 指标相对阈值的最大倍数，阈值为 0 的指标不参与
+func (s *LoadShedder) pressure(rate float64, depth int) float64 {
+	var pressure float64
+	if s.policy.PoolWaitsPerSecond > 0 {
+		pressure = max(pressure, rate/s.policy.PoolWaitsPerSecond)
+	}
+	if s.policy.QueueDepth > 0 {
+		pressure = max(pressure, float64(depth)/float64(s.policy.QueueDepth))
+	}
+	return pressure
+}
+
+// currentLevel now 时刻的过载级别
+func (s *LoadShedder) currentLevel(now time.Time) string {
+	switch {
+	case now.Before(s.normalUntil):
+		return LoadShedLevelNormal
+	case now.Before(s.lowUntil):
+		return LoadShedLevelLow
+	}
+	return LoadShedLevelOK
+}
+
+// loadShedScope 级别拒绝的请求范围，用于日志
+func loadShedScope(level string) string {
+	if level == LoadShedLevelNormal {
+		return "除受保护接口外的全部"
+	}
+	return "低优先级"
+}
+
+// loadShedMatch 路径是否匹配任一模式
+func loadShedMatch(patterns []string, urlPath string) bool {
+	for _, pattern := range patterns {
+		if strings.Contains(pattern, "*") {
+			if ok, _ := path.Match(pattern, urlPath); ok {
+				return true
+			}
+			continue
+		}
+		if urlPath == pattern || strings.HasPrefix(urlPath, pattern+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"timezone-saas-demo/models"
+)
+
+// 压测场景导出的默认值
+const (
+	// DefaultLoadTestRate 压测每秒的请求数
+	DefaultLoadTestRate = 10
+	// DefaultLoadTestDuration 压测时长
+	DefaultLoadTestDuration = 5 * time.Minute
+	// DefaultLoadTestRequests vegeta 目标文件的请求数，vegeta 按顺序循环使用
+	DefaultLoadTestRequests = 1000
+	// maxLoadTestRequests vegeta 目标文件的最大请求数
+	maxLoadTestRequests = 100000
+)
+
+// loadTestPathVar 路由模板中的路径变量，如 {id:[0-9]+}
+var loadTestPathVar = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// LoadTestOptions 压测场景参数
+type LoadTestOptions struct {
+	// BaseURL 压测目标的服务地址，如 https://staging.example.com；k6 脚本可用环境变量 BASE_URL 覆盖
+	BaseURL string
+	// Rate 每秒的请求数，为 0 时使用 DefaultLoadTestRate
+	Rate int
+	// Duration k6 场景的时长，为 0 时使用 DefaultLoadTestDuration
+	Duration time.Duration
+	// Requests vegeta 目标文件的请求数，为 0 时使用 DefaultLoadTestRequests
+	Requests int
+	// Seed vegeta 目标文件抽样的随机种子，相同的用量和种子生成相同的文件；为 0 时按当前时间
+	Seed int64
+}
+
+// loadTestRoute 压测场景中的一个路由，各分布只保留可重放的取值
+type loadTestRoute struct {
+	Method string `json:"method"`
+	// Path 路径变量写作 {name}
+	Path    string          `json:"path"`
+	Weight  int64           `json:"weight"`
+	Tenants []loadTestValue `json:"tenants"`
+	Vars    []loadTestParam `json:"vars"`
+	Query   []loadTestParam `json:"query"`
+}
+
+// loadTestParam 参数以 Presence 的概率出现，取值按次数加权抽样
+type loadTestParam struct {
+	Name     string          `json:"name"`
+	Presence float64         `json:"presence"`
+	Values   []loadTestValue `json:"values"`
+}
+
+type loadTestValue struct {
+	Value  string `json:"value"`
+	Weight int64  `json:"weight"`
+}
+
+// K6Script 按用量生成 k6 脚本：constant-arrival-rate 场景，每个请求按各路由的请求数抽样，
+// 路径变量、查询参数和 X-Tenant-ID 按统计到的取值分布抽样；只包含 GET 请求
+//
+//	k6 run -e BASE_URL=https://staging.example.com loadtest.js
+func K6Script(profile *models.UsageProfile, opts LoadTestOptions) ([]byte, error) {
+	opts = loadTestDefaults(opts)
+	routes, err := loadTestRoutes(profile)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(routes, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("序列化压测场景失败: %w", err)
+	}
+	baseURL, err := json.Marshal(strings.TrimRight(opts.BaseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("序列化压测场景失败: %w", err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// 按 %s 至 %s 的 %d 个请求生成的压测场景\n", profile.Since.Format(time.RFC3339), profile.GeneratedAt.Format(time.RFC3339), profile.TotalRequests)
+	fmt.Fprintf(&b, "// k6 run -e BASE_URL=https://staging.example.com loadtest.js\n")
+	b.WriteString("import http from 'k6/http';\nimport { check } from 'k6';\n\n")
+	fmt.Fprintf(&b, "const BASE_URL = __ENV.BASE_URL || %s;\n\n", baseURL)
+	fmt.Fprintf(&b, "const ROUTES = %s;\n\n", data)
+	fmt.Fprintf(&b, `export const options = {
+  scenarios: {
+    production_shaped: {
+      executor: 'constant-arrival-rate',
+      rate: %d,
+      timeUnit: '1s',
+      duration: '%s',
+      preAllocatedVUs: %d,
+      maxVUs: %d,
+    },
+  },
+};
+`, opts.Rate, opts.Duration, max(opts.Rate, 10), max(opts.Rate*10, 50))
+	b.WriteString(`
+function pick(items) {
+  let total = 0;
+  for (const item of items) total += item.weight;
+  let r = Math.random() * total;
+  for (const item of items) {
+    r -= item.weight;
+    if (r < 0) return item;
+  }
+  return items[items.length - 1];
+}
+
+export default function () {
+  const route = pick(ROUTES);
+  let path = route.path;
+  for (const v of route.vars) {
+    path = path.replace('{' + v.name + '}', encodeURIComponent(pick(v.values).value));
+  }
+  const query = [];
+  for (const q of route.query) {
+    if (Math.random() < q.presence) {
+      query.push(encodeURIComponent(q.name) + '=' + encodeURIComponent(pick(q.values).value));
+    }
+  }
+  const headers = {};
+  if (route.tenants.length > 0) {
+    const tenant = pick(route.tenants).value;
+    if (tenant !== '') headers['X-Tenant-ID'] = tenant;
+  }
+  const url = BASE_URL + path + (query.length > 0 ? '?' + query.join('&') : '');
+  const res = http.get(url, { headers: headers, tags: { name: route.path } });
+  check(res, { 'status < 500': (r) => r.status < 500 });
+}
+`)
+	return []byte(b.String()), nil
+}
+
+// VegetaTargets 按用量抽样生成 vegeta 目标文件（HTTP 格式），只包含 GET 请求；
+// vegeta 按顺序循环使用目标，请求数越多越接近统计到的分布
+//
+//	vegeta attack -targets targets.txt -rate 10 -duration 5m | vegeta report
+func VegetaTargets(profile *models.UsageProfile, opts LoadTestOptions) ([]byte, error) {
+	opts = loadTestDefaults(opts)
+	routes, err := loadTestRoutes(profile)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Seed == 0 {
+		opts.Seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(opts.Seed))
+	baseURL := strings.TrimRight(opts.BaseURL, "/")
+
+	routeWeights := make([]int64, len(routes))
+	for i, route := range routes {
+		routeWeights[i] = route.Weight
+	}
+	var b strings.Builder
+	for n := 0; n < opts.Requests; n++ {
+		route := routes[loadTestPick(rng, routeWeights)]
+		path := route.Path
+		for _, v := range route.Vars {
+			path = strings.Replace(path, "{"+v.Name+"}", url.PathEscape(loadTestSample(rng, v.Values)), 1)
+		}
+		query := url.Values{}
+		for _, q := range route.Query {
+			if rng.Float64() < q.Presence {
+				query.Set(q.Name, loadTestSample(rng, q.Values))
+			}
+		}
+		target := baseURL + path
+		if len(query) > 0 {
+			target += "?" + query.Encode()
+		}
+		fmt.Fprintf(&b, "%s %s\n", route.Method, target)
+		if len(route.Tenants) > 0 {
+			if tenant := loadTestSample(rng, route.Tenants); tenant != "" {
+				fmt.Fprintf(&b, "X-Tenant-ID: %s\n", tenant)
+			}
+		}
+		b.WriteString("\n")
+	}
+	return []byte(b.String()), nil
+}
+
+func loadTestDefaults(opts LoadTestOptions) LoadTestOptions {
+	if opts.Rate <= 0 {
+		opts.Rate = DefaultLoadTestRate
+	}
+	if opts.Duration <= 0 {
+		opts.Duration = DefaultLoadTestDuration
+	}
+	if opts.Requests <= 0 {
+		opts.Requests = DefaultLoadTestRequests
+	}
+	if opts.Requests > maxLoadTestRequests {
+		opts.Requests = maxLoadTestRequests
+	}
+	return opts
+}
+
+// loadTestRoutes 用量中可重放的 GET 路由：路径变量没有可用取值的路由跳过，
+// 被脱敏或没有可用取值的查询参数不带上，默认租户不带 X-Tenant-ID
+func loadTestRoutes(profile *models.UsageProfile) ([]loadTestRoute, error) {
+	var routes []loadTestRoute
+	for _, r := range profile.Routes {
+		if r.Method != http.MethodGet {
+			continue
+		}
+		route := loadTestRoute{
+			Method: r.Method,
+			Path:   loadTestPathVar.ReplaceAllString(r.Path, "{$1}"),
+			Weight: r.Requests,
+			Vars:   []loadTestParam{},
+			Query:  []loadTestParam{},
+		}
+		for _, t := range r.Tenants {
+			value := t.Value
+			if value == DefaultTenant {
+				value = ""
+			}
+			route.Tenants = append(route.Tenants, loadTestValue{Value: value, Weight: t.Count})
+		}
+		replayable := true
+		for _, v := range r.PathVars {
+			if len(v.Values) == 0 {
+				replayable = false
+				break
+			}
+			route.Vars = append(route.Vars, loadTestParam{Name: v.Name, Presence: 1, Values: loadTestValues(v.Values)})
+		}
+		if !replayable {
+			continue
+		}
+		for _, q := range r.Query {
+			if q.Redacted || len(q.Values) == 0 {
+				continue
+			}
+			route.Query = append(route.Query, loadTestParam{Name: q.Name, Presence: q.Presence, Values: loadTestValues(q.Values)})
+		}
+		if route.Tenants == nil {
+			route.Tenants = []loadTestValue{}
+		}
+		routes = append(routes, route)
+	}
+	if len(routes) == 0 {
+		return nil, fmt.Errorf("%w: 尚未统计到可重放的 GET 请求", ErrNotFound)
+	}
+	return routes, nil
+}
+
+func loadTestValues(values []models.UsageValue) []loadTestValue {
+	result := make([]loadTestValue, 0, len(values))
+	for _, v := range values {
+		result = append(result, loadTestValue{Value: v.Value, Weight: v.Count})
+	}
+	return result
+}
+
+// loadTestSample 按次数加权抽样一个取值
+func loadTestSample(rng *rand.Rand, values []loadTestValue) string {
+	weights := make([]int64, len(values))
+	for i, v := range values {
+		weights[i] = v.Weight
+	}
+	return values[loadTestPick(rng, weights)].Value
+}
+
+// loadTestPick 按权重抽样，返回下标
+func loadTestPick(rng *rand.Rand, weights []int64) int {
+	var total int64
+	for _, w := range weights {
+		total += w
+	}
+	r := rng.Int63n(total)
+	for i, w := range weights {
+		if r < w {
+			return i
+		}
+		r -= w
+	}
+	return len(weights) - 1
+}
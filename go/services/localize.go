@@ -0,0 +1,66 @@
+package services
+
+import (
+	"time"
+
+	"timezone-saas-demo/locale"
+	"timezone-saas-demo/models"
+)
+
+// LocalizeOrders 按语言填充订单的星期、日期和金额展示字段，原始字段保持不变
+func LocalizeOrders(orders []models.OrderAnalysis, l locale.Locale) {
+	for i := range orders {
+		o := &orders[i]
+		o.LocalWeekdayName = l.Weekday(time.Weekday(o.LocalDayOfWeek))
+		o.LocalDateDisplay = l.Date(o.OrderTimeLocal)
+		o.AmountDisplay = l.Currency(o.Amount, o.Currency)
+	}
+}
+
+// HumanizeOrders 按语言填充下单时刻相对 now 的描述，今天、昨天按商户时区的本地日期判断
+func HumanizeOrders(orders []models.OrderAnalysis, l locale.Locale, now time.Time) {
+	for i := range orders {
+		o := &orders[i]
+		loc, err := LoadLocation(o.Timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+		o.OrderTimeHumanized = l.Relative(o.OrderTimeUTC.In(loc), now)
+	}
+}
+
+// HumanizeSchedule 按语言填充各次发生相对 now 的描述，今天、明天按规则时区的本地日期判断
+func HumanizeSchedule(occurrences []models.ScheduleOccurrence, timezone string, l locale.Locale, now time.Time) error {
+	loc, err := LoadLocation(timezone)
+	if err != nil {
+		return err
+	}
+	for i := range occurrences {
+		occ := &occurrences[i]
+		occ.Humanized = l.Relative(occ.UTC.In(loc), now)
+	}
+	return nil
+}
+
+// LocalizeComparison 按语言填充时区对比中的星期名称
+func LocalizeComparison(comparison *models.TimezoneComparison, l locale.Locale) {
+	for i := range comparison.Comparisons {
+		item := &comparison.Comparisons[i]
+		item.DayOfWeekName = l.Weekday(time.Weekday(item.Weekday))
+	}
+}
+
+// LocalizeAnalysis 按语言填充分析数据的日期和各币种金额展示字段
+func LocalizeAnalysis(analysis *models.AnalysisData, l locale.Locale) {
+	analysis.Locale = l.Code()
+	if day, err := time.Parse("2006-01-02", analysis.Date); err == nil {
+		analysis.DateDisplay = l.Date(day)
+	}
+	if analysis.TotalAmount != nil {
+		analysis.TotalAmountDisplay = l.Currency(*analysis.TotalAmount, analysis.Currency)
+	}
+	for i := range analysis.TotalsByCurrency {
+		t := &analysis.TotalsByCurrency[i]
+		t.TotalAmountDisplay = l.Currency(t.TotalAmount, t.Currency)
+	}
+}
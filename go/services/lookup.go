@@ -0,0 +1,45 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"timezone-saas-demo/geo"
+	"timezone-saas-demo/models"
+)
+
+// LookupTimezone 根据经纬度查询所在时区，附带 at 时刻的本地时间和偏移
+func (s *TimezoneService) LookupTimezone(lat, lon float64, at time.Time) (*models.TimezoneLookup, error) {
+	result, err := s.lookup.Lookup(lat, lon)
+	if errors.Is(err, geo.ErrInvalidCoordinates) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询坐标时区失败: %w", err)
+	}
+
+	loc, err := LoadLocation(result.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	local := at.In(loc)
+	_, offset := local.Zone()
+
+	lookup := &models.TimezoneLookup{
+		Lat:           lat,
+		Lon:           lon,
+		Timezone:      result.Timezone,
+		Source:        result.Source,
+		LocalTime:     local.Format("2006-01-02 15:04:05"),
+		Offset:        formatOffset(offset),
+		OffsetSeconds: offset,
+	}
+	if result.City != nil {
+		lookup.NearestCity = result.City.NameZH
+		lookup.Country = result.City.CountryZH
+		lookup.DistanceKm = math.Round(result.DistanceKm*10) / 10
+	}
+	return lookup, nil
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Mail 一封邮件，Body 为纯文本正文；HTML 不为空时以 multipart/alternative 同时发送两种正文
+type Mail struct {
+	To      []string
+	Subject string
+	Body    string
+	HTML    string
+}
+
+// Mailer 发送邮件
+type Mailer interface {
+	Send(ctx context.Context, mail Mail) error
+}
+
+// SMTPMailer 通过 SMTP 服务器发送邮件，配置了用户名时使用 PLAIN 认证
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer 创建 SMTP 邮件发送，addr 为 host:port
+func NewSMTPMailer(addr, from, username, password string) (*SMTPMailer, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("SMTP 地址 %q 格式错误: %w", addr, err)
+	}
+	if from == "" {
+		return nil, fmt.Errorf("未配置发件人地址")
+	}
+	m := &SMTPMailer{addr: addr, from: from}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m, nil
+}
+
+// Send 发送邮件；net/smtp 不支持 ctx，ctx 已取消时不再发送
+func (m *SMTPMailer) Send(ctx context.Context, mail Mail) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(mail.To) == 0 {
+		return fmt.Errorf("邮件没有收件人")
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", m.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(mail.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.BEncoding.Encode("utf-8", mail.Subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	if mail.HTML == "" {
+		msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+		msg.WriteString(crlf(mail.Body))
+	} else if err := writeAlternative(&msg, mail); err != nil {
+		return err
+	}
+
+	if err := smtp.SendMail(m.addr, m.auth, m.from, mail.To, msg.Bytes()); err != nil {
+		return fmt.Errorf("发送邮件失败: %w", err)
+	}
+	return nil
+}
+
+// writeAlternative 写入 multipart/alternative 正文，纯文本在前、HTML 在后，邮件客户端优先显示 HTML
+func writeAlternative(msg *bytes.Buffer, mail Mail) error {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	fmt.Fprintf(msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", w.Boundary())
+
+	parts := []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", mail.Body},
+		{"text/html; charset=utf-8", mail.HTML},
+	}
+	for _, p := range parts {
+		if p.content == "" {
+			continue
+		}
+		part, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"8bit"},
+		})
+		if err != nil {
+			return fmt.Errorf("生成邮件正文失败: %w", err)
+		}
+		part.Write([]byte(crlf(p.content)))
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("生成邮件正文失败: %w", err)
+	}
+	msg.Write(body.Bytes())
+	return nil
+}
+
+// crlf 将正文的换行转换为 SMTP 要求的 CRLF
+func crlf(text string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n")
+}
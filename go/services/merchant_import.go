@@ -0,0 +1,319 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"timezone-saas-demo/geo"
+	"timezone-saas-demo/models"
+)
+
+// MaxMerchantImportRows 一次批量导入的最大行数
+const MaxMerchantImportRows = 5000
+
+// maxReportedImportErrors 拒绝提交时错误信息中列出的行数
+const maxReportedImportErrors = 5
+
+// MerchantCSVHeader 商户目录 CSV 的列，导出文件可以直接再次导入（id、status、created_at 导入时忽略）
+var MerchantCSVHeader = []string{
+	"id", "code", "name", "country_code", "country", "city", "timezone",
+	"business_hours_start", "business_hours_end", "weekend_days", "status", "created_at",
+}
+
+// noWeekend CSV 中表示没有周末的取值，空单元格表示按国家取默认值
+const noWeekend = "none"
+
+// ExportMerchants 全部商户，没有关联参考数据的商户 country_code 根据国家名称推断
+func (s *OnboardingService) ExportMerchants(ctx context.Context) ([]models.MerchantRecord, error) {
+	records, err := s.onboarding.Catalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if records == nil {
+		records = []models.MerchantRecord{}
+	}
+	for i := range records {
+		if records[i].CountryCode == "" {
+			records[i].CountryCode = geo.CountryCode(records[i].Country)
+		}
+	}
+	return records, nil
+}
+
+// ImportMerchants 逐行校验并批量创建商户
+// 每行按入驻接口的规则校验，另外要求 country_code 为 ISO 3166-1 alpha-2 代码；
+// 名称和城市（不区分大小写）与已有商户或文件中更早的行相同的视为重复，不会创建。
+// dryRun 时只返回报告；否则有校验失败的行时整批拒绝，没有时在一个事务中创建全部非重复的商户
+func (s *OnboardingService) ImportMerchants(ctx context.Context, rows []models.MerchantImportRow, dryRun bool) (*models.MerchantImportReport, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: 没有要导入的商户", ErrInvalidArgument)
+	}
+	if len(rows) > MaxMerchantImportRows {
+		return nil, fmt.Errorf("%w: 一次最多导入 %d 个商户", ErrInvalidArgument, MaxMerchantImportRows)
+	}
+
+	catalog, err := s.onboarding.Catalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]int, len(catalog))
+	codes := make(map[string]bool, len(catalog)+len(rows))
+	for _, m := range catalog {
+		existing[merchantKey(m.Name, m.City)] = m.ID
+		codes[m.Code] = true
+	}
+
+	report := &models.MerchantImportReport{DryRun: dryRun, Total: len(rows), Rows: make([]models.MerchantImportResult, 0, len(rows))}
+	seen := map[string]bool{}
+	var pending []models.MerchantOnboarding
+	var pendingRows []int
+	now := time.Now()
+	for i, row := range rows {
+		result, onboarding := s.validateImportRow(row, codes, now)
+		result.Row = i + 1
+		if result.Status == models.MerchantImportValid {
+			key := merchantKey(onboarding.Merchant.Name, onboarding.Merchant.City)
+			if id, ok := existing[key]; ok {
+				result.Status, result.DuplicateOf = models.MerchantImportDuplicate, id
+			} else if seen[key] {
+				result.Status = models.MerchantImportDuplicate
+			}
+			seen[key] = true
+		}
+
+		switch result.Status {
+		case models.MerchantImportValid:
+			report.Valid++
+			codes[onboarding.MerchantCode] = true
+			pending = append(pending, *onboarding)
+			pendingRows = append(pendingRows, len(report.Rows))
+		case models.MerchantImportDuplicate:
+			report.Duplicates++
+		default:
+			report.Invalid++
+		}
+		report.Rows = append(report.Rows, result)
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	if report.Invalid > 0 {
+		return nil, fmt.Errorf("%w: %d 行校验失败，未导入任何商户: %s", ErrInvalidArgument, report.Invalid, summarizeImportErrors(report.Rows))
+	}
+	if len(pending) > 0 {
+		if err := s.onboarding.Import(ctx, pending); err != nil {
+			return nil, err
+		}
+	}
+	for i, idx := range pendingRows {
+		result := &report.Rows[idx]
+		result.Status = models.MerchantImportCreated
+		// 不在参考数据中的国家不关联 dim_country，报告中保留校验过的国家代码
+		code := result.Merchant.CountryCode
+		result.Merchant.Merchant = pending[i].Merchant
+		result.Merchant.CountryCode = code
+		report.Created++
+	}
+	return report, nil
+}
+
+// validateImportRow 校验一行并生成待创建的商户，codes 为已占用的商户编码
+func (s *OnboardingService) validateImportRow(row models.MerchantImportRow, codes map[string]bool, now time.Time) (models.MerchantImportResult, *models.MerchantOnboarding) {
+	result := models.MerchantImportResult{Status: models.MerchantImportInvalid}
+
+	countryCode := strings.ToUpper(strings.TrimSpace(row.CountryCode))
+	if !geo.IsCountryCode(countryCode) {
+		result.Errors = append(result.Errors, fmt.Sprintf("country_code %q 不是 ISO 3166-1 alpha-2 代码", row.CountryCode))
+	}
+	code := strings.TrimSpace(row.Code)
+	if code != "" && codes[code] {
+		result.Errors = append(result.Errors, fmt.Sprintf("商户编码 %s 已存在", code))
+	}
+	prepared, err := s.prepare(OnboardRequest{
+		Name:               row.Name,
+		Code:               code,
+		Country:            countryCode,
+		City:               row.City,
+		Timezone:           row.Timezone,
+		BusinessHoursStart: row.BusinessHoursStart,
+		BusinessHoursEnd:   row.BusinessHoursEnd,
+		WeekendDays:        row.WeekendDays,
+	})
+	if err != nil {
+		result.Errors = append(result.Errors, strings.TrimPrefix(err.Error(), ErrInvalidArgument.Error()+": "))
+	}
+	if len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	onboarding := prepared.MerchantOnboarding
+	if onboarding.Merchant.CountryCode == "" {
+		onboarding.Merchant.Country = countryDisplayName(countryCode, row.Country)
+	}
+	// 同一批次的编码按毫秒生成，可能相同，顺延到未占用的编码
+	for at := now; code == "" && codes[onboarding.MerchantCode]; {
+		at = at.Add(time.Millisecond)
+		onboarding.MerchantCode = generateMerchantCode(countryCode, onboarding.Merchant.City, at)
+	}
+
+	result.Status = models.MerchantImportValid
+	result.Merchant = &models.MerchantRecord{
+		Merchant: onboarding.Merchant,
+		Code:     onboarding.MerchantCode,
+		Status:   "active",
+	}
+	result.Merchant.CountryCode = countryCode
+	return result, &onboarding
+}
+
+// countryDisplayName 不在参考数据中的国家保存的名称：优先使用导入数据中的名称，没有时使用英文简称
+func countryDisplayName(code, given string) string {
+	if given = strings.TrimSpace(given); given != "" {
+		return given
+	}
+	return geo.CountryName(code)
+}
+
+// merchantKey 重复检测的键：名称和城市，不区分大小写和首尾空格
+func merchantKey(name, city string) string {
+	return strings.ToLower(strings.TrimSpace(name)) + "\x00" + strings.ToLower(strings.TrimSpace(city))
+}
+
+// summarizeImportErrors 列出前几行校验失败的原因
+func summarizeImportErrors(rows []models.MerchantImportResult) string {
+	var parts []string
+	for _, r := range rows {
+		if r.Status != models.MerchantImportInvalid {
+			continue
+		}
+		if len(parts) == maxReportedImportErrors {
+			parts = append(parts, "……")
+			break
+		}
+		parts = append(parts, fmt.Sprintf("第 %d 行 %s", r.Row, strings.Join(r.Errors, "，")))
+	}
+	return strings.Join(parts, "；")
+}
+
+// ReadMerchantCSV 解析商户 CSV，第一行为表头，列的顺序不限，未知列忽略
+// 必须包含 name、country_code 和 city 列；weekend_days 为逗号分隔的星期序号，空表示按国家默认，none 表示没有周末
+func ReadMerchantCSV(r io.Reader) ([]models.MerchantImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: CSV 为空", ErrInvalidArgument)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: CSV 格式错误: %v", ErrInvalidArgument, err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, required := range []string{"name", "country_code", "city"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: CSV 缺少 %s 列", ErrInvalidArgument, required)
+		}
+	}
+
+	var rows []models.MerchantImportRow
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: CSV 格式错误: %v", ErrInvalidArgument, err)
+		}
+		if len(rows) == MaxMerchantImportRows {
+			return nil, fmt.Errorf("%w: 一次最多导入 %d 个商户", ErrInvalidArgument, MaxMerchantImportRows)
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		weekend, err := parseWeekendCell(field("weekend_days"))
+		if err != nil {
+			return nil, fmt.Errorf("%w: 第 %d 行 weekend_days 格式错误: %v", ErrInvalidArgument, line, err)
+		}
+		rows = append(rows, models.MerchantImportRow{
+			Code:               field("code"),
+			Name:               field("name"),
+			CountryCode:        field("country_code"),
+			Country:            field("country"),
+			City:               field("city"),
+			Timezone:           field("timezone"),
+			BusinessHoursStart: field("business_hours_start"),
+			BusinessHoursEnd:   field("business_hours_end"),
+			WeekendDays:        weekend,
+		})
+	}
+	return rows, nil
+}
+
+// WriteMerchantCSV 按 MerchantCSVHeader 写出商户目录
+func WriteMerchantCSV(w io.Writer, records []models.MerchantRecord) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(MerchantCSVHeader); err != nil {
+		return fmt.Errorf("写入 CSV 失败: %w", err)
+	}
+	for _, m := range records {
+		err := writer.Write([]string{
+			strconv.Itoa(m.ID), m.Code, m.Name, m.CountryCode, m.Country, m.City, m.Timezone,
+			m.BusinessHoursStart, m.BusinessHoursEnd, formatWeekendCell(m.WeekendDays), m.Status,
+			m.CreatedAt.UTC().Format(time.RFC3339),
+		})
+		if err != nil {
+			return fmt.Errorf("写入 CSV 失败: %w", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("写入 CSV 失败: %w", err)
+	}
+	return nil
+}
+
+// parseWeekendCell 解析 CSV 中的周末，空单元格返回 nil
+func parseWeekendCell(cell string) ([]int, error) {
+	if cell == "" {
+		return nil, nil
+	}
+	if strings.EqualFold(cell, noWeekend) {
+		return []int{}, nil
+	}
+	var days []int
+	for _, part := range strings.Split(cell, ",") {
+		day, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("%q 不是星期序号", part)
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// formatWeekendCell 与 parseWeekendCell 相反：未配置（nil）时为空，没有周末时写出 none
+func formatWeekendCell(days []int) string {
+	if days == nil {
+		return ""
+	}
+	if len(days) == 0 {
+		return noWeekend
+	}
+	parts := make([]string, len(days))
+	for i, d := range days {
+		parts[i] = strconv.Itoa(d)
+	}
+	return strings.Join(parts, ",")
+}
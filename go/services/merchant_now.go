@@ -0,0 +1,110 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/tzdb"
+)
+
+const (
+	// merchantSnapshotTTL 商户快照的有效期，过期后下一次请求重新读取全部商户；营业时间、周末等配置修改时立即失效
+	merchantSnapshotTTL = time.Minute
+	// maxMerchantNowAge 商户当前状态的最长缓存时间：配置修改后客户端最迟在此之后看到新的营业状态
+	maxMerchantNowAge = time.Hour
+)
+
+// MerchantNow 商户在 at 时刻的本地时间、偏移、夏令时和营业状态；商户取自内存快照，
+// 快照有效期内不访问数据库，快照之后新增的商户按ID单独读取一次
+// ValidUntil 为营业状态、本地日期或偏移下一次变化的时刻（最长一小时），此前客户端可以用 UTC 时间加偏移自行推算本地时间
+func (s *TimezoneService) MerchantNow(merchantID int, at time.Time) (*models.MerchantNow, error) {
+	merchant, err := s.cachedMerchant(merchantID)
+	if err != nil {
+		return nil, err
+	}
+	at = at.UTC().Truncate(time.Second)
+	boundaries, err := ComputeBoundaries(merchant, at)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := LoadLocation(merchant.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	local := at.In(loc)
+	zone := tzdb.ZoneAt(local)
+	now := &models.MerchantNow{
+		MerchantID:    merchant.ID,
+		MerchantName:  merchant.Name,
+		Timezone:      merchant.Timezone,
+		NowUTC:        at,
+		Local:         local.Format("2006-01-02 15:04:05"),
+		LocalDate:     local.Format("2006-01-02"),
+		Weekday:       int(local.Weekday()),
+		Offset:        formatOffset(zone.OffsetSeconds),
+		OffsetSeconds: zone.OffsetSeconds,
+		Abbreviation:  zone.Abbreviation,
+		IsDST:         zone.IsDST,
+		BusinessHours: boundaries.BusinessHours,
+		IsOpen:        boundaries.IsOpen,
+		NextOpen:      boundaries.NextOpen,
+		NextClose:     boundaries.NextClose,
+		NextMidnight:  boundaries.NextMidnight,
+	}
+	if transitions, _ := tzdb.Transitions(loc, at, at.Add(boundaryLookahead), 1); len(transitions) > 0 {
+		t := transitions[0]
+		now.NextOffsetChange = &models.Boundary{
+			UTC:          t.At,
+			Local:        t.At.In(loc).Format("2006-01-02 15:04:05"),
+			Offset:       formatOffset(t.After.OffsetSeconds),
+			SecondsUntil: int64(t.At.Sub(at) / time.Second),
+		}
+	}
+
+	validUntil := at.Add(maxMerchantNowAge)
+	for _, b := range []*models.Boundary{now.NextOpen, now.NextClose, &now.NextMidnight, now.NextOffsetChange} {
+		if b != nil && b.UTC.Before(validUntil) {
+			validUntil = b.UTC
+		}
+	}
+	now.ValidUntil = validUntil
+	now.MaxAgeSeconds = int(validUntil.Sub(at) / time.Second)
+	return now, nil
+}
+
+// InvalidateMerchantSnapshot 清空商户快照，商户的营业时间、周末等配置修改后调用
+func (s *TimezoneService) InvalidateMerchantSnapshot() {
+	s.snapshotMu.Lock()
+	defer s.snapshotMu.Unlock()
+	s.snapshot = nil
+}
+
+// cachedMerchant 从内存快照取商户，快照过期时重新读取全部商户；数据库不可用时继续使用过期的快照
+func (s *TimezoneService) cachedMerchant(id int) (models.Merchant, error) {
+	s.snapshotMu.Lock()
+	defer s.snapshotMu.Unlock()
+	if s.snapshot == nil || time.Since(s.snapshotAt) > merchantSnapshotTTL {
+		merchants, err := s.merchants.List()
+		switch {
+		case err == nil:
+			s.snapshot = make(map[int]models.Merchant, len(merchants))
+			for _, m := range merchants {
+				s.snapshot[m.ID] = m
+			}
+			s.snapshotAt = time.Now()
+		case s.snapshot == nil:
+			return models.Merchant{}, fmt.Errorf("读取商户失败: %w", err)
+		}
+	}
+	if m, ok := s.snapshot[id]; ok {
+		return m, nil
+	}
+	merchant, err := s.merchants.Get(id)
+	if err != nil {
+		return models.Merchant{}, err
+	}
+	s.snapshot[id] = *merchant
+	return *merchant, nil
+}
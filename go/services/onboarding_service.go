@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/geo"
+	"timezone-saas-demo/locale"
+	"timezone-saas-demo/mailtemplate"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/repository"
+)
+
+// DefaultWebhookEvents 入驻时登记的 Webhook 事件，地址为空且未启用，由商户后续配置
+var DefaultWebhookEvents = []string{"daily_close", "late_order"}
+
+// merchantCodePattern 商户编码允许的字符，与 dim_merchant.merchant_code 的长度限制一致
+var merchantCodePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,50}$`)
+
+// OnboardRequest 商户入驻请求
+// 时区可直接指定，也可以由国家/城市、地址或设备坐标推断；营业时间缺省为 09:00-19:00，
+// 周末缺省按国家取默认值（如沙特阿拉伯为周五周六）
+type OnboardRequest struct {
+	Name               string `json:"name"`
+	Code               string `json:"code"`
+	Country            string `json:"country"`
+	City               string `json:"city"`
+	Address            string `json:"address"`
+	Timezone           string `json:"timezone"`
+	BusinessHoursStart string `json:"business_hours_start"`
+	BusinessHoursEnd   string `json:"business_hours_end"`
+	// WeekendDays 周末的星期序号（0=周日 … 6=周六），不传时按国家取默认值，空数组表示没有周末
+	WeekendDays []int `json:"weekend_days"`
+	// Lat/Lon 设备定位坐标，国家/城市/地址都无法推断时使用
+	Lat *float64 `json:"lat"`
+	Lon *float64 `json:"lon"`
+	// ContactEmail 入驻成功后接收欢迎邮件的邮箱，可以为空
+	ContactEmail string `json:"contact_email"`
+	// DryRun 只返回推断和校验结果，不创建商户，供入驻向导逐步确认
+	DryRun bool `json:"dry_run"`
+}
+
+// OnboardingService 商户入驻服务
+type OnboardingService struct {
+	onboarding repository.OnboardingRepository
+	lookup     geo.Provider
+	emails     *EmailService
+	now        func() time.Time
+}
+
+// NewOnboardingService 创建入驻服务，使用 PostgreSQL 仓储
+func NewOnboardingService(db *database.DB) *OnboardingService {
+	return &OnboardingService{
+		onboarding: repository.NewPostgresOnboardingRepository(db),
+		lookup:     geo.DefaultProvider,
+		now:        time.Now,
+	}
+}
+
+// NewOnboardingServiceWithRepositories 使用指定仓储创建入驻服务
+func NewOnboardingServiceWithRepositories(onboarding repository.OnboardingRepository) *OnboardingService {
+	return &OnboardingService{
+		onboarding: onboarding,
+		lookup:     geo.DefaultProvider,
+		now:        time.Now,
+	}
+}
+
+// SetEmailService 设置发送欢迎邮件的邮件服务
+func (s *OnboardingService) SetEmailService(emails *EmailService) {
+	s.emails = emails
+}
+
+// SuggestTimezones 根据国家/城市推断时区，都未命中时再尝试解析地址
+func SuggestTimezones(country, city, address string) []models.TimezoneSuggestion {
+	matches := geo.Suggest(country, city)
+	if len(matches) == 0 && address != "" {
+		matches = geo.SuggestFromAddress(address)
+	}
+
+	suggestions := make([]models.TimezoneSuggestion, 0, len(matches))
+	for _, m := range matches {
+		suggestions = append(suggestions, models.TimezoneSuggestion{
+			Timezone:   m.City.Timezone,
+			Country:    m.City.CountryZH,
+			City:       m.City.NameZH,
+			MatchedBy:  m.MatchedBy,
+			Confidence: m.Confidence,
+		})
+	}
+	return suggestions
+}
+
+// Onboard 校验入驻信息、推断时区，并在一个事务中创建商户及默认报表和 Webhook 配置
+func (s *OnboardingService) Onboard(req OnboardRequest) (*models.OnboardingResult, error) {
+	result, err := s.prepare(req)
+	if err != nil {
+		return nil, err
+	}
+	if req.DryRun {
+		return result, nil
+	}
+	if err := s.onboarding.Onboard(&result.MerchantOnboarding); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// prepare 校验入驻信息、推断时区并生成待创建的商户和默认配置，不写入数据库
+func (s *OnboardingService) prepare(req OnboardRequest) (*models.OnboardingResult, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len([]rune(name)) > 100 {
+		return nil, fmt.Errorf("%w: 商户名称不能为空且不超过 100 个字符", ErrInvalidArgument)
+	}
+
+	country, city := strings.TrimSpace(req.Country), strings.TrimSpace(req.City)
+	suggestions := SuggestTimezones(country, city, req.Address)
+	if len(suggestions) == 0 && req.Lat != nil && req.Lon != nil {
+		suggestion, err := s.suggestFromCoordinates(*req.Lat, *req.Lon)
+		if err != nil {
+			return nil, err
+		}
+		if suggestion != nil {
+			suggestions = append(suggestions, *suggestion)
+		}
+	}
+
+	result := &models.OnboardingResult{DryRun: req.DryRun, Suggestions: suggestions}
+	if value := strings.TrimSpace(req.ContactEmail); value != "" {
+		emails, err := normalizeEmails([]string{value}, 1)
+		if err != nil {
+			return nil, err
+		}
+		result.ContactEmail = emails[0]
+	}
+	timezone := strings.TrimSpace(req.Timezone)
+	switch {
+	case timezone != "":
+		if _, err := LoadLocation(timezone); err != nil {
+			return nil, err
+		}
+		result.TimezoneSource = "input"
+	case len(suggestions) > 0:
+		timezone = suggestions[0].Timezone
+		result.TimezoneSource = "inferred"
+	default:
+		return nil, fmt.Errorf("%w: 无法根据国家/城市/地址/坐标推断时区，请指定 timezone", ErrInvalidArgument)
+	}
+
+	// 只给了地址时，国家和城市取推断结果
+	if len(suggestions) > 0 && suggestions[0].Timezone == timezone {
+		if country == "" {
+			country = suggestions[0].Country
+		}
+		if city == "" {
+			city = suggestions[0].City
+		}
+	}
+	if country == "" || city == "" {
+		return nil, fmt.Errorf("%w: 缺少国家或城市", ErrInvalidArgument)
+	}
+
+	hours, err := ParseBusinessHours(req.BusinessHoursStart, req.BusinessHoursEnd)
+	if err != nil {
+		return nil, fmt.Errorf("营业时间校验失败: %w", err)
+	}
+	weekend := CountryWeekend(country)
+	if req.WeekendDays != nil {
+		if weekend, err = ParseWeekendDays(req.WeekendDays); err != nil {
+			return nil, fmt.Errorf("周末校验失败: %w", err)
+		}
+	}
+
+	code := strings.TrimSpace(req.Code)
+	if code == "" {
+		code = generateMerchantCode(country, city, s.now())
+	} else if !merchantCodePattern.MatchString(code) {
+		return nil, fmt.Errorf("%w: 商户编码只能包含字母、数字、下划线和连字符，且不超过 50 个字符", ErrInvalidArgument)
+	}
+
+	result.MerchantOnboarding = models.MerchantOnboarding{
+		Merchant: models.Merchant{
+			Name:               name,
+			Timezone:           timezone,
+			Country:            country,
+			City:               city,
+			BusinessHoursStart: formatClock(hours.Start),
+			BusinessHoursEnd:   formatClock(hours.End),
+			WeekendDays:        weekend.Days(),
+		},
+		MerchantCode: code,
+		ReportSettings: models.ReportSettings{
+			DailyReportEnabled:  DefaultReportSchedule.DailyEnabled,
+			DailyReportTime:     DefaultReportSchedule.DailyTime,
+			WeeklyReportEnabled: DefaultReportSchedule.WeeklyEnabled,
+			WeekStartDay:        DefaultReportSchedule.WeekStartDay,
+		},
+		Webhooks: make([]models.WebhookSetting, 0, len(DefaultWebhookEvents)),
+	}
+	applyReferenceLocation(&result.Merchant)
+	for _, event := range DefaultWebhookEvents {
+		result.Webhooks = append(result.Webhooks, models.WebhookSetting{EventType: event})
+	}
+	return result, nil
+}
+
+// SendWelcome 按 ctx 中租户的 merchant_welcome 模板向入驻请求的 contact_email 发送欢迎邮件，
+// 使用请求协商的语言 l 和新商户的时区，结果写入 result.WelcomeEmail；发送失败不影响入驻
+func (s *OnboardingService) SendWelcome(ctx context.Context, result *models.OnboardingResult, l locale.Locale) {
+	if result.DryRun || result.ContactEmail == "" {
+		return
+	}
+	result.WelcomeEmail = AlertDeliverySent
+	if !s.emails.Enabled() {
+		result.WelcomeEmail = AlertDeliverySkipped
+		return
+	}
+	loc, err := LoadLocation(result.Merchant.Timezone)
+	if err != nil {
+		result.WelcomeEmail = AlertDeliveryFailed
+		log.Printf("⚠️ 商户 %d 的欢迎邮件发送失败: %v", result.Merchant.ID, err)
+		return
+	}
+	data := mailtemplate.WelcomeData{
+		Merchant:       result.Merchant,
+		MerchantCode:   result.MerchantCode,
+		ReportSettings: result.ReportSettings,
+	}
+	if data.Merchant.CreatedAt.IsZero() {
+		data.Merchant.CreatedAt = s.now().UTC()
+	}
+	if result.ReportSettings.DailyReportEnabled {
+		if minutes, err := parseClock(result.ReportSettings.DailyReportTime); err == nil {
+			first := nextLocalClock(s.now().In(loc), minutes).UTC()
+			data.FirstReportAt = &first
+		}
+	}
+	opts := mailtemplate.Options{Locale: l, Location: loc}
+	if err := s.emails.Send(ctx, TenantFromContext(ctx), mailtemplate.MerchantWelcome, []string{result.ContactEmail}, opts, data); err != nil {
+		result.WelcomeEmail = AlertDeliveryFailed
+		log.Printf("⚠️ 商户 %d 的欢迎邮件发送失败: %v", result.Merchant.ID, err)
+	}
+}
+
+// nextLocalClock local 之后第一个本地时间为 minutes（当天分钟数）的时刻，夏令时跳过的时刻顺延
+func nextLocalClock(local time.Time, minutes int) time.Time {
+	next := time.Date(local.Year(), local.Month(), local.Day(), minutes/60, minutes%60, 0, 0, local.Location())
+	if !next.After(local) {
+		next = time.Date(local.Year(), local.Month(), local.Day()+1, minutes/60, minutes%60, 0, 0, local.Location())
+	}
+	return next
+}
+
+// suggestFromCoordinates 根据设备坐标推断时区，附近没有已知城市时不给出建议
+func (s *OnboardingService) suggestFromCoordinates(lat, lon float64) (*models.TimezoneSuggestion, error) {
+	result, err := s.lookup.Lookup(lat, lon)
+	if errors.Is(err, geo.ErrInvalidCoordinates) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询坐标时区失败: %w", err)
+	}
+	if result.City == nil {
+		return nil, nil
+	}
+	return &models.TimezoneSuggestion{
+		Timezone:   result.Timezone,
+		Country:    result.City.CountryZH,
+		City:       result.City.NameZH,
+		MatchedBy:  "coordinates",
+		Confidence: 0.7,
+	}, nil
+}
+
+// generateMerchantCode 生成商户编码，格式与示例数据一致：国家代码_城市_序号
+// 国家或城市不在数据集中时使用 XX / MERCHANT，序号取当前时间的 36 进制毫秒数
+func generateMerchantCode(country, city string, now time.Time) string {
+	countryCode, cityName := "XX", "MERCHANT"
+	if matches := geo.Suggest(country, city); len(matches) > 0 {
+		countryCode = matches[0].City.CountryCode
+		if matches[0].MatchedBy == "city" {
+			cityName = strings.ToUpper(strings.Map(func(r rune) rune {
+				if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+					return r
+				}
+				return '_'
+			}, matches[0].City.Name))
+		}
+	}
+	return fmt.Sprintf("%s_%s_%s", countryCode, cityName, strings.ToUpper(strconv.FormatInt(now.UnixMilli(), 36)))
+}
+
+// formatClock 将当天的分钟数格式化为 HH:MM
+func formatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
@@ -0,0 +1,316 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/money"
+	"timezone-saas-demo/repository"
+	"timezone-saas-demo/timerange"
+)
+
+// organizationTokenPrefix 组织令牌的前缀，便于在日志和配置中识别令牌类型
+const organizationTokenPrefix = "org_"
+
+// ErrUnauthorized 组织令牌无效、已吊销或不属于请求的组织
+var ErrUnauthorized = errors.New("未授权")
+
+// OrganizationRequest 创建组织的请求
+type OrganizationRequest struct {
+	Name string `json:"name"`
+	// HQTimezone 总部时区，按总部口径统计时用于划分日期和小时
+	HQTimezone  string `json:"hq_timezone"`
+	MerchantIDs []int  `json:"merchant_ids"`
+}
+
+// OrganizationService 组织及其门店、组织令牌和组织分析
+// 组织拥有多个不同时区的门店，分析时可以按门店口径（每个门店自身的本地日期）或总部口径（总部时区的日期）汇总
+type OrganizationService struct {
+	orgs      repository.OrganizationRepository
+	merchants repository.MerchantRepository
+	revenue   models.RevenueDefinition
+}
+
+// NewOrganizationService 创建组织服务，使用 PostgreSQL 仓储
+func NewOrganizationService(db *database.DB) *OrganizationService {
+	return NewOrganizationServiceWithRepositories(
+		repository.NewPostgresOrganizationRepository(db),
+		repository.NewPostgresMerchantRepository(db),
+	)
+}
+
+// NewOrganizationServiceWithRepositories 使用指定仓储创建组织服务
+func NewOrganizationServiceWithRepositories(orgs repository.OrganizationRepository, merchants repository.MerchantRepository) *OrganizationService {
+	return &OrganizationService{orgs: orgs, merchants: merchants, revenue: DefaultRevenueDefinition}
+}
+
+// SetRevenueDefinition 设置未指定订单状态时排除的状态，与分析接口的营收口径一致
+// 组织分析只统计订单金额，不扣除退款
+func (s *OrganizationService) SetRevenueDefinition(revenue models.RevenueDefinition) {
+	s.revenue = revenue
+}
+
+// Create 创建组织并划入门店，每个商户最多属于一个组织
+func (s *OrganizationService) Create(ctx context.Context, req OrganizationRequest) (*models.Organization, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len([]rune(name)) > 100 {
+		return nil, fmt.Errorf("%w: 组织名称不能为空且不超过 100 个字符", ErrInvalidArgument)
+	}
+	if _, err := LoadLocation(req.HQTimezone); err != nil {
+		return nil, fmt.Errorf("总部时区校验失败: %w", err)
+	}
+	merchantIDs, err := normalizeMerchantIDs(req.MerchantIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	org := &models.Organization{Name: name, HQTimezone: req.HQTimezone, MerchantIDs: merchantIDs}
+	if err := s.orgs.Create(ctx, org); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// List 全部组织
+func (s *OrganizationService) List(ctx context.Context) ([]models.Organization, error) {
+	orgs, err := s.orgs.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if orgs == nil {
+		orgs = []models.Organization{}
+	}
+	return orgs, nil
+}
+
+// Get 组织及其门店详情
+func (s *OrganizationService) Get(ctx context.Context, id int) (*models.Organization, error) {
+	org, err := s.orgs.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	org.Locations = make([]models.Merchant, 0, len(org.MerchantIDs))
+	for _, merchantID := range org.MerchantIDs {
+		merchant, err := s.merchants.Get(merchantID)
+		if err != nil {
+			return nil, fmt.Errorf("获取门店 %d 失败: %w", merchantID, err)
+		}
+		org.Locations = append(org.Locations, *merchant)
+	}
+	return org, nil
+}
+
+// SetMerchants 替换组织的门店，移出的商户成为独立商户
+func (s *OrganizationService) SetMerchants(ctx context.Context, id int, merchantIDs []int) (*models.Organization, error) {
+	merchantIDs, err := normalizeMerchantIDs(merchantIDs)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.orgs.SetMerchants(ctx, id, merchantIDs); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// IssueToken 为组织签发令牌，明文只在返回值中出现一次，库中只保存 SHA-256 摘要
+func (s *OrganizationService) IssueToken(ctx context.Context, organizationID int, name string) (*models.OrganizationToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > 100 {
+		return nil, fmt.Errorf("%w: 令牌名称不能为空且不超过 100 个字符", ErrInvalidArgument)
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("生成组织令牌失败: %w", err)
+	}
+
+	token := &models.OrganizationToken{
+		OrganizationID: organizationID,
+		Name:           name,
+		Token:          organizationTokenPrefix + hex.EncodeToString(secret),
+	}
+	if err := s.orgs.CreateToken(ctx, token, hashOrganizationToken(token.Token)); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// Tokens 组织的全部令牌，不含明文
+func (s *OrganizationService) Tokens(ctx context.Context, organizationID int) ([]models.OrganizationToken, error) {
+	if _, err := s.orgs.Get(ctx, organizationID); err != nil {
+		return nil, err
+	}
+	tokens, err := s.orgs.Tokens(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if tokens == nil {
+		tokens = []models.OrganizationToken{}
+	}
+	return tokens, nil
+}
+
+// RevokeToken 吊销组织令牌，立即失效
+func (s *OrganizationService) RevokeToken(ctx context.Context, organizationID, tokenID int) error {
+	return s.orgs.RevokeToken(ctx, organizationID, tokenID)
+}
+
+// Authenticate 返回令牌所属的组织，令牌无效或已吊销时返回 ErrUnauthorized
+func (s *OrganizationService) Authenticate(ctx context.Context, token string) (int, error) {
+	if !strings.HasPrefix(token, organizationTokenPrefix) {
+		return 0, fmt.Errorf("%w: 不是组织令牌", ErrUnauthorized)
+	}
+	organizationID, err := s.orgs.TokenOrganization(ctx, hashOrganizationToken(token))
+	if errors.Is(err, repository.ErrNotFound) {
+		return 0, fmt.Errorf("%w: 组织令牌无效或已吊销", ErrUnauthorized)
+	}
+	return organizationID, err
+}
+
+// hashOrganizationToken 令牌的 SHA-256 摘要（十六进制）
+func hashOrganizationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Analysis 汇总组织全部门店在 date 的订单
+// mode 为 local（默认）时每个门店统计自身本地日期为 date 的订单，小时为门店本地小时；
+// 为 hq 时所有门店统计总部时区 date 当天的订单，小时为总部本地小时。
+// statuses 为空时按营收口径排除的状态过滤
+func (s *OrganizationService) Analysis(ctx context.Context, organizationID int, date, mode string, statuses []string) (*models.OrganizationAnalysis, error) {
+	if _, err := parseLocalDate(date); err != nil {
+		return nil, err
+	}
+	if mode == "" {
+		mode = models.OrganizationModeLocal
+	}
+	if mode != models.OrganizationModeLocal && mode != models.OrganizationModeHQ {
+		return nil, fmt.Errorf("%w: 无效的统计口径 %q，可选 %s,%s", ErrInvalidArgument, mode, models.OrganizationModeLocal, models.OrganizationModeHQ)
+	}
+
+	org, err := s.Get(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	filter := models.OrganizationAnalysisFilter{
+		OrganizationID: organizationID,
+		LocalDate:      date,
+		Statuses:       countedStatuses(s.revenue, statuses),
+	}
+	analysis := &models.OrganizationAnalysis{
+		OrganizationID:   org.ID,
+		Name:             org.Name,
+		Date:             date,
+		Mode:             mode,
+		Statuses:         filter.Statuses,
+		TotalsByCurrency: []models.OrganizationCurrencyTotal{},
+		HourlyBreakdown:  []models.HourlyOrderBreakdown{},
+		Locations:        make([]models.OrganizationLocationStats, 0, len(org.Locations)),
+	}
+
+	var hqStart, hqEnd time.Time
+	if mode == models.OrganizationModeHQ {
+		if hqStart, hqEnd, err = localDayWindow(date, org.HQTimezone); err != nil {
+			return nil, err
+		}
+		filter.HQTimezone, filter.Start, filter.End = org.HQTimezone, hqStart, hqEnd
+		analysis.ReportTimezone = org.HQTimezone
+	}
+
+	locations := map[int]*models.OrganizationLocationStats{}
+	for _, m := range org.Locations {
+		start, end := hqStart, hqEnd
+		if mode == models.OrganizationModeLocal {
+			if start, end, err = localDayWindow(date, m.Timezone); err != nil {
+				return nil, err
+			}
+		}
+		analysis.Locations = append(analysis.Locations, models.OrganizationLocationStats{
+			MerchantID:       m.ID,
+			MerchantName:     m.Name,
+			Timezone:         m.Timezone,
+			Country:          m.Country,
+			City:             m.City,
+			WindowStartUTC:   start,
+			WindowEndUTC:     end,
+			TotalsByCurrency: []models.OrganizationCurrencyTotal{},
+		})
+	}
+	for i := range analysis.Locations {
+		locations[analysis.Locations[i].MerchantID] = &analysis.Locations[i]
+	}
+	if len(org.Locations) == 0 {
+		return analysis, nil
+	}
+
+	totals, err := s.orgs.OrderTotals(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("获取组织订单汇总失败: %w", err)
+	}
+	hourly := map[int]*models.HourlyOrderBreakdown{}
+	for _, t := range totals {
+		analysis.TotalOrders += t.OrderCount
+		analysis.TotalsByCurrency = addCurrencyTotal(analysis.TotalsByCurrency, t)
+		// 汇总后门店可能已被移出组织，只计入组织合计
+		if loc, ok := locations[t.MerchantID]; ok {
+			loc.OrderCount += t.OrderCount
+			loc.TotalsByCurrency = addCurrencyTotal(loc.TotalsByCurrency, t)
+		}
+		b, ok := hourly[t.Hour]
+		if !ok {
+			b = &models.HourlyOrderBreakdown{Hour: t.Hour}
+			hourly[t.Hour] = b
+		}
+		if b.OrderCount == 0 || b.Currency == t.Currency {
+			b.Currency = t.Currency
+		} else {
+			b.Currency = ""
+		}
+		b.OrderCount += t.OrderCount
+		b.TotalAmount = b.TotalAmount.Add(t.Amount)
+	}
+
+	for _, b := range hourly {
+		b.AvgAmount = money.Average(b.TotalAmount, b.OrderCount, b.Currency)
+		b.TotalAmount = money.Round(b.TotalAmount, b.Currency)
+		analysis.HourlyBreakdown = append(analysis.HourlyBreakdown, *b)
+	}
+	sort.Slice(analysis.HourlyBreakdown, func(i, j int) bool {
+		return analysis.HourlyBreakdown[i].Hour < analysis.HourlyBreakdown[j].Hour
+	})
+	return analysis, nil
+}
+
+// addCurrencyTotal 把一行汇总计入按币种代码排序的合计
+func addCurrencyTotal(totals []models.OrganizationCurrencyTotal, t models.OrganizationOrderTotal) []models.OrganizationCurrencyTotal {
+	i := sort.Search(len(totals), func(i int) bool { return totals[i].Currency >= t.Currency })
+	if i == len(totals) || totals[i].Currency != t.Currency {
+		totals = append(totals, models.OrganizationCurrencyTotal{})
+		copy(totals[i+1:], totals[i:])
+		totals[i] = models.OrganizationCurrencyTotal{Currency: t.Currency}
+	}
+	totals[i].OrderCount += t.OrderCount
+	totals[i].TotalAmount = money.Round(totals[i].TotalAmount.Add(t.Amount), t.Currency)
+	return totals
+}
+
+// localDayWindow 时区 timezone 中本地日期 date 的 UTC 区间 [start, end)，夏令时切换日不是 24 小时
+func localDayWindow(date, timezone string) (time.Time, time.Time, error) {
+	loc, err := LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	day, err := timerange.Day(date, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: 日期格式错误，应为 YYYY-MM-DD", ErrInvalidArgument)
+	}
+	return day.Start, day.End, nil
+}
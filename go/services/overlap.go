@@ -0,0 +1,228 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"timezone-saas-demo/models"
+)
+
+const (
+	// maxOverlapMerchants 单次重叠分析允许的最大商户数
+	maxOverlapMerchants = 20
+	// defaultOverlapStep 候选时段的默认起点间隔
+	defaultOverlapStep = 30 * time.Minute
+	// defaultOverlapLimit 默认返回的候选时段数量
+	defaultOverlapLimit = 10
+	// maxOverlapLimit 最多返回的候选时段数量
+	maxOverlapLimit = 50
+)
+
+// OverlapOptions 营业时间重叠分析参数
+type OverlapOptions struct {
+	// Date 本地日期 YYYY-MM-DD，按 Timezone 解释，为空时使用参考时区的今天
+	Date string
+	// Timezone 参考时区，为空时使用第一个商户的时区
+	Timezone string
+	// Window 会议时长
+	Window time.Duration
+	// Step 候选时段起点间隔，为 0 时使用 30 分钟
+	Step time.Duration
+	// Limit 返回的候选时段数量，为 0 时使用 10
+	Limit int
+}
+
+// overlapParticipant 参与分析的商户及其在分析范围内的营业区间
+type overlapParticipant struct {
+	merchant models.Merchant
+	loc      *time.Location
+	hours    BusinessHours
+	// open 覆盖分析范围前后各一天的营业区间，避免边界处的区间被截断影响居中评分
+	open []TimeRange
+}
+
+// FindOverlap 计算多个商户在指定本地日期内的营业时间重叠区间，并给出排序后的候选会议时段
+// 候选时段按可参加商户数、居中程度（越接近各商户营业时间中点越好）、开始时间排序
+func (s *TimezoneService) FindOverlap(merchantIDs []int, opts OverlapOptions) (*models.MeetingOverlap, error) {
+	if len(merchantIDs) == 0 {
+		return nil, fmt.Errorf("%w: 至少需要指定一个商户", ErrInvalidArgument)
+	}
+	if len(merchantIDs) > maxOverlapMerchants {
+		return nil, fmt.Errorf("%w: 最多支持 %d 个商户", ErrInvalidArgument, maxOverlapMerchants)
+	}
+	if opts.Window <= 0 || opts.Window > 24*time.Hour {
+		return nil, fmt.Errorf("%w: 会议时长必须在 0 到 24 小时之间", ErrInvalidArgument)
+	}
+	if opts.Step == 0 {
+		opts.Step = defaultOverlapStep
+	}
+	if opts.Step < time.Minute {
+		return nil, fmt.Errorf("%w: 候选时段间隔不能小于 1 分钟", ErrInvalidArgument)
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = defaultOverlapLimit
+	}
+	if opts.Limit > maxOverlapLimit {
+		opts.Limit = maxOverlapLimit
+	}
+
+	seen := map[int]bool{}
+	var merchants []models.Merchant
+	for _, id := range merchantIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		merchant, err := s.merchants.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		merchants = append(merchants, *merchant)
+	}
+
+	if opts.Timezone == "" {
+		opts.Timezone = merchants[0].Timezone
+	}
+	refLoc, err := LoadLocation(opts.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	// 夏令时切换日当天可能只有 23 或 25 小时，零点也可能不存在，按参考时区的日期边界计算
+	day, err := localDay(opts.Date, refLoc, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	opts.Date = day.From
+	rangeStart, rangeEnd := day.Start, day.End
+
+	participants := make([]overlapParticipant, 0, len(merchants))
+	for _, merchant := range merchants {
+		loc, err := LoadLocation(merchant.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		hours, err := MerchantBusinessHours(merchant)
+		if err != nil {
+			return nil, err
+		}
+		participants = append(participants, overlapParticipant{
+			merchant: merchant,
+			loc:      loc,
+			hours:    hours,
+			open:     hours.Intervals(rangeStart.AddDate(0, 0, -1), rangeEnd.AddDate(0, 0, 1), loc),
+		})
+	}
+
+	result := &models.MeetingOverlap{
+		Date:          opts.Date,
+		Timezone:      opts.Timezone,
+		WindowMinutes: int(opts.Window / time.Minute),
+		RangeStartUTC: rangeStart.UTC(),
+		RangeEndUTC:   rangeEnd.UTC(),
+		Overlaps:      []models.TimeInterval{},
+		Candidates:    []models.MeetingSlot{},
+	}
+
+	overlaps := participants[0].open
+	for _, p := range participants {
+		result.Participants = append(result.Participants, models.OverlapParticipant{
+			MerchantID:    p.merchant.ID,
+			MerchantName:  p.merchant.Name,
+			Timezone:      p.merchant.Timezone,
+			BusinessHours: p.hours.String(),
+		})
+		overlaps = intersectRanges(overlaps, p.open)
+	}
+	for _, r := range clipRanges(overlaps, rangeStart, rangeEnd) {
+		result.Overlaps = append(result.Overlaps, models.TimeInterval{
+			StartUTC:        r.Start.UTC(),
+			EndUTC:          r.End.UTC(),
+			DurationMinutes: int(r.Duration() / time.Minute),
+		})
+	}
+
+	result.Candidates = rankMeetingSlots(participants, rangeStart, rangeEnd, opts)
+	return result, nil
+}
+
+// rankMeetingSlots 按固定间隔枚举候选时段并排序
+// 只保留至少有一个商户可参加的时段；多商户时要求至少两个商户可参加
+func rankMeetingSlots(participants []overlapParticipant, rangeStart, rangeEnd time.Time, opts OverlapOptions) []models.MeetingSlot {
+	minAvailable := 1
+	if len(participants) > 1 {
+		minAvailable = 2
+	}
+
+	var slots []models.MeetingSlot
+	for start := rangeStart; !start.Add(opts.Window).After(rangeEnd); start = start.Add(opts.Step) {
+		slot := TimeRange{Start: start, End: start.Add(opts.Window)}
+		candidate := models.MeetingSlot{
+			StartUTC: slot.Start.UTC(),
+			EndUTC:   slot.End.UTC(),
+		}
+
+		var score float64
+		for _, p := range participants {
+			open, ok := coveringRange(p.open, slot)
+			if ok {
+				candidate.AvailableCount++
+				score += centrality(open, slot)
+			}
+			candidate.LocalTimes = append(candidate.LocalTimes, models.SlotLocalTime{
+				MerchantID: p.merchant.ID,
+				Timezone:   p.merchant.Timezone,
+				LocalStart: slot.Start.In(p.loc).Format("2006-01-02 15:04"),
+				LocalEnd:   slot.End.In(p.loc).Format("2006-01-02 15:04"),
+				Available:  ok,
+			})
+		}
+		if candidate.AvailableCount < minAvailable {
+			continue
+		}
+
+		candidate.AllAvailable = candidate.AvailableCount == len(participants)
+		candidate.Score = math.Round(score/float64(len(participants))*1000) / 1000
+		slots = append(slots, candidate)
+	}
+
+	sort.SliceStable(slots, func(i, j int) bool {
+		if slots[i].AvailableCount != slots[j].AvailableCount {
+			return slots[i].AvailableCount > slots[j].AvailableCount
+		}
+		if slots[i].Score != slots[j].Score {
+			return slots[i].Score > slots[j].Score
+		}
+		return slots[i].StartUTC.Before(slots[j].StartUTC)
+	})
+	if len(slots) > opts.Limit {
+		slots = slots[:opts.Limit]
+	}
+	for i := range slots {
+		slots[i].Rank = i + 1
+	}
+	return slots
+}
+
+// coveringRange 查找完整覆盖时段的营业区间
+func coveringRange(ranges []TimeRange, slot TimeRange) (TimeRange, bool) {
+	for _, r := range ranges {
+		if r.Covers(slot) {
+			return r, true
+		}
+	}
+	return TimeRange{}, false
+}
+
+// centrality 时段中点距离营业区间中点的接近程度，1 表示正好居中，0 表示贴着营业区间边缘
+func centrality(open, slot TimeRange) float64 {
+	slack := open.Duration() - slot.Duration()
+	if slack <= 0 {
+		return 1
+	}
+	openMid := open.Start.Add(open.Duration() / 2)
+	slotMid := slot.Start.Add(slot.Duration() / 2)
+	distance := math.Abs(float64(slotMid.Sub(openMid)))
+	return 1 - distance/float64(slack/2)
+}
@@ -0,0 +1,351 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/repository"
+)
+
+// 租户健康标记
+const (
+	TenantFlagNoOrders      = "no_orders"
+	TenantFlagStale         = "stale"
+	TenantFlagThrottled     = "throttled"
+	TenantFlagHighErrorRate = "high_error_rate"
+)
+
+// 运营看板的默认阈值
+const (
+	// DefaultOverviewWindow 统计近期订单的窗口
+	DefaultOverviewWindow = 24 * time.Hour
+	// DefaultStaleAfter 超过该时长没有新订单入库的租户标记为 stale
+	DefaultStaleAfter = 24 * time.Hour
+	// DefaultErrorRateThreshold 5xx 请求占比达到该值的租户标记为 high_error_rate
+	DefaultErrorRateThreshold = 0.05
+	// errorRateMinRequests 请求数达到该值才计算错误率标记，避免少量请求时误报
+	errorRateMinRequests = 20
+)
+
+// overviewSorts 看板支持的排序字段及其默认方向（true 为降序）
+var overviewSorts = map[string]bool{
+	"merchant_id":   false,
+	"name":          false,
+	"orders":        true,
+	"recent_orders": true,
+	"requests":      true,
+	"error_rate":    true,
+	"last_ingested": true,
+}
+
+// OverviewOptions 运营看板的查询选项，零值字段使用默认值
+type OverviewOptions struct {
+	Window     time.Duration
+	StaleAfter time.Duration
+	ErrorRate  float64
+	// Sort 排序字段，见 overviewSorts；Order 为 asc 或 desc，为空时按字段的默认方向
+	Sort  string
+	Order string
+}
+
+// TenantRequestCounter 按租户（X-Tenant-ID）统计 API 请求数和错误数
+type TenantRequestCounter struct {
+	mu      sync.Mutex
+	tenants map[string]*models.TenantRequestStats
+}
+
+// NewTenantRequestCounter 创建租户请求计数器
+func NewTenantRequestCounter() *TenantRequestCounter {
+	return &TenantRequestCounter{tenants: make(map[string]*models.TenantRequestStats)}
+}
+
+// Record 记录租户的一次请求及其响应状态码
+func (c *TenantRequestCounter) Record(tenant string, status int, at time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats, ok := c.tenants[tenant]
+	if !ok {
+		stats = &models.TenantRequestStats{Tenant: tenant}
+		c.tenants[tenant] = stats
+	}
+	stats.Requests++
+	switch {
+	case status >= 500:
+		stats.ServerErrors++
+	case status >= 400:
+		stats.ClientErrors++
+	}
+	at = at.UTC()
+	stats.LastRequestAt = &at
+}
+
+// Stats 各租户的请求统计，按租户排序
+func (c *TenantRequestCounter) Stats() []models.TenantRequestStats {
+	if c == nil {
+		return []models.TenantRequestStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := make([]models.TenantRequestStats, 0, len(c.tenants))
+	for _, s := range c.tenants {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Tenant < stats[j].Tenant })
+	return stats
+}
+
+// OverviewService 跨租户运营看板：订单量、最近入库时间、请求统计和健康标记
+// 请求统计只有本进程的数据，多实例部署时各实例分别统计
+type OverviewService struct {
+	activity   repository.OverviewRepository
+	requests   *TenantRequestCounter
+	queryStats func() []models.TenantQueryStats
+	now        func() time.Time
+}
+
+// NewOverviewService 创建运营看板服务，使用 PostgreSQL 仓储
+// queryStats 提供分析查询的限流统计，可以为 nil
+func NewOverviewService(db *database.DB, requests *TenantRequestCounter, queryStats func() []models.TenantQueryStats) *OverviewService {
+	return NewOverviewServiceWithRepositories(repository.NewPostgresOverviewRepository(db), requests, queryStats)
+}
+
+// NewOverviewServiceWithRepositories 使用指定仓储创建运营看板服务
+func NewOverviewServiceWithRepositories(activity repository.OverviewRepository, requests *TenantRequestCounter, queryStats func() []models.TenantQueryStats) *OverviewService {
+	return &OverviewService{activity: activity, requests: requests, queryStats: queryStats, now: time.Now}
+}
+
+// Overview 生成运营看板
+// 每个商户是一个租户，X-Tenant-ID 等于商户ID的请求计入该商户，其余租户的请求列在 OtherTenants
+func (s *OverviewService) Overview(ctx context.Context, opts OverviewOptions) (*models.AdminOverview, error) {
+	opts, desc, err := normalizeOverviewOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now().UTC()
+	activity, err := s.activity.TenantActivity(ctx, now.Add(-opts.Window))
+	if err != nil {
+		return nil, err
+	}
+
+	requests := make(map[string]models.TenantRequestStats)
+	for _, r := range s.requests.Stats() {
+		requests[r.Tenant] = r
+	}
+	rejected := make(map[string]int64)
+	if s.queryStats != nil {
+		for _, q := range s.queryStats() {
+			rejected[q.Tenant] = q.Rejected
+		}
+	}
+
+	overview := &models.AdminOverview{
+		GeneratedAt:          now,
+		Window:               opts.Window.String(),
+		StaleAfter:           opts.StaleAfter.String(),
+		ErrorRate:            opts.ErrorRate,
+		Sort:                 opts.Sort,
+		Order:                opts.Order,
+		TimezoneDistribution: []models.TimezoneShare{},
+		Tenants:              make([]models.TenantOverview, 0, len(activity)),
+		OtherTenants:         []models.TenantRequestStats{},
+	}
+	shares := make(map[string]*models.TimezoneShare)
+	for _, a := range activity {
+		tenant := strconv.Itoa(a.MerchantID)
+		req := requests[tenant]
+		delete(requests, tenant)
+
+		t := models.TenantOverview{
+			TenantActivity: a,
+			Requests:       req.Requests,
+			ClientErrors:   req.ClientErrors,
+			ServerErrors:   req.ServerErrors,
+			LastRequestAt:  req.LastRequestAt,
+			Rejected:       rejected[tenant],
+			Flags:          []string{},
+		}
+		if req.Requests > 0 {
+			t.ErrorRate = math.Round(float64(req.ServerErrors)/float64(req.Requests)*10000) / 10000
+		}
+		if a.LastIngestedAt != nil {
+			lag := int64(now.Sub(*a.LastIngestedAt) / time.Second)
+			t.IngestionLagSeconds = &lag
+		}
+		t.Flags = tenantFlags(t, now, opts)
+		t.Healthy = len(t.Flags) == 0
+		overview.Tenants = append(overview.Tenants, t)
+
+		overview.Totals.Tenants++
+		overview.Totals.Orders += a.OrderCount
+		overview.Totals.RecentOrders += a.RecentOrderCount
+		overview.Totals.Requests += req.Requests
+		if hasFlag(t.Flags, TenantFlagStale) {
+			overview.Totals.Stale++
+		}
+		if !t.Healthy {
+			overview.Totals.Unhealthy++
+		}
+
+		share, ok := shares[a.Timezone]
+		if !ok {
+			share = &models.TimezoneShare{Timezone: a.Timezone}
+			shares[a.Timezone] = share
+		}
+		share.Tenants++
+		share.Orders += a.OrderCount
+		share.RecentOrders += a.RecentOrderCount
+	}
+
+	for _, share := range shares {
+		overview.TimezoneDistribution = append(overview.TimezoneDistribution, *share)
+	}
+	sort.Slice(overview.TimezoneDistribution, func(i, j int) bool {
+		a, b := overview.TimezoneDistribution[i], overview.TimezoneDistribution[j]
+		if a.Tenants != b.Tenants {
+			return a.Tenants > b.Tenants
+		}
+		return a.Timezone < b.Timezone
+	})
+
+	for _, r := range requests {
+		overview.OtherTenants = append(overview.OtherTenants, r)
+		overview.Totals.Requests += r.Requests
+	}
+	sort.Slice(overview.OtherTenants, func(i, j int) bool {
+		return overview.OtherTenants[i].Tenant < overview.OtherTenants[j].Tenant
+	})
+
+	sortTenantOverview(overview.Tenants, opts.Sort, desc)
+	return overview, nil
+}
+
+// normalizeOverviewOptions 填充默认值并校验，返回是否降序
+func normalizeOverviewOptions(opts OverviewOptions) (OverviewOptions, bool, error) {
+	if opts.Window == 0 {
+		opts.Window = DefaultOverviewWindow
+	}
+	if opts.StaleAfter == 0 {
+		opts.StaleAfter = DefaultStaleAfter
+	}
+	if opts.ErrorRate == 0 {
+		opts.ErrorRate = DefaultErrorRateThreshold
+	}
+	if opts.Window < 0 || opts.StaleAfter < 0 {
+		return opts, false, fmt.Errorf("%w: 统计窗口和 stale 阈值必须大于 0", ErrInvalidArgument)
+	}
+	if opts.ErrorRate < 0 || opts.ErrorRate > 1 {
+		return opts, false, fmt.Errorf("%w: 错误率阈值应在 0~1 之间", ErrInvalidArgument)
+	}
+
+	if opts.Sort == "" {
+		opts.Sort = "merchant_id"
+	}
+	desc, ok := overviewSorts[opts.Sort]
+	if !ok {
+		return opts, false, fmt.Errorf("%w: 不支持的排序字段 %q", ErrInvalidArgument, opts.Sort)
+	}
+	switch opts.Order {
+	case "":
+		opts.Order = "asc"
+		if desc {
+			opts.Order = "desc"
+		}
+	case "asc":
+		desc = false
+	case "desc":
+		desc = true
+	default:
+		return opts, false, fmt.Errorf("%w: 排序方向应为 asc 或 desc", ErrInvalidArgument)
+	}
+	return opts, desc, nil
+}
+
+// tenantFlags 按阈值计算租户的健康标记
+func tenantFlags(t models.TenantOverview, now time.Time, opts OverviewOptions) []string {
+	flags := []string{}
+	if t.LastIngestedAt == nil {
+		flags = append(flags, TenantFlagNoOrders)
+	} else if now.Sub(*t.LastIngestedAt) > opts.StaleAfter {
+		flags = append(flags, TenantFlagStale)
+	}
+	if t.Rejected > 0 {
+		flags = append(flags, TenantFlagThrottled)
+	}
+	if t.Requests >= errorRateMinRequests && t.ErrorRate >= opts.ErrorRate {
+		flags = append(flags, TenantFlagHighErrorRate)
+	}
+	return flags
+}
+
+// sortTenantOverview 按字段排序，相同时按商户ID升序
+func sortTenantOverview(tenants []models.TenantOverview, field string, desc bool) {
+	compare := func(a, b models.TenantOverview) int {
+		switch field {
+		case "name":
+			return compareOrdered(a.MerchantName, b.MerchantName)
+		case "orders":
+			return compareOrdered(a.OrderCount, b.OrderCount)
+		case "recent_orders":
+			return compareOrdered(a.RecentOrderCount, b.RecentOrderCount)
+		case "requests":
+			return compareOrdered(a.Requests, b.Requests)
+		case "error_rate":
+			return compareOrdered(a.ErrorRate, b.ErrorRate)
+		case "last_ingested":
+			return compareOrdered(unixOrZero(a.LastIngestedAt), unixOrZero(b.LastIngestedAt))
+		}
+		return compareOrdered(a.MerchantID, b.MerchantID)
+	}
+	sort.SliceStable(tenants, func(i, j int) bool {
+		c := compare(tenants[i], tenants[j])
+		if c == 0 {
+			return tenants[i].MerchantID < tenants[j].MerchantID
+		}
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+// compareOrdered 比较两个可排序的值
+func compareOrdered[T int | int64 | float64 | string](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// unixOrZero 时间的纳秒时间戳，nil 时为 0（排在最早）
+func unixOrZero(t *time.Time) int64 {
+	if t == nil {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// hasFlag 标记列表中是否包含 flag
+func hasFlag(flags []string, flag string) bool {
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,369 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/timerange"
+)
+
+// 高峰小时分析的默认值和上限
+const (
+	// DefaultPeakHourWeeks 默认统计的周数
+	DefaultPeakHourWeeks = 4
+	// MaxPeakHourWeeks 最多统计的周数
+	MaxPeakHourWeeks = 26
+	// DefaultPeakConfidence 判定高峰小时的默认置信度
+	DefaultPeakConfidence = 0.95
+)
+
+// PeakHourOptions 高峰小时分析的参数
+type PeakHourOptions struct {
+	// Weeks 统计的周数，0 时为 DefaultPeakHourWeeks
+	Weeks int
+	// To 统计的最后一个商户本地日期（含），为空时为商户本地的昨天，今天未结束不参与统计
+	To string
+	// Confidence 判定高峰所需的置信度，0 时为 DefaultPeakConfidence
+	Confidence float64
+	// Statuses 参与统计的订单状态，为空时按营收口径
+	Statuses []string
+}
+
+// GetPeakHours 统计商户最近若干周每个本地小时的订单，找出显著高于当天平均水平的高峰小时并合并为建议排班时段
+// 每个小时做单侧配对 t 检验：每天该小时的订单数减去当天每小时平均数，检验差值均值是否大于 0，
+// 以天为样本可以消除订单总量逐日波动的影响；置信度不低于 opts.Confidence 的小时为高峰。
+// 各小时分别检验，未做多重比较校正；本地小时按商户时区，夏令时切换日缺少或重复的小时照常计入
+func (s *TimezoneService) GetPeakHours(ctx context.Context, merchantID int, opts PeakHourOptions) (*models.PeakHoursReport, error) {
+	if opts.Weeks == 0 {
+		opts.Weeks = DefaultPeakHourWeeks
+	}
+	if opts.Weeks < 1 || opts.Weeks > MaxPeakHourWeeks {
+		return nil, fmt.Errorf("%w: 统计周数应为 1~%d，得到 %d", ErrInvalidArgument, MaxPeakHourWeeks, opts.Weeks)
+	}
+	if opts.Confidence == 0 {
+		opts.Confidence = DefaultPeakConfidence
+	}
+	if opts.Confidence < 0.5 || opts.Confidence >= 1 {
+		return nil, fmt.Errorf("%w: 置信度应在 0.5 到 1 之间（不含 1），得到 %v", ErrInvalidArgument, opts.Confidence)
+	}
+
+	merchant, err := s.merchants.Get(merchantID)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := LoadLocation(merchant.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	hours, err := MerchantBusinessHours(*merchant)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	dates, filter, err := merchantWeeks(merchant.ID, loc, opts.To, opts.Weeks, now)
+	if err != nil {
+		return nil, err
+	}
+	filter.Statuses = countedStatuses(s.revenue, opts.Statuses)
+	days := dates.Days()
+	from, _ := timerange.ParseDate(dates.From)
+	release, err := s.limiter.Acquire(ctx, TenantFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	var rows []models.LocalDateHourOrders
+	err = s.withQueryTimeout(ctx, func(ctx context.Context) (err error) {
+		if rows, err = s.analytics.MerchantHourlyOrders(ctx, filter); err != nil {
+			return fmt.Errorf("获取商户小时订单数失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// counts[d][h] 第 d 天本地 h 点的订单数，没有订单的小时为 0
+	counts := make([][24]int, days)
+	total := 0
+	for _, row := range rows {
+		date, err := timerange.ParseDate(row.LocalDate)
+		if err != nil || row.LocalHour < 0 || row.LocalHour > 23 {
+			continue
+		}
+		d := int(date.Sub(from).Hours() / 24)
+		if d < 0 || d >= days {
+			continue
+		}
+		counts[d][row.LocalHour] += row.OrderCount
+		total += row.OrderCount
+	}
+
+	report := &models.PeakHoursReport{
+		MerchantID:       merchant.ID,
+		MerchantName:     merchant.Name,
+		Timezone:         merchant.Timezone,
+		BusinessHours:    hours.String(),
+		From:             dates.From,
+		To:               dates.To,
+		Weeks:            opts.Weeks,
+		Days:             days,
+		ConfidenceLevel:  opts.Confidence,
+		TotalOrders:      total,
+		AvgOrdersPerHour: roundTo(float64(total)/float64(24*days), 2),
+		Hours:            peakHourStats(counts, opts.Confidence),
+		PeakHours:        []int{},
+		Source:           s.analytics.Name(),
+		Statuses:         filter.Statuses,
+		GeneratedAt:      now.Truncate(time.Second),
+	}
+	for _, h := range report.Hours {
+		if h.Peak {
+			report.PeakHours = append(report.PeakHours, h.Hour)
+		}
+	}
+	report.StaffingWindows = staffingWindows(report, hours)
+	return report, nil
+}
+
+// merchantWeeks 商户本地日期 to（含）之前最近 weeks 周的日期范围和对应的按小时查询条件，
+// to 为空时为商户本地的昨天，今天未结束不参与统计；高峰小时和履约时长分析共用
+func merchantWeeks(merchantID int, loc *time.Location, to string, weeks int, now time.Time) (timerange.Range, models.MerchantHourFilter, error) {
+	dates, err := recentDates("", to, timerange.Today(loc, now).AddDate(0, 0, -1), 7*weeks, loc)
+	if err != nil {
+		return timerange.Range{}, models.MerchantHourFilter{}, err
+	}
+	end, _ := timerange.ParseDate(dates.To)
+	filter := models.MerchantHourFilter{
+		MerchantID: merchantID,
+		From:       dates.From,
+		To:         end.AddDate(0, 0, 1).Format(timerange.Layout),
+	}
+	return dates, filter, nil
+}
+
+// peakHourStats 每个本地小时的均值、标准差和配对 t 检验结果
+func peakHourStats(counts [][24]int, confidence float64) []models.PeakHourStat {
+	n := len(counts)
+	dayMeans := make([]float64, n)
+	total := 0
+	for d, day := range counts {
+		sum := 0
+		for _, c := range day {
+			sum += c
+		}
+		dayMeans[d] = float64(sum) / 24
+		total += sum
+	}
+	overall := float64(total) / float64(24*n)
+
+	stats := make([]models.PeakHourStat, 24)
+	for h := range stats {
+		values := make([]float64, n)
+		diffs := make([]float64, n)
+		sum := 0
+		for d, day := range counts {
+			values[d] = float64(day[h])
+			diffs[d] = values[d] - dayMeans[d]
+			sum += day[h]
+		}
+		mean, sd := meanStdDev(values)
+		diffMean, diffSD := meanStdDev(diffs)
+
+		stat := models.PeakHourStat{Hour: h, TotalOrders: sum, MeanOrders: roundTo(mean, 2), StdDev: roundTo(sd, 2)}
+		if overall > 0 {
+			stat.Lift = roundTo(mean/overall, 2)
+		}
+		switch {
+		case n < 2 || total == 0:
+			stat.Confidence = 0
+		case diffSD == 0:
+			// 每天的差值都相同，没有波动：差值为正即确定高于平均
+			if diffMean > 0 {
+				stat.Confidence = 1
+			} else if diffMean == 0 {
+				stat.Confidence = 0.5
+			}
+		default:
+			t := diffMean / (diffSD / math.Sqrt(float64(n)))
+			stat.TStatistic = roundTo(t, 3)
+			stat.Confidence = roundTo(studentTCDF(t, n-1), 4)
+		}
+		stat.Peak = diffMean > 0 && stat.Confidence >= confidence
+		stats[h] = stat
+	}
+	return stats
+}
+
+// staffingWindows 把相邻的高峰小时合并为排班时段，23 点和 0 点视为相邻，按每小时订单数确定优先级
+func staffingWindows(report *models.PeakHoursReport, hours BusinessHours) []models.StaffingWindow {
+	windows := []models.StaffingWindow{}
+	peak := map[int]bool{}
+	for _, h := range report.PeakHours {
+		peak[h] = true
+	}
+	if len(peak) == 0 {
+		return windows
+	}
+
+	// 从一个非高峰小时之后开始扫描，跨午夜的时段不会被拆开；全天都是高峰时从 0 点开始
+	start := 0
+	for h := 0; h < 24; h++ {
+		if !peak[h] {
+			start = (h + 1) % 24
+			break
+		}
+	}
+	var current []int
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		orders := 0
+		within := true
+		for _, h := range current {
+			orders += report.Hours[h].TotalOrders
+			within = within && openAllHour(hours, h)
+		}
+		first, last := current[0], current[len(current)-1]
+		w := models.StaffingWindow{
+			Start:               fmt.Sprintf("%02d:00", first),
+			End:                 fmt.Sprintf("%02d:00", (last+1)%24),
+			Hours:               len(current),
+			PeakHours:           current,
+			AvgOrdersPerDay:     roundTo(float64(orders)/float64(report.Days), 2),
+			WithinBusinessHours: within,
+		}
+		if report.TotalOrders > 0 {
+			w.OrderSharePercent = roundTo(float64(orders)*100/float64(report.TotalOrders), 1)
+		}
+		if report.AvgOrdersPerHour > 0 {
+			w.Lift = roundTo(float64(orders)/float64(report.Days*len(current))/(float64(report.TotalOrders)/float64(24*report.Days)), 2)
+		}
+		windows = append(windows, w)
+		current = nil
+	}
+	for i := 0; i < 24; i++ {
+		h := (start + i) % 24
+		if peak[h] {
+			current = append(current, h)
+		} else {
+			flush()
+		}
+	}
+	flush()
+
+	order := make([]int, len(windows))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return windows[order[i]].Lift > windows[order[j]].Lift })
+	for rank, i := range order {
+		windows[i].Priority = rank + 1
+	}
+	return windows
+}
+
+// openAllHour 营业时间是否覆盖本地 hour 点的整个小时（不考虑周末）
+func openAllHour(b BusinessHours, hour int) bool {
+	start, end := hour*60, hour*60+60
+	if b.Overnight() {
+		return start >= b.Start || end <= b.End
+	}
+	return start >= b.Start && end <= b.End
+}
+
+// meanStdDev 均值和样本标准差，少于两个值时标准差为 0
+func meanStdDev(values []float64) (mean, sd float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	var ss float64
+	for _, v := range values {
+		ss += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(ss / float64(len(values)-1))
+}
+
+// roundTo 保留 digits 位小数
+func roundTo(v float64, digits int) float64 {
+	p := math.Pow(10, float64(digits))
+	return math.Round(v*p) / p
+}
+
+// studentTCDF 自由度为 df 的 t 分布在 t 处的累积概率
+func studentTCDF(t float64, df int) float64 {
+	x := float64(df) / (float64(df) + t*t)
+	tail := 0.5 * regularizedIncompleteBeta(x, float64(df)/2, 0.5)
+	if t > 0 {
+		return 1 - tail
+	}
+	return tail
+}
+
+// regularizedIncompleteBeta 正则化不完全 Beta 函数 I_x(a, b)，按连分式展开计算
+func regularizedIncompleteBeta(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lgab, _ := math.Lgamma(a + b)
+	lga, _ := math.Lgamma(a)
+	lgb, _ := math.Lgamma(b)
+	front := math.Exp(lgab - lga - lgb + a*math.Log(x) + b*math.Log(1-x))
+	// 连分式在 x < (a+1)/(a+b+2) 时收敛较快，否则利用 I_x(a,b) = 1 - I_{1-x}(b,a)
+	if x < (a+1)/(a+b+2) {
+		return front * betaContinuedFraction(x, a, b) / a
+	}
+	return 1 - front*betaContinuedFraction(1-x, b, a)/b
+}
+
+// betaContinuedFraction 不完全 Beta 函数的连分式部分（修正 Lentz 算法）
+func betaContinuedFraction(x, a, b float64) float64 {
+	const (
+		maxIterations = 300
+		epsilon       = 1e-12
+		tiny          = 1e-300
+	)
+	clamp := func(v float64) float64 {
+		if math.Abs(v) < tiny {
+			return tiny
+		}
+		return v
+	}
+
+	c, d := 1.0, 1/clamp(1-(a+b)*x/(a+1))
+	h := d
+	for m := 1; m <= maxIterations; m++ {
+		fm, m2 := float64(m), float64(2*m)
+		// 偶数项
+		aa := fm * (b - fm) * x / ((a + m2 - 1) * (a + m2))
+		d = 1 / clamp(1+aa*d)
+		c = clamp(1 + aa/c)
+		h *= d * c
+		// 奇数项
+		aa = -(a + fm) * (a + b + fm) * x / ((a + m2) * (a + m2 + 1))
+		d = 1 / clamp(1+aa*d)
+		c = clamp(1 + aa/c)
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return h
+}
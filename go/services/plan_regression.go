@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/repository"
+)
+
+// 执行计划回归检测的默认值
+const (
+	// DefaultPlanRegressionInterval 检查统计周期并保存耗时统计的周期
+	DefaultPlanRegressionInterval = 5 * time.Minute
+	// DefaultPlanRegressionThreshold 耗时中位数变慢超过该比例记为回归
+	DefaultPlanRegressionThreshold = 0.5
+	// planRegressionMinSamples 两个周期的样本数都不少于该值才比较
+	planRegressionMinSamples = 30
+	// planRegressionMinDeltaMs 中位数至少变慢这么多毫秒才记为回归，避免亚毫秒语句的抖动
+	planRegressionMinDeltaMs = 1.0
+)
+
+// planCost EXPLAIN 输出中的代价估计，比较执行计划结构时去掉
+var planCost = regexp.MustCompile(`\s*\(cost=[^)]*\)`)
+
+// PlanRegressionService 执行计划回归检测：定期把本实例按语句指纹统计的耗时中位数和执行计划保存到数据库，
+// 部署版本或表统计信息（ANALYZE）变化时开始新的统计周期，比较每个指纹最近两个周期的中位数
+type PlanRegressionService struct {
+	plans      repository.PlanRegressionRepository
+	recorder   *database.PlanRecorder
+	version    string
+	threshold  float64
+	minSamples int64
+	now        func() time.Time
+
+	mu    sync.Mutex
+	epoch *models.PlanEpoch
+}
+
+// NewPlanRegressionService 创建执行计划回归检测，使用 PostgreSQL 仓储；recorder 为 db 上启用的语句指纹统计
+// version 为部署版本，为空时每次启动视为一次部署；threshold 不大于 0 时使用 DefaultPlanRegressionThreshold
+func NewPlanRegressionService(db *database.DB, recorder *database.PlanRecorder, version string, threshold float64) *PlanRegressionService {
+	return NewPlanRegressionServiceWithRepositories(repository.NewPostgresPlanRegressionRepository(db), recorder, version, threshold)
+}
+
+// NewPlanRegressionServiceWithRepositories 使用指定仓储创建执行计划回归检测
+func NewPlanRegressionServiceWithRepositories(plans repository.PlanRegressionRepository, recorder *database.PlanRecorder, version string, threshold float64) *PlanRegressionService {
+	if threshold <= 0 {
+		threshold = DefaultPlanRegressionThreshold
+	}
+	if version == "" {
+		version = "start-" + processStartedAt.UTC().Format("20060102T150405Z")
+	}
+	return &PlanRegressionService{
+		plans:      plans,
+		recorder:   recorder,
+		version:    version,
+		threshold:  threshold,
+		minSamples: planRegressionMinSamples,
+		now:        time.Now,
+	}
+}
+
+// processStartedAt 未指定部署版本时以进程启动时间区分部署
+var processStartedAt = time.Now()
+
+// Run 立即开始第一个统计周期，之后每隔 interval 检查统计周期并保存耗时统计，直到 ctx 取消
+func (s *PlanRegressionService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.Flush(ctx); err != nil {
+			log.Printf("保存语句耗时统计失败: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush 检查统计周期：周期未变时保存本周期的统计；周期变化时把已有统计保存到上一个周期，清空统计并开始新的周期
+// 第一次调用时开始第一个周期，此前统计的耗时计入该周期
+func (s *PlanRegressionService) Flush(ctx context.Context) error {
+	analyzedAt, err := s.plans.StatsAnalyzedAt(ctx)
+	if err != nil {
+		return err
+	}
+	epoch := s.planEpoch(analyzedAt)
+
+	s.mu.Lock()
+	current := s.epoch
+	if current == nil {
+		s.epoch = &epoch
+		current = &epoch
+	}
+	s.mu.Unlock()
+
+	if err := s.plans.Save(ctx, s.snapshot(*current)); err != nil {
+		return err
+	}
+	if current.Epoch == epoch.Epoch {
+		return nil
+	}
+
+	s.recorder.Reset()
+	s.mu.Lock()
+	s.epoch = &epoch
+	s.mu.Unlock()
+	log.Printf("📐 语句耗时统计进入新的周期 %s（上一个周期 %s）", epoch.Epoch, current.Epoch)
+	return nil
+}
+
+// Report 最近一个周期比上一个周期变慢超过阈值的语句，按变慢比例倒序
+func (s *PlanRegressionService) Report(ctx context.Context) (*models.PlanRegressionReport, error) {
+	stats, err := s.plans.Latest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := &models.PlanRegressionReport{
+		Threshold:   s.threshold,
+		MinSamples:  s.minSamples,
+		Regressions: []models.PlanRegression{},
+	}
+	s.mu.Lock()
+	report.Epoch = s.epoch
+	s.mu.Unlock()
+
+	// Latest 按指纹排序，每个指纹最近的周期在前
+	for i := 0; i+1 < len(stats); i++ {
+		current, baseline := stats[i], stats[i+1]
+		if current.Fingerprint != baseline.Fingerprint {
+			continue
+		}
+		i++
+		if regression, ok := s.compare(baseline, current); ok {
+			report.Regressions = append(report.Regressions, regression)
+		}
+	}
+	sortPlanRegressions(report.Regressions)
+	return report, nil
+}
+
+// compare 比较同一指纹两个周期的中位数
+func (s *PlanRegressionService) compare(baseline, current models.QueryPlanStats) (models.PlanRegression, bool) {
+	if baseline.Samples < s.minSamples || current.Samples < s.minSamples || baseline.MedianMs <= 0 {
+		return models.PlanRegression{}, false
+	}
+	if current.MedianMs-baseline.MedianMs < planRegressionMinDeltaMs || current.MedianMs <= baseline.MedianMs*(1+s.threshold) {
+		return models.PlanRegression{}, false
+	}
+	return models.PlanRegression{
+		Fingerprint: current.Fingerprint,
+		Query:       current.Query,
+		Ratio:       math.Round(current.MedianMs/baseline.MedianMs*100) / 100,
+		PlanChanged: planShape(baseline.Plan) != planShape(current.Plan),
+		Baseline:    baseline,
+		Current:     current,
+	}, true
+}
+
+// planEpoch 由部署版本和表统计信息的更新时间得到统计周期
+func (s *PlanRegressionService) planEpoch(analyzedAt *time.Time) models.PlanEpoch {
+	analyzed := "never"
+	if analyzedAt != nil {
+		analyzed = analyzedAt.Format(time.RFC3339)
+	}
+	return models.PlanEpoch{
+		Epoch:           fmt.Sprintf("%s@%s", s.version, analyzed),
+		DeployVersion:   s.version,
+		StatsAnalyzedAt: analyzedAt,
+		StartedAt:       s.now().UTC(),
+	}
+}
+
+// snapshot 本实例当前的统计，归入 epoch
+func (s *PlanRegressionService) snapshot(epoch models.PlanEpoch) []models.QueryPlanStats {
+	fingerprints := s.recorder.Snapshot()
+	stats := make([]models.QueryPlanStats, 0, len(fingerprints))
+	for _, f := range fingerprints {
+		stats = append(stats, models.QueryPlanStats{
+			PlanEpoch:   epoch,
+			Fingerprint: f.Fingerprint,
+			Query:       f.Query,
+			Samples:     f.Samples,
+			MedianMs:    f.MedianMs,
+			Plan:        f.Plan,
+		})
+	}
+	return stats
+}
+
+// planShape 去掉代价估计后的执行计划，统计信息变化只改变估计值时结构相同
+func planShape(plan string) string {
+	return strings.TrimSpace(planCost.ReplaceAllString(plan, ""))
+}
+
+// sortPlanRegressions 按变慢比例倒序，比例相同时按指纹排序
+func sortPlanRegressions(regressions []models.PlanRegression) {
+	sort.Slice(regressions, func(i, j int) bool {
+		if regressions[i].Ratio != regressions[j].Ratio {
+			return regressions[i].Ratio > regressions[j].Ratio
+		}
+		return regressions[i].Fingerprint < regressions[j].Fingerprint
+	})
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/redact"
+	"timezone-saas-demo/repository"
+)
+
+// SQL 控制台的默认限制
+const (
+	// DefaultConsoleRole 执行查询时切换到的数据库角色，由 18_admin_query.sql 创建
+	DefaultConsoleRole = "saasview_console"
+	// DefaultConsoleTimeout 单条查询的语句超时
+	DefaultConsoleTimeout = 5 * time.Second
+	// DefaultConsoleMaxRows 单条查询最多返回的行数
+	DefaultConsoleMaxRows = 1000
+
+	// maxConsoleQueryLength 语句的最大长度（字节）
+	maxConsoleQueryLength = 10000
+)
+
+// 审计记录的状态，与 admin_query_audit 表的约束一致
+const (
+	ConsoleStatusRunning  = "running"
+	ConsoleStatusOK       = "ok"
+	ConsoleStatusRejected = "rejected"
+	ConsoleStatusFailed   = "failed"
+)
+
+// consoleDeniedFunctions 角色权限之外再拒绝的函数：
+// set_config 可以在事务内把 role 改回登录用户，其余函数读写服务器文件、影响其他会话或连接外部数据库
+var consoleDeniedFunctions = regexp.MustCompile(`(?i)\b(set_config|pg_terminate_backend|pg_cancel_backend|pg_reload_conf|pg_advisory\w*|dblink\w*|lo_\w+|pg_read_\w+|pg_ls_\w+|pg_stat_file)\b`)
+
+// consoleFirstKeyword 语句的第一个关键字
+var consoleFirstKeyword = regexp.MustCompile(`^\(*\s*([A-Za-z]+)`)
+
+// QueryConsoleService 管理员的只读 SQL 控制台
+// 语句先经过检查（单条 SELECT / WITH、不含危险函数），再在只读事务中以受限角色执行，
+// 受语句超时和行数上限约束；每次执行（包括被拒绝的）都写入审计记录
+type QueryConsoleService struct {
+	console repository.QueryConsoleRepository
+	role    string
+	timeout time.Duration
+	maxRows int
+	now     func() time.Time
+}
+
+// NewQueryConsoleService 创建 SQL 控制台服务，使用 PostgreSQL 仓储
+// role 为空、timeout 或 maxRows 不大于 0 时使用默认值
+func NewQueryConsoleService(db *database.DB, role string, timeout time.Duration, maxRows int) *QueryConsoleService {
+	return NewQueryConsoleServiceWithRepositories(repository.NewPostgresQueryConsoleRepository(db), role, timeout, maxRows)
+}
+
+// NewQueryConsoleServiceWithRepositories 使用指定仓储创建 SQL 控制台服务
+func NewQueryConsoleServiceWithRepositories(console repository.QueryConsoleRepository, role string, timeout time.Duration, maxRows int) *QueryConsoleService {
+	if role == "" {
+		role = DefaultConsoleRole
+	}
+	if timeout <= 0 {
+		timeout = DefaultConsoleTimeout
+	}
+	if maxRows <= 0 {
+		maxRows = DefaultConsoleMaxRows
+	}
+	return &QueryConsoleService{
+		console: console,
+		role:    role,
+		timeout: timeout,
+		maxRows: maxRows,
+		now:     time.Now,
+	}
+}
+
+// Run 检查并执行一条查询
+// 未填写操作人时直接拒绝，不写审计；其余情况先写入审计记录再检查语句，进程中途退出时记录保持 running
+func (s *QueryConsoleService) Run(ctx context.Context, q models.ConsoleQuery) (*models.ConsoleResult, error) {
+	operator := redact.Inline(strings.TrimSpace(q.Operator))
+	if operator == "" {
+		return nil, fmt.Errorf("%w: 必须填写操作人 operator", ErrInvalidArgument)
+	}
+	maxRows := s.maxRows
+	if q.MaxRows > 0 && q.MaxRows < maxRows {
+		maxRows = q.MaxRows
+	}
+
+	// 审计记录和日志可能交给外部平台保存，SQL 中的邮箱、订单号等字面量按脱敏策略处理
+	audit := &models.ConsoleAudit{
+		Operator:   operator,
+		SQL:        redact.Current().Text(q.SQL),
+		Status:     ConsoleStatusRunning,
+		ClientAddr: q.ClientAddr,
+	}
+	if err := s.console.CreateAudit(ctx, audit); err != nil {
+		return nil, err
+	}
+	log.Printf("🔎 SQL 控制台 #%d（%s，%s）: %s", audit.ID, operator, q.ClientAddr, strings.Join(strings.Fields(audit.SQL), " "))
+
+	query, err := checkConsoleQuery(q.SQL)
+	if err != nil {
+		audit.Status = ConsoleStatusRejected
+		audit.Error = err.Error()
+		s.finishAudit(ctx, audit)
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+
+	start := s.now()
+	result, err := s.console.Execute(ctx, s.role, query, s.timeout, maxRows)
+	audit.DurationMs = s.now().Sub(start).Milliseconds()
+	if err != nil {
+		audit.Status = ConsoleStatusFailed
+		audit.Error = err.Error()
+		s.finishAudit(ctx, audit)
+		if errors.Is(err, repository.ErrInvalidQuery) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+		}
+		return nil, err
+	}
+
+	audit.Status = ConsoleStatusOK
+	audit.RowCount = result.RowCount
+	audit.Truncated = result.Truncated
+	s.finishAudit(ctx, audit)
+
+	result.AuditID = audit.ID
+	result.DurationMs = audit.DurationMs
+	return result, nil
+}
+
+// finishAudit 更新审计记录；客户端断开时也要写完，失败只记录日志，不影响查询结果
+func (s *QueryConsoleService) finishAudit(ctx context.Context, audit *models.ConsoleAudit) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := s.console.FinishAudit(ctx, audit); err != nil {
+		log.Printf("⚠️ SQL 控制台 #%d 的审计记录更新失败: %v", audit.ID, err)
+	}
+}
+
+// Audits 最近 limit 条审计记录
+func (s *QueryConsoleService) Audits(ctx context.Context, limit int) ([]models.ConsoleAudit, error) {
+	audits, err := s.console.Audits(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	if audits == nil {
+		audits = []models.ConsoleAudit{}
+	}
+	return audits, nil
+}
+
+// checkConsoleQuery 检查语句并返回去掉注释和末尾分号后的文本
+// 数据库侧还有只读事务和角色权限两道限制，这里拒绝的是它们挡不住或报错不直观的情况
+func checkConsoleQuery(sql string) (string, error) {
+	if len(sql) > maxConsoleQueryLength {
+		return "", fmt.Errorf("语句超过 %d 字节", maxConsoleQueryLength)
+	}
+	query, err := stripSQLComments(sql)
+	if err != nil {
+		return "", err
+	}
+	query = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(query), ";"))
+	if query == "" {
+		return "", errors.New("语句为空")
+	}
+
+	m := consoleFirstKeyword.FindStringSubmatch(query)
+	if m == nil || !(strings.EqualFold(m[1], "SELECT") || strings.EqualFold(m[1], "WITH")) {
+		return "", errors.New("只允许 SELECT 或 WITH 开头的查询")
+	}
+	if strings.Contains(outsideQuotes(query), ";") {
+		return "", errors.New("一次只能执行一条语句")
+	}
+	if f := consoleDeniedFunctions.FindString(query); f != "" {
+		return "", fmt.Errorf("不允许调用 %s", f)
+	}
+	// U&"..." 可以用转义序列拼出被拒绝的函数名
+	if strings.Contains(strings.ToLower(query), "u&") {
+		return "", errors.New("不允许使用 U& 转义")
+	}
+	return query, nil
+}
+
+// stripSQLComments 把引号之外的 -- 和 /* */ 注释替换为空格，块注释可以嵌套（与 PostgreSQL 一致）
+// 不支持美元引号：控制台的查询不需要函数体，$ 开头的引号会被当作普通字符，直接拒绝更安全
+func stripSQLComments(sql string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := i + 1
+			for end < len(sql) {
+				if sql[end] == c {
+					// 连续两个引号是转义，字符串继续
+					if end+1 < len(sql) && sql[end+1] == c {
+						end += 2
+						continue
+					}
+					break
+				}
+				end++
+			}
+			if end >= len(sql) {
+				return "", errors.New("引号未闭合")
+			}
+			b.WriteString(sql[i : end+1])
+			i = end + 1
+		case c == '$' && i+1 < len(sql) && (sql[i+1] == '$' || isIdentStart(sql[i+1])) && dollarQuoted(sql[i:]):
+			return "", errors.New("不支持美元引号")
+		case strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end
+			}
+			b.WriteByte(' ')
+		case strings.HasPrefix(sql[i:], "/*"):
+			depth := 0
+			for i < len(sql) {
+				if strings.HasPrefix(sql[i:], "/*") {
+					depth++
+					i += 2
+				} else if strings.HasPrefix(sql[i:], "*/") {
+					depth--
+					i += 2
+					if depth == 0 {
+						break
+					}
+				} else {
+					i++
+				}
+			}
+			if depth != 0 {
+				return "", errors.New("注释未闭合")
+			}
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String(), nil
+}
+
+// dollarQuoted s 是否以 $tag$ 开头
+func dollarQuoted(s string) bool {
+	for i := 1; i < len(s); i++ {
+		if s[i] == '$' {
+			return true
+		}
+		if !isIdentStart(s[i]) && !(s[i] >= '0' && s[i] <= '9') {
+			return false
+		}
+	}
+	return false
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// outsideQuotes 去掉引号中的内容，只保留引号之外的文本
+func outsideQuotes(sql string) string {
+	var b strings.Builder
+	var quote byte
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case quote == 0 && (c == '\'' || c == '"'):
+			quote = c
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
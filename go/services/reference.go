@@ -0,0 +1,103 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"timezone-saas-demo/geo"
+	"timezone-saas-demo/models"
+)
+
+// ReferenceCountries 国家参考数据，q 不为空时按代码、英文名或中文名的子串过滤（不区分大小写）
+func ReferenceCountries(q string) []models.ReferenceCountry {
+	q = strings.ToLower(strings.TrimSpace(q))
+	countries := []models.ReferenceCountry{}
+	for _, c := range geo.Countries() {
+		if q != "" && !containsAny(q, c.Code, c.Name, c.NameZH) {
+			continue
+		}
+		countries = append(countries, toReferenceCountry(c))
+	}
+	return countries
+}
+
+// ReferenceCountry 按代码、英文名或中文名查找国家
+func ReferenceCountry(country string) (*models.ReferenceCountry, error) {
+	c, ok := geo.FindCountry(country)
+	if !ok {
+		return nil, fmt.Errorf("%w: 国家 %s 不在参考数据中", ErrNotFound, country)
+	}
+	result := toReferenceCountry(c)
+	return &result, nil
+}
+
+// ReferenceCities 城市参考数据，country 不为空时只返回该国家（代码、英文名或中文名）的城市，
+// q 不为空时按英文名或中文名的子串过滤
+func ReferenceCities(country, q string) ([]models.ReferenceCity, error) {
+	code := ""
+	if country = strings.TrimSpace(country); country != "" {
+		c, ok := geo.FindCountry(country)
+		if !ok {
+			return nil, fmt.Errorf("%w: 国家 %s 不在参考数据中", ErrNotFound, country)
+		}
+		code = c.Code
+	}
+	q = strings.ToLower(strings.TrimSpace(q))
+
+	cities := []models.ReferenceCity{}
+	for _, c := range geo.Cities() {
+		if code != "" && c.CountryCode != code {
+			continue
+		}
+		if q != "" && !containsAny(q, c.Name, c.NameZH) {
+			continue
+		}
+		cities = append(cities, models.ReferenceCity{
+			ID:          c.ID,
+			CountryCode: c.CountryCode,
+			Name:        c.Name,
+			NameZH:      c.NameZH,
+			Timezone:    c.Timezone,
+			Lat:         c.Lat,
+			Lon:         c.Lon,
+		})
+	}
+	return cities, nil
+}
+
+// applyReferenceLocation 国家和城市在参考数据中时，统一为中文名并关联国家代码和城市ID，
+// 避免同一国家的不同写法（CN、China、中国）在按国家统计时被分成多组；不在参考数据中时保持原样
+func applyReferenceLocation(m *models.Merchant) {
+	m.CountryCode, m.CityID = "", nil
+	country, ok := geo.FindCountry(m.Country)
+	if !ok {
+		return
+	}
+	m.Country, m.CountryCode = country.NameZH, country.Code
+	if city, ok := geo.FindCity(country.Code, m.City); ok {
+		id := city.ID
+		m.City, m.CityID = city.NameZH, &id
+	}
+}
+
+// toReferenceCountry 转换为接口返回的国家参考数据
+func toReferenceCountry(c geo.Country) models.ReferenceCountry {
+	return models.ReferenceCountry{
+		Code:            c.Code,
+		Name:            c.Name,
+		NameZH:          c.NameZH,
+		DefaultTimezone: c.Timezone,
+		DefaultCurrency: c.Currency,
+		WeekendDays:     append([]int{}, c.WeekendDays...),
+	}
+}
+
+// containsAny 任一取值（转为小写后）包含 q
+func containsAny(q string, values ...string) bool {
+	for _, v := range values {
+		if strings.Contains(strings.ToLower(v), q) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/money"
+	"timezone-saas-demo/repository"
+)
+
+// RefundRequest 退款请求
+// Amount 为零时退还订单剩余的全部金额；RefundedAt 为 RFC3339 时间，为空时取当前时间
+type RefundRequest struct {
+	Amount     decimal.Decimal `json:"amount"`
+	Reason     string          `json:"reason"`
+	RefundedAt string          `json:"refunded_at"`
+	Operator   string          `json:"operator"`
+}
+
+// RefundService 订单退款服务
+type RefundService struct {
+	refunds repository.RefundRepository
+	now     func() time.Time
+}
+
+// NewRefundService 创建退款服务，使用 PostgreSQL 仓储
+func NewRefundService(db *database.DB) *RefundService {
+	return NewRefundServiceWithRepositories(repository.NewPostgresRefundRepository(db))
+}
+
+// NewRefundServiceWithRepositories 使用指定仓储创建退款服务
+func NewRefundServiceWithRepositories(refunds repository.RefundRepository) *RefundService {
+	return &RefundService{refunds: refunds, now: time.Now}
+}
+
+// ListRefunds 获取订单及其退款记录，退款记录带有原订单和退款在商户时区下的本地日期
+func (s *RefundService) ListRefunds(orderID int) (*models.OrderRefunds, error) {
+	order, err := s.refunds.Order(orderID)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := LoadLocation(order.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("加载时区 %s 失败: %w", order.Timezone, err)
+	}
+	for i := range order.Refunds {
+		setRefundLocalDates(&order.Refunds[i], order.OrderTimeUTC, loc)
+	}
+	return order, nil
+}
+
+// CreateRefund 为订单创建一笔（部分）退款
+// 金额按订单币种的小数位校验；退款时间不能早于下单时间，也不能晚于当前时间
+func (s *RefundService) CreateRefund(orderID int, req RefundRequest) (*models.Refund, error) {
+	if req.Amount.IsNegative() {
+		return nil, fmt.Errorf("%w: 退款金额不能为负数", ErrInvalidArgument)
+	}
+
+	order, err := s.refunds.Order(orderID)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := LoadLocation(order.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("加载时区 %s 失败: %w", order.Timezone, err)
+	}
+
+	amount := req.Amount
+	if amount.IsZero() {
+		amount = order.RemainingAmount
+		if !amount.IsPositive() {
+			return nil, fmt.Errorf("%w: 订单 %d 已全额退款", ErrConflict, orderID)
+		}
+	}
+	if !amount.Equal(money.Round(amount, order.Currency)) {
+		return nil, fmt.Errorf("%w: 退款金额 %s 超出币种 %s 的小数位", ErrInvalidArgument, amount, order.Currency)
+	}
+
+	now := s.now().UTC()
+	refundedAt := now
+	if req.RefundedAt != "" {
+		t, err := time.Parse(time.RFC3339, req.RefundedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%w: 退款时间格式错误，应为 RFC3339: %v", ErrInvalidArgument, err)
+		}
+		refundedAt = t.UTC()
+	}
+	if refundedAt.Before(order.OrderTimeUTC) {
+		return nil, fmt.Errorf("%w: 退款时间 %s 早于下单时间 %s", ErrInvalidArgument,
+			refundedAt.Format(time.RFC3339), order.OrderTimeUTC.UTC().Format(time.RFC3339))
+	}
+	if refundedAt.After(now) {
+		return nil, fmt.Errorf("%w: 退款时间不能晚于当前时间", ErrInvalidArgument)
+	}
+
+	operator := strings.TrimSpace(req.Operator)
+	if operator == "" {
+		operator = "api"
+	}
+	refund := &models.Refund{
+		OrderID:       orderID,
+		Amount:        amount,
+		Reason:        strings.TrimSpace(req.Reason),
+		RefundTimeUTC: refundedAt,
+		CreatedBy:     operator,
+	}
+	if err := s.refunds.Create(refund); err != nil {
+		return nil, err
+	}
+	setRefundLocalDates(refund, order.OrderTimeUTC, loc)
+	return refund, nil
+}
+
+// setRefundLocalDates 按商户时区计算原订单和退款的本地日期
+func setRefundLocalDates(refund *models.Refund, orderTimeUTC time.Time, loc *time.Location) {
+	refund.OrderLocalDate = orderTimeUTC.In(loc).Format("2006-01-02")
+	refund.RefundLocalDate = refund.RefundTimeUTC.In(loc).Format("2006-01-02")
+}
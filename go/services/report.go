@@ -0,0 +1,663 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/locale"
+	"timezone-saas-demo/mailtemplate"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/money"
+	"timezone-saas-demo/repository"
+	"timezone-saas-demo/timerange"
+)
+
+// 报表的时区口径：merchant 按商户时区计算日期范围和执行时间，utc 按 UTC
+const (
+	ReportTimezoneMerchant = "merchant"
+	ReportTimezoneUTC      = "utc"
+)
+
+// 报表的日期范围类型，相对范围按执行时刻在报表时区的本地日期计算，周从周一开始
+const (
+	ReportRangeToday       = "today"
+	ReportRangeYesterday   = "yesterday"
+	ReportRangeLast7Days   = "last_7_days"
+	ReportRangeLast30Days  = "last_30_days"
+	ReportRangeWeekToDate  = "week_to_date"
+	ReportRangeLastWeek    = "last_week"
+	ReportRangeMonthToDate = "month_to_date"
+	ReportRangeLastMonth   = "last_month"
+	ReportRangeCustom      = "custom"
+)
+
+// 报表结果文件的格式
+const (
+	ReportFormatJSON = "json"
+	ReportFormatCSV  = "csv"
+)
+
+// 报表执行的触发方式和状态
+const (
+	ReportTriggerSchedule = "schedule"
+	ReportTriggerManual   = "manual"
+
+	ReportStatusRunning   = "running"
+	ReportStatusCompleted = "completed"
+	ReportStatusFailed    = "failed"
+)
+
+// maxReportDays 一次报表最多统计的本地日期数，每个日期执行一次分析查询
+const maxReportDays = 31
+
+// maxReportEmails 一个报表最多通知的邮箱数
+const maxReportEmails = 20
+
+// reportRangeTypes 支持的日期范围类型
+var reportRangeTypes = []string{
+	ReportRangeToday, ReportRangeYesterday, ReportRangeLast7Days, ReportRangeLast30Days,
+	ReportRangeWeekToDate, ReportRangeLastWeek, ReportRangeMonthToDate, ReportRangeLastMonth, ReportRangeCustom,
+}
+
+// ReportDefinitionRequest 创建或修改报表定义的请求
+type ReportDefinitionRequest struct {
+	Name string `json:"name"`
+	// MerchantID 时区口径为 merchant 时必填
+	MerchantID int `json:"merchant_id"`
+	// TimezoneMode 缺省时指定了商户为 merchant，否则为 utc
+	TimezoneMode string `json:"timezone_mode"`
+	// RangeType 缺省为 yesterday；custom 时 From、To 必填
+	RangeType string   `json:"range_type"`
+	From      string   `json:"from"`
+	To        string   `json:"to"`
+	Statuses  []string `json:"statuses"`
+	Currency  string   `json:"currency"`
+	// Format 缺省为 json
+	Format   string `json:"format"`
+	Schedule string `json:"schedule"`
+	// Emails 执行完成后通知的邮箱
+	Emails   []string `json:"emails"`
+	Operator string   `json:"operator"`
+}
+
+// ReportService 保存的报表定义、手动和定时执行
+// 每次执行按定义计算本地日期范围，逐日调用分析接口的查询，结果文件保存在执行记录中
+type ReportService struct {
+	reports   repository.ReportRepository
+	merchants repository.MerchantRepository
+	analysis  *TimezoneService
+	// emails 执行完成后发送 report_ready 邮件，baseURL 为下载地址的前缀
+	emails  *EmailService
+	baseURL string
+
+	// mu 保证同一进程内定时执行不会重叠
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+// NewReportService 创建报表服务，使用 PostgreSQL 仓储，分析数据来自 analysis
+func NewReportService(db *database.DB, analysis *TimezoneService) *ReportService {
+	return NewReportServiceWithRepositories(
+		repository.NewPostgresReportRepository(db),
+		repository.NewPostgresMerchantRepository(db),
+		analysis,
+	)
+}
+
+// NewReportServiceWithRepositories 使用指定仓储创建报表服务
+func NewReportServiceWithRepositories(reports repository.ReportRepository, merchants repository.MerchantRepository, analysis *TimezoneService) *ReportService {
+	return &ReportService{
+		reports:   reports,
+		merchants: merchants,
+		analysis:  analysis,
+		now:       time.Now,
+	}
+}
+
+// SetEmailService 设置执行完成后通知 emails 的邮件服务
+// baseURL 为服务对外的地址（如 https://saasview.example.com），邮件中的下载地址为 baseURL 加 artifact_url
+func (s *ReportService) SetEmailService(emails *EmailService, baseURL string) {
+	s.emails, s.baseURL = emails, strings.TrimRight(baseURL, "/")
+}
+
+// CreateDefinition 校验并保存报表定义，有重复规则时计算下一次执行时刻
+func (s *ReportService) CreateDefinition(ctx context.Context, req ReportDefinitionRequest) (*models.ReportDefinition, error) {
+	def, err := s.prepareDefinition(req)
+	if err != nil {
+		return nil, err
+	}
+	def.CreatedBy = operatorOrSystem(req.Operator)
+	if err := s.reports.CreateDefinition(ctx, def); err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+// UpdateDefinition 覆盖报表定义，重新计算下一次执行时刻；已有的执行记录不变
+func (s *ReportService) UpdateDefinition(ctx context.Context, id int, req ReportDefinitionRequest) (*models.ReportDefinition, error) {
+	if _, err := s.reports.Definition(ctx, id); err != nil {
+		return nil, err
+	}
+	def, err := s.prepareDefinition(req)
+	if err != nil {
+		return nil, err
+	}
+	def.ID = id
+	if err := s.reports.UpdateDefinition(ctx, def); err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+// DeleteDefinition 删除报表定义及其执行记录
+func (s *ReportService) DeleteDefinition(ctx context.Context, id int) error {
+	return s.reports.DeleteDefinition(ctx, id)
+}
+
+// Definitions 全部报表定义
+func (s *ReportService) Definitions(ctx context.Context) ([]models.ReportDefinition, error) {
+	defs, err := s.reports.Definitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if defs == nil {
+		defs = []models.ReportDefinition{}
+	}
+	for i := range defs {
+		if _, err := s.resolveTimezone(&defs[i]); err != nil {
+			return nil, err
+		}
+	}
+	return defs, nil
+}
+
+// Definition 单个报表定义，不存在时返回 ErrNotFound
+func (s *ReportService) Definition(ctx context.Context, id int) (*models.ReportDefinition, error) {
+	def, err := s.reports.Definition(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.resolveTimezone(def); err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+// ReportOccurrence 定时报表的一次预计执行
+type ReportOccurrence struct {
+	DefinitionID int
+	Name         string
+	Format       string
+	At           time.Time
+	// From、To 该次执行统计的本地日期范围
+	From, To string
+}
+
+// maxMerchantScheduleRuns 每个报表定义最多列出的预计执行次数
+const maxMerchantScheduleRuns = 400
+
+// MerchantSchedule 商户的定时报表在 until 之前的预计执行时刻，从各定义的 next_run_at 开始按重复规则推算，
+// 与定时执行一样以上一次执行时刻为起点计算下一次；按时刻排序
+func (s *ReportService) MerchantSchedule(ctx context.Context, merchantID int, until time.Time) ([]ReportOccurrence, error) {
+	defs, err := s.reports.Definitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	occurrences := []ReportOccurrence{}
+	for i := range defs {
+		def := &defs[i]
+		if def.MerchantID != merchantID || def.Schedule == "" || def.NextRunAt == nil {
+			continue
+		}
+		loc, err := s.resolveTimezone(def)
+		if err != nil {
+			return nil, fmt.Errorf("报表 %d 时区解析失败: %w", def.ID, err)
+		}
+		spec, err := s.parseSchedule(def)
+		if err != nil {
+			return nil, fmt.Errorf("报表 %d 重复规则解析失败: %w", def.ID, err)
+		}
+		for at, n := def.NextRunAt, 0; at != nil && at.Before(until) && n < maxMerchantScheduleRuns; n++ {
+			from, to, err := ReportDateRange(def, loc, *at)
+			if err != nil {
+				return nil, err
+			}
+			occurrences = append(occurrences, ReportOccurrence{
+				DefinitionID: def.ID, Name: def.Name, Format: def.Format, At: at.UTC(), From: from, To: to,
+			})
+			if at, err = spec.next(loc, *at); err != nil {
+				return nil, fmt.Errorf("报表 %d 计算下一次执行时刻失败: %w", def.ID, err)
+			}
+		}
+	}
+	sort.Slice(occurrences, func(i, j int) bool { return occurrences[i].At.Before(occurrences[j].At) })
+	return occurrences, nil
+}
+
+// prepareDefinition 校验请求并生成报表定义（不含ID）
+func (s *ReportService) prepareDefinition(req ReportDefinitionRequest) (*models.ReportDefinition, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len([]rune(name)) > 100 {
+		return nil, fmt.Errorf("%w: 报表名称不能为空且不超过 100 个字符", ErrInvalidArgument)
+	}
+	if req.MerchantID < 0 {
+		return nil, fmt.Errorf("%w: 无效的商户ID %d", ErrInvalidArgument, req.MerchantID)
+	}
+
+	def := &models.ReportDefinition{
+		Name:         name,
+		MerchantID:   req.MerchantID,
+		TimezoneMode: strings.ToLower(strings.TrimSpace(req.TimezoneMode)),
+		RangeType:    strings.ToLower(strings.TrimSpace(req.RangeType)),
+		Format:       strings.ToLower(strings.TrimSpace(req.Format)),
+	}
+	if def.TimezoneMode == "" {
+		def.TimezoneMode = ReportTimezoneUTC
+		if def.MerchantID > 0 {
+			def.TimezoneMode = ReportTimezoneMerchant
+		}
+	}
+	switch def.TimezoneMode {
+	case ReportTimezoneMerchant:
+		if def.MerchantID == 0 {
+			return nil, fmt.Errorf("%w: 时区口径为 merchant 时必须指定商户", ErrInvalidArgument)
+		}
+	case ReportTimezoneUTC:
+	default:
+		return nil, fmt.Errorf("%w: 无效的时区口径 %q，可选 %s,%s", ErrInvalidArgument, def.TimezoneMode, ReportTimezoneMerchant, ReportTimezoneUTC)
+	}
+	if def.MerchantID > 0 {
+		if _, err := s.merchants.Get(def.MerchantID); err != nil {
+			return nil, err
+		}
+	}
+
+	if def.RangeType == "" {
+		def.RangeType = ReportRangeYesterday
+	}
+	if !isReportRangeType(def.RangeType) {
+		return nil, fmt.Errorf("%w: 无效的日期范围类型 %q，可选 %s", ErrInvalidArgument, def.RangeType, strings.Join(reportRangeTypes, ","))
+	}
+	if def.RangeType == ReportRangeCustom {
+		if err := validateReportDates(req.From, req.To); err != nil {
+			return nil, err
+		}
+		def.From, def.To = req.From, req.To
+	} else if req.From != "" || req.To != "" {
+		return nil, fmt.Errorf("%w: 只有 range_type 为 custom 时可以指定 from/to", ErrInvalidArgument)
+	}
+
+	statuses, err := ParseStatuses(strings.Join(req.Statuses, ","))
+	if err != nil {
+		return nil, err
+	}
+	def.Statuses = statuses
+	if def.Statuses == nil {
+		def.Statuses = []string{}
+	}
+	if currency := strings.TrimSpace(req.Currency); currency != "" {
+		if def.Currency, err = money.ParseCode(currency); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+		}
+	}
+
+	if def.Format == "" {
+		def.Format = ReportFormatJSON
+	}
+	if def.Format != ReportFormatJSON && def.Format != ReportFormatCSV {
+		return nil, fmt.Errorf("%w: 无效的报表格式 %q，可选 %s,%s", ErrInvalidArgument, def.Format, ReportFormatJSON, ReportFormatCSV)
+	}
+	if def.Emails, err = normalizeEmails(req.Emails, maxReportEmails); err != nil {
+		return nil, err
+	}
+
+	loc, err := s.resolveTimezone(def)
+	if err != nil {
+		return nil, err
+	}
+	if def.Schedule = strings.TrimSpace(req.Schedule); def.Schedule != "" {
+		spec, err := s.parseSchedule(def)
+		if err != nil {
+			return nil, err
+		}
+		// 每次执行后从当前时刻重新展开规则，COUNT 无法累计
+		if spec.rule != nil && spec.rule.Count > 0 {
+			return nil, fmt.Errorf("%w: 报表的重复规则不支持 COUNT", ErrInvalidArgument)
+		}
+		def.Schedule = spec.String()
+		if def.NextRunAt, err = spec.next(loc, s.now()); err != nil {
+			return nil, err
+		}
+		if def.NextRunAt == nil {
+			return nil, fmt.Errorf("%w: 重复规则 %s 在一年内没有发生", ErrInvalidArgument, def.Schedule)
+		}
+	}
+	return def, nil
+}
+
+// isReportRangeType 是否为支持的日期范围类型
+func isReportRangeType(value string) bool {
+	for _, t := range reportRangeTypes {
+		if t == value {
+			return true
+		}
+	}
+	return false
+}
+
+// validateReportDates 校验自定义日期范围
+func validateReportDates(from, to string) error {
+	dates, err := localDates(from, to, time.UTC)
+	if err != nil {
+		return err
+	}
+	if dates.Days() > maxReportDays {
+		return fmt.Errorf("%w: 日期范围不能超过 %d 天", ErrInvalidArgument, maxReportDays)
+	}
+	return nil
+}
+
+// resolveTimezone 按时区口径确定报表时区，写入 def.Timezone
+func (s *ReportService) resolveTimezone(def *models.ReportDefinition) (*time.Location, error) {
+	def.Timezone = "UTC"
+	if def.TimezoneMode == ReportTimezoneMerchant {
+		merchant, err := s.merchants.Get(def.MerchantID)
+		if err != nil {
+			return nil, err
+		}
+		def.Timezone = merchant.Timezone
+	}
+	return LoadLocation(def.Timezone)
+}
+
+// parseSchedule 解析报表的定时规则，CRON_TZ=@merchant_local 使用报表关联商户的时区
+func (s *ReportService) parseSchedule(def *models.ReportDefinition) (*scheduleSpec, error) {
+	var merchantTimezone func() (string, error)
+	if def.MerchantID > 0 {
+		merchantTimezone = func() (string, error) {
+			merchant, err := s.merchants.Get(def.MerchantID)
+			if err != nil {
+				return "", err
+			}
+			return merchant.Timezone, nil
+		}
+	}
+	return parseScheduleSpec(def.Schedule, merchantTimezone)
+}
+
+// reportRelativeRanges 报表日期范围类型对应的 timerange.Relative 名称，与请求参数 range 含义一致：
+// last_7_days、last_30_days 截至执行当天（含当天），week_to_date、month_to_date 即 this_week、this_month
+var reportRelativeRanges = map[string]string{
+	ReportRangeToday:       "today",
+	ReportRangeYesterday:   "yesterday",
+	ReportRangeLast7Days:   "last_7_days",
+	ReportRangeLast30Days:  "last_30_days",
+	ReportRangeWeekToDate:  "this_week",
+	ReportRangeLastWeek:    "last_week",
+	ReportRangeMonthToDate: "this_month",
+	ReportRangeLastMonth:   "last_month",
+}
+
+// ReportDateRange 报表在 at 时刻执行时统计的本地日期范围 [from, to]
+func ReportDateRange(def *models.ReportDefinition, loc *time.Location, at time.Time) (string, string, error) {
+	if def.RangeType == ReportRangeCustom {
+		return def.From, def.To, nil
+	}
+	name, ok := reportRelativeRanges[def.RangeType]
+	if !ok {
+		return "", "", fmt.Errorf("%w: 无效的日期范围类型 %q", ErrInvalidArgument, def.RangeType)
+	}
+	rng, err := timerange.Relative(name, loc, at)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	return rng.From, rng.To, nil
+}
+
+// Execute 立即执行报表，返回执行记录；生成失败时记录状态为 failed，不返回错误
+func (s *ReportService) Execute(ctx context.Context, id int) (*models.ReportRun, error) {
+	def, err := s.reports.Definition(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.execute(ctx, def, ReportTriggerManual, s.now())
+}
+
+// Run 每隔 interval 执行到期的定时报表，直到 ctx 取消
+func (s *ReportService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := s.RunDue(ctx, s.now()); err != nil {
+			log.Printf("定时报表执行失败: %v", err)
+		} else if n > 0 {
+			log.Printf("定时报表执行完成: %d 个报表", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue 执行下一次执行时刻不晚于 at 的报表，返回执行的报表数
+// 日期范围按计划的执行时刻计算，服务停机后补跑时仍统计原本应统计的日期；
+// 停机期间错过的多次执行只补跑一次，下一次执行时刻从 at 之后重新计算
+func (s *ReportService) RunDue(ctx context.Context, at time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	defs, err := s.reports.DueDefinitions(ctx, at)
+	if err != nil {
+		return 0, err
+	}
+	executed := 0
+	for i := range defs {
+		def := &defs[i]
+		due := *def.NextRunAt
+		loc, err := s.resolveTimezone(def)
+		if err != nil {
+			return executed, fmt.Errorf("报表 %d 时区解析失败: %w", def.ID, err)
+		}
+		spec, err := s.parseSchedule(def)
+		if err != nil {
+			return executed, fmt.Errorf("报表 %d 重复规则解析失败: %w", def.ID, err)
+		}
+		next, err := spec.next(loc, at)
+		if err != nil {
+			return executed, fmt.Errorf("报表 %d 计算下一次执行时刻失败: %w", def.ID, err)
+		}
+		claimed, err := s.reports.ClaimDue(ctx, def.ID, due, next)
+		if err != nil {
+			return executed, err
+		}
+		if !claimed {
+			continue
+		}
+		run, err := s.execute(ctx, def, ReportTriggerSchedule, due)
+		if err != nil {
+			return executed, fmt.Errorf("报表 %d 执行失败: %w", def.ID, err)
+		}
+		if run.Status == ReportStatusFailed {
+			log.Printf("⚠️ 报表 %s 执行失败: %s", def.Name, run.Error)
+		}
+		executed++
+	}
+	return executed, nil
+}
+
+// execute 按 at 时刻计算日期范围并生成结果文件
+// 只有写入执行记录失败时返回错误
+func (s *ReportService) execute(ctx context.Context, def *models.ReportDefinition, triggeredBy string, at time.Time) (*models.ReportRun, error) {
+	loc, err := s.resolveTimezone(def)
+	if err != nil {
+		return nil, err
+	}
+	from, to, err := ReportDateRange(def, loc, at)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &models.ReportRun{
+		DefinitionID: def.ID,
+		TriggeredBy:  triggeredBy,
+		Status:       ReportStatusRunning,
+		From:         from,
+		To:           to,
+		Timezone:     def.Timezone,
+		Format:       def.Format,
+		StartedAt:    s.now().UTC(),
+	}
+	if err := s.reports.CreateRun(ctx, run); err != nil {
+		return nil, err
+	}
+
+	artifact, err := s.generate(ctx, def, from, to)
+	finished := s.now().UTC()
+	run.FinishedAt = &finished
+	if err != nil {
+		run.Status, run.Error, artifact = ReportStatusFailed, err.Error(), nil
+	} else {
+		run.Status, run.ArtifactSize = ReportStatusCompleted, int64(len(artifact))
+	}
+	if err := s.reports.FinishRun(ctx, run, artifact); err != nil {
+		return nil, err
+	}
+	setReportArtifactURL(run)
+	if run.Status == ReportStatusCompleted && len(def.Emails) > 0 {
+		if err := s.sendReady(ctx, def, run); err != nil && !errors.Is(err, ErrMailDisabled) {
+			log.Printf("⚠️ 报表 %s 的完成通知发送失败: %v", def.Name, err)
+		}
+	}
+	return run, nil
+}
+
+// sendReady 发送 report_ready 邮件；指定了商户时按商户的语言和时区渲染，否则按默认语言和 UTC
+// 报表不区分租户，使用默认租户的模板
+func (s *ReportService) sendReady(ctx context.Context, def *models.ReportDefinition, run *models.ReportRun) error {
+	if !s.emails.Enabled() {
+		return ErrMailDisabled
+	}
+	opts := mailtemplate.Options{Locale: locale.Default, Location: time.UTC}
+	if def.MerchantID > 0 {
+		merchant, err := s.merchants.Get(def.MerchantID)
+		if err != nil {
+			return err
+		}
+		if opts, err = s.emails.MerchantOptions(merchant); err != nil {
+			return err
+		}
+	}
+	data := mailtemplate.ReportData{Definition: *def, Run: *run, ArtifactURL: s.baseURL + run.ArtifactURL}
+	return s.emails.Send(ctx, DefaultTenant, mailtemplate.ReportReady, def.Emails, opts, data)
+}
+
+// generate 逐日查询分析数据并按报表格式生成结果文件
+func (s *ReportService) generate(ctx context.Context, def *models.ReportDefinition, from, to string) ([]byte, error) {
+	dates, err := timerange.Between(from, to, time.UTC)
+	if err != nil {
+		return nil, fmt.Errorf("解析报表日期范围失败: %w", err)
+	}
+
+	artifact := models.ReportArtifact{
+		Definition:  *def,
+		From:        from,
+		To:          to,
+		Timezone:    def.Timezone,
+		GeneratedAt: s.now().UTC(),
+		Days:        []models.AnalysisData{},
+	}
+	for _, date := range dates.Dates() {
+		data, err := s.analysis.GetAnalysisData(ctx, date, def.Currency, def.Statuses)
+		if err != nil {
+			return nil, fmt.Errorf("查询 %s 的分析数据失败: %w", date, err)
+		}
+		artifact.Days = append(artifact.Days, *data)
+	}
+
+	if def.Format == ReportFormatCSV {
+		return reportCSV(artifact)
+	}
+	return json.MarshalIndent(artifact, "", "  ")
+}
+
+// reportCSV 每个（本地日期，币种）一行；指定了币种时只输出该币种
+func reportCSV(artifact models.ReportArtifact) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	header := []string{"date", "currency", "order_count", "gross_amount", "refund_amount", "net_amount", "total_amount"}
+	if err := w.Write(header); err != nil {
+		return nil, fmt.Errorf("写入报表表头失败: %w", err)
+	}
+	for _, day := range artifact.Days {
+		for _, t := range day.TotalsByCurrency {
+			if artifact.Definition.Currency != "" && t.Currency != artifact.Definition.Currency {
+				continue
+			}
+			record := []string{
+				day.Date, t.Currency, strconv.Itoa(t.OrderCount),
+				t.GrossAmount.String(), t.RefundAmount.String(), t.NetAmount.String(), t.TotalAmount.String(),
+			}
+			if err := w.Write(record); err != nil {
+				return nil, fmt.Errorf("写入报表失败: %w", err)
+			}
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("写入报表失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Runs 报表最近 limit 次执行，完成的执行带结果文件的下载地址
+func (s *ReportService) Runs(ctx context.Context, id, limit int) ([]models.ReportRun, error) {
+	if _, err := s.reports.Definition(ctx, id); err != nil {
+		return nil, err
+	}
+	runs, err := s.reports.Runs(ctx, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	if runs == nil {
+		runs = []models.ReportRun{}
+	}
+	for i := range runs {
+		setReportArtifactURL(&runs[i])
+	}
+	return runs, nil
+}
+
+// Artifact 一次执行的结果文件及其 Content-Type
+func (s *ReportService) Artifact(ctx context.Context, runID int64) (*models.ReportRun, []byte, string, error) {
+	run, artifact, err := s.reports.Artifact(ctx, runID)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	contentType := "application/json"
+	if run.Format == ReportFormatCSV {
+		contentType = "text/csv; charset=utf-8"
+	}
+	return run, artifact, contentType, nil
+}
+
+// setReportArtifactURL 完成的执行设置结果文件的下载地址
+func setReportArtifactURL(run *models.ReportRun) {
+	if run.Status == ReportStatusCompleted {
+		run.ArtifactURL = fmt.Sprintf("/api/reports/runs/%d/artifact", run.RunID)
+	}
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"container/list"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"timezone-saas-demo/models"
+)
+
+// 响应缓存的默认配置
+const (
+	// DefaultResponseCacheTTL 缓存的响应在该时间内直接返回
+	DefaultResponseCacheTTL = 30 * time.Second
+	// DefaultResponseCacheStale 过期后在该时间内仍返回旧响应，同时在后台刷新
+	DefaultResponseCacheStale = 5 * time.Minute
+	// DefaultResponseCacheEntries 缓存的响应条数上限，超出时淘汰最久未使用的
+	DefaultResponseCacheEntries = 1000
+)
+
+// 缓存查找的结果
+const (
+	CacheMiss  = "MISS"
+	CacheHit   = "HIT"
+	CacheStale = "STALE"
+)
+
+// CachedResponse 缓存的一次响应
+type CachedResponse struct {
+	Status   int
+	Header   http.Header
+	Body     []byte
+	StoredAt time.Time
+}
+
+// cacheEntry 缓存条目，elem 为其在 LRU 链表中的位置
+type cacheEntry struct {
+	key      string
+	tenant   string
+	response CachedResponse
+	elem     *list.Element
+}
+
+// ResponseCache 按租户和请求参数缓存较重的 GET 接口的响应（stale-while-revalidate）
+// 缓存在 TTL 内直接返回；过期后的 Stale 时间内仍返回旧响应，并由一个请求在后台刷新；超过 TTL+Stale 后按未命中处理。
+// 写入数据后调用 Purge 清空，缓存只在本实例内存中，其他实例的写入最迟在 TTL+Stale 后可见
+type ResponseCache struct {
+	ttl        time.Duration
+	stale      time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu         sync.Mutex
+	entries    map[string]*cacheEntry
+	lru        *list.List
+	refreshing map[string]bool
+	// generation 每次清空时加一，清空前开始的请求和刷新不再写入
+	generation uint64
+	stats      models.ResponseCacheStats
+}
+
+// NewResponseCache 创建响应缓存，ttl 为 0 时不缓存，maxEntries 不大于 0 时使用 DefaultResponseCacheEntries
+func NewResponseCache(ttl, stale time.Duration, maxEntries int) *ResponseCache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultResponseCacheEntries
+	}
+	if stale < 0 {
+		stale = 0
+	}
+	return &ResponseCache{
+		ttl:        ttl,
+		stale:      stale,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		entries:    map[string]*cacheEntry{},
+		lru:        list.New(),
+		refreshing: map[string]bool{},
+	}
+}
+
+// Enabled 是否启用缓存
+func (c *ResponseCache) Enabled() bool {
+	return c != nil && c.ttl > 0
+}
+
+// Get 查找缓存的响应，返回 CacheHit、CacheStale 或 CacheMiss
+// 结果为 CacheStale 且 refresh 为 true 时，调用方负责在后台刷新并在完成后调用 Put 或 Abandon
+func (c *ResponseCache) Get(key string) (response CachedResponse, state string, refresh bool, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if ok {
+		age := c.now().Sub(entry.response.StoredAt)
+		switch {
+		case age < c.ttl:
+			c.lru.MoveToFront(entry.elem)
+			c.stats.Hits++
+			return entry.response, CacheHit, false, c.generation
+		case age < c.ttl+c.stale:
+			c.lru.MoveToFront(entry.elem)
+			c.stats.StaleHits++
+			if !c.refreshing[key] {
+				c.refreshing[key] = true
+				refresh = true
+				c.stats.Refreshes++
+			}
+			return entry.response, CacheStale, refresh, c.generation
+		}
+		c.remove(entry)
+	}
+	c.stats.Misses++
+	return CachedResponse{}, CacheMiss, false, c.generation
+}
+
+// Put 保存响应；generation 为 Get 返回的值，期间缓存被清空过时丢弃，避免写入前读取的旧数据重新进入缓存
+func (c *ResponseCache) Put(key, tenant string, response CachedResponse, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.refreshing, key)
+	if generation != c.generation {
+		return
+	}
+	response.StoredAt = c.now()
+	if entry, ok := c.entries[key]; ok {
+		entry.response = response
+		c.lru.MoveToFront(entry.elem)
+		return
+	}
+	entry := &cacheEntry{key: key, tenant: tenant, response: response}
+	entry.elem = c.lru.PushFront(entry)
+	c.entries[key] = entry
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back().Value.(*cacheEntry))
+		c.stats.Evictions++
+	}
+}
+
+// Abandon 后台刷新失败（如响应不是 200）时调用，保留旧响应直到超过 TTL+Stale，之后的请求可以再次刷新
+func (c *ResponseCache) Abandon(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.refreshing, key)
+}
+
+// Purge 清空缓存，tenant 不为空时只清空该租户的响应，返回清除的条数
+// 清空前开始的请求和刷新不再写入缓存
+func (c *ResponseCache) Purge(tenant string) int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for _, entry := range c.entries {
+		if tenant == "" || entry.tenant == tenant {
+			c.remove(entry)
+			n++
+		}
+	}
+	c.generation++
+	c.stats.Purges++
+	purgedAt := c.now().UTC()
+	c.stats.LastPurgeAt = &purgedAt
+	return n
+}
+
+// Stats 缓存的命中统计和各租户的条数
+func (c *ResponseCache) Stats() models.ResponseCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Enabled = c.ttl > 0
+	stats.TTLSeconds = c.ttl.Seconds()
+	stats.StaleSeconds = c.stale.Seconds()
+	stats.MaxEntries = c.maxEntries
+	stats.Entries = len(c.entries)
+	counts := map[string]int{}
+	for _, entry := range c.entries {
+		counts[entry.tenant]++
+	}
+	stats.Tenants = make([]models.ResponseCacheTenant, 0, len(counts))
+	for tenant, n := range counts {
+		stats.Tenants = append(stats.Tenants, models.ResponseCacheTenant{Tenant: tenant, Entries: n})
+	}
+	sort.Slice(stats.Tenants, func(i, j int) bool { return stats.Tenants[i].Tenant < stats.Tenants[j].Tenant })
+	return stats
+}
+
+// remove 删除条目，调用方持有锁
+func (c *ResponseCache) remove(entry *cacheEntry) {
+	c.lru.Remove(entry.elem)
+	delete(c.entries, entry.key)
+}
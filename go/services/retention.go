@@ -0,0 +1,399 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/export"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/repository"
+)
+
+// 保留策略的取值范围
+const (
+	// MaxRetentionMonths 最多保留的月数
+	MaxRetentionMonths = 120
+	// RetentionTargetTable 归档到冷表 dws_orders_archive
+	RetentionTargetTable = "table"
+	// RetentionTargetObject 归档为归档目录中的文件
+	RetentionTargetObject = "object"
+)
+
+// 归档执行的触发方式和状态，与 retention_run 表的约束一致
+const (
+	RetentionTriggerSchedule = "schedule"
+	RetentionTriggerManual   = "manual"
+
+	RetentionStatusRunning   = "running"
+	RetentionStatusCompleted = "completed"
+	RetentionStatusFailed    = "failed"
+)
+
+// ArchiveStore 归档文件的存储
+type ArchiveStore interface {
+	// Put 把 write 写出的内容保存为 key，write 返回错误时不留下文件；返回文件的位置
+	Put(ctx context.Context, key string, write func(io.Writer) error) (string, error)
+	// Remove 删除 key，文件不存在时不报错
+	Remove(ctx context.Context, key string) error
+	// RemoveAll 删除以 prefix 开头的全部文件，返回删除的文件数，用于删除商户的全部归档
+	RemoveAll(ctx context.Context, prefix string) (int, error)
+}
+
+// MerchantArchivePrefix 商户归档文件的 key 前缀
+func MerchantArchivePrefix(merchantID int) string {
+	return fmt.Sprintf("merchant_id=%d/", merchantID)
+}
+
+// FileArchiveStore 把归档文件写入本地目录（可以是挂载的对象存储），key 为相对路径
+type FileArchiveStore struct {
+	dir string
+}
+
+// NewFileArchiveStore 创建本地目录归档存储
+func NewFileArchiveStore(dir string) *FileArchiveStore {
+	return &FileArchiveStore{dir: dir}
+}
+
+// Put 先写入同目录的临时文件，成功后重命名，读取方不会看到写了一半的文件
+func (s *FileArchiveStore) Put(ctx context.Context, key string, write func(io.Writer) error) (string, error) {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("创建归档目录失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("创建归档文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("写入归档文件失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("写入归档文件失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("保存归档文件失败: %w", err)
+	}
+	return path, nil
+}
+
+// Remove 删除归档文件
+func (s *FileArchiveStore) Remove(ctx context.Context, key string) error {
+	err := os.Remove(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("删除归档文件失败: %w", err)
+	}
+	return nil
+}
+
+// RemoveAll 删除 prefix 目录及其中的文件
+func (s *FileArchiveStore) RemoveAll(ctx context.Context, prefix string) (int, error) {
+	dir := filepath.Join(s.dir, filepath.FromSlash(prefix))
+	files := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			files++
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("读取归档目录失败: %w", err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return 0, fmt.Errorf("删除归档目录失败: %w", err)
+	}
+	return files, nil
+}
+
+// RetentionService 按商户的保留策略归档旧订单
+// 早于最近 N 个本地自然月的订单按（商户，月份）逐个归档，每个月份在一个语句中完成；
+// 归档订单的小时汇总移入 agg_orders_hourly_archived，分析接口的合计不受归档影响
+type RetentionService struct {
+	merchants repository.MerchantRepository
+	retention repository.RetentionRepository
+	// store 为 nil 时不能使用 object 归档
+	store  ArchiveStore
+	format export.Format
+
+	// mu 保证定时归档和手动触发的归档不会同时执行
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+// NewRetentionService 创建归档服务，使用 PostgreSQL 仓储；store 为 nil 时只能归档到冷表
+func NewRetentionService(db *database.DB, store ArchiveStore, format export.Format) *RetentionService {
+	return NewRetentionServiceWithRepositories(
+		repository.NewPostgresMerchantRepository(db),
+		repository.NewPostgresRetentionRepository(db),
+		store,
+		format,
+	)
+}
+
+// NewRetentionServiceWithRepositories 使用指定仓储创建归档服务
+func NewRetentionServiceWithRepositories(merchants repository.MerchantRepository, retention repository.RetentionRepository, store ArchiveStore, format export.Format) *RetentionService {
+	return &RetentionService{
+		merchants: merchants,
+		retention: retention,
+		store:     store,
+		format:    format,
+		now:       time.Now,
+	}
+}
+
+// Policies 全部保留策略
+func (s *RetentionService) Policies(ctx context.Context) ([]models.RetentionPolicy, error) {
+	policies, err := s.retention.Policies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if policies == nil {
+		policies = []models.RetentionPolicy{}
+	}
+	return policies, nil
+}
+
+// SetPolicy 新增或覆盖商户的保留策略，下一次归档生效
+func (s *RetentionService) SetPolicy(ctx context.Context, merchantID, months int, target, operator string) (*models.RetentionPolicy, error) {
+	if months < 1 || months > MaxRetentionMonths {
+		return nil, fmt.Errorf("%w: 保留月数应在 1~%d 之间", ErrInvalidArgument, MaxRetentionMonths)
+	}
+	switch target {
+	case RetentionTargetTable:
+	case RetentionTargetObject:
+		if s.store == nil {
+			return nil, fmt.Errorf("%w: 未配置归档目录（RETENTION_ARCHIVE_DIR），不能归档为文件", ErrInvalidArgument)
+		}
+	default:
+		return nil, fmt.Errorf("%w: 归档位置应为 %s 或 %s", ErrInvalidArgument, RetentionTargetTable, RetentionTargetObject)
+	}
+	if _, err := s.merchants.Get(merchantID); err != nil {
+		return nil, err
+	}
+
+	policy := &models.RetentionPolicy{
+		MerchantID:         merchantID,
+		ArchiveAfterMonths: months,
+		Target:             target,
+		UpdatedBy:          operatorOrSystem(operator),
+	}
+	if err := s.retention.SavePolicy(ctx, policy); err != nil {
+		return nil, err
+	}
+	log.Printf("🗄️ 商户 %d 的保留策略已更新：保留 %d 个月，归档到 %s（%s）", merchantID, months, target, policy.UpdatedBy)
+	return policy, nil
+}
+
+// DeletePolicy 删除商户的保留策略，已归档的订单不会恢复
+func (s *RetentionService) DeletePolicy(ctx context.Context, merchantID int) error {
+	return s.retention.DeletePolicy(ctx, merchantID)
+}
+
+// Runs 最近 limit 次归档，不含明细
+func (s *RetentionService) Runs(ctx context.Context, limit int) ([]models.RetentionRun, error) {
+	runs, err := s.retention.Runs(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	if runs == nil {
+		runs = []models.RetentionRun{}
+	}
+	return runs, nil
+}
+
+// GetRun 一次归档及其明细，不存在时返回 ErrNotFound
+func (s *RetentionService) GetRun(ctx context.Context, runID int64) (*models.RetentionRun, error) {
+	return s.retention.Run(ctx, runID)
+}
+
+// Run 每隔 interval 归档一次，直到 ctx 取消
+// 启动时不立即归档，避免频繁重启时反复扫描订单表
+func (s *RetentionService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		run, err := s.Archive(ctx, RetentionTriggerSchedule, 0)
+		if err != nil {
+			log.Printf("订单归档失败: %v", err)
+		} else if run.Status == RetentionStatusFailed {
+			log.Printf("订单归档 %d 部分失败: %s", run.RunID, run.Error)
+		}
+	}
+}
+
+// Archive 按保留策略执行一次归档，merchantID 为 0 时处理所有配置了策略的商户
+// 某个商户归档失败时继续处理其余商户，记录状态为 failed；已经归档的月份不会回滚
+// 只有读取策略或保存执行记录失败时返回错误
+func (s *RetentionService) Archive(ctx context.Context, triggeredBy string, merchantID int) (*models.RetentionRun, error) {
+	policies, err := s.retention.Policies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if merchantID > 0 {
+		var selected []models.RetentionPolicy
+		for _, p := range policies {
+			if p.MerchantID == merchantID {
+				selected = append(selected, p)
+			}
+		}
+		if len(selected) == 0 {
+			return nil, fmt.Errorf("%w: 商户 %d 没有保留策略", ErrNotFound, merchantID)
+		}
+		policies = selected
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run := &models.RetentionRun{
+		TriggeredBy: triggeredBy,
+		Status:      RetentionStatusRunning,
+		StartedAt:   s.now().UTC(),
+		Buckets:     []models.RetentionBucket{},
+	}
+	if err := s.retention.CreateRun(ctx, run); err != nil {
+		return nil, err
+	}
+
+	var failures []string
+	for _, policy := range policies {
+		if err := s.archiveMerchant(ctx, run, policy); err != nil {
+			log.Printf("⚠️ 商户 %d 归档失败: %v", policy.MerchantID, err)
+			failures = append(failures, fmt.Sprintf("商户 %d: %v", policy.MerchantID, err))
+		}
+		run.Merchants++
+	}
+
+	run.Status = RetentionStatusCompleted
+	if len(failures) > 0 {
+		run.Status = RetentionStatusFailed
+		run.Error = strings.Join(failures, "; ")
+	}
+	finishedAt := s.now().UTC()
+	run.FinishedAt = &finishedAt
+	// 请求已取消时仍然保存执行结果，避免记录停留在 running
+	if err := s.retention.FinishRun(context.WithoutCancel(ctx), run); err != nil {
+		return nil, err
+	}
+	if run.ArchivedOrders > 0 {
+		log.Printf("🗄️ 订单归档 %d 完成：%d 个商户，归档 %d 笔订单", run.RunID, run.Merchants, run.ArchivedOrders)
+	}
+	return run, nil
+}
+
+// archiveMerchant 归档商户早于保留期的全部月份，从最早的月份开始
+func (s *RetentionService) archiveMerchant(ctx context.Context, run *models.RetentionRun, policy models.RetentionPolicy) error {
+	if policy.Target == RetentionTargetObject && s.store == nil {
+		return errors.New("未配置归档目录，不能归档为文件")
+	}
+	merchant, err := s.merchants.Get(policy.MerchantID)
+	if err != nil {
+		return err
+	}
+	cutoff, err := retentionCutoff(s.now(), merchant.Timezone, policy.ArchiveAfterMonths)
+	if err != nil {
+		return err
+	}
+
+	months, err := s.retention.PendingMonths(ctx, policy.MerchantID, cutoff)
+	if err != nil {
+		return err
+	}
+	for _, month := range months {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		bucket := models.RetentionBucket{MerchantID: policy.MerchantID, Month: month, Target: policy.Target}
+		if policy.Target == RetentionTargetObject {
+			err = s.archiveToObject(ctx, run.RunID, &bucket)
+		} else {
+			err = s.retention.ArchiveMonth(ctx, run.RunID, &bucket, nil)
+		}
+		if err != nil {
+			return err
+		}
+		if bucket.Orders > 0 {
+			run.Buckets = append(run.Buckets, bucket)
+			run.ArchivedOrders += bucket.Orders
+		}
+	}
+	return nil
+}
+
+// archiveToObject 先把月份内的订单写成文件，再只删除写入文件的订单
+// 写文件期间新到达的订单留到下一次归档；删除失败时移除文件，订单仍在 dws_orders 中
+func (s *RetentionService) archiveToObject(ctx context.Context, runID int64, bucket *models.RetentionBucket) error {
+	key := fmt.Sprintf("%smonth=%s/run-%d.%s", MerchantArchivePrefix(bucket.MerchantID), bucket.Month, runID, s.format)
+	orderIDs := []int{}
+	location, err := s.store.Put(ctx, key, func(w io.Writer) error {
+		writer, err := export.NewOrderWriter(w, s.format)
+		if err != nil {
+			return err
+		}
+		err = s.retention.StreamMonth(ctx, bucket.MerchantID, bucket.Month, func(order models.OrderAnalysis) error {
+			orderIDs = append(orderIDs, order.OrderID)
+			return writer.Write(order)
+		})
+		if err != nil {
+			return err
+		}
+		return writer.Close()
+	})
+	if err != nil {
+		return err
+	}
+	if len(orderIDs) == 0 {
+		return s.store.Remove(ctx, key)
+	}
+
+	bucket.Location = location
+	if err := s.retention.ArchiveMonth(ctx, runID, bucket, orderIDs); err != nil {
+		if rmErr := s.store.Remove(context.WithoutCancel(ctx), key); rmErr != nil {
+			log.Printf("⚠️ 归档失败后删除文件 %s 失败: %v", location, rmErr)
+		}
+		return err
+	}
+	// 写文件后订单全部被退款或调整引用，没有归档任何订单
+	if bucket.Orders == 0 {
+		bucket.Location = ""
+		return s.store.Remove(ctx, key)
+	}
+	return nil
+}
+
+// retentionCutoff 保留期的第一天（商户本地日期 YYYY-MM-DD）：含当月在内最近 months 个自然月的第一天
+func retentionCutoff(now time.Time, timezone string, months int) (string, error) {
+	loc, err := LoadLocation(timezone)
+	if err != nil {
+		return "", err
+	}
+	local := now.In(loc)
+	first := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return first.AddDate(0, 1-months, 0).Format("2006-01-02"), nil
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"timezone-saas-demo/models"
+)
+
+// DefaultRevenueDefinition 默认营收口径：不统计已取消订单，营收扣除退款，退款归属原订单的本地日期
+var DefaultRevenueDefinition = models.RevenueDefinition{
+	ExcludedStatuses:  []string{models.OrderStatusCancelled},
+	SubtractRefunds:   true,
+	RefundAttribution: models.RefundAttributionOrderDate,
+}
+
+// ParseStatuses 解析逗号分隔的订单状态列表，如 paid,shipped；空字符串返回 nil
+func ParseStatuses(value string) ([]string, error) {
+	var statuses []string
+	seen := map[string]bool{}
+	for _, part := range strings.Split(value, ",") {
+		status := strings.ToLower(strings.TrimSpace(part))
+		if status == "" || seen[status] {
+			continue
+		}
+		if !isOrderStatus(status) {
+			return nil, fmt.Errorf("%w: 无效的订单状态 %q，可选 %s", ErrInvalidArgument, status, strings.Join(models.OrderStatuses, ","))
+		}
+		seen[status] = true
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// SetRevenueDefinition 设置分析数据使用的营收口径，退款归属为空时按原订单日期
+func (s *TimezoneService) SetRevenueDefinition(revenue models.RevenueDefinition) error {
+	switch revenue.RefundAttribution {
+	case "":
+		revenue.RefundAttribution = models.RefundAttributionOrderDate
+	case models.RefundAttributionOrderDate, models.RefundAttributionRefundDate:
+	default:
+		return fmt.Errorf("%w: 无效的退款归属 %q，可选 %s,%s", ErrInvalidArgument, revenue.RefundAttribution,
+			models.RefundAttributionOrderDate, models.RefundAttributionRefundDate)
+	}
+	for _, status := range revenue.ExcludedStatuses {
+		if !isOrderStatus(status) {
+			return fmt.Errorf("%w: 无效的订单状态 %q", ErrInvalidArgument, status)
+		}
+	}
+	if len(countedStatuses(revenue, nil)) == 0 {
+		return fmt.Errorf("%w: 营收口径不能排除全部订单状态", ErrInvalidArgument)
+	}
+	s.revenue = revenue
+	return nil
+}
+
+// countedStatuses 参与统计的订单状态：请求指定状态时按请求统计，否则为营收口径排除之外的全部状态
+func countedStatuses(revenue models.RevenueDefinition, requested []string) []string {
+	if len(requested) > 0 {
+		return requested
+	}
+	var statuses []string
+	for _, status := range models.OrderStatuses {
+		excluded := false
+		for _, e := range revenue.ExcludedStatuses {
+			excluded = excluded || e == status
+		}
+		if !excluded {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+// applyRevenue 按营收口径选定退款归属，计算净额并确定营收
+func (s *TimezoneService) applyRevenue(total *models.CurrencyTotal) {
+	total.RefundAmount = total.RefundByOrderDate
+	if s.revenue.RefundAttribution == models.RefundAttributionRefundDate {
+		total.RefundAmount = total.RefundByRefundDate
+	}
+	total.NetAmount = total.GrossAmount.Sub(total.RefundAmount)
+	total.TotalAmount = total.GrossAmount
+	if s.revenue.SubtractRefunds {
+		total.TotalAmount = total.NetAmount
+	}
+}
+
+// isOrderStatus 是否为有效的订单状态
+func isOrderStatus(status string) bool {
+	for _, s := range models.OrderStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
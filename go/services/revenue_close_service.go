@@ -0,0 +1,267 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/repository"
+	"timezone-saas-demo/timerange"
+)
+
+// maxCloseDaysPerRun 每个商户单次最多结账的天数，历史数据较多时分多轮补齐
+const maxCloseDaysPerRun = 400
+
+// RevenueCloseService 日结服务
+// 每个商户的本地日期在本地零点过后结账，结果写入不可修改的日结快照
+type RevenueCloseService struct {
+	merchants repository.MerchantRepository
+	snapshots repository.SnapshotRepository
+}
+
+// NewRevenueCloseService 创建日结服务，使用 PostgreSQL 仓储
+func NewRevenueCloseService(db *database.DB) *RevenueCloseService {
+	return &RevenueCloseService{
+		merchants: repository.NewPostgresMerchantRepository(db),
+		snapshots: repository.NewPostgresSnapshotRepository(db),
+	}
+}
+
+// NewRevenueCloseServiceWithRepositories 使用指定仓储创建日结服务
+func NewRevenueCloseServiceWithRepositories(merchants repository.MerchantRepository, snapshots repository.SnapshotRepository) *RevenueCloseService {
+	return &RevenueCloseService{
+		merchants: merchants,
+		snapshots: snapshots,
+	}
+}
+
+// Run 周期性结账，直到 ctx 取消
+// interval 决定本地零点之后多久完成结账，默认配置为 1 分钟
+func (s *RevenueCloseService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := s.CloseDue(time.Now()); err != nil {
+			log.Printf("日结失败: %v", err)
+		} else if n > 0 {
+			log.Printf("日结完成: %d 个商户日", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CloseDue 为所有商户结账截至 at 时刻已经结束的本地日期，返回新写入的快照数
+// 从最近一次结账的次日（从未结账时为最早订单所在的本地日期）开始补齐；没有订单的商户不结账
+func (s *RevenueCloseService) CloseDue(at time.Time) (int, error) {
+	merchants, err := s.merchants.List()
+	if err != nil {
+		return 0, err
+	}
+
+	closed := 0
+	for _, merchant := range merchants {
+		n, err := s.closeMerchant(merchant, at)
+		closed += n
+		if err != nil {
+			return closed, fmt.Errorf("商户 %d 日结失败: %w", merchant.ID, err)
+		}
+	}
+	return closed, nil
+}
+
+// closeMerchant 为单个商户补齐结账
+func (s *RevenueCloseService) closeMerchant(merchant models.Merchant, at time.Time) (int, error) {
+	loc, err := LoadLocation(merchant.Timezone)
+	if err != nil {
+		return 0, err
+	}
+
+	// date 为下一个待结账的本地日期（UTC 零点表示）
+	var date time.Time
+	last, err := s.snapshots.LastClosedDate(merchant.ID)
+	if err != nil {
+		return 0, err
+	}
+	if last != "" {
+		lastDay, err := timerange.ParseDate(last)
+		if err != nil {
+			return 0, fmt.Errorf("解析结账日期失败: %w", err)
+		}
+		date = lastDay.AddDate(0, 0, 1)
+	} else {
+		earliest, err := s.snapshots.EarliestOrderTime(merchant.ID)
+		if err != nil || earliest == nil {
+			return 0, err
+		}
+		date = timerange.Today(loc, *earliest)
+	}
+
+	closed := 0
+	for i := 0; i < maxCloseDaysPerRun; i++ {
+		// 窗口为 timerange 的本地日期范围，零点因夏令时不存在时取当天第一个存在的时刻，相邻窗口首尾相接
+		day, err := timerange.Day(date.Format(timerange.Layout), loc)
+		if err != nil {
+			return closed, err
+		}
+		if day.End.After(at) {
+			break
+		}
+
+		count, amount, err := s.snapshots.WindowTotals(merchant.ID, day.Start, day.End)
+		if err != nil {
+			return closed, err
+		}
+		created, err := s.snapshots.Create(models.DailyRevenueSnapshot{
+			MerchantID:     merchant.ID,
+			LocalDate:      day.From,
+			Timezone:       merchant.Timezone,
+			WindowStartUTC: day.Start,
+			WindowEndUTC:   day.End,
+			OrderCount:     count,
+			TotalAmount:    amount,
+		})
+		if err != nil {
+			return closed, err
+		}
+		if created {
+			closed++
+		}
+		date = date.AddDate(0, 0, 1)
+	}
+	return closed, nil
+}
+
+// GetClosedAnalysis 获取指定本地日期的日结数据（读取快照，不重新计算）
+// 未结账的商户列在 Pending 中，附带该日期在商户时区的结账时刻
+func (s *RevenueCloseService) GetClosedAnalysis(date string) (*models.ClosedAnalysis, error) {
+	if _, err := parseLocalDate(date); err != nil {
+		return nil, err
+	}
+
+	snapshots, err := s.snapshots.ListByDate(date)
+	if err != nil {
+		return nil, err
+	}
+	merchants, err := s.merchants.List()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.ClosedAnalysis{
+		Date:      date,
+		Snapshots: []models.DailyRevenueSnapshot{},
+		Pending:   []models.PendingClose{},
+	}
+	closed := map[int]bool{}
+	for _, snapshot := range snapshots {
+		closed[snapshot.MerchantID] = true
+		result.ClosedMerchants++
+		result.TotalOrders += snapshot.AdjustedOrderCount
+		result.TotalAmount = result.TotalAmount.Add(snapshot.AdjustedAmount)
+		result.Snapshots = append(result.Snapshots, snapshot)
+	}
+
+	for _, merchant := range merchants {
+		if closed[merchant.ID] {
+			continue
+		}
+		loc, err := LoadLocation(merchant.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		day, err := timerange.Day(date, loc)
+		if err != nil {
+			return nil, err
+		}
+		result.Pending = append(result.Pending, models.PendingClose{
+			MerchantID:   merchant.ID,
+			MerchantName: merchant.Name,
+			Timezone:     merchant.Timezone,
+			ClosesAtUTC:  day.End,
+		})
+	}
+	return result, nil
+}
+
+// GetLateOrders 获取所属本地日期已结账后才入库的订单，date、merchantID 为空时不过滤
+func (s *RevenueCloseService) GetLateOrders(date string, merchantID int) (*models.ReconciliationReport, error) {
+	if date != "" {
+		if _, err := parseLocalDate(date); err != nil {
+			return nil, err
+		}
+	}
+
+	orders, err := s.snapshots.LateOrders(models.LateOrderFilter{LocalDate: date, MerchantID: merchantID})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.ReconciliationReport{
+		Date:       date,
+		MerchantID: merchantID,
+		LateOrders: []models.LateOrder{},
+	}
+	for _, order := range orders {
+		report.TotalLate++
+		if order.AdjustmentID == nil {
+			report.Unadjusted++
+			report.UnadjustedAmount = report.UnadjustedAmount.Add(order.Amount)
+		}
+		report.LateOrders = append(report.LateOrders, order)
+	}
+	return report, nil
+}
+
+// AdjustLateOrders 为尚未调整的迟到订单追加调整记录（每笔订单 +1 单、+订单金额），快照本身保持不变
+// 重复调用不会重复调整，actor 记录操作人
+func (s *RevenueCloseService) AdjustLateOrders(date string, merchantID int, reason, actor string) (*models.AdjustmentResult, error) {
+	if date != "" {
+		if _, err := parseLocalDate(date); err != nil {
+			return nil, err
+		}
+	}
+	if reason == "" {
+		reason = "迟到订单补录"
+	}
+	if actor == "" {
+		actor = "system"
+	}
+
+	orders, err := s.snapshots.LateOrders(models.LateOrderFilter{LocalDate: date, MerchantID: merchantID, UnadjustedOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	adjustments := make([]models.DailyRevenueAdjustment, 0, len(orders))
+	for _, order := range orders {
+		orderID := order.OrderID
+		adjustments = append(adjustments, models.DailyRevenueAdjustment{
+			SnapshotID:      order.SnapshotID,
+			MerchantID:      order.MerchantID,
+			LocalDate:       order.LocalDate,
+			OrderCountDelta: 1,
+			AmountDelta:     order.Amount,
+			Reason:          fmt.Sprintf("%s: %s", reason, order.OrderNumber),
+			OrderID:         &orderID,
+			CreatedBy:       actor,
+		})
+	}
+
+	created, err := s.snapshots.AddAdjustments(adjustments)
+	if err != nil {
+		return nil, err
+	}
+	if created == nil {
+		created = []models.DailyRevenueAdjustment{}
+	}
+	return &models.AdjustmentResult{Created: len(created), Adjustments: created}, nil
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/repository"
+	"timezone-saas-demo/timerange"
+)
+
+// 汇总表比对的默认配置
+const (
+	// DefaultRollupAuditDays 未指定日期范围时比对最近几天
+	DefaultRollupAuditDays = 7
+	// maxRollupAuditDays 一次比对的最大天数，比对需要扫描范围内的全部订单
+	maxRollupAuditDays = 92
+	// DefaultRollupAuditLimit 默认返回的差异条数
+	DefaultRollupAuditLimit = 100
+)
+
+// RollupService 订单小时汇总表（agg_orders_hourly）的比对和重建
+// 汇总表由触发器增量维护，时区规则更新（tzdata 升级）等不经过触发器的变化需要比对后重建
+type RollupService struct {
+	merchants repository.MerchantRepository
+	rollups   repository.RollupRepository
+	now       func() time.Time
+}
+
+// NewRollupService 创建汇总表服务，使用 PostgreSQL 仓储
+func NewRollupService(db *database.DB) *RollupService {
+	return NewRollupServiceWithRepositories(
+		repository.NewPostgresMerchantRepository(db),
+		repository.NewPostgresRollupRepository(db),
+	)
+}
+
+// NewRollupServiceWithRepositories 使用指定仓储创建汇总表服务
+func NewRollupServiceWithRepositories(merchants repository.MerchantRepository, rollups repository.RollupRepository) *RollupService {
+	return &RollupService{merchants: merchants, rollups: rollups, now: time.Now}
+}
+
+// Audit 比对本地日期 from~to（含两端）内汇总表与分析视图的每个小时桶
+// from/to 为空时比对截至今天（UTC）的最近 DefaultRollupAuditDays 天；limit <= 0 时使用 DefaultRollupAuditLimit
+func (s *RollupService) Audit(ctx context.Context, from, to string, limit int) (*models.RollupAudit, error) {
+	dates, err := recentDates(from, to, timerange.Today(time.UTC, s.now()), DefaultRollupAuditDays, time.UTC)
+	if err != nil {
+		return nil, err
+	}
+	if days := dates.Days(); days > maxRollupAuditDays {
+		return nil, fmt.Errorf("%w: 一次最多比对 %d 天，请求了 %d 天", ErrInvalidArgument, maxRollupAuditDays, days)
+	}
+	if limit <= 0 {
+		limit = DefaultRollupAuditLimit
+	}
+
+	audit := &models.RollupAudit{
+		From:      dates.From,
+		To:        dates.To,
+		CheckedAt: s.now(),
+	}
+	audit.Discrepancies, audit.DiscrepancyCount, err = s.rollups.Audit(ctx, audit.From, audit.To, limit)
+	if err != nil {
+		return nil, err
+	}
+	audit.Truncated = audit.DiscrepancyCount > len(audit.Discrepancies)
+	if audit.DiscrepancyCount > 0 {
+		log.Printf("⚠️ 汇总表 %s~%s 有 %d 个小时桶与分析视图不一致", audit.From, audit.To, audit.DiscrepancyCount)
+	}
+	return audit, nil
+}
+
+// Rebuild 从订单表重新计算商户的汇总，merchantID 为 0 时重建全部商户
+func (s *RollupService) Rebuild(ctx context.Context, merchantID int) (*models.RollupRebuild, error) {
+	if merchantID > 0 {
+		if _, err := s.merchants.Get(merchantID); err != nil {
+			return nil, err
+		}
+	}
+
+	start := s.now()
+	rows, err := s.rollups.Rebuild(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	result := &models.RollupRebuild{MerchantID: merchantID, Rows: rows, DurationMs: s.now().Sub(start).Milliseconds()}
+	log.Printf("🔁 汇总表已重建（商户 %d，0 表示全部）：%d 行，耗时 %dms", merchantID, rows, result.DurationMs)
+	return result, nil
+}
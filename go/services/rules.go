@@ -0,0 +1,83 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/tzdb"
+)
+
+// maxRuleTransitions 一次返回的偏移切换上限
+const maxRuleTransitions = 1000
+
+// 偏移切换类型
+const (
+	TransitionDSTStart           = "dst_start"
+	TransitionDSTEnd             = "dst_end"
+	TransitionOffsetChange       = "offset_change"
+	TransitionAbbreviationChange = "abbreviation_change"
+)
+
+// GetTimezoneRules 列出时区在 [from, to) 内的历史偏移切换，from/to 为 UTC 日期
+// from 默认 1970-01-01，to 默认明年 1 月 1 日；规则来自当前加载的 tzdata，响应中带版本信息，
+// 用于核对历史订单换算本地时间时使用的规则是否已被修订
+func (s *TimezoneService) GetTimezoneRules(timezone, fromStr, toStr string) (*models.TimezoneRules, error) {
+	loc, err := LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	from := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(now.Year()+1, 1, 1, 0, 0, 0, 0, time.UTC)
+	if fromStr != "" {
+		if from, err = parseLocalDate(fromStr); err != nil {
+			return nil, err
+		}
+	}
+	if toStr != "" {
+		if to, err = parseLocalDate(toStr); err != nil {
+			return nil, err
+		}
+	}
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: 结束日期必须晚于开始日期", ErrInvalidArgument)
+	}
+
+	transitions, truncated := tzdb.Transitions(loc, from, to, maxRuleTransitions)
+	rules := &models.TimezoneRules{
+		Timezone:    timezone,
+		From:        from.Format("2006-01-02"),
+		To:          to.Format("2006-01-02"),
+		TZData:      tzdb.Detect(),
+		Current:     tzdb.ZoneAt(now.In(loc)),
+		Count:       len(transitions),
+		Truncated:   truncated,
+		Transitions: make([]models.TimezoneTransition, 0, len(transitions)),
+	}
+	for _, t := range transitions {
+		rules.Transitions = append(rules.Transitions, models.TimezoneTransition{
+			Transition:   t,
+			Kind:         transitionKind(t),
+			DeltaSeconds: t.After.OffsetSeconds - t.Before.OffsetSeconds,
+			LocalBefore:  t.At.Add(-time.Second).In(loc).Format("2006-01-02 15:04:05"),
+			LocalAfter:   t.At.In(loc).Format("2006-01-02 15:04:05"),
+		})
+	}
+	return rules, nil
+}
+
+// transitionKind 判断切换类型
+func transitionKind(t tzdb.Transition) string {
+	switch {
+	case !t.Before.IsDST && t.After.IsDST:
+		return TransitionDSTStart
+	case t.Before.IsDST && !t.After.IsDST:
+		return TransitionDSTEnd
+	case t.Before.OffsetSeconds != t.After.OffsetSeconds:
+		return TransitionOffsetChange
+	default:
+		return TransitionAbbreviationChange
+	}
+}
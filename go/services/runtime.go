@@ -0,0 +1,92 @@
+package services
+
+import (
+	"bytes"
+	"database/sql"
+	"regexp"
+	"runtime"
+	"time"
+
+	"timezone-saas-demo/models"
+)
+
+// maxStackDump 统计 goroutine 状态时读取的堆栈上限，goroutine 过多时只统计能读到的部分，总数仍准确
+const maxStackDump = 64 << 20
+
+// goroutineHeader 堆栈中每个 goroutine 的首行，如 "goroutine 42 [IO wait, 5 minutes]:"
+var goroutineHeader = regexp.MustCompile(`(?m)^goroutine \d+ \[([^,\]]+)`)
+
+// CollectRuntime 读取进程的运行时状态，pool 为 nil 时不返回连接池统计
+// 读取内存统计和全部 goroutine 的堆栈会短暂暂停程序，不适合高频调用
+func CollectRuntime(startedAt time.Time, pool *sql.DBStats) models.RuntimeDiagnostics {
+	now := time.Now()
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	diag := models.RuntimeDiagnostics{
+		CollectedAt:       now.UTC(),
+		StartedAt:         startedAt.UTC(),
+		UptimeSeconds:     now.Sub(startedAt).Seconds(),
+		GoVersion:         runtime.Version(),
+		NumCPU:            runtime.NumCPU(),
+		GOMAXPROCS:        runtime.GOMAXPROCS(0),
+		Goroutines:        runtime.NumGoroutine(),
+		GoroutinesByState: goroutinesByState(),
+		Memory: models.MemoryStats{
+			HeapAlloc:   mem.HeapAlloc,
+			HeapInuse:   mem.HeapInuse,
+			HeapIdle:    mem.HeapIdle,
+			HeapObjects: mem.HeapObjects,
+			StackInuse:  mem.StackInuse,
+			Sys:         mem.Sys,
+			TotalAlloc:  mem.TotalAlloc,
+			Mallocs:     mem.Mallocs,
+			Frees:       mem.Frees,
+		},
+		GC: models.GCStats{
+			NumGC:         mem.NumGC,
+			NumForcedGC:   mem.NumForcedGC,
+			PauseTotalMs:  float64(mem.PauseTotalNs) / 1e6,
+			NextGCBytes:   mem.NextGC,
+			GCCPUFraction: mem.GCCPUFraction,
+		},
+	}
+	if mem.NumGC > 0 {
+		diag.GC.LastPauseMs = float64(mem.PauseNs[(mem.NumGC+255)%256]) / 1e6
+		lastGC := time.Unix(0, int64(mem.LastGC)).UTC()
+		diag.GC.LastGCAt = &lastGC
+	}
+	if pool != nil {
+		diag.DBPool = &models.DBPoolStats{
+			MaxOpenConnections: pool.MaxOpenConnections,
+			OpenConnections:    pool.OpenConnections,
+			InUse:              pool.InUse,
+			Idle:               pool.Idle,
+			WaitCount:          pool.WaitCount,
+			WaitDurationMs:     float64(pool.WaitDuration.Microseconds()) / 1000,
+			MaxIdleClosed:      pool.MaxIdleClosed,
+			MaxIdleTimeClosed:  pool.MaxIdleTimeClosed,
+			MaxLifetimeClosed:  pool.MaxLifetimeClosed,
+		}
+	}
+	return diag
+}
+
+// goroutinesByState 按堆栈首行中的状态统计 goroutine
+func goroutinesByState() map[string]int {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxStackDump {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	counts := map[string]int{}
+	for _, m := range goroutineHeader.FindAllSubmatch(buf, -1) {
+		counts[string(bytes.TrimSpace(m[1]))]++
+	}
+	return counts
+}
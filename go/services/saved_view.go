@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/repository"
+	"timezone-saas-demo/timerange"
+)
+
+// maxSavedViewMerchants 一个视图最多的商户数
+const maxSavedViewMerchants = 200
+
+// savedViewDateParams 请求中出现任意一个时不使用视图的日期范围，避免与请求的日期参数冲突
+var savedViewDateParams = []string{"date", "from", "to", "range", "dates", "month"}
+
+// SavedViewRequest 创建或修改筛选视图的请求
+type SavedViewRequest struct {
+	// Owner 创建时必填，修改时忽略
+	Owner       string   `json:"owner"`
+	Name        string   `json:"name"`
+	Timezone    string   `json:"timezone"`
+	MerchantIDs []int    `json:"merchant_ids"`
+	Statuses    []string `json:"statuses"`
+	Range       string   `json:"range"`
+}
+
+// SavedViewService 看板用户保存的筛选视图
+// 视图保存时区、商户、订单状态和相对日期范围，订单列表和分析接口通过 view_id 引用，展开为对应的查询参数；
+// 视图按请求的租户（X-Tenant-ID）隔离，同一租户内知道ID即可使用，便于分享链接
+type SavedViewService struct {
+	views repository.SavedViewRepository
+}
+
+// NewSavedViewService 创建筛选视图服务，使用 PostgreSQL 仓储
+func NewSavedViewService(db *database.DB) *SavedViewService {
+	return NewSavedViewServiceWithRepositories(repository.NewPostgresSavedViewRepository(db))
+}
+
+// NewSavedViewServiceWithRepositories 使用指定仓储创建筛选视图服务
+func NewSavedViewServiceWithRepositories(views repository.SavedViewRepository) *SavedViewService {
+	return &SavedViewService{views: views}
+}
+
+// CreateView 校验并保存 ctx 中租户的视图
+func (s *SavedViewService) CreateView(ctx context.Context, req SavedViewRequest) (*models.SavedView, error) {
+	view, err := prepareSavedView(req)
+	if err != nil {
+		return nil, err
+	}
+	view.Owner = strings.TrimSpace(req.Owner)
+	if view.Owner == "" || len([]rune(view.Owner)) > 100 {
+		return nil, fmt.Errorf("%w: owner 不能为空且不能超过 100 个字符", ErrInvalidArgument)
+	}
+	view.Tenant = TenantFromContext(ctx)
+	if err := s.views.CreateView(ctx, view); err != nil {
+		return nil, err
+	}
+	return view, nil
+}
+
+// UpdateView 覆盖视图的名称和筛选条件，owner 不变
+func (s *SavedViewService) UpdateView(ctx context.Context, id int, req SavedViewRequest) (*models.SavedView, error) {
+	view, err := prepareSavedView(req)
+	if err != nil {
+		return nil, err
+	}
+	view.ID = id
+	view.Tenant = TenantFromContext(ctx)
+	if err := s.views.UpdateView(ctx, view); err != nil {
+		return nil, err
+	}
+	return view, nil
+}
+
+// DeleteView 删除视图，已分享的链接随之失效
+func (s *SavedViewService) DeleteView(ctx context.Context, id int) error {
+	return s.views.DeleteView(ctx, TenantFromContext(ctx), id)
+}
+
+// View 单个视图，不存在或属于其他租户时返回 ErrNotFound
+func (s *SavedViewService) View(ctx context.Context, id int) (*models.SavedView, error) {
+	return s.views.View(ctx, TenantFromContext(ctx), id)
+}
+
+// Views ctx 中租户的视图，owner 不为空时只返回该用户的视图
+func (s *SavedViewService) Views(ctx context.Context, owner string) ([]models.SavedView, error) {
+	views, err := s.views.Views(ctx, TenantFromContext(ctx), strings.TrimSpace(owner))
+	if err != nil {
+		return nil, err
+	}
+	if views == nil {
+		views = []models.SavedView{}
+	}
+	return views, nil
+}
+
+// Expand 把视图的筛选条件补充到 query 中，请求中已有的参数优先：
+// timezone、merchants（逗号分隔的商户ID）、status（逗号分隔）以及日期范围，
+// today、yesterday 展开为 date，其他相对范围展开为 range；请求已指定任何日期参数时不使用视图的日期范围
+func (s *SavedViewService) Expand(ctx context.Context, id int, query url.Values) (*models.SavedView, error) {
+	view, err := s.View(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	setDefault := func(key, value string) {
+		if value != "" && query.Get(key) == "" {
+			query.Set(key, value)
+		}
+	}
+	setDefault("timezone", view.Timezone)
+	if len(view.MerchantIDs) > 0 {
+		ids := make([]string, len(view.MerchantIDs))
+		for i, merchantID := range view.MerchantIDs {
+			ids[i] = strconv.Itoa(merchantID)
+		}
+		setDefault("merchants", strings.Join(ids, ","))
+	}
+	setDefault("status", strings.Join(view.Statuses, ","))
+	if view.Range != "" {
+		for _, key := range savedViewDateParams {
+			if query.Get(key) != "" {
+				return view, nil
+			}
+		}
+		switch view.Range {
+		case "today", "yesterday":
+			query.Set("date", view.Range)
+		default:
+			query.Set("range", view.Range)
+		}
+	}
+	return view, nil
+}
+
+// prepareSavedView 校验请求并生成视图（不含ID、租户和 owner）
+func prepareSavedView(req SavedViewRequest) (*models.SavedView, error) {
+	view := &models.SavedView{
+		Name:     strings.TrimSpace(req.Name),
+		Timezone: strings.TrimSpace(req.Timezone),
+		Range:    strings.ToLower(strings.TrimSpace(req.Range)),
+	}
+	if view.Name == "" || len([]rune(view.Name)) > 100 {
+		return nil, fmt.Errorf("%w: name 不能为空且不能超过 100 个字符", ErrInvalidArgument)
+	}
+	if view.Timezone != "" {
+		if _, err := LoadLocation(view.Timezone); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(req.MerchantIDs) > maxSavedViewMerchants {
+		return nil, fmt.Errorf("%w: merchant_ids 最多 %d 个", ErrInvalidArgument, maxSavedViewMerchants)
+	}
+	view.MerchantIDs = []int{}
+	seen := make(map[int]bool, len(req.MerchantIDs))
+	for _, id := range req.MerchantIDs {
+		if id <= 0 {
+			return nil, fmt.Errorf("%w: 无效的商户ID %d", ErrInvalidArgument, id)
+		}
+		if !seen[id] {
+			seen[id] = true
+			view.MerchantIDs = append(view.MerchantIDs, id)
+		}
+	}
+
+	statuses, err := ParseStatuses(strings.Join(req.Statuses, ","))
+	if err != nil {
+		return nil, err
+	}
+	if statuses == nil {
+		statuses = []string{}
+	}
+	view.Statuses = statuses
+
+	// 相对范围在使用时按当天计算，这里只校验名称
+	if view.Range != "" {
+		if _, err := timerange.Relative(view.Range, time.UTC, time.Now()); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+		}
+	}
+	return view, nil
+}
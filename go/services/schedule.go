@@ -0,0 +1,317 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/schedule"
+	"timezone-saas-demo/timerange"
+)
+
+const (
+	// maxScheduleRangeDays 单次展开允许的最大日期范围
+	maxScheduleRangeDays = 366
+	// defaultScheduleLimit 默认最多返回的发生次数
+	defaultScheduleLimit = 500
+	// maxScheduleLimit 最多返回的发生次数
+	maxScheduleLimit = 5000
+	// defaultSchedulePreviewCount 定时规则预览默认返回的发生次数
+	defaultSchedulePreviewCount = 10
+	// maxSchedulePreviewCount 定时规则预览最多返回的发生次数
+	maxSchedulePreviewCount = 100
+)
+
+// ScheduleRequest 重复规则展开参数
+type ScheduleRequest struct {
+	// Rule 类 RRULE 规则，如 FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR;BYHOUR=9
+	Rule string
+	// MerchantID 大于 0 时使用商户时区，否则使用 Timezone
+	MerchantID int
+	Timezone   string
+	// From、To 为本地日期 YYYY-MM-DD，包含两端；也可以用 Range 指定相对范围（如 this_month），按该时区的今天计算
+	From  string
+	To    string
+	Range string
+	// Gap、Overlap 夏令时跳过/重复时的处理策略，见 schedule.GapPolicy、schedule.OverlapPolicy
+	Gap     string
+	Overlap string
+	Limit   int
+}
+
+// ExpandSchedule 按商户（或指定时区）的本地时间展开重复规则，返回具体的 UTC 时刻
+func (s *TimezoneService) ExpandSchedule(req ScheduleRequest) (*models.ScheduleExpansion, error) {
+	rule, err := schedule.Parse(req.Rule)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	gap, err := schedule.ParseGapPolicy(req.Gap)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	overlap, err := schedule.ParseOverlapPolicy(req.Overlap)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	if req.Limit <= 0 {
+		req.Limit = defaultScheduleLimit
+	}
+	if req.Limit > maxScheduleLimit {
+		req.Limit = maxScheduleLimit
+	}
+
+	timezone := req.Timezone
+	if req.MerchantID > 0 {
+		merchant, err := s.merchants.Get(req.MerchantID)
+		if err != nil {
+			return nil, err
+		}
+		timezone = merchant.Timezone
+	}
+	if timezone == "" {
+		return nil, fmt.Errorf("%w: 必须指定商户或时区", ErrInvalidArgument)
+	}
+	loc, err := LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	dates, err := timerange.Parse(timerange.Params{From: req.From, To: req.To, Range: req.Range}, loc, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	if dates.IsZero() {
+		return nil, fmt.Errorf("%w: 需要指定 from/to 或 range", ErrInvalidArgument)
+	}
+	if dates.Days() > maxScheduleRangeDays {
+		return nil, fmt.Errorf("%w: 日期范围不能超过 %d 天", ErrInvalidArgument, maxScheduleRangeDays)
+	}
+
+	// 结束日期包含在内，区间为 From 当天的第一个时刻至 To 次日的第一个时刻
+	occurrences, truncated, err := schedule.Expand(rule, loc, dates.Start.In(loc), dates.End.In(loc), schedule.Options{
+		Gap:     gap,
+		Overlap: overlap,
+		Limit:   req.Limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+
+	result := &models.ScheduleExpansion{
+		Rule:        rule.String(),
+		MerchantID:  req.MerchantID,
+		Timezone:    timezone,
+		From:        dates.From,
+		To:          dates.To,
+		GapPolicy:   string(gap),
+		Overlap:     string(overlap),
+		Count:       len(occurrences),
+		Truncated:   truncated,
+		Occurrences: make([]models.ScheduleOccurrence, 0, len(occurrences)),
+	}
+	for _, occ := range occurrences {
+		_, offset := occ.Time.Zone()
+		result.Occurrences = append(result.Occurrences, models.ScheduleOccurrence{
+			UTC:       occ.Time.UTC(),
+			Local:     occ.Time.Format("2006-01-02 15:04:05"),
+			Wall:      occ.Wall,
+			Offset:    formatOffset(offset),
+			Shifted:   occ.Shifted,
+			Ambiguous: occ.Ambiguous,
+		})
+	}
+	return result, nil
+}
+
+// SchedulePreviewRequest 定时规则预览参数
+type SchedulePreviewRequest struct {
+	// Expression cron 表达式（可带 CRON_TZ=<时区> 或 CRON_TZ=@merchant_local）或类 RRULE 规则
+	Expression string
+	// MerchantID 大于 0 时 @merchant_local 使用该商户的时区；表达式没有 CRON_TZ 且没有指定 Timezone 时也使用商户时区
+	MerchantID int
+	// Timezone 表达式没有 CRON_TZ 时使用的时区，缺省为商户时区或 UTC
+	Timezone string
+	// After 从该时刻之后开始，零值为当前时刻
+	After time.Time
+	Count int
+}
+
+// PreviewSchedule 校验定时规则并按报表和定时计划的执行方式逐次推算接下来的发生时刻，同时给出 UTC 和本地时间
+func (s *TimezoneService) PreviewSchedule(req SchedulePreviewRequest) (*models.SchedulePreview, error) {
+	if strings.TrimSpace(req.Expression) == "" {
+		return nil, fmt.Errorf("%w: 必须指定定时规则 expr", ErrInvalidArgument)
+	}
+	if req.Count <= 0 {
+		req.Count = defaultSchedulePreviewCount
+	}
+	if req.Count > maxSchedulePreviewCount {
+		req.Count = maxSchedulePreviewCount
+	}
+	if req.After.IsZero() {
+		req.After = time.Now()
+	}
+
+	var merchant *models.Merchant
+	if req.MerchantID > 0 {
+		m, err := s.merchants.Get(req.MerchantID)
+		if err != nil {
+			return nil, err
+		}
+		merchant = m
+	}
+	var merchantTimezone func() (string, error)
+	if merchant != nil {
+		merchantTimezone = func() (string, error) { return merchant.Timezone, nil }
+	}
+	spec, err := parseScheduleSpec(req.Expression, merchantTimezone)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &models.SchedulePreview{
+		Expression:     spec.String(),
+		Kind:           "rrule",
+		MerchantID:     req.MerchantID,
+		TimezoneSource: "default",
+		Timezone:       "UTC",
+		After:          req.After.UTC().Truncate(time.Second),
+		Occurrences:    []models.ScheduleOccurrence{},
+	}
+	if spec.cron != nil {
+		preview.Kind = "cron"
+	}
+	switch {
+	case spec.loc != nil:
+		preview.TimezoneSource, preview.Timezone = "cron_tz", spec.loc.String()
+	case req.Timezone != "":
+		preview.TimezoneSource, preview.Timezone = "request", req.Timezone
+	case merchant != nil:
+		preview.TimezoneSource, preview.Timezone = "merchant", merchant.Timezone
+	}
+	loc, err := LoadLocation(preview.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	after := preview.After
+	for len(preview.Occurrences) < req.Count {
+		occ, err := spec.occurrence(loc, after)
+		if err != nil {
+			return nil, err
+		}
+		if occ == nil {
+			break
+		}
+		_, offset := occ.Time.Zone()
+		preview.Occurrences = append(preview.Occurrences, models.ScheduleOccurrence{
+			UTC:       occ.Time.UTC(),
+			Local:     occ.Time.Format("2006-01-02 15:04:05"),
+			Wall:      occ.Wall,
+			Offset:    formatOffset(offset),
+			Shifted:   occ.Shifted,
+			Ambiguous: occ.Ambiguous,
+		})
+		after = occ.Time
+	}
+	if len(preview.Occurrences) == 0 {
+		return nil, fmt.Errorf("%w: 定时规则 %s 在 %s 之后没有发生", ErrInvalidArgument, preview.Expression, preview.After.Format(time.RFC3339))
+	}
+	preview.Count = len(preview.Occurrences)
+	return preview, nil
+}
+
+// scheduleSpec 定时报表和定时计划的规则：类 RRULE 规则或 cron 表达式（可带 CRON_TZ= 指定时区）
+type scheduleSpec struct {
+	rule *schedule.Rule
+	cron *schedule.Cron
+	// loc cron 表达式 CRON_TZ 指定的时区，为 nil 时使用调用方的时区
+	loc *time.Location
+}
+
+// parseScheduleSpec 解析定时规则；CRON_TZ=@merchant_local 使用 merchantTimezone，
+// merchantTimezone 为 nil 表示没有关联商户，此时不支持 @merchant_local
+func parseScheduleSpec(value string, merchantTimezone func() (string, error)) (*scheduleSpec, error) {
+	value = strings.TrimSpace(value)
+	if !schedule.IsCron(value) {
+		rule, err := schedule.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+		}
+		return &scheduleSpec{rule: rule}, nil
+	}
+	cron, err := schedule.ParseCron(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	spec := &scheduleSpec{cron: cron}
+	switch cron.Timezone {
+	case "":
+	case schedule.MerchantLocal:
+		if merchantTimezone == nil {
+			return nil, fmt.Errorf("%w: 没有关联商户，不能使用 CRON_TZ=%s", ErrInvalidArgument, schedule.MerchantLocal)
+		}
+		timezone, err := merchantTimezone()
+		if err != nil {
+			return nil, err
+		}
+		if spec.loc, err = LoadLocation(timezone); err != nil {
+			return nil, err
+		}
+	default:
+		if spec.loc, err = LoadLocation(cron.Timezone); err != nil {
+			return nil, err
+		}
+	}
+	return spec, nil
+}
+
+// String 规范化的规则
+func (s *scheduleSpec) String() string {
+	if s.cron != nil {
+		return s.cron.String()
+	}
+	return s.rule.String()
+}
+
+// in 规则实际使用的时区：cron 表达式指定了 CRON_TZ 时为该时区，否则为 loc
+func (s *scheduleSpec) in(loc *time.Location) *time.Location {
+	if s.loc != nil {
+		return s.loc
+	}
+	return loc
+}
+
+// next 规则在 after 之后的第一次发生（UTC），没有发生时返回 nil
+func (s *scheduleSpec) next(loc *time.Location, after time.Time) (*time.Time, error) {
+	occ, err := s.occurrence(loc, after)
+	if err != nil || occ == nil {
+		return nil, err
+	}
+	next := occ.Time.UTC()
+	return &next, nil
+}
+
+// occurrence 规则在 after 之后的第一次发生，夏令时跳过时顺延、重复时取前一次；没有发生时返回 nil
+// 类 RRULE 规则以 after 所在的本地日期为起点在一年内查找，cron 表达式按 schedule.Cron.Next 查找
+func (s *scheduleSpec) occurrence(loc *time.Location, after time.Time) (*schedule.Occurrence, error) {
+	loc = s.in(loc)
+	if s.cron != nil {
+		occ, ok := s.cron.Next(loc, after, schedule.Options{})
+		if !ok {
+			return nil, nil
+		}
+		return &occ, nil
+	}
+	from := timerange.StartOfDay(timerange.Today(loc, after), loc)
+	occurrences, _, err := schedule.Expand(s.rule, loc, from, from.AddDate(0, 0, maxScheduleRangeDays), schedule.Options{Limit: defaultScheduleLimit})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	for _, occ := range occurrences {
+		if occ.Time.After(after) {
+			return &occ, nil
+		}
+	}
+	return nil, nil
+}
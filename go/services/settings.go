@@ -0,0 +1,548 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/locale"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/money"
+	"timezone-saas-demo/repository"
+)
+
+// settingsCacheTTL 商户配置的缓存时间，多实例部署时其他实例的修改最迟在该时间后生效
+const settingsCacheTTL = 30 * time.Second
+
+// SettingKey 类型化的配置键，T 为配置值解码后的类型
+type SettingKey[T any] struct {
+	Name string
+}
+
+// BusinessHoursSetting 营业时间配置（商户本地时间 HH:MM），结束时间不晚于开始时间表示跨午夜
+type BusinessHoursSetting struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// ReportSchedule 报表发送计划，时间为商户本地时间
+type ReportSchedule struct {
+	DailyEnabled  bool   `json:"daily_enabled"`
+	DailyTime     string `json:"daily_time"`
+	WeeklyEnabled bool   `json:"weekly_enabled"`
+	// WeekStartDay 周报统计周起始日，ISO 星期：1=周一 ... 7=周日
+	WeekStartDay int `json:"week_start_day"`
+}
+
+// Holiday 商户的节假日（商户本地日期 YYYY-MM-DD），当天不营业
+type Holiday struct {
+	Date string `json:"date"`
+	Name string `json:"name"`
+}
+
+// maxHolidays 节假日配置的最大条数
+const maxHolidays = 400
+
+// DefaultReportSchedule 默认报表计划，与 merchant_report_settings 的列默认值一致
+var DefaultReportSchedule = ReportSchedule{DailyEnabled: true, DailyTime: "08:00", WeeklyEnabled: true, WeekStartDay: 1}
+
+// DefaultCurrency 未设置币种偏好时使用，与订单币种的缺省值一致
+const DefaultCurrency = "USD"
+
+// DefaultOrderConflictPolicy 未设置冲突策略时使用：平台的后续事件（支付、发货、退款）覆盖已有订单
+const DefaultOrderConflictPolicy = models.OrderConflictUpsert
+
+// 支持的配置键
+var (
+	// SettingBusinessHours 营业时间，同步到 dim_merchant.business_hours_start / business_hours_end
+	SettingBusinessHours = SettingKey[BusinessHoursSetting]{Name: "business_hours"}
+	// SettingWeekendDays 周末的星期序号（0=周日 … 6=周六），同步到 dim_merchant.weekend_days
+	SettingWeekendDays = SettingKey[[]int]{Name: "weekend_days"}
+	// SettingLocale 报表和通知使用的语言
+	SettingLocale = SettingKey[string]{Name: "locale"}
+	// SettingReportSchedule 日报和周报的发送计划
+	SettingReportSchedule = SettingKey[ReportSchedule]{Name: "report_schedule"}
+	// SettingCurrency 展示金额时偏好的币种（ISO 4217）
+	SettingCurrency = SettingKey[string]{Name: "currency"}
+	// SettingOrderConflictPolicy Webhook 写入的订单号在该商户下已存在时的处理方式（reject / upsert / version）
+	SettingOrderConflictPolicy = SettingKey[string]{Name: "order_conflict_policy"}
+	// SettingHolidays 商户的节假日，日历订阅中当天不显示营业时间
+	SettingHolidays = SettingKey[[]Holiday]{Name: "holidays"}
+)
+
+// settingDef 配置键的默认值、校验和同步方式
+type settingDef struct {
+	// defaultValue 未单独设置时的值
+	defaultValue func(merchant models.Merchant) (any, error)
+	// normalize 解码并校验配置值，返回规范化后的值
+	normalize func(raw json.RawMessage) (any, error)
+	// calendar 不为 nil 时配置同步到商户的营业时间和周末列，value 为 nil 表示恢复默认值
+	calendar func(merchant models.Merchant, value any) (*models.MerchantCalendar, error)
+}
+
+var settingDefs = map[string]settingDef{
+	SettingBusinessHours.Name: defineSetting(SettingBusinessHours,
+		func(m models.Merchant) (BusinessHoursSetting, error) {
+			hours, err := MerchantBusinessHours(m)
+			if err != nil {
+				return BusinessHoursSetting{}, err
+			}
+			return BusinessHoursSetting{Start: formatClock(hours.Start), End: formatClock(hours.End)}, nil
+		},
+		func(v BusinessHoursSetting) (BusinessHoursSetting, error) {
+			if v.Start == "" || v.End == "" {
+				return v, fmt.Errorf("%w: 营业时间必须同时指定 start 和 end", ErrInvalidArgument)
+			}
+			hours, err := ParseBusinessHours(v.Start, v.End)
+			if err != nil {
+				return v, err
+			}
+			return BusinessHoursSetting{Start: formatClock(hours.Start), End: formatClock(hours.End)}, nil
+		},
+		func(m models.Merchant, v *BusinessHoursSetting) (*models.MerchantCalendar, error) {
+			calendar, err := merchantCalendar(m)
+			if err != nil {
+				return nil, err
+			}
+			calendar.BusinessHoursStart = formatClock(DefaultBusinessHours.Start)
+			calendar.BusinessHoursEnd = formatClock(DefaultBusinessHours.End)
+			if v != nil {
+				calendar.BusinessHoursStart, calendar.BusinessHoursEnd = v.Start, v.End
+			}
+			return calendar, nil
+		},
+	),
+	SettingWeekendDays.Name: defineSetting(SettingWeekendDays,
+		func(m models.Merchant) ([]int, error) {
+			weekend, err := MerchantWeekend(m)
+			if err != nil {
+				return nil, err
+			}
+			return weekend.Days(), nil
+		},
+		func(v []int) ([]int, error) {
+			if v == nil {
+				return nil, fmt.Errorf("%w: 周末不能为 null，没有周末请使用空数组", ErrInvalidArgument)
+			}
+			weekend, err := ParseWeekendDays(v)
+			if err != nil {
+				return nil, err
+			}
+			return weekend.Days(), nil
+		},
+		func(m models.Merchant, v *[]int) (*models.MerchantCalendar, error) {
+			calendar, err := merchantCalendar(m)
+			if err != nil {
+				return nil, err
+			}
+			// 恢复默认时按国家取默认周末，与 sql/12_merchant_weekend.sql 的回填一致
+			calendar.WeekendDays = CountryWeekend(m.Country).Days()
+			if v != nil {
+				calendar.WeekendDays = *v
+			}
+			return calendar, nil
+		},
+	),
+	SettingLocale.Name: defineSetting(SettingLocale,
+		func(models.Merchant) (string, error) { return locale.Default.Code(), nil },
+		func(v string) (string, error) {
+			for _, code := range locale.Supported() {
+				if strings.EqualFold(v, code) {
+					return code, nil
+				}
+			}
+			return "", fmt.Errorf("%w: 不支持的语言 %q，可选 %s", ErrInvalidArgument, v, strings.Join(locale.Supported(), "、"))
+		},
+		nil,
+	),
+	SettingReportSchedule.Name: defineSetting(SettingReportSchedule,
+		func(models.Merchant) (ReportSchedule, error) { return DefaultReportSchedule, nil },
+		func(v ReportSchedule) (ReportSchedule, error) {
+			minutes, err := parseClock(v.DailyTime)
+			if err != nil {
+				return v, err
+			}
+			if v.WeekStartDay < 1 || v.WeekStartDay > 7 {
+				return v, fmt.Errorf("%w: 周起始日应为 1（周一）~7（周日），得到 %d", ErrInvalidArgument, v.WeekStartDay)
+			}
+			v.DailyTime = formatClock(minutes)
+			return v, nil
+		},
+		nil,
+	),
+	SettingCurrency.Name: defineSetting(SettingCurrency,
+		func(models.Merchant) (string, error) { return DefaultCurrency, nil },
+		func(v string) (string, error) {
+			code, err := money.ParseCode(v)
+			if err != nil {
+				return "", fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+			}
+			return code, nil
+		},
+		nil,
+	),
+	SettingOrderConflictPolicy.Name: defineSetting(SettingOrderConflictPolicy,
+		func(models.Merchant) (string, error) { return DefaultOrderConflictPolicy, nil },
+		func(v string) (string, error) {
+			for _, policy := range models.OrderConflictPolicies {
+				if strings.EqualFold(v, policy) {
+					return policy, nil
+				}
+			}
+			return "", fmt.Errorf("%w: 不支持的冲突策略 %q，可选 %s", ErrInvalidArgument, v, strings.Join(models.OrderConflictPolicies, "、"))
+		},
+		nil,
+	),
+	SettingHolidays.Name: defineSetting(SettingHolidays,
+		func(models.Merchant) ([]Holiday, error) { return []Holiday{}, nil },
+		func(v []Holiday) ([]Holiday, error) {
+			if v == nil {
+				return nil, fmt.Errorf("%w: 节假日不能为 null，没有节假日请使用空数组", ErrInvalidArgument)
+			}
+			if len(v) > maxHolidays {
+				return nil, fmt.Errorf("%w: 节假日最多 %d 个，得到 %d 个", ErrInvalidArgument, maxHolidays, len(v))
+			}
+			seen := make(map[string]bool, len(v))
+			holidays := make([]Holiday, 0, len(v))
+			for _, h := range v {
+				date, err := time.Parse("2006-01-02", strings.TrimSpace(h.Date))
+				if err != nil {
+					return nil, fmt.Errorf("%w: 节假日日期应为 YYYY-MM-DD，得到 %q", ErrInvalidArgument, h.Date)
+				}
+				h.Date = date.Format("2006-01-02")
+				if seen[h.Date] {
+					return nil, fmt.Errorf("%w: 节假日日期重复: %s", ErrInvalidArgument, h.Date)
+				}
+				seen[h.Date] = true
+				h.Name = strings.TrimSpace(h.Name)
+				if h.Name == "" {
+					return nil, fmt.Errorf("%w: 节假日 %s 缺少名称", ErrInvalidArgument, h.Date)
+				}
+				holidays = append(holidays, h)
+			}
+			sort.Slice(holidays, func(i, j int) bool { return holidays[i].Date < holidays[j].Date })
+			return holidays, nil
+		},
+		nil,
+	),
+}
+
+// defineSetting 由类型化的默认值、校验和同步函数构造配置键定义
+func defineSetting[T any](
+	key SettingKey[T],
+	defaultValue func(models.Merchant) (T, error),
+	normalize func(T) (T, error),
+	calendar func(models.Merchant, *T) (*models.MerchantCalendar, error),
+) settingDef {
+	def := settingDef{
+		defaultValue: func(m models.Merchant) (any, error) { return defaultValue(m) },
+		normalize: func(raw json.RawMessage) (any, error) {
+			var value T
+			decoder := json.NewDecoder(bytes.NewReader(raw))
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&value); err != nil {
+				return nil, fmt.Errorf("%w: 配置 %s 的值格式错误: %v", ErrInvalidArgument, key.Name, err)
+			}
+			return normalize(value)
+		},
+	}
+	if calendar != nil {
+		def.calendar = func(m models.Merchant, value any) (*models.MerchantCalendar, error) {
+			if value == nil {
+				return calendar(m, nil)
+			}
+			v := value.(T)
+			return calendar(m, &v)
+		}
+	}
+	return def
+}
+
+// merchantCalendar 商户当前的营业时间和周末
+func merchantCalendar(m models.Merchant) (*models.MerchantCalendar, error) {
+	hours, err := MerchantBusinessHours(m)
+	if err != nil {
+		return nil, err
+	}
+	return &models.MerchantCalendar{
+		BusinessHoursStart: formatClock(hours.Start),
+		BusinessHoursEnd:   formatClock(hours.End),
+		WeekendDays:        hours.Weekend.Days(),
+	}, nil
+}
+
+// SettingKeys 支持的配置键，按名称排序
+func SettingKeys() []string {
+	keys := make([]string, 0, len(settingDefs))
+	for key := range settingDefs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SettingsService 商户（租户）配置服务
+// 配置按商户缓存 settingsCacheTTL，本实例的修改立即生效；修改后依次通知订阅者
+type SettingsService struct {
+	settings  repository.SettingsRepository
+	merchants repository.MerchantRepository
+	now       func() time.Time
+
+	mu          sync.Mutex
+	cache       map[int]*settingsEntry
+	subscribers []func(models.SettingChange)
+}
+
+// settingsEntry 一个商户的缓存：商户信息（用于计算默认值）和单独设置过的配置
+type settingsEntry struct {
+	merchant models.Merchant
+	stored   map[string]models.TenantSetting
+	loadedAt time.Time
+}
+
+// NewSettingsService 创建配置服务，使用 PostgreSQL 仓储
+func NewSettingsService(db *database.DB) *SettingsService {
+	return NewSettingsServiceWithRepositories(
+		repository.NewPostgresSettingsRepository(db),
+		repository.NewPostgresMerchantRepository(db),
+	)
+}
+
+// NewSettingsServiceWithRepositories 使用指定仓储创建配置服务
+func NewSettingsServiceWithRepositories(settings repository.SettingsRepository, merchants repository.MerchantRepository) *SettingsService {
+	return &SettingsService{
+		settings:  settings,
+		merchants: merchants,
+		now:       time.Now,
+		cache:     map[int]*settingsEntry{},
+	}
+}
+
+// Subscribe 注册配置变更回调，回调在修改成功后同步执行
+func (s *SettingsService) Subscribe(fn func(models.SettingChange)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribers = append(s.subscribers, fn)
+}
+
+// List 商户的全部配置（含默认值），按键排序
+func (s *SettingsService) List(merchantID int) ([]models.TenantSetting, error) {
+	entry, err := s.load(merchantID)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := make([]models.TenantSetting, 0, len(settingDefs))
+	for _, key := range SettingKeys() {
+		setting, err := resolveSetting(entry, key)
+		if err != nil {
+			return nil, err
+		}
+		settings = append(settings, setting)
+	}
+	return settings, nil
+}
+
+// Get 商户的一项配置，未单独设置时返回默认值
+func (s *SettingsService) Get(merchantID int, key string) (*models.TenantSetting, error) {
+	if _, err := lookupSetting(key); err != nil {
+		return nil, err
+	}
+	entry, err := s.load(merchantID)
+	if err != nil {
+		return nil, err
+	}
+	setting, err := resolveSetting(entry, key)
+	if err != nil {
+		return nil, err
+	}
+	return &setting, nil
+}
+
+// Set 校验并保存商户的一项配置，值按配置键的类型规范化后保存
+func (s *SettingsService) Set(merchantID int, key string, raw json.RawMessage, operator string) (*models.TenantSetting, error) {
+	def, err := lookupSetting(key)
+	if err != nil {
+		return nil, err
+	}
+	value, err := def.normalize(raw)
+	if err != nil {
+		return nil, err
+	}
+	normalized, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("序列化配置 %s 失败: %w", key, err)
+	}
+
+	entry, err := s.load(merchantID)
+	if err != nil {
+		return nil, err
+	}
+	old, err := resolveSetting(entry, key)
+	if err != nil {
+		return nil, err
+	}
+	var calendar *models.MerchantCalendar
+	if def.calendar != nil {
+		if calendar, err = def.calendar(entry.merchant, value); err != nil {
+			return nil, err
+		}
+	}
+
+	setting := &models.TenantSetting{MerchantID: merchantID, Key: key, Value: normalized, UpdatedBy: operatorOrSystem(operator)}
+	if err := s.settings.Save(setting, calendar); err != nil {
+		return nil, err
+	}
+	s.invalidate(merchantID)
+	s.publish(models.SettingChange{
+		MerchantID: merchantID, Key: key, Old: old.Value, New: setting.Value,
+		ChangedBy: setting.UpdatedBy, ChangedAt: s.now().UTC(),
+	})
+	return setting, nil
+}
+
+// Reset 删除商户单独设置的配置，返回恢复后的默认值
+func (s *SettingsService) Reset(merchantID int, key string, operator string) (*models.TenantSetting, error) {
+	def, err := lookupSetting(key)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := s.load(merchantID)
+	if err != nil {
+		return nil, err
+	}
+	old, err := resolveSetting(entry, key)
+	if err != nil {
+		return nil, err
+	}
+	var calendar *models.MerchantCalendar
+	if def.calendar != nil {
+		if calendar, err = def.calendar(entry.merchant, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.settings.Delete(merchantID, key, calendar); err != nil {
+		return nil, err
+	}
+	s.invalidate(merchantID)
+
+	// 营业时间和周末的默认值取自商户的列，需要重新加载
+	setting, err := s.Get(merchantID, key)
+	if err != nil {
+		return nil, err
+	}
+	s.publish(models.SettingChange{
+		MerchantID: merchantID, Key: key, Old: old.Value, New: setting.Value, Reset: true,
+		ChangedBy: operatorOrSystem(operator), ChangedAt: s.now().UTC(),
+	})
+	return setting, nil
+}
+
+// GetSetting 读取商户的一项配置并解码为配置键的类型
+func GetSetting[T any](s *SettingsService, merchantID int, key SettingKey[T]) (T, error) {
+	var value T
+	setting, err := s.Get(merchantID, key.Name)
+	if err != nil {
+		return value, err
+	}
+	if err := json.Unmarshal(setting.Value, &value); err != nil {
+		return value, fmt.Errorf("解析配置 %s 失败: %w", key.Name, err)
+	}
+	return value, nil
+}
+
+// load 读取商户及其配置，优先使用未过期的缓存
+func (s *SettingsService) load(merchantID int) (*settingsEntry, error) {
+	s.mu.Lock()
+	entry, ok := s.cache[merchantID]
+	s.mu.Unlock()
+	if ok && s.now().Sub(entry.loadedAt) < settingsCacheTTL {
+		return entry, nil
+	}
+
+	merchant, err := s.merchants.Get(merchantID)
+	if err == nil {
+		var stored []models.TenantSetting
+		stored, err = s.settings.List(merchantID)
+		if err == nil {
+			return s.store(merchantID, merchant, stored), nil
+		}
+	}
+	// 数据库不可用时继续使用过期的缓存，避免数据库短暂重启期间所有依赖配置的接口失败
+	if ok && database.IsConnectionError(err) {
+		log.Printf("⚠️ 数据库不可用，商户 %d 使用过期的配置缓存: %v", merchantID, err)
+		return entry, nil
+	}
+	return nil, err
+}
+
+// store 缓存商户及其配置
+func (s *SettingsService) store(merchantID int, merchant *models.Merchant, stored []models.TenantSetting) *settingsEntry {
+	entry := &settingsEntry{merchant: *merchant, stored: map[string]models.TenantSetting{}, loadedAt: s.now()}
+	for _, setting := range stored {
+		entry.stored[setting.Key] = setting
+	}
+
+	s.mu.Lock()
+	s.cache[merchantID] = entry
+	s.mu.Unlock()
+	return entry
+}
+
+// invalidate 丢弃商户的缓存
+func (s *SettingsService) invalidate(merchantID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.cache, merchantID)
+}
+
+// publish 通知订阅者
+func (s *SettingsService) publish(change models.SettingChange) {
+	s.mu.Lock()
+	subscribers := make([]func(models.SettingChange), len(s.subscribers))
+	copy(subscribers, s.subscribers)
+	s.mu.Unlock()
+	for _, fn := range subscribers {
+		fn(change)
+	}
+}
+
+// lookupSetting 查找配置键定义，未知的键返回 ErrNotFound
+func lookupSetting(key string) (settingDef, error) {
+	def, ok := settingDefs[key]
+	if !ok {
+		return settingDef{}, fmt.Errorf("%w: 未知的配置项 %q，可选 %s", ErrNotFound, key, strings.Join(SettingKeys(), "、"))
+	}
+	return def, nil
+}
+
+// resolveSetting 商户单独设置的值，未设置时计算默认值
+func resolveSetting(entry *settingsEntry, key string) (models.TenantSetting, error) {
+	if setting, ok := entry.stored[key]; ok {
+		return setting, nil
+	}
+	value, err := settingDefs[key].defaultValue(entry.merchant)
+	if err != nil {
+		return models.TenantSetting{}, err
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return models.TenantSetting{}, fmt.Errorf("序列化配置 %s 失败: %w", key, err)
+	}
+	return models.TenantSetting{MerchantID: entry.merchant.ID, Key: key, Value: raw, IsDefault: true}, nil
+}
+
+// operatorOrSystem 未指定操作人时记为 system，与 tenant_settings.updated_by 的默认值一致
+func operatorOrSystem(operator string) string {
+	if operator = strings.TrimSpace(operator); operator != "" {
+		return operator
+	}
+	return "system"
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"timezone-saas-demo/geo"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/money"
+)
+
+// 订单流模拟的默认值
+const (
+	// DefaultSimulatorAmount 订单金额的中位数（商户币种），小数位为 0 的币种（JPY、KRW 等）乘以 100
+	DefaultSimulatorAmount = 50.0
+	// simulatorAmountSigma 订单金额对数正态分布的标准差
+	simulatorAmountSigma = 0.6
+	// simulatorWeekendFactor 商户周末的下单量相对工作日的倍数
+	simulatorWeekendFactor = 1.25
+)
+
+// simulatorHourlyWeights 本地时间各小时的相对下单量：凌晨最低，午间和晚间两个高峰
+var simulatorHourlyWeights = [24]float64{
+	0.15, 0.08, 0.05, 0.04, 0.05, 0.10, 0.25, 0.50, 0.80, 1.00, 1.10, 1.30,
+	1.50, 1.30, 1.10, 1.10, 1.20, 1.40, 1.60, 1.70, 1.50, 1.10, 0.70, 0.35,
+}
+
+// simulatorStatuses 生成订单的状态及其累计概率
+var simulatorStatuses = []struct {
+	status     string
+	cumulative float64
+}{
+	{models.OrderStatusPaid, 0.85},
+	{models.OrderStatusPending, 0.93},
+	{models.OrderStatusShipped, 0.98},
+	{models.OrderStatusCancelled, 1},
+}
+
+// simulatorMerchant 参与模拟的商户
+type simulatorMerchant struct {
+	merchant models.Merchant
+	loc      *time.Location
+	weekend  Weekend
+	currency string
+}
+
+// OrderSimulator 合成订单流：每个商户按本地时间的昼夜曲线（周末略高）以泊松过程下单，
+// 全部商户全天平均每秒 rate 笔；不是并发安全的，由一个 goroutine 按固定间隔调用 Next
+type OrderSimulator struct {
+	merchants []simulatorMerchant
+	rate      float64
+	amount    float64
+	prefix    string
+	rng       *rand.Rand
+	seq       int64
+}
+
+// NewOrderSimulator 创建订单流模拟，amount 为订单金额的中位数，不大于 0 时使用 DefaultSimulatorAmount
+// 订单号为 SIM-<启动时间>-<序号>，seed 相同时生成的订单相同（时刻除外）
+func NewOrderSimulator(merchants []models.Merchant, rate, amount float64, seed int64) (*OrderSimulator, error) {
+	if len(merchants) == 0 {
+		return nil, fmt.Errorf("%w: 没有可模拟的商户", ErrInvalidArgument)
+	}
+	if rate <= 0 {
+		return nil, fmt.Errorf("%w: 下单速率必须大于 0", ErrInvalidArgument)
+	}
+	if amount <= 0 {
+		amount = DefaultSimulatorAmount
+	}
+	s := &OrderSimulator{
+		rate:   rate,
+		amount: amount,
+		prefix: fmt.Sprintf("SIM-%d", time.Now().Unix()),
+		rng:    rand.New(rand.NewSource(seed)),
+	}
+	for _, m := range merchants {
+		loc, err := LoadLocation(m.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		weekend, err := MerchantWeekend(m)
+		if err != nil {
+			return nil, err
+		}
+		currency := DefaultCurrency
+		if country, ok := geo.FindCountry(m.Country); ok && country.Currency != "" {
+			currency = country.Currency
+		}
+		s.merchants = append(s.merchants, simulatorMerchant{merchant: m, loc: loc, weekend: weekend, currency: currency})
+	}
+	return s, nil
+}
+
+// Rate 在 at 时刻全部商户的瞬时下单速率（笔/秒）
+func (s *OrderSimulator) Rate(at time.Time) float64 {
+	var total float64
+	for _, m := range s.merchants {
+		total += s.merchantRate(m, at)
+	}
+	return total
+}
+
+// Next 生成 [from, to) 内下单的订单，按下单时刻排序
+func (s *OrderSimulator) Next(from, to time.Time) []models.Order {
+	seconds := to.Sub(from).Seconds()
+	if seconds <= 0 {
+		return nil
+	}
+	// 区间很短，按区间中点的速率计算
+	mid := from.Add(to.Sub(from) / 2)
+	var orders []models.Order
+	for _, m := range s.merchants {
+		for n := s.poisson(s.merchantRate(m, mid) * seconds); n > 0; n-- {
+			orders = append(orders, s.order(m, from.Add(time.Duration(s.rng.Float64()*float64(to.Sub(from))))))
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].OrderTimeUTC.Before(orders[j].OrderTimeUTC) })
+	return orders
+}
+
+// merchantRate 商户在 at 时刻的下单速率：平均速率乘以本地时间的昼夜权重（小时之间线性插值）
+func (s *OrderSimulator) merchantRate(m simulatorMerchant, at time.Time) float64 {
+	local := at.In(m.loc)
+	hour := local.Hour()
+	fraction := float64(local.Minute()*60+local.Second()) / 3600
+	weight := simulatorHourlyWeights[hour]*(1-fraction) + simulatorHourlyWeights[(hour+1)%24]*fraction
+	if m.weekend.Contains(local.Weekday()) {
+		weight *= simulatorWeekendFactor
+	}
+	return s.rate / float64(len(s.merchants)) * weight / simulatorMeanWeight
+}
+
+// order 生成一笔订单，金额按对数正态分布
+func (s *OrderSimulator) order(m simulatorMerchant, at time.Time) models.Order {
+	s.seq++
+	amount := s.amount * math.Exp(s.rng.NormFloat64()*simulatorAmountSigma)
+	if money.Exponent(m.currency) == 0 {
+		amount *= 100
+	}
+	status, p := simulatorStatuses[len(simulatorStatuses)-1].status, s.rng.Float64()
+	for _, st := range simulatorStatuses {
+		if p < st.cumulative {
+			status = st.status
+			break
+		}
+	}
+	return models.Order{
+		MerchantID:   m.merchant.ID,
+		OrderNumber:  fmt.Sprintf("%s-%d", s.prefix, s.seq),
+		Amount:       money.Round(decimal.NewFromFloat(amount), m.currency),
+		Currency:     m.currency,
+		Status:       status,
+		OrderTimeUTC: at.UTC().Truncate(time.Microsecond),
+	}
+}
+
+// poisson 泊松分布的随机数；均值较大时用正态近似
+func (s *OrderSimulator) poisson(mean float64) int {
+	if mean <= 0 {
+		return 0
+	}
+	if mean > 30 {
+		return max(0, int(math.Round(mean+math.Sqrt(mean)*s.rng.NormFloat64())))
+	}
+	limit, n, p := math.Exp(-mean), 0, s.rng.Float64()
+	for p > limit {
+		n++
+		p *= s.rng.Float64()
+	}
+	return n
+}
+
+// simulatorMeanWeight 昼夜权重的平均值，速率按它归一化，使全天平均速率等于 rate（周末除外）
+var simulatorMeanWeight = func() float64 {
+	var total float64
+	for _, w := range simulatorHourlyWeights {
+		total += w
+	}
+	return total / float64(len(simulatorHourlyWeights))
+}()
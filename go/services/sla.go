@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"timezone-saas-demo/models"
+)
+
+// SLA 统计的默认值和状态
+const (
+	// DefaultSLAWindow 统计响应时间和错误率的滚动窗口
+	DefaultSLAWindow = time.Hour
+	// DefaultSLAP95Budget、DefaultSLAP99Budget 响应时间的默认预算
+	DefaultSLAP95Budget = 500 * time.Millisecond
+	DefaultSLAP99Budget = 2 * time.Second
+	// DefaultSLAErrorRateBudget 5xx 响应占比的默认预算
+	DefaultSLAErrorRateBudget = 0.01
+	// DefaultSLAMinRequests 窗口内请求数少于该值时不评估预算，避免少量请求触发告警
+	DefaultSLAMinRequests = 20
+	// slaSlot 滚动窗口的分片长度，窗口按分片整体滑动
+	slaSlot = time.Minute
+
+	SLAStatusOK           = "ok"
+	SLAStatusBreached     = "breached"
+	SLAStatusInsufficient = "insufficient_data"
+)
+
+// slaLatencyBounds 响应时间直方图各分桶的上限，超过最后一个上限的请求计入溢出分桶
+var slaLatencyBounds = []time.Duration{
+	5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	75 * time.Millisecond, 100 * time.Millisecond, 150 * time.Millisecond, 200 * time.Millisecond,
+	300 * time.Millisecond, 500 * time.Millisecond, 750 * time.Millisecond, time.Second,
+	1500 * time.Millisecond, 2 * time.Second, 3 * time.Second, 5 * time.Second,
+	10 * time.Second, 30 * time.Second,
+}
+
+// slaSlotStats 一个分片内的请求数、5xx 数和响应时间直方图
+type slaSlotStats struct {
+	start        time.Time
+	requests     int64
+	serverErrors int64
+	counts       []int64
+}
+
+// slaTenant 一个租户的环形分片和预算状态
+type slaTenant struct {
+	slots         []slaSlotStats
+	breachedSince *time.Time
+}
+
+// SLAMonitor 按租户（X-Tenant-ID）统计滚动窗口内的 p50/p95/p99 响应时间和 5xx 错误率，超出预算时告警
+// 统计只有本进程的数据，多实例部署时各实例分别统计和告警
+type SLAMonitor struct {
+	window      time.Duration
+	budget      models.SLABudget
+	minRequests int64
+	alerter     Alerter
+	now         func() time.Time
+
+	mu      sync.Mutex
+	tenants map[string]*slaTenant
+}
+
+// NewSLAMonitor 创建 SLA 统计，window 向上取整到分钟，不大于 0 时使用 DefaultSLAWindow；alerter 为 nil 时只记录日志
+func NewSLAMonitor(window time.Duration, budget models.SLABudget, alerter Alerter) *SLAMonitor {
+	if window <= 0 {
+		window = DefaultSLAWindow
+	}
+	window = (window + slaSlot - 1).Truncate(slaSlot)
+	return &SLAMonitor{
+		window:      window,
+		budget:      budget,
+		minRequests: DefaultSLAMinRequests,
+		alerter:     alerter,
+		now:         time.Now,
+		tenants:     make(map[string]*slaTenant),
+	}
+}
+
+// Record 记录租户的一次请求的响应状态码和耗时
+func (m *SLAMonitor) Record(tenant string, status int, elapsed time.Duration, at time.Time) {
+	if m == nil {
+		return
+	}
+	start := at.Truncate(slaSlot)
+	bucket := sort.Search(len(slaLatencyBounds), func(i int) bool { return elapsed <= slaLatencyBounds[i] })
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tenants[tenant]
+	if !ok {
+		t = &slaTenant{slots: make([]slaSlotStats, m.window/slaSlot)}
+		m.tenants[tenant] = t
+	}
+	slot := &t.slots[int(start.Unix()/int64(slaSlot/time.Second))%len(t.slots)]
+	if !slot.start.Equal(start) {
+		*slot = slaSlotStats{start: start, counts: make([]int64, len(slaLatencyBounds)+1)}
+	}
+	slot.requests++
+	if status >= 500 {
+		slot.serverErrors++
+	}
+	slot.counts[bucket]++
+}
+
+// Report 各租户在窗口内的统计，按租户排序；窗口内没有请求的租户不列出
+func (m *SLAMonitor) Report() *models.SLAReport {
+	report := &models.SLAReport{Tenants: []models.TenantSLA{}}
+	if m == nil {
+		return report
+	}
+	now := m.now()
+	report.GeneratedAt = now.UTC()
+	report.Window = m.window.String()
+	report.Budget = m.budget
+	report.MinRequests = m.minRequests
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for tenant, t := range m.tenants {
+		if sla, ok := m.evaluate(tenant, t, now); ok {
+			report.Tenants = append(report.Tenants, sla)
+		}
+	}
+	sort.Slice(report.Tenants, func(i, j int) bool { return report.Tenants[i].Tenant < report.Tenants[j].Tenant })
+	return report
+}
+
+// Tenant 一个租户在窗口内的统计，窗口内没有请求时请求数为 0、状态为 insufficient_data
+func (m *SLAMonitor) Tenant(tenant string) models.TenantSLA {
+	empty := models.TenantSLA{Tenant: tenant, Status: SLAStatusInsufficient, Breaches: []string{}}
+	if m == nil {
+		return empty
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tenants[tenant]
+	if !ok {
+		return empty
+	}
+	if sla, ok := m.evaluate(tenant, t, m.now()); ok {
+		return sla
+	}
+	return empty
+}
+
+// Run 每隔 interval 检查一次各租户的预算，直到 ctx 取消
+func (m *SLAMonitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Check(ctx)
+		}
+	}
+}
+
+// Check 评估各租户的预算：从正常变为超出预算时记录日志并告警，恢复时记录日志；
+// 同时清除窗口内已没有请求的租户
+func (m *SLAMonitor) Check(ctx context.Context) []models.TenantSLA {
+	now := m.now()
+	var breached, recovered []models.TenantSLA
+
+	m.mu.Lock()
+	for tenant, t := range m.tenants {
+		sla, ok := m.evaluate(tenant, t, now)
+		switch {
+		case !ok:
+			delete(m.tenants, tenant)
+		case sla.Status == SLAStatusBreached && t.breachedSince == nil:
+			at := now.UTC()
+			t.breachedSince = &at
+			sla.BreachedSince = &at
+			breached = append(breached, sla)
+		case sla.Status != SLAStatusBreached && t.breachedSince != nil:
+			t.breachedSince = nil
+			recovered = append(recovered, sla)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, sla := range recovered {
+		log.Printf("✅ 租户 %q 的 SLA 已恢复：p95 %.0f ms，p99 %.0f ms，错误率 %.2f%%", sla.Tenant, sla.P95Ms, sla.P99Ms, sla.ErrorRate*100)
+	}
+	for _, sla := range breached {
+		log.Printf("⚠️ 租户 %q 超出 SLA 预算（%s）：p95 %.0f ms，p99 %.0f ms，错误率 %.2f%%",
+			sla.Tenant, strings.Join(sla.Breaches, "、"), sla.P95Ms, sla.P99Ms, sla.ErrorRate*100)
+		m.alert(ctx, sla)
+	}
+	return breached
+}
+
+// evaluate 合并窗口内的分片并计算统计，窗口内没有请求时返回 false；调用方持有 m.mu
+func (m *SLAMonitor) evaluate(tenant string, t *slaTenant, now time.Time) (models.TenantSLA, bool) {
+	sla := models.TenantSLA{Tenant: tenant, Breaches: []string{}}
+	cutoff := now.Truncate(slaSlot).Add(slaSlot - m.window)
+	counts := make([]int64, len(slaLatencyBounds)+1)
+	for _, slot := range t.slots {
+		if slot.counts == nil || slot.start.Before(cutoff) {
+			continue
+		}
+		sla.Requests += slot.requests
+		sla.ServerErrors += slot.serverErrors
+		for i, n := range slot.counts {
+			counts[i] += n
+		}
+	}
+	if sla.Requests == 0 {
+		return sla, false
+	}
+
+	sla.ErrorRate = float64(sla.ServerErrors) / float64(sla.Requests)
+	sla.P50Ms = slaPercentile(counts, sla.Requests, 0.50)
+	sla.P95Ms = slaPercentile(counts, sla.Requests, 0.95)
+	sla.P99Ms = slaPercentile(counts, sla.Requests, 0.99)
+	sla.Status = SLAStatusOK
+	if sla.Requests < m.minRequests {
+		sla.Status = SLAStatusInsufficient
+		return sla, true
+	}
+	if m.budget.P95Ms > 0 && sla.P95Ms > float64(m.budget.P95Ms) {
+		sla.Breaches = append(sla.Breaches, "p95")
+	}
+	if m.budget.P99Ms > 0 && sla.P99Ms > float64(m.budget.P99Ms) {
+		sla.Breaches = append(sla.Breaches, "p99")
+	}
+	if m.budget.ErrorRate > 0 && sla.ErrorRate > m.budget.ErrorRate {
+		sla.Breaches = append(sla.Breaches, "error_rate")
+	}
+	if len(sla.Breaches) > 0 {
+		sla.Status = SLAStatusBreached
+		sla.BreachedSince = t.breachedSince
+	}
+	return sla, true
+}
+
+// slaPercentile 由直方图估计分位数（毫秒）：在分位所在分桶内按线性插值，落在溢出分桶时返回最后一个上限
+func slaPercentile(counts []int64, total int64, q float64) float64 {
+	rank := int64(math.Ceil(q * float64(total)))
+	var cumulative int64
+	for i, n := range counts {
+		if n == 0 || cumulative+n < rank {
+			cumulative += n
+			continue
+		}
+		if i == len(slaLatencyBounds) {
+			break
+		}
+		var lower time.Duration
+		if i > 0 {
+			lower = slaLatencyBounds[i-1]
+		}
+		upper := slaLatencyBounds[i]
+		value := float64(lower) + float64(upper-lower)*float64(rank-cumulative)/float64(n)
+		return math.Round(value/float64(time.Millisecond)*10) / 10
+	}
+	return float64(slaLatencyBounds[len(slaLatencyBounds)-1].Milliseconds())
+}
+
+// alert 发送超出预算的告警，失败只记录日志
+func (m *SLAMonitor) alert(ctx context.Context, sla models.TenantSLA) {
+	if m.alerter == nil {
+		return
+	}
+	alert := models.Alert{
+		Source:   "sla",
+		Severity: AlertWarning,
+		Title:    fmt.Sprintf("租户 %s 超出 SLA 预算", sla.Tenant),
+		Text: fmt.Sprintf("最近 %s 内 %d 个请求：p95 %.0f ms（预算 %d ms），p99 %.0f ms（预算 %d ms），错误率 %.2f%%（预算 %.2f%%）",
+			m.window, sla.Requests, sla.P95Ms, m.budget.P95Ms, sla.P99Ms, m.budget.P99Ms, sla.ErrorRate*100, m.budget.ErrorRate*100),
+		Details: sla,
+		At:      m.now().UTC(),
+	}
+	if err := m.alerter.Alert(ctx, alert); err != nil {
+		log.Printf("SLA 告警发送失败: %v", err)
+	}
+}
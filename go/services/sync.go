@@ -0,0 +1,413 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/money"
+	"timezone-saas-demo/repository"
+	"timezone-saas-demo/schedule"
+)
+
+const (
+	// MaxSyncMutations 一次同步最多推送的修改，离线期间更多的修改分批推送
+	MaxSyncMutations = 500
+	// syncSkewTolerance 小于该值的时钟偏差视为网络延迟，不做校正
+	syncSkewTolerance = 2 * time.Second
+	// maxSyncIDLength 设备ID和修改ID的最大长度，与 sync_mutation 的列宽一致
+	maxSyncIDLength = 100
+	// maxOrderNumberLength 订单号的最大长度，与 dws_orders.order_no 的列宽一致
+	maxOrderNumberLength = 50
+)
+
+// 冲突处理规则，出现在 SyncConflict.Rule 中
+const (
+	// SyncRuleStatusProgress 双方都修改了状态时，pending → paid → shipped → delivered 中靠后的状态生效
+	SyncRuleStatusProgress = "status_progress"
+	// SyncRuleCancelBeforeShipment 一方取消订单时，另一方尚未发货（pending/paid）则取消生效，已发货或已签收则取消无效
+	SyncRuleCancelBeforeShipment = "cancel_before_shipment"
+	// SyncRuleRefundedFinal 已退款的订单不能再修改，退款只能通过退款接口
+	SyncRuleRefundedFinal = "refunded_final"
+	// SyncRuleLockedAfterPayment 金额、币种和下单时间只能在订单为 pending 时修改
+	SyncRuleLockedAfterPayment = "locked_after_payment"
+	// SyncRuleLastWriterWins 订单为 pending 时金额、币种和下单时间冲突，修改时刻（UTC）较晚的一方生效
+	SyncRuleLastWriterWins = "last_writer_wins"
+	// SyncRuleOrderNumberExists 新建的订单号已存在，保留服务端的订单
+	SyncRuleOrderNumberExists = "order_number_exists"
+)
+
+// statusProgress 订单状态在履约流程中的先后，cancelled 和 refunded 不在其中
+var statusProgress = map[string]int{
+	models.OrderStatusPending:   0,
+	models.OrderStatusPaid:      1,
+	models.OrderStatusShipped:   2,
+	models.OrderStatusDelivered: 3,
+}
+
+// SyncService 外勤平板的离线同步：设备推送离线期间的订单修改，再拉取服务端的变更
+// 设备时钟可能不准，时刻按请求中的 client_time 与服务器时刻之差校正后统一换算为 UTC；
+// 修改带有所基于的订单版本，版本落后时按字段逐一应用冲突规则，而不是整单覆盖
+type SyncService struct {
+	sync      repository.SyncRepository
+	merchants repository.MerchantRepository
+	changes   repository.ChangeRepository
+	now       func() time.Time
+}
+
+// NewSyncService 创建离线同步服务，使用 PostgreSQL 仓储
+func NewSyncService(db *database.DB) *SyncService {
+	return NewSyncServiceWithRepositories(
+		repository.NewPostgresSyncRepository(db),
+		repository.NewPostgresMerchantRepository(db),
+		repository.NewPostgresChangeRepository(db),
+	)
+}
+
+// NewSyncServiceWithRepositories 使用指定仓储创建离线同步服务
+func NewSyncServiceWithRepositories(sync repository.SyncRepository, merchants repository.MerchantRepository, changes repository.ChangeRepository) *SyncService {
+	return &SyncService{sync: sync, merchants: merchants, changes: changes, now: time.Now}
+}
+
+// syncContext 一次同步中换算设备时刻所需的信息
+type syncContext struct {
+	merchantID int
+	loc        *time.Location
+	skew       time.Duration
+	receivedAt time.Time
+}
+
+// Sync 依次处理推送的修改，再返回 since 之后该商户的变更（包括本次推送产生的变更）
+// 单个修改的内容不合法时只拒绝该修改，不影响其他修改；数据库出错时返回错误，已处理的修改在重试时返回原结果
+func (s *SyncService) Sync(ctx context.Context, req models.SyncRequest) (*models.SyncResponse, error) {
+	receivedAt := s.now().UTC()
+	req.DeviceID = strings.TrimSpace(req.DeviceID)
+	if req.DeviceID == "" || len(req.DeviceID) > maxSyncIDLength {
+		return nil, fmt.Errorf("%w: device_id 不能为空且不超过 %d 个字符", ErrInvalidArgument, maxSyncIDLength)
+	}
+	if req.Since < 0 {
+		return nil, fmt.Errorf("%w: since 不能为负数", ErrInvalidArgument)
+	}
+	if len(req.Mutations) > MaxSyncMutations {
+		return nil, fmt.Errorf("%w: 一次最多推送 %d 个修改，实际 %d 个", ErrInvalidArgument, MaxSyncMutations, len(req.Mutations))
+	}
+	seen := make(map[string]bool, len(req.Mutations))
+	for i, m := range req.Mutations {
+		if m.MutationID == "" || len(m.MutationID) > maxSyncIDLength {
+			return nil, fmt.Errorf("%w: 第 %d 个修改的 mutation_id 不能为空且不超过 %d 个字符", ErrInvalidArgument, i+1, maxSyncIDLength)
+		}
+		if seen[m.MutationID] {
+			return nil, fmt.Errorf("%w: mutation_id %q 重复", ErrInvalidArgument, m.MutationID)
+		}
+		seen[m.MutationID] = true
+	}
+
+	merchant, err := s.merchants.Get(req.MerchantID)
+	if err != nil {
+		return nil, err
+	}
+	timezone := req.Timezone
+	if timezone == "" {
+		timezone = merchant.Timezone
+	}
+	loc, err := LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+	sc := syncContext{merchantID: merchant.ID, loc: loc, receivedAt: receivedAt}
+	if req.ClientTime != "" {
+		clientTime, err := time.Parse(time.RFC3339Nano, req.ClientTime)
+		if err != nil {
+			return nil, fmt.Errorf("%w: client_time 应为 RFC3339 时间: %v", ErrInvalidArgument, err)
+		}
+		if skew := receivedAt.Sub(clientTime); skew >= syncSkewTolerance || skew <= -syncSkewTolerance {
+			sc.skew = skew
+		}
+	}
+
+	resp := &models.SyncResponse{
+		ServerTime:  receivedAt,
+		ClockSkewMs: sc.skew.Milliseconds(),
+		Timezone:    timezone,
+		Results:     make([]models.SyncMutationResult, 0, len(req.Mutations)),
+	}
+	for _, m := range req.Mutations {
+		result, err := s.apply(ctx, req.DeviceID, m, sc)
+		if err != nil {
+			return nil, err
+		}
+		switch result.Status {
+		case models.SyncStatusApplied:
+			resp.Applied++
+		case models.SyncStatusMerged:
+			resp.Merged++
+		default:
+			resp.Rejected++
+		}
+		resp.Results = append(resp.Results, *result)
+	}
+
+	filter := models.ChangeFilter{Since: req.Since, MerchantID: merchant.ID, Limit: DefaultChangeLimit}
+	changes, err := s.changes.Changes(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	feed := newChangeFeed(changes, filter, receivedAt)
+	resp.Changes, resp.NextSeq, resp.HasMore = feed.Changes, feed.NextSeq, feed.HasMore
+
+	err = s.sync.SaveDevice(ctx, models.SyncDevice{
+		DeviceID:    req.DeviceID,
+		MerchantID:  merchant.ID,
+		Timezone:    timezone,
+		ClockSkewMs: resp.ClockSkewMs,
+		LastPullSeq: resp.NextSeq,
+		LastSyncAt:  receivedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// apply 校验并应用一个修改；内容不合法时返回 rejected 结果，不写入也不保存
+func (s *SyncService) apply(ctx context.Context, deviceID string, m models.SyncMutation, sc syncContext) (*models.SyncMutationResult, error) {
+	changedAt := sc.receivedAt.Truncate(time.Second)
+	rejected := func(format string, args ...interface{}) *models.SyncMutationResult {
+		return &models.SyncMutationResult{MutationID: m.MutationID, Status: models.SyncStatusRejected, ChangedAtUTC: changedAt, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	if m.ChangedAt != "" {
+		t, err := sc.time(m.ChangedAt)
+		if err != nil {
+			return rejected("changed_at: %v", err), nil
+		}
+		changedAt = t
+	}
+	var orderTime time.Time
+	if m.OrderTime != "" {
+		t, err := sc.time(m.OrderTime)
+		if err != nil {
+			return rejected("order_time: %v", err), nil
+		}
+		orderTime = t
+	}
+	if m.Currency != "" {
+		code, err := money.ParseCode(m.Currency)
+		if err != nil {
+			return rejected("currency: %v", err), nil
+		}
+		m.Currency = code
+	}
+	if m.Amount != nil && !m.Amount.IsPositive() {
+		return rejected("amount 必须大于 0"), nil
+	}
+	if m.Status != "" && !isOrderStatus(m.Status) {
+		return rejected("无效的订单状态 %q，可选 %s", m.Status, strings.Join(models.OrderStatuses, ",")), nil
+	}
+	if m.Status == models.OrderStatusRefunded {
+		return rejected("不能通过同步把订单改为 refunded，退款请使用退款接口"), nil
+	}
+
+	var resolve repository.SyncResolver
+	switch m.Op {
+	case models.ChangeOpInsert:
+		m.OrderNumber = strings.TrimSpace(m.OrderNumber)
+		if m.OrderNumber == "" || len(m.OrderNumber) > maxOrderNumberLength {
+			return rejected("order_number 不能为空且不超过 %d 个字符", maxOrderNumberLength), nil
+		}
+		if m.Amount == nil || m.Currency == "" || orderTime.IsZero() {
+			return rejected("新建订单需要 amount、currency 和 order_time"), nil
+		}
+		if m.Status == "" {
+			m.Status = models.OrderStatusPending
+		}
+		resolve = func(current *models.Order) (*models.Order, models.SyncMutationResult) {
+			return resolveSyncInsert(current, m, sc.merchantID, orderTime, changedAt)
+		}
+	case models.ChangeOpUpdate:
+		if m.OrderID <= 0 || m.BaseVersion <= 0 {
+			return rejected("修改订单需要 order_id 和 base_version"), nil
+		}
+		if m.Amount == nil && m.Currency == "" && m.Status == "" && orderTime.IsZero() {
+			return rejected("没有要修改的字段"), nil
+		}
+		resolve = func(current *models.Order) (*models.Order, models.SyncMutationResult) {
+			return resolveSyncUpdate(current, m, sc.merchantID, orderTime, changedAt)
+		}
+	default:
+		return rejected("未知的操作 %q，可选 %s,%s", m.Op, models.ChangeOpInsert, models.ChangeOpUpdate), nil
+	}
+
+	return s.sync.ApplyOrder(ctx, deviceID, sc.merchantID, m, resolve)
+}
+
+// time 把设备上的时刻换算为 UTC
+// RFC3339 直接解析；不带偏移的本地时间按设备时区解析，夏令时跳过的时刻顺延，重复的时刻取第一次；
+// 再加上时钟偏差，校正后仍晚于服务器收到请求的时刻的按收到时刻处理
+func (sc syncContext) time(value string) (time.Time, error) {
+	instant, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		wall, ok := parseLocalTimestamp(value)
+		if !ok {
+			return time.Time{}, fmt.Errorf("时间格式错误 %q，应为 RFC3339 或 2006-01-02T15:04:05 形式的本地时间", value)
+		}
+		occurrences := schedule.Resolve(wall, sc.loc, schedule.Options{Gap: schedule.GapShift, Overlap: schedule.OverlapEarlier})
+		if len(occurrences) == 0 {
+			return time.Time{}, fmt.Errorf("本地时间 %s 在 %s 不存在", value, sc.loc)
+		}
+		instant = occurrences[0].Time
+	}
+	instant = instant.Add(sc.skew).UTC().Truncate(time.Second)
+	if instant.After(sc.receivedAt) {
+		instant = sc.receivedAt.Truncate(time.Second)
+	}
+	return instant, nil
+}
+
+// resolveSyncInsert 新建订单；订单号在该商户下已存在时保留服务端的订单
+func resolveSyncInsert(current *models.Order, m models.SyncMutation, merchantID int, orderTime, changedAt time.Time) (*models.Order, models.SyncMutationResult) {
+	result := models.SyncMutationResult{MutationID: m.MutationID, Status: models.SyncStatusApplied, ChangedAtUTC: changedAt}
+	if current != nil {
+		result.Status = models.SyncStatusRejected
+		result.Reason = fmt.Sprintf("订单号 %s 已存在", m.OrderNumber)
+		result.Order = current
+		result.Conflicts = []models.SyncConflict{{
+			Field: "order_number", ClientValue: m.OrderNumber, ServerValue: current.OrderNumber,
+			Winner: "server", Rule: SyncRuleOrderNumberExists,
+		}}
+		return nil, result
+	}
+	if !m.Amount.Equal(money.Round(*m.Amount, m.Currency)) {
+		result.Status = models.SyncStatusRejected
+		result.Reason = fmt.Sprintf("金额 %s 超出币种 %s 的小数位", m.Amount, m.Currency)
+		return nil, result
+	}
+	return &models.Order{
+		MerchantID:   merchantID,
+		OrderNumber:  m.OrderNumber,
+		Amount:       *m.Amount,
+		Currency:     m.Currency,
+		Status:       m.Status,
+		OrderTimeUTC: orderTime,
+	}, result
+}
+
+// resolveSyncUpdate 修改订单，逐个字段处理：
+// 版本一致时直接生效；版本落后时状态按 SyncRuleCancelBeforeShipment / SyncRuleStatusProgress，
+// 金额、币种和下单时间按 SyncRuleLastWriterWins；已退款的订单和非 pending 订单的金额等字段始终保留服务端的值
+func resolveSyncUpdate(current *models.Order, m models.SyncMutation, merchantID int, orderTime, changedAt time.Time) (*models.Order, models.SyncMutationResult) {
+	result := models.SyncMutationResult{MutationID: m.MutationID, Status: models.SyncStatusRejected, ChangedAtUTC: changedAt}
+	if current == nil || current.MerchantID != merchantID {
+		result.Reason = fmt.Sprintf("订单 %d 不存在", m.OrderID)
+		return nil, result
+	}
+	result.Order = current
+	if m.BaseVersion > current.Version {
+		result.Reason = fmt.Sprintf("base_version %d 大于服务端的版本 %d", m.BaseVersion, current.Version)
+		return nil, result
+	}
+	stale := m.BaseVersion < current.Version
+	next := *current
+	won, lost := 0, 0
+	field := func(name, client, server string, winner, rule string) {
+		if winner == "client" {
+			won++
+		} else {
+			lost++
+		}
+		if rule != "" {
+			result.Conflicts = append(result.Conflicts, models.SyncConflict{
+				Field: name, ClientValue: client, ServerValue: server, Winner: winner, Rule: rule,
+			})
+		}
+	}
+
+	if current.Status == models.OrderStatusRefunded {
+		result.Reason = "订单已退款，不能再修改"
+		result.Conflicts = []models.SyncConflict{{
+			Field: "status", ClientValue: m.Status, ServerValue: current.Status, Winner: "server", Rule: SyncRuleRefundedFinal,
+		}}
+		return nil, result
+	}
+
+	if m.Status != "" && m.Status != current.Status {
+		winner, rule := "client", ""
+		if stale {
+			winner, rule = resolveStatusConflict(m.Status, current.Status)
+		}
+		if winner == "client" {
+			next.Status = m.Status
+		}
+		field("status", m.Status, current.Status, winner, rule)
+	}
+
+	// 金额、币种和下单时间：只能在订单为 pending 时修改，版本落后时修改时刻较晚的一方生效
+	financial := func(name, client, server string, apply func()) {
+		winner, rule := "client", ""
+		switch {
+		case current.Status != models.OrderStatusPending:
+			winner, rule = "server", SyncRuleLockedAfterPayment
+		case stale:
+			rule = SyncRuleLastWriterWins
+			if !changedAt.After(current.UpdatedAt) {
+				winner = "server"
+			}
+		}
+		if winner == "client" {
+			apply()
+		}
+		field(name, client, server, winner, rule)
+	}
+	if m.Amount != nil && !m.Amount.Equal(current.Amount) {
+		financial("amount", m.Amount.String(), current.Amount.String(), func() { next.Amount = *m.Amount })
+	}
+	if m.Currency != "" && m.Currency != current.Currency {
+		financial("currency", m.Currency, current.Currency, func() { next.Currency = m.Currency })
+	}
+	if !orderTime.IsZero() && !orderTime.Equal(current.OrderTimeUTC) {
+		financial("order_time", orderTime.Format(time.RFC3339), current.OrderTimeUTC.UTC().Format(time.RFC3339), func() { next.OrderTimeUTC = orderTime })
+	}
+
+	if !next.Amount.Equal(money.Round(next.Amount, next.Currency)) {
+		result.Reason = fmt.Sprintf("金额 %s 超出币种 %s 的小数位", next.Amount, next.Currency)
+		return nil, result
+	}
+	switch {
+	case lost == 0:
+		result.Status = models.SyncStatusApplied
+	case won > 0:
+		result.Status = models.SyncStatusMerged
+	}
+	if won == 0 {
+		if lost > 0 {
+			result.Reason = "修改与服务端冲突，保留服务端的值"
+		}
+		return nil, result
+	}
+	return &next, result
+}
+
+// resolveStatusConflict 设备和服务端都修改了状态时决定哪一方生效，返回 client 或 server 及所用的规则
+func resolveStatusConflict(client, server string) (string, string) {
+	if client == models.OrderStatusCancelled || server == models.OrderStatusCancelled {
+		// 取消只在另一方尚未发货时生效
+		other, otherSide := server, "server"
+		if server == models.OrderStatusCancelled {
+			other, otherSide = client, "client"
+		}
+		if other == models.OrderStatusShipped || other == models.OrderStatusDelivered {
+			return otherSide, SyncRuleCancelBeforeShipment
+		}
+		if otherSide == "server" {
+			return "client", SyncRuleCancelBeforeShipment
+		}
+		return "server", SyncRuleCancelBeforeShipment
+	}
+	if statusProgress[client] > statusProgress[server] {
+		return "client", SyncRuleStatusProgress
+	}
+	return "server", SyncRuleStatusProgress
+}
@@ -0,0 +1,21 @@
+package services
+
+import "context"
+
+// DefaultTenant 请求未标明租户时归入的租户
+const DefaultTenant = "default"
+
+type tenantKey struct{}
+
+// WithTenant 在 ctx 中记录发起请求的租户，用于按租户限流
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext 取出 ctx 中的租户，未设置时返回 DefaultTenant
+func TenantFromContext(ctx context.Context) string {
+	if tenant, ok := ctx.Value(tenantKey{}).(string); ok && tenant != "" {
+		return tenant
+	}
+	return DefaultTenant
+}
@@ -0,0 +1,317 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/repository"
+)
+
+// 处理记录的状态，与 tenant_erasure 表的约束一致
+const (
+	TenantErasureRunning   = "running"
+	TenantErasureCompleted = "completed"
+	TenantErasureFailed    = "failed"
+)
+
+// 核验报告中数据库以外的存储
+const (
+	erasureStepClickHouse = "clickhouse:orders_analysis"
+	erasureStepArchive    = "archive_files"
+)
+
+// TenantErasureRequest 删除或匿名化一个商户数据的请求
+type TenantErasureRequest struct {
+	Mode     string `json:"mode"`
+	Operator string `json:"operator"`
+	// Confirm 必须与商户名称一致，避免误删
+	Confirm string `json:"confirm"`
+	DryRun  bool   `json:"dry_run"`
+}
+
+// TenantDataService 导出、删除和匿名化一个租户（商户）的全部数据，满足欧盟客户的数据可携带和删除要求
+// 数据库中的处理在一个事务中完成并在提交前核验；归档文件和 ClickHouse 镜像在提交前删除，失败时整体回滚
+type TenantDataService struct {
+	merchants repository.MerchantRepository
+	tenants   repository.TenantDataRepository
+	// store 为 nil 时没有归档文件需要删除
+	store ArchiveStore
+	// ch、backfill 为 nil 时分析查询不使用 ClickHouse
+	ch       *database.ClickHouse
+	backfill *ClickHouseBackfill
+
+	// mu 同一时刻只处理一个删除请求
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+// NewTenantDataService 创建租户数据服务，使用 PostgreSQL 仓储
+func NewTenantDataService(db *database.DB, store ArchiveStore) *TenantDataService {
+	return NewTenantDataServiceWithRepositories(
+		repository.NewPostgresMerchantRepository(db),
+		repository.NewPostgresTenantDataRepository(db),
+		store,
+	)
+}
+
+// NewTenantDataServiceWithRepositories 使用指定仓储创建租户数据服务
+func NewTenantDataServiceWithRepositories(merchants repository.MerchantRepository, tenants repository.TenantDataRepository, store ArchiveStore) *TenantDataService {
+	return &TenantDataService{
+		merchants: merchants,
+		tenants:   tenants,
+		store:     store,
+		now:       time.Now,
+	}
+}
+
+// SetClickHouse 分析查询使用 ClickHouse 时，删除同时清除镜像中商户的订单；
+// 匿名化后通过回填任务重新镜像匿名化的订单
+func (s *TenantDataService) SetClickHouse(ch *database.ClickHouse, backfill *ClickHouseBackfill) {
+	s.ch, s.backfill = ch, backfill
+}
+
+// Merchant 导出前确认商户存在，不存在时返回 ErrNotFound
+func (s *TenantDataService) Merchant(merchantID int) (*models.Merchant, error) {
+	return s.merchants.Get(merchantID)
+}
+
+// Export 把商户的全部数据写成一个 JSON 文档：
+// {"merchant_id":3,"exported_at":"...","sections":{"merchant":[...],"settings":[...],"orders":[...],...,"audit":[...]}}
+// 每个分组为一张表中属于该商户的行，字段与表的列一致；各表的数据来自同一个数据库快照
+// 写出途中失败时文档不完整，调用方应当丢弃
+func (s *TenantDataService) Export(ctx context.Context, merchantID int, w io.Writer) error {
+	if _, err := s.merchants.Get(merchantID); err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `{"merchant_id":%d,"exported_at":%q,"sections":{`, merchantID, s.now().UTC().Format(time.RFC3339))
+	sections, rows := 0, 0
+	err := s.tenants.Export(ctx, merchantID,
+		func(name string) error {
+			if sections > 0 {
+				bw.WriteString("],")
+			}
+			sections, rows = sections+1, 0
+			_, err := fmt.Fprintf(bw, "%s:[", strconv.Quote(name))
+			return err
+		},
+		func(row json.RawMessage) error {
+			if rows > 0 {
+				bw.WriteByte(',')
+			}
+			rows++
+			_, err := bw.Write(row)
+			return err
+		})
+	if err != nil {
+		return err
+	}
+	if sections > 0 {
+		bw.WriteString("]")
+	}
+	bw.WriteString("}}\n")
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("写入导出文件失败: %w", err)
+	}
+	return nil
+}
+
+// Erase 删除或匿名化商户的数据，返回核验报告
+// dry_run 时只统计每张表会处理的行数；否则先写入执行中的记录，处理完成后更新状态和核验结果，
+// 核验未通过或任一存储处理失败时数据库回滚，记录为 failed
+func (s *TenantDataService) Erase(ctx context.Context, merchantID int, req TenantErasureRequest) (*models.TenantErasure, error) {
+	switch req.Mode {
+	case models.TenantErasureDelete, models.TenantErasureAnonymize:
+	default:
+		return nil, fmt.Errorf("%w: mode 应为 %s 或 %s", ErrInvalidArgument, models.TenantErasureDelete, models.TenantErasureAnonymize)
+	}
+	operator := strings.TrimSpace(req.Operator)
+	if operator == "" {
+		return nil, fmt.Errorf("%w: 缺少 operator", ErrInvalidArgument)
+	}
+	merchant, err := s.merchants.Get(merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Confirm != merchant.Name {
+		return nil, fmt.Errorf("%w: confirm 与商户名称不一致", ErrInvalidArgument)
+	}
+
+	erasure := &models.TenantErasure{
+		MerchantID:  merchantID,
+		Mode:        req.Mode,
+		Status:      TenantErasureRunning,
+		DryRun:      req.DryRun,
+		RequestedBy: operator,
+		StartedAt:   s.now().UTC(),
+	}
+	if req.DryRun {
+		return s.preview(ctx, erasure)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.tenants.CreateErasure(ctx, erasure); err != nil {
+		return nil, err
+	}
+	var extra []models.TenantErasureStep
+	steps, err := s.tenants.Erase(ctx, merchantID, req.Mode, func() error {
+		var err error
+		extra, err = s.eraseExternal(ctx, merchantID)
+		return err
+	})
+	erasure.Steps = append(steps, extra...)
+	if err == nil {
+		err = s.verifyExternal(ctx, erasure)
+	}
+
+	finishedAt := s.now().UTC()
+	erasure.FinishedAt = &finishedAt
+	erasure.Status = TenantErasureCompleted
+	erasure.Verified = err == nil
+	if err != nil {
+		erasure.Status = TenantErasureFailed
+		erasure.Error = err.Error()
+	}
+	if erasure.Steps == nil {
+		erasure.Steps = []models.TenantErasureStep{}
+	}
+	// 请求被取消时仍要记录结果
+	if finishErr := s.tenants.FinishErasure(context.WithoutCancel(ctx), erasure); finishErr != nil {
+		log.Printf("⚠️ 更新删除记录 %d 失败: %v", erasure.ID, finishErr)
+	}
+	if err != nil {
+		log.Printf("❌ 商户 %d 的数据%s失败（%s）: %v", merchantID, erasureModeName(req.Mode), operator, err)
+		return erasure, err
+	}
+	log.Printf("🧹 商户 %d 的数据已%s（%s），记录 %d", merchantID, erasureModeName(req.Mode), operator, erasure.ID)
+	return erasure, nil
+}
+
+// preview 统计会处理的行数，包括归档文件所在的商户目录和 ClickHouse 镜像
+func (s *TenantDataService) preview(ctx context.Context, erasure *models.TenantErasure) (*models.TenantErasure, error) {
+	steps, err := s.tenants.Preview(ctx, erasure.MerchantID, erasure.Mode)
+	if err != nil {
+		return nil, err
+	}
+	if s.ch != nil {
+		rows, err := s.clickHouseRows(ctx, erasure.MerchantID, models.TenantErasureDelete)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, models.TenantErasureStep{Table: erasureStepClickHouse, Action: models.TenantErasureDelete, Rows: rows})
+	}
+	erasure.Steps = steps
+	erasure.Status = TenantErasureCompleted
+	return erasure, nil
+}
+
+// eraseExternal 删除商户的归档文件和 ClickHouse 镜像，在数据库事务提交前执行
+// 匿名化同样删除：归档文件和镜像中是匿名化之前的订单
+func (s *TenantDataService) eraseExternal(ctx context.Context, merchantID int) ([]models.TenantErasureStep, error) {
+	var steps []models.TenantErasureStep
+	if s.store != nil {
+		files, err := s.store.RemoveAll(ctx, MerchantArchivePrefix(merchantID))
+		if err != nil {
+			return steps, err
+		}
+		steps = append(steps, models.TenantErasureStep{Table: erasureStepArchive, Action: models.TenantErasureDelete, Rows: int64(files)})
+	}
+	if s.ch != nil {
+		rows, err := s.clickHouseRows(ctx, merchantID, models.TenantErasureDelete)
+		if err != nil {
+			return steps, err
+		}
+		params := map[string]string{"merchant": strconv.Itoa(merchantID)}
+		if err := s.ch.ExecContext(ctx, "DELETE FROM orders_analysis WHERE merchant_id = {merchant:UInt32}", params, nil); err != nil {
+			return steps, fmt.Errorf("删除 ClickHouse 镜像失败: %w", err)
+		}
+		steps = append(steps, models.TenantErasureStep{Table: erasureStepClickHouse, Action: models.TenantErasureDelete, Rows: rows})
+	}
+	return steps, nil
+}
+
+// verifyExternal 数据库提交后核验 ClickHouse 镜像；匿名化时先创建回填任务重新镜像匿名化的订单，
+// 核验只统计匿名化之前的订单号
+func (s *TenantDataService) verifyExternal(ctx context.Context, erasure *models.TenantErasure) error {
+	if s.ch == nil {
+		return nil
+	}
+	if erasure.Mode == models.TenantErasureAnonymize && s.backfill != nil {
+		job, err := s.backfill.Create(ctx, BackfillRequest{MerchantIDs: []int{erasure.MerchantID}, Reason: "商户数据已匿名化"})
+		if err == nil {
+			err = s.backfill.Start(job.ID)
+		}
+		if err != nil {
+			return fmt.Errorf("创建 ClickHouse 回填任务失败，匿名化的订单需要手动回填: %w", err)
+		}
+	}
+	rows, err := s.clickHouseRows(ctx, erasure.MerchantID, erasure.Mode)
+	if err != nil {
+		return err
+	}
+	for i := range erasure.Steps {
+		if erasure.Steps[i].Table == erasureStepClickHouse {
+			erasure.Steps[i].Remaining = rows
+		}
+	}
+	if rows > 0 {
+		return fmt.Errorf("%w: 核验未通过，ClickHouse 镜像中仍有 %d 行", ErrConflict, rows)
+	}
+	return nil
+}
+
+// clickHouseRows ClickHouse 镜像中商户的订单数，anonymize 时只统计未匿名化的订单
+func (s *TenantDataService) clickHouseRows(ctx context.Context, merchantID int, mode string) (int64, error) {
+	query := "SELECT count() AS order_count FROM orders_analysis WHERE merchant_id = {merchant:UInt32}"
+	if mode == models.TenantErasureAnonymize {
+		query += " AND NOT startsWith(order_number, 'anon-')"
+	}
+	var result []struct {
+		Rows int64 `json:"order_count"`
+	}
+	if err := s.ch.QueryContext(ctx, query, map[string]string{"merchant": strconv.Itoa(merchantID)}, &result); err != nil {
+		return 0, fmt.Errorf("统计 ClickHouse 镜像失败: %w", err)
+	}
+	if len(result) == 0 {
+		return 0, nil
+	}
+	return result[0].Rows, nil
+}
+
+// Erasures 最近 limit 条处理记录，merchantID 不为 0 时只返回该商户的记录
+func (s *TenantDataService) Erasures(ctx context.Context, merchantID, limit int) ([]models.TenantErasure, error) {
+	erasures, err := s.tenants.Erasures(ctx, merchantID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if erasures == nil {
+		erasures = []models.TenantErasure{}
+	}
+	return erasures, nil
+}
+
+// GetErasure 一条处理记录及其核验报告，不存在时返回 ErrNotFound
+func (s *TenantDataService) GetErasure(ctx context.Context, id int64) (*models.TenantErasure, error) {
+	return s.tenants.Erasure(ctx, id)
+}
+
+// erasureModeName 日志中的处理方式
+func erasureModeName(mode string) string {
+	if mode == models.TenantErasureAnonymize {
+		return "匿名化"
+	}
+	return "删除"
+}
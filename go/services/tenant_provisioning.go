@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/repository"
+)
+
+// tenantAPIKeyPrefix 租户 API 密钥的前缀，便于在日志和配置中识别密钥类型
+const tenantAPIKeyPrefix = "sv_"
+
+// maxTrialDays 开通时最长的试用天数
+const maxTrialDays = 90
+
+// sampleOrderAmount 开通时写入的示例订单金额
+var sampleOrderAmount = decimal.NewFromInt(100)
+
+// TenantProvisionRequest 开通租户的请求：商户信息与入驻接口相同，另外可以指定配置项、隔离方式和 API 密钥的名称
+type TenantProvisionRequest struct {
+	OnboardRequest
+	// Settings 开通时写入的配置项，键见 SettingKeys；未指定的键写入默认值。
+	// 营业时间和周末由 business_hours_start / business_hours_end / weekend_days 指定，不在其中
+	Settings map[string]json.RawMessage `json:"settings"`
+	// Isolation 隔离方式 shared（默认）或 rls
+	Isolation   string `json:"isolation"`
+	APIKeyLabel string `json:"api_key_label"`
+	// TrialDays 试用天数（含开通当天，按商户本地日期），为 0 时直接为 active
+	TrialDays int    `json:"trial_days"`
+	Operator  string `json:"operator"`
+}
+
+// TenantProvisioningService 在一个事务中开通租户：创建商户、默认配置、Webhook 占位、API 密钥、隔离策略和示例订单
+type TenantProvisioningService struct {
+	provisioning repository.ProvisioningRepository
+	onboarding   *OnboardingService
+	now          func() time.Time
+}
+
+// NewTenantProvisioningService 创建租户开通服务，使用 PostgreSQL 仓储
+func NewTenantProvisioningService(db *database.DB, onboarding *OnboardingService) *TenantProvisioningService {
+	return NewTenantProvisioningServiceWithRepositories(repository.NewPostgresProvisioningRepository(db), onboarding)
+}
+
+// NewTenantProvisioningServiceWithRepositories 使用指定仓储创建租户开通服务，商户信息由 onboarding 校验和推断
+func NewTenantProvisioningServiceWithRepositories(provisioning repository.ProvisioningRepository, onboarding *OnboardingService) *TenantProvisioningService {
+	return &TenantProvisioningService{
+		provisioning: provisioning,
+		onboarding:   onboarding,
+		now:          time.Now,
+	}
+}
+
+// Provision 校验请求并开通租户，返回的 APIKey.Secret 为完整密钥，之后无法再次获取
+// DryRun 时只返回校验结果和将写入的配置，不生成密钥、不写入数据库
+func (s *TenantProvisioningService) Provision(ctx context.Context, req TenantProvisionRequest) (*models.TenantProvisioning, error) {
+	isolation := strings.ToLower(strings.TrimSpace(req.Isolation))
+	if isolation == "" {
+		isolation = models.TenantIsolationShared
+	}
+	if isolation != models.TenantIsolationShared && isolation != models.TenantIsolationRLS {
+		return nil, fmt.Errorf("%w: 不支持的隔离方式 %q，可选 %s、%s", ErrInvalidArgument, req.Isolation, models.TenantIsolationShared, models.TenantIsolationRLS)
+	}
+	label := strings.TrimSpace(req.APIKeyLabel)
+	if label == "" {
+		label = "default"
+	}
+	if len([]rune(label)) > 100 {
+		return nil, fmt.Errorf("%w: API 密钥名称不超过 100 个字符", ErrInvalidArgument)
+	}
+
+	if req.TrialDays < 0 || req.TrialDays > maxTrialDays {
+		return nil, fmt.Errorf("%w: 试用天数应为 0~%d", ErrInvalidArgument, maxTrialDays)
+	}
+
+	onboarded, err := s.onboarding.prepare(req.OnboardRequest)
+	if err != nil {
+		return nil, err
+	}
+	p := &models.TenantProvisioning{
+		MerchantOnboarding: onboarded.MerchantOnboarding,
+		Isolation:          isolation,
+		Lifecycle:          models.TenantLifecycleActive,
+		ProvisionedBy:      operatorOrSystem(req.Operator),
+	}
+	if req.TrialDays > 0 {
+		loc, err := LoadLocation(p.Merchant.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		local := s.now().In(loc)
+		p.Lifecycle = models.TenantLifecycleTrial
+		p.TrialEndsOn = time.Date(local.Year(), local.Month(), local.Day()+req.TrialDays-1, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
+	}
+	if p.Settings, err = provisionSettings(p, req.Settings); err != nil {
+		return nil, err
+	}
+
+	currency := DefaultCurrency
+	for _, setting := range p.Settings {
+		if setting.Key == SettingCurrency.Name {
+			if err := json.Unmarshal(setting.Value, &currency); err != nil {
+				return nil, fmt.Errorf("解析币种配置失败: %w", err)
+			}
+		}
+	}
+	p.SampleOrder = models.Order{
+		Amount:       sampleOrderAmount,
+		Currency:     currency,
+		Status:       models.OrderStatusPaid,
+		OrderTimeUTC: s.now().UTC().Truncate(time.Second),
+	}
+	p.APIKey.Label = label
+	if req.DryRun {
+		return p, nil
+	}
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("生成 API 密钥失败: %w", err)
+	}
+	key := tenantAPIKeyPrefix + hex.EncodeToString(secret)
+	p.APIKey.Prefix = key[:len(tenantAPIKeyPrefix)+8]
+	if err := s.provisioning.Provision(ctx, p, hashTenantAPIKey(key)); err != nil {
+		return nil, err
+	}
+	p.APIKey.Secret = key
+	return p, nil
+}
+
+// Authenticate 返回 API 密钥所属的商户，密钥无效或已吊销时返回 ErrUnauthorized
+func (s *TenantProvisioningService) Authenticate(ctx context.Context, key string) (int, error) {
+	if !strings.HasPrefix(key, tenantAPIKeyPrefix) {
+		return 0, fmt.Errorf("%w: 不是租户 API 密钥", ErrUnauthorized)
+	}
+	merchantID, err := s.provisioning.APIKeyMerchant(ctx, hashTenantAPIKey(key))
+	if errors.Is(err, repository.ErrNotFound) {
+		return 0, fmt.Errorf("%w: API 密钥无效或已吊销", ErrUnauthorized)
+	}
+	return merchantID, err
+}
+
+// RevokeAPIKey 吊销商户的 API 密钥，立即失效
+func (s *TenantProvisioningService) RevokeAPIKey(ctx context.Context, merchantID, keyID int) error {
+	return s.provisioning.RevokeAPIKey(ctx, merchantID, keyID)
+}
+
+// hashTenantAPIKey API 密钥的 SHA-256 摘要（十六进制）
+func hashTenantAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// provisionSettings 校验请求的配置项，与其余配置键的默认值一起按键名排序返回
+// 报表计划同时决定 merchant_report_settings 的初始值
+func provisionSettings(p *models.TenantProvisioning, requested map[string]json.RawMessage) ([]models.TenantSetting, error) {
+	for key := range requested {
+		def, err := lookupSetting(key)
+		if err != nil {
+			return nil, err
+		}
+		if def.calendar != nil {
+			return nil, fmt.Errorf("%w: 配置 %s 由商户的营业时间和周末字段指定", ErrInvalidArgument, key)
+		}
+	}
+
+	var settings []models.TenantSetting
+	for _, key := range SettingKeys() {
+		def := settingDefs[key]
+		if def.calendar != nil {
+			continue
+		}
+		var value any
+		var err error
+		if raw, ok := requested[key]; ok {
+			value, err = def.normalize(raw)
+		} else {
+			value, err = def.defaultValue(p.Merchant)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if schedule, ok := value.(ReportSchedule); ok {
+			p.ReportSettings = models.ReportSettings{
+				DailyReportEnabled:  schedule.DailyEnabled,
+				DailyReportTime:     schedule.DailyTime,
+				WeeklyReportEnabled: schedule.WeeklyEnabled,
+				WeekStartDay:        schedule.WeekStartDay,
+			}
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("序列化配置 %s 失败: %w", key, err)
+		}
+		settings = append(settings, models.TenantSetting{Key: key, Value: encoded, UpdatedBy: p.ProvisionedBy})
+	}
+	return settings, nil
+}
@@ -1,447 +1,501 @@
 package services
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/shopspring/decimal"
+	"golang.org/x/sync/errgroup"
+
 	"timezone-saas-demo/database"
+	"timezone-saas-demo/geo"
 	"timezone-saas-demo/models"
+	"timezone-saas-demo/money"
+	"timezone-saas-demo/repository"
 )
 
 // TimezoneService 时区服务
+// 数据访问全部通过 repository 接口完成，测试时可注入 testsupport 中的内存实现
 type TimezoneService struct {
-	db *database.DB
+	db        *database.DB
+	merchants repository.MerchantRepository
+	orders    repository.OrderRepository
+	analytics repository.AnalysisRepository
+	refunds   repository.RefundRepository
+	lookup    geo.Provider
+	revenue   models.RevenueDefinition
+	// queryTimeout 分析接口单项查询的超时时间
+	queryTimeout time.Duration
+	// queryMode 分析接口的查询方式
+	queryMode string
+	// limiter 按租户限制同时执行的分析请求，为 nil 时不限制
+	limiter *TenantLimiter
+
+	// lastMerchants 最近一次成功读取的商户列表，数据库不可用时作为降级结果
+	merchantsMu     sync.Mutex
+	lastMerchants   []models.Merchant
+	lastMerchantsAt time.Time
+
+	// snapshot 按ID索引的商户快照，供不访问数据库的本地时间接口使用，为 nil 时下次读取
+	snapshotMu sync.Mutex
+	snapshot   map[int]models.Merchant
+	snapshotAt time.Time
 }
 
+// DefaultAnalysisQueryTimeout 分析接口单项查询的默认超时时间
+const DefaultAnalysisQueryTimeout = 10 * time.Second
+
+// 分析接口的查询方式
+const (
+	// AnalysisQueryModeFanout 各部分分别查询并发执行，单项超时时可返回部分结果
+	AnalysisQueryModeFanout = "fanout"
+	// AnalysisQueryModeSingle 一条语句返回全部聚合，减少数据库往返；分析后端不支持时回退为 fanout
+	AnalysisQueryModeSingle = "single"
+	// AnalysisQueryModeBatch 批量分析时多个日期的全部聚合由一条语句返回，只出现在结果中，不能作为配置
+	AnalysisQueryModeBatch = "batch"
+)
+
+// topMerchantLimit 分析数据中商户排行的数量
+const topMerchantLimit = 10
+
 // NewTimezoneService 创建新的时区服务
+// 默认使用 PostgreSQL 仓储，分析查询走 dws_orders_analysis_view 视图
 func NewTimezoneService(db *database.DB) *TimezoneService {
 	return &TimezoneService{
-		db: db,
+		db:           db,
+		merchants:    repository.NewPostgresMerchantRepository(db),
+		orders:       repository.NewPostgresOrderRepository(db),
+		analytics:    repository.NewPostgresAnalysisRepository(db),
+		refunds:      repository.NewPostgresRefundRepository(db),
+		lookup:       geo.DefaultProvider,
+		revenue:      DefaultRevenueDefinition,
+		queryTimeout: DefaultAnalysisQueryTimeout,
+		queryMode:    AnalysisQueryModeFanout,
+	}
+}
+
+// NewTimezoneServiceWithRepositories 使用指定仓储创建时区服务（不依赖数据库连接）
+func NewTimezoneServiceWithRepositories(merchants repository.MerchantRepository, orders repository.OrderRepository, analytics repository.AnalysisRepository, refunds repository.RefundRepository) *TimezoneService {
+	return &TimezoneService{
+		merchants:    merchants,
+		orders:       orders,
+		analytics:    analytics,
+		refunds:      refunds,
+		lookup:       geo.DefaultProvider,
+		revenue:      DefaultRevenueDefinition,
+		queryTimeout: DefaultAnalysisQueryTimeout,
+		queryMode:    AnalysisQueryModeFanout,
+	}
+}
+
+// SetAnalysisRepository 切换分析数据存储后端
+func (s *TimezoneService) SetAnalysisRepository(analytics repository.AnalysisRepository) {
+	s.analytics = analytics
+}
+
+// SetQueryTimeout 设置分析接口单项查询的超时时间，0 表示不限制
+func (s *TimezoneService) SetQueryTimeout(timeout time.Duration) {
+	s.queryTimeout = timeout
+}
+
+// SetQueryMode 设置分析接口的查询方式：fanout | single
+func (s *TimezoneService) SetQueryMode(mode string) error {
+	switch mode {
+	case AnalysisQueryModeFanout, AnalysisQueryModeSingle:
+		s.queryMode = mode
+		return nil
+	default:
+		return fmt.Errorf("%w: 不支持的分析查询方式 %q，可选 %s,%s", ErrInvalidArgument, mode, AnalysisQueryModeFanout, AnalysisQueryModeSingle)
 	}
 }
 
+// SetTenantLimiter 设置分析接口按租户的并发限制，nil 表示不限制
+func (s *TimezoneService) SetTenantLimiter(limiter *TenantLimiter) {
+	s.limiter = limiter
+}
+
+// TenantQueryStats 分析接口各租户的并发和排队统计
+func (s *TimezoneService) TenantQueryStats() []models.TenantQueryStats {
+	return s.limiter.Stats()
+}
+
+// SetTimezoneLookup 切换坐标→时区的查询实现
+func (s *TimezoneService) SetTimezoneLookup(lookup geo.Provider) {
+	s.lookup = lookup
+}
+
 // GetMerchants 获取所有商户
 func (s *TimezoneService) GetMerchants() ([]models.Merchant, error) {
-	query := `
-		SELECT id, name, timezone, country, city, description, created_at, updated_at
-		FROM dim_merchant
-		ORDER BY name
-	`
-
-	rows, err := s.db.Query(query)
+	merchants, err := s.merchants.List()
 	if err != nil {
-		return nil, fmt.Errorf("查询商户失败: %w", err)
-	}
-	defer rows.Close()
-
-	var merchants []models.Merchant
-	for rows.Next() {
-		var merchant models.Merchant
-		err := rows.Scan(
-			&merchant.ID,
-			&merchant.Name,
-			&merchant.Timezone,
-			&merchant.Country,
-			&merchant.City,
-			&merchant.Description,
-			&merchant.CreatedAt,
-			&merchant.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("扫描商户数据失败: %w", err)
-		}
-		merchants = append(merchants, merchant)
+		return nil, err
 	}
+	s.merchantsMu.Lock()
+	s.lastMerchants, s.lastMerchantsAt = merchants, time.Now()
+	s.merchantsMu.Unlock()
+	return merchants, nil
+}
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("遍历商户数据失败: %w", err)
+// GetMerchantsWithFallback 获取所有商户，数据库不可用（连接失败或熔断中）时返回最近一次成功读取的列表
+// cachedAt 为缓存的读取时间，返回的是最新数据时为零值；从未成功读取过时原样返回错误
+func (s *TimezoneService) GetMerchantsWithFallback() (merchants []models.Merchant, cachedAt time.Time, err error) {
+	merchants, err = s.GetMerchants()
+	if err == nil || !database.IsConnectionError(err) {
+		return merchants, time.Time{}, err
 	}
 
-	return merchants, nil
+	s.merchantsMu.Lock()
+	defer s.merchantsMu.Unlock()
+	if s.lastMerchants == nil {
+		return nil, time.Time{}, err
+	}
+	log.Printf("⚠️ 数据库不可用，返回 %s 缓存的商户列表: %v", s.lastMerchantsAt.Format(time.RFC3339), err)
+	return s.lastMerchants, s.lastMerchantsAt, nil
 }
 
-// GetOrders 获取订单列表（支持时区转换）
-func (s *TimezoneService) GetOrders(timezone string, limit, offset int) ([]models.OrderAnalysis, error) {
-	var query string
-
-	if timezone != "" {
-		// 查询指定时区的订单
-		query = `
-			SELECT 
-				order_id, order_number, amount, currency, status,
-				merchant_id, merchant_name, timezone, country, city,
-				order_time_utc, order_time_local, local_date,
-				local_hour, local_day_of_week, local_weekday,
-				is_weekend, is_business_hour, timezone_offset
-			FROM dws_orders_analysis_view
-			WHERE timezone = $1
-			ORDER BY order_time_utc DESC
-			LIMIT $2 OFFSET $3
-		`
-	} else {
-		// 查询所有订单
-		query = `
-			SELECT 
-				order_id, order_number, amount, currency, status,
-				merchant_id, merchant_name, timezone, country, city,
-				order_time_utc, order_time_local, local_date,
-				local_hour, local_day_of_week, local_weekday,
-				is_weekend, is_business_hour, timezone_offset
-			FROM dws_orders_analysis_view
-			ORDER BY order_time_utc DESC
-			LIMIT $1 OFFSET $2
-		`
-	}
-
-	var rows *sql.Rows
-	var err error
-
-	if timezone != "" {
-		rows, err = s.db.Query(query, timezone, limit, offset)
-	} else {
-		rows, err = s.db.Query(query, limit, offset)
-	}
+// GetMerchant 按ID获取商户，不存在时返回 ErrNotFound
+func (s *TimezoneService) GetMerchant(id int) (*models.Merchant, error) {
+	return s.merchants.Get(id)
+}
 
+// GetOrder 按ID获取订单，字段与订单列表相同，不存在时返回 ErrNotFound
+func (s *TimezoneService) GetOrder(id int, formatting string) (*models.OrderAnalysis, error) {
+	orders, err := s.orders.List(models.OrderFilter{OrderID: id, Limit: 1, Formatting: formatting})
 	if err != nil {
-		return nil, fmt.Errorf("查询订单失败: %w", err)
-	}
-	defer rows.Close()
-
-	var orders []models.OrderAnalysis
-	for rows.Next() {
-		var order models.OrderAnalysis
-		var localDate time.Time
-		var localWeekday string
-
-		err := rows.Scan(
-			&order.OrderID,
-			&order.OrderNumber,
-			&order.Amount,
-			&order.Currency,
-			&order.Status,
-			&order.MerchantID,
-			&order.MerchantName,
-			&order.Timezone,
-			&order.Country,
-			&order.City,
-			&order.OrderTimeUTC,
-			&order.OrderTimeLocal,
-			&localDate,
-			&order.LocalHour,
-			&order.LocalDayOfWeek,
-			&localWeekday,
-			&order.IsWeekend,
-			&order.IsBusinessHour,
-			&order.TimezoneOffset,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("扫描订单数据失败: %w", err)
-		}
-
-		order.LocalDate = localDate.Format("2006-01-02")
-		order.LocalWeekday = strings.TrimSpace(localWeekday)
-		orders = append(orders, order)
+		return nil, err
 	}
+	if len(orders) == 0 {
+		return nil, fmt.Errorf("%w: 订单 %d", ErrNotFound, id)
+	}
+	return &orders[0], nil
+}
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("遍历订单数据失败: %w", err)
+// GetOrders 获取订单列表（支持时区转换），可按时区和订单状态过滤
+func (s *TimezoneService) GetOrders(filter models.OrderFilter) ([]models.OrderAnalysis, error) {
+	return s.orders.List(filter)
+}
+
+// ParseOrderFormatting 校验订单的格式化方式（go、sql、raw），空字符串返回 fallback
+func ParseOrderFormatting(value, fallback string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return fallback, nil
 	}
+	for _, formatting := range models.OrderFormattings {
+		if value == formatting {
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("%w: 不支持的订单格式化方式 %q，可选 %s", ErrInvalidArgument, value, strings.Join(models.OrderFormattings, ","))
+}
 
-	return orders, nil
+// EstimateOrderCount 估算符合 GetOrders 条件（忽略分页）的订单数，用于分页元数据
+func (s *TimezoneService) EstimateOrderCount(ctx context.Context, filter models.OrderFilter) (int64, error) {
+	return s.orders.EstimateCount(ctx, filter)
+}
+
+// StreamOrders 按 GetOrders 的条件逐条回调订单，不在内存中累积结果，用于导出等大批量场景
+// filter.Limit 为 0 时不限制条数；fn 返回错误时停止并返回该错误，ctx 取消时中止查询
+func (s *TimezoneService) StreamOrders(ctx context.Context, filter models.OrderFilter, fn func(models.OrderAnalysis) error) error {
+	return s.orders.Stream(ctx, filter, fn)
 }
 
 // GetAnalysisData 获取分析数据
-func (s *TimezoneService) GetAnalysisData(date string) (*models.AnalysisData, error) {
+// 金额按币种分组合计；currency 指定目标币种时额外返回该币种的单一合计，为空时不返回单一合计。
+// statuses 指定参与统计的订单状态，为空时按营收口径排除（默认排除已取消订单）。
+// fanout 方式下各项查询并发执行，每项受 queryTimeout 限制：合计超时或任一查询出错时返回错误，
+// 小时分解、时区统计、商户排行超时时省略该项并在 OmittedSections 中标明；
+// single 方式下四种聚合由一条语句返回，与退款合计并发执行，超时时整体返回错误
+func (s *TimezoneService) GetAnalysisData(ctx context.Context, date, currency string, statuses []string) (*models.AnalysisData, error) {
+	return s.GetAnalysisDataIn(ctx, date, "", currency, statuses)
+}
+
+// GetAnalysisDataIn 与 GetAnalysisData 相同，aggregateTZ 非空时按该时区（如总部所在的 Europe/London）而不是各商户的本地时间
+// 划分日期和小时：date 为该时区的日期，订单按 order_time_utc 换算到该时区后归入小时，退款的订单日期和退款日期同样换算；
+// 汇总表按商户本地时间汇总，因此这种方式总是扫描明细，查不到已归档的订单
+func (s *TimezoneService) GetAnalysisDataIn(ctx context.Context, date, aggregateTZ, currency string, statuses []string) (*models.AnalysisData, error) {
 	// 解析日期
 	_, err := time.Parse("2006-01-02", date)
 	if err != nil {
 		return nil, fmt.Errorf("日期格式错误: %w", err)
 	}
-
-	analysis := &models.AnalysisData{
-		Date: date,
+	if currency != "" {
+		if currency, err = money.ParseCode(currency); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+		}
 	}
-
-	// 获取总订单数和总金额
-	err = s.getOrderSummary(date, analysis)
-	if err != nil {
-		return nil, fmt.Errorf("获取订单汇总失败: %w", err)
+	if err := validateAggregateTimezone(aggregateTZ); err != nil {
+		return nil, err
 	}
 
-	// 获取按小时分解的数据
-	err = s.getHourlyBreakdown(date, analysis)
+	// 一次分析请求占用一个名额（fanout 方式下内部的并发查询共用该名额）
+	release, err := s.limiter.Acquire(ctx, TenantFromContext(ctx))
 	if err != nil {
-		return nil, fmt.Errorf("获取小时分解数据失败: %w", err)
+		return nil, err
 	}
+	defer release()
+	return s.analysisData(ctx, date, aggregateTZ, currency, statuses)
+}
 
-	// 获取时区统计
-	err = s.getTimezoneStats(date, analysis)
-	if err != nil {
-		return nil, fmt.Errorf("获取时区统计失败: %w", err)
+// validateAggregateTimezone 校验汇总时区，为空表示按商户本地时间
+func validateAggregateTimezone(aggregateTZ string) error {
+	if aggregateTZ == "" {
+		return nil
 	}
+	_, err := LoadLocation(aggregateTZ)
+	return err
+}
 
-	// 获取顶级商户
-	err = s.getTopMerchants(date, analysis)
-	if err != nil {
-		return nil, fmt.Errorf("获取顶级商户失败: %w", err)
+// analysisData GetAnalysisDataIn 的查询部分，调用方已校验参数并占用了租户名额
+func (s *TimezoneService) analysisData(ctx context.Context, date, aggregateTZ, currency string, statuses []string) (*models.AnalysisData, error) {
+	filter := models.AnalysisFilter{LocalDate: date, Statuses: countedStatuses(s.revenue, statuses)}
+	analysis := &models.AnalysisData{
+		Date:      date,
+		Source:    s.analytics.Name(),
+		Statuses:  filter.Statuses,
+		Revenue:   s.revenue,
+		Currency:  currency,
+		TimeBasis: models.TimeBasisMerchantLocal,
+	}
+	if aggregateTZ != "" {
+		start, end, err := localDayWindow(date, aggregateTZ)
+		if err != nil {
+			return nil, err
+		}
+		filter.AggregateTimezone, filter.Start, filter.End = aggregateTZ, start, end
+		analysis.TimeBasis, analysis.AggregateTimezone = models.TimeBasisAggregateTimezone, aggregateTZ
+		analysis.WindowStartUTC, analysis.WindowEndUTC = &start, &end
 	}
 
-	return analysis, nil
-}
-
-// getOrderSummary 获取订单汇总
-func (s *TimezoneService) getOrderSummary(date string, analysis *models.AnalysisData) error {
-	query := `
-		SELECT 
-			COUNT(*) as total_orders,
-			COALESCE(SUM(amount), 0) as total_amount
-		FROM dws_orders_analysis_view
-		WHERE local_date = $1
-	`
-
-	err := s.db.QueryRow(query, date).Scan(
-		&analysis.TotalOrders,
-		&analysis.TotalAmount,
+	var (
+		totals  []models.CurrencyTotal
+		refunds []models.RefundTotal
+		mu      sync.Mutex
 	)
-	if err != nil {
-		return fmt.Errorf("查询订单汇总失败: %w", err)
+	g, gctx := errgroup.WithContext(ctx)
+	// optional 可省略的部分：自身查询超时（而不是调用方取消）时只记录省略，不影响其他结果
+	optional := func(section string, query func(ctx context.Context) error) func() error {
+		return func() error {
+			err := s.withQueryTimeout(gctx, query)
+			if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+				log.Printf("⚠️ 分析数据 %s 的 %s 查询超时，返回部分结果", date, section)
+				mu.Lock()
+				analysis.OmittedSections = append(analysis.OmittedSections, section)
+				mu.Unlock()
+				return nil
+			}
+			return err
+		}
 	}
 
-	return nil
+	// 退款记录始终在 PostgreSQL 中统计，与分析后端无关
+	g.Go(func() error {
+		return s.withQueryTimeout(gctx, func(ctx context.Context) (err error) {
+			if refunds, err = s.refunds.Totals(ctx, filter); err != nil {
+				return fmt.Errorf("获取退款汇总失败: %w", err)
+			}
+			return nil
+		})
+	})
+
+	if combined, ok := s.analytics.(repository.CombinedAnalysisRepository); ok && s.queryMode == AnalysisQueryModeSingle {
+		// 一条语句返回全部聚合，没有可单独省略的部分
+		analysis.QueryMode = AnalysisQueryModeSingle
+		g.Go(func() error {
+			return s.withQueryTimeout(gctx, func(ctx context.Context) error {
+				aggregates, err := combined.Aggregates(ctx, filter, topMerchantLimit)
+				if err != nil {
+					return fmt.Errorf("获取分析聚合失败: %w", err)
+				}
+				totals = aggregates.Summary
+				analysis.HourlyBreakdown = aggregates.Hourly
+				analysis.TimezoneStats = aggregates.Timezones
+				analysis.TopMerchants = aggregates.TopMerchants
+				return nil
+			})
+		})
+	} else {
+		analysis.QueryMode = AnalysisQueryModeFanout
+		// 按币种获取订单数和金额
+		g.Go(func() error {
+			return s.withQueryTimeout(gctx, func(ctx context.Context) (err error) {
+				if totals, err = s.analytics.OrderSummary(ctx, filter); err != nil {
+					return fmt.Errorf("获取订单汇总失败: %w", err)
+				}
+				return nil
+			})
+		})
+		// 获取按小时分解的数据
+		g.Go(optional("hourly_breakdown", func(ctx context.Context) (err error) {
+			if analysis.HourlyBreakdown, err = s.analytics.HourlyBreakdown(ctx, filter); err != nil {
+				return fmt.Errorf("获取小时分解数据失败: %w", err)
+			}
+			return nil
+		}))
+		// 获取时区统计
+		g.Go(optional("timezone_stats", func(ctx context.Context) (err error) {
+			if analysis.TimezoneStats, err = s.analytics.TimezoneStats(ctx, filter); err != nil {
+				return fmt.Errorf("获取时区统计失败: %w", err)
+			}
+			return nil
+		}))
+		// 获取顶级商户
+		g.Go(optional("top_merchants", func(ctx context.Context) (err error) {
+			if analysis.TopMerchants, err = s.analytics.TopMerchants(ctx, filter, topMerchantLimit); err != nil {
+				return fmt.Errorf("获取顶级商户失败: %w", err)
+			}
+			return nil
+		}))
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	sort.Strings(analysis.OmittedSections)
+	analysis.Partial = len(analysis.OmittedSections) > 0
+
+	s.finishAnalysis(analysis, totals, refunds)
+	return analysis, nil
 }
 
-// getHourlyBreakdown 获取按小时分解的数据
-func (s *TimezoneService) getHourlyBreakdown(date string, analysis *models.AnalysisData) error {
-	query := `
-		SELECT 
-			local_hour,
-			COUNT(*) as order_count,
-			COALESCE(SUM(amount), 0) as total_amount,
-			COALESCE(AVG(amount), 0) as avg_amount
-		FROM dws_orders_analysis_view
-		WHERE local_date = $1
-		GROUP BY local_hour
-		ORDER BY local_hour
-	`
-
-	rows, err := s.db.Query(query, date)
-	if err != nil {
-		return fmt.Errorf("查询小时分解数据失败: %w", err)
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var breakdown models.HourlyOrderBreakdown
-		err := rows.Scan(
-			&breakdown.Hour,
-			&breakdown.OrderCount,
-			&breakdown.TotalAmount,
-			&breakdown.AvgAmount,
-		)
-		if err != nil {
-			return fmt.Errorf("扫描小时分解数据失败: %w", err)
+// finishAnalysis 合并退款、按营收口径计算各币种合计，指定了目标币种时填充单一合计，最后按币种小数位舍入
+func (s *TimezoneService) finishAnalysis(analysis *models.AnalysisData, totals []models.CurrencyTotal, refunds []models.RefundTotal) {
+	analysis.TotalsByCurrency = mergeRefunds(totals, refunds)
+	for i := range analysis.TotalsByCurrency {
+		s.applyRevenue(&analysis.TotalsByCurrency[i])
+		analysis.TotalOrders += analysis.TotalsByCurrency[i].OrderCount
+	}
+	if analysis.Currency != "" {
+		target := models.CurrencyTotal{Currency: analysis.Currency}
+		for _, total := range analysis.TotalsByCurrency {
+			if total.Currency == analysis.Currency {
+				target = total
+			}
 		}
-		analysis.HourlyBreakdown = append(analysis.HourlyBreakdown, breakdown)
+		analysis.TotalAmount = &target.TotalAmount
+		analysis.GrossAmount = &target.GrossAmount
+		analysis.NetAmount = &target.NetAmount
 	}
 
-	return rows.Err()
+	roundAnalysis(analysis)
 }
 
-// getTimezoneStats 获取时区统计
-func (s *TimezoneService) getTimezoneStats(date string, analysis *models.AnalysisData) error {
-	query := `
-		SELECT 
-			timezone,
-			country,
-			COUNT(*) as order_count,
-			COALESCE(SUM(amount), 0) as total_amount,
-			COALESCE(AVG(amount), 0) as avg_amount
-		FROM dws_orders_analysis_view
-		WHERE local_date = $1
-		GROUP BY timezone, country
-		ORDER BY total_amount DESC
-	`
-
-	rows, err := s.db.Query(query, date)
-	if err != nil {
-		return fmt.Errorf("查询时区统计失败: %w", err)
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var stats models.TimezoneOrderStats
-		err := rows.Scan(
-			&stats.Timezone,
-			&stats.Country,
-			&stats.OrderCount,
-			&stats.TotalAmount,
-			&stats.AvgAmount,
-		)
-		if err != nil {
-			return fmt.Errorf("扫描时区统计数据失败: %w", err)
-		}
-		analysis.TimezoneStats = append(analysis.TimezoneStats, stats)
+// withQueryTimeout 为单项分析查询设置超时，queryTimeout 不大于 0 时只受 ctx 限制
+func (s *TimezoneService) withQueryTimeout(ctx context.Context, query func(ctx context.Context) error) error {
+	if s.queryTimeout <= 0 {
+		return query(ctx)
 	}
-
-	return rows.Err()
+	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+	return query(ctx)
 }
 
-// getTopMerchants 获取顶级商户
-func (s *TimezoneService) getTopMerchants(date string, analysis *models.AnalysisData) error {
-	query := `
-		SELECT 
-			merchant_id,
-			merchant_name,
-			timezone,
-			COUNT(*) as order_count,
-			COALESCE(SUM(amount), 0) as total_amount,
-			COALESCE(AVG(amount), 0) as avg_amount
-		FROM dws_orders_analysis_view
-		WHERE local_date = $1
-		GROUP BY merchant_id, merchant_name, timezone
-		ORDER BY total_amount DESC
-		LIMIT 10
-	`
-
-	rows, err := s.db.Query(query, date)
-	if err != nil {
-		return fmt.Errorf("查询顶级商户失败: %w", err)
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var merchant models.MerchantOrderStats
-		err := rows.Scan(
-			&merchant.MerchantID,
-			&merchant.MerchantName,
-			&merchant.Timezone,
-			&merchant.OrderCount,
-			&merchant.TotalAmount,
-			&merchant.AvgAmount,
-		)
-		if err != nil {
-			return fmt.Errorf("扫描顶级商户数据失败: %w", err)
+// mergeRefunds 把退款合计并入对应币种的订单合计
+// 按退款日期归属时，当天可能只有退款没有订单，此时补充订单数为 0 的币种合计
+func mergeRefunds(totals []models.CurrencyTotal, refunds []models.RefundTotal) []models.CurrencyTotal {
+	merged := append([]models.CurrencyTotal{}, totals...)
+	for _, refund := range refunds {
+		i := 0
+		for i < len(merged) && merged[i].Currency != refund.Currency {
+			i++
+		}
+		if i == len(merged) {
+			merged = append(merged, models.CurrencyTotal{Currency: refund.Currency})
 		}
-		analysis.TopMerchants = append(analysis.TopMerchants, merchant)
+		merged[i].RefundByOrderDate = refund.ByOrderDate
+		merged[i].RefundByRefundDate = refund.ByRefundDate
 	}
-
-	return rows.Err()
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Currency < merged[j].Currency })
+	return merged
 }
 
-// CompareTimezones 时区对比分析
-func (s *TimezoneService) CompareTimezones(utcTimeStr string) (*models.TimezoneComparison, error) {
-	// 解析UTC时间
-	utcTime, err := time.Parse(time.RFC3339, utcTimeStr)
-	if err != nil {
-		return nil, fmt.Errorf("UTC时间格式错误: %w", err)
+// roundAnalysis 按币种小数位舍入汇总金额，混合币种的分组按默认两位小数
+func roundAnalysis(analysis *models.AnalysisData) {
+	for _, amount := range []*decimal.Decimal{analysis.TotalAmount, analysis.GrossAmount, analysis.NetAmount} {
+		if amount != nil {
+			*amount = money.Round(*amount, analysis.Currency)
+		}
 	}
-
-	comparison := &models.TimezoneComparison{
-		UTCTime: utcTimeStr,
+	for i := range analysis.TotalsByCurrency {
+		t := &analysis.TotalsByCurrency[i]
+		t.TotalAmount = money.Round(t.TotalAmount, t.Currency)
+		t.GrossAmount = money.Round(t.GrossAmount, t.Currency)
+		t.RefundAmount = money.Round(t.RefundAmount, t.Currency)
+		t.RefundByOrderDate = money.Round(t.RefundByOrderDate, t.Currency)
+		t.RefundByRefundDate = money.Round(t.RefundByRefundDate, t.Currency)
+		t.NetAmount = money.Round(t.NetAmount, t.Currency)
+	}
+	for i := range analysis.HourlyBreakdown {
+		b := &analysis.HourlyBreakdown[i]
+		b.TotalAmount = money.Round(b.TotalAmount, b.Currency)
+		b.AvgAmount = money.Round(b.AvgAmount, b.Currency)
+	}
+	for i := range analysis.TimezoneStats {
+		s := &analysis.TimezoneStats[i]
+		s.TotalAmount = money.Round(s.TotalAmount, s.Currency)
+		s.AvgAmount = money.Round(s.AvgAmount, s.Currency)
+	}
+	for i := range analysis.TopMerchants {
+		m := &analysis.TopMerchants[i]
+		m.TotalAmount = money.Round(m.TotalAmount, m.Currency)
+		m.AvgAmount = money.Round(m.AvgAmount, m.Currency)
 	}
+}
 
-	// 获取所有商户的时区转换
-	query := `
-		SELECT 
-			name as merchant_name,
-			timezone,
-			$1::timestamptz AT TIME ZONE timezone as local_time,
-			($1::timestamptz AT TIME ZONE timezone)::date as local_date,
-			EXTRACT(hour FROM $1::timestamptz AT TIME ZONE timezone)::int as hour,
-			TO_CHAR($1::timestamptz AT TIME ZONE timezone, 'Day') as day_of_week,
-			EXTRACT(dow FROM $1::timestamptz AT TIME ZONE timezone) IN (0, 6) as is_weekend,
-			EXTRACT(hour FROM $1::timestamptz AT TIME ZONE timezone) BETWEEN 9 AND 17 as is_business_hour
-		FROM dim_merchant
-		ORDER BY timezone
-	`
-
-	rows, err := s.db.Query(query, utcTime)
+// CompareTimezones 时区对比分析（世界时钟）
+// timezones 为空时对比所有商户的时区；否则只对比指定的时区，此时不访问数据库。
+// utcTimeStr 接受任意带偏移的 RFC3339 时间，统一换算为 UTC。
+func (s *TimezoneService) CompareTimezones(utcTimeStr string, timezones []string) (*models.TimezoneComparison, error) {
+	// 解析时间
+	t, err := time.Parse(time.RFC3339, utcTimeStr)
 	if err != nil {
-		return nil, fmt.Errorf("查询时区对比失败: %w", err)
-	}
-	defer rows.Close()
-
-	var businessHourCount, weekendCount int
-	var totalHours float64
-	var minHour, maxHour int = 24, -1
-
-	for rows.Next() {
-		var item models.TimezoneComparisonItem
-		var localTime time.Time
-		var localDate time.Time
-		var dayOfWeek string
-
-		err := rows.Scan(
-			&item.MerchantName,
-			&item.Timezone,
-			&localTime,
-			&localDate,
-			&item.Hour,
-			&dayOfWeek,
-			&item.IsWeekend,
-			&item.IsBusinessHour,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("扫描时区对比数据失败: %w", err)
-		}
-
-		item.LocalTime = localTime.Format("2006-01-02 15:04:05")
-		item.LocalDate = localDate.Format("2006-01-02")
-		item.DayOfWeek = strings.TrimSpace(dayOfWeek)
-
-		// 计算时差
-		hourDiff := item.Hour - utcTime.Hour()
-		if hourDiff > 12 {
-			hourDiff -= 24
-		} else if hourDiff < -12 {
-			hourDiff += 24
-		}
-		item.TimeDifference = fmt.Sprintf("%+d小时", hourDiff)
-
-		comparison.Comparisons = append(comparison.Comparisons, item)
+		return nil, fmt.Errorf("%w: UTC时间格式错误: %v", ErrInvalidArgument, err)
+	}
+	utcTime := t.UTC()
 
-		// 统计信息
-		if item.IsBusinessHour {
-			businessHourCount++
+	var zones []ZoneLabel
+	if len(timezones) > 0 {
+		for _, tz := range timezones {
+			zones = append(zones, ZoneLabel{Timezone: tz})
 		}
-		if item.IsWeekend {
-			weekendCount++
-		}
-		totalHours += float64(item.Hour)
-		if item.Hour < minHour {
-			minHour = item.Hour
+	} else {
+		merchants, err := s.merchants.List()
+		if err != nil {
+			return nil, err
 		}
-		if item.Hour > maxHour {
-			maxHour = item.Hour
+		for _, m := range merchants {
+			weekend, err := MerchantWeekend(m)
+			if err != nil {
+				return nil, err
+			}
+			zones = append(zones, ZoneLabel{MerchantName: m.Name, Timezone: m.Timezone, WeekendDays: weekend.Days()})
 		}
+		// 与原有行为保持一致：按时区排序
+		sort.SliceStable(zones, func(i, j int) bool { return zones[i].Timezone < zones[j].Timezone })
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("遍历时区对比数据失败: %w", err)
-	}
-
-	// 计算统计信息
-	totalCount := len(comparison.Comparisons)
-	if totalCount > 0 {
-		comparison.Statistics = models.TimezoneStatistics{
-			BusinessHourCount: businessHourCount,
-			WeekendCount:      weekendCount,
-			AverageHour:       totalHours / float64(totalCount),
-			TimezoneSpread:    maxHour - minHour,
-		}
+	items, err := CompareAt(utcTime, zones)
+	if err != nil {
+		return nil, err
 	}
 
-	return comparison, nil
+	return &models.TimezoneComparison{
+		UTCTime:     utcTime.Format(time.RFC3339),
+		Comparisons: items,
+		Statistics:  ComputeTimezoneStatistics(items),
+	}, nil
 }
 
-// GetTimezoneDemo 获取时区演示数据
-func (s *TimezoneService) GetTimezoneDemo() (*models.TimezoneDemo, error) {
-	// 使用一个固定的UTC时间进行演示
-	utcTime := time.Date(2024, 8, 19, 0, 0, 0, 0, time.UTC)
+// GetTimezoneDemo 获取 asOf 时刻的时区演示数据：按商户列表在 Go 中换算各商户时区的本地时间，按时区排序
+// 结果只取决于 asOf、商户的时区和 tzdata 版本
+func (s *TimezoneService) GetTimezoneDemo(asOf time.Time) (*models.TimezoneDemo, error) {
+	utcTime := asOf.UTC()
 	utcTimeStr := utcTime.Format(time.RFC3339)
 
 	demo := &models.TimezoneDemo{
@@ -450,48 +504,16 @@ func (s *TimezoneService) GetTimezoneDemo() (*models.TimezoneDemo, error) {
 	}
 
 	// 获取所有商户的时区信息
-	query := `
-		SELECT 
-			timezone, country, city,
-			$1::timestamptz AT TIME ZONE timezone as local_time,
-			($1::timestamptz AT TIME ZONE timezone)::date as local_date,
-			TO_CHAR($1::timestamptz AT TIME ZONE timezone, 'TZ') as offset
-		FROM dim_merchant
-		ORDER BY timezone
-	`
-
-	rows, err := s.db.Query(query, utcTime)
+	conversions, err := s.conversionsAt(utcTime)
 	if err != nil {
-		return nil, fmt.Errorf("查询时区演示数据失败: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
 
 	var nextDayCount, sameDayCount, prevDayCount int
 	var minOffset, maxOffset int = 24, -24
 	utcDate := utcTime.Format("2006-01-02")
 
-	for rows.Next() {
-		var conversion models.TimezoneConversion
-		var localTime time.Time
-		var localDate time.Time
-		var offset string
-
-		err := rows.Scan(
-			&conversion.Timezone,
-			&conversion.Country,
-			&conversion.City,
-			&localTime,
-			&localDate,
-			&offset,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("扫描时区演示数据失败: %w", err)
-		}
-
-		conversion.LocalTime = localTime.Format("2006-01-02 15:04:05")
-		conversion.LocalDate = localDate.Format("2006-01-02")
-		conversion.Offset = offset
-
+	for _, conversion := range conversions {
 		// 判断日期关系
 		if conversion.LocalDate > utcDate {
 			conversion.IsNextDay = true
@@ -504,7 +526,7 @@ func (s *TimezoneService) GetTimezoneDemo() (*models.TimezoneDemo, error) {
 		}
 
 		// 解析时区偏移（简化处理）
-		if offsetHours, err := parseTimezoneOffset(offset); err == nil {
+		if offsetHours, err := parseTimezoneOffset(conversion.Offset); err == nil {
 			if offsetHours < minOffset {
 				minOffset = offsetHours
 			}
@@ -516,10 +538,6 @@ func (s *TimezoneService) GetTimezoneDemo() (*models.TimezoneDemo, error) {
 		demo.Timezones = append(demo.Timezones, conversion)
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("遍历时区演示数据失败: %w", err)
-	}
-
 	// 设置汇总信息
 	demo.Summary = models.TimezoneDemoSummary{
 		TotalTimezones: len(demo.Timezones),
@@ -533,6 +551,33 @@ func (s *TimezoneService) GetTimezoneDemo() (*models.TimezoneDemo, error) {
 	return demo, nil
 }
 
+// conversionsAt 指定 UTC 时刻在每个商户时区下的转换结果，按时区排序（同一时区按商户名称）
+func (s *TimezoneService) conversionsAt(utcTime time.Time) ([]models.TimezoneConversion, error) {
+	merchants, err := s.merchants.List()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(merchants, func(i, j int) bool { return merchants[i].Timezone < merchants[j].Timezone })
+
+	conversions := make([]models.TimezoneConversion, 0, len(merchants))
+	for _, merchant := range merchants {
+		loc, err := LoadLocation(merchant.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		local := utcTime.In(loc)
+		conversions = append(conversions, models.TimezoneConversion{
+			Timezone:  merchant.Timezone,
+			LocalTime: local.Format("2006-01-02 15:04:05"),
+			LocalDate: local.Format("2006-01-02"),
+			Offset:    local.Format("-07:00"),
+			Country:   merchant.Country,
+			City:      merchant.City,
+		})
+	}
+	return conversions, nil
+}
+
 // parseTimezoneOffset 解析时区偏移字符串
 func parseTimezoneOffset(offset string) (int, error) {
 	// 简化的时区偏移解析，实际应用中可能需要更复杂的逻辑
@@ -561,6 +606,36 @@ func parseTimezoneOffset(offset string) (int, error) {
 
 // HealthCheck 健康检查
 func (s *TimezoneService) HealthCheck() error {
+	// 仅在使用数据库仓储时检查连接和表结构
+	if s.db != nil {
+		if err := s.checkSchema(); err != nil {
+			return err
+		}
+	}
+
+	// 检查数据完整性
+	merchantCount, err := s.merchants.Count()
+	if err != nil {
+		return fmt.Errorf("获取商户数量失败: %w", err)
+	}
+	if merchantCount == 0 {
+		return fmt.Errorf("商户表为空")
+	}
+
+	orderCount, err := s.orders.Count()
+	if err != nil {
+		return fmt.Errorf("获取订单数量失败: %w", err)
+	}
+	if orderCount == 0 {
+		return fmt.Errorf("订单表为空")
+	}
+
+	log.Printf("✅ 时区服务健康检查通过: %d个商户, %d个订单", merchantCount, orderCount)
+	return nil
+}
+
+// checkSchema 检查数据库连接以及关键表、视图是否存在
+func (s *TimezoneService) checkSchema() error {
 	// 检查数据库连接
 	if err := s.db.Ping(); err != nil {
 		return fmt.Errorf("数据库连接失败: %w", err)
@@ -587,23 +662,5 @@ func (s *TimezoneService) HealthCheck() error {
 		return fmt.Errorf("分析视图不存在")
 	}
 
-	// 检查数据完整性
-	merchantCount, err := s.db.GetTableRowCount("dim_merchant")
-	if err != nil {
-		return fmt.Errorf("获取商户数量失败: %w", err)
-	}
-	if merchantCount == 0 {
-		return fmt.Errorf("商户表为空")
-	}
-
-	orderCount, err := s.db.GetTableRowCount("dws_orders")
-	if err != nil {
-		return fmt.Errorf("获取订单数量失败: %w", err)
-	}
-	if orderCount == 0 {
-		return fmt.Errorf("订单表为空")
-	}
-
-	log.Printf("✅ 时区服务健康检查通过: %d个商户, %d个订单", merchantCount, orderCount)
 	return nil
-}
\ No newline at end of file
+}
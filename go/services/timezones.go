@@ -0,0 +1,69 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"timezone-saas-demo/tzdb"
+)
+
+// locationCache 已加载的时区，避免每次请求都读取 zoneinfo 文件
+var locationCache sync.Map
+
+// LoadLocation 加载 IANA 时区（带缓存），设置了 TZDATA_DIR 时从该目录读取
+// 与 time.LoadLocation 不同，空字符串和 Local 会被视为非法参数，避免意外使用服务器本地时区
+func LoadLocation(name string) (*time.Location, error) {
+	if cached, ok := locationCache.Load(name); ok {
+		return cached.(*time.Location), nil
+	}
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("%w: 无效的时区 %q", ErrInvalidArgument, name)
+	}
+
+	loc, err := tzdb.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 无效的时区 %q", ErrInvalidArgument, name)
+	}
+	locationCache.Store(name, loc)
+	return loc, nil
+}
+
+// ReloadTimezoneData 重新加载外部 zoneinfo 目录并清空时区缓存，之后的请求使用新的规则
+// 未设置 TZDATA_DIR 时同样清空缓存，但 time 包会继续使用启动时读取的系统 tzdata
+func ReloadTimezoneData() (tzdb.Info, error) {
+	if err := tzdb.SetDir(tzdb.Dir()); err != nil {
+		return tzdb.Info{}, fmt.Errorf("重新加载 tzdata 失败: %w", err)
+	}
+	locationCache.Range(func(key, _ any) bool {
+		locationCache.Delete(key)
+		return true
+	})
+	return tzdb.Detect(), nil
+}
+
+// formatOffset 将秒数偏移格式化为 +08:00 形式
+func formatOffset(offsetSeconds int) string {
+	sign := '+'
+	if offsetSeconds < 0 {
+		sign = '-'
+		offsetSeconds = -offsetSeconds
+	}
+	return fmt.Sprintf("%c%02d:%02d", sign, offsetSeconds/3600, offsetSeconds%3600/60)
+}
+
+// formatOffsetDifference 将相对 UTC 的偏移格式化为 +8小时 / +5小时30分 形式
+func formatOffsetDifference(offsetSeconds int) string {
+	hours := offsetSeconds / 3600
+	minutes := offsetSeconds % 3600 / 60
+	if minutes == 0 {
+		return fmt.Sprintf("%+d小时", hours)
+	}
+	if minutes < 0 {
+		minutes = -minutes
+	}
+	if hours == 0 && offsetSeconds < 0 {
+		return fmt.Sprintf("-0小时%d分", minutes)
+	}
+	return fmt.Sprintf("%+d小时%d分", hours, minutes)
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"timezone-saas-demo/models"
+)
+
+// 接口用量统计的上限，超过后新的路由、参数或取值不再单独统计
+const (
+	// maxUsageRoutes 统计的路由数，路由按模板统计，正常情况下远少于该值
+	maxUsageRoutes = 500
+	// maxUsageParams 每个路由统计的查询参数数
+	maxUsageParams = 30
+	// maxUsageValues 每个参数单独统计的取值数，其余取值计入 other
+	maxUsageValues = 50
+	// maxUsageValueLength 取值超过该长度时计入 other，避免统计自由文本
+	maxUsageValueLength = 100
+)
+
+// usageParam 一个参数的出现次数和取值分布
+type usageParam struct {
+	requests int64
+	values   map[string]int64
+	other    int64
+}
+
+// record 记录一次取值，不可重放（被脱敏）的参数只记录出现次数
+func (p *usageParam) record(value string, replayable bool) {
+	p.requests++
+	if !replayable {
+		return
+	}
+	if _, ok := p.values[value]; !ok && (len(p.values) >= maxUsageValues || len(value) > maxUsageValueLength) {
+		p.other++
+		return
+	}
+	p.values[value]++
+}
+
+// usageRoute 一个路由的请求数和参数分布
+type usageRoute struct {
+	method   string
+	path     string
+	requests int64
+	tenants  *usageParam
+	vars     map[string]*usageParam
+	query    map[string]*usageParam
+}
+
+// UsageMeter 按路由模板统计 API 请求数，以及路径变量、查询参数和租户（X-Tenant-ID）的取值分布，用于导出与生产流量同分布的压测场景
+// 参数取值按脱敏策略处理：被脱敏的参数只统计出现比例，不保留取值；统计只在本进程的内存中，Reset 后重新开始
+type UsageMeter struct {
+	now func() time.Time
+
+	mu     sync.Mutex
+	since  time.Time
+	routes map[string]*usageRoute
+}
+
+// NewUsageMeter 创建接口用量统计
+func NewUsageMeter() *UsageMeter {
+	return &UsageMeter{now: time.Now, since: time.Now(), routes: make(map[string]*usageRoute)}
+}
+
+// Record 记录一次请求，path 为路由模板（如 /api/timezone/merchants/{id:[0-9]+}），vars 为路径变量的取值
+func (m *UsageMeter) Record(method, path string, vars map[string]string, query url.Values, tenant string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := method + " " + path
+	route, ok := m.routes[key]
+	if !ok {
+		if len(m.routes) >= maxUsageRoutes {
+			return
+		}
+		route = &usageRoute{
+			method:  method,
+			path:    path,
+			tenants: newUsageParam(),
+			vars:    map[string]*usageParam{},
+			query:   map[string]*usageParam{},
+		}
+		m.routes[key] = route
+	}
+	route.requests++
+	route.tenants.record(tenant, true)
+	for name, value := range vars {
+		usageParamFor(route.vars, name).record(value, true)
+	}
+	for name, values := range query {
+		if p := usageParamFor(route.query, name); p != nil && len(values) > 0 {
+			p.record(values[0], Replayable(name))
+		}
+	}
+}
+
+// Profile 各路由的请求数、占比和参数分布，按请求数倒序；methods 不为空时只包含这些方法的路由，占比按包含的路由计算
+func (m *UsageMeter) Profile(methods ...string) *models.UsageProfile {
+	profile := &models.UsageProfile{Routes: []models.RouteUsage{}}
+	if m == nil {
+		return profile
+	}
+	include := map[string]bool{}
+	for _, method := range methods {
+		include[method] = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	profile.Since = m.since.UTC()
+	profile.GeneratedAt = m.now().UTC()
+	for _, route := range m.routes {
+		if len(include) > 0 && !include[route.method] {
+			continue
+		}
+		profile.TotalRequests += route.requests
+		profile.Routes = append(profile.Routes, models.RouteUsage{
+			Method:   route.method,
+			Path:     route.path,
+			Requests: route.requests,
+			Tenants:  route.tenants.usage("X-Tenant-ID", route.requests).Values,
+			PathVars: usageParams(route.vars, route.requests),
+			Query:    usageParams(route.query, route.requests),
+		})
+	}
+	for i := range profile.Routes {
+		profile.Routes[i].Weight = float64(profile.Routes[i].Requests) / float64(profile.TotalRequests)
+	}
+	sort.Slice(profile.Routes, func(i, j int) bool {
+		if profile.Routes[i].Requests != profile.Routes[j].Requests {
+			return profile.Routes[i].Requests > profile.Routes[j].Requests
+		}
+		return profile.Routes[i].Method+" "+profile.Routes[i].Path < profile.Routes[j].Method+" "+profile.Routes[j].Path
+	})
+	return profile
+}
+
+// Reset 清空统计，从当前时刻重新开始
+func (m *UsageMeter) Reset() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.since = m.now()
+	m.routes = make(map[string]*usageRoute)
+}
+
+func newUsageParam() *usageParam {
+	return &usageParam{values: map[string]int64{}}
+}
+
+// usageParamFor 取出或创建参数的统计，参数数达到上限时返回 nil
+func usageParamFor(params map[string]*usageParam, name string) *usageParam {
+	p, ok := params[name]
+	if !ok {
+		if len(params) >= maxUsageParams {
+			return nil
+		}
+		p = newUsageParam()
+		params[name] = p
+	}
+	return p
+}
+
+// usage 参数在 requests 个请求中的出现比例和取值分布，取值按次数倒序
+func (p *usageParam) usage(name string, requests int64) models.ParamUsage {
+	usage := models.ParamUsage{
+		Name:       name,
+		Requests:   p.requests,
+		Presence:   float64(p.requests) / float64(requests),
+		Values:     make([]models.UsageValue, 0, len(p.values)),
+		OtherCount: p.other,
+		Redacted:   p.requests > 0 && len(p.values) == 0 && p.other == 0,
+	}
+	for value, count := range p.values {
+		usage.Values = append(usage.Values, models.UsageValue{Value: value, Count: count})
+	}
+	sort.Slice(usage.Values, func(i, j int) bool {
+		if usage.Values[i].Count != usage.Values[j].Count {
+			return usage.Values[i].Count > usage.Values[j].Count
+		}
+		return usage.Values[i].Value < usage.Values[j].Value
+	})
+	return usage
+}
+
+// usageParams 各参数的分布，按名称排序
+func usageParams(params map[string]*usageParam, requests int64) []models.ParamUsage {
+	result := make([]models.ParamUsage, 0, len(params))
+	for name, p := range params {
+		result = append(result, p.usage(name, requests))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"timezone-saas-demo/geo"
+	"timezone-saas-demo/models"
+)
+
+// Weekend 周末包含的星期，第 n 位对应 time.Weekday(n)
+type Weekend uint8
+
+// DefaultWeekend 周六周日，与 dim_merchant.weekend_days 的列默认值一致
+var DefaultWeekend = NewWeekend(time.Saturday, time.Sunday)
+
+// NewWeekend 由星期列表构造周末
+func NewWeekend(days ...time.Weekday) Weekend {
+	var w Weekend
+	for _, day := range days {
+		w |= 1 << uint(day)
+	}
+	return w
+}
+
+// ParseWeekendDays 解析星期序号列表（0=周日 … 6=周六），可以为空（没有周末），但不能包含全部 7 天
+func ParseWeekendDays(days []int) (Weekend, error) {
+	var w Weekend
+	for _, day := range days {
+		if day < 0 || day > 6 {
+			return 0, fmt.Errorf("%w: 无效的周末星期 %d，应为 0（周日）~6（周六）", ErrInvalidArgument, day)
+		}
+		w |= 1 << uint(day)
+	}
+	if w == NewWeekend(time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday) {
+		return 0, fmt.Errorf("%w: 周末不能包含全部 7 天", ErrInvalidArgument)
+	}
+	return w, nil
+}
+
+// Contains 是否为周末
+func (w Weekend) Contains(day time.Weekday) bool {
+	return w&(1<<uint(day)) != 0
+}
+
+// Days 周末的星期序号，按 0（周日）~6（周六）排序
+func (w Weekend) Days() []int {
+	days := []int{}
+	for day := 0; day < 7; day++ {
+		if w.Contains(time.Weekday(day)) {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// CountryWeekend 国家的默认周末，取自国家参考数据（dim_country.weekend_days）
+// 国家可以是代码、英文或中文名称，不在参考数据中的国家为周六周日
+func CountryWeekend(country string) Weekend {
+	c, ok := geo.FindCountry(country)
+	if !ok {
+		return DefaultWeekend
+	}
+	var w Weekend
+	for _, day := range c.WeekendDays {
+		w |= 1 << uint(day)
+	}
+	return w
+}
+
+// TimezoneWeekend 时区所在国家的默认周末，用于没有商户信息的时区对比
+// 时区不在城市数据集中时为周六周日
+func TimezoneWeekend(timezone string) Weekend {
+	for _, city := range geo.Cities() {
+		if city.Timezone == timezone {
+			return CountryWeekend(city.CountryCode)
+		}
+	}
+	return DefaultWeekend
+}
+
+// MerchantWeekend 商户的周末，未配置（nil）时按国家取默认值
+func MerchantWeekend(merchant models.Merchant) (Weekend, error) {
+	if merchant.WeekendDays == nil {
+		return CountryWeekend(merchant.Country), nil
+	}
+	w, err := ParseWeekendDays(merchant.WeekendDays)
+	if err != nil {
+		return 0, fmt.Errorf("商户 %d 周末配置错误: %w", merchant.ID, err)
+	}
+	return w, nil
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"time"
+
+	"timezone-saas-demo/models"
+)
+
+// ZoneLabel 参与对比的时区，MerchantName 为空表示调用方直接指定的时区
+// WeekendDays 为 nil 时使用时区所在国家的默认周末
+type ZoneLabel struct {
+	MerchantName string
+	Timezone     string
+	WeekendDays  []int
+}
+
+// CompareAt 纯 Go 计算同一 UTC 时刻在各时区的本地时间，不依赖数据库
+// 规则：周末按商户配置或时区所在国家判断，本地 9-17 点为工作时间
+func CompareAt(utcTime time.Time, zones []ZoneLabel) ([]models.TimezoneComparisonItem, error) {
+	items := make([]models.TimezoneComparisonItem, 0, len(zones))
+	for _, zone := range zones {
+		loc, err := LoadLocation(zone.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		weekend := TimezoneWeekend(zone.Timezone)
+		if zone.WeekendDays != nil {
+			if weekend, err = ParseWeekendDays(zone.WeekendDays); err != nil {
+				return nil, err
+			}
+		}
+
+		local := utcTime.In(loc)
+		_, offset := local.Zone()
+		weekday := local.Weekday()
+
+		items = append(items, models.TimezoneComparisonItem{
+			MerchantName:   zone.MerchantName,
+			Timezone:       zone.Timezone,
+			LocalTime:      local.Format("2006-01-02 15:04:05"),
+			LocalDate:      local.Format("2006-01-02"),
+			Hour:           local.Hour(),
+			DayOfWeek:      weekday.String(),
+			Weekday:        int(weekday),
+			IsWeekend:      weekend.Contains(weekday),
+			WeekendDays:    weekend.Days(),
+			IsBusinessHour: local.Hour() >= 9 && local.Hour() <= 17,
+			TimeDifference: formatOffsetDifference(offset),
+			Offset:         formatOffset(offset),
+			OffsetSeconds:  offset,
+			Abbreviation:   local.Format("MST"),
+			IsDST:          local.IsDST(),
+		})
+	}
+	return items, nil
+}
+
+// ComputeTimezoneStatistics 计算对比结果的统计信息
+// 时区跨度按 UTC 偏移计算，不受本地时间跨日影响
+func ComputeTimezoneStatistics(items []models.TimezoneComparisonItem) models.TimezoneStatistics {
+	var stats models.TimezoneStatistics
+	if len(items) == 0 {
+		return stats
+	}
+
+	var totalHours float64
+	minOffset, maxOffset := items[0].OffsetSeconds, items[0].OffsetSeconds
+	for _, item := range items {
+		if item.IsBusinessHour {
+			stats.BusinessHourCount++
+		}
+		if item.IsWeekend {
+			stats.WeekendCount++
+		}
+		totalHours += float64(item.Hour)
+		if item.OffsetSeconds < minOffset {
+			minOffset = item.OffsetSeconds
+		}
+		if item.OffsetSeconds > maxOffset {
+			maxOffset = item.OffsetSeconds
+		}
+	}
+
+	stats.AverageHour = totalHours / float64(len(items))
+	stats.TimezoneSpread = (maxOffset - minOffset) / 3600
+	return stats
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// startupOpenPaths 后台连接数据库期间仍然可以访问的路径，其他 API 返回 503
+var startupOpenPaths = []string{"/api/health", "/api/docs"}
+
+// startupState 设置 SERVE_BEFORE_DB_READY=true 时，服务在数据库连接成功前就开始监听
+// 期间存活探针正常返回，就绪探针和业务接口返回 503；连接成功并初始化各服务后才标记为就绪
+type startupState struct {
+	// pending 是否仍在等待数据库，各服务全局变量在 pending 变为 false 之前不可读取
+	pending atomic.Bool
+
+	mu       sync.Mutex
+	since    time.Time
+	attempts int
+	lastErr  error
+}
+
+// startup 服务的启动状态
+var startup startupState
+
+// begin 标记开始在后台连接数据库
+func (s *startupState) begin() {
+	s.mu.Lock()
+	s.since = time.Now()
+	s.mu.Unlock()
+	s.pending.Store(true)
+}
+
+// retrying 记录一次失败的连接尝试
+func (s *startupState) retrying(attempt int, err error, wait time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts = attempt
+	s.lastErr = err
+}
+
+// finish 数据库已连接、各服务已初始化
+func (s *startupState) finish() {
+	s.pending.Store(false)
+}
+
+// err 仍在等待数据库时返回原因，已就绪时返回 nil
+func (s *startupState) err() error {
+	if !s.pending.Load() {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	waited := time.Since(s.since).Round(time.Second)
+	if s.lastErr == nil {
+		return fmt.Errorf("正在连接数据库（已等待 %s）", waited)
+	}
+	return fmt.Errorf("正在连接数据库（已等待 %s，尝试 %d 次）: %v", waited, s.attempts, s.lastErr)
+}
+
+// startupMiddleware 数据库连接成功前，除健康检查和文档外的请求返回 503
+func startupMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := startup.err(); err != nil && !startupOpen(r.URL.Path) {
+			w.Header().Set("Retry-After", "5")
+			respondError(w, r, http.StatusServiceUnavailable, "health.not_ready", err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// startupOpen 路径是否在启动期间可以访问
+func startupOpen(path string) bool {
+	for _, prefix := range startupOpenPaths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
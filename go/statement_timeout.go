@@ -0,0 +1,23 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"timezone-saas-demo/database"
+)
+
+// statementTimeoutMiddleware 为请求中执行的 SQL 语句设置 statement_timeout，失控的聚合查询由数据库中止，不会长时间占用连接
+// timeout 在 serve 启动时才确定，因此传入指针；为 0 时不设置，使用外层中间件或数据库的默认值
+func statementTimeoutMiddleware(timeout *time.Duration) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if *timeout > 0 {
+				r = r.WithContext(database.WithStatementTimeout(r.Context(), *timeout))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
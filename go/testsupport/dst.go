@@ -0,0 +1,139 @@
+package testsupport
+
+import (
+	"fmt"
+	"time"
+
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/models"
+)
+
+// DSTMerchant 夏令时用例使用的商户
+type DSTMerchant struct {
+	Code     string
+	Name     string
+	Timezone string
+	Country  string
+	City     string
+}
+
+// DSTCase 夏令时边界用例：一笔订单的 UTC 时间及其期望的本地派生字段
+type DSTCase struct {
+	Name          string
+	OrderNo       string
+	Merchant      DSTMerchant
+	OrderTimeUTC  time.Time
+	WantLocalDate string
+	WantLocalHour int
+	// WantOffset 期望的时区偏移（秒），与视图的 timezone_offset 一致
+	WantOffset int
+}
+
+var (
+	dstBerlin  = DSTMerchant{Code: "TEST_DST_BERLIN", Name: "DST测试-柏林", Timezone: "Europe/Berlin", Country: "德国", City: "柏林"}
+	dstNewYork = DSTMerchant{Code: "TEST_DST_NYC", Name: "DST测试-纽约", Timezone: "America/New_York", Country: "美国", City: "纽约"}
+	dstSydney  = DSTMerchant{Code: "TEST_DST_SYDNEY", Name: "DST测试-悉尼", Timezone: "Australia/Sydney", Country: "澳大利亚", City: "悉尼"}
+)
+
+// DSTCases 覆盖夏令时开始（本地时间跳过一小时）和结束（本地时间重复一小时）的订单
+var DSTCases = []DSTCase{
+	{
+		// 柏林 2024-03-31 02:00 CET 跳到 03:00 CEST，01:30 仍是冬令时
+		Name: "柏林夏令时开始前", OrderNo: "DST-BER-0331-0130", Merchant: dstBerlin,
+		OrderTimeUTC:  time.Date(2024, 3, 31, 0, 30, 0, 0, time.UTC),
+		WantLocalDate: "2024-03-31", WantLocalHour: 1, WantOffset: 3600,
+	},
+	{
+		Name: "柏林夏令时开始后", OrderNo: "DST-BER-0331-0330", Merchant: dstBerlin,
+		OrderTimeUTC:  time.Date(2024, 3, 31, 1, 30, 0, 0, time.UTC),
+		WantLocalDate: "2024-03-31", WantLocalHour: 3, WantOffset: 7200,
+	},
+	{
+		// 柏林 2024-10-27 03:00 CEST 回拨到 02:00 CET，02:30 出现两次
+		Name: "柏林夏令时结束-第一次02:30", OrderNo: "DST-BER-1027-0230A", Merchant: dstBerlin,
+		OrderTimeUTC:  time.Date(2024, 10, 27, 0, 30, 0, 0, time.UTC),
+		WantLocalDate: "2024-10-27", WantLocalHour: 2, WantOffset: 7200,
+	},
+	{
+		Name: "柏林夏令时结束-第二次02:30", OrderNo: "DST-BER-1027-0230B", Merchant: dstBerlin,
+		OrderTimeUTC:  time.Date(2024, 10, 27, 1, 30, 0, 0, time.UTC),
+		WantLocalDate: "2024-10-27", WantLocalHour: 2, WantOffset: 3600,
+	},
+	{
+		// 纽约 2024-03-10 02:00 EST 跳到 03:00 EDT
+		Name: "纽约夏令时开始后", OrderNo: "DST-NYC-0310-0330", Merchant: dstNewYork,
+		OrderTimeUTC:  time.Date(2024, 3, 10, 7, 30, 0, 0, time.UTC),
+		WantLocalDate: "2024-03-10", WantLocalHour: 3, WantOffset: -4 * 3600,
+	},
+	{
+		// 纽约本地日期比 UTC 早一天
+		Name: "纽约跨日", OrderNo: "DST-NYC-0310-2330", Merchant: dstNewYork,
+		OrderTimeUTC:  time.Date(2024, 3, 11, 3, 30, 0, 0, time.UTC),
+		WantLocalDate: "2024-03-10", WantLocalHour: 23, WantOffset: -4 * 3600,
+	},
+	{
+		// 悉尼（南半球）2024-04-07 03:00 AEDT 回拨到 02:00 AEST
+		Name: "悉尼夏令时结束-第一次02:30", OrderNo: "DST-SYD-0407-0230A", Merchant: dstSydney,
+		OrderTimeUTC:  time.Date(2024, 4, 6, 15, 30, 0, 0, time.UTC),
+		WantLocalDate: "2024-04-07", WantLocalHour: 2, WantOffset: 11 * 3600,
+	},
+	{
+		Name: "悉尼夏令时结束-第二次02:30", OrderNo: "DST-SYD-0407-0230B", Merchant: dstSydney,
+		OrderTimeUTC:  time.Date(2024, 4, 6, 16, 30, 0, 0, time.UTC),
+		WantLocalDate: "2024-04-07", WantLocalHour: 2, WantOffset: 10 * 3600,
+	},
+}
+
+// DSTAmount 每笔夏令时用例订单的金额
+const DSTAmount = 100.0
+
+// SeedDSTFixtures 将夏令时用例写入数据库，返回商户编码到商户ID的映射
+// 重复执行是安全的：商户按编码 upsert，订单按订单号跳过已存在的记录
+func SeedDSTFixtures(db *database.DB) (map[string]int, error) {
+	merchantIDs := map[string]int{}
+
+	for _, c := range DSTCases {
+		m := c.Merchant
+		if _, ok := merchantIDs[m.Code]; !ok {
+			var id int
+			err := db.QueryRow(`
+				INSERT INTO dim_merchant (merchant_name, merchant_code, country, city, timezone, status)
+				VALUES ($1, $2, $3, $4, $5, 'active')
+				ON CONFLICT (merchant_code) DO UPDATE SET timezone = EXCLUDED.timezone
+				RETURNING merchant_id
+			`, m.Name, m.Code, m.Country, m.City, m.Timezone).Scan(&id)
+			if err != nil {
+				return nil, fmt.Errorf("写入夏令时测试商户 %s 失败: %w", m.Code, err)
+			}
+			merchantIDs[m.Code] = id
+		}
+
+		_, err := db.Exec(`
+			INSERT INTO dws_orders (order_no, merchant_id, order_amount, currency, order_status, order_time_utc)
+			VALUES ($1, $2, $3, 'USD', 'paid', $4)
+			ON CONFLICT (merchant_id, order_no) DO NOTHING
+		`, c.OrderNo, merchantIDs[m.Code], DSTAmount, c.OrderTimeUTC)
+		if err != nil {
+			return nil, fmt.Errorf("写入夏令时测试订单 %s 失败: %w", c.OrderNo, err)
+		}
+	}
+
+	return merchantIDs, nil
+}
+
+// AddDSTFixtures 将夏令时用例写入内存仓储，商户ID从 firstID 开始分配
+func (f *Fakes) AddDSTFixtures(firstID int) map[string]models.Merchant {
+	merchants := map[string]models.Merchant{}
+	for i, c := range DSTCases {
+		m, ok := merchants[c.Merchant.Code]
+		if !ok {
+			m = NewMerchant(firstID+len(merchants), c.Merchant.Name, c.Merchant.Timezone, c.Merchant.Country, c.Merchant.City)
+			merchants[c.Merchant.Code] = m
+			f.Merchants.Add(m)
+		}
+		order := NewOrderAnalysis(m, 900000+i, DSTAmount, c.OrderTimeUTC)
+		order.OrderNumber = c.OrderNo
+		f.Orders.Add(order)
+	}
+	return merchants
+}
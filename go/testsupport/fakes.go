@@ -0,0 +1,3302 @@
+// Package testsupport 提供 repository 接口的内存实现和测试数据构造工具，
+// 用于在不依赖 PostgreSQL 的情况下测试服务层逻辑。
+package testsupport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/repository"
+)
+
+// 编译期检查内存实现满足仓储接口
+var (
+	_ repository.MerchantRepository         = (*MerchantRepository)(nil)
+	_ repository.OrderRepository            = (*OrderRepository)(nil)
+	_ repository.AnalysisRepository         = (*AnalysisRepository)(nil)
+	_ repository.CombinedAnalysisRepository = (*AnalysisRepository)(nil)
+	_ repository.BatchAnalysisRepository    = (*AnalysisRepository)(nil)
+	_ repository.BillingRepository          = (*BillingRepository)(nil)
+	_ repository.SnapshotRepository         = (*SnapshotRepository)(nil)
+
+	_ repository.FulfillmentAnalysisRepository = (*AnalysisRepository)(nil)
+
+	_ repository.OnboardingRepository = (*OnboardingRepository)(nil)
+	_ repository.RefundRepository     = (*RefundRepository)(nil)
+	_ repository.IndexStatsRepository = (*IndexStatsRepository)(nil)
+	_ repository.SettingsRepository   = (*SettingsRepository)(nil)
+	_ repository.OverviewRepository   = (*OverviewRepository)(nil)
+
+	_ repository.ConsistencyRepository   = (*ConsistencyRepository)(nil)
+	_ repository.OrganizationRepository  = (*OrganizationRepository)(nil)
+	_ repository.ReportRepository        = (*ReportRepository)(nil)
+	_ repository.AlertRepository         = (*AlertRepository)(nil)
+	_ repository.EmailTemplateRepository = (*EmailTemplateRepository)(nil)
+	_ repository.DashboardRepository     = (*DashboardRepository)(nil)
+	_ repository.ChangeRepository        = (*ChangeRepository)(nil)
+	_ repository.SyncRepository          = (*SyncRepository)(nil)
+	_ repository.IngestRepository        = (*IngestRepository)(nil)
+
+	_ repository.LifecycleRepository    = (*LifecycleRepository)(nil)
+	_ repository.ProvisioningRepository = (*ProvisioningRepository)(nil)
+)
+
+// MerchantRepository 内存商户仓储
+type MerchantRepository struct {
+	mu        sync.RWMutex
+	merchants []models.Merchant
+	// changes 相当于 change_log 的触发器，为 nil 时不记录变更
+	changes *ChangeRepository
+
+	// Err 不为 nil 时所有方法返回该错误，用于模拟数据库故障
+	Err error
+}
+
+// NewMerchantRepository 创建内存商户仓储
+func NewMerchantRepository(merchants ...models.Merchant) *MerchantRepository {
+	return &MerchantRepository{merchants: merchants}
+}
+
+// Add 添加商户
+func (r *MerchantRepository) Add(merchants ...models.Merchant) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.merchants = append(r.merchants, merchants...)
+	for _, m := range merchants {
+		r.changes.recordMerchant(models.ChangeOpInsert, m)
+	}
+}
+
+// List 获取所有商户，按名称排序
+func (r *MerchantRepository) List() ([]models.Merchant, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	merchants := append([]models.Merchant(nil), r.merchants...)
+	sort.SliceStable(merchants, func(i, j int) bool {
+		return merchants[i].Name < merchants[j].Name
+	})
+	return merchants, nil
+}
+
+// Get 按ID获取商户
+func (r *MerchantRepository) Get(id int) (*models.Merchant, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, merchant := range r.merchants {
+		if merchant.ID == id {
+			m := merchant
+			return &m, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: 商户 %d", repository.ErrNotFound, id)
+}
+
+// Count 获取商户数量
+func (r *MerchantRepository) Count() (int, error) {
+	if r.Err != nil {
+		return 0, r.Err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.merchants), nil
+}
+
+// OrderRepository 内存订单仓储
+type OrderRepository struct {
+	mu     sync.RWMutex
+	orders []models.OrderAnalysis
+	// meta 订单的版本号和更新时间，没有记录的订单版本为 1，更新时间为入库时间
+	meta map[int]orderMeta
+	// shipped 订单首次变为已发货或已送达的时刻，相当于 dws_orders.shipped_time_utc
+	shipped map[int]time.Time
+	// changes 相当于 change_log 的触发器，为 nil 时不记录变更
+	changes *ChangeRepository
+
+	// Err 不为 nil 时所有方法返回该错误，用于模拟数据库故障
+	Err error
+}
+
+// NewOrderRepository 创建内存订单仓储
+func NewOrderRepository(orders ...models.OrderAnalysis) *OrderRepository {
+	return &OrderRepository{orders: orders}
+}
+
+// Add 添加订单
+func (r *OrderRepository) Add(orders ...models.OrderAnalysis) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.orders = append(r.orders, orders...)
+	for _, o := range orders {
+		r.changes.recordOrder(models.ChangeOpInsert, o, r.meta[o.OrderID])
+	}
+}
+
+// Snapshot 返回当前所有订单的副本
+func (r *OrderRepository) Snapshot() []models.OrderAnalysis {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]models.OrderAnalysis(nil), r.orders...)
+}
+
+// SetStatus 修改订单状态，订单不存在时返回 false
+func (r *OrderRepository) SetStatus(orderID int, status string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.orders {
+		if r.orders[i].OrderID == orderID {
+			changed := r.orders[i].Status != status
+			r.markShipped(orderID, r.orders[i].Status, status, time.Now())
+			r.orders[i].Status = status
+			if changed {
+				r.changes.recordOrder(models.ChangeOpUpdate, r.orders[i], r.touch(orderID, time.Now()))
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// SetShippedTime 设置订单的发货时间，用于准备履约时长数据
+func (r *OrderRepository) SetShippedTime(orderID int, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.shipped == nil {
+		r.shipped = map[int]time.Time{}
+	}
+	r.shipped[orderID] = at.UTC()
+}
+
+// markShipped 相当于 set_orders_shipped_time 触发器：订单首次从未发货变为已发货或已送达时记录发货时间；调用方持有写锁
+func (r *OrderRepository) markShipped(orderID int, from, to string, now time.Time) {
+	if !isShippedStatus(to) || isShippedStatus(from) {
+		return
+	}
+	if _, ok := r.shipped[orderID]; ok {
+		return
+	}
+	if r.shipped == nil {
+		r.shipped = map[int]time.Time{}
+	}
+	r.shipped[orderID] = now.UTC()
+}
+
+// shippedTimes 返回发货时间的副本
+func (r *OrderRepository) shippedTimes() map[int]time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	shipped := make(map[int]time.Time, len(r.shipped))
+	for id, at := range r.shipped {
+		shipped[id] = at
+	}
+	return shipped
+}
+
+// isShippedStatus 订单状态是否表示已发货
+func isShippedStatus(status string) bool {
+	return status == models.OrderStatusShipped || status == models.OrderStatusDelivered
+}
+
+// orderMeta 内存订单的版本号和更新时间，相当于 dws_orders 的 version 和 updated_at
+type orderMeta struct {
+	version   int
+	updatedAt time.Time
+}
+
+// touch 订单被修改：版本号加一，更新时间为 now；调用方持有写锁
+func (r *OrderRepository) touch(orderID int, now time.Time) orderMeta {
+	if r.meta == nil {
+		r.meta = map[int]orderMeta{}
+	}
+	meta := r.meta[orderID]
+	if meta.version == 0 {
+		meta.version = 1
+	}
+	meta.version++
+	meta.updatedAt = now.UTC()
+	r.meta[orderID] = meta
+	return meta
+}
+
+// syncOrder 按ID（orderID 大于 0 时）或商户下的订单号查找订单，返回与 dws_orders 一致的订单行
+func (r *OrderRepository) syncOrder(orderID, merchantID int, orderNumber string) *models.Order {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, o := range r.orders {
+		if (orderID > 0 && o.OrderID == orderID) || (orderID <= 0 && o.MerchantID == merchantID && o.OrderNumber == orderNumber) {
+			order := orderRow(o, r.meta[o.OrderID])
+			return &order
+		}
+	}
+	return nil
+}
+
+// saveSyncOrder 写入离线同步的订单：ID 为 0 时新建并分配ID，否则更新并使版本号加一，本地时间字段按商户时区重新计算
+func (r *OrderRepository) saveSyncOrder(o models.Order, merchant models.Merchant) models.Order {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now().UTC()
+	row := NewOrderAnalysis(merchant, o.ID, 0, o.OrderTimeUTC)
+	row.OrderNumber = o.OrderNumber
+	row.Amount = o.Amount
+	row.Currency = o.Currency
+	row.Status = o.Status
+
+	if o.ID == 0 {
+		for _, existing := range r.orders {
+			if existing.OrderID > row.OrderID {
+				row.OrderID = existing.OrderID
+			}
+		}
+		row.OrderID++
+		row.IngestedAt = now
+		r.orders = append(r.orders, row)
+		r.changes.recordOrder(models.ChangeOpInsert, row, r.meta[row.OrderID])
+		return orderRow(row, r.meta[row.OrderID])
+	}
+	for i := range r.orders {
+		if r.orders[i].OrderID == o.ID {
+			row.IngestedAt = r.orders[i].IngestedAt
+			r.markShipped(o.ID, r.orders[i].Status, row.Status, now)
+			r.orders[i] = row
+			meta := r.touch(o.ID, now)
+			r.changes.recordOrder(models.ChangeOpUpdate, row, meta)
+			return orderRow(row, meta)
+		}
+	}
+	return o
+}
+
+// orderRow 内存订单对应的 dws_orders 行；内存订单没有创建时间，以入库时间代替，时刻精确到秒
+func orderRow(o models.OrderAnalysis, meta orderMeta) models.Order {
+	created := o.IngestedAt
+	if created.IsZero() {
+		created = o.OrderTimeUTC
+	}
+	if meta.version == 0 {
+		meta.version = 1
+	}
+	if meta.updatedAt.IsZero() {
+		meta.updatedAt = created
+	}
+	return models.Order{
+		ID:           o.OrderID,
+		MerchantID:   o.MerchantID,
+		OrderNumber:  o.OrderNumber,
+		Amount:       o.Amount,
+		Currency:     o.Currency,
+		Status:       o.Status,
+		OrderTimeUTC: o.OrderTimeUTC.UTC().Truncate(time.Second),
+		Version:      meta.version,
+		CreatedAt:    created.UTC().Truncate(time.Second),
+		UpdatedAt:    meta.updatedAt.UTC().Truncate(time.Second),
+	}
+}
+
+// find 按ID查找订单
+func (r *OrderRepository) find(orderID int) (models.OrderAnalysis, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, order := range r.orders {
+		if order.OrderID == orderID {
+			return order, true
+		}
+	}
+	return models.OrderAnalysis{}, false
+}
+
+// List 分页获取订单，timezone 为空时不过滤，按 UTC 时间倒序
+// filter.Formatting 为 raw 时与 PostgreSQL 实现一样不返回 local_date、local_weekday；不支持按自定义属性过滤
+func (r *OrderRepository) List(filter models.OrderFilter) ([]models.OrderAnalysis, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	var orders []models.OrderAnalysis
+	for _, order := range r.Snapshot() {
+		if (filter.Timezone == "" || order.Timezone == filter.Timezone) && matchStatus(filter.Statuses, order.Status) &&
+			(filter.OrderID == 0 || order.OrderID == filter.OrderID) && matchMerchant(filter.MerchantIDs, order.MerchantID) &&
+			(filter.From == "" || order.LocalDate >= filter.From) && (filter.To == "" || order.LocalDate <= filter.To) {
+			if filter.Formatting == models.OrderFormattingRaw {
+				order.LocalDate, order.LocalWeekday = "", ""
+			}
+			orders = append(orders, order)
+		}
+	}
+	sort.SliceStable(orders, func(i, j int) bool {
+		return orders[i].OrderTimeUTC.After(orders[j].OrderTimeUTC)
+	})
+
+	if filter.Offset >= len(orders) {
+		return nil, nil
+	}
+	orders = orders[filter.Offset:]
+	if filter.Limit < len(orders) {
+		orders = orders[:filter.Limit]
+	}
+	return orders, nil
+}
+
+// Stream 逐条回调订单，条件和顺序与 List 相同，filter.Limit 为 0 时不限制条数
+func (r *OrderRepository) Stream(ctx context.Context, filter models.OrderFilter, fn func(models.OrderAnalysis) error) error {
+	if filter.Limit == 0 {
+		filter.Limit = math.MaxInt
+	}
+	orders, err := r.List(filter)
+	if err != nil {
+		return err
+	}
+	for _, order := range orders {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(order); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EstimateCount 返回符合条件的订单数，内存实现总是精确的
+func (r *OrderRepository) EstimateCount(ctx context.Context, filter models.OrderFilter) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	filter.Limit, filter.Offset = math.MaxInt, 0
+	orders, err := r.List(filter)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(orders)), nil
+}
+
+// Count 获取订单数量
+func (r *OrderRepository) Count() (int, error) {
+	if r.Err != nil {
+		return 0, r.Err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.orders), nil
+}
+
+// AnalysisRepository 内存分析仓储，聚合结果基于 OrderRepository 中的订单实时计算
+type AnalysisRepository struct {
+	orders *OrderRepository
+
+	// Err 不为 nil 时所有方法返回该错误，用于模拟数据库故障
+	Err error
+	// Delays 按方法名模拟慢查询，如 {"TopMerchants": time.Second}；等待期间 ctx 结束时返回 ctx.Err()
+	Delays map[string]time.Duration
+}
+
+// NewAnalysisRepository 创建内存分析仓储
+func NewAnalysisRepository(orders *OrderRepository) *AnalysisRepository {
+	return &AnalysisRepository{orders: orders}
+}
+
+// Name 返回存储后端名称
+func (r *AnalysisRepository) Name() string {
+	return "memory"
+}
+
+// OrderSummary 按币种分组获取订单汇总
+func (r *AnalysisRepository) OrderSummary(ctx context.Context, filter models.AnalysisFilter) ([]models.CurrencyTotal, error) {
+	orders, err := r.ordersOn(ctx, "OrderSummary", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	byCurrency := map[string]*models.CurrencyTotal{}
+	for _, order := range orders {
+		t, ok := byCurrency[order.Currency]
+		if !ok {
+			t = &models.CurrencyTotal{Currency: order.Currency}
+			byCurrency[order.Currency] = t
+		}
+		t.OrderCount++
+		t.GrossAmount = t.GrossAmount.Add(order.Amount)
+	}
+
+	var result []models.CurrencyTotal
+	for _, t := range byCurrency {
+		result = append(result, *t)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Currency < result[j].Currency })
+	return result, nil
+}
+
+// HourlyBreakdown 获取按小时分解的数据
+func (r *AnalysisRepository) HourlyBreakdown(ctx context.Context, filter models.AnalysisFilter) ([]models.HourlyOrderBreakdown, error) {
+	orders, err := r.ordersOn(ctx, "HourlyBreakdown", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	byHour := map[int]*models.HourlyOrderBreakdown{}
+	for _, order := range orders {
+		b, ok := byHour[order.LocalHour]
+		if !ok {
+			b = &models.HourlyOrderBreakdown{Hour: order.LocalHour}
+			byHour[order.LocalHour] = b
+		}
+		b.Currency = groupCurrency(b.Currency, b.OrderCount, order.Currency)
+		b.OrderCount++
+		b.TotalAmount = b.TotalAmount.Add(order.Amount)
+	}
+
+	var result []models.HourlyOrderBreakdown
+	for _, b := range byHour {
+		b.AvgAmount = b.TotalAmount.Div(decimal.NewFromInt(int64(b.OrderCount)))
+		result = append(result, *b)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Hour < result[j].Hour })
+	return result, nil
+}
+
+// TimezoneStats 获取时区统计
+func (r *AnalysisRepository) TimezoneStats(ctx context.Context, filter models.AnalysisFilter) ([]models.TimezoneOrderStats, error) {
+	orders, err := r.ordersOn(ctx, "TimezoneStats", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	byZone := map[[2]string]*models.TimezoneOrderStats{}
+	for _, order := range orders {
+		key := [2]string{order.Timezone, order.Country}
+		s, ok := byZone[key]
+		if !ok {
+			s = &models.TimezoneOrderStats{Timezone: order.Timezone, Country: order.Country}
+			byZone[key] = s
+		}
+		s.Currency = groupCurrency(s.Currency, s.OrderCount, order.Currency)
+		s.OrderCount++
+		s.TotalAmount = s.TotalAmount.Add(order.Amount)
+	}
+
+	var result []models.TimezoneOrderStats
+	for _, s := range byZone {
+		s.AvgAmount = s.TotalAmount.Div(decimal.NewFromInt(int64(s.OrderCount)))
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].TotalAmount.Equal(result[j].TotalAmount) {
+			return result[i].TotalAmount.GreaterThan(result[j].TotalAmount)
+		}
+		if result[i].Timezone != result[j].Timezone {
+			return result[i].Timezone < result[j].Timezone
+		}
+		return result[i].Country < result[j].Country
+	})
+	return result, nil
+}
+
+// TopMerchants 获取顶级商户
+func (r *AnalysisRepository) TopMerchants(ctx context.Context, filter models.AnalysisFilter, limit int) ([]models.MerchantOrderStats, error) {
+	orders, err := r.ordersOn(ctx, "TopMerchants", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	byMerchant := map[int]*models.MerchantOrderStats{}
+	for _, order := range orders {
+		s, ok := byMerchant[order.MerchantID]
+		if !ok {
+			s = &models.MerchantOrderStats{
+				MerchantID:   order.MerchantID,
+				MerchantName: order.MerchantName,
+				Timezone:     order.Timezone,
+			}
+			byMerchant[order.MerchantID] = s
+		}
+		s.Currency = groupCurrency(s.Currency, s.OrderCount, order.Currency)
+		s.OrderCount++
+		s.TotalAmount = s.TotalAmount.Add(order.Amount)
+	}
+
+	var result []models.MerchantOrderStats
+	for _, s := range byMerchant {
+		s.AvgAmount = s.TotalAmount.Div(decimal.NewFromInt(int64(s.OrderCount)))
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].TotalAmount.Equal(result[j].TotalAmount) {
+			return result[i].TotalAmount.GreaterThan(result[j].TotalAmount)
+		}
+		return result[i].MerchantID < result[j].MerchantID
+	})
+	if limit < len(result) {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// Aggregates 一次返回全部分析聚合，结果与分别调用各单项方法一致，Delays["Aggregates"] 模拟整条语句的耗时
+func (r *AnalysisRepository) Aggregates(ctx context.Context, filter models.AnalysisFilter, limit int) (*models.AnalysisAggregates, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	if err := sleepContext(ctx, r.Delays["Aggregates"]); err != nil {
+		return nil, err
+	}
+
+	var aggregates models.AnalysisAggregates
+	var err error
+	if aggregates.Summary, err = r.OrderSummary(ctx, filter); err != nil {
+		return nil, err
+	}
+	if aggregates.Hourly, err = r.HourlyBreakdown(ctx, filter); err != nil {
+		return nil, err
+	}
+	if aggregates.Timezones, err = r.TimezoneStats(ctx, filter); err != nil {
+		return nil, err
+	}
+	if aggregates.TopMerchants, err = r.TopMerchants(ctx, filter, limit); err != nil {
+		return nil, err
+	}
+	return &aggregates, nil
+}
+
+// AggregatesByDate 逐个日期调用 Aggregates，没有订单的日期不返回；Delays["AggregatesByDate"] 模拟整条语句的耗时
+func (r *AnalysisRepository) AggregatesByDate(ctx context.Context, dates []string, filter models.AnalysisFilter, limit int) (map[string]*models.AnalysisAggregates, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	if err := sleepContext(ctx, r.Delays["AggregatesByDate"]); err != nil {
+		return nil, err
+	}
+
+	result := map[string]*models.AnalysisAggregates{}
+	for _, date := range dates {
+		filter.LocalDate = date
+		aggregates, err := r.Aggregates(ctx, filter, limit)
+		if err != nil {
+			return nil, err
+		}
+		if len(aggregates.Summary) > 0 {
+			result[date] = aggregates
+		}
+	}
+	return result, nil
+}
+
+// WeeklyMerchantOrders 按订单的本地日期分周，与 SQL 实现一致；Delays["WeeklyMerchantOrders"] 模拟查询耗时
+func (r *AnalysisRepository) WeeklyMerchantOrders(ctx context.Context, filter models.CohortFilter) ([]models.MerchantWeeklyOrders, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	if err := sleepContext(ctx, r.Delays["WeeklyMerchantOrders"]); err != nil {
+		return nil, err
+	}
+
+	type weekKey struct {
+		merchantID int
+		weekStart  string
+	}
+	counts := map[weekKey]int{}
+	for _, order := range r.orders.Snapshot() {
+		if order.LocalDate < filter.From || order.LocalDate >= filter.To || !matchStatus(filter.Statuses, order.Status) {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", order.LocalDate)
+		if err != nil {
+			return nil, err
+		}
+		isoWeekday := (int(date.Weekday())+6)%7 + 1
+		start := date.AddDate(0, 0, -((isoWeekday - filter.WeekStart + 7) % 7))
+		counts[weekKey{order.MerchantID, start.Format("2006-01-02")}]++
+	}
+
+	result := make([]models.MerchantWeeklyOrders, 0, len(counts))
+	for key, count := range counts {
+		result = append(result, models.MerchantWeeklyOrders{MerchantID: key.merchantID, WeekStart: key.weekStart, OrderCount: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].MerchantID != result[j].MerchantID {
+			return result[i].MerchantID < result[j].MerchantID
+		}
+		return result[i].WeekStart < result[j].WeekStart
+	})
+	return result, nil
+}
+
+// UTCHourlyOrders 按订单 UTC 时刻所在整点、时区和 is_business_hour 分组，与 SQL 实现一致
+func (r *AnalysisRepository) UTCHourlyOrders(ctx context.Context, filter models.UTCHourFilter) ([]models.UTCHourTimezoneOrders, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	if err := sleepContext(ctx, r.Delays["UTCHourlyOrders"]); err != nil {
+		return nil, err
+	}
+
+	type hourKey struct {
+		start    time.Time
+		timezone string
+		business bool
+	}
+	counts := map[hourKey]int{}
+	for _, order := range r.orders.Snapshot() {
+		at := order.OrderTimeUTC.UTC()
+		if at.Before(filter.Start) || !at.Before(filter.End) || !matchStatus(filter.Statuses, order.Status) {
+			continue
+		}
+		counts[hourKey{at.Truncate(time.Hour), order.Timezone, order.IsBusinessHour}]++
+	}
+
+	result := make([]models.UTCHourTimezoneOrders, 0, len(counts))
+	for key, count := range counts {
+		result = append(result, models.UTCHourTimezoneOrders{HourStart: key.start, Timezone: key.timezone, IsBusinessHour: key.business, OrderCount: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if !a.HourStart.Equal(b.HourStart) {
+			return a.HourStart.Before(b.HourStart)
+		}
+		if a.Timezone != b.Timezone {
+			return a.Timezone < b.Timezone
+		}
+		return !a.IsBusinessHour && b.IsBusinessHour
+	})
+	return result, nil
+}
+
+// MerchantHourlyOrders 按订单的本地日期和小时分组，与 SQL 实现一致
+func (r *AnalysisRepository) MerchantHourlyOrders(ctx context.Context, filter models.MerchantHourFilter) ([]models.LocalDateHourOrders, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	if err := sleepContext(ctx, r.Delays["MerchantHourlyOrders"]); err != nil {
+		return nil, err
+	}
+
+	type hourKey struct {
+		date string
+		hour int
+	}
+	counts := map[hourKey]int{}
+	for _, order := range r.orders.Snapshot() {
+		if order.MerchantID != filter.MerchantID || order.LocalDate < filter.From || order.LocalDate >= filter.To || !matchStatus(filter.Statuses, order.Status) {
+			continue
+		}
+		counts[hourKey{order.LocalDate, order.LocalHour}]++
+	}
+
+	result := make([]models.LocalDateHourOrders, 0, len(counts))
+	for key, count := range counts {
+		result = append(result, models.LocalDateHourOrders{LocalDate: key.date, LocalHour: key.hour, OrderCount: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].LocalDate != result[j].LocalDate {
+			return result[i].LocalDate < result[j].LocalDate
+		}
+		return result[i].LocalHour < result[j].LocalHour
+	})
+	return result, nil
+}
+
+// MerchantFulfillmentDurations 按订单的发货时间计算履约秒数，与 SQL 实现一致
+func (r *AnalysisRepository) MerchantFulfillmentDurations(ctx context.Context, filter models.MerchantHourFilter) ([]models.OrderFulfillment, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	if err := sleepContext(ctx, r.Delays["MerchantFulfillmentDurations"]); err != nil {
+		return nil, err
+	}
+
+	shipped := r.orders.shippedTimes()
+	orders := r.orders.Snapshot()
+	sort.SliceStable(orders, func(i, j int) bool { return orders[i].OrderTimeUTC.Before(orders[j].OrderTimeUTC) })
+	result := []models.OrderFulfillment{}
+	for _, order := range orders {
+		at, ok := shipped[order.OrderID]
+		if !ok || at.Before(order.OrderTimeUTC) || order.MerchantID != filter.MerchantID ||
+			order.LocalDate < filter.From || order.LocalDate >= filter.To || !matchStatus(filter.Statuses, order.Status) {
+			continue
+		}
+		result = append(result, models.OrderFulfillment{
+			LocalHour:      order.LocalHour,
+			LocalDayOfWeek: order.LocalDayOfWeek,
+			Seconds:        int64(at.Sub(order.OrderTimeUTC) / time.Second),
+		})
+	}
+	return result, nil
+}
+
+// matchStatus statuses 为空或包含 status 时返回 true
+func matchStatus(statuses []string, status string) bool {
+	if len(statuses) == 0 {
+		return true
+	}
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// matchMerchant merchantIDs 为空或包含 merchantID 时返回 true
+func matchMerchant(merchantIDs []int, merchantID int) bool {
+	if len(merchantIDs) == 0 {
+		return true
+	}
+	for _, id := range merchantIDs {
+		if id == merchantID {
+			return true
+		}
+	}
+	return false
+}
+
+// groupCurrency 分组币种：与已有订单一致时保持不变，出现第二种币种后为空，与 SQL 实现一致
+func groupCurrency(current string, count int, next string) string {
+	if count == 0 || current == next {
+		return next
+	}
+	return ""
+}
+
+// ordersOn 获取指定本地日期、指定状态的订单，method 对应 Delays 中模拟的查询耗时
+func (r *AnalysisRepository) ordersOn(ctx context.Context, method string, filter models.AnalysisFilter) ([]models.OrderAnalysis, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	if err := sleepContext(ctx, r.Delays[method]); err != nil {
+		return nil, err
+	}
+
+	var loc *time.Location
+	if filter.AggregateTimezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(filter.AggregateTimezone); err != nil {
+			return nil, fmt.Errorf("加载时区 %s 失败: %w", filter.AggregateTimezone, err)
+		}
+	}
+	var result []models.OrderAnalysis
+	for _, order := range r.orders.Snapshot() {
+		if loc != nil {
+			// 与 SQL 实现一致，按汇总时区由 order_time_utc 重新计算本地日期和小时
+			t := order.OrderTimeUTC.In(loc)
+			order.LocalDate, order.LocalHour = t.Format("2006-01-02"), t.Hour()
+		}
+		if order.LocalDate == filter.LocalDate && matchStatus(filter.Statuses, order.Status) {
+			result = append(result, order)
+		}
+	}
+	return result, nil
+}
+
+// sleepContext 等待 d，ctx 先结束时返回 ctx.Err()
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// BillingRepository 内存计费仓储
+type BillingRepository struct {
+	mu            sync.RWMutex
+	subscriptions map[int]models.Subscription
+	periods       []models.BillingPeriod
+	nextID        int64
+
+	// Err 不为 nil 时所有方法返回该错误，用于模拟数据库故障
+	Err error
+}
+
+// NewBillingRepository 创建内存计费仓储
+func NewBillingRepository() *BillingRepository {
+	return &BillingRepository{subscriptions: map[int]models.Subscription{}}
+}
+
+// SetSubscription 设置商户订阅配置
+func (r *BillingRepository) SetSubscription(sub models.Subscription) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscriptions[sub.MerchantID] = sub
+}
+
+// Subscription 获取商户订阅配置
+func (r *BillingRepository) Subscription(merchantID int) (*models.Subscription, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sub, ok := r.subscriptions[merchantID]
+	if !ok {
+		return nil, fmt.Errorf("%w: 商户 %d 的订阅配置", repository.ErrNotFound, merchantID)
+	}
+	return &sub, nil
+}
+
+// SavePeriods 保存计费周期，已存在的（商户+序号）记录保持不变
+func (r *BillingRepository) SavePeriods(periods []models.BillingPeriod) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, p := range periods {
+		exists := false
+		for _, saved := range r.periods {
+			if saved.MerchantID == p.MerchantID && saved.Sequence == p.Sequence {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			r.nextID++
+			p.PeriodID = r.nextID
+			r.periods = append(r.periods, p)
+		}
+	}
+	return nil
+}
+
+// ListPeriods 获取商户已保存的计费周期，按序号排序
+func (r *BillingRepository) ListPeriods(merchantID int) ([]models.BillingPeriod, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var periods []models.BillingPeriod
+	for _, p := range r.periods {
+		if p.MerchantID == merchantID {
+			periods = append(periods, p)
+		}
+	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Sequence < periods[j].Sequence })
+	return periods, nil
+}
+
+// SnapshotRepository 内存日结仓储，订单统计基于 OrderRepository 中的订单
+type SnapshotRepository struct {
+	mu          sync.RWMutex
+	orders      *OrderRepository
+	merchants   *MerchantRepository
+	snapshots   []models.DailyRevenueSnapshot
+	adjustments []models.DailyRevenueAdjustment
+	nextID      int64
+	nextAdjID   int64
+
+	// Err 不为 nil 时所有方法返回该错误，用于模拟数据库故障
+	Err error
+}
+
+// NewSnapshotRepository 创建内存日结仓储
+func NewSnapshotRepository(merchants *MerchantRepository, orders *OrderRepository) *SnapshotRepository {
+	return &SnapshotRepository{merchants: merchants, orders: orders}
+}
+
+// LastClosedDate 获取商户最近一次结账的本地日期
+func (r *SnapshotRepository) LastClosedDate(merchantID int) (string, error) {
+	if r.Err != nil {
+		return "", r.Err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	last := ""
+	for _, s := range r.snapshots {
+		if s.MerchantID == merchantID && s.LocalDate > last {
+			last = s.LocalDate
+		}
+	}
+	return last, nil
+}
+
+// EarliestOrderTime 获取商户最早一笔订单的 UTC 时间
+func (r *SnapshotRepository) EarliestOrderTime(merchantID int) (*time.Time, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	var earliest *time.Time
+	for _, order := range r.orders.Snapshot() {
+		if order.MerchantID != merchantID {
+			continue
+		}
+		if earliest == nil || order.OrderTimeUTC.Before(*earliest) {
+			t := order.OrderTimeUTC
+			earliest = &t
+		}
+	}
+	return earliest, nil
+}
+
+// WindowTotals 统计商户在 UTC 区间内的订单数和金额
+func (r *SnapshotRepository) WindowTotals(merchantID int, start, end time.Time) (int, decimal.Decimal, error) {
+	if r.Err != nil {
+		return 0, decimal.Zero, r.Err
+	}
+
+	var count int
+	amount := decimal.Zero
+	for _, order := range r.orders.Snapshot() {
+		if order.MerchantID == merchantID && !order.OrderTimeUTC.Before(start) && order.OrderTimeUTC.Before(end) {
+			count++
+			amount = amount.Add(order.Amount)
+		}
+	}
+	return count, amount, nil
+}
+
+// Create 写入快照，同一商户同一日期已存在时返回 false
+func (r *SnapshotRepository) Create(snapshot models.DailyRevenueSnapshot) (bool, error) {
+	if r.Err != nil {
+		return false, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, s := range r.snapshots {
+		if s.MerchantID == snapshot.MerchantID && s.LocalDate == snapshot.LocalDate {
+			return false, nil
+		}
+	}
+	r.nextID++
+	snapshot.SnapshotID = r.nextID
+	snapshot.ClosedAt = time.Now().UTC()
+	r.snapshots = append(r.snapshots, snapshot)
+	return true, nil
+}
+
+// ListByDate 获取指定本地日期的所有快照，按商户ID排序
+func (r *SnapshotRepository) ListByDate(date string) ([]models.DailyRevenueSnapshot, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var result []models.DailyRevenueSnapshot
+	for _, s := range r.snapshots {
+		if s.LocalDate != date {
+			continue
+		}
+		if m, err := r.merchants.Get(s.MerchantID); err == nil {
+			s.MerchantName = m.Name
+		}
+		s.AdjustedOrderCount = s.OrderCount
+		s.AdjustedAmount = s.TotalAmount
+		for _, a := range r.adjustments {
+			if a.SnapshotID == s.SnapshotID {
+				s.AdjustmentCount++
+				s.AdjustedOrderCount += a.OrderCountDelta
+				s.AdjustedAmount = s.AdjustedAmount.Add(a.AmountDelta)
+			}
+		}
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].MerchantID < result[j].MerchantID })
+	return result, nil
+}
+
+// LateOrders 获取入库时间晚于所属快照结账时间的订单
+func (r *SnapshotRepository) LateOrders(filter models.LateOrderFilter) ([]models.LateOrder, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	orders := r.orders.Snapshot()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var result []models.LateOrder
+	for _, s := range r.snapshots {
+		if (filter.LocalDate != "" && s.LocalDate != filter.LocalDate) || (filter.MerchantID != 0 && s.MerchantID != filter.MerchantID) {
+			continue
+		}
+		for _, order := range orders {
+			if order.MerchantID != s.MerchantID || order.OrderTimeUTC.Before(s.WindowStartUTC) ||
+				!order.OrderTimeUTC.Before(s.WindowEndUTC) || !order.IngestedAt.After(s.ClosedAt) {
+				continue
+			}
+			late := models.LateOrder{
+				OrderID:      order.OrderID,
+				OrderNumber:  order.OrderNumber,
+				MerchantID:   order.MerchantID,
+				MerchantName: order.MerchantName,
+				Timezone:     s.Timezone,
+				Amount:       order.Amount,
+				OrderTimeUTC: order.OrderTimeUTC,
+				IngestedAt:   order.IngestedAt,
+				LocalDate:    s.LocalDate,
+				SnapshotID:   s.SnapshotID,
+				ClosedAt:     s.ClosedAt,
+				DelaySeconds: int64(order.IngestedAt.Sub(order.OrderTimeUTC).Seconds()),
+			}
+			for _, a := range r.adjustments {
+				if a.SnapshotID == s.SnapshotID && a.OrderID != nil && *a.OrderID == order.OrderID {
+					id := a.AdjustmentID
+					late.AdjustmentID = &id
+				}
+			}
+			if filter.UnadjustedOnly && late.AdjustmentID != nil {
+				continue
+			}
+			result = append(result, late)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].LocalDate != result[j].LocalDate {
+			return result[i].LocalDate < result[j].LocalDate
+		}
+		return result[i].OrderTimeUTC.Before(result[j].OrderTimeUTC)
+	})
+	return result, nil
+}
+
+// AddAdjustments 追加调整记录，同一快照+订单已有调整时跳过
+func (r *SnapshotRepository) AddAdjustments(adjustments []models.DailyRevenueAdjustment) ([]models.DailyRevenueAdjustment, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var created []models.DailyRevenueAdjustment
+	for _, a := range adjustments {
+		if a.OrderID != nil && r.hasOrderAdjustment(a.SnapshotID, *a.OrderID) {
+			continue
+		}
+		r.nextAdjID++
+		a.AdjustmentID = r.nextAdjID
+		a.CreatedAt = time.Now().UTC()
+		r.adjustments = append(r.adjustments, a)
+		created = append(created, a)
+	}
+	return created, nil
+}
+
+// hasOrderAdjustment 判断订单是否已对快照做过调整，调用方需持有锁
+func (r *SnapshotRepository) hasOrderAdjustment(snapshotID int64, orderID int) bool {
+	for _, a := range r.adjustments {
+		if a.SnapshotID == snapshotID && a.OrderID != nil && *a.OrderID == orderID {
+			return true
+		}
+	}
+	return false
+}
+
+// OnboardingRepository 内存入驻仓储，创建的商户写入 MerchantRepository
+type OnboardingRepository struct {
+	mu        sync.Mutex
+	merchants *MerchantRepository
+	codes     map[string]int
+	reports   map[int]models.ReportSettings
+	webhooks  []models.WebhookSetting
+
+	// Err 不为 nil 时所有方法返回该错误，用于模拟数据库故障
+	Err error
+}
+
+// NewOnboardingRepository 创建内存入驻仓储
+func NewOnboardingRepository(merchants *MerchantRepository) *OnboardingRepository {
+	return &OnboardingRepository{
+		merchants: merchants,
+		codes:     map[string]int{},
+		reports:   map[int]models.ReportSettings{},
+	}
+}
+
+// Onboard 创建商户并保存默认配置，商户编码重复时返回 ErrConflict
+func (r *OnboardingRepository) Onboard(o *models.MerchantOnboarding) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.codes[o.MerchantCode]; ok {
+		return fmt.Errorf("%w: 商户编码 %s", repository.ErrConflict, o.MerchantCode)
+	}
+	return r.onboard(o)
+}
+
+// Import 先检查全部商户编码，都不重复时依次创建，任一编码重复时不创建任何商户
+func (r *OnboardingRepository) Import(ctx context.Context, onboardings []models.MerchantOnboarding) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen := map[string]bool{}
+	for _, o := range onboardings {
+		if _, ok := r.codes[o.MerchantCode]; ok || seen[o.MerchantCode] {
+			return fmt.Errorf("导入商户 %s 失败: %w: 商户编码 %s", o.Merchant.Name, repository.ErrConflict, o.MerchantCode)
+		}
+		seen[o.MerchantCode] = true
+	}
+	for i := range onboardings {
+		if err := r.onboard(&onboardings[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Catalog 全部商户，示例数据中的商户没有编码
+func (r *OnboardingRepository) Catalog(ctx context.Context) ([]models.MerchantRecord, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	merchants, err := r.merchants.List()
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	codes := make(map[int]string, len(r.codes))
+	for code, id := range r.codes {
+		codes[id] = code
+	}
+	records := make([]models.MerchantRecord, 0, len(merchants))
+	for _, m := range merchants {
+		records = append(records, models.MerchantRecord{Merchant: m, Code: codes[m.ID], Status: "active"})
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+	return records, nil
+}
+
+// onboard 分配ID并保存商户和默认配置，调用方需持有锁
+func (r *OnboardingRepository) onboard(o *models.MerchantOnboarding) error {
+	existing, err := r.merchants.List()
+	if err != nil {
+		return err
+	}
+	id := 0
+	for _, m := range existing {
+		if m.ID > id {
+			id = m.ID
+		}
+	}
+	now := time.Now().UTC()
+	o.Merchant.ID = id + 1
+	o.Merchant.CreatedAt = now
+	o.Merchant.UpdatedAt = now
+	o.ReportSettings.MerchantID = o.Merchant.ID
+	for i := range o.Webhooks {
+		o.Webhooks[i].MerchantID = o.Merchant.ID
+		o.Webhooks[i].WebhookID = len(r.webhooks) + 1
+		r.webhooks = append(r.webhooks, o.Webhooks[i])
+	}
+
+	r.codes[o.MerchantCode] = o.Merchant.ID
+	r.reports[o.Merchant.ID] = o.ReportSettings
+	r.merchants.Add(o.Merchant)
+	return nil
+}
+
+// ReportSettings 获取商户的报表配置
+func (r *OnboardingRepository) ReportSettings(merchantID int) (models.ReportSettings, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	settings, ok := r.reports[merchantID]
+	return settings, ok
+}
+
+// RefundRepository 内存退款仓储，退款关联 OrderRepository 中的订单
+type RefundRepository struct {
+	mu      sync.Mutex
+	orders  *OrderRepository
+	refunds []models.Refund
+
+	// Err 不为 nil 时所有方法返回该错误，用于模拟数据库故障
+	Err error
+}
+
+// NewRefundRepository 创建内存退款仓储
+func NewRefundRepository(orders *OrderRepository) *RefundRepository {
+	return &RefundRepository{orders: orders}
+}
+
+// Order 获取订单及其退款记录
+func (r *RefundRepository) Order(orderID int) (*models.OrderRefunds, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	order, ok := r.orders.find(orderID)
+	if !ok {
+		return nil, fmt.Errorf("%w: 订单 %d", repository.ErrNotFound, orderID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := &models.OrderRefunds{
+		OrderID:      order.OrderID,
+		MerchantID:   order.MerchantID,
+		Timezone:     order.Timezone,
+		OrderTimeUTC: order.OrderTimeUTC,
+		Currency:     order.Currency,
+		Status:       order.Status,
+		OrderAmount:  order.Amount,
+		Refunds:      []models.Refund{},
+	}
+	for _, refund := range r.refunds {
+		if refund.OrderID == orderID {
+			result.RefundedAmount = result.RefundedAmount.Add(refund.Amount)
+			result.Refunds = append(result.Refunds, refund)
+		}
+	}
+	sort.SliceStable(result.Refunds, func(i, j int) bool {
+		return result.Refunds[i].RefundTimeUTC.Before(result.Refunds[j].RefundTimeUTC)
+	})
+	result.RemainingAmount = result.OrderAmount.Sub(result.RefundedAmount)
+	return result, nil
+}
+
+// Create 写入退款记录，累计退款超过订单金额时返回 ErrConflict，退满时将订单状态置为 refunded
+func (r *RefundRepository) Create(refund *models.Refund) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order, ok := r.orders.find(refund.OrderID)
+	if !ok {
+		return fmt.Errorf("%w: 订单 %d", repository.ErrNotFound, refund.OrderID)
+	}
+	refunded := refund.Amount
+	for _, existing := range r.refunds {
+		if existing.OrderID == refund.OrderID {
+			refunded = refunded.Add(existing.Amount)
+		}
+	}
+	if refunded.GreaterThan(order.Amount) {
+		return fmt.Errorf("%w: 订单 %d 累计退款 %s 超过订单金额 %s", repository.ErrConflict, refund.OrderID, refunded, order.Amount)
+	}
+
+	refund.RefundID = int64(len(r.refunds) + 1)
+	refund.MerchantID = order.MerchantID
+	refund.Currency = order.Currency
+	refund.CreatedAt = time.Now().UTC()
+	r.refunds = append(r.refunds, *refund)
+	if refunded.Equal(order.Amount) {
+		r.orders.SetStatus(order.OrderID, models.OrderStatusRefunded)
+	}
+	return nil
+}
+
+// Totals 按币种分组统计指定本地日期的退款，退款日期按订单所属商户时区换算，filter.AggregateTimezone 非空时订单日期和退款日期都按该时区换算
+func (r *RefundRepository) Totals(ctx context.Context, filter models.AnalysisFilter) ([]models.RefundTotal, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	refunds := append([]models.Refund(nil), r.refunds...)
+	r.mu.Unlock()
+
+	byCurrency := map[string]*models.RefundTotal{}
+	for _, refund := range refunds {
+		order, ok := r.orders.find(refund.OrderID)
+		if !ok || !matchStatus(filter.Statuses, order.Status) {
+			continue
+		}
+		timezone := order.Timezone
+		if filter.AggregateTimezone != "" {
+			timezone = filter.AggregateTimezone
+		}
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("加载时区 %s 失败: %w", timezone, err)
+		}
+		byOrderDate := order.LocalDate == filter.LocalDate
+		if filter.AggregateTimezone != "" {
+			byOrderDate = order.OrderTimeUTC.In(loc).Format("2006-01-02") == filter.LocalDate
+		}
+		byRefundDate := refund.RefundTimeUTC.In(loc).Format("2006-01-02") == filter.LocalDate
+		if !byOrderDate && !byRefundDate {
+			continue
+		}
+
+		t, ok := byCurrency[refund.Currency]
+		if !ok {
+			t = &models.RefundTotal{Currency: refund.Currency}
+			byCurrency[refund.Currency] = t
+		}
+		if byOrderDate {
+			t.ByOrderDate = t.ByOrderDate.Add(refund.Amount)
+		}
+		if byRefundDate {
+			t.ByRefundDate = t.ByRefundDate.Add(refund.Amount)
+		}
+	}
+
+	var result []models.RefundTotal
+	for _, t := range byCurrency {
+		result = append(result, *t)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Currency < result[j].Currency })
+	return result, nil
+}
+
+// TotalsByDate 逐个日期调用 Totals，没有退款的日期不返回
+func (r *RefundRepository) TotalsByDate(ctx context.Context, dates []string, filter models.AnalysisFilter) (map[string][]models.RefundTotal, error) {
+	result := map[string][]models.RefundTotal{}
+	for _, date := range dates {
+		filter.LocalDate = date
+		totals, err := r.Totals(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		if len(totals) > 0 {
+			result[date] = totals
+		}
+	}
+	return result, nil
+}
+
+// SettingsRepository 内存商户配置仓储，营业时间和周末同步写入 MerchantRepository 中的商户
+type SettingsRepository struct {
+	mu        sync.Mutex
+	merchants *MerchantRepository
+	settings  map[int]map[string]models.TenantSetting
+
+	// Err 不为 nil 时所有方法返回该错误，用于模拟数据库故障
+	Err error
+}
+
+// NewSettingsRepository 创建内存商户配置仓储
+func NewSettingsRepository(merchants *MerchantRepository) *SettingsRepository {
+	return &SettingsRepository{merchants: merchants, settings: map[int]map[string]models.TenantSetting{}}
+}
+
+// List 获取商户单独设置过的配置，按键排序
+func (r *SettingsRepository) List(merchantID int) ([]models.TenantSetting, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var settings []models.TenantSetting
+	for _, setting := range r.settings[merchantID] {
+		settings = append(settings, setting)
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Key < settings[j].Key })
+	return settings, nil
+}
+
+// Save 写入配置，calendar 不为 nil 时同步更新商户
+func (r *SettingsRepository) Save(setting *models.TenantSetting, calendar *models.MerchantCalendar) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.merchants.applyCalendar(setting.MerchantID, calendar); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	setting.UpdatedAt = &now
+	if r.settings[setting.MerchantID] == nil {
+		r.settings[setting.MerchantID] = map[string]models.TenantSetting{}
+	}
+	r.settings[setting.MerchantID][setting.Key] = *setting
+	return nil
+}
+
+// Delete 删除配置，calendar 不为 nil 时同步更新商户
+func (r *SettingsRepository) Delete(merchantID int, key string, calendar *models.MerchantCalendar) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.merchants.applyCalendar(merchantID, calendar); err != nil {
+		return err
+	}
+	delete(r.settings[merchantID], key)
+	return nil
+}
+
+// applyCalendar 更新商户的营业时间和周末，calendar 为 nil 时只检查商户是否存在
+func (r *MerchantRepository) applyCalendar(merchantID int, calendar *models.MerchantCalendar) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.merchants {
+		if r.merchants[i].ID != merchantID {
+			continue
+		}
+		if calendar != nil {
+			r.merchants[i].BusinessHoursStart = calendar.BusinessHoursStart
+			r.merchants[i].BusinessHoursEnd = calendar.BusinessHoursEnd
+			r.merchants[i].WeekendDays = append([]int{}, calendar.WeekendDays...)
+			r.merchants[i].UpdatedAt = time.Now().UTC()
+			r.changes.recordMerchant(models.ChangeOpUpdate, r.merchants[i])
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: 商户 %d", repository.ErrNotFound, merchantID)
+}
+
+// IndexStatsRepository 内存索引统计仓储，数据由测试直接填充
+type IndexStatsRepository struct {
+	mu         sync.Mutex
+	indexes    []models.IndexInfo
+	tables     []models.TableScanStats
+	statements []models.StatementStats
+
+	// StatementsErr 不为 nil 时 Statements 返回该错误，用于模拟 pg_stat_statements 不可用
+	StatementsErr error
+	// Err 不为 nil 时所有方法返回该错误，用于模拟数据库故障
+	Err error
+}
+
+// NewIndexStatsRepository 创建内存索引统计仓储
+func NewIndexStatsRepository() *IndexStatsRepository {
+	return &IndexStatsRepository{}
+}
+
+// AddIndex 添加一个索引
+func (r *IndexStatsRepository) AddIndex(index models.IndexInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.indexes = append(r.indexes, index)
+}
+
+// SetTableStats 设置表的扫描统计
+func (r *IndexStatsRepository) SetTableStats(stats models.TableScanStats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.tables {
+		if r.tables[i].Table == stats.Table {
+			r.tables[i] = stats
+			return
+		}
+	}
+	r.tables = append(r.tables, stats)
+}
+
+// AddStatement 添加一条语句统计
+func (r *IndexStatsRepository) AddStatement(statement models.StatementStats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statements = append(r.statements, statement)
+}
+
+// Indexes 获取指定表上的索引，按表名和索引名排序
+func (r *IndexStatsRepository) Indexes(ctx context.Context, tables []string) ([]models.IndexInfo, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var result []models.IndexInfo
+	for _, index := range r.indexes {
+		if containsString(tables, index.Table) {
+			result = append(result, index)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Table != result[j].Table {
+			return result[i].Table < result[j].Table
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// TableStats 获取指定表的扫描统计，按表名排序
+func (r *IndexStatsRepository) TableStats(ctx context.Context, tables []string) ([]models.TableScanStats, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var result []models.TableScanStats
+	for _, t := range r.tables {
+		if containsString(tables, t.Table) {
+			result = append(result, t)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Table < result[j].Table })
+	return result, nil
+}
+
+// Statements 获取总耗时最高的 limit 条语句
+func (r *IndexStatsRepository) Statements(ctx context.Context, tables []string, limit int) ([]models.StatementStats, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	if r.StatementsErr != nil {
+		return nil, r.StatementsErr
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := append([]models.StatementStats(nil), r.statements...)
+	sort.Slice(result, func(i, j int) bool { return result[i].TotalTimeMs > result[j].TotalTimeMs })
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// OverviewRepository 内存运营看板仓储，基于 MerchantRepository 和 OrderRepository 中的数据统计
+type OverviewRepository struct {
+	merchants *MerchantRepository
+	orders    *OrderRepository
+
+	// Err 不为 nil 时所有方法返回该错误，用于模拟数据库故障
+	Err error
+}
+
+// NewOverviewRepository 创建内存运营看板仓储
+func NewOverviewRepository(merchants *MerchantRepository, orders *OrderRepository) *OverviewRepository {
+	return &OverviewRepository{merchants: merchants, orders: orders}
+}
+
+// TenantActivity 获取每个商户的订单数和最近入库时间，按商户ID排序
+func (r *OverviewRepository) TenantActivity(ctx context.Context, since time.Time) ([]models.TenantActivity, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	merchants, err := r.merchants.List()
+	if err != nil {
+		return nil, err
+	}
+
+	byMerchant := make(map[int]*models.TenantActivity, len(merchants))
+	activity := make([]models.TenantActivity, len(merchants))
+	for i, m := range merchants {
+		activity[i] = models.TenantActivity{MerchantID: m.ID, MerchantName: m.Name, Timezone: m.Timezone}
+		byMerchant[m.ID] = &activity[i]
+	}
+	for _, order := range r.orders.Snapshot() {
+		a, ok := byMerchant[order.MerchantID]
+		if !ok {
+			continue
+		}
+		a.OrderCount++
+		if !order.IngestedAt.Before(since) {
+			a.RecentOrderCount++
+		}
+		if a.LastIngestedAt == nil || order.IngestedAt.After(*a.LastIngestedAt) {
+			t := order.IngestedAt.UTC()
+			a.LastIngestedAt = &t
+		}
+	}
+	sort.Slice(activity, func(i, j int) bool { return activity[i].MerchantID < activity[j].MerchantID })
+	return activity, nil
+}
+
+// ConsistencyRepository 内存一致性检查仓储
+// 分析视图一侧即 OrderRepository 中的订单；AddUnjoined 添加只存在于 dws_orders 的订单，用于模拟视图丢行
+type ConsistencyRepository struct {
+	orders *OrderRepository
+
+	mu       sync.Mutex
+	unjoined []models.OrderAnalysis
+	runs     []models.ConsistencyRun
+	nextID   int64
+
+	// Err 不为 nil 时所有方法返回该错误，用于模拟数据库故障
+	Err error
+}
+
+// NewConsistencyRepository 创建内存一致性检查仓储
+func NewConsistencyRepository(orders *OrderRepository) *ConsistencyRepository {
+	return &ConsistencyRepository{orders: orders}
+}
+
+// AddUnjoined 添加只存在于 dws_orders、在分析视图中缺失的订单
+func (r *ConsistencyRepository) AddUnjoined(orders ...models.OrderAnalysis) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.unjoined = append(r.unjoined, orders...)
+}
+
+// MerchantTotals 按商户统计订单数和金额，dws_orders 一侧包含 AddUnjoined 的订单
+func (r *ConsistencyRepository) MerchantTotals(ctx context.Context) ([]models.ConsistencyTotals, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	unjoined := append([]models.OrderAnalysis(nil), r.unjoined...)
+	r.mu.Unlock()
+
+	byMerchant := map[int]*models.ConsistencyTotals{}
+	get := func(merchantID int) *models.ConsistencyTotals {
+		t, ok := byMerchant[merchantID]
+		if !ok {
+			t = &models.ConsistencyTotals{MerchantID: merchantID}
+			byMerchant[merchantID] = t
+		}
+		return t
+	}
+	for _, order := range r.orders.Snapshot() {
+		t := get(order.MerchantID)
+		t.OrderCount++
+		t.OrderAmount = t.OrderAmount.Add(order.Amount)
+		t.ViewCount++
+		t.ViewAmount = t.ViewAmount.Add(order.Amount)
+	}
+	for _, order := range unjoined {
+		t := get(order.MerchantID)
+		t.OrderCount++
+		t.OrderAmount = t.OrderAmount.Add(order.Amount)
+	}
+
+	totals := make([]models.ConsistencyTotals, 0, len(byMerchant))
+	for _, t := range byMerchant {
+		totals = append(totals, *t)
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].MerchantID < totals[j].MerchantID })
+	return totals, nil
+}
+
+// SampleOrders 按订单ID顺序返回前 n 笔订单，结果是确定的
+func (r *ConsistencyRepository) SampleOrders(ctx context.Context, n int) ([]models.OrderAnalysis, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	orders := r.orders.Snapshot()
+	sort.Slice(orders, func(i, j int) bool { return orders[i].OrderID < orders[j].OrderID })
+	if len(orders) > n {
+		orders = orders[:n]
+	}
+	return orders, nil
+}
+
+// SaveRun 保存检查记录并回填ID
+func (r *ConsistencyRepository) SaveRun(ctx context.Context, run *models.ConsistencyRun) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	run.RunID = int64(len(r.runs) + 1)
+	for i := range run.Discrepancies {
+		r.nextID++
+		run.Discrepancies[i].ID = r.nextID
+		run.Discrepancies[i].RunID = run.RunID
+	}
+	saved := *run
+	saved.Discrepancies = append([]models.ConsistencyDiscrepancy(nil), run.Discrepancies...)
+	r.runs = append(r.runs, saved)
+	return nil
+}
+
+// Runs 最近 limit 次检查，按开始时间倒序，不含差异明细
+func (r *ConsistencyRepository) Runs(ctx context.Context, limit int) ([]models.ConsistencyRun, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var runs []models.ConsistencyRun
+	for i := len(r.runs) - 1; i >= 0 && len(runs) < limit; i-- {
+		run := r.runs[i]
+		run.Discrepancies = nil
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// Run 获取一次检查及其差异明细
+func (r *ConsistencyRepository) Run(ctx context.Context, runID int64) (*models.ConsistencyRun, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if runID < 1 || runID > int64(len(r.runs)) {
+		return nil, fmt.Errorf("%w: 一致性检查 %d", repository.ErrNotFound, runID)
+	}
+	run := r.runs[runID-1]
+	run.Discrepancies = append([]models.ConsistencyDiscrepancy(nil), run.Discrepancies...)
+	return &run, nil
+}
+
+// containsString values 中是否包含 s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// OrganizationRepository 内存组织仓储，门店归属保存在仓储内，订单汇总基于 OrderRepository 中的订单实时计算
+type OrganizationRepository struct {
+	merchants *MerchantRepository
+	orders    *OrderRepository
+
+	mu     sync.Mutex
+	orgs   []models.Organization
+	owners map[int]int
+	tokens []organizationToken
+
+	// Err 不为 nil 时所有方法返回该错误，用于模拟数据库故障
+	Err error
+}
+
+// organizationToken 保存的组织令牌及其摘要
+type organizationToken struct {
+	models.OrganizationToken
+	hash string
+}
+
+// NewOrganizationRepository 创建内存组织仓储
+func NewOrganizationRepository(merchants *MerchantRepository, orders *OrderRepository) *OrganizationRepository {
+	return &OrganizationRepository{merchants: merchants, orders: orders, owners: map[int]int{}}
+}
+
+// Create 创建组织并划入门店
+func (r *OrganizationRepository) Create(ctx context.Context, org *models.Organization) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, o := range r.orgs {
+		if o.Name == org.Name {
+			return fmt.Errorf("%w: 组织名称 %s", repository.ErrConflict, org.Name)
+		}
+	}
+	id := len(r.orgs) + 1
+	if err := r.assign(id, org.MerchantIDs); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	org.ID, org.CreatedAt, org.UpdatedAt = id, now, now
+	stored := *org
+	stored.MerchantIDs, stored.Locations = nil, nil
+	r.orgs = append(r.orgs, stored)
+	return nil
+}
+
+// List 全部组织
+func (r *OrganizationRepository) List(ctx context.Context) ([]models.Organization, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	orgs := make([]models.Organization, len(r.orgs))
+	for i, org := range r.orgs {
+		org.MerchantIDs = r.members(org.ID)
+		orgs[i] = org
+	}
+	return orgs, nil
+}
+
+// Get 按ID获取组织
+func (r *OrganizationRepository) Get(ctx context.Context, id int) (*models.Organization, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id < 1 || id > len(r.orgs) {
+		return nil, fmt.Errorf("%w: 组织 %d", repository.ErrNotFound, id)
+	}
+	org := r.orgs[id-1]
+	org.MerchantIDs = r.members(id)
+	return &org, nil
+}
+
+// SetMerchants 替换组织的门店
+func (r *OrganizationRepository) SetMerchants(ctx context.Context, id int, merchantIDs []int) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id < 1 || id > len(r.orgs) {
+		return fmt.Errorf("%w: 组织 %d", repository.ErrNotFound, id)
+	}
+	if err := r.assign(id, merchantIDs); err != nil {
+		return err
+	}
+	r.orgs[id-1].UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+// assign 检查全部商户后再修改归属，与 PostgreSQL 实现的事务一样要么全部生效要么不生效，调用方持有锁
+func (r *OrganizationRepository) assign(id int, merchantIDs []int) error {
+	for _, merchantID := range merchantIDs {
+		if _, err := r.merchants.Get(merchantID); err != nil {
+			return err
+		}
+		if owner, ok := r.owners[merchantID]; ok && owner != id {
+			return fmt.Errorf("%w: 商户 %d 已属于组织 %d", repository.ErrConflict, merchantID, owner)
+		}
+	}
+	for merchantID, owner := range r.owners {
+		if owner == id {
+			delete(r.owners, merchantID)
+		}
+	}
+	for _, merchantID := range merchantIDs {
+		r.owners[merchantID] = id
+	}
+	return nil
+}
+
+// members 组织的门店ID，按ID排序，调用方持有锁
+func (r *OrganizationRepository) members(id int) []int {
+	ids := []int{}
+	for merchantID, owner := range r.owners {
+		if owner == id {
+			ids = append(ids, merchantID)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// CreateToken 保存令牌摘要
+func (r *OrganizationRepository) CreateToken(ctx context.Context, token *models.OrganizationToken, hash string) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if token.OrganizationID < 1 || token.OrganizationID > len(r.orgs) {
+		return fmt.Errorf("%w: 组织 %d", repository.ErrNotFound, token.OrganizationID)
+	}
+	token.ID, token.CreatedAt = len(r.tokens)+1, time.Now().UTC()
+	stored := *token
+	stored.Token = ""
+	r.tokens = append(r.tokens, organizationToken{OrganizationToken: stored, hash: hash})
+	return nil
+}
+
+// Tokens 组织的全部令牌
+func (r *OrganizationRepository) Tokens(ctx context.Context, organizationID int) ([]models.OrganizationToken, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var tokens []models.OrganizationToken
+	for _, t := range r.tokens {
+		if t.OrganizationID == organizationID {
+			tokens = append(tokens, t.OrganizationToken)
+		}
+	}
+	return tokens, nil
+}
+
+// RevokeToken 吊销令牌
+func (r *OrganizationRepository) RevokeToken(ctx context.Context, organizationID, tokenID int) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.tokens {
+		t := &r.tokens[i]
+		if t.ID == tokenID && t.OrganizationID == organizationID && t.RevokedAt == nil {
+			now := time.Now().UTC()
+			t.RevokedAt = &now
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: 组织 %d 的令牌 %d", repository.ErrNotFound, organizationID, tokenID)
+}
+
+// TokenOrganization 查找令牌所属的组织并记录使用时间
+func (r *OrganizationRepository) TokenOrganization(ctx context.Context, hash string) (int, error) {
+	if r.Err != nil {
+		return 0, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.tokens {
+		t := &r.tokens[i]
+		if t.hash == hash && t.RevokedAt == nil {
+			now := time.Now().UTC()
+			t.LastUsedAt = &now
+			return t.OrganizationID, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: 组织令牌", repository.ErrNotFound)
+}
+
+// OrderTotals 按门店、小时和币种聚合组织的订单，口径与 PostgreSQL 实现一致
+func (r *OrganizationRepository) OrderTotals(ctx context.Context, filter models.OrganizationAnalysisFilter) ([]models.OrganizationOrderTotal, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var hq *time.Location
+	if filter.HQTimezone != "" {
+		loc, err := time.LoadLocation(filter.HQTimezone)
+		if err != nil {
+			return nil, fmt.Errorf("加载时区 %s 失败: %w", filter.HQTimezone, err)
+		}
+		hq = loc
+	}
+	r.mu.Lock()
+	owners := make(map[int]int, len(r.owners))
+	for merchantID, owner := range r.owners {
+		owners[merchantID] = owner
+	}
+	r.mu.Unlock()
+
+	type key struct {
+		merchantID, hour int
+		currency         string
+	}
+	byKey := map[key]*models.OrganizationOrderTotal{}
+	for _, order := range r.orders.Snapshot() {
+		if owners[order.MerchantID] != filter.OrganizationID || !matchStatus(filter.Statuses, order.Status) {
+			continue
+		}
+		hour := order.LocalHour
+		if hq == nil {
+			if order.LocalDate != filter.LocalDate {
+				continue
+			}
+		} else {
+			if order.OrderTimeUTC.Before(filter.Start) || !order.OrderTimeUTC.Before(filter.End) {
+				continue
+			}
+			hour = order.OrderTimeUTC.In(hq).Hour()
+		}
+		k := key{order.MerchantID, hour, order.Currency}
+		t, ok := byKey[k]
+		if !ok {
+			t = &models.OrganizationOrderTotal{MerchantID: order.MerchantID, Hour: hour, Currency: order.Currency}
+			byKey[k] = t
+		}
+		t.OrderCount++
+		t.Amount = t.Amount.Add(order.Amount)
+	}
+
+	totals := make([]models.OrganizationOrderTotal, 0, len(byKey))
+	for _, t := range byKey {
+		totals = append(totals, *t)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		a, b := totals[i], totals[j]
+		if a.MerchantID != b.MerchantID {
+			return a.MerchantID < b.MerchantID
+		}
+		if a.Hour != b.Hour {
+			return a.Hour < b.Hour
+		}
+		return a.Currency < b.Currency
+	})
+	return totals, nil
+}
+
+// ReportRepository 内存报表仓储，merchants 用于校验商户是否存在
+type ReportRepository struct {
+	merchants *MerchantRepository
+
+	mu        sync.Mutex
+	defs      []models.ReportDefinition
+	nextDefID int
+	runs      []reportRun
+
+	// Err 不为 nil 时所有方法返回该错误，用于模拟数据库故障
+	Err error
+}
+
+// reportRun 保存的执行记录及其结果文件
+type reportRun struct {
+	models.ReportRun
+	artifact []byte
+}
+
+// NewReportRepository 创建内存报表仓储
+func NewReportRepository(merchants *MerchantRepository) *ReportRepository {
+	return &ReportRepository{merchants: merchants}
+}
+
+// CreateDefinition 写入报表定义
+func (r *ReportRepository) CreateDefinition(ctx context.Context, def *models.ReportDefinition) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.check(def); err != nil {
+		return err
+	}
+	r.nextDefID++
+	now := time.Now().UTC()
+	def.ID, def.CreatedAt, def.UpdatedAt = r.nextDefID, now, now
+	r.defs = append(r.defs, copyReportDefinition(*def))
+	return nil
+}
+
+// UpdateDefinition 覆盖报表定义，创建人和创建时间不变
+func (r *ReportRepository) UpdateDefinition(ctx context.Context, def *models.ReportDefinition) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.index(def.ID)
+	if i < 0 {
+		return fmt.Errorf("%w: 报表定义 %d", repository.ErrNotFound, def.ID)
+	}
+	if err := r.check(def); err != nil {
+		return err
+	}
+	def.CreatedBy, def.CreatedAt, def.UpdatedAt = r.defs[i].CreatedBy, r.defs[i].CreatedAt, time.Now().UTC()
+	r.defs[i] = copyReportDefinition(*def)
+	return nil
+}
+
+// check 与表约束一致：名称唯一，商户必须存在；调用方持有锁
+func (r *ReportRepository) check(def *models.ReportDefinition) error {
+	for _, d := range r.defs {
+		if d.Name == def.Name && d.ID != def.ID {
+			return fmt.Errorf("%w: 报表名称 %s", repository.ErrConflict, def.Name)
+		}
+	}
+	if def.MerchantID > 0 {
+		if _, err := r.merchants.Get(def.MerchantID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// index 报表定义在切片中的位置，不存在时返回 -1；调用方持有锁
+func (r *ReportRepository) index(id int) int {
+	for i, d := range r.defs {
+		if d.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// DeleteDefinition 删除报表定义及其执行记录
+func (r *ReportRepository) DeleteDefinition(ctx context.Context, id int) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.index(id)
+	if i < 0 {
+		return fmt.Errorf("%w: 报表定义 %d", repository.ErrNotFound, id)
+	}
+	r.defs = append(r.defs[:i], r.defs[i+1:]...)
+	kept := r.runs[:0]
+	for _, run := range r.runs {
+		if run.DefinitionID != id {
+			kept = append(kept, run)
+		}
+	}
+	r.runs = kept
+	return nil
+}
+
+// Definitions 全部报表定义
+func (r *ReportRepository) Definitions(ctx context.Context) ([]models.ReportDefinition, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	defs := make([]models.ReportDefinition, 0, len(r.defs))
+	for _, d := range r.defs {
+		defs = append(defs, copyReportDefinition(d))
+	}
+	return defs, nil
+}
+
+// Definition 单个报表定义
+func (r *ReportRepository) Definition(ctx context.Context, id int) (*models.ReportDefinition, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.index(id)
+	if i < 0 {
+		return nil, fmt.Errorf("%w: 报表定义 %d", repository.ErrNotFound, id)
+	}
+	def := copyReportDefinition(r.defs[i])
+	return &def, nil
+}
+
+// DueDefinitions 到期的报表定义
+func (r *ReportRepository) DueDefinitions(ctx context.Context, at time.Time) ([]models.ReportDefinition, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due []models.ReportDefinition
+	for _, d := range r.defs {
+		if d.NextRunAt != nil && !d.NextRunAt.After(at) {
+			due = append(due, copyReportDefinition(d))
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].NextRunAt.Before(*due[j].NextRunAt) })
+	return due, nil
+}
+
+// ClaimDue 下一次执行时刻仍为 due 时改为 next
+func (r *ReportRepository) ClaimDue(ctx context.Context, id int, due time.Time, next *time.Time) (bool, error) {
+	if r.Err != nil {
+		return false, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.index(id)
+	if i < 0 || r.defs[i].NextRunAt == nil || !r.defs[i].NextRunAt.Equal(due) {
+		return false, nil
+	}
+	if next != nil {
+		t := *next
+		next = &t
+	}
+	r.defs[i].NextRunAt = next
+	return true, nil
+}
+
+// CreateRun 写入执行记录
+func (r *ReportRepository) CreateRun(ctx context.Context, run *models.ReportRun) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	run.RunID = int64(len(r.runs) + 1)
+	r.runs = append(r.runs, reportRun{ReportRun: *run})
+	return nil
+}
+
+// FinishRun 更新执行记录
+func (r *ReportRepository) FinishRun(ctx context.Context, run *models.ReportRun, artifact []byte) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.runs {
+		if r.runs[i].RunID == run.RunID {
+			stored := *run
+			stored.ArtifactURL = ""
+			r.runs[i] = reportRun{ReportRun: stored}
+			if artifact != nil {
+				r.runs[i].artifact = append([]byte{}, artifact...)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: 报表执行记录 %d", repository.ErrNotFound, run.RunID)
+}
+
+// Runs 报表最近的执行记录
+func (r *ReportRepository) Runs(ctx context.Context, definitionID, limit int) ([]models.ReportRun, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var runs []models.ReportRun
+	for i := len(r.runs) - 1; i >= 0 && len(runs) < limit; i-- {
+		if r.runs[i].DefinitionID == definitionID {
+			runs = append(runs, r.runs[i].ReportRun)
+		}
+	}
+	return runs, nil
+}
+
+// Artifact 一次执行及其结果文件
+func (r *ReportRepository) Artifact(ctx context.Context, runID int64) (*models.ReportRun, []byte, error) {
+	if r.Err != nil {
+		return nil, nil, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, run := range r.runs {
+		if run.RunID == runID {
+			if run.artifact == nil {
+				return nil, nil, fmt.Errorf("%w: 报表执行记录 %d 没有结果文件", repository.ErrNotFound, runID)
+			}
+			stored := run.ReportRun
+			return &stored, append([]byte(nil), run.artifact...), nil
+		}
+	}
+	return nil, nil, fmt.Errorf("%w: 报表执行记录 %d", repository.ErrNotFound, runID)
+}
+
+// copyReportDefinition 复制报表定义，避免调用方修改仓储中的切片和指针
+func copyReportDefinition(def models.ReportDefinition) models.ReportDefinition {
+	def.Statuses = append([]string{}, def.Statuses...)
+	def.Emails = append([]string{}, def.Emails...)
+	if def.NextRunAt != nil {
+		t := *def.NextRunAt
+		def.NextRunAt = &t
+	}
+	def.Timezone = ""
+	return def
+}
+
+// AlertRepository 内存告警仓储，订单汇总基于 orders 中的订单
+type AlertRepository struct {
+	merchants *MerchantRepository
+	orders    *OrderRepository
+
+	mu          sync.Mutex
+	rules       []models.AlertRule
+	nextRuleID  int
+	events      []models.AlertEvent
+	nextEventID int64
+
+	// Err 不为 nil 时所有方法返回该错误，用于模拟数据库故障
+	Err error
+}
+
+// NewAlertRepository 创建内存告警仓储
+func NewAlertRepository(merchants *MerchantRepository, orders *OrderRepository) *AlertRepository {
+	return &AlertRepository{merchants: merchants, orders: orders}
+}
+
+// CreateRule 写入告警规则，初始状态为 ok
+func (r *AlertRepository) CreateRule(ctx context.Context, rule *models.AlertRule) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.check(rule); err != nil {
+		return err
+	}
+	r.nextRuleID++
+	now := time.Now().UTC()
+	rule.ID, rule.State, rule.CreatedAt, rule.UpdatedAt = r.nextRuleID, "ok", now, now
+	r.rules = append(r.rules, copyAlertRule(*rule))
+	return nil
+}
+
+// UpdateRule 覆盖告警规则的配置，状态、检查时间和创建人不变
+func (r *AlertRepository) UpdateRule(ctx context.Context, rule *models.AlertRule) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.index(rule.Tenant, rule.ID)
+	if i < 0 {
+		return fmt.Errorf("%w: 告警规则 %d", repository.ErrNotFound, rule.ID)
+	}
+	if err := r.check(rule); err != nil {
+		return err
+	}
+	stored := r.rules[i]
+	rule.State, rule.LastEvaluatedAt = stored.State, stored.LastEvaluatedAt
+	rule.CreatedBy, rule.CreatedAt, rule.UpdatedAt = stored.CreatedBy, stored.CreatedAt, time.Now().UTC()
+	r.rules[i] = copyAlertRule(*rule)
+	return nil
+}
+
+// check 与表约束一致：同一租户内名称唯一，商户必须存在；调用方持有锁
+func (r *AlertRepository) check(rule *models.AlertRule) error {
+	for _, existing := range r.rules {
+		if existing.Tenant == rule.Tenant && existing.Name == rule.Name && existing.ID != rule.ID {
+			return fmt.Errorf("%w: 告警规则名称 %s", repository.ErrConflict, rule.Name)
+		}
+	}
+	if _, err := r.merchants.Get(rule.MerchantID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// index 租户的告警规则在切片中的位置，不存在时返回 -1；调用方持有锁
+func (r *AlertRepository) index(tenant string, id int) int {
+	for i, rule := range r.rules {
+		if rule.ID == id && rule.Tenant == tenant {
+			return i
+		}
+	}
+	return -1
+}
+
+// DeleteRule 删除告警规则及其告警历史
+func (r *AlertRepository) DeleteRule(ctx context.Context, tenant string, id int) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.index(tenant, id)
+	if i < 0 {
+		return fmt.Errorf("%w: 告警规则 %d", repository.ErrNotFound, id)
+	}
+	r.rules = append(r.rules[:i], r.rules[i+1:]...)
+	kept := r.events[:0]
+	for _, event := range r.events {
+		if event.RuleID != id {
+			kept = append(kept, event)
+		}
+	}
+	r.events = kept
+	return nil
+}
+
+// Rules 租户的全部告警规则
+func (r *AlertRepository) Rules(ctx context.Context, tenant string) ([]models.AlertRule, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var rules []models.AlertRule
+	for _, rule := range r.rules {
+		if rule.Tenant == tenant {
+			rules = append(rules, copyAlertRule(rule))
+		}
+	}
+	return rules, nil
+}
+
+// Rule 租户的单个告警规则
+func (r *AlertRepository) Rule(ctx context.Context, tenant string, id int) (*models.AlertRule, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.index(tenant, id)
+	if i < 0 {
+		return nil, fmt.Errorf("%w: 告警规则 %d", repository.ErrNotFound, id)
+	}
+	rule := copyAlertRule(r.rules[i])
+	return &rule, nil
+}
+
+// EnabledRules 所有租户已启用的告警规则
+func (r *AlertRepository) EnabledRules(ctx context.Context) ([]models.AlertRule, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var rules []models.AlertRule
+	for _, rule := range r.rules {
+		if rule.Enabled {
+			rules = append(rules, copyAlertRule(rule))
+		}
+	}
+	return rules, nil
+}
+
+// TransitionState 规则状态仍为 from 时改为 to
+func (r *AlertRepository) TransitionState(ctx context.Context, id int, from, to string, at time.Time) (bool, error) {
+	if r.Err != nil {
+		return false, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.rules {
+		if r.rules[i].ID == id && r.rules[i].State == from {
+			evaluatedAt := at.UTC()
+			r.rules[i].State, r.rules[i].LastEvaluatedAt = to, &evaluatedAt
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CreateEvent 写入告警历史
+func (r *AlertRepository) CreateEvent(ctx context.Context, event *models.AlertEvent) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextEventID++
+	event.EventID = r.nextEventID
+	stored := *event
+	stored.Details = append(json.RawMessage(nil), event.Details...)
+	stored.Deliveries = append([]models.AlertDelivery{}, event.Deliveries...)
+	r.events = append(r.events, stored)
+	return nil
+}
+
+// Events 租户最近的告警历史，按时间倒序
+func (r *AlertRepository) Events(ctx context.Context, tenant string, ruleID, limit int) ([]models.AlertEvent, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var events []models.AlertEvent
+	for i := len(r.events) - 1; i >= 0 && len(events) < limit; i-- {
+		event := r.events[i]
+		if event.Tenant != tenant || (ruleID != 0 && event.RuleID != ruleID) {
+			continue
+		}
+		event.Deliveries = append([]models.AlertDelivery{}, event.Deliveries...)
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// MerchantTotals 按币种汇总商户下单时间在 [start, end) 内的订单
+func (r *AlertRepository) MerchantTotals(ctx context.Context, merchantID int, start, end time.Time, statuses []string) ([]models.CurrencyTotal, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	byCurrency := map[string]*models.CurrencyTotal{}
+	for _, order := range r.orders.Snapshot() {
+		if order.MerchantID != merchantID || !matchStatus(statuses, order.Status) ||
+			order.OrderTimeUTC.Before(start) || !order.OrderTimeUTC.Before(end) {
+			continue
+		}
+		t, ok := byCurrency[order.Currency]
+		if !ok {
+			t = &models.CurrencyTotal{Currency: order.Currency}
+			byCurrency[order.Currency] = t
+		}
+		t.OrderCount++
+		t.GrossAmount = t.GrossAmount.Add(order.Amount)
+	}
+
+	totals := make([]models.CurrencyTotal, 0, len(byCurrency))
+	for _, t := range byCurrency {
+		totals = append(totals, *t)
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].Currency < totals[j].Currency })
+	return totals, nil
+}
+
+// copyAlertRule 复制告警规则，避免调用方修改仓储中的切片和指针
+func copyAlertRule(rule models.AlertRule) models.AlertRule {
+	rule.Statuses = append([]string{}, rule.Statuses...)
+	rule.Emails = append([]string{}, rule.Emails...)
+	if rule.LastEvaluatedAt != nil {
+		t := *rule.LastEvaluatedAt
+		rule.LastEvaluatedAt = &t
+	}
+	return rule
+}
+
+// EmailTemplateRepository 内存邮件模板仓储
+type EmailTemplateRepository struct {
+	mu        sync.Mutex
+	templates map[string]models.EmailTemplate
+
+	// Err 不为 nil 时所有方法返回该错误，用于模拟数据库故障
+	Err error
+}
+
+// NewEmailTemplateRepository 创建空的内存邮件模板仓储
+func NewEmailTemplateRepository() *EmailTemplateRepository {
+	return &EmailTemplateRepository{templates: make(map[string]models.EmailTemplate)}
+}
+
+// emailTemplateKey 模板在 map 中的键
+func emailTemplateKey(tenant, name, locale string) string {
+	return tenant + "/" + name + "/" + locale
+}
+
+// Templates 租户覆盖的全部模板，按名称和语言排序
+func (r *EmailTemplateRepository) Templates(ctx context.Context, tenant string) ([]models.EmailTemplate, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var templates []models.EmailTemplate
+	for _, t := range r.templates {
+		if t.Tenant == tenant {
+			templates = append(templates, t)
+		}
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		if templates[i].Name != templates[j].Name {
+			return templates[i].Name < templates[j].Name
+		}
+		return templates[i].Locale < templates[j].Locale
+	})
+	return templates, nil
+}
+
+// Template 租户覆盖的单个模板
+func (r *EmailTemplateRepository) Template(ctx context.Context, tenant, name, locale string) (*models.EmailTemplate, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.templates[emailTemplateKey(tenant, name, locale)]
+	if !ok {
+		return nil, fmt.Errorf("%w: 邮件模板 %s（%s）", repository.ErrNotFound, name, locale)
+	}
+	return &t, nil
+}
+
+// SaveTemplate 写入或覆盖租户的模板
+func (r *EmailTemplateRepository) SaveTemplate(ctx context.Context, t *models.EmailTemplate) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+	t.Source, t.UpdatedAt = "tenant", &now
+	r.templates[emailTemplateKey(t.Tenant, t.Name, t.Locale)] = *t
+	return nil
+}
+
+// DeleteTemplate 删除租户覆盖的模板
+func (r *EmailTemplateRepository) DeleteTemplate(ctx context.Context, tenant, name, locale string) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := emailTemplateKey(tenant, name, locale)
+	if _, ok := r.templates[key]; !ok {
+		return fmt.Errorf("%w: 邮件模板 %s（%s）", repository.ErrNotFound, name, locale)
+	}
+	delete(r.templates, key)
+	return nil
+}
+
+// DashboardRepository 基于内存订单的首页概览仓储
+type DashboardRepository struct {
+	orders *OrderRepository
+
+	// Err 不为 nil 时所有方法返回该错误，用于模拟数据库故障
+	Err error
+}
+
+// NewDashboardRepository 创建内存首页概览仓储
+func NewDashboardRepository(orders *OrderRepository) *DashboardRepository {
+	return &DashboardRepository{orders: orders}
+}
+
+// Summary 与 PostgreSQL 实现相同：小时点按距 HourlyStart 的整小时数分桶
+func (r *DashboardRepository) Summary(ctx context.Context, filter models.DashboardFilter) (*models.DashboardAggregates, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	type hourKey struct {
+		start    time.Time
+		currency string
+	}
+	today := map[string]*models.CurrencyTotal{}
+	sameTime := map[string]*models.CurrencyTotal{}
+	yesterday := map[string]*models.CurrencyTotal{}
+	hourly := map[hourKey]*models.DashboardHourlyTotal{}
+	add := func(totals map[string]*models.CurrencyTotal, order models.OrderAnalysis) {
+		t, ok := totals[order.Currency]
+		if !ok {
+			t = &models.CurrencyTotal{Currency: order.Currency}
+			totals[order.Currency] = t
+		}
+		t.OrderCount++
+		t.GrossAmount = t.GrossAmount.Add(order.Amount)
+	}
+	in := func(at, start, end time.Time) bool {
+		return !at.Before(start) && at.Before(end)
+	}
+
+	for _, order := range r.orders.Snapshot() {
+		at := order.OrderTimeUTC
+		if order.MerchantID != filter.MerchantID || !matchStatus(filter.Statuses, order.Status) ||
+			(filter.Currency != "" && order.Currency != filter.Currency) || !at.Before(filter.Now) {
+			continue
+		}
+		if in(at, filter.TodayStart, filter.Now) {
+			add(today, order)
+		}
+		if in(at, filter.YesterdayStart, filter.YesterdayAt) {
+			add(sameTime, order)
+		}
+		if in(at, filter.YesterdayStart, filter.TodayStart) {
+			add(yesterday, order)
+		}
+		if in(at, filter.HourlyStart, filter.Now) {
+			key := hourKey{
+				start:    filter.HourlyStart.Add(at.Sub(filter.HourlyStart) / time.Hour * time.Hour),
+				currency: order.Currency,
+			}
+			h, ok := hourly[key]
+			if !ok {
+				h = &models.DashboardHourlyTotal{HourStart: key.start, Currency: key.currency}
+				hourly[key] = h
+			}
+			h.OrderCount++
+			h.Amount = h.Amount.Add(order.Amount)
+		}
+	}
+
+	sorted := func(totals map[string]*models.CurrencyTotal) []models.CurrencyTotal {
+		result := make([]models.CurrencyTotal, 0, len(totals))
+		for _, t := range totals {
+			result = append(result, *t)
+		}
+		sort.Slice(result, func(i, j int) bool { return result[i].Currency < result[j].Currency })
+		return result
+	}
+	aggregates := &models.DashboardAggregates{
+		Today:             sorted(today),
+		YesterdaySameTime: sorted(sameTime),
+		Yesterday:         sorted(yesterday),
+	}
+	for _, h := range hourly {
+		aggregates.Hourly = append(aggregates.Hourly, *h)
+	}
+	sort.Slice(aggregates.Hourly, func(i, j int) bool {
+		a, b := aggregates.Hourly[i], aggregates.Hourly[j]
+		if !a.HourStart.Equal(b.HourStart) {
+			return a.HourStart.Before(b.HourStart)
+		}
+		return a.Currency < b.Currency
+	})
+	return aggregates, nil
+}
+
+// ChangeRepository 内存变更流水，商户和订单仓储的写入相当于 change_log 的触发器
+// 内存中写入即可见，记录时直接分配 seq
+type ChangeRepository struct {
+	mu      sync.Mutex
+	changes []models.Change
+	nextSeq int64
+
+	// Err 不为 nil 时所有方法返回该错误，用于模拟数据库故障
+	Err error
+}
+
+// NewChangeRepository 创建内存变更流水仓储
+func NewChangeRepository() *ChangeRepository {
+	return &ChangeRepository{}
+}
+
+// recordMerchant 记录商户变更，快照与 change_log_merchant_snapshot 一致（时刻精确到秒）
+func (r *ChangeRepository) recordMerchant(op string, m models.Merchant) {
+	if r == nil {
+		return
+	}
+	m.Description = ""
+	m.CreatedAt = m.CreatedAt.UTC().Truncate(time.Second)
+	m.UpdatedAt = m.UpdatedAt.UTC().Truncate(time.Second)
+	if m.WeekendDays == nil {
+		m.WeekendDays = []int{}
+	}
+	r.record(models.ChangeEntityMerchant, m.ID, m.ID, op, m)
+}
+
+// recordOrder 记录订单变更，快照与 change_log_order_snapshot 一致
+func (r *ChangeRepository) recordOrder(op string, o models.OrderAnalysis, meta orderMeta) {
+	if r == nil {
+		return
+	}
+	r.record(models.ChangeEntityOrder, o.OrderID, o.MerchantID, op, orderRow(o, meta))
+}
+
+// record 追加一条变更
+func (r *ChangeRepository) record(entity string, entityID, merchantID int, op string, snapshot interface{}) {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		panic(fmt.Sprintf("序列化变更快照失败: %v", err))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextSeq++
+	r.changes = append(r.changes, models.Change{
+		Seq:        r.nextSeq,
+		Entity:     entity,
+		EntityID:   entityID,
+		MerchantID: merchantID,
+		Op:         op,
+		Snapshot:   data,
+		ChangedAt:  time.Now().UTC(),
+	})
+}
+
+// Changes 按 seq 升序返回符合条件的变更
+func (r *ChangeRepository) Changes(ctx context.Context, filter models.ChangeFilter) ([]models.Change, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var changes []models.Change
+	for _, c := range r.changes {
+		if filter.Limit > 0 && len(changes) >= filter.Limit {
+			break
+		}
+		if c.Seq <= filter.Since || (filter.MerchantID != 0 && c.MerchantID != filter.MerchantID) ||
+			(len(filter.Entities) > 0 && !matchStatus(filter.Entities, c.Entity)) {
+			continue
+		}
+		changes = append(changes, c)
+	}
+	return changes, nil
+}
+
+// SyncRepository 内存离线同步仓储，订单写入内存订单仓储（同时记录变更流水）
+// 一个修改的查找、决定和写入在同一把锁内完成，相当于数据库事务中的行锁
+type SyncRepository struct {
+	merchants *MerchantRepository
+	orders    *OrderRepository
+
+	mu        sync.Mutex
+	mutations map[string]models.SyncMutationResult
+	devices   map[string]models.SyncDevice
+
+	// Err 不为 nil 时所有方法返回该错误，用于模拟数据库故障
+	Err error
+}
+
+// NewSyncRepository 创建内存离线同步仓储
+func NewSyncRepository(merchants *MerchantRepository, orders *OrderRepository) *SyncRepository {
+	return &SyncRepository{
+		merchants: merchants,
+		orders:    orders,
+		mutations: map[string]models.SyncMutationResult{},
+		devices:   map[string]models.SyncDevice{},
+	}
+}
+
+// ApplyOrder 同一设备的同一修改只处理一次，之后返回保存的结果
+func (r *SyncRepository) ApplyOrder(ctx context.Context, deviceID string, merchantID int, mutation models.SyncMutation, resolve repository.SyncResolver) (*models.SyncMutationResult, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := deviceID + "\x00" + mutation.MutationID
+	if stored, ok := r.mutations[key]; ok {
+		stored.Replayed = true
+		return &stored, nil
+	}
+
+	var current *models.Order
+	if mutation.Op == models.ChangeOpInsert {
+		current = r.orders.syncOrder(0, merchantID, mutation.OrderNumber)
+	} else {
+		current = r.orders.syncOrder(mutation.OrderID, 0, "")
+	}
+	next, result := resolve(current)
+	if next != nil {
+		merchant, err := r.merchants.Get(next.MerchantID)
+		if err != nil {
+			return nil, err
+		}
+		written := r.orders.saveSyncOrder(*next, *merchant)
+		result.Order = &written
+	}
+	r.mutations[key] = result
+	return &result, nil
+}
+
+// SaveDevice 记录设备最近一次同步
+func (r *SyncRepository) SaveDevice(ctx context.Context, device models.SyncDevice) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.devices[device.DeviceID] = device
+	return nil
+}
+
+// Device 获取设备最近一次同步，未同步过时返回 false
+func (r *SyncRepository) Device(deviceID string) (models.SyncDevice, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	device, ok := r.devices[deviceID]
+	return device, ok
+}
+
+// IngestRepository 内存 Webhook 投递仓储，订单写入内存订单仓储（同时记录变更流水）
+type IngestRepository struct {
+	merchants *MerchantRepository
+	orders    *OrderRepository
+
+	mu         sync.Mutex
+	deliveries []models.IngestDelivery
+
+	// Err 不为 nil 时所有方法返回该错误，用于模拟数据库故障
+	Err error
+}
+
+// NewIngestRepository 创建内存 Webhook 投递仓储
+func NewIngestRepository(merchants *MerchantRepository, orders *OrderRepository) *IngestRepository {
+	return &IngestRepository{merchants: merchants, orders: orders}
+}
+
+// SaveDelivery 写入投递，同一平台的投递ID已存在时返回已有记录
+func (r *IngestRepository) SaveDelivery(ctx context.Context, d *models.IngestDelivery) (bool, error) {
+	if r.Err != nil {
+		return false, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.deliveries {
+		if existing.Provider == d.Provider && existing.ExternalID == d.ExternalID {
+			payload := d.Payload
+			*d = existing
+			d.Payload = payload
+			return false, nil
+		}
+	}
+	d.ID = int64(len(r.deliveries) + 1)
+	d.Status = models.IngestStatusReceived
+	d.ReceivedAt = time.Now().UTC()
+	r.deliveries = append(r.deliveries, *d)
+	return true, nil
+}
+
+// ApplyOrder 同一平台已处理过该订单更晚的事件时标记为 skipped，否则按 resolve 的结果新建、更新订单或记录冲突
+func (r *IngestRepository) ApplyOrder(ctx context.Context, d *models.IngestDelivery, order models.Order, resolve repository.IngestResolver) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d.Status = models.IngestStatusProcessed
+	d.Conflict = nil
+	var lastEventTime *time.Time
+	for _, other := range r.deliveries {
+		if other.ID == d.ID || other.MerchantID != order.MerchantID || other.OrderNumber != order.OrderNumber ||
+			other.Status != models.IngestStatusProcessed || other.EventTime == nil {
+			continue
+		}
+		if d.EventTime != nil && other.Provider == d.Provider && other.EventTime.After(*d.EventTime) {
+			d.Status = models.IngestStatusSkipped
+		}
+		if lastEventTime == nil || other.EventTime.After(*lastEventTime) {
+			lastEventTime = other.EventTime
+		}
+	}
+	if d.Status == models.IngestStatusProcessed {
+		current := r.orders.syncOrder(0, order.MerchantID, order.OrderNumber)
+		if current == nil {
+			lastEventTime = nil
+		}
+		next, conflict := resolve(current, lastEventTime)
+		if conflict != nil {
+			d.Status = models.IngestStatusConflict
+			d.Conflict = conflict
+			d.OrderID = current.ID
+		} else {
+			merchant, err := r.merchants.Get(next.MerchantID)
+			if err != nil {
+				return err
+			}
+			written := r.orders.saveSyncOrder(*next, *merchant)
+			d.OrderID = written.ID
+		}
+	}
+
+	d.MerchantID = order.MerchantID
+	d.OrderNumber = order.OrderNumber
+	d.Error = ""
+	return r.finish(d)
+}
+
+// FinishDelivery 更新投递的状态和错误信息
+func (r *IngestRepository) FinishDelivery(ctx context.Context, d *models.IngestDelivery) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d.Conflict = nil
+	return r.finish(d)
+}
+
+// finish 保存处理结果，尝试次数加一；调用方持有锁
+func (r *IngestRepository) finish(d *models.IngestDelivery) error {
+	for i := range r.deliveries {
+		if r.deliveries[i].ID == d.ID {
+			now := time.Now().UTC()
+			stored := &r.deliveries[i]
+			stored.Status = d.Status
+			stored.Error = d.Error
+			stored.Conflict = d.Conflict
+			if d.MerchantID != 0 {
+				stored.MerchantID = d.MerchantID
+			}
+			if d.OrderNumber != "" {
+				stored.OrderNumber = d.OrderNumber
+			}
+			if d.OrderID != 0 {
+				stored.OrderID = d.OrderID
+			}
+			if d.EventTime != nil {
+				stored.EventTime = d.EventTime
+			}
+			stored.Attempts++
+			stored.ProcessedAt = &now
+			d.Attempts = stored.Attempts
+			d.ProcessedAt = stored.ProcessedAt
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: Webhook 投递 %d", repository.ErrNotFound, d.ID)
+}
+
+// Delivery 按ID获取投递及其载荷
+func (r *IngestRepository) Delivery(ctx context.Context, id int64) (*models.IngestDelivery, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range r.deliveries {
+		if d.ID == id {
+			return &d, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: Webhook 投递 %d", repository.ErrNotFound, id)
+}
+
+// Deliveries 按接收时间倒序查询投递，不含载荷
+func (r *IngestRepository) Deliveries(ctx context.Context, filter models.IngestDeliveryFilter) ([]models.IngestDelivery, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	deliveries := []models.IngestDelivery{}
+	for i := len(r.deliveries) - 1; i >= 0 && len(deliveries) < filter.Limit; i-- {
+		d := r.deliveries[i]
+		if (filter.Provider != "" && d.Provider != filter.Provider) || (filter.Status != "" && d.Status != filter.Status) {
+			continue
+		}
+		d.Payload = nil
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+// ProviderEvents 事件时刻在 [From, To) 内、已写入订单的投递，按事件时刻排序
+func (r *IngestRepository) ProviderEvents(ctx context.Context, filter models.ProviderEventFilter) ([]models.ProviderEvent, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var matched []models.IngestDelivery
+	for _, d := range r.deliveries {
+		if d.Provider != filter.Provider || d.EventTime == nil || d.OrderNumber == "" || d.MerchantID == 0 ||
+			(d.Status != models.IngestStatusProcessed && d.Status != models.IngestStatusSkipped) ||
+			d.EventTime.Before(filter.From) || !d.EventTime.Before(filter.To) ||
+			(filter.MerchantID != 0 && d.MerchantID != filter.MerchantID) {
+			continue
+		}
+		matched = append(matched, d)
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].EventTime.Before(*matched[j].EventTime) })
+
+	events := []models.ProviderEvent{}
+	for _, d := range matched {
+		if len(events) == filter.Limit {
+			break
+		}
+		events = append(events, models.ProviderEvent{ID: d.ExternalID, Type: d.Topic, Created: d.EventTime.Unix(), OrderNumber: d.OrderNumber, MerchantID: d.MerchantID})
+	}
+	return events, nil
+}
+
+// OrdersByNumber 按订单号查找内存订单
+func (r *IngestRepository) OrdersByNumber(ctx context.Context, merchantID int, orderNumbers []string) ([]models.OrderAnalysis, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	wanted := make(map[string]bool, len(orderNumbers))
+	for _, number := range orderNumbers {
+		wanted[number] = true
+	}
+	var orders []models.OrderAnalysis
+	for _, o := range r.orders.Snapshot() {
+		if wanted[o.OrderNumber] && (merchantID == 0 || o.MerchantID == merchantID) {
+			orders = append(orders, o)
+		}
+	}
+	return orders, nil
+}
+
+// LifecycleRepository 内存租户生命周期仓储，商户来自 MerchantRepository，未变更过的商户为 active
+type LifecycleRepository struct {
+	mu        sync.Mutex
+	merchants *MerchantRepository
+	states    map[int]models.TenantLifecycle
+	events    []models.TenantLifecycleEvent
+
+	// Err 不为 nil 时所有方法返回该错误，用于模拟数据库故障
+	Err error
+}
+
+// NewLifecycleRepository 创建内存生命周期仓储
+func NewLifecycleRepository(merchants *MerchantRepository) *LifecycleRepository {
+	return &LifecycleRepository{merchants: merchants, states: map[int]models.TenantLifecycle{}}
+}
+
+// Lifecycle 商户当前的生命周期状态，商户不存在时返回 ErrNotFound
+func (r *LifecycleRepository) Lifecycle(ctx context.Context, merchantID int) (*models.TenantLifecycle, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	merchant, err := r.merchants.Get(merchantID)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	l, ok := r.states[merchantID]
+	if !ok {
+		l = models.TenantLifecycle{MerchantID: merchantID, State: models.TenantLifecycleActive}
+	}
+	l.Timezone = merchant.Timezone
+	return &l, nil
+}
+
+// Transition 商户的状态仍为 event.From 时改为 event.To 并写入变更记录
+func (r *LifecycleRepository) Transition(ctx context.Context, event *models.TenantLifecycleEvent) error {
+	current, err := r.Lifecycle(ctx, event.MerchantID)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if l, ok := r.states[event.MerchantID]; ok {
+		current = &l
+	}
+	if current.State != event.From {
+		return fmt.Errorf("%w: 商户 %d 的状态已变为 %s", repository.ErrConflict, event.MerchantID, current.State)
+	}
+	event.EventID = int64(len(r.events) + 1)
+	event.ChangedAt = time.Now().UTC()
+	r.events = append(r.events, *event)
+	changedAt := event.ChangedAt
+	r.states[event.MerchantID] = models.TenantLifecycle{
+		MerchantID: event.MerchantID, State: event.To, TrialEndsOn: event.TrialEndsOn, ChangedAt: &changedAt,
+	}
+	return nil
+}
+
+// Events 商户最近 limit 条变更记录，按时间倒序
+func (r *LifecycleRepository) Events(ctx context.Context, merchantID, limit int) ([]models.TenantLifecycleEvent, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var events []models.TenantLifecycleEvent
+	for i := len(r.events) - 1; i >= 0 && len(events) < limit; i-- {
+		if r.events[i].MerchantID == merchantID {
+			events = append(events, r.events[i])
+		}
+	}
+	return events, nil
+}
+
+// Trials 全部试用中的商户，按试用结束日期排序
+func (r *LifecycleRepository) Trials(ctx context.Context) ([]models.TenantLifecycle, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	r.mu.Lock()
+	var trials []models.TenantLifecycle
+	for _, l := range r.states {
+		if l.State == models.TenantLifecycleTrial {
+			trials = append(trials, l)
+		}
+	}
+	r.mu.Unlock()
+
+	for i := range trials {
+		if merchant, err := r.merchants.Get(trials[i].MerchantID); err == nil {
+			trials[i].Timezone = merchant.Timezone
+		}
+	}
+	sort.Slice(trials, func(i, j int) bool { return trials[i].TrialEndsOn < trials[j].TrialEndsOn })
+	return trials, nil
+}
+
+// set 直接设置商户的生命周期，不写变更记录
+func (r *LifecycleRepository) set(l models.TenantLifecycle) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.states[l.MerchantID] = l
+}
+
+// ProvisioningRepository 内存租户开通仓储：商户写入 OnboardingRepository，开通后的状态写入 LifecycleRepository，
+// 只保存 API 密钥的摘要；不创建数据库角色，示例订单不写入订单仓储
+type ProvisioningRepository struct {
+	mu         sync.Mutex
+	onboarding *OnboardingRepository
+	lifecycle  *LifecycleRepository
+	keys       map[string]provisionedKey
+
+	// Err 不为 nil 时所有方法返回该错误，用于模拟数据库故障
+	Err error
+}
+
+// provisionedKey 一个已签发的 API 密钥
+type provisionedKey struct {
+	keyID      int
+	merchantID int
+	revoked    bool
+}
+
+// NewProvisioningRepository 创建内存开通仓储
+func NewProvisioningRepository(onboarding *OnboardingRepository, lifecycle *LifecycleRepository) *ProvisioningRepository {
+	return &ProvisioningRepository{onboarding: onboarding, lifecycle: lifecycle, keys: map[string]provisionedKey{}}
+}
+
+// Provision 创建商户、保存 API 密钥摘要和开通后的生命周期，商户编码重复时返回 ErrConflict
+func (r *ProvisioningRepository) Provision(ctx context.Context, p *models.TenantProvisioning, keyHash string) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	if err := r.onboarding.Onboard(&p.MerchantOnboarding); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+	p.APIKey.KeyID = len(r.keys) + 1
+	p.APIKey.CreatedAt = now
+	p.SampleOrder.MerchantID = p.Merchant.ID
+	p.SampleOrder.OrderNumber = fmt.Sprintf("SAMPLE-%d", p.Merchant.ID)
+	p.ProvisionedAt = now
+	r.keys[keyHash] = provisionedKey{keyID: p.APIKey.KeyID, merchantID: p.Merchant.ID}
+	r.lifecycle.set(models.TenantLifecycle{MerchantID: p.Merchant.ID, State: p.Lifecycle, TrialEndsOn: p.TrialEndsOn})
+	return nil
+}
+
+// APIKeyMerchant 未吊销的 API 密钥所属的商户ID
+func (r *ProvisioningRepository) APIKeyMerchant(ctx context.Context, keyHash string) (int, error) {
+	if r.Err != nil {
+		return 0, r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.keys[keyHash]
+	if !ok || key.revoked {
+		return 0, fmt.Errorf("%w: API 密钥", repository.ErrNotFound)
+	}
+	return key.merchantID, nil
+}
+
+// RevokeAPIKey 吊销商户的 API 密钥
+func (r *ProvisioningRepository) RevokeAPIKey(ctx context.Context, merchantID, keyID int) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for hash, key := range r.keys {
+		if key.merchantID == merchantID && key.keyID == keyID && !key.revoked {
+			key.revoked = true
+			r.keys[hash] = key
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: 商户 %d 的 API 密钥 %d", repository.ErrNotFound, merchantID, keyID)
+}
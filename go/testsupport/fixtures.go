@@ -0,0 +1,263 @@
+package testsupport
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"timezone-saas-demo/geo"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/services"
+)
+
+// Fakes 一组相互关联的内存仓储
+type Fakes struct {
+	Merchants  *MerchantRepository
+	Orders     *OrderRepository
+	Analysis   *AnalysisRepository
+	Billing    *BillingRepository
+	Snapshots  *SnapshotRepository
+	Onboarding *OnboardingRepository
+	Refunds    *RefundRepository
+	IndexStats *IndexStatsRepository
+	Settings   *SettingsRepository
+	Overview   *OverviewRepository
+	// Consistency 一致性检查仓储，分析视图一侧即 Orders 中的订单
+	Consistency *ConsistencyRepository
+	// Organizations 组织仓储，门店和订单即 Merchants 和 Orders 中的数据
+	Organizations *OrganizationRepository
+	// Reports 报表定义和执行记录
+	Reports *ReportRepository
+	// Alerts 告警规则和告警历史，订单汇总即 Orders 中的订单
+	Alerts *AlertRepository
+	// EmailTemplates 租户覆盖的邮件模板
+	EmailTemplates *EmailTemplateRepository
+	// Dashboard 首页概览仓储，订单即 Orders 中的订单
+	Dashboard *DashboardRepository
+	// Changes 变更流水，记录 Merchants 和 Orders 的增删改
+	Changes *ChangeRepository
+	// Sync 离线同步的处理结果和设备状态，订单写入 Orders
+	Sync *SyncRepository
+	// Ingest 外部平台的 Webhook 投递，订单写入 Orders
+	Ingest *IngestRepository
+	// Lifecycle 租户生命周期，商户即 Merchants 中的商户
+	Lifecycle *LifecycleRepository
+	// Provisioning 租户开通和 API 密钥，商户经 Onboarding 写入 Merchants，生命周期写入 Lifecycle
+	Provisioning *ProvisioningRepository
+}
+
+// NewFakes 创建内存仓储，分析、日结和退款仓储基于同一份商户和订单数据
+func NewFakes() *Fakes {
+	changes := NewChangeRepository()
+	merchants := NewMerchantRepository()
+	merchants.changes = changes
+	orders := NewOrderRepository()
+	orders.changes = changes
+	onboarding := NewOnboardingRepository(merchants)
+	lifecycle := NewLifecycleRepository(merchants)
+	return &Fakes{
+		Merchants:  merchants,
+		Orders:     orders,
+		Analysis:   NewAnalysisRepository(orders),
+		Billing:    NewBillingRepository(),
+		Snapshots:  NewSnapshotRepository(merchants, orders),
+		Onboarding: onboarding,
+		Refunds:    NewRefundRepository(orders),
+		IndexStats: NewIndexStatsRepository(),
+		Settings:   NewSettingsRepository(merchants),
+		Overview:   NewOverviewRepository(merchants, orders),
+
+		Consistency:   NewConsistencyRepository(orders),
+		Organizations: NewOrganizationRepository(merchants, orders),
+		Reports:       NewReportRepository(merchants),
+		Alerts:        NewAlertRepository(merchants, orders),
+
+		EmailTemplates: NewEmailTemplateRepository(),
+		Dashboard:      NewDashboardRepository(orders),
+		Changes:        changes,
+		Sync:           NewSyncRepository(merchants, orders),
+		Ingest:         NewIngestRepository(merchants, orders),
+		Lifecycle:      lifecycle,
+		Provisioning:   NewProvisioningRepository(onboarding, lifecycle),
+	}
+}
+
+// Service 基于内存仓储创建时区服务
+func (f *Fakes) Service() *services.TimezoneService {
+	return services.NewTimezoneServiceWithRepositories(f.Merchants, f.Orders, f.Analysis, f.Refunds)
+}
+
+// BillingService 基于内存仓储创建计费服务
+func (f *Fakes) BillingService() *services.BillingService {
+	return services.NewBillingServiceWithRepositories(f.Merchants, f.Billing)
+}
+
+// RevenueCloseService 基于内存仓储创建日结服务
+func (f *Fakes) RevenueCloseService() *services.RevenueCloseService {
+	return services.NewRevenueCloseServiceWithRepositories(f.Merchants, f.Snapshots)
+}
+
+// OnboardingService 基于内存仓储创建入驻服务，创建的商户对其他服务可见
+func (f *Fakes) OnboardingService() *services.OnboardingService {
+	return services.NewOnboardingServiceWithRepositories(f.Onboarding)
+}
+
+// RefundService 基于内存仓储创建退款服务，退款计入同一份订单数据的分析结果
+func (f *Fakes) RefundService() *services.RefundService {
+	return services.NewRefundServiceWithRepositories(f.Refunds)
+}
+
+// IndexAdvisorService 基于内存索引统计创建索引建议服务
+func (f *Fakes) IndexAdvisorService() *services.IndexAdvisorService {
+	return services.NewIndexAdvisorServiceWithRepositories(f.IndexStats)
+}
+
+// SettingsService 基于内存仓储创建商户配置服务
+func (f *Fakes) SettingsService() *services.SettingsService {
+	return services.NewSettingsServiceWithRepositories(f.Settings, f.Merchants)
+}
+
+// OverviewService 基于内存仓储创建运营看板服务，requests 和 queryStats 可以为 nil
+func (f *Fakes) OverviewService(requests *services.TenantRequestCounter, queryStats func() []models.TenantQueryStats) *services.OverviewService {
+	return services.NewOverviewServiceWithRepositories(f.Overview, requests, queryStats)
+}
+
+// ConsistencyService 基于内存仓储创建一致性检查服务，alerter 可以为 nil
+func (f *Fakes) ConsistencyService(alerter services.Alerter) *services.ConsistencyService {
+	return services.NewConsistencyServiceWithRepositories(f.Merchants, f.Consistency, alerter)
+}
+
+// OrganizationService 基于内存仓储创建组织服务
+func (f *Fakes) OrganizationService() *services.OrganizationService {
+	return services.NewOrganizationServiceWithRepositories(f.Organizations, f.Merchants)
+}
+
+// ReportService 基于内存仓储创建报表服务，分析数据来自 analysis（通常为 Service() 创建的时区服务）
+func (f *Fakes) ReportService(analysis *services.TimezoneService) *services.ReportService {
+	return services.NewReportServiceWithRepositories(f.Reports, f.Merchants, analysis)
+}
+
+// EmailService 基于内存仓储创建邮件服务，商户语言来自 settings，mailer 为 nil 时不发送邮件
+func (f *Fakes) EmailService(settings *services.SettingsService, mailer services.Mailer) *services.EmailService {
+	return services.NewEmailServiceWithRepositories(f.EmailTemplates, settings, mailer)
+}
+
+// AlertRuleService 基于内存仓储创建告警规则服务，邮件通过 emails 发送，可以为 nil
+func (f *Fakes) AlertRuleService(emails *services.EmailService) *services.AlertRuleService {
+	return services.NewAlertRuleServiceWithRepositories(f.Alerts, f.Merchants, emails)
+}
+
+// DashboardService 基于内存仓储创建首页概览服务
+func (f *Fakes) DashboardService() *services.DashboardService {
+	return services.NewDashboardServiceWithRepositories(f.Dashboard, f.Merchants)
+}
+
+// ChangeService 基于内存仓储创建变更流水服务
+func (f *Fakes) ChangeService() *services.ChangeService {
+	return services.NewChangeServiceWithRepositories(f.Changes)
+}
+
+// SyncService 基于内存仓储创建离线同步服务
+func (f *Fakes) SyncService() *services.SyncService {
+	return services.NewSyncServiceWithRepositories(f.Sync, f.Merchants, f.Changes)
+}
+
+// IngestService 基于内存仓储创建 Webhook 接收服务，签名密钥需另行设置
+func (f *Fakes) IngestService() *services.IngestService {
+	return services.NewIngestServiceWithRepositories(f.Ingest, f.Merchants)
+}
+
+// LifecycleService 基于内存仓储创建租户生命周期服务
+func (f *Fakes) LifecycleService() *services.LifecycleService {
+	return services.NewLifecycleServiceWithRepositories(f.Lifecycle)
+}
+
+// ProvisioningService 基于内存仓储创建租户开通服务，开通的商户对其他服务可见
+func (f *Fakes) ProvisioningService() *services.TenantProvisioningService {
+	return services.NewTenantProvisioningServiceWithRepositories(f.Provisioning, f.OnboardingService())
+}
+
+// AddOrder 为商户添加一笔订单，派生的本地时间字段按分析视图的规则计算
+func (f *Fakes) AddOrder(merchant models.Merchant, orderID int, amount float64, orderTimeUTC time.Time) models.OrderAnalysis {
+	order := NewOrderAnalysis(merchant, orderID, amount, orderTimeUTC)
+	f.Orders.Add(order)
+	return order
+}
+
+// AddLateOrder 添加一笔在 ingestedAt 才入库的订单，用于模拟结账后到达的迟到订单
+func (f *Fakes) AddLateOrder(merchant models.Merchant, orderID int, amount float64, orderTimeUTC, ingestedAt time.Time) models.OrderAnalysis {
+	order := NewOrderAnalysis(merchant, orderID, amount, orderTimeUTC)
+	order.IngestedAt = ingestedAt.UTC()
+	f.Orders.Add(order)
+	return order
+}
+
+// NewMerchant 构造商户，周末与 sql/12_merchant_weekend.sql 的回填一致按国家取默认值，
+// 国家和城市与 sql/19_reference_data.sql 的回填一致关联参考数据（名称保持不变）
+func NewMerchant(id int, name, timezone, country, city string) models.Merchant {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := models.Merchant{
+		ID:                 id,
+		Name:               name,
+		Timezone:           timezone,
+		Country:            country,
+		City:               city,
+		BusinessHoursStart: "09:00",
+		BusinessHoursEnd:   "19:00",
+		WeekendDays:        services.CountryWeekend(country).Days(),
+		CreatedAt:          created,
+		UpdatedAt:          created,
+	}
+	if c, ok := geo.FindCountry(country); ok {
+		m.CountryCode = c.Code
+		if ci, ok := geo.FindCity(c.Code, city); ok {
+			id := ci.ID
+			m.CityID = &id
+		}
+	}
+	return m
+}
+
+// NewOrderAnalysis 构造订单分析记录
+// 与 dws_orders_analysis_view 保持一致：按商户周末判断 is_weekend，非周末的商户营业时间内为工作时间，
+// timezone_offset 以秒为单位；amount 按最短十进制表示转换，如 12.34 即精确的 12.34
+func NewOrderAnalysis(merchant models.Merchant, orderID int, amount float64, orderTimeUTC time.Time) models.OrderAnalysis {
+	loc, err := time.LoadLocation(merchant.Timezone)
+	if err != nil {
+		panic(fmt.Sprintf("加载时区 %s 失败: %v", merchant.Timezone, err))
+	}
+
+	utc := orderTimeUTC.UTC()
+	local := utc.In(loc)
+	_, offset := local.Zone()
+	weekday := local.Weekday()
+	hours, err := services.MerchantBusinessHours(merchant)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return models.OrderAnalysis{
+		OrderID:        orderID,
+		OrderNumber:    fmt.Sprintf("ORD%06d", orderID),
+		Amount:         decimal.NewFromFloat(amount),
+		Currency:       "USD",
+		Status:         "paid",
+		MerchantID:     merchant.ID,
+		MerchantName:   merchant.Name,
+		Timezone:       merchant.Timezone,
+		Country:        merchant.Country,
+		City:           merchant.City,
+		OrderTimeUTC:   utc,
+		OrderTimeLocal: time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), local.Nanosecond(), time.UTC),
+		LocalDate:      local.Format("2006-01-02"),
+		LocalHour:      local.Hour(),
+		LocalDayOfWeek: int(weekday),
+		LocalWeekday:   weekday.String(),
+		IsWeekend:      hours.Weekend.Contains(weekday),
+		IsBusinessHour: hours.IsOpen(local),
+		WeekendDays:    hours.Weekend.Days(),
+		TimezoneOffset: offset,
+		IngestedAt:     utc,
+	}
+}
@@ -0,0 +1,194 @@
+package testsupport
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"timezone-saas-demo/models"
+)
+
+// mockMerchant 模拟数据中的商户，与 sql/02_sample_data.sql 的示例商户一致
+type mockMerchant struct {
+	Name     string
+	Country  string
+	City     string
+	Timezone string
+	Currency string
+	// Amount 客单价的基准值（以 Currency 计）
+	Amount float64
+}
+
+var mockMerchants = []mockMerchant{
+	{"北京科技有限公司", "中国", "北京", "Asia/Shanghai", "CNY", 800},
+	{"东京电商株式会社", "日本", "东京", "Asia/Tokyo", "JPY", 12000},
+	{"新加坡贸易公司", "新加坡", "新加坡", "Asia/Singapore", "SGD", 300},
+	{"首尔科技公司", "韩国", "首尔", "Asia/Seoul", "KRW", 90000},
+	{"伦敦金融服务", "英国", "伦敦", "Europe/London", "GBP", 120},
+	{"巴黎时尚集团", "法国", "巴黎", "Europe/Paris", "EUR", 260},
+	{"柏林科技创新", "德国", "柏林", "Europe/Berlin", "EUR", 150},
+	{"阿姆斯特丹贸易", "荷兰", "阿姆斯特丹", "Europe/Amsterdam", "EUR", 110},
+	{"纽约金融公司", "美国", "纽约", "America/New_York", "USD", 250},
+	{"洛杉矶科技", "美国", "洛杉矶", "America/Los_Angeles", "USD", 180},
+	{"芝加哥贸易", "美国", "芝加哥", "America/Chicago", "USD", 140},
+	{"圣保罗商贸", "巴西", "圣保罗", "America/Sao_Paulo", "BRL", 600},
+	{"多伦多服务", "加拿大", "多伦多", "America/Toronto", "CAD", 200},
+	{"悉尼零售集团", "澳大利亚", "悉尼", "Australia/Sydney", "AUD", 160},
+	{"奥克兰服务公司", "新西兰", "奥克兰", "Pacific/Auckland", "NZD", 130},
+	{"迪拜贸易中心", "阿联酋", "迪拜", "Asia/Dubai", "AED", 900},
+	{"莫斯科科技", "俄罗斯", "莫斯科", "Europe/Moscow", "RUB", 9000},
+}
+
+// zeroDecimalCurrencies 没有小数位的币种，金额取整
+var zeroDecimalCurrencies = map[string]bool{"JPY": true, "KRW": true}
+
+// MockOptions 模拟数据集的选项，零值字段使用默认值
+type MockOptions struct {
+	// Seed 随机种子，种子和其余选项相同时生成的数据完全相同
+	Seed int64
+	// EndDate 订单覆盖的最后一天（按 UTC 日期），默认 2024-08-19，与示例数据一致
+	EndDate time.Time
+	// Days 订单覆盖的天数，默认 14
+	Days int
+	// OrdersPerDay 每个商户每天的平均订单数，默认 12
+	OrdersPerDay int
+}
+
+// 模拟数据集的默认值
+const (
+	DefaultMockDays         = 14
+	DefaultMockOrdersPerDay = 12
+)
+
+// DefaultMockEndDate 模拟订单默认覆盖到的日期
+var DefaultMockEndDate = time.Date(2024, 8, 19, 0, 0, 0, 0, time.UTC)
+
+// NewMockFakes 创建填充了确定性示例数据的内存仓储，供 serve --mock 离线提供完整 API
+// 包含示例数据中的 17 个商户、按本地营业时间分布的订单、夏令时用例、订阅配置和索引统计
+func NewMockFakes(opts MockOptions) *Fakes {
+	if opts.EndDate.IsZero() {
+		opts.EndDate = DefaultMockEndDate
+	}
+	if opts.Days <= 0 {
+		opts.Days = DefaultMockDays
+	}
+	if opts.OrdersPerDay <= 0 {
+		opts.OrdersPerDay = DefaultMockOrdersPerDay
+	}
+	end := time.Date(opts.EndDate.Year(), opts.EndDate.Month(), opts.EndDate.Day(), 0, 0, 0, 0, time.UTC)
+	rng := rand.New(rand.NewSource(opts.Seed))
+	// 发货时间使用单独的随机源，不影响其余示例数据
+	shipRng := rand.New(rand.NewSource(opts.Seed + 1))
+
+	f := NewFakes()
+	orderID := 1
+	for i, mm := range mockMerchants {
+		merchant := NewMerchant(i+1, mm.Name, mm.Timezone, mm.Country, mm.City)
+		f.Merchants.Add(merchant)
+		loc, err := time.LoadLocation(mm.Timezone)
+		if err != nil {
+			panic(fmt.Sprintf("加载时区 %s 失败: %v", mm.Timezone, err))
+		}
+
+		for d := opts.Days - 1; d >= 0; d-- {
+			day := end.AddDate(0, 0, -d)
+			count := opts.OrdersPerDay/2 + rng.Intn(opts.OrdersPerDay+1)
+			for n := 0; n < count; n++ {
+				local := time.Date(day.Year(), day.Month(), day.Day(), mockOrderHour(rng), rng.Intn(60), rng.Intn(60), 0, loc)
+				order := NewOrderAnalysis(merchant, orderID, mockAmount(rng, mm), local)
+				order.OrderNumber = fmt.Sprintf("ORD_MOCK_%s_%06d", day.Format("20060102"), orderID)
+				order.Currency = mm.Currency
+				order.Status = mockOrderStatus(rng)
+				f.Orders.Add(order)
+				if order.Status == models.OrderStatusShipped || order.Status == models.OrderStatusDelivered {
+					// 发货晚于示例数据最后一天的订单视为没有发货时间
+					if shipped := mockShippedTime(shipRng, local, merchant.WeekendDays); shipped.Before(end.AddDate(0, 0, 1)) {
+						f.Orders.SetShippedTime(orderID, shipped)
+					}
+				}
+				orderID++
+			}
+		}
+	}
+	f.AddDSTFixtures(len(mockMerchants) + 1)
+
+	// 部分商户使用非默认的订阅，覆盖月末锚定和按周计费
+	f.Billing.SetSubscription(models.Subscription{MerchantID: 1, PlanCode: "pro", IntervalUnit: "month", IntervalCount: 1, AnchorDate: "2024-01-31", Status: "active"})
+	f.Billing.SetSubscription(models.Subscription{MerchantID: 9, PlanCode: "standard", IntervalUnit: "week", IntervalCount: 2, AnchorDate: "2024-03-04", Status: "active"})
+	f.Billing.SetSubscription(models.Subscription{MerchantID: 14, PlanCode: "enterprise", IntervalUnit: "year", IntervalCount: 1, AnchorDate: "2024-02-29", Status: "active"})
+
+	f.IndexStats.AddIndex(models.IndexInfo{Table: "dws_orders", Name: "dws_orders_pkey", Columns: []string{"order_id"}, Unique: true, Primary: true, Scans: 1200})
+	f.IndexStats.AddIndex(models.IndexInfo{Table: "dws_orders", Name: "idx_orders_merchant_id", Columns: []string{"merchant_id"}, Scans: 40})
+	f.IndexStats.AddIndex(models.IndexInfo{Table: "dws_orders", Name: "idx_orders_merchant_time", Columns: []string{"merchant_id", "order_time_utc"}, Scans: 3500})
+	f.IndexStats.AddIndex(models.IndexInfo{Table: "dws_orders", Name: "idx_orders_source", Columns: []string{"order_source"}})
+	f.IndexStats.AddIndex(models.IndexInfo{Table: "dim_merchant", Name: "idx_merchant_timezone", Columns: []string{"timezone"}, Scans: 80})
+	f.IndexStats.SetTableStats(models.TableScanStats{Table: "dws_orders", LiveRows: int64(orderID - 1), SeqScans: 600, IndexScans: 4700})
+	f.IndexStats.SetTableStats(models.TableScanStats{Table: "dim_merchant", LiveRows: int64(len(mockMerchants)), SeqScans: 2000, IndexScans: 80})
+
+	return f
+}
+
+// mockOrderHour 订单的本地小时，大部分落在 9~21 点
+func mockOrderHour(rng *rand.Rand) int {
+	if rng.Intn(5) == 0 {
+		return rng.Intn(24)
+	}
+	return 9 + rng.Intn(13)
+}
+
+// mockAmount 在客单价的 0.2~2 倍之间取值，按币种保留小数位
+func mockAmount(rng *rand.Rand, mm mockMerchant) float64 {
+	amount := mm.Amount * (0.2 + rng.Float64()*1.8)
+	if zeroDecimalCurrencies[mm.Currency] {
+		return math.Round(amount)
+	}
+	return math.Round(amount*100) / 100
+}
+
+// mockShippedTime 订单的发货时间：下单后 0.5~3 小时备货完成，只在非周末的本地 9~19 点发货，
+// 营业时间外备货完成的订单在下一个工作日早上排队发出，因此晚间和周末下单的履约时长更长
+func mockShippedTime(rng *rand.Rand, ordered time.Time, weekend []int) time.Time {
+	ready := ordered.Add(time.Duration(30+rng.Intn(150)) * time.Minute)
+	deferred := false
+	for {
+		morning := time.Date(ready.Year(), ready.Month(), ready.Day(), 9, 0, 0, 0, ready.Location())
+		switch {
+		case mockIsWeekend(ready.Weekday(), weekend) || ready.Hour() >= 19:
+			ready, deferred = morning.AddDate(0, 0, 1), true
+		case ready.Hour() < 9:
+			ready, deferred = morning, true
+		default:
+			if deferred {
+				ready = ready.Add(time.Duration(rng.Intn(180)) * time.Minute)
+			}
+			return ready
+		}
+	}
+}
+
+// mockIsWeekend day 是否为商户周末
+func mockIsWeekend(day time.Weekday, weekend []int) bool {
+	for _, d := range weekend {
+		if d == int(day) {
+			return true
+		}
+	}
+	return false
+}
+
+// mockOrderStatus 订单状态：大部分已支付，少量已取消
+func mockOrderStatus(rng *rand.Rand) string {
+	switch p := rng.Intn(100); {
+	case p < 60:
+		return "paid"
+	case p < 75:
+		return "shipped"
+	case p < 92:
+		return "delivered"
+	case p < 97:
+		return "pending"
+	default:
+		return "cancelled"
+	}
+}
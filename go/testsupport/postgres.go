@@ -0,0 +1,155 @@
+//go:build integration
+
+package testsupport
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"timezone-saas-demo/database"
+)
+
+// PostgresContainer 通过 testcontainers 启动的临时 PostgreSQL 实例
+type PostgresContainer struct {
+	container *postgres.PostgresContainer
+	Config    database.Config
+}
+
+// StartPostgres 启动一个临时 PostgreSQL 容器并等待其可连接
+// 镜像可通过 TEST_POSTGRES_IMAGE 覆盖，默认与 docker-compose 保持一致
+func StartPostgres(ctx context.Context) (*PostgresContainer, error) {
+	image := os.Getenv("TEST_POSTGRES_IMAGE")
+	if image == "" {
+		image = "postgres:15-alpine"
+	}
+
+	// 官方镜像初始化期间会重启一次，第二次输出就绪日志后才真正可用
+	container, err := postgres.RunContainer(ctx,
+		testcontainers.WithImage(image),
+		postgres.WithDatabase("timezone_test"),
+		postgres.WithUsername("postgres"),
+		postgres.WithPassword("postgres"),
+		testcontainers.WithEnv(map[string]string{
+			"POSTGRES_INITDB_ARGS": "--timezone=UTC",
+			"TZ":                   "UTC",
+		}),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(time.Minute),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("启动 PostgreSQL 容器失败: %w", err)
+	}
+	c := &PostgresContainer{container: container}
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		c.Terminate()
+		return nil, fmt.Errorf("获取容器地址失败: %w", err)
+	}
+	port, err := container.MappedPort(ctx, "5432/tcp")
+	if err != nil {
+		c.Terminate()
+		return nil, fmt.Errorf("获取容器端口失败: %w", err)
+	}
+
+	c.Config = database.Config{
+		Host:     host,
+		Port:     port.Int(),
+		User:     "postgres",
+		Password: "postgres",
+		DBName:   "timezone_test",
+		SSLMode:  "disable",
+		Timezone: "UTC",
+	}
+	return c, nil
+}
+
+// Connect 等待数据库接受连接并返回连接，超时由 ctx 控制
+// 就绪日志出现后端口映射可能还要片刻才转发连接，因此轮询而不是只连一次
+func (c *PostgresContainer) Connect(ctx context.Context) (*database.DB, error) {
+	var lastErr error
+	for {
+		db, err := database.NewConnectionWithConfig(c.Config)
+		if err == nil {
+			return db, nil
+		}
+		lastErr = err
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("等待 PostgreSQL 就绪超时: %w", lastErr)
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// Terminate 停止并删除容器
+func (c *PostgresContainer) Terminate() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return c.container.Terminate(ctx)
+}
+
+// IntegrationEnv 集成测试环境：临时 PostgreSQL + 完整架构 + 示例数据 + 夏令时用例
+type IntegrationEnv struct {
+	DB          *database.DB
+	Container   *PostgresContainer
+	MerchantIDs map[string]int
+}
+
+// NewIntegrationEnv 启动集成测试环境，测试结束时自动清理
+// 设置了 SKIP_INTEGRATION 或 docker 不可用时跳过测试
+func NewIntegrationEnv(t testing.TB) *IntegrationEnv {
+	t.Helper()
+
+	if os.Getenv("SKIP_INTEGRATION") != "" {
+		t.Skip("已设置 SKIP_INTEGRATION，跳过集成测试")
+	}
+	provider, err := testcontainers.ProviderDocker.GetProvider()
+	if err != nil {
+		t.Skipf("docker 不可用，跳过集成测试: %v", err)
+	}
+	if err := provider.Health(context.Background()); err != nil {
+		t.Skipf("docker 不可用，跳过集成测试: %v", err)
+	}
+
+	sqlDir, err := FindSQLDir()
+	if err != nil {
+		t.Fatalf("定位 sql 目录失败: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	container, err := StartPostgres(ctx)
+	if err != nil {
+		t.Fatalf("启动 PostgreSQL 失败: %v", err)
+	}
+	t.Cleanup(func() { container.Terminate() })
+
+	db, err := container.Connect(ctx)
+	if err != nil {
+		t.Fatalf("连接 PostgreSQL 失败: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := ApplyScripts(db, sqlDir, true); err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	merchantIDs, err := SeedDSTFixtures(db)
+	if err != nil {
+		t.Fatalf("写入夏令时用例失败: %v", err)
+	}
+
+	return &IntegrationEnv{DB: db, Container: container, MerchantIDs: merchantIDs}
+}
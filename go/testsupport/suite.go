@@ -0,0 +1,2101 @@
+package testsupport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"timezone-saas-demo/client"
+	"timezone-saas-demo/database"
+	"timezone-saas-demo/geo"
+	"timezone-saas-demo/locale"
+	"timezone-saas-demo/models"
+	"timezone-saas-demo/money"
+	"timezone-saas-demo/repository"
+	"timezone-saas-demo/services"
+)
+
+// FindSQLDir 查找仓库中的 sql 目录：优先使用 SQL_DIR，否则从当前目录逐级向上查找
+func FindSQLDir() (string, error) {
+	if dir := os.Getenv("SQL_DIR"); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		candidate := filepath.Join(dir, "sql")
+		if _, err := os.Stat(filepath.Join(candidate, "01_schema.sql")); err == nil {
+			return candidate, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", os.ErrNotExist
+		}
+		dir = parent
+	}
+}
+
+// ApplyScripts 按顺序执行 sqlDir 下的架构脚本，withSeed 为 true 时同时导入示例数据
+func ApplyScripts(db *database.DB, sqlDir string, withSeed bool) error {
+	scripts, err := database.ListScripts(sqlDir)
+	if err != nil {
+		return err
+	}
+	for _, script := range scripts {
+		if script.Kind == database.ScriptExample || (script.Kind == database.ScriptSeed && !withSeed) {
+			continue
+		}
+		if err := db.ExecuteScript(script.Path); err != nil {
+			return fmt.Errorf("执行 %s 失败: %w", script.Name, err)
+		}
+	}
+	return nil
+}
+
+// RunServiceSuite 针对已写入示例数据和夏令时用例的数据源，逐一验证 TimezoneService 的所有方法
+// 既可用于 PostgreSQL 集成环境，也可用于 Fakes 内存环境
+func RunServiceSuite(t *testing.T, svc *services.TimezoneService) {
+	t.Run("HealthCheck", func(t *testing.T) {
+		if err := svc.HealthCheck(); err != nil {
+			t.Fatalf("健康检查失败: %v", err)
+		}
+	})
+
+	t.Run("GetMerchants", func(t *testing.T) {
+		merchants, err := svc.GetMerchants()
+		if err != nil {
+			t.Fatalf("获取商户失败: %v", err)
+		}
+		found := map[string]bool{}
+		for _, m := range merchants {
+			found[m.Timezone] = true
+		}
+		for _, c := range DSTCases {
+			if !found[c.Merchant.Timezone] {
+				t.Errorf("商户列表缺少时区 %s", c.Merchant.Timezone)
+			}
+		}
+	})
+
+	t.Run("GetOrders/DST", func(t *testing.T) {
+		for _, c := range DSTCases {
+			orders, err := svc.GetOrders(models.OrderFilter{Timezone: c.Merchant.Timezone, Limit: 10000})
+			if err != nil {
+				t.Fatalf("获取订单失败: %v", err)
+			}
+			order, ok := findOrder(orders, c.OrderNo)
+			if !ok {
+				t.Errorf("%s: 未找到订单 %s", c.Name, c.OrderNo)
+				continue
+			}
+			if order.LocalDate != c.WantLocalDate || order.LocalHour != c.WantLocalHour || order.TimezoneOffset != c.WantOffset {
+				t.Errorf("%s: 得到 %s %02d时 偏移%d, 期望 %s %02d时 偏移%d", c.Name,
+					order.LocalDate, order.LocalHour, order.TimezoneOffset,
+					c.WantLocalDate, c.WantLocalHour, c.WantOffset)
+			}
+		}
+	})
+
+	t.Run("GetOrders/Pagination", func(t *testing.T) {
+		first, err := svc.GetOrders(models.OrderFilter{Limit: 2})
+		if err != nil {
+			t.Fatalf("获取订单失败: %v", err)
+		}
+		second, err := svc.GetOrders(models.OrderFilter{Limit: 2, Offset: 2})
+		if err != nil {
+			t.Fatalf("获取订单失败: %v", err)
+		}
+		if len(first) != 2 || len(second) == 0 {
+			t.Fatalf("分页结果数量异常: %d, %d", len(first), len(second))
+		}
+		if first[1].OrderTimeUTC.Before(second[0].OrderTimeUTC) {
+			t.Errorf("订单未按 UTC 时间倒序排列")
+		}
+	})
+
+	t.Run("StreamOrders", func(t *testing.T) {
+		want, err := svc.GetOrders(models.OrderFilter{Limit: 100000})
+		if err != nil {
+			t.Fatalf("获取订单失败: %v", err)
+		}
+		var got []int
+		err = svc.StreamOrders(context.Background(), models.OrderFilter{}, func(order models.OrderAnalysis) error {
+			got = append(got, order.OrderID)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("流式读取订单失败: %v", err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("流式读取 %d 条订单, 分页读取 %d 条", len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i].OrderID {
+				t.Fatalf("第 %d 条订单不一致: %d != %d", i, got[i], want[i].OrderID)
+			}
+		}
+
+		// 回调返回错误时停止扫描并原样返回
+		stop := errors.New("stop")
+		calls := 0
+		err = svc.StreamOrders(context.Background(), models.OrderFilter{}, func(models.OrderAnalysis) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) || calls != 1 {
+			t.Errorf("回调出错后应立即停止: err=%v, calls=%d", err, calls)
+		}
+	})
+
+	t.Run("GetAnalysisData/DSTStart", func(t *testing.T) {
+		analysis, err := svc.GetAnalysisData(context.Background(), "2024-03-31", "", nil)
+		if err != nil {
+			t.Fatalf("获取分析数据失败: %v", err)
+		}
+		// 柏林当天不存在 02 点，订单分别落在 01 点和 03 点
+		hours := hourCounts(analysis)
+		if hours[1] < 1 || hours[3] < 1 {
+			t.Errorf("夏令时开始日小时分解异常: %v", hours)
+		}
+	})
+
+	t.Run("GetAnalysisData/DSTEnd", func(t *testing.T) {
+		analysis, err := svc.GetAnalysisData(context.Background(), "2024-10-27", "", nil)
+		if err != nil {
+			t.Fatalf("获取分析数据失败: %v", err)
+		}
+		// 柏林 02:30 出现两次，两笔订单都应计入 02 点
+		if hours := hourCounts(analysis); hours[2] < 2 {
+			t.Errorf("夏令时结束日 02 点订单数 = %d, 期望至少 2", hours[2])
+		}
+		if analysis.TotalOrders < 2 {
+			t.Errorf("总订单数 = %d, 期望至少 2", analysis.TotalOrders)
+		}
+	})
+
+	t.Run("GetAnalysisData/Amounts", func(t *testing.T) {
+		const date = "2024-10-27"
+		analysis, err := svc.GetAnalysisData(context.Background(), date, "", nil)
+		if err != nil {
+			t.Fatalf("获取分析数据失败: %v", err)
+		}
+		orders, err := svc.GetOrders(models.OrderFilter{Limit: 10000})
+		if err != nil {
+			t.Fatalf("获取订单失败: %v", err)
+		}
+		// 各币种合计应与逐笔累加的精确值一致，不能有浮点误差，也不能跨币种相加；
+		// 默认口径不统计已取消订单，营收扣除归属原订单日期的退款
+		gross := map[string]decimal.Decimal{}
+		for _, o := range orders {
+			if o.LocalDate != date || o.Status == models.OrderStatusCancelled {
+				continue
+			}
+			gross[o.Currency] = gross[o.Currency].Add(o.Amount)
+		}
+		if analysis.TotalAmount != nil {
+			t.Errorf("未指定币种时不应返回单一合计: %s", analysis.TotalAmount)
+		}
+		for _, total := range analysis.TotalsByCurrency {
+			if _, ok := gross[total.Currency]; !ok && total.OrderCount > 0 {
+				t.Errorf("币种分组 = %v, 期望 %v", analysis.TotalsByCurrency, gross)
+			}
+		}
+		for _, total := range analysis.TotalsByCurrency {
+			if !total.RefundAmount.Equal(total.RefundByOrderDate) || total.RefundByOrderDate.GreaterThan(total.GrossAmount) {
+				t.Errorf("%s 退款 = %s（按订单日期 %s）, 毛额 %s", total.Currency, total.RefundAmount, total.RefundByOrderDate, total.GrossAmount)
+			}
+			net := gross[total.Currency].Sub(total.RefundAmount)
+			if !total.GrossAmount.Equal(gross[total.Currency]) || !total.NetAmount.Equal(net) || !total.TotalAmount.Equal(net) {
+				t.Errorf("%s 合计 = %s 毛额 %s 净额 %s, 期望毛额 %s 净额 %s", total.Currency,
+					total.TotalAmount, total.GrossAmount, total.NetAmount, gross[total.Currency], net)
+			}
+
+			single, err := svc.GetAnalysisData(context.Background(), date, strings.ToLower(total.Currency), nil)
+			if err != nil {
+				t.Fatalf("按币种 %s 获取分析数据失败: %v", total.Currency, err)
+			}
+			if single.Currency != total.Currency || single.TotalAmount == nil || !single.TotalAmount.Equal(total.TotalAmount) {
+				t.Errorf("目标币种 %s 合计 = %s %v, 期望 %s", total.Currency, single.Currency, single.TotalAmount, total.TotalAmount)
+			}
+		}
+		for _, b := range analysis.HourlyBreakdown {
+			if !b.AvgAmount.Equal(money.Round(b.AvgAmount, b.Currency)) {
+				t.Errorf("%02d 点平均金额 %s 未按币种 %q 舍入", b.Hour, b.AvgAmount, b.Currency)
+			}
+		}
+
+		if _, err := svc.GetAnalysisData(context.Background(), date, "XYZ1", nil); !errors.Is(err, services.ErrInvalidArgument) {
+			t.Errorf("非法币种应返回 ErrInvalidArgument, 得到 %v", err)
+		}
+	})
+
+	t.Run("GetAnalysisData/Statuses", func(t *testing.T) {
+		const date = "2024-10-27"
+		statuses := []string{models.OrderStatusPaid}
+		analysis, err := svc.GetAnalysisData(context.Background(), date, "", statuses)
+		if err != nil {
+			t.Fatalf("获取分析数据失败: %v", err)
+		}
+		orders, err := svc.GetOrders(models.OrderFilter{Statuses: statuses, Limit: 10000})
+		if err != nil {
+			t.Fatalf("获取订单失败: %v", err)
+		}
+		count := 0
+		for _, o := range orders {
+			if o.Status != models.OrderStatusPaid {
+				t.Errorf("订单 %s 状态 %s 不在过滤条件中", o.OrderNumber, o.Status)
+			}
+			if o.LocalDate == date {
+				count++
+			}
+		}
+		if analysis.TotalOrders != count || len(analysis.Statuses) != 1 {
+			t.Errorf("按状态统计订单数 = %d %v, 期望 %d", analysis.TotalOrders, analysis.Statuses, count)
+		}
+	})
+
+	t.Run("GetAnalysisData/QueryModes", func(t *testing.T) {
+		const date = "2024-10-27"
+		fanout, err := svc.GetAnalysisData(context.Background(), date, "USD", nil)
+		if err != nil {
+			t.Fatalf("fanout 获取分析数据失败: %v", err)
+		}
+		if err := svc.SetQueryMode(services.AnalysisQueryModeSingle); err != nil {
+			t.Fatalf("切换查询方式失败: %v", err)
+		}
+		defer svc.SetQueryMode(services.AnalysisQueryModeFanout)
+		single, err := svc.GetAnalysisData(context.Background(), date, "USD", nil)
+		if err != nil {
+			t.Fatalf("single 获取分析数据失败: %v", err)
+		}
+		// 后端不支持 single 时回退为 fanout，两种方式的结果都应一致
+		fanout.QueryMode, single.QueryMode = "", ""
+		want, _ := json.Marshal(fanout)
+		got, _ := json.Marshal(single)
+		if string(got) != string(want) {
+			t.Errorf("single 与 fanout 结果不一致:\n%s\n%s", got, want)
+		}
+		if err := svc.SetQueryMode("parallel"); !errors.Is(err, services.ErrInvalidArgument) {
+			t.Errorf("不支持的查询方式应返回 ErrInvalidArgument, 得到 %v", err)
+		}
+	})
+
+	t.Run("GetAnalysisData/InvalidDate", func(t *testing.T) {
+		if _, err := svc.GetAnalysisData(context.Background(), "2024/03/31", "", nil); err == nil {
+			t.Errorf("非法日期应返回错误")
+		}
+	})
+
+	t.Run("CompareTimezones/DST", func(t *testing.T) {
+		for _, c := range DSTCases {
+			comparison, err := svc.CompareTimezones(c.OrderTimeUTC.Format(time.RFC3339), nil)
+			if err != nil {
+				t.Fatalf("时区对比失败: %v", err)
+			}
+			found := false
+			for _, item := range comparison.Comparisons {
+				if item.Timezone != c.Merchant.Timezone {
+					continue
+				}
+				found = true
+				if item.Hour != c.WantLocalHour || item.LocalDate != c.WantLocalDate {
+					t.Errorf("%s: 对比结果 %s %02d时, 期望 %s %02d时", c.Name, item.LocalDate, item.Hour, c.WantLocalDate, c.WantLocalHour)
+				}
+			}
+			if !found {
+				t.Errorf("%s: 对比结果缺少时区 %s", c.Name, c.Merchant.Timezone)
+			}
+		}
+	})
+
+	t.Run("CompareTimezones/InvalidTime", func(t *testing.T) {
+		if _, err := svc.CompareTimezones("not-a-time", nil); err == nil {
+			t.Errorf("非法时间应返回错误")
+		}
+	})
+
+	t.Run("CompareTimezones/ExplicitZones", func(t *testing.T) {
+		// 柏林夏令时开始瞬间，使用 +08:00 表示同一时刻
+		comparison, err := svc.CompareTimezones("2024-03-31T09:00:00+08:00", []string{"Europe/Berlin", "Asia/Kolkata"})
+		if err != nil {
+			t.Fatalf("时区对比失败: %v", err)
+		}
+		if len(comparison.Comparisons) != 2 {
+			t.Fatalf("对比结果数量 = %d, 期望 2", len(comparison.Comparisons))
+		}
+		berlin, kolkata := comparison.Comparisons[0], comparison.Comparisons[1]
+		if berlin.Hour != 3 || !berlin.IsDST || berlin.Offset != "+02:00" {
+			t.Errorf("柏林对比结果异常: %+v", berlin)
+		}
+		if kolkata.Offset != "+05:30" || kolkata.TimeDifference != "+5小时30分" {
+			t.Errorf("加尔各答对比结果异常: %+v", kolkata)
+		}
+		if _, err := svc.CompareTimezones("2024-03-31T01:00:00Z", []string{"Mars/Olympus"}); err == nil {
+			t.Errorf("非法时区应返回错误")
+		}
+	})
+
+	t.Run("CompareTimezones/Weekend", func(t *testing.T) {
+		// 周五中午：利雅得周末为周五周六，柏林为周六周日
+		comparison, err := svc.CompareTimezones("2024-08-16T10:00:00Z", []string{"Asia/Riyadh", "Europe/Berlin"})
+		if err != nil {
+			t.Fatalf("时区对比失败: %v", err)
+		}
+		riyadh, berlin := comparison.Comparisons[0], comparison.Comparisons[1]
+		if !riyadh.IsWeekend || fmt.Sprint(riyadh.WeekendDays) != "[5 6]" {
+			t.Errorf("利雅得周五应为周末: %+v", riyadh)
+		}
+		if berlin.IsWeekend || fmt.Sprint(berlin.WeekendDays) != "[0 6]" {
+			t.Errorf("柏林周五不应为周末: %+v", berlin)
+		}
+		if comparison.Statistics.WeekendCount != 1 {
+			t.Errorf("周末数 = %d, 期望 1", comparison.Statistics.WeekendCount)
+		}
+	})
+
+	t.Run("FindOverlap", func(t *testing.T) {
+		merchants, err := svc.GetMerchants()
+		if err != nil {
+			t.Fatalf("获取商户失败: %v", err)
+		}
+		ids := map[string]int{}
+		for _, m := range merchants {
+			ids[m.Timezone] = m.ID
+		}
+		// 2024-08-19 为周一：柏林 09:00-19:00 CEST = 07:00-17:00 UTC，纽约 09:00-19:00 EDT = 13:00-23:00 UTC
+		overlap, err := svc.FindOverlap([]int{ids["Europe/Berlin"], ids["America/New_York"]}, services.OverlapOptions{
+			Date:     "2024-08-19",
+			Timezone: "UTC",
+			Window:   time.Hour,
+		})
+		if err != nil {
+			t.Fatalf("重叠分析失败: %v", err)
+		}
+		wantStart := time.Date(2024, 8, 19, 13, 0, 0, 0, time.UTC)
+		wantEnd := time.Date(2024, 8, 19, 17, 0, 0, 0, time.UTC)
+		if len(overlap.Overlaps) != 1 || !overlap.Overlaps[0].StartUTC.Equal(wantStart) || !overlap.Overlaps[0].EndUTC.Equal(wantEnd) {
+			t.Errorf("重叠区间 = %+v, 期望 13:00-17:00 UTC", overlap.Overlaps)
+		}
+		if len(overlap.Candidates) == 0 || !overlap.Candidates[0].AllAvailable {
+			t.Errorf("首个候选时段应所有商户可参加: %+v", overlap.Candidates)
+		}
+		if _, err := svc.FindOverlap([]int{999999}, services.OverlapOptions{Date: "2024-08-19", Window: time.Hour}); !errors.Is(err, services.ErrNotFound) {
+			t.Errorf("不存在的商户应返回 ErrNotFound, 得到 %v", err)
+		}
+	})
+
+	t.Run("GetBoundaries/DST", func(t *testing.T) {
+		merchants, err := svc.GetMerchants()
+		if err != nil {
+			t.Fatalf("获取商户失败: %v", err)
+		}
+		var berlinID int
+		for _, m := range merchants {
+			if m.Timezone == "Europe/Berlin" {
+				berlinID = m.ID
+			}
+		}
+		// 2024-03-30 周六中午，当晚柏林进入夏令时，下周一开始营业
+		b, err := svc.GetBoundaries(berlinID, "2024-03-30T12:00:00Z")
+		if err != nil {
+			t.Fatalf("获取时间边界失败: %v", err)
+		}
+		checks := map[string]struct {
+			got, want time.Time
+		}{
+			"下一个午夜":  {b.NextMidnight.UTC, time.Date(2024, 3, 30, 23, 0, 0, 0, time.UTC)},
+			"下周一零点":  {b.NextWeekStart.UTC, time.Date(2024, 3, 31, 22, 0, 0, 0, time.UTC)},
+			"下月一日零点": {b.NextMonthStart.UTC, time.Date(2024, 3, 31, 22, 0, 0, 0, time.UTC)},
+		}
+		for name, c := range checks {
+			if !c.got.Equal(c.want) {
+				t.Errorf("%s = %v, 期望 %v", name, c.got, c.want)
+			}
+		}
+		if b.IsOpen || b.NextOpen == nil || !b.NextOpen.UTC.Equal(time.Date(2024, 4, 1, 7, 0, 0, 0, time.UTC)) {
+			t.Errorf("下一次营业开始异常: %+v", b.NextOpen)
+		}
+		if b.NextClose == nil || !b.NextClose.UTC.Equal(time.Date(2024, 4, 1, 17, 0, 0, 0, time.UTC)) {
+			t.Errorf("下一次营业结束异常: %+v", b.NextClose)
+		}
+	})
+
+	t.Run("ExpandSchedule/DST", func(t *testing.T) {
+		// 柏林每天 02:30：夏令时开始日顺延到 03:30，结束日取第一次出现
+		expansion, err := svc.ExpandSchedule(services.ScheduleRequest{
+			Rule:     "FREQ=DAILY;BYHOUR=2;BYMINUTE=30",
+			Timezone: "Europe/Berlin",
+			From:     "2024-03-31",
+			To:       "2024-03-31",
+		})
+		if err != nil {
+			t.Fatalf("展开重复规则失败: %v", err)
+		}
+		if expansion.Count != 1 || !expansion.Occurrences[0].Shifted ||
+			!expansion.Occurrences[0].UTC.Equal(time.Date(2024, 3, 31, 1, 30, 0, 0, time.UTC)) {
+			t.Errorf("夏令时开始日展开异常: %+v", expansion.Occurrences)
+		}
+
+		expansion, err = svc.ExpandSchedule(services.ScheduleRequest{
+			Rule:     "FREQ=DAILY;BYHOUR=2;BYMINUTE=30",
+			Timezone: "Europe/Berlin",
+			From:     "2024-10-27",
+			To:       "2024-10-27",
+			Overlap:  "both",
+		})
+		if err != nil {
+			t.Fatalf("展开重复规则失败: %v", err)
+		}
+		if expansion.Count != 2 || !expansion.Occurrences[0].Ambiguous {
+			t.Errorf("夏令时结束日展开异常: %+v", expansion.Occurrences)
+		}
+	})
+
+	t.Run("LookupTimezone", func(t *testing.T) {
+		at := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+		cases := []struct {
+			lat, lon float64
+			timezone string
+			offset   int
+		}{
+			{31.2, 121.5, "Asia/Shanghai", 8 * 3600},
+			// 阿德莱德为半小时偏移，7 月为南半球冬季
+			{-34.9, 138.6, "Australia/Adelaide", 9*3600 + 1800},
+			// 太平洋中部远离已知城市，按经度取航海时区
+			{0, -140, "Etc/GMT+9", -9 * 3600},
+		}
+		for _, c := range cases {
+			lookup, err := svc.LookupTimezone(c.lat, c.lon, at)
+			if err != nil {
+				t.Fatalf("查询 (%v, %v) 失败: %v", c.lat, c.lon, err)
+			}
+			if lookup.Timezone != c.timezone || lookup.OffsetSeconds != c.offset {
+				t.Errorf("(%v, %v) = %s %d, 期望 %s %d", c.lat, c.lon, lookup.Timezone, lookup.OffsetSeconds, c.timezone, c.offset)
+			}
+		}
+		if _, err := svc.LookupTimezone(91, 0, at); !errors.Is(err, services.ErrInvalidArgument) {
+			t.Errorf("超出范围的纬度应返回 ErrInvalidArgument, 得到 %v", err)
+		}
+	})
+
+	t.Run("ConvertTimestamps/DST", func(t *testing.T) {
+		items := []models.ConversionItem{
+			{Timestamp: "2024-03-31T02:30:00", FromTZ: "Europe/Berlin", ToTZ: "Asia/Tokyo"},
+			{Timestamp: "2024-10-27 02:30", FromTZ: "Europe/Berlin", ToTZ: "UTC"},
+			{Timestamp: "2024-08-19T23:30:00Z", FromTZ: "UTC", ToTZ: "Asia/Shanghai"},
+			{Timestamp: "2024-08-19T10:00:00", FromTZ: "Mars/Olympus", ToTZ: "UTC"},
+		}
+		result, err := svc.ConvertTimestamps(items, "", "later")
+		if err != nil {
+			t.Fatalf("批量转换失败: %v", err)
+		}
+		if result.Count != 4 || result.Failed != 1 || result.Results[3].Error == "" {
+			t.Fatalf("应只有非法时区一项失败: count=%d failed=%d", result.Count, result.Failed)
+		}
+
+		// 柏林 02:30 不存在，顺延为夏令时 03:30
+		gap := result.Results[0]
+		if !gap.Shifted || gap.UTC != "2024-03-31T01:30:00Z" || gap.From.LocalTime != "2024-03-31T03:30:00" || !gap.From.IsDST {
+			t.Errorf("夏令时跳过区间 = shifted %v utc %s from %+v", gap.Shifted, gap.UTC, gap.From)
+		}
+		// 柏林 02:30 出现两次，later 取标准时间
+		overlap := result.Results[1]
+		if !overlap.Ambiguous || overlap.UTC != "2024-10-27T01:30:00Z" || overlap.From.IsDST {
+			t.Errorf("夏令时重复区间 = ambiguous %v utc %s", overlap.Ambiguous, overlap.UTC)
+		}
+		shift := result.Results[2]
+		if shift.DayShift != 1 || shift.To.LocalTime != "2024-08-20T07:30:00" || shift.To.Offset != "+08:00" {
+			t.Errorf("跨日转换 = day_shift %d to %+v", shift.DayShift, shift.To)
+		}
+
+		skipped, err := svc.ConvertTimestamps(items[:1], "skip", "")
+		if err != nil || skipped.Failed != 1 {
+			t.Errorf("gap=skip 时不存在的本地时间应报错: %+v, %v", skipped, err)
+		}
+		if _, err := svc.ConvertTimestamps(nil, "", ""); !errors.Is(err, services.ErrInvalidArgument) {
+			t.Errorf("空列表应返回参数错误, 得到 %v", err)
+		}
+	})
+
+	t.Run("GetTimezoneRules", func(t *testing.T) {
+		// 莫斯科 2011 年起取消夏令时改用 +04:00，2014 年改回 +03:00
+		rules, err := svc.GetTimezoneRules("Europe/Moscow", "2010-01-01", "2016-01-01")
+		if err != nil {
+			t.Fatalf("获取时区规则失败: %v", err)
+		}
+		kinds := make([]string, 0, len(rules.Transitions))
+		for _, tr := range rules.Transitions {
+			kinds = append(kinds, fmt.Sprintf("%s%+d", tr.Kind, tr.DeltaSeconds/3600))
+		}
+		want := "dst_start+1 dst_end-1 offset_change+1 offset_change-1"
+		if got := strings.Join(kinds, " "); got != want {
+			t.Errorf("莫斯科偏移切换 = %s, 期望 %s", got, want)
+		}
+
+		rules, err = svc.GetTimezoneRules("Europe/Berlin", "2024-01-01", "2025-01-01")
+		if err != nil {
+			t.Fatalf("获取时区规则失败: %v", err)
+		}
+		if rules.Count != 2 || rules.Transitions[0].LocalBefore != "2024-03-31 01:59:59" || rules.Transitions[0].LocalAfter != "2024-03-31 03:00:00" {
+			t.Errorf("柏林 2024 年偏移切换 = %+v", rules.Transitions)
+		}
+
+		if _, err := svc.GetTimezoneRules("Europe/Berlin", "2025-01-01", "2024-01-01"); !errors.Is(err, services.ErrInvalidArgument) {
+			t.Errorf("结束日期早于开始日期应返回参数错误, 得到 %v", err)
+		}
+	})
+
+	t.Run("GetTimezoneDemo", func(t *testing.T) {
+		demo, err := svc.GetTimezoneDemo(time.Date(2024, 8, 19, 0, 0, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatalf("获取时区演示失败: %v", err)
+		}
+		s := demo.Summary
+		if s.TotalTimezones == 0 || s.NextDayCount+s.SameDayCount+s.PrevDayCount != s.TotalTimezones {
+			t.Errorf("演示汇总不一致: %+v", s)
+		}
+	})
+
+	berlinID := dstMerchantID(t, svc, dstBerlin)
+
+	t.Run("GetMerchant", func(t *testing.T) {
+		merchant, err := svc.GetMerchant(berlinID)
+		if err != nil {
+			t.Fatalf("获取商户失败: %v", err)
+		}
+		if merchant.ID != berlinID || merchant.Timezone != dstBerlin.Timezone {
+			t.Errorf("商户 = %d %s, 期望 %d %s", merchant.ID, merchant.Timezone, berlinID, dstBerlin.Timezone)
+		}
+		if _, err := svc.GetMerchant(999999); !errors.Is(err, services.ErrNotFound) {
+			t.Errorf("不存在的商户应返回 ErrNotFound, 得到 %v", err)
+		}
+	})
+
+	t.Run("GetMerchantsWithFallback", func(t *testing.T) {
+		want, err := svc.GetMerchants()
+		if err != nil {
+			t.Fatalf("获取商户失败: %v", err)
+		}
+		// 数据库可用时返回最新数据，cachedAt 为零值
+		merchants, cachedAt, err := svc.GetMerchantsWithFallback()
+		if err != nil {
+			t.Fatalf("获取商户失败: %v", err)
+		}
+		if len(merchants) != len(want) || !cachedAt.IsZero() {
+			t.Errorf("商户数 = %d 缓存时间 %v, 期望 %d 条最新数据", len(merchants), cachedAt, len(want))
+		}
+	})
+
+	t.Run("GetOrder/DST", func(t *testing.T) {
+		orders, err := svc.GetOrders(models.OrderFilter{Timezone: dstBerlin.Timezone, Limit: 10000})
+		if err != nil {
+			t.Fatalf("获取订单失败: %v", err)
+		}
+		for _, c := range DSTCases {
+			if c.Merchant != dstBerlin {
+				continue
+			}
+			listed, ok := findOrder(orders, c.OrderNo)
+			if !ok {
+				t.Fatalf("%s: 未找到订单 %s", c.Name, c.OrderNo)
+			}
+			order, err := svc.GetOrder(listed.OrderID, "")
+			if err != nil {
+				t.Fatalf("%s: 获取订单失败: %v", c.Name, err)
+			}
+			if order.LocalDate != c.WantLocalDate || order.LocalHour != c.WantLocalHour || order.TimezoneOffset != c.WantOffset {
+				t.Errorf("%s: 得到 %s %02d时 偏移%d, 期望 %s %02d时 偏移%d", c.Name,
+					order.LocalDate, order.LocalHour, order.TimezoneOffset,
+					c.WantLocalDate, c.WantLocalHour, c.WantOffset)
+			}
+		}
+		if _, err := svc.GetOrder(999999999, ""); !errors.Is(err, services.ErrNotFound) {
+			t.Errorf("不存在的订单应返回 ErrNotFound, 得到 %v", err)
+		}
+	})
+
+	t.Run("EstimateOrderCount", func(t *testing.T) {
+		filter := models.OrderFilter{Timezone: dstBerlin.Timezone}
+		estimate, err := svc.EstimateOrderCount(context.Background(), filter)
+		if err != nil {
+			t.Fatalf("估算订单数失败: %v", err)
+		}
+		// 估算值来自查询计划，只要求非零
+		if estimate <= 0 {
+			t.Errorf("柏林订单估算数 = %d, 期望大于 0", estimate)
+		}
+	})
+
+	t.Run("GetAnalysisBatch/DST", func(t *testing.T) {
+		dates := []string{"2024-03-31", "2024-10-27"}
+		batch, err := svc.GetAnalysisBatch(context.Background(), dates, "", "", nil)
+		if err != nil {
+			t.Fatalf("批量获取分析数据失败: %v", err)
+		}
+		if len(batch) != len(dates) {
+			t.Fatalf("批量结果数量 = %d, 期望 %d", len(batch), len(dates))
+		}
+		// 每个日期的内容应与单独查询一致
+		for i, date := range dates {
+			single, err := svc.GetAnalysisData(context.Background(), date, "", nil)
+			if err != nil {
+				t.Fatalf("获取分析数据失败: %v", err)
+			}
+			got := batch[i]
+			got.QueryMode, single.QueryMode = "", ""
+			want, _ := json.Marshal(single)
+			if data, _ := json.Marshal(got); string(data) != string(want) {
+				t.Errorf("%s 批量结果与单独查询不一致:\n%s\n%s", date, data, want)
+			}
+		}
+		if _, err := svc.GetAnalysisBatch(context.Background(), nil, "", "", nil); !errors.Is(err, services.ErrInvalidArgument) {
+			t.Errorf("没有日期应返回 ErrInvalidArgument, 得到 %v", err)
+		}
+	})
+
+	t.Run("GetAnalysisDataIn/DST", func(t *testing.T) {
+		// 按柏林时间划分：2024-03-31 没有 02 点，00:30Z 和 01:30Z 分别落在 01 点和 03 点
+		analysis, err := svc.GetAnalysisDataIn(context.Background(), "2024-03-31", dstBerlin.Timezone, "", nil)
+		if err != nil {
+			t.Fatalf("按柏林时间获取分析数据失败: %v", err)
+		}
+		hours := hourCounts(analysis)
+		if hours[1] < 1 || hours[3] < 1 || hours[2] != 0 {
+			t.Errorf("柏林时间夏令时开始日小时分解异常: %v", hours)
+		}
+		if analysis.AggregateTimezone != dstBerlin.Timezone || analysis.WindowStartUTC == nil || analysis.WindowEndUTC == nil {
+			t.Fatalf("缺少汇总时区或 UTC 窗口: %+v", analysis)
+		}
+		// 当天只有 23 小时
+		start, end := *analysis.WindowStartUTC, *analysis.WindowEndUTC
+		if !start.Equal(time.Date(2024, 3, 30, 23, 0, 0, 0, time.UTC)) || end.Sub(start) != 23*time.Hour {
+			t.Errorf("UTC 窗口 = %v ~ %v, 期望 2024-03-30 23:00Z 起 23 小时", start, end)
+		}
+		if _, err := svc.GetAnalysisDataIn(context.Background(), "2024-03-31", "Mars/Olympus", "", nil); err == nil {
+			t.Errorf("非法汇总时区应返回错误")
+		}
+	})
+
+	t.Run("GetPeakHours/DST", func(t *testing.T) {
+		report, err := svc.GetPeakHours(context.Background(), berlinID, services.PeakHourOptions{Weeks: 1, To: "2024-03-31"})
+		if err != nil {
+			t.Fatalf("高峰时段分析失败: %v", err)
+		}
+		if report.From != "2024-03-25" || report.To != "2024-03-31" || report.Days != 7 || len(report.Hours) != 24 {
+			t.Fatalf("统计范围 = %s~%s %d 天 %d 小时, 期望 2024-03-25~2024-03-31 7 天 24 小时", report.From, report.To, report.Days, len(report.Hours))
+		}
+		// 夏令时开始日的两笔订单分别计入本地 01 点和 03 点
+		if report.TotalOrders != 2 || report.Hours[1].TotalOrders != 1 || report.Hours[2].TotalOrders != 0 || report.Hours[3].TotalOrders != 1 {
+			t.Errorf("小时订单数异常: 共 %d, 01点 %d 02点 %d 03点 %d", report.TotalOrders,
+				report.Hours[1].TotalOrders, report.Hours[2].TotalOrders, report.Hours[3].TotalOrders)
+		}
+		if _, err := svc.GetPeakHours(context.Background(), berlinID, services.PeakHourOptions{Weeks: services.MaxPeakHourWeeks + 1}); !errors.Is(err, services.ErrInvalidArgument) {
+			t.Errorf("超出范围的周数应返回 ErrInvalidArgument, 得到 %v", err)
+		}
+		if _, err := svc.GetPeakHours(context.Background(), 999999, services.PeakHourOptions{}); !errors.Is(err, services.ErrNotFound) {
+			t.Errorf("不存在的商户应返回 ErrNotFound, 得到 %v", err)
+		}
+	})
+
+	t.Run("GetFulfillment/DST", func(t *testing.T) {
+		report, err := svc.GetFulfillment(context.Background(), berlinID, services.FulfillmentOptions{Weeks: 1, To: "2024-03-31"})
+		if err != nil {
+			t.Fatalf("履约时长分析失败: %v", err)
+		}
+		// 夏令时用例订单直接以已支付状态写入，没有发货时间，不参与统计
+		if report.From != "2024-03-25" || report.To != "2024-03-31" || report.Overall.Orders != 0 || len(report.Hours) != 24 || len(report.Weekdays) != 7 {
+			t.Errorf("履约时长报告异常: %s~%s 订单 %d 小时 %d 星期 %d", report.From, report.To,
+				report.Overall.Orders, len(report.Hours), len(report.Weekdays))
+		}
+		if _, err := svc.GetFulfillment(context.Background(), berlinID, services.FulfillmentOptions{Weeks: services.MaxFulfillmentWeeks + 1}); !errors.Is(err, services.ErrInvalidArgument) {
+			t.Errorf("超出范围的周数应返回 ErrInvalidArgument, 得到 %v", err)
+		}
+	})
+
+	t.Run("GetCohorts", func(t *testing.T) {
+		report, err := svc.GetCohorts(context.Background(), services.CohortQuery{Weeks: 2})
+		if err != nil {
+			t.Fatalf("群组留存分析失败: %v", err)
+		}
+		if report.Weeks != 2 || report.WeekStartDay != 1 || report.From > report.To {
+			t.Errorf("群组留存参数异常: %+v", report)
+		}
+		merchants, err := svc.GetMerchants()
+		if err != nil {
+			t.Fatalf("获取商户失败: %v", err)
+		}
+		total := 0
+		for _, c := range report.Cohorts {
+			if c.Merchants != len(c.MerchantIDs) {
+				t.Errorf("群组 %s 商户数 %d 与商户ID %v 不一致", c.WeekStart, c.Merchants, c.MerchantIDs)
+			}
+			total += c.Merchants
+		}
+		if total > len(merchants) {
+			t.Errorf("群组商户合计 %d 超过商户总数 %d", total, len(merchants))
+		}
+		if _, err := svc.GetCohorts(context.Background(), services.CohortQuery{WeekStart: 8}); !errors.Is(err, services.ErrInvalidArgument) {
+			t.Errorf("非法周起始日应返回 ErrInvalidArgument, 得到 %v", err)
+		}
+	})
+
+	t.Run("GlobalBusinessDay/DST", func(t *testing.T) {
+		report, err := svc.GlobalBusinessDay(context.Background(), "2024-03-31", nil)
+		if err != nil {
+			t.Fatalf("全球营业日分析失败: %v", err)
+		}
+		// 柏林夏令时开始日的两笔订单都在当地 2024-03-31
+		if report.Date != "2024-03-31" || report.OrderCount < 2 || report.Inside+report.Outside != report.OrderCount {
+			t.Errorf("全球营业日报告异常: 日期 %s 订单 %d 营业内 %d 营业外 %d", report.Date, report.OrderCount, report.Inside, report.Outside)
+		}
+		if _, err := svc.GlobalBusinessDay(context.Background(), "2024/03/31", nil); !errors.Is(err, services.ErrInvalidArgument) {
+			t.Errorf("非法日期应返回 ErrInvalidArgument, 得到 %v", err)
+		}
+	})
+
+	t.Run("BusinessHoursCoverage", func(t *testing.T) {
+		coverage, err := svc.BusinessHoursCoverage(services.CoverageOptions{Date: "2024-08-19"})
+		if err != nil {
+			t.Fatalf("营业时间覆盖分析失败: %v", err)
+		}
+		if coverage.Date != "2024-08-19" || !coverage.RangeStartUTC.Equal(time.Date(2024, 8, 19, 0, 0, 0, 0, time.UTC)) || len(coverage.Hours) != 24 {
+			t.Errorf("覆盖范围 = %s %v %d 小时", coverage.Date, coverage.RangeStartUTC, len(coverage.Hours))
+		}
+		if coverage.MerchantCount == 0 || coverage.PeakOpenMerchants == 0 {
+			t.Errorf("营业商户数异常: %d 个商户, 峰值 %d", coverage.MerchantCount, coverage.PeakOpenMerchants)
+		}
+		if _, err := svc.BusinessHoursCoverage(services.CoverageOptions{Date: "bad"}); !errors.Is(err, services.ErrInvalidArgument) {
+			t.Errorf("非法日期应返回 ErrInvalidArgument, 得到 %v", err)
+		}
+	})
+
+	t.Run("PreviewSchedule/DST", func(t *testing.T) {
+		// 柏林商户每天 02:30：2024-03-31 不存在 02:30，顺延到 03:30 CEST
+		preview, err := svc.PreviewSchedule(services.SchedulePreviewRequest{
+			Expression: "CRON_TZ=@merchant_local 30 2 * * *",
+			MerchantID: berlinID,
+			After:      time.Date(2024, 3, 30, 12, 0, 0, 0, time.UTC),
+			Count:      2,
+		})
+		if err != nil {
+			t.Fatalf("预览定时规则失败: %v", err)
+		}
+		if preview.Timezone != dstBerlin.Timezone || len(preview.Occurrences) != 2 {
+			t.Fatalf("预览结果 = %s %d 次, 期望 %s 2 次", preview.Timezone, len(preview.Occurrences), dstBerlin.Timezone)
+		}
+		first, second := preview.Occurrences[0], preview.Occurrences[1]
+		if !first.UTC.Equal(time.Date(2024, 3, 31, 1, 30, 0, 0, time.UTC)) || !first.Shifted {
+			t.Errorf("夏令时开始日 = %v shifted %v, 期望 2024-03-31 01:30Z 顺延", first.UTC, first.Shifted)
+		}
+		if !second.UTC.Equal(time.Date(2024, 4, 1, 0, 30, 0, 0, time.UTC)) || second.Offset != "+02:00" {
+			t.Errorf("次日 = %v %s, 期望 2024-04-01 00:30Z +02:00", second.UTC, second.Offset)
+		}
+		if _, err := svc.PreviewSchedule(services.SchedulePreviewRequest{}); !errors.Is(err, services.ErrInvalidArgument) {
+			t.Errorf("缺少定时规则应返回 ErrInvalidArgument, 得到 %v", err)
+		}
+	})
+
+	t.Run("MerchantNow/DST", func(t *testing.T) {
+		// 柏林 2024-03-31 01:30 冬令时，半小时后切换为夏令时
+		before, err := svc.MerchantNow(berlinID, time.Date(2024, 3, 31, 0, 30, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatalf("获取商户当前时间失败: %v", err)
+		}
+		change := time.Date(2024, 3, 31, 1, 0, 0, 0, time.UTC)
+		if before.Local != "2024-03-31 01:30:00" || before.Offset != "+01:00" || before.IsDST {
+			t.Errorf("切换前 = %s %s dst=%v, 期望 2024-03-31 01:30:00 +01:00", before.Local, before.Offset, before.IsDST)
+		}
+		if before.NextOffsetChange == nil || !before.NextOffsetChange.UTC.Equal(change) || before.ValidUntil.After(change) {
+			t.Errorf("下一次偏移切换 = %+v, 有效期至 %v, 期望 %v", before.NextOffsetChange, before.ValidUntil, change)
+		}
+
+		after, err := svc.MerchantNow(berlinID, time.Date(2024, 3, 31, 1, 30, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatalf("获取商户当前时间失败: %v", err)
+		}
+		if after.Local != "2024-03-31 03:30:00" || after.Offset != "+02:00" || !after.IsDST {
+			t.Errorf("切换后 = %s %s dst=%v, 期望 2024-03-31 03:30:00 +02:00", after.Local, after.Offset, after.IsDST)
+		}
+		if _, err := svc.MerchantNow(999999, time.Now()); !errors.Is(err, services.ErrNotFound) {
+			t.Errorf("不存在的商户应返回 ErrNotFound, 得到 %v", err)
+		}
+	})
+
+	t.Run("InvalidateMerchantSnapshot", func(t *testing.T) {
+		at := time.Date(2024, 3, 31, 1, 30, 0, 0, time.UTC)
+		cached, err := svc.MerchantNow(berlinID, at)
+		if err != nil {
+			t.Fatalf("获取商户当前时间失败: %v", err)
+		}
+		// 清空快照后重新读取商户，结果不变
+		svc.InvalidateMerchantSnapshot()
+		reloaded, err := svc.MerchantNow(berlinID, at)
+		if err != nil {
+			t.Fatalf("清空快照后获取商户当前时间失败: %v", err)
+		}
+		want, _ := json.Marshal(cached)
+		if got, _ := json.Marshal(reloaded); string(got) != string(want) {
+			t.Errorf("清空快照前后结果不一致:\n%s\n%s", want, got)
+		}
+	})
+
+	t.Run("SetTimezoneLookup", func(t *testing.T) {
+		// 只有上海一个城市的数据集：附近坐标取上海，远离上海时取航海时区
+		var shanghai []geo.City
+		for _, c := range geo.Cities() {
+			if c.Timezone == "Asia/Shanghai" {
+				shanghai = append(shanghai, c)
+			}
+		}
+		svc.SetTimezoneLookup(geo.NewNearestCityProvider(shanghai, 500))
+		defer svc.SetTimezoneLookup(geo.DefaultProvider)
+		at := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+		lookup, err := svc.LookupTimezone(52.5, 13.4, at)
+		if err != nil {
+			t.Fatalf("查询坐标时区失败: %v", err)
+		}
+		if lookup.Timezone != "Etc/GMT-1" {
+			t.Errorf("柏林坐标 = %s, 期望航海时区 Etc/GMT-1", lookup.Timezone)
+		}
+	})
+}
+
+// dstMerchantID 按名称查找夏令时用例商户的ID
+func dstMerchantID(t *testing.T, svc *services.TimezoneService, m DSTMerchant) int {
+	t.Helper()
+	merchants, err := svc.GetMerchants()
+	if err != nil {
+		t.Fatalf("获取商户失败: %v", err)
+	}
+	for _, merchant := range merchants {
+		if merchant.Name == m.Name {
+			return merchant.ID
+		}
+	}
+	t.Fatalf("未找到夏令时用例商户 %s", m.Name)
+	return 0
+}
+
+// RunHTTPSuite 通过 HTTP 层验证所有时区接口，handler 一般为 main 包中的路由
+func RunHTTPSuite(t *testing.T, handler http.Handler) {
+	paths := []string{
+		"/api/health",
+		"/api/docs",
+		"/api/timezone/demo",
+		"/api/timezone/merchants",
+		"/api/timezone/orders?timezone=Europe/Berlin&limit=50",
+		"/api/timezone/analysis?date=2024-03-31",
+		"/api/timezone/compare?utc_time=2024-03-31T01:30:00Z",
+	}
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("状态码 = %d, 响应: %s", rec.Code, rec.Body.String())
+			}
+			var resp struct {
+				Success bool `json:"success"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || !resp.Success {
+				t.Fatalf("响应异常: %s", rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("analysis/DST", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/timezone/analysis?date=2024-10-27", nil))
+		var resp struct {
+			Data models.AnalysisData `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("解析响应失败: %v", err)
+		}
+		if hours := hourCounts(&resp.Data); hours[2] < 2 {
+			t.Errorf("夏令时结束日 02 点订单数 = %d, 期望至少 2", hours[2])
+		}
+	})
+
+	t.Run("analysis/InvalidDate", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/timezone/analysis?date=bad", nil))
+		if rec.Code < 400 {
+			t.Errorf("非法日期状态码 = %d, 期望错误状态码", rec.Code)
+		}
+	})
+
+	t.Run("orders/Locale", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/timezone/orders?timezone=Europe/Berlin&limit=50", nil)
+		req.Header.Set("Accept-Language", "de-DE,de;q=0.9,en;q=0.5")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if got := rec.Header().Get("Content-Language"); got != "de" {
+			t.Errorf("Content-Language = %q, 期望 de", got)
+		}
+		var resp struct {
+			Data []models.OrderAnalysis `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || len(resp.Data) == 0 {
+			t.Fatalf("解析响应失败: %v %s", err, rec.Body.String())
+		}
+		for _, o := range resp.Data {
+			want := locale.For("de").Weekday(time.Weekday(o.LocalDayOfWeek))
+			if o.LocalWeekdayName != want || o.AmountDisplay == "" {
+				t.Errorf("订单 %s 本地化字段 = %q %q, 期望星期 %q", o.OrderNumber, o.LocalWeekdayName, o.AmountDisplay, want)
+			}
+		}
+	})
+
+	t.Run("orders/Pagination", func(t *testing.T) {
+		// 按 meta.links.next 翻页直到最后一页，各页条数之和应等于最后一页给出的精确总数
+		path, pages, seen := "/api/timezone/orders?timezone=Europe/Berlin&limit=2", 0, 0
+		var last models.PageMeta
+		for path != "" {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			var resp struct {
+				Data []models.OrderAnalysis `json:"data"`
+				Meta *models.PageMeta       `json:"meta"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Meta == nil {
+				t.Fatalf("%s: 缺少 meta: %v %s", path, err, rec.Body.String())
+			}
+			meta := resp.Meta
+			if meta.Count != len(resp.Data) || meta.Page != pages+1 || meta.Offset != seen {
+				t.Fatalf("%s: meta 异常 %+v, 本页 %d 条", path, meta, len(resp.Data))
+			}
+			link := rec.Header().Get("Link")
+			if meta.HasMore != strings.Contains(link, `rel="next"`) || (pages > 0) != strings.Contains(link, `rel="prev"`) {
+				t.Errorf("%s: Link 响应头 %q 与 meta %+v 不一致", path, link, meta)
+			}
+			if meta.HasMore && meta.TotalCount <= int64(seen+meta.Count) {
+				t.Errorf("%s: 还有下一页时 total_count = %d 不应小于已翻过的 %d", path, meta.TotalCount, seen+meta.Count)
+			}
+			pages++
+			seen += meta.Count
+			last = *meta
+			path = meta.Links.Next
+			if pages > 1000 {
+				t.Fatal("翻页未结束")
+			}
+		}
+		if pages < 2 || !last.TotalCountExact || last.TotalCount != int64(seen) || last.PageCount != pages {
+			t.Errorf("共 %d 页 %d 条, 最后一页 meta %+v", pages, seen, last)
+		}
+	})
+
+	t.Run("Messages", func(t *testing.T) {
+		cases := []struct {
+			path, lang, code, message string
+		}{
+			{"/api/timezone/compare?timezones=Asia/Tokyo", "en", "compare.ok", "World timezone comparison at "},
+			{"/api/timezone/compare?timezones=Mars/Base", "en-GB", "compare.failed", "Timezone comparison failed"},
+			{"/api/timezone/compare?timezones=Mars/Base", "", "compare.failed", "时区对比分析失败"},
+			{"/api/timezone/compare?timezones=Asia/Tokyo", "ja", "compare.ok", "World timezone comparison at "},
+		}
+		for _, c := range cases {
+			req := httptest.NewRequest(http.MethodGet, c.path, nil)
+			if c.lang != "" {
+				req.Header.Set("Accept-Language", c.lang)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			var resp struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("解析响应失败: %v %s", err, rec.Body.String())
+			}
+			if resp.Code != c.code || !strings.HasPrefix(resp.Message, c.message) {
+				t.Errorf("%s [%s] code/message = %q %q, 期望 %q %q...", c.path, c.lang, resp.Code, resp.Message, c.code, c.message)
+			}
+		}
+	})
+
+	t.Run("DST", func(t *testing.T) {
+		var merchants []models.Merchant
+		getData(t, handler, "/api/timezone/merchants", &merchants)
+		berlinID := 0
+		for _, m := range merchants {
+			if m.Name == dstBerlin.Name {
+				berlinID = m.ID
+			}
+		}
+		if berlinID == 0 {
+			t.Fatalf("商户列表缺少夏令时用例商户 %s", dstBerlin.Name)
+		}
+		merchantPath := fmt.Sprintf("/api/timezone/merchants/%d", berlinID)
+
+		var merchant models.Merchant
+		getData(t, handler, merchantPath, &merchant)
+		if merchant.Timezone != dstBerlin.Timezone {
+			t.Errorf("商户时区 = %s, 期望 %s", merchant.Timezone, dstBerlin.Timezone)
+		}
+
+		// 柏林 2024-03-31 01:30 冬令时下单，对应本地 01 点；01:30Z 的订单为夏令时 03:30
+		var orders []models.OrderAnalysis
+		getData(t, handler, "/api/timezone/orders?timezone=Europe/Berlin&limit=1000", &orders)
+		for _, c := range DSTCases {
+			if c.Merchant != dstBerlin {
+				continue
+			}
+			listed, ok := findOrder(orders, c.OrderNo)
+			if !ok {
+				t.Fatalf("%s: 未找到订单 %s", c.Name, c.OrderNo)
+			}
+			var order models.OrderAnalysis
+			getData(t, handler, fmt.Sprintf("/api/timezone/orders/%d", listed.OrderID), &order)
+			if order.LocalDate != c.WantLocalDate || order.LocalHour != c.WantLocalHour || order.TimezoneOffset != c.WantOffset {
+				t.Errorf("%s: 得到 %s %02d时 偏移%d, 期望 %s %02d时 偏移%d", c.Name,
+					order.LocalDate, order.LocalHour, order.TimezoneOffset,
+					c.WantLocalDate, c.WantLocalHour, c.WantOffset)
+			}
+		}
+
+		var now models.MerchantNow
+		getData(t, handler, merchantPath+"/now", &now)
+		if now.MerchantID != berlinID || now.Timezone != dstBerlin.Timezone || (now.Offset != "+01:00" && now.Offset != "+02:00") {
+			t.Errorf("商户当前时间异常: %+v", now)
+		}
+
+		var boundaries models.MerchantBoundaries
+		getData(t, handler, merchantPath+"/boundaries?at=2024-03-30T12:00:00Z", &boundaries)
+		if !boundaries.NextMidnight.UTC.Equal(time.Date(2024, 3, 30, 23, 0, 0, 0, time.UTC)) ||
+			!boundaries.NextWeekStart.UTC.Equal(time.Date(2024, 3, 31, 22, 0, 0, 0, time.UTC)) {
+			t.Errorf("时间边界 = 午夜 %v 下周 %v", boundaries.NextMidnight.UTC, boundaries.NextWeekStart.UTC)
+		}
+
+		var peak models.PeakHoursReport
+		getData(t, handler, merchantPath+"/peak-hours?to=2024-03-31&weeks=1", &peak)
+		if peak.TotalOrders != 2 || len(peak.Hours) != 24 || peak.Hours[1].TotalOrders != 1 || peak.Hours[2].TotalOrders != 0 || peak.Hours[3].TotalOrders != 1 {
+			t.Errorf("高峰时段小时订单数异常: %+v", peak.Hours)
+		}
+
+		var fulfillment models.FulfillmentReport
+		getData(t, handler, merchantPath+"/fulfillment?to=2024-03-31&weeks=1", &fulfillment)
+		if fulfillment.From != "2024-03-25" || fulfillment.To != "2024-03-31" {
+			t.Errorf("履约时长统计范围 = %s~%s, 期望 2024-03-25~2024-03-31", fulfillment.From, fulfillment.To)
+		}
+
+		var batch []models.AnalysisData
+		getData(t, handler, "/api/timezone/analysis/batch?dates=2024-03-31,2024-10-27", &batch)
+		if len(batch) != 2 {
+			t.Fatalf("批量分析结果数量 = %d, 期望 2", len(batch))
+		}
+		if hours := hourCounts(&batch[0]); hours[1] < 1 || hours[3] < 1 {
+			t.Errorf("夏令时开始日小时分解异常: %v", hours)
+		}
+		if hours := hourCounts(&batch[1]); hours[2] < 2 {
+			t.Errorf("夏令时结束日 02 点订单数 = %d, 期望至少 2", hours[2])
+		}
+
+		var businessDay models.GlobalBusinessDayReport
+		getData(t, handler, "/api/timezone/analysis/business-day?date=2024-03-31", &businessDay)
+		if businessDay.Date != "2024-03-31" || businessDay.OrderCount < 2 {
+			t.Errorf("全球营业日 = %s %d 笔, 期望 2024-03-31 至少 2 笔", businessDay.Date, businessDay.OrderCount)
+		}
+
+		var cohorts models.CohortReport
+		getData(t, handler, "/api/timezone/analysis/cohorts?weeks=2", &cohorts)
+		if cohorts.Weeks != 2 {
+			t.Errorf("群组留存周数 = %d, 期望 2", cohorts.Weeks)
+		}
+
+		var preview models.SchedulePreview
+		getData(t, handler, fmt.Sprintf("/api/timezone/schedule/validate?expr=%s&merchant_id=%d&after=2024-03-30T12:00:00Z&count=1",
+			url.QueryEscape("CRON_TZ=@merchant_local 30 2 * * *"), berlinID), &preview)
+		if len(preview.Occurrences) != 1 || !preview.Occurrences[0].UTC.Equal(time.Date(2024, 3, 31, 1, 30, 0, 0, time.UTC)) {
+			t.Errorf("定时规则预览 = %+v, 期望 2024-03-31 01:30Z", preview.Occurrences)
+		}
+
+		var expansion models.ScheduleExpansion
+		getData(t, handler, "/api/timezone/schedule/expand?rule="+url.QueryEscape("FREQ=DAILY;BYHOUR=2;BYMINUTE=30")+
+			"&timezone=Europe/Berlin&from=2024-10-27&to=2024-10-27&overlap=both", &expansion)
+		if expansion.Count != 2 {
+			t.Errorf("夏令时结束日 02:30 展开 %d 次, 期望 2", expansion.Count)
+		}
+
+		var overlap models.MeetingOverlap
+		getData(t, handler, fmt.Sprintf("/api/timezone/overlap?merchants=%d&date=2024-03-31&tz=UTC", berlinID), &overlap)
+
+		var lookup models.TimezoneLookup
+		getData(t, handler, "/api/timezone/lookup?lat=52.5&lon=13.4&at=2024-03-31T01:30:00Z", &lookup)
+		if lookup.Timezone != dstBerlin.Timezone || lookup.OffsetSeconds != 7200 {
+			t.Errorf("柏林坐标 = %s %d, 期望 %s 7200", lookup.Timezone, lookup.OffsetSeconds, dstBerlin.Timezone)
+		}
+
+		var rules models.TimezoneRules
+		getData(t, handler, "/api/timezone/rules?timezone=Europe/Berlin&from=2024-01-01&to=2025-01-01", &rules)
+		if rules.Count != 2 || rules.Transitions[0].LocalAfter != "2024-03-31 03:00:00" {
+			t.Errorf("柏林 2024 年偏移切换 = %+v", rules.Transitions)
+		}
+
+		body := `[{"timestamp":"2024-03-31T02:30:00","from_tz":"Europe/Berlin","to_tz":"UTC"}]`
+		req := httptest.NewRequest(http.MethodPost, "/api/timezone/convert", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var converted struct {
+			Data models.BatchConversion `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &converted); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("批量转换失败: %d %s", rec.Code, rec.Body.String())
+		}
+		if len(converted.Data.Results) != 1 || converted.Data.Results[0].UTC != "2024-03-31T01:30:00Z" || !converted.Data.Results[0].Shifted {
+			t.Errorf("柏林 02:30 转换结果 = %+v, 期望顺延为 01:30Z", converted.Data.Results)
+		}
+	})
+}
+
+// getData 发送 GET 请求，要求返回 200 并将响应的 data 字段解析到 v
+func getData(t *testing.T, handler http.Handler, path string, v interface{}) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s: 状态码 = %d, 响应: %s", path, rec.Code, rec.Body.String())
+	}
+	resp := struct {
+		Data interface{} `json:"data"`
+	}{Data: v}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s: 解析响应失败: %v", path, err)
+	}
+}
+
+// findOrder 按订单号查找订单
+func findOrder(orders []models.OrderAnalysis, orderNo string) (models.OrderAnalysis, bool) {
+	for _, order := range orders {
+		if order.OrderNumber == orderNo {
+			return order, true
+		}
+	}
+	return models.OrderAnalysis{}, false
+}
+
+// hourCounts 将小时分解转换为 小时->订单数
+func hourCounts(analysis *models.AnalysisData) map[int]int {
+	counts := map[int]int{}
+	for _, b := range analysis.HourlyBreakdown {
+		counts[b.Hour] = b.OrderCount
+	}
+	return counts
+}
+
+// RunBillingSuite 验证计费周期计算，merchantID 为数据源中任意一个已存在的商户
+func RunBillingSuite(t *testing.T, svc *services.BillingService, merchantID int) {
+	t.Run("ComputeBillingPeriods/MonthEnd", func(t *testing.T) {
+		sub := models.Subscription{MerchantID: merchantID, IntervalUnit: "month", IntervalCount: 1, AnchorDate: "2024-01-31"}
+		periods, err := services.ComputeBillingPeriods(sub, "Asia/Shanghai", time.Date(2024, 4, 15, 0, 0, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatalf("计算计费周期失败: %v", err)
+		}
+		want := []string{"2024-01-31 00:00:00", "2024-02-29 00:00:00", "2024-03-31 00:00:00", "2024-04-30 00:00:00"}
+		if len(periods) != len(want)-1 {
+			t.Fatalf("周期数 = %d, 期望 %d", len(periods), len(want)-1)
+		}
+		for i, p := range periods {
+			if p.StartLocal != want[i] || p.EndLocal != want[i+1] {
+				t.Errorf("第 %d 期 = %s ~ %s, 期望 %s ~ %s", p.Sequence, p.StartLocal, p.EndLocal, want[i], want[i+1])
+			}
+		}
+	})
+
+	t.Run("ComputeBillingPeriods/DST", func(t *testing.T) {
+		// 柏林 2024-03 月含夏令时开始，周期比 31*24 小时少 1 小时
+		sub := models.Subscription{MerchantID: merchantID, IntervalUnit: "month", IntervalCount: 1, AnchorDate: "2024-03-01"}
+		periods, err := services.ComputeBillingPeriods(sub, "Europe/Berlin", time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatalf("计算计费周期失败: %v", err)
+		}
+		if len(periods) != 1 || periods[0].DurationHours != 31*24-1 {
+			t.Fatalf("夏令时月份周期异常: %+v", periods)
+		}
+		if !periods[0].StartUTC.Equal(time.Date(2024, 2, 29, 23, 0, 0, 0, time.UTC)) {
+			t.Errorf("周期开始 = %v, 期望柏林本地零点", periods[0].StartUTC)
+		}
+	})
+
+	t.Run("GetPeriods/Idempotent", func(t *testing.T) {
+		first, err := svc.GetPeriods(merchantID, "2024-12-31")
+		if err != nil {
+			t.Fatalf("获取计费周期失败: %v", err)
+		}
+		second, err := svc.GetPeriods(merchantID, "2024-12-31")
+		if err != nil {
+			t.Fatalf("获取计费周期失败: %v", err)
+		}
+		if len(first.Periods) == 0 || len(first.Periods) != len(second.Periods) {
+			t.Fatalf("重复计算周期数不一致: %d, %d", len(first.Periods), len(second.Periods))
+		}
+		if first.Periods[0].PeriodID != second.Periods[0].PeriodID {
+			t.Errorf("重复计算不应生成新的周期记录")
+		}
+	})
+
+	t.Run("GetPeriods/NotFound", func(t *testing.T) {
+		if _, err := svc.GetPeriods(999999, ""); !errors.Is(err, services.ErrNotFound) {
+			t.Errorf("不存在的商户应返回 ErrNotFound, 得到 %v", err)
+		}
+	})
+}
+
+// RunRevenueCloseSuite 验证日结：fakes 需已写入夏令时用例（AddDSTFixtures）
+func RunRevenueCloseSuite(t *testing.T, svc *services.RevenueCloseService) {
+	// 2024-11-01 时所有夏令时用例日期都已结束
+	at := time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("CloseDue", func(t *testing.T) {
+		n, err := svc.CloseDue(at)
+		if err != nil {
+			t.Fatalf("日结失败: %v", err)
+		}
+		if n == 0 {
+			t.Fatalf("应至少写入一个快照")
+		}
+		again, err := svc.CloseDue(at)
+		if err != nil {
+			t.Fatalf("重复日结失败: %v", err)
+		}
+		if again != 0 {
+			t.Errorf("重复日结写入了 %d 个快照，期望 0", again)
+		}
+	})
+
+	t.Run("GetClosedAnalysis/DSTEnd", func(t *testing.T) {
+		closed, err := svc.GetClosedAnalysis("2024-10-27")
+		if err != nil {
+			t.Fatalf("获取日结数据失败: %v", err)
+		}
+		for _, s := range closed.Snapshots {
+			if s.Timezone != "Europe/Berlin" {
+				continue
+			}
+			// 柏林夏令时结束日有 25 小时，两笔 02:30 订单都计入当天
+			if got := s.WindowEndUTC.Sub(s.WindowStartUTC); got != 25*time.Hour {
+				t.Errorf("柏林夏令时结束日窗口 = %v, 期望 25h", got)
+			}
+			if s.OrderCount < 2 {
+				t.Errorf("柏林夏令时结束日订单数 = %d, 期望至少 2", s.OrderCount)
+			}
+			return
+		}
+		t.Errorf("缺少柏林 2024-10-27 的日结快照: %+v", closed.Snapshots)
+	})
+
+	t.Run("GetClosedAnalysis/InvalidDate", func(t *testing.T) {
+		if _, err := svc.GetClosedAnalysis("2024/10/27"); !errors.Is(err, services.ErrInvalidArgument) {
+			t.Errorf("非法日期应返回 ErrInvalidArgument, 得到 %v", err)
+		}
+	})
+}
+
+// RunReconciliationSuite 验证迟到订单对账和调整
+// addLateOrder 为某个已有订单的商户写入一笔 orderTimeUTC 下单、ingestedAt 才入库的订单
+func RunReconciliationSuite(t *testing.T, svc *services.RevenueCloseService, addLateOrder func(orderTimeUTC, ingestedAt time.Time) error) {
+	at := time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := svc.CloseDue(at); err != nil {
+		t.Fatalf("日结失败: %v", err)
+	}
+
+	orderTime := time.Date(2024, 10, 15, 12, 0, 0, 0, time.UTC)
+	if err := addLateOrder(orderTime, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("写入迟到订单失败: %v", err)
+	}
+
+	report, err := svc.GetLateOrders("", 0)
+	if err != nil {
+		t.Fatalf("获取迟到订单失败: %v", err)
+	}
+	var late *models.LateOrder
+	for i := range report.LateOrders {
+		if report.LateOrders[i].OrderTimeUTC.Equal(orderTime) {
+			late = &report.LateOrders[i]
+		}
+	}
+	if late == nil {
+		t.Fatalf("迟到订单未出现在对账结果中: %+v", report.LateOrders)
+	}
+	if late.AdjustmentID != nil || report.Unadjusted == 0 {
+		t.Errorf("新写入的迟到订单不应已调整: %+v", late)
+	}
+
+	before, err := svc.GetClosedAnalysis(late.LocalDate)
+	if err != nil {
+		t.Fatalf("获取日结数据失败: %v", err)
+	}
+
+	t.Run("AdjustLateOrders", func(t *testing.T) {
+		result, err := svc.AdjustLateOrders("", late.MerchantID, "", "suite")
+		if err != nil {
+			t.Fatalf("调整迟到订单失败: %v", err)
+		}
+		if result.Created == 0 {
+			t.Fatalf("应至少新增一条调整记录")
+		}
+		for _, a := range result.Adjustments {
+			if a.CreatedBy != "suite" || a.OrderID == nil {
+				t.Errorf("调整记录缺少审计信息: %+v", a)
+			}
+		}
+
+		again, err := svc.AdjustLateOrders("", late.MerchantID, "", "suite")
+		if err != nil {
+			t.Fatalf("重复调整失败: %v", err)
+		}
+		if again.Created != 0 {
+			t.Errorf("重复调整新增了 %d 条记录，期望 0", again.Created)
+		}
+
+		after, err := svc.GetClosedAnalysis(late.LocalDate)
+		if err != nil {
+			t.Fatalf("获取日结数据失败: %v", err)
+		}
+		if after.TotalOrders != before.TotalOrders+1 {
+			t.Errorf("调整后订单数 = %d, 期望 %d", after.TotalOrders, before.TotalOrders+1)
+		}
+		for _, s := range after.Snapshots {
+			if s.MerchantID == late.MerchantID && s.OrderCount == s.AdjustedOrderCount {
+				t.Errorf("快照原始数据应保持不变、调整单独累加: %+v", s)
+			}
+		}
+	})
+
+	t.Run("GetLateOrders/InvalidDate", func(t *testing.T) {
+		if _, err := svc.GetLateOrders("2024/10/15", 0); !errors.Is(err, services.ErrInvalidArgument) {
+			t.Errorf("非法日期应返回 ErrInvalidArgument, 得到 %v", err)
+		}
+	})
+}
+
+// RunAnalysisTimeoutSuite 验证分析接口的并发查询超时：可省略的部分超时时返回部分结果，合计超时或调用方取消时返回错误
+// analysis 为 svc 使用的内存分析仓储，通过 Delays 模拟慢查询
+func RunAnalysisTimeoutSuite(t *testing.T, svc *services.TimezoneService, analysis *AnalysisRepository) {
+	const date = "2024-10-27"
+	svc.SetQueryTimeout(20 * time.Millisecond)
+	defer svc.SetQueryTimeout(services.DefaultAnalysisQueryTimeout)
+	defer func() { analysis.Delays = nil }()
+
+	complete, err := svc.GetAnalysisData(context.Background(), date, "", nil)
+	if err != nil {
+		t.Fatalf("获取分析数据失败: %v", err)
+	}
+	if complete.Partial || len(complete.OmittedSections) != 0 {
+		t.Fatalf("没有慢查询时不应返回部分结果: %v", complete.OmittedSections)
+	}
+
+	t.Run("OptionalSectionTimeout", func(t *testing.T) {
+		analysis.Delays = map[string]time.Duration{"TopMerchants": time.Second}
+		start := time.Now()
+		partial, err := svc.GetAnalysisData(context.Background(), date, "", nil)
+		if err != nil {
+			t.Fatalf("商户排行超时不应导致整体失败: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("应在单项超时后返回，实际耗时 %s", elapsed)
+		}
+		if !partial.Partial || len(partial.OmittedSections) != 1 || partial.OmittedSections[0] != "top_merchants" || partial.TopMerchants != nil {
+			t.Errorf("部分结果 = partial %v omitted %v, 期望省略 top_merchants", partial.Partial, partial.OmittedSections)
+		}
+		if partial.TotalOrders != complete.TotalOrders || len(partial.HourlyBreakdown) != len(complete.HourlyBreakdown) {
+			t.Errorf("其他部分应完整返回: %d 笔订单, 期望 %d", partial.TotalOrders, complete.TotalOrders)
+		}
+	})
+
+	t.Run("RequiredSectionTimeout", func(t *testing.T) {
+		analysis.Delays = map[string]time.Duration{"OrderSummary": time.Second}
+		if _, err := svc.GetAnalysisData(context.Background(), date, "", nil); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("订单合计超时应返回 DeadlineExceeded, 得到 %v", err)
+		}
+	})
+
+	t.Run("SingleStatementTimeout", func(t *testing.T) {
+		analysis.Delays = map[string]time.Duration{"Aggregates": time.Second}
+		if err := svc.SetQueryMode(services.AnalysisQueryModeSingle); err != nil {
+			t.Fatalf("切换查询方式失败: %v", err)
+		}
+		defer svc.SetQueryMode(services.AnalysisQueryModeFanout)
+		if _, err := svc.GetAnalysisData(context.Background(), date, "", nil); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("single 方式超时应返回 DeadlineExceeded, 得到 %v", err)
+		}
+	})
+
+	t.Run("CallerCancelled", func(t *testing.T) {
+		analysis.Delays = map[string]time.Duration{"TopMerchants": time.Second}
+		svc.SetQueryTimeout(time.Minute)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if _, err := svc.GetAnalysisData(ctx, date, "", nil); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("调用方超时应返回错误而不是部分结果, 得到 %v", err)
+		}
+	})
+}
+
+// RunTenantLimitSuite 验证分析接口按租户限流：名额占满的租户排队超时后被拒绝，其他租户不受影响
+func RunTenantLimitSuite(t *testing.T, svc *services.TimezoneService, analysis *AnalysisRepository) {
+	const date = "2024-10-27"
+	limiter := services.NewTenantLimiter(1, 20*time.Millisecond)
+	svc.SetTenantLimiter(limiter)
+	defer svc.SetTenantLimiter(nil)
+	analysis.Delays = map[string]time.Duration{"OrderSummary": 300 * time.Millisecond}
+	defer func() { analysis.Delays = nil }()
+
+	busy := services.WithTenant(context.Background(), "busy")
+	done := make(chan error, 1)
+	go func() {
+		_, err := svc.GetAnalysisData(busy, date, "", nil)
+		done <- err
+	}()
+	// 等待第一个请求占用名额
+	deadline := time.Now().Add(time.Second)
+	for !inFlight(limiter, "busy") {
+		if time.Now().After(deadline) {
+			t.Fatal("第一个请求未占用名额")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Run("SameTenantRejected", func(t *testing.T) {
+		_, err := svc.GetAnalysisData(busy, date, "", nil)
+		var overloaded *services.OverloadedError
+		if !errors.As(err, &overloaded) || !errors.Is(err, services.ErrOverloaded) {
+			t.Fatalf("名额已满应返回 OverloadedError, 得到 %v", err)
+		}
+		if overloaded.Tenant != "busy" || overloaded.RetryAfter < time.Second {
+			t.Errorf("OverloadedError = %+v", overloaded)
+		}
+	})
+
+	t.Run("OtherTenantUnaffected", func(t *testing.T) {
+		if _, err := svc.GetAnalysisData(services.WithTenant(context.Background(), "quiet"), date, "", nil); err != nil {
+			t.Errorf("其他租户应不受影响: %v", err)
+		}
+	})
+
+	if err := <-done; err != nil {
+		t.Fatalf("第一个请求失败: %v", err)
+	}
+
+	t.Run("Stats", func(t *testing.T) {
+		for _, s := range svc.TenantQueryStats() {
+			if s.InFlight != 0 || s.Waiting != 0 {
+				t.Errorf("%s 请求结束后名额未归还: %+v", s.Tenant, s)
+			}
+			if s.Tenant == "busy" && (s.Acquired != 1 || s.Rejected != 1) {
+				t.Errorf("busy 统计 = %+v, 期望获得 1 次、拒绝 1 次", s)
+			}
+		}
+	})
+}
+
+// inFlight 租户当前是否有执行中的请求
+func inFlight(limiter *services.TenantLimiter, tenant string) bool {
+	for _, s := range limiter.Stats() {
+		if s.Tenant == tenant && s.InFlight > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// RunRefundSuite 验证部分退款、超额退款和两种退款归属口径
+// addOrder 为某个已有商户写入一笔 orderTimeUTC 下单、金额为 amount 的已支付订单，返回订单ID
+func RunRefundSuite(t *testing.T, svc *services.TimezoneService, refunds *services.RefundService, addOrder func(orderTimeUTC time.Time, amount decimal.Decimal) (int, error)) {
+	orderTime := time.Date(2024, 10, 15, 12, 0, 0, 0, time.UTC)
+	orderID, err := addOrder(orderTime, decimal.RequireFromString("100.00"))
+	if err != nil {
+		t.Fatalf("写入订单失败: %v", err)
+	}
+	order, err := refunds.ListRefunds(orderID)
+	if err != nil {
+		t.Fatalf("获取退款记录失败: %v", err)
+	}
+	loc, err := time.LoadLocation(order.Timezone)
+	if err != nil {
+		t.Fatalf("加载时区失败: %v", err)
+	}
+	// 退款发生在下单 36 小时后，原订单和退款的本地日期一定不同
+	refundTime := orderTime.Add(36 * time.Hour)
+	orderDate := orderTime.In(loc).Format("2006-01-02")
+	refundDate := refundTime.In(loc).Format("2006-01-02")
+
+	// refundTotal 指定本地日期订单币种的合计
+	refundTotal := func(t *testing.T, date string) models.CurrencyTotal {
+		t.Helper()
+		analysis, err := svc.GetAnalysisData(context.Background(), date, "", nil)
+		if err != nil {
+			t.Fatalf("获取 %s 分析数据失败: %v", date, err)
+		}
+		for _, total := range analysis.TotalsByCurrency {
+			if total.Currency == order.Currency {
+				return total
+			}
+		}
+		return models.CurrencyTotal{Currency: order.Currency}
+	}
+	beforeOrderDate, beforeRefundDate := refundTotal(t, orderDate), refundTotal(t, refundDate)
+
+	t.Run("CreateRefund/Partial", func(t *testing.T) {
+		refund, err := refunds.CreateRefund(orderID, services.RefundRequest{
+			Amount:     decimal.RequireFromString("30"),
+			Reason:     "部分退款",
+			RefundedAt: refundTime.Format(time.RFC3339),
+			Operator:   "suite",
+		})
+		if err != nil {
+			t.Fatalf("创建退款失败: %v", err)
+		}
+		if refund.OrderLocalDate != orderDate || refund.RefundLocalDate != refundDate || refund.CreatedBy != "suite" {
+			t.Errorf("退款记录 = %+v, 期望订单日期 %s 退款日期 %s", refund, orderDate, refundDate)
+		}
+	})
+
+	t.Run("CreateRefund/Invalid", func(t *testing.T) {
+		_, err := refunds.CreateRefund(orderID, services.RefundRequest{Amount: decimal.RequireFromString("80")})
+		if !errors.Is(err, services.ErrConflict) {
+			t.Errorf("超额退款应返回 ErrConflict, 得到 %v", err)
+		}
+		_, err = refunds.CreateRefund(orderID, services.RefundRequest{
+			Amount:     decimal.RequireFromString("1"),
+			RefundedAt: orderTime.Add(-time.Hour).Format(time.RFC3339),
+		})
+		if !errors.Is(err, services.ErrInvalidArgument) {
+			t.Errorf("早于下单时间的退款应返回 ErrInvalidArgument, 得到 %v", err)
+		}
+		if _, err := refunds.CreateRefund(orderID, services.RefundRequest{Amount: decimal.RequireFromString("0.001")}); !errors.Is(err, services.ErrInvalidArgument) {
+			t.Errorf("超出币种小数位的金额应返回 ErrInvalidArgument, 得到 %v", err)
+		}
+		if _, err := refunds.ListRefunds(1 << 30); !errors.Is(err, services.ErrNotFound) {
+			t.Errorf("不存在的订单应返回 ErrNotFound, 得到 %v", err)
+		}
+	})
+
+	t.Run("GetAnalysisData/RefundAttribution", func(t *testing.T) {
+		refunded := decimal.RequireFromString("30")
+		byOrder, byRefund := refundTotal(t, orderDate), refundTotal(t, refundDate)
+		if !byOrder.RefundByOrderDate.Sub(beforeOrderDate.RefundByOrderDate).Equal(refunded) ||
+			!byOrder.RefundByRefundDate.Equal(beforeOrderDate.RefundByRefundDate) {
+			t.Errorf("%s 按订单日期退款 %s → %s, 期望增加 %s", orderDate, beforeOrderDate.RefundByOrderDate, byOrder.RefundByOrderDate, refunded)
+		}
+		if !byRefund.RefundByRefundDate.Sub(beforeRefundDate.RefundByRefundDate).Equal(refunded) ||
+			!byRefund.RefundByOrderDate.Equal(beforeRefundDate.RefundByOrderDate) {
+			t.Errorf("%s 按退款日期退款 %s → %s, 期望增加 %s", refundDate, beforeRefundDate.RefundByRefundDate, byRefund.RefundByRefundDate, refunded)
+		}
+		// 默认口径下退款从原订单日期的营收中扣除
+		if !byOrder.NetAmount.Equal(byOrder.GrossAmount.Sub(byOrder.RefundByOrderDate)) || !byRefund.RefundAmount.Equal(byRefund.RefundByOrderDate) {
+			t.Errorf("默认口径净额 = %+v", byOrder)
+		}
+
+		revenue := services.DefaultRevenueDefinition
+		revenue.RefundAttribution = models.RefundAttributionRefundDate
+		if err := svc.SetRevenueDefinition(revenue); err != nil {
+			t.Fatalf("设置营收口径失败: %v", err)
+		}
+		defer svc.SetRevenueDefinition(services.DefaultRevenueDefinition)
+		byRefund = refundTotal(t, refundDate)
+		if !byRefund.RefundAmount.Equal(byRefund.RefundByRefundDate) || !byRefund.NetAmount.Equal(byRefund.GrossAmount.Sub(byRefund.RefundByRefundDate)) {
+			t.Errorf("按退款日期口径净额 = %+v", byRefund)
+		}
+
+		revenue.RefundAttribution = "yesterday"
+		if err := svc.SetRevenueDefinition(revenue); !errors.Is(err, services.ErrInvalidArgument) {
+			t.Errorf("无效的退款归属应返回 ErrInvalidArgument, 得到 %v", err)
+		}
+	})
+
+	t.Run("CreateRefund/Remaining", func(t *testing.T) {
+		refund, err := refunds.CreateRefund(orderID, services.RefundRequest{RefundedAt: refundTime.Format(time.RFC3339)})
+		if err != nil {
+			t.Fatalf("退还剩余金额失败: %v", err)
+		}
+		if !refund.Amount.Equal(decimal.RequireFromString("70")) {
+			t.Errorf("剩余退款金额 = %s, 期望 70", refund.Amount)
+		}
+		order, err := refunds.ListRefunds(orderID)
+		if err != nil {
+			t.Fatalf("获取退款记录失败: %v", err)
+		}
+		if order.Status != models.OrderStatusRefunded || !order.RemainingAmount.IsZero() || len(order.Refunds) != 2 {
+			t.Errorf("全额退款后订单 = %+v", order)
+		}
+		if _, err := refunds.CreateRefund(orderID, services.RefundRequest{}); !errors.Is(err, services.ErrConflict) {
+			t.Errorf("已全额退款的订单应返回 ErrConflict, 得到 %v", err)
+		}
+	})
+}
+
+// RunOnboardingSuite 验证商户入驻的时区推断、校验和创建
+func RunOnboardingSuite(t *testing.T, svc *services.OnboardingService) {
+	t.Run("Onboard/InferFromCity", func(t *testing.T) {
+		result, err := svc.Onboard(services.OnboardRequest{
+			Name:               "旧金山咖啡",
+			Country:            "US",
+			City:               "San Francisco",
+			BusinessHoursStart: "07:00",
+			BusinessHoursEnd:   "15:30",
+		})
+		if err != nil {
+			t.Fatalf("入驻失败: %v", err)
+		}
+		if result.Merchant.ID == 0 || result.Merchant.Timezone != "America/Los_Angeles" || result.TimezoneSource != "inferred" {
+			t.Errorf("入驻结果不符合预期: %+v", result)
+		}
+		if result.Merchant.BusinessHoursEnd != "15:30" || result.ReportSettings.MerchantID != result.Merchant.ID {
+			t.Errorf("营业时间或报表配置不符合预期: %+v", result.MerchantOnboarding)
+		}
+		if len(result.Webhooks) != len(services.DefaultWebhookEvents) {
+			t.Errorf("默认 Webhook 数 = %d, 期望 %d", len(result.Webhooks), len(services.DefaultWebhookEvents))
+		}
+
+		again := services.OnboardRequest{Name: "旧金山咖啡二店", Code: result.MerchantCode, Timezone: "America/Los_Angeles", Country: "美国", City: "旧金山"}
+		if _, err := svc.Onboard(again); !errors.Is(err, services.ErrConflict) {
+			t.Errorf("重复商户编码应返回 ErrConflict, 得到 %v", err)
+		}
+	})
+
+	t.Run("Onboard/DryRunAddress", func(t *testing.T) {
+		result, err := svc.Onboard(services.OnboardRequest{Name: "阿德莱德面包", Address: "12 King William St, Adelaide, Australia", DryRun: true})
+		if err != nil {
+			t.Fatalf("试运行失败: %v", err)
+		}
+		// 阿德莱德为 UTC+9:30，不能按国家默认推断为悉尼
+		if result.Merchant.Timezone != "Australia/Adelaide" || result.Merchant.ID != 0 {
+			t.Errorf("地址推断结果不符合预期: %+v", result)
+		}
+	})
+
+	t.Run("Onboard/Coordinates", func(t *testing.T) {
+		lat, lon := 49.28, -123.12
+		result, err := svc.Onboard(services.OnboardRequest{Name: "温哥华花店", Lat: &lat, Lon: &lon, DryRun: true})
+		if err != nil {
+			t.Fatalf("按坐标入驻失败: %v", err)
+		}
+		if result.Merchant.Timezone != "America/Vancouver" || result.Suggestions[0].MatchedBy != "coordinates" {
+			t.Errorf("坐标推断结果不符合预期: %+v", result)
+		}
+	})
+
+	t.Run("Onboard/Weekend", func(t *testing.T) {
+		result, err := svc.Onboard(services.OnboardRequest{Name: "利雅得香料", Country: "沙特阿拉伯", City: "利雅得", DryRun: true})
+		if err != nil {
+			t.Fatalf("试运行失败: %v", err)
+		}
+		if fmt.Sprint(result.Merchant.WeekendDays) != "[5 6]" {
+			t.Errorf("沙特商户默认周末 = %v, 期望 [5 6]", result.Merchant.WeekendDays)
+		}
+
+		result, err = svc.Onboard(services.OnboardRequest{Name: "迪拜珠宝", Country: "阿联酋", City: "迪拜", WeekendDays: []int{}, DryRun: true})
+		if err != nil {
+			t.Fatalf("试运行失败: %v", err)
+		}
+		if result.Merchant.WeekendDays == nil || len(result.Merchant.WeekendDays) != 0 {
+			t.Errorf("显式指定没有周末时应为空数组: %v", result.Merchant.WeekendDays)
+		}
+
+		order := NewOrderAnalysis(NewMerchant(1, "利雅得香料", "Asia/Riyadh", "SA", "利雅得"), 1, 10, time.Date(2024, 8, 16, 9, 0, 0, 0, time.UTC))
+		if !order.IsWeekend || order.IsBusinessHour {
+			t.Errorf("利雅得周五 12:00 的订单应为周末且不在营业时间: %+v", order)
+		}
+	})
+
+	t.Run("Onboard/Invalid", func(t *testing.T) {
+		cases := []services.OnboardRequest{
+			{Name: "", Country: "日本", City: "东京"},
+			{Name: "未知城市", Country: "Atlantis", City: "Poseidonia"},
+			{Name: "错误时区", Country: "日本", City: "东京", Timezone: "Asia/Nowhere"},
+			{Name: "错误营业时间", Country: "日本", City: "东京", BusinessHoursStart: "25:00", BusinessHoursEnd: "18:00"},
+			{Name: "缺少结束时间", Country: "日本", City: "东京", BusinessHoursStart: "09:00"},
+			{Name: "错误编码", Country: "日本", City: "东京", Code: "含 空格"},
+			{Name: "错误周末", Country: "日本", City: "东京", WeekendDays: []int{7}},
+			{Name: "全周周末", Country: "日本", City: "东京", WeekendDays: []int{0, 1, 2, 3, 4, 5, 6}},
+		}
+		for _, req := range cases {
+			if _, err := svc.Onboard(req); !errors.Is(err, services.ErrInvalidArgument) {
+				t.Errorf("%s: 应返回 ErrInvalidArgument, 得到 %v", req.Name, err)
+			}
+		}
+	})
+}
+
+// RunIndexAdvisorSuite 验证索引建议：缺少的访问前缀、被覆盖的索引、未使用的索引和以顺序扫描为主的大表
+func RunIndexAdvisorSuite(t *testing.T, svc *services.IndexAdvisorService, stats *IndexStatsRepository) {
+	stats.AddIndex(models.IndexInfo{Table: "dws_orders", Name: "dws_orders_pkey", Columns: []string{"order_id"}, Unique: true, Primary: true})
+	stats.AddIndex(models.IndexInfo{Table: "dws_orders", Name: "idx_orders_merchant_id", Columns: []string{"merchant_id"}, Scans: 5})
+	stats.AddIndex(models.IndexInfo{Table: "dws_orders", Name: "idx_orders_merchant_time", Columns: []string{"merchant_id", "order_time_utc"}, Scans: 100})
+	stats.AddIndex(models.IndexInfo{Table: "dws_orders", Name: "idx_orders_recent", Columns: []string{"order_time_utc", "merchant_id"}, Partial: true, Scans: 10})
+	stats.AddIndex(models.IndexInfo{Table: "dws_orders", Name: "idx_orders_source", Columns: []string{"order_source"}})
+	stats.AddIndex(models.IndexInfo{Table: "dim_merchant", Name: "idx_merchant_timezone", Columns: []string{"timezone"}, Scans: 50})
+	stats.SetTableStats(models.TableScanStats{Table: "dws_orders", LiveRows: 50000, SeqScans: 900, IndexScans: 300})
+	stats.SetTableStats(models.TableScanStats{Table: "dim_merchant", LiveRows: 20, SeqScans: 5000, IndexScans: 50})
+	stats.StatementsErr = errors.New("未安装 pg_stat_statements 扩展")
+
+	report, err := svc.Advise(context.Background())
+	if err != nil {
+		t.Fatalf("生成索引建议失败: %v", err)
+	}
+	got := map[string]bool{}
+	for _, a := range report.Advice {
+		key := a.Kind + ":" + a.Table + ":" + a.Index + strings.Join(a.Columns, ",")
+		got[key] = true
+		if a.Kind != services.IndexAdviceSeqScan && a.SQL == "" {
+			t.Errorf("建议 %s 缺少 SQL", key)
+		}
+	}
+
+	want := []string{
+		// 只有部分索引以 order_time_utc 开头
+		"create:dws_orders:order_time_utc",
+		"create:dws_orders:merchant_id,ingested_at",
+		"redundant:dws_orders:idx_orders_merchant_idmerchant_id",
+		"unused:dws_orders:idx_orders_sourceorder_source",
+		"seq_scan:dws_orders:",
+	}
+	for _, key := range want {
+		if !got[key] {
+			t.Errorf("缺少建议 %s, 得到 %v", key, got)
+		}
+	}
+	unwanted := []string{
+		"create:dws_orders:merchant_id,order_time_utc",
+		"create:dim_merchant:timezone",
+		"unused:dws_orders:dws_orders_pkeyorder_id",
+		// 小表顺序扫描是正常的
+		"seq_scan:dim_merchant:",
+	}
+	for _, key := range unwanted {
+		if got[key] {
+			t.Errorf("不应出现建议 %s", key)
+		}
+	}
+
+	if report.StatementsAvailable || report.StatementsNote == "" || report.Statements == nil {
+		t.Errorf("pg_stat_statements 不可用时应返回说明: available=%v note=%q", report.StatementsAvailable, report.StatementsNote)
+	}
+}
+
+// RunSettingsSuite 验证商户配置：默认值、类型校验、营业时间和周末同步到商户、重置和变更事件
+// merchantID 为一个已存在、所在国家周末为周六周日且未单独设置过配置的商户
+func RunSettingsSuite(t *testing.T, svc *services.SettingsService, merchants repository.MerchantRepository, merchantID int) {
+	var changes []models.SettingChange
+	svc.Subscribe(func(change models.SettingChange) { changes = append(changes, change) })
+
+	t.Run("List/Defaults", func(t *testing.T) {
+		settings, err := svc.List(merchantID)
+		if err != nil {
+			t.Fatalf("获取配置失败: %v", err)
+		}
+		if len(settings) != len(services.SettingKeys()) {
+			t.Fatalf("配置数 = %d, 期望 %d", len(settings), len(services.SettingKeys()))
+		}
+		for _, setting := range settings {
+			if !setting.IsDefault {
+				t.Errorf("配置 %s 应为默认值: %s", setting.Key, setting.Value)
+			}
+		}
+		currency, err := services.GetSetting(svc, merchantID, services.SettingCurrency)
+		if err != nil || currency != services.DefaultCurrency {
+			t.Errorf("默认币种 = %q, %v", currency, err)
+		}
+		schedule, err := services.GetSetting(svc, merchantID, services.SettingReportSchedule)
+		if err != nil || schedule != services.DefaultReportSchedule {
+			t.Errorf("默认报表计划 = %+v, %v", schedule, err)
+		}
+	})
+
+	t.Run("Set/Normalized", func(t *testing.T) {
+		if _, err := svc.Set(merchantID, "currency", json.RawMessage(`"eur"`), "alice"); err != nil {
+			t.Fatalf("修改币种失败: %v", err)
+		}
+		currency, err := services.GetSetting(svc, merchantID, services.SettingCurrency)
+		if err != nil || currency != "EUR" {
+			t.Errorf("币种 = %q, %v, 期望 EUR", currency, err)
+		}
+		schedule, err := svc.Set(merchantID, "report_schedule", json.RawMessage(`{"daily_enabled":false,"daily_time":"7:30","weekly_enabled":true,"week_start_day":7}`), "")
+		if err != nil {
+			t.Fatalf("修改报表计划失败: %v", err)
+		}
+		if string(schedule.Value) != `{"daily_enabled":false,"daily_time":"07:30","weekly_enabled":true,"week_start_day":7}` || schedule.UpdatedBy != "system" {
+			t.Errorf("报表计划未规范化: %s (%s)", schedule.Value, schedule.UpdatedBy)
+		}
+	})
+
+	t.Run("Set/Calendar", func(t *testing.T) {
+		if _, err := svc.Set(merchantID, "business_hours", json.RawMessage(`{"start":"22:00","end":"06:00"}`), "alice"); err != nil {
+			t.Fatalf("修改营业时间失败: %v", err)
+		}
+		if _, err := svc.Set(merchantID, "weekend_days", json.RawMessage(`[5,6]`), "alice"); err != nil {
+			t.Fatalf("修改周末失败: %v", err)
+		}
+		merchant, err := merchants.Get(merchantID)
+		if err != nil {
+			t.Fatalf("获取商户失败: %v", err)
+		}
+		if merchant.BusinessHoursStart != "22:00" || merchant.BusinessHoursEnd != "06:00" || fmt.Sprint(merchant.WeekendDays) != "[5 6]" {
+			t.Errorf("营业时间和周末未同步到商户: %+v", merchant)
+		}
+
+		setting, err := svc.Reset(merchantID, "weekend_days", "alice")
+		if err != nil {
+			t.Fatalf("重置周末失败: %v", err)
+		}
+		if !setting.IsDefault || string(setting.Value) != "[0,6]" {
+			t.Errorf("重置后的周末 = %s (默认 %v), 期望 [0,6]", setting.Value, setting.IsDefault)
+		}
+		hours, err := services.GetSetting(svc, merchantID, services.SettingBusinessHours)
+		if err != nil || hours.Start != "22:00" {
+			t.Errorf("重置周末不应影响营业时间: %+v, %v", hours, err)
+		}
+	})
+
+	t.Run("Set/Invalid", func(t *testing.T) {
+		cases := []struct {
+			key, value string
+		}{
+			{"currency", `"XYZ1"`},
+			{"locale", `"xx"`},
+			{"weekend_days", `[0,1,2,3,4,5,6]`},
+			{"weekend_days", `null`},
+			{"business_hours", `{"start":"09:00"}`},
+			{"report_schedule", `{"daily_time":"08:00","week_start_day":0}`},
+			{"report_schedule", `{"daily_time":"08:00","week_start_day":1,"unknown":true}`},
+		}
+		for _, c := range cases {
+			if _, err := svc.Set(merchantID, c.key, json.RawMessage(c.value), ""); !errors.Is(err, services.ErrInvalidArgument) {
+				t.Errorf("%s=%s: 应返回 ErrInvalidArgument, 得到 %v", c.key, c.value, err)
+			}
+		}
+		if _, err := svc.Get(merchantID, "no_such_key"); !errors.Is(err, services.ErrNotFound) {
+			t.Errorf("未知配置项应返回 ErrNotFound, 得到 %v", err)
+		}
+		if _, err := svc.List(999999); !errors.Is(err, services.ErrNotFound) {
+			t.Errorf("不存在的商户应返回 ErrNotFound, 得到 %v", err)
+		}
+	})
+
+	t.Run("Changes", func(t *testing.T) {
+		// 币种、报表计划、营业时间、周末各修改一次，周末重置一次
+		if len(changes) != 5 {
+			t.Fatalf("变更事件数 = %d, 期望 5: %+v", len(changes), changes)
+		}
+		first, last := changes[0], changes[len(changes)-1]
+		if first.Key != "currency" || string(first.Old) != `"USD"` || string(first.New) != `"EUR"` || first.ChangedBy != "alice" {
+			t.Errorf("币种变更事件异常: %+v", first)
+		}
+		if last.Key != "weekend_days" || !last.Reset || string(last.Old) != "[5,6]" {
+			t.Errorf("周末重置事件异常: %+v", last)
+		}
+	})
+}
+
+// RunOverviewSuite 运营看板用例，对任意商户和订单数据成立
+// requests 必须是 svc 使用的计数器，用例会向其中写入请求记录
+func RunOverviewSuite(t *testing.T, svc *services.OverviewService, requests *services.TenantRequestCounter) {
+	ctx := context.Background()
+	base, err := svc.Overview(ctx, services.OverviewOptions{})
+	if err != nil {
+		t.Fatalf("生成运营看板失败: %v", err)
+	}
+	if len(base.Tenants) == 0 {
+		t.Fatal("运营看板没有租户")
+	}
+
+	t.Run("Totals", func(t *testing.T) {
+		var orders, recent int64
+		tenants := 0
+		for _, tz := range base.TimezoneDistribution {
+			tenants += tz.Tenants
+		}
+		for _, tenant := range base.Tenants {
+			orders += tenant.OrderCount
+			recent += tenant.RecentOrderCount
+			if tenant.Healthy != (len(tenant.Flags) == 0) {
+				t.Errorf("商户 %d: healthy=%v 与 flags=%v 不一致", tenant.MerchantID, tenant.Healthy, tenant.Flags)
+			}
+			if (tenant.OrderCount == 0) != (tenant.LastIngestedAt == nil) {
+				t.Errorf("商户 %d: 订单数 %d 与最近入库时间 %v 不一致", tenant.MerchantID, tenant.OrderCount, tenant.LastIngestedAt)
+			}
+		}
+		if base.Totals.Tenants != len(base.Tenants) || tenants != len(base.Tenants) {
+			t.Errorf("租户数: totals=%d, 时区分布=%d, 列表=%d", base.Totals.Tenants, tenants, len(base.Tenants))
+		}
+		if base.Totals.Orders != orders || base.Totals.RecentOrders != recent {
+			t.Errorf("订单合计 %d/%d, 期望 %d/%d", base.Totals.Orders, base.Totals.RecentOrders, orders, recent)
+		}
+	})
+
+	t.Run("Requests", func(t *testing.T) {
+		merchantID := base.Tenants[0].MerchantID
+		tenant := fmt.Sprint(merchantID)
+		now := time.Now()
+		for i := 0; i < 20; i++ {
+			status := http.StatusOK
+			if i < 2 {
+				status = http.StatusServiceUnavailable
+			}
+			requests.Record(tenant, status, now)
+		}
+		requests.Record("overview-suite", http.StatusNotFound, now)
+
+		overview, err := svc.Overview(ctx, services.OverviewOptions{})
+		if err != nil {
+			t.Fatalf("生成运营看板失败: %v", err)
+		}
+		for _, o := range overview.Tenants {
+			if o.MerchantID != merchantID {
+				continue
+			}
+			if o.Requests < 20 || o.ServerErrors < 2 || o.LastRequestAt == nil {
+				t.Errorf("商户 %d 的请求统计异常: %+v", merchantID, o)
+			}
+			if o.ErrorRate >= 0.05 && !containsString(o.Flags, services.TenantFlagHighErrorRate) {
+				t.Errorf("错误率 %.4f 应标记 high_error_rate: %v", o.ErrorRate, o.Flags)
+			}
+		}
+		found := false
+		for _, other := range overview.OtherTenants {
+			if other.Tenant == "overview-suite" {
+				found = other.Requests == 1 && other.ClientErrors == 1
+			}
+			if other.Tenant == tenant {
+				t.Errorf("商户租户 %s 不应出现在 other_tenants", tenant)
+			}
+		}
+		if !found {
+			t.Errorf("other_tenants 缺少 overview-suite: %+v", overview.OtherTenants)
+		}
+	})
+
+	t.Run("Stale", func(t *testing.T) {
+		overview, err := svc.Overview(ctx, services.OverviewOptions{StaleAfter: time.Nanosecond})
+		if err != nil {
+			t.Fatalf("生成运营看板失败: %v", err)
+		}
+		stale := 0
+		for _, o := range overview.Tenants {
+			if o.OrderCount > 0 {
+				stale++
+				if !containsString(o.Flags, services.TenantFlagStale) {
+					t.Errorf("商户 %d 应标记 stale: %v", o.MerchantID, o.Flags)
+				}
+			} else if !containsString(o.Flags, services.TenantFlagNoOrders) {
+				t.Errorf("商户 %d 没有订单应标记 no_orders: %v", o.MerchantID, o.Flags)
+			}
+		}
+		if overview.Totals.Stale != stale {
+			t.Errorf("stale 合计 = %d, 期望 %d", overview.Totals.Stale, stale)
+		}
+	})
+
+	t.Run("Sort", func(t *testing.T) {
+		overview, err := svc.Overview(ctx, services.OverviewOptions{Sort: "orders"})
+		if err != nil {
+			t.Fatalf("生成运营看板失败: %v", err)
+		}
+		if overview.Order != "desc" {
+			t.Errorf("orders 默认应降序, 得到 %s", overview.Order)
+		}
+		for i := 1; i < len(overview.Tenants); i++ {
+			if overview.Tenants[i-1].OrderCount < overview.Tenants[i].OrderCount {
+				t.Fatalf("未按订单数降序: %d < %d", overview.Tenants[i-1].OrderCount, overview.Tenants[i].OrderCount)
+			}
+		}
+
+		invalid := []services.OverviewOptions{
+			{Sort: "bogus"},
+			{Sort: "orders", Order: "up"},
+			{ErrorRate: 1.5},
+			{StaleAfter: -time.Hour},
+		}
+		for _, opts := range invalid {
+			if _, err := svc.Overview(ctx, opts); !errors.Is(err, services.ErrInvalidArgument) {
+				t.Errorf("%+v: 应返回 ErrInvalidArgument, 得到 %v", opts, err)
+			}
+		}
+	})
+}
+
+// RunClientSuite 通过 Go 客户端验证时区接口，c 指向的服务数据与 RunHTTPSuite 相同
+func RunClientSuite(t *testing.T, c *client.Client) {
+	ctx := context.Background()
+
+	t.Run("Health", func(t *testing.T) {
+		health, err := c.Health(ctx)
+		if err != nil {
+			t.Fatalf("健康检查失败: %v", err)
+		}
+		if health.Service == "" || health.TZData.Source == "" {
+			t.Errorf("健康检查结果异常: %+v", health)
+		}
+	})
+
+	t.Run("EachOrder", func(t *testing.T) {
+		_, first, err := c.Orders(ctx, client.OrdersParams{Page: client.Page{Limit: 1000}, Timezone: "Europe/Berlin"})
+		if err != nil {
+			t.Fatalf("获取订单失败: %v", err)
+		}
+		seen := map[int]bool{}
+		err = c.EachOrder(ctx, client.OrdersParams{Page: client.Page{Limit: 2}, Timezone: "Europe/Berlin"}, func(o models.OrderAnalysis) error {
+			if seen[o.OrderID] {
+				return fmt.Errorf("订单 %d 重复出现", o.OrderID)
+			}
+			seen[o.OrderID] = true
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("逐页获取订单失败: %v", err)
+		}
+		if first == nil || !first.TotalCountExact || int64(len(seen)) != first.TotalCount {
+			t.Errorf("逐页获取 %d 条, 一次获取的 meta %+v", len(seen), first)
+		}
+	})
+
+	t.Run("Compare", func(t *testing.T) {
+		at := time.Date(2024, 3, 31, 1, 30, 0, 0, time.UTC)
+		comparison, err := c.CompareTimezones(ctx, at, "Asia/Tokyo", "Europe/Berlin")
+		if err != nil {
+			t.Fatalf("时区对比失败: %v", err)
+		}
+		if len(comparison.Comparisons) != 2 {
+			t.Errorf("对比结果 %d 个时区, 期望 2", len(comparison.Comparisons))
+		}
+	})
+
+	t.Run("Convert", func(t *testing.T) {
+		result, err := c.ConvertTimestamps(ctx, []models.ConversionItem{
+			{Timestamp: "2024-03-31T02:30:00", FromTZ: "Europe/Berlin", ToTZ: "Asia/Tokyo"},
+			{Timestamp: "2024-08-19T00:00:00Z", FromTZ: "UTC", ToTZ: "Mars/Base"},
+		}, "shift", "")
+		if err != nil {
+			t.Fatalf("批量转换失败: %v", err)
+		}
+		if result.Count != 2 || result.Failed != 1 || !result.Results[0].Shifted {
+			t.Errorf("批量转换结果异常: %+v", result)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := c.CompareTimezones(ctx, time.Time{}, "Mars/Base")
+		var apiErr *client.APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Code != "compare.failed" {
+			t.Errorf("非法时区应返回 400 compare.failed, 得到 %v", err)
+		}
+		if _, err := c.TimezoneRules(ctx, "", "", ""); !client.IsStatus(err, http.StatusBadRequest) {
+			t.Errorf("缺少时区应返回 400, 得到 %v", err)
+		}
+	})
+}
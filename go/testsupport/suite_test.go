@@ -0,0 +1,8 @@
+package testsupport
+
+import "testing"
+
+// TestServiceSuite 针对内存仓储运行时区服务用例，PostgreSQL 上的同一组用例见 go/integration_test.go
+func TestServiceSuite(t *testing.T) {
+	RunServiceSuite(t, NewMockFakes(MockOptions{Seed: 1}).Service())
+}
@@ -0,0 +1,251 @@
+// Package timerange 把请求中的日期参数解析为某个时区的本地日期范围，以及对应的 UTC 半开区间 [Start, End)。
+// 支持单个日期（date=2024-08-19、today、yesterday）、from/to（包含两端）和相对范围（last_7_days、this_month 等），
+// 相对范围按指定时区的今天计算。
+// 本地日期的边界按时区规则换算：夏令时切换当天不是 24 小时，有的时区零点因夏令时不存在（如 America/Santiago），
+// 因此不要用 Truncate(24*time.Hour) 或加减 24 小时计算日期边界，也不要直接使用 time.Date(y, m, d, 0, 0, 0, 0, loc)。
+package timerange
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layout 本地日期的格式
+const Layout = "2006-01-02"
+
+// MaxRelativeDays last_N_days 中 N 的上限
+const MaxRelativeDays = 366
+
+// ErrInvalid 日期或日期范围参数无效
+var ErrInvalid = errors.New("无效的日期范围")
+
+// Range 本地日期范围及其 UTC 半开区间
+type Range struct {
+	// From、To 本地日期（YYYY-MM-DD），包含两端
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Start 为 From 当天的第一个时刻，End 为 To 次日的第一个时刻，均为 UTC
+	Start time.Time `json:"start_utc"`
+	End   time.Time `json:"end_utc"`
+}
+
+// IsZero 是否为零值（未指定任何日期参数）
+func (r Range) IsZero() bool {
+	return r.From == "" && r.To == ""
+}
+
+// Days 范围包含的本地日期数
+func (r Range) Days() int {
+	from, errFrom := ParseDate(r.From)
+	to, errTo := ParseDate(r.To)
+	if errFrom != nil || errTo != nil {
+		return 0
+	}
+	return int(to.Sub(from).Hours()/24) + 1
+}
+
+// Dates 范围内的每个本地日期，按时间顺序
+func (r Range) Dates() []string {
+	from, errFrom := ParseDate(r.From)
+	to, errTo := ParseDate(r.To)
+	if errFrom != nil || errTo != nil {
+		return nil
+	}
+	var result []string
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		result = append(result, d.Format(Layout))
+	}
+	return result
+}
+
+// Contains t 是否落在 [Start, End) 内
+func (r Range) Contains(t time.Time) bool {
+	return !t.Before(r.Start) && t.Before(r.End)
+}
+
+// ParseDate 解析本地日期 YYYY-MM-DD，返回该日期 UTC 零点，只用于按日历计算（加减天数、比较先后）
+func ParseDate(value string) (time.Time, error) {
+	date, err := time.Parse(Layout, strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: 日期 %q 格式错误，应为 YYYY-MM-DD", ErrInvalid, value)
+	}
+	return date, nil
+}
+
+// StartOfDay 本地日期 date（只取年月日）在 loc 中的第一个时刻
+// 零点因夏令时不存在时 time.Date 可能换算到前一天，此时取当天第一个存在的时刻（如 01:00）；零点重复出现时取较早的一次
+func StartOfDay(date time.Time, loc *time.Location) time.Time {
+	want := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC).Format(Layout)
+	t := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+	if t.Format(Layout) == want {
+		return t
+	}
+	for t.Format(Layout) < want {
+		t = t.Add(time.Hour)
+	}
+	for {
+		prev := t.Add(-time.Minute)
+		if prev.Format(Layout) != want {
+			return t
+		}
+		t = prev
+	}
+}
+
+// Wall 本地日期 date（只取年月日）零点之后第 minute 分钟的本地时间在 loc 中的时刻，minute 超过一天时落在之后的日期
+// 该本地时间因夏令时不存在时按切换前的偏移换算，即顺延跳过的时长（如 02:30 顺延为 03:30）；出现两次时取较早的一次
+func Wall(date time.Time, minute int, loc *time.Location) time.Time {
+	wall := time.Date(date.Year(), date.Month(), date.Day(), 0, minute, 0, 0, time.UTC)
+	// 候选偏移取前后各一天和当天的偏移，足以覆盖一次夏令时切换
+	var first time.Time
+	for _, probe := range []time.Time{wall.Add(-24 * time.Hour), wall, wall.Add(24 * time.Hour)} {
+		_, offset := probe.In(loc).Zone()
+		t := wall.Add(-time.Duration(offset) * time.Second).In(loc)
+		if sameWall(t, wall) && (first.IsZero() || t.Before(first)) {
+			first = t
+		}
+	}
+	if !first.IsZero() {
+		return first
+	}
+	_, before := wall.Add(-24 * time.Hour).In(loc).Zone()
+	return wall.Add(-time.Duration(before) * time.Second).In(loc)
+}
+
+// sameWall 时刻 t 的本地年月日时分是否与 wall（以 UTC 字段表示）一致
+func sameWall(t, wall time.Time) bool {
+	return t.Year() == wall.Year() && t.Month() == wall.Month() && t.Day() == wall.Day() &&
+		t.Hour() == wall.Hour() && t.Minute() == wall.Minute()
+}
+
+// Today loc 中 now 所在的本地日期（UTC 零点表示）
+func Today(loc *time.Location, now time.Time) time.Time {
+	local := now.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Day 一个本地日期的范围
+func Day(date string, loc *time.Location) (Range, error) {
+	return Between(date, date, loc)
+}
+
+// Between 本地日期 from~to（包含两端）的范围
+func Between(from, to string, loc *time.Location) (Range, error) {
+	start, err := ParseDate(from)
+	if err != nil {
+		return Range{}, err
+	}
+	end, err := ParseDate(to)
+	if err != nil {
+		return Range{}, err
+	}
+	if end.Before(start) {
+		return Range{}, fmt.Errorf("%w: 结束日期 %s 早于开始日期 %s", ErrInvalid, to, from)
+	}
+	return dates(start, end, loc), nil
+}
+
+// lastDaysPattern last_N_days：截至今天（含）的最近 N 天
+var lastDaysPattern = regexp.MustCompile(`^last_(\d+)_days$`)
+
+// Relative 按 loc 中的今天计算相对范围：
+// today、yesterday；this_week（本周一至今天）、last_week（上周一至周日）；
+// this_month（本月一日至今天）、last_month（上个月整月）；last_N_days（截至今天的最近 N 天，含今天）
+func Relative(name string, loc *time.Location, now time.Time) (Range, error) {
+	today := Today(loc, now)
+	monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+	firstOfMonth := today.AddDate(0, 0, 1-today.Day())
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "today":
+		return dates(today, today, loc), nil
+	case "yesterday":
+		yesterday := today.AddDate(0, 0, -1)
+		return dates(yesterday, yesterday, loc), nil
+	case "this_week":
+		return dates(monday, today, loc), nil
+	case "last_week":
+		return dates(monday.AddDate(0, 0, -7), monday.AddDate(0, 0, -1), loc), nil
+	case "this_month":
+		return dates(firstOfMonth, today, loc), nil
+	case "last_month":
+		return dates(firstOfMonth.AddDate(0, -1, 0), firstOfMonth.AddDate(0, 0, -1), loc), nil
+	}
+	if m := lastDaysPattern.FindStringSubmatch(name); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || n < 1 || n > MaxRelativeDays {
+			return Range{}, fmt.Errorf("%w: %s 的天数应为 1~%d", ErrInvalid, name, MaxRelativeDays)
+		}
+		return dates(today.AddDate(0, 0, 1-n), today, loc), nil
+	}
+	return Range{}, fmt.Errorf("%w: 不支持的相对范围 %q，可选 today、yesterday、this_week、last_week、this_month、last_month、last_N_days", ErrInvalid, name)
+}
+
+// Params 请求中的日期参数，Date、From/To、Range 只能指定一种
+type Params struct {
+	// Date 单个本地日期 YYYY-MM-DD，也可以是 today 或 yesterday
+	Date string
+	// From、To 本地日期，包含两端，需要同时指定
+	From string
+	To   string
+	// Range 相对范围，见 Relative
+	Range string
+}
+
+// FromQuery 读取查询参数 date、from、to、range
+func FromQuery(query url.Values) Params {
+	return Params{
+		Date:  strings.TrimSpace(query.Get("date")),
+		From:  strings.TrimSpace(query.Get("from")),
+		To:    strings.TrimSpace(query.Get("to")),
+		Range: strings.TrimSpace(query.Get("range")),
+	}
+}
+
+// IsZero 是否未指定任何日期参数
+func (p Params) IsZero() bool {
+	return p.Date == "" && p.From == "" && p.To == "" && p.Range == ""
+}
+
+// Parse 按 loc 解析日期参数，未指定任何参数时返回零值 Range，由调用方使用自己的默认范围
+func Parse(p Params, loc *time.Location, now time.Time) (Range, error) {
+	kinds := 0
+	for _, set := range []bool{p.Date != "", p.From != "" || p.To != "", p.Range != ""} {
+		if set {
+			kinds++
+		}
+	}
+	switch {
+	case kinds == 0:
+		return Range{}, nil
+	case kinds > 1:
+		return Range{}, fmt.Errorf("%w: date、from/to 和 range 只能指定一种", ErrInvalid)
+	case p.Range != "":
+		return Relative(p.Range, loc, now)
+	case p.Date != "":
+		switch strings.ToLower(p.Date) {
+		case "today", "yesterday":
+			return Relative(p.Date, loc, now)
+		}
+		return Day(p.Date, loc)
+	case p.From == "" || p.To == "":
+		return Range{}, fmt.Errorf("%w: from 和 to 需要同时指定", ErrInvalid)
+	default:
+		return Between(p.From, p.To, loc)
+	}
+}
+
+// dates 本地日期 from~to（UTC 零点表示）在 loc 中的范围
+func dates(from, to time.Time, loc *time.Location) Range {
+	return Range{
+		From:  from.Format(Layout),
+		To:    to.Format(Layout),
+		Start: StartOfDay(from, loc).UTC(),
+		End:   StartOfDay(to.AddDate(0, 0, 1), loc).UTC(),
+	}
+}
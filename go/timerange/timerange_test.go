@@ -0,0 +1,203 @@
+package timerange_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"timezone-saas-demo/timerange"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("加载时区 %s 失败: %v", name, err)
+	}
+	return loc
+}
+
+func utc(year int, month time.Month, day, hour int) time.Time {
+	return time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+}
+
+// TestStartOfDay 零点不存在时取当天第一个存在的时刻，不能回退到前一天
+func TestStartOfDay(t *testing.T) {
+	cases := []struct {
+		name     string
+		timezone string
+		date     string
+		want     time.Time
+		wantWall string
+	}{
+		{"UTC", "UTC", "2024-09-08", utc(2024, time.September, 8, 0), "2024-09-08 00:00"},
+		{"柏林夏令时开始", "Europe/Berlin", "2024-03-31", utc(2024, time.March, 30, 23), "2024-03-31 00:00"},
+		{"柏林夏令时结束", "Europe/Berlin", "2024-10-27", utc(2024, time.October, 26, 22), "2024-10-27 00:00"},
+		{"圣地亚哥零点不存在", "America/Santiago", "2024-09-08", utc(2024, time.September, 8, 4), "2024-09-08 01:00"},
+		{"圣地亚哥零点前一天", "America/Santiago", "2024-09-07", utc(2024, time.September, 7, 4), "2024-09-07 00:00"},
+		{"圣地亚哥夏令时结束", "America/Santiago", "2024-04-07", utc(2024, time.April, 7, 4), "2024-04-07 00:00"},
+		{"哈瓦那零点不存在", "America/Havana", "2024-03-10", utc(2024, time.March, 10, 5), "2024-03-10 01:00"},
+		{"圣保罗零点不存在", "America/Sao_Paulo", "2018-11-04", utc(2018, time.November, 4, 3), "2018-11-04 01:00"},
+		{"加德满都非整点偏移", "Asia/Kathmandu", "2024-09-08", time.Date(2024, time.September, 7, 18, 15, 0, 0, time.UTC), "2024-09-08 00:00"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			loc := mustLoad(t, c.timezone)
+			date, err := timerange.ParseDate(c.date)
+			if err != nil {
+				t.Fatalf("解析日期失败: %v", err)
+			}
+			got := timerange.StartOfDay(date, loc)
+			if !got.Equal(c.want) {
+				t.Errorf("StartOfDay(%s) = %s, 期望 %s", c.date, got.UTC(), c.want)
+			}
+			if wall := got.In(loc).Format("2006-01-02 15:04"); wall != c.wantWall {
+				t.Errorf("StartOfDay(%s) 本地时间 = %s, 期望 %s", c.date, wall, c.wantWall)
+			}
+		})
+	}
+}
+
+// TestRelative 相对范围按 loc 中 now 所在的本地日期计算，last_N_days 包含今天
+func TestRelative(t *testing.T) {
+	santiago := mustLoad(t, "America/Santiago")
+	// 2024-09-08（周日）09:00 -03
+	now := utc(2024, time.September, 8, 12)
+	cases := []struct {
+		name       string
+		loc        *time.Location
+		now        time.Time
+		from, to   string
+		start, end time.Time
+	}{
+		{"today", santiago, now, "2024-09-08", "2024-09-08", utc(2024, time.September, 8, 4), utc(2024, time.September, 9, 3)},
+		{"yesterday", santiago, now, "2024-09-07", "2024-09-07", utc(2024, time.September, 7, 4), utc(2024, time.September, 8, 4)},
+		{"this_week", santiago, now, "2024-09-02", "2024-09-08", utc(2024, time.September, 2, 4), utc(2024, time.September, 9, 3)},
+		{"last_week", santiago, now, "2024-08-26", "2024-09-01", utc(2024, time.August, 26, 4), utc(2024, time.September, 2, 4)},
+		{"this_month", santiago, now, "2024-09-01", "2024-09-08", utc(2024, time.September, 1, 4), utc(2024, time.September, 9, 3)},
+		{"last_month", santiago, now, "2024-08-01", "2024-08-31", utc(2024, time.August, 1, 4), utc(2024, time.September, 1, 4)},
+		{"last_7_days", santiago, now, "2024-09-02", "2024-09-08", utc(2024, time.September, 2, 4), utc(2024, time.September, 9, 3)},
+		{"last_1_days", santiago, now, "2024-09-08", "2024-09-08", utc(2024, time.September, 8, 4), utc(2024, time.September, 9, 3)},
+		{" Last_7_Days ", santiago, now, "2024-09-02", "2024-09-08", utc(2024, time.September, 2, 4), utc(2024, time.September, 9, 3)},
+		// UTC 已是 09-08，圣地亚哥仍是 09-07 22:00
+		{"today", santiago, utc(2024, time.September, 8, 2), "2024-09-07", "2024-09-07", utc(2024, time.September, 7, 4), utc(2024, time.September, 8, 4)},
+		{"today", time.UTC, utc(2024, time.September, 8, 2), "2024-09-08", "2024-09-08", utc(2024, time.September, 8, 0), utc(2024, time.September, 9, 0)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := timerange.Relative(c.name, c.loc, c.now)
+			if err != nil {
+				t.Fatalf("Relative(%q) 失败: %v", c.name, err)
+			}
+			if got.From != c.from || got.To != c.to {
+				t.Errorf("Relative(%q) = %s~%s, 期望 %s~%s", c.name, got.From, got.To, c.from, c.to)
+			}
+			if !got.Start.Equal(c.start) || !got.End.Equal(c.end) {
+				t.Errorf("Relative(%q) = [%s, %s), 期望 [%s, %s)", c.name, got.Start, got.End, c.start, c.end)
+			}
+		})
+	}
+
+	for _, name := range []string{"", "tomorrow", "last_0_days", "last_367_days", "last_x_days"} {
+		if _, err := timerange.Relative(name, santiago, now); !errors.Is(err, timerange.ErrInvalid) {
+			t.Errorf("Relative(%q) 错误 = %v, 期望 ErrInvalid", name, err)
+		}
+	}
+}
+
+// TestBetween 包含两端的本地日期范围，UTC 区间按各自日期的第一个时刻计算
+func TestBetween(t *testing.T) {
+	cases := []struct {
+		name       string
+		timezone   string
+		from, to   string
+		start, end time.Time
+		days       int
+	}{
+		{"单日", "UTC", "2024-09-08", "2024-09-08", utc(2024, time.September, 8, 0), utc(2024, time.September, 9, 0), 1},
+		{"柏林 23 小时的一天", "Europe/Berlin", "2024-03-31", "2024-03-31", utc(2024, time.March, 30, 23), utc(2024, time.March, 31, 22), 1},
+		{"柏林 25 小时的一天", "Europe/Berlin", "2024-10-27", "2024-10-27", utc(2024, time.October, 26, 22), utc(2024, time.October, 27, 23), 1},
+		{"圣地亚哥跨过零点不存在的一天", "America/Santiago", "2024-09-07", "2024-09-08", utc(2024, time.September, 7, 4), utc(2024, time.September, 9, 3), 2},
+		{"哈瓦那结束于零点不存在的一天之前", "America/Havana", "2024-03-08", "2024-03-09", utc(2024, time.March, 8, 5), utc(2024, time.March, 10, 5), 2},
+		{"圣保罗跨月", "America/Sao_Paulo", "2018-10-31", "2018-11-04", utc(2018, time.October, 31, 3), utc(2018, time.November, 5, 2), 5},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := timerange.Between(c.from, c.to, mustLoad(t, c.timezone))
+			if err != nil {
+				t.Fatalf("Between 失败: %v", err)
+			}
+			if !got.Start.Equal(c.start) || !got.End.Equal(c.end) {
+				t.Errorf("Between(%s, %s) = [%s, %s), 期望 [%s, %s)", c.from, c.to, got.Start, got.End, c.start, c.end)
+			}
+			if got.Days() != c.days || len(got.Dates()) != c.days {
+				t.Errorf("Between(%s, %s) 天数 = %d（%v）, 期望 %d", c.from, c.to, got.Days(), got.Dates(), c.days)
+			}
+			if !got.Contains(c.start) || got.Contains(c.end) || got.Contains(c.start.Add(-time.Nanosecond)) {
+				t.Errorf("Between(%s, %s) 应为半开区间 [Start, End)", c.from, c.to)
+			}
+		})
+	}
+
+	for _, c := range [][2]string{{"2024-09-09", "2024-09-08"}, {"2024-9-8", "2024-09-08"}, {"2024-09-08", "2024-02-30"}, {"", "2024-09-08"}} {
+		if _, err := timerange.Between(c[0], c[1], time.UTC); !errors.Is(err, timerange.ErrInvalid) {
+			t.Errorf("Between(%q, %q) 错误 = %v, 期望 ErrInvalid", c[0], c[1], err)
+		}
+	}
+}
+
+// TestParse date、from/to、range 只能指定一种，未指定时返回零值
+func TestParse(t *testing.T) {
+	santiago := mustLoad(t, "America/Santiago")
+	now := utc(2024, time.September, 8, 12)
+	cases := []struct {
+		name     string
+		params   timerange.Params
+		from, to string
+	}{
+		{"未指定", timerange.Params{}, "", ""},
+		{"单日", timerange.Params{Date: "2024-09-08"}, "2024-09-08", "2024-09-08"},
+		{"today", timerange.Params{Date: "today"}, "2024-09-08", "2024-09-08"},
+		{"YESTERDAY", timerange.Params{Date: "YESTERDAY"}, "2024-09-07", "2024-09-07"},
+		{"from/to", timerange.Params{From: "2024-09-01", To: "2024-09-08"}, "2024-09-01", "2024-09-08"},
+		{"range", timerange.Params{Range: "last_7_days"}, "2024-09-02", "2024-09-08"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := timerange.Parse(c.params, santiago, now)
+			if err != nil {
+				t.Fatalf("Parse(%+v) 失败: %v", c.params, err)
+			}
+			if got.From != c.from || got.To != c.to {
+				t.Errorf("Parse(%+v) = %s~%s, 期望 %s~%s", c.params, got.From, got.To, c.from, c.to)
+			}
+			if c.from == "" && !got.IsZero() {
+				t.Errorf("Parse(%+v) 应返回零值, 得到 %+v", c.params, got)
+			}
+		})
+	}
+
+	// 单日参数与 Day 一致：零点不存在的日期从 01:00 -03 开始
+	day, err := timerange.Parse(timerange.Params{Date: "2024-09-08"}, santiago, now)
+	if err != nil {
+		t.Fatalf("Parse 失败: %v", err)
+	}
+	if want := utc(2024, time.September, 8, 4); !day.Start.Equal(want) {
+		t.Errorf("Parse(2024-09-08).Start = %s, 期望 %s", day.Start, want)
+	}
+
+	invalid := []timerange.Params{
+		{Date: "2024-09-08", Range: "today"},
+		{Date: "2024-09-08", From: "2024-09-01", To: "2024-09-08"},
+		{From: "2024-09-01", Range: "last_7_days"},
+		{From: "2024-09-01"},
+		{To: "2024-09-08"},
+		{Date: "tomorrow"},
+		{Range: "next_week"},
+	}
+	for _, p := range invalid {
+		if _, err := timerange.Parse(p, santiago, now); !errors.Is(err, timerange.ErrInvalid) {
+			t.Errorf("Parse(%+v) 错误 = %v, 期望 ErrInvalid", p, err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package tzdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	dirMu sync.RWMutex
+	// externalDir 外部 zoneinfo 目录（TZDATA_DIR），为空时使用 time 包的默认查找
+	externalDir string
+)
+
+// SetDir 切换到外部 zoneinfo 目录，dir 为空时恢复 time 包的默认查找
+// 目录中必须有 UTC 文件，避免指向错误的目录后所有时区都加载失败
+// time 包只在第一次加载时区时读取 ZONEINFO 环境变量，因此运行中切换需要通过这里
+func SetDir(dir string) error {
+	if dir != "" {
+		if _, err := os.Stat(filepath.Join(dir, "UTC")); err != nil {
+			return fmt.Errorf("%s 不是有效的 zoneinfo 目录: %w", dir, err)
+		}
+	}
+	dirMu.Lock()
+	externalDir = dir
+	dirMu.Unlock()
+	return nil
+}
+
+// Dir 当前使用的外部 zoneinfo 目录，为空表示使用 time 包的默认查找
+func Dir() string {
+	dirMu.RLock()
+	defer dirMu.RUnlock()
+	return externalDir
+}
+
+// LoadLocation 加载时区：设置了外部目录时从该目录读取，否则等同 time.LoadLocation
+// 调用方负责缓存，切换目录后需要丢弃已缓存的 *time.Location
+func LoadLocation(name string) (*time.Location, error) {
+	dir := Dir()
+	if dir == "" || name == "UTC" {
+		return time.LoadLocation(name)
+	}
+	if name == "" || filepath.IsAbs(name) || strings.Contains(name, "..") || strings.ContainsRune(name, '\\') {
+		return nil, fmt.Errorf("无效的时区名称 %q", name)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, fmt.Errorf("读取时区 %s 失败: %w", name, err)
+	}
+	return time.LoadLocationFromTZData(name, data)
+}
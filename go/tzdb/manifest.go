@@ -0,0 +1,123 @@
+package tzdb
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// manifestTimeout 从 URL 获取最新版本清单的超时时间
+const manifestTimeout = 5 * time.Second
+
+//go:embed manifest.json
+var embeddedManifest []byte
+
+// Manifest 已知的最新 tzdata 版本，随代码发布时更新 manifest.json
+type Manifest struct {
+	Version  string `json:"version"`
+	Released string `json:"released,omitempty"`
+}
+
+// LoadManifest 读取最新版本清单：source 为空时使用内置清单，
+// 以 http:// 或 https:// 开头时从 URL 获取，否则按文件路径读取
+func LoadManifest(ctx context.Context, source string) (Manifest, error) {
+	data := embeddedManifest
+	switch {
+	case source == "":
+	case strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://"):
+		ctx, cancel := context.WithTimeout(ctx, manifestTimeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+		if err != nil {
+			return Manifest{}, fmt.Errorf("创建清单请求失败: %w", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return Manifest{}, fmt.Errorf("获取清单 %s 失败: %w", source, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return Manifest{}, fmt.Errorf("获取清单 %s 失败: HTTP %d", source, resp.StatusCode)
+		}
+		if data, err = io.ReadAll(io.LimitReader(resp.Body, 1<<20)); err != nil {
+			return Manifest{}, fmt.Errorf("读取清单 %s 失败: %w", source, err)
+		}
+	default:
+		var err error
+		if data, err = os.ReadFile(source); err != nil {
+			return Manifest{}, fmt.Errorf("读取清单 %s 失败: %w", source, err)
+		}
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return Manifest{}, fmt.Errorf("解析清单失败: %w", err)
+	}
+	if _, _, ok := parseVersion(manifest.Version); !ok {
+		return Manifest{}, fmt.Errorf("清单中的版本 %q 无效", manifest.Version)
+	}
+	return manifest, nil
+}
+
+// EffectiveVersion 当前使用的 tzdata 版本：优先运行时读取到的版本，其次构建时版本
+func (i Info) EffectiveVersion() string {
+	if i.RuntimeVersion != "" {
+		return i.RuntimeVersion
+	}
+	return i.BuildVersion
+}
+
+// CompareVersions 比较两个 tzdata 版本（如 2024a 与 2024b、2023c 与 2024a）
+// a 较旧返回 -1，相同返回 0，较新返回 1；任一版本无法解析时 ok 为 false
+func CompareVersions(a, b string) (result int, ok bool) {
+	yearA, suffixA, okA := parseVersion(a)
+	yearB, suffixB, okB := parseVersion(b)
+	if !okA || !okB {
+		return 0, false
+	}
+	switch {
+	case yearA != yearB:
+		return cmpInt(yearA, yearB), true
+	case len(suffixA) != len(suffixB):
+		// 一年内超过 26 次发布时后缀为 za、zb…，更长的后缀更新
+		return cmpInt(len(suffixA), len(suffixB)), true
+	default:
+		return strings.Compare(suffixA, suffixB), true
+	}
+}
+
+// parseVersion 将 2024a 拆分为年份和字母后缀
+func parseVersion(version string) (int, string, bool) {
+	version = strings.TrimSpace(version)
+	if len(version) < 5 {
+		return 0, "", false
+	}
+	year, err := strconv.Atoi(version[:4])
+	if err != nil {
+		return 0, "", false
+	}
+	suffix := version[4:]
+	for _, r := range suffix {
+		if r < 'a' || r > 'z' {
+			return 0, "", false
+		}
+	}
+	return year, suffix, true
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,124 @@
+// Package tzdb 提供时区数据库（IANA tzdata）的版本信息和历史偏移切换，
+// 用于核对历史订单是按哪一版规则换算的本地时间。
+package tzdb
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// BuildVersion 构建镜像时使用的 tzdata 版本，由 Dockerfile 通过
+// -ldflags "-X timezone-saas-demo/tzdb.BuildVersion=2024a" 写入，本地构建时为空
+var BuildVersion string
+
+// zoneSources 与 time 包在类 Unix 系统上查找 zoneinfo 的顺序一致（ZONEINFO 环境变量优先）
+var zoneSources = []string{
+	"/usr/share/zoneinfo/",
+	"/usr/share/lib/zoneinfo/",
+	"/usr/lib/locale/TZ/",
+	"/etc/zoneinfo/",
+}
+
+// Info 时区数据库版本信息
+type Info struct {
+	// BuildVersion 构建时的 tzdata 版本，未知时为空
+	BuildVersion string `json:"build_version,omitempty"`
+	// RuntimeVersion 当前加载时区使用的 tzdata 版本，读取不到版本文件时为空
+	RuntimeVersion string `json:"runtime_version,omitempty"`
+	// Source time 包加载时区的来源：zoneinfo 目录或 zip 文件路径
+	Source    string `json:"source"`
+	GoVersion string `json:"go_version"`
+}
+
+// Detect 检测当前进程加载时区使用的 tzdata 来源和版本，设置了外部目录时为该目录
+func Detect() Info {
+	info := Info{BuildVersion: BuildVersion, GoVersion: runtime.Version()}
+	if dir := Dir(); dir != "" {
+		info.Source = filepath.Clean(dir)
+		info.RuntimeVersion = DirVersion(dir)
+		return info
+	}
+
+	sources := zoneSources
+	if env := os.Getenv("ZONEINFO"); env != "" {
+		sources = append([]string{env}, sources...)
+	}
+	for _, source := range sources {
+		if _, err := os.Stat(filepath.Join(source, "UTC")); err != nil {
+			continue
+		}
+		info.Source = filepath.Clean(source)
+		info.RuntimeVersion = DirVersion(source)
+		return info
+	}
+
+	// 系统没有 zoneinfo 时 time 包使用 Go 自带的 zoneinfo.zip，版本随 Go 发布
+	info.Source = filepath.Join(runtime.GOROOT(), "lib", "time", "zoneinfo.zip")
+	return info
+}
+
+// DirVersion 读取 zoneinfo 目录中的 tzdata 版本
+// 依次查找 tzdata.zi 首行的 "# version 2024a" 和 +VERSION 文件，都没有时返回空
+func DirVersion(dir string) string {
+	if f, err := os.Open(filepath.Join(dir, "tzdata.zi")); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		if scanner.Scan() {
+			if version, ok := strings.CutPrefix(scanner.Text(), "# version "); ok {
+				return strings.TrimSpace(version)
+			}
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "+VERSION")); err == nil {
+		return strings.TrimSpace(string(data))
+	}
+	return ""
+}
+
+// Zone 某一时段内生效的时区规则
+type Zone struct {
+	Abbreviation  string `json:"abbreviation"`
+	OffsetSeconds int    `json:"offset_seconds"`
+	IsDST         bool   `json:"is_dst"`
+}
+
+// Transition 一次偏移切换
+type Transition struct {
+	// At 切换时刻，Before 在此之前生效，After 从此刻起生效
+	At     time.Time `json:"at"`
+	Before Zone      `json:"before"`
+	After  Zone      `json:"after"`
+}
+
+// Transitions 返回 loc 在 [from, to) 内的偏移切换，最多 limit 条，第二个返回值表示是否被截断
+// 切换时刻来自 time.Time.ZoneBounds，与 time 包换算本地时间使用的规则完全一致
+func Transitions(loc *time.Location, from, to time.Time, limit int) ([]Transition, bool) {
+	var transitions []Transition
+	t := from.In(loc)
+	for t.Before(to) {
+		_, end := t.ZoneBounds()
+		if end.IsZero() || !end.Before(to) {
+			break
+		}
+		if len(transitions) == limit {
+			return transitions, true
+		}
+		transitions = append(transitions, Transition{
+			At:     end.UTC(),
+			Before: ZoneAt(t),
+			After:  ZoneAt(end),
+		})
+		t = end
+	}
+	return transitions, false
+}
+
+// ZoneAt 时刻 t（已位于目标时区）生效的规则
+func ZoneAt(t time.Time) Zone {
+	abbreviation, offset := t.Zone()
+	return Zone{Abbreviation: abbreviation, OffsetSeconds: offset, IsDST: t.IsDST()}
+}
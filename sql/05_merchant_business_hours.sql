@@ -0,0 +1,69 @@
+-- =====================================================
+-- 商户营业时间
+-- 营业时间以商户本地时间表示，结束时间不晚于开始时间表示跨午夜营业
+-- 默认 09:00-19:00 与原视图的 9~18 点判断保持一致
+-- =====================================================
+
+ALTER TABLE dim_merchant
+    ADD COLUMN IF NOT EXISTS business_hours_start TIME NOT NULL DEFAULT '09:00',
+    ADD COLUMN IF NOT EXISTS business_hours_end   TIME NOT NULL DEFAULT '19:00';
+
+COMMENT ON COLUMN dim_merchant.business_hours_start IS '营业开始时间（商户本地时间）';
+COMMENT ON COLUMN dim_merchant.business_hours_end IS '营业结束时间（商户本地时间，不含），不晚于开始时间表示跨午夜';
+
+-- =====================================================
+-- 分析视图：is_business_hour 改为使用商户营业时间
+-- =====================================================
+
+DROP VIEW IF EXISTS dws_orders_analysis_view;
+
+CREATE VIEW dws_orders_analysis_view AS
+WITH t AS (
+  SELECT
+    o.order_id,
+    o.order_no                         AS order_number,
+    o.order_amount                     AS amount,
+    o.currency,
+    o.order_status                     AS status,
+
+    m.merchant_id,
+    m.merchant_name,
+    m.country,
+    m.city,
+    m.timezone,
+
+    o.order_time_utc,
+    o.payment_time_utc,
+
+    (o.order_time_utc   AT TIME ZONE m.timezone) AS order_time_local,
+    (o.payment_time_utc AT TIME ZONE m.timezone) AS payment_time_local,
+
+    (o.order_time_utc AT TIME ZONE m.timezone)::date AS local_date,
+
+    m.business_hours_start,
+    m.business_hours_end
+  FROM dws_orders o
+  JOIN dim_merchant m ON m.merchant_id = o.merchant_id
+)
+SELECT
+  t.order_id, t.order_number, t.amount, t.currency, t.status,
+  t.merchant_id, t.merchant_name, t.country, t.city, t.timezone,
+  t.order_time_utc, t.payment_time_utc,
+  t.order_time_local, t.payment_time_local,
+  t.local_date,
+
+  EXTRACT(HOUR FROM t.order_time_local)::int       AS local_hour,
+  EXTRACT(DOW  FROM t.order_time_local)::int       AS local_day_of_week,   -- 0=周日, 1=周一, ...
+  TO_CHAR(t.order_time_local, 'FMDay')             AS local_weekday,
+
+  CASE WHEN EXTRACT(DOW FROM t.order_time_local) IN (0,6) THEN TRUE ELSE FALSE END AS is_weekend,
+  -- 工作日且处于商户营业时间内（支持跨午夜营业时间）
+  CASE
+    WHEN EXTRACT(DOW FROM t.order_time_local) NOT BETWEEN 1 AND 5 THEN FALSE
+    WHEN t.business_hours_start < t.business_hours_end
+      THEN t.order_time_local::time >= t.business_hours_start AND t.order_time_local::time < t.business_hours_end
+    ELSE t.order_time_local::time >= t.business_hours_start OR t.order_time_local::time < t.business_hours_end
+  END AS is_business_hour,
+
+  EXTRACT(EPOCH FROM (t.order_time_local - (t.order_time_utc AT TIME ZONE 'UTC')))::int AS timezone_offset
+FROM t;
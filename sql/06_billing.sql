@@ -0,0 +1,45 @@
+-- =====================================================
+-- 订阅计费
+-- 计费周期锚定在商户本地时区：每期从签约日的本地零点开始
+-- =====================================================
+
+-- 商户订阅配置，未配置的商户按月计费、以商户创建日为签约日
+CREATE TABLE IF NOT EXISTS merchant_subscription (
+    merchant_id INTEGER PRIMARY KEY REFERENCES dim_merchant(merchant_id) ON DELETE CASCADE,
+    plan_code VARCHAR(50) NOT NULL DEFAULT 'standard',
+    -- 计费间隔：week / month / year，配合 interval_count 表示每 N 周/月/年
+    interval_unit VARCHAR(10) NOT NULL DEFAULT 'month',
+    interval_count INTEGER NOT NULL DEFAULT 1,
+    -- 签约日（商户本地日期），月末签约时短月按当月最后一天计费
+    anchor_date DATE NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'active',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_subscription_interval_unit CHECK (interval_unit IN ('week', 'month', 'year')),
+    CONSTRAINT chk_subscription_interval_count CHECK (interval_count > 0),
+    CONSTRAINT chk_subscription_status CHECK (status IN ('active', 'cancelled'))
+);
+
+COMMENT ON TABLE merchant_subscription IS '商户订阅配置，计费周期按商户本地时区计算';
+COMMENT ON COLUMN merchant_subscription.anchor_date IS '签约日（商户本地日期），每期在对应日期的本地零点开始';
+
+-- 已计算的计费周期，同一商户的周期序号唯一，重复计算不会覆盖已有记录
+CREATE TABLE IF NOT EXISTS billing_period (
+    period_id BIGSERIAL PRIMARY KEY,
+    merchant_id INTEGER NOT NULL REFERENCES dim_merchant(merchant_id) ON DELETE CASCADE,
+    sequence INTEGER NOT NULL,
+    timezone VARCHAR(50) NOT NULL,
+    -- 本地起止时间（不含时区），便于对账时直接阅读
+    period_start_local TIMESTAMP NOT NULL,
+    period_end_local TIMESTAMP NOT NULL,
+    -- 实际起止时刻，左闭右开
+    period_start_utc TIMESTAMP WITH TIME ZONE NOT NULL,
+    period_end_utc TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uq_billing_period_sequence UNIQUE (merchant_id, sequence),
+    CONSTRAINT chk_billing_period_range CHECK (period_end_utc > period_start_utc)
+);
+
+CREATE INDEX IF NOT EXISTS idx_billing_period_merchant_start ON billing_period(merchant_id, period_start_utc);
+
+COMMENT ON TABLE billing_period IS '计费周期，起止时刻为商户本地零点对应的UTC时间';
@@ -0,0 +1,61 @@
+-- =====================================================
+-- 日结快照
+-- 每个商户的本地日期在本地零点过后结账，快照一经写入不可修改；
+-- 结账后才入库的订单（迟到订单）以调整记录的形式单独追加
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS daily_revenue_snapshot (
+    snapshot_id BIGSERIAL PRIMARY KEY,
+    merchant_id INTEGER NOT NULL REFERENCES dim_merchant(merchant_id),
+    -- 商户本地日期
+    local_date DATE NOT NULL,
+    timezone VARCHAR(50) NOT NULL,
+    -- 该本地日期对应的 UTC 区间（左闭右开），夏令时切换日不是 24 小时
+    window_start_utc TIMESTAMP WITH TIME ZONE NOT NULL,
+    window_end_utc TIMESTAMP WITH TIME ZONE NOT NULL,
+    order_count INTEGER NOT NULL,
+    total_amount DECIMAL(15,2) NOT NULL,
+    closed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uq_daily_revenue_snapshot UNIQUE (merchant_id, local_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_daily_revenue_snapshot_date ON daily_revenue_snapshot(local_date);
+
+COMMENT ON TABLE daily_revenue_snapshot IS '日结快照（不可修改），按商户本地日期结账';
+
+-- 迟到订单等造成的调整，快照本身保持不变
+CREATE TABLE IF NOT EXISTS daily_revenue_adjustment (
+    adjustment_id BIGSERIAL PRIMARY KEY,
+    snapshot_id BIGINT NOT NULL REFERENCES daily_revenue_snapshot(snapshot_id),
+    merchant_id INTEGER NOT NULL,
+    local_date DATE NOT NULL,
+    order_count_delta INTEGER NOT NULL DEFAULT 0,
+    amount_delta DECIMAL(15,2) NOT NULL DEFAULT 0,
+    reason VARCHAR(200) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_daily_revenue_adjustment_snapshot ON daily_revenue_adjustment(snapshot_id);
+CREATE INDEX IF NOT EXISTS idx_daily_revenue_adjustment_date ON daily_revenue_adjustment(local_date);
+
+COMMENT ON TABLE daily_revenue_adjustment IS '日结调整记录，已结账日期的变动只追加调整，不修改快照';
+
+-- 快照与调整记录只允许插入（TRUNCATE 不受影响，示例数据重置仍可清空）
+CREATE OR REPLACE FUNCTION reject_immutable_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION '% 不允许 %，请追加调整记录', TG_TABLE_NAME, TG_OP;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS daily_revenue_snapshot_immutable ON daily_revenue_snapshot;
+CREATE TRIGGER daily_revenue_snapshot_immutable
+    BEFORE UPDATE OR DELETE ON daily_revenue_snapshot
+    FOR EACH ROW
+    EXECUTE FUNCTION reject_immutable_change();
+
+DROP TRIGGER IF EXISTS daily_revenue_adjustment_immutable ON daily_revenue_adjustment;
+CREATE TRIGGER daily_revenue_adjustment_immutable
+    BEFORE UPDATE OR DELETE ON daily_revenue_adjustment
+    FOR EACH ROW
+    EXECUTE FUNCTION reject_immutable_change();
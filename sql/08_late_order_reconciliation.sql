@@ -0,0 +1,88 @@
+-- =====================================================
+-- 迟到订单对账
+-- ingested_at 记录订单入库时间，与 order_time_utc 对比可识别在本地日期结账后才入库的订单
+-- =====================================================
+
+ALTER TABLE dws_orders
+    ADD COLUMN IF NOT EXISTS ingested_at TIMESTAMP WITH TIME ZONE;
+
+-- 已有订单以创建时间作为入库时间；临时停用更新时间触发器，避免回填改动 updated_at
+ALTER TABLE dws_orders DISABLE TRIGGER update_orders_updated_at;
+UPDATE dws_orders SET ingested_at = COALESCE(created_at, CURRENT_TIMESTAMP) WHERE ingested_at IS NULL;
+ALTER TABLE dws_orders ENABLE TRIGGER update_orders_updated_at;
+
+ALTER TABLE dws_orders
+    ALTER COLUMN ingested_at SET DEFAULT CURRENT_TIMESTAMP,
+    ALTER COLUMN ingested_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_orders_merchant_ingested ON dws_orders(merchant_id, ingested_at);
+
+COMMENT ON COLUMN dws_orders.ingested_at IS '订单入库时间（UTC），晚于所属本地日期的结账时间即为迟到订单';
+
+-- 调整记录关联到具体订单，同一订单对同一快照只调整一次
+ALTER TABLE daily_revenue_adjustment
+    ADD COLUMN IF NOT EXISTS order_id INTEGER REFERENCES dws_orders(order_id),
+    ADD COLUMN IF NOT EXISTS created_by VARCHAR(100) NOT NULL DEFAULT 'system';
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_daily_revenue_adjustment_order
+    ON daily_revenue_adjustment(snapshot_id, order_id) WHERE order_id IS NOT NULL;
+
+-- =====================================================
+-- 分析视图：增加 ingested_at
+-- =====================================================
+
+DROP VIEW IF EXISTS dws_orders_analysis_view;
+
+CREATE VIEW dws_orders_analysis_view AS
+WITH t AS (
+  SELECT
+    o.order_id,
+    o.order_no                         AS order_number,
+    o.order_amount                     AS amount,
+    o.currency,
+    o.order_status                     AS status,
+
+    m.merchant_id,
+    m.merchant_name,
+    m.country,
+    m.city,
+    m.timezone,
+
+    o.order_time_utc,
+    o.payment_time_utc,
+    o.ingested_at,
+
+    (o.order_time_utc   AT TIME ZONE m.timezone) AS order_time_local,
+    (o.payment_time_utc AT TIME ZONE m.timezone) AS payment_time_local,
+
+    (o.order_time_utc AT TIME ZONE m.timezone)::date AS local_date,
+
+    m.business_hours_start,
+    m.business_hours_end
+  FROM dws_orders o
+  JOIN dim_merchant m ON m.merchant_id = o.merchant_id
+)
+SELECT
+  t.order_id, t.order_number, t.amount, t.currency, t.status,
+  t.merchant_id, t.merchant_name, t.country, t.city, t.timezone,
+  t.order_time_utc, t.payment_time_utc,
+  t.order_time_local, t.payment_time_local,
+  t.local_date,
+
+  EXTRACT(HOUR FROM t.order_time_local)::int       AS local_hour,
+  EXTRACT(DOW  FROM t.order_time_local)::int       AS local_day_of_week,   -- 0=周日, 1=周一, ...
+  TO_CHAR(t.order_time_local, 'FMDay')             AS local_weekday,
+
+  CASE WHEN EXTRACT(DOW FROM t.order_time_local) IN (0,6) THEN TRUE ELSE FALSE END AS is_weekend,
+  -- 工作日且处于商户营业时间内（支持跨午夜营业时间）
+  CASE
+    WHEN EXTRACT(DOW FROM t.order_time_local) NOT BETWEEN 1 AND 5 THEN FALSE
+    WHEN t.business_hours_start < t.business_hours_end
+      THEN t.order_time_local::time >= t.business_hours_start AND t.order_time_local::time < t.business_hours_end
+    ELSE t.order_time_local::time >= t.business_hours_start OR t.order_time_local::time < t.business_hours_end
+  END AS is_business_hour,
+
+  EXTRACT(EPOCH FROM (t.order_time_local - (t.order_time_utc AT TIME ZONE 'UTC')))::int AS timezone_offset,
+
+  t.ingested_at
+FROM t;
@@ -0,0 +1,42 @@
+-- =====================================================
+-- 商户入驻：默认报表与 Webhook 配置
+-- 入驻接口在同一事务中创建商户并写入以下默认配置
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS merchant_report_settings (
+    merchant_id INTEGER PRIMARY KEY REFERENCES dim_merchant(merchant_id),
+    -- 日报在商户本地时间发送
+    daily_report_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    daily_report_time TIME NOT NULL DEFAULT '08:00',
+    weekly_report_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    -- 周报统计周起始日，ISO 星期：1=周一 ... 7=周日
+    week_start_day SMALLINT NOT NULL DEFAULT 1 CHECK (week_start_day BETWEEN 1 AND 7),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+COMMENT ON TABLE merchant_report_settings IS '商户报表配置，发送时间为商户本地时间';
+
+CREATE TABLE IF NOT EXISTS merchant_webhook (
+    webhook_id SERIAL PRIMARY KEY,
+    merchant_id INTEGER NOT NULL REFERENCES dim_merchant(merchant_id),
+    event_type VARCHAR(50) NOT NULL,
+    -- 入驻时只登记事件类型，商户配置地址后再启用
+    url VARCHAR(500) NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (merchant_id, event_type)
+);
+
+COMMENT ON TABLE merchant_webhook IS '商户 Webhook 订阅，每种事件一条';
+
+DROP TRIGGER IF EXISTS update_report_settings_updated_at ON merchant_report_settings;
+CREATE TRIGGER update_report_settings_updated_at
+    BEFORE UPDATE ON merchant_report_settings
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+DROP TRIGGER IF EXISTS update_webhook_updated_at ON merchant_webhook;
+CREATE TRIGGER update_webhook_updated_at
+    BEFORE UPDATE ON merchant_webhook
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
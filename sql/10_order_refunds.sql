@@ -0,0 +1,32 @@
+-- =====================================================
+-- 订单退款
+-- 一笔订单可以有多条（部分）退款记录，退款合计不超过订单金额；
+-- 退款时间统一存储 UTC，分析时可按原订单本地日期或退款本地日期归属
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS dws_order_refund (
+    refund_id BIGSERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL REFERENCES dws_orders(order_id),
+    merchant_id INTEGER NOT NULL REFERENCES dim_merchant(merchant_id),
+    refund_amount DECIMAL(15,2) NOT NULL CHECK (refund_amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    reason VARCHAR(200) NOT NULL DEFAULT '',
+    -- 退款发生时间（UTC），按订单所属商户时区换算退款本地日期
+    refund_time_utc TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_by VARCHAR(100) NOT NULL DEFAULT 'system',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_refund_order ON dws_order_refund(order_id);
+CREATE INDEX IF NOT EXISTS idx_order_refund_time ON dws_order_refund(refund_time_utc);
+
+COMMENT ON TABLE dws_order_refund IS '订单退款记录，退款时间为UTC';
+
+-- 已是 refunded 状态的历史订单补一条全额退款记录，退款时间取订单最后更新时间
+INSERT INTO dws_order_refund (order_id, merchant_id, refund_amount, currency, reason, refund_time_utc, created_by)
+SELECT o.order_id, o.merchant_id, o.order_amount, COALESCE(o.currency, 'USD'), '历史退款订单',
+       GREATEST(o.order_time_utc, COALESCE(o.updated_at, o.order_time_utc)), 'migration'
+FROM dws_orders o
+WHERE o.order_status = 'refunded'
+  AND o.order_amount > 0
+  AND NOT EXISTS (SELECT 1 FROM dws_order_refund r WHERE r.order_id = o.order_id);
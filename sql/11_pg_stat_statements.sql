@@ -0,0 +1,14 @@
+-- =====================================================
+-- 语句统计扩展
+-- /api/admin/index-advice 读取 pg_stat_statements 中涉及订单和商户表的语句耗时；
+-- 扩展需要 shared_preload_libraries=pg_stat_statements（见 docker-compose.yml），
+-- 没有权限或数据库不支持时只给出提示，不影响迁移
+-- =====================================================
+
+DO $$
+BEGIN
+    CREATE EXTENSION IF NOT EXISTS pg_stat_statements;
+EXCEPTION WHEN OTHERS THEN
+    RAISE NOTICE '无法创建 pg_stat_statements 扩展（%），索引建议将不包含语句统计', SQLERRM;
+END
+$$;
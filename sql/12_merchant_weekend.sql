@@ -0,0 +1,100 @@
+-- =====================================================
+-- 商户周末定义
+-- weekend_days 为周末的星期序号（与 EXTRACT(DOW) 一致：0=周日 … 6=周六）
+-- 默认周六周日；中东等地区的商户按国家回填为周五周六（伊朗为周五）
+-- 国家列表与 go/services/weekend.go 保持一致
+-- =====================================================
+
+ALTER TABLE dim_merchant
+    ADD COLUMN IF NOT EXISTS weekend_days SMALLINT[];
+
+UPDATE dim_merchant SET weekend_days = CASE
+    WHEN country IN (
+        'SA', 'Saudi Arabia', '沙特阿拉伯',
+        'QA', 'Qatar', '卡塔尔',
+        'KW', 'Kuwait', '科威特',
+        'BH', 'Bahrain', '巴林',
+        'OM', 'Oman', '阿曼',
+        'JO', 'Jordan', '约旦',
+        'IQ', 'Iraq', '伊拉克',
+        'EG', 'Egypt', '埃及',
+        'IL', 'Israel', '以色列',
+        'BD', 'Bangladesh', '孟加拉国',
+        'DZ', 'Algeria', '阿尔及利亚'
+    ) THEN '{5,6}'::smallint[]
+    WHEN country IN ('IR', 'Iran', '伊朗') THEN '{5}'::smallint[]
+    ELSE '{0,6}'::smallint[]
+END
+WHERE weekend_days IS NULL;
+
+ALTER TABLE dim_merchant
+    ALTER COLUMN weekend_days SET DEFAULT '{0,6}',
+    ALTER COLUMN weekend_days SET NOT NULL;
+
+ALTER TABLE dim_merchant DROP CONSTRAINT IF EXISTS chk_merchant_weekend_days;
+ALTER TABLE dim_merchant ADD CONSTRAINT chk_merchant_weekend_days
+    CHECK (weekend_days <@ '{0,1,2,3,4,5,6}'::smallint[] AND cardinality(weekend_days) < 7);
+
+COMMENT ON COLUMN dim_merchant.weekend_days IS '周末的星期序号（0=周日 … 6=周六），空数组表示没有周末';
+
+-- =====================================================
+-- 分析视图：is_weekend 和 is_business_hour 改为使用商户周末
+-- =====================================================
+
+DROP VIEW IF EXISTS dws_orders_analysis_view;
+
+CREATE VIEW dws_orders_analysis_view AS
+WITH t AS (
+  SELECT
+    o.order_id,
+    o.order_no                         AS order_number,
+    o.order_amount                     AS amount,
+    o.currency,
+    o.order_status                     AS status,
+
+    m.merchant_id,
+    m.merchant_name,
+    m.country,
+    m.city,
+    m.timezone,
+
+    o.order_time_utc,
+    o.payment_time_utc,
+    o.ingested_at,
+
+    (o.order_time_utc   AT TIME ZONE m.timezone) AS order_time_local,
+    (o.payment_time_utc AT TIME ZONE m.timezone) AS payment_time_local,
+
+    (o.order_time_utc AT TIME ZONE m.timezone)::date AS local_date,
+
+    m.business_hours_start,
+    m.business_hours_end,
+    m.weekend_days
+  FROM dws_orders o
+  JOIN dim_merchant m ON m.merchant_id = o.merchant_id
+)
+SELECT
+  t.order_id, t.order_number, t.amount, t.currency, t.status,
+  t.merchant_id, t.merchant_name, t.country, t.city, t.timezone,
+  t.order_time_utc, t.payment_time_utc,
+  t.order_time_local, t.payment_time_local,
+  t.local_date,
+
+  EXTRACT(HOUR FROM t.order_time_local)::int       AS local_hour,
+  EXTRACT(DOW  FROM t.order_time_local)::int       AS local_day_of_week,   -- 0=周日, 1=周一, ...
+  TO_CHAR(t.order_time_local, 'FMDay')             AS local_weekday,
+
+  EXTRACT(DOW FROM t.order_time_local)::smallint = ANY(t.weekend_days) AS is_weekend,
+  -- 非周末且处于商户营业时间内（支持跨午夜营业时间）
+  CASE
+    WHEN EXTRACT(DOW FROM t.order_time_local)::smallint = ANY(t.weekend_days) THEN FALSE
+    WHEN t.business_hours_start < t.business_hours_end
+      THEN t.order_time_local::time >= t.business_hours_start AND t.order_time_local::time < t.business_hours_end
+    ELSE t.order_time_local::time >= t.business_hours_start OR t.order_time_local::time < t.business_hours_end
+  END AS is_business_hour,
+
+  EXTRACT(EPOCH FROM (t.order_time_local - (t.order_time_utc AT TIME ZONE 'UTC')))::int AS timezone_offset,
+
+  t.ingested_at,
+  t.weekend_days
+FROM t;
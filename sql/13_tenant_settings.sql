@@ -0,0 +1,23 @@
+-- =====================================================
+-- 商户（租户）配置
+-- 每个配置键一行，值为 JSON；键和值的类型由 go/services/settings.go 定义和校验
+-- business_hours / weekend_days 同时写入 dim_merchant 对应的列，分析视图直接读取这些列
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS tenant_settings (
+    merchant_id INTEGER NOT NULL REFERENCES dim_merchant(merchant_id) ON DELETE CASCADE,
+    setting_key VARCHAR(100) NOT NULL,
+    setting_value JSONB NOT NULL,
+    updated_by VARCHAR(100) NOT NULL DEFAULT 'system',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (merchant_id, setting_key)
+);
+
+COMMENT ON TABLE tenant_settings IS '商户配置，未出现的键使用默认值';
+COMMENT ON COLUMN tenant_settings.setting_value IS '配置值（JSON），类型由配置键决定';
+
+DROP TRIGGER IF EXISTS update_tenant_settings_updated_at ON tenant_settings;
+CREATE TRIGGER update_tenant_settings_updated_at
+    BEFORE UPDATE ON tenant_settings
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,38 @@
+-- =====================================================
+-- 数据一致性检查
+-- go/services/consistency.go 定期比对 dws_orders 与 dws_orders_analysis_view：
+-- 按商户比较订单数和金额合计，并抽样在 Go 中重新计算本地时间派生字段
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS consistency_check_run (
+    run_id BIGSERIAL PRIMARY KEY,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    finished_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('ok', 'discrepancies', 'failed')),
+    merchants INTEGER NOT NULL DEFAULT 0,
+    sampled_orders INTEGER NOT NULL DEFAULT 0,
+    discrepancy_count INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT ''
+);
+
+COMMENT ON TABLE consistency_check_run IS '一致性检查记录，每次检查一行';
+COMMENT ON COLUMN consistency_check_run.discrepancy_count IS '发现的差异总数，明细最多保存 1000 条';
+
+CREATE INDEX IF NOT EXISTS idx_consistency_check_run_started ON consistency_check_run (started_at DESC);
+
+CREATE TABLE IF NOT EXISTS consistency_discrepancy (
+    discrepancy_id BIGSERIAL PRIMARY KEY,
+    run_id BIGINT NOT NULL REFERENCES consistency_check_run(run_id) ON DELETE CASCADE,
+    kind VARCHAR(50) NOT NULL,
+    merchant_id INTEGER NOT NULL,
+    order_id INTEGER,
+    expected TEXT NOT NULL,
+    actual TEXT NOT NULL
+);
+
+COMMENT ON TABLE consistency_discrepancy IS '一致性检查发现的差异';
+COMMENT ON COLUMN consistency_discrepancy.kind IS '差异类型：row_count、amount_sum 或派生字段名（local_date 等）';
+COMMENT ON COLUMN consistency_discrepancy.expected IS '期望值：dws_orders 的统计或 Go 重新计算的结果';
+COMMENT ON COLUMN consistency_discrepancy.actual IS '实际值：分析视图中的结果';
+
+CREATE INDEX IF NOT EXISTS idx_consistency_discrepancy_run ON consistency_discrepancy (run_id);
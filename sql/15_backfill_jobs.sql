@@ -0,0 +1,32 @@
+-- =====================================================
+-- 本地时间字段回填任务
+-- 营业时间、周末或商户时区修改后，分析视图立即按新规则计算，
+-- 但 ClickHouse 镜像（orders_analysis）中已写入的派生字段不会变化，需要按商户重新镜像
+-- go/services/clickhouse_backfill.go 按 (merchant_id, order_id) 分批执行，游标保存在本表中，中断后可继续
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS backfill_job (
+    job_id BIGSERIAL PRIMARY KEY,
+    merchant_ids INTEGER[] NOT NULL DEFAULT '{}',
+    reason TEXT NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL CHECK (status IN ('running', 'completed', 'failed')),
+    batch_size INTEGER NOT NULL CHECK (batch_size > 0),
+    total_orders BIGINT NOT NULL DEFAULT 0,
+    processed_orders BIGINT NOT NULL DEFAULT 0,
+    cursor_merchant_id INTEGER NOT NULL DEFAULT 0,
+    cursor_order_id INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+COMMENT ON TABLE backfill_job IS '本地时间字段回填任务';
+COMMENT ON COLUMN backfill_job.merchant_ids IS '需要回填的商户，为空表示全部商户';
+COMMENT ON COLUMN backfill_job.total_orders IS '创建任务时统计的订单数，用于计算进度';
+COMMENT ON COLUMN backfill_job.cursor_merchant_id IS '最后一批完成的 (merchant_id, order_id)，继续执行时从其后开始';
+
+DROP TRIGGER IF EXISTS update_backfill_job_updated_at ON backfill_job;
+CREATE TRIGGER update_backfill_job_updated_at
+    BEFORE UPDATE ON backfill_job
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
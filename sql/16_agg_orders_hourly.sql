@@ -0,0 +1,183 @@
+-- =====================================================
+-- 按商户本地小时汇总的订单表
+-- 分析接口的小时分解和时区统计读取本表，不再扫描 dws_orders_analysis_view
+-- dws_orders 的增删改由语句级触发器增量维护；商户时区修改后重建该商户的汇总
+-- 本地日期和小时的算法与分析视图一致，go/repository/postgres_rollup.go 提供比对和重建
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS agg_orders_hourly (
+    merchant_id INTEGER NOT NULL REFERENCES dim_merchant(merchant_id) ON DELETE CASCADE,
+    local_date DATE NOT NULL,
+    local_hour SMALLINT NOT NULL CHECK (local_hour BETWEEN 0 AND 23),
+    currency VARCHAR(3) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    order_count BIGINT NOT NULL DEFAULT 0,
+    amount_sum DECIMAL(18,2) NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (merchant_id, local_date, local_hour, currency, status)
+);
+
+COMMENT ON TABLE agg_orders_hourly IS '按商户、本地日期、本地小时、币种和状态汇总的订单数和金额，由触发器维护';
+COMMENT ON COLUMN agg_orders_hourly.currency IS '币种，订单币种为空时为空字符串';
+COMMENT ON COLUMN agg_orders_hourly.status IS '订单状态，订单状态为空时为空字符串';
+COMMENT ON COLUMN agg_orders_hourly.order_count IS '订单数，订单全部删除或改为其他状态后为 0，查询时忽略';
+
+CREATE INDEX IF NOT EXISTS idx_agg_orders_hourly_date ON agg_orders_hourly (local_date);
+
+-- 把一批订单的增量（新行 +1，旧行 -1）合并进汇总表
+CREATE OR REPLACE FUNCTION agg_orders_hourly_apply()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO agg_orders_hourly AS a (merchant_id, local_date, local_hour, currency, status, order_count, amount_sum)
+        SELECT
+            n.merchant_id,
+            (n.order_time_utc AT TIME ZONE m.timezone)::date,
+            EXTRACT(HOUR FROM n.order_time_utc AT TIME ZONE m.timezone)::smallint,
+            COALESCE(n.currency, ''),
+            COALESCE(n.order_status, ''),
+            COUNT(*),
+            SUM(n.order_amount)
+        FROM new_rows n
+        JOIN dim_merchant m ON m.merchant_id = n.merchant_id
+        GROUP BY 1, 2, 3, 4, 5
+        ON CONFLICT (merchant_id, local_date, local_hour, currency, status) DO UPDATE SET
+            order_count = a.order_count + EXCLUDED.order_count,
+            amount_sum = a.amount_sum + EXCLUDED.amount_sum,
+            updated_at = CURRENT_TIMESTAMP;
+    ELSIF TG_OP = 'DELETE' THEN
+        INSERT INTO agg_orders_hourly AS a (merchant_id, local_date, local_hour, currency, status, order_count, amount_sum)
+        SELECT
+            o.merchant_id,
+            (o.order_time_utc AT TIME ZONE m.timezone)::date,
+            EXTRACT(HOUR FROM o.order_time_utc AT TIME ZONE m.timezone)::smallint,
+            COALESCE(o.currency, ''),
+            COALESCE(o.order_status, ''),
+            -COUNT(*),
+            -SUM(o.order_amount)
+        FROM old_rows o
+        JOIN dim_merchant m ON m.merchant_id = o.merchant_id
+        GROUP BY 1, 2, 3, 4, 5
+        ON CONFLICT (merchant_id, local_date, local_hour, currency, status) DO UPDATE SET
+            order_count = a.order_count + EXCLUDED.order_count,
+            amount_sum = a.amount_sum + EXCLUDED.amount_sum,
+            updated_at = CURRENT_TIMESTAMP;
+    ELSE
+        INSERT INTO agg_orders_hourly AS a (merchant_id, local_date, local_hour, currency, status, order_count, amount_sum)
+        SELECT merchant_id, local_date, local_hour, currency, status, SUM(delta_count), SUM(delta_amount)
+        FROM (
+            SELECT
+                n.merchant_id,
+                (n.order_time_utc AT TIME ZONE m.timezone)::date AS local_date,
+                EXTRACT(HOUR FROM n.order_time_utc AT TIME ZONE m.timezone)::smallint AS local_hour,
+                COALESCE(n.currency, '') AS currency,
+                COALESCE(n.order_status, '') AS status,
+                1 AS delta_count,
+                n.order_amount AS delta_amount
+            FROM new_rows n
+            JOIN dim_merchant m ON m.merchant_id = n.merchant_id
+            UNION ALL
+            SELECT
+                o.merchant_id,
+                (o.order_time_utc AT TIME ZONE m.timezone)::date,
+                EXTRACT(HOUR FROM o.order_time_utc AT TIME ZONE m.timezone)::smallint,
+                COALESCE(o.currency, ''),
+                COALESCE(o.order_status, ''),
+                -1,
+                -o.order_amount
+            FROM old_rows o
+            JOIN dim_merchant m ON m.merchant_id = o.merchant_id
+        ) d
+        GROUP BY merchant_id, local_date, local_hour, currency, status
+        -- 只改了与汇总无关的列（如 customer_email）时增量为 0，不写汇总表
+        HAVING SUM(delta_count) <> 0 OR SUM(delta_amount) <> 0
+        ON CONFLICT (merchant_id, local_date, local_hour, currency, status) DO UPDATE SET
+            order_count = a.order_count + EXCLUDED.order_count,
+            amount_sum = a.amount_sum + EXCLUDED.amount_sum,
+            updated_at = CURRENT_TIMESTAMP;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- 从订单表重新计算汇总，p_merchant_id 为 NULL 时重建全部商户，返回写入的行数
+-- 重建期间锁住汇总表，并发写入订单的事务等待重建完成后再合并增量，不会重复或遗漏
+CREATE OR REPLACE FUNCTION rebuild_agg_orders_hourly(p_merchant_id INTEGER)
+RETURNS BIGINT AS $$
+DECLARE
+    inserted BIGINT;
+BEGIN
+    LOCK TABLE agg_orders_hourly IN SHARE ROW EXCLUSIVE MODE;
+
+    DELETE FROM agg_orders_hourly WHERE p_merchant_id IS NULL OR merchant_id = p_merchant_id;
+
+    INSERT INTO agg_orders_hourly (merchant_id, local_date, local_hour, currency, status, order_count, amount_sum)
+    SELECT
+        o.merchant_id,
+        (o.order_time_utc AT TIME ZONE m.timezone)::date,
+        EXTRACT(HOUR FROM o.order_time_utc AT TIME ZONE m.timezone)::smallint,
+        COALESCE(o.currency, ''),
+        COALESCE(o.order_status, ''),
+        COUNT(*),
+        SUM(o.order_amount)
+    FROM dws_orders o
+    JOIN dim_merchant m ON m.merchant_id = o.merchant_id
+    WHERE p_merchant_id IS NULL OR o.merchant_id = p_merchant_id
+    GROUP BY 1, 2, 3, 4, 5;
+
+    GET DIAGNOSTICS inserted = ROW_COUNT;
+    RETURN inserted;
+END;
+$$ LANGUAGE plpgsql;
+
+-- 商户时区修改后，该商户所有订单的本地日期和小时都会变化
+CREATE OR REPLACE FUNCTION agg_orders_hourly_rebuild_merchant()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM rebuild_agg_orders_hourly(NEW.merchant_id);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- TRUNCATE 不触发行删除，示例数据脚本清空订单后汇总随之清空
+CREATE OR REPLACE FUNCTION agg_orders_hourly_truncate()
+RETURNS TRIGGER AS $$
+BEGIN
+    TRUNCATE TABLE agg_orders_hourly;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- 带过渡表的触发器只能对应一种事件，增删改分别创建
+DROP TRIGGER IF EXISTS agg_orders_hourly_insert ON dws_orders;
+CREATE TRIGGER agg_orders_hourly_insert
+    AFTER INSERT ON dws_orders
+    REFERENCING NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION agg_orders_hourly_apply();
+
+DROP TRIGGER IF EXISTS agg_orders_hourly_update ON dws_orders;
+CREATE TRIGGER agg_orders_hourly_update
+    AFTER UPDATE ON dws_orders
+    REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION agg_orders_hourly_apply();
+
+DROP TRIGGER IF EXISTS agg_orders_hourly_delete ON dws_orders;
+CREATE TRIGGER agg_orders_hourly_delete
+    AFTER DELETE ON dws_orders
+    REFERENCING OLD TABLE AS old_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION agg_orders_hourly_apply();
+
+DROP TRIGGER IF EXISTS agg_orders_hourly_truncate ON dws_orders;
+CREATE TRIGGER agg_orders_hourly_truncate
+    AFTER TRUNCATE ON dws_orders
+    FOR EACH STATEMENT EXECUTE FUNCTION agg_orders_hourly_truncate();
+
+DROP TRIGGER IF EXISTS agg_orders_hourly_timezone ON dim_merchant;
+CREATE TRIGGER agg_orders_hourly_timezone
+    AFTER UPDATE OF timezone ON dim_merchant
+    FOR EACH ROW
+    WHEN (OLD.timezone IS DISTINCT FROM NEW.timezone)
+    EXECUTE FUNCTION agg_orders_hourly_rebuild_merchant();
+
+-- 已有订单的初始汇总
+SELECT rebuild_agg_orders_hourly(NULL);
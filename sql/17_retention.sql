@@ -0,0 +1,106 @@
+-- =====================================================
+-- 订单保留与归档
+-- 商户配置 retention_policy 后，早于 N 个本地自然月的订单按月归档：
+-- 移入冷表 dws_orders_archive（target = table）或写成文件后删除（target = object）
+-- 归档订单的小时汇总移入 agg_orders_hourly_archived，分析接口通过 agg_orders_hourly_all 仍能查到
+-- go/services/retention.go 执行归档，每个（商户，月份）一个事务
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS retention_policy (
+    merchant_id INTEGER PRIMARY KEY REFERENCES dim_merchant(merchant_id) ON DELETE CASCADE,
+    archive_after_months INTEGER NOT NULL CHECK (archive_after_months BETWEEN 1 AND 120),
+    target VARCHAR(20) NOT NULL CHECK (target IN ('table', 'object')),
+    updated_by VARCHAR(100) NOT NULL DEFAULT 'system',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+COMMENT ON TABLE retention_policy IS '商户的订单保留策略，没有策略的商户不归档';
+COMMENT ON COLUMN retention_policy.archive_after_months IS '保留最近几个本地自然月（含当月）的订单，更早的按月归档';
+COMMENT ON COLUMN retention_policy.target IS '归档位置：table 冷表 dws_orders_archive，object 归档目录中的文件';
+
+DROP TRIGGER IF EXISTS update_retention_policy_updated_at ON retention_policy;
+CREATE TRIGGER update_retention_policy_updated_at
+    BEFORE UPDATE ON retention_policy
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE IF NOT EXISTS retention_run (
+    run_id BIGSERIAL PRIMARY KEY,
+    triggered_by VARCHAR(20) NOT NULL CHECK (triggered_by IN ('schedule', 'manual')),
+    status VARCHAR(20) NOT NULL CHECK (status IN ('running', 'completed', 'failed')),
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    merchants INTEGER NOT NULL DEFAULT 0,
+    archived_orders BIGINT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT ''
+);
+
+COMMENT ON TABLE retention_run IS '归档执行记录，每次执行一行';
+
+CREATE INDEX IF NOT EXISTS idx_retention_run_started ON retention_run (started_at DESC);
+
+CREATE TABLE IF NOT EXISTS retention_bucket (
+    run_id BIGINT NOT NULL REFERENCES retention_run(run_id) ON DELETE CASCADE,
+    merchant_id INTEGER NOT NULL,
+    month DATE NOT NULL,
+    target VARCHAR(20) NOT NULL,
+    orders BIGINT NOT NULL,
+    amount DECIMAL(18,2) NOT NULL,
+    location TEXT NOT NULL DEFAULT '',
+    archived_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (run_id, merchant_id, month)
+);
+
+COMMENT ON TABLE retention_bucket IS '一次归档中每个（商户，本地月份）归档的订单数和金额';
+COMMENT ON COLUMN retention_bucket.location IS 'target = object 时为归档文件的位置';
+
+CREATE INDEX IF NOT EXISTS idx_retention_bucket_merchant ON retention_bucket (merchant_id, month);
+
+-- 冷表：与 dws_orders 的列相同，不参与分析视图
+CREATE TABLE IF NOT EXISTS dws_orders_archive (
+    order_id INTEGER PRIMARY KEY,
+    order_no VARCHAR(50) NOT NULL,
+    merchant_id INTEGER NOT NULL,
+    order_amount DECIMAL(15,2) NOT NULL,
+    currency VARCHAR(3),
+    order_status VARCHAR(20),
+    order_time_utc TIMESTAMP WITH TIME ZONE NOT NULL,
+    payment_time_utc TIMESTAMP WITH TIME ZONE,
+    customer_id VARCHAR(50),
+    customer_email VARCHAR(100),
+    order_source VARCHAR(50),
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    ingested_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    archive_run_id BIGINT NOT NULL,
+    archived_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+COMMENT ON TABLE dws_orders_archive IS '已归档的订单（冷数据），列与 dws_orders 相同';
+
+CREATE INDEX IF NOT EXISTS idx_orders_archive_merchant_time ON dws_orders_archive (merchant_id, order_time_utc);
+
+-- 已归档订单的小时汇总，归档后不再变化，重建 agg_orders_hourly 时不受影响
+CREATE TABLE IF NOT EXISTS agg_orders_hourly_archived (
+    merchant_id INTEGER NOT NULL REFERENCES dim_merchant(merchant_id) ON DELETE CASCADE,
+    local_date DATE NOT NULL,
+    local_hour SMALLINT NOT NULL CHECK (local_hour BETWEEN 0 AND 23),
+    currency VARCHAR(3) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    order_count BIGINT NOT NULL DEFAULT 0,
+    amount_sum DECIMAL(18,2) NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (merchant_id, local_date, local_hour, currency, status)
+);
+
+COMMENT ON TABLE agg_orders_hourly_archived IS '已归档订单的小时汇总，按归档时商户的时区计算本地日期和小时';
+
+CREATE INDEX IF NOT EXISTS idx_agg_orders_hourly_archived_date ON agg_orders_hourly_archived (local_date);
+
+-- 分析接口读取的全部汇总：在线订单和已归档订单
+CREATE OR REPLACE VIEW agg_orders_hourly_all AS
+SELECT merchant_id, local_date, local_hour, currency, status, order_count, amount_sum
+FROM agg_orders_hourly
+UNION ALL
+SELECT merchant_id, local_date, local_hour, currency, status, order_count, amount_sum
+FROM agg_orders_hourly_archived;
@@ -0,0 +1,39 @@
+-- =====================================================
+-- 管理接口的只读 SQL 控制台
+-- /api/admin/query 在只读事务中 SET LOCAL ROLE saasview_console 后执行查询，
+-- 该角色只能读取分析视图；每次执行（包括被拒绝的语句）都记录在 admin_query_audit
+-- =====================================================
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'saasview_console') THEN
+        CREATE ROLE saasview_console NOLOGIN;
+    END IF;
+END
+$$;
+
+COMMENT ON ROLE saasview_console IS '只读 SQL 控制台使用的角色，只能查询 dws_orders_analysis_view';
+
+-- 应用的登录用户需要是该角色的成员才能 SET ROLE
+GRANT saasview_console TO CURRENT_USER;
+GRANT USAGE ON SCHEMA public TO saasview_console;
+-- 视图按所有者的权限读取底层表，角色本身不需要 dws_orders、dim_merchant 的权限
+GRANT SELECT ON dws_orders_analysis_view TO saasview_console;
+
+CREATE TABLE IF NOT EXISTS admin_query_audit (
+    audit_id BIGSERIAL PRIMARY KEY,
+    operator VARCHAR(100) NOT NULL,
+    query_text TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('running', 'ok', 'rejected', 'failed')),
+    row_count INTEGER NOT NULL DEFAULT 0,
+    truncated BOOLEAN NOT NULL DEFAULT FALSE,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    client_addr VARCHAR(100) NOT NULL DEFAULT '',
+    executed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+COMMENT ON TABLE admin_query_audit IS 'SQL 控制台的审计记录：谁在什么时候执行了什么语句';
+COMMENT ON COLUMN admin_query_audit.status IS 'running 执行中（进程在执行期间退出时保留此状态），ok 成功，rejected 未通过检查，failed 执行出错';
+
+CREATE INDEX IF NOT EXISTS idx_admin_query_audit_executed ON admin_query_audit (executed_at DESC);
@@ -0,0 +1,250 @@
+-- =====================================================
+-- 国家和城市参考数据
+-- dim_country / dim_city 与 go/geo/countries.csv、go/geo/cities.csv 一致，
+-- dim_city.city_id 为 cities.csv 中的数据行号，数据集只追加，已有城市的 ID 不变。
+-- 商户增加 country_code、city_id 外键，country / city 统一为参考数据中的中文名，
+-- 避免"中国"、"China"、"CN"这类不同写法在按国家统计时被分成多组
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS dim_country (
+    country_code CHAR(2) PRIMARY KEY,
+    name_en VARCHAR(100) NOT NULL,
+    name_zh VARCHAR(50) NOT NULL,
+    default_timezone VARCHAR(50) NOT NULL,
+    default_currency CHAR(3) NOT NULL,
+    -- 周末的星期序号（0=周日 … 6=周六），与 dim_merchant.weekend_days 一致
+    weekend_days SMALLINT[] NOT NULL DEFAULT '{0,6}',
+    CONSTRAINT chk_country_weekend_days
+        CHECK (weekend_days <@ '{0,1,2,3,4,5,6}'::smallint[] AND cardinality(weekend_days) < 7)
+);
+
+COMMENT ON TABLE dim_country IS '国家参考数据：ISO 3166-1 alpha-2 代码、默认时区、默认币种和周末';
+
+CREATE TABLE IF NOT EXISTS dim_city (
+    city_id INTEGER PRIMARY KEY,
+    country_code CHAR(2) NOT NULL REFERENCES dim_country(country_code),
+    name_en VARCHAR(100) NOT NULL,
+    name_zh VARCHAR(50) NOT NULL,
+    timezone VARCHAR(50) NOT NULL,
+    lat DOUBLE PRECISION NOT NULL,
+    lon DOUBLE PRECISION NOT NULL,
+    UNIQUE (country_code, name_en)
+);
+
+CREATE INDEX IF NOT EXISTS idx_city_country ON dim_city(country_code);
+
+COMMENT ON TABLE dim_city IS '城市参考数据，city_id 与 go/geo/cities.csv 的数据行号一致';
+
+INSERT INTO dim_country (country_code, name_en, name_zh, default_timezone, default_currency, weekend_days) VALUES
+('AE', 'United Arab Emirates', '阿联酋', 'Asia/Dubai', 'AED', '{0,6}'),
+('AR', 'Argentina', '阿根廷', 'America/Argentina/Buenos_Aires', 'ARS', '{0,6}'),
+('AT', 'Austria', '奥地利', 'Europe/Vienna', 'EUR', '{0,6}'),
+('AU', 'Australia', '澳大利亚', 'Australia/Sydney', 'AUD', '{0,6}'),
+('BD', 'Bangladesh', '孟加拉国', 'Asia/Dhaka', 'BDT', '{5,6}'),
+('BE', 'Belgium', '比利时', 'Europe/Brussels', 'EUR', '{0,6}'),
+('BH', 'Bahrain', '巴林', 'Asia/Bahrain', 'BHD', '{5,6}'),
+('BR', 'Brazil', '巴西', 'America/Sao_Paulo', 'BRL', '{0,6}'),
+('CA', 'Canada', '加拿大', 'America/Toronto', 'CAD', '{0,6}'),
+('CH', 'Switzerland', '瑞士', 'Europe/Zurich', 'CHF', '{0,6}'),
+('CL', 'Chile', '智利', 'America/Santiago', 'CLP', '{0,6}'),
+('CN', 'China', '中国', 'Asia/Shanghai', 'CNY', '{0,6}'),
+('CO', 'Colombia', '哥伦比亚', 'America/Bogota', 'COP', '{0,6}'),
+('CZ', 'Czech Republic', '捷克', 'Europe/Prague', 'CZK', '{0,6}'),
+('DE', 'Germany', '德国', 'Europe/Berlin', 'EUR', '{0,6}'),
+('DK', 'Denmark', '丹麦', 'Europe/Copenhagen', 'DKK', '{0,6}'),
+('DZ', 'Algeria', '阿尔及利亚', 'Africa/Algiers', 'DZD', '{5,6}'),
+('EG', 'Egypt', '埃及', 'Africa/Cairo', 'EGP', '{5,6}'),
+('ES', 'Spain', '西班牙', 'Europe/Madrid', 'EUR', '{0,6}'),
+('FI', 'Finland', '芬兰', 'Europe/Helsinki', 'EUR', '{0,6}'),
+('FR', 'France', '法国', 'Europe/Paris', 'EUR', '{0,6}'),
+('GB', 'United Kingdom', '英国', 'Europe/London', 'GBP', '{0,6}'),
+('GR', 'Greece', '希腊', 'Europe/Athens', 'EUR', '{0,6}'),
+('HK', 'Hong Kong', '香港', 'Asia/Hong_Kong', 'HKD', '{0,6}'),
+('ID', 'Indonesia', '印度尼西亚', 'Asia/Jakarta', 'IDR', '{0,6}'),
+('IE', 'Ireland', '爱尔兰', 'Europe/Dublin', 'EUR', '{0,6}'),
+('IL', 'Israel', '以色列', 'Asia/Jerusalem', 'ILS', '{5,6}'),
+('IN', 'India', '印度', 'Asia/Kolkata', 'INR', '{0,6}'),
+('IQ', 'Iraq', '伊拉克', 'Asia/Baghdad', 'IQD', '{5,6}'),
+('IR', 'Iran', '伊朗', 'Asia/Tehran', 'IRR', '{5}'),
+('IT', 'Italy', '意大利', 'Europe/Rome', 'EUR', '{0,6}'),
+('JO', 'Jordan', '约旦', 'Asia/Amman', 'JOD', '{5,6}'),
+('JP', 'Japan', '日本', 'Asia/Tokyo', 'JPY', '{0,6}'),
+('KE', 'Kenya', '肯尼亚', 'Africa/Nairobi', 'KES', '{0,6}'),
+('KR', 'South Korea', '韩国', 'Asia/Seoul', 'KRW', '{0,6}'),
+('KW', 'Kuwait', '科威特', 'Asia/Kuwait', 'KWD', '{5,6}'),
+('MA', 'Morocco', '摩洛哥', 'Africa/Casablanca', 'MAD', '{0,6}'),
+('MX', 'Mexico', '墨西哥', 'America/Mexico_City', 'MXN', '{0,6}'),
+('MY', 'Malaysia', '马来西亚', 'Asia/Kuala_Lumpur', 'MYR', '{0,6}'),
+('NG', 'Nigeria', '尼日利亚', 'Africa/Lagos', 'NGN', '{0,6}'),
+('NL', 'Netherlands', '荷兰', 'Europe/Amsterdam', 'EUR', '{0,6}'),
+('NO', 'Norway', '挪威', 'Europe/Oslo', 'NOK', '{0,6}'),
+('NP', 'Nepal', '尼泊尔', 'Asia/Kathmandu', 'NPR', '{0,6}'),
+('NZ', 'New Zealand', '新西兰', 'Pacific/Auckland', 'NZD', '{0,6}'),
+('OM', 'Oman', '阿曼', 'Asia/Muscat', 'OMR', '{5,6}'),
+('PE', 'Peru', '秘鲁', 'America/Lima', 'PEN', '{0,6}'),
+('PH', 'Philippines', '菲律宾', 'Asia/Manila', 'PHP', '{0,6}'),
+('PK', 'Pakistan', '巴基斯坦', 'Asia/Karachi', 'PKR', '{0,6}'),
+('PL', 'Poland', '波兰', 'Europe/Warsaw', 'PLN', '{0,6}'),
+('PT', 'Portugal', '葡萄牙', 'Europe/Lisbon', 'EUR', '{0,6}'),
+('QA', 'Qatar', '卡塔尔', 'Asia/Qatar', 'QAR', '{5,6}'),
+('RU', 'Russia', '俄罗斯', 'Europe/Moscow', 'RUB', '{0,6}'),
+('SA', 'Saudi Arabia', '沙特阿拉伯', 'Asia/Riyadh', 'SAR', '{5,6}'),
+('SE', 'Sweden', '瑞典', 'Europe/Stockholm', 'SEK', '{0,6}'),
+('SG', 'Singapore', '新加坡', 'Asia/Singapore', 'SGD', '{0,6}'),
+('TH', 'Thailand', '泰国', 'Asia/Bangkok', 'THB', '{0,6}'),
+('TR', 'Turkey', '土耳其', 'Europe/Istanbul', 'TRY', '{0,6}'),
+('TW', 'Taiwan', '台湾', 'Asia/Taipei', 'TWD', '{0,6}'),
+('UA', 'Ukraine', '乌克兰', 'Europe/Kyiv', 'UAH', '{0,6}'),
+('US', 'United States', '美国', 'America/New_York', 'USD', '{0,6}'),
+('VN', 'Vietnam', '越南', 'Asia/Ho_Chi_Minh', 'VND', '{0,6}'),
+('ZA', 'South Africa', '南非', 'Africa/Johannesburg', 'ZAR', '{0,6}')
+ON CONFLICT (country_code) DO UPDATE SET
+    name_en = EXCLUDED.name_en,
+    name_zh = EXCLUDED.name_zh,
+    default_timezone = EXCLUDED.default_timezone,
+    default_currency = EXCLUDED.default_currency,
+    weekend_days = EXCLUDED.weekend_days;
+
+INSERT INTO dim_city (city_id, country_code, name_en, name_zh, timezone, lat, lon) VALUES
+(1, 'CN', 'Beijing', '北京', 'Asia/Shanghai', 39.9042, 116.4074),
+(2, 'CN', 'Shanghai', '上海', 'Asia/Shanghai', 31.2304, 121.4737),
+(3, 'CN', 'Guangzhou', '广州', 'Asia/Shanghai', 23.1291, 113.2644),
+(4, 'CN', 'Shenzhen', '深圳', 'Asia/Shanghai', 22.5431, 114.0579),
+(5, 'CN', 'Hangzhou', '杭州', 'Asia/Shanghai', 30.2741, 120.1551),
+(6, 'CN', 'Chengdu', '成都', 'Asia/Shanghai', 30.5728, 104.0668),
+(7, 'CN', 'Urumqi', '乌鲁木齐', 'Asia/Urumqi', 43.8256, 87.6168),
+(8, 'HK', 'Hong Kong', '香港', 'Asia/Hong_Kong', 22.3193, 114.1694),
+(9, 'TW', 'Taipei', '台北', 'Asia/Taipei', 25.0330, 121.5654),
+(10, 'JP', 'Tokyo', '东京', 'Asia/Tokyo', 35.6762, 139.6503),
+(11, 'JP', 'Osaka', '大阪', 'Asia/Tokyo', 34.6937, 135.5023),
+(12, 'KR', 'Seoul', '首尔', 'Asia/Seoul', 37.5665, 126.9780),
+(13, 'SG', 'Singapore', '新加坡', 'Asia/Singapore', 1.3521, 103.8198),
+(14, 'MY', 'Kuala Lumpur', '吉隆坡', 'Asia/Kuala_Lumpur', 3.1390, 101.6869),
+(15, 'TH', 'Bangkok', '曼谷', 'Asia/Bangkok', 13.7563, 100.5018),
+(16, 'VN', 'Ho Chi Minh City', '胡志明市', 'Asia/Ho_Chi_Minh', 10.8231, 106.6297),
+(17, 'VN', 'Hanoi', '河内', 'Asia/Bangkok', 21.0278, 105.8342),
+(18, 'ID', 'Jakarta', '雅加达', 'Asia/Jakarta', -6.2088, 106.8456),
+(19, 'ID', 'Denpasar', '登巴萨', 'Asia/Makassar', -8.6705, 115.2126),
+(20, 'PH', 'Manila', '马尼拉', 'Asia/Manila', 14.5995, 120.9842),
+(21, 'IN', 'Mumbai', '孟买', 'Asia/Kolkata', 19.0760, 72.8777),
+(22, 'IN', 'New Delhi', '新德里', 'Asia/Kolkata', 28.6139, 77.2090),
+(23, 'IN', 'Bangalore', '班加罗尔', 'Asia/Kolkata', 12.9716, 77.5946),
+(24, 'NP', 'Kathmandu', '加德满都', 'Asia/Kathmandu', 27.7172, 85.3240),
+(25, 'PK', 'Karachi', '卡拉奇', 'Asia/Karachi', 24.8607, 67.0011),
+(26, 'AE', 'Dubai', '迪拜', 'Asia/Dubai', 25.2048, 55.2708),
+(27, 'SA', 'Riyadh', '利雅得', 'Asia/Riyadh', 24.7136, 46.6753),
+(28, 'IL', 'Tel Aviv', '特拉维夫', 'Asia/Jerusalem', 32.0853, 34.7818),
+(29, 'IR', 'Tehran', '德黑兰', 'Asia/Tehran', 35.6892, 51.3890),
+(30, 'TR', 'Istanbul', '伊斯坦布尔', 'Europe/Istanbul', 41.0082, 28.9784),
+(31, 'RU', 'Moscow', '莫斯科', 'Europe/Moscow', 55.7558, 37.6173),
+(32, 'RU', 'Saint Petersburg', '圣彼得堡', 'Europe/Moscow', 59.9311, 30.3609),
+(33, 'RU', 'Novosibirsk', '新西伯利亚', 'Asia/Novosibirsk', 55.0084, 82.9357),
+(34, 'RU', 'Vladivostok', '符拉迪沃斯托克', 'Asia/Vladivostok', 43.1198, 131.8869),
+(35, 'GB', 'London', '伦敦', 'Europe/London', 51.5074, -0.1278),
+(36, 'GB', 'Manchester', '曼彻斯特', 'Europe/London', 53.4808, -2.2426),
+(37, 'IE', 'Dublin', '都柏林', 'Europe/Dublin', 53.3498, -6.2603),
+(38, 'FR', 'Paris', '巴黎', 'Europe/Paris', 48.8566, 2.3522),
+(39, 'FR', 'Lyon', '里昂', 'Europe/Paris', 45.7640, 4.8357),
+(40, 'DE', 'Berlin', '柏林', 'Europe/Berlin', 52.5200, 13.4050),
+(41, 'DE', 'Munich', '慕尼黑', 'Europe/Berlin', 48.1351, 11.5820),
+(42, 'DE', 'Frankfurt', '法兰克福', 'Europe/Berlin', 50.1109, 8.6821),
+(43, 'NL', 'Amsterdam', '阿姆斯特丹', 'Europe/Amsterdam', 52.3676, 4.9041),
+(44, 'BE', 'Brussels', '布鲁塞尔', 'Europe/Brussels', 50.8503, 4.3517),
+(45, 'CH', 'Zurich', '苏黎世', 'Europe/Zurich', 47.3769, 8.5417),
+(46, 'AT', 'Vienna', '维也纳', 'Europe/Vienna', 48.2082, 16.3738),
+(47, 'IT', 'Rome', '罗马', 'Europe/Rome', 41.9028, 12.4964),
+(48, 'IT', 'Milan', '米兰', 'Europe/Rome', 45.4642, 9.1900),
+(49, 'ES', 'Madrid', '马德里', 'Europe/Madrid', 40.4168, -3.7038),
+(50, 'ES', 'Barcelona', '巴塞罗那', 'Europe/Madrid', 41.3851, 2.1734),
+(51, 'ES', 'Las Palmas', '拉斯帕尔马斯', 'Atlantic/Canary', 28.1235, -15.4363),
+(52, 'PT', 'Lisbon', '里斯本', 'Europe/Lisbon', 38.7223, -9.1393),
+(53, 'SE', 'Stockholm', '斯德哥尔摩', 'Europe/Stockholm', 59.3293, 18.0686),
+(54, 'NO', 'Oslo', '奥斯陆', 'Europe/Oslo', 59.9139, 10.7522),
+(55, 'DK', 'Copenhagen', '哥本哈根', 'Europe/Copenhagen', 55.6761, 12.5683),
+(56, 'FI', 'Helsinki', '赫尔辛基', 'Europe/Helsinki', 60.1699, 24.9384),
+(57, 'PL', 'Warsaw', '华沙', 'Europe/Warsaw', 52.2297, 21.0122),
+(58, 'CZ', 'Prague', '布拉格', 'Europe/Prague', 50.0755, 14.4378),
+(59, 'GR', 'Athens', '雅典', 'Europe/Athens', 37.9838, 23.7275),
+(60, 'UA', 'Kyiv', '基辅', 'Europe/Kyiv', 50.4501, 30.5234),
+(61, 'EG', 'Cairo', '开罗', 'Africa/Cairo', 30.0444, 31.2357),
+(62, 'ZA', 'Johannesburg', '约翰内斯堡', 'Africa/Johannesburg', -26.2041, 28.0473),
+(63, 'ZA', 'Cape Town', '开普敦', 'Africa/Johannesburg', -33.9249, 18.4241),
+(64, 'NG', 'Lagos', '拉各斯', 'Africa/Lagos', 6.5244, 3.3792),
+(65, 'KE', 'Nairobi', '内罗毕', 'Africa/Nairobi', -1.2921, 36.8219),
+(66, 'MA', 'Casablanca', '卡萨布兰卡', 'Africa/Casablanca', 33.5731, -7.5898),
+(67, 'US', 'New York', '纽约', 'America/New_York', 40.7128, -74.0060),
+(68, 'US', 'Boston', '波士顿', 'America/New_York', 42.3601, -71.0589),
+(69, 'US', 'Miami', '迈阿密', 'America/New_York', 25.7617, -80.1918),
+(70, 'US', 'Atlanta', '亚特兰大', 'America/New_York', 33.7490, -84.3880),
+(71, 'US', 'Detroit', '底特律', 'America/Detroit', 42.3314, -83.0458),
+(72, 'US', 'Chicago', '芝加哥', 'America/Chicago', 41.8781, -87.6298),
+(73, 'US', 'Houston', '休斯顿', 'America/Chicago', 29.7604, -95.3698),
+(74, 'US', 'Dallas', '达拉斯', 'America/Chicago', 32.7767, -96.7970),
+(75, 'US', 'Denver', '丹佛', 'America/Denver', 39.7392, -104.9903),
+(76, 'US', 'Phoenix', '凤凰城', 'America/Phoenix', 33.4484, -112.0740),
+(77, 'US', 'Los Angeles', '洛杉矶', 'America/Los_Angeles', 34.0522, -118.2437),
+(78, 'US', 'San Francisco', '旧金山', 'America/Los_Angeles', 37.7749, -122.4194),
+(79, 'US', 'Seattle', '西雅图', 'America/Los_Angeles', 47.6062, -122.3321),
+(80, 'US', 'Anchorage', '安克雷奇', 'America/Anchorage', 61.2181, -149.9003),
+(81, 'US', 'Honolulu', '檀香山', 'Pacific/Honolulu', 21.3069, -157.8583),
+(82, 'CA', 'Toronto', '多伦多', 'America/Toronto', 43.6532, -79.3832),
+(83, 'CA', 'Montreal', '蒙特利尔', 'America/Toronto', 45.5017, -73.5673),
+(84, 'CA', 'Vancouver', '温哥华', 'America/Vancouver', 49.2827, -123.1207),
+(85, 'CA', 'Calgary', '卡尔加里', 'America/Edmonton', 51.0447, -114.0719),
+(86, 'CA', 'Halifax', '哈利法克斯', 'America/Halifax', 44.6488, -63.5752),
+(87, 'CA', 'St. John''s', '圣约翰斯', 'America/St_Johns', 47.5615, -52.7126),
+(88, 'MX', 'Mexico City', '墨西哥城', 'America/Mexico_City', 19.4326, -99.1332),
+(89, 'MX', 'Tijuana', '蒂华纳', 'America/Tijuana', 32.5149, -117.0382),
+(90, 'BR', 'Sao Paulo', '圣保罗', 'America/Sao_Paulo', -23.5505, -46.6333),
+(91, 'BR', 'Rio de Janeiro', '里约热内卢', 'America/Sao_Paulo', -22.9068, -43.1729),
+(92, 'BR', 'Manaus', '马瑙斯', 'America/Manaus', -3.1190, -60.0217),
+(93, 'AR', 'Buenos Aires', '布宜诺斯艾利斯', 'America/Argentina/Buenos_Aires', -34.6037, -58.3816),
+(94, 'CL', 'Santiago', '圣地亚哥', 'America/Santiago', -33.4489, -70.6693),
+(95, 'CO', 'Bogota', '波哥大', 'America/Bogota', 4.7110, -74.0721),
+(96, 'PE', 'Lima', '利马', 'America/Lima', -12.0464, -77.0428),
+(97, 'AU', 'Sydney', '悉尼', 'Australia/Sydney', -33.8688, 151.2093),
+(98, 'AU', 'Melbourne', '墨尔本', 'Australia/Melbourne', -37.8136, 144.9631),
+(99, 'AU', 'Brisbane', '布里斯班', 'Australia/Brisbane', -27.4698, 153.0251),
+(100, 'AU', 'Adelaide', '阿德莱德', 'Australia/Adelaide', -34.9285, 138.6007),
+(101, 'AU', 'Perth', '珀斯', 'Australia/Perth', -31.9505, 115.8605),
+(102, 'AU', 'Darwin', '达尔文', 'Australia/Darwin', -12.4634, 130.8456),
+(103, 'NZ', 'Auckland', '奥克兰', 'Pacific/Auckland', -36.8485, 174.7633),
+(104, 'NZ', 'Wellington', '惠灵顿', 'Pacific/Auckland', -41.2865, 174.7762)
+ON CONFLICT (city_id) DO UPDATE SET
+    country_code = EXCLUDED.country_code,
+    name_en = EXCLUDED.name_en,
+    name_zh = EXCLUDED.name_zh,
+    timezone = EXCLUDED.timezone,
+    lat = EXCLUDED.lat,
+    lon = EXCLUDED.lon;
+
+-- =====================================================
+-- 商户关联参考数据
+-- 不在参考数据中的国家和城市保留原来的文本，country_code / city_id 为 NULL
+-- =====================================================
+
+ALTER TABLE dim_merchant
+    ADD COLUMN IF NOT EXISTS country_code CHAR(2) REFERENCES dim_country(country_code),
+    ADD COLUMN IF NOT EXISTS city_id INTEGER REFERENCES dim_city(city_id);
+
+CREATE INDEX IF NOT EXISTS idx_merchant_country_code ON dim_merchant(country_code);
+
+-- 国家按代码、英文名或中文名匹配，不区分大小写
+UPDATE dim_merchant m SET
+    country_code = c.country_code,
+    country = c.name_zh
+FROM dim_country c
+WHERE m.country_code IS NULL
+  AND lower(trim(m.country)) IN (lower(c.country_code), lower(c.name_en), lower(c.name_zh));
+
+-- 城市在所属国家内按英文名或中文名匹配
+UPDATE dim_merchant m SET
+    city_id = ci.city_id,
+    city = ci.name_zh
+FROM dim_city ci
+WHERE m.city_id IS NULL
+  AND ci.country_code = m.country_code
+  AND lower(trim(m.city)) IN (lower(ci.name_en), lower(ci.name_zh));
+
+COMMENT ON COLUMN dim_merchant.country_code IS '国家代码，关联 dim_country；不在参考数据中的国家为 NULL';
+COMMENT ON COLUMN dim_merchant.city_id IS '城市，关联 dim_city；不在参考数据中的城市为 NULL';
@@ -0,0 +1,38 @@
+-- =====================================================
+-- 组织（连锁品牌、集团）及其门店
+-- 一个组织拥有多个不同时区的商户门店，每个商户最多属于一个组织；
+-- 组织令牌只能访问所属组织的 /api/orgs/{id} 接口，库中只保存令牌的 SHA-256 摘要
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS organization (
+    organization_id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    -- 总部时区：按总部口径统计时用于划分日期和小时
+    hq_timezone VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+COMMENT ON TABLE organization IS '组织，拥有多个商户门店';
+
+ALTER TABLE dim_merchant
+    ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organization(organization_id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_merchant_organization ON dim_merchant(organization_id);
+
+COMMENT ON COLUMN dim_merchant.organization_id IS '所属组织，独立商户为 NULL';
+
+CREATE TABLE IF NOT EXISTS organization_token (
+    token_id SERIAL PRIMARY KEY,
+    organization_id INTEGER NOT NULL REFERENCES organization(organization_id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    -- 令牌明文只在创建时返回一次
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_organization_token_org ON organization_token(organization_id);
+
+COMMENT ON TABLE organization_token IS '组织令牌，只能访问所属组织的接口';
@@ -0,0 +1,59 @@
+-- =====================================================
+-- 保存的报表定义及执行记录
+-- 报表定义保存分析接口的参数（订单状态、币种）、日期范围类型、时区口径和输出格式，
+-- 可以手动执行，也可以按商户本地时间的重复规则定时执行；每次执行的结果文件保存在 report_run 中
+-- go/services/report.go 负责计算日期范围、生成结果和调度
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS report_definition (
+    definition_id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    -- 时区口径为 merchant 时按该商户的时区计算日期范围和执行时间
+    merchant_id INTEGER REFERENCES dim_merchant(merchant_id) ON DELETE CASCADE,
+    timezone_mode VARCHAR(20) NOT NULL CHECK (timezone_mode IN ('merchant', 'utc')),
+    range_type VARCHAR(20) NOT NULL,
+    -- range_type = custom 时的本地日期范围，包含两端
+    from_date DATE,
+    to_date DATE,
+    statuses TEXT[] NOT NULL DEFAULT '{}',
+    currency VARCHAR(3) NOT NULL DEFAULT '',
+    format VARCHAR(10) NOT NULL CHECK (format IN ('json', 'csv')),
+    -- 类 RRULE 的重复规则，如 FREQ=DAILY;BYHOUR=8，为空时只能手动执行
+    schedule TEXT NOT NULL DEFAULT '',
+    next_run_at TIMESTAMP WITH TIME ZONE,
+    created_by VARCHAR(100) NOT NULL DEFAULT 'system',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (timezone_mode <> 'merchant' OR merchant_id IS NOT NULL)
+);
+
+COMMENT ON TABLE report_definition IS '保存的报表定义';
+COMMENT ON COLUMN report_definition.next_run_at IS '下一次定时执行的 UTC 时刻，没有重复规则时为 NULL';
+
+CREATE INDEX IF NOT EXISTS idx_report_definition_next_run ON report_definition (next_run_at) WHERE next_run_at IS NOT NULL;
+
+DROP TRIGGER IF EXISTS update_report_definition_updated_at ON report_definition;
+CREATE TRIGGER update_report_definition_updated_at
+    BEFORE UPDATE ON report_definition
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE IF NOT EXISTS report_run (
+    run_id BIGSERIAL PRIMARY KEY,
+    definition_id INTEGER NOT NULL REFERENCES report_definition(definition_id) ON DELETE CASCADE,
+    triggered_by VARCHAR(20) NOT NULL CHECK (triggered_by IN ('schedule', 'manual')),
+    status VARCHAR(20) NOT NULL CHECK (status IN ('running', 'completed', 'failed')),
+    -- 本次执行统计的本地日期范围及计算范围使用的时区
+    from_date DATE NOT NULL,
+    to_date DATE NOT NULL,
+    timezone VARCHAR(50) NOT NULL,
+    format VARCHAR(10) NOT NULL,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    artifact BYTEA,
+    artifact_size BIGINT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT ''
+);
+
+COMMENT ON TABLE report_run IS '报表执行记录，artifact 为生成的结果文件';
+
+CREATE INDEX IF NOT EXISTS idx_report_run_definition ON report_run (definition_id, started_at DESC);
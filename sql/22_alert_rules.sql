@@ -0,0 +1,63 @@
+-- =====================================================
+-- 指标告警规则及告警历史
+-- 租户（X-Tenant-ID）为商户定义告警规则：
+--   no_orders     连续 window_hours 个本地营业小时没有订单
+--   revenue_drop  当天截至当前的营收低于上周同一天同一本地时刻的 threshold_percent%
+-- 规则由后台任务定期检查，状态在 ok / firing 之间变化时发送 Webhook 和邮件通知，并写入 alert_event
+-- go/services/alert_rule.go 负责检查和通知
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS alert_rule (
+    rule_id SERIAL PRIMARY KEY,
+    tenant VARCHAR(100) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    merchant_id INTEGER NOT NULL REFERENCES dim_merchant(merchant_id) ON DELETE CASCADE,
+    rule_type VARCHAR(20) NOT NULL CHECK (rule_type IN ('no_orders', 'revenue_drop')),
+    window_hours INTEGER NOT NULL DEFAULT 0,
+    threshold_percent INTEGER NOT NULL DEFAULT 0,
+    currency VARCHAR(3) NOT NULL DEFAULT '',
+    statuses TEXT[] NOT NULL DEFAULT '{}',
+    webhook_url TEXT NOT NULL DEFAULT '',
+    emails TEXT[] NOT NULL DEFAULT '{}',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    state VARCHAR(10) NOT NULL DEFAULT 'ok' CHECK (state IN ('ok', 'firing')),
+    last_evaluated_at TIMESTAMP WITH TIME ZONE,
+    created_by VARCHAR(100) NOT NULL DEFAULT 'system',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (tenant, name),
+    CHECK (rule_type <> 'no_orders' OR window_hours > 0),
+    CHECK (rule_type <> 'revenue_drop' OR threshold_percent BETWEEN 1 AND 100)
+);
+
+COMMENT ON TABLE alert_rule IS '租户定义的指标告警规则';
+COMMENT ON COLUMN alert_rule.state IS '最近一次检查的结果，只在变化时发送通知';
+
+CREATE INDEX IF NOT EXISTS idx_alert_rule_enabled ON alert_rule (rule_id) WHERE enabled;
+
+DROP TRIGGER IF EXISTS update_alert_rule_updated_at ON alert_rule;
+CREATE TRIGGER update_alert_rule_updated_at
+    BEFORE UPDATE ON alert_rule
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE IF NOT EXISTS alert_event (
+    event_id BIGSERIAL PRIMARY KEY,
+    rule_id INTEGER NOT NULL REFERENCES alert_rule(rule_id) ON DELETE CASCADE,
+    tenant VARCHAR(100) NOT NULL,
+    merchant_id INTEGER NOT NULL,
+    state VARCHAR(10) NOT NULL CHECK (state IN ('firing', 'resolved')),
+    title TEXT NOT NULL,
+    text TEXT NOT NULL,
+    -- 检查时刻在商户时区的本地时间
+    timezone VARCHAR(50) NOT NULL,
+    local_time VARCHAR(30) NOT NULL,
+    details JSONB,
+    -- 每个通知渠道的发送结果
+    deliveries JSONB NOT NULL DEFAULT '[]',
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+COMMENT ON TABLE alert_event IS '告警历史：规则开始告警（firing）和恢复（resolved）的记录';
+
+CREATE INDEX IF NOT EXISTS idx_alert_event_tenant ON alert_event (tenant, occurred_at DESC);
+CREATE INDEX IF NOT EXISTS idx_alert_event_rule ON alert_event (rule_id, occurred_at DESC);
@@ -0,0 +1,30 @@
+-- =====================================================
+-- 邮件模板的租户覆盖、报表的邮件收件人
+-- 告警、报表完成和商户入驻邮件默认使用 go/mailtemplate 中的内置模板，
+-- 租户（X-Tenant-ID）可以按（模板名称，语言）保存自己的版本，发送时优先使用；
+-- 模板使用 Go 模板语法，时刻按收件商户的时区和语言格式化
+-- go/services/email.go 负责选择模板、渲染和发送
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS email_template (
+    tenant VARCHAR(100) NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    locale VARCHAR(10) NOT NULL,
+    subject TEXT NOT NULL,
+    html TEXT NOT NULL,
+    text TEXT NOT NULL DEFAULT '',
+    updated_by VARCHAR(100) NOT NULL DEFAULT 'system',
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (tenant, name, locale)
+);
+
+COMMENT ON TABLE email_template IS '租户覆盖的邮件模板，未覆盖时使用内置模板';
+COMMENT ON COLUMN email_template.locale IS '语言代码（zh、en 等），按收件商户的 locale 配置选择';
+
+DROP TRIGGER IF EXISTS update_email_template_updated_at ON email_template;
+CREATE TRIGGER update_email_template_updated_at
+    BEFORE UPDATE ON email_template
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- 报表执行完成后通知的邮箱，为空时不发送
+ALTER TABLE report_definition ADD COLUMN IF NOT EXISTS emails TEXT[] NOT NULL DEFAULT '{}';
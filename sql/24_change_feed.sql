@@ -0,0 +1,136 @@
+-- =====================================================
+-- 商户和订单的变更流水（增量同步）
+-- dim_merchant / dws_orders 的增删改由行级触发器写入 change_log，快照与接口返回的字段一致；
+-- 触发器只记录变更，不分配序号。读取前由 sequence_change_log() 在咨询锁内按写入顺序为已提交的变更分配 seq，
+-- 因此客户端看到 seq=N 时，所有 seq<N 的变更都已可见，按 since 增量拉取不会遗漏
+-- go/repository/postgres_change.go 负责编号和读取
+-- =====================================================
+
+CREATE SEQUENCE IF NOT EXISTS change_log_seq;
+
+CREATE TABLE IF NOT EXISTS change_log (
+    change_id BIGSERIAL PRIMARY KEY,
+    seq BIGINT UNIQUE,
+    entity VARCHAR(20) NOT NULL CHECK (entity IN ('merchant', 'order')),
+    entity_id INTEGER NOT NULL,
+    -- 商户变更为商户自身，订单变更为订单所属商户；商户删除后保留流水，不设外键
+    merchant_id INTEGER NOT NULL,
+    op VARCHAR(10) NOT NULL CHECK (op IN ('insert', 'update', 'delete')),
+    -- 变更后的快照，删除时为删除前的快照
+    snapshot JSONB NOT NULL,
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+COMMENT ON TABLE change_log IS '商户和订单的变更流水，seq 由 sequence_change_log() 分配，单调递增';
+COMMENT ON COLUMN change_log.seq IS '同步序号，尚未编号的变更为 NULL，客户端读取不到';
+
+CREATE INDEX IF NOT EXISTS idx_change_log_unsequenced ON change_log (change_id) WHERE seq IS NULL;
+CREATE INDEX IF NOT EXISTS idx_change_log_merchant_seq ON change_log (merchant_id, seq);
+
+-- 时刻统一格式化为 UTC 的 RFC 3339，与 Go 的 JSON 编码一致
+CREATE OR REPLACE FUNCTION change_log_time(t TIMESTAMP WITH TIME ZONE)
+RETURNS TEXT AS $$
+    SELECT TO_CHAR(t AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')
+$$ LANGUAGE sql IMMUTABLE;
+
+-- 商户快照，字段与 models.Merchant 的 JSON 一致
+CREATE OR REPLACE FUNCTION change_log_merchant_snapshot(m dim_merchant)
+RETURNS JSONB AS $$
+    SELECT jsonb_strip_nulls(jsonb_build_object(
+        'country_code', NULLIF(m.country_code, ''),
+        'city_id', m.city_id
+    )) || jsonb_build_object(
+        'id', m.merchant_id,
+        'name', m.merchant_name,
+        'timezone', m.timezone,
+        'country', m.country,
+        'city', m.city,
+        'description', '',
+        'business_hours_start', TO_CHAR(m.business_hours_start, 'HH24:MI'),
+        'business_hours_end', TO_CHAR(m.business_hours_end, 'HH24:MI'),
+        'weekend_days', COALESCE(to_jsonb(m.weekend_days), '[]'::jsonb),
+        'created_at', change_log_time(m.created_at),
+        'updated_at', change_log_time(m.updated_at)
+    )
+$$ LANGUAGE sql STABLE;
+
+-- 订单快照，字段与 models.Order 的 JSON 一致，金额为字符串以保留小数位
+CREATE OR REPLACE FUNCTION change_log_order_snapshot(o dws_orders)
+RETURNS JSONB AS $$
+    SELECT jsonb_build_object(
+        'id', o.order_id,
+        'merchant_id', o.merchant_id,
+        'order_number', o.order_no,
+        'amount', o.order_amount::text,
+        'currency', o.currency,
+        'status', o.order_status,
+        'order_time_utc', change_log_time(o.order_time_utc),
+        'created_at', change_log_time(o.created_at),
+        'updated_at', change_log_time(o.updated_at)
+    )
+$$ LANGUAGE sql STABLE;
+
+-- 记录一行变更；只改了快照以外的列（如 customer_email）或只刷新了 updated_at 的更新不记录
+CREATE OR REPLACE FUNCTION change_log_record()
+RETURNS TRIGGER AS $$
+DECLARE
+    old_snapshot JSONB;
+    new_snapshot JSONB;
+BEGIN
+    IF TG_TABLE_NAME = 'dim_merchant' THEN
+        IF TG_OP <> 'INSERT' THEN old_snapshot := change_log_merchant_snapshot(OLD); END IF;
+        IF TG_OP <> 'DELETE' THEN new_snapshot := change_log_merchant_snapshot(NEW); END IF;
+    ELSE
+        IF TG_OP <> 'INSERT' THEN old_snapshot := change_log_order_snapshot(OLD); END IF;
+        IF TG_OP <> 'DELETE' THEN new_snapshot := change_log_order_snapshot(NEW); END IF;
+    END IF;
+
+    IF TG_OP = 'UPDATE' AND (old_snapshot - 'updated_at') = (new_snapshot - 'updated_at') THEN
+        RETURN NULL;
+    END IF;
+
+    INSERT INTO change_log (entity, entity_id, merchant_id, op, snapshot)
+    VALUES (
+        CASE WHEN TG_TABLE_NAME = 'dim_merchant' THEN 'merchant' ELSE 'order' END,
+        (COALESCE(new_snapshot, old_snapshot) ->> 'id')::int,
+        CASE WHEN TG_TABLE_NAME = 'dim_merchant'
+            THEN (COALESCE(new_snapshot, old_snapshot) ->> 'id')::int
+            ELSE (COALESCE(new_snapshot, old_snapshot) ->> 'merchant_id')::int
+        END,
+        LOWER(TG_OP),
+        COALESCE(new_snapshot, old_snapshot)
+    );
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- 为已提交、尚未编号的变更按写入顺序分配 seq，返回编号的条数
+-- 咨询锁保证同一时刻只有一个编号事务，后一次编号的 seq 一定大于前一次，且在前一次提交后才可见
+CREATE OR REPLACE FUNCTION sequence_change_log()
+RETURNS BIGINT AS $$
+DECLARE
+    sequenced BIGINT;
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext('change_log_seq'));
+
+    UPDATE change_log c SET seq = s.seq
+    FROM (
+        SELECT change_id, nextval('change_log_seq') AS seq
+        FROM (SELECT change_id FROM change_log WHERE seq IS NULL ORDER BY change_id) pending
+    ) s
+    WHERE c.change_id = s.change_id;
+
+    GET DIAGNOSTICS sequenced = ROW_COUNT;
+    RETURN sequenced;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS change_log_merchant ON dim_merchant;
+CREATE TRIGGER change_log_merchant
+    AFTER INSERT OR UPDATE OR DELETE ON dim_merchant
+    FOR EACH ROW EXECUTE FUNCTION change_log_record();
+
+DROP TRIGGER IF EXISTS change_log_order ON dws_orders;
+CREATE TRIGGER change_log_order
+    AFTER INSERT OR UPDATE OR DELETE ON dws_orders
+    FOR EACH ROW EXECUTE FUNCTION change_log_record();
@@ -0,0 +1,72 @@
+-- =====================================================
+-- 离线同步（外勤平板）
+-- dws_orders 增加版本号，快照字段有变化的更新使版本号加一，客户端推送修改时带上所基于的版本，用于发现冲突；
+-- sync_mutation 保存每台设备每个修改的处理结果，网络中断后重复推送返回同一结果，不会重复写入
+-- go/repository/postgres_sync.go 负责读写，冲突规则见 go/services/sync.go
+-- =====================================================
+
+ALTER TABLE dws_orders ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+COMMENT ON COLUMN dws_orders.version IS '订单版本号，订单号、商户、金额、币种、状态或下单时间变化时加一';
+
+CREATE OR REPLACE FUNCTION bump_order_version()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF (NEW.order_no, NEW.merchant_id, NEW.order_amount, NEW.currency, NEW.order_status, NEW.order_time_utc)
+        IS DISTINCT FROM (OLD.order_no, OLD.merchant_id, OLD.order_amount, OLD.currency, OLD.order_status, OLD.order_time_utc) THEN
+        NEW.version := OLD.version + 1;
+    ELSE
+        NEW.version := OLD.version;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS bump_order_version ON dws_orders;
+CREATE TRIGGER bump_order_version
+    BEFORE UPDATE ON dws_orders
+    FOR EACH ROW EXECUTE FUNCTION bump_order_version();
+
+-- 订单快照增加版本号，客户端用变更流水中的版本作为下一次修改的 base_version
+CREATE OR REPLACE FUNCTION change_log_order_snapshot(o dws_orders)
+RETURNS JSONB AS $$
+    SELECT jsonb_build_object(
+        'id', o.order_id,
+        'merchant_id', o.merchant_id,
+        'order_number', o.order_no,
+        'amount', o.order_amount::text,
+        'currency', o.currency,
+        'status', o.order_status,
+        'order_time_utc', change_log_time(o.order_time_utc),
+        'version', o.version,
+        'created_at', change_log_time(o.created_at),
+        'updated_at', change_log_time(o.updated_at)
+    )
+$$ LANGUAGE sql STABLE;
+
+-- 设备最近一次同步，用于排查时钟偏差和同步进度
+CREATE TABLE IF NOT EXISTS sync_device (
+    device_id VARCHAR(100) PRIMARY KEY,
+    merchant_id INTEGER NOT NULL REFERENCES dim_merchant(merchant_id) ON DELETE CASCADE,
+    -- 设备时区，设备提交不带偏移的本地时间时按该时区换算
+    timezone VARCHAR(50) NOT NULL,
+    -- 服务器收到请求的时刻减去设备时钟，正数表示设备时钟偏慢
+    clock_skew_ms BIGINT NOT NULL DEFAULT 0,
+    last_pull_seq BIGINT NOT NULL DEFAULT 0,
+    last_sync_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS sync_mutation (
+    device_id VARCHAR(100) NOT NULL,
+    mutation_id VARCHAR(100) NOT NULL,
+    order_id INTEGER,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('applied', 'merged', 'rejected')),
+    -- 返回给设备的处理结果（models.SyncMutationResult）
+    result JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (device_id, mutation_id)
+);
+
+COMMENT ON TABLE sync_mutation IS '设备推送的订单修改及其处理结果，重复推送时原样返回';
+
+CREATE INDEX IF NOT EXISTS idx_sync_mutation_order ON sync_mutation (order_id);
@@ -0,0 +1,34 @@
+-- =====================================================
+-- 外部订单来源的 Webhook 接收（Shopify、Stripe）
+-- 签名校验通过的投递先原样写入 ingest_delivery，再映射为订单写入 dws_orders（order_source 为平台名称）；
+-- 处理失败的投递保留为 failed（死信），修正商户或数据后可以重放
+-- go/repository/postgres_ingest.go 负责读写，平台格式的映射见 go/services/ingest.go
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS ingest_delivery (
+    delivery_id BIGSERIAL PRIMARY KEY,
+    provider VARCHAR(20) NOT NULL,
+    -- 平台的投递ID（Shopify 的 X-Shopify-Webhook-Id、Stripe 的事件ID），用于去重
+    external_id VARCHAR(100) NOT NULL,
+    topic VARCHAR(100) NOT NULL DEFAULT '',
+    -- Webhook 地址中的 merchant_id，Stripe 事件也可以在 metadata.merchant_id 中指定
+    merchant_id INTEGER,
+    order_no VARCHAR(50),
+    order_id INTEGER,
+    -- 平台事件发生的时刻（UTC），同一订单只应用事件时刻不早于已处理投递的事件
+    event_time TIMESTAMP WITH TIME ZONE,
+    status VARCHAR(20) NOT NULL DEFAULT 'received'
+        CHECK (status IN ('received', 'processed', 'skipped', 'ignored', 'failed')),
+    error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    payload JSONB NOT NULL,
+    received_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    processed_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (provider, external_id)
+);
+
+COMMENT ON TABLE ingest_delivery IS '外部平台的 Webhook 投递，status=failed 的为死信，可以重放';
+COMMENT ON COLUMN ingest_delivery.status IS 'received 未处理，processed 已写入订单，skipped 事件早于已处理的事件，ignored 不处理的事件类型，failed 处理失败';
+
+CREATE INDEX IF NOT EXISTS idx_ingest_delivery_failed ON ingest_delivery (received_at) WHERE status = 'failed';
+CREATE INDEX IF NOT EXISTS idx_ingest_delivery_order ON ingest_delivery (provider, order_no, event_time) WHERE status = 'processed';
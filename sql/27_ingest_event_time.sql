@@ -0,0 +1,9 @@
+-- =====================================================
+-- 支付平台事件日期核对（/api/timezone/reconciliation/events）
+-- 按平台和事件时刻范围读取已写入订单的 Webhook 投递，与订单的本地日期比较
+-- go/repository/postgres_ingest.go 的 ProviderEvents 使用该索引
+-- =====================================================
+
+CREATE INDEX IF NOT EXISTS idx_ingest_delivery_event_time
+    ON ingest_delivery (provider, event_time)
+    WHERE status IN ('processed', 'skipped');
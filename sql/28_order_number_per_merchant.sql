@@ -0,0 +1,24 @@
+-- =====================================================
+-- 订单号按商户唯一
+-- 不同商户（不同店铺、不同支付账号）的订单号可以相同，dws_orders 的唯一约束由 order_no 改为 (merchant_id, order_no)；
+-- Webhook 写入该商户已存在的订单号时按商户配置 order_conflict_policy 处理（reject / upsert / version），
+-- 未写入的投递记为 conflict，冲突详情保存在 ingest_delivery.conflict
+-- go/repository/postgres_ingest.go 负责读写，冲突策略见 go/services/ingest.go
+-- =====================================================
+
+ALTER TABLE dws_orders DROP CONSTRAINT IF EXISTS dws_orders_order_no_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_orders_merchant_order_no ON dws_orders (merchant_id, order_no);
+
+ALTER TABLE ingest_delivery DROP CONSTRAINT IF EXISTS ingest_delivery_status_check;
+ALTER TABLE ingest_delivery ADD CONSTRAINT ingest_delivery_status_check
+    CHECK (status IN ('received', 'processed', 'skipped', 'ignored', 'conflict', 'failed'));
+
+-- 冲突详情：商户的冲突策略、已有订单的ID和版本，以及与投递不同的字段
+ALTER TABLE ingest_delivery ADD COLUMN IF NOT EXISTS conflict JSONB;
+
+COMMENT ON COLUMN ingest_delivery.status IS 'received 未处理，processed 已写入订单，skipped 事件早于已处理的事件，ignored 不处理的事件类型，conflict 订单已存在且按商户的冲突策略未写入，failed 处理失败';
+
+DROP INDEX IF EXISTS idx_ingest_delivery_order;
+-- 同一商户同一订单号已处理的事件（不限平台），version 策略按其中最晚的事件时刻判断
+CREATE INDEX IF NOT EXISTS idx_ingest_delivery_order ON ingest_delivery (merchant_id, order_no, event_time) WHERE status = 'processed';
@@ -0,0 +1,42 @@
+-- =====================================================
+-- 租户（商户）数据导出与删除
+-- 欧盟客户要求可以导出商户的全部数据，并在解约后删除或匿名化：
+--   delete     删除商户及其订单、配置、流水等全部数据
+--   anonymize  保留订单金额和时间用于历史统计，清除订单号、客户信息、商户名称等可识别字段，删除配置和流水
+-- 每次处理在一个事务中完成，提交前逐表核验没有残留，结果写入 tenant_erasure
+-- go/repository/postgres_tenant_data.go 负责导出、删除和核验
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS tenant_erasure (
+    erasure_id BIGSERIAL PRIMARY KEY,
+    -- 商户删除后仍保留记录，作为已删除的证明，不设外键
+    merchant_id INTEGER NOT NULL,
+    mode VARCHAR(20) NOT NULL CHECK (mode IN ('delete', 'anonymize')),
+    status VARCHAR(20) NOT NULL CHECK (status IN ('running', 'completed', 'failed')),
+    requested_by VARCHAR(100) NOT NULL,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    verified BOOLEAN NOT NULL DEFAULT FALSE,
+    -- 每张表处理的行数和残留行数（models.TenantErasureStep）
+    steps JSONB NOT NULL DEFAULT '[]',
+    error TEXT NOT NULL DEFAULT ''
+);
+
+COMMENT ON TABLE tenant_erasure IS '租户数据删除和匿名化记录及核验报告';
+COMMENT ON COLUMN tenant_erasure.verified IS '处理后每张表都没有该商户可识别的数据';
+
+CREATE INDEX IF NOT EXISTS idx_tenant_erasure_merchant ON tenant_erasure (merchant_id, started_at DESC);
+
+-- 快照与调整记录只允许插入；删除租户的事务设置 saasview.tenant_erasure = on 后可以删除或匿名化
+CREATE OR REPLACE FUNCTION reject_immutable_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF current_setting('saasview.tenant_erasure', true) = 'on' THEN
+        IF TG_OP = 'DELETE' THEN
+            RETURN OLD;
+        END IF;
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION '% 不允许 %，请追加调整记录', TG_TABLE_NAME, TG_OP;
+END;
+$$ language 'plpgsql';
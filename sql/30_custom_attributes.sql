@@ -0,0 +1,61 @@
+-- =====================================================
+-- 商户和订单的自定义属性（标签）
+-- 租户（X-Tenant-ID）先定义属性的键和类型，再为商户或订单设置取值：
+--   string   任意文本（最长 200 个字符）
+--   number   数字
+--   boolean  true / false
+--   date     YYYY-MM-DD
+--   enum     allowed_values 中的一个值
+-- 取值按租户隔离保存为 JSONB，订单列表可按取值过滤（tag=region:emea），分析接口可按取值分组（group_by=tag:region）；
+-- 同一个键同时定义在商户和订单上时，订单的取值覆盖商户的取值
+-- go/services/attributes.go 负责校验，go/repository/postgres_attribute.go 负责读写和分组统计
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS attribute_definition (
+    tenant VARCHAR(100) NOT NULL,
+    entity VARCHAR(20) NOT NULL CHECK (entity IN ('merchant', 'order')),
+    attr_key VARCHAR(50) NOT NULL CHECK (attr_key ~ '^[a-z][a-z0-9_]*$'),
+    value_type VARCHAR(20) NOT NULL CHECK (value_type IN ('string', 'number', 'boolean', 'date', 'enum')),
+    allowed_values TEXT[] NOT NULL DEFAULT '{}',
+    description TEXT NOT NULL DEFAULT '',
+    created_by VARCHAR(100) NOT NULL DEFAULT 'system',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (tenant, entity, attr_key),
+    CHECK (value_type <> 'enum' OR cardinality(allowed_values) > 0)
+);
+
+COMMENT ON TABLE attribute_definition IS '租户为商户和订单定义的自定义属性';
+
+DROP TRIGGER IF EXISTS update_attribute_definition_updated_at ON attribute_definition;
+CREATE TRIGGER update_attribute_definition_updated_at
+    BEFORE UPDATE ON attribute_definition
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE IF NOT EXISTS merchant_attributes (
+    tenant VARCHAR(100) NOT NULL,
+    merchant_id INTEGER NOT NULL REFERENCES dim_merchant(merchant_id) ON DELETE CASCADE,
+    attributes JSONB NOT NULL DEFAULT '{}',
+    updated_by VARCHAR(100) NOT NULL DEFAULT 'system',
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (tenant, merchant_id)
+);
+
+-- 订单归档或删除后取值随之删除
+CREATE TABLE IF NOT EXISTS order_attributes (
+    tenant VARCHAR(100) NOT NULL,
+    order_id INTEGER NOT NULL REFERENCES dws_orders(order_id) ON DELETE CASCADE,
+    merchant_id INTEGER NOT NULL,
+    attributes JSONB NOT NULL DEFAULT '{}',
+    updated_by VARCHAR(100) NOT NULL DEFAULT 'system',
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (tenant, order_id)
+);
+
+COMMENT ON TABLE merchant_attributes IS '租户为商户设置的自定义属性取值';
+COMMENT ON TABLE order_attributes IS '租户为订单设置的自定义属性取值，同名的键覆盖商户的取值';
+
+-- 按取值过滤（attributes @> '{"region":"emea"}'）
+CREATE INDEX IF NOT EXISTS idx_merchant_attributes_values ON merchant_attributes USING GIN (attributes jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS idx_order_attributes_values ON order_attributes USING GIN (attributes jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS idx_order_attributes_merchant ON order_attributes (merchant_id);
@@ -0,0 +1,30 @@
+-- =====================================================
+-- 保存的筛选视图
+-- 看板用户把常用的筛选条件（时区、商户、订单状态、日期范围）保存为命名视图，
+-- 订单列表和分析接口通过 view_id=<ID> 引用，请求中显式指定的参数优先；
+-- 视图按租户（X-Tenant-ID）隔离，同一租户内可以通过 ID 共享，owner 只用于按用户列出
+-- go/services/saved_view.go 负责校验和展开为查询参数
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS saved_view (
+    view_id SERIAL PRIMARY KEY,
+    tenant VARCHAR(100) NOT NULL,
+    owner VARCHAR(100) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    timezone VARCHAR(50) NOT NULL DEFAULT '',
+    merchant_ids INTEGER[] NOT NULL DEFAULT '{}',
+    statuses TEXT[] NOT NULL DEFAULT '{}',
+    -- 相对日期范围：today、yesterday、this_week、last_week、this_month、last_month、last_N_days，空为不限
+    date_range VARCHAR(30) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (tenant, owner, name)
+);
+
+COMMENT ON TABLE saved_view IS '看板用户保存的命名筛选条件，按 view_id 在订单和分析接口中引用';
+COMMENT ON COLUMN saved_view.merchant_ids IS '不设外键：商户删除后视图仍然可用，只是不再匹配该商户的订单';
+
+DROP TRIGGER IF EXISTS update_saved_view_updated_at ON saved_view;
+CREATE TRIGGER update_saved_view_updated_at
+    BEFORE UPDATE ON saved_view
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
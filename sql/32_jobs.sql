@@ -0,0 +1,67 @@
+-- =====================================================
+-- 后台任务
+-- 导出、报表、归档、回填等耗时操作作为任务写入 job 表，由 serve 中各队列的工作协程领取执行：
+-- 领取使用 FOR UPDATE SKIP LOCKED，多个实例同时运行时每个任务只会被一个实例执行；
+-- 失败的任务按指数退避重试，达到 max_attempts 后为 failed，可以通过 /api/admin/jobs/{id}/retry 重新排队
+-- job_schedule 保存定时计划（RRULE 加时区），到期时生成任务
+-- go/services/jobs.go 负责领取、心跳、重试和定时计划
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS job (
+    job_id BIGSERIAL PRIMARY KEY,
+    queue VARCHAR(50) NOT NULL,
+    kind VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(20) NOT NULL CHECK (status IN ('queued', 'running', 'succeeded', 'failed', 'cancelled')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL CHECK (max_attempts > 0),
+    -- 最早执行时刻：新任务为指定的延迟时刻，失败重试时为退避后的时刻
+    run_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    dedupe_key VARCHAR(200),
+    schedule_name VARCHAR(100),
+    -- 执行中的任务由 locked_by 实例定期刷新 locked_at，长时间没有刷新的任务视为实例已退出，重新排队
+    locked_by VARCHAR(200),
+    locked_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT NOT NULL DEFAULT '',
+    result JSONB,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+COMMENT ON TABLE job IS '后台任务，按队列由工作协程领取执行';
+COMMENT ON COLUMN job.attempts IS '已开始执行的次数，领取时加一';
+COMMENT ON COLUMN job.dedupe_key IS '不为空时同一键只能有一个排队或执行中的任务';
+COMMENT ON COLUMN job.schedule_name IS '由定时计划生成的任务对应的计划名称';
+
+CREATE INDEX IF NOT EXISTS idx_job_claim ON job (queue, run_at, job_id) WHERE status = 'queued';
+CREATE INDEX IF NOT EXISTS idx_job_running ON job (locked_at) WHERE status = 'running';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_job_dedupe ON job (dedupe_key)
+    WHERE dedupe_key IS NOT NULL AND status IN ('queued', 'running');
+
+DROP TRIGGER IF EXISTS update_job_updated_at ON job;
+CREATE TRIGGER update_job_updated_at
+    BEFORE UPDATE ON job
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE IF NOT EXISTS job_schedule (
+    name VARCHAR(100) PRIMARY KEY,
+    kind VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    -- RRULE 重复规则，按 timezone 的本地时间展开，如 FREQ=DAILY;BYHOUR=3
+    schedule VARCHAR(200) NOT NULL,
+    timezone VARCHAR(50) NOT NULL DEFAULT 'UTC',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+COMMENT ON TABLE job_schedule IS '后台任务的定时计划，到期时生成任务；上一次生成的任务仍在排队或执行时跳过本次';
+
+CREATE INDEX IF NOT EXISTS idx_job_schedule_due ON job_schedule (next_run_at) WHERE enabled;
+
+DROP TRIGGER IF EXISTS update_job_schedule_updated_at ON job_schedule;
+CREATE TRIGGER update_job_schedule_updated_at
+    BEFORE UPDATE ON job_schedule
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,56 @@
+-- =====================================================
+-- 租户开通
+-- POST /api/admin/tenants 在一个事务中创建商户、默认配置、Webhook 占位、API 密钥、隔离策略和一笔示例订单，
+-- 任一步失败时全部回滚；API 密钥只在开通的响应中返回一次，库中只保存 SHA-256 摘要
+-- 隔离方式：
+--   shared  与其他商户共用表，由应用按 merchant_id 过滤（默认）
+--   rls     另外创建租户专属的数据库角色 saasview_tenant_<商户ID> 和行级安全策略，
+--           该角色直接查询 dws_orders、dim_merchant 时只能看到本商户的行，供租户专属的 BI 连接或导出使用
+-- go/repository/postgres_tenant_provisioning.go 负责开通事务
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS tenant_api_key (
+    key_id SERIAL PRIMARY KEY,
+    merchant_id INTEGER NOT NULL REFERENCES dim_merchant(merchant_id),
+    -- 密钥的前 11 个字符（sv_ 及随后 8 个字符），用于在日志和列表中识别密钥
+    key_prefix VARCHAR(20) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    label VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+COMMENT ON TABLE tenant_api_key IS '租户的 API 密钥，只保存摘要，明文只在创建时返回一次';
+COMMENT ON COLUMN tenant_api_key.key_hash IS '完整密钥的 SHA-256（十六进制）';
+
+CREATE INDEX IF NOT EXISTS idx_tenant_api_key_merchant ON tenant_api_key (merchant_id);
+
+CREATE TABLE IF NOT EXISTS tenant_provisioning (
+    merchant_id INTEGER PRIMARY KEY REFERENCES dim_merchant(merchant_id),
+    isolation VARCHAR(20) NOT NULL CHECK (isolation IN ('shared', 'rls')),
+    -- isolation 为 rls 时租户专属的数据库角色
+    db_role VARCHAR(100),
+    sample_order_id INTEGER,
+    provisioned_by VARCHAR(100) NOT NULL,
+    provisioned_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+COMMENT ON TABLE tenant_provisioning IS '通过开通接口创建的租户及其隔离方式';
+
+-- rls 隔离：所有租户角色都是 saasview_tenant 的成员，由它统一授予表的读取权限，
+-- 每个租户角色再各有一条只匹配本商户的策略。应用的登录用户是表的所有者，不受行级安全策略限制
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'saasview_tenant') THEN
+        CREATE ROLE saasview_tenant NOLOGIN;
+    END IF;
+END
+$$;
+
+COMMENT ON ROLE saasview_tenant IS '租户专属角色的公共父角色，只能读取行级安全策略允许的行';
+
+GRANT USAGE ON SCHEMA public TO saasview_tenant;
+GRANT SELECT ON dws_orders, dim_merchant TO saasview_tenant;
+
+ALTER TABLE dws_orders ENABLE ROW LEVEL SECURITY;
+ALTER TABLE dim_merchant ENABLE ROW LEVEL SECURITY;
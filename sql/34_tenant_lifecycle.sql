@@ -0,0 +1,39 @@
+-- =====================================================
+-- 租户生命周期
+--   trial      试用中，试用到 trial_ends_on（商户本地日期）当天结束，之后由 serve 自动改为 suspended
+--   active     正式使用
+--   suspended  已暂停（欠费或试用到期），数据接口返回 402，仍可查看账单
+--   archived   已归档（解约），数据接口返回 403，仍可查看账单
+-- 每次变更写入 tenant_lifecycle_event 作为审计记录
+-- go/repository/postgres_lifecycle.go 负责状态变更
+-- =====================================================
+
+ALTER TABLE dim_merchant ADD COLUMN IF NOT EXISTS lifecycle VARCHAR(20) NOT NULL DEFAULT 'active';
+ALTER TABLE dim_merchant ADD COLUMN IF NOT EXISTS trial_ends_on DATE;
+ALTER TABLE dim_merchant ADD COLUMN IF NOT EXISTS lifecycle_changed_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE dim_merchant DROP CONSTRAINT IF EXISTS chk_merchant_lifecycle;
+ALTER TABLE dim_merchant ADD CONSTRAINT chk_merchant_lifecycle
+    CHECK (lifecycle IN ('trial', 'active', 'suspended', 'archived') AND (lifecycle <> 'trial' OR trial_ends_on IS NOT NULL));
+
+COMMENT ON COLUMN dim_merchant.lifecycle IS '租户生命周期：trial、active、suspended、archived';
+COMMENT ON COLUMN dim_merchant.trial_ends_on IS '试用的最后一天（商户本地日期），只对 trial 有意义';
+
+-- 试用到期检查只扫描试用中的商户
+CREATE INDEX IF NOT EXISTS idx_merchant_trial ON dim_merchant (trial_ends_on) WHERE lifecycle = 'trial';
+
+CREATE TABLE IF NOT EXISTS tenant_lifecycle_event (
+    event_id BIGSERIAL PRIMARY KEY,
+    merchant_id INTEGER NOT NULL REFERENCES dim_merchant(merchant_id),
+    from_state VARCHAR(20) NOT NULL,
+    to_state VARCHAR(20) NOT NULL,
+    trial_ends_on DATE,
+    reason TEXT NOT NULL DEFAULT '',
+    -- 操作人，试用自动到期为 system
+    changed_by VARCHAR(100) NOT NULL,
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+COMMENT ON TABLE tenant_lifecycle_event IS '租户生命周期的变更记录';
+
+CREATE INDEX IF NOT EXISTS idx_tenant_lifecycle_event_merchant ON tenant_lifecycle_event (merchant_id, event_id DESC);
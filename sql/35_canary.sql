@@ -0,0 +1,49 @@
+-- =====================================================
+-- 合成监控租户
+-- serve 定期以该租户走一遍商户 → 订单 → 分析的读取路径，记录端到端耗时并与预期合计比对
+-- 商户位于 Asia/Kathmandu（UTC+05:45），固定订单分布在本地日期 2000-01-01 的零点前后：
+-- 本地日期的划分出错时订单数或金额会与预期不一致
+-- 订单使用测试币种 XTS 和其他商户都没有订单的日期，不影响真实租户的分析结果
+-- go/services/canary.go 执行检查
+-- =====================================================
+
+INSERT INTO dim_merchant (merchant_name, merchant_code, country, city, timezone, status)
+VALUES ('合成监控租户', 'SYNTHETIC_CANARY', '尼泊尔', '加德满都', 'Asia/Kathmandu', 'active')
+ON CONFLICT (merchant_code) DO NOTHING;
+
+-- 前三笔在本地日期 2000-01-01 内（合计 60.75），后两笔分别在前一天和后一天
+INSERT INTO dws_orders (order_no, merchant_id, order_amount, currency, order_status, order_time_utc, order_source)
+SELECT o.order_no, m.merchant_id, o.amount, 'XTS', 'paid', o.local_time AT TIME ZONE m.timezone, 'canary'
+FROM dim_merchant m
+CROSS JOIN (VALUES
+    ('CANARY-1', 10.00, TIMESTAMP '2000-01-01 00:10:00'),
+    ('CANARY-2', 20.50, TIMESTAMP '2000-01-01 12:00:00'),
+    ('CANARY-3', 30.25, TIMESTAMP '2000-01-01 23:50:00'),
+    ('CANARY-4', 99.00, TIMESTAMP '1999-12-31 23:55:00'),
+    ('CANARY-5', 99.00, TIMESTAMP '2000-01-02 00:05:00')
+) AS o (order_no, amount, local_time)
+WHERE m.merchant_code = 'SYNTHETIC_CANARY'
+ON CONFLICT (merchant_id, order_no) DO NOTHING;
+
+-- 只有一行：合成监控租户和预期合计
+CREATE TABLE IF NOT EXISTS canary_tenant (
+    singleton BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (singleton),
+    merchant_id INTEGER NOT NULL REFERENCES dim_merchant(merchant_id),
+    canary_date DATE NOT NULL,
+    currency CHAR(3) NOT NULL,
+    expected_orders INTEGER NOT NULL,
+    expected_amount DECIMAL(15,2) NOT NULL
+);
+
+COMMENT ON TABLE canary_tenant IS '合成监控租户，修改固定订单时同时修改预期合计';
+
+INSERT INTO canary_tenant (merchant_id, canary_date, currency, expected_orders, expected_amount)
+SELECT merchant_id, DATE '2000-01-01', 'XTS', 3, 60.75
+FROM dim_merchant
+WHERE merchant_code = 'SYNTHETIC_CANARY'
+ON CONFLICT (singleton) DO UPDATE SET
+    merchant_id = EXCLUDED.merchant_id,
+    canary_date = EXCLUDED.canary_date,
+    currency = EXCLUDED.currency,
+    expected_orders = EXCLUDED.expected_orders,
+    expected_amount = EXCLUDED.expected_amount;
@@ -0,0 +1,28 @@
+-- =====================================================
+-- 执行计划回归检测
+-- serve 按归一化的语句指纹统计只读语句的耗时，每个统计周期采集一次 EXPLAIN；
+-- 部署版本（DEPLOY_VERSION）或表统计信息（ANALYZE）变化时开始新的周期，
+-- 最近一个周期的耗时中位数比上一个周期变慢超过阈值的语句在 /api/admin/plan-regressions 中列出
+-- go/repository/postgres_plan_regression.go 负责读写，统计 30 天未更新的周期会被清除
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS query_plan_stats (
+    fingerprint VARCHAR(16) NOT NULL,
+    -- 周期标识：部署版本@统计信息更新时间
+    epoch VARCHAR(200) NOT NULL,
+    deploy_version VARCHAR(100) NOT NULL,
+    stats_analyzed_at TIMESTAMP WITH TIME ZONE,
+    epoch_started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    -- 归一化的语句，字面量和占位符替换为 ?
+    query TEXT NOT NULL,
+    samples BIGINT NOT NULL,
+    median_ms DOUBLE PRECISION NOT NULL,
+    plan TEXT,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (fingerprint, epoch)
+);
+
+COMMENT ON TABLE query_plan_stats IS '语句指纹在各统计周期的耗时中位数和执行计划，多实例时后写入的实例覆盖先写入的';
+
+CREATE INDEX IF NOT EXISTS idx_query_plan_stats_epoch ON query_plan_stats (fingerprint, epoch_started_at DESC);
+CREATE INDEX IF NOT EXISTS idx_query_plan_stats_updated ON query_plan_stats (updated_at);
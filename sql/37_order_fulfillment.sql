@@ -0,0 +1,34 @@
+-- =====================================================
+-- 订单发货时间和履约时长分析（/api/timezone/merchants/{id}/fulfillment）
+-- shipped_time_utc 为订单首次变为 shipped 或 delivered 的时刻：
+-- Webhook 写入时取平台事件时刻，其余更新（离线同步、手工修改）由触发器取更新时刻；
+-- 直接以已发货状态写入的订单和本脚本之前已发货的订单没有发货时间，不参与履约时长统计
+-- go/repository/postgres_analysis.go 的 MerchantFulfillmentDurations 读取
+-- =====================================================
+
+ALTER TABLE dws_orders ADD COLUMN IF NOT EXISTS shipped_time_utc TIMESTAMP WITH TIME ZONE;
+
+COMMENT ON COLUMN dws_orders.shipped_time_utc IS '首次变为已发货或已送达的时刻（UTC），之后的状态变化不会清除';
+
+CREATE OR REPLACE FUNCTION set_order_shipped_time()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.shipped_time_utc IS NULL
+        AND NEW.order_status IN ('shipped', 'delivered')
+        AND COALESCE(OLD.order_status, '') NOT IN ('shipped', 'delivered') THEN
+        NEW.shipped_time_utc = CURRENT_TIMESTAMP;
+    END IF;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS set_orders_shipped_time ON dws_orders;
+CREATE TRIGGER set_orders_shipped_time
+    BEFORE UPDATE ON dws_orders
+    FOR EACH ROW
+    EXECUTE FUNCTION set_order_shipped_time();
+
+-- 按商户读取有发货时间的订单
+CREATE INDEX IF NOT EXISTS idx_orders_merchant_shipped
+    ON dws_orders (merchant_id, order_time_utc)
+    WHERE shipped_time_utc IS NOT NULL;